@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/audit"
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/spf13/cobra"
+)
+
+// auditSQLWidth is how much of a query audit list shows on one line
+const auditSQLWidth = 80
+
+func newAuditCmd() *cobra.Command {
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show the audit log of executed queries",
+		Long: `Show the queries run from the shell, the CLI, the HTTP API and the gRPC API,
+with the user or API token that ran them, how long they took and how many
+rows they returned. Entries are kept for audit.retention_days.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List recent queries, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuditList(cmd, "")
+		},
+	}
+
+	searchCmd := &cobra.Command{
+		Use:     "search <text>",
+		Short:   "List queries whose SQL contains text",
+		Args:    cobra.ExactArgs(1),
+		Example: "  pubdatahub audit search \"FROM items\" --since 24h --interface api",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuditList(cmd, args[0])
+		},
+	}
+
+	for _, cmd := range []*cobra.Command{listCmd, searchCmd} {
+		cmd.Flags().Int("limit", 50, "Most queries to show; 0 shows all")
+		cmd.Flags().String("source", "", "Only queries on this data source")
+		cmd.Flags().String("interface", "", "Only queries from this interface (cli, tui, api, grpc)")
+		cmd.Flags().String("user", "", "Only queries by this user or API token")
+		cmd.Flags().String("since", "", "Only queries from this long ago, e.g. 2h or 7d")
+		cmd.Flags().Bool("failed", false, "Only queries that failed")
+		cmd.Flags().Bool("json", false, "Print the entries as JSON")
+	}
+	auditCmd.AddCommand(listCmd, searchCmd)
+	return auditCmd
+}
+
+// runAuditList prints the audit entries selected by the flags of cmd whose
+// SQL contains text
+func runAuditList(cmd *cobra.Command, text string) error {
+	filter := audit.Filter{Text: text}
+	filter.Limit, _ = cmd.Flags().GetInt("limit")
+	filter.Source, _ = cmd.Flags().GetString("source")
+	filter.Interface, _ = cmd.Flags().GetString("interface")
+	filter.User, _ = cmd.Flags().GetString("user")
+	filter.Failed, _ = cmd.Flags().GetBool("failed")
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		age, err := jobs.ParseAge(since)
+		if err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		filter.Since = time.Now().Add(-age)
+	}
+
+	auditLog, err := audit.Open(config.AppConfig.StoragePath, 0)
+	if err != nil {
+		return err
+	}
+	defer auditLog.Close()
+	entries, err := auditLog.List(filter)
+	if err != nil {
+		return err
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No queries recorded")
+		return nil
+	}
+	for _, entry := range entries {
+		outcome := fmt.Sprintf("%d rows", entry.Rows)
+		if entry.Error != "" {
+			outcome = "failed: " + entry.Error
+		}
+		fmt.Printf("%s  %-4s  %-12s  %-10s  %8s  %s\n", entry.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			entry.Interface, entry.User, entry.Source, entry.Duration, outcome)
+		fmt.Printf("    %s\n", truncateSQL(entry.SQL, auditSQLWidth))
+	}
+	return nil
+}
+
+// truncateSQL puts sql on one line of at most width characters
+func truncateSQL(sql string, width int) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if runes := []rune(sql); len(runes) > width {
+		return string(runes[:width-3]) + "..."
+	}
+	return sql
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+func newBenchCmd() *cobra.Command {
+	benchCmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Measure how fast PubDataHub runs on this machine",
+	}
+
+	defaults := storage.DefaultBenchmarkConfig()
+	storageCmd := &cobra.Command{
+		Use:   "storage",
+		Short: "Benchmark inserts, queries and WAL checkpoints on a storage location",
+		Long: `Run a synthetic workload in a temporary SQLite database under the storage
+path, or --path, and report insert throughput, the latency of synced commits
+and of indexed and full-scan queries, and how the write-ahead log grows and
+checkpoints. Compare locations, such as an SSD and a network drive, before
+choosing a storage path. The temporary database is removed afterwards.`,
+		Example: "  pubdatahub bench storage\n  pubdatahub bench storage --path /mnt/nas/pubdatahub --items 20000",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if storage.ReadOnly() {
+				return fmt.Errorf("bench storage writes a temporary database and is not available in read-only mode")
+			}
+			dir, _ := cmd.Flags().GetString("path")
+			if dir == "" {
+				dir = config.AppConfig.StoragePath
+			}
+			benchConfig := storage.BenchmarkConfig{}
+			benchConfig.Items, _ = cmd.Flags().GetInt("items")
+			benchConfig.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+			benchConfig.Queries, _ = cmd.Flags().GetInt("queries")
+			benchConfig.Commits, _ = cmd.Flags().GetInt("commits")
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			// Interrupting still removes the temporary database
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			progress := func(stage string) { fmt.Fprintf(os.Stderr, "%s...\n", stage) }
+			if asJSON {
+				progress = nil
+			}
+			result, err := storage.RunBenchmark(ctx, dir, benchConfig, progress)
+			if err != nil {
+				return fmt.Errorf("benchmark failed: %w", err)
+			}
+
+			if asJSON {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(result)
+			}
+			printBenchmarkResult(result)
+			return nil
+		},
+	}
+	storageCmd.Flags().String("path", "", "Directory to benchmark (default: the storage path)")
+	storageCmd.Flags().Int("items", defaults.Items, "Synthetic items to insert")
+	storageCmd.Flags().Int("batch-size", defaults.BatchSize, "Items per insert transaction")
+	storageCmd.Flags().Int("queries", defaults.Queries, "Runs of each indexed query")
+	storageCmd.Flags().Int("commits", defaults.Commits, "Single-item commits synced to disk")
+	storageCmd.Flags().Bool("json", false, "Print the results as JSON")
+
+	benchCmd.AddCommand(storageCmd)
+	return benchCmd
+}
+
+// printBenchmarkResult prints a storage benchmark report
+func printBenchmarkResult(result *storage.BenchmarkResult) {
+	fmt.Printf("Storage benchmark of %s\n\n", result.Path)
+	fmt.Printf("Inserts:        %d items in %s, %.0f items/s\n", result.Items, result.InsertDuration.Round(time.Millisecond), result.ItemsPerSecond)
+	fmt.Printf("Synced commits: p50 %s  p95 %s  max %s\n",
+		formatLatency(result.Commits.P50), formatLatency(result.Commits.P95), formatLatency(result.Commits.Max))
+
+	fmt.Println("\nQueries:")
+	for _, query := range result.Queries {
+		fmt.Printf("  %-14s %4d runs  p50 %-9s p95 %-9s max %s\n", query.Name, query.Runs,
+			formatLatency(query.P50), formatLatency(query.P95), formatLatency(query.Max))
+	}
+
+	fmt.Println("\nWrite-ahead log:")
+	fmt.Printf("  Peak size:  %.1f MB\n", float64(result.WALPeakBytes)/1024/1024)
+	fmt.Printf("  Checkpoint: %d pages in %s\n", result.CheckpointPages, formatLatency(result.CheckpointDuration))
+	fmt.Printf("  Database:   %.1f MB after checkpoint\n", float64(result.DatabaseBytes)/1024/1024)
+
+	if warnings := result.Warnings(); len(warnings) > 0 {
+		fmt.Println("\nWarnings:")
+		for _, warning := range warnings {
+			fmt.Printf("  - %s\n", warning)
+		}
+	}
+}
+
+// formatLatency rounds a latency to a readable precision
+func formatLatency(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
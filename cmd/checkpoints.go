@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// checkpointProvider is implemented by data sources that keep pipeline
+// checkpoints
+type checkpointProvider interface {
+	Checkpoints() *storage.Checkpoints
+}
+
+func newCheckpointsCmd() *cobra.Command {
+	checkpointsCmd := &cobra.Command{
+		Use:   "checkpoints",
+		Short: "Manage the incremental cursors pipelines keep with a data source",
+		Long: `Manage checkpoints: values such as the last ID or timestamp a custom data
+source, enricher or plugin processed, kept in the data source database by
+namespace and key. The commands do not take the instance lock, so plugins
+run from the shell can read and save their cursors.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list <source> [namespace]",
+		Short: "List checkpoints, optionally of one namespace",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			namespace := ""
+			if len(args) == 2 {
+				namespace = args[1]
+			}
+			return withCheckpoints(args[0], func(checkpoints *storage.Checkpoints) error {
+				list, err := checkpoints.List(namespace)
+				if err != nil {
+					return err
+				}
+				if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+					encoder := json.NewEncoder(os.Stdout)
+					encoder.SetIndent("", "  ")
+					return encoder.Encode(list)
+				}
+				if len(list) == 0 {
+					fmt.Println("No checkpoints stored")
+					return nil
+				}
+				for _, checkpoint := range list {
+					fmt.Printf("%-32s %-24s updated %s\n", checkpoint.Namespace+"/"+checkpoint.Key,
+						checkpoint.Value, checkpoint.UpdatedAt.Local().Format("2006-01-02 15:04:05"))
+				}
+				return nil
+			})
+		},
+	}
+	listCmd.Flags().Bool("json", false, "Print the checkpoints as JSON")
+
+	getCmd := &cobra.Command{
+		Use:     "get <source> <namespace> <key>",
+		Short:   "Print the value of a checkpoint",
+		Args:    cobra.ExactArgs(3),
+		Example: "  LAST=$(pubdatahub checkpoints get hackernews my-enricher last_id --default 0)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withCheckpoints(args[0], func(checkpoints *storage.Checkpoints) error {
+				checkpoint, err := checkpoints.Get(args[1], args[2])
+				if err != nil {
+					return err
+				}
+				if checkpoint == nil {
+					if !cmd.Flags().Changed("default") {
+						return fmt.Errorf("checkpoint not found: %s/%s", args[1], args[2])
+					}
+					fallback, _ := cmd.Flags().GetString("default")
+					fmt.Println(fallback)
+					return nil
+				}
+				fmt.Println(checkpoint.Value)
+				return nil
+			})
+		},
+	}
+	getCmd.Flags().String("default", "", "Print this instead of failing when the checkpoint was never set")
+
+	setCmd := &cobra.Command{
+		Use:   "set <source> <namespace> <key> <value>",
+		Short: "Save the value of a checkpoint",
+		Args:  cobra.MinimumNArgs(4),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withCheckpoints(args[0], func(checkpoints *storage.Checkpoints) error {
+				return checkpoints.Set(args[1], args[2], strings.Join(args[3:], " "))
+			})
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete <source> <namespace> [key]",
+		Short: "Delete a checkpoint, or every checkpoint of a namespace",
+		Args:  cobra.RangeArgs(2, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := ""
+			if len(args) == 3 {
+				key = args[2]
+			}
+			return withCheckpoints(args[0], func(checkpoints *storage.Checkpoints) error {
+				removed, err := checkpoints.Delete(args[1], key)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Deleted %d checkpoints\n", removed)
+				return nil
+			})
+		},
+	}
+
+	pruneCmd := &cobra.Command{
+		Use:     "prune <source>",
+		Short:   "Delete checkpoints not updated for a while",
+		Args:    cobra.ExactArgs(1),
+		Example: "  pubdatahub checkpoints prune hackernews --older-than 90d",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			olderThan, _ := cmd.Flags().GetString("older-than")
+			age, err := jobs.ParseAge(olderThan)
+			if err != nil {
+				return fmt.Errorf("invalid --older-than: %w", err)
+			}
+			return withCheckpoints(args[0], func(checkpoints *storage.Checkpoints) error {
+				removed, err := checkpoints.Prune(time.Now().Add(-age))
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Pruned %d checkpoints not updated in %s\n", removed, olderThan)
+				return nil
+			})
+		},
+	}
+	pruneCmd.Flags().String("older-than", "", "Delete checkpoints not updated for this long, e.g. 30d")
+	pruneCmd.MarkFlagRequired("older-than")
+
+	checkpointsCmd.AddCommand(listCmd, getCmd, setCmd, deleteCmd, pruneCmd)
+	return checkpointsCmd
+}
+
+// withCheckpoints opens the checkpoints of a data source for fn
+func withCheckpoints(source string, fn func(*storage.Checkpoints) error) error {
+	ds, err := getDataSource(source, 100)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closer, ok := ds.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}()
+
+	provider, ok := ds.(checkpointProvider)
+	if !ok || provider.Checkpoints() == nil {
+		return fmt.Errorf("data source %s does not keep checkpoints", source)
+	}
+	return fn(provider.Checkpoints())
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/daemon"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/grpcserver"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+// tokenEnv names the environment variable holding the API token that
+// clients of the daemon send, so the daemon applies its role
+const tokenEnv = "PUBDATAHUB_TOKEN"
+
+// activeJobStates are the states of jobs shown by daemon status
+var activeJobStates = []jobs.JobState{jobs.JobStateQueued, jobs.JobStateRunning, jobs.JobStatePaused}
+
+func newDaemonCmd() *cobra.Command {
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run downloads, the scheduler and jobs in a background daemon",
+		Long: `Run downloads, scheduled syncs and other jobs in a background process that
+keeps running after the terminal closes. While the daemon runs, 'sources
+download' and the interactive shell attach to it: downloads and job commands
+are sent to the daemon over its control socket in the storage directory, and
+exiting the shell leaves them running.`,
+	}
+
+	startCmd := &cobra.Command{
+		Use:   "start",
+		Short: "Start the daemon in the background",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			storagePath := config.AppConfig.StoragePath
+			if status, err := daemon.NewClient(storagePath).Status(); err == nil {
+				fmt.Printf("Daemon is already running (pid %d)\n", status.PID)
+				return nil
+			}
+			if storage.ReadOnly() {
+				return fmt.Errorf("the daemon writes to storage and is not available with --read-only")
+			}
+			if err := storage.CheckWritable(storagePath); err != nil {
+				return err
+			}
+
+			executable, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to find the pubdatahub executable: %w", err)
+			}
+			runArgs := []string{"daemon", "run"}
+			if verbose {
+				runArgs = append(runArgs, "--verbose")
+			}
+			if grpcListen, _ := cmd.Flags().GetString("grpc-listen"); grpcListen != "" {
+				runArgs = append(runArgs, "--grpc-listen", grpcListen)
+			}
+			if err := storage.MkdirAll(config.LogDir()); err != nil {
+				return err
+			}
+			logPath := filepath.Join(config.LogDir(), "daemon.log")
+
+			command := exec.Command(executable, runArgs...)
+			if err := daemon.Start(command, logPath); err != nil {
+				return err
+			}
+			status, err := daemon.WaitReady(storagePath, command, 30*time.Second)
+			if err != nil {
+				return fmt.Errorf("%w; see %s", err, logPath)
+			}
+			fmt.Printf("Daemon started (pid %d), logging to %s\n", status.PID, logPath)
+			return nil
+		},
+	}
+
+	runCmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run the daemon in the foreground, e.g. under a service manager",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			grpcListen, _ := cmd.Flags().GetString("grpc-listen")
+			if grpcListen == "" {
+				grpcListen = config.AppConfig.GRPC.Listen
+			}
+			return runDaemon(grpcListen)
+		},
+	}
+
+	stopCmd := &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the daemon; running downloads resume when it starts again",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := daemonClient(config.AppConfig.StoragePath)
+			if err := client.Stop(); err != nil {
+				if errors.Is(err, daemon.ErrNotRunning) {
+					fmt.Println("Daemon is not running")
+					return nil
+				}
+				return err
+			}
+			fmt.Println("Stopping daemon...")
+			if err := client.WaitStopped(time.Minute); err != nil {
+				return err
+			}
+			fmt.Println("Daemon stopped")
+			return nil
+		},
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the daemon runs and its active jobs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := daemonClient(config.AppConfig.StoragePath)
+			status, err := client.Status()
+			if errors.Is(err, daemon.ErrNotRunning) {
+				fmt.Println("Daemon is not running")
+				os.Exit(3)
+			}
+			if err != nil {
+				return err
+			}
+			active, err := client.Jobs(jobs.JobFilter{States: activeJobStates})
+			if err != nil {
+				return err
+			}
+			printDaemonStatus(status, active)
+			return nil
+		},
+	}
+
+	for _, cmd := range []*cobra.Command{startCmd, runCmd} {
+		cmd.Flags().String("grpc-listen", "", "Serve the gRPC control API on this address (default grpc.listen)")
+	}
+	daemonCmd.AddCommand(startCmd, runCmd, stopCmd, statusCmd)
+	return daemonCmd
+}
+
+// runDaemon runs the job manager, the control socket and, with a listen
+// address, the gRPC control API until stopped by a signal or 'daemon stop'
+func runDaemon(grpcListen string) error {
+	storagePath := config.AppConfig.StoragePath
+	lock, err := acquireInstanceLock(daemon.LockCommand)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	dataSources := openDataSources()
+	jobManager, err := startJobManager(dataSources)
+	if err != nil {
+		return err
+	}
+
+	// The gRPC control API starts first, so a bad address fails before
+	// clients can attach
+	var grpcServer *grpc.Server
+	if grpcListen != "" {
+		if grpcServer, err = serveGRPC(grpcListen, jobManager, dataSources); err != nil {
+			jobManager.Stop()
+			return err
+		}
+	}
+
+	listener, err := daemon.Listen(storagePath)
+	if err != nil {
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+		jobManager.Stop()
+		return err
+	}
+	server := daemon.NewServer(storagePath, jobManager, dataSources)
+	auth, err := apiAuthenticator()
+	if err != nil {
+		server.Close()
+		listener.Close()
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+		jobManager.Stop()
+		return fmt.Errorf("control socket authentication: %w", err)
+	}
+	server.SetAuth(auth)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Logger.Errorf("Control socket error: %v", err)
+		}
+	}()
+	log.Logger.Infof("Daemon running (pid %d), control socket %s", os.Getpid(), daemon.SocketPath(storagePath))
+
+	// The daemon outlives the terminal that started it
+	signal.Ignore(syscall.SIGHUP)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-stop:
+	case <-server.Stopped():
+	}
+
+	log.Logger.Info("Stopping daemon...")
+	if grpcServer != nil {
+		// Watch streams keep running until stopped
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			grpcServer.Stop()
+		}
+	}
+	// Running jobs finish or are paused by jobs.drain_policy
+	if err := jobManager.Shutdown(context.Background()); err != nil {
+		log.Logger.Errorf("Failed to stop job manager: %v", err)
+	}
+	// The socket closes last, so 'daemon stop' returns once storage is free
+	lock.Release()
+	server.Close()
+	log.Logger.Info("Daemon stopped")
+	return nil
+}
+
+// serveGRPC serves the gRPC control API on addr with the API tokens
+// daemonClient returns a client for the daemon of a storage directory. The
+// token in PUBDATAHUB_TOKEN, if any, is sent with each request so the daemon
+// limits it to the token's role.
+func daemonClient(storagePath string) *daemon.Client {
+	return daemon.NewClient(storagePath).WithToken(os.Getenv(tokenEnv))
+}
+
+func serveGRPC(addr string, jobManager *jobs.EnhancedJobManager, dataSources map[string]datasource.DataSource) (*grpc.Server, error) {
+	auth, err := apiAuthenticator()
+	if err != nil {
+		return nil, fmt.Errorf("gRPC authentication: %w", err)
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := grpcserver.New(grpcserver.Config{
+		JobManager:  jobManager,
+		DataSources: dataSources,
+		StoragePath: config.AppConfig.StoragePath,
+		Version:     version,
+		Auth:        auth,
+	})
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Logger.Errorf("gRPC server error: %v", err)
+		}
+	}()
+	log.Logger.Infof("gRPC control API listening on %s", listener.Addr())
+	return server, nil
+}
+
+// printDaemonStatus prints the daemon status and its active jobs
+func printDaemonStatus(status *daemon.Status, active []*jobs.JobStatus) {
+	fmt.Printf("Daemon running (pid %d) since %s (%s)\n", status.PID,
+		status.StartedAt.Format(time.RFC3339), time.Since(status.StartedAt).Round(time.Second))
+	fmt.Printf("Storage: %s\n", status.StoragePath)
+	fmt.Printf("Role: %s\n", status.Role)
+	fmt.Printf("Sources: %v\n", status.Sources)
+	fmt.Printf("Jobs: %d running, %d queued, %d completed, %d failed\n", status.Stats.RunningJobs,
+		status.Stats.QueuedJobs, status.Stats.CompletedJobs, status.Stats.FailedJobs)
+	if len(active) == 0 {
+		fmt.Println("No active jobs")
+		return
+	}
+	fmt.Println("Active jobs:")
+	for _, job := range active {
+		fmt.Printf("  %s: %s (%s) - %.1f%% - %s\n", job.ID, job.Description, job.State,
+			job.Progress.Percentage(), job.Progress.Message)
+	}
+}
+
+// downloadInDaemon starts a download in the running daemon and follows its
+// progress; with detach, or on Ctrl+C, it returns and the download continues
+func downloadInDaemon(client *daemon.Client, sourceName string, batchSize int, detach bool) error {
+	job, err := client.Download(sourceName, batchSize, 0)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started download job %s in the daemon\n", job.ID)
+	if detach {
+		fmt.Println("Check it with 'pubdatahub daemon status'")
+		return nil
+	}
+	fmt.Println("Press Ctrl+C to detach; the download continues in the daemon")
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-interrupt:
+			fmt.Println("\nDetached; check the download with 'pubdatahub daemon status'")
+			return nil
+		case <-ticker.C:
+		}
+
+		job, err = client.Job(job.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\r%s: %.1f%% %s\033[K", job.State, job.Progress.Percentage(), job.Progress.Message)
+		if job.State.IsFinished() {
+			fmt.Println()
+			if job.State == jobs.JobStateFailed || job.State == jobs.JobStateDeadLetter {
+				return fmt.Errorf("download failed: %s", job.ErrorMessage)
+			}
+			fmt.Printf("Download %s\n", job.State)
+			return nil
+		}
+	}
+}
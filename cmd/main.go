@@ -2,24 +2,50 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/audit"
+	"github.com/brainless/PubDataHub/internal/clipboard"
 	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/daemon"
+	"github.com/brainless/PubDataHub/internal/dataset"
 	"github.com/brainless/PubDataHub/internal/datasource"
 	"github.com/brainless/PubDataHub/internal/datasource/hackernews"
+	"github.com/brainless/PubDataHub/internal/editor"
+	"github.com/brainless/PubDataHub/internal/httpclient"
+	"github.com/brainless/PubDataHub/internal/instance"
 	"github.com/brainless/PubDataHub/internal/jobs"
 	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/objectstore"
+	"github.com/brainless/PubDataHub/internal/progress"
+	"github.com/brainless/PubDataHub/internal/query"
+	"github.com/brainless/PubDataHub/internal/report"
+	"github.com/brainless/PubDataHub/internal/secrets"
+	"github.com/brainless/PubDataHub/internal/sshserver"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/brainless/PubDataHub/internal/transform"
+	"github.com/brainless/PubDataHub/internal/trash"
 	"github.com/brainless/PubDataHub/internal/tui"
+	"github.com/brainless/PubDataHub/internal/workspace"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var version = "dev"
 var verbose bool
+var readOnly bool
 
 // getDataSource creates and initializes a data source by name
 func getDataSource(name string, batchSize int) (datasource.DataSource, error) {
@@ -40,6 +66,92 @@ func getDataSource(name string, batchSize int) (datasource.DataSource, error) {
 	return ds, nil
 }
 
+// datasetPorter is implemented by data sources that can export and import
+// their database as a dataset archive
+type datasetPorter interface {
+	ExportDataset(archivePath string) (*dataset.Manifest, error)
+	ImportDataset(archivePath string) (*dataset.Manifest, error)
+}
+
+// getDatasetPorter opens a data source that supports dataset archives and
+// returns a function that closes it
+func getDatasetPorter(name string) (datasetPorter, func(), error) {
+	ds, err := getDataSource(name, 100)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeSource := func() {
+		if closer, ok := ds.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}
+
+	porter, ok := ds.(datasetPorter)
+	if !ok {
+		closeSource()
+		return nil, nil, fmt.Errorf("data source %s does not support dataset export", name)
+	}
+	return porter, closeSource, nil
+}
+
+// userRefresher is implemented by data sources that keep user profiles
+type userRefresher interface {
+	RefreshUsers(ctx context.Context, progress func(done, total int64)) error
+}
+
+// itemRepairer is implemented by data sources that re-check dead, deleted
+// and failed items
+type itemRepairer interface {
+	RepairItems(ctx context.Context, progress func(done, total int64)) error
+}
+
+// itemRedownloader is implemented by data sources that can fetch specific
+// items again
+type itemRedownloader interface {
+	RedownloadItems(ctx context.Context, ids []int64, progress func(done, total int64)) error
+}
+
+// itemArchiver is implemented by data sources that move old items into
+// compressed yearly archives
+type itemArchiver interface {
+	ArchiveItems(ctx context.Context, olderThanDays int, progress func(done, total int64)) error
+}
+
+// archiveQuerier is implemented by data sources whose queries can include
+// their archived items
+type archiveQuerier interface {
+	QueryWithArchives(query string) (datasource.QueryResult, error)
+}
+
+// threadLoader is implemented by data sources whose items form discussion threads
+type threadLoader interface {
+	Thread(rootID int64) (*datasource.ThreadItem, error)
+}
+
+// textEnricher is implemented by data sources whose item text can be enriched
+type textEnricher interface {
+	Enrichers() []string
+	Enrich(ctx context.Context, names []string, rerun bool, progress func(done, total int64)) error
+}
+
+// changePruner is implemented by data sources that record changes of
+// updated items
+type changePruner interface {
+	PruneChanges(olderThan time.Duration) (int64, error)
+}
+
+// snapshotBootstrapper is implemented by data sources that can be seeded
+// from a published snapshot
+type snapshotBootstrapper interface {
+	Bootstrap(ctx context.Context, location, sha256 string) (*hackernews.SnapshotResult, error)
+}
+
+// rankingCapturer is implemented by data sources with ranked lists
+type rankingCapturer interface {
+	RankingLists() []string
+	CaptureRanking(ctx context.Context, list string, depth int) (int, error)
+}
+
 func main() {
 	if err := newRootCmd().Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -74,6 +186,26 @@ Future data sources:
 				log.Logger.Fatalf("Failed to initialize configuration: %v", err)
 				return err
 			}
+			secrets.SetDefault(secrets.New(config.Dir()))
+
+			// The interactive shell starts with the setup wizard on first launch
+			if config.FirstRun() && cmd == cmd.Root() && len(args) == 0 && term.IsTerminal(int(os.Stdin.Fd())) {
+				if err := tui.NewSetupWizard(os.Stdin, os.Stdout).Run(); err != nil {
+					if !errors.Is(err, tui.ErrSetupCancelled) {
+						return err
+					}
+					fmt.Println("Setup skipped, using the default configuration. Run 'pubdatahub config setup' to change it.")
+				}
+			}
+
+			// File modes apply to the log files, so storage settings come first
+			applyStorageConfig()
+			applyLogConfig()
+			applyHTTPConfig()
+			applyExportConfig()
+			applyJobsConfig()
+			applySourceConfig()
+			applyAuditConfig()
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
@@ -82,6 +214,10 @@ Future data sources:
 				// Reinitialize logger for TUI mode to reduce log noise
 				log.InitLoggerForTUI(verbose)
 
+				if lock := lockShellStorage(); lock != nil {
+					defer lock.Release()
+				}
+
 				// Try to create enhanced shell first
 				enhancedShell, err := tui.NewEnhancedShell()
 				if err != nil {
@@ -112,16 +248,550 @@ Future data sources:
 	rootCmd.PersistentFlags().StringP("storage-path", "p", "", "Set storage path for data")
 	rootCmd.PersistentFlags().String("config", "", "Config file (default is $HOME/.pubdatahub.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
+	rootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", false, "Open storage read-only: queries work, downloads and other writes are disabled")
 
 	// Add subcommands
 	rootCmd.AddCommand(newConfigCmd())
 	rootCmd.AddCommand(newSourcesCmd())
 	rootCmd.AddCommand(newQueryCmd())
+	rootCmd.AddCommand(newAuditCmd())
+	rootCmd.AddCommand(newThreadCmd())
+	rootCmd.AddCommand(newReportCmd())
 	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newServeSSHCmd())
+	rootCmd.AddCommand(newDaemonCmd())
+	rootCmd.AddCommand(newCacheCmd())
+	rootCmd.AddCommand(newDoctorCmd())
+	rootCmd.AddCommand(newStorageCmd())
+	rootCmd.AddCommand(newPluginsCmd())
+	rootCmd.AddCommand(newCheckpointsCmd())
+	rootCmd.AddCommand(newMetricsCmd())
+	rootCmd.AddCommand(newBenchCmd())
+	addPluginCommands(rootCmd, discoverPlugins())
 
 	return rootCmd
 }
 
+// lockShellStorage takes the storage lock for the interactive shell. The shell
+// runs read-only instead when started with --read-only, when the user cannot
+// write to storage or when another instance holds the lock; when that
+// instance is the daemon, the shell attaches to it.
+func lockShellStorage() *instance.Lock {
+	if readOnly {
+		tui.SetReadOnly("started with --read-only")
+		return nil
+	}
+
+	storagePath := config.AppConfig.StoragePath
+	var denied *storage.PermissionError
+	if err := storage.CheckWritable(storagePath); errors.As(err, &denied) {
+		storage.SetReadOnly(true)
+		tui.SetReadOnly(fmt.Sprintf("no write permission for %s", denied.Path))
+		return nil
+	}
+
+	lock, err := instance.Acquire(storagePath, "shell")
+	var locked *instance.LockedError
+	switch {
+	case errors.As(err, &locked) && locked.Holder.Command == daemon.LockCommand:
+		client := daemonClient(storagePath)
+		status, err := client.Status()
+		if err == nil {
+			tui.AttachDaemon(client, status.PID, status.Role)
+			return nil
+		}
+		if !errors.Is(err, daemon.ErrNotRunning) {
+			tui.SetReadOnly(fmt.Sprintf("the daemon refused to attach: %v", err))
+			return nil
+		}
+		tui.SetReadOnly(fmt.Sprintf("storage is in use by %s, which does not answer; run 'pubdatahub daemon status' to check it", locked.Holder))
+		return nil
+	case errors.As(err, &locked):
+		tui.SetReadOnly(fmt.Sprintf("storage is in use by %s; run 'pubdatahub doctor' to inspect the lock", locked.Holder))
+		return nil
+	case err != nil:
+		log.Logger.Warnf("Failed to lock storage: %v", err)
+		return nil
+	}
+	return lock
+}
+
+// applyLogConfig applies per-component levels and file logging from the config
+func applyLogConfig() {
+	if err := log.SetComponentLevels(config.AppConfig.Log.Levels); err != nil {
+		log.Logger.Warnf("Ignoring log levels: %v", err)
+	}
+
+	// Log files live in the storage path, which read-only mode leaves alone
+	if config.AppConfig.Log.File && !storage.ReadOnly() {
+		modes := storage.CurrentFileModes()
+		rotation := log.RotationConfig{
+			MaxSizeMB:  config.AppConfig.Log.MaxSizeMB,
+			MaxAgeDays: config.AppConfig.Log.MaxAgeDays,
+			MaxBackups: config.AppConfig.Log.MaxBackups,
+			FileMode:   modes.File,
+			DirMode:    modes.Dir,
+		}
+		if err := log.EnableFileLogging(config.LogDir(), rotation); err != nil {
+			log.Logger.Warnf("File logging disabled: %v", err)
+		}
+	}
+}
+
+// applyHTTPConfig sets the HTTP client defaults and per-source overrides
+// used by data sources
+func applyHTTPConfig() {
+	httpConfig := config.AppConfig.HTTP
+	global := httpOverride(httpConfig.HTTPClientConfig)
+
+	defaults := global.Apply(httpclient.DefaultConfig())
+	defaults.CacheEnabled = httpConfig.Cache.Enabled
+	defaults.CacheMaxBytes = httpConfig.Cache.MaxSizeMB * 1024 * 1024
+	httpclient.SetDefaults(defaults)
+
+	for source, sourceConfig := range httpConfig.Sources {
+		httpclient.SetSourceOverride(source, httpOverride(sourceConfig))
+	}
+}
+
+// applyAuditConfig records the queries run by this process in the audit
+// log of the storage path
+func applyAuditConfig() {
+	auditConfig := config.AppConfig.Audit
+	if !auditConfig.Enabled {
+		audit.Disable()
+		return
+	}
+	audit.Enable(config.AppConfig.StoragePath, time.Duration(auditConfig.RetentionDays)*24*time.Hour)
+}
+
+// applyExportConfig sets the object storage exports are uploaded to and the
+// hooks run after every export job
+func applyExportConfig() {
+	s3Config := config.AppConfig.Export.S3
+	objectstore.SetDefaults(objectstore.Settings{
+		Endpoint:  s3Config.Endpoint,
+		Region:    s3Config.Region,
+		PathStyle: s3Config.PathStyle,
+		PartSize:  int64(s3Config.PartSizeMB) * 1024 * 1024,
+	})
+
+	var hooks []query.ExportHook
+	for _, hookConfig := range config.AppConfig.Export.Hooks {
+		hook := query.ExportHook{Command: hookConfig.Command, Webhook: hookConfig.Webhook}
+		if err := hook.Validate(); err != nil {
+			log.Logger.Warnf("Ignoring export hook: %v", err)
+			continue
+		}
+		hooks = append(hooks, hook)
+	}
+	query.SetExportHooks(hooks)
+}
+
+// httpOverride converts HTTP client settings from the config
+func httpOverride(clientConfig config.HTTPClientConfig) httpclient.Override {
+	return httpclient.Override{
+		Timeout:   time.Duration(clientConfig.TimeoutSeconds) * time.Second,
+		Proxy:     clientConfig.Proxy,
+		CABundle:  clientConfig.CABundle,
+		UserAgent: clientConfig.UserAgent,
+	}
+}
+
+// applyJobsConfig sets how scheduled jobs catch up on runs missed while the
+// application was not running and handle runs that would overlap
+func applyJobsConfig() {
+	jobsConfig := config.AppConfig.Jobs
+	defaults := schedulePolicy("jobs", jobsConfig.ScheduleConfig, jobs.SchedulePolicy{
+		CatchUp:     jobs.CatchUpSkip,
+		Concurrency: jobs.ConcurrencyForbid,
+	})
+	schedules := make(map[string]jobs.SchedulePolicy, len(jobsConfig.Schedules))
+	for id, scheduleConfig := range jobsConfig.Schedules {
+		schedules[id] = schedulePolicy("jobs.schedules."+id, scheduleConfig, defaults)
+	}
+	jobs.SetSchedulePolicies(defaults, schedules)
+}
+
+// schedulePolicy converts schedule settings from the config; unset or
+// invalid settings keep those of fallback
+func schedulePolicy(key string, scheduleConfig config.ScheduleConfig, fallback jobs.SchedulePolicy) jobs.SchedulePolicy {
+	policy := fallback
+	if scheduleConfig.CatchUp != "" {
+		if catchUp, err := jobs.ParseCatchUpPolicy(scheduleConfig.CatchUp); err != nil {
+			log.Logger.Warnf("Ignoring %s.catch_up: %v", key, err)
+		} else {
+			policy.CatchUp = catchUp
+		}
+	}
+	if scheduleConfig.MaxCatchUp > 0 {
+		policy.MaxCatchUp = scheduleConfig.MaxCatchUp
+	}
+	if scheduleConfig.Concurrency != "" {
+		if concurrency, err := jobs.ParseConcurrencyPolicy(scheduleConfig.Concurrency); err != nil {
+			log.Logger.Warnf("Ignoring %s.concurrency: %v", key, err)
+		} else {
+			policy.Concurrency = concurrency
+		}
+	}
+	return policy
+}
+
+// applyStorageConfig selects the database layout used by jobs, progress
+// tracking and data sources, and the permissions of files created in storage
+func applyStorageConfig() {
+	storage.SetReadOnly(readOnly)
+
+	storageConfig := config.AppConfig.Storage
+	modes := storage.DefaultFileModes()
+	if storageConfig.FileMode != "" {
+		if mode, err := storage.ParseFileMode(storageConfig.FileMode); err != nil {
+			log.Logger.Warnf("Ignoring storage.file_mode: %v", err)
+		} else {
+			modes.File = mode
+		}
+	}
+	if storageConfig.DirMode != "" {
+		if mode, err := storage.ParseFileMode(storageConfig.DirMode); err != nil {
+			log.Logger.Warnf("Ignoring storage.dir_mode: %v", err)
+		} else {
+			modes.Dir = mode
+		}
+	}
+	storage.SetFileModes(modes)
+	if storageConfig.Umask != "" {
+		if mask, err := storage.ParseFileMode(storageConfig.Umask); err != nil {
+			log.Logger.Warnf("Ignoring storage.umask: %v", err)
+		} else {
+			storage.SetUmask(int(mask))
+		}
+	}
+
+	switch layout := config.AppConfig.Storage.Layout; layout {
+	case storage.LayoutShared:
+		storage.UseSharedDatabase(filepath.Join(config.AppConfig.StoragePath, storage.SharedDatabaseFile))
+	case storage.LayoutSeparate, "":
+		storage.UseSharedDatabase("")
+	default:
+		log.Logger.Warnf("Unknown storage.layout %q, using %s", layout, storage.LayoutSeparate)
+		storage.UseSharedDatabase("")
+	}
+}
+
+// applySourceConfig applies per-source API settings, batch sizes, re-check
+// intervals, change tracking, transform hooks and conflict strategies from
+// the config
+func applySourceConfig() {
+	if sourceConfig, ok := config.AppConfig.DataSources["hackernews"]; ok {
+		for key, err := range config.InvalidSourceSettings("hackernews") {
+			log.Logger.Warnf("Ignoring data_sources.hackernews.%s: %v", key, err)
+			switch key {
+			case "rate_limit":
+				sourceConfig.RateLimit = 0
+			case "batch_size":
+				sourceConfig.BatchSize = 0
+			case "workers":
+				sourceConfig.Workers = 0
+			case "api_base_url":
+				sourceConfig.APIBaseURL = ""
+			}
+		}
+		hackernews.SetRateLimit(sourceConfig.RateLimit)
+		hackernews.SetBatchSize(sourceConfig.BatchSize)
+		hackernews.SetWorkers(sourceConfig.Workers)
+		hackernews.SetAPIBaseURL(sourceConfig.APIBaseURL)
+		hackernews.SetRecheckInterval(time.Duration(sourceConfig.RecheckHours) * time.Hour)
+		hackernews.SetTrackChanges(sourceConfig.TrackChanges)
+		hackernews.SetChangeRetention(time.Duration(sourceConfig.ChangeRetentionDays) * 24 * time.Hour)
+		hackernews.SetTransforms(loadTransforms("hackernews", sourceConfig))
+		strategy, err := hackernews.ParseConflictStrategy(sourceConfig.ConflictStrategy)
+		if err != nil {
+			log.Logger.Warnf("Ignoring conflict_strategy of hackernews: %v", err)
+		}
+		hackernews.SetConflictStrategy(strategy)
+	}
+}
+
+// loadTransforms loads the transform hooks of a source. A hook that fails to
+// load is logged and the chain returned fails every item, so nothing is
+// stored without its transformation.
+func loadTransforms(source string, sourceConfig config.DataSourceConfig) *transform.Chain {
+	if len(sourceConfig.Transforms) == 0 {
+		return nil
+	}
+	paths := make([]string, len(sourceConfig.Transforms))
+	for i, path := range sourceConfig.Transforms {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(config.Dir(), path)
+		}
+		paths[i] = path
+	}
+	chain, err := transform.LoadChain(paths, time.Duration(sourceConfig.TransformTimeoutMs)*time.Millisecond)
+	if err != nil {
+		log.Logger.Errorf("Transform hooks of %s failed to load, items will not be stored: %v", source, err)
+	}
+	return chain
+}
+
+// componentDatabases returns the database files of the separate layout
+func componentDatabases(storagePath string) []string {
+	return []string{
+		filepath.Join(storagePath, jobs.DatabaseFile),
+		filepath.Join(storagePath, progress.DatabaseFile),
+		hackernews.DatabasePath(storagePath),
+	}
+}
+
+func newStorageCmd() *cobra.Command {
+	storageCmd := &cobra.Command{
+		Use:   "storage",
+		Short: "Manage the database layout",
+	}
+
+	infoCmd := &cobra.Command{
+		Use:   "info",
+		Short: "Show the database layout and files",
+		Run: func(cmd *cobra.Command, args []string) {
+			storagePath := config.AppConfig.StoragePath
+			databases := componentDatabases(storagePath)
+			if shared := storage.SharedDatabase(); shared != "" {
+				fmt.Printf("Layout: %s\n", storage.LayoutShared)
+				databases = []string{shared}
+			} else {
+				fmt.Printf("Layout: %s\n", storage.LayoutSeparate)
+			}
+
+			for _, path := range databases {
+				stat, err := os.Stat(path)
+				if err != nil {
+					fmt.Printf("  %-60s (not created)\n", path)
+					continue
+				}
+				fmt.Printf("  %-60s %8.1f MB\n", path, float64(stat.Size())/1024/1024)
+			}
+		},
+	}
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Move the per-component databases into one shared database",
+		Long: `Copy the jobs, progress and data source databases into a single WAL-mode
+database (` + storage.SharedDatabaseFile + ` in the storage path) and switch storage.layout to
+shared. The original files are kept with a ` + storage.MigratedSuffix + ` suffix.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if storage.SharedDatabase() != "" {
+				log.Logger.Info("Storage already uses the shared layout")
+				return
+			}
+
+			lock, err := acquireInstanceLock("storage migrate")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer lock.Release()
+
+			storagePath := config.AppConfig.StoragePath
+			sharedPath := filepath.Join(storagePath, storage.SharedDatabaseFile)
+			migrated, err := storage.MigrateToShared(sharedPath, componentDatabases(storagePath))
+			if err != nil && len(migrated) == 0 {
+				log.Logger.Errorf("Migration failed: %v", err)
+				return
+			}
+			if err != nil {
+				log.Logger.Warnf("Migration completed with a warning: %v", err)
+			}
+			if len(migrated) == 0 {
+				log.Logger.Info("No existing databases to migrate")
+			}
+			for _, database := range migrated {
+				log.Logger.Infof("Migrated %s (%d tables), backup at %s", database.Path, len(database.Tables), database.Backup)
+			}
+
+			if err := config.Set("storage.layout", storage.LayoutShared, config.OriginCLI); err != nil {
+				log.Logger.Errorf("Migrated, but failed to switch storage.layout to shared: %v", err)
+				return
+			}
+			log.Logger.Infof("Storage now uses the shared database %s", sharedPath)
+		},
+	}
+
+	usageCmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Show the storage used by each data source and its quota",
+		Long: `Show the size of each data source's directory under the storage path and
+its data_sources.<name>.quota_mb. Downloads of a source pause once it reaches
+its quota, after a warning at storage.quota_warn_percent.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if storage.SharedDatabase() != "" {
+				fmt.Println("Note: with the shared layout, data kept in the shared database is not counted")
+			}
+
+			sources := []string{"hackernews"}
+			for name := range config.AppConfig.DataSources {
+				sources = append(sources, name)
+			}
+			usage := jobs.MeasureUsage(config.AppConfig.StoragePath, sources,
+				config.AppConfig.SourceQuotas(), config.AppConfig.Storage.QuotaWarnPercent)
+
+			fmt.Printf("%-14s %10s %10s %6s  %s\n", "SOURCE", "USED", "QUOTA", "USE", "STATE")
+			for _, source := range usage {
+				quota, percent, state := "-", "-", "ok"
+				if source.QuotaBytes > 0 {
+					quota = fmt.Sprintf("%.1f MB", float64(source.QuotaBytes)/1024/1024)
+					percent = fmt.Sprintf("%.0f%%", source.Percent())
+				}
+				switch {
+				case source.Error != "":
+					state = "error: " + source.Error
+				case source.Exceeded:
+					state = "over quota, downloads paused"
+				case source.Warning:
+					state = "near quota"
+				}
+				fmt.Printf("%-14s %7.1f MB %10s %6s  %s\n", source.Source, float64(source.UsedBytes)/1024/1024, quota, percent, state)
+			}
+		},
+	}
+
+	storageCmd.AddCommand(infoCmd, migrateCmd, usageCmd)
+	return storageCmd
+}
+
+// acquireInstanceLock takes the storage lock for commands that write to
+// storage, explaining how to recover when another instance holds it
+func acquireInstanceLock(command string) (*instance.Lock, error) {
+	if storage.ReadOnly() {
+		return nil, fmt.Errorf("%s writes to storage and is not available with --read-only", command)
+	}
+	if err := storage.CheckWritable(config.AppConfig.StoragePath); err != nil {
+		return nil, err
+	}
+
+	lock, err := instance.Acquire(config.AppConfig.StoragePath, command)
+	var locked *instance.LockedError
+	if errors.As(err, &locked) {
+		return nil, fmt.Errorf("%w; if it is no longer running, run 'pubdatahub doctor --force-unlock'", err)
+	}
+	return lock, err
+}
+
+func newDoctorCmd() *cobra.Command {
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check storage health and recover from a stuck instance lock",
+		Long: `Check the storage path and the instance lock that keeps two pubdatahub
+processes from writing to the same storage. Use --force-unlock to remove a lock
+left behind by a process that is no longer running.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			forceUnlock, _ := cmd.Flags().GetBool("force-unlock")
+			storagePath := config.AppConfig.StoragePath
+
+			fmt.Printf("Storage path: %s\n", storagePath)
+			if _, err := os.Stat(storagePath); err != nil {
+				fmt.Printf("  ✗ %v\n", err)
+			} else if err := storage.CheckWritable(storagePath); err != nil {
+				fmt.Printf("  ✗ %v\n", err)
+			} else {
+				fmt.Println("  ✓ exists and is writable")
+			}
+
+			holder, err := instance.Read(storagePath)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			fmt.Printf("Instance lock: %s\n", instance.LockPath(storagePath))
+			switch {
+			case holder == nil:
+				fmt.Println("  ✓ not locked")
+			case holder.Stale():
+				fmt.Printf("  ! stale lock from %s, last heartbeat %s\n", holder, holder.HeartbeatAt.Format(time.RFC3339))
+			default:
+				fmt.Printf("  ✓ held by %s, last heartbeat %s\n", holder, holder.HeartbeatAt.Format(time.RFC3339))
+			}
+
+			if !forceUnlock {
+				if holder != nil && holder.Stale() {
+					fmt.Println("Run 'pubdatahub doctor --force-unlock' to remove the stale lock")
+				}
+				return
+			}
+			if holder != nil && !holder.Stale() {
+				fmt.Printf("Warning: %s still looks alive; forcing the unlock lets another instance write to the same storage\n", holder)
+			}
+			previous, err := instance.ForceUnlock(storagePath)
+			if err != nil {
+				log.Logger.Errorf("Force unlock failed: %v", err)
+				return
+			}
+			if previous == nil {
+				fmt.Println("Nothing to unlock")
+				return
+			}
+			fmt.Println("Lock removed")
+		},
+	}
+
+	doctorCmd.Flags().Bool("force-unlock", false, "Remove the instance lock even if a process still holds it")
+	return doctorCmd
+}
+
+func newCacheCmd() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage local caches",
+	}
+
+	httpCmd := &cobra.Command{
+		Use:   "http",
+		Short: "Manage the HTTP response cache",
+	}
+
+	openCache := func() (*httpclient.Cache, error) {
+		maxBytes := config.AppConfig.HTTP.Cache.MaxSizeMB * 1024 * 1024
+		return httpclient.NewCache(httpclient.CacheDir(config.AppConfig.StoragePath), maxBytes)
+	}
+
+	// cache http clear subcommand
+	clearCmd := &cobra.Command{
+		Use:   "clear",
+		Short: "Remove all cached HTTP responses",
+		Run: func(cmd *cobra.Command, args []string) {
+			cache, err := openCache()
+			if err != nil {
+				log.Logger.Errorf("Failed to open HTTP cache: %v", err)
+				return
+			}
+			if err := cache.Clear(); err != nil {
+				log.Logger.Errorf("Failed to clear HTTP cache: %v", err)
+				return
+			}
+			log.Logger.Info("HTTP cache cleared")
+		},
+	}
+
+	// cache http stats subcommand
+	statsCmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show HTTP cache size",
+		Run: func(cmd *cobra.Command, args []string) {
+			cache, err := openCache()
+			if err != nil {
+				log.Logger.Errorf("Failed to open HTTP cache: %v", err)
+				return
+			}
+			stats := cache.Stats()
+			log.Logger.Infof("HTTP cache: %s", stats.Dir)
+			log.Logger.Infof("Entries: %d, size: %.1f MB", stats.Entries, float64(stats.Size)/(1024*1024))
+		},
+	}
+
+	httpCmd.AddCommand(clearCmd, statsCmd)
+	cacheCmd.AddCommand(httpCmd)
+	return cacheCmd
+}
+
 func newConfigCmd() *cobra.Command {
 	configCmd := &cobra.Command{
 		Use:   "config",
@@ -136,7 +806,7 @@ func newConfigCmd() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			newPath := args[0]
-			if err := config.SetStoragePath(newPath); err != nil {
+			if err := config.SetStoragePath(newPath, config.OriginCLI); err != nil {
 				log.Logger.Errorf("Failed to set storage path: %v", err)
 				return
 			}
@@ -144,6 +814,67 @@ func newConfigCmd() *cobra.Command {
 		},
 	}
 
+	// config set subcommand
+	setCmd := &cobra.Command{
+		Use:   "set [key] [value]",
+		Short: "Set a configuration value (e.g. log.levels.jobs debug)",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := config.Set(args[0], args[1], config.OriginCLI); err != nil {
+				log.Logger.Errorf("Failed to set %s: %v", args[0], err)
+				return
+			}
+			log.Logger.Infof("%s set to: %s", args[0], args[1])
+		},
+	}
+
+	// config source subcommand
+	sourceCmd := &cobra.Command{
+		Use:   "source",
+		Short: "Show and set per-source settings such as batch_size and rate_limit",
+	}
+	sourceShowCmd := &cobra.Command{
+		Use:   "show [source]",
+		Short: "Show the settings of data sources with their defaults and allowed values",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			descriptors := datasource.Descriptors()
+			if len(args) == 1 {
+				descriptor, err := datasource.LookupDescriptor(args[0])
+				if err != nil {
+					return err
+				}
+				descriptors = []datasource.Descriptor{descriptor}
+			}
+			for _, descriptor := range descriptors {
+				fmt.Printf("%s: %s\n", descriptor.Name, descriptor.Description)
+				for _, setting := range descriptor.Settings {
+					value, set := config.SourceSetting(descriptor.Name, setting)
+					origin := "default"
+					if set {
+						origin = "default " + setting.Default
+					}
+					fmt.Printf("  %-14s %-40s (%s; %s)  %s\n", setting.Key, value, origin, setting.Range(), setting.Description)
+				}
+			}
+			return nil
+		},
+	}
+	sourceSetCmd := &cobra.Command{
+		Use:     "set <source> <key> <value>",
+		Short:   "Set a per-source setting, checked against its allowed values",
+		Args:    cobra.ExactArgs(3),
+		Example: "  pubdatahub config source set hackernews batch_size 500",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.SetSourceSetting(args[0], args[1], args[2], config.OriginCLI); err != nil {
+				return err
+			}
+			log.Logger.Infof("%s %s set to: %s", args[0], args[1], args[2])
+			return nil
+		},
+	}
+	sourceCmd.AddCommand(sourceShowCmd, sourceSetCmd)
+
 	// config show subcommand
 	showCmd := &cobra.Command{
 		Use:   "show",
@@ -151,6 +882,18 @@ func newConfigCmd() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			log.Logger.Info("Current configuration:")
 			log.Logger.Infof("Storage path: %s", config.AppConfig.StoragePath)
+			log.Logger.Infof("Log files: %t (%s)", config.AppConfig.Log.File, config.LogDir())
+			for component, level := range config.AppConfig.Log.Levels {
+				log.Logger.Infof("Log level %s: %s", component, level)
+			}
+			if proxy := config.AppConfig.HTTP.Proxy; proxy != "" {
+				log.Logger.Infof("HTTP proxy: %s", proxy)
+			}
+			for source, sourceConfig := range config.AppConfig.HTTP.Sources {
+				if sourceConfig.Proxy != "" {
+					log.Logger.Infof("HTTP proxy for %s: %s", source, sourceConfig.Proxy)
+				}
+			}
 			// You can add more config fields here as they are added to config.AppConfig
 		},
 	}
@@ -179,49 +922,211 @@ func newConfigCmd() *cobra.Command {
 		},
 	}
 
-	configCmd.AddCommand(setStorageCmd, showCmd, validateCmd)
-	return configCmd
-}
-
-func newSourcesCmd() *cobra.Command {
-	sourcesCmd := &cobra.Command{
-		Use:   "sources",
-		Short: "Manage data sources",
-		Long:  "List, download, and manage data from various public data sources.",
-	}
-
-	// sources list subcommand
-	listCmd := &cobra.Command{
-		Use:   "list",
-		Short: "List available data sources",
+	// config setup subcommand
+	setupCmd := &cobra.Command{
+		Use:   "setup",
+		Short: "Run the interactive setup wizard",
 		Run: func(cmd *cobra.Command, args []string) {
-			log.Logger.Info("Available data sources:")
-			log.Logger.Info("- hackernews: Hacker News stories, comments, and users")
-			log.Logger.Info("  Status: Ready for download")
-			log.Logger.Info("")
-			log.Logger.Info("Future data sources:")
-			log.Logger.Info("- reddit: Reddit posts and comments")
-			log.Logger.Info("- twitter: Twitter posts and metrics")
+			if err := tui.NewSetupWizard(os.Stdin, os.Stdout).Run(); err != nil {
+				if errors.Is(err, tui.ErrSetupCancelled) {
+					fmt.Println("Setup cancelled, configuration unchanged.")
+					return
+				}
+				log.Logger.Errorf("Setup failed: %v", err)
+				os.Exit(1)
+			}
 		},
 	}
 
-	// sources status subcommand
-	statusCmd := &cobra.Command{
-		Use:   "status [source]",
-		Short: "Show status of specific data source",
-		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
-			sourceName := args[0]
-			log.Logger.Infof("Status for data source '%s':", sourceName)
-
-			ds, err := getDataSource(sourceName, 100)
+	// config history subcommand
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "List recorded configuration changes, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := config.History()
 			if err != nil {
-				log.Logger.Errorf("Error: %v", err)
-				return
+				return err
 			}
-			defer func() {
-				if closer, ok := ds.(interface{ Close() error }); ok {
-					closer.Close()
+			if len(entries) == 0 {
+				fmt.Println("No config changes recorded")
+				return nil
+			}
+			for i := len(entries) - 1; i >= 0; i-- {
+				entry := entries[i]
+				fmt.Printf("%d  %s  %s\n", entry.ID, entry.Time.Format("2006-01-02 15:04:05"), entry.Origin)
+				for _, change := range entry.Changes {
+					fmt.Printf("    %s\n", change)
+				}
+			}
+			return nil
+		},
+	}
+
+	// config rollback subcommand
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback <change>",
+		Short: "Restore the configuration from before a change in config history",
+		Long: `Restore the configuration from before a change listed by config history,
+undoing that change and every later one. The rollback is recorded as a
+change too, so it can be undone the same way. A running daemon or server
+picks up the restored configuration when it restarts.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid change %q; use a number from config history", args[0])
+			}
+			changes, err := config.Rollback(id, config.OriginCLI)
+			if err != nil {
+				return err
+			}
+			if len(changes) == 0 {
+				fmt.Println("The config already matches that point; nothing changed")
+				return nil
+			}
+			for _, change := range changes {
+				fmt.Printf("  %s\n", change)
+			}
+			fmt.Printf("Rolled back to the config before change %d\n", id)
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(setStorageCmd, setCmd, sourceCmd, showCmd, validateCmd, setupCmd, historyCmd, rollbackCmd, newSecretCmd())
+	return configCmd
+}
+
+func newSecretCmd() *cobra.Command {
+	secretCmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Manage API keys and other data source credentials",
+		Long: `Store data source credentials, named <source>.<key>, in the encrypted file
+` + secrets.FileName + ` next to the config file. Values are read from the terminal
+without echo, or from stdin when it is piped. Set ` + secrets.PassphraseEnv + `
+to protect the file with a passphrase instead of the generated ` + secrets.KeyFileName + `.
+An environment variable such as ` + secrets.EnvName("reddit.client_id") + ` overrides a stored secret.`,
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set [name]",
+		Short: "Store a secret, e.g. reddit.client_id",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := secrets.ValidateName(args[0]); err != nil {
+				return err
+			}
+			value, err := readSecretValue(fmt.Sprintf("Value for %s: ", args[0]))
+			if err != nil {
+				return err
+			}
+			if err := secrets.Default().Set(args[0], value); err != nil {
+				return fmt.Errorf("failed to set secret: %w", err)
+			}
+			fmt.Printf("Secret %s saved\n", args[0])
+			return nil
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the names of stored secrets",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, err := secrets.Default().Names()
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				fmt.Println(name)
+			}
+			return nil
+		},
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete [name]",
+		Short: "Remove a stored secret",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := secrets.Default().Delete(args[0]); err != nil {
+				return fmt.Errorf("failed to delete secret: %w", err)
+			}
+			fmt.Printf("Secret %s deleted\n", args[0])
+			return nil
+		},
+	}
+
+	secretCmd.AddCommand(setCmd, listCmd, deleteCmd)
+	return secretCmd
+}
+
+// readSecretValue reads a secret from the terminal without echo, or from
+// piped stdin
+func readSecretValue(prompt string) (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Print(prompt)
+		value, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		return strings.TrimSpace(string(value)), nil
+	}
+
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+func newSourcesCmd() *cobra.Command {
+	sourcesCmd := &cobra.Command{
+		Use:   "sources",
+		Short: "Manage data sources",
+		Long:  "List, download, and manage data from various public data sources.",
+	}
+
+	// sources list subcommand
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List available data sources",
+		Run: func(cmd *cobra.Command, args []string) {
+			log.Logger.Info("Available data sources:")
+			log.Logger.Info("- hackernews: Hacker News stories, comments, and users")
+			if config.AppConfig.SourceEnabled("hackernews") {
+				log.Logger.Info("  Status: Ready for download")
+			} else {
+				log.Logger.Info("  Status: Disabled")
+			}
+			log.Logger.Info("")
+			log.Logger.Info("Future data sources:")
+			log.Logger.Info("- reddit: Reddit posts and comments")
+			log.Logger.Info("- twitter: Twitter posts and metrics")
+		},
+	}
+
+	// sources status subcommand
+	statusCmd := &cobra.Command{
+		Use:   "status [source]",
+		Short: "Show status of specific data source",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sourceName := args[0]
+			if !config.AppConfig.SourceEnabled(sourceName) {
+				log.Logger.Infof("Data source '%s' is disabled; enable it with: pubdatahub sources enable %s", sourceName, sourceName)
+				return
+			}
+			log.Logger.Infof("Status for data source '%s':", sourceName)
+
+			ds, err := getDataSource(sourceName, 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer func() {
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
 				}
 			}()
 
@@ -247,10 +1152,33 @@ func newSourcesCmd() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			sourceName := args[0]
 			resume, _ := cmd.Flags().GetBool("resume")
+			verify, _ := cmd.Flags().GetBool("verify")
 			batchSize, _ := cmd.Flags().GetInt("batch-size")
 
+			// A running daemon owns storage and runs the download
+			client := daemonClient(config.AppConfig.StoragePath)
+			if _, err := client.Status(); err == nil {
+				detach, _ := cmd.Flags().GetBool("detach")
+				if verify {
+					log.Logger.Warn("--verify is ignored when the daemon runs the download")
+				}
+				if err := downloadInDaemon(client, sourceName, batchSize, detach); err != nil {
+					log.Logger.Errorf("Error: %v", err)
+				}
+				return
+			}
+
+			lock, err := acquireInstanceLock("sources download")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer lock.Release()
+
 			log.Logger.Infof("Starting download for data source '%s'", sourceName)
-			log.Logger.Infof("Batch size: %d", batchSize)
+			if batchSize > 0 {
+				log.Logger.Infof("Batch size: %d", batchSize)
+			}
 
 			ds, err := getDataSource(sourceName, batchSize)
 			if err != nil {
@@ -267,10 +1195,32 @@ func newSourcesCmd() *cobra.Command {
 
 			if resume {
 				log.Logger.Info("Resume mode enabled")
+			}
+			if verify {
+				verifier, ok := ds.(interface{ SetVerify(bool) error })
+				if !ok {
+					log.Logger.Errorf("Error: %s downloads cannot be verified", sourceName)
+					return
+				}
+				if err := verifier.SetVerify(true); err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+			}
+
+			// A bar on a terminal replaces the per-batch log lines
+			tty := term.IsTerminal(int(os.Stdout.Fd()))
+			if _, configured := log.ComponentLevels()["download"]; tty && !verbose && !configured {
+				log.SetComponentLevel("download", "warn")
+			}
+			bar := progress.NewDownloadBar(ds, os.Stdout, tty)
+			bar.Start()
+			if resume {
 				err = ds.ResumeDownload(ctx)
 			} else {
 				err = ds.StartDownload(ctx)
 			}
+			bar.Stop()
 
 			if err != nil {
 				log.Logger.Errorf("Download failed: %v", err)
@@ -280,7 +1230,9 @@ func newSourcesCmd() *cobra.Command {
 		},
 	}
 	downloadCmd.Flags().Bool("resume", false, "Resume interrupted download")
-	downloadCmd.Flags().Int("batch-size", 100, "Batch size for downloading")
+	downloadCmd.Flags().Bool("verify", false, "Recount a sample of completed batches and fetch again those with items missing")
+	downloadCmd.Flags().Int("batch-size", 0, "Batch size for downloading; 0 uses data_sources.<source>.batch_size")
+	downloadCmd.Flags().Bool("detach", false, "With a running daemon, start the download and return")
 
 	// sources progress subcommand
 	progressCmd := &cobra.Command{
@@ -321,39 +1273,84 @@ func newSourcesCmd() *cobra.Command {
 		},
 	}
 
-	sourcesCmd.AddCommand(listCmd, statusCmd, downloadCmd, progressCmd)
-	return sourcesCmd
-}
+	// sources export-dataset subcommand
+	exportDatasetCmd := &cobra.Command{
+		Use:   "export-dataset [source] [file.tar.gz|file.tar.zst]",
+		Short: "Package a data source's database with a checksummed manifest",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			porter, closeSource, err := getDatasetPorter(args[0])
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer closeSource()
 
-func newQueryCmd() *cobra.Command {
-	queryCmd := &cobra.Command{
-		Use:   "query [source] [query]",
-		Short: "Execute queries against data sources",
-		Long:  "Execute SQL queries against downloaded data from various sources.",
-		Args:  cobra.MinimumNArgs(1),
+			manifest, err := porter.ExportDataset(args[1])
+			if err != nil {
+				log.Logger.Errorf("Export failed: %v", err)
+				return
+			}
+			log.Logger.Infof("Exported %s dataset (schema version %d, sha256 %s) to %s",
+				manifest.Source, manifest.SchemaVersion, manifest.SHA256, args[1])
+		},
+	}
+
+	// sources import-dataset subcommand
+	importDatasetCmd := &cobra.Command{
+		Use:   "import-dataset [file.tar.gz|file.tar.zst]",
+		Short: "Verify a dataset archive and replace the source's database with it",
+		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			sourceName := args[0]
-			interactive, _ := cmd.Flags().GetBool("interactive")
-			output, _ := cmd.Flags().GetString("output")
-			file, _ := cmd.Flags().GetString("file")
+			manifest, err := dataset.ReadManifest(args[0])
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
 
-			if interactive {
-				log.Logger.Infof("Starting interactive query mode for '%s'", sourceName)
-				log.Logger.Info("(Interactive mode implementation coming in future phases)")
+			lock, err := acquireInstanceLock("sources import-dataset")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
 				return
 			}
+			defer lock.Release()
 
-			if len(args) < 2 {
-				log.Logger.Error("Error: query string required when not in interactive mode")
-				log.Logger.Error("Use --interactive flag for interactive mode")
+			porter, closeSource, err := getDatasetPorter(manifest.Source)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
 				return
 			}
+			defer closeSource()
 
-			query := args[1]
-			log.Logger.Infof("Executing query on '%s':", sourceName)
-			log.Logger.Infof("Query: %s", query)
+			manifest, err = porter.ImportDataset(args[0])
+			if err != nil {
+				log.Logger.Errorf("Import failed: %v", err)
+				return
+			}
+			for table, count := range manifest.Tables {
+				log.Logger.Infof("  %s: %d rows", table, count)
+			}
+			log.Logger.Infof("Imported %s dataset from %s", manifest.Source, args[0])
+		},
+	}
 
-			ds, err := getDataSource(sourceName, 100)
+	// sources bootstrap subcommand
+	bootstrapCmd := &cobra.Command{
+		Use:   "bootstrap [source] [url|file]",
+		Short: "Seed a data source from a published snapshot, then download newer items",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			noSync, _ := cmd.Flags().GetBool("no-sync")
+			sha256, _ := cmd.Flags().GetString("sha256")
+
+			lock, err := acquireInstanceLock("sources bootstrap")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer lock.Release()
+
+			ds, err := getDataSource(args[0], 100)
 			if err != nil {
 				log.Logger.Errorf("Error: %v", err)
 				return
@@ -364,120 +1361,923 @@ func newQueryCmd() *cobra.Command {
 				}
 			}()
 
-			result, err := ds.Query(query)
-			if err != nil {
-				log.Logger.Errorf("Query failed: %v", err)
+			bootstrapper, ok := ds.(snapshotBootstrapper)
+			if !ok {
+				log.Logger.Errorf("Error: data source %s cannot be bootstrapped from a snapshot", args[0])
 				return
 			}
 
-			log.Logger.Infof("Query completed in %v", result.Duration)
-			log.Logger.Infof("Found %d rows", result.Count)
-
-			// For now, just display basic table format
-			if len(result.Rows) > 0 {
-				// Print column headers
-				for i, col := range result.Columns {
-					if i > 0 {
-						fmt.Printf("\t")
-					}
-					fmt.Printf("%s", col)
-				}
-				fmt.Println()
+			ctx := context.Background()
+			log.Logger.Infof("Importing snapshot %s", args[1])
+			result, err := bootstrapper.Bootstrap(ctx, args[1], sha256)
+			if err != nil {
+				log.Logger.Errorf("Bootstrap failed: %v", err)
+				return
+			}
+			log.Logger.Infof("Imported %d items up to ID %d in %s", result.Items, result.MaxID, result.Duration.Round(time.Second))
+			if noSync {
+				log.Logger.Infof("Run 'pubdatahub sources download %s' to fetch newer items", args[0])
+				return
+			}
 
-				// Print separator
-				for i := range result.Columns {
-					if i > 0 {
-						fmt.Printf("\t")
-					}
-					fmt.Printf("---")
-				}
-				fmt.Println()
+			log.Logger.Info("Downloading items newer than the snapshot")
+			tty := term.IsTerminal(int(os.Stdout.Fd()))
+			if _, configured := log.ComponentLevels()["download"]; tty && !verbose && !configured {
+				log.SetComponentLevel("download", "warn")
+			}
+			bar := progress.NewDownloadBar(ds, os.Stdout, tty)
+			bar.Start()
+			err = ds.StartDownload(ctx)
+			bar.Stop()
+			if err != nil {
+				log.Logger.Errorf("Download failed: %v", err)
+				return
+			}
+			log.Logger.Info("Download completed successfully")
+		},
+	}
+	bootstrapCmd.Flags().Bool("no-sync", false, "Only import the snapshot, without downloading newer items")
+	bootstrapCmd.Flags().String("sha256", "", "Expected SHA-256 checksum of a downloaded snapshot")
 
-				// Print rows (limit to first 20 for readability)
-				limit := result.Count
-				if limit > 20 {
-					limit = 20
-				}
+	// sources refresh-users subcommand
+	refreshUsersCmd := &cobra.Command{
+		Use:   "refresh-users [source]",
+		Short: "Fetch profiles of new authors and record karma of known users",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			lock, err := acquireInstanceLock("sources refresh-users")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer lock.Release()
 
-				for i := 0; i < limit; i++ {
-					row := result.Rows[i]
-					for j, val := range row {
-						if j > 0 {
-							fmt.Printf("\t")
-						}
-						fmt.Printf("%v", val)
-					}
-					fmt.Println()
+			ds, err := getDataSource(args[0], 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer func() {
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
 				}
+			}()
 
-				if result.Count > 20 {
-					log.Logger.Infof("... and %d more rows", result.Count-20)
-				}
+			refresher, ok := ds.(userRefresher)
+			if !ok {
+				log.Logger.Errorf("Error: data source %s has no user profiles", args[0])
+				return
 			}
-
-			// TODO: Implement file output and other formats
-			if file != "" || output != "table" {
-				log.Logger.Info("File output and other formats coming in future phases")
+			if err := refresher.RefreshUsers(context.Background(), nil); err != nil {
+				log.Logger.Errorf("User refresh failed: %v", err)
+				return
 			}
+			log.Logger.Info("User refresh completed successfully")
 		},
 	}
 
-	queryCmd.Flags().Bool("interactive", false, "Enter interactive query mode")
-	queryCmd.Flags().String("output", "table", "Output format (table, json, csv)")
-	queryCmd.Flags().String("file", "", "Output file path")
-
-	return queryCmd
-}
-
-func newServeCmd() *cobra.Command {
-	serveCmd := &cobra.Command{
-		Use:   "serve",
-		Short: "Start the web API server",
-		Long:  "Start the web API server to serve HTTP requests",
+	// sources repair subcommand
+	repairCmd := &cobra.Command{
+		Use:   "repair [source]",
+		Short: "Re-fetch failed items and dead, deleted or missing items due for a re-check",
+		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			port, _ := cmd.Flags().GetString("port")
-			addr := fmt.Sprintf(":%s", port)
-
-			// Create data sources
-			dataSources := make(map[string]datasource.DataSource)
-			// For now, just add hackernews as an example
-			// In a real implementation, this would be configurable
-			hnSource := hackernews.NewHackerNewsDataSource(100)
-			if err := hnSource.InitializeStorage(config.AppConfig.StoragePath); err != nil {
-				log.Logger.Errorf("Failed to initialize Hacker News storage: %v", err)
-			} else {
-				dataSources["hackernews"] = hnSource
-			}
-
-			// Create job manager
-			jobManager, err := jobs.NewEnhancedJobManager(
-				config.AppConfig.StoragePath,
-				dataSources,
-				jobs.DefaultManagerConfig(),
-			)
+			lock, err := acquireInstanceLock("sources repair")
 			if err != nil {
-				log.Logger.Errorf("Failed to create job manager: %v", err)
-				os.Exit(1)
+				log.Logger.Errorf("Error: %v", err)
+				return
 			}
+			defer lock.Release()
 
-			// Start job manager
-			if err := jobManager.Start(); err != nil {
-				log.Logger.Errorf("Failed to start job manager: %v", err)
-				os.Exit(1)
+			ds, err := getDataSource(args[0], 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
 			}
 			defer func() {
-				if err := jobManager.Stop(); err != nil {
-					log.Logger.Errorf("Failed to stop job manager: %v", err)
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
 				}
 			}()
 
-			// Create and start the server with webapp support
-			server := api.NewWebAppServer(addr, jobManager)
+			repairer, ok := ds.(itemRepairer)
+			if !ok {
+				log.Logger.Errorf("Error: data source %s has no items to repair", args[0])
+				return
+			}
+			if err := repairer.RepairItems(context.Background(), nil); err != nil {
+				log.Logger.Errorf("Repair failed: %v", err)
+				return
+			}
+			log.Logger.Info("Repair completed successfully")
+		},
+	}
 
-			// Start server in a goroutine to allow for graceful shutdown
-			go func() {
-				if err := server.Start(); err != nil {
-					log.Logger.Errorf("Server error: %v", err)
+	// sources redownload subcommand
+	redownloadCmd := &cobra.Command{
+		Use:   "redownload [source]",
+		Short: "Re-fetch specific items, such as corrupted rows, and update them in place",
+		Long: `Re-fetch the items given by --ids and --range from the API and overwrite
+the stored rows whatever the conflict strategy. How each stored item changed
+is logged; items the API no longer returns get a tombstone.`,
+		Example: "  pubdatahub sources redownload hackernews --ids 123,456\n  pubdatahub sources redownload hackernews --range 1000-2000",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ids, _ := cmd.Flags().GetString("ids")
+			idRange, _ := cmd.Flags().GetString("range")
+			var parts []string
+			for _, part := range []string{ids, idRange} {
+				if part != "" {
+					parts = append(parts, part)
+				}
+			}
+			if len(parts) == 0 {
+				log.Logger.Error("Error: --ids or --range is required")
+				return
+			}
+			itemIDs, err := jobs.ParseItemIDs(strings.Join(parts, ","))
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+
+			lock, err := acquireInstanceLock("sources redownload")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer lock.Release()
+
+			ds, err := getDataSource(args[0], 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer func() {
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
+				}
+			}()
+
+			redownloader, ok := ds.(itemRedownloader)
+			if !ok {
+				log.Logger.Errorf("Error: data source %s does not support re-downloading items", args[0])
+				return
+			}
+			if err := redownloader.RedownloadItems(context.Background(), itemIDs, nil); err != nil {
+				log.Logger.Errorf("Re-download failed: %v", err)
+				return
+			}
+			log.Logger.Info("Re-download completed successfully")
+		},
+	}
+	redownloadCmd.Flags().String("ids", "", "Comma-separated item IDs to re-download")
+	redownloadCmd.Flags().String("range", "", "Inclusive range of item IDs to re-download, e.g. 1000-2000")
+
+	// sources snapshot subcommand
+	snapshotCmd := &cobra.Command{
+		Use:   "snapshot [source]",
+		Short: "Record the current rankings of a source's lists, such as the front page",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			lists, _ := cmd.Flags().GetStringSlice("lists")
+			depth, _ := cmd.Flags().GetInt("depth")
+
+			lock, err := acquireInstanceLock("sources snapshot")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer lock.Release()
+
+			ds, err := getDataSource(args[0], 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer func() {
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
+				}
+			}()
+
+			capturer, ok := ds.(rankingCapturer)
+			if !ok {
+				log.Logger.Errorf("Error: data source %s has no rankings to capture", args[0])
+				return
+			}
+			if len(lists) == 0 {
+				lists = capturer.RankingLists()
+			}
+			for _, list := range lists {
+				count, err := capturer.CaptureRanking(context.Background(), list, depth)
+				if err != nil {
+					log.Logger.Errorf("Snapshot failed: %v", err)
+					return
+				}
+				log.Logger.Infof("Captured %d %s entries", count, list)
+			}
+		},
+	}
+	snapshotCmd.Flags().StringSlice("lists", nil, "Lists to capture, e.g. top,new,best (default all)")
+	snapshotCmd.Flags().Int("depth", 0, "Entries to capture per list (default one front page)")
+
+	// sources enrich subcommand
+	enrichCmd := &cobra.Command{
+		Use:   "enrich [source]",
+		Short: "Run enrichers such as language detection over downloaded item text",
+		Long: `Run enrichers over the title and text of downloaded items and store their
+results in enrich_<enricher> tables, e.g. enrich_language. Items already
+enriched by the current version of an enricher are skipped.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			names, _ := cmd.Flags().GetStringSlice("enrichers")
+			rerun, _ := cmd.Flags().GetBool("rerun")
+
+			lock, err := acquireInstanceLock("sources enrich")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer lock.Release()
+
+			ds, err := getDataSource(args[0], 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer func() {
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
+				}
+			}()
+
+			enricher, ok := ds.(textEnricher)
+			if !ok {
+				log.Logger.Errorf("Error: data source %s does not support enrichment", args[0])
+				return
+			}
+			if len(names) == 0 {
+				names = enricher.Enrichers()
+			}
+			if err := enricher.Enrich(context.Background(), names, rerun, nil); err != nil {
+				log.Logger.Errorf("Enrichment failed: %v", err)
+				return
+			}
+			log.Logger.Infof("Enriched items with %s", strings.Join(names, ", "))
+		},
+	}
+	enrichCmd.Flags().StringSlice("enrichers", nil, "Enrichers to run, e.g. language,words,sentiment (default all)")
+	enrichCmd.Flags().Bool("rerun", false, "Discard stored results and enrich every item again")
+
+	// sources prune-changes subcommand
+	pruneChangesCmd := &cobra.Command{
+		Use:   "prune-changes [source]",
+		Short: "Delete old item changes recorded by change tracking",
+		Long: `Delete rows of the item_changes table recorded longer ago than --older-than,
+or than change_retention_days of the source when the flag is not given.
+Downloads prune by change_retention_days automatically.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			olderThan, _ := cmd.Flags().GetString("older-than")
+			var age time.Duration
+			if olderThan != "" {
+				parsed, err := jobs.ParseAge(olderThan)
+				if err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+				age = parsed
+			} else if days := config.AppConfig.DataSources[args[0]].ChangeRetentionDays; days > 0 {
+				age = time.Duration(days) * 24 * time.Hour
+			} else {
+				log.Logger.Errorf("Error: give --older-than or set data_sources.%s.change_retention_days", args[0])
+				return
+			}
+
+			lock, err := acquireInstanceLock("sources prune-changes")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer lock.Release()
+
+			ds, err := getDataSource(args[0], 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer func() {
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
+				}
+			}()
+
+			pruner, ok := ds.(changePruner)
+			if !ok {
+				log.Logger.Errorf("Error: data source %s does not track changes", args[0])
+				return
+			}
+			pruned, err := pruner.PruneChanges(age)
+			if err != nil {
+				log.Logger.Errorf("Pruning failed: %v", err)
+				return
+			}
+			log.Logger.Infof("Pruned %d item changes older than %s", pruned, age)
+		},
+	}
+	pruneChangesCmd.Flags().String("older-than", "", "Delete changes older than this, e.g. 30d or 12h (default change_retention_days)")
+
+	// sources archive subcommand
+	archiveCmd := &cobra.Command{
+		Use:   "archive [source]",
+		Short: "Move old items into compressed per-year archive databases",
+		Long: `Move the items posted longer ago than --older-than-days, or than
+archive_after_days of the source when the flag is not given (default 365),
+into a gzipped database per year in the source's archives directory, then
+vacuum the database. Archived items are left out of queries unless they are
+run with --include-archives.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			days, _ := cmd.Flags().GetInt("older-than-days")
+			if days <= 0 {
+				days = config.AppConfig.DataSources[args[0]].ArchiveAfterDays
+			}
+			if days <= 0 {
+				days = jobs.DefaultArchiveAfterDays
+			}
+
+			lock, err := acquireInstanceLock("sources archive")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer lock.Release()
+
+			ds, err := getDataSource(args[0], 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer func() {
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
+				}
+			}()
+
+			archiver, ok := ds.(itemArchiver)
+			if !ok {
+				log.Logger.Errorf("Error: data source %s does not support archiving", args[0])
+				return
+			}
+			var archived int64
+			err = archiver.ArchiveItems(context.Background(), days, func(done, total int64) {
+				archived = done
+				log.Logger.Infof("Archived %d of %d items", done, total)
+			})
+			if err != nil {
+				log.Logger.Errorf("Archiving failed: %v", err)
+				return
+			}
+			log.Logger.Infof("Archived %d items older than %d days", archived, days)
+		},
+	}
+	archiveCmd.Flags().Int("older-than-days", 0, "Archive items older than this many days (default archive_after_days, or 365)")
+
+	enableCmd := &cobra.Command{
+		Use:     "enable <source>",
+		Aliases: []string{"add"},
+		Short:   "Enable a data source in the shell, API and schedules",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setSourceEnabled(args[0], true)
+		},
+	}
+
+	disableCmd := &cobra.Command{
+		Use:     "disable <source>",
+		Aliases: []string{"remove"},
+		Short:   "Disable a data source, keeping its downloaded data",
+		Long: `Disable a data source in the data_sources section of the config. Disabled
+sources are hidden from the shell, the API and status output, and their
+scheduled syncs and maintenance jobs do not run. Downloaded data is kept;
+"sources enable" brings the source back.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setSourceEnabled(args[0], false)
+		},
+	}
+
+	sourcesCmd.AddCommand(listCmd, statusCmd, enableCmd, disableCmd, downloadCmd, progressCmd, exportDatasetCmd, importDatasetCmd, bootstrapCmd, refreshUsersCmd, repairCmd, redownloadCmd, snapshotCmd, enrichCmd, pruneChangesCmd, archiveCmd)
+	return sourcesCmd
+}
+
+// setSourceEnabled enables or disables a data source in the config
+func setSourceEnabled(name string, enabled bool) error {
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	if err := config.CheckSource(name); err != nil {
+		return err
+	}
+	if config.AppConfig.SourceEnabled(name) == enabled {
+		fmt.Printf("Data source %s is already %s\n", name, state)
+		return nil
+	}
+	if err := config.SetSourceEnabled(name, enabled, config.OriginCLI); err != nil {
+		return err
+	}
+	fmt.Printf("Data source %s %s; restart a running serve or daemon to apply it\n", name, state)
+	return nil
+}
+
+// editQueryDraft opens sql, or the last draft when sql is empty, in the
+// external editor and keeps the result as the draft, so a query that fails
+// can be fixed with the next --edit
+func editQueryDraft(sql string) (string, error) {
+	path := filepath.Join(config.AppConfig.StoragePath, "query_draft.sql")
+	if sql == "" {
+		if data, err := os.ReadFile(path); err == nil {
+			sql = string(data)
+		}
+	}
+
+	edited, err := editor.Edit(sql, ".sql")
+	if err != nil {
+		return "", err
+	}
+	edited = strings.TrimSpace(edited)
+	if err := os.WriteFile(path, []byte(edited+"\n"), 0644); err != nil {
+		log.Logger.Warnf("Failed to save the query draft: %v", err)
+	}
+	return edited, nil
+}
+
+// queryFileFormat returns the format of a query's --file: the --output format
+// when given, otherwise picked by the file extension
+func queryFileFormat(output, file string) query.OutputFormat {
+	format := query.OutputFormat(strings.ToLower(output))
+	if format == query.OutputFormatTable {
+		format = query.FormatForPath(file)
+	}
+	return format
+}
+
+// querySheets runs the queries of --add-sheet flags, each given as
+// NAME=SQL, and returns their results as worksheets
+func querySheets(sourceName string, ds datasource.DataSource, specs []string) ([]query.Sheet, error) {
+	sheets := make([]query.Sheet, 0, len(specs))
+	for _, spec := range specs {
+		name, sql, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(sql) == "" {
+			return nil, fmt.Errorf("invalid --add-sheet %q, expected NAME=SQL", spec)
+		}
+		result, err := audit.Query(audit.Local(audit.InterfaceCLI), sourceName, sql, ds.Query)
+		if err != nil {
+			return nil, fmt.Errorf("query for sheet %s failed: %w", name, query.DiagnoseQueryError(sql, err, ds.GetSchema()))
+		}
+		sheets = append(sheets, query.Sheet{Name: name, Columns: result.Columns, Rows: result.Rows})
+	}
+	return sheets, nil
+}
+
+func newQueryCmd() *cobra.Command {
+	queryCmd := &cobra.Command{
+		Use:   "query [source] [query]",
+		Short: "Execute queries against data sources",
+		Long:  "Execute SQL queries against downloaded data from various sources.",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			sourceName := args[0]
+			interactive, _ := cmd.Flags().GetBool("interactive")
+			output, _ := cmd.Flags().GetString("output")
+			file, _ := cmd.Flags().GetString("file")
+			chart, _ := cmd.Flags().GetString("chart")
+			copyResults, _ := cmd.Flags().GetBool("copy")
+			edit, _ := cmd.Flags().GetBool("edit")
+			sheet, _ := cmd.Flags().GetString("sheet")
+			addSheets, _ := cmd.Flags().GetStringArray("add-sheet")
+			compress, _ := cmd.Flags().GetString("compress")
+			includeArchives, _ := cmd.Flags().GetBool("include-archives")
+			redact, _ := cmd.Flags().GetString("redact")
+			workspaceName, _ := cmd.Flags().GetString("workspace")
+
+			// Piped output carries only the results; logs go to stderr
+			piped := !term.IsTerminal(int(os.Stdout.Fd()))
+			if piped {
+				log.UseStderr()
+			}
+
+			if strings.EqualFold(output, string(query.OutputFormatSQLite)) && file == "" {
+				log.Logger.Error("Error: --output sqlite writes a database file, set it with --file results.db")
+				return
+			}
+			if strings.EqualFold(output, string(query.OutputFormatXLSX)) && file == "" {
+				log.Logger.Error("Error: --output xlsx writes a workbook, set it with --file results.xlsx")
+				return
+			}
+			if len(addSheets) > 0 && (file == "" || queryFileFormat(output, file) != query.OutputFormatXLSX) {
+				log.Logger.Error("Error: --add-sheet adds worksheets to an Excel workbook, set it with --file report.xlsx")
+				return
+			}
+			compression, err := query.ParseCompression(compress)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			if file != "" {
+				if compression == query.CompressionNone {
+					compression = query.CompressionForPath(file)
+				}
+				if err := query.CheckCompression(queryFileFormat(output, file), compression); err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+				file = query.CompressedPath(file, compression)
+			} else if compression != query.CompressionNone {
+				log.Logger.Error("Error: --compress compresses the --file output, set it with --file results.csv")
+				return
+			}
+
+			var redaction *query.Redaction
+			if redact != "" {
+				found, err := workspaceRedaction(workspaceName, redact)
+				if err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+				redaction = &found
+			}
+
+			var chartSpec query.ChartSpec
+			if chart != "" {
+				spec, err := query.ParseChartSpec(chart)
+				if err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+				chartSpec = spec
+			}
+
+			if interactive {
+				log.Logger.Infof("Starting interactive query mode for '%s'", sourceName)
+				log.Logger.Info("(Interactive mode implementation coming in future phases)")
+				return
+			}
+
+			if len(args) < 2 && !edit {
+				log.Logger.Error("Error: query string required when not in interactive mode")
+				log.Logger.Error("Use --interactive flag for interactive mode, or --edit to compose the query in $EDITOR")
+				return
+			}
+
+			var sql string
+			if len(args) > 1 {
+				sql = args[1]
+			}
+			if edit {
+				edited, err := editQueryDraft(sql)
+				if err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+				if edited == "" {
+					log.Logger.Info("Empty query, nothing to run")
+					return
+				}
+				sql = edited
+			}
+			log.Logger.Infof("Executing query on '%s':", sourceName)
+			log.Logger.Infof("Query: %s", sql)
+
+			ds, err := getDataSource(sourceName, 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer func() {
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
+				}
+			}()
+
+			run := ds.Query
+			if includeArchives {
+				querier, ok := ds.(archiveQuerier)
+				if !ok {
+					log.Logger.Errorf("Error: data source %s has no archives", sourceName)
+					return
+				}
+				run = querier.QueryWithArchives
+			}
+			result, err := audit.Query(audit.Local(audit.InterfaceCLI), sourceName, sql, run)
+			if err != nil {
+				log.Logger.Errorf("Query failed: %v", query.DiagnoseQueryError(sql, err, ds.GetSchema()))
+				return
+			}
+
+			log.Logger.Infof("Query completed in %v", result.Duration)
+			log.Logger.Infof("Found %d rows", result.Count)
+
+			if redaction != nil {
+				result.Columns, result.Rows, err = redaction.Apply(result.Columns, result.Rows)
+				if err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+			}
+
+			if copyResults {
+				text, err := query.FormatDelimited(result.Columns, result.Rows, query.Delimiter(output))
+				if err != nil {
+					log.Logger.Errorf("Copy failed: %v", err)
+					return
+				}
+				if err := clipboard.Copy(text); err != nil {
+					log.Logger.Errorf("Copy failed: %v", err)
+					return
+				}
+				log.Logger.Infof("Copied %d rows to the clipboard", len(result.Rows))
+			}
+
+			if file != "" && len(addSheets) > 0 {
+				if sheet == "" {
+					sheet = query.DefaultSheetName
+				}
+				extra, err := querySheets(sourceName, ds, addSheets)
+				if err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+				sheets := append([]query.Sheet{{Name: sheet, Columns: result.Columns, Rows: result.Rows}}, extra...)
+				if err := query.WriteXLSX(file, sheets); err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+				log.Logger.Infof("Wrote %d sheets to %s", len(sheets), file)
+			} else if file != "" {
+				opts := query.SaveOptions{Format: queryFileFormat(output, file), Sheet: sheet, Compression: compression}
+				savings, err := query.SaveResult(file, opts, result.Columns, result.Rows)
+				if err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+				if savings != "" {
+					log.Logger.Infof("Wrote %d rows to %s (%s)", len(result.Rows), file, savings)
+				} else {
+					log.Logger.Infof("Wrote %d rows to %s", len(result.Rows), file)
+				}
+			} else if piped {
+				if err := query.WriteDelimited(os.Stdout, result.Columns, result.Rows, query.Delimiter(output)); err != nil {
+					log.Logger.Errorf("Error: %v", err)
+				}
+				return
+			}
+
+			if len(result.Rows) > 0 && file == "" {
+				// Show the first 20 rows for readability
+				limit := result.Count
+				if limit > 20 {
+					limit = 20
+				}
+				if err := query.WriteTable(os.Stdout, result.Columns, result.Rows[:limit], nil, query.DefaultCellWidth); err != nil {
+					log.Logger.Errorf("Error: %v", err)
+					return
+				}
+
+				if result.Count > 20 {
+					log.Logger.Infof("... and %d more rows", result.Count-20)
+				}
+			}
+
+			if chart != "" {
+				rendered, err := query.RenderChart(result.Columns, result.Rows, chartSpec)
+				if err != nil {
+					log.Logger.Errorf("Chart failed: %v", err)
+					return
+				}
+				fmt.Println()
+				fmt.Print(rendered)
+			}
+
+		},
+	}
+
+	queryCmd.Flags().Bool("interactive", false, "Enter interactive query mode")
+	queryCmd.Flags().String("output", "table", "Output format (table, json, csv, tsv, sqlite, xlsx); piped and copied results are tab-separated unless csv")
+	queryCmd.Flags().String("file", "", "Write the results to a file, or upload them to s3://bucket/key; the extension picks the format unless --output is given")
+	queryCmd.Flags().String("sheet", "", "Worksheet name of an .xlsx file (default Results)")
+	queryCmd.Flags().StringArray("add-sheet", nil, "Add the results of another query as a worksheet of the .xlsx file, given as NAME=SQL; repeatable")
+	queryCmd.Flags().String("compress", "", "Compress the --file output with gzip or zstd while writing it, adding .gz or .zst; implied by a .gz or .zst file")
+	queryCmd.Flags().Bool("copy", false, "Copy the results to the system clipboard")
+	queryCmd.Flags().String("chart", "", "Render a chart, e.g. bar:x=day,y=stories, spark:y=score, hist:x=score,bins=20")
+	queryCmd.Flags().Bool("include-archives", false, "Also query items moved to the source's yearly archives by sources archive")
+	queryCmd.Flags().String("redact", "", "Apply a redaction rule set of the --workspace to the results before they are written, shown or copied")
+	queryCmd.Flags().String("workspace", "", "Workspace whose redaction rule sets --redact uses")
+	queryCmd.Flags().BoolP("edit", "e", false, "Compose the query in $EDITOR, starting from the given query or the last draft")
+
+	return queryCmd
+}
+
+func newThreadCmd() *cobra.Command {
+	threadCmd := &cobra.Command{
+		Use:   "thread [source] [story_id]",
+		Short: "Show the comment tree of a story as a threaded view",
+		Long:  "Reconstruct the comment tree of a story from downloaded items and print it indented, or export it as Markdown or JSON.",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			depth, _ := cmd.Flags().GetInt("depth")
+			width, _ := cmd.Flags().GetInt("width")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+
+			rootID, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				log.Logger.Errorf("Error: invalid story ID %q", args[1])
+				return
+			}
+			if format == "" {
+				format = query.ThreadFormatForPath(output)
+			}
+
+			ds, err := getDataSource(args[0], 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer func() {
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
+				}
+			}()
+
+			loader, ok := ds.(threadLoader)
+			if !ok {
+				log.Logger.Errorf("Error: data source %s has no threads", args[0])
+				return
+			}
+			root, err := loader.Thread(rootID)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			rendered, err := query.RenderThread(root, query.ThreadOptions{Format: format, Depth: depth, Width: width})
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+
+			if output == "" {
+				fmt.Print(rendered)
+				return
+			}
+			if err := os.WriteFile(output, []byte(rendered), 0644); err != nil {
+				log.Logger.Errorf("Error: failed to write output file: %v", err)
+				return
+			}
+			log.Logger.Infof("Wrote thread of %d replies to %s", root.CountReplies(), output)
+		},
+	}
+
+	threadCmd.Flags().Int("depth", 0, "Reply levels to show before collapsing (default all)")
+	threadCmd.Flags().Int("width", query.DefaultThreadWidth, "Line width of text output")
+	threadCmd.Flags().String("format", "", "Output format (text, markdown, json); defaults to the output file extension")
+	threadCmd.Flags().String("output", "", "Write the thread to a file instead of the screen")
+
+	return threadCmd
+}
+
+func newReportCmd() *cobra.Command {
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "List and generate reports",
+		Long: `Generate reports defined with the report command of the interactive shell.
+Reports render a template with query results and are written to a file or
+posted to a webhook. Scheduled reports run while the shell, serve or daemon
+is running; "report run" generates one immediately, e.g. from system cron.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List defined reports",
+		Run: func(cmd *cobra.Command, args []string) {
+			reports, err := report.NewStore(report.Dir(config.AppConfig.StoragePath)).List()
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			if len(reports) == 0 {
+				fmt.Println("No reports defined")
+				return
+			}
+			for _, r := range reports {
+				schedule := r.Schedule
+				if schedule == "" {
+					schedule = "manual"
+				}
+				fmt.Printf("%-20s %-12s %s\n", r.Name, r.Source, schedule)
+			}
+		},
+	}
+
+	runCmd := &cobra.Command{
+		Use:   "run [name]",
+		Short: "Generate a report now",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			store := report.NewStore(report.Dir(config.AppConfig.StoragePath))
+			r, err := store.Get(args[0])
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+
+			ds, err := getDataSource(r.Source, 100)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			defer func() {
+				if closer, ok := ds.(interface{ Close() error }); ok {
+					closer.Close()
+				}
+			}()
+
+			clientConfig := httpclient.Defaults()
+			clientConfig.CacheEnabled = false
+			client, err := httpclient.New(clientConfig)
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				return
+			}
+			r, err = report.Run(context.Background(), store, args[0], map[string]datasource.DataSource{r.Source: ds}, client)
+			if err != nil {
+				log.Logger.Errorf("Report failed: %v", err)
+				return
+			}
+			if r.LastOutput != "" {
+				log.Logger.Infof("Wrote report %s to %s", r.Name, r.LastOutput)
+			}
+			if r.Webhook != "" {
+				log.Logger.Infof("Posted report %s to its webhook", r.Name)
+			}
+		},
+	}
+
+	reportCmd.AddCommand(listCmd, runCmd)
+	return reportCmd
+}
+
+func newServeCmd() *cobra.Command {
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Start the web API server",
+		Long:  "Start the web API server to serve HTTP requests",
+		Run: func(cmd *cobra.Command, args []string) {
+			port, _ := cmd.Flags().GetString("port")
+			addr := fmt.Sprintf(":%s", port)
+
+			lock, err := acquireInstanceLock("serve")
+			if err != nil {
+				log.Logger.Errorf("Error: %v", err)
+				os.Exit(1)
+			}
+			defer lock.Release()
+
+			dataSources := openDataSources()
+			jobManager, err := startJobManager(dataSources)
+			if err != nil {
+				log.Logger.Errorf("%v", err)
+				os.Exit(1)
+			}
+			defer func() {
+				// Running jobs finish or are paused by jobs.drain_policy
+				if err := jobManager.Shutdown(context.Background()); err != nil {
+					log.Logger.Errorf("Failed to stop job manager: %v", err)
+				}
+			}()
+
+			auth, err := apiAuthenticator()
+			if err != nil {
+				log.Logger.Errorf("API authentication: %v", err)
+				os.Exit(1)
+			}
+
+			// Create and start the server with webapp support
+			server := api.NewServerWithConfig(addr, jobManager, api.ServerConfig{ServeStatic: true, Auth: auth, GraphQL: config.AppConfig.API.GraphQL})
+			server.SetDataSources(dataSources)
+			server.SetStoragePath(config.AppConfig.StoragePath)
+			if workspaces, err := openWorkspaces(); err != nil {
+				log.Logger.Warnf("Workspaces are not available: %v", err)
+			} else {
+				server.SetWorkspaces(workspaces)
+			}
+
+			// Start server in a goroutine to allow for graceful shutdown
+			go func() {
+				if err := server.Start(); err != nil {
+					log.Logger.Errorf("Server error: %v", err)
 					os.Exit(1)
 				}
 			}()
@@ -506,7 +2306,299 @@ func newServeCmd() *cobra.Command {
 		},
 	}
 
+	tokenCmd := &cobra.Command{
+		Use:   "token",
+		Short: "Print the generated API token, creating it if needed",
+		Long: `Print the API token used when api.tokens is empty. Send it as
+"Authorization: Bearer <token>", or open the web app once with ?token=<token>.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, _, err := api.EnsureDefaultToken(secrets.Default())
+			if err != nil {
+				return err
+			}
+			fmt.Println(token)
+			return nil
+		},
+	}
+
 	serveCmd.Flags().StringP("port", "P", "8080", "Port to listen on")
+	serveCmd.AddCommand(tokenCmd)
 
 	return serveCmd
 }
+
+// workspaceRedaction returns a redaction rule set of a workspace
+func workspaceRedaction(workspaceName, name string) (query.Redaction, error) {
+	if workspaceName == "" {
+		return query.Redaction{}, fmt.Errorf("--redact needs the --workspace with the rule set")
+	}
+	workspaces, err := openWorkspaces()
+	if err != nil {
+		return query.Redaction{}, err
+	}
+	ws, err := workspaces.Get(workspaceName)
+	if err != nil {
+		return query.Redaction{}, err
+	}
+	return ws.Settings.Redaction(name)
+}
+
+// openWorkspaces opens the workspaces the shell uses, keeping deleted ones in
+// the trash when it is enabled
+func openWorkspaces() (*workspace.Service, error) {
+	service, err := workspace.NewService(workspace.DefaultDir())
+	if err != nil {
+		return nil, err
+	}
+	if days := config.AppConfig.Trash.RetentionDays; days > 0 {
+		bin, err := trash.New(trash.Dir(config.AppConfig.StoragePath), time.Duration(days)*24*time.Hour)
+		if err != nil {
+			log.Logger.Warnf("Failed to open trash, deleted workspaces cannot be restored: %v", err)
+		} else {
+			service.SetTrash(bin)
+		}
+	}
+	return service, nil
+}
+
+// openDataSources initializes the enabled data sources for a process that
+// runs jobs
+func openDataSources() map[string]datasource.DataSource {
+	dataSources := make(map[string]datasource.DataSource)
+	if config.AppConfig.SourceEnabled("hackernews") {
+		hnSource := hackernews.NewHackerNewsDataSource(0)
+		if err := hnSource.InitializeStorage(config.AppConfig.StoragePath); err != nil {
+			log.Logger.Errorf("Failed to initialize Hacker News storage: %v", err)
+		} else {
+			dataSources["hackernews"] = hnSource
+		}
+	}
+	return dataSources
+}
+
+// sourceLimits returns the configured job limits of the data sources,
+// skipping invalid ones
+func sourceLimits() jobs.SourceLimits {
+	limits := make(jobs.SourceLimits)
+	for name, sourceConfig := range config.AppConfig.DataSources {
+		for jobType, limit := range sourceConfig.JobLimits {
+			if limit < 1 {
+				log.Logger.Warnf("Ignoring data_sources.%s.job_limits.%s: must be at least 1, got %d", name, jobType, limit)
+				continue
+			}
+			if limits[name] == nil {
+				limits[name] = make(map[jobs.JobType]int)
+			}
+			limits[name][jobs.JobType(jobType)] = limit
+		}
+	}
+	return limits
+}
+
+// jobTimeouts returns the configured timeouts of job types, skipping
+// invalid ones
+func jobTimeouts() map[jobs.JobType]time.Duration {
+	timeouts := make(map[jobs.JobType]time.Duration)
+	for jobType, minutes := range config.AppConfig.Jobs.TypeTimeoutMinutes {
+		if minutes < 0 {
+			log.Logger.Warnf("Ignoring jobs.type_timeout_minutes.%s: must not be negative, got %d", jobType, minutes)
+			continue
+		}
+		timeouts[jobs.JobType(jobType)] = time.Duration(minutes) * time.Minute
+	}
+	return timeouts
+}
+
+// startJobManager creates and starts the job manager and schedules the
+// configured source syncs
+func startJobManager(dataSources map[string]datasource.DataSource) (*jobs.EnhancedJobManager, error) {
+	jobConfig := jobs.DefaultManagerConfig()
+	jobsConfig := config.AppConfig.Jobs
+	if jobsConfig.Workers > 0 {
+		jobConfig.MaxWorkers = jobsConfig.Workers
+	}
+	if jobsConfig.MinWorkers > 0 && jobsConfig.MinWorkers < jobConfig.MaxWorkers {
+		jobConfig.Scaling.Enabled = true
+		jobConfig.Scaling.MinWorkers = jobsConfig.MinWorkers
+		jobConfig.Scaling.MaxWorkers = jobConfig.MaxWorkers
+		if jobsConfig.ScaleUpWaitSeconds > 0 {
+			jobConfig.Scaling.ScaleUpWait = time.Duration(jobsConfig.ScaleUpWaitSeconds) * time.Second
+		}
+		if jobsConfig.IdleSeconds > 0 {
+			jobConfig.Scaling.IdleTimeout = time.Duration(jobsConfig.IdleSeconds) * time.Second
+		}
+	}
+	if jobsConfig.TimeoutMinutes >= 0 {
+		jobConfig.JobTimeout = time.Duration(jobsConfig.TimeoutMinutes) * time.Minute
+	}
+	jobConfig.JobTimeouts = jobTimeouts()
+	if jobsConfig.MaxRetries >= 0 {
+		jobConfig.MaxRetries = jobsConfig.MaxRetries
+	}
+	if jobsConfig.RetryDelaySeconds >= 0 {
+		jobConfig.RetryDelay = time.Duration(jobsConfig.RetryDelaySeconds) * time.Second
+	}
+	if jobsConfig.GracefulTimeoutSeconds > 0 {
+		jobConfig.GracefulTimeout = time.Duration(jobsConfig.GracefulTimeoutSeconds) * time.Second
+	}
+	drainPolicy, err := jobs.ParseDrainPolicy(jobsConfig.DrainPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("jobs.drain_policy: %w", err)
+	}
+	jobConfig.DrainPolicy = drainPolicy
+	jobConfig.SourceLimits = sourceLimits()
+	jobConfig.DiskGuard.MinFreeMB = config.AppConfig.Download.MinFreeMB
+	jobConfig.DiskGuard.ResumeFreeMB = config.AppConfig.Download.ResumeFreeMB
+	jobConfig.QuotaGuard.QuotasMB = config.AppConfig.SourceQuotas()
+	jobConfig.QuotaGuard.WarnPercent = config.AppConfig.Storage.QuotaWarnPercent
+	jobManager, err := jobs.NewEnhancedJobManager(config.AppConfig.StoragePath, dataSources, jobConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job manager: %w", err)
+	}
+	if err := jobManager.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start job manager: %w", err)
+	}
+
+	for name, sourceConfig := range config.AppConfig.DataSources {
+		if sourceConfig.SyncSchedule != "" && dataSources[name] != nil {
+			if _, err := jobManager.ScheduleSourceSync(name, sourceConfig.SyncSchedule); err != nil {
+				log.Logger.Warnf("Failed to schedule sync: %v", err)
+			}
+		}
+		if sourceConfig.UserRefreshSchedule != "" && dataSources[name] != nil {
+			if _, err := jobManager.ScheduleUserRefresh(name, sourceConfig.UserRefreshSchedule); err != nil {
+				log.Logger.Warnf("Failed to schedule user refresh: %v", err)
+			}
+		}
+		if sourceConfig.RepairSchedule != "" && dataSources[name] != nil {
+			if _, err := jobManager.ScheduleItemRepair(name, sourceConfig.RepairSchedule); err != nil {
+				log.Logger.Warnf("Failed to schedule item repair: %v", err)
+			}
+		}
+		if sourceConfig.ArchiveSchedule != "" && dataSources[name] != nil {
+			days := sourceConfig.ArchiveAfterDays
+			if days <= 0 {
+				days = jobs.DefaultArchiveAfterDays
+			}
+			if _, err := jobManager.ScheduleArchive(name, sourceConfig.ArchiveSchedule, days); err != nil {
+				log.Logger.Warnf("Failed to schedule archive: %v", err)
+			}
+		}
+		if sourceConfig.SnapshotSchedule != "" && dataSources[name] != nil {
+			if _, err := jobManager.ScheduleRankingSnapshot(name, sourceConfig.SnapshotSchedule); err != nil {
+				log.Logger.Warnf("Failed to schedule ranking snapshot: %v", err)
+			}
+		}
+		if sourceConfig.EnrichSchedule != "" && dataSources[name] != nil {
+			if _, err := jobManager.ScheduleEnrichment(name, sourceConfig.EnrichSchedule, sourceConfig.Enrichers); err != nil {
+				log.Logger.Warnf("Failed to schedule enrichment: %v", err)
+			}
+		}
+	}
+	return jobManager, nil
+}
+
+func newServeSSHCmd() *cobra.Command {
+	serveSSHCmd := &cobra.Command{
+		Use:   "serve-ssh",
+		Short: "Serve the interactive shell over SSH",
+		Long: `Accept SSH connections and run the interactive shell for each session, with
+the same commands, completions and status bar as a local terminal. Only the
+public keys in ssh.authorized_keys (default ~/.ssh/authorized_keys) may log
+in. The host key is generated in the config directory on first start.
+
+The first session runs downloads and jobs; while it is connected, further
+sessions are read-only. Connect with: ssh -t -p 2222 <host>`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sshConfig := config.AppConfig.SSH
+			addr, _ := cmd.Flags().GetString("listen")
+			if addr == "" {
+				addr = sshConfig.Listen
+			}
+			authorizedKeys := sshConfig.AuthorizedKeys
+			if authorizedKeys == "" {
+				home, err := os.UserHomeDir()
+				if err != nil {
+					return fmt.Errorf("failed to find authorized keys: %w", err)
+				}
+				authorizedKeys = filepath.Join(home, ".ssh", "authorized_keys")
+			}
+
+			executable, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("failed to find the pubdatahub executable: %w", err)
+			}
+			var shellArgs []string
+			if readOnly {
+				shellArgs = append(shellArgs, "--read-only")
+			}
+
+			server, err := sshserver.New(sshserver.Config{
+				HostKeyPath:        filepath.Join(config.Dir(), sshserver.HostKeyFile),
+				AuthorizedKeysPath: authorizedKeys,
+				Command: func() *exec.Cmd {
+					return exec.Command(executable, shellArgs...)
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				return fmt.Errorf("failed to listen on %s: %w", addr, err)
+			}
+			log.Logger.Infof("SSH server listening on %s; press Ctrl+C to stop", listener.Addr())
+
+			stop := make(chan os.Signal, 1)
+			signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-stop
+				log.Logger.Info("Stopping SSH server")
+				server.Close()
+			}()
+			return server.Serve(listener)
+		},
+	}
+
+	serveSSHCmd.Flags().String("listen", "", "Address to listen on (default ssh.listen, :2222)")
+	return serveSSHCmd
+}
+
+// apiAuthenticator builds the API token check from the config. Without
+// configured tokens it uses the generated token, which may control jobs.
+func apiAuthenticator() (*api.Authenticator, error) {
+	apiConfig := config.AppConfig.API
+	if !apiConfig.Auth {
+		log.Logger.Warn("API authentication is disabled (api.auth is false): anyone who can reach the server can control jobs")
+		return nil, nil
+	}
+
+	var tokens []api.Token
+	for _, tokenConfig := range apiConfig.Tokens {
+		scope, err := api.ParseScope(tokenConfig.Scope)
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %w", tokenConfig.Name, err)
+		}
+		value := tokenConfig.Token
+		if name, ok := strings.CutPrefix(value, "secret:"); ok {
+			if value, err = secrets.Default().Get(name); err != nil {
+				return nil, fmt.Errorf("token %s: %w", tokenConfig.Name, err)
+			}
+		}
+		tokens = append(tokens, api.Token{Name: tokenConfig.Name, Value: value, Scope: scope})
+	}
+
+	if len(tokens) == 0 {
+		value, created, err := api.EnsureDefaultToken(secrets.Default())
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			fmt.Printf("Generated an API token, stored as secret %s: %s\n", api.DefaultTokenSecret, value)
+		}
+		log.Logger.Info("API requests need a token; print it with 'pubdatahub serve token'")
+		tokens = append(tokens, api.Token{Name: "default", Value: value, Scope: api.ScopeAdmin})
+	}
+	return api.NewAuthenticator(tokens)
+}
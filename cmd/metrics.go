@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/brainless/PubDataHub/internal/command"
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/spf13/cobra"
+)
+
+func newMetricsCmd() *cobra.Command {
+	metricsCmd := &cobra.Command{
+		Use:   "metrics",
+		Short: "Show job performance metrics kept in the jobs database",
+	}
+
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "List past job runs with their throughput and error rate trends",
+		Long: `List the latest job runs recorded in the jobs database, with items processed
+per second and the share of failed runs drawn as sparklines. A run lasts
+from a job starting or resuming until it completes, fails, pauses or is
+cancelled.`,
+		Example: "  pubdatahub metrics history --source hackernews\n  pubdatahub metrics history --type download --limit 50",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := jobs.MetricsFilter{}
+			filter.DataSource, _ = cmd.Flags().GetString("source")
+			filter.JobType, _ = cmd.Flags().GetString("type")
+			filter.Limit, _ = cmd.Flags().GetInt("limit")
+			if filter.Limit < 1 {
+				return fmt.Errorf("--limit must be at least 1")
+			}
+
+			persistence, err := jobs.NewJobPersistence(config.AppConfig.StoragePath)
+			if err != nil {
+				return err
+			}
+			defer persistence.Close()
+
+			runs, err := persistence.LoadRunMetrics(filter)
+			if err != nil {
+				return err
+			}
+			return command.WriteMetricsHistory(os.Stdout, filter, runs)
+		},
+	}
+	historyCmd.Flags().String("source", "", "Only runs of jobs of this data source")
+	historyCmd.Flags().String("type", "", "Only runs of jobs of this type")
+	historyCmd.Flags().Int("limit", command.DefaultMetricsRuns, "Latest runs to show")
+
+	metricsCmd.AddCommand(historyCmd)
+	return metricsCmd
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/plugin"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// discoverPlugins returns the plugins in the plugins directory and on PATH.
+// It runs before the config is read, so the directory is resolved directly.
+func discoverPlugins() []plugin.Plugin {
+	dir, err := config.ResolveDir()
+	if err != nil {
+		dir = ""
+	} else {
+		dir = plugin.Dir(dir)
+	}
+	return plugin.Discover(dir)
+}
+
+// addPluginCommands makes every plugin a command of rootCmd, skipping those
+// named like a built-in command
+func addPluginCommands(rootCmd *cobra.Command, plugins []plugin.Plugin) {
+	builtin := map[string]bool{"help": true, "completion": true}
+	for _, cmd := range rootCmd.Commands() {
+		builtin[cmd.Name()] = true
+		for _, alias := range cmd.Aliases {
+			builtin[alias] = true
+		}
+	}
+
+	for _, p := range plugins {
+		if builtin[p.Name] {
+			continue
+		}
+		p := p
+		rootCmd.AddCommand(&cobra.Command{
+			Use:                p.Name + " [args...]",
+			Short:              fmt.Sprintf("Plugin %s", p.Path),
+			DisableFlagParsing: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runPlugin(cmd, p, args)
+			},
+		})
+	}
+}
+
+// runPlugin runs p with args and the context of the CLI
+func runPlugin(cmd *cobra.Command, p plugin.Plugin, args []string) error {
+	pctx := plugin.Context{
+		Args:        args,
+		Interface:   plugin.InterfaceCLI,
+		ConfigDir:   config.Dir(),
+		StoragePath: config.AppConfig.StoragePath,
+		ReadOnly:    storage.ReadOnly(),
+	}
+	// Plugin output is the command output, so logs go to stderr
+	log.UseStderr()
+	for name, ds := range openDataSources() {
+		pctx.DataSources = append(pctx.DataSources, plugin.Source{Name: name, StoragePath: ds.GetStoragePath()})
+	}
+	return p.Run(cmd.Context(), pctx, os.Stdout, os.Stderr)
+}
+
+func newPluginsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "plugins",
+		Short: "List the plugins found in the plugins directory and on PATH",
+		Long: `Plugins are executables named ` + plugin.Prefix + `<command> on PATH, or any
+executable in the plugins directory of the config directory. Each becomes the
+command <command> of the CLI and the shell; it gets its arguments on the
+command line and a JSON context with the storage path and data sources on stdin.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plugins := discoverPlugins()
+			if len(plugins) == 0 {
+				fmt.Printf("No plugins found in %s or on PATH\n", plugin.Dir(config.Dir()))
+				return nil
+			}
+			for _, p := range plugins {
+				fmt.Printf("%-20s %s\n", p.Name, p.Path)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/secrets"
+)
+
+// Scope limits what an API token may do
+type Scope string
+
+// API tokens have one of two roles
+const (
+	// ScopeRead allows queries and status: GET requests and GraphQL queries
+	ScopeRead Scope = "read"
+	// ScopeAdmin additionally allows starting downloads, controlling jobs,
+	// changing settings and changing workspaces
+	ScopeAdmin Scope = "admin"
+)
+
+// legacyScopeJobs is the former name of ScopeAdmin, still accepted in configs
+const legacyScopeJobs Scope = "jobs"
+
+// DefaultTokenSecret names the secret that holds the token generated when
+// no tokens are configured
+const DefaultTokenSecret = "api.token"
+
+// tokenCookie carries the token of a browser session for the web app, which
+// cannot send an Authorization header with its requests
+const tokenCookie = "pubdatahub_token"
+
+// ParseScope parses a scope name from the config
+func ParseScope(name string) (Scope, error) {
+	switch Scope(name) {
+	case ScopeRead, ScopeAdmin:
+		return Scope(name), nil
+	case legacyScopeJobs:
+		return ScopeAdmin, nil
+	case "":
+		return ScopeRead, nil
+	}
+	return "", fmt.Errorf("unknown API token scope %q (expected %s or %s)", name, ScopeRead, ScopeAdmin)
+}
+
+// allows reports whether a token with this scope may make the request
+func (s Scope) allows(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	// GraphQL queries are posted but only read, as the schema has no mutations
+	if r.URL.Path == "/api/graphql" {
+		return true
+	}
+	return s == ScopeAdmin
+}
+
+// Token is an API token and what it may do
+type Token struct {
+	Name  string
+	Value string
+	Scope Scope
+}
+
+// Authenticator checks the bearer tokens of /api requests
+type Authenticator struct {
+	// Tokens are looked up by hash so the comparison takes constant time
+	tokens map[[sha256.Size]byte]Token
+}
+
+// NewAuthenticator accepts the given tokens
+func NewAuthenticator(tokens []Token) (*Authenticator, error) {
+	a := &Authenticator{tokens: make(map[[sha256.Size]byte]Token, len(tokens))}
+	for _, token := range tokens {
+		if len(token.Value) < 16 {
+			return nil, fmt.Errorf("API token %s is too short (at least 16 characters)", token.Name)
+		}
+		log.RedactSecret(token.Value)
+		a.tokens[sha256.Sum256([]byte(token.Value))] = token
+	}
+	return a, nil
+}
+
+// Authenticate returns the token matching value
+func (a *Authenticator) Authenticate(value string) (Token, bool) {
+	hash := sha256.Sum256([]byte(value))
+	token, ok := a.tokens[hash]
+	if !ok || subtle.ConstantTimeCompare([]byte(token.Value), []byte(value)) != 1 {
+		return Token{}, false
+	}
+	return token, true
+}
+
+// tokenContextKey stores the authenticated token in the request context
+type tokenContextKey struct{}
+
+// TokenName returns the name of the token that authenticated the request
+func TokenName(ctx context.Context) string {
+	if token, ok := ctx.Value(tokenContextKey{}).(Token); ok {
+		return token.Name
+	}
+	return ""
+}
+
+// Middleware requires a valid token for /api routes and logs each
+// authenticated request. Opening any page with ?token=<token> stores the
+// token in a cookie so the web app can use the API.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	logger := log.ForComponent("api")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if value := r.URL.Query().Get("token"); value != "" && !strings.HasPrefix(r.URL.Path, "/api/") {
+			if _, ok := a.Authenticate(value); ok {
+				http.SetCookie(w, &http.Cookie{
+					Name:     tokenCookie,
+					Value:    value,
+					Path:     "/",
+					HttpOnly: true,
+					SameSite: http.SameSiteStrictMode,
+				})
+			}
+		}
+
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		value := requestToken(r)
+		if value == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pubdatahub"`)
+			writeAuthError(w, http.StatusUnauthorized, "missing API token")
+			return
+		}
+		token, ok := a.Authenticate(value)
+		if !ok {
+			logger.Warnf("Rejected API request %s %s from %s: invalid token", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pubdatahub", error="invalid_token"`)
+			writeAuthError(w, http.StatusUnauthorized, "invalid API token")
+			return
+		}
+		if !token.Scope.allows(r) {
+			logger.Warnf("Rejected API request %s %s by token %s: needs scope %s", r.Method, r.URL.Path, token.Name, ScopeAdmin)
+			writeAuthError(w, http.StatusForbidden, fmt.Sprintf("token %s has scope %s; %s %s needs scope %s", token.Name, token.Scope, r.Method, r.URL.Path, ScopeAdmin))
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), tokenContextKey{}, token)))
+		logger.WithFields(map[string]interface{}{
+			"token":       token.Name,
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      recorder.status,
+			"duration_ms": time.Since(start).Milliseconds(),
+		}).Info("API request")
+	})
+}
+
+// requestToken returns the bearer token of the request, or the token cookie
+// of the web app
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if value, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(value)
+		}
+		return ""
+	}
+	if cookie, err := r.Cookie(tokenCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// writeAuthError writes a JSON error response
+func writeAuthError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "{\"error\": %q}\n", message)
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// GenerateToken returns a new random API token
+func GenerateToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// EnsureDefaultToken returns the token kept in the DefaultTokenSecret
+// secret, generating it on first use; created reports a new token
+func EnsureDefaultToken(store *secrets.Store) (token string, created bool, err error) {
+	token, err = store.Get(DefaultTokenSecret)
+	if err == nil {
+		return token, false, nil
+	}
+	if !errors.Is(err, secrets.ErrNotFound) {
+		return "", false, err
+	}
+
+	if token, err = GenerateToken(); err != nil {
+		return "", false, err
+	}
+	if err := store.Set(DefaultTokenSecret, token); err != nil {
+		return "", false, fmt.Errorf("failed to store API token: %w", err)
+	}
+	return token, true, nil
+}
@@ -0,0 +1,126 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/secrets"
+)
+
+const (
+	readToken = "read-token-0123456789"
+	jobsToken = "jobs-token-0123456789"
+)
+
+func newTestAuthHandler(t *testing.T) http.Handler {
+	t.Helper()
+	log.InitLogger(false)
+
+	auth, err := api.NewAuthenticator([]api.Token{
+		{Name: "dashboard", Value: readToken, Scope: api.ScopeRead},
+		{Name: "ci", Value: jobsToken, Scope: api.ScopeAdmin},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	return auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Token-Name", api.TokenName(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestAuthenticator_Scopes(t *testing.T) {
+	handler := newTestAuthHandler(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+		wantName   string
+	}{
+		{"health needs no token", http.MethodGet, "/health", "", http.StatusOK, ""},
+		{"missing token", http.MethodGet, "/api/sources", "", http.StatusUnauthorized, ""},
+		{"invalid token", http.MethodGet, "/api/sources", "not-a-valid-token-at-all", http.StatusUnauthorized, ""},
+		{"read token reads", http.MethodGet, "/api/jobs", readToken, http.StatusOK, "dashboard"},
+		{"read token cannot control jobs", http.MethodPost, "/api/jobs/1/pause", readToken, http.StatusForbidden, ""},
+		{"jobs token controls jobs", http.MethodPost, "/api/jobs/1/pause", jobsToken, http.StatusOK, "ci"},
+		{"read token posts GraphQL queries", http.MethodPost, "/api/graphql", readToken, http.StatusOK, "dashboard"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("X-Token-Name"); got != tt.wantName {
+				t.Errorf("token name = %q, want %q", got, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_WebAppCookie(t *testing.T) {
+	handler := newTestAuthHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?token="+readToken, nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %v, want one HttpOnly token cookie", cookies)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/sources", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status with cookie = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// An invalid token sets no cookie
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?token=wrong-token-0123456789", nil))
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("cookies = %v, want none", cookies)
+	}
+}
+
+func TestNewAuthenticator_Validation(t *testing.T) {
+	if _, err := api.NewAuthenticator([]api.Token{{Name: "short", Value: "abc"}}); err == nil {
+		t.Error("NewAuthenticator() accepted a short token")
+	}
+	if _, err := api.ParseScope("owner"); err == nil {
+		t.Error("ParseScope() accepted an unknown scope")
+	}
+	if scope, err := api.ParseScope("jobs"); err != nil || scope != api.ScopeAdmin {
+		t.Errorf("ParseScope(\"jobs\") = %q, %v, want admin", scope, err)
+	}
+	if scope, err := api.ParseScope(""); err != nil || scope != api.ScopeRead {
+		t.Errorf("ParseScope(\"\") = %q, %v, want read", scope, err)
+	}
+}
+
+func TestEnsureDefaultToken(t *testing.T) {
+	store := secrets.New(t.TempDir())
+
+	token, created, err := api.EnsureDefaultToken(store)
+	if err != nil || !created || len(token) < 32 {
+		t.Fatalf("EnsureDefaultToken() = %q, %t, %v, want a new token", token, created, err)
+	}
+	again, created, err := api.EnsureDefaultToken(store)
+	if err != nil || created || again != token {
+		t.Errorf("EnsureDefaultToken() = %q, %t, %v, want the stored token", again, created, err)
+	}
+}
@@ -0,0 +1,500 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/audit"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/query"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/brainless/PubDataHub/internal/workspace"
+	"github.com/graphql-go/graphql"
+)
+
+// GraphQLQueryLimit is the most rows runSavedQuery returns unless the
+// request asks for fewer
+var GraphQLQueryLimit = 1000
+
+// GraphQLRequest is the body of a GraphQL request
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// graphqlHandler handles GraphQL queries, sent as a JSON body with POST or
+// as the query parameter with GET. The schema has no mutations, so it only
+// reads.
+func (s *Server) graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				http.Error(w, "Invalid variables parameter", http.StatusBadRequest)
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Query == "" {
+		http.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s.graphqlSchema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// newGraphQLSchema builds the schema over the server's job manager, data
+// sources and workspaces
+func (s *Server) newGraphQLSchema() (graphql.Schema, error) {
+	columnType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Column",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"type": &graphql.Field{Type: graphql.String},
+		},
+	})
+	tableType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Table",
+		Fields: graphql.Fields{
+			"name":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"columns": &graphql.Field{Type: graphql.NewList(columnType)},
+		},
+	})
+	downloadType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "DownloadStatus",
+		Fields: graphql.Fields{
+			"active":       &graphql.Field{Type: graphql.Boolean},
+			"status":       &graphql.Field{Type: graphql.String},
+			"progress":     &graphql.Field{Type: graphql.Float, Description: "Between 0 and 1"},
+			"itemsTotal":   &graphql.Field{Type: graphql.Float},
+			"itemsCached":  &graphql.Field{Type: graphql.Float},
+			"lastUpdate":   &graphql.Field{Type: graphql.DateTime},
+			"errorMessage": &graphql.Field{Type: graphql.String},
+		},
+	})
+	sourceType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Source",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(datasource.DataSource).Name(), nil
+				},
+			},
+			"description": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(datasource.DataSource).Description(), nil
+				},
+			},
+			"download": &graphql.Field{
+				Type: downloadType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					status := p.Source.(datasource.DataSource).GetDownloadStatus()
+					return map[string]interface{}{
+						"active":       status.IsActive,
+						"status":       status.Status,
+						"progress":     status.Progress,
+						"itemsTotal":   status.ItemsTotal,
+						"itemsCached":  status.ItemsCached,
+						"lastUpdate":   status.LastUpdate,
+						"errorMessage": status.ErrorMessage,
+					}, nil
+				},
+			},
+			"tables": &graphql.Field{
+				Type:        graphql.NewList(tableType),
+				Description: "The tables of the source and their columns",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return schemaTables(p.Source.(datasource.DataSource).GetSchema()), nil
+				},
+			},
+		},
+	})
+
+	progressType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "JobProgress",
+		Fields: graphql.Fields{
+			"current":    &graphql.Field{Type: graphql.Float},
+			"total":      &graphql.Field{Type: graphql.Float},
+			"percentage": &graphql.Field{Type: graphql.Float},
+			"message":    &graphql.Field{Type: graphql.String},
+			"rate":       &graphql.Field{Type: graphql.Float, Description: "Items per second"},
+			"stalled":    &graphql.Field{Type: graphql.Boolean},
+		},
+	})
+	jobType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Job",
+		Fields: graphql.Fields{
+			"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"type":         &graphql.Field{Type: graphql.String},
+			"state":        &graphql.Field{Type: graphql.String},
+			"priority":     &graphql.Field{Type: graphql.Int},
+			"progress":     &graphql.Field{Type: progressType},
+			"startTime":    &graphql.Field{Type: graphql.DateTime},
+			"endTime":      &graphql.Field{Type: graphql.DateTime},
+			"errorMessage": &graphql.Field{Type: graphql.String},
+			"retryCount":   &graphql.Field{Type: graphql.Int},
+			"maxRetries":   &graphql.Field{Type: graphql.Int},
+			"createdBy":    &graphql.Field{Type: graphql.String},
+			"description":  &graphql.Field{Type: graphql.String},
+			"source":       &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	savedQueryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SavedQuery",
+		Fields: graphql.Fields{
+			"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"query":       &graphql.Field{Type: graphql.String},
+			"dataSource":  &graphql.Field{Type: graphql.String},
+			"description": &graphql.Field{Type: graphql.String},
+			"tags":        &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"parameters":  &graphql.Field{Type: graphql.NewList(graphql.String), Description: "Names of the {placeholders} runSavedQuery needs values for"},
+			"usageCount":  &graphql.Field{Type: graphql.Int},
+			"lastUsed":    &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+	workspaceType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Workspace",
+		Fields: graphql.Fields{
+			"name":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"description":  &graphql.Field{Type: graphql.String},
+			"tags":         &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"lastUsed":     &graphql.Field{Type: graphql.DateTime},
+			"savedQueries": &graphql.Field{Type: graphql.NewList(savedQueryType)},
+		},
+	})
+
+	queryResultType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "QueryResult",
+		Fields: graphql.Fields{
+			"columns":    &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"rows":       &graphql.Field{Type: graphql.NewList(graphql.NewList(graphql.String)), Description: "Values as text; NULL is null"},
+			"count":      &graphql.Field{Type: graphql.Int},
+			"durationMs": &graphql.Field{Type: graphql.Float},
+			"truncated":  &graphql.Field{Type: graphql.Boolean},
+		},
+	})
+	parameterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "QueryParameter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"value": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"sources": &graphql.Field{
+				Type: graphql.NewList(sourceType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					names := make([]string, 0, len(s.dataSources))
+					for name := range s.dataSources {
+						names = append(names, name)
+					}
+					sort.Strings(names)
+					sources := make([]datasource.DataSource, 0, len(names))
+					for _, name := range names {
+						sources = append(sources, s.dataSources[name])
+					}
+					return sources, nil
+				},
+			},
+			"source": &graphql.Field{
+				Type: sourceType,
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					ds, ok := s.dataSources[p.Args["name"].(string)]
+					if !ok {
+						return nil, nil
+					}
+					return ds, nil
+				},
+			},
+			"jobs": &graphql.Field{
+				Type: graphql.NewList(jobType),
+				Args: graphql.FieldConfigArgument{
+					"state":  &graphql.ArgumentConfig{Type: graphql.String},
+					"type":   &graphql.ArgumentConfig{Type: graphql.String},
+					"source": &graphql.ArgumentConfig{Type: graphql.String},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					filter := jobs.JobFilter{}
+					if state, ok := p.Args["state"].(string); ok {
+						filter.States = []jobs.JobState{jobs.JobState(state)}
+					}
+					if jobType, ok := p.Args["type"].(string); ok {
+						filter.Types = []jobs.JobType{jobs.JobType(jobType)}
+					}
+					if source, ok := p.Args["source"].(string); ok {
+						filter.Source = source
+					}
+					statuses, err := s.jobManager.ListJobs(filter)
+					if err != nil {
+						return nil, fmt.Errorf("failed to list jobs: %w", err)
+					}
+					if limit, ok := p.Args["limit"].(int); ok && limit >= 0 && limit < len(statuses) {
+						statuses = statuses[:limit]
+					}
+					result := make([]map[string]interface{}, len(statuses))
+					for i, status := range statuses {
+						result[i] = graphqlJob(status)
+					}
+					return result, nil
+				},
+			},
+			"job": &graphql.Field{
+				Type: jobType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					status, err := s.jobManager.GetJob(p.Args["id"].(string))
+					if err != nil {
+						return nil, err
+					}
+					return graphqlJob(status), nil
+				},
+			},
+			"workspaces": &graphql.Field{
+				Type: graphql.NewList(workspaceType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if s.workspaces == nil {
+						return nil, fmt.Errorf("workspaces are not available")
+					}
+					workspaces, err := s.workspaces.List()
+					if err != nil {
+						return nil, err
+					}
+					result := make([]map[string]interface{}, len(workspaces))
+					for i, ws := range workspaces {
+						result[i] = graphqlWorkspace(ws)
+					}
+					return result, nil
+				},
+			},
+			"workspace": &graphql.Field{
+				Type: workspaceType,
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if s.workspaces == nil {
+						return nil, fmt.Errorf("workspaces are not available")
+					}
+					ws, err := s.workspaces.Get(p.Args["name"].(string))
+					if err != nil {
+						return nil, err
+					}
+					return graphqlWorkspace(ws), nil
+				},
+			},
+			"runSavedQuery": &graphql.Field{
+				Type:        queryResultType,
+				Description: "Runs a saved query of a workspace with values for its parameters",
+				Args: graphql.FieldConfigArgument{
+					"workspace":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"name":       &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"parameters": &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(parameterType))},
+					"limit":      &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: s.runSavedQuery,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// runSavedQuery resolves runSavedQuery. Only queries that read are run.
+func (s *Server) runSavedQuery(p graphql.ResolveParams) (interface{}, error) {
+	if s.workspaces == nil {
+		return nil, fmt.Errorf("workspaces are not available")
+	}
+	ws, err := s.workspaces.Get(p.Args["workspace"].(string))
+	if err != nil {
+		return nil, err
+	}
+	name := p.Args["name"].(string)
+	saved, ok := ws.SavedQueries[name]
+	if !ok {
+		return nil, fmt.Errorf("query '%s' %w", name, workspace.ErrNotFound)
+	}
+	ds, ok := s.dataSources[saved.DataSource]
+	if !ok {
+		return nil, fmt.Errorf("unknown data source: %s", saved.DataSource)
+	}
+
+	values := make(map[string]string)
+	if parameters, ok := p.Args["parameters"].([]interface{}); ok {
+		for _, parameter := range parameters {
+			fields := parameter.(map[string]interface{})
+			values[fields["name"].(string)] = fields["value"].(string)
+		}
+	}
+	sql, err := query.BindParameters(saved.Query, values)
+	if err != nil {
+		return nil, err
+	}
+	if tables := storage.WrittenTables(sql); len(tables) > 0 {
+		return nil, fmt.Errorf("only queries that read can run through GraphQL; this query writes %v", tables)
+	}
+
+	caller := audit.Caller{Interface: audit.InterfaceAPI, User: TokenName(p.Context)}
+	result, err := audit.Query(caller, saved.DataSource, sql, ds.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := GraphQLQueryLimit
+	if requested, ok := p.Args["limit"].(int); ok && requested >= 0 && requested < limit {
+		limit = requested
+	}
+	rows := make([][]interface{}, 0, min(len(result.Rows), limit))
+	for i, row := range result.Rows {
+		if i == limit {
+			break
+		}
+		values := make([]interface{}, len(row))
+		for j, value := range row {
+			if value != nil {
+				values[j] = graphqlText(value)
+			}
+		}
+		rows = append(rows, values)
+	}
+
+	return map[string]interface{}{
+		"columns":    result.Columns,
+		"rows":       rows,
+		"count":      result.Count,
+		"durationMs": float64(result.Duration) / float64(time.Millisecond),
+		"truncated":  len(result.Rows) > limit,
+	}, nil
+}
+
+// schemaTables converts a data source schema for GraphQL
+func schemaTables(schema datasource.Schema) []map[string]interface{} {
+	tables := make([]map[string]interface{}, len(schema.Tables))
+	for i, table := range schema.Tables {
+		columns := make([]map[string]interface{}, len(table.Columns))
+		for j, column := range table.Columns {
+			columns[j] = map[string]interface{}{"name": column.Name, "type": column.Type}
+		}
+		tables[i] = map[string]interface{}{"name": table.Name, "columns": columns}
+	}
+	return tables
+}
+
+// graphqlJob converts a job status for GraphQL
+func graphqlJob(status *jobs.JobStatus) map[string]interface{} {
+	job := map[string]interface{}{
+		"id":       status.ID,
+		"type":     string(status.Type),
+		"state":    string(status.State),
+		"priority": int(status.Priority),
+		"progress": map[string]interface{}{
+			"current":    status.Progress.Current,
+			"total":      status.Progress.Total,
+			"percentage": status.Progress.Percentage(),
+			"message":    status.Progress.Message,
+			"rate":       status.Progress.Rate,
+			"stalled":    status.Progress.Stalled,
+		},
+		"startTime":    status.StartTime,
+		"errorMessage": status.ErrorMessage,
+		"retryCount":   status.RetryCount,
+		"maxRetries":   status.MaxRetries,
+		"createdBy":    status.CreatedBy,
+		"description":  status.Description,
+	}
+	if status.EndTime != nil {
+		job["endTime"] = *status.EndTime
+	}
+	if source, ok := status.Metadata["source_name"].(string); ok {
+		job["source"] = source
+	}
+	return job
+}
+
+// graphqlWorkspace converts a workspace for GraphQL, with its saved queries
+// sorted by name
+func graphqlWorkspace(ws *workspace.Workspace) map[string]interface{} {
+	queries := make([]map[string]interface{}, 0, len(ws.SavedQueries))
+	for _, saved := range ws.SavedQueries {
+		queries = append(queries, map[string]interface{}{
+			"name":        saved.Name,
+			"query":       saved.Query,
+			"dataSource":  saved.DataSource,
+			"description": saved.Description,
+			"tags":        saved.Tags,
+			"parameters":  query.Parameters(saved.Query),
+			"usageCount":  saved.UsageCount,
+			"lastUsed":    saved.LastUsed,
+		})
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i]["name"].(string) < queries[j]["name"].(string) })
+
+	return map[string]interface{}{
+		"name":         ws.Name,
+		"description":  ws.Description,
+		"tags":         ws.Tags,
+		"lastUsed":     ws.LastUsed,
+		"savedQueries": queries,
+	}
+}
+
+// graphqlText formats a query value as text
+func graphqlText(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// registerGraphQLRoutesOnMux registers the GraphQL endpoint on the provided mux
+func (s *Server) registerGraphQLRoutesOnMux(mux *http.ServeMux) {
+	schema, err := s.newGraphQLSchema()
+	if err != nil {
+		log.Logger.Errorf("Failed to build GraphQL schema: %v", err)
+		return
+	}
+	s.graphqlSchema = schema
+
+	mux.HandleFunc("GET /api/graphql", s.graphqlHandler)
+	mux.HandleFunc("POST /api/graphql", s.graphqlHandler)
+}
@@ -0,0 +1,133 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/workspace"
+)
+
+// recordingDataSource records the queries it runs
+type recordingDataSource struct {
+	*datasource.MockDataSource
+	queries []string
+}
+
+func (d *recordingDataSource) Query(query string) (datasource.QueryResult, error) {
+	d.queries = append(d.queries, query)
+	return d.MockDataSource.Query(query)
+}
+
+func TestGraphQLEndpoint(t *testing.T) {
+	// Initialize logger for tests
+	log.InitLogger(false)
+
+	service, err := workspace.NewService(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create workspace service: %v", err)
+	}
+	if _, err := service.Create("research", ""); err != nil {
+		t.Fatalf("Failed to create workspace: %v", err)
+	}
+	if _, err := service.SaveQuery("research", workspace.SavedQuery{Name: "top", Query: "SELECT id FROM items WHERE by = {author}", DataSource: "mock"}); err != nil {
+		t.Fatalf("Failed to save query: %v", err)
+	}
+	if _, err := service.SaveQuery("research", workspace.SavedQuery{Name: "purge", Query: "DELETE FROM items", DataSource: "mock"}); err != nil {
+		t.Fatalf("Failed to save query: %v", err)
+	}
+
+	source := &recordingDataSource{MockDataSource: datasource.NewMockDataSource("mock", "Mock source")}
+
+	addr := ":8088" // Use a different port to avoid conflicts
+	server := api.NewServerWithConfig(addr, &mockJobManager{}, api.ServerConfig{GraphQL: true})
+	server.SetDataSources(map[string]datasource.DataSource{"mock": source})
+	server.SetWorkspaces(service)
+
+	go func() {
+		if err := server.Start(); err != nil {
+			t.Errorf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Give the server a moment to start
+	time.Sleep(100 * time.Millisecond)
+
+	graphql := func(t *testing.T, query string, variables map[string]interface{}) map[string]interface{} {
+		t.Helper()
+		body, _ := json.Marshal(api.GraphQLRequest{Query: query, Variables: variables})
+		resp, err := http.Post(fmt.Sprintf("http://localhost%s/api/graphql", addr), "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to make request to GraphQL endpoint: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+		}
+
+		var result map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return result
+	}
+
+	t.Run("Sources and jobs", func(t *testing.T) {
+		result := graphql(t, `{ sources { name description } jobs { id } }`, nil)
+		if result["errors"] != nil {
+			t.Fatalf("Unexpected errors: %v", result["errors"])
+		}
+		data := result["data"].(map[string]interface{})
+		sources := data["sources"].([]interface{})
+		if len(sources) != 1 || sources[0].(map[string]interface{})["name"] != "mock" {
+			t.Errorf("Expected the mock source, got %v", sources)
+		}
+		if jobs := data["jobs"].([]interface{}); len(jobs) != 0 {
+			t.Errorf("Expected no jobs, got %v", jobs)
+		}
+	})
+
+	t.Run("Saved queries with parameters", func(t *testing.T) {
+		result := graphql(t, `query Top($author: String!) {
+			workspace(name: "research") { savedQueries { name parameters } }
+			runSavedQuery(workspace: "research", name: "top", parameters: [{name: "author", value: $author}], limit: 1) {
+				columns rows truncated
+			}
+		}`, map[string]interface{}{"author": "o'brien"})
+		if result["errors"] != nil {
+			t.Fatalf("Unexpected errors: %v", result["errors"])
+		}
+		data := result["data"].(map[string]interface{})
+		queries := data["workspace"].(map[string]interface{})["savedQueries"].([]interface{})
+		if top := queries[1].(map[string]interface{}); top["name"] != "top" || fmt.Sprint(top["parameters"]) != "[author]" {
+			t.Errorf("Expected the top query with an author parameter, got %v", top)
+		}
+		run := data["runSavedQuery"].(map[string]interface{})
+		if rows := run["rows"].([]interface{}); len(rows) != 1 || run["truncated"] != true {
+			t.Errorf("Expected one row of two, got %v", run)
+		}
+		if want := "SELECT id FROM items WHERE by = 'o''brien'"; len(source.queries) != 1 || source.queries[0] != want {
+			t.Errorf("Expected query %q, got %v", want, source.queries)
+		}
+	})
+
+	t.Run("Only reads", func(t *testing.T) {
+		result := graphql(t, `{ runSavedQuery(workspace: "research", name: "purge") { count } }`, nil)
+		if result["errors"] == nil {
+			t.Error("Expected a saved query that writes to be refused")
+		}
+		result = graphql(t, `{ runSavedQuery(workspace: "research", name: "top") { count } }`, nil)
+		if result["errors"] == nil {
+			t.Error("Expected a missing parameter to be refused")
+		}
+		if len(source.queries) != 1 {
+			t.Errorf("Expected no further queries to run, got %v", source.queries)
+		}
+	})
+}
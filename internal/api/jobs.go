@@ -2,9 +2,9 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/brainless/PubDataHub/internal/jobs"
@@ -117,66 +117,59 @@ func (s *Server) startDownloadJobHandler(w http.ResponseWriter, r *http.Request)
 
 // pauseJobHandler handles requests to pause a job
 func (s *Server) pauseJobHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract job ID from URL path
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) != 5 || pathParts[4] != "pause" {
-		http.Error(w, "Invalid URL path", http.StatusBadRequest)
-		return
-	}
-
-	jobID := pathParts[3]
-	if jobID == "" {
-		http.Error(w, "Job ID is required", http.StatusBadRequest)
-		return
-	}
-
-	// Use the job manager to pause the job
-	err := s.jobManager.PauseJob(jobID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to pause job: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	response := map[string]interface{}{
-		"message": fmt.Sprintf("Job %s paused successfully", jobID),
-		"job_id":  jobID,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		return
-	}
+	s.controlJob(w, r, "pause", "paused", s.jobManager.PauseJob)
 }
 
 // resumeJobHandler handles requests to resume a job
 func (s *Server) resumeJobHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract job ID from URL path
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) != 5 || pathParts[4] != "resume" {
-		http.Error(w, "Invalid URL path", http.StatusBadRequest)
-		return
-	}
+	s.controlJob(w, r, "resume", "resumed", s.jobManager.ResumeJob)
+}
+
+// cancelJobHandler handles requests to cancel a job
+func (s *Server) cancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	s.controlJob(w, r, "cancel", "cancelled", s.jobManager.CancelJob)
+}
 
-	jobID := pathParts[3]
+// retryJobHandler handles requests to retry a failed job, which is queued
+// and started again
+func (s *Server) retryJobHandler(w http.ResponseWriter, r *http.Request) {
+	s.controlJob(w, r, "retry", "retried", func(id string) error {
+		if err := s.jobManager.RetryJob(id); err != nil {
+			return err
+		}
+		return s.jobManager.StartJob(id)
+	})
+}
+
+// controlJob applies an action to the job in the URL path and responds with
+// the job's state afterwards. Unknown jobs get 404 and actions the job's
+// state does not allow get 409.
+func (s *Server) controlJob(w http.ResponseWriter, r *http.Request, verb, done string, action func(id string) error) {
+	jobID := r.PathValue("job_id")
 	if jobID == "" {
 		http.Error(w, "Job ID is required", http.StatusBadRequest)
 		return
 	}
 
-	// Use the job manager to resume the job
-	err := s.jobManager.ResumeJob(jobID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to resume job: %v", err), http.StatusInternalServerError)
+	if err := action(jobID); err != nil {
+		switch {
+		case errors.Is(err, jobs.ErrJobNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, jobs.ErrInvalidJobState):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, fmt.Sprintf("Failed to %s job: %v", verb, err), http.StatusInternalServerError)
+		}
 		return
 	}
 
 	response := map[string]interface{}{
-		"message": fmt.Sprintf("Job %s resumed successfully", jobID),
+		"message": fmt.Sprintf("Job %s %s successfully", jobID, done),
 		"job_id":  jobID,
 	}
+	if status, err := s.jobManager.GetJob(jobID); err == nil {
+		response["job"] = convertJobStatusToJobInfo(status)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -189,7 +182,7 @@ func (s *Server) resumeJobHandler(w http.ResponseWriter, r *http.Request) {
 
 // registerJobsRoutes registers the jobs-related routes (legacy)
 func (s *Server) registerJobsRoutes() {
-	s.registerJobsRoutesOnMux(s.httpServer.Handler.(*http.ServeMux))
+	s.registerJobsRoutesOnMux(s.mux)
 }
 
 // registerJobsRoutesOnMux registers the jobs-related routes on provided mux
@@ -198,4 +191,6 @@ func (s *Server) registerJobsRoutesOnMux(mux *http.ServeMux) {
 	mux.HandleFunc("POST /api/jobs/download", s.startDownloadJobHandler)
 	mux.HandleFunc("POST /api/jobs/{job_id}/pause", s.pauseJobHandler)
 	mux.HandleFunc("POST /api/jobs/{job_id}/resume", s.resumeJobHandler)
+	mux.HandleFunc("POST /api/jobs/{job_id}/cancel", s.cancelJobHandler)
+	mux.HandleFunc("POST /api/jobs/{job_id}/retry", s.retryJobHandler)
 }
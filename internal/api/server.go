@@ -7,21 +7,32 @@ import (
 	"strings"
 	"time"
 
+	"github.com/brainless/PubDataHub/internal/datasource"
 	"github.com/brainless/PubDataHub/internal/jobs"
 	"github.com/brainless/PubDataHub/internal/log"
 	"github.com/brainless/PubDataHub/internal/web"
+	"github.com/brainless/PubDataHub/internal/workspace"
+	"github.com/graphql-go/graphql"
 )
 
 // ServerConfig represents server configuration options
 type ServerConfig struct {
-	ServeStatic bool // Whether to serve static frontend files
+	ServeStatic bool           // Whether to serve static frontend files
+	Auth        *Authenticator // Requires API tokens for /api routes when set
+	GraphQL     bool           // Whether to serve the read-only GraphQL endpoint at /api/graphql
 }
 
 // Server represents the API server
 type Server struct {
-	httpServer *http.Server
-	jobManager jobs.JobManager
-	config     ServerConfig
+	httpServer    *http.Server
+	mux           *http.ServeMux
+	jobManager    jobs.JobManager
+	dataSources   map[string]datasource.DataSource
+	workspaces    *workspace.Service
+	storagePath   string
+	storageStats  storageStatsCache
+	graphqlSchema graphql.Schema
+	config        ServerConfig
 }
 
 // NewServer creates a new API-only server instance
@@ -39,6 +50,7 @@ func NewServerWithConfig(addr string, jobManager jobs.JobManager, config ServerC
 	mux := http.NewServeMux()
 
 	server := &Server{
+		mux:        mux,
 		jobManager: jobManager,
 		config:     config,
 	}
@@ -55,10 +67,15 @@ func NewServerWithConfig(addr string, jobManager jobs.JobManager, config ServerC
 		mux.HandleFunc("/", rootHandler)
 	}
 
+	var handler http.Handler = mux
+	if config.Auth != nil {
+		handler = config.Auth.Middleware(mux)
+	}
+
 	// Create server instance
 	server.httpServer = &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: handler,
 	}
 
 	return server
@@ -97,6 +114,11 @@ func (s *Server) registerAPIRoutes(mux *http.ServeMux) {
 	// API routes
 	s.registerSourcesRoutesOnMux(mux)
 	s.registerJobsRoutesOnMux(mux)
+	s.registerStorageRoutesOnMux(mux)
+	s.registerWorkspaceRoutesOnMux(mux)
+	if s.config.GraphQL {
+		s.registerGraphQLRoutesOnMux(mux)
+	}
 }
 
 // registerStaticRoutes registers static file serving routes
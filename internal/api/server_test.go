@@ -373,3 +373,69 @@ func TestJobsEndpoints(t *testing.T) {
 		t.Errorf("Failed to stop server: %v", err)
 	}
 }
+
+// controlJobManager fails job actions the way the job manager does, by job ID
+type controlJobManager struct {
+	mockJobManager
+}
+
+func (m *controlJobManager) control(id string) error {
+	switch id {
+	case "missing":
+		return fmt.Errorf("%w: %s", jobs.ErrJobNotFound, id)
+	case "finished":
+		return fmt.Errorf("wrapped: %w", jobs.ErrInvalidJobState)
+	}
+	return nil
+}
+
+func (m *controlJobManager) PauseJob(id string) error  { return m.control(id) }
+func (m *controlJobManager) ResumeJob(id string) error { return m.control(id) }
+func (m *controlJobManager) CancelJob(id string) error { return m.control(id) }
+func (m *controlJobManager) RetryJob(id string) error  { return m.control(id) }
+
+func TestJobControlEndpoints(t *testing.T) {
+	// Initialize logger for tests
+	log.InitLogger(false)
+
+	addr := ":8087" // Use a different port to avoid conflicts
+	server := api.NewServer(addr, &controlJobManager{})
+
+	go func() {
+		if err := server.Start(); err != nil {
+			t.Errorf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Give the server a moment to start
+	time.Sleep(100 * time.Millisecond)
+
+	tests := []struct {
+		jobID  string
+		status int
+	}{
+		{"test-job-789", http.StatusOK},
+		{"missing", http.StatusNotFound},
+		{"finished", http.StatusConflict},
+	}
+	for _, action := range []string{"pause", "resume", "cancel", "retry"} {
+		for _, tt := range tests {
+			resp, err := http.Post(fmt.Sprintf("http://localhost%s/api/jobs/%s/%s", addr, tt.jobID, action), "application/json", nil)
+			if err != nil {
+				t.Fatalf("Failed to make request to %s endpoint: %v", action, err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("%s %s: expected status %d, got %d", action, tt.jobID, tt.status, resp.StatusCode)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Stop(ctx); err != nil {
+		t.Errorf("Failed to stop server: %v", err)
+	}
+}
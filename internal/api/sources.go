@@ -295,7 +295,7 @@ func (s *Server) getDataHandler(w http.ResponseWriter, r *http.Request) {
 
 // registerSourcesRoutes registers the sources-related routes (legacy)
 func (s *Server) registerSourcesRoutes() {
-	s.registerSourcesRoutesOnMux(s.httpServer.Handler.(*http.ServeMux))
+	s.registerSourcesRoutesOnMux(s.mux)
 }
 
 // registerSourcesRoutesOnMux registers the sources-related routes on provided mux
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// StorageStatsTTL is how long storage stats are reused before the data
+// source directories are walked again
+var StorageStatsTTL = time.Minute
+
+// databaseHealthReporter is implemented by data sources that monitor their database
+type databaseHealthReporter interface {
+	DatabaseHealth() storage.DBHealth
+}
+
+// StorageHealthResponse represents the database health of all data sources
+type StorageHealthResponse struct {
+	Status    string                      `json:"status"`
+	Sources   map[string]storage.DBHealth `json:"sources"`
+	Timestamp time.Time                   `json:"timestamp"`
+}
+
+// StoragePathResponse reports whether a path can be used for storage
+type StoragePathResponse struct {
+	Path       string `json:"path"`
+	Valid      bool   `json:"valid"`
+	Error      string `json:"error,omitempty"`
+	FreeBytes  uint64 `json:"free_bytes,omitempty"`
+	TotalBytes uint64 `json:"total_bytes,omitempty"`
+}
+
+// SourceStorageStats is the storage used by one data source
+type SourceStorageStats struct {
+	Source string `json:"source"`
+	Path   string `json:"path"`
+	jobs.DirectoryStats
+	Error string `json:"error,omitempty"`
+}
+
+// StorageStatsResponse reports the disk space of the storage path and the
+// storage used by each data source
+type StorageStatsResponse struct {
+	StoragePath    string               `json:"storage_path"`
+	FreeBytes      uint64               `json:"free_bytes"`
+	TotalBytes     uint64               `json:"total_bytes"`
+	UsedBytes      uint64               `json:"used_bytes"` // By the data sources
+	OldestDownload time.Time            `json:"oldest_download,omitempty"`
+	Sources        []SourceStorageStats `json:"sources"`
+	MeasuredAt     time.Time            `json:"measured_at"`
+}
+
+// storageStatsCache keeps the last storage stats, as walking large data
+// source directories takes a while
+type storageStatsCache struct {
+	mu    sync.Mutex
+	stats *StorageStatsResponse
+}
+
+// SetStoragePath sets the storage path reported by the storage stats endpoint
+func (s *Server) SetStoragePath(path string) {
+	s.storagePath = path
+}
+
+// SetDataSources sets the data sources exposed by storage endpoints
+func (s *Server) SetDataSources(dataSources map[string]datasource.DataSource) {
+	s.dataSources = dataSources
+}
+
+// getStorageHealthHandler handles requests for database health
+func (s *Server) getStorageHealthHandler(w http.ResponseWriter, r *http.Request) {
+	response := StorageHealthResponse{
+		Status:    "healthy",
+		Sources:   make(map[string]storage.DBHealth),
+		Timestamp: time.Now(),
+	}
+
+	for name, ds := range s.dataSources {
+		reporter, ok := ds.(databaseHealthReporter)
+		if !ok {
+			continue
+		}
+		health := reporter.DatabaseHealth()
+		response.Sources[name] = health
+		response.Status = worseHealthStatus(response.Status, health.Status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status == "unhealthy" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode storage health", http.StatusInternalServerError)
+		return
+	}
+}
+
+// validateStoragePathHandler handles requests to check a storage path
+func (s *Server) validateStoragePathHandler(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "Missing path parameter", http.StatusBadRequest)
+		return
+	}
+
+	response := StoragePathResponse{Path: path}
+	resolved, free, err := jobs.ValidateStoragePath(path)
+	if err != nil {
+		response.Error = err.Error()
+	} else {
+		response.Path = resolved
+		response.Valid = true
+		response.FreeBytes = free
+		if _, total, err := jobs.DiskSpace(nearestDir(resolved)); err == nil {
+			response.TotalBytes = total
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode storage path check", http.StatusInternalServerError)
+		return
+	}
+}
+
+// getStorageStatsHandler handles requests for storage stats; refresh=true
+// measures again instead of reusing recent stats
+func (s *Server) getStorageStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.storagePath == "" {
+		http.Error(w, "Storage stats are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	s.storageStats.mu.Lock()
+	stats := s.storageStats.stats
+	if stats == nil || r.URL.Query().Get("refresh") == "true" || time.Since(stats.MeasuredAt) > StorageStatsTTL {
+		stats = s.measureStorage()
+		s.storageStats.stats = stats
+	}
+	s.storageStats.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		http.Error(w, "Failed to encode storage stats", http.StatusInternalServerError)
+		return
+	}
+}
+
+// measureStorage walks the directory of every data source
+func (s *Server) measureStorage() *StorageStatsResponse {
+	stats := &StorageStatsResponse{
+		StoragePath: s.storagePath,
+		Sources:     make([]SourceStorageStats, 0, len(s.dataSources)),
+		MeasuredAt:  time.Now(),
+	}
+	if free, total, err := jobs.DiskSpace(s.storagePath); err == nil {
+		stats.FreeBytes, stats.TotalBytes = free, total
+	}
+
+	for name := range s.dataSources {
+		source := SourceStorageStats{Source: name, Path: jobs.SourceDir(s.storagePath, name)}
+		dirStats, err := jobs.MeasureDirectory(source.Path)
+		if err != nil {
+			source.Error = err.Error()
+		}
+		source.DirectoryStats = dirStats
+		stats.UsedBytes += dirStats.Bytes
+		if !dirStats.Oldest.IsZero() && (stats.OldestDownload.IsZero() || dirStats.Oldest.Before(stats.OldestDownload)) {
+			stats.OldestDownload = dirStats.Oldest
+		}
+		stats.Sources = append(stats.Sources, source)
+	}
+	sort.Slice(stats.Sources, func(i, j int) bool { return stats.Sources[i].Source < stats.Sources[j].Source })
+	return stats
+}
+
+// nearestDir returns path, or its nearest existing parent when path does not
+// exist yet
+func nearestDir(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// worseHealthStatus returns the more severe of two health statuses
+func worseHealthStatus(a, b string) string {
+	rank := map[string]int{"healthy": 0, "unknown": 1, "degraded": 2, "unhealthy": 3}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// registerStorageRoutesOnMux registers storage routes on the provided mux
+func (s *Server) registerStorageRoutesOnMux(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/storage/health", s.getStorageHealthHandler)
+	mux.HandleFunc("GET /api/storage/validate", s.validateStoragePathHandler)
+	mux.HandleFunc("GET /api/storage/stats", s.getStorageStatsHandler)
+}
@@ -0,0 +1,110 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+func TestStorageStatsEndpoints(t *testing.T) {
+	// Initialize logger for tests
+	log.InitLogger(false)
+
+	storagePath := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(storagePath, "hackernews"), 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(storagePath, "hackernews", "hackernews.sqlite"), make([]byte, 2048), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+
+	addr := ":8086" // Use a different port to avoid conflicts
+	server := api.NewServer(addr, &mockJobManager{})
+	server.SetDataSources(map[string]datasource.DataSource{"hackernews": nil})
+	server.SetStoragePath(storagePath)
+
+	go func() {
+		if err := server.Start(); err != nil {
+			t.Errorf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Give the server a moment to start
+	time.Sleep(100 * time.Millisecond)
+
+	base := fmt.Sprintf("http://localhost%s/api/storage", addr)
+
+	t.Run("Stats", func(t *testing.T) {
+		resp, err := http.Get(base + "/stats")
+		if err != nil {
+			t.Fatalf("Failed to make request to stats endpoint: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+		}
+
+		var stats api.StorageStatsResponse
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if stats.UsedBytes != 2048 || len(stats.Sources) != 1 || stats.Sources[0].Files != 1 {
+			t.Errorf("Expected one 2048 byte file for hackernews, got %+v", stats)
+		}
+		if stats.OldestDownload.IsZero() {
+			t.Error("Expected the oldest download time to be set")
+		}
+		if stats.TotalBytes == 0 || stats.FreeBytes > stats.TotalBytes {
+			t.Errorf("Expected free space within the disk size, got %d of %d", stats.FreeBytes, stats.TotalBytes)
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		resp, err := http.Get(base + "/validate?path=" + url.QueryEscape(filepath.Join(storagePath, "new", "dir")))
+		if err != nil {
+			t.Fatalf("Failed to make request to validate endpoint: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var check api.StoragePathResponse
+		if err := json.NewDecoder(resp.Body).Decode(&check); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if !check.Valid || check.FreeBytes == 0 {
+			t.Errorf("Expected a creatable path with free space, got %+v", check)
+		}
+
+		file := filepath.Join(storagePath, "hackernews", "hackernews.sqlite")
+		resp, err = http.Get(base + "/validate?path=" + url.QueryEscape(filepath.Join(file, "sub")))
+		if err != nil {
+			t.Fatalf("Failed to make request to validate endpoint: %v", err)
+		}
+		defer resp.Body.Close()
+
+		check = api.StoragePathResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&check); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if check.Valid || check.Error == "" {
+			t.Errorf("Expected a path below a file to be invalid, got %+v", check)
+		}
+
+		resp, err = http.Get(base + "/validate")
+		if err != nil {
+			t.Fatalf("Failed to make request to validate endpoint: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400 without a path, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,412 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/brainless/PubDataHub/internal/workspace"
+)
+
+// WorkspaceRequest is the body of requests creating or updating a
+// workspace; fields left out of an update keep their value
+type WorkspaceRequest struct {
+	Name        string              `json:"name"`
+	Description *string             `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Settings    *workspace.Settings `json:"settings,omitempty"`
+}
+
+// SetWorkspaces sets the service the workspace endpoints work through, the
+// same one the shell uses
+func (s *Server) SetWorkspaces(service *workspace.Service) {
+	s.workspaces = service
+}
+
+// getWorkspacesHandler handles requests to list workspaces
+func (s *Server) getWorkspacesHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	workspaces, err := s.workspaces.List()
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	writeWorkspaceJSON(w, http.StatusOK, workspaces)
+}
+
+// createWorkspaceHandler handles requests to create a workspace
+func (s *Server) createWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	var req WorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := workspace.ValidateName(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws := workspace.New(req.Name, "")
+	if err := applyWorkspaceRequest(ws, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.workspaces.Import(ws); err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	writeWorkspaceJSON(w, http.StatusCreated, ws)
+}
+
+// getWorkspaceHandler handles requests for one workspace
+func (s *Server) getWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	ws, err := s.workspaces.Get(r.PathValue("name"))
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	writeWorkspaceJSON(w, http.StatusOK, ws)
+}
+
+// updateWorkspaceHandler handles requests to change the description, tags
+// or settings of a workspace
+func (s *Server) updateWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	var req WorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	name := r.PathValue("name")
+	if req.Name != "" && req.Name != name {
+		http.Error(w, "Workspaces cannot be renamed", http.StatusBadRequest)
+		return
+	}
+
+	var invalid error
+	ws, err := s.workspaces.Update(name, func(ws *workspace.Workspace) error {
+		invalid = applyWorkspaceRequest(ws, req)
+		return invalid
+	})
+	if invalid != nil {
+		http.Error(w, invalid.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	writeWorkspaceJSON(w, http.StatusOK, ws)
+}
+
+// deleteWorkspaceHandler handles requests to delete a workspace; it goes to
+// the trash when one is set, so the shell's undo can restore it
+func (s *Server) deleteWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	name := r.PathValue("name")
+	undoID, err := s.workspaces.Delete(name)
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"message":   fmt.Sprintf("Workspace %s deleted", name),
+		"workspace": name,
+	}
+	if undoID != "" {
+		response["undo_id"] = undoID
+	}
+	writeWorkspaceJSON(w, http.StatusOK, response)
+}
+
+// getSavedQueriesHandler handles requests to list the saved queries of a
+// workspace, sorted by name
+func (s *Server) getSavedQueriesHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	ws, err := s.workspaces.Get(r.PathValue("name"))
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+
+	queries := make([]workspace.SavedQuery, 0, len(ws.SavedQueries))
+	for _, saved := range ws.SavedQueries {
+		queries = append(queries, saved)
+	}
+	sort.Slice(queries, func(i, j int) bool { return queries[i].Name < queries[j].Name })
+	writeWorkspaceJSON(w, http.StatusOK, queries)
+}
+
+// getSavedQueryHandler handles requests for one saved query
+func (s *Server) getSavedQueryHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	ws, err := s.workspaces.Get(r.PathValue("name"))
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	saved, ok := ws.SavedQueries[r.PathValue("query")]
+	if !ok {
+		http.Error(w, fmt.Sprintf("query '%s' not found", r.PathValue("query")), http.StatusNotFound)
+		return
+	}
+	writeWorkspaceJSON(w, http.StatusOK, saved)
+}
+
+// createSavedQueryHandler handles requests to add a saved query; a query of
+// the same name is a conflict
+func (s *Server) createSavedQueryHandler(w http.ResponseWriter, r *http.Request) {
+	s.saveQuery(w, r, "")
+}
+
+// putSavedQueryHandler handles requests to add or replace a saved query
+func (s *Server) putSavedQueryHandler(w http.ResponseWriter, r *http.Request) {
+	s.saveQuery(w, r, r.PathValue("query"))
+}
+
+// saveQuery stores the saved query in the request body. With a name from
+// the path the query is added or replaced, otherwise it must be new.
+func (s *Server) saveQuery(w http.ResponseWriter, r *http.Request, name string) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	var saved workspace.SavedQuery
+	if err := json.NewDecoder(r.Body).Decode(&saved); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if name != "" {
+		saved.Name = name
+	}
+
+	wsName := r.PathValue("name")
+	ws, err := s.workspaces.Get(wsName)
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	_, exists := ws.SavedQueries[saved.Name]
+	if name == "" && exists {
+		http.Error(w, fmt.Sprintf("query '%s' already exists", saved.Name), http.StatusConflict)
+		return
+	}
+	if saved.DataSource == "" {
+		saved.DataSource = ws.Settings.DefaultDataSource
+	}
+
+	stored, err := s.workspaces.SaveQuery(wsName, saved)
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if !exists {
+		status = http.StatusCreated
+	}
+	writeWorkspaceJSON(w, status, stored)
+}
+
+// deleteSavedQueryHandler handles requests to delete a saved query
+func (s *Server) deleteSavedQueryHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	if err := s.workspaces.DeleteQuery(r.PathValue("name"), r.PathValue("query")); err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getJobTemplatesHandler handles requests to list the job templates of a
+// workspace, sorted by name
+func (s *Server) getJobTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	ws, err := s.workspaces.Get(r.PathValue("name"))
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+
+	templates := make([]workspace.JobTemplate, 0, len(ws.JobTemplates))
+	for _, template := range ws.JobTemplates {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	writeWorkspaceJSON(w, http.StatusOK, templates)
+}
+
+// getJobTemplateHandler handles requests for one job template
+func (s *Server) getJobTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	ws, err := s.workspaces.Get(r.PathValue("name"))
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	template, ok := ws.JobTemplates[r.PathValue("template")]
+	if !ok {
+		http.Error(w, fmt.Sprintf("job template '%s' not found", r.PathValue("template")), http.StatusNotFound)
+		return
+	}
+	writeWorkspaceJSON(w, http.StatusOK, template)
+}
+
+// createJobTemplateHandler handles requests to add a job template; a
+// template of the same name is a conflict
+func (s *Server) createJobTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	s.saveJobTemplate(w, r, "")
+}
+
+// putJobTemplateHandler handles requests to add or replace a job template
+func (s *Server) putJobTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	s.saveJobTemplate(w, r, r.PathValue("template"))
+}
+
+// saveJobTemplate stores the job template in the request body. With a name
+// from the path the template is added or replaced, otherwise it must be new.
+func (s *Server) saveJobTemplate(w http.ResponseWriter, r *http.Request, name string) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	var template workspace.JobTemplate
+	if err := json.NewDecoder(r.Body).Decode(&template); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if name != "" {
+		template.Name = name
+	}
+
+	wsName := r.PathValue("name")
+	ws, err := s.workspaces.Get(wsName)
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	_, exists := ws.JobTemplates[template.Name]
+	if name == "" && exists {
+		http.Error(w, fmt.Sprintf("job template '%s' already exists", template.Name), http.StatusConflict)
+		return
+	}
+
+	stored, err := s.workspaces.SaveJobTemplate(wsName, template)
+	if err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if !exists {
+		status = http.StatusCreated
+	}
+	writeWorkspaceJSON(w, status, stored)
+}
+
+// deleteJobTemplateHandler handles requests to delete a job template
+func (s *Server) deleteJobTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.workspacesAvailable(w) {
+		return
+	}
+	if err := s.workspaces.DeleteJobTemplate(r.PathValue("name"), r.PathValue("template")); err != nil {
+		writeWorkspaceError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyWorkspaceRequest sets the fields given in a request on a workspace
+func applyWorkspaceRequest(ws *workspace.Workspace, req WorkspaceRequest) error {
+	if req.Settings != nil {
+		if err := req.Settings.Validate(); err != nil {
+			return err
+		}
+		settings := *req.Settings
+		if settings.CustomVariables == nil {
+			settings.CustomVariables = make(map[string]string)
+		}
+		ws.Settings = settings
+	}
+	if req.Description != nil {
+		ws.Description = *req.Description
+	}
+	if req.Tags != nil {
+		ws.Tags = req.Tags
+	}
+	return nil
+}
+
+// workspacesAvailable writes an error when the server has no workspace service
+func (s *Server) workspacesAvailable(w http.ResponseWriter) bool {
+	if s.workspaces == nil {
+		http.Error(w, "Workspaces are not available", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// writeWorkspaceError writes the status matching a workspace service error
+func writeWorkspaceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, workspace.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, workspace.ErrExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// writeWorkspaceJSON writes a JSON response
+func writeWorkspaceJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// registerWorkspaceRoutesOnMux registers the workspace routes on the provided mux
+func (s *Server) registerWorkspaceRoutesOnMux(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/workspaces", s.getWorkspacesHandler)
+	mux.HandleFunc("POST /api/workspaces", s.createWorkspaceHandler)
+	mux.HandleFunc("GET /api/workspaces/{name}", s.getWorkspaceHandler)
+	mux.HandleFunc("PUT /api/workspaces/{name}", s.updateWorkspaceHandler)
+	mux.HandleFunc("DELETE /api/workspaces/{name}", s.deleteWorkspaceHandler)
+
+	mux.HandleFunc("GET /api/workspaces/{name}/queries", s.getSavedQueriesHandler)
+	mux.HandleFunc("POST /api/workspaces/{name}/queries", s.createSavedQueryHandler)
+	mux.HandleFunc("GET /api/workspaces/{name}/queries/{query}", s.getSavedQueryHandler)
+	mux.HandleFunc("PUT /api/workspaces/{name}/queries/{query}", s.putSavedQueryHandler)
+	mux.HandleFunc("DELETE /api/workspaces/{name}/queries/{query}", s.deleteSavedQueryHandler)
+
+	mux.HandleFunc("GET /api/workspaces/{name}/templates", s.getJobTemplatesHandler)
+	mux.HandleFunc("POST /api/workspaces/{name}/templates", s.createJobTemplateHandler)
+	mux.HandleFunc("GET /api/workspaces/{name}/templates/{template}", s.getJobTemplateHandler)
+	mux.HandleFunc("PUT /api/workspaces/{name}/templates/{template}", s.putJobTemplateHandler)
+	mux.HandleFunc("DELETE /api/workspaces/{name}/templates/{template}", s.deleteJobTemplateHandler)
+}
@@ -0,0 +1,176 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/workspace"
+)
+
+func workspaceRequest(t *testing.T, method, url string, body interface{}) *http.Response {
+	t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			t.Fatalf("Failed to encode request: %v", err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &reader)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to make %s request to %s: %v", method, url, err)
+	}
+	return resp
+}
+
+func TestWorkspacesEndpoints(t *testing.T) {
+	// Initialize logger for tests
+	log.InitLogger(false)
+
+	service, err := workspace.NewService(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create workspace service: %v", err)
+	}
+
+	addr := ":8085" // Use a different port to avoid conflicts
+	server := api.NewServer(addr, &mockJobManager{})
+	server.SetWorkspaces(service)
+
+	go func() {
+		if err := server.Start(); err != nil {
+			t.Errorf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Give the server a moment to start
+	time.Sleep(100 * time.Millisecond)
+
+	base := fmt.Sprintf("http://localhost%s/api/workspaces", addr)
+
+	t.Run("Create", func(t *testing.T) {
+		description := "Research queries"
+		resp := workspaceRequest(t, http.MethodPost, base, api.WorkspaceRequest{Name: "research", Description: &description})
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+		}
+
+		resp = workspaceRequest(t, http.MethodPost, base, api.WorkspaceRequest{Name: "research"})
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 for a duplicate workspace, got %d", resp.StatusCode)
+		}
+
+		resp = workspaceRequest(t, http.MethodPost, base, api.WorkspaceRequest{Name: "../escape"})
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for an invalid name, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		settings := workspace.DefaultSettings()
+		settings.OutputFormat = workspace.OutputFormatCSV
+		resp := workspaceRequest(t, http.MethodPut, base+"/research", api.WorkspaceRequest{Settings: &settings})
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+		}
+
+		settings.OutputFormat = "xml"
+		resp = workspaceRequest(t, http.MethodPut, base+"/research", api.WorkspaceRequest{Settings: &settings})
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for invalid settings, got %d", resp.StatusCode)
+		}
+
+		// The change is visible to anything else using the service, such as the shell
+		ws, err := service.Get("research")
+		if err != nil {
+			t.Fatalf("Failed to get workspace: %v", err)
+		}
+		if ws.Settings.OutputFormat != workspace.OutputFormatCSV || ws.Description != "Research queries" {
+			t.Errorf("Expected csv output and the description kept, got %q and %q", ws.Settings.OutputFormat, ws.Description)
+		}
+	})
+
+	t.Run("SavedQueries", func(t *testing.T) {
+		saved := workspace.SavedQuery{Name: "top", Query: "SELECT * FROM items ORDER BY score DESC"}
+		resp := workspaceRequest(t, http.MethodPost, base+"/research/queries", saved)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+		}
+
+		resp = workspaceRequest(t, http.MethodPost, base+"/research/queries", saved)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected status 409 for a duplicate query, got %d", resp.StatusCode)
+		}
+
+		resp = workspaceRequest(t, http.MethodGet, base+"/research/queries", nil)
+		defer resp.Body.Close()
+		var queries []workspace.SavedQuery
+		if err := json.NewDecoder(resp.Body).Decode(&queries); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(queries) != 1 || queries[0].DataSource != "hackernews" {
+			t.Errorf("Expected one query on the default data source, got %+v", queries)
+		}
+
+		resp = workspaceRequest(t, http.MethodDelete, base+"/research/queries/top", nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", resp.StatusCode)
+		}
+
+		resp = workspaceRequest(t, http.MethodGet, base+"/research/queries/top", nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status 404 for a deleted query, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("JobTemplates", func(t *testing.T) {
+		template := workspace.JobTemplate{JobType: "download", Config: map[string]interface{}{"source": "hackernews"}}
+		resp := workspaceRequest(t, http.MethodPut, base+"/research/templates/nightly", template)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d", resp.StatusCode)
+		}
+
+		resp = workspaceRequest(t, http.MethodGet, base+"/research/templates/nightly", nil)
+		defer resp.Body.Close()
+		var stored workspace.JobTemplate
+		if err := json.NewDecoder(resp.Body).Decode(&stored); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if stored.Name != "nightly" || stored.JobType != "download" {
+			t.Errorf("Expected the nightly download template, got %+v", stored)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		resp := workspaceRequest(t, http.MethodDelete, base+"/research", nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+		}
+
+		resp = workspaceRequest(t, http.MethodGet, base+"/research", nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status 404 for a deleted workspace, got %d", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,312 @@
+// Package audit records the queries run through every interface, with the
+// user or API token that ran them, so shared deployments can see who ran
+// what and which queries put load on the storage.
+package audit
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DatabaseFile is the name of the audit database in the storage directory
+const DatabaseFile = "audit.db"
+
+// UserEnv overrides the user recorded for queries run in the shell and the
+// CLI, such as the login of a remote shell session
+const UserEnv = "PUBDATAHUB_AUDIT_USER"
+
+// Interfaces queries are run from
+const (
+	InterfaceCLI   = "cli"
+	InterfaceShell = "tui"
+	InterfaceAPI   = "api"
+	InterfaceGRPC  = "grpc"
+)
+
+// pruneInterval is how often recording removes entries past the retention
+const pruneInterval = time.Hour
+
+// Caller identifies who ran a query: the interface and the local user or
+// the name of the API token
+type Caller struct {
+	Interface string
+	User      string
+}
+
+// Local returns the caller for queries run by the current user through iface
+func Local(iface string) Caller {
+	return Caller{Interface: iface, User: currentUser()}
+}
+
+// currentUser returns the override in UserEnv or the name of the OS user
+func currentUser() string {
+	if name := os.Getenv(UserEnv); name != "" {
+		return name
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Entry is one executed query
+type Entry struct {
+	ID        int64         `json:"id"`
+	StartedAt time.Time     `json:"started_at"`
+	Source    string        `json:"source"`
+	SQL       string        `json:"sql"`
+	Duration  time.Duration `json:"duration"`
+	Rows      int           `json:"rows"`
+	Interface string        `json:"interface"`
+	User      string        `json:"user"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Filter selects audit entries; empty fields match every entry
+type Filter struct {
+	Source    string
+	Interface string
+	User      string
+	Text      string // Part of the SQL, matched case-insensitively
+	Since     time.Time
+	Failed    bool // Only queries that failed
+	Limit     int  // Most entries returned, newest first; 0 returns all
+}
+
+// Log stores executed queries in the query_audit table
+type Log struct {
+	db        *sql.DB
+	retention time.Duration
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+// Open opens the audit database in storagePath. Entries older than
+// retention are removed as new ones are recorded; 0 keeps them all.
+func Open(storagePath string, retention time.Duration) (*Log, error) {
+	dbPath := storage.DatabasePath(storagePath, DatabaseFile)
+
+	if err := storage.PrepareDatabase(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open audit database: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", storage.DatabaseDSN(dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit database: %w", err)
+	}
+
+	if !storage.ReadOnly() {
+		schema := `
+		CREATE TABLE IF NOT EXISTS query_audit (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			started_at DATETIME NOT NULL,
+			source TEXT NOT NULL,
+			sql TEXT NOT NULL,
+			duration_ms INTEGER NOT NULL,
+			rows INTEGER NOT NULL,
+			interface TEXT NOT NULL,
+			user TEXT NOT NULL,
+			error TEXT
+		);
+		CREATE INDEX IF NOT EXISTS idx_query_audit_started_at ON query_audit(started_at);
+		`
+		if _, err := db.Exec(schema); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize audit table: %w", err)
+		}
+	}
+
+	return &Log{db: db, retention: retention}, nil
+}
+
+// Record stores an executed query, removing expired entries at most once
+// an hour
+func (l *Log) Record(entry Entry) error {
+	var errorMessage interface{}
+	if entry.Error != "" {
+		errorMessage = entry.Error
+	}
+	_, err := l.db.Exec(`INSERT INTO query_audit (started_at, source, sql, duration_ms, rows, interface, user, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.StartedAt.UTC(), entry.Source, entry.SQL, entry.Duration.Milliseconds(), entry.Rows,
+		entry.Interface, entry.User, errorMessage)
+	if err != nil {
+		return fmt.Errorf("failed to record query: %w", err)
+	}
+
+	l.mu.Lock()
+	prune := l.retention > 0 && time.Since(l.lastPruned) >= pruneInterval
+	if prune {
+		l.lastPruned = time.Now()
+	}
+	l.mu.Unlock()
+	if prune {
+		if _, err := l.Prune(time.Now().Add(-l.retention)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune removes the entries of queries started before cutoff and returns
+// how many were removed
+func (l *Log) Prune(cutoff time.Time) (int64, error) {
+	result, err := l.db.Exec(`DELETE FROM query_audit WHERE started_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune audit log: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// List returns the entries matching filter, newest first
+func (l *Log) List(filter Filter) ([]Entry, error) {
+	var conditions []string
+	var args []interface{}
+	for _, match := range []struct{ column, value string }{
+		{"source", filter.Source}, {"interface", filter.Interface}, {"user", filter.User},
+	} {
+		if match.value != "" {
+			conditions = append(conditions, match.column+" = ?")
+			args = append(args, match.value)
+		}
+	}
+	if filter.Text != "" {
+		conditions = append(conditions, `sql LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(filter.Text)+"%")
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "started_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Failed {
+		conditions = append(conditions, "error IS NOT NULL")
+	}
+
+	query := `SELECT id, started_at, source, sql, duration_ms, rows, interface, user, COALESCE(error, '') FROM query_audit`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY started_at DESC, id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := l.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		var durationMs int64
+		if err := rows.Scan(&entry.ID, &entry.StartedAt, &entry.Source, &entry.SQL, &durationMs, &entry.Rows,
+			&entry.Interface, &entry.User, &entry.Error); err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		entry.Duration = time.Duration(durationMs) * time.Millisecond
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
+
+// Close closes the audit database
+func (l *Log) Close() error {
+	return l.db.Close()
+}
+
+var (
+	defaultMu        sync.Mutex
+	defaultPath      string
+	defaultRetention time.Duration
+	defaultLog       *Log
+	defaultFailed    bool
+)
+
+// Enable makes Query record into the audit database in storagePath, opened
+// when the first query runs
+func Enable(storagePath string, retention time.Duration) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	closeDefault()
+	defaultPath = storagePath
+	defaultRetention = retention
+}
+
+// Disable stops Query from recording and closes the audit database
+func Disable() {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	closeDefault()
+	defaultPath = ""
+}
+
+// closeDefault closes the open audit database; defaultMu must be held
+func closeDefault() {
+	if defaultLog != nil {
+		defaultLog.Close()
+	}
+	defaultLog = nil
+	defaultFailed = false
+}
+
+// record stores entry in the enabled audit database. Failures are logged,
+// as a query is not refused because it could not be audited; read-only
+// storage records nothing.
+func record(entry Entry) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultPath == "" || defaultFailed || storage.ReadOnly() {
+		return
+	}
+	if defaultLog == nil {
+		auditLog, err := Open(defaultPath, defaultRetention)
+		if err != nil {
+			// Warn once instead of for every query
+			defaultFailed = true
+			log.ForComponent("audit").Warnf("Queries are not audited: %v", err)
+			return
+		}
+		defaultLog = auditLog
+	}
+	if err := defaultLog.Record(entry); err != nil {
+		log.ForComponent("audit").Warnf("Failed to audit query: %v", err)
+	}
+}
+
+// Query runs sql on source with run and records it for caller
+func Query(caller Caller, source, sql string, run func(string) (datasource.QueryResult, error)) (datasource.QueryResult, error) {
+	start := time.Now()
+	result, err := run(sql)
+	entry := Entry{
+		StartedAt: start,
+		Source:    source,
+		SQL:       sql,
+		Duration:  time.Since(start),
+		Rows:      result.Count,
+		Interface: caller.Interface,
+		User:      caller.User,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	record(entry)
+	return result, err
+}
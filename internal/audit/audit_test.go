@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog_RecordAndList(t *testing.T) {
+	auditLog, err := Open(t.TempDir(), 0)
+	require.NoError(t, err)
+	defer auditLog.Close()
+
+	now := time.Now()
+	entries := []Entry{
+		{StartedAt: now.Add(-3 * time.Hour), Source: "hackernews", SQL: "SELECT * FROM items", Rows: 10, Interface: InterfaceCLI, User: "alice"},
+		{StartedAt: now.Add(-2 * time.Hour), Source: "hackernews", SQL: "SELECT * FROM users", Rows: 3, Interface: InterfaceAPI, User: "dashboard"},
+		{StartedAt: now.Add(-time.Hour), Source: "hackernews", SQL: "SELECT 100% FROM items", Interface: InterfaceShell, User: "alice", Error: "syntax error"},
+	}
+	for _, entry := range entries {
+		require.NoError(t, auditLog.Record(entry))
+	}
+
+	all, err := auditLog.List(Filter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "SELECT 100% FROM items", all[0].SQL, "newest first")
+	assert.Equal(t, "syntax error", all[0].Error)
+
+	byUser, err := auditLog.List(Filter{User: "alice", Interface: InterfaceCLI})
+	require.NoError(t, err)
+	require.Len(t, byUser, 1)
+	assert.Equal(t, 10, byUser[0].Rows)
+
+	search, err := auditLog.List(Filter{Text: "from ITEMS"})
+	require.NoError(t, err)
+	assert.Len(t, search, 2)
+
+	// Wildcards in the text match literally
+	search, err = auditLog.List(Filter{Text: "100%"})
+	require.NoError(t, err)
+	assert.Len(t, search, 1)
+
+	failed, err := auditLog.List(Filter{Failed: true})
+	require.NoError(t, err)
+	assert.Len(t, failed, 1)
+
+	recent, err := auditLog.List(Filter{Since: now.Add(-90 * time.Minute), Limit: 5})
+	require.NoError(t, err)
+	assert.Len(t, recent, 1)
+
+	removed, err := auditLog.Prune(now.Add(-150 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+}
+
+func TestQuery_RecordsWhenEnabled(t *testing.T) {
+	log.InitLogger(false)
+	dir := t.TempDir()
+	Enable(dir, 24*time.Hour)
+	t.Cleanup(Disable)
+
+	caller := Caller{Interface: InterfaceGRPC, User: "ci"}
+	result, err := Query(caller, "mock", "SELECT 1", func(sql string) (datasource.QueryResult, error) {
+		return datasource.QueryResult{Count: 1}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Count)
+	_, err = Query(caller, "mock", "SELECT nope", func(sql string) (datasource.QueryResult, error) {
+		return datasource.QueryResult{}, errors.New("no such column: nope")
+	})
+	assert.Error(t, err)
+
+	Disable()
+	_, err = Query(caller, "mock", "SELECT 2", func(sql string) (datasource.QueryResult, error) {
+		return datasource.QueryResult{}, nil
+	})
+	require.NoError(t, err)
+
+	auditLog, err := Open(dir, 0)
+	require.NoError(t, err)
+	defer auditLog.Close()
+	entries, err := auditLog.List(Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "queries after Disable are not recorded")
+	assert.Equal(t, "no such column: nope", entries[0].Error)
+	assert.Equal(t, "ci", entries[1].User)
+	assert.Equal(t, InterfaceGRPC, entries[1].Interface)
+	assert.Equal(t, 1, entries[1].Rows)
+}
+
+func TestLocal_UserOverride(t *testing.T) {
+	t.Setenv(UserEnv, "ssh:alice")
+	assert.Equal(t, Caller{Interface: InterfaceShell, User: "ssh:alice"}, Local(InterfaceShell))
+}
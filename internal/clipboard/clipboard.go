@@ -0,0 +1,62 @@
+// Package clipboard copies text to the system clipboard using the platform's
+// clipboard utility
+package clipboard
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// command is a clipboard utility that reads the text to copy from stdin
+type command struct {
+	name string
+	args []string
+}
+
+// candidates returns the clipboard utilities to try for an operating system,
+// in order of preference
+func candidates(goos string) []command {
+	switch goos {
+	case "darwin":
+		return []command{{name: "pbcopy"}}
+	case "windows":
+		return []command{{name: "clip.exe"}}
+	default:
+		return []command{
+			{name: "wl-copy"},
+			{name: "xclip", args: []string{"-selection", "clipboard"}},
+			{name: "xsel", args: []string{"--clipboard", "--input"}},
+			// WSL can reach the Windows clipboard
+			{name: "clip.exe"},
+		}
+	}
+}
+
+// lookPath is replaced in tests
+var lookPath = exec.LookPath
+
+// Copy puts text on the system clipboard
+func Copy(text string) error {
+	cmds := candidates(runtime.GOOS)
+	for _, c := range cmds {
+		path, err := lookPath(c.name)
+		if err != nil {
+			continue
+		}
+
+		cmd := exec.Command(path, c.args...)
+		cmd.Stdin = strings.NewReader(text)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s failed: %w: %s", c.name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	names := make([]string, len(cmds))
+	for i, c := range cmds {
+		names[i] = c.name
+	}
+	return fmt.Errorf("no clipboard utility found (install one of: %s)", strings.Join(names, ", "))
+}
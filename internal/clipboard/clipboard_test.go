@@ -0,0 +1,51 @@
+package clipboard
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCandidates(t *testing.T) {
+	assert.Equal(t, "pbcopy", candidates("darwin")[0].name)
+	assert.Equal(t, "clip.exe", candidates("windows")[0].name)
+	assert.Equal(t, "wl-copy", candidates("linux")[0].name)
+}
+
+func TestCopy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the clipboard utility")
+	}
+
+	// Stand in for the first clipboard utility with a script that saves stdin
+	dir := t.TempDir()
+	output := filepath.Join(dir, "clipboard.txt")
+	script := filepath.Join(dir, "fake-clipboard")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\ncat > "+output+"\n"), 0755))
+
+	original := lookPath
+	defer func() { lookPath = original }()
+
+	first := candidates(runtime.GOOS)[0].name
+	lookPath = func(name string) (string, error) {
+		if name == first {
+			return script, nil
+		}
+		return "", exec.ErrNotFound
+	}
+
+	require.NoError(t, Copy("a\tb\n1\t2\n"))
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "a\tb\n1\t2\n", string(data))
+
+	lookPath = func(name string) (string, error) { return "", exec.ErrNotFound }
+	err = Copy("text")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no clipboard utility found")
+}
@@ -0,0 +1,246 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/query"
+)
+
+// BookmarkHandler saves the last query result under a name
+type BookmarkHandler struct {
+	*BaseHandler
+}
+
+// NewBookmarkHandler creates a new bookmark handler
+func NewBookmarkHandler() *BookmarkHandler {
+	spec := &CommandSpec{
+		Name:        ".bookmark",
+		Description: "Save the last query result with its query to recall later",
+		Usage:       ".bookmark <name> [--delete]",
+		Category:    "data",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Flags: map[string]FlagSpec{
+			"delete": {Type: "bool", Description: "Delete the bookmark instead of saving one"},
+		},
+		Examples: []string{
+			".bookmark top_stories",
+			".bookmark top_stories --delete",
+		},
+	}
+
+	return &BookmarkHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute saves the last result, replacing a bookmark of the same name, or
+// deletes a bookmark
+func (bh *BookmarkHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	path, err := contextStoragePath(ctx)
+	if err != nil {
+		return err
+	}
+	dir := query.BookmarkDir(path)
+	name := cmd.Args[0]
+
+	if cmd.Flags["delete"] == true {
+		if err := query.DeleteResultSnapshot(dir, name); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("no bookmark named %s", name)
+			}
+			return err
+		}
+		fmt.Printf("Deleted bookmark %s\n", name)
+		return nil
+	}
+
+	var result datasource.QueryResult
+	var ok bool
+	var source queryBuffer
+	if ctx.Session != nil {
+		result, ok = ctx.Session.Variables[lastResultVariable].(datasource.QueryResult)
+		source, _ = ctx.Session.Variables[lastResultQueryVariable].(queryBuffer)
+	}
+	if !ok {
+		return fmt.Errorf("no query result to bookmark, run a query first")
+	}
+
+	if err := query.SaveResultSnapshot(dir, query.NewResultSnapshot(name, source.Source, source.SQL, result)); err != nil {
+		return err
+	}
+	fmt.Printf("Bookmarked %d rows as %s; show them again with .recall %s\n", len(result.Rows), name, name)
+	return nil
+}
+
+// GetArgumentCompletions completes bookmark names
+func (bh *BookmarkHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	return completeBookmarks(ctx, partial)
+}
+
+// BookmarksHandler lists the saved bookmarks
+type BookmarksHandler struct {
+	*BaseHandler
+}
+
+// NewBookmarksHandler creates a new bookmarks handler
+func NewBookmarksHandler() *BookmarksHandler {
+	spec := &CommandSpec{
+		Name:        ".bookmarks",
+		Description: "List bookmarked query results",
+		Usage:       ".bookmarks",
+		Category:    "data",
+		MinArgs:     0,
+		MaxArgs:     0,
+		Examples: []string{
+			".bookmarks",
+		},
+	}
+
+	return &BookmarksHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute prints each bookmark with when it was taken and its query
+func (bh *BookmarksHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	path, err := contextStoragePath(ctx)
+	if err != nil {
+		return err
+	}
+	bookmarks, err := query.ListResultSnapshots(query.BookmarkDir(path))
+	if err != nil {
+		return err
+	}
+	if len(bookmarks) == 0 {
+		fmt.Println("No bookmarks; save the last result with .bookmark <name>")
+		return nil
+	}
+
+	for _, bookmark := range bookmarks {
+		fmt.Printf("%-20s %s  %6d rows  %-12s %s\n", bookmark.Name, bookmark.Taken.Format("2006-01-02 15:04"),
+			len(bookmark.Rows), bookmark.Source, truncateQuery(bookmark.Query, 60))
+	}
+	return nil
+}
+
+// RecallHandler shows or exports a bookmarked result
+type RecallHandler struct {
+	*BaseHandler
+}
+
+// NewRecallHandler creates a new recall handler
+func NewRecallHandler() *RecallHandler {
+	spec := &CommandSpec{
+		Name:        ".recall",
+		Description: "Show or export a bookmarked query result",
+		Usage:       ".recall <name> [--format <format>] [--output <file>]",
+		Category:    "data",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Flags: map[string]FlagSpec{
+			"format": {Type: "string", Short: "f", Description: "Output format (table, csv, tsv, json, sqlite, xlsx); defaults to the workspace setting"},
+			"output": {Type: "string", Short: "o", Description: "Write the result to a file instead of the screen"},
+		},
+		Examples: []string{
+			".recall top_stories",
+			".recall top_stories --output top_stories.csv",
+		},
+	}
+
+	return &RecallHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute displays or saves the bookmark, which becomes the last result so
+// .chart and .materialize can use it
+func (rh *RecallHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	settings := DefaultOutputSettings()
+	if ctx.Shell != nil {
+		settings = ctx.Shell.OutputSettings()
+	}
+	if format, ok := cmd.Flags["format"].(string); ok {
+		parsed, err := parseOutputFormat(format)
+		if err != nil {
+			return err
+		}
+		settings.Format = parsed
+	}
+
+	path, err := contextStoragePath(ctx)
+	if err != nil {
+		return err
+	}
+	bookmark, err := query.LoadResultSnapshot(query.BookmarkDir(path), cmd.Args[0])
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no bookmark named %s (list them with .bookmarks)", cmd.Args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	result := bookmark.Result()
+	if ctx.Session != nil {
+		ctx.Session.Variables[lastResultVariable] = result
+		ctx.Session.Variables[lastResultQueryVariable] = queryBuffer{Source: bookmark.Source, SQL: bookmark.Query}
+	}
+
+	if output, ok := cmd.Flags["output"].(string); ok {
+		output, _, err := saveQueryResult(output, query.SaveOptions{Format: settings.Format}, cmd.Flags["format"] != nil, result)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %d rows to %s\n", len(result.Rows), output)
+		return nil
+	}
+
+	// Delimited and JSON output stay machine readable
+	if settings.Format == query.OutputFormatTable {
+		fmt.Printf("Bookmark %s, taken %s from %s:\n%s\n\n", bookmark.Name, bookmark.Taken.Format("2006-01-02 15:04"),
+			bookmark.Source, bookmark.Query)
+	}
+	settings.ShowTiming = false
+	return writeQueryResult(os.Stdout, result, settings)
+}
+
+// GetArgumentCompletions completes bookmark names
+func (rh *RecallHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	return completeBookmarks(ctx, partial)
+}
+
+// completeBookmarks completes the names of saved bookmarks
+func completeBookmarks(ctx *ExecutionContext, partial string) []string {
+	path, err := contextStoragePath(ctx)
+	if err != nil {
+		return []string{}
+	}
+	bookmarks, err := query.ListResultSnapshots(query.BookmarkDir(path))
+	if err != nil {
+		return []string{}
+	}
+	names := make([]string, len(bookmarks))
+	for i, bookmark := range bookmarks {
+		names[i] = bookmark.Name
+	}
+	return completeFrom(names, partial)
+}
+
+// truncateQuery shortens a query to one line of at most width characters
+func truncateQuery(sql string, width int) string {
+	sql = strings.Join(strings.Fields(sql), " ")
+	if runes := []rune(sql); len(runes) > width {
+		return string(runes[:width-3]) + "..."
+	}
+	return sql
+}
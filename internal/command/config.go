@@ -0,0 +1,309 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/secrets"
+)
+
+// ConfigHandler handles configuration commands
+type ConfigHandler struct {
+	*BaseHandler
+}
+
+// NewConfigHandler creates a new config handler
+func NewConfigHandler() *ConfigHandler {
+	spec := &CommandSpec{
+		Name:        "config",
+		Description: "Manage configuration settings",
+		Usage:       "config <show|set|set-storage|source|history|rollback|secret> [args...]",
+		Category:    "configuration",
+		MinArgs:     1,
+		MaxArgs:     5,
+		Examples: []string{
+			"config show",
+			"config set-storage /path/to/storage",
+			"config set log.levels.jobs debug",
+			"config source show hackernews",
+			"config source set hackernews batch_size 500",
+			"config history",
+			"config rollback 12",
+			"config secret set reddit.client_id",
+			"config secret list",
+		},
+	}
+
+	return &ConfigHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute handles config operations
+func (ch *ConfigHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	args := cmd.Args
+	switch args[0] {
+	case "show":
+		cfg, ok := ctx.Config.(config.Config)
+		if !ok {
+			cfg = config.AppConfig
+		}
+		fmt.Printf("Storage path: %s\n", cfg.StoragePath)
+		for component, level := range log.ComponentLevels() {
+			fmt.Printf("Log level %s: %s\n", component, level)
+		}
+		return nil
+	case "set":
+		if len(args) < 3 {
+			return fmt.Errorf("set requires a key and a value")
+		}
+		key, value := args[1], args[2]
+		if component, ok := strings.CutPrefix(key, "log.levels."); ok {
+			// Apply immediately so the new level takes effect in this session
+			if err := log.SetComponentLevel(component, value); err != nil {
+				return err
+			}
+		}
+		if err := config.Set(key, value, config.OriginShell); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		fmt.Printf("%s set to: %s\n", key, value)
+		return nil
+	case "set-storage":
+		if len(args) < 2 {
+			return fmt.Errorf("set-storage requires a path argument")
+		}
+		if err := config.SetStoragePath(args[1], config.OriginShell); err != nil {
+			return fmt.Errorf("failed to set storage path: %w", err)
+		}
+		fmt.Printf("Storage path set to: %s\n", args[1])
+		if ctx.Shell != nil {
+			ctx.Shell.ReloadDataSources()
+		}
+		return nil
+	case "source":
+		return ch.source(args[1:])
+	case "history":
+		return ch.history()
+	case "rollback":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: config rollback <change>; see config history")
+		}
+		return ch.rollback(ctx, args[1])
+	case "secret":
+		return ch.secret(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown config subcommand: %s", args[0])
+	}
+}
+
+// source shows and sets per-source settings, checked against the ranges of
+// the data source's descriptor
+func (ch *ConfigHandler) source(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: config source <show [source]|set <source> <key> <value>>")
+	}
+
+	switch args[0] {
+	case "show":
+		descriptors := datasource.Descriptors()
+		if len(args) > 1 {
+			descriptor, err := datasource.LookupDescriptor(args[1])
+			if err != nil {
+				return err
+			}
+			descriptors = []datasource.Descriptor{descriptor}
+		}
+		for _, descriptor := range descriptors {
+			fmt.Printf("%s: %s\n", descriptor.Name, descriptor.Description)
+			for _, setting := range descriptor.Settings {
+				value, set := config.SourceSetting(descriptor.Name, setting)
+				origin := "default"
+				if set {
+					origin = "default " + setting.Default
+				}
+				fmt.Printf("  %-14s %-40s (%s; %s)  %s\n", setting.Key, value, origin, setting.Range(), setting.Description)
+			}
+		}
+		return nil
+	case "set":
+		if len(args) != 4 {
+			return fmt.Errorf("usage: config source set <source> <key> <value>")
+		}
+		if err := config.SetSourceSetting(args[1], args[2], args[3], config.OriginShell); err != nil {
+			return err
+		}
+		fmt.Printf("%s %s set to: %s (applies after a restart)\n", args[1], args[2], args[3])
+		return nil
+	default:
+		return fmt.Errorf("unknown config source subcommand: %s", args[0])
+	}
+}
+
+// history lists the recorded config changes, newest first
+func (ch *ConfigHandler) history() error {
+	entries, err := config.History()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("No config changes recorded")
+		return nil
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		fmt.Printf("%d  %s  %s\n", entry.ID, entry.Time.Format("2006-01-02 15:04:05"), entry.Origin)
+		for _, change := range entry.Changes {
+			fmt.Printf("    %s\n", change)
+		}
+	}
+	return nil
+}
+
+// rollback restores the config from before a change and applies the log
+// levels and storage path it restores to the session
+func (ch *ConfigHandler) rollback(ctx *ExecutionContext, id string) error {
+	changeID, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("invalid change %q; use a number from config history", id)
+	}
+	changes, err := config.Rollback(changeID, config.OriginShell)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("The config already matches that point; nothing changed")
+		return nil
+	}
+
+	reload := false
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change)
+		if component, ok := strings.CutPrefix(change.Key, "log.levels."); ok && change.New != "" {
+			if err := log.SetComponentLevel(component, change.New); err != nil {
+				fmt.Printf("  Failed to apply log level of %s: %v\n", component, err)
+			}
+		}
+		if change.Key == "storage_path" {
+			reload = true
+		}
+	}
+	fmt.Printf("Rolled back to the config before change %d\n", changeID)
+	if reload && ctx.Shell != nil {
+		ctx.Shell.ReloadDataSources()
+	}
+	return nil
+}
+
+// secret manages data source credentials. Values are only read from a
+// prompt, so they never end up in the command history.
+func (ch *ConfigHandler) secret(ctx *ExecutionContext, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("secret requires list, set or delete")
+	}
+	store := secrets.Default()
+
+	switch args[0] {
+	case "list":
+		names, err := store.Names()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("No secrets stored")
+			return nil
+		}
+		for _, name := range names {
+			if env := secrets.EnvName(name); os.Getenv(env) != "" {
+				fmt.Printf("%s (overridden by %s)\n", name, env)
+				continue
+			}
+			fmt.Println(name)
+		}
+		return nil
+	case "set":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: config secret set <source>.<key>; the value is prompted for")
+		}
+		if err := secrets.ValidateName(args[1]); err != nil {
+			return err
+		}
+		if ctx.Shell == nil {
+			return fmt.Errorf("secret values are read from a prompt; run 'pubdatahub config secret set %s'", args[1])
+		}
+		value, err := ctx.Shell.ReadSecret(fmt.Sprintf("Value for %s: ", args[1]))
+		if err != nil {
+			return err
+		}
+		if err := store.Set(args[1], strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("failed to set secret: %w", err)
+		}
+		fmt.Printf("Secret %s saved\n", args[1])
+		return nil
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: config secret delete <source>.<key>")
+		}
+		if err := store.Delete(args[1]); err != nil {
+			return fmt.Errorf("failed to delete secret: %w", err)
+		}
+		fmt.Printf("Secret %s deleted\n", args[1])
+		return nil
+	default:
+		return fmt.Errorf("unknown secret subcommand: %s", args[0])
+	}
+}
+
+// GetArgumentCompletions provides config subcommand completions
+func (ch *ConfigHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) == 0 {
+		return completeFrom([]string{"show", "set", "set-storage", "source", "history", "rollback", "secret"}, partial)
+	}
+	if args[0] == "source" {
+		return completeSourceSettings(partial, args[1:])
+	}
+	if args[0] != "secret" {
+		return []string{}
+	}
+	if len(args) == 1 {
+		return completeFrom([]string{"list", "set", "delete"}, partial)
+	}
+	if len(args) == 2 && args[1] == "delete" {
+		if names, err := secrets.Default().Names(); err == nil {
+			return completeFrom(names, partial)
+		}
+	}
+	return []string{}
+}
+
+// completeSourceSettings completes config source subcommands, data sources
+// and setting keys
+func completeSourceSettings(partial string, args []string) []string {
+	if len(args) == 0 {
+		return completeFrom([]string{"show", "set"}, partial)
+	}
+	if len(args) == 1 {
+		var names []string
+		for _, descriptor := range datasource.Descriptors() {
+			names = append(names, descriptor.Name)
+		}
+		return completeFrom(names, partial)
+	}
+	if len(args) == 2 && args[0] == "set" {
+		descriptor, err := datasource.LookupDescriptor(args[1])
+		if err != nil {
+			return []string{}
+		}
+		var keys []string
+		for _, setting := range descriptor.Settings {
+			keys = append(keys, setting.Key)
+		}
+		return completeFrom(keys, partial)
+	}
+	return []string{}
+}
@@ -0,0 +1,61 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/secrets"
+)
+
+// secretTestShell answers secret prompts with a fixed value
+type secretTestShell struct {
+	ShellServices
+	value   string
+	prompts []string
+}
+
+func (s *secretTestShell) ReadSecret(prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	return s.value, nil
+}
+
+func TestConfigSecret(t *testing.T) {
+	defer secrets.SetDefault(secrets.Default())
+	store := secrets.New(t.TempDir())
+	secrets.SetDefault(store)
+
+	integration := NewShellIntegration()
+	if err := integration.GetRegistry().Register(NewConfigHandler()); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	shell := &secretTestShell{value: "abc123\n"}
+	ctx := context.Background()
+
+	if err := integration.ProcessCommand(ctx, "config secret set reddit.client_id", nil, nil, nil, shell); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if len(shell.prompts) != 1 || !strings.Contains(shell.prompts[0], "reddit.client_id") {
+		t.Errorf("prompts = %q, want one prompt for reddit.client_id", shell.prompts)
+	}
+	value, err := store.Get("reddit.client_id")
+	if err != nil || value != "abc123" {
+		t.Errorf("Get() = %q, %v, want abc123", value, err)
+	}
+
+	// Values on the command line would end up in the history
+	if err := integration.ProcessCommand(ctx, "config secret set reddit.client_id abc123", nil, nil, nil, shell); err == nil {
+		t.Error("ProcessCommand() accepted a value on the command line")
+	}
+	if err := integration.ProcessCommand(ctx, "config secret set reddit", nil, nil, nil, shell); err == nil {
+		t.Error("ProcessCommand() accepted a name without a key")
+	}
+
+	if err := integration.ProcessCommand(ctx, "config secret delete reddit.client_id", nil, nil, nil, shell); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if _, err := store.Get("reddit.client_id"); !errors.Is(err, secrets.ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+}
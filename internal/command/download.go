@@ -0,0 +1,124 @@
+package command
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/jobs"
+)
+
+// DownloadHandler handles download commands
+type DownloadHandler struct {
+	*BaseHandler
+}
+
+// NewDownloadHandler creates a new download handler
+func NewDownloadHandler() *DownloadHandler {
+	spec := &CommandSpec{
+		Name:        "download",
+		Description: "Start background download for a data source",
+		Usage:       "download <source> [flags...]",
+		Category:    "data",
+		MinArgs:     1,
+		MaxArgs:     2, // download <source> <batch size> is kept for old scripts
+		Flags: map[string]FlagSpec{
+			"batch-size": {Type: "int", Description: "Items per batch", Default: defaultDownloadBatchSize},
+			"priority":   {Type: "int", Short: "p", Description: "Download priority (1-10)", Default: int(jobs.PriorityNormal)},
+			"resume":     {Type: "bool", Short: "r", Description: "Resume an interrupted download (downloads always resume)"},
+			"timeout":    {Type: "string", Description: "Fail the download if it runs longer (e.g. 90m, 1d; 0 for none) instead of the configured timeout"},
+		},
+		Examples: []string{
+			"download hackernews",
+			"download hackernews --batch-size 50",
+			"download hackernews --priority 10",
+			"download hackernews --timeout 6h",
+		},
+	}
+
+	return &DownloadHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute submits and starts a download job, then follows its progress in
+// the shell
+func (dh *DownloadHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	jm, err := requireJobManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	sourceName := cmd.Args[0]
+	ds, err := contextDataSource(ctx, sourceName)
+	if err != nil {
+		return err
+	}
+
+	batchSize, err := downloadBatchSize(cmd)
+	if err != nil {
+		return err
+	}
+	priority, _ := cmd.Flags["priority"].(int)
+	if priority < int(jobs.PriorityLow) || priority > int(jobs.PriorityHigh) {
+		return fmt.Errorf("--priority must be from %d to %d", jobs.PriorityLow, jobs.PriorityHigh)
+	}
+
+	job := jobs.NewDownloadJob(fmt.Sprintf("download-%s-%d", sourceName, time.Now().Unix()), sourceName, ds, batchSize)
+	job.SetPriority(jobs.JobPriority(priority))
+	if value, _ := cmd.Flags["timeout"].(string); value != "" {
+		timeout, err := jobs.ParseAge(value)
+		if err != nil {
+			return fmt.Errorf("invalid --timeout: %w", err)
+		}
+		jobs.SetJobTimeout(job.Metadata(), timeout)
+	}
+
+	jobID, err := jm.SubmitJob(job)
+	if err != nil {
+		return fmt.Errorf("failed to start download job: %w", err)
+	}
+	if err := jm.StartJob(jobID); err != nil {
+		return fmt.Errorf("failed to start job: %w", err)
+	}
+
+	fmt.Printf("Started download job %s for %s\n", jobID, sourceName)
+	if ctx.Shell != nil {
+		ctx.Shell.FollowDownload(jobID, sourceName)
+	}
+	return nil
+}
+
+// defaultDownloadBatchSize is the default of --batch-size
+const defaultDownloadBatchSize = 100
+
+// downloadBatchSize returns the batch size of a download: --batch-size, or
+// the deprecated positional form download <source> <batch size>
+func downloadBatchSize(cmd *Command) (int, error) {
+	batchSize, _ := cmd.Flags["batch-size"].(int)
+	if len(cmd.Args) > 1 {
+		positional, err := strconv.Atoi(cmd.Args[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid batch size %q; use --batch-size <n>", cmd.Args[1])
+		}
+		// The flag holds its default unless it was given too
+		if batchSize != defaultDownloadBatchSize && batchSize != positional {
+			return 0, fmt.Errorf("batch size given as both %d and --batch-size %d", positional, batchSize)
+		}
+		fmt.Printf("Note: 'download %s %d' is deprecated, use 'download %s --batch-size %d'\n",
+			cmd.Args[0], positional, cmd.Args[0], positional)
+		batchSize = positional
+	}
+	if batchSize < 1 {
+		return 0, fmt.Errorf("--batch-size must be at least 1")
+	}
+	return batchSize, nil
+}
+
+// GetArgumentCompletions provides data source completions
+func (dh *DownloadHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) == 0 {
+		return completeDataSources(ctx, partial)
+	}
+	return []string{}
+}
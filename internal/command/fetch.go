@@ -0,0 +1,87 @@
+package command
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// FetchHandler handles downloads of large files such as data dumps
+type FetchHandler struct {
+	*BaseHandler
+}
+
+// NewFetchHandler creates a new fetch handler
+func NewFetchHandler() *FetchHandler {
+	spec := &CommandSpec{
+		Name:        "fetch",
+		Description: "Download a large file in parallel segments as a background job",
+		Usage:       "fetch <url> [flags...]",
+		Category:    "data",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Flags: map[string]FlagSpec{
+			"output":   {Type: "string", Short: "o", Description: "Destination file (default: downloads/<name> in the storage directory)"},
+			"sha256":   {Type: "string", Description: "Expected SHA-256 checksum of the file"},
+			"segments": {Type: "int", Description: "Parallel range requests", Default: 4},
+		},
+		Examples: []string{
+			"fetch https://example.com/hn-items.jsonl.gz",
+			"fetch https://example.com/hn-items.jsonl.gz --sha256 9f86d08... --segments 8",
+		},
+	}
+
+	return &FetchHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute submits and starts a fetch job
+func (fh *FetchHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	jm, err := requireJobManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	source := cmd.Args[0]
+	parsed, err := url.Parse(source)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("fetch requires an http or https URL")
+	}
+
+	segments, _ := cmd.Flags["segments"].(int)
+	if segments < 1 {
+		return fmt.Errorf("--segments must be at least 1")
+	}
+
+	output, _ := cmd.Flags["output"].(string)
+	if output == "" {
+		name := path.Base(parsed.Path)
+		if name == "." || name == "/" {
+			return fmt.Errorf("cannot name the file from the URL; use --output")
+		}
+		storagePath, err := contextStoragePath(ctx)
+		if err != nil {
+			return err
+		}
+		output = filepath.Join(storagePath, "downloads", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	sha256, _ := cmd.Flags["sha256"].(string)
+	jobID, err := jm.FetchFile(source, output, sha256, segments)
+	if err != nil {
+		return err
+	}
+	if err := jm.StartJob(jobID); err != nil {
+		return fmt.Errorf("failed to start job: %w", err)
+	}
+
+	fmt.Printf("Started fetch job %s, saving to %s\n", jobID, output)
+	fmt.Println("Use 'jobs pause' and 'jobs resume' to stop and continue it")
+	return nil
+}
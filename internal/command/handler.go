@@ -2,11 +2,18 @@ package command
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/query"
 )
 
+// ErrExit is returned by the exit command to ask the shell to quit
+var ErrExit = errors.New("exit")
+
 // ExecutionContext provides context for command execution
 type ExecutionContext struct {
 	Context     context.Context
@@ -15,9 +22,35 @@ type ExecutionContext struct {
 	DataSources map[string]interface{}
 	Config      interface{}
 	Parser      *Parser
+	Shell       ShellServices // nil when commands run outside the interactive shell
+	Spec        *CommandSpec  // Spec of the executing command
+	DryRun      bool          // Report what the command would do without doing it
 	StartTime   time.Time
 }
 
+// ShellServices are interactive shell features that commands use when they
+// run inside the shell
+type ShellServices interface {
+	// FollowDownload reports the progress of a started download job
+	FollowDownload(jobID, sourceName string)
+	// RunJobsTop runs the live jobs view, or draws it once
+	RunJobsTop(once bool) error
+	// BrowseSchema runs the schema browser of a data source
+	BrowseSchema(sourceName string) error
+	// BrowseResult pages through a query result in a full-screen view
+	BrowseResult(title string, result datasource.QueryResult, values query.ValueFormat) error
+	// EditQuery opens a query in the external editor and returns the saved text
+	EditQuery(sql string) (string, error)
+	// OutputSettings returns how query results are displayed
+	OutputSettings() OutputSettings
+	// ReloadDataSources reopens data sources after the storage path changes
+	ReloadDataSources()
+	// Confirm asks the user a yes/no question
+	Confirm(prompt string) (bool, error)
+	// ReadSecret reads a line without echoing it or adding it to the history
+	ReadSecret(prompt string) (string, error)
+}
+
 // Session represents a user session
 type Session struct {
 	ID        string
@@ -39,6 +72,7 @@ type HandlerRegistry struct {
 	handlers   map[string]Handler
 	categories map[string][]string
 	parser     *Parser
+	middleware []Middleware
 }
 
 // NewHandlerRegistry creates a new handler registry
@@ -82,6 +116,11 @@ func (hr *HandlerRegistry) Register(handler Handler) error {
 	return nil
 }
 
+// Use adds middleware that wraps every command; the first added runs outermost
+func (hr *HandlerRegistry) Use(middleware ...Middleware) {
+	hr.middleware = append(hr.middleware, middleware...)
+}
+
 // Execute executes a command
 func (hr *HandlerRegistry) Execute(ctx *ExecutionContext, input string) error {
 	cmd, err := hr.parser.Parse(input)
@@ -99,9 +138,10 @@ func (hr *HandlerRegistry) Execute(ctx *ExecutionContext, input string) error {
 		return fmt.Errorf("permission denied: %w", err)
 	}
 
-	// Execute command
+	// Execute command through the middleware chain
 	ctx.StartTime = time.Now()
-	return handler.Execute(ctx, cmd)
+	ctx.Spec = handler.GetSpec()
+	return chain(hr.middleware, handler.Execute)(ctx, cmd)
 }
 
 // GetCompletions returns command and argument completions
@@ -322,7 +362,7 @@ func NewExitHandler() *ExitHandler {
 
 // Execute triggers application exit
 func (eh *ExitHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
-	return fmt.Errorf("exit")
+	return ErrExit
 }
 
 // SuggestionEngine provides command suggestions for typos
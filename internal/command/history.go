@@ -0,0 +1,108 @@
+package command
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/storage"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// HistoryDatabaseFile is the name of the command history database in the
+// storage directory
+const HistoryDatabaseFile = "command_history.db"
+
+// HistoryEntry is one executed command
+type HistoryEntry struct {
+	ID        int64         `json:"id"`
+	SessionID string        `json:"session_id"`
+	Command   string        `json:"command"`
+	Input     string        `json:"input"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	DryRun    bool          `json:"dry_run"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// CommandHistory stores executed commands for auditing
+type CommandHistory struct {
+	db *sql.DB
+}
+
+// NewCommandHistory opens the command history database in storagePath
+func NewCommandHistory(storagePath string) (*CommandHistory, error) {
+	dbPath := storage.DatabasePath(storagePath, HistoryDatabaseFile)
+
+	if err := storage.PrepareDatabase(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open command history database: %w", err)
+	}
+
+	db, err := sql.Open("sqlite3", storage.DatabaseDSN(dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open command history database: %w", err)
+	}
+
+	schema := `
+	CREATE TABLE IF NOT EXISTS command_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id TEXT NOT NULL,
+		command TEXT NOT NULL,
+		input TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		dry_run BOOLEAN NOT NULL DEFAULT 0,
+		error TEXT
+	);
+	CREATE INDEX IF NOT EXISTS idx_command_history_started_at ON command_history(started_at);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize command history table: %w", err)
+	}
+
+	return &CommandHistory{db: db}, nil
+}
+
+// Record stores an executed command
+func (h *CommandHistory) Record(entry HistoryEntry) error {
+	var errorMessage interface{}
+	if entry.Error != "" {
+		errorMessage = entry.Error
+	}
+	_, err := h.db.Exec(`INSERT INTO command_history (session_id, command, input, started_at, duration_ms, dry_run, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.SessionID, entry.Command, entry.Input, entry.StartedAt.UTC(), entry.Duration.Milliseconds(), entry.DryRun, errorMessage)
+	if err != nil {
+		return fmt.Errorf("failed to record command: %w", err)
+	}
+	return nil
+}
+
+// Recent returns up to limit commands, newest first
+func (h *CommandHistory) Recent(limit int) ([]HistoryEntry, error) {
+	rows, err := h.db.Query(`SELECT id, session_id, command, input, started_at, duration_ms, dry_run, COALESCE(error, '')
+		FROM command_history ORDER BY started_at DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query command history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var entry HistoryEntry
+		var durationMs int64
+		if err := rows.Scan(&entry.ID, &entry.SessionID, &entry.Command, &entry.Input, &entry.StartedAt,
+			&durationMs, &entry.DryRun, &entry.Error); err != nil {
+			return nil, fmt.Errorf("failed to read command history: %w", err)
+		}
+		entry.Duration = time.Duration(durationMs) * time.Millisecond
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Close closes the history database
+func (h *CommandHistory) Close() error {
+	return h.db.Close()
+}
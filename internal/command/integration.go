@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/plugin"
 )
 
 // ShellIntegration provides integration between the command system and shell
@@ -33,10 +34,32 @@ func NewShellIntegration() *ShellIntegration {
 
 	// Register built-in commands
 	integration.registerBuiltinCommands()
+	integration.registerGlobalFlags()
 
 	return integration
 }
 
+// registerGlobalFlags registers --dry-run and --yes along with the middleware
+// that honours them, so they can never be silently ignored
+func (si *ShellIntegration) registerGlobalFlags() {
+	flags := map[string]FlagSpec{
+		DryRunFlag: {Type: "bool", Description: "Show what the command would do without doing it"},
+		YesFlag:    {Type: "bool", Short: "y", Description: "Run destructive commands without asking for confirmation"},
+	}
+	for name, flag := range flags {
+		if err := si.registry.parser.RegisterGlobalFlag(name, flag); err != nil {
+			fmt.Printf("Warning: failed to register global flag: %v\n", err)
+		}
+	}
+	si.registry.Use(DryRunMiddleware(), ConfirmMiddleware())
+}
+
+// Use adds middleware to every command; it runs inside the built-in dry-run
+// and confirmation middleware
+func (si *ShellIntegration) Use(middleware ...Middleware) {
+	si.registry.Use(middleware...)
+}
+
 // registerBuiltinCommands registers the built-in system commands
 func (si *ShellIntegration) registerBuiltinCommands() {
 	// Register help command
@@ -66,6 +89,12 @@ func (si *ShellIntegration) RegisterApplicationCommands() error {
 		return fmt.Errorf("failed to register download command: %w", err)
 	}
 
+	// Fetch command
+	fetchHandler := NewFetchHandler()
+	if err := si.registry.Register(fetchHandler); err != nil {
+		return fmt.Errorf("failed to register fetch command: %w", err)
+	}
+
 	// Query command
 	queryHandler := NewQueryHandler()
 	if err := si.registry.Register(queryHandler); err != nil {
@@ -78,23 +107,124 @@ func (si *ShellIntegration) RegisterApplicationCommands() error {
 		return fmt.Errorf("failed to register jobs command: %w", err)
 	}
 
+	// Metrics command
+	metricsHandler := NewMetricsHandler()
+	if err := si.registry.Register(metricsHandler); err != nil {
+		return fmt.Errorf("failed to register metrics command: %w", err)
+	}
+
 	// Sources command
 	sourcesHandler := NewSourcesHandler()
 	if err := si.registry.Register(sourcesHandler); err != nil {
 		return fmt.Errorf("failed to register sources command: %w", err)
 	}
 
+	// Chart command
+	chartHandler := NewChartHandler()
+	if err := si.registry.Register(chartHandler); err != nil {
+		return fmt.Errorf("failed to register .chart command: %w", err)
+	}
+
+	// Export command
+	exportHandler := NewExportHandler()
+	if err := si.registry.Register(exportHandler); err != nil {
+		return fmt.Errorf("failed to register .export command: %w", err)
+	}
+
+	// Results command
+	resultsHandler := NewResultsHandler()
+	if err := si.registry.Register(resultsHandler); err != nil {
+		return fmt.Errorf("failed to register .results command: %w", err)
+	}
+
+	// Browse command
+	browseHandler := NewBrowseHandler()
+	if err := si.registry.Register(browseHandler); err != nil {
+		return fmt.Errorf("failed to register .browse command: %w", err)
+	}
+
+	// Tables command
+	tablesHandler := NewTablesHandler()
+	if err := si.registry.Register(tablesHandler); err != nil {
+		return fmt.Errorf("failed to register .tables command: %w", err)
+	}
+
+	// Materialize command
+	materializeHandler := NewMaterializeHandler()
+	if err := si.registry.Register(materializeHandler); err != nil {
+		return fmt.Errorf("failed to register .materialize command: %w", err)
+	}
+
+	// Scratch command
+	scratchHandler := NewScratchHandler()
+	if err := si.registry.Register(scratchHandler); err != nil {
+		return fmt.Errorf("failed to register .scratch command: %w", err)
+	}
+
+	// Bookmark commands
+	bookmarkHandler := NewBookmarkHandler()
+	if err := si.registry.Register(bookmarkHandler); err != nil {
+		return fmt.Errorf("failed to register .bookmark command: %w", err)
+	}
+	bookmarksHandler := NewBookmarksHandler()
+	if err := si.registry.Register(bookmarksHandler); err != nil {
+		return fmt.Errorf("failed to register .bookmarks command: %w", err)
+	}
+	recallHandler := NewRecallHandler()
+	if err := si.registry.Register(recallHandler); err != nil {
+		return fmt.Errorf("failed to register .recall command: %w", err)
+	}
+
+	// Use command
+	useHandler := NewUseHandler()
+	if err := si.registry.Register(useHandler); err != nil {
+		return fmt.Errorf("failed to register use command: %w", err)
+	}
+
+	// Edit command
+	editHandler := NewEditHandler()
+	if err := si.registry.Register(editHandler); err != nil {
+		return fmt.Errorf("failed to register .edit command: %w", err)
+	}
+
 	// Status command
 	statusHandler := NewStatusHandler()
 	if err := si.registry.Register(statusHandler); err != nil {
 		return fmt.Errorf("failed to register status command: %w", err)
 	}
 
+	// Thread command
+	threadHandler := NewThreadHandler()
+	if err := si.registry.Register(threadHandler); err != nil {
+		return fmt.Errorf("failed to register thread command: %w", err)
+	}
+
+	// Report command
+	reportHandler := NewReportHandler()
+	if err := si.registry.Register(reportHandler); err != nil {
+		return fmt.Errorf("failed to register report command: %w", err)
+	}
+
+	scheduleHandler := NewScheduleHandler()
+	if err := si.registry.Register(scheduleHandler); err != nil {
+		return fmt.Errorf("failed to register schedule command: %w", err)
+	}
+
+	return nil
+}
+
+// RegisterPlugin makes an external plugin a shell command. It fails when a
+// command of that name exists, so plugins cannot replace built-in commands.
+func (si *ShellIntegration) RegisterPlugin(p plugin.Plugin) error {
+	if err := si.registry.Register(NewPluginHandler(p)); err != nil {
+		return fmt.Errorf("failed to register plugin %s: %w", p.Name, err)
+	}
 	return nil
 }
 
-// ProcessCommand processes a command input with enhanced error handling
-func (si *ShellIntegration) ProcessCommand(ctx context.Context, input string, jobManager interface{}, dataSources map[string]datasource.DataSource, config interface{}) error {
+// ProcessCommand processes a command input with enhanced error handling;
+// shell provides the interactive shell features commands use, or nil
+func (si *ShellIntegration) ProcessCommand(ctx context.Context, input string, jobManager interface{}, dataSources map[string]datasource.DataSource, config interface{}, shell ShellServices) error {
 	// Add to history
 	si.session.History = append(si.session.History, input)
 
@@ -106,6 +236,7 @@ func (si *ShellIntegration) ProcessCommand(ctx context.Context, input string, jo
 		DataSources: convertDataSources(dataSources),
 		Config:      config,
 		Parser:      si.registry.parser,
+		Shell:       shell,
 	}
 
 	// Try to execute command
@@ -169,259 +300,3 @@ func (si *ShellIntegration) ListCommands() map[string][]string {
 func (si *ShellIntegration) GetCommandHelp(commandName string) (string, error) {
 	return si.registry.parser.GetCommandHelp(commandName)
 }
-
-// Application command handlers
-
-// ConfigHandler handles configuration commands
-type ConfigHandler struct {
-	*BaseHandler
-}
-
-// NewConfigHandler creates a new config handler
-func NewConfigHandler() *ConfigHandler {
-	spec := &CommandSpec{
-		Name:        "config",
-		Description: "Manage configuration settings",
-		Usage:       "config <subcommand> [args...]",
-		Category:    "configuration",
-		MinArgs:     1,
-		MaxArgs:     -1,
-		Flags: map[string]FlagSpec{
-			"verbose": {Type: "bool", Short: "v", Description: "Verbose output"},
-		},
-		Examples: []string{
-			"config show",
-			"config set-storage /path/to/storage",
-			"config validate",
-		},
-	}
-
-	return &ConfigHandler{
-		BaseHandler: NewBaseHandler(spec),
-	}
-}
-
-// Execute handles config operations
-func (ch *ConfigHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
-	if len(cmd.Args) == 0 {
-		return fmt.Errorf("config command requires a subcommand (show, set-storage)")
-	}
-
-	// For now, delegate to existing shell handler
-	// This would be replaced with actual implementation
-	return fmt.Errorf("config command not fully implemented yet - use existing shell commands")
-}
-
-// GetArgumentCompletions provides config subcommand completions
-func (ch *ConfigHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
-	if len(args) == 0 {
-		subcommands := []string{"show", "set-storage", "validate", "reset"}
-		var completions []string
-		for _, cmd := range subcommands {
-			if strings.HasPrefix(cmd, partial) {
-				completions = append(completions, cmd)
-			}
-		}
-		return completions
-	}
-	return []string{}
-}
-
-// DownloadHandler handles download commands
-type DownloadHandler struct {
-	*BaseHandler
-}
-
-// NewDownloadHandler creates a new download handler
-func NewDownloadHandler() *DownloadHandler {
-	spec := &CommandSpec{
-		Name:        "download",
-		Description: "Start background download for a data source",
-		Usage:       "download <source> [flags...]",
-		Category:    "data",
-		MinArgs:     1,
-		MaxArgs:     1,
-		Flags: map[string]FlagSpec{
-			"batch-size": {Type: "int", Description: "Items per batch", Default: 100},
-			"count":      {Type: "int", Short: "c", Description: "Number of items to download"},
-			"resume":     {Type: "bool", Short: "r", Description: "Resume interrupted download"},
-			"priority":   {Type: "int", Short: "p", Description: "Download priority (1-10)", Default: 5},
-		},
-		Examples: []string{
-			"download hackernews",
-			"download hackernews --count 1000",
-			"download hackernews --batch-size 50 --resume",
-		},
-	}
-
-	return &DownloadHandler{
-		BaseHandler: NewBaseHandler(spec),
-	}
-}
-
-// Execute handles download operations
-func (dh *DownloadHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
-	return fmt.Errorf("download command not fully implemented yet - use existing shell commands")
-}
-
-// GetArgumentCompletions provides data source completions
-func (dh *DownloadHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
-	if len(args) == 0 {
-		var completions []string
-		for name := range ctx.DataSources {
-			if strings.HasPrefix(name, partial) {
-				completions = append(completions, name)
-			}
-		}
-		return completions
-	}
-	return []string{}
-}
-
-// QueryHandler handles query commands
-type QueryHandler struct {
-	*BaseHandler
-}
-
-// NewQueryHandler creates a new query handler
-func NewQueryHandler() *QueryHandler {
-	spec := &CommandSpec{
-		Name:        "query",
-		Description: "Execute SQL query against a data source",
-		Usage:       "query <source> <sql>",
-		Category:    "data",
-		MinArgs:     2,
-		MaxArgs:     -1,
-		Flags: map[string]FlagSpec{
-			"format": {Type: "string", Short: "f", Description: "Output format (table, csv, json)", Default: "table"},
-			"limit":  {Type: "int", Short: "l", Description: "Limit number of results"},
-			"output": {Type: "string", Short: "o", Description: "Output file path"},
-		},
-		Examples: []string{
-			"query hackernews \"SELECT title FROM items LIMIT 10\"",
-			"query hackernews \"SELECT * FROM items WHERE score > 100\" --format csv",
-		},
-	}
-
-	return &QueryHandler{
-		BaseHandler: NewBaseHandler(spec),
-	}
-}
-
-// Execute handles query operations
-func (qh *QueryHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
-	return fmt.Errorf("query command not fully implemented yet - use existing shell commands")
-}
-
-// GetArgumentCompletions provides data source completions
-func (qh *QueryHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
-	if len(args) == 0 {
-		var completions []string
-		for name := range ctx.DataSources {
-			if strings.HasPrefix(name, partial) {
-				completions = append(completions, name)
-			}
-		}
-		return completions
-	}
-	return []string{}
-}
-
-// JobsHandler handles job management commands
-type JobsHandler struct {
-	*BaseHandler
-}
-
-// NewJobsHandler creates a new jobs handler
-func NewJobsHandler() *JobsHandler {
-	spec := &CommandSpec{
-		Name:        "jobs",
-		Description: "Manage background jobs",
-		Usage:       "jobs [subcommand] [args...]",
-		Category:    "system",
-		MinArgs:     0,
-		MaxArgs:     -1,
-		Examples: []string{
-			"jobs",
-			"jobs list",
-			"jobs status job_123",
-			"jobs pause job_123",
-			"jobs resume job_123",
-			"jobs stop job_123",
-		},
-	}
-
-	return &JobsHandler{
-		BaseHandler: NewBaseHandler(spec),
-	}
-}
-
-// Execute handles job operations
-func (jh *JobsHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
-	return fmt.Errorf("jobs command not fully implemented yet - use existing shell commands")
-}
-
-// SourcesHandler handles data source management
-type SourcesHandler struct {
-	*BaseHandler
-}
-
-// NewSourcesHandler creates a new sources handler
-func NewSourcesHandler() *SourcesHandler {
-	spec := &CommandSpec{
-		Name:        "sources",
-		Description: "Manage data sources",
-		Usage:       "sources <subcommand> [args...]",
-		Category:    "data",
-		MinArgs:     1,
-		MaxArgs:     -1,
-		Examples: []string{
-			"sources list",
-			"sources status hackernews",
-		},
-	}
-
-	return &SourcesHandler{
-		BaseHandler: NewBaseHandler(spec),
-	}
-}
-
-// Execute handles sources operations
-func (sh *SourcesHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
-	return fmt.Errorf("sources command not fully implemented yet - use existing shell commands")
-}
-
-// StatusHandler handles system status commands
-type StatusHandler struct {
-	*BaseHandler
-}
-
-// NewStatusHandler creates a new status handler
-func NewStatusHandler() *StatusHandler {
-	spec := &CommandSpec{
-		Name:        "status",
-		Description: "Show system status",
-		Usage:       "status [component]",
-		Category:    "system",
-		MinArgs:     0,
-		MaxArgs:     1,
-		Flags: map[string]FlagSpec{
-			"verbose": {Type: "bool", Short: "v", Description: "Show detailed status"},
-			"refresh": {Type: "int", Short: "r", Description: "Auto-refresh interval in seconds"},
-		},
-		Examples: []string{
-			"status",
-			"status --verbose",
-			"status jobs",
-		},
-	}
-
-	return &StatusHandler{
-		BaseHandler: NewBaseHandler(spec),
-	}
-}
-
-// Execute handles status operations
-func (sh *StatusHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
-	return fmt.Errorf("status command not fully implemented yet - use existing shell commands")
-}
@@ -0,0 +1,799 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/plugin"
+	"github.com/brainless/PubDataHub/internal/query"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// queryTestSource is a data source returning a fixed query result
+type queryTestSource struct {
+	statusTestSource
+	result  datasource.QueryResult
+	queries []string
+}
+
+func (s *queryTestSource) Query(sql string) (datasource.QueryResult, error) {
+	s.queries = append(s.queries, sql)
+	return s.result, nil
+}
+
+func newApplicationParser(t *testing.T) *Parser {
+	t.Helper()
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	return integration.GetRegistry().parser
+}
+
+func TestApplicationCommands_Flags(t *testing.T) {
+	parser := newApplicationParser(t)
+
+	tests := []struct {
+		name      string
+		input     string
+		wantArgs  []string
+		wantFlags map[string]interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "query with format, limit and copy",
+			input:     `query hackernews "SELECT title FROM items WHERE type='story'" --format csv --limit 5 --copy`,
+			wantArgs:  []string{"hackernews", "SELECT title FROM items WHERE type='story'"},
+			wantFlags: map[string]interface{}{"format": "csv", "limit": 5, "copy": true},
+		},
+		{
+			name:      "query with chart and short output flag",
+			input:     `query hackernews "SELECT day, n FROM t" --chart bar:x=day,y=n -o out.json`,
+			wantArgs:  []string{"hackernews", "SELECT day, n FROM t"},
+			wantFlags: map[string]interface{}{"chart": "bar:x=day,y=n", "output": "out.json"},
+		},
+		{
+			name:      "jobs cancel with filters",
+			input:     "jobs cancel --source hackernews --state queued,running",
+			wantArgs:  []string{"cancel"},
+			wantFlags: map[string]interface{}{"source": "hackernews", "state": "queued,running"},
+		},
+		{
+			name:      "jobs retry with inline since",
+			input:     "jobs retry --failed --since=1h",
+			wantArgs:  []string{"retry"},
+			wantFlags: map[string]interface{}{"failed": true, "since": "1h"},
+		},
+		{
+			name:      "jobs history summary",
+			input:     "jobs history --all --since 24h",
+			wantArgs:  []string{"history"},
+			wantFlags: map[string]interface{}{"all": true, "since": "24h"},
+		},
+		{
+			name:      "jobs top once",
+			input:     "jobs top --once",
+			wantArgs:  []string{"top"},
+			wantFlags: map[string]interface{}{"once": true},
+		},
+		{
+			name:      "jobs without subcommand",
+			input:     "jobs",
+			wantArgs:  []string{},
+			wantFlags: map[string]interface{}{},
+		},
+		{
+			name:      "download defaults",
+			input:     "download hackernews",
+			wantArgs:  []string{"hackernews"},
+			wantFlags: map[string]interface{}{"batch-size": 100, "priority": 5},
+		},
+		{
+			name:      "download with a positional batch size",
+			input:     "download hackernews 1000",
+			wantArgs:  []string{"hackernews", "1000"},
+			wantFlags: map[string]interface{}{"batch-size": 100, "priority": 5},
+		},
+		{
+			name:      "download with batch size and priority",
+			input:     "download hackernews --batch-size 50 -p 10",
+			wantArgs:  []string{"hackernews"},
+			wantFlags: map[string]interface{}{"batch-size": 50, "priority": 10},
+		},
+		{
+			name:      "config set",
+			input:     "config set log.levels.jobs debug",
+			wantArgs:  []string{"set", "log.levels.jobs", "debug"},
+			wantFlags: map[string]interface{}{},
+		},
+		{
+			name:      "sources export",
+			input:     "sources export-dataset hackernews hn.tar.gz",
+			wantArgs:  []string{"export-dataset", "hackernews", "hn.tar.gz"},
+			wantFlags: map[string]interface{}{},
+		},
+		{
+			name:      "chart spec",
+			input:     ".chart hist:x=score,bins=20",
+			wantArgs:  []string{"hist:x=score,bins=20"},
+			wantFlags: map[string]interface{}{},
+		},
+		{name: "unknown jobs flag", input: "jobs cancel --bogus x", wantErr: true},
+		{name: "non-numeric batch size", input: "download hackernews --batch-size big", wantErr: true},
+		{
+			name:      "query without source",
+			input:     `query "SELECT 1"`,
+			wantArgs:  []string{"SELECT 1"},
+			wantFlags: map[string]interface{}{},
+		},
+		{name: "query without arguments", input: "query", wantErr: true},
+		{name: "config without subcommand", input: "config", wantErr: true},
+		{name: "chart without spec", input: ".chart", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := parser.Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(cmd.Args, tt.wantArgs) {
+				t.Errorf("Args = %q, want %q", cmd.Args, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(cmd.Flags, tt.wantFlags) {
+				t.Errorf("Flags = %v, want %v", cmd.Flags, tt.wantFlags)
+			}
+		})
+	}
+}
+
+func TestJobFilter(t *testing.T) {
+	parser := newApplicationParser(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	cmd, err := parser.Parse("jobs cleanup --source hackernews --failed --type download --older-than 7d")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	filter, err := jobFilter(cmd, now)
+	if err != nil {
+		t.Fatalf("jobFilter() error = %v", err)
+	}
+
+	if filter.Source != "hackernews" {
+		t.Errorf("Source = %q, want hackernews", filter.Source)
+	}
+	if !reflect.DeepEqual(filter.States, []jobs.JobState{jobs.JobStateFailed}) {
+		t.Errorf("States = %v, want [failed]", filter.States)
+	}
+	if !reflect.DeepEqual(filter.Types, []jobs.JobType{"download"}) {
+		t.Errorf("Types = %v, want [download]", filter.Types)
+	}
+	if filter.CreatedBefore == nil || !filter.CreatedBefore.Equal(now.Add(-7*24*time.Hour)) {
+		t.Errorf("CreatedBefore = %v, want 7 days before now", filter.CreatedBefore)
+	}
+
+	cmd, err = parser.Parse("jobs cancel --state sleeping")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if _, err := jobFilter(cmd, now); err == nil {
+		t.Error("expected an error for an unknown job state")
+	}
+}
+
+func TestQueryHandler_Output(t *testing.T) {
+	source := &queryTestSource{result: datasource.QueryResult{
+		Columns:  []string{"id", "title"},
+		Rows:     [][]interface{}{{1, "first"}, {2, "second"}, {3, "third"}},
+		Count:    3,
+		Duration: 250 * time.Millisecond,
+	}}
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	dataSources := map[string]datasource.DataSource{"test": source}
+
+	path := filepath.Join(t.TempDir(), "result.json")
+	input := `query test "SELECT id, title FROM items" --limit 2 --output ` + path
+	if err := integration.ProcessCommand(context.Background(), input, nil, dataSources, nil, nil); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+
+	if len(source.queries) != 1 || source.queries[0] != "SELECT id, title FROM items" {
+		t.Errorf("queries = %q, want the quoted SQL", source.queries)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	var records []map[string]interface{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, data)
+	}
+	if len(records) != 2 || records[1]["title"] != "second" {
+		t.Errorf("records = %v, want the first two rows", records)
+	}
+
+	last, ok := integration.GetSession().Variables[lastResultVariable].(datasource.QueryResult)
+	if !ok || len(last.Rows) != 2 {
+		t.Errorf("last result = %+v, want the limited result", last)
+	}
+	if source, duration := LastQuery(integration.GetSession()); source != "test" || duration != 250*time.Millisecond {
+		t.Errorf("LastQuery() = %q, %v, want test, 250ms", source, duration)
+	}
+}
+
+func TestQueryHandler_OutputHooks(t *testing.T) {
+	source := &queryTestSource{result: datasource.QueryResult{
+		Columns: []string{"id", "title"},
+		Rows:    [][]interface{}{{1, "first"}, {2, "second"}},
+		Count:   2,
+	}}
+	events := make(chan query.ExportEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event query.ExportEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("webhook body is not an export event: %v", err)
+		}
+		events <- event
+	}))
+	defer server.Close()
+
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	dataSources := map[string]datasource.DataSource{"test": source}
+	run := func(input string) error {
+		return integration.ProcessCommand(context.Background(), input, nil, dataSources, nil, nil)
+	}
+
+	if err := run(`query test "SELECT id, title FROM items" --webhook ` + server.URL); err == nil || !strings.Contains(err.Error(), "--output") {
+		t.Errorf("hooks without --output error = %v, want a hint to add --output", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "result.csv")
+	if err := run(`query test "SELECT id, title FROM items" --output ` + path + ` --webhook ` + server.URL); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.Output != path || event.Format != "csv" || event.Rows != 2 || event.DataSource != "test" || event.Bytes == 0 {
+			t.Errorf("event = %+v, want the written CSV file", event)
+		}
+	default:
+		t.Fatal("webhook was not posted")
+	}
+}
+
+func TestUseHandler(t *testing.T) {
+	source := &queryTestSource{result: datasource.QueryResult{Columns: []string{"id"}, Rows: [][]interface{}{{1}}, Count: 1}}
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	dataSources := map[string]datasource.DataSource{"test": source}
+	run := func(input string) error {
+		return integration.ProcessCommand(context.Background(), input, nil, dataSources, nil, nil)
+	}
+
+	if err := run(`query "SELECT id FROM items"`); err == nil || !strings.Contains(err.Error(), "use <source>") {
+		t.Errorf("query without a source error = %v, want a hint to use a source", err)
+	}
+	if err := run("use missing"); err == nil {
+		t.Error("use of an unknown source should fail")
+	}
+
+	if err := run("use test"); err != nil {
+		t.Fatalf("use error = %v", err)
+	}
+	if got := ActiveSource(integration.GetSession()); got != "test" {
+		t.Errorf("ActiveSource() = %q, want test", got)
+	}
+	if err := run(`query "SELECT id FROM items"`); err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	if err := run(`query test "SELECT 2"`); err != nil {
+		t.Fatalf("query naming the source error = %v", err)
+	}
+	if want := []string{"SELECT id FROM items", "SELECT 2"}; !reflect.DeepEqual(source.queries, want) {
+		t.Errorf("queries = %q, want %q", source.queries, want)
+	}
+	if err := run("query test"); err == nil {
+		t.Error("query without SQL should fail")
+	}
+
+	if err := run("use none"); err != nil {
+		t.Fatalf("use none error = %v", err)
+	}
+	if got := ActiveSource(integration.GetSession()); got != "" {
+		t.Errorf("ActiveSource() = %q after use none, want none", got)
+	}
+}
+
+// scratchTestSource is a query test source with a scratch area
+type scratchTestSource struct {
+	queryTestSource
+	db *sql.DB
+}
+
+func (s *scratchTestSource) OpenScratch() (*storage.Scratch, error) {
+	return storage.NewScratch(s.db)
+}
+
+func TestMaterializeHandler(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "scratch.sqlite"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	source := &scratchTestSource{db: db}
+
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	dataSources := map[string]datasource.DataSource{"test": source}
+	run := func(input string) error {
+		return integration.ProcessCommand(context.Background(), input, nil, dataSources, nil, nil)
+	}
+
+	if err := run(".materialize last_result AS tmp_top"); err == nil {
+		t.Error(".materialize before a query should fail")
+	}
+	if err := run(`query test "SELECT 1 AS id, 'a' AS title UNION ALL SELECT 2, 'b'"`); err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	if len(source.queries) != 0 {
+		t.Errorf("queries = %q, want them run on the scratch connection", source.queries)
+	}
+	if err := run(".materialize last_result AS tmp_top"); err != nil {
+		t.Fatalf(".materialize error = %v", err)
+	}
+	if err := run(".materialize last_result AS tmp_top"); err == nil || !strings.Contains(err.Error(), "--replace") {
+		t.Errorf(".materialize over an existing table error = %v, want a hint to replace it", err)
+	}
+	if err := run(".materialize last_result AS tmp_top --replace"); err != nil {
+		t.Fatalf(".materialize --replace error = %v", err)
+	}
+
+	if err := run(`query test "SELECT COUNT(*) FROM tmp_top"`); err != nil {
+		t.Fatalf("query of the scratch table error = %v", err)
+	}
+	last, _ := integration.GetSession().Variables[lastResultVariable].(datasource.QueryResult)
+	if len(last.Rows) != 1 || last.Rows[0][0] != int64(2) {
+		t.Errorf("scratch table rows = %v, want 2", last.Rows)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'tmp_top'").Scan(&count); err != nil || count != 0 {
+		t.Errorf("tmp_top in the database file: count = %d, err = %v", count, err)
+	}
+
+	if err := run(".scratch drop tmp_top"); err != nil {
+		t.Fatalf(".scratch drop error = %v", err)
+	}
+	if err := run(".scratch drop tmp_top"); err == nil {
+		t.Error("dropping a missing scratch table should fail")
+	}
+	CloseScratch(integration.GetSession())
+	if _, ok := integration.GetSession().Variables[scratchVariable]; ok {
+		t.Error("CloseScratch() left the scratch connections in the session")
+	}
+}
+
+func TestQueryHandler_ResultLimits(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "scratch.sqlite"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+	source := &scratchTestSource{db: db}
+
+	previous := config.AppConfig.Query
+	config.AppConfig.Query = config.QueryConfig{MaxResultRows: 2}
+	defer func() { config.AppConfig.Query = previous }()
+
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	dataSources := map[string]datasource.DataSource{"test": source}
+	run := func(input string) error {
+		return integration.ProcessCommand(context.Background(), input, nil, dataSources, nil, nil)
+	}
+	const sql = "SELECT 1 AS id UNION ALL SELECT 2 UNION ALL SELECT 3"
+
+	// The table shows the rows read so far, which are not kept as the last
+	// result
+	if err := run(`query test "` + sql + `"`); err != nil {
+		t.Fatalf("oversized table query error = %v", err)
+	}
+	if _, ok := integration.GetSession().Variables[lastResultVariable]; ok {
+		t.Error("a truncated result was kept as the last result")
+	}
+
+	path := filepath.Join(t.TempDir(), "result.csv")
+	err = run(`query test "` + sql + `" --output ` + path)
+	if err == nil || !strings.Contains(err.Error(), ".export") {
+		t.Errorf("oversized --output error = %v, want a suggestion to export", err)
+	}
+	if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+		t.Errorf("oversized result was written to %s", path)
+	}
+
+	// --limit below the limits stops reading early without an error
+	if err := run(`query test "` + sql + `" --limit 1 --output ` + path); err != nil {
+		t.Fatalf("--limit query error = %v", err)
+	}
+	last, _ := integration.GetSession().Variables[lastResultVariable].(datasource.QueryResult)
+	if len(last.Rows) != 1 || last.Truncated {
+		t.Errorf("last result = %+v, want the one row asked for", last)
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	log.InitLogger(false)
+	defer func(query config.QueryConfig) { config.AppConfig.Query = query }(config.AppConfig.Query)
+	config.AppConfig.Query = config.QueryConfig{MaxResultRows: 2}
+	source := &queryTestSource{result: datasource.QueryResult{
+		Columns:   []string{"id", "title"},
+		Rows:      [][]interface{}{{1, "first"}, {2, "second"}},
+		Count:     2,
+		Truncated: true,
+	}}
+	jobManager, err := jobs.NewEnhancedJobManager(t.TempDir(), nil, jobs.DefaultManagerConfig())
+	if err != nil {
+		t.Fatalf("NewEnhancedJobManager() error = %v", err)
+	}
+	if err := jobManager.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer jobManager.Stop()
+
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	dataSources := map[string]datasource.DataSource{"test": source}
+	run := func(input string) error {
+		return integration.ProcessCommand(context.Background(), input, jobManager, dataSources, nil, nil)
+	}
+	path := filepath.Join(t.TempDir(), "items.csv")
+
+	if err := run(".export csv " + path); err == nil || !strings.Contains(err.Error(), "run a query first") {
+		t.Errorf(".export before a query error = %v, want a hint to run one", err)
+	}
+	// A truncated result is shown in part but can still be exported whole
+	if err := run(`query test "SELECT id, title FROM items"`); err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	if err := run(".export table " + path); err == nil {
+		t.Error(".export as a table should fail")
+	}
+	source.result.Rows = append(source.result.Rows, []interface{}{3, "third"})
+	source.result.Truncated = false
+	if err := run(".export csv " + path); err != nil {
+		t.Fatalf(".export error = %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	var exported []*jobs.JobStatus
+	for time.Now().Before(deadline) {
+		exported, _ = jobManager.ListJobs(jobs.JobFilter{Types: []jobs.JobType{jobs.JobTypeExport}, States: []jobs.JobState{jobs.JobStateCompleted}})
+		if len(exported) == 1 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(exported) != 1 {
+		t.Fatalf("export job did not complete")
+	}
+	if got := exported[0].Metadata["output_file"]; got != path {
+		t.Errorf("output_file = %v, want %s", got, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if want := "id,title\n1,first\n2,second\n3,third\n"; string(data) != want {
+		t.Errorf("export = %q, want %q", data, want)
+	}
+	if want := []string{"SELECT id, title FROM items", "SELECT id, title FROM items"}; !reflect.DeepEqual(source.queries, want) {
+		t.Errorf("queries = %q, want the last query run again", source.queries)
+	}
+}
+
+func TestBookmarkHandlers(t *testing.T) {
+	source := &queryTestSource{result: datasource.QueryResult{
+		Columns: []string{"id", "title"},
+		Rows:    [][]interface{}{{int64(1), "first"}, {int64(2), "second"}},
+		Count:   2,
+	}}
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	dataSources := map[string]datasource.DataSource{"test": source}
+	cfg := config.Config{StoragePath: t.TempDir()}
+	run := func(input string) error {
+		return integration.ProcessCommand(context.Background(), input, nil, dataSources, cfg, nil)
+	}
+
+	if err := run(".bookmark top"); err == nil {
+		t.Error(".bookmark before a query should fail")
+	}
+	if err := run(`query test "SELECT id, title FROM items"`); err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	if err := run(".bookmark top"); err != nil {
+		t.Fatalf(".bookmark error = %v", err)
+	}
+	if err := run(".bookmarks"); err != nil {
+		t.Fatalf(".bookmarks error = %v", err)
+	}
+
+	// A later query replaces the last result until the bookmark is recalled
+	source.result = datasource.QueryResult{Columns: []string{"n"}, Rows: [][]interface{}{{int64(9)}}, Count: 1}
+	if err := run(`query test "SELECT 9 AS n"`); err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "top.csv")
+	if err := run(".recall top --output " + path); err != nil {
+		t.Fatalf(".recall error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if want := "id,title\n1,first\n2,second\n"; string(data) != want {
+		t.Errorf("recalled CSV = %q, want %q", data, want)
+	}
+	if last, _ := integration.GetSession().Variables[lastResultQueryVariable].(queryBuffer); last.SQL != "SELECT id, title FROM items" || last.Source != "test" {
+		t.Errorf("last result query = %+v, want the bookmarked query", last)
+	}
+
+	if err := run(".recall missing"); err == nil || !strings.Contains(err.Error(), "no bookmark") {
+		t.Errorf(".recall of a missing bookmark error = %v", err)
+	}
+	if err := run(".bookmark top --delete"); err != nil {
+		t.Fatalf(".bookmark --delete error = %v", err)
+	}
+	if err := run(".recall top"); err == nil {
+		t.Error(".recall of a deleted bookmark should fail")
+	}
+}
+
+func TestChartHandler_NoResult(t *testing.T) {
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+
+	err := integration.ProcessCommand(context.Background(), ".chart bar:x=a,y=b", nil, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "no query result") {
+		t.Errorf("ProcessCommand() error = %v, want missing result error", err)
+	}
+}
+
+// editTestShell stands in for the external editor
+type editTestShell struct {
+	ShellServices
+	edited string
+	opened []string
+}
+
+func (s *editTestShell) EditQuery(sql string) (string, error) {
+	s.opened = append(s.opened, sql)
+	return s.edited, nil
+}
+
+func (s *editTestShell) OutputSettings() OutputSettings {
+	return DefaultOutputSettings()
+}
+
+func TestEditHandler(t *testing.T) {
+	source := &queryTestSource{result: datasource.QueryResult{Columns: []string{"id"}, Rows: [][]interface{}{{1}}, Count: 1}}
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	dataSources := map[string]datasource.DataSource{"test": source}
+
+	if err := integration.ProcessCommand(context.Background(), `query test "SELECT id FROM items"`, nil, dataSources, nil, nil); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+
+	// .edit opens the last query and runs the saved text
+	shell := &editTestShell{edited: "SELECT id\nFROM items\nLIMIT 5\n"}
+	if err := integration.ProcessCommand(context.Background(), ".edit", nil, dataSources, nil, shell); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if len(shell.opened) != 1 || shell.opened[0] != "SELECT id FROM items" {
+		t.Errorf("opened = %q, want the last query", shell.opened)
+	}
+	if len(source.queries) != 2 || source.queries[1] != "SELECT id\nFROM items\nLIMIT 5" {
+		t.Errorf("queries = %q, want the edited query", source.queries)
+	}
+
+	// The next .edit starts from the edited query
+	shell.edited = ""
+	if err := integration.ProcessCommand(context.Background(), ".edit", nil, dataSources, nil, shell); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if shell.opened[1] != "SELECT id\nFROM items\nLIMIT 5" {
+		t.Errorf("opened = %q, want the edited query", shell.opened[1])
+	}
+	if len(source.queries) != 2 {
+		t.Errorf("an empty edit should not run a query, got %q", source.queries)
+	}
+
+	err := integration.ProcessCommand(context.Background(), ".edit", nil, dataSources, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "interactive shell") {
+		t.Errorf("ProcessCommand() error = %v, want an interactive shell error", err)
+	}
+}
+
+// resultsTestShell records the results it is asked to show
+type resultsTestShell struct {
+	ShellServices
+	title  string
+	result datasource.QueryResult
+}
+
+func (s *resultsTestShell) BrowseResult(title string, result datasource.QueryResult, values query.ValueFormat) error {
+	s.title, s.result = title, result
+	return nil
+}
+
+func (s *resultsTestShell) OutputSettings() OutputSettings {
+	return DefaultOutputSettings()
+}
+
+func TestResultsHandler(t *testing.T) {
+	source := &queryTestSource{result: datasource.QueryResult{
+		Columns: []string{"id"},
+		Rows:    [][]interface{}{{1}, {2}, {3}},
+		Count:   3,
+	}}
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	dataSources := map[string]datasource.DataSource{"test": source}
+	shell := &resultsTestShell{}
+	run := func(input string) error {
+		return integration.ProcessCommand(context.Background(), input, nil, dataSources, nil, shell)
+	}
+
+	if err := run(".results"); err == nil || !strings.Contains(err.Error(), "run a query first") {
+		t.Errorf(".results before a query error = %v, want a hint to run one", err)
+	}
+	if err := run(`query test "SELECT id FROM items"`); err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	if err := run(".results"); err != nil {
+		t.Fatalf(".results error = %v", err)
+	}
+	if shell.title != "Result of test: SELECT id FROM items" || len(shell.result.Rows) != 3 {
+		t.Errorf("shown %q with %d rows, want the last query's result", shell.title, len(shell.result.Rows))
+	}
+}
+
+func TestDownloadBatchSize(t *testing.T) {
+	tests := []struct {
+		args    []string
+		flag    int
+		want    int
+		wantErr bool
+	}{
+		{args: []string{"hackernews"}, flag: 100, want: 100},
+		{args: []string{"hackernews"}, flag: 50, want: 50},
+		{args: []string{"hackernews", "1000"}, flag: 100, want: 1000},
+		{args: []string{"hackernews", "1000"}, flag: 1000, want: 1000},
+		{args: []string{"hackernews", "1000"}, flag: 50, wantErr: true},
+		{args: []string{"hackernews", "many"}, flag: 100, wantErr: true},
+		{args: []string{"hackernews", "0"}, flag: 100, wantErr: true},
+	}
+	for _, tt := range tests {
+		cmd := &Command{Args: tt.args, Flags: map[string]interface{}{"batch-size": tt.flag}}
+		got, err := downloadBatchSize(cmd)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("downloadBatchSize(%v, --batch-size %d) = %d, %v; want %d, error %v", tt.args, tt.flag, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWriteQueryResult(t *testing.T) {
+	result := datasource.QueryResult{
+		Columns:  []string{"id", "title"},
+		Rows:     [][]interface{}{{1, "a,b"}, {2, "c"}, {30, "d"}},
+		Count:    3,
+		Duration: time.Millisecond,
+	}
+
+	var table bytes.Buffer
+	if err := writeQueryResult(&table, result, OutputSettings{Format: query.OutputFormatTable, PageSize: 2}); err != nil {
+		t.Fatalf("writeQueryResult() error = %v", err)
+	}
+	want := "id  title\n--  -----\n 1  a,b\n 2  c\n... and 1 more rows\n"
+	if table.String() != want {
+		t.Errorf("table output = %q, want %q", table.String(), want)
+	}
+
+	var csv bytes.Buffer
+	if err := writeQueryResult(&csv, result, OutputSettings{Format: query.OutputFormatCSV, ShowTiming: true}); err != nil {
+		t.Fatalf("writeQueryResult() error = %v", err)
+	}
+	if !strings.HasPrefix(csv.String(), "id,title\n1,\"a,b\"\n2,c\n30,d\n") {
+		t.Errorf("csv output = %q", csv.String())
+	}
+	if !strings.Contains(csv.String(), "Query completed in 1ms (3 rows)") {
+		t.Errorf("csv output is missing the query time: %q", csv.String())
+	}
+}
+
+func TestShellIntegration_RegisterPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugin is a shell script in this test")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pubdatahub-keep-context")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\ncat > \"$2\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	integration := NewShellIntegration()
+	if err := integration.RegisterApplicationCommands(); err != nil {
+		t.Fatalf("RegisterApplicationCommands() error = %v", err)
+	}
+	if err := integration.RegisterPlugin(plugin.Plugin{Name: "query", Path: path}); err == nil {
+		t.Error("Expected a plugin named like a built-in command to be refused")
+	}
+	if err := integration.RegisterPlugin(plugin.Plugin{Name: "keep-context", Path: path}); err != nil {
+		t.Fatalf("RegisterPlugin() error = %v", err)
+	}
+
+	output := filepath.Join(dir, "context.json")
+	dataSources := map[string]datasource.DataSource{"hackernews": &statusTestSource{}}
+	if err := integration.ProcessCommand(context.Background(), "keep-context --to "+output, nil, dataSources, nil, nil); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pctx plugin.Context
+	if err := json.Unmarshal(data, &pctx); err != nil {
+		t.Fatalf("Plugin context is not JSON: %v", err)
+	}
+	if pctx.Command != "keep-context" || pctx.Interface != plugin.InterfaceShell {
+		t.Errorf("Unexpected plugin context %+v", pctx)
+	}
+	if !reflect.DeepEqual(pctx.Args, []string{"--to", output}) {
+		t.Errorf("Args = %v, want --to %s", pctx.Args, output)
+	}
+	if len(pctx.DataSources) != 1 || pctx.DataSources[0].Name != "hackernews" {
+		t.Errorf("DataSources = %+v, want hackernews", pctx.DataSources)
+	}
+}
@@ -0,0 +1,661 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/jobs"
+)
+
+// jobFilterFlags are the jobs flags that select jobs, in the form
+// jobs.ParseJobFilter reads them
+var jobFilterFlags = []string{"source", "state", "type", "since", "older-than", "created-by"}
+
+// JobsHandler handles job management commands
+type JobsHandler struct {
+	*BaseHandler
+}
+
+// NewJobsHandler creates a new jobs handler
+func NewJobsHandler() *JobsHandler {
+	spec := &CommandSpec{
+		Name:        "jobs",
+		Description: "Manage background jobs",
+		Usage:       "jobs [list|status|pause|resume|stop|stats|cancel|retry|cleanup|history|top|disk|quota|workers|dlq] [args...]",
+		Category:    "system",
+		MinArgs:     0,
+		MaxArgs:     -1,
+		Flags: map[string]FlagSpec{
+			"source":     {Type: "string", Description: "Only jobs of this data source"},
+			"state":      {Type: "string", Description: "Only jobs in these states (comma-separated)"},
+			"type":       {Type: "string", Description: "Only jobs of these types (comma-separated)"},
+			"failed":     {Type: "bool", Description: "Only failed jobs"},
+			"since":      {Type: "string", Description: "Only jobs created within this age (e.g. 1h, 7d)"},
+			"older-than": {Type: "string", Description: "Only jobs created before this age (e.g. 7d)"},
+			"created-by": {Type: "string", Description: "Only jobs created by this user"},
+			"all":        {Type: "bool", Description: "Summarize the history of all matching jobs"},
+			"once":       {Type: "bool", Description: "Draw the jobs view once and exit"},
+		},
+		DryRun:      true,
+		Destructive: []string{"stop", "cancel"},
+		Examples: []string{
+			"jobs",
+			"jobs list",
+			"jobs status job_123",
+			"jobs pause job_123",
+			"jobs resume job_123",
+			"jobs stop job_123",
+			"jobs cancel --source hackernews --state queued",
+			"jobs retry --failed --since 1h",
+			"jobs cleanup --older-than 7d",
+			"jobs cleanup --older-than 7d --dry-run",
+			"jobs history job_123",
+			"jobs history --all --since 24h",
+			"jobs top --once",
+			"jobs disk override",
+			"jobs quota",
+			"jobs workers",
+			"jobs workers set 8",
+			"jobs workers auto",
+			"jobs dlq list",
+			"jobs dlq requeue job_123",
+		},
+	}
+
+	return &JobsHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute handles job operations; without a subcommand it lists active jobs
+func (jh *JobsHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	jm, err := requireJobManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	args := cmd.Args
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		summaries, err := jm.ListActiveSummaries()
+		if err != nil {
+			return fmt.Errorf("failed to list jobs: %w", err)
+		}
+		if len(summaries) == 0 {
+			fmt.Println("No active jobs")
+			return nil
+		}
+		fmt.Println("Active jobs:")
+		for _, summary := range summaries {
+			message := summary["message"]
+			if reason, waiting := summary["wait_reason"]; waiting {
+				message = fmt.Sprintf("waiting: %s", reason)
+			}
+			fmt.Printf("  %s: %s (%s) - %.1f%% - %s\n",
+				summary["id"],
+				summary["description"],
+				summary["state"],
+				summary["progress"],
+				message)
+		}
+		return nil
+	case "status":
+		if len(args) < 2 {
+			return fmt.Errorf("status command requires job ID")
+		}
+		summary, err := jm.GetJobSummary(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to get job status: %w", err)
+		}
+		displayJobSummary(summary)
+		return nil
+	case "pause":
+		if len(args) < 2 {
+			return fmt.Errorf("pause command requires job ID")
+		}
+		if ctx.DryRun {
+			return previewJobCommand(jm, args[1], "paused")
+		}
+		if err := jm.PauseJob(args[1]); err != nil {
+			return fmt.Errorf("failed to pause job: %w", err)
+		}
+		fmt.Printf("Job %s paused\n", args[1])
+		return nil
+	case "resume":
+		if len(args) < 2 {
+			return fmt.Errorf("resume command requires job ID")
+		}
+		if ctx.DryRun {
+			return previewJobCommand(jm, args[1], "resumed")
+		}
+		if err := jm.ResumeJob(args[1]); err != nil {
+			return fmt.Errorf("failed to resume job: %w", err)
+		}
+		fmt.Printf("Job %s resumed\n", args[1])
+		return nil
+	case "stop":
+		if len(args) < 2 {
+			return fmt.Errorf("stop command requires job ID")
+		}
+		if ctx.DryRun {
+			return previewJobCommand(jm, args[1], "stopped")
+		}
+		if err := jm.CancelJob(args[1]); err != nil {
+			return fmt.Errorf("failed to stop job: %w", err)
+		}
+		fmt.Printf("Job %s stopped\n", args[1])
+		return nil
+	case "stats":
+		displayManagerStats(jm.GetManagerSummary())
+		return nil
+	case "cancel":
+		return runBulkJobCommand(ctx, jm, cmd, "cancelled", jm.CancelJob, jm.CancelMatching,
+			func(status *jobs.JobStatus) bool { return !status.IsFinished() })
+	case "retry":
+		return runBulkJobCommand(ctx, jm, cmd, "retried", func(id string) error {
+			if err := jm.RetryJob(id); err != nil {
+				return err
+			}
+			return jm.StartJob(id)
+		}, jm.RetryMatching, func(status *jobs.JobStatus) bool { return status.State == jobs.JobStateFailed })
+	case "cleanup":
+		return runBulkJobCommand(ctx, jm, cmd, "removed", nil, jm.TrashMatching,
+			func(status *jobs.JobStatus) bool { return status.IsFinished() })
+	case "history":
+		return runJobHistoryCommand(jm, cmd)
+	case "top":
+		if ctx.Shell == nil {
+			return fmt.Errorf("jobs top is only available in the interactive shell")
+		}
+		return ctx.Shell.RunJobsTop(cmd.Flags["once"] == true)
+	case "disk":
+		if ctx.DryRun && len(args) > 1 {
+			fmt.Println("Dry run: the disk space guard would be overridden")
+			return nil
+		}
+		return runDiskGuardCommand(jm, args[1:])
+	case "quota":
+		return runQuotaGuardCommand(jm)
+	case "workers":
+		if ctx.DryRun && len(args) > 1 {
+			fmt.Printf("Dry run: the worker pool would be %s\n", strings.Join(args[1:], " "))
+			return nil
+		}
+		return runWorkersCommand(jm, args[1:])
+	case "dlq":
+		if ctx.DryRun && len(args) > 2 {
+			return previewJobCommand(jm, args[2], "requeued")
+		}
+		return runDeadLetterCommand(jm, args[1:])
+	default:
+		return fmt.Errorf("unknown jobs subcommand: %s", args[0])
+	}
+}
+
+// GetArgumentCompletions completes jobs subcommands
+func (jh *JobsHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	switch {
+	case len(args) == 0:
+		return completeFrom([]string{"list", "status", "pause", "resume", "stop", "stats",
+			"cancel", "retry", "cleanup", "history", "top", "disk", "quota", "workers", "dlq"}, partial)
+	case len(args) == 1 && args[0] == "disk":
+		return completeFrom([]string{"override"}, partial)
+	case len(args) == 1 && args[0] == "workers":
+		return completeFrom([]string{"set", "auto"}, partial)
+	case len(args) == 1 && args[0] == "dlq":
+		return completeFrom([]string{"list", "requeue"}, partial)
+	}
+	return []string{}
+}
+
+// jobFilter builds a job filter from the filter flags of cmd
+func jobFilter(cmd *Command, now time.Time) (jobs.JobFilter, error) {
+	var args []string
+	for _, name := range jobFilterFlags {
+		if value, ok := cmd.Flags[name].(string); ok {
+			args = append(args, "--"+name, value)
+		}
+	}
+	if cmd.Flags["failed"] == true {
+		args = append(args, "--failed")
+	}
+
+	filter, _, err := jobs.ParseJobFilter(args, now)
+	return filter, err
+}
+
+// runBulkJobCommand applies a job action to the jobs named in the arguments,
+// or to every job matching the filter flags
+func runBulkJobCommand(ctx *ExecutionContext, jm *jobs.EnhancedJobManager, cmd *Command, verb string,
+	single func(id string) error, bulk func(filter jobs.JobFilter) (*jobs.BulkResult, error),
+	applies func(status *jobs.JobStatus) bool) error {
+	filter, err := jobFilter(cmd, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if ids := cmd.Args[1:]; len(ids) > 0 {
+		if single == nil {
+			return fmt.Errorf("this command takes filter flags, not job IDs")
+		}
+		for _, id := range ids {
+			if ctx.DryRun {
+				if err := previewJobCommand(jm, id, verb); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := single(id); err != nil {
+				return fmt.Errorf("job %s: %w", id, err)
+			}
+			fmt.Printf("Job %s %s\n", id, verb)
+		}
+		return nil
+	}
+
+	// Refuse to act on every job by accident
+	if filter.IsEmpty() {
+		return fmt.Errorf("specify job IDs or at least one filter (--source, --state, --type, --failed, --since, --older-than)")
+	}
+
+	if ctx.DryRun {
+		return previewBulkJobCommand(jm, filter, verb, applies)
+	}
+
+	result, err := bulk(filter)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d of %d matching jobs %s\n", len(result.Affected), result.Matched, verb)
+	for _, id := range result.Affected {
+		fmt.Printf("  %s\n", id)
+	}
+	if result.TrashID != "" {
+		fmt.Printf("Undo with: undo %s\n", result.TrashID)
+	}
+	return result.Err()
+}
+
+// previewJobCommand reports what a job action would do in a dry run
+func previewJobCommand(jm *jobs.EnhancedJobManager, id, verb string) error {
+	status, err := jm.GetJob(id)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", id, err)
+	}
+	fmt.Printf("Dry run: job %s (%s) would be %s\n", id, status.State, verb)
+	return nil
+}
+
+// previewBulkJobCommand lists the jobs a bulk action would apply to in a dry
+// run; applies reports whether the action applies to a matching job
+func previewBulkJobCommand(jm *jobs.EnhancedJobManager, filter jobs.JobFilter, verb string,
+	applies func(status *jobs.JobStatus) bool) error {
+	matched, err := jm.ListJobs(filter)
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	var affected []*jobs.JobStatus
+	for _, status := range matched {
+		if applies(status) {
+			affected = append(affected, status)
+		}
+	}
+
+	fmt.Printf("Dry run: %d of %d matching jobs would be %s\n", len(affected), len(matched), verb)
+	for _, status := range affected {
+		fmt.Printf("  %s (%s)\n", status.ID, status.State)
+	}
+	return nil
+}
+
+// runJobHistoryCommand shows a job's event timeline, or with --all a summary
+// of job outcomes matching the filter flags
+func runJobHistoryCommand(jm *jobs.EnhancedJobManager, cmd *Command) error {
+	ids := cmd.Args[1:]
+	if cmd.Flags["all"] != true {
+		if len(ids) != 1 {
+			return fmt.Errorf("usage: jobs history <id> | jobs history --all [--since 24h]")
+		}
+		return displayJobHistory(jm, ids[0])
+	}
+
+	filter, err := jobFilter(cmd, time.Now())
+	if err != nil {
+		return err
+	}
+	summary, err := jm.SummarizeJobs(filter)
+	if err != nil {
+		return fmt.Errorf("failed to summarize jobs: %w", err)
+	}
+	displayJobOutcomes(summary)
+	return nil
+}
+
+// displayJobHistory prints a job's event timeline
+func displayJobHistory(jm *jobs.EnhancedJobManager, id string) error {
+	timeline, err := jm.JobHistory(id)
+	if err != nil {
+		return fmt.Errorf("failed to load job history: %w", err)
+	}
+
+	fmt.Printf("History for job %s:\n", id)
+	if len(timeline) == 0 {
+		fmt.Println("  No events recorded")
+		return nil
+	}
+
+	fmt.Printf("  %-19s  %9s  %-16s  %s\n", "TIME", "+PREV", "EVENT", "MESSAGE")
+	for _, entry := range timeline {
+		fmt.Printf("  %-19s  %9s  %-16s  %s\n",
+			entry.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			"+"+entry.SincePrevious.Round(time.Second).String(),
+			entry.EventType,
+			entry.Message)
+	}
+	fmt.Printf("  Elapsed: %s\n", timeline[len(timeline)-1].SinceStart.Round(time.Second))
+	return nil
+}
+
+// displayJobOutcomes prints a summary of job outcomes
+func displayJobOutcomes(summary *jobs.JobOutcomeSummary) {
+	fmt.Printf("Jobs: %d\n", summary.Total)
+	if summary.Total == 0 {
+		return
+	}
+
+	states := []jobs.JobState{jobs.JobStateCompleted, jobs.JobStateFailed, jobs.JobStateDeadLetter,
+		jobs.JobStateCancelled, jobs.JobStateRunning, jobs.JobStatePaused, jobs.JobStateQueued}
+	for _, state := range states {
+		if count := summary.ByState[state]; count > 0 {
+			fmt.Printf("  %-11s %d\n", state, count)
+		}
+	}
+
+	types := make([]string, 0, len(summary.ByType))
+	for jobType := range summary.ByType {
+		types = append(types, string(jobType))
+	}
+	sort.Strings(types)
+	fmt.Println("By type:")
+	for _, jobType := range types {
+		counts := summary.ByType[jobs.JobType(jobType)]
+		fmt.Printf("  %-12s %d completed, %d failed, %d cancelled\n", jobType,
+			counts[jobs.JobStateCompleted], counts[jobs.JobStateFailed], counts[jobs.JobStateCancelled])
+	}
+
+	if summary.AverageDuration > 0 {
+		fmt.Printf("Duration: average %s, longest %s\n",
+			summary.AverageDuration.Round(time.Second), summary.LongestDuration.Round(time.Second))
+	}
+
+	if len(summary.Failures) > 0 {
+		fmt.Println("Recent failures:")
+		for i, failure := range summary.Failures {
+			if i == 10 {
+				fmt.Printf("  ... and %d more\n", len(summary.Failures)-i)
+				break
+			}
+			fmt.Printf("  %s  %s  %s\n", failure.StartTime.Local().Format("2006-01-02 15:04"), failure.ID, failure.ErrorMessage)
+		}
+	}
+}
+
+// runWorkersCommand shows the worker pool and its scaling, sets its size by
+// hand or hands it back to auto-scaling
+func runWorkersCommand(jm *jobs.EnhancedJobManager, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "set":
+			if len(args) != 2 {
+				return fmt.Errorf("usage: jobs workers set <n>")
+			}
+			size, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Errorf("invalid worker count: %s", args[1])
+			}
+			if err := jm.SetWorkers(size); err != nil {
+				return err
+			}
+			fmt.Printf("Worker pool set to %d workers\n", size)
+			return nil
+		case "auto":
+			if err := jm.AutoScaleWorkers(); err != nil {
+				return fmt.Errorf("%w (set jobs.min_workers below jobs.workers)", err)
+			}
+			fmt.Println("Worker pool scales with load again")
+			return nil
+		default:
+			return fmt.Errorf("unknown jobs workers subcommand: %s (use set or auto)", args[0])
+		}
+	}
+
+	stats := jm.WorkerStats()
+	fmt.Printf("Workers:       %d (%d active, %d idle)\n", stats.TotalWorkers, stats.ActiveWorkers, stats.IdleWorkers)
+	fmt.Printf("Queued jobs:   %d\n", stats.QueueSize)
+	if stats.QueueSize > 0 {
+		fmt.Printf("Oldest wait:   %s\n", stats.OldestWait.Round(time.Second))
+	}
+
+	scaling := "off (fixed size)"
+	if stats.MaxWorkers > 0 {
+		scaling = fmt.Sprintf("%d to %d workers", stats.MinWorkers, stats.MaxWorkers)
+		if stats.Pinned {
+			scaling += ", paused by jobs workers set"
+		}
+	}
+	fmt.Printf("Auto-scaling:  %s\n", scaling)
+
+	if len(stats.ScalingEvents) > 0 {
+		fmt.Printf("Size changes:  %d up, %d down\n", stats.ScaleUps, stats.ScaleDowns)
+		for _, event := range stats.ScalingEvents {
+			fmt.Printf("  %s  %d -> %d  %s\n", event.Time.Local().Format("2006-01-02 15:04:05"), event.From, event.To, event.Reason)
+		}
+	}
+	return nil
+}
+
+// runDeadLetterCommand lists the jobs that failed after their last retry
+// with the error of each attempt, or requeues one of them
+func runDeadLetterCommand(jm *jobs.EnhancedJobManager, args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+	case "requeue":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: jobs dlq requeue <id>")
+		}
+		if err := jm.RequeueDeadLetter(args[1]); err != nil {
+			return fmt.Errorf("failed to requeue job: %w", err)
+		}
+		fmt.Printf("Job %s requeued\n", args[1])
+		return nil
+	default:
+		return fmt.Errorf("unknown jobs dlq subcommand: %s (use list or requeue)", args[0])
+	}
+
+	deadLetter, err := jm.DeadLetterJobs()
+	if err != nil {
+		return fmt.Errorf("failed to list dead-letter jobs: %w", err)
+	}
+	if len(deadLetter) == 0 {
+		fmt.Println("Dead-letter queue is empty")
+		return nil
+	}
+
+	fmt.Printf("Dead-letter queue (%d jobs):\n", len(deadLetter))
+	for _, status := range deadLetter {
+		errors, err := jm.JobErrors(status.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("  %s: %s (%s) - failed %d times\n", status.ID, status.Description, status.Type, len(errors))
+		for _, jobError := range errors {
+			fmt.Printf("    %d. %s  %s\n", jobError.Attempt, jobError.Time.Local().Format("2006-01-02 15:04:05"), jobError.Message)
+		}
+	}
+	fmt.Println("Requeue with: jobs dlq requeue <id>")
+	return nil
+}
+
+// runDiskGuardCommand shows the disk space guard or overrides it
+func runDiskGuardCommand(jm *jobs.EnhancedJobManager, args []string) error {
+	guard := jm.DiskGuard()
+
+	if len(args) > 0 {
+		if args[0] != "override" {
+			return fmt.Errorf("unknown jobs disk subcommand: %s (use override)", args[0])
+		}
+		resumed := guard.Override()
+		fmt.Printf("Disk space guard overridden until space is recovered; resumed %d jobs\n", len(resumed))
+		return nil
+	}
+
+	status := guard.Check()
+	if !status.Enabled {
+		fmt.Println("Disk space guard is disabled (download.min_free_mb is 0)")
+		return nil
+	}
+
+	fmt.Printf("Storage path:  %s\n", status.Path)
+	if status.Error != "" {
+		fmt.Printf("Free space:    unknown (%s)\n", status.Error)
+	} else {
+		fmt.Printf("Free space:    %d MB\n", status.FreeBytes/(1024*1024))
+	}
+	fmt.Printf("Pause below:   %d MB\n", status.MinFreeBytes/(1024*1024))
+	fmt.Printf("Resume above:  %d MB\n", status.ResumeFreeBytes/(1024*1024))
+
+	state := "ok"
+	if status.Low && status.Overridden {
+		state = "low (overridden)"
+	} else if status.Low {
+		state = "low, downloads paused"
+	}
+	fmt.Printf("State:         %s\n", state)
+	if len(status.HeldJobs) > 0 {
+		fmt.Printf("Held jobs:     %s\n", strings.Join(status.HeldJobs, ", "))
+	}
+	return nil
+}
+
+// runQuotaGuardCommand shows the storage used by data sources with a quota
+func runQuotaGuardCommand(jm *jobs.EnhancedJobManager) error {
+	usage := jm.QuotaGuard().Check()
+	if len(usage) == 0 {
+		fmt.Println("No storage quotas (set data_sources.<name>.quota_mb)")
+		return nil
+	}
+
+	for _, source := range usage {
+		fmt.Printf("%s (%s)\n", source.Source, source.Path)
+		if source.Error != "" {
+			fmt.Printf("  Used:       unknown (%s)\n", source.Error)
+		} else {
+			fmt.Printf("  Used:       %d MB of %d MB (%.0f%%)\n", source.UsedBytes/(1024*1024), source.QuotaBytes/(1024*1024), source.Percent())
+		}
+
+		state := "ok"
+		if source.Exceeded {
+			state = "over quota, downloads paused"
+		} else if source.Warning {
+			state = "near quota"
+		}
+		fmt.Printf("  State:      %s\n", state)
+		if len(source.HeldJobs) > 0 {
+			fmt.Printf("  Held jobs:  %s\n", strings.Join(source.HeldJobs, ", "))
+		}
+	}
+	return nil
+}
+
+// displayJobSummary shows detailed job summary
+func displayJobSummary(summary map[string]interface{}) {
+	fmt.Printf("Job %s:\n", summary["id"])
+	fmt.Printf("  Type: %s\n", summary["type"])
+	fmt.Printf("  Description: %s\n", summary["description"])
+	fmt.Printf("  State: %s\n", summary["state"])
+	fmt.Printf("  Progress: %.1f%%\n", summary["progress"])
+	fmt.Printf("  Message: %s\n", summary["message"])
+	fmt.Printf("  Duration: %s\n", summary["duration"])
+	fmt.Printf("  Active: %t\n", summary["active"])
+
+	if reason, exists := summary["wait_reason"]; exists {
+		fmt.Printf("  Waiting: %s\n", reason)
+	}
+
+	if rate, exists := summary["rate"]; exists {
+		fmt.Printf("  Rate: %.1f items/sec\n", rate)
+	}
+	if eta, exists := summary["eta"]; exists {
+		fmt.Printf("  ETA: %s\n", eta)
+	}
+	if _, exists := summary["stalled"]; exists {
+		fmt.Printf("  Warning: job is stalled (no recent progress)\n")
+	}
+
+	if endTime, exists := summary["end_time"]; exists {
+		fmt.Printf("  End Time: %s\n", endTime)
+	}
+
+	if url, exists := summary["object_url"]; exists {
+		fmt.Printf("  Uploaded: %s\n", url)
+	}
+
+	if hookError, exists := summary["hook_error"]; exists {
+		fmt.Printf("  Hook Failed: %s\n", hookError)
+	}
+
+	if errorMsg, exists := summary["error"]; exists {
+		fmt.Printf("  Error: %s\n", errorMsg)
+	}
+}
+
+// displayManagerStats shows job manager statistics
+func displayManagerStats(summary map[string]interface{}) {
+	fmt.Println("Job Manager Statistics:")
+	fmt.Printf("  Total Jobs: %v\n", summary["total_jobs"])
+	fmt.Printf("  Active Jobs: %v\n", summary["active_jobs"])
+	fmt.Printf("  Queued Jobs: %v\n", summary["queued_jobs"])
+	fmt.Printf("  Running Jobs: %v\n", summary["running_jobs"])
+	fmt.Printf("  Completed Jobs: %v\n", summary["completed_jobs"])
+	fmt.Printf("  Failed Jobs: %v\n", summary["failed_jobs"])
+	fmt.Printf("  Dead-letter Jobs: %v\n", summary["dead_letter_jobs"])
+
+	if workerStats, exists := summary["worker_stats"].(map[string]interface{}); exists {
+		fmt.Println("  Worker Pool:")
+		fmt.Printf("    Total Workers: %v\n", workerStats["total_workers"])
+		fmt.Printf("    Active Workers: %v\n", workerStats["active_workers"])
+		fmt.Printf("    Idle Workers: %v\n", workerStats["idle_workers"])
+		fmt.Printf("    Queue Size: %v\n", workerStats["queue_size"])
+	}
+}
+
+// contextJobManager returns the job manager of the context, or nil when the
+// shell runs without one
+func contextJobManager(ctx *ExecutionContext) *jobs.EnhancedJobManager {
+	jm, ok := ctx.JobManager.(*jobs.EnhancedJobManager)
+	if !ok || jm == nil {
+		return nil
+	}
+	return jm
+}
+
+// requireJobManager returns the job manager of the context, or an error when
+// the shell runs without one
+func requireJobManager(ctx *ExecutionContext) (*jobs.EnhancedJobManager, error) {
+	jm := contextJobManager(ctx)
+	if jm == nil {
+		return nil, fmt.Errorf("job manager not available")
+	}
+	return jm, nil
+}
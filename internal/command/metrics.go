@@ -0,0 +1,142 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/query"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// Metrics history defaults
+const (
+	DefaultMetricsRuns    = 20 // Latest runs listed
+	metricsErrorRateRuns  = 5  // Runs the error rate trend is averaged over
+	metricsSparklineWidth = 40
+)
+
+// MetricsHandler shows job performance metrics kept in the jobs database
+type MetricsHandler struct {
+	*BaseHandler
+}
+
+// NewMetricsHandler creates a new metrics handler
+func NewMetricsHandler() *MetricsHandler {
+	spec := &CommandSpec{
+		Name:        "metrics",
+		Description: "Show job performance over past runs",
+		Usage:       "metrics history [--source <name>] [--type <job type>] [--limit <runs>]",
+		Category:    "system",
+		MinArgs:     1,
+		MaxArgs:     1,
+		Flags: map[string]FlagSpec{
+			"source": {Type: "string", Description: "Only runs of jobs of this data source"},
+			"type":   {Type: "string", Description: "Only runs of jobs of this type"},
+			"limit":  {Type: "int", Description: "Latest runs to show", Default: DefaultMetricsRuns},
+		},
+		Examples: []string{
+			"metrics history --source hackernews",
+			"metrics history --type download --limit 50",
+		},
+	}
+
+	return &MetricsHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute handles metrics operations
+func (mh *MetricsHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	if cmd.Args[0] != "history" {
+		return fmt.Errorf("unknown metrics subcommand: %s", cmd.Args[0])
+	}
+
+	jm, err := requireJobManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	filter := jobs.MetricsFilter{Limit: DefaultMetricsRuns}
+	filter.DataSource, _ = cmd.Flags["source"].(string)
+	filter.JobType, _ = cmd.Flags["type"].(string)
+	if limit, ok := cmd.Flags["limit"].(int); ok {
+		if limit < 1 {
+			return fmt.Errorf("--limit must be at least 1")
+		}
+		filter.Limit = limit
+	}
+
+	runs, err := jm.RunMetrics(filter)
+	if err != nil {
+		return err
+	}
+	return WriteMetricsHistory(os.Stdout, filter, runs)
+}
+
+// GetArgumentCompletions completes the metrics subcommand
+func (mh *MetricsHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) == 0 {
+		return completeFrom([]string{"history"}, partial)
+	}
+	return []string{}
+}
+
+// WriteMetricsHistory writes recorded job runs with sparklines of their
+// throughput and error rate
+func WriteMetricsHistory(w io.Writer, filter jobs.MetricsFilter, runs []storage.JobMetrics) error {
+	subject := "all jobs"
+	switch {
+	case filter.DataSource != "" && filter.JobType != "":
+		subject = filter.DataSource + " " + filter.JobType + " jobs"
+	case filter.DataSource != "":
+		subject = filter.DataSource
+	case filter.JobType != "":
+		subject = filter.JobType + " jobs"
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(w, "No job runs recorded for %s\n", subject)
+		return nil
+	}
+
+	fmt.Fprintf(w, "Metrics history for %s (latest %d runs):\n", subject, len(runs))
+	fmt.Fprintf(w, "  %-19s  %-12s  %-11s  %9s  %10s  %9s\n", "STARTED", "TYPE", "STATUS", "DURATION", "ITEMS", "ITEMS/S")
+	rates := make([][]interface{}, len(runs))
+	for i, run := range runs {
+		fmt.Fprintf(w, "  %-19s  %-12s  %-11s  %9s  %10d  %9.1f\n",
+			run.StartTime.Local().Format("2006-01-02 15:04:05"), run.JobType, run.Status,
+			run.Duration.Round(time.Second), run.ProcessedItems, run.ItemsPerSecond)
+		rates[i] = []interface{}{run.ItemsPerSecond}
+	}
+
+	errorRates := jobs.RollingErrorRates(runs, metricsErrorRateRuns)
+	percentages := make([][]interface{}, len(errorRates))
+	for i, rate := range errorRates {
+		percentages[i] = []interface{}{rate * 100}
+	}
+
+	if len(runs) > 1 {
+		fmt.Fprintln(w)
+		for _, trend := range []struct {
+			column string
+			rows   [][]interface{}
+		}{
+			{"items/s", rates},
+			{"errors%", percentages},
+		} {
+			chart, err := query.RenderChart([]string{trend.column}, trend.rows, query.ChartSpec{Kind: query.ChartSparkline, Y: trend.column, Width: metricsSparklineWidth})
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(w, "  "+chart)
+		}
+		fmt.Fprintf(w, "  (errors%% is the share of failed runs among the last %d at each run)\n", metricsErrorRateRuns)
+	}
+
+	summary := jobs.SummarizeRuns(runs)
+	fmt.Fprintf(w, "\n%d of %d runs failed (%.1f%%); %d items at %.1f items/s on average\n",
+		summary.Failed, summary.Runs, summary.ErrorRate*100, summary.Items, summary.AverageItemsPerSecond)
+	return nil
+}
@@ -0,0 +1,125 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// Global flags accepted by every command and read by the built-in middleware
+const (
+	DryRunFlag = "dry-run"
+	YesFlag    = "yes"
+)
+
+// HandlerFunc executes a parsed command
+type HandlerFunc func(ctx *ExecutionContext, cmd *Command) error
+
+// Middleware wraps the execution of every command after parsing and
+// permission checks. It calls next to continue the chain, or returns without
+// calling it to stop the command.
+type Middleware func(ctx *ExecutionContext, cmd *Command, next HandlerFunc) error
+
+// chain wraps handler in middleware; the first middleware runs outermost
+func chain(middleware []Middleware, handler HandlerFunc) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		mw, next := middleware[i], handler
+		handler = func(ctx *ExecutionContext, cmd *Command) error {
+			return mw(ctx, cmd, next)
+		}
+	}
+	return handler
+}
+
+// TimingMiddleware logs how long each command took and prints the time of
+// commands that run for at least slow; a slow of 0 never prints
+func TimingMiddleware(slow time.Duration) Middleware {
+	return func(ctx *ExecutionContext, cmd *Command, next HandlerFunc) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		elapsed := time.Since(start)
+
+		log.ForComponent("command").WithField("command", cmd.Name).Debugf("Command finished in %s", elapsed)
+		if slow > 0 && elapsed >= slow {
+			fmt.Printf("(%s took %s)\n", cmd.Name, elapsed.Round(time.Millisecond))
+		}
+		return err
+	}
+}
+
+// AuditMiddleware records every command, its duration and outcome in history.
+// Failing to record is logged and does not fail the command.
+func AuditMiddleware(history *CommandHistory) Middleware {
+	return func(ctx *ExecutionContext, cmd *Command, next HandlerFunc) error {
+		entry := HistoryEntry{
+			Command:   cmd.Name,
+			Input:     cmd.RawInput,
+			StartedAt: time.Now(),
+			DryRun:    cmd.Flags[DryRunFlag] == true,
+		}
+		if ctx.Session != nil {
+			entry.SessionID = ctx.Session.ID
+		}
+
+		err := next(ctx, cmd)
+		entry.Duration = time.Since(entry.StartedAt)
+		if err != nil && !errors.Is(err, ErrExit) {
+			entry.Error = err.Error()
+		}
+		if recordErr := history.Record(entry); recordErr != nil {
+			log.ForComponent("command").Warnf("Failed to record command history: %v", recordErr)
+		}
+		return err
+	}
+}
+
+// DryRunMiddleware handles --dry-run. Commands whose spec supports dry runs
+// execute with ExecutionContext.DryRun set and report what they would do;
+// any other command is only described.
+func DryRunMiddleware() Middleware {
+	return func(ctx *ExecutionContext, cmd *Command, next HandlerFunc) error {
+		if cmd.Flags[DryRunFlag] != true {
+			return next(ctx, cmd)
+		}
+		if ctx.Spec != nil && ctx.Spec.DryRun {
+			ctx.DryRun = true
+			return next(ctx, cmd)
+		}
+		fmt.Printf("Dry run: would run %s\n", strings.TrimSpace(cmd.RawInput))
+		return nil
+	}
+}
+
+// ConfirmMiddleware asks before running destructive commands. --yes skips
+// the question; outside the interactive shell it is required.
+func ConfirmMiddleware() Middleware {
+	return func(ctx *ExecutionContext, cmd *Command, next HandlerFunc) error {
+		if ctx.DryRun || ctx.Spec == nil || !ctx.Spec.IsDestructive(cmd) || cmd.Flags[YesFlag] == true {
+			return next(ctx, cmd)
+		}
+		if ctx.Shell == nil {
+			return fmt.Errorf("%s cannot be undone; add --%s to confirm", describeCommand(cmd), YesFlag)
+		}
+
+		confirmed, err := ctx.Shell.Confirm(fmt.Sprintf("%s cannot be undone. Continue?", describeCommand(cmd)))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Cancelled")
+			return nil
+		}
+		return next(ctx, cmd)
+	}
+}
+
+// describeCommand names a command and its subcommand, e.g. "jobs cleanup"
+func describeCommand(cmd *Command) string {
+	if len(cmd.Args) > 0 {
+		return cmd.Name + " " + cmd.Args[0]
+	}
+	return cmd.Name
+}
@@ -0,0 +1,243 @@
+package command
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// recordingHandler counts executions and records whether they were dry runs
+type recordingHandler struct {
+	*BaseHandler
+	runs    int
+	dryRuns int
+}
+
+func (h *recordingHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	h.runs++
+	if ctx.DryRun {
+		h.dryRuns++
+	}
+	return nil
+}
+
+// confirmTestShell answers confirmation prompts with a fixed answer
+type confirmTestShell struct {
+	ShellServices
+	answer  bool
+	prompts []string
+}
+
+func (s *confirmTestShell) Confirm(prompt string) (bool, error) {
+	s.prompts = append(s.prompts, prompt)
+	return s.answer, nil
+}
+
+// newMiddlewareTestIntegration registers a "wipe" command whose "all"
+// subcommand is destructive, and a "plain" command without dry-run support
+func newMiddlewareTestIntegration(t *testing.T) (*ShellIntegration, *recordingHandler, *recordingHandler) {
+	t.Helper()
+	integration := NewShellIntegration()
+	wipe := &recordingHandler{BaseHandler: NewBaseHandler(&CommandSpec{
+		Name:        "wipe",
+		MaxArgs:     -1,
+		DryRun:      true,
+		Destructive: []string{"all"},
+	})}
+	plain := &recordingHandler{BaseHandler: NewBaseHandler(&CommandSpec{Name: "plain", MaxArgs: -1})}
+	for _, handler := range []Handler{wipe, plain} {
+		if err := integration.GetRegistry().Register(handler); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+	return integration, wipe, plain
+}
+
+func TestChain_Order(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(ctx *ExecutionContext, cmd *Command, next HandlerFunc) error {
+			calls = append(calls, name+" before")
+			err := next(ctx, cmd)
+			calls = append(calls, name+" after")
+			return err
+		}
+	}
+
+	handler := chain([]Middleware{record("outer"), record("inner")}, func(ctx *ExecutionContext, cmd *Command) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+	if err := handler(&ExecutionContext{}, &Command{Name: "test"}); err != nil {
+		t.Fatalf("handler() error = %v", err)
+	}
+
+	want := []string{"outer before", "inner before", "handler", "inner after", "outer after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestGlobalFlags(t *testing.T) {
+	integration, _, _ := newMiddlewareTestIntegration(t)
+	parser := integration.GetRegistry().parser
+
+	cmd, err := parser.Parse("wipe all --dry-run -y")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if cmd.Flags[DryRunFlag] != true || cmd.Flags[YesFlag] != true {
+		t.Errorf("Flags = %v, want dry-run and yes", cmd.Flags)
+	}
+	if err := parser.Validate(cmd); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	help, err := parser.GetCommandHelp("wipe")
+	if err != nil {
+		t.Fatalf("GetCommandHelp() error = %v", err)
+	}
+	if !strings.Contains(help, "Global flags:") || !strings.Contains(help, "--dry-run") {
+		t.Errorf("help is missing the global flags:\n%s", help)
+	}
+}
+
+func TestDryRunMiddleware(t *testing.T) {
+	integration, wipe, plain := newMiddlewareTestIntegration(t)
+	ctx := context.Background()
+
+	if err := integration.ProcessCommand(ctx, "wipe some --dry-run", nil, nil, nil, nil); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if wipe.runs != 1 || wipe.dryRuns != 1 {
+		t.Errorf("wipe runs = %d, dry runs = %d, want one dry run", wipe.runs, wipe.dryRuns)
+	}
+
+	// Commands without dry-run support are only described
+	if err := integration.ProcessCommand(ctx, "plain --dry-run", nil, nil, nil, nil); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if plain.runs != 0 {
+		t.Errorf("plain runs = %d, want 0", plain.runs)
+	}
+
+	// A dry run of a destructive command does not ask for confirmation
+	if err := integration.ProcessCommand(ctx, "wipe all --dry-run", nil, nil, nil, nil); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if wipe.dryRuns != 2 {
+		t.Errorf("wipe dry runs = %d, want 2", wipe.dryRuns)
+	}
+}
+
+func TestConfirmMiddleware(t *testing.T) {
+	integration, wipe, _ := newMiddlewareTestIntegration(t)
+	ctx := context.Background()
+
+	err := integration.ProcessCommand(ctx, "wipe all", nil, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "--yes") {
+		t.Errorf("ProcessCommand() error = %v, want a request for --yes", err)
+	}
+	if err := integration.ProcessCommand(ctx, "wipe all --yes", nil, nil, nil, nil); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if wipe.runs != 1 {
+		t.Errorf("wipe runs = %d, want 1 after --yes", wipe.runs)
+	}
+
+	shell := &confirmTestShell{answer: false}
+	if err := integration.ProcessCommand(ctx, "wipe all", nil, nil, nil, shell); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if len(shell.prompts) != 1 || !strings.Contains(shell.prompts[0], "wipe all") {
+		t.Errorf("prompts = %q, want one for wipe all", shell.prompts)
+	}
+	if wipe.runs != 1 {
+		t.Errorf("wipe runs = %d, want no run after a refusal", wipe.runs)
+	}
+
+	shell.answer = true
+	if err := integration.ProcessCommand(ctx, "wipe all", nil, nil, nil, shell); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if err := integration.ProcessCommand(ctx, "wipe some", nil, nil, nil, shell); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if wipe.runs != 3 || len(shell.prompts) != 2 {
+		t.Errorf("wipe runs = %d, prompts = %d, want 3 runs and 2 prompts", wipe.runs, len(shell.prompts))
+	}
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	log.InitLogger(false)
+	history, err := NewCommandHistory(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewCommandHistory() error = %v", err)
+	}
+	defer history.Close()
+
+	integration, _, _ := newMiddlewareTestIntegration(t)
+	integration.Use(TimingMiddleware(0), AuditMiddleware(history))
+	ctx := context.Background()
+
+	for _, input := range []string{"wipe some", "wipe all --dry-run"} {
+		if err := integration.ProcessCommand(ctx, input, nil, nil, nil, nil); err != nil {
+			t.Fatalf("ProcessCommand(%q) error = %v", input, err)
+		}
+	}
+	// Refused commands never reach the audit middleware
+	if err := integration.ProcessCommand(ctx, "wipe all", nil, nil, nil, nil); err == nil {
+		t.Fatal("expected wipe all to require --yes")
+	}
+
+	entries, err := history.Recent(10)
+	if err != nil {
+		t.Fatalf("Recent() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d history entries, want 2: %+v", len(entries), entries)
+	}
+	if entries[0].Input != "wipe all --dry-run" || !entries[0].DryRun {
+		t.Errorf("newest entry = %+v, want the dry run", entries[0])
+	}
+	if entries[1].Command != "wipe" || entries[1].DryRun || entries[1].SessionID != "default" {
+		t.Errorf("oldest entry = %+v", entries[1])
+	}
+}
+
+func TestCommandHistory_RecordError(t *testing.T) {
+	history, err := NewCommandHistory(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewCommandHistory() error = %v", err)
+	}
+	defer history.Close()
+
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entry := HistoryEntry{
+		SessionID: "s1",
+		Command:   "query",
+		Input:     "query hackernews bogus",
+		StartedAt: started,
+		Duration:  1500 * time.Millisecond,
+		Error:     "syntax error",
+	}
+	if err := history.Record(entry); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	entries, err := history.Recent(1)
+	if err != nil {
+		t.Fatalf("Recent() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	got := entries[0]
+	if got.Error != "syntax error" || got.Duration != entry.Duration || !got.StartedAt.Equal(started) {
+		t.Errorf("entry = %+v, want %+v", got, entry)
+	}
+}
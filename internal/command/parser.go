@@ -2,6 +2,7 @@ package command
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -36,20 +37,62 @@ type CommandSpec struct {
 	MaxArgs     int                 `json:"max_args"` // -1 for unlimited
 	Flags       map[string]FlagSpec `json:"flags"`
 	Examples    []string            `json:"examples"`
+	// DryRun marks commands that honour --dry-run themselves; others are
+	// only described when it is given
+	DryRun bool `json:"dry_run"`
+	// Destructive lists the subcommands (first argument) that change or
+	// delete data irreversibly and ask for confirmation
+	Destructive []string `json:"destructive"`
+	// PassFlags hands flags other than the global ones to the command as
+	// arguments, for commands such as plugins that parse their own
+	PassFlags bool `json:"pass_flags"`
+}
+
+// IsDestructive reports whether cmd runs a destructive subcommand of the spec
+func (s *CommandSpec) IsDestructive(cmd *Command) bool {
+	if len(cmd.Args) == 0 {
+		return false
+	}
+	for _, subcommand := range s.Destructive {
+		if subcommand == cmd.Args[0] {
+			return true
+		}
+	}
+	return false
 }
 
 // Parser handles command parsing with advanced features
 type Parser struct {
-	specs map[string]*CommandSpec
+	specs       map[string]*CommandSpec
+	globalFlags map[string]FlagSpec
 }
 
 // NewParser creates a new command parser
 func NewParser() *Parser {
 	return &Parser{
-		specs: make(map[string]*CommandSpec),
+		specs:       make(map[string]*CommandSpec),
+		globalFlags: make(map[string]FlagSpec),
 	}
 }
 
+// RegisterGlobalFlag registers a flag accepted by every command
+func (p *Parser) RegisterGlobalFlag(name string, flag FlagSpec) error {
+	if _, exists := p.globalFlags[name]; exists {
+		return fmt.Errorf("global flag --%s already registered", name)
+	}
+	p.globalFlags[name] = flag
+	return nil
+}
+
+// lookupFlag returns the spec of a command flag or global flag
+func (p *Parser) lookupFlag(spec *CommandSpec, name string) (FlagSpec, bool) {
+	if flag, exists := spec.Flags[name]; exists {
+		return flag, true
+	}
+	flag, exists := p.globalFlags[name]
+	return flag, exists
+}
+
 // RegisterCommand registers a command specification
 func (p *Parser) RegisterCommand(spec *CommandSpec) error {
 	if spec.Name == "" {
@@ -198,10 +241,23 @@ func (p *Parser) parseWithSpec(cmd *Command, tokens []Token, spec *CommandSpec)
 	i := 0
 	for i < len(tokens) {
 		token := tokens[i]
+		if spec.PassFlags && token.Type != "arg" && !p.isGlobalFlag(token.Value) {
+			cmd.Args = append(cmd.Args, token.Value)
+			i++
+			continue
+		}
 
 		switch token.Type {
 		case "long_flag":
 			flagName := strings.TrimPrefix(token.Value, "--")
+			if name, value, hasValue := strings.Cut(flagName, "="); hasValue {
+				// --flag=value form
+				if err := p.parseInlineFlag(cmd, name, value, spec); err != nil {
+					return cmd, err
+				}
+				i++
+				continue
+			}
 			consumed, err := p.parseFlag(cmd, flagName, tokens, i, spec, false)
 			if err != nil {
 				return cmd, err
@@ -226,7 +282,7 @@ func (p *Parser) parseWithSpec(cmd *Command, tokens []Token, spec *CommandSpec)
 					i += consumed
 				} else {
 					// Short flag in middle, must be boolean
-					flagSpec, exists := spec.Flags[flagName]
+					flagSpec, exists := p.lookupFlag(spec, flagName)
 					if !exists || flagSpec.Type != "bool" {
 						return cmd, fmt.Errorf("non-boolean flag -%c cannot be combined at position %d", char, token.Position+j+1)
 					}
@@ -263,7 +319,7 @@ func (p *Parser) parseWithSpec(cmd *Command, tokens []Token, spec *CommandSpec)
 
 // parseFlag parses a single flag and its value
 func (p *Parser) parseFlag(cmd *Command, flagName string, tokens []Token, index int, spec *CommandSpec, isShort bool) (int, error) {
-	flagSpec, exists := spec.Flags[flagName]
+	flagSpec, exists := p.lookupFlag(spec, flagName)
 	if !exists {
 		prefix := "--"
 		if isShort {
@@ -314,6 +370,54 @@ func (p *Parser) parseFlag(cmd *Command, flagName string, tokens []Token, index
 	return consumed, nil
 }
 
+// parseInlineFlag parses a flag given as --flag=value
+func (p *Parser) parseInlineFlag(cmd *Command, flagName, value string, spec *CommandSpec) error {
+	flagSpec, exists := p.lookupFlag(spec, flagName)
+	if !exists {
+		return fmt.Errorf("unknown flag: --%s", flagName)
+	}
+
+	switch flagSpec.Type {
+	case "bool":
+		val, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("flag --%s requires a boolean value, got: %s", flagName, value)
+		}
+		cmd.Flags[flagName] = val
+	case "string":
+		cmd.Flags[flagName] = value
+	case "int":
+		val, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("flag --%s requires an integer value, got: %s", flagName, value)
+		}
+		cmd.Flags[flagName] = val
+	case "float":
+		val, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("flag --%s requires a float value, got: %s", flagName, value)
+		}
+		cmd.Flags[flagName] = val
+	default:
+		return fmt.Errorf("unsupported flag type: %s", flagSpec.Type)
+	}
+	return nil
+}
+
+// isGlobalFlag reports whether a flag token names a global flag
+func (p *Parser) isGlobalFlag(value string) bool {
+	if name, ok := strings.CutPrefix(value, "--"); ok {
+		_, exists := p.globalFlags[name]
+		return exists
+	}
+	for _, flag := range p.globalFlags {
+		if flag.Short != "" && value == "-"+flag.Short {
+			return true
+		}
+	}
+	return false
+}
+
 // findShortFlag finds the flag name for a short flag character
 func (p *Parser) findShortFlag(char string, spec *CommandSpec) string {
 	for flagName, flagSpec := range spec.Flags {
@@ -321,6 +425,11 @@ func (p *Parser) findShortFlag(char string, spec *CommandSpec) string {
 			return flagName
 		}
 	}
+	for flagName, flagSpec := range p.globalFlags {
+		if flagSpec.Short == char {
+			return flagName
+		}
+	}
 	return ""
 }
 
@@ -370,7 +479,7 @@ func (p *Parser) Validate(cmd *Command) error {
 
 	// Validate flags
 	for flagName, value := range cmd.Flags {
-		flagSpec, exists := spec.Flags[flagName]
+		flagSpec, exists := p.lookupFlag(spec, flagName)
 		if !exists {
 			return fmt.Errorf("unknown flag: %s", flagName)
 		}
@@ -451,6 +560,23 @@ func (p *Parser) GetCommandHelp(commandName string) (string, error) {
 		}
 	}
 
+	if len(p.globalFlags) > 0 {
+		help.WriteString("\nGlobal flags:\n")
+		names := make([]string, 0, len(p.globalFlags))
+		for flagName := range p.globalFlags {
+			names = append(names, flagName)
+		}
+		sort.Strings(names)
+		for _, flagName := range names {
+			flagSpec := p.globalFlags[flagName]
+			shortFlag := ""
+			if flagSpec.Short != "" {
+				shortFlag = fmt.Sprintf(", -%s", flagSpec.Short)
+			}
+			help.WriteString(fmt.Sprintf("  --%s%s: %s\n", flagName, shortFlag, flagSpec.Description))
+		}
+	}
+
 	if len(spec.Examples) > 0 {
 		help.WriteString("\nExamples:\n")
 		for _, example := range spec.Examples {
@@ -120,6 +120,23 @@ func TestParser_Parse(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:  "command with inline flag values",
+			input: "test arg1 --count=10 --output=out.csv --verbose=false",
+			want: &Command{
+				Name:     "test",
+				Args:     []string{"arg1"},
+				Flags:    map[string]interface{}{"count": 10, "output": "out.csv", "verbose": false},
+				RawInput: "test arg1 --count=10 --output=out.csv --verbose=false",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "inline flag with invalid value",
+			input:   "test arg1 --count=many",
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name:    "too few arguments",
 			input:   "test",
@@ -170,6 +187,28 @@ func TestParser_Parse(t *testing.T) {
 	}
 }
 
+func TestParser_PassFlags(t *testing.T) {
+	parser := NewParser()
+	if err := parser.RegisterGlobalFlag(YesFlag, FlagSpec{Type: "bool", Short: "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := parser.RegisterCommand(&CommandSpec{Name: "trends", MaxArgs: -1, PassFlags: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := parser.Parse("trends --since=7d -n 10 --top authors -y")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	wantArgs := []string{"--since=7d", "-n", "10", "--top", "authors"}
+	if !reflect.DeepEqual(cmd.Args, wantArgs) {
+		t.Errorf("Args = %v, want %v", cmd.Args, wantArgs)
+	}
+	if cmd.Flags[YesFlag] != true {
+		t.Errorf("Expected the global --yes flag to be parsed, got %v", cmd.Flags)
+	}
+}
+
 func TestParser_Tokenize(t *testing.T) {
 	parser := NewParser()
 
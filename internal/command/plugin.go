@@ -0,0 +1,56 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/plugin"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// PluginHandler runs an external plugin as a shell command
+type PluginHandler struct {
+	*BaseHandler
+	plugin plugin.Plugin
+}
+
+// NewPluginHandler creates a handler for a plugin found by plugin.Discover
+func NewPluginHandler(p plugin.Plugin) *PluginHandler {
+	spec := &CommandSpec{
+		Name:        p.Name,
+		Description: fmt.Sprintf("Plugin %s", p.Path),
+		Usage:       p.Name + " [args...]",
+		Category:    "plugins",
+		MinArgs:     0,
+		MaxArgs:     -1,
+		PassFlags:   true,
+	}
+	return &PluginHandler{BaseHandler: NewBaseHandler(spec), plugin: p}
+}
+
+// Execute runs the plugin with the arguments of the command
+func (ph *PluginHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	pctx := plugin.Context{
+		Args:         cmd.Args,
+		Interface:    plugin.InterfaceShell,
+		ConfigDir:    config.Dir(),
+		StoragePath:  config.AppConfig.StoragePath,
+		ActiveSource: ActiveSource(ctx.Session),
+		ReadOnly:     storage.ReadOnly(),
+	}
+	for _, name := range sortedDataSourceNames(ctx) {
+		source := plugin.Source{Name: name}
+		if ds, ok := ctx.DataSources[name].(datasource.DataSource); ok {
+			source.StoragePath = ds.GetStoragePath()
+		}
+		pctx.DataSources = append(pctx.DataSources, source)
+	}
+	runCtx := ctx.Context
+	if runCtx == nil {
+		runCtx = context.Background()
+	}
+	return ph.plugin.Run(runCtx, pctx, os.Stdout, os.Stderr)
+}
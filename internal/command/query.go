@@ -0,0 +1,994 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/audit"
+	"github.com/brainless/PubDataHub/internal/clipboard"
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/query"
+	"golang.org/x/term"
+)
+
+// lastResultVariable is the session variable holding the last query result,
+// which .chart draws
+const lastResultVariable = "last_result"
+
+// lastResultQueryVariable is the session variable holding the query that
+// produced the last result, which .bookmark saves with it
+const lastResultQueryVariable = "last_result_query"
+
+// queryBufferVariable is the session variable holding the last query run or
+// edited, which .edit opens
+const queryBufferVariable = "query_buffer"
+
+// lastExecutedVariable is the session variable holding the last query that
+// ran, even when its result was too large to keep, which .export runs again
+// in the background
+const lastExecutedVariable = "last_executed"
+
+// queryBuffer is a query with the data source it runs against
+type queryBuffer struct {
+	Source string
+	SQL    string
+}
+
+// executedQuery is a query that ran, with whether it included the archives
+type executedQuery struct {
+	queryBuffer
+	Archives bool
+}
+
+// LastQuery returns the data source of the last query run or edited in a
+// session and how long the last successful query took; both are empty
+// before the first query
+func LastQuery(session *Session) (string, time.Duration) {
+	if session == nil {
+		return "", 0
+	}
+	buffer, _ := session.Variables[queryBufferVariable].(queryBuffer)
+	result, _ := session.Variables[lastResultVariable].(datasource.QueryResult)
+	return buffer.Source, result.Duration
+}
+
+// OutputSettings control how query results are displayed
+type OutputSettings struct {
+	Format     query.OutputFormat
+	PageSize   int // Rows shown in table output; 0 shows every row
+	ShowTiming bool
+	Values     query.ValueFormat          // Number and timestamp formatting of table output
+	Redactions map[string]query.Redaction // Rule sets --redact applies, by name
+}
+
+// DefaultOutputSettings returns the settings used when the shell has no
+// workspace settings
+func DefaultOutputSettings() OutputSettings {
+	return OutputSettings{
+		Format:     query.OutputFormatTable,
+		PageSize:   20,
+		ShowTiming: true,
+	}
+}
+
+// parseOutputFormat validates a format given on the command line
+func parseOutputFormat(format string) (query.OutputFormat, error) {
+	switch query.OutputFormat(strings.ToLower(format)) {
+	case query.OutputFormatTable, query.OutputFormatCSV, query.OutputFormatTSV, query.OutputFormatJSON, query.OutputFormatSQLite, query.OutputFormatXLSX:
+		return query.OutputFormat(strings.ToLower(format)), nil
+	}
+	return "", fmt.Errorf("invalid output format %q (supported: table, csv, tsv, json, sqlite, xlsx)", format)
+}
+
+// archiveQuerier is implemented by data sources whose queries can include
+// the items moved to their archives. Such queries run on a connection of
+// their own, without the session's scratch tables.
+type archiveQuerier interface {
+	QueryWithArchivesLimited(query string, limits datasource.ResultLimits) (datasource.QueryResult, error)
+}
+
+// resultTooLarge is the error of a query whose result exceeds the
+// configured limits, suggesting how to get it instead
+func resultTooLarge(limits datasource.ResultLimits) error {
+	return fmt.Errorf("the result is larger than %s (query.max_result_rows, query.max_result_mb); add LIMIT to the query, or export all of it in the background with .export csv results.csv",
+		limits)
+}
+
+// QueryHandler handles query commands
+type QueryHandler struct {
+	*BaseHandler
+}
+
+// NewQueryHandler creates a new query handler
+func NewQueryHandler() *QueryHandler {
+	spec := &CommandSpec{
+		Name:        "query",
+		Description: "Execute SQL query against a data source",
+		Usage:       "query [source] <sql> | query diff [source] <sql> --key <column>",
+		Category:    "data",
+		MinArgs:     1,
+		MaxArgs:     -1,
+		Flags: map[string]FlagSpec{
+			"format":           {Type: "string", Short: "f", Description: "Output format (table, csv, tsv, json, sqlite, xlsx); defaults to the workspace setting"},
+			"limit":            {Type: "int", Short: "l", Description: "Limit number of results"},
+			"output":           {Type: "string", Short: "o", Description: "Write results to a file (.csv, .tsv, .json, an .xlsx workbook or a .db SQLite database), or upload them to s3://bucket/key, instead of the screen"},
+			"sheet":            {Type: "string", Description: "Worksheet name of an .xlsx file (default Results)"},
+			"compress":         {Type: "string", Description: "Compress the --output file with gzip or zstd while writing it, adding .gz or .zst"},
+			"redact":           {Type: "string", Description: "Apply a redaction rule set of the workspace, see workspace redact, before the results are shown, saved or copied"},
+			"on-complete":      {Type: "string", Description: "Run a command with the --output path as its last argument once it is written"},
+			"webhook":          {Type: "string", Description: "POST the details of the --output file as JSON to a URL once it is written"},
+			"chart":            {Type: "string", Description: "Chart results (bar:x=col,y=col, spark:y=col, hist:x=col,bins=N)"},
+			"copy":             {Type: "bool", Description: "Copy results to the system clipboard"},
+			"include-archives": {Type: "bool", Description: "Also query the items moved to the source's yearly archives"},
+			"key":              {Type: "string", Short: "k", Description: "Diff: columns identifying rows, comma-separated"},
+			"snapshot":         {Type: "string", Description: "Diff: compare with a saved result, then save the new one"},
+			"keep":             {Type: "bool", Description: "Diff: keep the saved snapshot instead of replacing it"},
+			"period":           {Type: "string", Description: "Diff: compare the last period with the one before, e.g. 7d; the query uses {start} and {end}"},
+			"before":           {Type: "string", Description: "Diff: earlier time window, e.g. 2024-01-01..2024-01-08"},
+			"after":            {Type: "string", Description: "Diff: later time window, e.g. 2024-01-08..2024-01-15"},
+		},
+		Examples: []string{
+			"query hackernews \"SELECT title FROM items LIMIT 10\"",
+			"query hackernews \"SELECT * FROM items WHERE score > 100\" --format csv",
+			"query hackernews \"SELECT id, title FROM items\" --limit 100 --output stories.json",
+			"query hackernews \"SELECT * FROM items WHERE type='story' AND score > 500\" --output top.db",
+			"query hackernews \"SELECT by, COUNT(*) AS stories FROM items WHERE type='story' GROUP BY by\" --output authors.xlsx --sheet Authors",
+			"query hackernews \"SELECT * FROM items\" --output items.csv --compress zstd",
+			"query hackernews \"SELECT id, by, text FROM items\" --output share.csv --redact public",
+			"query hackernews \"SELECT * FROM items\" --output items.csv --on-complete ./load.sh --webhook https://ci.example.com/hooks/export",
+			"query hackernews \"SELECT epoch_to_date(time) AS day, COUNT(*) AS stories FROM items GROUP BY day\" --chart bar:x=day,y=stories",
+			"query diff hackernews \"SELECT id, title, score FROM items WHERE type='story' ORDER BY score DESC LIMIT 30\" --key id --snapshot top30",
+			"query diff hackernews \"SELECT by, COUNT(*) AS stories FROM items WHERE type='story' AND time >= {start} AND time < {end} GROUP BY by\" --key by --period 7d",
+			"query hackernews \"SELECT strftime('%Y', time, 'unixepoch') AS year, COUNT(*) FROM items GROUP BY year\" --include-archives",
+			"query \"SELECT COUNT(*) FROM items\"  (after 'use hackernews')",
+		},
+	}
+
+	return &QueryHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute runs the query and displays, saves, copies or charts its result
+func (qh *QueryHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	if cmd.Args[0] == "diff" && ctx.DataSources["diff"] == nil {
+		return qh.diff(ctx, cmd)
+	}
+
+	settings := DefaultOutputSettings()
+	if ctx.Shell != nil {
+		settings = ctx.Shell.OutputSettings()
+	}
+	if format, ok := cmd.Flags["format"].(string); ok {
+		parsed, err := parseOutputFormat(format)
+		if err != nil {
+			return err
+		}
+		settings.Format = parsed
+	}
+
+	compress, _ := cmd.Flags["compress"].(string)
+	compression, err := query.ParseCompression(compress)
+	if err != nil {
+		return err
+	}
+
+	var redaction *query.Redaction
+	if name, ok := cmd.Flags["redact"].(string); ok {
+		found, ok := settings.Redactions[name]
+		if !ok {
+			return fmt.Errorf("unknown redaction rule set %q; add rules with 'workspace redact set %s <column> <action>'", name, name)
+		}
+		redaction = &found
+	}
+
+	hooks, err := exportHooks(cmd)
+	if err != nil {
+		return err
+	}
+	if _, ok := cmd.Flags["output"].(string); !ok && len(hooks) > 0 {
+		return fmt.Errorf("--on-complete and --webhook run on the --output file, add --output")
+	}
+
+	var chartSpec *query.ChartSpec
+	if spec, ok := cmd.Flags["chart"].(string); ok {
+		parsed, err := query.ParseChartSpec(spec)
+		if err != nil {
+			return err
+		}
+		chartSpec = &parsed
+	}
+
+	sourceName, args, err := resolveSource(ctx, cmd.Args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", qh.spec.Usage)
+	}
+	ds, err := contextDataSource(ctx, sourceName)
+	if err != nil {
+		return err
+	}
+
+	sql := strings.Join(args, " ")
+	if ctx.Session != nil {
+		ctx.Session.Variables[queryBufferVariable] = queryBuffer{Source: sourceName, SQL: sql}
+	}
+	// Reading stops at --limit or the configured limits, so a large result
+	// never has to fit in memory whole
+	configured := config.AppConfig.Query.ResultLimits()
+	limits := configured
+	limit, _ := cmd.Flags["limit"].(int)
+	if limit > 0 && (limits.MaxRows <= 0 || limit < limits.MaxRows) {
+		limits.MaxRows = limit
+	}
+	run := func(sql string) (datasource.QueryResult, error) {
+		return sessionQuery(ctx, sourceName, ds, sql, limits)
+	}
+	if cmd.Flags["include-archives"] == true {
+		querier, ok := ds.(archiveQuerier)
+		if !ok {
+			return fmt.Errorf("%s has no archives to include", sourceName)
+		}
+		run = func(sql string) (datasource.QueryResult, error) {
+			return querier.QueryWithArchivesLimited(sql, limits)
+		}
+	}
+	result, err := audit.Query(audit.Local(audit.InterfaceShell), sourceName, sql, run)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", query.DiagnoseQueryError(sql, err, ds.GetSchema()))
+	}
+	if ctx.Session != nil {
+		ctx.Session.Variables[lastExecutedVariable] = executedQuery{
+			queryBuffer: queryBuffer{Source: sourceName, SQL: sql},
+			Archives:    cmd.Flags["include-archives"] == true,
+		}
+	}
+	if limit > 0 && len(result.Rows) >= limit {
+		result.Rows = result.Rows[:limit]
+		result.Count = limit
+		result.Truncated = false
+	}
+
+	// Only a table on the screen can show part of an oversized result;
+	// files, the clipboard and charts would silently miss rows
+	_, toFile := cmd.Flags["output"].(string)
+	if result.Truncated {
+		if toFile || cmd.Flags["copy"] == true || chartSpec != nil || settings.Format != query.OutputFormatTable {
+			return resultTooLarge(configured)
+		}
+	} else if ctx.Session != nil {
+		ctx.Session.Variables[lastResultVariable] = result
+		ctx.Session.Variables[lastResultQueryVariable] = queryBuffer{Source: sourceName, SQL: sql}
+	}
+	if redaction != nil {
+		result.Columns, result.Rows, err = redaction.Apply(result.Columns, result.Rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	if path, ok := cmd.Flags["output"].(string); ok {
+		opts := saveOptions(path, query.SaveOptions{Format: settings.Format, Compression: compression}, cmd.Flags["format"] != nil)
+		opts.Sheet, _ = cmd.Flags["sheet"].(string)
+		path, savings, err := saveQueryResult(path, opts, true, result)
+		if err != nil {
+			return err
+		}
+		if savings != "" {
+			fmt.Printf("Wrote %d rows to %s (%s)\n", len(result.Rows), path, savings)
+		} else {
+			fmt.Printf("Wrote %d rows to %s\n", len(result.Rows), path)
+		}
+		if err := runOutputHooks(ctx, hooks, sourceName, sql, opts, path, len(result.Rows)); err != nil {
+			return err
+		}
+	} else if err := writeQueryResult(os.Stdout, result, settings); err != nil {
+		return err
+	} else if ctx.Shell != nil && settings.Format == query.OutputFormatTable && !result.Truncated &&
+		settings.PageSize > 0 && len(result.Rows) > settings.PageSize {
+		fmt.Println("Use .results to page through all of them and sort by column")
+	}
+	if result.Truncated {
+		fmt.Printf("\nShowing the first %d rows: the result is larger than %s (query.max_result_rows, query.max_result_mb). Add LIMIT to the query, or export all of it in the background with .export csv results.csv\n",
+			len(result.Rows), configured)
+	}
+
+	if cmd.Flags["copy"] == true {
+		text, err := query.FormatDelimited(result.Columns, result.Rows, '\t')
+		if err != nil {
+			return err
+		}
+		if err := clipboard.Copy(text); err != nil {
+			return fmt.Errorf("failed to copy results: %w", err)
+		}
+		fmt.Printf("Copied %d rows to the clipboard\n", len(result.Rows))
+	}
+	if chartSpec != nil {
+		return printChart(result, *chartSpec)
+	}
+	return nil
+}
+
+// diff runs a query twice, against a saved snapshot or for two time windows,
+// and shows the rows added, removed and changed between the results
+func (qh *QueryHandler) diff(ctx *ExecutionContext, cmd *Command) error {
+	sourceName, args, err := resolveSource(ctx, cmd.Args[1:])
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: query diff [source] <sql> --key <column> (--snapshot <name> | --period <age> | --before <window> --after <window>)")
+	}
+	keyFlag, _ := cmd.Flags["key"].(string)
+	var key []string
+	for _, column := range strings.Split(keyFlag, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			key = append(key, column)
+		}
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("--key is required to match rows between results")
+	}
+
+	ds, err := contextDataSource(ctx, sourceName)
+	if err != nil {
+		return err
+	}
+	sql := strings.Join(args, " ")
+	limits := config.AppConfig.Query.ResultLimits()
+	run := func(sql string) (datasource.QueryResult, error) {
+		result, err := audit.Query(audit.Local(audit.InterfaceShell), sourceName, sql, func(sql string) (datasource.QueryResult, error) {
+			return sessionQuery(ctx, sourceName, ds, sql, limits)
+		})
+		if err != nil {
+			return result, fmt.Errorf("query failed: %w", query.DiagnoseQueryError(sql, err, ds.GetSchema()))
+		}
+		if result.Truncated {
+			return result, resultTooLarge(limits)
+		}
+		return result, nil
+	}
+
+	var before, after datasource.QueryResult
+	if name, ok := cmd.Flags["snapshot"].(string); ok {
+		path, err := contextStoragePath(ctx)
+		if err != nil {
+			return err
+		}
+		dir := query.ResultSnapshotDir(path)
+		snapshot, err := query.LoadResultSnapshot(dir, name)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		after, err = run(sql)
+		if err != nil {
+			return err
+		}
+		if snapshot == nil || cmd.Flags["keep"] != true {
+			if err := query.SaveResultSnapshot(dir, query.NewResultSnapshot(name, sourceName, sql, after)); err != nil {
+				return err
+			}
+		}
+		if snapshot == nil {
+			fmt.Printf("Saved snapshot %s with %d rows; run the diff again to see what changed\n", name, len(after.Rows))
+			return nil
+		}
+		if snapshot.Query != sql {
+			fmt.Printf("Note: snapshot %s was taken with a different query\n", name)
+		}
+		before = snapshot.Result()
+		fmt.Printf("Comparing with snapshot %s taken %s\n", name, snapshot.Taken.Format("2006-01-02 15:04"))
+	} else {
+		previous, current, err := diffWindows(cmd)
+		if err != nil {
+			return err
+		}
+		if !query.HasWindowPlaceholders(sql) {
+			return fmt.Errorf("the query must filter on {start} and {end} to compare time windows")
+		}
+		if before, err = run(previous.Apply(sql)); err != nil {
+			return err
+		}
+		if after, err = run(current.Apply(sql)); err != nil {
+			return err
+		}
+		fmt.Printf("Comparing %s with %s\n", previous, current)
+	}
+
+	diff, err := query.DiffResults(before, after, key)
+	if err != nil {
+		return err
+	}
+	fmt.Print(query.FormatDiff(diff))
+	return nil
+}
+
+// diffWindows returns the time windows given by --period or --before and
+// --after
+func diffWindows(cmd *Command) (query.TimeWindow, query.TimeWindow, error) {
+	if period, ok := cmd.Flags["period"].(string); ok {
+		age, err := jobs.ParseAge(period)
+		if err != nil || age == 0 {
+			return query.TimeWindow{}, query.TimeWindow{}, fmt.Errorf("invalid period %q", period)
+		}
+		previous, current := query.ConsecutiveWindows(age, time.Now())
+		return previous, current, nil
+	}
+
+	beforeFlag, hasBefore := cmd.Flags["before"].(string)
+	afterFlag, hasAfter := cmd.Flags["after"].(string)
+	if !hasBefore || !hasAfter {
+		return query.TimeWindow{}, query.TimeWindow{}, fmt.Errorf("query diff needs --snapshot, --period, or --before and --after")
+	}
+	previous, err := query.ParseTimeWindow(beforeFlag)
+	if err != nil {
+		return query.TimeWindow{}, query.TimeWindow{}, err
+	}
+	current, err := query.ParseTimeWindow(afterFlag)
+	if err != nil {
+		return query.TimeWindow{}, query.TimeWindow{}, err
+	}
+	return previous, current, nil
+}
+
+// GetArgumentCompletions provides data source completions
+func (qh *QueryHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) == 0 {
+		return append(completeDataSources(ctx, partial), completeFrom([]string{"diff"}, partial)...)
+	}
+	if len(args) == 1 && args[0] == "diff" {
+		return completeDataSources(ctx, partial)
+	}
+	return []string{}
+}
+
+// saveOptions completes the options of saving to path: the format follows
+// its extension unless given explicitly, and a .gz or .zst extension
+// compresses it
+func saveOptions(path string, opts query.SaveOptions, explicit bool) query.SaveOptions {
+	if !explicit {
+		opts.Format = query.FormatForPath(path)
+	}
+	if opts.Compression == query.CompressionNone {
+		opts.Compression = query.CompressionForPath(path)
+	}
+	return opts
+}
+
+// saveQueryResult writes a result to a file. Without an explicit format the
+// file extension chooses one, falling back to CSV; table output is saved as
+// tab-separated text. A .gz or .zst extension compresses the file, and
+// compression adds its extension to the path. It returns the path written and
+// the savings of compression.
+func saveQueryResult(path string, opts query.SaveOptions, explicit bool, result datasource.QueryResult) (string, string, error) {
+	opts = saveOptions(path, opts, explicit)
+	path = query.CompressedPath(path, opts.Compression)
+	savings, err := query.SaveResult(path, opts, result.Columns, result.Rows)
+	return path, savings, err
+}
+
+// exportHooks returns the hook given with --on-complete and --webhook, if
+// any, to run after the hooks of the config
+func exportHooks(cmd *Command) ([]query.ExportHook, error) {
+	var hook query.ExportHook
+	hook.Command, _ = cmd.Flags["on-complete"].(string)
+	hook.Webhook, _ = cmd.Flags["webhook"].(string)
+	if hook == (query.ExportHook{}) {
+		return nil, nil
+	}
+	if err := hook.Validate(); err != nil {
+		return nil, err
+	}
+	return []query.ExportHook{hook}, nil
+}
+
+// runOutputHooks runs the hooks of a query written with --output. The file
+// is already written, so failing hooks are reported rather than undoing it.
+func runOutputHooks(ctx *ExecutionContext, hooks []query.ExportHook, sourceName, sql string, opts query.SaveOptions, path string, rows int) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	event := query.ExportEvent{
+		DataSource:  sourceName,
+		Query:       sql,
+		Format:      string(opts.Format),
+		Compression: string(opts.Compression),
+		Output:      path,
+		Rows:        int64(rows),
+		CompletedAt: time.Now(),
+	}
+	if info, err := os.Stat(path); err == nil {
+		event.Bytes = info.Size()
+	}
+	runCtx := ctx.Context
+	if runCtx == nil {
+		runCtx = context.Background()
+	}
+	if err := query.RunExportHooks(runCtx, hooks, event); err != nil {
+		return fmt.Errorf("wrote %s, but its hooks failed: %w", path, err)
+	}
+	return nil
+}
+
+// writeQueryResult writes a result in the output format of settings, limiting
+// table output to the page size and adding the query time if enabled
+func writeQueryResult(w io.Writer, result datasource.QueryResult, settings OutputSettings) error {
+	switch settings.Format {
+	case query.OutputFormatCSV, query.OutputFormatTSV:
+		if err := query.WriteDelimited(w, result.Columns, result.Rows, query.Delimiter(string(settings.Format))); err != nil {
+			return fmt.Errorf("failed to write results: %w", err)
+		}
+	case query.OutputFormatJSON:
+		if err := query.WriteJSON(w, result.Columns, result.Rows); err != nil {
+			return err
+		}
+	case query.OutputFormatSQLite:
+		return fmt.Errorf("sqlite output needs a file, use --output results.db")
+	case query.OutputFormatXLSX:
+		return fmt.Errorf("xlsx output needs a file, use --output results.xlsx")
+	default:
+		// Column kinds come from the stored values, before numbers and
+		// timestamps are formatted as text
+		kinds := query.InferColumnKinds(result.Columns, result.Rows)
+		result.Rows = settings.Values.FormatRows(result.Columns, result.Rows, time.Now())
+		if err := writeResultTable(w, result, kinds, settings.PageSize); err != nil {
+			return fmt.Errorf("failed to write results: %w", err)
+		}
+	}
+
+	if settings.ShowTiming {
+		fmt.Fprintf(w, "\nQuery completed in %v (%d rows)\n", result.Duration, result.Count)
+	}
+	return nil
+}
+
+// writeResultTable writes results as an aligned table, showing at most
+// pageSize rows (all rows when pageSize is 0)
+func writeResultTable(w io.Writer, result datasource.QueryResult, kinds []query.ColumnKind, pageSize int) error {
+	if len(result.Rows) == 0 {
+		fmt.Fprintln(w, "No results found")
+		return nil
+	}
+
+	limit := len(result.Rows)
+	if pageSize > 0 && limit > pageSize {
+		limit = pageSize
+	}
+	if err := query.WriteTableWidth(w, result.Columns, result.Rows[:limit], kinds, query.DefaultCellWidth, terminalWidth(w)); err != nil {
+		return err
+	}
+
+	if len(result.Rows) > limit {
+		fmt.Fprintf(w, "... and %d more rows\n", len(result.Rows)-limit)
+	}
+	return nil
+}
+
+// terminalWidth returns the current width of w when it is a terminal, read
+// on every table so output follows resizes, or 0 otherwise
+func terminalWidth(w io.Writer) int {
+	file, ok := w.(*os.File)
+	if !ok || !term.IsTerminal(int(file.Fd())) {
+		return 0
+	}
+	width, _, err := term.GetSize(int(file.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// printChart renders a query result as a terminal chart
+func printChart(result datasource.QueryResult, spec query.ChartSpec) error {
+	chart, err := query.RenderChart(result.Columns, result.Rows, spec)
+	if err != nil {
+		return fmt.Errorf("failed to render chart: %w", err)
+	}
+	fmt.Println()
+	fmt.Print(chart)
+	return nil
+}
+
+// ChartHandler charts the result of the last query
+type ChartHandler struct {
+	*BaseHandler
+}
+
+// NewChartHandler creates a new chart handler
+func NewChartHandler() *ChartHandler {
+	spec := &CommandSpec{
+		Name:        ".chart",
+		Description: "Chart the last query result as bars, a sparkline or a histogram",
+		Usage:       ".chart <bar:x=col,y=col|spark:y=col|hist:x=col[,bins=N]>[,width=N]",
+		Category:    "data",
+		MinArgs:     1,
+		MaxArgs:     -1,
+		Examples: []string{
+			".chart bar:x=day,y=stories",
+			".chart spark:y=score",
+			".chart hist:x=score,bins=20",
+		},
+	}
+
+	return &ChartHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute renders the chart
+func (ch *ChartHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	var result datasource.QueryResult
+	var ok bool
+	if ctx.Session != nil {
+		result, ok = ctx.Session.Variables[lastResultVariable].(datasource.QueryResult)
+	}
+	if !ok {
+		return fmt.Errorf("no query result to chart, run a query first")
+	}
+
+	spec, err := query.ParseChartSpec(strings.Join(cmd.Args, ""))
+	if err != nil {
+		return err
+	}
+	return printChart(result, spec)
+}
+
+// GetArgumentCompletions completes chart types
+func (ch *ChartHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	return completeFrom([]string{"bar:", "spark:", "hist:"}, partial)
+}
+
+// ResultsHandler pages through the last query result
+type ResultsHandler struct {
+	*BaseHandler
+}
+
+// NewResultsHandler creates a new results handler
+func NewResultsHandler() *ResultsHandler {
+	spec := &CommandSpec{
+		Name:        ".results",
+		Description: "Page through every row of the last query result, sorting by clicked columns",
+		Usage:       ".results",
+		Category:    "data",
+		MinArgs:     0,
+		MaxArgs:     0,
+		Examples: []string{
+			".results",
+		},
+	}
+
+	return &ResultsHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute opens the result view of the last query result
+func (rh *ResultsHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	if ctx.Shell == nil {
+		return fmt.Errorf(".results is only available in the interactive shell")
+	}
+	var result datasource.QueryResult
+	var ok bool
+	if ctx.Session != nil {
+		result, ok = ctx.Session.Variables[lastResultVariable].(datasource.QueryResult)
+	}
+	if !ok {
+		return fmt.Errorf("no query result to show, run a query first")
+	}
+
+	title := "Last result"
+	if last, ok := ctx.Session.Variables[lastResultQueryVariable].(queryBuffer); ok {
+		title = fmt.Sprintf("Result of %s: %s", last.Source, strings.Join(strings.Fields(last.SQL), " "))
+	}
+	return ctx.Shell.BrowseResult(title, result, ctx.Shell.OutputSettings().Values)
+}
+
+// ExportHandler exports the full result of the last query in the background
+type ExportHandler struct {
+	*BaseHandler
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler() *ExportHandler {
+	spec := &CommandSpec{
+		Name:        ".export",
+		Description: "Export the full result of the last query as a background job",
+		Usage:       ".export <csv|tsv|json|sqlite|xlsx> <file>",
+		Category:    "data",
+		MinArgs:     2,
+		MaxArgs:     2,
+		Flags: map[string]FlagSpec{
+			"compress":    {Type: "string", Description: "Compress the file with gzip or zstd while writing it, adding .gz or .zst"},
+			"redact":      {Type: "string", Description: "Apply a redaction rule set of the workspace, see workspace redact, before the results are written"},
+			"on-complete": {Type: "string", Description: "Run a command with the output path as its last argument once the export completes"},
+			"webhook":     {Type: "string", Description: "POST the details of the completed export as JSON to a URL"},
+		},
+		Examples: []string{
+			".export csv items.csv",
+			".export json top.json --compress gzip",
+			".export xlsx s3://exports/items.xlsx",
+			".export csv items.csv --on-complete ./load.sh --webhook https://ci.example.com/hooks/export",
+		},
+	}
+
+	return &ExportHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute submits an export job running the last query again, on a
+// connection of the data source without the session's scratch tables and
+// without the result limits
+func (eh *ExportHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	var last executedQuery
+	var ok bool
+	if ctx.Session != nil {
+		last, ok = ctx.Session.Variables[lastExecutedVariable].(executedQuery)
+	}
+	if !ok {
+		return fmt.Errorf("no query to export, run a query first")
+	}
+
+	format, err := parseOutputFormat(cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	if format == query.OutputFormatTable {
+		return fmt.Errorf("table is not an export format (supported: csv, tsv, json, sqlite, xlsx)")
+	}
+	compress, _ := cmd.Flags["compress"].(string)
+	compression, err := query.ParseCompression(compress)
+	if err != nil {
+		return err
+	}
+	hooks, err := exportHooks(cmd)
+	if err != nil {
+		return err
+	}
+	var redaction *query.Redaction
+	if name, ok := cmd.Flags["redact"].(string); ok {
+		settings := DefaultOutputSettings()
+		if ctx.Shell != nil {
+			settings = ctx.Shell.OutputSettings()
+		}
+		found, ok := settings.Redactions[name]
+		if !ok {
+			return fmt.Errorf("unknown redaction rule set %q; add rules with 'workspace redact set %s <column> <action>'", name, name)
+		}
+		redaction = &found
+	}
+
+	jm, err := requireJobManager(ctx)
+	if err != nil {
+		return err
+	}
+	ds, err := contextDataSource(ctx, last.Source)
+	if err != nil {
+		return err
+	}
+	run := ds.Query
+	if last.Archives {
+		querier, ok := ds.(archiveQuerier)
+		if !ok {
+			return fmt.Errorf("%s has no archives to include", last.Source)
+		}
+		run = func(sql string) (datasource.QueryResult, error) {
+			return querier.QueryWithArchivesLimited(sql, datasource.ResultLimits{})
+		}
+	}
+
+	job := query.NewExportJob(last.Source, last.SQL, format, compression, redaction, cmd.Args[1], hooks...)
+	job.SetQueryRunner(func(sql string) (datasource.QueryResult, error) {
+		return audit.Query(audit.Local(audit.InterfaceShell), last.Source, sql, run)
+	})
+	jobID, err := jm.SubmitJob(job)
+	if err != nil {
+		return fmt.Errorf("failed to submit export job: %w", err)
+	}
+	fmt.Printf("Started export job %s writing the last %s query to %s\n", jobID, last.Source, job.Metadata()["output_file"])
+	fmt.Printf("The status bar shows when it completes; see 'jobs status %s' for details\n", jobID)
+	return nil
+}
+
+// GetArgumentCompletions completes export formats
+func (eh *ExportHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	return completeFrom([]string{"csv", "tsv", "json", "sqlite", "xlsx"}, partial)
+}
+
+// BrowseHandler opens the interactive schema browser
+type BrowseHandler struct {
+	*BaseHandler
+}
+
+// NewBrowseHandler creates a new browse handler
+func NewBrowseHandler() *BrowseHandler {
+	spec := &CommandSpec{
+		Name:        ".browse",
+		Description: "Browse tables, columns, indexes and sample rows interactively",
+		Usage:       ".browse [source]",
+		Category:    "data",
+		MinArgs:     0,
+		MaxArgs:     1,
+		Examples: []string{
+			".browse",
+			".browse hackernews",
+		},
+	}
+
+	return &BrowseHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute opens the schema browser of the named or first data source
+func (bh *BrowseHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	if ctx.Shell == nil {
+		return fmt.Errorf(".browse is only available in the interactive shell")
+	}
+
+	sourceName := defaultSourceName(ctx)
+	if len(cmd.Args) > 0 {
+		sourceName = cmd.Args[0]
+	}
+	if _, err := contextDataSource(ctx, sourceName); err != nil {
+		return err
+	}
+	return ctx.Shell.BrowseSchema(sourceName)
+}
+
+// GetArgumentCompletions completes data source names
+func (bh *BrowseHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	return completeDataSources(ctx, partial)
+}
+
+// TablesHandler lists the tables of a data source
+type TablesHandler struct {
+	*BaseHandler
+}
+
+// NewTablesHandler creates a new tables handler
+func NewTablesHandler() *TablesHandler {
+	spec := &CommandSpec{
+		Name:        ".tables",
+		Description: "List the tables and columns of a data source",
+		Usage:       ".tables [source]",
+		Category:    "data",
+		MinArgs:     0,
+		MaxArgs:     1,
+		Examples: []string{
+			".tables",
+			".tables hackernews",
+		},
+	}
+
+	return &TablesHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute prints each table with its columns
+func (th *TablesHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	sourceName := defaultSourceName(ctx)
+	if len(cmd.Args) > 0 {
+		sourceName = cmd.Args[0]
+	}
+	ds, err := contextDataSource(ctx, sourceName)
+	if err != nil {
+		return err
+	}
+
+	tables := ds.GetSchema().Tables
+	if len(tables) == 0 {
+		fmt.Printf("%s has no tables\n", sourceName)
+		return nil
+	}
+	for _, table := range tables {
+		columns := make([]string, len(table.Columns))
+		for i, column := range table.Columns {
+			columns[i] = column.Name
+		}
+		fmt.Printf("%-20s %s\n", table.Name, strings.Join(columns, ", "))
+	}
+	return nil
+}
+
+// GetArgumentCompletions completes data source names
+func (th *TablesHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	return completeDataSources(ctx, partial)
+}
+
+// EditHandler composes a query in the external editor and runs it
+type EditHandler struct {
+	*BaseHandler
+}
+
+// NewEditHandler creates a new edit handler
+func NewEditHandler() *EditHandler {
+	spec := &CommandSpec{
+		Name:        ".edit",
+		Description: "Edit the last query in $EDITOR and run it",
+		Usage:       ".edit [source]",
+		Category:    "data",
+		MinArgs:     0,
+		MaxArgs:     1,
+		Examples: []string{
+			".edit",
+			".edit hackernews",
+		},
+	}
+
+	return &EditHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute opens the query buffer in the editor and runs the saved query. The
+// buffer keeps the edited query, so a failed query can be fixed with .edit.
+func (eh *EditHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	if ctx.Shell == nil {
+		return fmt.Errorf(".edit is only available in the interactive shell")
+	}
+
+	var buffer queryBuffer
+	if ctx.Session != nil {
+		buffer, _ = ctx.Session.Variables[queryBufferVariable].(queryBuffer)
+	}
+	if len(cmd.Args) > 0 {
+		buffer.Source = cmd.Args[0]
+	} else if buffer.Source == "" {
+		buffer.Source = defaultSourceName(ctx)
+	}
+	if _, err := contextDataSource(ctx, buffer.Source); err != nil {
+		return err
+	}
+
+	edited, err := ctx.Shell.EditQuery(buffer.SQL)
+	if err != nil {
+		return err
+	}
+	buffer.SQL = strings.TrimSpace(edited)
+	if ctx.Session != nil {
+		ctx.Session.Variables[queryBufferVariable] = buffer
+	}
+	if buffer.SQL == "" {
+		fmt.Println("Empty query, nothing to run")
+		return nil
+	}
+
+	fmt.Println(buffer.SQL)
+	return NewQueryHandler().Execute(ctx, &Command{
+		Name:  "query",
+		Args:  []string{buffer.Source, buffer.SQL},
+		Flags: map[string]interface{}{},
+	})
+}
+
+// GetArgumentCompletions completes data source names
+func (eh *EditHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	return completeDataSources(ctx, partial)
+}
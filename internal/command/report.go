@@ -0,0 +1,298 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/httpclient"
+	"github.com/brainless/PubDataHub/internal/report"
+)
+
+// ReportHandler manages reports generated from templates on a schedule
+type ReportHandler struct {
+	*BaseHandler
+}
+
+// NewReportHandler creates a new report handler
+func NewReportHandler() *ReportHandler {
+	spec := &CommandSpec{
+		Name:        "report",
+		Description: "Generate reports from templates with query placeholders, on demand or on a schedule",
+		Usage:       "report [list|create|show|run|delete] [name]",
+		Category:    "data",
+		MinArgs:     0,
+		MaxArgs:     2,
+		Flags: map[string]FlagSpec{
+			"source":        {Type: "string", Short: "s", Description: "Data source the template queries (default: hackernews)"},
+			"template":      {Type: "string", Short: "t", Description: "Template text"},
+			"template-file": {Type: "string", Description: "File to read the template from, e.g. a Markdown file"},
+			"output":        {Type: "string", Short: "o", Description: "File to write the report to; {name}, {date} and {time} are replaced"},
+			"webhook":       {Type: "string", Description: "URL to post the report to as JSON"},
+			"schedule":      {Type: "string", Description: "Cron expression for generating the report"},
+			"description":   {Type: "string", Description: "Description of the report"},
+		},
+		Destructive: []string{"delete"},
+		Examples: []string{
+			"report list",
+			"report create weekly-digest --template-file digest.md --output reports/digest-{date}.md --schedule \"0 8 * * 1\"",
+			"report create top-today --template \"{{table \\\"SELECT title, score FROM items ORDER BY score DESC LIMIT 5\\\"}}\" --webhook https://hooks.example.com/abc",
+			"report show weekly-digest",
+			"report run weekly-digest",
+			"report delete weekly-digest",
+		},
+	}
+
+	return &ReportHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute runs a report subcommand; without one it lists the reports
+func (rh *ReportHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	store, err := reportStore(ctx)
+	if err != nil {
+		return err
+	}
+
+	args := cmd.Args
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	if args[0] != "list" && len(args) < 2 {
+		return fmt.Errorf("usage: report %s <name>", args[0])
+	}
+
+	switch args[0] {
+	case "list":
+		return listReports(store)
+	case "create":
+		return rh.create(ctx, cmd, store, args[1])
+	case "show":
+		r, err := store.Get(args[1])
+		if err != nil {
+			return err
+		}
+		displayReport(r)
+		return nil
+	case "run":
+		return runReport(ctx, store, args[1])
+	case "delete":
+		if err := store.Delete(args[1]); err != nil {
+			return err
+		}
+		if jm := contextJobManager(ctx); jm != nil {
+			jm.UnscheduleReport(args[1])
+		}
+		fmt.Printf("Deleted report %s\n", args[1])
+		return nil
+	default:
+		return fmt.Errorf("unknown report subcommand: %s", args[0])
+	}
+}
+
+// create defines a report, or replaces the report with the same name
+func (rh *ReportHandler) create(ctx *ExecutionContext, cmd *Command, store *report.Store, name string) error {
+	r := &report.Report{Name: name, Source: "hackernews"}
+	if existing, err := store.Get(name); err == nil {
+		r = existing
+	}
+
+	if source, ok := cmd.Flags["source"].(string); ok {
+		if _, err := contextDataSource(ctx, source); err != nil {
+			return err
+		}
+		r.Source = source
+	}
+	if text, ok := cmd.Flags["template"].(string); ok {
+		r.Template = text
+	}
+	if path, ok := cmd.Flags["template-file"].(string); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read template: %w", err)
+		}
+		r.Template = string(data)
+	}
+	if output, ok := cmd.Flags["output"].(string); ok {
+		r.Output = output
+	}
+	if webhook, ok := cmd.Flags["webhook"].(string); ok {
+		r.Webhook = webhook
+	}
+	if schedule, ok := cmd.Flags["schedule"].(string); ok {
+		r.Schedule = schedule
+	}
+	if description, ok := cmd.Flags["description"].(string); ok {
+		r.Description = description
+	}
+
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	jm := contextJobManager(ctx)
+	if r.Schedule != "" && jm == nil {
+		return fmt.Errorf("scheduling reports requires the job manager")
+	}
+	if jm != nil {
+		// Schedule first so an invalid cron expression is not saved
+		if err := jm.ScheduleReport(r); err != nil {
+			return err
+		}
+	}
+	if err := store.Save(r); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved report %s\n", r.Name)
+	if r.Schedule != "" {
+		fmt.Printf("Scheduled: %s\n", r.Schedule)
+	}
+	return nil
+}
+
+// runReport generates a report in a job, or directly when no job manager is
+// available
+func runReport(ctx *ExecutionContext, store *report.Store, name string) error {
+	if jm := contextJobManager(ctx); jm != nil {
+		jobID, err := jm.RunReport(name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Report job %s started\n", jobID)
+		return nil
+	}
+
+	sources := make(map[string]datasource.DataSource, len(ctx.DataSources))
+	for name, ds := range ctx.DataSources {
+		if typed, ok := ds.(datasource.DataSource); ok {
+			sources[name] = typed
+		}
+	}
+	clientConfig := httpclient.Defaults()
+	clientConfig.CacheEnabled = false
+	client, err := httpclient.New(clientConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	r, err := report.Run(ctx.Context, store, name, sources, client)
+	if err != nil {
+		return err
+	}
+	printReportDelivery(r)
+	return nil
+}
+
+// printReportDelivery reports where a generated report went
+func printReportDelivery(r *report.Report) {
+	if r.LastOutput != "" {
+		fmt.Printf("Wrote report %s to %s\n", r.Name, r.LastOutput)
+	}
+	if r.Webhook != "" {
+		fmt.Printf("Posted report %s to its webhook\n", r.Name)
+	}
+}
+
+// listReports prints the defined reports
+func listReports(store *report.Store) error {
+	reports, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(reports) == 0 {
+		fmt.Println("No reports defined")
+		return nil
+	}
+
+	fmt.Printf("%-20s %-12s %-16s %-17s %s\n", "NAME", "SOURCE", "SCHEDULE", "LAST RUN", "DELIVERY")
+	fmt.Println(strings.Repeat("-", 85))
+	for _, r := range reports {
+		schedule := r.Schedule
+		if schedule == "" {
+			schedule = "manual"
+		}
+		lastRun := "never"
+		if r.LastRun != nil {
+			lastRun = r.LastRun.Format("2006-01-02 15:04")
+			if r.LastError != "" {
+				lastRun += " (failed)"
+			}
+		}
+		fmt.Printf("%-20s %-12s %-16s %-17s %s\n", r.Name, r.Source, schedule, lastRun, reportDelivery(r))
+	}
+	return nil
+}
+
+// displayReport prints a report definition and its last run
+func displayReport(r *report.Report) {
+	fmt.Printf("Report: %s\n", r.Name)
+	if r.Description != "" {
+		fmt.Printf("Description: %s\n", r.Description)
+	}
+	fmt.Printf("Source: %s\n", r.Source)
+	if r.Schedule != "" {
+		fmt.Printf("Schedule: %s\n", r.Schedule)
+	}
+	fmt.Printf("Delivery: %s\n", reportDelivery(r))
+	if r.LastRun != nil {
+		fmt.Printf("Last run: %s\n", r.LastRun.Format(time.RFC3339))
+		if r.LastOutput != "" {
+			fmt.Printf("Last output: %s\n", r.LastOutput)
+		}
+		if r.LastError != "" {
+			fmt.Printf("Last error: %s\n", r.LastError)
+		}
+	}
+	fmt.Printf("\nTemplate:\n%s\n", r.Template)
+}
+
+// reportDelivery describes where a report is delivered
+func reportDelivery(r *report.Report) string {
+	var targets []string
+	if r.Output != "" {
+		targets = append(targets, r.Output)
+	}
+	if r.Webhook != "" {
+		targets = append(targets, "webhook")
+	}
+	return strings.Join(targets, ", ")
+}
+
+// reportStore returns the report store of the job manager, or of the
+// configured storage path when the shell runs without one
+func reportStore(ctx *ExecutionContext) (*report.Store, error) {
+	if jm := contextJobManager(ctx); jm != nil {
+		return jm.Reports(), nil
+	}
+	path, err := contextStoragePath(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return report.NewStore(report.Dir(path)), nil
+}
+
+// GetArgumentCompletions completes subcommands and report names
+func (rh *ReportHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	switch len(args) {
+	case 0:
+		return completeFrom([]string{"list", "create", "show", "run", "delete"}, partial)
+	case 1:
+		store, err := reportStore(ctx)
+		if err != nil {
+			return []string{}
+		}
+		reports, err := store.List()
+		if err != nil {
+			return []string{}
+		}
+		names := make([]string, len(reports))
+		for i, r := range reports {
+			names[i] = r.Name
+		}
+		return completeFrom(names, partial)
+	}
+	return []string{}
+}
@@ -0,0 +1,161 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/jobs"
+)
+
+// ScheduleHandler lists scheduled jobs and their run history
+type ScheduleHandler struct {
+	*BaseHandler
+}
+
+// NewScheduleHandler creates a new schedule handler
+func NewScheduleHandler() *ScheduleHandler {
+	spec := &CommandSpec{
+		Name:        "schedule",
+		Description: "List scheduled jobs with their catch-up and concurrency policies and missed runs, or show one job's run history",
+		Usage:       "schedule [list|history] [id]",
+		Category:    "system",
+		MinArgs:     0,
+		MaxArgs:     2,
+		Flags: map[string]FlagSpec{
+			"limit": {Type: "int", Short: "n", Description: "History entries to show", Default: 20},
+		},
+		Examples: []string{
+			"schedule list",
+			"schedule history sync-hackernews",
+			"schedule history sync-hackernews --limit 50",
+		},
+	}
+
+	return &ScheduleHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute runs a schedule subcommand; without one it lists the schedules
+func (sh *ScheduleHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	jm, err := requireJobManager(ctx)
+	if err != nil {
+		return err
+	}
+	scheduler := jm.Scheduler()
+
+	args := cmd.Args
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		return listSchedules(scheduler)
+	case "history":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: schedule history <id>")
+		}
+		limit := 20
+		if value, ok := cmd.Flags["limit"].(int); ok && value > 0 {
+			limit = value
+		}
+		return showScheduleHistory(scheduler, args[1], limit)
+	default:
+		return fmt.Errorf("unknown schedule subcommand: %s", args[0])
+	}
+}
+
+// listSchedules prints every scheduled job with the runs it missed
+func listSchedules(scheduler *jobs.JobScheduler) error {
+	scheduled := scheduler.ListScheduledJobs()
+	if len(scheduled) == 0 {
+		fmt.Println("No scheduled jobs")
+		return nil
+	}
+
+	fmt.Printf("%-24s %-20s %-9s %-11s %-17s %-17s %s\n", "ID", "SCHEDULE", "CATCH-UP", "CONCURRENCY", "NEXT RUN", "LAST RUN", "MISSED")
+	fmt.Println(strings.Repeat("-", 112))
+	for _, job := range scheduled {
+		history, err := scheduler.History(job.ID, 0)
+		if err != nil {
+			return err
+		}
+		missed := 0
+		lastRun := "never"
+		for _, run := range history {
+			switch run.Outcome {
+			case jobs.ScheduleMissed:
+				missed += run.Missed
+			case jobs.ScheduleRan, jobs.ScheduleCaughtUp:
+				if lastRun == "never" {
+					lastRun = run.ScheduledFor.Local().Format("2006-01-02 15:04")
+				}
+			}
+		}
+
+		nextRun := "disabled"
+		if job.Enabled {
+			nextRun = job.NextRun.Format("2006-01-02 15:04")
+		}
+		catchUp := string(job.CatchUp)
+		if job.CatchUp == jobs.CatchUpAll {
+			catchUp += fmt.Sprintf(" (%d)", maxCatchUp(job))
+		}
+		fmt.Printf("%-24s %-20s %-9s %-11s %-17s %-17s %d\n", job.ID, scheduleText(job), catchUp, job.Concurrency, nextRun, lastRun, missed)
+	}
+	return nil
+}
+
+// showScheduleHistory prints the latest runs and missed times of a scheduled
+// job
+func showScheduleHistory(scheduler *jobs.JobScheduler, id string, limit int) error {
+	job, err := scheduler.GetScheduledJob(id)
+	if err != nil {
+		return err
+	}
+	history, err := scheduler.History(id, limit)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schedule: %s (%s)\n", job.ID, scheduleText(job))
+	fmt.Printf("Catch-up: %s\n", job.CatchUp)
+	fmt.Printf("Concurrency: %s\n", job.Concurrency)
+	if len(history) == 0 {
+		fmt.Println("No runs recorded")
+		return nil
+	}
+
+	fmt.Printf("%-19s %-10s %s\n", "SCHEDULED FOR", "OUTCOME", "DETAILS")
+	for _, run := range history {
+		details := run.JobID
+		switch run.Outcome {
+		case jobs.ScheduleMissed:
+			details = fmt.Sprintf("%d run(s) through %s", run.Missed, run.Until.Local().Format("2006-01-02 15:04:05"))
+		case jobs.ScheduleBlocked:
+			details = "previous run " + run.JobID + " still active"
+		case jobs.ScheduleCaughtUp:
+			details = fmt.Sprintf("%s, run %s late", run.JobID, run.RecordedAt.Sub(run.ScheduledFor).Round(time.Second))
+		}
+		fmt.Printf("%-19s %-10s %s\n", run.ScheduledFor.Local().Format("2006-01-02 15:04:05"), run.Outcome, details)
+	}
+	return nil
+}
+
+// scheduleText returns the cron expression of a job with its time zone
+func scheduleText(job *jobs.ScheduledJob) string {
+	if job.Timezone != "" {
+		return job.Schedule + " " + job.Timezone
+	}
+	return job.Schedule
+}
+
+// maxCatchUp returns the runs the "all" policy makes up for a job
+func maxCatchUp(job *jobs.ScheduledJob) int {
+	if job.MaxCatchUp > 0 {
+		return job.MaxCatchUp
+	}
+	return jobs.DefaultMaxCatchUp
+}
@@ -0,0 +1,289 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// scratchVariable is the session variable holding the scratch connection of
+// each data source, whose TEMP tables last until the session ends
+const scratchVariable = "scratch"
+
+// scratchProvider is implemented by data sources that give sessions a
+// scratch area for TEMP tables
+type scratchProvider interface {
+	OpenScratch() (*storage.Scratch, error)
+}
+
+// sessionScratch returns the scratch connection of a data source for the
+// session, opening it on first use. It returns nil when the source has no
+// scratch area or there is no session.
+func sessionScratch(ctx *ExecutionContext, sourceName string, ds datasource.DataSource) (*storage.Scratch, error) {
+	provider, ok := ds.(scratchProvider)
+	if !ok || ctx.Session == nil {
+		return nil, nil
+	}
+
+	scratches, _ := ctx.Session.Variables[scratchVariable].(map[string]*storage.Scratch)
+	if scratch, ok := scratches[sourceName]; ok {
+		return scratch, nil
+	}
+	scratch, err := provider.OpenScratch()
+	if err != nil {
+		return nil, err
+	}
+	if scratches == nil {
+		scratches = make(map[string]*storage.Scratch)
+		ctx.Session.Variables[scratchVariable] = scratches
+	}
+	scratches[sourceName] = scratch
+	return scratch, nil
+}
+
+// limitedQuerier is implemented by data sources that can stop reading a
+// result once it exceeds limits
+type limitedQuerier interface {
+	QueryLimited(query string, limits datasource.ResultLimits) (datasource.QueryResult, error)
+}
+
+// sessionQuery runs a query on the session's scratch connection, so it sees
+// and can create TEMP tables, falling back to the data source. Rows are read
+// until the result would exceed limits, where the source supports it.
+func sessionQuery(ctx *ExecutionContext, sourceName string, ds datasource.DataSource, sql string, limits datasource.ResultLimits) (datasource.QueryResult, error) {
+	scratch, err := sessionScratch(ctx, sourceName, ds)
+	if err != nil {
+		return datasource.QueryResult{}, err
+	}
+	if scratch == nil {
+		if limited, ok := ds.(limitedQuerier); ok {
+			return limited.QueryLimited(sql, limits)
+		}
+		return ds.Query(sql)
+	}
+
+	start := time.Now()
+	columns, rows, truncated, err := scratch.QueryLimited(sql, limits)
+	if err != nil {
+		return datasource.QueryResult{}, err
+	}
+	return datasource.QueryResult{
+		Columns:   columns,
+		Rows:      rows,
+		Count:     len(rows),
+		Duration:  time.Since(start),
+		Truncated: truncated,
+	}, nil
+}
+
+// scratchSourceName returns the data source of the last query, whose
+// scratch area holds the tables it built, or the default one
+func scratchSourceName(ctx *ExecutionContext) string {
+	if sourceName, _ := LastQuery(ctx.Session); sourceName != "" {
+		return sourceName
+	}
+	return defaultSourceName(ctx)
+}
+
+// CloseScratch closes the scratch connections of a session, dropping its
+// TEMP tables
+func CloseScratch(session *Session) {
+	if session == nil {
+		return
+	}
+	scratches, _ := session.Variables[scratchVariable].(map[string]*storage.Scratch)
+	for _, scratch := range scratches {
+		scratch.Close()
+	}
+	delete(session.Variables, scratchVariable)
+}
+
+// MaterializeHandler stores the last query result in a scratch table
+type MaterializeHandler struct {
+	*BaseHandler
+}
+
+// NewMaterializeHandler creates a new materialize handler
+func NewMaterializeHandler() *MaterializeHandler {
+	spec := &CommandSpec{
+		Name:        ".materialize",
+		Description: "Save the last query result as a TEMP table for the rest of the session",
+		Usage:       ".materialize last_result AS <table> [--replace]",
+		Category:    "data",
+		MinArgs:     3,
+		MaxArgs:     3,
+		Flags: map[string]FlagSpec{
+			"replace": {Type: "bool", Description: "Replace a scratch table of the same name"},
+		},
+		Examples: []string{
+			".materialize last_result AS tmp_top",
+			".materialize last_result AS tmp_top --replace",
+		},
+	}
+
+	return &MaterializeHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute creates the table in the scratch area of the last query's source
+func (mh *MaterializeHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	if cmd.Args[0] != lastResultVariable || !strings.EqualFold(cmd.Args[1], "AS") {
+		return fmt.Errorf("usage: %s", mh.spec.Usage)
+	}
+	name := cmd.Args[2]
+
+	var result datasource.QueryResult
+	var ok bool
+	if ctx.Session != nil {
+		result, ok = ctx.Session.Variables[lastResultVariable].(datasource.QueryResult)
+	}
+	if !ok {
+		return fmt.Errorf("no query result to materialize, run a query first")
+	}
+
+	sourceName := scratchSourceName(ctx)
+	ds, err := contextDataSource(ctx, sourceName)
+	if err != nil {
+		return err
+	}
+	scratch, err := sessionScratch(ctx, sourceName, ds)
+	if err != nil {
+		return err
+	}
+	if scratch == nil {
+		return fmt.Errorf("%s has no scratch area for session tables", sourceName)
+	}
+
+	replace := cmd.Flags["replace"] == true
+	if err := scratch.Materialize(name, result.Columns, result.Rows, replace); err != nil {
+		if !replace && strings.Contains(err.Error(), "already exists") {
+			return fmt.Errorf("%w; use --replace to overwrite it", err)
+		}
+		return err
+	}
+	fmt.Printf("Saved %d rows as %s in the %s scratch area; it is dropped when the session ends\n", len(result.Rows), name, sourceName)
+	return nil
+}
+
+// GetArgumentCompletions completes the fixed words of the command
+func (mh *MaterializeHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	switch len(args) {
+	case 0:
+		return completeFrom([]string{lastResultVariable}, partial)
+	case 1:
+		return completeFrom([]string{"AS"}, partial)
+	}
+	return []string{}
+}
+
+// ScratchHandler lists and drops the session's scratch tables
+type ScratchHandler struct {
+	*BaseHandler
+}
+
+// NewScratchHandler creates a new scratch handler
+func NewScratchHandler() *ScratchHandler {
+	spec := &CommandSpec{
+		Name:        ".scratch",
+		Description: "List or drop the session's TEMP tables",
+		Usage:       ".scratch [list|drop <table>] [--source <source>]",
+		Category:    "data",
+		MinArgs:     0,
+		MaxArgs:     2,
+		Flags: map[string]FlagSpec{
+			"source": {Type: "string", Short: "s", Description: "Data source of the scratch area (default the last queried source)"},
+		},
+		Examples: []string{
+			".scratch",
+			".scratch drop tmp_top",
+		},
+	}
+
+	return &ScratchHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute lists the scratch tables of every open scratch area, or drops one
+func (sh *ScratchHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	action := "list"
+	if len(cmd.Args) > 0 {
+		action = cmd.Args[0]
+	}
+
+	switch action {
+	case "list":
+		return sh.list(ctx, cmd)
+	case "drop":
+		if len(cmd.Args) < 2 {
+			return fmt.Errorf("usage: .scratch drop <table>")
+		}
+		sourceName, _ := cmd.Flags["source"].(string)
+		if sourceName == "" {
+			sourceName = scratchSourceName(ctx)
+		}
+		ds, err := contextDataSource(ctx, sourceName)
+		if err != nil {
+			return err
+		}
+		scratch, err := sessionScratch(ctx, sourceName, ds)
+		if err != nil {
+			return err
+		}
+		if scratch == nil {
+			return fmt.Errorf("%s has no scratch area for session tables", sourceName)
+		}
+		if err := scratch.Drop(cmd.Args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Dropped %s\n", cmd.Args[1])
+		return nil
+	}
+	return fmt.Errorf("unknown action %q (valid: list, drop)", action)
+}
+
+// list prints the tables of the open scratch areas
+func (sh *ScratchHandler) list(ctx *ExecutionContext, cmd *Command) error {
+	var scratches map[string]*storage.Scratch
+	if ctx.Session != nil {
+		scratches, _ = ctx.Session.Variables[scratchVariable].(map[string]*storage.Scratch)
+	}
+	only, _ := cmd.Flags["source"].(string)
+
+	names := make([]string, 0, len(scratches))
+	for name := range scratches {
+		if only == "" || name == only {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	listed := 0
+	for _, name := range names {
+		tables, err := scratches[name].Tables()
+		if err != nil {
+			return err
+		}
+		for _, table := range tables {
+			fmt.Printf("%-12s %-24s %d rows\n", name, table.Name, table.Rows)
+			listed++
+		}
+	}
+	if listed == 0 {
+		fmt.Println("No scratch tables; create one with CREATE TEMP TABLE or .materialize last_result AS <table>")
+	}
+	return nil
+}
+
+// GetArgumentCompletions completes the actions
+func (sh *ScratchHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	return completeFrom([]string{"list", "drop"}, partial)
+}
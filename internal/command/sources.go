@@ -0,0 +1,306 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/dataset"
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+// SourcesHandler handles data source management
+type SourcesHandler struct {
+	*BaseHandler
+}
+
+// NewSourcesHandler creates a new sources handler
+func NewSourcesHandler() *SourcesHandler {
+	spec := &CommandSpec{
+		Name:        "sources",
+		Description: "Manage data sources",
+		Usage:       "sources <list|status|enable|disable|redownload|export-dataset|import-dataset> [args...]",
+		Category:    "data",
+		MinArgs:     1,
+		MaxArgs:     3,
+		Flags: map[string]FlagSpec{
+			"ids":   {Type: "string", Description: "Comma-separated item IDs to re-download"},
+			"range": {Type: "string", Description: "Inclusive range of item IDs to re-download, e.g. 1000-2000"},
+		},
+		Examples: []string{
+			"sources list",
+			"sources status hackernews",
+			"sources disable hackernews",
+			"sources redownload hackernews --ids 123,456",
+			"sources redownload hackernews --range 1000-2000",
+			"sources export-dataset hackernews hn.tar.gz",
+			"sources export-dataset hackernews hn.tar.zst",
+			"sources import-dataset hn.tar.gz",
+		},
+		Destructive: []string{"import-dataset"},
+	}
+
+	return &SourcesHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute handles sources operations
+func (sh *SourcesHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	args := cmd.Args
+	switch args[0] {
+	case "list":
+		fmt.Println("Available data sources:")
+		for _, name := range knownSourceNames(ctx) {
+			if _, open := ctx.DataSources[name]; open || config.AppConfig.SourceEnabled(name) {
+				fmt.Printf("  %s\n", name)
+			} else {
+				fmt.Printf("  %s (disabled)\n", name)
+			}
+		}
+		return nil
+	case "enable", "add", "disable", "remove":
+		if len(args) < 2 {
+			return fmt.Errorf("%s requires a source name", args[0])
+		}
+		return sh.setEnabled(ctx, args[1], args[0] == "enable" || args[0] == "add")
+	case "status":
+		if len(args) < 2 {
+			return fmt.Errorf("status command requires source name")
+		}
+		ds, err := contextDataSource(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		displayDownloadStatus(args[1], ds.GetDownloadStatus())
+		return nil
+	case "redownload":
+		if len(args) < 2 {
+			return fmt.Errorf("redownload requires a source name")
+		}
+		return sh.redownload(ctx, cmd, args[1])
+	case "export-dataset":
+		if len(args) < 3 {
+			return fmt.Errorf("export-dataset requires source name and archive path")
+		}
+		porter, err := contextDatasetPorter(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		manifest, err := porter.ExportDataset(args[2])
+		if err != nil {
+			return fmt.Errorf("failed to export dataset: %w", err)
+		}
+		fmt.Printf("Exported %s dataset to %s\n", manifest.Source, args[2])
+		displayDatasetManifest(manifest)
+		return nil
+	case "import-dataset":
+		if len(args) < 2 {
+			return fmt.Errorf("import-dataset requires archive path")
+		}
+		manifest, err := dataset.ReadManifest(args[1])
+		if err != nil {
+			return err
+		}
+		porter, err := contextDatasetPorter(ctx, manifest.Source)
+		if err != nil {
+			return err
+		}
+		manifest, err = porter.ImportDataset(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to import dataset: %w", err)
+		}
+		fmt.Printf("Imported %s dataset from %s\n", manifest.Source, args[1])
+		displayDatasetManifest(manifest)
+		return nil
+	default:
+		return fmt.Errorf("unknown sources subcommand: %s", args[0])
+	}
+}
+
+// GetArgumentCompletions completes subcommands and data source names
+func (sh *SourcesHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	switch {
+	case len(args) == 0:
+		return completeFrom([]string{"list", "status", "enable", "disable", "redownload", "export-dataset", "import-dataset"}, partial)
+	case len(args) == 1 && (args[0] == "status" || args[0] == "redownload" || args[0] == "export-dataset"):
+		return completeDataSources(ctx, partial)
+	case len(args) == 1 && (args[0] == "enable" || args[0] == "add" || args[0] == "disable" || args[0] == "remove"):
+		return completeFrom(knownSourceNames(ctx), partial)
+	}
+	return []string{}
+}
+
+// redownload submits a background job that fetches the items given by
+// --ids and --range again and updates them in place
+func (sh *SourcesHandler) redownload(ctx *ExecutionContext, cmd *Command, sourceName string) error {
+	jm, err := requireJobManager(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := contextDataSource(ctx, sourceName); err != nil {
+		return err
+	}
+
+	var parts []string
+	for _, name := range []string{"ids", "range"} {
+		if value, _ := cmd.Flags[name].(string); value != "" {
+			parts = append(parts, value)
+		}
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("redownload requires --ids or --range")
+	}
+	spec := strings.Join(parts, ",")
+
+	jobID, err := jm.RedownloadItems(sourceName, spec)
+	if err != nil {
+		return err
+	}
+	if err := jm.StartJob(jobID); err != nil {
+		return fmt.Errorf("failed to start job: %w", err)
+	}
+	fmt.Printf("Started re-download job %s for %s items %s\n", jobID, sourceName, spec)
+	fmt.Println("Changes to stored items are written to the log")
+	return nil
+}
+
+// setEnabled enables or disables a data source in the config and reopens
+// the shell's data sources, so a disabled one is hidden and its scheduled
+// jobs stop. Downloaded data is kept.
+func (sh *SourcesHandler) setEnabled(ctx *ExecutionContext, name string, enabled bool) error {
+	if err := config.CheckSource(name); err != nil {
+		return err
+	}
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	if config.AppConfig.SourceEnabled(name) == enabled {
+		fmt.Printf("Data source %s is already %s\n", name, state)
+		return nil
+	}
+	if err := config.SetSourceEnabled(name, enabled, config.OriginShell); err != nil {
+		return err
+	}
+	if ctx.Shell != nil {
+		ctx.Shell.ReloadDataSources()
+	}
+	fmt.Printf("Data source %s %s\n", name, state)
+	return nil
+}
+
+// knownSourceNames returns the supported data sources and any others of the
+// context in order
+func knownSourceNames(ctx *ExecutionContext) []string {
+	names := sortedDataSourceNames(ctx)
+	for _, name := range config.KnownSources {
+		if _, open := ctx.DataSources[name]; !open {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// datasetPorter is implemented by data sources that can export and import
+// their database as a dataset archive
+type datasetPorter interface {
+	ExportDataset(archivePath string) (*dataset.Manifest, error)
+	ImportDataset(archivePath string) (*dataset.Manifest, error)
+}
+
+// contextDatasetPorter returns the named data source if it supports dataset
+// archives
+func contextDatasetPorter(ctx *ExecutionContext, sourceName string) (datasetPorter, error) {
+	ds, err := contextDataSource(ctx, sourceName)
+	if err != nil {
+		return nil, err
+	}
+	porter, ok := ds.(datasetPorter)
+	if !ok {
+		return nil, fmt.Errorf("data source %s does not support dataset export", sourceName)
+	}
+	return porter, nil
+}
+
+// contextDataSource returns the named data source of the context
+func contextDataSource(ctx *ExecutionContext, sourceName string) (datasource.DataSource, error) {
+	ds, ok := ctx.DataSources[sourceName].(datasource.DataSource)
+	if !ok {
+		return nil, fmt.Errorf("unknown data source: %s", sourceName)
+	}
+	return ds, nil
+}
+
+// contextStoragePath returns the configured storage path
+func contextStoragePath(ctx *ExecutionContext) (string, error) {
+	cfg, ok := ctx.Config.(config.Config)
+	if !ok || cfg.StoragePath == "" {
+		return "", fmt.Errorf("storage path not configured")
+	}
+	return cfg.StoragePath, nil
+}
+
+// sortedDataSourceNames returns the names of the context's data sources in order
+func sortedDataSourceNames(ctx *ExecutionContext) []string {
+	names := make([]string, 0, len(ctx.DataSources))
+	for name := range ctx.DataSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completeDataSources returns the data source names starting with partial,
+// the active one first
+func completeDataSources(ctx *ExecutionContext, partial string) []string {
+	names := sortedDataSourceNames(ctx)
+	if active := ActiveSource(ctx.Session); active != "" {
+		sort.SliceStable(names, func(i, j int) bool { return names[i] == active && names[j] != active })
+	}
+	return completeFrom(names, partial)
+}
+
+// completeFrom returns the candidates starting with partial
+func completeFrom(candidates []string, partial string) []string {
+	var completions []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, partial) {
+			completions = append(completions, candidate)
+		}
+	}
+	return completions
+}
+
+// displayDownloadStatus shows data source download status
+func displayDownloadStatus(sourceName string, status datasource.DownloadStatus) {
+	fmt.Printf("Status for %s:\n", sourceName)
+	fmt.Printf("  Active: %t\n", status.IsActive)
+	fmt.Printf("  Status: %s\n", status.Status)
+	fmt.Printf("  Progress: %.1f%%\n", status.Progress*100)
+	fmt.Printf("  Items: %d/%d\n", status.ItemsCached, status.ItemsTotal)
+	fmt.Printf("  Last Update: %s\n", status.LastUpdate.Format("2006-01-02 15:04:05"))
+	if status.ErrorMessage != "" {
+		fmt.Printf("  Error: %s\n", status.ErrorMessage)
+	}
+}
+
+// displayDatasetManifest prints the schema version, checksum and row counts of a dataset
+func displayDatasetManifest(manifest *dataset.Manifest) {
+	fmt.Printf("  Schema version: %d\n", manifest.SchemaVersion)
+	fmt.Printf("  Created: %s\n", manifest.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("  Size: %.1f MB\n", float64(manifest.Size)/(1024*1024))
+	fmt.Printf("  SHA-256: %s\n", manifest.SHA256)
+
+	tables := make([]string, 0, len(manifest.Tables))
+	for table := range manifest.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		fmt.Printf("  %-20s %d rows\n", table, manifest.Tables[table])
+	}
+}
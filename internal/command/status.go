@@ -0,0 +1,428 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// StatusComponents lists the sections of the status report in display order
+var StatusComponents = []string{"config", "sources", "jobs", "storage", "scheduler", "errors"}
+
+// Status report limits; --verbose widens them
+const (
+	statusErrorWindow      = 24 * time.Hour
+	statusErrorLimit       = 5
+	statusUpcomingWindow   = 24 * time.Hour
+	statusVerboseUpcoming  = 7 * 24 * time.Hour
+	statusVerboseErrorSize = 50
+)
+
+// databaseHealthReporter is implemented by data sources that monitor their database
+type databaseHealthReporter interface {
+	DatabaseHealth() storage.DBHealth
+}
+
+// storageStatsReporter is implemented by data sources that report database size
+type storageStatsReporter interface {
+	StorageStats() storage.StorageStats
+}
+
+// StatusReport aggregates the state of every subsystem shown by `status`
+type StatusReport struct {
+	Config    *ConfigStatus      `json:"config,omitempty"`
+	Sources   []SourceStatus     `json:"sources,omitempty"`
+	Jobs      *jobs.ManagerStats `json:"jobs,omitempty"`
+	Storage   []StorageStatus    `json:"storage,omitempty"`
+	Scheduler []ScheduledRun     `json:"scheduler,omitempty"`
+	Errors    []StatusError      `json:"errors,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// ConfigStatus summarizes the active configuration
+type ConfigStatus struct {
+	StoragePath     string   `json:"storage_path"`
+	StorageLayout   string   `json:"storage_layout"`
+	Workers         int      `json:"workers"`
+	LogFiles        bool     `json:"log_files"`
+	DisabledSources []string `json:"disabled_sources,omitempty"`
+}
+
+// SourceStatus is the download status of one data source
+type SourceStatus struct {
+	Name        string  `json:"name"`
+	Status      string  `json:"status"`
+	Active      bool    `json:"active"`
+	Progress    float64 `json:"progress"`
+	ItemsCached int64   `json:"items_cached"`
+	ItemsTotal  int64   `json:"items_total"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// StorageStatus is the database state of one data source
+type StorageStatus struct {
+	Name   string                `json:"name"`
+	Health *storage.DBHealth     `json:"health,omitempty"`
+	Stats  *storage.StorageStats `json:"stats,omitempty"`
+}
+
+// ScheduledRun is an upcoming run of a scheduled job
+type ScheduledRun struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	NextRun  time.Time `json:"next_run"`
+}
+
+// StatusError is a recent failure reported by a subsystem
+type StatusError struct {
+	Component string    `json:"component"`
+	Source    string    `json:"source"`
+	Message   string    `json:"message"`
+	Time      time.Time `json:"time,omitempty"`
+}
+
+// StatusHandler handles system status commands
+type StatusHandler struct {
+	*BaseHandler
+}
+
+// NewStatusHandler creates a new status handler
+func NewStatusHandler() *StatusHandler {
+	spec := &CommandSpec{
+		Name:        "status",
+		Description: "Show system status",
+		Usage:       "status [config|sources|jobs|storage|scheduler|errors]",
+		Category:    "system",
+		MinArgs:     0,
+		MaxArgs:     1,
+		Flags: map[string]FlagSpec{
+			"verbose": {Type: "bool", Short: "v", Description: "Show detailed status"},
+			"json":    {Type: "bool", Description: "Print the status as JSON"},
+		},
+		Examples: []string{
+			"status",
+			"status --verbose",
+			"status jobs",
+			"status storage --json",
+		},
+	}
+
+	return &StatusHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute prints the status of all subsystems, or of one component
+func (sh *StatusHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	component := ""
+	if len(cmd.Args) > 0 {
+		component = cmd.Args[0]
+	}
+	verbose, _ := cmd.Flags["verbose"].(bool)
+	asJSON, _ := cmd.Flags["json"].(bool)
+
+	report, err := BuildStatusReport(ctx, component, verbose)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("failed to encode status: %w", err)
+		}
+		return nil
+	}
+
+	report.WriteText(os.Stdout, verbose)
+	return nil
+}
+
+// GetArgumentCompletions provides component completions
+func (sh *StatusHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	var completions []string
+	for _, component := range StatusComponents {
+		if strings.HasPrefix(component, partial) {
+			completions = append(completions, component)
+		}
+	}
+	return completions
+}
+
+// BuildStatusReport collects the status of one component, or of every
+// component when component is empty
+func BuildStatusReport(ctx *ExecutionContext, component string, verbose bool) (*StatusReport, error) {
+	if component != "" && !containsString(StatusComponents, component) {
+		return nil, fmt.Errorf("unknown status component %q (available: %s)", component, strings.Join(StatusComponents, ", "))
+	}
+	include := func(name string) bool {
+		return component == "" || component == name
+	}
+
+	report := &StatusReport{Timestamp: time.Now()}
+	jm := contextJobManager(ctx)
+	sourceNames := sortedDataSourceNames(ctx)
+
+	if cfg, ok := ctx.Config.(config.Config); ok && include("config") {
+		report.Config = &ConfigStatus{
+			StoragePath:   cfg.StoragePath,
+			StorageLayout: cfg.Storage.Layout,
+			Workers:       cfg.Jobs.Workers,
+			LogFiles:      cfg.Log.File,
+		}
+		for name := range cfg.DataSources {
+			if !cfg.SourceEnabled(name) {
+				report.Config.DisabledSources = append(report.Config.DisabledSources, name)
+			}
+		}
+		sort.Strings(report.Config.DisabledSources)
+	}
+
+	// Download status is needed for both the sources section and errors
+	var sources []SourceStatus
+	if include("sources") || include("errors") {
+		for _, name := range sourceNames {
+			ds, ok := ctx.DataSources[name].(datasource.DataSource)
+			if !ok {
+				continue
+			}
+			status := ds.GetDownloadStatus()
+			sources = append(sources, SourceStatus{
+				Name:        name,
+				Status:      status.Status,
+				Active:      status.IsActive,
+				Progress:    status.Progress,
+				ItemsCached: status.ItemsCached,
+				ItemsTotal:  status.ItemsTotal,
+				Error:       status.ErrorMessage,
+			})
+		}
+	}
+	if include("sources") {
+		report.Sources = sources
+	}
+
+	if jm != nil && include("jobs") {
+		stats := jm.GetStats()
+		report.Jobs = &stats
+	}
+
+	var storageStatuses []StorageStatus
+	if include("storage") || include("errors") {
+		for _, name := range sourceNames {
+			status := StorageStatus{Name: name}
+			if reporter, ok := ctx.DataSources[name].(databaseHealthReporter); ok {
+				health := reporter.DatabaseHealth()
+				status.Health = &health
+			}
+			if reporter, ok := ctx.DataSources[name].(storageStatsReporter); ok {
+				stats := reporter.StorageStats()
+				status.Stats = &stats
+			}
+			storageStatuses = append(storageStatuses, status)
+		}
+	}
+	if include("storage") {
+		report.Storage = storageStatuses
+	}
+
+	if jm != nil && include("scheduler") {
+		window := statusUpcomingWindow
+		if verbose {
+			window = statusVerboseUpcoming
+		}
+		for _, job := range jm.Scheduler().GetUpcomingJobs(window) {
+			report.Scheduler = append(report.Scheduler, ScheduledRun{
+				ID:       job.ID,
+				Name:     job.Name,
+				Schedule: job.Schedule,
+				NextRun:  job.NextRun,
+			})
+		}
+	}
+
+	if include("errors") {
+		errors, err := recentErrors(jm, sources, storageStatuses)
+		if err != nil {
+			return nil, err
+		}
+		limit := statusErrorLimit
+		if verbose {
+			limit = statusVerboseErrorSize
+		}
+		if len(errors) > limit {
+			errors = errors[:limit]
+		}
+		report.Errors = errors
+	}
+
+	return report, nil
+}
+
+// recentErrors gathers failed jobs from the last day, source download errors
+// and database issues, newest first
+func recentErrors(jm *jobs.EnhancedJobManager, sources []SourceStatus, storageStatuses []StorageStatus) ([]StatusError, error) {
+	var errors []StatusError
+
+	if jm != nil {
+		since := time.Now().Add(-statusErrorWindow)
+		failed, err := jm.ListJobs(jobs.JobFilter{States: []jobs.JobState{jobs.JobStateFailed, jobs.JobStateDeadLetter}, CreatedAfter: &since})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list failed jobs: %w", err)
+		}
+		for _, job := range failed {
+			at := job.StartTime
+			if job.EndTime != nil {
+				at = *job.EndTime
+			}
+			errors = append(errors, StatusError{Component: "jobs", Source: job.ID, Message: job.ErrorMessage, Time: at})
+		}
+	}
+
+	for _, source := range sources {
+		if source.Status == "error" && source.Error != "" {
+			errors = append(errors, StatusError{Component: "sources", Source: source.Name, Message: source.Error})
+		}
+	}
+
+	for _, status := range storageStatuses {
+		if status.Health == nil {
+			continue
+		}
+		for _, issue := range status.Health.Issues {
+			errors = append(errors, StatusError{Component: "storage", Source: status.Name, Message: issue, Time: status.Health.LastCheck})
+		}
+	}
+
+	sort.SliceStable(errors, func(i, j int) bool {
+		return errors[i].Time.After(errors[j].Time)
+	})
+	return errors, nil
+}
+
+// WriteText prints the report as text; verbose adds per-type job counts,
+// connection usage and database details
+func (r *StatusReport) WriteText(w io.Writer, verbose bool) {
+	if r.Config != nil {
+		fmt.Fprintln(w, "Config:")
+		fmt.Fprintf(w, "  Storage: %s (%s layout)\n", r.Config.StoragePath, r.Config.StorageLayout)
+		fmt.Fprintf(w, "  Workers: %d  Log files: %t\n", r.Config.Workers, r.Config.LogFiles)
+		if len(r.Config.DisabledSources) > 0 {
+			fmt.Fprintf(w, "  Disabled sources: %s\n", strings.Join(r.Config.DisabledSources, ", "))
+		}
+	}
+
+	if r.Sources != nil {
+		fmt.Fprintln(w, "Sources:")
+		for _, source := range r.Sources {
+			fmt.Fprintf(w, "  %-12s %-12s %d/%d items (%.1f%%)\n",
+				source.Name, source.Status, source.ItemsCached, source.ItemsTotal, source.Progress*100)
+		}
+	}
+
+	if r.Jobs != nil {
+		fmt.Fprintln(w, "Jobs:")
+		fmt.Fprintf(w, "  Running: %d  Queued: %d  Completed: %d  Failed: %d\n",
+			r.Jobs.RunningJobs, r.Jobs.QueuedJobs, r.Jobs.CompletedJobs, r.Jobs.FailedJobs)
+		if r.Jobs.DeadLetterJobs > 0 {
+			fmt.Fprintf(w, "  Dead-letter: %d (see jobs dlq list)\n", r.Jobs.DeadLetterJobs)
+		}
+		fmt.Fprintf(w, "  Workers: %d/%d active\n", r.Jobs.WorkerStats.ActiveWorkers, r.Jobs.WorkerStats.TotalWorkers)
+		if verbose {
+			types := make([]string, 0, len(r.Jobs.JobsByType))
+			for jobType := range r.Jobs.JobsByType {
+				types = append(types, string(jobType))
+			}
+			sort.Strings(types)
+			for _, jobType := range types {
+				fmt.Fprintf(w, "  %-12s %d\n", jobType, r.Jobs.JobsByType[jobs.JobType(jobType)])
+			}
+		}
+	}
+
+	if r.Storage != nil {
+		fmt.Fprintln(w, "Storage:")
+		for _, status := range r.Storage {
+			writeStorageStatus(w, status, verbose)
+		}
+	}
+
+	if r.Scheduler != nil {
+		fmt.Fprintln(w, "Scheduled:")
+		for _, run := range r.Scheduler {
+			fmt.Fprintf(w, "  %-24s %-16s next %s\n", run.Name, run.Schedule, run.NextRun.Format("2006-01-02 15:04"))
+		}
+	}
+
+	if r.Errors != nil {
+		fmt.Fprintln(w, "Recent errors:")
+		for _, statusErr := range r.Errors {
+			when := ""
+			if !statusErr.Time.IsZero() {
+				when = statusErr.Time.Format("01-02 15:04") + " "
+			}
+			fmt.Fprintf(w, "  %s[%s] %s: %s\n", when, statusErr.Component, statusErr.Source, statusErr.Message)
+		}
+	}
+}
+
+// writeStorageStatus prints a one-line summary of a data source's database
+// followed by any issues
+func writeStorageStatus(w io.Writer, status StorageStatus, verbose bool) {
+	if status.Health == nil && status.Stats == nil {
+		fmt.Fprintf(w, "  %-12s no storage information\n", status.Name)
+		return
+	}
+
+	health := "unknown"
+	if status.Health != nil {
+		health = status.Health.Status
+	}
+	line := fmt.Sprintf("  %-12s %s", status.Name, health)
+	if status.Stats != nil {
+		line += fmt.Sprintf(", %.1f MB", float64(status.Stats.DatabaseSize)/(1024*1024))
+		if verbose {
+			line += fmt.Sprintf(", %d/%d connections in use", status.Stats.ConnectionsUsed, status.Stats.ConnectionsMax)
+		}
+	}
+	fmt.Fprintln(w, line)
+
+	if status.Health == nil {
+		return
+	}
+	if verbose {
+		lastCheckpoint := "never"
+		if !status.Health.LastCheckpoint.IsZero() {
+			lastCheckpoint = status.Health.LastCheckpoint.Format("15:04:05")
+		}
+		fmt.Fprintf(w, "    WAL %.1f KB, last checkpoint %s, %d busy errors in window\n",
+			float64(status.Health.WALSizeBytes)/1024, lastCheckpoint, status.Health.RecentBusyErrors)
+	}
+	for _, issue := range status.Health.Issues {
+		fmt.Fprintf(w, "    - %s\n", issue)
+	}
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// statusTestSource is a data source with fixed download and database status
+type statusTestSource struct {
+	status datasource.DownloadStatus
+	health storage.DBHealth
+}
+
+func (s *statusTestSource) Name() string                                 { return "test" }
+func (s *statusTestSource) Description() string                          { return "test source" }
+func (s *statusTestSource) GetDownloadStatus() datasource.DownloadStatus { return s.status }
+func (s *statusTestSource) StartDownload(ctx context.Context) error      { return nil }
+func (s *statusTestSource) PauseDownload() error                         { return nil }
+func (s *statusTestSource) ResumeDownload(ctx context.Context) error     { return nil }
+func (s *statusTestSource) Query(query string) (datasource.QueryResult, error) {
+	return datasource.QueryResult{}, nil
+}
+func (s *statusTestSource) GetSchema() datasource.Schema               { return datasource.Schema{} }
+func (s *statusTestSource) InitializeStorage(storagePath string) error { return nil }
+func (s *statusTestSource) GetStoragePath() string                     { return "" }
+func (s *statusTestSource) DatabaseHealth() storage.DBHealth           { return s.health }
+func (s *statusTestSource) StorageStats() storage.StorageStats {
+	return storage.StorageStats{DatabaseSize: 2 * 1024 * 1024, ConnectionsUsed: 1, ConnectionsMax: 4}
+}
+
+func newStatusTestContext() *ExecutionContext {
+	source := &statusTestSource{
+		status: datasource.DownloadStatus{Status: "error", ItemsCached: 50, ItemsTotal: 100, Progress: 0.5, ErrorMessage: "connection refused"},
+		health: storage.DBHealth{Status: "degraded", Issues: []string{"WAL is 80 MB"}, LastCheck: time.Now()},
+	}
+	cfg := config.Config{StoragePath: "/data", Jobs: config.JobsConfig{Workers: 2}}
+	cfg.Storage.Layout = "separate"
+	cfg.DataSources = map[string]config.DataSourceConfig{"other": {Enabled: false}}
+
+	var jobManager *jobs.EnhancedJobManager
+	return &ExecutionContext{
+		Context:     context.Background(),
+		JobManager:  jobManager, // A read-only shell has no job manager
+		DataSources: map[string]interface{}{"test": source},
+		Config:      cfg,
+	}
+}
+
+func TestBuildStatusReport(t *testing.T) {
+	report, err := BuildStatusReport(newStatusTestContext(), "", false)
+	if err != nil {
+		t.Fatalf("BuildStatusReport() error = %v", err)
+	}
+
+	if report.Config == nil || report.Config.Workers != 2 {
+		t.Errorf("Config = %+v, want workers 2", report.Config)
+	}
+	if len(report.Config.DisabledSources) != 1 || report.Config.DisabledSources[0] != "other" {
+		t.Errorf("DisabledSources = %v, want [other]", report.Config.DisabledSources)
+	}
+	if len(report.Sources) != 1 || report.Sources[0].ItemsCached != 50 {
+		t.Errorf("Sources = %+v, want one source with 50 items", report.Sources)
+	}
+	if report.Jobs != nil || report.Scheduler != nil {
+		t.Errorf("Jobs and Scheduler should be empty without a job manager")
+	}
+	if len(report.Storage) != 1 || report.Storage[0].Health.Status != "degraded" || report.Storage[0].Stats.DatabaseSize == 0 {
+		t.Errorf("Storage = %+v, want degraded health with stats", report.Storage)
+	}
+	if len(report.Errors) != 2 {
+		t.Fatalf("Errors = %+v, want source error and storage issue", report.Errors)
+	}
+	if report.Errors[0].Component != "storage" || report.Errors[1].Component != "sources" {
+		t.Errorf("Errors should be newest first, got %+v", report.Errors)
+	}
+
+	var out bytes.Buffer
+	report.WriteText(&out, true)
+	for _, want := range []string{"Config:", "Sources:", "Storage:", "2.0 MB, 1/4 connections in use", "- WAL is 80 MB", "Recent errors:", "connection refused"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("WriteText() output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestBuildStatusReport_Component(t *testing.T) {
+	report, err := BuildStatusReport(newStatusTestContext(), "storage", false)
+	if err != nil {
+		t.Fatalf("BuildStatusReport() error = %v", err)
+	}
+	if report.Config != nil || report.Sources != nil || report.Errors != nil {
+		t.Errorf("only storage should be reported, got %+v", report)
+	}
+	if len(report.Storage) != 1 {
+		t.Errorf("Storage = %+v, want one entry", report.Storage)
+	}
+
+	if _, err := BuildStatusReport(newStatusTestContext(), "bogus", false); err == nil {
+		t.Error("expected an error for an unknown component")
+	}
+}
+
+func TestStatusHandler_Flags(t *testing.T) {
+	parser := NewParser()
+	if err := parser.RegisterCommand(NewStatusHandler().GetSpec()); err != nil {
+		t.Fatalf("RegisterCommand() error = %v", err)
+	}
+
+	cmd, err := parser.Parse("status storage --json -v")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(cmd.Args) != 1 || cmd.Args[0] != "storage" {
+		t.Errorf("Args = %v, want [storage]", cmd.Args)
+	}
+	if cmd.Flags["json"] != true || cmd.Flags["verbose"] != true {
+		t.Errorf("Flags = %v, want json and verbose", cmd.Flags)
+	}
+}
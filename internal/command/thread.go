@@ -0,0 +1,108 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/query"
+)
+
+// threadLoader is implemented by data sources whose items form discussion
+// threads
+type threadLoader interface {
+	Thread(rootID int64) (*datasource.ThreadItem, error)
+}
+
+// ThreadHandler shows the comment tree of a story
+type ThreadHandler struct {
+	*BaseHandler
+}
+
+// NewThreadHandler creates a new thread handler
+func NewThreadHandler() *ThreadHandler {
+	spec := &CommandSpec{
+		Name:        "thread",
+		Description: "Show the comment tree of a story as a threaded view",
+		Usage:       "thread <source> <story_id>",
+		Category:    "data",
+		MinArgs:     2,
+		MaxArgs:     2,
+		Flags: map[string]FlagSpec{
+			"depth":  {Type: "int", Short: "d", Description: "Reply levels to show before collapsing (default all)"},
+			"format": {Type: "string", Short: "f", Description: "Output format (text, markdown, json); defaults to the output file extension"},
+			"output": {Type: "string", Short: "o", Description: "Write the thread to a file (.md, .json or .txt) instead of the screen"},
+			"width":  {Type: "int", Short: "w", Description: "Line width of text output"},
+		},
+		Examples: []string{
+			"thread hackernews 8863",
+			"thread hackernews 8863 --depth 2",
+			"thread hackernews 8863 --output dropbox.md",
+			"thread hackernews 8863 --format json --output dropbox.json",
+		},
+	}
+
+	return &ThreadHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute reconstructs and renders the thread
+func (th *ThreadHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	rootID, err := strconv.ParseInt(cmd.Args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid story ID %q", cmd.Args[1])
+	}
+
+	ds, err := contextDataSource(ctx, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	loader, ok := ds.(threadLoader)
+	if !ok {
+		return fmt.Errorf("data source %s has no threads", cmd.Args[0])
+	}
+
+	opts := query.ThreadOptions{Format: query.ThreadFormatText}
+	path, toFile := cmd.Flags["output"].(string)
+	if toFile {
+		opts.Format = query.ThreadFormatForPath(path)
+	}
+	if format, ok := cmd.Flags["format"].(string); ok {
+		opts.Format = format
+	}
+	if depth, ok := cmd.Flags["depth"].(int); ok {
+		opts.Depth = depth
+	}
+	if width, ok := cmd.Flags["width"].(int); ok {
+		opts.Width = width
+	}
+
+	root, err := loader.Thread(rootID)
+	if err != nil {
+		return err
+	}
+	rendered, err := query.RenderThread(root, opts)
+	if err != nil {
+		return err
+	}
+
+	if !toFile {
+		fmt.Print(rendered)
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(rendered), 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	fmt.Printf("Wrote thread of %d replies to %s\n", root.CountReplies(), path)
+	return nil
+}
+
+// GetArgumentCompletions provides data source completions
+func (th *ThreadHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) == 0 {
+		return completeDataSources(ctx, partial)
+	}
+	return []string{}
+}
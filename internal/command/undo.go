@@ -0,0 +1,117 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/trash"
+)
+
+// UndoHandler restores deletions kept in the trash
+type UndoHandler struct {
+	*BaseHandler
+	bin *trash.Trash
+}
+
+// NewUndoHandler creates an undo handler for bin
+func NewUndoHandler(bin *trash.Trash) *UndoHandler {
+	spec := &CommandSpec{
+		Name:        "undo",
+		Description: "Restore a deleted workspace or cleaned-up jobs",
+		Usage:       "undo [list|<id>]",
+		Category:    "system",
+		MinArgs:     0,
+		MaxArgs:     1,
+		DryRun:      true,
+		Examples: []string{
+			"undo",
+			"undo list",
+			"undo 20240501-120000-workspace",
+		},
+	}
+
+	return &UndoHandler{
+		BaseHandler: NewBaseHandler(spec),
+		bin:         bin,
+	}
+}
+
+// Execute restores the newest deletion, or the one named in the arguments
+func (uh *UndoHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	id := ""
+	if len(cmd.Args) > 0 {
+		id = cmd.Args[0]
+	}
+	if id == "list" {
+		return uh.list()
+	}
+
+	if ctx.DryRun {
+		entry, err := uh.find(id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Dry run: would restore %s (%s)\n", entry.Description, entry.ID)
+		return nil
+	}
+
+	entry, err := uh.bin.Restore(id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s\n", entry.Description)
+	return nil
+}
+
+// list shows the deletions that can be undone
+func (uh *UndoHandler) list() error {
+	entries, err := uh.bin.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("Nothing to undo")
+		return nil
+	}
+
+	fmt.Printf("%-36s %-20s %s\n", "ID", "DELETED", "WHAT")
+	for _, entry := range entries {
+		fmt.Printf("%-36s %-20s %s (until %s)\n", entry.ID, entry.DeletedAt.Format("2006-01-02 15:04"),
+			entry.Description, entry.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// find returns the entry with the given ID, or the newest when id is empty
+func (uh *UndoHandler) find(id string) (*trash.Entry, error) {
+	entries, err := uh.bin.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if id == "" || entry.ID == id {
+			if time.Now().After(entry.ExpiresAt) {
+				return nil, fmt.Errorf("trash entry %s expired at %s", entry.ID, entry.ExpiresAt.Format("2006-01-02 15:04"))
+			}
+			return entry, nil
+		}
+	}
+	if id == "" {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	return nil, fmt.Errorf("trash entry %s not found", id)
+}
+
+// GetArgumentCompletions completes trash entry IDs
+func (uh *UndoHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	options := []string{"list"}
+	if entries, err := uh.bin.List(); err == nil {
+		for _, entry := range entries {
+			options = append(options, entry.ID)
+		}
+	}
+	return completeFrom(options, partial)
+}
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/trash"
+)
+
+func TestUndoHandler(t *testing.T) {
+	bin, err := trash.New(trash.Dir(t.TempDir()), time.Hour)
+	if err != nil {
+		t.Fatalf("trash.New() error = %v", err)
+	}
+	var restored []string
+	bin.RegisterRestorer("note", func(entry *trash.Entry, dir string) error {
+		restored = append(restored, entry.Description)
+		return nil
+	})
+
+	integration := NewShellIntegration()
+	if err := integration.GetRegistry().Register(NewUndoHandler(bin)); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	ctx := context.Background()
+
+	err = integration.ProcessCommand(ctx, "undo", nil, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "nothing to undo") {
+		t.Errorf("ProcessCommand() error = %v, want nothing to undo", err)
+	}
+
+	for _, description := range []string{"first note", "second note"} {
+		if _, err := bin.Put("note", description, func(dir string) error { return nil }); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	if err := integration.ProcessCommand(ctx, "undo --dry-run", nil, nil, nil, nil); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if len(restored) != 0 {
+		t.Errorf("dry run restored %q", restored)
+	}
+
+	if err := integration.ProcessCommand(ctx, "undo", nil, nil, nil, nil); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if len(restored) != 1 || restored[0] != "second note" {
+		t.Errorf("restored = %q, want the newest entry", restored)
+	}
+
+	entries, err := bin.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Description != "first note" {
+		t.Errorf("entries = %+v, want the first note left", entries)
+	}
+	if err := integration.ProcessCommand(ctx, "undo "+entries[0].ID, nil, nil, nil, nil); err != nil {
+		t.Fatalf("ProcessCommand() error = %v", err)
+	}
+	if len(restored) != 2 {
+		t.Errorf("restored = %q, want both notes", restored)
+	}
+}
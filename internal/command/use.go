@@ -0,0 +1,117 @@
+package command
+
+import (
+	"fmt"
+)
+
+// activeSourceVariable is the session variable holding the data source
+// chosen with use
+const activeSourceVariable = "active_source"
+
+// ActiveSource returns the data source chosen with use, or "" when none is
+func ActiveSource(session *Session) string {
+	if session == nil {
+		return ""
+	}
+	source, _ := session.Variables[activeSourceVariable].(string)
+	return source
+}
+
+// SetActiveSource makes source the data source of commands that omit one;
+// "" clears it
+func SetActiveSource(session *Session, source string) {
+	if session == nil {
+		return
+	}
+	if source == "" {
+		delete(session.Variables, activeSourceVariable)
+		return
+	}
+	session.Variables[activeSourceVariable] = source
+}
+
+// resolveSource splits the data source off the arguments of a command. When
+// the first argument is not a data source the active one is used.
+func resolveSource(ctx *ExecutionContext, args []string) (string, []string, error) {
+	if len(args) > 0 {
+		if _, ok := ctx.DataSources[args[0]]; ok {
+			return args[0], args[1:], nil
+		}
+	}
+	if active := ActiveSource(ctx.Session); active != "" {
+		return active, args, nil
+	}
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("no data source given; name one or choose it with 'use <source>'")
+	}
+	return "", nil, fmt.Errorf("unknown data source: %s (choose one with 'use <source>')", args[0])
+}
+
+// defaultSourceName returns the active data source, or the first one by name
+func defaultSourceName(ctx *ExecutionContext) string {
+	if active := ActiveSource(ctx.Session); active != "" {
+		return active
+	}
+	if names := sortedDataSourceNames(ctx); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// UseHandler chooses the data source of commands that omit one
+type UseHandler struct {
+	*BaseHandler
+}
+
+// NewUseHandler creates a new use handler
+func NewUseHandler() *UseHandler {
+	spec := &CommandSpec{
+		Name:        "use",
+		Description: "Set the data source of queries that do not name one",
+		Usage:       "use [source|none]",
+		Category:    "data",
+		MinArgs:     0,
+		MaxArgs:     1,
+		Examples: []string{
+			"use hackernews",
+			"use",
+			"use none",
+		},
+	}
+
+	return &UseHandler{
+		BaseHandler: NewBaseHandler(spec),
+	}
+}
+
+// Execute sets, clears or shows the active data source
+func (uh *UseHandler) Execute(ctx *ExecutionContext, cmd *Command) error {
+	if len(cmd.Args) == 0 {
+		if active := ActiveSource(ctx.Session); active != "" {
+			fmt.Printf("Using %s\n", active)
+		} else {
+			fmt.Println("No data source in use; queries name their source")
+		}
+		return nil
+	}
+
+	if cmd.Args[0] == "none" {
+		SetActiveSource(ctx.Session, "")
+		fmt.Println("Queries name their source again")
+		return nil
+	}
+	if _, err := contextDataSource(ctx, cmd.Args[0]); err != nil {
+		return err
+	}
+	SetActiveSource(ctx.Session, cmd.Args[0])
+	fmt.Printf("Using %s; queries can omit the source\n", cmd.Args[0])
+	return nil
+}
+
+// GetArgumentCompletions completes data source names
+func (uh *UseHandler) GetArgumentCompletions(ctx *ExecutionContext, partial string, args []string) []string {
+	if len(args) > 0 {
+		return []string{}
+	}
+	return append(completeDataSources(ctx, partial), completeFrom([]string{"none"}, partial)...)
+}
@@ -4,37 +4,420 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/brainless/PubDataHub/internal/datasource"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	StoragePath string `mapstructure:"storage_path"`
+	StoragePath string         `mapstructure:"storage_path"`
+	Log         LogConfig      `mapstructure:"log"`
+	Download    DownloadConfig `mapstructure:"download"`
+	HTTP        HTTPConfig     `mapstructure:"http"`
+	Storage     StorageConfig  `mapstructure:"storage"`
+	Jobs        JobsConfig     `mapstructure:"jobs"`
+	Trash       TrashConfig    `mapstructure:"trash"`
+	Audit       AuditConfig    `mapstructure:"audit"`
+	API         APIConfig      `mapstructure:"api"`
+	SSH         SSHConfig      `mapstructure:"ssh"`
+	GRPC        GRPCConfig     `mapstructure:"grpc"`
+	Export      ExportConfig   `mapstructure:"export"`
+	Query       QueryConfig    `mapstructure:"query"`
+	UI          UIConfig       `mapstructure:"ui"`
+
+	// DataSources holds per-source settings keyed by data source name
+	DataSources map[string]DataSourceConfig `mapstructure:"data_sources"`
+}
+
+// JobsConfig holds background job settings
+type JobsConfig struct {
+	Workers int `mapstructure:"workers"` // Jobs that run at the same time; the most when scaling with load
+
+	// Scaling of the workers with load: below workers, min_workers enables
+	// it. Queued jobs waiting scale_up_wait_seconds (default 5) add workers,
+	// workers idle for idle_seconds (default 60) are removed.
+	MinWorkers         int `mapstructure:"min_workers"`
+	ScaleUpWaitSeconds int `mapstructure:"scale_up_wait_seconds"`
+	IdleSeconds        int `mapstructure:"idle_seconds"`
+
+	// Running jobs are cancelled and failed after timeout_minutes (default
+	// 120, 0 disables it); type_timeout_minutes overrides it per job type,
+	// such as {export: 30}
+	TimeoutMinutes     int            `mapstructure:"timeout_minutes"`
+	TypeTimeoutMinutes map[string]int `mapstructure:"type_timeout_minutes"`
+
+	// Failed jobs are retried max_retries times (default 3), waiting
+	// retry_delay_seconds (default 60, 0 for retries by hand only) doubled
+	// with each retry; jobs failing after that go to the dead-letter queue
+	MaxRetries        int `mapstructure:"max_retries"`
+	RetryDelaySeconds int `mapstructure:"retry_delay_seconds"`
+
+	// On shutdown running jobs get graceful_timeout_seconds (default 30) to
+	// finish; drain_policy "pause" (default) then pauses the rest so they
+	// resume on the next start, "cancel" cancels them
+	GracefulTimeoutSeconds int    `mapstructure:"graceful_timeout_seconds"`
+	DrainPolicy            string `mapstructure:"drain_policy"`
+
+	// Policies of every scheduled job, and overrides keyed by scheduled job
+	// ID such as sync-hackernews
+	ScheduleConfig `mapstructure:",squash"`
+	Schedules      map[string]ScheduleConfig `mapstructure:"schedules"`
+}
+
+// ScheduleConfig holds the policies of scheduled jobs
+type ScheduleConfig struct {
+	// Handling of runs missed while the app was not running: "skip"
+	// (default) records them, "once" runs once immediately and "all" runs
+	// each missed time up to max_catch_up
+	CatchUp    string `mapstructure:"catch_up"`
+	MaxCatchUp int    `mapstructure:"max_catch_up"` // 0 uses 10
+
+	// Handling of a run while the previous one is still active: "forbid"
+	// (default) skips it, "allow" runs both and "replace" cancels the
+	// previous one
+	Concurrency string `mapstructure:"concurrency"`
+}
+
+// APIConfig holds settings for the HTTP API server
+type APIConfig struct {
+	Auth    bool             `mapstructure:"auth"`    // Require a token for /api routes
+	Tokens  []APITokenConfig `mapstructure:"tokens"`  // Static tokens; without any, one is generated
+	GraphQL bool             `mapstructure:"graphql"` // Serve the read-only GraphQL endpoint at /api/graphql
+}
+
+// APITokenConfig is a static API token
+type APITokenConfig struct {
+	Name  string `mapstructure:"name"`
+	Token string `mapstructure:"token"` // The token, or secret:<name> to read it from the secrets store
+	Scope string `mapstructure:"scope"` // "read" (default) or "admin" to start downloads, control jobs and change settings; "jobs" is an older name for admin
+}
+
+// SSHConfig holds settings for serving the shell over SSH
+type SSHConfig struct {
+	Listen         string `mapstructure:"listen"`          // Address of the SSH server
+	AuthorizedKeys string `mapstructure:"authorized_keys"` // Public keys allowed to log in; empty uses ~/.ssh/authorized_keys
+}
+
+// GRPCConfig holds settings for the daemon's gRPC control API
+type GRPCConfig struct {
+	Listen string `mapstructure:"listen"` // Address of the control API; empty disables it
+}
+
+// ExportConfig holds settings for export destinations
+type ExportConfig struct {
+	S3    S3Config           `mapstructure:"s3"`
+	Hooks []ExportHookConfig `mapstructure:"hooks"` // Run after every export job completes
+}
+
+// ExportHookConfig hands a completed export to a downstream pipeline
+type ExportHookConfig struct {
+	Command string `mapstructure:"command"` // Run with the output path as its last argument
+	Webhook string `mapstructure:"webhook"` // http(s) URL the job metadata is posted to as JSON
+}
+
+// S3Config holds the S3-compatible object storage that exports to s3://
+// URLs are uploaded to. The access keys are the secrets s3.access_key_id and
+// s3.secret_access_key.
+type S3Config struct {
+	Endpoint   string `mapstructure:"endpoint"`     // Service URL, e.g. https://minio.example.com; empty uses AWS S3
+	Region     string `mapstructure:"region"`       // Signing region; empty uses us-east-1
+	PathStyle  bool   `mapstructure:"path_style"`   // Put the bucket in the path instead of the host name, as MinIO needs
+	PartSizeMB int    `mapstructure:"part_size_mb"` // Multipart upload part size, at least 5; 0 uses 8
+}
+
+// TrashConfig holds settings for undoing deletions
+type TrashConfig struct {
+	RetentionDays int `mapstructure:"retention_days"` // Keep deleted workspaces and jobs this long; 0 deletes immediately
+}
+
+// AuditConfig holds settings for the audit log of executed queries
+type AuditConfig struct {
+	Enabled       bool `mapstructure:"enabled"`        // Record every query with its interface and user
+	RetentionDays int  `mapstructure:"retention_days"` // Keep entries this long; 0 keeps them all
+}
+
+// QueryConfig holds settings of queries run in the interactive shell
+type QueryConfig struct {
+	// Results are read into memory up to max_result_rows rows and
+	// max_result_mb MB; table output of a larger result shows its first
+	// rows, other output is refused in favour of LIMIT or an export job.
+	// 0 is unlimited.
+	MaxResultRows int `mapstructure:"max_result_rows"`
+	MaxResultMB   int `mapstructure:"max_result_mb"`
+}
+
+// ResultLimits returns the limits shell query results are read with
+func (q QueryConfig) ResultLimits() datasource.ResultLimits {
+	limits := datasource.ResultLimits{MaxRows: q.MaxResultRows}
+	if q.MaxResultMB > 0 {
+		limits.MaxBytes = int64(q.MaxResultMB) << 20
+	}
+	return limits
+}
+
+// UIConfig holds settings of the interactive shell's full-screen views
+type UIConfig struct {
+	Mouse bool       `mapstructure:"mouse"` // Click and scroll in the status bar, .results, jobs top and the schema browser
+	Keys  KeysConfig `mapstructure:"keys"`
+
+	// Prompt is the shell prompt template, such as "{ws}:{source} [{jobs}]> ";
+	// workspaces can set their own
+	Prompt string `mapstructure:"prompt"`
+}
+
+// KeysConfig overrides key bindings by key name, such as "ctrl+j" or "f5";
+// binding a key to "" removes it
+type KeysConfig struct {
+	Shell map[string]string `mapstructure:"shell"` // Key to the command it runs at the prompt
+	Top   map[string]string `mapstructure:"top"`   // Key to a jobs top action, such as sort or refresh
+}
+
+// DataSourceConfig holds settings for one data source
+type DataSourceConfig struct {
+	Enabled             bool     `mapstructure:"enabled"`
+	RateLimit           int      `mapstructure:"rate_limit"`            // API requests per second; 0 uses the source's default
+	BatchSize           int      `mapstructure:"batch_size"`            // Items per download batch; 0 uses the source's default
+	Workers             int      `mapstructure:"workers"`               // Items of a batch fetched at the same time; 0 uses the source's default
+	APIBaseURL          string   `mapstructure:"api_base_url"`          // Base URL of the source's API, such as a mirror; empty uses the official one
+	SyncSchedule        string   `mapstructure:"sync_schedule"`         // Cron expression for a recurring download; empty disables
+	UserRefreshSchedule string   `mapstructure:"user_refresh_schedule"` // Cron expression for refreshing user profiles and karma snapshots; empty disables
+	SnapshotSchedule    string   `mapstructure:"snapshot_schedule"`     // Cron expression for capturing list rankings such as the front page; empty disables
+	RepairSchedule      string   `mapstructure:"repair_schedule"`       // Cron expression for re-fetching dead, deleted and failed items; empty disables
+	RecheckHours        int      `mapstructure:"recheck_hours"`         // Hours before a dead, deleted or missing item is fetched again; 0 uses the source's default
+	EnrichSchedule      string   `mapstructure:"enrich_schedule"`       // Cron expression for enriching new item text; empty disables
+	Enrichers           []string `mapstructure:"enrichers"`             // Enrichers run by the schedule, e.g. language, words, sentiment; empty runs all
+	TrackChanges        bool     `mapstructure:"track_changes"`         // Record before/after values of updated items, such as scores, in item_changes
+	ChangeRetentionDays int      `mapstructure:"change_retention_days"` // Days recorded item changes are kept; 0 keeps them
+	QuotaMB             int64    `mapstructure:"quota_mb"`              // Storage the source's directory may use before downloads pause; 0 is unlimited
+	ArchiveSchedule     string   `mapstructure:"archive_schedule"`      // Cron expression for moving old items into compressed yearly archives; empty disables
+	ArchiveAfterDays    int      `mapstructure:"archive_after_days"`    // Age in days of the items the archive schedule moves; 0 uses 365
+	Transforms          []string `mapstructure:"transforms"`            // Starlark scripts, relative to the config directory, run in order on each item before it is stored
+	TransformTimeoutMs  int      `mapstructure:"transform_timeout_ms"`  // Time a transform hook may run on one item; 0 uses 100
+	ConflictStrategy    string   `mapstructure:"conflict_strategy"`     // How stored items are updated when downloaded again: keep-newest (default), keep-highest-score or merge-non-null
+
+	// JobLimits caps the jobs of each type running at the same time for
+	// the source, such as {download: 1, export: 2}; others wait their turn
+	JobLimits map[string]int `mapstructure:"job_limits"`
+}
+
+// SourceQuotas returns the storage quotas of the data sources in MB, keyed
+// by source name; sources without one are left out
+func (c Config) SourceQuotas() map[string]int64 {
+	quotas := make(map[string]int64)
+	for name, sourceConfig := range c.DataSources {
+		if sourceConfig.QuotaMB > 0 {
+			quotas[name] = sourceConfig.QuotaMB
+		}
+	}
+	return quotas
+}
+
+// SourceEnabled reports whether a data source is enabled; sources missing
+// from the config are enabled
+func (c Config) SourceEnabled(name string) bool {
+	sourceConfig, ok := c.DataSources[name]
+	return !ok || sourceConfig.Enabled
+}
+
+// KnownSources are the names of the data sources PubDataHub supports
+var KnownSources = []string{"hackernews"}
+
+// CheckSource returns an error unless name is a supported data source
+func CheckSource(name string) error {
+	for _, source := range KnownSources {
+		if source == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown data source %q (supported: %s)", name, strings.Join(KnownSources, ", "))
+}
+
+// SetSourceEnabled enables or disables a supported data source in the
+// data_sources section; disabled sources are not opened by the shell, the
+// API or the daemon, so their scheduled jobs do not run
+func SetSourceEnabled(name string, enabled bool, origin string) error {
+	if err := CheckSource(name); err != nil {
+		return err
+	}
+	return Save(map[string]interface{}{"data_sources." + name + ".enabled": enabled}, origin)
+}
+
+// SetSourceSetting checks a per-source setting such as batch_size against
+// the ranges of the data source's descriptor and stores it under
+// data_sources.<source>
+func SetSourceSetting(source, key, value, origin string) error {
+	descriptor, err := datasource.LookupDescriptor(source)
+	if err != nil {
+		return err
+	}
+	parsed, err := descriptor.Parse(key, value)
+	if err != nil {
+		return err
+	}
+	return Save(map[string]interface{}{"data_sources." + source + "." + key: parsed}, origin)
+}
+
+// SourceSetting returns the configured value of a per-source setting, or
+// its default with false when it is not set; 0 and empty values are unset
+func SourceSetting(source string, setting datasource.Setting) (string, bool) {
+	value, ok := Get("data_sources." + source + "." + setting.Key)
+	if !ok || value == "" || value == "0" {
+		return setting.Default, false
+	}
+	return value, true
+}
+
+// InvalidSourceSettings returns the errors of the per-source settings that
+// are outside the ranges of the data source's descriptor, keyed by setting,
+// such as values set with config set or by editing the file
+func InvalidSourceSettings(source string) map[string]error {
+	descriptor, err := datasource.LookupDescriptor(source)
+	if err != nil {
+		return nil
+	}
+	invalid := make(map[string]error)
+	for _, setting := range descriptor.Settings {
+		if value, set := SourceSetting(source, setting); set {
+			if _, err := setting.Parse(value); err != nil {
+				invalid[setting.Key] = err
+			}
+		}
+	}
+	return invalid
+}
+
+// StorageConfig holds database layout settings
+type StorageConfig struct {
+	Layout string `mapstructure:"layout"` // "separate" database per component or one "shared" database
+
+	// Permissions of created databases, logs and other files, in octal. A
+	// storage path shared by several OS users of one group needs 0664/0775.
+	FileMode string `mapstructure:"file_mode"`
+	DirMode  string `mapstructure:"dir_mode"`
+	Umask    string `mapstructure:"umask"` // Process umask in octal; empty keeps the inherited one
+
+	QuotaWarnPercent int `mapstructure:"quota_warn_percent"` // Warn once a source uses this much of its quota_mb
+}
+
+// HTTPConfig holds settings for HTTP clients used by data sources
+type HTTPConfig struct {
+	HTTPClientConfig `mapstructure:",squash"`
+	Cache            HTTPCacheConfig             `mapstructure:"cache"`
+	Sources          map[string]HTTPClientConfig `mapstructure:"sources"` // Per-source overrides of the global settings
+}
+
+// HTTPClientConfig holds connection settings for data source API clients
+type HTTPClientConfig struct {
+	Proxy          string `mapstructure:"proxy"`           // http(s):// or socks5:// proxy URL
+	CABundle       string `mapstructure:"ca_bundle"`       // PEM file of extra trusted CA certificates
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"` // Request timeout
+	UserAgent      string `mapstructure:"user_agent"`      // User-Agent header override
+}
+
+// HTTPCacheConfig holds HTTP response cache settings
+type HTTPCacheConfig struct {
+	Enabled   bool  `mapstructure:"enabled"`     // Cache API responses under storage_path/cache/http
+	MaxSizeMB int64 `mapstructure:"max_size_mb"` // Evict least recently used responses above this size; 0 is unlimited
+}
+
+// DownloadConfig holds download settings
+type DownloadConfig struct {
+	MinFreeMB    int64 `mapstructure:"min_free_mb"`    // Pause downloads below this much free disk space; 0 disables
+	ResumeFreeMB int64 `mapstructure:"resume_free_mb"` // Resume paused downloads once free space reaches this
+}
+
+// LogConfig holds logging settings
+type LogConfig struct {
+	Levels     map[string]string `mapstructure:"levels"` // Per-component level overrides
+	File       bool              `mapstructure:"file"`   // Write rotating log files under storage_path/logs
+	MaxSizeMB  int               `mapstructure:"max_size_mb"`
+	MaxAgeDays int               `mapstructure:"max_age_days"`
+	MaxBackups int               `mapstructure:"max_backups"`
 }
 
 var AppConfig Config
 
+// firstRun is set when InitConfig created the config file
+var firstRun bool
+
+// configDir is the directory of the config file, set by InitConfig
+var configDir string
+
+// Dir returns the directory holding the config file and other per-user
+// settings such as secrets
+func Dir() string {
+	return configDir
+}
+
+// ResolveDir returns the directory InitConfig reads the config from:
+// PUBDATAHUB_CONFIG_PATH, or else .pubdatahub in the home directory. Unlike
+// Dir it can be called before InitConfig.
+func ResolveDir() (string, error) {
+	if configPath := os.Getenv("PUBDATAHUB_CONFIG_PATH"); configPath != "" {
+		return configPath, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".pubdatahub"), nil
+}
+
+// FirstRun reports whether the config file did not exist before this run
+func FirstRun() bool {
+	return firstRun
+}
+
 func InitConfig() error {
+	firstRun = false
 	configName := "config"
 	configType := "json"
-	configPath := os.Getenv("PUBDATAHUB_CONFIG_PATH")
-	if configPath == "" {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get user home directory: %w", err)
-		}
-		configPath = filepath.Join(homeDir, ".pubdatahub")
+	configPath, err := ResolveDir()
+	if err != nil {
+		return err
 	}
+	configDir = configPath
 
 	viper.AddConfigPath(configPath)
 	viper.SetConfigName(configName)
 	viper.SetConfigType(configType)
 
 	viper.SetDefault("storage_path", filepath.Join(configPath, "data"))
+	viper.SetDefault("log.file", true)
+	viper.SetDefault("log.max_size_mb", 10)
+	viper.SetDefault("log.max_age_days", 14)
+	viper.SetDefault("log.max_backups", 5)
+	viper.SetDefault("download.min_free_mb", 500)
+	viper.SetDefault("download.resume_free_mb", 1024)
+	viper.SetDefault("http.timeout_seconds", 30)
+	viper.SetDefault("http.cache.enabled", true)
+	viper.SetDefault("http.cache.max_size_mb", 256)
+	viper.SetDefault("storage.layout", "separate")
+	viper.SetDefault("storage.file_mode", "0644")
+	viper.SetDefault("storage.dir_mode", "0755")
+	viper.SetDefault("storage.quota_warn_percent", 90)
+	viper.SetDefault("jobs.workers", 4)
+	viper.SetDefault("jobs.timeout_minutes", 120)
+	viper.SetDefault("jobs.max_retries", 3)
+	viper.SetDefault("jobs.retry_delay_seconds", 60)
+	viper.SetDefault("jobs.graceful_timeout_seconds", 30)
+	viper.SetDefault("jobs.drain_policy", "pause")
+	viper.SetDefault("trash.retention_days", 7)
+	viper.SetDefault("audit.enabled", true)
+	viper.SetDefault("audit.retention_days", 30)
+	viper.SetDefault("query.max_result_rows", 100000)
+	viper.SetDefault("query.max_result_mb", 256)
+	viper.SetDefault("api.auth", true)
+	viper.SetDefault("ssh.listen", ":2222")
+	viper.SetDefault("ui.mouse", true)
+	viper.SetDefault("data_sources.hackernews.enabled", true)
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			// Config file not found, create a default one
+			firstRun = true
 			fmt.Printf("Config file not found, creating default at %s/%s.%s\n", configPath, configName, configType)
 			if err := os.MkdirAll(configPath, 0755); err != nil {
 				return fmt.Errorf("failed to create config directory: %w", err)
@@ -47,6 +430,8 @@ func InitConfig() error {
 		}
 	}
 
+	// Start from zero values, so settings removed from the file are cleared
+	AppConfig = Config{}
 	if err := viper.Unmarshal(&AppConfig); err != nil {
 		return fmt.Errorf("failed to unmarshal config: %w", err)
 	}
@@ -59,7 +444,51 @@ func InitConfig() error {
 	return nil
 }
 
-func SetStoragePath(path string) error {
-	viper.Set("storage_path", path)
-	return viper.WriteConfig()
+// SetStoragePath stores the storage path and reloads AppConfig
+func SetStoragePath(path, origin string) error {
+	return Set("storage_path", path, origin)
+}
+
+// Set stores a dotted config key (e.g. log.levels.jobs), records the change
+// in the history with its origin and reloads AppConfig
+func Set(key, value, origin string) error {
+	return Save(map[string]interface{}{key: value}, origin)
+}
+
+// Get returns a dotted config key formatted as text, and whether it is set
+func Get(key string) (string, bool) {
+	if !viper.IsSet(key) {
+		return "", false
+	}
+	return fmt.Sprint(viper.Get(key)), true
+}
+
+// Keys returns the dotted keys of every setting, including defaults
+func Keys() []string {
+	return viper.AllKeys()
+}
+
+// Save stores several config keys with one write, records the changes in
+// the history with their origin and reloads AppConfig
+func Save(values map[string]interface{}, origin string) error {
+	previous := viper.AllSettings()
+	var changes []Change
+	for key, value := range values {
+		old, _ := Get(key)
+		changes = append(changes, Change{Key: key, Old: old, New: fmt.Sprint(value)})
+		viper.Set(key, value)
+	}
+	if err := viper.WriteConfig(); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := viper.Unmarshal(&AppConfig); err != nil {
+		return fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return recordChange(origin, previous, changes)
+}
+
+// LogDir returns the directory for rotating log files
+func LogDir() string {
+	return filepath.Join(AppConfig.StoragePath, "logs")
 }
@@ -0,0 +1,237 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// HistoryFile is the config change history in the config directory, one
+// JSON entry per line
+const HistoryFile = "config_history.jsonl"
+
+// historyLimit is how many changes the history keeps
+const historyLimit = 100
+
+// Origins of config changes
+const (
+	OriginCLI   = "cli"
+	OriginShell = "shell"
+	OriginSetup = "setup"
+	OriginGRPC  = "grpc"
+)
+
+// Change is a config key changed from Old to New; an empty value means the
+// key was not set
+type Change struct {
+	Key string `json:"key"`
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// String formats the change as "key: old -> new"
+func (c Change) String() string {
+	old, value := c.Old, c.New
+	if old == "" {
+		old = "(unset)"
+	}
+	if value == "" {
+		value = "(unset)"
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Key, old, value)
+}
+
+// HistoryEntry is one recorded config change with the settings before it,
+// which a rollback restores
+type HistoryEntry struct {
+	ID       int                    `json:"id"`
+	Time     time.Time              `json:"time"`
+	Origin   string                 `json:"origin"`
+	Changes  []Change               `json:"changes"`
+	Previous map[string]interface{} `json:"previous"`
+}
+
+// ErrHistoryNotFound is returned when rolling back a change that is not in
+// the history
+var ErrHistoryNotFound = errors.New("config change not found in history")
+
+// historyPath returns the path of the history file
+func historyPath() string {
+	return filepath.Join(configDir, HistoryFile)
+}
+
+// History returns the recorded config changes, oldest first
+func History() ([]HistoryEntry, error) {
+	file, err := os.Open(historyPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read config history: %w", err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to read config history: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config history: %w", err)
+	}
+	return entries, nil
+}
+
+// recordChange appends a change to the history, keeping the last
+// historyLimit changes. Unchanged keys are left out; nothing is recorded
+// when no key changed.
+func recordChange(origin string, previous map[string]interface{}, changes []Change) error {
+	var changed []Change
+	for _, change := range changes {
+		if change.Old != change.New {
+			changed = append(changed, change)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	entries, err := History()
+	if err != nil {
+		return err
+	}
+	id := 1
+	if len(entries) > 0 {
+		id = entries[len(entries)-1].ID + 1
+	}
+	entries = append(entries, HistoryEntry{
+		ID:       id,
+		Time:     time.Now(),
+		Origin:   origin,
+		Changes:  changed,
+		Previous: previous,
+	})
+	if len(entries) > historyLimit {
+		entries = entries[len(entries)-historyLimit:]
+	}
+
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to record config change: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	// The settings may include API tokens, so only the user can read them
+	if err := os.WriteFile(historyPath(), data, 0600); err != nil {
+		return fmt.Errorf("failed to record config change: %w", err)
+	}
+	return nil
+}
+
+// Rollback restores the settings from before change id, undoing it and
+// every later change, and reloads AppConfig. The rollback is recorded as a
+// change by origin itself, so it can be rolled back too.
+func Rollback(id int, origin string) ([]Change, error) {
+	entries, err := History()
+	if err != nil {
+		return nil, err
+	}
+	var target *HistoryEntry
+	for i := range entries {
+		if entries[i].ID == id {
+			target = &entries[i]
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("change %d: %w", id, ErrHistoryNotFound)
+	}
+
+	previous := viper.AllSettings()
+	changes := diffSettings(flattenSettings(previous), flattenSettings(target.Previous))
+
+	data, err := json.MarshalIndent(target.Previous, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore config: %w", err)
+	}
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		path = filepath.Join(configDir, "config.json")
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write config: %w", err)
+	}
+
+	// Values set in this process override the file, so viper starts over
+	viper.Reset()
+	if err := InitConfig(); err != nil {
+		return nil, err
+	}
+	if err := recordChange(fmt.Sprintf("%s (rollback to before %d)", origin, id), previous, changes); err != nil {
+		return changes, err
+	}
+	return changes, nil
+}
+
+// flattenSettings returns nested settings as dotted keys with text values
+func flattenSettings(settings map[string]interface{}) map[string]string {
+	flat := make(map[string]string)
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		if nested, ok := value.(map[string]interface{}); ok {
+			for key, value := range nested {
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				walk(key, value)
+			}
+			return
+		}
+		switch v := value.(type) {
+		case nil:
+		case float64:
+			// Numbers read back from the history are floats
+			flat[prefix] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			flat[prefix] = fmt.Sprint(v)
+		}
+	}
+	walk("", settings)
+	return flat
+}
+
+// diffSettings returns the changes from one set of flattened settings to
+// another, sorted by key
+func diffSettings(from, to map[string]string) []Change {
+	var changes []Change
+	for key, old := range from {
+		if value := to[key]; value != old {
+			changes = append(changes, Change{Key: key, Old: old, New: value})
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			changes = append(changes, Change{Key: key, New: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/datasource"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
@@ -59,7 +60,7 @@ func TestSetStoragePath(t *testing.T) {
 	config.InitConfig()
 
 	newPath := filepath.Join(testConfigPath, "new_storage")
-	err := config.SetStoragePath(newPath)
+	err := config.SetStoragePath(newPath, config.OriginCLI)
 	assert.NoError(t, err)
 
 	// Verify by re-initializing config and checking the path
@@ -81,3 +82,185 @@ func dirExists(path string) bool {
 	info, err := os.Stat(path)
 	return !os.IsNotExist(err) && info.IsDir()
 }
+
+func TestHTTPConfig(t *testing.T) {
+	testConfigPath := filepath.Join(t.TempDir(), ".pubdatahub_test_http")
+	os.Setenv("PUBDATAHUB_CONFIG_PATH", testConfigPath)
+	defer os.Unsetenv("PUBDATAHUB_CONFIG_PATH")
+	viper.Reset()
+
+	assert.NoError(t, config.InitConfig())
+	assert.Equal(t, 30, config.AppConfig.HTTP.TimeoutSeconds)
+	assert.True(t, config.AppConfig.HTTP.Cache.Enabled)
+
+	assert.NoError(t, config.Set("http.proxy", "socks5://127.0.0.1:1080", config.OriginCLI))
+	assert.NoError(t, config.Set("http.sources.hackernews.user_agent", "PubDataHub-test", config.OriginCLI))
+	assert.Equal(t, "socks5://127.0.0.1:1080", config.AppConfig.HTTP.Proxy)
+	assert.Equal(t, "PubDataHub-test", config.AppConfig.HTTP.Sources["hackernews"].UserAgent)
+}
+
+func TestStorageConfig(t *testing.T) {
+	testConfigPath := filepath.Join(t.TempDir(), ".pubdatahub_test_storage")
+	os.Setenv("PUBDATAHUB_CONFIG_PATH", testConfigPath)
+	defer os.Unsetenv("PUBDATAHUB_CONFIG_PATH")
+	viper.Reset()
+
+	assert.NoError(t, config.InitConfig())
+	assert.Equal(t, "separate", config.AppConfig.Storage.Layout)
+	assert.Equal(t, "0644", config.AppConfig.Storage.FileMode)
+	assert.Equal(t, "0755", config.AppConfig.Storage.DirMode)
+	assert.Empty(t, config.AppConfig.Storage.Umask)
+
+	assert.NoError(t, config.Set("storage.layout", "shared", config.OriginCLI))
+	assert.Equal(t, "shared", config.AppConfig.Storage.Layout)
+	assert.NoError(t, config.Set("storage.file_mode", "0664", config.OriginCLI))
+	assert.Equal(t, "0664", config.AppConfig.Storage.FileMode)
+}
+
+func TestFirstRunAndSave(t *testing.T) {
+	testConfigPath := filepath.Join(t.TempDir(), ".pubdatahub_test_setup")
+	os.Setenv("PUBDATAHUB_CONFIG_PATH", testConfigPath)
+	defer os.Unsetenv("PUBDATAHUB_CONFIG_PATH")
+	viper.Reset()
+
+	assert.NoError(t, config.InitConfig())
+	assert.True(t, config.FirstRun())
+	assert.Equal(t, 4, config.AppConfig.Jobs.Workers)
+	assert.Equal(t, 7, config.AppConfig.Trash.RetentionDays)
+	assert.True(t, config.AppConfig.API.Auth)
+	assert.Empty(t, config.AppConfig.API.Tokens)
+	assert.True(t, config.AppConfig.SourceEnabled("hackernews"))
+	assert.True(t, config.AppConfig.SourceEnabled("unknown"))
+
+	storagePath := filepath.Join(testConfigPath, "setup_data")
+	assert.NoError(t, config.Save(map[string]interface{}{
+		"storage_path":                          storagePath,
+		"jobs.workers":                          2,
+		"data_sources.hackernews.enabled":       false,
+		"data_sources.hackernews.rate_limit":    5,
+		"data_sources.hackernews.sync_schedule": "0 2 * * *",
+	}, config.OriginSetup))
+
+	viper.Reset()
+	assert.NoError(t, config.InitConfig())
+	assert.False(t, config.FirstRun())
+	assert.Equal(t, storagePath, config.AppConfig.StoragePath)
+	assert.Equal(t, 2, config.AppConfig.Jobs.Workers)
+	assert.False(t, config.AppConfig.SourceEnabled("hackernews"))
+	assert.Equal(t, 5, config.AppConfig.DataSources["hackernews"].RateLimit)
+	assert.Equal(t, "0 2 * * *", config.AppConfig.DataSources["hackernews"].SyncSchedule)
+}
+
+func TestSetSourceEnabled(t *testing.T) {
+	testConfigPath := filepath.Join(t.TempDir(), ".pubdatahub_test_sources")
+	os.Setenv("PUBDATAHUB_CONFIG_PATH", testConfigPath)
+	defer os.Unsetenv("PUBDATAHUB_CONFIG_PATH")
+	viper.Reset()
+	assert.NoError(t, config.InitConfig())
+
+	assert.NoError(t, config.SetSourceEnabled("hackernews", false, config.OriginCLI))
+	assert.False(t, config.AppConfig.SourceEnabled("hackernews"))
+
+	viper.Reset()
+	assert.NoError(t, config.InitConfig())
+	assert.False(t, config.AppConfig.SourceEnabled("hackernews"))
+
+	assert.NoError(t, config.SetSourceEnabled("hackernews", true, config.OriginShell))
+	assert.True(t, config.AppConfig.SourceEnabled("hackernews"))
+
+	assert.Error(t, config.SetSourceEnabled("reddit", true, config.OriginCLI))
+	_, set := config.Get("data_sources.reddit.enabled")
+	assert.False(t, set)
+}
+
+func TestSetSourceSetting(t *testing.T) {
+	testConfigPath := filepath.Join(t.TempDir(), ".pubdatahub_test_source_settings")
+	os.Setenv("PUBDATAHUB_CONFIG_PATH", testConfigPath)
+	defer os.Unsetenv("PUBDATAHUB_CONFIG_PATH")
+	viper.Reset()
+	assert.NoError(t, config.InitConfig())
+
+	datasource.RegisterDescriptor(datasource.Descriptor{
+		Name: "settingsource",
+		Settings: []datasource.Setting{
+			{Key: "batch_size", Default: "100", Min: 1, Max: 10000},
+			{Key: "api_base_url", Default: "https://api.example.com", URL: true},
+		},
+	})
+	descriptor, err := datasource.LookupDescriptor("settingsource")
+	assert.NoError(t, err)
+	batchSize, _ := descriptor.Setting("batch_size")
+
+	value, set := config.SourceSetting("settingsource", batchSize)
+	assert.Equal(t, "100", value)
+	assert.False(t, set)
+
+	assert.NoError(t, config.SetSourceSetting("settingsource", "batch_size", "500", config.OriginCLI))
+	assert.Equal(t, 500, config.AppConfig.DataSources["settingsource"].BatchSize)
+	value, set = config.SourceSetting("settingsource", batchSize)
+	assert.Equal(t, "500", value)
+	assert.True(t, set)
+
+	assert.Error(t, config.SetSourceSetting("settingsource", "batch_size", "20000", config.OriginCLI))
+	assert.Error(t, config.SetSourceSetting("settingsource", "workers", "2", config.OriginCLI))
+	assert.Error(t, config.SetSourceSetting("missing", "batch_size", "10", config.OriginCLI))
+	assert.Equal(t, 500, config.AppConfig.DataSources["settingsource"].BatchSize)
+	assert.Empty(t, config.InvalidSourceSettings("settingsource"))
+
+	assert.NoError(t, config.Set("data_sources.settingsource.api_base_url", "not a url", config.OriginCLI))
+	invalid := config.InvalidSourceSettings("settingsource")
+	assert.Len(t, invalid, 1)
+	assert.Contains(t, invalid, "api_base_url")
+}
+
+func TestHistoryAndRollback(t *testing.T) {
+	testConfigPath := filepath.Join(t.TempDir(), ".pubdatahub_test_history")
+	os.Setenv("PUBDATAHUB_CONFIG_PATH", testConfigPath)
+	defer os.Unsetenv("PUBDATAHUB_CONFIG_PATH")
+	viper.Reset()
+
+	assert.NoError(t, config.InitConfig())
+	assert.NoError(t, config.Set("jobs.workers", "8", config.OriginCLI))
+	assert.NoError(t, config.Set("jobs.workers", "8", config.OriginCLI), "setting the same value records nothing")
+	assert.NoError(t, config.Set("http.proxy", "socks5://127.0.0.1:1080", config.OriginShell))
+	assert.NoError(t, config.Set("jobs.workers", "16", config.OriginGRPC))
+
+	history, err := config.History()
+	assert.NoError(t, err)
+	if assert.Len(t, history, 3) {
+		assert.Equal(t, 1, history[0].ID)
+		assert.Equal(t, config.OriginCLI, history[0].Origin)
+		assert.Equal(t, []config.Change{{Key: "jobs.workers", Old: "4", New: "8"}}, history[0].Changes)
+		assert.Equal(t, []config.Change{{Key: "http.proxy", New: "socks5://127.0.0.1:1080"}}, history[1].Changes)
+	}
+
+	// Rolling back change 2 undoes it and change 3
+	changes, err := config.Rollback(2, config.OriginCLI)
+	assert.NoError(t, err)
+	assert.Equal(t, []config.Change{
+		{Key: "http.proxy", Old: "socks5://127.0.0.1:1080"},
+		{Key: "jobs.workers", Old: "16", New: "8"},
+	}, changes)
+	assert.Equal(t, 8, config.AppConfig.Jobs.Workers)
+	assert.Empty(t, config.AppConfig.HTTP.Proxy)
+
+	// The file holds the restored settings
+	viper.Reset()
+	assert.NoError(t, config.InitConfig())
+	assert.Equal(t, 8, config.AppConfig.Jobs.Workers)
+	assert.Empty(t, config.AppConfig.HTTP.Proxy)
+
+	// The rollback is a change itself
+	history, err = config.History()
+	assert.NoError(t, err)
+	if assert.Len(t, history, 4) {
+		assert.Contains(t, history[3].Origin, "rollback")
+	}
+	_, err = config.Rollback(4, config.OriginCLI)
+	assert.NoError(t, err)
+	assert.Equal(t, 16, config.AppConfig.Jobs.Workers)
+	assert.Equal(t, "socks5://127.0.0.1:1080", config.AppConfig.HTTP.Proxy)
+
+	_, err = config.Rollback(99, config.OriginCLI)
+	assert.ErrorIs(t, err, config.ErrHistoryNotFound)
+}
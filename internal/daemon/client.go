@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/jobs"
+)
+
+// dialTimeout bounds connecting to the control socket
+const dialTimeout = 2 * time.Second
+
+// Client calls the daemon of a storage directory over its control socket
+type Client struct {
+	socketPath string
+	token      string
+}
+
+// NewClient creates a client for the daemon of a storage directory
+func NewClient(storagePath string) *Client {
+	return &Client{socketPath: SocketPath(storagePath)}
+}
+
+// WithToken returns a client that sends an API token with its requests, so
+// the daemon limits them to the token's role; "" sends none
+func (c *Client) WithToken(token string) *Client {
+	return &Client{socketPath: c.socketPath, token: token}
+}
+
+// call sends one request and returns the response; it returns ErrNotRunning
+// when nothing listens on the socket
+func (c *Client) call(req Request) (*Response, error) {
+	conn, err := net.DialTimeout("unix", c.socketPath, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	req.Token = c.token
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send %s request to daemon: %w", req.Op, err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read daemon response: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// Status returns the daemon's status
+func (c *Client) Status() (*Status, error) {
+	resp, err := c.call(Request{Op: OpStatus})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
+
+// Jobs lists the daemon's jobs matching filter
+func (c *Client) Jobs(filter jobs.JobFilter) ([]*jobs.JobStatus, error) {
+	resp, err := c.call(Request{Op: OpJobs, Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Jobs, nil
+}
+
+// Job returns the status of a job
+func (c *Client) Job(id string) (*jobs.JobStatus, error) {
+	resp, err := c.call(Request{Op: OpJob, JobID: id})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Job, nil
+}
+
+// Download starts a download job in the daemon; zero batchSize and priority
+// use the defaults
+func (c *Client) Download(source string, batchSize, priority int) (*jobs.JobStatus, error) {
+	resp, err := c.call(Request{Op: OpDownload, Source: source, BatchSize: batchSize, Priority: priority})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Job, nil
+}
+
+// Pause pauses a job
+func (c *Client) Pause(id string) error {
+	_, err := c.call(Request{Op: OpPause, JobID: id})
+	return err
+}
+
+// Resume resumes a paused job
+func (c *Client) Resume(id string) error {
+	_, err := c.call(Request{Op: OpResume, JobID: id})
+	return err
+}
+
+// Cancel cancels a job
+func (c *Client) Cancel(id string) error {
+	_, err := c.call(Request{Op: OpCancel, JobID: id})
+	return err
+}
+
+// Stop asks the daemon to stop its jobs and exit; WaitStopped waits for it
+func (c *Client) Stop() error {
+	_, err := c.call(Request{Op: OpStop})
+	return err
+}
+
+// WaitStopped waits until the daemon no longer answers
+func (c *Client) WaitStopped(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err := c.Status(); errors.Is(err, ErrNotRunning) {
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return fmt.Errorf("daemon did not stop within %s", timeout)
+}
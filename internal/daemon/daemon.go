@@ -0,0 +1,78 @@
+// Package daemon runs downloads, the scheduler and the job manager in a
+// background process that outlives the terminal. The daemon listens on a
+// local control socket in the storage directory; the CLI and the shell
+// attach to it to start downloads and manage jobs.
+package daemon
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/jobs"
+)
+
+// SocketFile is the name of the control socket in the storage directory
+const SocketFile = "pubdatahub.sock"
+
+// LockCommand is the command recorded in the instance lock held by the daemon
+const LockCommand = "daemon"
+
+// ErrNotRunning is returned by the client when no daemon listens on the socket
+var ErrNotRunning = errors.New("daemon is not running")
+
+// Operations understood by the control socket
+const (
+	OpStatus   = "status"
+	OpJobs     = "jobs"
+	OpJob      = "job"
+	OpDownload = "download"
+	OpPause    = "pause"
+	OpResume   = "resume"
+	OpCancel   = "cancel"
+	OpStop     = "stop"
+)
+
+// adminOps change state and need the admin role
+var adminOps = map[string]bool{
+	OpDownload: true,
+	OpPause:    true,
+	OpResume:   true,
+	OpCancel:   true,
+	OpStop:     true,
+}
+
+// SocketPath returns the control socket path for a storage directory
+func SocketPath(storagePath string) string {
+	return filepath.Join(storagePath, SocketFile)
+}
+
+// Request is one call on the control socket
+type Request struct {
+	Op        string         `json:"op"`
+	JobID     string         `json:"job_id,omitempty"`
+	Source    string         `json:"source,omitempty"`
+	BatchSize int            `json:"batch_size,omitempty"`
+	Priority  int            `json:"priority,omitempty"`
+	Filter    jobs.JobFilter `json:"filter"`
+	Token     string         `json:"token,omitempty"` // API token whose role limits the request
+}
+
+// Response answers a Request; Error is set when the call failed
+type Response struct {
+	Error  string            `json:"error,omitempty"`
+	Status *Status           `json:"status,omitempty"`
+	Jobs   []*jobs.JobStatus `json:"jobs,omitempty"`
+	Job    *jobs.JobStatus   `json:"job,omitempty"`
+}
+
+// Status describes a running daemon
+type Status struct {
+	PID         int               `json:"pid"`
+	StartedAt   time.Time         `json:"started_at"`
+	StoragePath string            `json:"storage_path"`
+	Sources     []string          `json:"sources"`
+	Stats       jobs.ManagerStats `json:"stats"`
+	Role        api.Scope         `json:"role"` // Role of the token that asked
+}
@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestDaemon serves a job manager with a mock data source on the
+// control socket of a temporary storage directory
+func startTestDaemon(t *testing.T) (*Server, *Client) {
+	t.Helper()
+	log.InitLogger(false)
+	storagePath := t.TempDir()
+
+	dataSources := map[string]datasource.DataSource{
+		"mock": datasource.NewMockDataSource("mock", "Mock data source"),
+	}
+	jobManager, err := jobs.NewEnhancedJobManager(storagePath, dataSources, jobs.DefaultManagerConfig())
+	require.NoError(t, err)
+	require.NoError(t, jobManager.Start())
+	t.Cleanup(func() { jobManager.Stop() })
+
+	listener, err := Listen(storagePath)
+	require.NoError(t, err)
+	server := NewServer(storagePath, jobManager, dataSources)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return server, NewClient(storagePath)
+}
+
+func TestClient_StatusAndDownload(t *testing.T) {
+	_, client := startTestDaemon(t)
+
+	status, err := client.Status()
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), status.PID)
+	assert.Equal(t, []string{"mock"}, status.Sources)
+
+	job, err := client.Download("mock", 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.JobTypeDownload, job.Type)
+
+	got, err := client.Job(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, got.ID)
+
+	list, err := client.Jobs(jobs.JobFilter{Types: []jobs.JobType{jobs.JobTypeDownload}})
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestClient_Errors(t *testing.T) {
+	_, client := startTestDaemon(t)
+
+	_, err := client.Download("missing", 0, 0)
+	assert.ErrorContains(t, err, "unknown data source")
+	_, err = client.Download("mock", 0, 99)
+	assert.ErrorContains(t, err, "priority")
+	assert.Error(t, client.Pause("no-such-job"))
+}
+
+func TestClient_Stop(t *testing.T) {
+	server, client := startTestDaemon(t)
+
+	require.NoError(t, client.Stop())
+	select {
+	case <-server.Stopped():
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not report the stop request")
+	}
+
+	server.Close()
+	assert.NoError(t, client.WaitStopped(5*time.Second))
+}
+
+func TestClient_Roles(t *testing.T) {
+	server, client := startTestDaemon(t)
+	auth, err := api.NewAuthenticator([]api.Token{
+		{Name: "viewer", Value: "read-token-0123456789", Scope: api.ScopeRead},
+		{Name: "operator", Value: "admin-token-0123456789", Scope: api.ScopeAdmin},
+	})
+	require.NoError(t, err)
+	server.SetAuth(auth)
+
+	reader := client.WithToken("read-token-0123456789")
+	status, err := reader.Status()
+	require.NoError(t, err)
+	assert.Equal(t, api.ScopeRead, status.Role)
+	_, err = reader.Jobs(jobs.JobFilter{})
+	assert.NoError(t, err)
+	_, err = reader.Download("mock", 10, 0)
+	assert.ErrorContains(t, err, "needs role admin")
+	assert.ErrorContains(t, reader.Pause("no-such-job"), "needs role admin")
+	assert.ErrorContains(t, reader.Stop(), "needs role admin")
+
+	_, err = client.WithToken("not-a-real-token-at-all").Status()
+	assert.ErrorContains(t, err, "invalid API token")
+
+	status, err = client.WithToken("admin-token-0123456789").Status()
+	require.NoError(t, err)
+	assert.Equal(t, api.ScopeAdmin, status.Role)
+
+	// Local clients without a token keep full control
+	status, err = client.Status()
+	require.NoError(t, err)
+	assert.Equal(t, api.ScopeAdmin, status.Role)
+}
+
+func TestClient_NotRunning(t *testing.T) {
+	client := NewClient(t.TempDir())
+	_, err := client.Status()
+	assert.True(t, errors.Is(err, ErrNotRunning))
+}
+
+func TestListen_RefusesRunningDaemon(t *testing.T) {
+	storagePath := t.TempDir()
+	log.InitLogger(false)
+	jobManager, err := jobs.NewEnhancedJobManager(storagePath, map[string]datasource.DataSource{}, jobs.DefaultManagerConfig())
+	require.NoError(t, err)
+
+	listener, err := Listen(storagePath)
+	require.NoError(t, err)
+	server := NewServer(storagePath, jobManager, nil)
+	go server.Serve(listener)
+	defer server.Close()
+
+	info, err := os.Stat(SocketPath(storagePath))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = Listen(storagePath)
+	assert.ErrorContains(t, err, "already running")
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package daemon
+
+import "syscall"
+
+// detachedProcAttr starts the daemon in its own session so it has no
+// controlling terminal and survives the terminal closing
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+// +build windows
+
+package daemon
+
+import "syscall"
+
+// detachedProcess is the DETACHED_PROCESS creation flag: the daemon gets no
+// console and survives the console window closing
+const detachedProcess = 0x00000008
+
+// detachedProcAttr starts the daemon without a console
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
@@ -0,0 +1,246 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// requestTimeout bounds how long one control connection may take
+const requestTimeout = 30 * time.Second
+
+// Server answers control socket requests with the daemon's job manager
+type Server struct {
+	jobManager  *jobs.EnhancedJobManager
+	dataSources map[string]datasource.DataSource
+	storagePath string
+	startedAt   time.Time
+	auth        *api.Authenticator
+
+	mu       sync.Mutex
+	listener net.Listener
+	closed   bool
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+// NewServer creates a control server for the job manager and data sources
+func NewServer(storagePath string, jobManager *jobs.EnhancedJobManager, dataSources map[string]datasource.DataSource) *Server {
+	return &Server{
+		jobManager:  jobManager,
+		dataSources: dataSources,
+		storagePath: storagePath,
+		startedAt:   time.Now(),
+		stopped:     make(chan struct{}),
+	}
+}
+
+// SetAuth makes requests that carry a token subject to its role, so a read
+// token cannot start downloads or control jobs. Requests without a token
+// come from the socket's owner and have the admin role.
+func (s *Server) SetAuth(auth *api.Authenticator) {
+	s.auth = auth
+}
+
+// Listen opens the control socket of a storage directory, replacing a socket
+// left behind by a daemon that did not shut down cleanly. The socket is only
+// accessible to its owner.
+func Listen(storagePath string) (net.Listener, error) {
+	if _, err := NewClient(storagePath).Status(); err == nil {
+		return nil, fmt.Errorf("a daemon is already running for %s", storagePath)
+	}
+
+	path := SocketPath(storagePath)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove old control socket: %w", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict control socket: %w", err)
+	}
+	return listener, nil
+}
+
+// Serve answers requests on listener until Close is called
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return fmt.Errorf("failed to accept control connection: %w", err)
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops accepting requests and removes the socket
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// Stopped is closed when a client asks the daemon to stop
+func (s *Server) Stopped() <-chan struct{} {
+	return s.stopped
+}
+
+// handleConn answers the single request of a connection
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	var req Request
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		resp.Error = fmt.Sprintf("invalid request: %v", err)
+	} else if err := s.handle(req, &resp); err != nil {
+		resp.Error = err.Error()
+	}
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		log.ForComponent("daemon").Warnf("Failed to answer %s request: %v", req.Op, err)
+	}
+	if req.Op == OpStop && resp.Error == "" {
+		s.stopOnce.Do(func() { close(s.stopped) })
+	}
+}
+
+// role returns the role of the token sent with a request
+func (s *Server) role(req Request) (api.Scope, error) {
+	if req.Token == "" {
+		return api.ScopeAdmin, nil
+	}
+	if s.auth == nil {
+		return "", fmt.Errorf("this daemon does not check API tokens (api.auth is false); connect without one")
+	}
+	token, ok := s.auth.Authenticate(req.Token)
+	if !ok {
+		log.ForComponent("daemon").Warnf("Rejected %s request: invalid token", req.Op)
+		return "", fmt.Errorf("invalid API token")
+	}
+	if adminOps[req.Op] && token.Scope != api.ScopeAdmin {
+		log.ForComponent("daemon").Warnf("Rejected %s request by token %s: needs role %s", req.Op, token.Name, api.ScopeAdmin)
+		return "", fmt.Errorf("token %s has role %s; %s needs role %s", token.Name, token.Scope, req.Op, api.ScopeAdmin)
+	}
+	return token.Scope, nil
+}
+
+// handle runs a request and fills in the response
+func (s *Server) handle(req Request, resp *Response) error {
+	logger := log.ForComponent("daemon")
+
+	role, err := s.role(req)
+	if err != nil {
+		return err
+	}
+
+	switch req.Op {
+	case OpStatus:
+		resp.Status = s.status()
+		resp.Status.Role = role
+	case OpJobs:
+		list, err := s.jobManager.ListJobs(req.Filter)
+		if err != nil {
+			return fmt.Errorf("failed to list jobs: %w", err)
+		}
+		resp.Jobs = list
+	case OpJob:
+		status, err := s.jobManager.GetJob(req.JobID)
+		if err != nil {
+			return err
+		}
+		resp.Job = status
+	case OpDownload:
+		status, err := s.startDownload(req)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Started download job %s for %s from the control socket", status.ID, req.Source)
+		resp.Job = status
+	case OpPause:
+		if err := s.jobManager.PauseJob(req.JobID); err != nil {
+			return fmt.Errorf("failed to pause job: %w", err)
+		}
+	case OpResume:
+		if err := s.jobManager.ResumeJob(req.JobID); err != nil {
+			return fmt.Errorf("failed to resume job: %w", err)
+		}
+	case OpCancel:
+		if err := s.jobManager.CancelJob(req.JobID); err != nil {
+			return fmt.Errorf("failed to cancel job: %w", err)
+		}
+	case OpStop:
+		logger.Info("Stop requested on the control socket")
+	default:
+		return fmt.Errorf("unknown operation %q", req.Op)
+	}
+	return nil
+}
+
+// status describes the daemon
+func (s *Server) status() *Status {
+	sources := make([]string, 0, len(s.dataSources))
+	for name := range s.dataSources {
+		sources = append(sources, name)
+	}
+	sort.Strings(sources)
+
+	return &Status{
+		PID:         os.Getpid(),
+		StartedAt:   s.startedAt,
+		StoragePath: s.storagePath,
+		Sources:     sources,
+		Stats:       s.jobManager.GetStats(),
+	}
+}
+
+// startDownload starts a download job, using the defaults of the shell's
+// download command for a zero batch size or priority
+func (s *Server) startDownload(req Request) (*jobs.JobStatus, error) {
+	ds, ok := s.dataSources[req.Source]
+	if !ok {
+		return nil, fmt.Errorf("unknown data source: %s", req.Source)
+	}
+
+	batchSize := req.BatchSize
+	if batchSize == 0 {
+		batchSize = 100
+	}
+	priority := jobs.JobPriority(req.Priority)
+	if priority == 0 {
+		priority = jobs.PriorityNormal
+	}
+
+	jobID, err := s.jobManager.StartSourceDownload(req.Source, ds, batchSize, priority)
+	if err != nil {
+		return nil, err
+	}
+	return s.jobManager.GetJob(jobID)
+}
@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// Start runs the daemon command in the background, detached from the
+// terminal, with its output appended to logPath
+func Start(command *exec.Cmd, logPath string) error {
+	logFile, err := storage.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	if err != nil {
+		return fmt.Errorf("failed to open daemon log: %w", err)
+	}
+	defer logFile.Close()
+
+	command.Stdin = nil
+	command.Stdout = logFile
+	command.Stderr = logFile
+	command.SysProcAttr = detachedProcAttr()
+	if err := command.Start(); err != nil {
+		return fmt.Errorf("failed to start daemon: %w", err)
+	}
+	return nil
+}
+
+// WaitReady waits until the started daemon answers on the control socket,
+// failing early when the process exits
+func WaitReady(storagePath string, command *exec.Cmd, timeout time.Duration) (*Status, error) {
+	exited := make(chan error, 1)
+	go func() { exited <- command.Wait() }()
+
+	client := NewClient(storagePath)
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = fmt.Errorf("exit status 0")
+			}
+			return nil, fmt.Errorf("daemon exited during startup: %w", err)
+		case <-deadline:
+			return nil, fmt.Errorf("daemon did not answer within %s", timeout)
+		case <-ticker.C:
+			if status, err := client.Status(); err == nil {
+				return status, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,361 @@
+// Package dataset packages a data source's SQLite database with a manifest
+// of its schema version, table row counts and checksum so it can be moved
+// between machines and verified on import.
+package dataset
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/query"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// FormatVersion is the version of the archive layout
+	FormatVersion = 1
+	// ManifestName is the name of the manifest entry in an archive
+	ManifestName = "manifest.json"
+)
+
+// Manifest describes a packaged dataset
+type Manifest struct {
+	FormatVersion int              `json:"format_version"`
+	Source        string           `json:"source"`
+	SchemaVersion int              `json:"schema_version"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Database      string           `json:"database"`
+	Size          int64            `json:"size"`
+	SHA256        string           `json:"sha256"`
+	Tables        map[string]int64 `json:"tables"`
+}
+
+// Export writes a snapshot of db to a tar archive at archivePath, compressed
+// with gzip or zstd by its name. The snapshot is taken with VACUUM INTO, so
+// db can stay in use.
+func Export(db *sql.DB, archivePath, source string, schemaVersion int) (*Manifest, error) {
+	compression, err := archiveCompression(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(archivePath), ".dataset-export-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	snapshotPath := filepath.Join(tmpDir, source+".sqlite")
+	if _, err := db.Exec("VACUUM INTO ?", snapshotPath); err != nil {
+		return nil, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	tables, err := countTables(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+	size, sum, err := checksumFile(snapshotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		Source:        source,
+		SchemaVersion: schemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		Database:      filepath.Base(snapshotPath),
+		Size:          size,
+		SHA256:        sum,
+		Tables:        tables,
+	}
+
+	tmpArchive := archivePath + ".tmp"
+	if err := writeArchive(tmpArchive, compression, manifest, snapshotPath); err != nil {
+		os.Remove(tmpArchive)
+		return nil, err
+	}
+	if err := os.Rename(tmpArchive, archivePath); err != nil {
+		os.Remove(tmpArchive)
+		return nil, fmt.Errorf("failed to move archive into place: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// ReadManifest returns the manifest of an archive without extracting it
+func ReadManifest(archivePath string) (*Manifest, error) {
+	file, reader, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readManifest(reader)
+}
+
+// Import verifies an archive and replaces the database at dbPath with its
+// contents. It refuses archives for another source or schema version, and
+// leaves dbPath untouched if any check fails.
+func Import(archivePath, dbPath, source string, schemaVersion int) (*Manifest, error) {
+	file, reader, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	manifest, err := readManifest(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.Compatible(source, schemaVersion); err != nil {
+		return nil, err
+	}
+
+	header, err := reader.Next()
+	if err != nil {
+		return nil, fmt.Errorf("archive has no database: %w", err)
+	}
+	if header.Name != manifest.Database {
+		return nil, fmt.Errorf("unexpected archive entry %q, want %q", header.Name, manifest.Database)
+	}
+
+	stagedPath := dbPath + ".import"
+	defer os.Remove(stagedPath)
+	if err := extract(reader, stagedPath, manifest); err != nil {
+		return nil, err
+	}
+
+	tables, err := countTables(stagedPath)
+	if err != nil {
+		return nil, err
+	}
+	for table, count := range manifest.Tables {
+		if tables[table] != count {
+			return nil, fmt.Errorf("table %s has %d rows, manifest expects %d", table, tables[table], count)
+		}
+	}
+
+	// Remove WAL files of the old database so they are not replayed into the new one
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", dbPath+suffix, err)
+		}
+	}
+	if err := os.Rename(stagedPath, dbPath); err != nil {
+		return nil, fmt.Errorf("failed to replace database: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// Compatible checks that the manifest can be imported into source
+func (m *Manifest) Compatible(source string, schemaVersion int) error {
+	if m.FormatVersion != FormatVersion {
+		return fmt.Errorf("unsupported archive format version %d", m.FormatVersion)
+	}
+	if m.Source != source {
+		return fmt.Errorf("archive contains a %s dataset, not %s", m.Source, source)
+	}
+	if m.SchemaVersion != schemaVersion {
+		return fmt.Errorf("archive schema version %d is incompatible with %s schema version %d",
+			m.SchemaVersion, source, schemaVersion)
+	}
+	return nil
+}
+
+// archiveCompression returns the compression of an archive by its name,
+// rejecting names that are not compressed tar archives
+func archiveCompression(path string) (query.Compression, error) {
+	switch {
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return query.CompressionGzip, nil
+	case strings.HasSuffix(path, ".tar.zst"), strings.HasSuffix(path, ".tzst"):
+		return query.CompressionZstd, nil
+	}
+	return query.CompressionNone, fmt.Errorf("dataset archives must end in .tar.gz, .tgz or .tar.zst")
+}
+
+// writeArchive writes the manifest followed by the database file
+func writeArchive(path string, compression query.Compression, manifest *Manifest, dbPath string) error {
+	out, err := query.CreateCompressed(path, compression)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	if err := writeEntries(tar.NewWriter(out), manifest, dbPath); err != nil {
+		out.Abort()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to finish compression: %w", err)
+	}
+	return nil
+}
+
+// writeEntries writes the archive entries and the end of the archive
+func writeEntries(tw *tar.Writer, manifest *Manifest, dbPath string) error {
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    ManifestName,
+		Mode:    0644,
+		Size:    int64(len(manifestData)),
+		ModTime: manifest.CreatedAt,
+	}); err != nil {
+		return fmt.Errorf("failed to write manifest header: %w", err)
+	}
+	if _, err := tw.Write(manifestData); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	db, err := os.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database snapshot: %w", err)
+	}
+	defer db.Close()
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    manifest.Database,
+		Mode:    0644,
+		Size:    manifest.Size,
+		ModTime: manifest.CreatedAt,
+	}); err != nil {
+		return fmt.Errorf("failed to write database header: %w", err)
+	}
+	if _, err := io.Copy(tw, db); err != nil {
+		return fmt.Errorf("failed to write database: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return nil
+}
+
+// openArchive opens a compressed tar archive
+func openArchive(path string) (io.ReadCloser, *tar.Reader, error) {
+	compression, err := archiveCompression(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := query.OpenCompressed(path, compression)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	return file, tar.NewReader(file), nil
+}
+
+// readManifest reads the manifest, which is always the first entry
+func readManifest(reader *tar.Reader) (*Manifest, error) {
+	header, err := reader.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if header.Name != ManifestName {
+		return nil, fmt.Errorf("archive does not start with %s", ManifestName)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(reader).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// extract writes the database entry to path, verifying its size and checksum
+func extract(reader io.Reader, path string, manifest *Manifest) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create database file: %w", err)
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hash), reader)
+	if err != nil {
+		return fmt.Errorf("failed to extract database: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write database file: %w", err)
+	}
+
+	if size != manifest.Size {
+		return fmt.Errorf("database is %d bytes, manifest expects %d", size, manifest.Size)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != manifest.SHA256 {
+		return fmt.Errorf("database checksum mismatch: got %s, manifest expects %s", sum, manifest.SHA256)
+	}
+	return nil
+}
+
+// checksumFile returns the size and SHA-256 of a file
+func checksumFile(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to checksum %s: %w", path, err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// countTables checks the integrity of a database and returns the row count
+// of each table
+func countTables(path string) (map[string]int64, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	var integrity string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return nil, fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	if integrity != "ok" {
+		return nil, fmt.Errorf("database integrity check failed: %s", integrity)
+	}
+
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+
+	tables := make(map[string]int64, len(names))
+	for _, name := range names {
+		var count int64
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, strings.ReplaceAll(name, `"`, `""`))
+		if err := db.QueryRow(query).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count rows in %s: %w", name, err)
+		}
+		tables[name] = count
+	}
+	return tables, nil
+}
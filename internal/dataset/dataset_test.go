@@ -0,0 +1,164 @@
+package dataset
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB(t *testing.T, path string, rows int) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, title TEXT)")
+	require.NoError(t, err)
+	for i := 0; i < rows; i++ {
+		_, err = db.Exec("INSERT INTO items (title) VALUES (?)", "item")
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDB(t, filepath.Join(dir, "source.sqlite"), 5)
+
+	archive := filepath.Join(dir, "dataset.tar.gz")
+	manifest, err := Export(db, archive, "hackernews", 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), manifest.Tables["items"])
+	assert.Len(t, manifest.SHA256, 64)
+
+	read, err := ReadManifest(archive)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.SHA256, read.SHA256)
+
+	target := filepath.Join(dir, "target.sqlite")
+	newTestDB(t, target, 1).Close()
+
+	imported, err := Import(archive, target, "hackernews", 1)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.SHA256, imported.SHA256)
+
+	tables, err := countTables(target)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), tables["items"])
+}
+
+func TestExportImport_Zstd(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd is not installed")
+	}
+	dir := t.TempDir()
+	db := newTestDB(t, filepath.Join(dir, "source.sqlite"), 5)
+
+	archive := filepath.Join(dir, "dataset.tar.zst")
+	manifest, err := Export(db, archive, "hackernews", 1)
+	require.NoError(t, err)
+
+	// The archive is a zstd stream, not gzip
+	header := make([]byte, 4)
+	file, err := os.Open(archive)
+	require.NoError(t, err)
+	_, err = io.ReadFull(file, header)
+	file.Close()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x28, 0xb5, 0x2f, 0xfd}, header)
+
+	read, err := ReadManifest(archive)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.SHA256, read.SHA256)
+
+	target := filepath.Join(dir, "target.sqlite")
+	imported, err := Import(archive, target, "hackernews", 1)
+	require.NoError(t, err)
+	assert.Equal(t, manifest.SHA256, imported.SHA256)
+
+	tables, err := countTables(target)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), tables["items"])
+}
+
+func TestImport_RefusesIncompatibleArchives(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDB(t, filepath.Join(dir, "source.sqlite"), 2)
+	archive := filepath.Join(dir, "dataset.tar.gz")
+	_, err := Export(db, archive, "hackernews", 1)
+	require.NoError(t, err)
+
+	target := filepath.Join(dir, "target.sqlite")
+	_, err = Import(archive, target, "hackernews", 2)
+	assert.ErrorContains(t, err, "schema version")
+	_, err = Import(archive, target, "reddit", 1)
+	assert.ErrorContains(t, err, "not reddit")
+	_, err = os.Stat(target)
+	assert.True(t, os.IsNotExist(err), "refused import must not create the database")
+
+	_, err = Export(db, filepath.Join(dir, "dataset.zip"), "hackernews", 1)
+	assert.ErrorContains(t, err, ".tar.zst")
+}
+
+func TestImport_DetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDB(t, filepath.Join(dir, "source.sqlite"), 2)
+	archive := filepath.Join(dir, "dataset.tar.gz")
+	manifest, err := Export(db, archive, "hackernews", 1)
+	require.NoError(t, err)
+
+	// Rewrite the archive with a manifest whose checksum does not match
+	manifest.SHA256 = "0000"
+	tampered := filepath.Join(dir, "tampered.tar.gz")
+	rewriteManifest(t, archive, tampered, manifest)
+
+	_, err = Import(tampered, filepath.Join(dir, "target.sqlite"), "hackernews", 1)
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
+
+// rewriteManifest copies an archive, replacing its manifest
+func rewriteManifest(t *testing.T, src, dst string, manifest *Manifest) {
+	t.Helper()
+	in, err := os.Open(src)
+	require.NoError(t, err)
+	defer in.Close()
+	gzIn, err := gzip.NewReader(in)
+	require.NoError(t, err)
+	reader := tar.NewReader(gzIn)
+
+	out, err := os.Create(dst)
+	require.NoError(t, err)
+	defer out.Close()
+	gzOut := gzip.NewWriter(out)
+	writer := tar.NewWriter(gzOut)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		if header.Name == ManifestName {
+			data, err = json.Marshal(manifest)
+			require.NoError(t, err)
+			header.Size = int64(len(data))
+		}
+		require.NoError(t, writer.WriteHeader(header))
+		_, err = writer.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, gzOut.Close())
+}
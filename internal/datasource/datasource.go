@@ -33,6 +33,8 @@ type DownloadStatus struct {
 	Progress     float64 // 0.0 to 1.0
 	ItemsTotal   int64
 	ItemsCached  int64
+	BatchesDone  int // Batches of the current download finished so far
+	BatchesTotal int // Batches the current download fetches; 0 until known
 	LastUpdate   time.Time
 	Status       string // "idle", "downloading", "paused", "error"
 	ErrorMessage string
@@ -40,10 +42,11 @@ type DownloadStatus struct {
 
 // QueryResult holds the results of a data query.
 type QueryResult struct {
-	Columns  []string
-	Rows     [][]interface{}
-	Count    int
-	Duration time.Duration
+	Columns   []string
+	Rows      [][]interface{}
+	Count     int
+	Duration  time.Duration
+	Truncated bool // Reading stopped at the ResultLimits of the query; more rows exist
 }
 
 // Schema represents the schema of the data provided by a data source.
@@ -0,0 +1,134 @@
+package datasource
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Setting describes a per-source setting kept under data_sources.<source>
+// in the config, with its default and the values allowed
+type Setting struct {
+	Key         string // Config key, e.g. batch_size
+	Description string
+	Default     string
+	Min, Max    int  // Allowed range of integer settings; both 0 for text
+	URL         bool // The value must be an http or https URL
+}
+
+// Integer reports whether the setting takes an integer
+func (s Setting) Integer() bool {
+	return s.Min != 0 || s.Max != 0
+}
+
+// Range formats the allowed values, e.g. "1-10000" or "http(s) URL"
+func (s Setting) Range() string {
+	switch {
+	case s.Integer():
+		return fmt.Sprintf("%d-%d", s.Min, s.Max)
+	case s.URL:
+		return "http(s) URL"
+	}
+	return "text"
+}
+
+// Parse checks value against the allowed values and returns it as it is
+// stored in the config: an int for integer settings, else a string
+func (s Setting) Parse(value string) (interface{}, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case s.Integer():
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number, got %q", s.Key, value)
+		}
+		if n < s.Min || n > s.Max {
+			return nil, fmt.Errorf("%s must be from %d to %d, got %d", s.Key, s.Min, s.Max, n)
+		}
+		return n, nil
+	case s.URL:
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("%s must be an http or https URL, got %q", s.Key, value)
+		}
+		return strings.TrimSuffix(value, "/"), nil
+	}
+	return value, nil
+}
+
+// Descriptor describes a data source and the settings it takes
+type Descriptor struct {
+	Name        string
+	Description string
+	Settings    []Setting
+}
+
+// Setting returns the setting called key
+func (d Descriptor) Setting(key string) (Setting, bool) {
+	for _, setting := range d.Settings {
+		if setting.Key == key {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Parse checks the value of the setting called key and returns it as it is
+// stored in the config
+func (d Descriptor) Parse(key, value string) (interface{}, error) {
+	setting, ok := d.Setting(key)
+	if !ok {
+		keys := make([]string, len(d.Settings))
+		for i, setting := range d.Settings {
+			keys[i] = setting.Key
+		}
+		return nil, fmt.Errorf("unknown %s setting %q (available: %s)", d.Name, key, strings.Join(keys, ", "))
+	}
+	return setting.Parse(value)
+}
+
+var (
+	descriptorsMu sync.RWMutex
+	descriptors   = make(map[string]Descriptor)
+)
+
+// RegisterDescriptor makes the descriptor of a data source available by
+// name, replacing any registered under the same name
+func RegisterDescriptor(descriptor Descriptor) {
+	descriptorsMu.Lock()
+	defer descriptorsMu.Unlock()
+	descriptors[descriptor.Name] = descriptor
+}
+
+// Descriptors returns the registered descriptors ordered by name
+func Descriptors() []Descriptor {
+	descriptorsMu.RLock()
+	defer descriptorsMu.RUnlock()
+
+	list := make([]Descriptor, 0, len(descriptors))
+	for _, descriptor := range descriptors {
+		list = append(list, descriptor)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// LookupDescriptor returns the descriptor of a data source
+func LookupDescriptor(name string) (Descriptor, error) {
+	descriptorsMu.RLock()
+	defer descriptorsMu.RUnlock()
+
+	descriptor, ok := descriptors[name]
+	if !ok {
+		names := make([]string, 0, len(descriptors))
+		for known := range descriptors {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return Descriptor{}, fmt.Errorf("unknown data source %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	return descriptor, nil
+}
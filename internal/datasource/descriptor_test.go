@@ -0,0 +1,57 @@
+package datasource_test
+
+import (
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescriptor_Parse(t *testing.T) {
+	descriptor := datasource.Descriptor{
+		Name: "test-descriptor",
+		Settings: []datasource.Setting{
+			{Key: "batch_size", Default: "100", Min: 1, Max: 1000},
+			{Key: "api_base_url", Default: "https://api.example.com", URL: true},
+			{Key: "label", Default: "x"},
+		},
+	}
+
+	value, err := descriptor.Parse("batch_size", " 500 ")
+	require.NoError(t, err)
+	assert.Equal(t, 500, value)
+	_, err = descriptor.Parse("batch_size", "1001")
+	assert.ErrorContains(t, err, "from 1 to 1000")
+	_, err = descriptor.Parse("batch_size", "many")
+	assert.ErrorContains(t, err, "whole number")
+
+	value, err = descriptor.Parse("api_base_url", "http://localhost:8080/v0/")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8080/v0", value)
+	_, err = descriptor.Parse("api_base_url", "localhost")
+	assert.Error(t, err)
+
+	value, err = descriptor.Parse("label", "anything")
+	require.NoError(t, err)
+	assert.Equal(t, "anything", value)
+
+	_, err = descriptor.Parse("workers", "2")
+	assert.ErrorContains(t, err, "available: batch_size, api_base_url, label")
+
+	setting, ok := descriptor.Setting("batch_size")
+	require.True(t, ok)
+	assert.Equal(t, "1-1000", setting.Range())
+}
+
+func TestRegisterDescriptor(t *testing.T) {
+	datasource.RegisterDescriptor(datasource.Descriptor{Name: "test-registered", Description: "Registered"})
+
+	descriptor, err := datasource.LookupDescriptor("test-registered")
+	require.NoError(t, err)
+	assert.Equal(t, "Registered", descriptor.Description)
+	assert.NotEmpty(t, datasource.Descriptors())
+
+	_, err = datasource.LookupDescriptor("missing")
+	assert.ErrorContains(t, err, "unknown data source")
+}
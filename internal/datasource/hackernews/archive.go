@@ -0,0 +1,575 @@
+package hackernews
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// archiveDirName is the directory of the archive databases in the storage
+// directory
+const archiveDirName = "archives"
+
+// archiveCacheFile is the decompressed union of every archive, kept in the
+// cache directory of the archives and rebuilt when an archive changes
+const archiveCacheFile = "items_archive.sqlite"
+
+// archiveFilePattern matches the compressed archive database of a year
+var archiveFilePattern = regexp.MustCompile(`^items_(\d{4})\.sqlite\.gz$`)
+
+// Archive is a compressed database holding the archived items of one year
+type Archive struct {
+	Year     int
+	Path     string
+	Size     int64
+	Modified time.Time
+}
+
+// archiveColumn is a column of an items table
+type archiveColumn struct {
+	name     string
+	dataType string
+}
+
+// ArchiveDir returns the directory of the archive databases
+func (s *Storage) ArchiveDir() string {
+	return filepath.Join(s.path, archiveDirName)
+}
+
+// Archives lists the archive databases, oldest year first
+func (s *Storage) Archives() ([]Archive, error) {
+	entries, err := os.ReadDir(s.ArchiveDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	var archives []Archive
+	for _, entry := range entries {
+		match := archiveFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat archive %s: %w", entry.Name(), err)
+		}
+		year, _ := strconv.Atoi(match[1])
+		archives = append(archives, Archive{
+			Year:     year,
+			Path:     filepath.Join(s.ArchiveDir(), entry.Name()),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Year < archives[j].Year })
+	return archives, nil
+}
+
+// ArchiveItems moves the items posted before the cutoff into the gzipped
+// archive database of their year and vacuums the database, returning the
+// number of items moved. Items already in an archive are replaced. Each year
+// is moved in one transaction; a year interrupted after its rows left the
+// database is compressed by the next run.
+func (s *Storage) ArchiveItems(ctx context.Context, before time.Time, progress func(done, total int64)) (int64, error) {
+	if storage.ReadOnly() {
+		return 0, fmt.Errorf("cannot archive items of a read-only database")
+	}
+	s.archiveMutex.Lock()
+	defer s.archiveMutex.Unlock()
+
+	dir := s.ArchiveDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	if err := compressLeftoverArchives(dir); err != nil {
+		return 0, err
+	}
+
+	cutoff := before.Unix()
+	var total int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM items WHERE time < ?", cutoff).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to count items to archive: %w", err)
+	}
+	if total == 0 {
+		return 0, nil
+	}
+
+	done, err := s.archiveYears(ctx, dir, cutoff, total, progress)
+	if done > 0 {
+		// Deleted rows only free pages for reuse; vacuuming returns them
+		if _, vacuumErr := s.db.ExecContext(ctx, "VACUUM"); vacuumErr != nil && err == nil {
+			err = fmt.Errorf("failed to vacuum database: %w", vacuumErr)
+		}
+	}
+	return done, err
+}
+
+// archiveYears moves the items before cutoff year by year on one connection,
+// which the archive being written is attached to
+func (s *Storage) archiveYears(ctx context.Context, dir string, cutoff, total int64, progress func(done, total int64)) (int64, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open archive connection: %w", err)
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `SELECT DISTINCT CAST(strftime('%Y', time, 'unixepoch') AS INTEGER)
+		FROM items WHERE time < ? ORDER BY 1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find years to archive: %w", err)
+	}
+	var years []int
+	for rows.Next() {
+		var year int
+		if err := rows.Scan(&year); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan year: %w", err)
+		}
+		years = append(years, year)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find years to archive: %w", err)
+	}
+
+	var done int64
+	for _, year := range years {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		moved, err := archiveYear(ctx, conn, dir, year, cutoff)
+		if err != nil {
+			return done, fmt.Errorf("failed to archive %d: %w", year, err)
+		}
+		done += moved
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+	return done, nil
+}
+
+// archiveYear moves the items of a year posted before cutoff into the
+// year's archive, decompressing and compressing it again around the move
+func archiveYear(ctx context.Context, conn *sql.Conn, dir string, year int, cutoff int64) (int64, error) {
+	archivePath := filepath.Join(dir, fmt.Sprintf("items_%d.sqlite.gz", year))
+	workPath := strings.TrimSuffix(archivePath, ".gz")
+	if err := gunzipFile(archivePath, workPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS archive", workPath); err != nil {
+		return 0, fmt.Errorf("failed to attach archive: %w", err)
+	}
+	moved, err := moveArchiveRows(ctx, conn, year, cutoff)
+	if _, detachErr := conn.ExecContext(context.Background(), "DETACH DATABASE archive"); detachErr != nil && err == nil {
+		err = fmt.Errorf("failed to detach archive: %w", detachErr)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return moved, gzipFile(workPath, archivePath)
+}
+
+// moveArchiveRows copies the year's rows into the attached archive and
+// deletes them from the database in one transaction
+func moveArchiveRows(ctx context.Context, conn *sql.Conn, year int, cutoff int64) (int64, error) {
+	columns, err := createArchiveTable(ctx, conn, "main", "archive")
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = `"` + column.name + `"`
+	}
+	list := strings.Join(names, ", ")
+
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	end := min(time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), cutoff)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT OR REPLACE INTO archive.items (%s)
+		SELECT %s FROM main.items WHERE time >= ? AND time < ?`, list, list), start, end); err != nil {
+		return 0, fmt.Errorf("failed to copy items to archive: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM main.items WHERE time >= ? AND time < ?", start, end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete archived items: %w", err)
+	}
+	moved, _ := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit archive: %w", err)
+	}
+	return moved, nil
+}
+
+// createArchiveTable creates the items table in the target schema from the
+// definition in the source schema, adding the columns an older archive
+// lacks, and returns the source columns
+func createArchiveTable(ctx context.Context, conn *sql.Conn, source, target string) ([]archiveColumn, error) {
+	var ddl string
+	if err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT sql FROM %s.sqlite_master WHERE type = 'table' AND name = 'items'", source)).Scan(&ddl); err != nil {
+		return nil, fmt.Errorf("failed to read items table definition: %w", err)
+	}
+	definition, ok := strings.CutPrefix(ddl, "CREATE TABLE items")
+	if !ok {
+		return nil, fmt.Errorf("unexpected items table definition: %s", ddl)
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s.items%s", target, definition)); err != nil {
+		return nil, fmt.Errorf("failed to create archive table: %w", err)
+	}
+
+	columns, err := itemColumns(ctx, conn, source)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := itemColumns(ctx, conn, target)
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool, len(existing))
+	for _, column := range existing {
+		have[column.name] = true
+	}
+	for _, column := range columns {
+		if have[column.name] {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %s.items ADD COLUMN "%s" %s`, target, column.name, column.dataType)); err != nil {
+			return nil, fmt.Errorf("failed to add column %s to archive: %w", column.name, err)
+		}
+	}
+	return columns, nil
+}
+
+// itemColumns returns the columns of the items table in a schema
+func itemColumns(ctx context.Context, conn *sql.Conn, schema string) ([]archiveColumn, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("PRAGMA %s.table_info(items)", schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read items columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []archiveColumn
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var column archiveColumn
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &column.name, &column.dataType, &notNull, &defaultValue, &primaryKey); err != nil {
+			return nil, fmt.Errorf("failed to scan items column: %w", err)
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// QueryWithArchives runs a query on a connection where items also holds the
+// archived items, through a TEMP view over the database and the archive
+// cache. Scratch tables of the session are not visible to it. Rows are read
+// until the result would exceed limits.
+func (s *Storage) QueryWithArchives(ctx context.Context, query string, limits datasource.ResultLimits) (*QueryResult, error) {
+	cache, err := s.archiveCache(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cache == "" {
+		return s.QueryLimited(query, limits)
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS archive", cache); err != nil {
+		return nil, fmt.Errorf("failed to attach archives: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "DETACH DATABASE archive")
+
+	columns, err := itemColumns(ctx, conn, "main")
+	if err != nil {
+		return nil, err
+	}
+	archived, err := itemColumns(ctx, conn, "archive")
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool, len(archived))
+	for _, column := range archived {
+		have[column.name] = true
+	}
+	names := make([]string, len(columns))
+	selected := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = `"` + column.name + `"`
+		selected[i] = names[i]
+		if !have[column.name] {
+			selected[i] = "NULL AS " + names[i]
+		}
+	}
+
+	// A TEMP view takes precedence over main.items for unqualified names
+	view := fmt.Sprintf("CREATE TEMP VIEW items AS SELECT %s FROM main.items UNION ALL SELECT %s FROM archive.items",
+		strings.Join(names, ", "), strings.Join(selected, ", "))
+	if _, err := conn.ExecContext(ctx, view); err != nil {
+		return nil, fmt.Errorf("failed to create archive view: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "DROP VIEW IF EXISTS temp.items")
+
+	start := time.Now()
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+	return scanQueryResult(rows, start, limits)
+}
+
+// archiveCache returns the path of the decompressed union of the archives,
+// rebuilding it when an archive is newer, or "" when there are no archives
+func (s *Storage) archiveCache(ctx context.Context) (string, error) {
+	s.archiveMutex.Lock()
+	defer s.archiveMutex.Unlock()
+
+	archives, err := s.Archives()
+	if err != nil || len(archives) == 0 {
+		return "", err
+	}
+	cacheDir := filepath.Join(s.ArchiveDir(), ".cache")
+	cache := filepath.Join(cacheDir, archiveCacheFile)
+	if info, err := os.Stat(cache); err == nil {
+		fresh := true
+		for _, archive := range archives {
+			if archive.Modified.After(info.ModTime()) {
+				fresh = false
+				break
+			}
+		}
+		if fresh {
+			return cache, nil
+		}
+	}
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive cache directory: %w", err)
+	}
+	building := cache + ".tmp"
+	os.Remove(building)
+	if err := s.buildArchiveCache(ctx, building, archives); err != nil {
+		os.Remove(building)
+		return "", err
+	}
+	if err := os.Rename(building, cache); err != nil {
+		return "", fmt.Errorf("failed to replace archive cache: %w", err)
+	}
+	return cache, nil
+}
+
+// buildArchiveCache writes the items of every archive into one database at
+// path, attaching one decompressed year at a time
+func (s *Storage) buildArchiveCache(ctx context.Context, path string, archives []Archive) error {
+	db, err := sql.Open(storage.DriverName, path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive cache: %w", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create archive cache: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS hot", s.dbPath); err != nil {
+		return fmt.Errorf("failed to attach database: %w", err)
+	}
+	_, err = createArchiveTable(ctx, conn, "hot", "main")
+	if _, detachErr := conn.ExecContext(ctx, "DETACH DATABASE hot"); detachErr != nil && err == nil {
+		err = fmt.Errorf("failed to detach database: %w", detachErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, archive := range archives {
+		if err := appendArchive(ctx, conn, archive.Path, path+".year"); err != nil {
+			return fmt.Errorf("failed to read archive %d: %w", archive.Year, err)
+		}
+	}
+	return nil
+}
+
+// appendArchive copies the items of a compressed archive into the cache,
+// decompressing it to scratchPath for the copy
+func appendArchive(ctx context.Context, conn *sql.Conn, archivePath, scratchPath string) error {
+	if err := gunzipFile(archivePath, scratchPath); err != nil {
+		return err
+	}
+	defer os.Remove(scratchPath)
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS year", scratchPath); err != nil {
+		return fmt.Errorf("failed to attach archive: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "DETACH DATABASE year")
+
+	columns, err := itemColumns(ctx, conn, "year")
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = `"` + column.name + `"`
+	}
+	list := strings.Join(names, ", ")
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("INSERT OR REPLACE INTO main.items (%s) SELECT %s FROM year.items", list, list))
+	return err
+}
+
+// compressLeftoverArchives compresses the archive databases an interrupted
+// run left decompressed, whose rows are no longer in the database
+func compressLeftoverArchives(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list archives: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !archiveFilePattern.MatchString(entry.Name()+".gz") {
+			continue
+		}
+		workPath := filepath.Join(dir, entry.Name())
+		if err := gzipFile(workPath, workPath+".gz"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gzipFile compresses src to dst, replacing dst atomically, and removes src
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compress archive: %w", err)
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+// gunzipFile decompresses src to dst, replacing dst atomically; the error
+// wraps os.ErrNotExist when src does not exist
+func gunzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer in.Close()
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("failed to read archive %s: %w", filepath.Base(src), err)
+	}
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	_, err = io.Copy(out, zr)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to decompress archive %s: %w", filepath.Base(src), err)
+	}
+	return nil
+}
+
+// ArchiveItems moves the items older than the given number of days into the
+// per-year archive databases
+func (h *HackerNewsDataSource) ArchiveItems(ctx context.Context, olderThanDays int, progress func(done, total int64)) error {
+	if h.storage == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	if olderThanDays <= 0 {
+		return fmt.Errorf("the archive age must be at least one day")
+	}
+	_, err := h.storage.ArchiveItems(ctx, time.Now().AddDate(0, 0, -olderThanDays), progress)
+	return err
+}
+
+// Archives lists the archive databases of the data source
+func (h *HackerNewsDataSource) Archives() ([]Archive, error) {
+	if h.storage == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+	return h.storage.Archives()
+}
+
+// QueryWithArchives executes a query that also sees the archived items
+func (h *HackerNewsDataSource) QueryWithArchives(query string) (datasource.QueryResult, error) {
+	return h.QueryWithArchivesLimited(query, datasource.ResultLimits{})
+}
+
+// QueryWithArchivesLimited executes a query that also sees the archived
+// items, reading rows only until the result would exceed limits
+func (h *HackerNewsDataSource) QueryWithArchivesLimited(query string, limits datasource.ResultLimits) (datasource.QueryResult, error) {
+	if h.storage == nil {
+		return datasource.QueryResult{}, fmt.Errorf("storage not initialized")
+	}
+	result, err := h.storage.QueryWithArchives(context.Background(), query, limits)
+	if err != nil {
+		return datasource.QueryResult{}, err
+	}
+	return datasource.QueryResult{
+		Columns:   result.Columns,
+		Rows:      result.Rows,
+		Count:     result.Count,
+		Duration:  result.Duration,
+		Truncated: result.Truncated,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package hackernews
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func archiveTestTime(year int) int64 {
+	return time.Date(year, 6, 1, 0, 0, 0, 0, time.UTC).Unix()
+}
+
+func TestStorage_ArchiveItems(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	require.NoError(t, storage.InsertItemsBatch([]*Item{
+		{ID: 1, Type: "story", Title: "first", Time: archiveTestTime(2008)},
+		{ID: 2, Type: "comment", Parent: 1, Time: archiveTestTime(2008)},
+		{ID: 3, Type: "story", Title: "later", Time: archiveTestTime(2009)},
+		{ID: 4, Type: "story", Title: "recent", Time: time.Now().Unix()},
+	}))
+
+	var reported int64
+	moved, err := storage.ArchiveItems(context.Background(), time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), func(done, total int64) {
+		assert.Equal(t, int64(3), total)
+		reported = done
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), moved)
+	assert.Equal(t, int64(3), reported)
+
+	archives, err := storage.Archives()
+	require.NoError(t, err)
+	require.Len(t, archives, 2)
+	assert.Equal(t, 2008, archives[0].Year)
+	assert.Equal(t, 2009, archives[1].Year)
+	assert.NoFileExists(t, filepath.Join(storage.ArchiveDir(), "items_2008.sqlite"))
+
+	result, err := storage.Query("SELECT id FROM items ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(4)}}, result.Rows)
+
+	result, err = storage.QueryWithArchives(context.Background(), "SELECT id, title FROM items WHERE type = 'story' ORDER BY id", datasource.ResultLimits{})
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(1), "first"}, {int64(3), "later"}, {int64(4), "recent"}}, result.Rows)
+
+	// Later runs add to the archive of a year, and queries see the addition
+	require.NoError(t, storage.InsertItem(&Item{ID: 5, Type: "comment", Parent: 3, Time: archiveTestTime(2009)}))
+	moved, err = storage.ArchiveItems(context.Background(), time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), moved)
+
+	result, err = storage.QueryWithArchives(context.Background(), "SELECT COUNT(*) FROM items", datasource.ResultLimits{})
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(5)}}, result.Rows)
+
+	// The view is dropped with the query's connection
+	result, err = storage.Query("SELECT COUNT(*) FROM items")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(1)}}, result.Rows)
+}
+
+func TestStorage_ArchiveItemsCompressesLeftovers(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	require.NoError(t, storage.InsertItem(&Item{ID: 1, Type: "story", Time: archiveTestTime(2008)}))
+	_, err := storage.ArchiveItems(context.Background(), time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), nil)
+	require.NoError(t, err)
+
+	// An interrupted run leaves a year decompressed after its rows moved
+	archive := filepath.Join(storage.ArchiveDir(), "items_2008.sqlite.gz")
+	require.NoError(t, gunzipFile(archive, filepath.Join(storage.ArchiveDir(), "items_2007.sqlite")))
+
+	moved, err := storage.ArchiveItems(context.Background(), time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), nil)
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+	assert.FileExists(t, filepath.Join(storage.ArchiveDir(), "items_2007.sqlite.gz"))
+	assert.NoFileExists(t, filepath.Join(storage.ArchiveDir(), "items_2007.sqlite"))
+}
+
+func TestStorage_QueryWithArchivesWithoutArchives(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	require.NoError(t, storage.InsertItem(&Item{ID: 1, Type: "story", Time: archiveTestTime(2008)}))
+	result, err := storage.QueryWithArchives(context.Background(), "SELECT COUNT(*) FROM items", datasource.ResultLimits{})
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(1)}}, result.Rows)
+}
@@ -0,0 +1,104 @@
+package hackernews
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// trackedItemFields are the item columns whose updates are recorded in
+// item_changes while change tracking is enabled
+var trackedItemFields = []string{"score", "descendants", "title", "dead", "deleted"}
+
+// changeTrigger is the trigger recording updates of tracked fields
+const changeTrigger = "items_track_changes"
+
+// trackChanges enables the change trigger of storages opened afterwards
+var trackChanges atomic.Bool
+
+// changeRetention is how long recorded changes are kept, in nanoseconds;
+// 0 keeps them
+var changeRetention atomic.Int64
+
+// SetTrackChanges sets whether storages opened afterwards record updates of
+// stored items, such as score changes, in item_changes. Tracking grows the
+// database with every sync, so it is off by default.
+func SetTrackChanges(enabled bool) {
+	trackChanges.Store(enabled)
+}
+
+// SetChangeRetention sets how long recorded item changes are kept; each
+// download prunes older changes. 0 keeps them.
+func SetChangeRetention(retention time.Duration) {
+	if retention < 0 {
+		retention = 0
+	}
+	changeRetention.Store(int64(retention))
+}
+
+// applyChangeTracking creates the change trigger when tracking is enabled
+// and drops it otherwise. The trigger is recreated so it follows
+// trackedItemFields.
+func (s *Storage) applyChangeTracking() error {
+	if _, err := s.db.Exec("DROP TRIGGER IF EXISTS " + changeTrigger); err != nil {
+		return fmt.Errorf("failed to drop change trigger: %w", err)
+	}
+	if !trackChanges.Load() {
+		return nil
+	}
+
+	var inserts strings.Builder
+	for _, field := range trackedItemFields {
+		fmt.Fprintf(&inserts, `
+		INSERT INTO item_changes (item_id, field, old_value, new_value)
+		SELECT NEW.id, '%[1]s', OLD.%[1]s, NEW.%[1]s WHERE OLD.%[1]s IS NOT NEW.%[1]s;`, field)
+	}
+	trigger := fmt.Sprintf("CREATE TRIGGER %s AFTER UPDATE ON items BEGIN%s\n\tEND", changeTrigger, inserts.String())
+	if _, err := s.db.Exec(trigger); err != nil {
+		return fmt.Errorf("failed to create change trigger: %w", err)
+	}
+	return nil
+}
+
+// PruneChanges deletes item changes recorded longer than olderThan ago and
+// returns how many it deleted
+func (s *Storage) PruneChanges(olderThan time.Duration) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM item_changes WHERE changed_at < datetime('now', ?)",
+		fmt.Sprintf("-%d seconds", int64(olderThan.Seconds())))
+	if err != nil {
+		s.monitor.RecordError(err)
+		return 0, fmt.Errorf("failed to prune item changes: %w", err)
+	}
+	pruned, _ := result.RowsAffected()
+	if pruned > 0 {
+		storage.NotifyTableWrites(sourceName, "item_changes")
+	}
+	return pruned, nil
+}
+
+// PruneChanges deletes item changes recorded longer than olderThan ago
+func (h *HackerNewsDataSource) PruneChanges(olderThan time.Duration) (int64, error) {
+	if h.storage == nil {
+		return 0, fmt.Errorf("storage not initialized")
+	}
+	return h.storage.PruneChanges(olderThan)
+}
+
+// pruneExpiredChanges applies the change retention after a download
+func (h *HackerNewsDataSource) pruneExpiredChanges() {
+	retention := time.Duration(changeRetention.Load())
+	if retention == 0 {
+		return
+	}
+	pruned, err := h.storage.PruneChanges(retention)
+	if err != nil {
+		downloadLog().Warnf("Failed to prune item changes: %v", err)
+		return
+	}
+	if pruned > 0 {
+		downloadLog().Infof("Pruned %d item changes older than %s", pruned, retention)
+	}
+}
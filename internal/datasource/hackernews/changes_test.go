@@ -0,0 +1,73 @@
+package hackernews
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_TrackChanges(t *testing.T) {
+	SetTrackChanges(true)
+	defer SetTrackChanges(false)
+
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	story := &Item{ID: 1, Type: "story", By: "pg", Title: "Launch", Score: 10, Descendants: 1}
+	require.NoError(t, storage.InsertItem(story))
+
+	var createdAt string
+	require.NoError(t, storage.db.QueryRow("SELECT created_at FROM items WHERE id = 1").Scan(&createdAt))
+
+	// An update records only the fields that changed; created_at is kept
+	updated := *story
+	updated.Score = 42
+	updated.Descendants = 3
+	require.NoError(t, storage.InsertItemsBatch([]*Item{&updated, {ID: 2, Type: "comment", Parent: 1}}))
+	require.NoError(t, storage.InsertItemsBatch([]*Item{&updated}))
+
+	result, err := storage.Query("SELECT item_id, field, old_value, new_value FROM item_changes ORDER BY field")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{int64(1), "descendants", int64(1), int64(3)},
+		{int64(1), "score", int64(10), int64(42)},
+	}, result.Rows)
+
+	var keptCreatedAt string
+	require.NoError(t, storage.db.QueryRow("SELECT created_at FROM items WHERE id = 1").Scan(&keptCreatedAt))
+	assert.Equal(t, createdAt, keptCreatedAt)
+
+	_, err = storage.db.Exec("UPDATE item_changes SET changed_at = datetime('now', '-10 days') WHERE field = 'score'")
+	require.NoError(t, err)
+	pruned, err := storage.PruneChanges(7 * 24 * time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+
+	result, err = storage.Query("SELECT field FROM item_changes")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{"descendants"}}, result.Rows)
+}
+
+func TestStorage_TrackChangesDisabled(t *testing.T) {
+	SetTrackChanges(true)
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, storage.Close())
+
+	// Reopening with tracking disabled drops the trigger
+	SetTrackChanges(false)
+	storage, err := NewStorage(tempDir)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	require.NoError(t, storage.InsertItem(&Item{ID: 1, Type: "story", Score: 1}))
+	require.NoError(t, storage.InsertItem(&Item{ID: 1, Type: "story", Score: 2}))
+
+	result, err := storage.Query("SELECT COUNT(*) FROM item_changes")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Rows[0][0])
+}
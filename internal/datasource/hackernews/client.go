@@ -5,7 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	neturl "net/url"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/brainless/PubDataHub/internal/httpclient"
 )
 
 var (
@@ -20,6 +25,7 @@ type Client struct {
 	httpClient  *http.Client
 	rateLimiter *RateLimiter
 	baseURL     string
+	workers     int // Items GetItemsBatch fetches at the same time
 }
 
 // Item represents a Hacker News item
@@ -37,6 +43,39 @@ type Item struct {
 	Score       int64   `json:"score"`
 	Title       string  `json:"title"`
 	Descendants int64   `json:"descendants"`
+
+	// Fields are computed by transform hooks and stored as a JSON object
+	// in the fields column
+	Fields map[string]interface{} `json:"-"`
+
+	// unchanged is set when the item was revalidated from the HTTP cache
+	unchanged bool
+}
+
+// Unchanged reports whether the API reported the item as unchanged since it
+// was last fetched
+func (i *Item) Unchanged() bool {
+	return i.unchanged
+}
+
+// DefaultRequestsPerSecond is the API rate limit of new clients unless
+// changed with SetRateLimit
+const DefaultRequestsPerSecond = 10
+
+// requestsPerSecond is the API rate limit of clients created from now on
+var requestsPerSecond atomic.Int32
+
+func init() {
+	requestsPerSecond.Store(DefaultRequestsPerSecond)
+}
+
+// SetRateLimit sets the number of API requests per second allowed for
+// clients created afterwards; values below 1 restore the default
+func SetRateLimit(perSecond int) {
+	if perSecond < 1 {
+		perSecond = DefaultRequestsPerSecond
+	}
+	requestsPerSecond.Store(int32(perSecond))
 }
 
 // NewClient creates a new Hacker News API client
@@ -45,11 +84,23 @@ func NewClient() *Client {
 		httpClient: &http.Client{
 			Timeout: DefaultTimeout,
 		},
-		rateLimiter: NewRateLimiter(10, time.Second), // 10 requests per second
-		baseURL:     BaseURL,
+		rateLimiter: NewRateLimiter(int(requestsPerSecond.Load()), time.Second),
+		baseURL:     currentAPIBaseURL(),
+		workers:     int(fetchWorkers.Load()),
 	}
 }
 
+// SetHTTPClient replaces the HTTP client used for API requests
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// HTTPCache returns the response cache of the client, or nil if responses
+// are not cached
+func (c *Client) HTTPCache() *httpclient.Cache {
+	return httpclient.CacheOf(c.httpClient)
+}
+
 // GetMaxItemID fetches the current maximum item ID from the API
 func (c *Client) GetMaxItemID(ctx context.Context) (int64, error) {
 	// Wait for rate limiter
@@ -120,16 +171,35 @@ func (c *Client) GetItem(ctx context.Context, id int64) (*Item, error) {
 	if err := json.Unmarshal(rawJson, &item); err != nil {
 		return nil, fmt.Errorf("failed to decode item %d: %w", id, err)
 	}
+	item.unchanged = httpclient.IsRevalidated(resp)
 
 	return &item, nil
 }
 
+// ItemError is returned by GetItemsBatch when an item cannot be fetched
+type ItemError struct {
+	ID  int64
+	Err error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("failed to get item %d: %v", e.ID, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
 // GetItemsBatch fetches multiple items in a batch
 func (c *Client) GetItemsBatch(ctx context.Context, startID, endID int64) ([]*Item, error) {
 	if startID > endID {
 		return nil, fmt.Errorf("startID (%d) must be <= endID (%d)", startID, endID)
 	}
 
+	if c.workers > 1 {
+		return c.getItemsConcurrently(ctx, startID, endID)
+	}
+
 	items := make([]*Item, 0, endID-startID+1)
 
 	for id := startID; id <= endID; id++ {
@@ -141,7 +211,7 @@ func (c *Client) GetItemsBatch(ctx context.Context, startID, endID int64) ([]*It
 
 		item, err := c.GetItem(ctx, id)
 		if err != nil {
-			return items, fmt.Errorf("failed to get item %d: %w", id, err)
+			return items, &ItemError{ID: id, Err: err}
 		}
 
 		// Item can be nil if it doesn't exist or is deleted
@@ -152,3 +222,137 @@ func (c *Client) GetItemsBatch(ctx context.Context, startID, endID int64) ([]*It
 
 	return items, nil
 }
+
+// getItemsConcurrently fetches a range of items with c.workers requests at
+// a time. Like a sequential fetch, it stops at the first item that fails
+// and returns the items before it in order.
+func (c *Client) getItemsConcurrently(ctx context.Context, startID, endID int64) ([]*Item, error) {
+	fetchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	count := int(endID - startID + 1)
+	results := make([]*Item, count)
+	fetched := make([]bool, count)
+	var mu sync.Mutex
+	var failure *ItemError
+
+	ids := make(chan int64)
+	var wg sync.WaitGroup
+	for i := 0; i < c.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				item, err := c.GetItem(fetchCtx, id)
+				if err != nil {
+					mu.Lock()
+					if fetchCtx.Err() == nil && (failure == nil || id < failure.ID) {
+						failure = &ItemError{ID: id, Err: err}
+					}
+					mu.Unlock()
+					cancel()
+					continue
+				}
+				results[id-startID] = item
+				fetched[id-startID] = true
+			}
+		}()
+	}
+feed:
+	for id := startID; id <= endID; id++ {
+		select {
+		case ids <- id:
+		case <-fetchCtx.Done():
+			break feed
+		}
+	}
+	close(ids)
+	wg.Wait()
+
+	items := make([]*Item, 0, count)
+	for i, item := range results {
+		if !fetched[i] {
+			break
+		}
+		// Item can be nil if it doesn't exist or is deleted
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	if ctx.Err() != nil {
+		return items, ctx.Err()
+	}
+	if failure != nil {
+		return items, failure
+	}
+	return items, nil
+}
+
+// User represents a Hacker News user profile
+type User struct {
+	ID        string  `json:"id"`
+	Created   int64   `json:"created"`
+	Karma     int64   `json:"karma"`
+	About     string  `json:"about"`
+	Submitted []int64 `json:"submitted"`
+}
+
+// GetUser fetches a user profile from the API; it returns nil if the user
+// does not exist
+func (c *Client) GetUser(ctx context.Context, id string) (*User, error) {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("rate limiter error: %w", err)
+	}
+
+	url := c.baseURL + "/user/" + neturl.PathEscape(id) + ".json"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user %s: %w", id, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d for user %s", resp.StatusCode, id)
+	}
+
+	var user *User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("failed to decode user %s: %w", id, err)
+	}
+	return user, nil
+}
+
+// GetStoryList fetches the item IDs of a story list such as "topstories",
+// in ranking order
+func (c *Client) GetStoryList(ctx context.Context, list string) ([]int64, error) {
+	if err := c.rateLimiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("rate limiter error: %w", err)
+	}
+
+	url := c.baseURL + "/" + list + ".json"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", list, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d for %s", resp.StatusCode, list)
+	}
+
+	var ids []int64
+	if err := json.NewDecoder(resp.Body).Decode(&ids); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", list, err)
+	}
+	return ids, nil
+}
@@ -186,3 +186,17 @@ func TestRateLimiter_Context_Cancellation(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, context.Canceled, err)
 }
+
+func TestSetRateLimit(t *testing.T) {
+	defer SetRateLimit(DefaultRequestsPerSecond)
+
+	SetRateLimit(3)
+	client := NewClient()
+	defer client.rateLimiter.Close()
+	assert.Equal(t, 3, client.rateLimiter.rate)
+
+	SetRateLimit(0)
+	client = NewClient()
+	defer client.rateLimiter.Close()
+	assert.Equal(t, DefaultRequestsPerSecond, client.rateLimiter.rate)
+}
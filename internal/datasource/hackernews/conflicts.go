@@ -0,0 +1,188 @@
+package hackernews
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ConflictStrategy decides what happens when a stored item is downloaded
+// again
+type ConflictStrategy string
+
+// Conflict strategies
+const (
+	// ConflictKeepNewest replaces the stored item with the downloaded one
+	ConflictKeepNewest ConflictStrategy = "keep-newest"
+	// ConflictKeepHighestScore keeps whichever has the higher score, the
+	// downloaded item on a tie
+	ConflictKeepHighestScore ConflictStrategy = "keep-highest-score"
+	// ConflictMergeNonNull takes the downloaded fields that are set and keeps
+	// the stored ones that are not, such as the text of a since deleted item
+	ConflictMergeNonNull ConflictStrategy = "merge-non-null"
+)
+
+// ConflictStrategies are the supported conflict strategies
+var ConflictStrategies = []ConflictStrategy{ConflictKeepNewest, ConflictKeepHighestScore, ConflictMergeNonNull}
+
+// conflictStrategy is the strategy of item writes; empty is keep-newest
+var conflictStrategy atomic.Value
+
+// ParseConflictStrategy returns the conflict strategy called name; empty is
+// keep-newest
+func ParseConflictStrategy(name string) (ConflictStrategy, error) {
+	if name == "" {
+		return ConflictKeepNewest, nil
+	}
+	for _, strategy := range ConflictStrategies {
+		if ConflictStrategy(name) == strategy {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown conflict strategy %q (supported: keep-newest, keep-highest-score, merge-non-null)", name)
+}
+
+// SetConflictStrategy sets how stored items are updated when they are
+// written again, by downloads, repairs, snapshots and imports
+func SetConflictStrategy(strategy ConflictStrategy) {
+	conflictStrategy.Store(strategy)
+}
+
+// currentConflictStrategy returns the strategy of item writes
+func currentConflictStrategy() ConflictStrategy {
+	if strategy, ok := conflictStrategy.Load().(ConflictStrategy); ok && strategy != "" {
+		return strategy
+	}
+	return ConflictKeepNewest
+}
+
+// UpsertReport counts what writing a batch of items did
+type UpsertReport struct {
+	Inserted int // New items
+	Updated  int // Stored items updated
+	Kept     int // Stored items the conflict strategy left as they were
+}
+
+// Add adds the counts of another report
+func (r *UpsertReport) Add(other UpsertReport) {
+	r.Inserted += other.Inserted
+	r.Updated += other.Updated
+	r.Kept += other.Kept
+}
+
+// String formats the report as "n inserted, n updated, n kept"
+func (r UpsertReport) String() string {
+	return fmt.Sprintf("%d inserted, %d updated, %d kept", r.Inserted, r.Updated, r.Kept)
+}
+
+// upsertClause returns the ON CONFLICT clause of item INSERTs for a
+// strategy. Stored items are updated in place rather than replaced, so
+// created_at is kept and update triggers such as change tracking fire; each
+// update counts in update_count.
+func upsertClause(strategy ConflictStrategy) string {
+	switch strategy {
+	case ConflictKeepHighestScore:
+		return ` ON CONFLICT(id) DO UPDATE SET type = excluded.type, by = excluded.by,
+	time = excluded.time, text = excluded.text, dead = excluded.dead, deleted = excluded.deleted,
+	parent = excluded.parent, kids = excluded.kids, url = excluded.url,
+	url_canonical = excluded.url_canonical, score = excluded.score, title = excluded.title,
+	descendants = excluded.descendants, fields = excluded.fields, updated_at = excluded.updated_at,
+	update_count = items.update_count + 1
+	WHERE COALESCE(excluded.score, 0) >= COALESCE(items.score, 0)`
+	case ConflictMergeNonNull:
+		// Items are stored with empty text and zero numbers for missing
+		// fields, so those count as not set; flags always take the new value
+		return ` ON CONFLICT(id) DO UPDATE SET type = excluded.type,
+	by = COALESCE(NULLIF(excluded.by, ''), items.by),
+	time = COALESCE(NULLIF(excluded.time, 0), items.time),
+	text = COALESCE(NULLIF(excluded.text, ''), items.text),
+	dead = excluded.dead, deleted = excluded.deleted,
+	parent = COALESCE(NULLIF(excluded.parent, 0), items.parent),
+	kids = COALESCE(NULLIF(excluded.kids, ''), items.kids),
+	url = COALESCE(NULLIF(excluded.url, ''), items.url),
+	url_canonical = COALESCE(excluded.url_canonical, items.url_canonical),
+	score = COALESCE(NULLIF(excluded.score, 0), items.score),
+	title = COALESCE(NULLIF(excluded.title, ''), items.title),
+	descendants = COALESCE(NULLIF(excluded.descendants, 0), items.descendants),
+	fields = CASE WHEN items.fields IS NULL THEN excluded.fields WHEN excluded.fields IS NULL THEN items.fields
+		ELSE json_patch(items.fields, excluded.fields) END,
+	updated_at = excluded.updated_at, update_count = items.update_count + 1`
+	default:
+		return ` ON CONFLICT(id) DO UPDATE SET type = excluded.type, by = excluded.by,
+	time = excluded.time, text = excluded.text, dead = excluded.dead, deleted = excluded.deleted,
+	parent = excluded.parent, kids = excluded.kids, url = excluded.url,
+	url_canonical = excluded.url_canonical, score = excluded.score, title = excluded.title,
+	descendants = excluded.descendants, fields = excluded.fields, updated_at = excluded.updated_at,
+	update_count = items.update_count + 1`
+	}
+}
+
+// dedupeItems resolves items with the same ID in one batch by the strategy,
+// as if they were written one after the other, keeping the order of first
+// appearance
+func dedupeItems(items []*Item, strategy ConflictStrategy) []*Item {
+	index := make(map[int64]int, len(items))
+	deduped := make([]*Item, 0, len(items))
+	for _, item := range items {
+		i, seen := index[item.ID]
+		if !seen {
+			index[item.ID] = len(deduped)
+			deduped = append(deduped, item)
+			continue
+		}
+		deduped[i] = resolveConflict(deduped[i], item, strategy)
+	}
+	return deduped
+}
+
+// resolveConflict returns the item to keep when item is written over
+// stored, matching upsertClause
+func resolveConflict(stored, item *Item, strategy ConflictStrategy) *Item {
+	switch strategy {
+	case ConflictKeepHighestScore:
+		if item.Score >= stored.Score {
+			return item
+		}
+		return stored
+	case ConflictMergeNonNull:
+		merged := *item
+		merged.By = firstSet(item.By, stored.By)
+		merged.Time = firstNonZero(item.Time, stored.Time)
+		merged.Text = firstSet(item.Text, stored.Text)
+		merged.Parent = firstNonZero(item.Parent, stored.Parent)
+		if len(merged.Kids) == 0 {
+			merged.Kids = stored.Kids
+		}
+		merged.URL = firstSet(item.URL, stored.URL)
+		merged.Score = firstNonZero(item.Score, stored.Score)
+		merged.Title = firstSet(item.Title, stored.Title)
+		merged.Descendants = firstNonZero(item.Descendants, stored.Descendants)
+		if len(stored.Fields) > 0 {
+			merged.Fields = make(map[string]interface{}, len(stored.Fields)+len(item.Fields))
+			for name, value := range stored.Fields {
+				merged.Fields[name] = value
+			}
+			for name, value := range item.Fields {
+				merged.Fields[name] = value
+			}
+		}
+		return &merged
+	default:
+		return item
+	}
+}
+
+// firstSet returns value, or fallback when value is empty
+func firstSet(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// firstNonZero returns value, or fallback when value is 0
+func firstNonZero(value, fallback int64) int64 {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,109 @@
+package hackernews
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConflictStrategy(t *testing.T) {
+	strategy, err := ParseConflictStrategy("")
+	require.NoError(t, err)
+	assert.Equal(t, ConflictKeepNewest, strategy)
+
+	strategy, err = ParseConflictStrategy("merge-non-null")
+	require.NoError(t, err)
+	assert.Equal(t, ConflictMergeNonNull, strategy)
+
+	_, err = ParseConflictStrategy("keep-oldest")
+	assert.Error(t, err)
+}
+
+func TestStorage_UpsertItemsStrategies(t *testing.T) {
+	stored := []*Item{
+		{ID: 1, Type: "story", By: "pg", Title: "Launch", Score: 50, Kids: []int64{2}},
+		{ID: 2, Type: "comment", By: "dang", Text: "Congrats", Parent: 1},
+	}
+	downloaded := []*Item{
+		{ID: 1, Type: "story", By: "pg", Title: "Launch HN", Score: 40},
+		{ID: 2, Type: "comment", Deleted: true, Parent: 1},
+		{ID: 3, Type: "comment", By: "tptacek", Text: "Nice", Parent: 1},
+	}
+
+	for _, tt := range []struct {
+		strategy ConflictStrategy
+		report   UpsertReport
+		rows     [][]interface{}
+	}{
+		{
+			strategy: ConflictKeepNewest,
+			report:   UpsertReport{Inserted: 1, Updated: 2},
+			rows: [][]interface{}{
+				{int64(1), "Launch HN", int64(40), "pg", "", int64(1)},
+				{int64(2), "", int64(0), "", "", int64(1)},
+				{int64(3), "", int64(0), "tptacek", "Nice", int64(0)},
+			},
+		},
+		{
+			strategy: ConflictKeepHighestScore,
+			report:   UpsertReport{Inserted: 1, Updated: 1, Kept: 1},
+			rows: [][]interface{}{
+				{int64(1), "Launch", int64(50), "pg", "", int64(0)},
+				{int64(2), "", int64(0), "", "", int64(1)},
+				{int64(3), "", int64(0), "tptacek", "Nice", int64(0)},
+			},
+		},
+		{
+			strategy: ConflictMergeNonNull,
+			report:   UpsertReport{Inserted: 1, Updated: 2},
+			rows: [][]interface{}{
+				{int64(1), "Launch HN", int64(40), "pg", "", int64(1)},
+				{int64(2), "", int64(0), "dang", "Congrats", int64(1)},
+				{int64(3), "", int64(0), "tptacek", "Nice", int64(0)},
+			},
+		},
+	} {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			storage, tempDir := createTestStorage(t)
+			defer os.RemoveAll(tempDir)
+			defer storage.Close()
+
+			report, err := storage.UpsertItems(stored)
+			require.NoError(t, err)
+			assert.Equal(t, UpsertReport{Inserted: 2}, report)
+
+			SetConflictStrategy(tt.strategy)
+			defer SetConflictStrategy(ConflictKeepNewest)
+			report, err = storage.UpsertItems(downloaded)
+			require.NoError(t, err)
+			assert.Equal(t, tt.report, report)
+
+			result, err := storage.Query("SELECT id, title, score, by, text, update_count FROM items ORDER BY id")
+			require.NoError(t, err)
+			assert.Equal(t, tt.rows, result.Rows)
+		})
+	}
+}
+
+func TestDedupeItems(t *testing.T) {
+	items := []*Item{
+		{ID: 1, Type: "story", Title: "First", Score: 10},
+		{ID: 2, Type: "comment", Text: "Hi"},
+		{ID: 1, Type: "story", Score: 5},
+	}
+
+	newest := dedupeItems(items, ConflictKeepNewest)
+	require.Len(t, newest, 2)
+	assert.Equal(t, int64(5), newest[0].Score)
+	assert.Empty(t, newest[0].Title)
+
+	highest := dedupeItems(items, ConflictKeepHighestScore)
+	assert.Equal(t, "First", highest[0].Title)
+
+	merged := dedupeItems(items, ConflictMergeNonNull)
+	assert.Equal(t, "First", merged[0].Title)
+	assert.Equal(t, int64(5), merged[0].Score)
+	assert.Equal(t, int64(2), merged[1].ID, "the order of first appearance is kept")
+}
@@ -2,19 +2,27 @@ package hackernews
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/brainless/PubDataHub/internal/datasource"
 	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/sirupsen/logrus"
 )
 
+// downloadLog returns a logger entry tagged for Hacker News downloads
+func downloadLog() *logrus.Entry {
+	return log.ForComponent("download").WithField(log.FieldSource, "hackernews")
+}
+
 // Downloader manages the download process for Hacker News data
 type Downloader struct {
 	client    *Client
 	storage   *Storage
 	batchSize int
+	verify    bool // Spot-check completed batches before downloading
 	status    datasource.DownloadStatus
 }
 
@@ -33,13 +41,14 @@ func NewDownloader(client *Client, storage *Storage, batchSize int) *Downloader
 	}
 }
 
-// StartDownload begins the download process
+// StartDownload downloads the items up to the current max ID that are not
+// stored yet
 func (d *Downloader) StartDownload(ctx context.Context) error {
 	d.status.IsActive = true
 	d.status.Status = "downloading"
 	d.status.LastUpdate = time.Now()
 
-	log.Logger.Info("Starting Hacker News download")
+	downloadLog().Info("Starting Hacker News download")
 
 	// Get current max ID from API
 	maxID, err := d.client.GetMaxItemID(ctx)
@@ -50,20 +59,46 @@ func (d *Downloader) StartDownload(ctx context.Context) error {
 		return fmt.Errorf("failed to get max item ID: %w", err)
 	}
 
-	log.Logger.Infof("Current max item ID: %d", maxID)
+	downloadLog().Infof("Current max item ID: %d", maxID)
 
 	// Store max ID in metadata
 	if err := d.storage.SetMetadata("max_id", strconv.FormatInt(maxID, 10)); err != nil {
-		log.Logger.Errorf("Failed to store max ID: %v", err)
+		downloadLog().Errorf("Failed to store max ID: %v", err)
 	}
 
+	return d.download(ctx, maxID)
+}
+
+// ResumeDownload continues the last download up to the max ID it started
+// with, rebuilding what is left from the batch status; without a previous
+// download it starts a new one
+func (d *Downloader) ResumeDownload(ctx context.Context) error {
+	value, err := d.storage.GetMetadata("max_id")
+	if err != nil {
+		return fmt.Errorf("failed to read download state: %w", err)
+	}
+	maxID, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || maxID < 1 {
+		return d.StartDownload(ctx)
+	}
+
+	d.status.IsActive = true
+	d.status.Status = "downloading"
+	d.status.LastUpdate = time.Now()
+	downloadLog().Infof("Resuming Hacker News download up to item %d", maxID)
+
+	return d.download(ctx, maxID)
+}
+
+// download fetches the batches of items 1 to maxID that are not stored yet
+func (d *Downloader) download(ctx context.Context, maxID int64) error {
 	d.status.ItemsTotal = maxID
 
 	// Get current cached count from storage
 	if result, err := d.storage.Query("SELECT COUNT(*) FROM items"); err == nil && len(result.Rows) > 0 {
 		if count, ok := result.Rows[0][0].(int64); ok {
 			d.status.ItemsCached = count
-			log.Logger.Infof("Current cached items: %d", count)
+			downloadLog().Infof("Current cached items: %d", count)
 		}
 	}
 
@@ -76,7 +111,9 @@ func (d *Downloader) StartDownload(ctx context.Context) error {
 		return fmt.Errorf("failed to calculate missing batches: %w", err)
 	}
 
-	log.Logger.Infof("Found %d missing batches to download", len(missingBatches))
+	downloadLog().Infof("Found %d missing batches to download", len(missingBatches))
+	d.status.BatchesDone = 0
+	d.status.BatchesTotal = len(missingBatches)
 
 	// Download missing batches
 	for i, batch := range missingBatches {
@@ -89,7 +126,7 @@ func (d *Downloader) StartDownload(ctx context.Context) error {
 		}
 
 		if err := d.downloadBatch(ctx, batch); err != nil {
-			log.Logger.Errorf("Failed to download batch %d-%d: %v", batch.BatchStart, batch.BatchEnd, err)
+			downloadLog().Errorf("Failed to download batch %d-%d: %v", batch.BatchStart, batch.BatchEnd, err)
 			d.status.ErrorMessage = err.Error()
 			continue
 		}
@@ -97,16 +134,17 @@ func (d *Downloader) StartDownload(ctx context.Context) error {
 		// Update progress
 		progress := float64(i+1) / float64(len(missingBatches))
 		d.status.Progress = progress
+		d.status.BatchesDone = i + 1
 		d.status.LastUpdate = time.Now()
 
-		log.Logger.Infof("Completed batch %d/%d (%.1f%%)", i+1, len(missingBatches), progress*100)
+		downloadLog().Infof("Completed batch %d/%d (%.1f%%)", i+1, len(missingBatches), progress*100)
 	}
 
 	// Update final cached count
 	if result, err := d.storage.Query("SELECT COUNT(*) FROM items"); err == nil && len(result.Rows) > 0 {
 		if count, ok := result.Rows[0][0].(int64); ok {
 			d.status.ItemsCached = count
-			log.Logger.Infof("Final cached items: %d", count)
+			downloadLog().Infof("Final cached items: %d", count)
 		}
 	}
 
@@ -115,97 +153,65 @@ func (d *Downloader) StartDownload(ctx context.Context) error {
 	d.status.Progress = 1.0
 	d.status.LastUpdate = time.Now()
 
-	log.Logger.Info("Download completed successfully")
+	downloadLog().Info("Download completed successfully")
 	return nil
 }
 
-// calculateMissingBatches determines which batches need to be downloaded
-func (d *Downloader) calculateMissingBatches(ctx context.Context, maxID int64) ([]BatchStatus, error) {
-	// Get existing batch status
-	existingBatches, err := d.storage.GetBatchStatus()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get batch status: %w", err)
-	}
-
-	// Create a map of completed batch ranges
-	completedRanges := make(map[string]bool)
-	for _, batch := range existingBatches {
-		if batch.Completed {
-			key := fmt.Sprintf("%d-%d", batch.BatchStart, batch.BatchEnd)
-			completedRanges[key] = true
-		}
-	}
-
-	// Calculate all possible batches from maxID down to 1
-	var missingBatches []BatchStatus
-	batchSize := int64(d.batchSize)
-
-	for startID := maxID; startID >= 1; startID -= batchSize {
-		endID := startID - batchSize + 1
-		if endID < 1 {
-			endID = 1
-		}
-
-		// Check if this batch is already completed
-		key := fmt.Sprintf("%d-%d", endID, startID)
-		if completedRanges[key] {
-			continue
-		}
-
-		// Check if we need to download this batch by examining existing items
-		existingItems, err := d.storage.GetExistingItemIDs(endID, startID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to check existing items: %w", err)
-		}
-
-		// Calculate how many items are missing in this range
-		expectedItems := int(startID - endID + 1)
-		actualItems := len(existingItems)
-
-		// If we have fewer than 90% of expected items, mark batch for download
-		if float64(actualItems)/float64(expectedItems) < 0.9 {
-			batch := BatchStatus{
-				BatchStart:      endID,
-				BatchEnd:        startID,
-				BatchSize:       int(batchSize),
-				Completed:       false,
-				ItemsDownloaded: actualItems,
-				CreatedAt:       time.Now(),
-			}
-			missingBatches = append(missingBatches, batch)
-		}
-	}
-
-	return missingBatches, nil
-}
-
 // downloadBatch downloads a single batch of items
 func (d *Downloader) downloadBatch(ctx context.Context, batch BatchStatus) error {
-	log.Logger.Infof("Downloading batch %d-%d", batch.BatchStart, batch.BatchEnd)
+	downloadLog().Infof("Downloading batch %d-%d", batch.BatchStart, batch.BatchEnd)
 
 	// Mark batch as started
 	batch.CreatedAt = time.Now()
 	if err := d.storage.SetBatchStatus(batch); err != nil {
-		log.Logger.Errorf("Failed to update batch status: %v", err)
+		downloadLog().Errorf("Failed to update batch status: %v", err)
 	}
 
 	// Download items in this batch
 	items, err := d.client.GetItemsBatch(ctx, batch.BatchStart, batch.BatchEnd)
 	if err != nil {
+		var itemErr *ItemError
+		if errors.As(err, &itemErr) && ctx.Err() == nil {
+			if recordErr := d.storage.RecordFetchFailure(itemErr.ID, itemErr.Err); recordErr != nil {
+				downloadLog().Errorf("Failed to record failed item: %v", recordErr)
+			}
+		}
 		return fmt.Errorf("failed to download items: %w", err)
 	}
 
-	// Store items in database
-	if len(items) > 0 {
-		if err := d.storage.InsertItemsBatch(items); err != nil {
+	// Store items in database, skipping items the API reported as unchanged
+	changed := make([]*Item, 0, len(items))
+	for _, item := range items {
+		if !item.Unchanged() {
+			changed = append(changed, item)
+		}
+	}
+	if skipped := len(items) - len(changed); skipped > 0 {
+		downloadLog().Debugf("Skipped %d unchanged items in batch %d-%d", skipped, batch.BatchStart, batch.BatchEnd)
+	}
+	var report UpsertReport
+	if len(changed) > 0 {
+		if report, err = d.storage.UpsertItems(changed); err != nil {
 			return fmt.Errorf("failed to store items: %w", err)
 		}
+		downloadLog().Debugf("Stored batch %d-%d: %s", batch.BatchStart, batch.BatchEnd, report)
+	}
+
+	// Tombstone dead, deleted and missing items; live ones lose old tombstones
+	checked := make([]int64, 0, batch.BatchEnd-batch.BatchStart+1)
+	for id := batch.BatchStart; id <= batch.BatchEnd; id++ {
+		checked = append(checked, id)
+	}
+	if err := d.storage.RecordItemStates(checked, items); err != nil {
+		return fmt.Errorf("failed to record tombstones: %w", err)
 	}
 
 	// Mark batch as completed
 	now := time.Now()
 	batch.Completed = true
 	batch.ItemsDownloaded = len(items)
+	batch.ItemsInserted = report.Inserted
+	batch.ItemsUpdated = report.Updated
 	batch.CompletedAt = &now
 
 	if err := d.storage.SetBatchStatus(batch); err != nil {
@@ -228,7 +234,7 @@ func (d *Downloader) PauseDownload() error {
 		d.status.Status = "paused"
 		d.status.IsActive = false
 		d.status.LastUpdate = time.Now()
-		log.Logger.Info("Download paused")
+		downloadLog().Info("Download paused")
 	}
 	return nil
 }
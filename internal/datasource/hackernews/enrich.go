@@ -0,0 +1,33 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/brainless/PubDataHub/internal/enrich"
+)
+
+// Enrich runs the named enrichers, or every registered enricher when names
+// is empty, over the title and text of stored items. Items already enriched
+// by the current version of an enricher are skipped unless rerun is set.
+func (s *Storage) Enrich(ctx context.Context, names []string, rerun bool, progress func(done, total int64)) (int64, error) {
+	enrichers, err := enrich.Lookup(names...)
+	if err != nil {
+		return 0, err
+	}
+	return enrich.NewRunner(s.db, sourceName, "items", "title", "text").Run(ctx, enrichers, rerun, progress)
+}
+
+// Enrich post-processes downloaded item text with the named enrichers
+func (h *HackerNewsDataSource) Enrich(ctx context.Context, names []string, rerun bool, progress func(done, total int64)) error {
+	if h.storage == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	_, err := h.storage.Enrich(ctx, names, rerun, progress)
+	return err
+}
+
+// Enrichers returns the names of the available enrichers
+func (h *HackerNewsDataSource) Enrichers() []string {
+	return enrich.Names()
+}
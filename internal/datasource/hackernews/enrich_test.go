@@ -0,0 +1,30 @@
+package hackernews
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHackerNewsDataSource_Enrich(t *testing.T) {
+	hn := NewHackerNewsDataSource(50)
+	require.NoError(t, hn.InitializeStorage(t.TempDir()))
+	defer hn.Close()
+
+	require.NoError(t, hn.storage.InsertItemsBatch([]*Item{
+		{ID: 1, Type: "story", Title: "Show HN: the best tool that I have built for the web"},
+		{ID: 2, Type: "comment", Text: "Das ist nicht die Lösung, aber es ist auch kein Problem"},
+	}))
+	require.NoError(t, hn.Enrich(context.Background(), []string{"language"}, false, nil))
+
+	result, err := hn.Query("SELECT item_id, language FROM enrich_language ORDER BY item_id")
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 2)
+	assert.Equal(t, "en", result.Rows[0][1])
+	assert.Equal(t, "de", result.Rows[1][1])
+
+	tables := hn.GetSchema().Tables
+	assert.Equal(t, "enrich_language", tables[len(tables)-1].Name)
+}
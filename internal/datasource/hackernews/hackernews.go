@@ -4,8 +4,14 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"time"
 
+	"github.com/brainless/PubDataHub/internal/dataset"
 	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/enrich"
+	"github.com/brainless/PubDataHub/internal/httpclient"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
 )
 
 // HackerNewsDataSource implements the DataSource interface for Hacker News
@@ -16,21 +22,22 @@ type HackerNewsDataSource struct {
 	batchSize  int
 }
 
-// NewHackerNewsDataSource creates a new Hacker News data source
-func NewHackerNewsDataSource(batchSize int) *HackerNewsDataSource {
-	if batchSize <= 0 {
-		batchSize = 100 // Default batch size
+// NewHackerNewsDataSource creates a new Hacker News data source; a batch
+// size below 1 uses the one set with SetBatchSize
+func NewHackerNewsDataSource(size int) *HackerNewsDataSource {
+	if size <= 0 {
+		size = int(batchSize.Load())
 	}
 
 	return &HackerNewsDataSource{
 		client:    NewClient(),
-		batchSize: batchSize,
+		batchSize: size,
 	}
 }
 
 // Name returns the name of the data source
 func (h *HackerNewsDataSource) Name() string {
-	return "hackernews"
+	return sourceName
 }
 
 // Description returns the description of the data source
@@ -48,6 +55,14 @@ func (h *HackerNewsDataSource) InitializeStorage(storagePath string) error {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
+	config := httpclient.ForSource(h.Name())
+	config.CacheDir = httpclient.CacheDir(storagePath)
+	httpClient, err := httpclient.New(config)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	h.client.SetHTTPClient(httpClient)
+
 	h.storage = storage
 	h.downloader = NewDownloader(h.client, h.storage, h.batchSize)
 
@@ -102,7 +117,11 @@ func (h *HackerNewsDataSource) StartDownload(ctx context.Context) error {
 	if h.downloader == nil {
 		return fmt.Errorf("storage not initialized")
 	}
-	return h.downloader.StartDownload(ctx)
+	if err := h.downloader.StartDownload(ctx); err != nil {
+		return err
+	}
+	h.pruneExpiredChanges()
+	return nil
 }
 
 // PauseDownload pauses the download process
@@ -113,12 +132,21 @@ func (h *HackerNewsDataSource) PauseDownload() error {
 	return h.downloader.PauseDownload()
 }
 
-// ResumeDownload resumes a paused download
+// ResumeDownload continues the last download where it stopped
 func (h *HackerNewsDataSource) ResumeDownload(ctx context.Context) error {
 	if h.downloader == nil {
 		return fmt.Errorf("storage not initialized")
 	}
-	return h.downloader.StartDownload(ctx) // Same as StartDownload - it calculates missing batches
+	return h.downloader.ResumeDownload(ctx)
+}
+
+// SetVerify makes downloads recount a sample of completed batches first
+func (h *HackerNewsDataSource) SetVerify(verify bool) error {
+	if h.downloader == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	h.downloader.SetVerify(verify)
+	return nil
 }
 
 // Query executes a query against the stored data
@@ -141,8 +169,95 @@ func (h *HackerNewsDataSource) Query(query string) (datasource.QueryResult, erro
 	}, nil
 }
 
-// GetSchema returns the schema of the data source
+// QueryLimited executes a query against the stored data, reading rows only
+// until the result would exceed limits
+func (h *HackerNewsDataSource) QueryLimited(query string, limits datasource.ResultLimits) (datasource.QueryResult, error) {
+	if h.storage == nil {
+		return datasource.QueryResult{}, fmt.Errorf("storage not initialized")
+	}
+
+	result, err := h.storage.QueryLimited(query, limits)
+	if err != nil {
+		return datasource.QueryResult{}, err
+	}
+	return datasource.QueryResult{
+		Columns:   result.Columns,
+		Rows:      result.Rows,
+		Count:     result.Count,
+		Duration:  result.Duration,
+		Truncated: result.Truncated,
+	}, nil
+}
+
+// Optimize runs storage maintenance for the data source
+func (h *HackerNewsDataSource) Optimize() error {
+	if h.storage == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	return h.storage.Optimize()
+}
+
+// GetSchema returns the schema of the data source, including derived tables
 func (h *HackerNewsDataSource) GetSchema() datasource.Schema {
+	schema := baseSchema()
+	if h.storage == nil {
+		return schema
+	}
+
+	derived, err := h.storage.DerivedTables().List()
+	if err != nil {
+		log.Logger.Warnf("Failed to list derived tables: %v", err)
+		return schema
+	}
+	for _, table := range derived {
+		columns, err := h.storage.DerivedTables().Columns(table.Name)
+		if err != nil || len(columns) == 0 {
+			// Not built yet
+			continue
+		}
+		tableSchema := datasource.TableSchema{Name: table.Name}
+		for _, column := range columns {
+			tableSchema.Columns = append(tableSchema.Columns, datasource.ColumnSchema{Name: column.Name, Type: column.Type})
+		}
+		schema.Tables = append(schema.Tables, tableSchema)
+	}
+
+	enriched, err := enrich.TableSchemas(h.storage.db)
+	if err != nil {
+		log.Logger.Warnf("Failed to list enrichment tables: %v", err)
+		return schema
+	}
+	schema.Tables = append(schema.Tables, enriched...)
+	return schema
+}
+
+// DerivedTables returns the derived tables defined over the data source
+func (h *HackerNewsDataSource) DerivedTables() *storage.DerivedTables {
+	if h.storage == nil {
+		return nil
+	}
+	return h.storage.DerivedTables()
+}
+
+// Checkpoints returns the checkpoints pipelines keep with the data source
+func (h *HackerNewsDataSource) Checkpoints() *storage.Checkpoints {
+	if h.storage == nil {
+		return nil
+	}
+	return h.storage.Checkpoints()
+}
+
+// OpenScratch opens a session scratch area whose TEMP tables are kept out of
+// the database file
+func (h *HackerNewsDataSource) OpenScratch() (*storage.Scratch, error) {
+	if h.storage == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+	return h.storage.OpenScratch()
+}
+
+// baseSchema returns the tables created by the storage migration
+func baseSchema() datasource.Schema {
 	return datasource.Schema{
 		Tables: []datasource.TableSchema{
 			{
@@ -163,6 +278,9 @@ func (h *HackerNewsDataSource) GetSchema() datasource.Schema {
 					{Name: "descendants", Type: "INTEGER"},
 					{Name: "created_at", Type: "DATETIME"},
 					{Name: "updated_at", Type: "DATETIME"},
+					{Name: "url_canonical", Type: "TEXT"},
+					{Name: "fields", Type: "TEXT"},
+					{Name: "update_count", Type: "INTEGER"},
 				},
 			},
 			{
@@ -183,12 +301,115 @@ func (h *HackerNewsDataSource) GetSchema() datasource.Schema {
 					{Name: "items_downloaded", Type: "INTEGER"},
 					{Name: "created_at", Type: "DATETIME"},
 					{Name: "completed_at", Type: "DATETIME"},
+					{Name: "items_inserted", Type: "INTEGER"},
+					{Name: "items_updated", Type: "INTEGER"},
+				},
+			},
+			{
+				Name: "users",
+				Columns: []datasource.ColumnSchema{
+					{Name: "id", Type: "TEXT"},
+					{Name: "created", Type: "INTEGER"},
+					{Name: "karma", Type: "INTEGER"},
+					{Name: "about", Type: "TEXT"},
+					{Name: "submitted", Type: "INTEGER"},
+					{Name: "fetched_at", Type: "DATETIME"},
+				},
+			},
+			{
+				Name: "user_karma",
+				Columns: []datasource.ColumnSchema{
+					{Name: "user_id", Type: "TEXT"},
+					{Name: "karma", Type: "INTEGER"},
+					{Name: "recorded_at", Type: "DATETIME"},
+				},
+			},
+			{
+				Name: "rankings",
+				Columns: []datasource.ColumnSchema{
+					{Name: "list", Type: "TEXT"},
+					{Name: "captured_at", Type: "DATETIME"},
+					{Name: "rank", Type: "INTEGER"},
+					{Name: "item_id", Type: "INTEGER"},
+					{Name: "score", Type: "INTEGER"},
+				},
+			},
+			{
+				Name: "tombstones",
+				Columns: []datasource.ColumnSchema{
+					{Name: "item_id", Type: "INTEGER"},
+					{Name: "reason", Type: "TEXT"},
+					{Name: "error", Type: "TEXT"},
+					{Name: "first_seen", Type: "DATETIME"},
+					{Name: "checked_at", Type: "DATETIME"},
+					{Name: "checks", Type: "INTEGER"},
+				},
+			},
+			{
+				Name: "item_changes",
+				Columns: []datasource.ColumnSchema{
+					{Name: "item_id", Type: "INTEGER"},
+					{Name: "field", Type: "TEXT"},
+					{Name: "old_value", Type: "ANY"},
+					{Name: "new_value", Type: "ANY"},
+					{Name: "changed_at", Type: "DATETIME"},
+				},
+			},
+			{
+				Name: "url_submissions",
+				Columns: []datasource.ColumnSchema{
+					{Name: "url_canonical", Type: "TEXT"},
+					{Name: "submissions", Type: "INTEGER"},
+					{Name: "first_id", Type: "INTEGER"},
+					{Name: "first_time", Type: "INTEGER"},
+					{Name: "last_time", Type: "INTEGER"},
+					{Name: "total_score", Type: "INTEGER"},
+					{Name: "best_score", Type: "INTEGER"},
+				},
+			},
+			{
+				Name: "url_duplicates",
+				Columns: []datasource.ColumnSchema{
+					{Name: "id", Type: "INTEGER"},
+					{Name: "url_canonical", Type: "TEXT"},
+					{Name: "url", Type: "TEXT"},
+					{Name: "title", Type: "TEXT"},
+					{Name: "by", Type: "TEXT"},
+					{Name: "time", Type: "INTEGER"},
+					{Name: "score", Type: "INTEGER"},
+					{Name: "first_id", Type: "INTEGER"},
+					{Name: "submissions", Type: "INTEGER"},
 				},
 			},
 		},
 	}
 }
 
+// DatabaseHealth returns WAL and lock contention health for the data source's database
+func (h *HackerNewsDataSource) DatabaseHealth() storage.DBHealth {
+	if h.storage == nil {
+		return storage.DBHealth{Status: "unknown", LastCheck: time.Now()}
+	}
+	return h.storage.Health()
+}
+
+// RecoverableDatabase returns the data source's database for the startup
+// integrity check and repair, or nil before its storage is opened
+func (h *HackerNewsDataSource) RecoverableDatabase() *storage.RecoverableDB {
+	if h.storage == nil {
+		return nil
+	}
+	return h.storage.Recoverable()
+}
+
+// StorageStats returns database size and connection usage
+func (h *HackerNewsDataSource) StorageStats() storage.StorageStats {
+	if h.storage == nil {
+		return storage.StorageStats{LastUpdate: time.Now()}
+	}
+	return h.storage.Stats()
+}
+
 // Close closes any resources used by the data source
 func (h *HackerNewsDataSource) Close() error {
 	if h.storage != nil {
@@ -196,3 +417,64 @@ func (h *HackerNewsDataSource) Close() error {
 	}
 	return nil
 }
+
+// HTTPCache returns the cache of API responses, or nil if caching is disabled
+func (h *HackerNewsDataSource) HTTPCache() *httpclient.Cache {
+	return h.client.HTTPCache()
+}
+
+// ExportDataset packages the database with a manifest into archivePath
+func (h *HackerNewsDataSource) ExportDataset(archivePath string) (*dataset.Manifest, error) {
+	if h.storage == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+	return h.storage.ExportDataset(archivePath)
+}
+
+// ImportDataset replaces the database with the dataset in archivePath. The
+// database is closed while the archive is verified and reopened afterwards,
+// even if the import fails.
+func (h *HackerNewsDataSource) ImportDataset(archivePath string) (*dataset.Manifest, error) {
+	if h.storage == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+	if h.downloader != nil && h.downloader.GetDownloadStatus().IsActive {
+		return nil, fmt.Errorf("cannot import while a download is active")
+	}
+
+	if storage.SharedDatabase() != "" {
+		return nil, errSharedLayout
+	}
+
+	// Verify the manifest before closing the database
+	manifest, err := dataset.ReadManifest(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.Compatible(h.Name(), SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	hnStoragePath := h.storage.GetStoragePath()
+	if err := h.storage.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close database: %w", err)
+	}
+
+	manifest, importErr := dataset.Import(archivePath, filepath.Join(hnStoragePath, databaseFile), h.Name(), SchemaVersion)
+
+	reopened, err := NewStorage(hnStoragePath)
+	if err != nil {
+		h.storage = nil
+		return nil, fmt.Errorf("failed to reopen storage: %w", err)
+	}
+	h.storage = reopened
+	h.downloader = NewDownloader(h.client, h.storage, h.batchSize)
+
+	// The whole database was replaced
+	storage.NotifyTableWrites(sourceName)
+
+	if importErr != nil {
+		return nil, importErr
+	}
+	return manifest, nil
+}
@@ -52,12 +52,12 @@ func TestHackerNewsDataSource_GetSchema(t *testing.T) {
 	hn := NewHackerNewsDataSource(100)
 	schema := hn.GetSchema()
 
-	assert.Len(t, schema.Tables, 3)
+	assert.Len(t, schema.Tables, 10)
 
 	// Check items table schema
 	itemsTable := schema.Tables[0]
 	assert.Equal(t, "items", itemsTable.Name)
-	assert.Len(t, itemsTable.Columns, 15)
+	assert.Len(t, itemsTable.Columns, 18)
 
 	// Check specific columns
 	idColumn := itemsTable.Columns[0]
@@ -76,7 +76,29 @@ func TestHackerNewsDataSource_GetSchema(t *testing.T) {
 	// Check batch status table
 	batchTable := schema.Tables[2]
 	assert.Equal(t, "batch_status", batchTable.Name)
-	assert.Len(t, batchTable.Columns, 7)
+	assert.Len(t, batchTable.Columns, 9)
+
+	// Check user tables
+	assert.Equal(t, "users", schema.Tables[3].Name)
+	assert.Len(t, schema.Tables[3].Columns, 6)
+	assert.Equal(t, "user_karma", schema.Tables[4].Name)
+	assert.Len(t, schema.Tables[4].Columns, 3)
+
+	// Check rankings table
+	assert.Equal(t, "rankings", schema.Tables[5].Name)
+	assert.Len(t, schema.Tables[5].Columns, 5)
+
+	// Check tombstones table
+	assert.Equal(t, "tombstones", schema.Tables[6].Name)
+	assert.Len(t, schema.Tables[6].Columns, 6)
+
+	// Check item changes table
+	assert.Equal(t, "item_changes", schema.Tables[7].Name)
+	assert.Len(t, schema.Tables[7].Columns, 5)
+
+	// Check canonical URL views
+	assert.Equal(t, "url_submissions", schema.Tables[8].Name)
+	assert.Equal(t, "url_duplicates", schema.Tables[9].Name)
 }
 
 func TestHackerNewsDataSource_DownloadStatus_NotInitialized(t *testing.T) {
@@ -189,3 +211,28 @@ func containsSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestHackerNewsDataSource_ExportImportDataset(t *testing.T) {
+	source := NewHackerNewsDataSource(50)
+	require.NoError(t, source.InitializeStorage(t.TempDir()))
+	defer source.Close()
+	require.NoError(t, source.storage.InsertItem(&Item{ID: 1, Type: "story", Title: "Exported"}))
+
+	archive := filepath.Join(t.TempDir(), "hn.tar.gz")
+	manifest, err := source.ExportDataset(archive)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion, manifest.SchemaVersion)
+	assert.Equal(t, int64(1), manifest.Tables["items"])
+
+	target := NewHackerNewsDataSource(50)
+	require.NoError(t, target.InitializeStorage(t.TempDir()))
+	defer target.Close()
+
+	_, err = target.ImportDataset(archive)
+	require.NoError(t, err)
+
+	result, err := target.Query("SELECT title FROM items WHERE id = 1")
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, "Exported", result.Rows[0][0])
+}
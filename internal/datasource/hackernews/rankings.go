@@ -0,0 +1,120 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// DefaultRankingDepth is how many stories of each list a snapshot captures,
+// one front page
+const DefaultRankingDepth = 30
+
+// rankingLists maps the story lists a snapshot can capture to their API names
+var rankingLists = map[string]string{
+	"top":  "topstories",
+	"new":  "newstories",
+	"best": "beststories",
+}
+
+// Ranking is the position of a story in a list at capture time
+type Ranking struct {
+	Rank   int
+	ItemID int64
+	Score  int64
+}
+
+// InsertRankings stores the rankings of one list snapshot
+func (s *Storage) InsertRankings(list string, capturedAt time.Time, rankings []Ranking) error {
+	if len(rankings) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.monitor.RecordError(err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The format of CURRENT_TIMESTAMP, so rankings compare with other columns
+	captured := capturedAt.UTC().Format("2006-01-02 15:04:05")
+	for _, ranking := range rankings {
+		if _, err := tx.Exec(`
+		INSERT OR REPLACE INTO rankings (list, captured_at, rank, item_id, score)
+		VALUES (?, ?, ?, ?, ?)`, list, captured, ranking.Rank, ranking.ItemID, ranking.Score); err != nil {
+			return fmt.Errorf("failed to store %s ranking %d: %w", list, ranking.Rank, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.monitor.RecordError(err)
+		return err
+	}
+	storage.NotifyTableWrites(sourceName, "rankings")
+	return nil
+}
+
+// captureRanking fetches the first depth stories of a list with their
+// current score, stores the stories and records their rankings
+func captureRanking(ctx context.Context, client *Client, store *Storage, list string, depth int) (int, error) {
+	apiList, ok := rankingLists[list]
+	if !ok {
+		return 0, fmt.Errorf("unknown story list %q (use top, new or best)", list)
+	}
+	if depth <= 0 {
+		depth = DefaultRankingDepth
+	}
+
+	capturedAt := time.Now()
+	ids, err := client.GetStoryList(ctx, apiList)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) > depth {
+		ids = ids[:depth]
+	}
+
+	rankings := make([]Ranking, 0, len(ids))
+	changed := make([]*Item, 0, len(ids))
+	for i, id := range ids {
+		item, err := client.GetItem(ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		if item == nil {
+			continue
+		}
+		rankings = append(rankings, Ranking{Rank: i + 1, ItemID: id, Score: item.Score})
+		if !item.Unchanged() {
+			changed = append(changed, item)
+		}
+	}
+
+	// Keep the stories themselves current as well
+	if err := store.InsertItemsBatch(changed); err != nil {
+		return 0, fmt.Errorf("failed to store ranked stories: %w", err)
+	}
+	if err := store.InsertRankings(list, capturedAt, rankings); err != nil {
+		return 0, err
+	}
+	downloadLog().Infof("Captured %d %s stories", len(rankings), list)
+	return len(rankings), nil
+}
+
+// RankingLists returns the story lists a snapshot captures by default
+func (h *HackerNewsDataSource) RankingLists() []string {
+	return []string{"top", "new", "best"}
+}
+
+// CaptureRanking records the current ranking and score of the first depth
+// stories of a list (top, new or best) in the rankings table; depth 0 uses
+// DefaultRankingDepth
+func (h *HackerNewsDataSource) CaptureRanking(ctx context.Context, list string, depth int) (int, error) {
+	if h.storage == nil {
+		return 0, fmt.Errorf("storage not initialized")
+	}
+	return captureRanking(ctx, h.client, h.storage, list, depth)
+}
@@ -0,0 +1,57 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureRanking(t *testing.T) {
+	log.InitLogger(false)
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/topstories.json" {
+			w.Write([]byte("[30, 10, 20, 40]"))
+			return
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/item/"), ".json")
+		fmt.Fprintf(w, `{"id": %s, "type": "story", "by": "alice", "score": %s}`, id, id)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.httpClient = server.Client()
+	client.baseURL = server.URL
+
+	count, err := captureRanking(context.Background(), client, storage, "top", 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	result, err := storage.Query("SELECT list, rank, item_id, score FROM rankings ORDER BY rank")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{"top", int64(1), int64(30), int64(30)},
+		{"top", int64(2), int64(10), int64(10)},
+		{"top", int64(3), int64(20), int64(20)},
+	}, result.Rows)
+
+	// Ranked stories are stored as items too
+	result, err = storage.Query("SELECT COUNT(*) FROM items")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Rows[0][0])
+
+	_, err = captureRanking(context.Background(), client, storage, "ask", 0)
+	assert.Error(t, err)
+}
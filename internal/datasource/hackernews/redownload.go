@@ -0,0 +1,189 @@
+package hackernews
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// redownloadColumns are the stored columns a re-download compares, in the
+// order of itemValues
+var redownloadColumns = []string{"type", "by", "time", "text", "dead", "deleted", "parent", "kids", "url", "score", "title", "descendants", "fields"}
+
+// shortValueLength is the longest value a difference is logged with; longer
+// ones, such as text, are only named
+const shortValueLength = 40
+
+// itemValues formats the compared columns of an item as they are read
+// back from storage
+func itemValues(item *Item) []string {
+	kids := ""
+	if len(item.Kids) > 0 {
+		encoded, _ := json.Marshal(item.Kids)
+		kids = string(encoded)
+	}
+	fields := ""
+	if len(item.Fields) > 0 {
+		encoded, _ := json.Marshal(item.Fields)
+		fields = string(encoded)
+	}
+	return []string{
+		item.Type, item.By, strconv.FormatInt(item.Time, 10), item.Text,
+		strconv.FormatBool(item.Dead), strconv.FormatBool(item.Deleted), strconv.FormatInt(item.Parent, 10), kids,
+		item.URL, strconv.FormatInt(item.Score, 10), item.Title, strconv.FormatInt(item.Descendants, 10), fields,
+	}
+}
+
+// storedValues returns the compared columns of the stored items among ids
+func (s *Storage) storedValues(ids []int64) (map[int64][]string, error) {
+	stored := make(map[int64][]string, len(ids))
+	if len(ids) == 0 {
+		return stored, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.db.Query("SELECT id, "+strings.Join(redownloadColumns, ", ")+" FROM items WHERE id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		values := make([]sql.NullString, len(redownloadColumns))
+		dest := make([]interface{}, len(values)+1)
+		dest[0] = &id
+		for i := range values {
+			dest[i+1] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to read stored item: %w", err)
+		}
+		formatted := make([]string, len(values))
+		for i, value := range values {
+			formatted[i] = value.String
+		}
+		stored[id] = formatted
+	}
+	return stored, rows.Err()
+}
+
+// itemDifferences describes how a fetched item differs from the stored one,
+// e.g. "score 10 -> 12, text"; empty when they match
+func itemDifferences(stored, fetched []string) string {
+	var differences []string
+	for i, column := range redownloadColumns {
+		if stored[i] == fetched[i] {
+			continue
+		}
+		if len(stored[i]) > shortValueLength || len(fetched[i]) > shortValueLength {
+			differences = append(differences, column)
+			continue
+		}
+		differences = append(differences, fmt.Sprintf("%s %q -> %q", column, stored[i], fetched[i]))
+	}
+	return strings.Join(differences, ", ")
+}
+
+// redownloadItems fetches the given items again and overwrites the stored
+// rows, whatever the conflict strategy, logging how each stored item
+// changed. Items the API no longer returns get a tombstone; items that fail
+// are recorded for the next repair. Stored in batches so an interrupted
+// re-download keeps its progress.
+func redownloadItems(ctx context.Context, client *Client, store *Storage, ids []int64, progress func(done, total int64)) error {
+	downloadLog().Infof("Re-downloading %d items", len(ids))
+
+	const storeBatch = 100
+	checked := make([]int64, 0, storeBatch)
+	items := make([]*Item, 0, storeBatch)
+	var changed, inserted, failed int
+	flush := func() error {
+		transformed, err := transformItems(items)
+		if err != nil {
+			return err
+		}
+		fetchedIDs := make([]int64, len(transformed))
+		for i, item := range transformed {
+			fetchedIDs[i] = item.ID
+		}
+		stored, err := store.storedValues(fetchedIDs)
+		if err != nil {
+			return err
+		}
+		for _, item := range transformed {
+			previous, ok := stored[item.ID]
+			if !ok {
+				inserted++
+				continue
+			}
+			if differences := itemDifferences(previous, itemValues(item)); differences != "" {
+				changed++
+				downloadLog().Infof("Item %d changed: %s", item.ID, differences)
+			}
+		}
+		if _, err := store.upsertItems(transformed, ConflictKeepNewest); err != nil {
+			return fmt.Errorf("failed to store re-downloaded items: %w", err)
+		}
+		if err := store.RecordItemStates(checked, items); err != nil {
+			return err
+		}
+		checked, items = checked[:0], items[:0]
+		return nil
+	}
+
+	total := int64(len(ids))
+	for i, id := range ids {
+		if err := ctx.Err(); err != nil {
+			if flushErr := flush(); flushErr != nil {
+				return flushErr
+			}
+			return err
+		}
+
+		item, err := client.GetItem(ctx, id)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			failed++
+			downloadLog().Warnf("Failed to re-download item %d: %v", id, err)
+			if err := store.RecordFetchFailure(id, err); err != nil {
+				return err
+			}
+		case err == nil:
+			checked = append(checked, id)
+			if item != nil {
+				items = append(items, item)
+			}
+		}
+
+		if len(checked) == storeBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if progress != nil {
+			progress(int64(i+1), total)
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+	downloadLog().Infof("Re-downloaded %d items: %d changed, %d new, %d failed", len(ids), changed, inserted, failed)
+	return nil
+}
+
+// RedownloadItems fetches specific items again, such as known corrupted
+// rows, and updates them in place, logging how each one changed. progress
+// is called after each item.
+func (h *HackerNewsDataSource) RedownloadItems(ctx context.Context, ids []int64, progress func(done, total int64)) error {
+	if h.storage == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	return redownloadItems(ctx, h.client, h.storage, ids, progress)
+}
@@ -0,0 +1,85 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedownloadItems(t *testing.T) {
+	log.InitLogger(false)
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/item/"), ".json")
+		switch id {
+		case "3":
+			w.Write([]byte("null"))
+		case "4":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			fmt.Fprintf(w, `{"id": %s, "type": "story", "by": "alice", "title": "Fixed", "score": 12, "kids": [10, 11]}`, id)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.httpClient = server.Client()
+	client.baseURL = server.URL
+
+	// Item 1 is corrupted; item 2 is not stored yet
+	require.NoError(t, storage.InsertItemsBatch([]*Item{{ID: 1, Type: "story", By: "alice", Title: "Garbled", Score: 10}}))
+
+	// The re-download overwrites even with a strategy that would keep the row
+	SetConflictStrategy(ConflictKeepHighestScore)
+	defer SetConflictStrategy(ConflictKeepNewest)
+
+	var done, total int64
+	require.NoError(t, redownloadItems(context.Background(), client, storage, []int64{1, 2, 3, 4}, func(d, t int64) {
+		done, total = d, t
+	}))
+	assert.Equal(t, int64(4), done)
+	assert.Equal(t, int64(4), total)
+
+	result, err := storage.Query("SELECT id, title, score, kids FROM items ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{int64(1), "Fixed", int64(12), "[10,11]"},
+		{int64(2), "Fixed", int64(12), "[10,11]"},
+	}, result.Rows)
+
+	result, err = storage.Query("SELECT item_id, reason FROM tombstones ORDER BY item_id")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{int64(3), TombstoneMissing},
+		{int64(4), TombstoneFailed},
+	}, result.Rows)
+}
+
+func TestItemDifferences(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	stored := &Item{ID: 1, Type: "story", Title: "Garbled", Score: 10, Text: strings.Repeat("a", 100)}
+	require.NoError(t, storage.InsertItemsBatch([]*Item{stored}))
+	values, err := storage.storedValues([]int64{1, 2})
+	require.NoError(t, err)
+	require.Len(t, values, 1)
+
+	assert.Empty(t, itemDifferences(values[1], itemValues(stored)), "a stored item reads back unchanged")
+
+	fetched := *stored
+	fetched.Score, fetched.Text, fetched.Dead = 12, "short", true
+	assert.Equal(t, `text, dead "false" -> "true", score "10" -> "12"`, itemDifferences(values[1], itemValues(&fetched)))
+}
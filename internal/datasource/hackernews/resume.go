@@ -0,0 +1,208 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// verifySampleSize is how many completed batches a verified download
+// recounts
+const verifySampleSize = 50
+
+// idRange is an inclusive range of item IDs
+type idRange struct {
+	start, end int64
+}
+
+// mergeRanges sorts ranges and merges those that overlap or touch
+func mergeRanges(ranges []idRange) []idRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+
+	var merged []idRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.start <= merged[n-1].end+1 {
+			merged[n-1].end = max(merged[n-1].end, r.end)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// rangeGaps returns the parts of 1 to maxID outside the merged ranges,
+// highest first
+func rangeGaps(merged []idRange, maxID int64) []idRange {
+	var gaps []idRange
+	next := maxID
+	for i := len(merged) - 1; i >= 0 && next >= 1; i-- {
+		r := merged[i]
+		if r.start > next {
+			continue
+		}
+		if r.end < next {
+			gaps = append(gaps, idRange{r.end + 1, next})
+		}
+		next = r.start - 1
+	}
+	if next >= 1 {
+		gaps = append(gaps, idRange{1, next})
+	}
+	return gaps
+}
+
+// covers reports whether the merged ranges hold all of r
+func covers(merged []idRange, r idRange) bool {
+	for _, m := range merged {
+		if m.start <= r.start && r.end <= m.end {
+			return true
+		}
+	}
+	return false
+}
+
+// SetVerify makes downloads recount a sample of completed batches first,
+// fetching again those with items missing
+func (d *Downloader) SetVerify(verify bool) {
+	d.verify = verify
+}
+
+// calculateMissingBatches rebuilds from the batch status the batches a
+// download of items 1 to maxID still needs, newest first. Completed batches
+// are not fetched again, whatever batch size made them. Batches an
+// interrupted download left incomplete are recounted: those fully stored
+// are marked completed, the others resume with their own range. The rest is
+// split into new batches, skipping those with 90% of their items stored.
+func (d *Downloader) calculateMissingBatches(ctx context.Context, maxID int64) ([]BatchStatus, error) {
+	existing, err := d.storage.GetBatchStatus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch status: %w", err)
+	}
+
+	var completed, incomplete []BatchStatus
+	for _, batch := range existing {
+		switch {
+		case batch.BatchStart > maxID:
+		case batch.Completed:
+			completed = append(completed, batch)
+		default:
+			incomplete = append(incomplete, batch)
+		}
+	}
+
+	if d.verify {
+		failed, err := d.verifyBatches(ctx, completed)
+		if err != nil {
+			return nil, err
+		}
+		incomplete = append(incomplete, failed...)
+	}
+
+	// Verification marks the batches it fails incomplete in place
+	var done []idRange
+	for _, batch := range completed {
+		if batch.Completed {
+			done = append(done, idRange{batch.BatchStart, batch.BatchEnd})
+		}
+	}
+	done = mergeRanges(done)
+
+	var missing []BatchStatus
+	planned := append([]idRange(nil), done...)
+	for _, batch := range incomplete {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r := idRange{batch.BatchStart, batch.BatchEnd}
+		if covers(done, r) {
+			continue
+		}
+
+		stored, err := d.storage.CountStoredItems(r.start, r.end)
+		if err != nil {
+			return nil, err
+		}
+		batch.ItemsDownloaded = stored
+		if stored == int(r.end-r.start+1) {
+			// Stored before the download stopped, but not marked
+			now := time.Now()
+			batch.Completed = true
+			batch.CompletedAt = &now
+			if err := d.storage.SetBatchStatus(batch); err != nil {
+				downloadLog().Errorf("Failed to update batch status: %v", err)
+			}
+		} else {
+			downloadLog().Infof("Resuming incomplete batch %d-%d with %d of %d items stored", r.start, r.end, stored, r.end-r.start+1)
+			missing = append(missing, batch)
+		}
+		planned = append(planned, r)
+	}
+	planned = mergeRanges(planned)
+
+	batchSize := int64(d.batchSize)
+	for _, gap := range rangeGaps(planned, maxID) {
+		for end := gap.end; end >= gap.start; end -= batchSize {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			start := max(end-batchSize+1, gap.start)
+
+			stored, err := d.storage.CountStoredItems(start, end)
+			if err != nil {
+				return nil, err
+			}
+			if float64(stored)/float64(end-start+1) >= 0.9 {
+				continue
+			}
+			missing = append(missing, BatchStatus{
+				BatchStart:      start,
+				BatchEnd:        end,
+				BatchSize:       int(batchSize),
+				ItemsDownloaded: stored,
+				CreatedAt:       time.Now(),
+			})
+		}
+	}
+
+	sort.Slice(missing, func(i, j int) bool { return missing[i].BatchEnd > missing[j].BatchEnd })
+	return missing, nil
+}
+
+// verifyBatches recounts a sample of the completed batches and marks those
+// with items missing incomplete, returning them
+func (d *Downloader) verifyBatches(ctx context.Context, completed []BatchStatus) ([]BatchStatus, error) {
+	sample := rand.Perm(len(completed))
+	if len(sample) > verifySampleSize {
+		sample = sample[:verifySampleSize]
+	}
+
+	var failed []BatchStatus
+	for _, i := range sample {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		batch := &completed[i]
+		stored, err := d.storage.CountStoredItems(batch.BatchStart, batch.BatchEnd)
+		if err != nil {
+			return nil, err
+		}
+		expected := int(batch.BatchEnd - batch.BatchStart + 1)
+		if stored >= expected {
+			continue
+		}
+
+		downloadLog().Warnf("Completed batch %d-%d has %d of %d items stored, fetching it again", batch.BatchStart, batch.BatchEnd, stored, expected)
+		batch.Completed = false
+		batch.CompletedAt = nil
+		batch.ItemsDownloaded = stored
+		if err := d.storage.SetBatchStatus(*batch); err != nil {
+			return nil, fmt.Errorf("failed to update batch status: %w", err)
+		}
+		failed = append(failed, *batch)
+	}
+
+	downloadLog().Infof("Verified %d of %d completed batches, %d with items missing", len(sample), len(completed), len(failed))
+	return failed, nil
+}
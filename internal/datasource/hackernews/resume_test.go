@@ -0,0 +1,74 @@
+package hackernews
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func idsBetween(start, end int64) []int64 {
+	var ids []int64
+	for id := start; id <= end; id++ {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func batchRanges(batches []BatchStatus) []idRange {
+	var ranges []idRange
+	for _, batch := range batches {
+		ranges = append(ranges, idRange{batch.BatchStart, batch.BatchEnd})
+	}
+	return ranges
+}
+
+func TestRangeGaps(t *testing.T) {
+	merged := mergeRanges([]idRange{{51, 60}, {10, 20}, {21, 30}, {55, 70}})
+	assert.Equal(t, []idRange{{10, 30}, {51, 70}}, merged)
+	assert.Equal(t, []idRange{{71, 100}, {31, 50}, {1, 9}}, rangeGaps(merged, 100))
+	assert.Equal(t, []idRange{{31, 40}, {1, 9}}, rangeGaps(merged, 40))
+	assert.True(t, covers(merged, idRange{52, 70}))
+	assert.False(t, covers(merged, idRange{25, 55}))
+}
+
+func TestDownloader_CalculateMissingBatches(t *testing.T) {
+	log.InitLogger(false)
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	now := time.Now()
+	// Completed by an earlier download with another batch size
+	require.NoError(t, storage.SetBatchStatus(BatchStatus{BatchStart: 101, BatchEnd: 200, BatchSize: 100, Completed: true, CreatedAt: now, CompletedAt: &now}))
+	// Interrupted with some items stored
+	require.NoError(t, storage.SetBatchStatus(BatchStatus{BatchStart: 201, BatchEnd: 230, BatchSize: 30, CreatedAt: now}))
+	require.NoError(t, storage.RecordItemStates(idsBetween(201, 210), nil))
+	// Interrupted after storing every item
+	require.NoError(t, storage.SetBatchStatus(BatchStatus{BatchStart: 1, BatchEnd: 20, BatchSize: 20, CreatedAt: now}))
+	require.NoError(t, storage.RecordItemStates(idsBetween(1, 20), nil))
+
+	downloader := NewDownloader(nil, storage, 50)
+	missing, err := downloader.calculateMissingBatches(context.Background(), 250)
+	require.NoError(t, err)
+	assert.Equal(t, []idRange{{231, 250}, {201, 230}, {51, 100}, {21, 50}}, batchRanges(missing))
+	assert.Equal(t, 10, missing[1].ItemsDownloaded)
+
+	batches, err := storage.GetBatchStatus()
+	require.NoError(t, err)
+	for _, batch := range batches {
+		if batch.BatchStart == 1 {
+			assert.True(t, batch.Completed)
+		}
+	}
+
+	// Verification fetches completed batches with items missing again
+	downloader.SetVerify(true)
+	missing, err = downloader.calculateMissingBatches(context.Background(), 250)
+	require.NoError(t, err)
+	assert.Equal(t, []idRange{{231, 250}, {201, 230}, {101, 200}, {51, 100}, {21, 50}}, batchRanges(missing))
+}
@@ -0,0 +1,83 @@
+package hackernews
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+// Defaults of the per-source settings
+const (
+	DefaultBatchSize = 100 // Items per download batch
+	DefaultWorkers   = 1   // Items of a batch fetched at the same time
+)
+
+// Allowed ranges of the per-source settings
+const (
+	maxBatchSize = 10000
+	maxRateLimit = 1000
+	maxWorkers   = 32
+)
+
+// Descriptor describes the settings of the Hacker News data source
+var Descriptor = datasource.Descriptor{
+	Name:        sourceName,
+	Description: "Hacker News stories, comments and users",
+	Settings: []datasource.Setting{
+		{Key: "batch_size", Description: "Items per download batch", Default: strconv.Itoa(DefaultBatchSize), Min: 1, Max: maxBatchSize},
+		{Key: "rate_limit", Description: "API requests per second", Default: strconv.Itoa(DefaultRequestsPerSecond), Min: 1, Max: maxRateLimit},
+		{Key: "workers", Description: "Items of a batch fetched at the same time", Default: strconv.Itoa(DefaultWorkers), Min: 1, Max: maxWorkers},
+		{Key: "api_base_url", Description: "Base URL of the API, e.g. a mirror or proxy", Default: BaseURL, URL: true},
+	},
+}
+
+func init() {
+	datasource.RegisterDescriptor(Descriptor)
+	batchSize.Store(DefaultBatchSize)
+	fetchWorkers.Store(DefaultWorkers)
+}
+
+// batchSize is the batch size of data sources created without one
+var batchSize atomic.Int32
+
+// fetchWorkers is how many items clients created from now on fetch at once
+var fetchWorkers atomic.Int32
+
+// apiBaseURL is the API base URL of clients created from now on; empty is
+// BaseURL
+var apiBaseURL atomic.Value
+
+// SetBatchSize sets the batch size of data sources created afterwards
+// without one; values below 1 restore the default
+func SetBatchSize(size int) {
+	if size < 1 {
+		size = DefaultBatchSize
+	}
+	batchSize.Store(int32(size))
+}
+
+// SetWorkers sets how many items of a batch clients created afterwards
+// fetch at the same time, within the rate limit; values below 1 restore
+// the default
+func SetWorkers(workers int) {
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+	fetchWorkers.Store(int32(workers))
+}
+
+// SetAPIBaseURL sets the API base URL of clients created afterwards, such
+// as a mirror; empty restores BaseURL
+func SetAPIBaseURL(url string) {
+	apiBaseURL.Store(strings.TrimSuffix(url, "/"))
+}
+
+// currentAPIBaseURL returns the API base URL of new clients
+func currentAPIBaseURL() string {
+	if url, ok := apiBaseURL.Load().(string); ok && url != "" {
+		return url
+	}
+	return BaseURL
+}
@@ -0,0 +1,103 @@
+package hackernews
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescriptor(t *testing.T) {
+	descriptor, err := datasource.LookupDescriptor("hackernews")
+	require.NoError(t, err)
+
+	value, err := descriptor.Parse("batch_size", "500")
+	require.NoError(t, err)
+	assert.Equal(t, 500, value)
+	_, err = descriptor.Parse("batch_size", "0")
+	assert.Error(t, err)
+	_, err = descriptor.Parse("workers", "64")
+	assert.Error(t, err)
+	_, err = descriptor.Parse("api_base_url", "ftp://example.com")
+	assert.Error(t, err)
+
+	setting, ok := descriptor.Setting("api_base_url")
+	require.True(t, ok)
+	assert.Equal(t, BaseURL, setting.Default)
+}
+
+func TestSettings(t *testing.T) {
+	defer SetBatchSize(0)
+	defer SetWorkers(0)
+	defer SetAPIBaseURL("")
+
+	SetBatchSize(250)
+	SetWorkers(4)
+	SetAPIBaseURL("http://mirror.example.com/v0/")
+	ds := NewHackerNewsDataSource(0)
+	assert.Equal(t, 250, ds.batchSize)
+	assert.Equal(t, 4, ds.client.workers)
+	assert.Equal(t, "http://mirror.example.com/v0", ds.client.baseURL)
+	assert.Equal(t, 50, NewHackerNewsDataSource(50).batchSize)
+
+	SetBatchSize(0)
+	SetWorkers(0)
+	SetAPIBaseURL("")
+	client := NewClient()
+	assert.Equal(t, DefaultWorkers, client.workers)
+	assert.Equal(t, BaseURL, client.baseURL)
+	assert.Equal(t, DefaultBatchSize, NewHackerNewsDataSource(0).batchSize)
+}
+
+func TestClient_GetItemsBatch_Concurrent(t *testing.T) {
+	failing := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/item/"), ".json")
+		if id == failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if id == "5" {
+			w.Write([]byte("null"))
+			return
+		}
+		fmt.Fprintf(w, `{"id": %s, "type": "comment"}`, id)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.rateLimiter = NewRateLimiter(1000, time.Second)
+	client.httpClient = server.Client()
+	client.baseURL = server.URL
+	client.workers = 4
+
+	items, err := client.GetItemsBatch(context.Background(), 1, 20)
+	require.NoError(t, err)
+	require.Len(t, items, 19)
+	for i, item := range items {
+		expected := int64(i + 1)
+		if expected >= 5 {
+			expected++
+		}
+		assert.Equal(t, expected, item.ID)
+	}
+
+	failing = "12"
+	items, err = client.GetItemsBatch(context.Background(), 1, 20)
+	var itemErr *ItemError
+	require.True(t, errors.As(err, &itemErr))
+	assert.Equal(t, int64(12), itemErr.ID)
+	assert.LessOrEqual(t, len(items), 10)
+	for i, item := range items {
+		assert.Less(t, item.ID, int64(12), "item %d", i)
+	}
+}
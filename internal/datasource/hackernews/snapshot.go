@@ -0,0 +1,338 @@
+package hackernews
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/httpclient"
+)
+
+// snapshotBatchSize is the number of snapshot items stored per transaction
+const snapshotBatchSize = 5000
+
+// snapshotCoverageSize is the ID range of each completed batch recorded for
+// a snapshot, which a verified download fetches again when items are missing
+const snapshotCoverageSize = 10000
+
+// Metadata keys recording the snapshot a database was bootstrapped from
+const (
+	metadataSnapshotSource = "snapshot_source"
+	metadataSnapshotMaxID  = "snapshot_max_id"
+	metadataSnapshotTime   = "snapshot_imported_at"
+)
+
+// snapshotTimestampLayout is the format of the BigQuery timestamp column
+const snapshotTimestampLayout = "2006-01-02 15:04:05 MST"
+
+// SnapshotResult describes an imported snapshot
+type SnapshotResult struct {
+	Items    int64
+	MaxID    int64
+	Duration time.Duration
+}
+
+// Bootstrap imports a published snapshot of items from location, a file or
+// an http(s) URL, then records items 1 to the highest snapshot ID as
+// downloaded so later downloads and syncs only fetch newer items from the
+// API. Snapshots are newline-delimited JSON in the API's item format or CSV
+// with a header row in the BigQuery column names, optionally gzipped. A URL
+// is downloaded in parallel segments to the snapshots directory first, so an
+// interrupted bootstrap continues the download; sha256 is verified when set.
+func (h *HackerNewsDataSource) Bootstrap(ctx context.Context, location, sha256 string) (*SnapshotResult, error) {
+	if h.storage == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+	if h.downloader != nil && h.downloader.GetDownloadStatus().IsActive {
+		return nil, fmt.Errorf("cannot bootstrap while a download is active")
+	}
+
+	path := location
+	downloaded := strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+	if downloaded {
+		var err error
+		if path, err = fetchSnapshot(ctx, location, sha256, filepath.Join(h.storage.GetStoragePath(), "snapshots")); err != nil {
+			return nil, err
+		}
+	} else if sha256 != "" {
+		return nil, fmt.Errorf("checksums are only verified for downloaded snapshots")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+
+	result, err := importSnapshot(ctx, h.storage, file, location)
+	if err != nil {
+		return nil, err
+	}
+	// The imported items replace the downloaded file
+	if downloaded {
+		os.Remove(path)
+	}
+	return result, nil
+}
+
+// fetchSnapshot downloads a snapshot into dir, continuing an interrupted
+// download of it, and returns its path
+func fetchSnapshot(ctx context.Context, location, sha256, dir string) (string, error) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid snapshot URL: %w", err)
+	}
+	name := path.Base(parsed.Path)
+	if name == "." || name == "/" {
+		name = "snapshot"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	dest := filepath.Join(dir, name)
+
+	// Snapshots are too large for the response cache and the API timeout;
+	// the context bounds the download instead
+	config := httpclient.ForSource(sourceName)
+	config.CacheEnabled = false
+	client, err := httpclient.New(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	client.Timeout = 0
+
+	var reported time.Time
+	err = httpclient.DownloadFile(ctx, client, httpclient.FileDownload{URL: location, Path: dest, SHA256: sha256}, func(done, total int64) {
+		if time.Since(reported) < 10*time.Second {
+			return
+		}
+		reported = time.Now()
+		if total > 0 {
+			downloadLog().Infof("Downloaded %d of %d snapshot bytes", done, total)
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to download snapshot: %w", err)
+	}
+	return dest, nil
+}
+
+// importSnapshot stores the items read from r in batches and records the
+// coverage of the snapshot
+func importSnapshot(ctx context.Context, s *Storage, r io.Reader, location string) (*SnapshotResult, error) {
+	started := time.Now()
+	reader := bufio.NewReaderSize(r, 1<<20)
+
+	// Detect compression by its magic bytes, whatever the file is named
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzipped snapshot: %w", err)
+		}
+		defer gz.Close()
+		reader = bufio.NewReaderSize(gz, 1<<20)
+	}
+
+	next, err := snapshotDecoder(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SnapshotResult{}
+	batch := make([]*Item, 0, snapshotBatchSize)
+	flush := func() error {
+		if err := s.InsertItemsBatch(batch); err != nil {
+			return fmt.Errorf("failed to store snapshot items: %w", err)
+		}
+		result.Items += int64(len(batch))
+		batch = batch[:0]
+		if result.Items%(100*snapshotBatchSize) == 0 {
+			downloadLog().Infof("Imported %d snapshot items", result.Items)
+		}
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		item, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot item %d: %w", result.Items+int64(len(batch))+1, err)
+		}
+		if item.ID <= 0 {
+			continue
+		}
+		result.MaxID = max(result.MaxID, item.ID)
+		batch = append(batch, item)
+		if len(batch) == snapshotBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if result.Items == 0 {
+		return nil, fmt.Errorf("snapshot %s holds no items", location)
+	}
+
+	if err := recordSnapshot(s, result.MaxID, location); err != nil {
+		return nil, err
+	}
+	result.Duration = time.Since(started)
+	downloadLog().Infof("Imported %d snapshot items up to ID %d in %s", result.Items, result.MaxID, result.Duration.Round(time.Second))
+	return result, nil
+}
+
+// recordSnapshot marks items 1 to maxID downloaded, so the batch planner
+// leaves them out, and keeps where the snapshot came from. IDs absent from
+// the snapshot are not fetched from the API; a verified download recounts
+// a sample of the recorded batches.
+func recordSnapshot(s *Storage, maxID int64, location string) error {
+	now := time.Now()
+	for start := int64(1); start <= maxID; start += snapshotCoverageSize {
+		batch := BatchStatus{
+			BatchStart:  start,
+			BatchEnd:    min(start+snapshotCoverageSize-1, maxID),
+			BatchSize:   snapshotCoverageSize,
+			Completed:   true,
+			CreatedAt:   now,
+			CompletedAt: &now,
+		}
+		if err := s.SetBatchStatus(batch); err != nil {
+			return fmt.Errorf("failed to record snapshot coverage: %w", err)
+		}
+	}
+
+	// Keep a higher max ID from an earlier download
+	stored, _ := s.GetMetadata("max_id")
+	if current, err := strconv.ParseInt(stored, 10, 64); err != nil || current < maxID {
+		if err := s.SetMetadata("max_id", strconv.FormatInt(maxID, 10)); err != nil {
+			return fmt.Errorf("failed to record max ID: %w", err)
+		}
+	}
+
+	metadata := map[string]string{
+		metadataSnapshotSource: location,
+		metadataSnapshotMaxID:  strconv.FormatInt(maxID, 10),
+		metadataSnapshotTime:   now.UTC().Format(time.RFC3339),
+	}
+	for key, value := range metadata {
+		if err := s.SetMetadata(key, value); err != nil {
+			return fmt.Errorf("failed to record snapshot metadata: %w", err)
+		}
+	}
+	return nil
+}
+
+// snapshotDecoder returns a function reading the next item of a JSON lines
+// or CSV snapshot, told apart by the first character
+func snapshotDecoder(reader *bufio.Reader) (func() (*Item, error), error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	if first[0] == '{' {
+		decoder := json.NewDecoder(reader)
+		return func() (*Item, error) {
+			var item Item
+			if err := decoder.Decode(&item); err != nil {
+				return nil, err
+			}
+			return &item, nil
+		}, nil
+	}
+
+	records := csv.NewReader(reader)
+	records.ReuseRecord = true
+	header, err := records.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["id"]; !ok {
+		return nil, fmt.Errorf("snapshot is neither JSON lines nor CSV with an id column")
+	}
+	return func() (*Item, error) {
+		record, err := records.Read()
+		if err != nil {
+			return nil, err
+		}
+		return csvItem(record, columns)
+	}, nil
+}
+
+// csvItem builds an item from a CSV snapshot row
+func csvItem(record []string, columns map[string]int) (*Item, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	number := func(name string) (int64, error) {
+		value := field(name)
+		if value == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q", name, value)
+		}
+		return n, nil
+	}
+
+	item := &Item{
+		Type:    field("type"),
+		By:      field("by"),
+		Text:    field("text"),
+		URL:     field("url"),
+		Title:   field("title"),
+		Dead:    field("dead") == "true",
+		Deleted: field("deleted") == "true",
+	}
+	var err error
+	if item.ID, err = number("id"); err != nil {
+		return nil, err
+	}
+	if item.Parent, err = number("parent"); err != nil {
+		return nil, err
+	}
+	if item.Score, err = number("score"); err != nil {
+		return nil, err
+	}
+	if item.Descendants, err = number("descendants"); err != nil {
+		return nil, err
+	}
+	if item.Time, err = number("time"); err != nil {
+		return nil, err
+	}
+	// Some exports only carry the formatted timestamp
+	if item.Time == 0 && field("timestamp") != "" {
+		if parsed, err := time.Parse(snapshotTimestampLayout, field("timestamp")); err == nil {
+			item.Time = parsed.Unix()
+		}
+	}
+	return item, nil
+}
@@ -0,0 +1,95 @@
+package hackernews
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportSnapshot_JSONLines(t *testing.T) {
+	log.InitLogger(false)
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	snapshot := `{"id":1,"type":"story","by":"pg","time":1160418111,"title":"Y Combinator","url":"http://ycombinator.com","score":57,"kids":[15]}
+{"id":15,"type":"comment","by":"sama","time":1160423461,"parent":1,"text":"Good"}
+{"id":20,"type":"story","by":"pg","time":1160424038,"title":"Gone","dead":true}
+`
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	_, err := gz.Write([]byte(snapshot))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(gzipped.Bytes())
+	}))
+	defer server.Close()
+
+	snapshotPath, err := fetchSnapshot(context.Background(), server.URL+"/items.json.gz", "", t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, "items.json.gz", filepath.Base(snapshotPath))
+	body, err := os.Open(snapshotPath)
+	require.NoError(t, err)
+	defer body.Close()
+	result, err := importSnapshot(context.Background(), storage, body, server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Items)
+	assert.Equal(t, int64(20), result.MaxID)
+
+	res, err := storage.Query("SELECT title, dead FROM items WHERE id = 20")
+	require.NoError(t, err)
+	require.Len(t, res.Rows, 1)
+	assert.Equal(t, "Gone", res.Rows[0][0])
+
+	maxID, err := storage.GetMetadata("max_id")
+	require.NoError(t, err)
+	assert.Equal(t, "20", maxID)
+
+	// Only items newer than the snapshot are planned
+	downloader := NewDownloader(nil, storage, 10)
+	missing, err := downloader.calculateMissingBatches(context.Background(), 45)
+	require.NoError(t, err)
+	assert.Equal(t, []idRange{{36, 45}, {26, 35}, {21, 25}}, batchRanges(missing))
+}
+
+func TestImportSnapshot_CSV(t *testing.T) {
+	log.InitLogger(false)
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	snapshot := `title,url,text,dead,by,score,time,timestamp,type,id,parent,descendants,ranking,deleted
+"Hello, world",http://example.com,,,alice,10,1160418111,2006-10-09 18:21:51 UTC,story,7,,2,,
+,,"A ""quoted"" reply",,bob,,,2006-10-09 19:00:00 UTC,comment,9,7,,0,
+`
+	result, err := importSnapshot(context.Background(), storage, strings.NewReader(snapshot), "items.csv")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.Items)
+	assert.Equal(t, int64(9), result.MaxID)
+
+	res, err := storage.Query("SELECT title, score, descendants FROM items WHERE id = 7")
+	require.NoError(t, err)
+	require.Len(t, res.Rows, 1)
+	assert.Equal(t, "Hello, world", res.Rows[0][0])
+
+	res, err = storage.Query("SELECT text, parent, time FROM items WHERE id = 9")
+	require.NoError(t, err)
+	require.Len(t, res.Rows, 1)
+	assert.Equal(t, `A "quoted" reply`, res.Rows[0][0])
+	assert.EqualValues(t, 1160420400, res.Rows[0][2])
+
+	_, err = importSnapshot(context.Background(), storage, strings.NewReader("name,karma\npg,1\n"), "users.csv")
+	assert.Error(t, err)
+}
@@ -1,57 +1,143 @@
 package hackernews
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/brainless/PubDataHub/internal/dataset"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// SchemaVersion is the version of the Hacker News database schema; bump it
+// when migrate changes in a way older databases cannot be read
+const SchemaVersion = 1
+
+// sourceName is the data source name reported with table writes
+const sourceName = "hackernews"
+
+// databaseFile is the name of the database file in the storage directory
+const databaseFile = "hackernews.sqlite"
+
+// errSharedLayout is returned by dataset operations that work on a whole
+// database file, which the shared layout shares with other components
+var errSharedLayout = fmt.Errorf("dataset export and import need the separate storage layout (storage.layout is shared)")
+
+// Multi-row INSERT sizing: 64 rows of 15 bound columns stay below SQLite's
+// 999 bound parameter limit
+const (
+	insertRowsPerStatement = 64
+	insertItemArgs         = 15
+)
+
+// insertItemColumns are the bound columns of an item INSERT
+const insertItemColumns = "(id, type, by, time, text, dead, deleted, parent, kids, url, url_canonical, score, title, descendants, fields, updated_at)"
+
+// insertItemRow is the VALUES row for one item
+const insertItemRow = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)"
+
+// itemTables are the tables and views that change when items are written
+var itemTables = []string{"items", "item_changes", "url_submissions", "url_duplicates"}
+
+// DatabasePath returns the Hacker News database file under a storage path
+// in the separate layout
+func DatabasePath(storagePath string) string {
+	return filepath.Join(storagePath, "hackernews", databaseFile)
+}
+
 // Storage handles SQLite database operations for Hacker News data
 type Storage struct {
-	db   *sql.DB
-	path string
+	db          *sql.DB
+	path        string
+	dbPath      string
+	monitor     *storage.DBHealthMonitor
+	derived     *storage.DerivedTables
+	checkpoints *storage.Checkpoints
+
+	// Multi-row INSERT statements by row count and conflict strategy,
+	// reused across batches
+	insertStmts map[insertKey]*sql.Stmt
+	stmtMutex   sync.Mutex
+
+	// Serializes changes to the archive databases and their query cache
+	archiveMutex sync.Mutex
+}
+
+// insertKey identifies a prepared item INSERT
+type insertKey struct {
+	rows     int
+	strategy ConflictStrategy
 }
 
 // BatchStatus represents the status of a download batch
 type BatchStatus struct {
-	BatchStart      int64      `json:"batch_start"`
-	BatchEnd        int64      `json:"batch_end"`
-	BatchSize       int        `json:"batch_size"`
-	Completed       bool       `json:"completed"`
-	ItemsDownloaded int        `json:"items_downloaded"`
-	CreatedAt       time.Time  `json:"created_at"`
-	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	BatchStart      int64      `json:"batch_start" db:"batch_start"`
+	BatchEnd        int64      `json:"batch_end" db:"batch_end"`
+	BatchSize       int        `json:"batch_size" db:"batch_size"`
+	Completed       bool       `json:"completed" db:"completed"`
+	ItemsDownloaded int        `json:"items_downloaded" db:"items_downloaded"`
+	ItemsInserted   int        `json:"items_inserted" db:"items_inserted"` // Downloaded items that were new
+	ItemsUpdated    int        `json:"items_updated" db:"items_updated"`   // Downloaded items that updated stored ones
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty" db:"completed_at"`
 }
 
 // NewStorage creates a new storage instance
 func NewStorage(storagePath string) (*Storage, error) {
-	// Ensure directory exists
-	if err := os.MkdirAll(storagePath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	dbPath := storage.DatabasePath(storagePath, databaseFile)
+	if err := storage.PrepareDatabase(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to create database: %w", err)
 	}
 
-	dbPath := filepath.Join(storagePath, "hackernews.sqlite")
-	db, err := sql.Open("sqlite3", dbPath)
+	// In WAL mode synchronous=NORMAL skips the sync of every commit; a power
+	// loss or OS crash can lose the last commits but not corrupt the database
+	dsn := dbPath + "?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000"
+	if storage.ReadOnly() {
+		dsn = storage.ReadOnlyDSN(dbPath)
+	}
+	db, err := sql.Open(storage.DriverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	storage := &Storage{
-		db:   db,
-		path: storagePath,
+	s := &Storage{
+		db:          db,
+		path:        storagePath,
+		dbPath:      dbPath,
+		monitor:     storage.NewDBHealthMonitor(db, dbPath, storage.DefaultDBHealthConfig()),
+		insertStmts: make(map[insertKey]*sql.Stmt),
 	}
 
-	if err := storage.migrate(); err != nil {
+	// A read-only database keeps the schema of the last writer
+	if !storage.ReadOnly() {
+		if err := s.migrate(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+	if s.derived, err = storage.NewDerivedTables(db); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+		return nil, err
+	}
+	if s.checkpoints, err = storage.NewCheckpoints(db); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	return storage, nil
+	// Only a writer can checkpoint the WAL
+	if !storage.ReadOnly() {
+		s.monitor.Start()
+	}
+	return s, nil
 }
 
 // migrate creates or updates the database schema
@@ -73,7 +159,10 @@ func (s *Storage) migrate() error {
 		title TEXT,
 		descendants INTEGER,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		url_canonical TEXT, -- url without tracking parameters, see storage.CanonicalURL
+		fields TEXT, -- JSON object of the fields computed by transform hooks
+		update_count INTEGER DEFAULT 0 -- times the item was written again after it was stored
 	);
 
 	-- Download metadata table
@@ -92,86 +181,315 @@ func (s *Storage) migrate() error {
 		items_downloaded INTEGER DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		completed_at DATETIME,
+		items_inserted INTEGER DEFAULT 0, -- downloaded items that were new
+		items_updated INTEGER DEFAULT 0, -- downloaded items that updated stored ones
 		PRIMARY KEY (batch_start, batch_end)
 	);
 
+	-- User profiles of item authors
+	CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		created INTEGER,
+		karma INTEGER,
+		about TEXT,
+		submitted INTEGER DEFAULT 0, -- number of submitted items
+		fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Karma recorded on each profile fetch
+	CREATE TABLE IF NOT EXISTS user_karma (
+		user_id TEXT NOT NULL,
+		karma INTEGER NOT NULL,
+		recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, recorded_at)
+	);
+
+	-- Story list rankings captured by snapshot jobs
+	CREATE TABLE IF NOT EXISTS rankings (
+		list TEXT NOT NULL, -- top, new or best
+		captured_at DATETIME NOT NULL,
+		rank INTEGER NOT NULL,
+		item_id INTEGER NOT NULL,
+		score INTEGER,
+		PRIMARY KEY (list, captured_at, rank)
+	);
+
+	-- Items that are dead, deleted, missing from the API or failed to
+	-- download, re-checked by repair jobs
+	CREATE TABLE IF NOT EXISTS tombstones (
+		item_id INTEGER PRIMARY KEY,
+		reason TEXT NOT NULL, -- dead, deleted, missing or failed
+		error TEXT, -- last fetch error
+		first_seen DATETIME DEFAULT CURRENT_TIMESTAMP,
+		checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		checks INTEGER DEFAULT 1
+	);
+
+	-- Updates of tracked item fields, recorded while change tracking is
+	-- enabled; see trackedItemFields
+	CREATE TABLE IF NOT EXISTS item_changes (
+		item_id INTEGER NOT NULL,
+		field TEXT NOT NULL, -- score, descendants, title, dead or deleted
+		old_value, -- untyped, keeping the type of the field
+		new_value,
+		changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
 	-- Indexes for performance
 	CREATE INDEX IF NOT EXISTS idx_items_type ON items(type);
 	CREATE INDEX IF NOT EXISTS idx_items_by ON items(by);
 	CREATE INDEX IF NOT EXISTS idx_items_time ON items(time);
 	CREATE INDEX IF NOT EXISTS idx_items_parent ON items(parent);
 	CREATE INDEX IF NOT EXISTS idx_batch_status_completed ON batch_status(completed);
+	CREATE INDEX IF NOT EXISTS idx_users_fetched_at ON users(fetched_at);
+	CREATE INDEX IF NOT EXISTS idx_rankings_item_id ON rankings(item_id);
+	CREATE INDEX IF NOT EXISTS idx_tombstones_checked_at ON tombstones(checked_at);
+	CREATE INDEX IF NOT EXISTS idx_item_changes_item ON item_changes(item_id, field, changed_at);
+	CREATE INDEX IF NOT EXISTS idx_item_changes_changed_at ON item_changes(changed_at);
 	`
 
-	_, err := s.db.Exec(schema)
-	return err
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+	if err := s.migrateCanonicalURLs(); err != nil {
+		return err
+	}
+	for _, column := range []struct{ table, name, definition string }{
+		{"items", "fields", "TEXT"},
+		{"items", "update_count", "INTEGER DEFAULT 0"},
+		{"batch_status", "items_inserted", "INTEGER DEFAULT 0"},
+		{"batch_status", "items_updated", "INTEGER DEFAULT 0"},
+	} {
+		if err := s.addColumn(column.table, column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	return s.applyChangeTracking()
 }
 
-// InsertItem stores an item in the database
-func (s *Storage) InsertItem(item *Item) error {
-	kidsJSON := ""
-	if len(item.Kids) > 0 {
-		kidsBytes, err := json.Marshal(item.Kids)
-		if err != nil {
-			return fmt.Errorf("failed to marshal kids: %w", err)
+// addColumn adds a column to databases created before it existed
+func (s *Storage) addColumn(table, name, definition string) error {
+	var hasColumn int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, name).Scan(&hasColumn); err != nil {
+		return fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	if hasColumn == 0 {
+		if _, err := s.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, name, definition)); err != nil {
+			return fmt.Errorf("failed to add %s column: %w", name, err)
 		}
-		kidsJSON = string(kidsBytes)
 	}
+	return nil
+}
 
-	query := `
-	INSERT OR REPLACE INTO items 
-	(id, type, by, time, text, dead, deleted, parent, kids, url, score, title, descendants, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-	`
+// migrateCanonicalURLs adds the url_canonical column to databases created
+// before it existed, filling it from url, and creates the views that group
+// stories by canonical URL
+func (s *Storage) migrateCanonicalURLs() error {
+	var hasColumn int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('items') WHERE name = 'url_canonical'").Scan(&hasColumn); err != nil {
+		return fmt.Errorf("failed to inspect items table: %w", err)
+	}
+	if hasColumn == 0 {
+		if _, err := s.db.Exec("ALTER TABLE items ADD COLUMN url_canonical TEXT"); err != nil {
+			return fmt.Errorf("failed to add url_canonical column: %w", err)
+		}
+		if _, err := s.db.Exec("UPDATE items SET url_canonical = url_canonical(url) WHERE url IS NOT NULL AND url != ''"); err != nil {
+			return fmt.Errorf("failed to fill url_canonical column: %w", err)
+		}
+	}
 
-	_, err := s.db.Exec(query,
-		item.ID, item.Type, item.By, item.Time, item.Text,
-		item.Dead, item.Deleted, item.Parent, kidsJSON,
-		item.URL, item.Score, item.Title, item.Descendants,
+	_, err := s.db.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_items_url_canonical ON items(url_canonical);
+
+	-- Stories per canonical URL
+	CREATE VIEW IF NOT EXISTS url_submissions AS
+	SELECT url_canonical, COUNT(*) AS submissions, MIN(id) AS first_id,
+		MIN(time) AS first_time, MAX(time) AS last_time,
+		SUM(score) AS total_score, MAX(score) AS best_score
+	FROM items
+	WHERE type = 'story' AND url_canonical IS NOT NULL AND url_canonical != ''
+	GROUP BY url_canonical;
+
+	-- Stories whose canonical URL was submitted before, with the first submission
+	CREATE VIEW IF NOT EXISTS url_duplicates AS
+	SELECT id, url_canonical, url, title, by, time, score, first_id, submissions
+	FROM (
+		SELECT id, url_canonical, url, title, by, time, score,
+			MIN(id) OVER submission AS first_id, COUNT(*) OVER submission AS submissions
+		FROM items
+		WHERE type = 'story' AND url_canonical IS NOT NULL AND url_canonical != ''
+		WINDOW submission AS (PARTITION BY url_canonical)
 	)
-
+	WHERE id != first_id;
+	`)
 	return err
 }
 
-// InsertItemsBatch stores multiple items in a single transaction
+// InsertItem stores an item in the database
+func (s *Storage) InsertItem(item *Item) error {
+	items, err := transformItems([]*Item{item})
+	if err != nil || len(items) == 0 {
+		return err
+	}
+	args, err := itemArgs(items[0])
+	if err != nil {
+		return err
+	}
+
+	stmt, err := s.insertStatement(1, currentConflictStrategy())
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(args...); err != nil {
+		return err
+	}
+	storage.NotifyTableWrites(sourceName, itemTables...)
+	return nil
+}
+
+// InsertItemsBatch stores multiple items in a single transaction, see
+// UpsertItems
 func (s *Storage) InsertItemsBatch(items []*Item) error {
-	tx, err := s.db.Begin()
+	_, err := s.UpsertItems(items)
+	return err
+}
+
+// UpsertItems stores multiple items in a single transaction using multi-row
+// INSERTs, updating stored items by the conflict strategy, and reports how
+// many were inserted and updated. Items with the same ID in the batch are
+// resolved by the strategy first. The transaction commits with
+// synchronous=NORMAL, as every commit of the storage does, so a crash can
+// lose it; the batch is only marked complete by a later commit.
+func (s *Storage) UpsertItems(items []*Item) (UpsertReport, error) {
+	items, err := transformItems(items)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return UpsertReport{}, err
 	}
-	defer tx.Rollback()
+	return s.upsertItems(items, currentConflictStrategy())
+}
 
-	stmt, err := tx.Prepare(`
-	INSERT OR REPLACE INTO items 
-	(id, type, by, time, text, dead, deleted, parent, kids, url, score, title, descendants, updated_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-	`)
+// upsertItems stores transformed items like UpsertItems with a given
+// conflict strategy
+func (s *Storage) upsertItems(items []*Item, strategy ConflictStrategy) (UpsertReport, error) {
+	var report UpsertReport
+	if len(items) == 0 {
+		return report, nil
+	}
+	items = dedupeItems(items, strategy)
+
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		s.monitor.RecordError(err)
+		return report, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
+
+	args := make([]interface{}, 0, insertRowsPerStatement*insertItemArgs)
+	ids := make([]interface{}, 0, insertRowsPerStatement)
+	for start := 0; start < len(items); start += insertRowsPerStatement {
+		end := start + insertRowsPerStatement
+		if end > len(items) {
+			end = len(items)
+		}
 
-	for _, item := range items {
-		kidsJSON := ""
-		if len(item.Kids) > 0 {
-			kidsBytes, err := json.Marshal(item.Kids)
+		args, ids = args[:0], ids[:0]
+		for _, item := range items[start:end] {
+			row, err := itemArgs(item)
 			if err != nil {
-				return fmt.Errorf("failed to marshal kids for item %d: %w", item.ID, err)
+				return report, err
 			}
-			kidsJSON = string(kidsBytes)
+			args = append(args, row...)
+			ids = append(ids, item.ID)
+		}
+
+		// Rows the strategy left alone are neither inserted nor updated, so
+		// they are the stored ones missing from the affected rows
+		var stored int
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM items WHERE id IN ("+placeholders+")", ids...).Scan(&stored); err != nil {
+			return report, fmt.Errorf("failed to count stored items: %w", err)
+		}
+
+		stmt, err := s.insertStatement(end-start, strategy)
+		if err != nil {
+			return report, err
+		}
+		result, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+		if err != nil {
+			s.monitor.RecordError(err)
+			return report, fmt.Errorf("failed to insert items %d-%d: %w", items[start].ID, items[end-1].ID, err)
+		}
+		written, err := result.RowsAffected()
+		if err != nil {
+			return report, fmt.Errorf("failed to count written items: %w", err)
+		}
+		inserted := len(ids) - stored
+		report.Add(UpsertReport{Inserted: inserted, Updated: int(written) - inserted, Kept: stored - (int(written) - inserted)})
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.monitor.RecordError(err)
+		return UpsertReport{}, err
+	}
+	storage.NotifyTableWrites(sourceName, itemTables...)
+	return report, nil
+}
+
+// insertStatement returns the prepared INSERT for the given number of rows
+// and conflict strategy
+func (s *Storage) insertStatement(rows int, strategy ConflictStrategy) (*sql.Stmt, error) {
+	s.stmtMutex.Lock()
+	defer s.stmtMutex.Unlock()
+
+	key := insertKey{rows: rows, strategy: strategy}
+	if stmt, ok := s.insertStmts[key]; ok {
+		return stmt, nil
+	}
+
+	values := strings.TrimSuffix(strings.Repeat(insertItemRow+", ", rows), ", ")
+	stmt, err := s.db.Prepare("INSERT INTO items " + insertItemColumns + " VALUES " + values + upsertClause(strategy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	s.insertStmts[key] = stmt
+	return stmt, nil
+}
+
+// itemArgs returns the bound values of an item INSERT row
+func itemArgs(item *Item) ([]interface{}, error) {
+	kidsJSON := ""
+	if len(item.Kids) > 0 {
+		kidsBytes, err := json.Marshal(item.Kids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal kids for item %d: %w", item.ID, err)
 		}
+		kidsJSON = string(kidsBytes)
+	}
 
-		_, err = stmt.Exec(
-			item.ID, item.Type, item.By, item.Time, item.Text,
-			item.Dead, item.Deleted, item.Parent, kidsJSON,
-			item.URL, item.Score, item.Title, item.Descendants,
-		)
+	var fieldsJSON interface{}
+	if len(item.Fields) > 0 {
+		fieldsBytes, err := json.Marshal(item.Fields)
 		if err != nil {
-			return fmt.Errorf("failed to insert item %d: %w", item.ID, err)
+			return nil, fmt.Errorf("failed to marshal fields for item %d: %w", item.ID, err)
 		}
+		fieldsJSON = string(fieldsBytes)
 	}
 
-	return tx.Commit()
+	return []interface{}{
+		item.ID, item.Type, item.By, item.Time, item.Text,
+		item.Dead, item.Deleted, item.Parent, kidsJSON,
+		item.URL, canonicalURL(item.URL), item.Score, item.Title, item.Descendants, fieldsJSON,
+	}, nil
+}
+
+// canonicalURL returns the url_canonical value of an item URL, NULL when the
+// item has none
+func canonicalURL(raw string) interface{} {
+	if raw == "" {
+		return nil
+	}
+	return storage.CanonicalURL(raw)
 }
 
 // GetExistingItemIDs returns a map of existing item IDs in the given range
@@ -195,57 +513,56 @@ func (s *Storage) GetExistingItemIDs(startID, endID int64) (map[int64]bool, erro
 	return existing, rows.Err()
 }
 
+// CountStoredItems counts the IDs in the given range that are stored as
+// items or tombstones, which after a completed batch is every ID in it;
+// items that failed to fetch do not count
+func (s *Storage) CountStoredItems(startID, endID int64) (int, error) {
+	query := `
+	SELECT COUNT(*) FROM (
+		SELECT id FROM items WHERE id BETWEEN ? AND ?
+		UNION
+		SELECT item_id FROM tombstones WHERE item_id BETWEEN ? AND ? AND reason != 'failed'
+	)`
+
+	var count int
+	if err := s.db.QueryRow(query, startID, endID, startID, endID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count stored items: %w", err)
+	}
+	return count, nil
+}
+
 // SetBatchStatus updates or creates a batch status record
 func (s *Storage) SetBatchStatus(batch BatchStatus) error {
 	query := `
 	INSERT OR REPLACE INTO batch_status 
-	(batch_start, batch_end, batch_size, completed, items_downloaded, created_at, completed_at)
-	VALUES (?, ?, ?, ?, ?, ?, ?)
+	(batch_start, batch_end, batch_size, completed, items_downloaded, items_inserted, items_updated, created_at, completed_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := s.db.Exec(query,
 		batch.BatchStart, batch.BatchEnd, batch.BatchSize,
-		batch.Completed, batch.ItemsDownloaded, batch.CreatedAt, batch.CompletedAt,
+		batch.Completed, batch.ItemsDownloaded, batch.ItemsInserted, batch.ItemsUpdated, batch.CreatedAt, batch.CompletedAt,
 	)
-
-	return err
+	if err != nil {
+		return err
+	}
+	storage.NotifyTableWrites(sourceName, "batch_status")
+	return nil
 }
 
 // GetBatchStatus retrieves batch status records
 func (s *Storage) GetBatchStatus() ([]BatchStatus, error) {
 	query := `
-	SELECT batch_start, batch_end, batch_size, completed, items_downloaded, created_at, completed_at
+	SELECT batch_start, batch_end, batch_size, completed, items_downloaded, items_inserted, items_updated, created_at, completed_at
 	FROM batch_status
 	ORDER BY batch_start DESC
 	`
 
-	rows, err := s.db.Query(query)
+	batches, err := storage.QueryAs[BatchStatus](s.db, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query batch status: %w", err)
 	}
-	defer rows.Close()
-
-	var batches []BatchStatus
-	for rows.Next() {
-		var batch BatchStatus
-		var completedAt sql.NullTime
-
-		err := rows.Scan(
-			&batch.BatchStart, &batch.BatchEnd, &batch.BatchSize,
-			&batch.Completed, &batch.ItemsDownloaded, &batch.CreatedAt, &completedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan batch status: %w", err)
-		}
-
-		if completedAt.Valid {
-			batch.CompletedAt = &completedAt.Time
-		}
-
-		batches = append(batches, batch)
-	}
-
-	return batches, rows.Err()
+	return batches, nil
 }
 
 // SetMetadata stores a metadata key-value pair
@@ -255,8 +572,11 @@ func (s *Storage) SetMetadata(key, value string) error {
 	VALUES (?, ?, CURRENT_TIMESTAMP)
 	`
 
-	_, err := s.db.Exec(query, key, value)
-	return err
+	if _, err := s.db.Exec(query, key, value); err != nil {
+		return err
+	}
+	storage.NotifyTableWrites(sourceName, "download_metadata")
+	return nil
 }
 
 // GetMetadata retrieves a metadata value by key
@@ -279,17 +599,45 @@ func (s *Storage) Query(query string, args ...interface{}) (*QueryResult, error)
 
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
+		s.monitor.RecordError(err)
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	return scanQueryResult(rows, startTime, datasource.ResultLimits{})
+}
+
+// QueryLimited executes a query, reading rows only until the result would
+// exceed limits; the result is then marked truncated
+func (s *Storage) QueryLimited(query string, limits datasource.ResultLimits) (*QueryResult, error) {
+	startTime := time.Now()
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		s.monitor.RecordError(err)
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
 	defer rows.Close()
 
+	return scanQueryResult(rows, startTime, limits)
+}
+
+// scanQueryResult reads the rows of a query started at startTime, stopping
+// at the first row that would take the result past limits
+func scanQueryResult(rows *sql.Rows, startTime time.Time, limits datasource.ResultLimits) (*QueryResult, error) {
 	columns, err := rows.Columns()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns: %w", err)
 	}
 
 	var results [][]interface{}
+	var size int64
+	truncated := false
 	for rows.Next() {
+		if !limits.Allows(len(results)+1, size) {
+			truncated = true
+			break
+		}
 		values := make([]interface{}, len(columns))
 		valuePtrs := make([]interface{}, len(columns))
 		for i := range values {
@@ -307,6 +655,11 @@ func (s *Storage) Query(query string, args ...interface{}) (*QueryResult, error)
 			}
 		}
 
+		size += datasource.RowSize(values)
+		if !limits.Allows(len(results)+1, size) {
+			truncated = true
+			break
+		}
 		results = append(results, values)
 	}
 
@@ -315,23 +668,79 @@ func (s *Storage) Query(query string, args ...interface{}) (*QueryResult, error)
 	}
 
 	return &QueryResult{
-		Columns:  columns,
-		Rows:     results,
-		Count:    len(results),
-		Duration: time.Since(startTime),
+		Columns:   columns,
+		Rows:      results,
+		Count:     len(results),
+		Duration:  time.Since(startTime),
+		Truncated: truncated,
 	}, nil
 }
 
 // QueryResult represents the result of a database query
 type QueryResult struct {
-	Columns  []string
-	Rows     [][]interface{}
-	Count    int
-	Duration time.Duration
+	Columns   []string
+	Rows      [][]interface{}
+	Count     int
+	Duration  time.Duration
+	Truncated bool // Rows stop at the limits the query was read with
+}
+
+// Optimize refreshes query planner statistics for the database
+func (s *Storage) Optimize() error {
+	if _, err := s.db.Exec("ANALYZE"); err != nil {
+		return fmt.Errorf("failed to analyze database: %w", err)
+	}
+	if _, err := s.db.Exec("PRAGMA optimize"); err != nil {
+		return fmt.Errorf("failed to optimize database: %w", err)
+	}
+	return nil
+}
+
+// Health returns WAL and lock contention health for the database
+func (s *Storage) Health() storage.DBHealth {
+	return s.monitor.Health()
 }
 
-// Close closes the database connection
+// Stats returns the size of the database files and connection usage
+func (s *Storage) Stats() storage.StorageStats {
+	stats := storage.StorageStats{LastUpdate: time.Now()}
+	for _, file := range []string{s.dbPath, s.dbPath + "-wal"} {
+		if info, err := os.Stat(file); err == nil {
+			stats.DatabaseSize += info.Size()
+		}
+	}
+
+	dbStats := s.db.Stats()
+	stats.ConnectionsUsed = dbStats.InUse
+	stats.ConnectionsMax = dbStats.MaxOpenConnections
+	return stats
+}
+
+// Recoverable returns the database for the startup integrity check and repair
+func (s *Storage) Recoverable() *storage.RecoverableDB {
+	return storage.NewRecoverableDB(s.db)
+}
+
+// CheckpointWAL forces a wal_checkpoint(TRUNCATE)
+func (s *Storage) CheckpointWAL() error {
+	return s.monitor.Checkpoint()
+}
+
+// Close checkpoints the WAL and closes the database connection
 func (s *Storage) Close() error {
+	s.stmtMutex.Lock()
+	for _, stmt := range s.insertStmts {
+		stmt.Close()
+	}
+	s.insertStmts = make(map[insertKey]*sql.Stmt)
+	s.stmtMutex.Unlock()
+
+	s.monitor.Stop()
+	if !storage.ReadOnly() {
+		if err := s.monitor.Checkpoint(); err != nil {
+			log.Logger.Warnf("Failed to checkpoint Hacker News database on close: %v", err)
+		}
+	}
 	return s.db.Close()
 }
 
@@ -339,3 +748,26 @@ func (s *Storage) Close() error {
 func (s *Storage) GetStoragePath() string {
 	return s.path
 }
+
+// ExportDataset writes a snapshot of the database to archivePath
+func (s *Storage) ExportDataset(archivePath string) (*dataset.Manifest, error) {
+	if storage.SharedDatabase() != "" {
+		return nil, errSharedLayout
+	}
+	return dataset.Export(s.db, archivePath, "hackernews", SchemaVersion)
+}
+
+// DerivedTables returns the derived tables defined over this database
+func (s *Storage) DerivedTables() *storage.DerivedTables {
+	return s.derived
+}
+
+// Checkpoints returns the checkpoints pipelines keep in this database
+func (s *Storage) Checkpoints() *storage.Checkpoints {
+	return s.checkpoints
+}
+
+// OpenScratch takes a connection for an interactive session's TEMP tables
+func (s *Storage) OpenScratch() (*storage.Scratch, error) {
+	return storage.NewScratch(s.db)
+}
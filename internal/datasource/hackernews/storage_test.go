@@ -1,11 +1,14 @@
 package hackernews
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/brainless/PubDataHub/internal/datasource"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -215,6 +218,37 @@ func TestStorage_Query_Complex(t *testing.T) {
 	assert.Equal(t, float64(150), result.Rows[0][2]) // Average of 100 and 200
 }
 
+func TestStorage_QueryLimited(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	items := []*Item{
+		{ID: 1, Type: "story", Title: "Story 1", Time: 1000},
+		{ID: 2, Type: "story", Title: "Story 2", Time: 2000},
+		{ID: 3, Type: "story", Title: "Story 3", Time: 3000},
+	}
+	require.NoError(t, storage.InsertItemsBatch(items))
+
+	result, err := storage.QueryLimited("SELECT id, title FROM items ORDER BY id", datasource.ResultLimits{MaxRows: 2})
+	require.NoError(t, err)
+	assert.True(t, result.Truncated)
+	assert.Equal(t, 2, result.Count)
+	assert.Equal(t, int64(2), result.Rows[1][0])
+
+	result, err = storage.QueryLimited("SELECT id, title FROM items ORDER BY id", datasource.ResultLimits{MaxRows: 3})
+	require.NoError(t, err)
+	assert.False(t, result.Truncated, "a result of exactly MaxRows rows is whole")
+	assert.Equal(t, 3, result.Count)
+
+	// Each row holds two values and a 7 byte title
+	rowSize := datasource.RowSize([]interface{}{int64(1), "Story 1"})
+	result, err = storage.QueryLimited("SELECT id, title FROM items ORDER BY id", datasource.ResultLimits{MaxBytes: 2*rowSize + 1})
+	require.NoError(t, err)
+	assert.True(t, result.Truncated)
+	assert.Equal(t, 2, result.Count)
+}
+
 func TestStorage_GetStoragePath(t *testing.T) {
 	storage, tempDir := createTestStorage(t)
 	defer os.RemoveAll(tempDir)
@@ -223,6 +257,17 @@ func TestStorage_GetStoragePath(t *testing.T) {
 	assert.Equal(t, tempDir, storage.GetStoragePath())
 }
 
+func TestStorage_Stats(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	stats := storage.Stats()
+	assert.Greater(t, stats.DatabaseSize, int64(0))
+	assert.Equal(t, 0, stats.ConnectionsUsed)
+	assert.False(t, stats.LastUpdate.IsZero())
+}
+
 func TestStorage_DatabaseFile(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "hackernews_test_*")
 	require.NoError(t, err)
@@ -237,3 +282,179 @@ func TestStorage_DatabaseFile(t *testing.T) {
 	_, err = os.Stat(dbPath)
 	assert.NoError(t, err, "Database file should exist")
 }
+
+func TestStorage_InsertItemsBatch_MultiRow(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	// Two full statements and a remainder, with one item repeated
+	items := benchmarkItems(2*insertRowsPerStatement+5, 0)
+	items = append(items, &Item{ID: 1, Type: "story", Title: "Replaced", Kids: []int64{7, 8}})
+	require.NoError(t, storage.InsertItemsBatch(items))
+
+	result, err := storage.Query("SELECT COUNT(*) FROM items")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2*insertRowsPerStatement+5), result.Rows[0][0])
+
+	result, err = storage.Query("SELECT title, kids FROM items WHERE id = 1")
+	require.NoError(t, err)
+	require.Len(t, result.Rows, 1)
+	assert.Equal(t, "Replaced", result.Rows[0][0])
+	assert.Equal(t, "[7,8]", result.Rows[0][1])
+
+	// Commits skip the sync, NORMAL rather than OFF or FULL
+	result, err = storage.Query("PRAGMA synchronous")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Rows[0][0])
+
+	require.NoError(t, storage.InsertItemsBatch(nil))
+}
+
+func TestStorage_CanonicalURLs(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	require.NoError(t, storage.InsertItemsBatch([]*Item{
+		{ID: 1, Type: "story", URL: "https://example.com/post", Score: 10},
+		{ID: 2, Type: "story", URL: "http://www.example.com/post/?utm_source=hn", Score: 30},
+		{ID: 3, Type: "story", URL: "https://other.org/"},
+		{ID: 4, Type: "story", Title: "Ask HN: no URL"},
+	}))
+
+	result, err := storage.Query("SELECT id, url_canonical FROM items ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{int64(1), "https://example.com/post"},
+		{int64(2), "https://example.com/post"},
+		{int64(3), "https://other.org"},
+		{int64(4), nil},
+	}, result.Rows)
+
+	result, err = storage.Query("SELECT url_canonical, submissions, first_id, total_score FROM url_submissions ORDER BY submissions DESC")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{"https://example.com/post", int64(2), int64(1), int64(40)},
+		{"https://other.org", int64(1), int64(3), int64(0)},
+	}, result.Rows)
+
+	result, err = storage.Query("SELECT id, first_id, submissions FROM url_duplicates")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(2), int64(1), int64(2)}}, result.Rows)
+}
+
+func TestStorage_MigrateCanonicalURLs(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+
+	// Recreate the items table as it was before url_canonical existed
+	_, err := storage.db.Exec(`
+	DROP VIEW url_submissions;
+	DROP VIEW url_duplicates;
+	DROP INDEX idx_items_url_canonical;
+	ALTER TABLE items DROP COLUMN url_canonical;
+	INSERT INTO items (id, type, url) VALUES (1, 'story', 'https://example.com/?ref=hn');`)
+	require.NoError(t, err)
+	require.NoError(t, storage.Close())
+
+	storage, err = NewStorage(tempDir)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	result, err := storage.Query("SELECT url_canonical FROM items WHERE id = 1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", result.Rows[0][0])
+
+	result, err = storage.Query("SELECT submissions FROM url_submissions")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.Rows[0][0])
+}
+
+// benchmarkItems returns count stories and comments starting after offset
+func benchmarkItems(count, offset int) []*Item {
+	items := make([]*Item, count)
+	for i := range items {
+		id := int64(offset + i + 1)
+		items[i] = &Item{
+			ID:    id,
+			Type:  "comment",
+			By:    fmt.Sprintf("user%d", id%100),
+			Time:  1700000000 + id,
+			Text:  "A comment long enough to look like a typical Hacker News reply.",
+			Kids:  []int64{id * 10, id*10 + 1},
+			Score: id % 500,
+		}
+		if i%10 == 0 {
+			items[i].Type = "story"
+			items[i].Title = fmt.Sprintf("Story %d", id)
+			items[i].URL = "https://example.com/"
+		}
+	}
+	return items
+}
+
+// insertItemsRowByRow is the previous ingestion path, one prepared INSERT
+// per item, kept as the benchmark baseline
+func insertItemsRowByRow(s *Storage, items []*Item) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT OR REPLACE INTO items " + insertItemColumns + " VALUES " + insertItemRow)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, item := range items {
+		kids, err := json.Marshal(item.Kids)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(item.ID, item.Type, item.By, item.Time, item.Text,
+			item.Dead, item.Deleted, item.Parent, string(kids),
+			item.URL, item.Score, item.Title, item.Descendants); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// BenchmarkStorage_InsertItemsBatch compares items/sec of the row-by-row
+// baseline with multi-row batches, run with go test -bench InsertItemsBatch
+func BenchmarkStorage_InsertItemsBatch(b *testing.B) {
+	const batchSize = 1000
+
+	inserters := []struct {
+		name   string
+		insert func(s *Storage, items []*Item) error
+	}{
+		{"RowByRow", insertItemsRowByRow},
+		{"MultiRow", (*Storage).InsertItemsBatch},
+	}
+
+	for _, inserter := range inserters {
+		b.Run(inserter.name, func(b *testing.B) {
+			tempDir := b.TempDir()
+			storage, err := NewStorage(tempDir)
+			require.NoError(b, err)
+			defer storage.Close()
+
+			batches := make([][]*Item, b.N)
+			for i := range batches {
+				batches[i] = benchmarkItems(batchSize, i*batchSize)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := inserter.insert(storage, batches[i]); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "items/sec")
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package hackernews
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+// Thread reconstructs the discussion below an item from the stored parent
+// and kids relationships. Replies follow the order of the parent's kids,
+// which is the order Hacker News ranks them in; replies missing from kids
+// come last by time.
+func (s *Storage) Thread(rootID int64) (*datasource.ThreadItem, error) {
+	rows, err := s.db.Query(`
+	WITH RECURSIVE thread(id) AS (
+		SELECT id FROM items WHERE id = ?
+		UNION
+		SELECT items.id FROM items JOIN thread ON items.parent = thread.id
+	)
+	SELECT i.id, i.type, i.by, i.time, i.text, i.dead, i.deleted, i.parent, i.kids,
+		i.url, i.score, i.title
+	FROM items i JOIN thread ON i.id = thread.id`, rootID)
+	if err != nil {
+		s.monitor.RecordError(err)
+		return nil, fmt.Errorf("failed to load thread %d: %w", rootID, err)
+	}
+	defer rows.Close()
+
+	nodes := make(map[int64]*datasource.ThreadItem)
+	parents := make(map[int64]int64)
+	kids := make(map[int64][]int64)
+	for rows.Next() {
+		var (
+			node                           datasource.ThreadItem
+			by, text, kidsJSON, url, title sql.NullString
+			itemTime, parent, score        sql.NullInt64
+		)
+		if err := rows.Scan(&node.ID, &node.Type, &by, &itemTime, &text, &node.Dead, &node.Deleted,
+			&parent, &kidsJSON, &url, &score, &title); err != nil {
+			return nil, fmt.Errorf("failed to scan thread item: %w", err)
+		}
+		node.By, node.Text, node.URL, node.Title = by.String, text.String, url.String, title.String
+		node.Time, node.Score = itemTime.Int64, score.Int64
+
+		if kidsJSON.String != "" {
+			var ids []int64
+			if err := json.Unmarshal([]byte(kidsJSON.String), &ids); err != nil {
+				return nil, fmt.Errorf("failed to decode kids of item %d: %w", node.ID, err)
+			}
+			kids[node.ID] = ids
+		}
+		nodes[node.ID] = &node
+		parents[node.ID] = parent.Int64
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating thread items: %w", err)
+	}
+
+	root, ok := nodes[rootID]
+	if !ok {
+		return nil, fmt.Errorf("item %d not found", rootID)
+	}
+
+	for id, node := range nodes {
+		if id == rootID {
+			continue
+		}
+		if parent, ok := nodes[parents[id]]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+	for id, node := range nodes {
+		orderReplies(node, kids[id])
+	}
+	return root, nil
+}
+
+// orderReplies sorts the replies of a node by their position in kids and
+// counts the kids that were not downloaded
+func orderReplies(node *datasource.ThreadItem, kids []int64) {
+	position := make(map[int64]int, len(kids))
+	for i, id := range kids {
+		position[id] = i
+	}
+
+	sort.SliceStable(node.Replies, func(i, j int) bool {
+		pi, iKnown := position[node.Replies[i].ID]
+		pj, jKnown := position[node.Replies[j].ID]
+		switch {
+		case iKnown && jKnown:
+			return pi < pj
+		case iKnown != jKnown:
+			return iKnown
+		}
+		return node.Replies[i].Time < node.Replies[j].Time
+	})
+
+	present := 0
+	for _, reply := range node.Replies {
+		if _, ok := position[reply.ID]; ok {
+			present++
+		}
+	}
+	node.Missing = len(kids) - present
+}
+
+// Thread returns the discussion below a story or comment with its replies
+// nested in display order
+func (h *HackerNewsDataSource) Thread(rootID int64) (*datasource.ThreadItem, error) {
+	if h.storage == nil {
+		return nil, fmt.Errorf("storage not initialized")
+	}
+	return h.storage.Thread(rootID)
+}
@@ -0,0 +1,41 @@
+package hackernews
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Thread(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	require.NoError(t, storage.InsertItemsBatch([]*Item{
+		{ID: 1, Type: "story", By: "alice", Title: "Story", Kids: []int64{3, 2, 9}},
+		{ID: 2, Type: "comment", By: "bob", Parent: 1, Time: 10},
+		{ID: 3, Type: "comment", By: "carol", Parent: 1, Time: 20, Kids: []int64{4}},
+		{ID: 4, Type: "comment", By: "alice", Parent: 3, Time: 30},
+		{ID: 5, Type: "comment", By: "dave", Parent: 1, Time: 5},
+		{ID: 6, Type: "comment", By: "erin", Parent: 7},
+	}))
+
+	root, err := storage.Thread(1)
+	require.NoError(t, err)
+	assert.Equal(t, "Story", root.Title)
+	assert.Equal(t, 4, root.CountReplies())
+	assert.Equal(t, 1, root.Missing, "kid 9 was not downloaded")
+
+	// Ranked kids first, then replies the story does not list by time
+	require.Len(t, root.Replies, 3)
+	assert.Equal(t, int64(3), root.Replies[0].ID)
+	assert.Equal(t, int64(2), root.Replies[1].ID)
+	assert.Equal(t, int64(5), root.Replies[2].ID)
+	require.Len(t, root.Replies[0].Replies, 1)
+	assert.Equal(t, "alice", root.Replies[0].Replies[0].By)
+
+	_, err = storage.Thread(100)
+	assert.Error(t, err)
+}
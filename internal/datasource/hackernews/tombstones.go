@@ -0,0 +1,242 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// Tombstone reasons recorded for items that are not live
+const (
+	TombstoneDead    = "dead"    // Flagged or killed; the API still returns it
+	TombstoneDeleted = "deleted" // Deleted by its author or a moderator
+	TombstoneMissing = "missing" // The API returned null
+	TombstoneFailed  = "failed"  // Could not be fetched; retried on every repair
+)
+
+// DefaultRecheckInterval is how long a dead, deleted or missing item keeps
+// its tombstone before RepairItems fetches it again
+const DefaultRecheckInterval = 7 * 24 * time.Hour
+
+// recheckInterval is the re-check interval of RepairItems, in nanoseconds
+var recheckInterval atomic.Int64
+
+func init() {
+	recheckInterval.Store(int64(DefaultRecheckInterval))
+}
+
+// SetRecheckInterval sets how long tombstoned items wait before they are
+// fetched again; values below one second restore the default
+func SetRecheckInterval(interval time.Duration) {
+	if interval < time.Second {
+		interval = DefaultRecheckInterval
+	}
+	recheckInterval.Store(int64(interval))
+}
+
+// tombstoneReason returns the tombstone reason of a fetched item, or "" for
+// a live item
+func tombstoneReason(item *Item) string {
+	switch {
+	case item == nil:
+		return TombstoneMissing
+	case item.Deleted:
+		return TombstoneDeleted
+	case item.Dead:
+		return TombstoneDead
+	}
+	return ""
+}
+
+// RecordItemStates updates the tombstones of the item IDs in checked from
+// the items the API returned for them: dead, deleted and absent items get a
+// tombstone, live items lose theirs
+func (s *Storage) RecordItemStates(checked []int64, items []*Item) error {
+	if len(checked) == 0 {
+		return nil
+	}
+
+	fetched := make(map[int64]*Item, len(items))
+	for _, item := range items {
+		fetched[item.ID] = item
+	}
+	low, high := checked[0], checked[0]
+	for _, id := range checked {
+		if id < low {
+			low = id
+		}
+		if id > high {
+			high = id
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.monitor.RecordError(err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Usually few items of a range have tombstones; only those may need deleting
+	ids, err := storage.QueryAs[int64](tx, "SELECT item_id FROM tombstones WHERE item_id BETWEEN ? AND ?", low, high)
+	if err != nil {
+		return fmt.Errorf("failed to query tombstones: %w", err)
+	}
+	existing := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		existing[id] = true
+	}
+
+	for _, id := range checked {
+		reason := tombstoneReason(fetched[id])
+		switch {
+		case reason != "":
+			if _, err := tx.Exec(`
+			INSERT INTO tombstones (item_id, reason) VALUES (?, ?)
+			ON CONFLICT(item_id) DO UPDATE SET reason = excluded.reason, error = NULL,
+				checked_at = CURRENT_TIMESTAMP, checks = checks + 1`, id, reason); err != nil {
+				return fmt.Errorf("failed to record tombstone of item %d: %w", id, err)
+			}
+		case existing[id]:
+			if _, err := tx.Exec("DELETE FROM tombstones WHERE item_id = ?", id); err != nil {
+				return fmt.Errorf("failed to clear tombstone of item %d: %w", id, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.monitor.RecordError(err)
+		return err
+	}
+	storage.NotifyTableWrites(sourceName, "tombstones")
+	return nil
+}
+
+// RecordFetchFailure records that an item could not be fetched. An item
+// without a tombstone gets a failed one; an existing tombstone keeps its
+// reason and notes the error.
+func (s *Storage) RecordFetchFailure(id int64, fetchErr error) error {
+	if _, err := s.db.Exec(`
+	INSERT INTO tombstones (item_id, reason, error) VALUES (?, ?, ?)
+	ON CONFLICT(item_id) DO UPDATE SET error = excluded.error,
+		checked_at = CURRENT_TIMESTAMP, checks = checks + 1`, id, TombstoneFailed, fetchErr.Error()); err != nil {
+		s.monitor.RecordError(err)
+		return fmt.Errorf("failed to record failure of item %d: %w", id, err)
+	}
+	storage.NotifyTableWrites(sourceName, "tombstones")
+	return nil
+}
+
+// TombstonesDue returns the items to fetch again: failed items and items
+// whose tombstone was last checked longer than recheck ago
+func (s *Storage) TombstonesDue(recheck time.Duration) ([]int64, error) {
+	ids, err := storage.QueryAs[int64](s.db, `
+	SELECT item_id FROM tombstones
+	WHERE reason = ? OR checked_at < datetime('now', ?)
+	ORDER BY item_id`, TombstoneFailed, fmt.Sprintf("-%d seconds", int64(recheck.Seconds())))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tombstones: %w", err)
+	}
+	return ids, nil
+}
+
+// recordStoredTombstones adds tombstones for stored dead and deleted items
+// that have none, such as items downloaded before tombstones were recorded
+func (s *Storage) recordStoredTombstones() error {
+	result, err := s.db.Exec(`
+	INSERT OR IGNORE INTO tombstones (item_id, reason, first_seen, checked_at)
+	SELECT id, CASE WHEN deleted THEN ? ELSE ? END, updated_at, updated_at
+	FROM items WHERE deleted OR dead`, TombstoneDeleted, TombstoneDead)
+	if err != nil {
+		s.monitor.RecordError(err)
+		return fmt.Errorf("failed to record tombstones of stored items: %w", err)
+	}
+	if added, _ := result.RowsAffected(); added > 0 {
+		storage.NotifyTableWrites(sourceName, "tombstones")
+	}
+	return nil
+}
+
+// repairItems fetches the items whose tombstones are due for a re-check,
+// stores the items the API returns and updates their tombstones. Progress is
+// stored in batches so an interrupted repair keeps it.
+func repairItems(ctx context.Context, client *Client, store *Storage, recheck time.Duration, progress func(done, total int64)) error {
+	if err := store.recordStoredTombstones(); err != nil {
+		return err
+	}
+	ids, err := store.TombstonesDue(recheck)
+	if err != nil {
+		return err
+	}
+	downloadLog().Infof("Re-checking %d dead, deleted, missing and failed items", len(ids))
+
+	const storeBatch = 100
+	checked := make([]int64, 0, storeBatch)
+	items := make([]*Item, 0, storeBatch)
+	flush := func() error {
+		if err := store.InsertItemsBatch(items); err != nil {
+			return fmt.Errorf("failed to store repaired items: %w", err)
+		}
+		if err := store.RecordItemStates(checked, items); err != nil {
+			return err
+		}
+		checked, items = checked[:0], items[:0]
+		return nil
+	}
+
+	restored := 0
+	total := int64(len(ids))
+	for i, id := range ids {
+		if err := ctx.Err(); err != nil {
+			if flushErr := flush(); flushErr != nil {
+				return flushErr
+			}
+			return err
+		}
+
+		item, err := client.GetItem(ctx, id)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			downloadLog().Warnf("Failed to re-check item %d: %v", id, err)
+			if err := store.RecordFetchFailure(id, err); err != nil {
+				return err
+			}
+		case err == nil:
+			checked = append(checked, id)
+			if item != nil {
+				items = append(items, item)
+			}
+			if tombstoneReason(item) == "" {
+				restored++
+			}
+		}
+
+		if len(checked) == storeBatch {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if progress != nil {
+			progress(int64(i+1), total)
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+	downloadLog().Infof("Re-checked %d items, %d are live again", len(ids), restored)
+	return nil
+}
+
+// RepairItems fetches failed items and items that were dead, deleted or
+// missing longer than the re-check interval ago, storing their current state
+// and updating their tombstones. progress is called after each item.
+func (h *HackerNewsDataSource) RepairItems(ctx context.Context, progress func(done, total int64)) error {
+	if h.storage == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	return repairItems(ctx, h.client, h.storage, time.Duration(recheckInterval.Load()), progress)
+}
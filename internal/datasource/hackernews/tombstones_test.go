@@ -0,0 +1,111 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_RecordItemStates(t *testing.T) {
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	require.NoError(t, storage.RecordItemStates([]int64{1, 2, 3, 4}, []*Item{
+		{ID: 1, Type: "story"},
+		{ID: 2, Type: "comment", Dead: true},
+		{ID: 3, Type: "comment", Deleted: true, Dead: true},
+	}))
+	require.NoError(t, storage.RecordFetchFailure(5, fmt.Errorf("timeout")))
+
+	result, err := storage.Query("SELECT item_id, reason FROM tombstones ORDER BY item_id")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{int64(2), TombstoneDead},
+		{int64(3), TombstoneDeleted},
+		{int64(4), TombstoneMissing},
+		{int64(5), TombstoneFailed},
+	}, result.Rows)
+
+	// A failed re-check keeps the reason; a live item loses its tombstone
+	require.NoError(t, storage.RecordFetchFailure(2, fmt.Errorf("timeout")))
+	require.NoError(t, storage.RecordItemStates([]int64{3, 5}, []*Item{{ID: 3, Type: "comment"}, {ID: 5, Type: "comment"}}))
+
+	result, err = storage.Query("SELECT item_id, reason, error, checks FROM tombstones ORDER BY item_id")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{int64(2), TombstoneDead, "timeout", int64(2)},
+		{int64(4), TombstoneMissing, nil, int64(1)},
+	}, result.Rows)
+}
+
+func TestRepairItems(t *testing.T) {
+	log.InitLogger(false)
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/item/"), ".json")
+		switch id {
+		case "3":
+			w.Write([]byte("null"))
+		case "4":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			fmt.Fprintf(w, `{"id": %s, "type": "comment", "by": "alice", "text": "vouched"}`, id)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.httpClient = server.Client()
+	client.baseURL = server.URL
+
+	// Item 1 was stored dead before tombstones were recorded
+	require.NoError(t, storage.InsertItemsBatch([]*Item{{ID: 1, Type: "comment", Dead: true}}))
+	require.NoError(t, storage.RecordItemStates([]int64{2, 3}, []*Item{{ID: 2, Type: "comment", Deleted: true}}))
+	require.NoError(t, storage.RecordFetchFailure(4, fmt.Errorf("timeout")))
+	_, err := storage.db.Exec("UPDATE tombstones SET checked_at = datetime('now', '-2 hours') WHERE item_id = 2")
+	require.NoError(t, err)
+	_, err = storage.db.Exec("UPDATE items SET updated_at = datetime('now', '-2 hours')")
+	require.NoError(t, err)
+
+	var done, total int64
+	require.NoError(t, repairItems(context.Background(), client, storage, time.Hour, func(d, t int64) {
+		done, total = d, t
+	}))
+	assert.Equal(t, int64(3), done, "items 1, 2 and 4 are due, item 3 is not")
+	assert.Equal(t, int64(3), total)
+
+	result, err := storage.Query("SELECT item_id, reason FROM tombstones ORDER BY item_id")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{int64(3), TombstoneMissing},
+		{int64(4), TombstoneFailed},
+	}, result.Rows)
+
+	result, err = storage.Query("SELECT id, dead, text FROM items ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{
+		{int64(1), false, "vouched"},
+		{int64(2), false, "vouched"},
+	}, result.Rows)
+
+	// Only the failed item is due again
+	requests.Store(0)
+	require.NoError(t, repairItems(context.Background(), client, storage, time.Hour, nil))
+	assert.Equal(t, int32(1), requests.Load())
+}
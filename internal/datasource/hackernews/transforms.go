@@ -0,0 +1,132 @@
+package hackernews
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/brainless/PubDataHub/internal/transform"
+)
+
+// transforms are the hooks run on items before they are stored
+var transforms atomic.Pointer[transform.Chain]
+
+// SetTransforms sets the Starlark hooks run on every item before it is
+// stored, whether downloaded, repaired or imported from a snapshot; nil
+// runs none
+func SetTransforms(chain *transform.Chain) {
+	transforms.Store(chain)
+}
+
+// itemFields returns the fields of an item as transform hooks see them
+func itemFields(item *Item) map[string]interface{} {
+	kids := item.Kids
+	if kids == nil {
+		kids = []int64{}
+	}
+	fields := map[string]interface{}{
+		"id": item.ID, "type": item.Type, "by": item.By, "time": item.Time, "text": item.Text,
+		"dead": item.Dead, "deleted": item.Deleted, "parent": item.Parent, "kids": kids,
+		"url": item.URL, "score": item.Score, "title": item.Title, "descendants": item.Descendants,
+	}
+	for name, value := range item.Fields {
+		fields[name] = value
+	}
+	return fields
+}
+
+// transformItems runs the transform hooks on items and returns the items
+// to store, without those a hook skipped. A failing hook fails the whole
+// batch, so no item is stored without its transformation.
+func transformItems(items []*Item) ([]*Item, error) {
+	chain := transforms.Load()
+	if chain.Empty() {
+		return items, nil
+	}
+
+	transformed := make([]*Item, 0, len(items))
+	for _, item := range items {
+		fields, err := chain.Apply(itemFields(item))
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", item.ID, err)
+		}
+		if fields == nil {
+			continue
+		}
+		result, err := itemFromFields(item, fields)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", item.ID, err)
+		}
+		transformed = append(transformed, result)
+	}
+	return transformed, nil
+}
+
+// itemFromFields returns a copy of item with the fields a hook returned.
+// Fields that are not item columns are kept in Fields; a field set to None
+// is cleared.
+func itemFromFields(item *Item, fields map[string]interface{}) (*Item, error) {
+	result := &Item{ID: item.ID, unchanged: item.unchanged}
+	if id, ok := fields["id"]; ok && id != item.ID {
+		return nil, fmt.Errorf("transform hooks cannot change the item id")
+	}
+
+	var err error
+	text := func(name string, target *string) {
+		if value, ok := fields[name]; ok && value != nil && err == nil {
+			if *target, ok = value.(string); !ok {
+				err = fmt.Errorf("transform hook set %s to %T, want a string", name, value)
+			}
+		}
+	}
+	number := func(name string, target *int64) {
+		if value, ok := fields[name]; ok && value != nil && err == nil {
+			if *target, ok = value.(int64); !ok {
+				err = fmt.Errorf("transform hook set %s to %T, want an integer", name, value)
+			}
+		}
+	}
+	flag := func(name string, target *bool) {
+		if value, ok := fields[name]; ok && value != nil && err == nil {
+			if *target, ok = value.(bool); !ok {
+				err = fmt.Errorf("transform hook set %s to %T, want a bool", name, value)
+			}
+		}
+	}
+	text("type", &result.Type)
+	text("by", &result.By)
+	number("time", &result.Time)
+	text("text", &result.Text)
+	flag("dead", &result.Dead)
+	flag("deleted", &result.Deleted)
+	number("parent", &result.Parent)
+	text("url", &result.URL)
+	number("score", &result.Score)
+	text("title", &result.Title)
+	number("descendants", &result.Descendants)
+	if err != nil {
+		return nil, err
+	}
+	if kids, ok := fields["kids"].([]interface{}); ok {
+		for _, kid := range kids {
+			id, ok := kid.(int64)
+			if !ok {
+				return nil, fmt.Errorf("transform hook set kids to a list with %T, want integers", kid)
+			}
+			result.Kids = append(result.Kids, id)
+		}
+	}
+	if result.Type == "" {
+		return nil, fmt.Errorf("transform hooks cannot clear the item type")
+	}
+
+	columns := itemFields(&Item{})
+	for name, value := range fields {
+		if _, column := columns[name]; !column && value != nil {
+			if result.Fields == nil {
+				result.Fields = make(map[string]interface{})
+			}
+			result.Fields[name] = value
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package hackernews
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/transform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Transforms(t *testing.T) {
+	log.InitLogger(false)
+	script := filepath.Join(t.TempDir(), "redact.star")
+	require.NoError(t, os.WriteFile(script, []byte(`
+def transform(item):
+    if item["type"] == "job":
+        return None
+    item["text"] = ""
+    item["title_words"] = len(item["title"].split())
+    return item
+`), 0644))
+	chain, err := transform.LoadChain([]string{script}, 0)
+	require.NoError(t, err)
+	SetTransforms(chain)
+	defer SetTransforms(nil)
+
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	require.NoError(t, storage.InsertItemsBatch([]*Item{
+		{ID: 1, Type: "story", By: "pg", Title: "Show HN: Transform hooks", Text: "secret", Kids: []int64{3}},
+		{ID: 2, Type: "job", Title: "Hiring"},
+	}))
+
+	result, err := storage.Query("SELECT id, text, kids, json_extract(fields, '$.title_words') FROM items")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(1), "", "[3]", int64(4)}}, result.Rows, "the job is skipped")
+
+	// A hook that fails to load fails every item instead of storing it as is
+	broken, err := transform.LoadChain([]string{filepath.Join(tempDir, "missing.star")}, 0)
+	require.Error(t, err)
+	SetTransforms(broken)
+	assert.Error(t, storage.InsertItem(&Item{ID: 3, Type: "comment", Parent: 1}))
+}
+
+func TestItemFromFields_Validation(t *testing.T) {
+	item := &Item{ID: 1, Type: "story"}
+
+	_, err := itemFromFields(item, map[string]interface{}{"id": int64(2), "type": "story"})
+	assert.Error(t, err, "the id cannot change")
+
+	_, err = itemFromFields(item, map[string]interface{}{"type": "story", "score": "high"})
+	assert.Error(t, err)
+
+	result, err := itemFromFields(item, map[string]interface{}{"type": "story", "title": "A", "topic": nil})
+	require.NoError(t, err)
+	assert.Equal(t, "A", result.Title)
+	assert.Nil(t, result.Fields, "None fields are not stored")
+}
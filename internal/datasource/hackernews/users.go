@@ -0,0 +1,137 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// DefaultUserRefreshAge is how old a stored user profile must be before
+// RefreshUsers fetches it again
+const DefaultUserRefreshAge = 24 * time.Hour
+
+// InsertUsers stores user profiles and records their current karma in the
+// karma history
+func (s *Storage) InsertUsers(users []*User) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.monitor.RecordError(err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, user := range users {
+		if _, err := tx.Exec(`
+		INSERT OR REPLACE INTO users (id, created, karma, about, submitted, fetched_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+			user.ID, user.Created, user.Karma, user.About, len(user.Submitted)); err != nil {
+			return fmt.Errorf("failed to store user %s: %w", user.ID, err)
+		}
+		if _, err := tx.Exec(`
+		INSERT OR REPLACE INTO user_karma (user_id, karma, recorded_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)`, user.ID, user.Karma); err != nil {
+			return fmt.Errorf("failed to record karma of user %s: %w", user.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		s.monitor.RecordError(err)
+		return err
+	}
+	storage.NotifyTableWrites(sourceName, "users", "user_karma")
+	return nil
+}
+
+// MissingAuthors returns the authors of stored items without a stored profile
+func (s *Storage) MissingAuthors() ([]string, error) {
+	return s.queryUserIDs(`
+	SELECT DISTINCT by FROM items
+	WHERE by IS NOT NULL AND by != '' AND by NOT IN (SELECT id FROM users)
+	ORDER BY by`)
+}
+
+// StaleUsers returns the users whose profile was fetched longer than maxAge
+// ago, least recently fetched first
+func (s *Storage) StaleUsers(maxAge time.Duration) ([]string, error) {
+	return s.queryUserIDs(`
+	SELECT id FROM users
+	WHERE fetched_at < datetime('now', ?)
+	ORDER BY fetched_at`, fmt.Sprintf("-%d seconds", int64(maxAge.Seconds())))
+}
+
+// queryUserIDs runs a query returning one user ID per row
+func (s *Storage) queryUserIDs(query string, args ...interface{}) ([]string, error) {
+	ids, err := storage.QueryAs[string](s.db, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	return ids, nil
+}
+
+// refreshUsers fetches the profiles of authors without one and of users
+// fetched longer than maxAge ago, storing them in batches so an interrupted
+// refresh keeps its progress
+func refreshUsers(ctx context.Context, client *Client, store *Storage, maxAge time.Duration, progress func(done, total int64)) error {
+	missing, err := store.MissingAuthors()
+	if err != nil {
+		return err
+	}
+	stale, err := store.StaleUsers(maxAge)
+	if err != nil {
+		return err
+	}
+	ids := append(missing, stale...)
+
+	downloadLog().Infof("Refreshing %d user profiles (%d new, %d stale)", len(ids), len(missing), len(stale))
+
+	const storeBatch = 100
+	users := make([]*User, 0, storeBatch)
+	total := int64(len(ids))
+	for i, id := range ids {
+		if err := ctx.Err(); err != nil {
+			if storeErr := store.InsertUsers(users); storeErr != nil {
+				return storeErr
+			}
+			return err
+		}
+
+		user, err := client.GetUser(ctx, id)
+		if err != nil && ctx.Err() == nil {
+			downloadLog().Warnf("Failed to fetch user %s: %v", id, err)
+		} else if user != nil {
+			users = append(users, user)
+		}
+
+		if len(users) == storeBatch {
+			if err := store.InsertUsers(users); err != nil {
+				return err
+			}
+			users = users[:0]
+		}
+		if progress != nil {
+			progress(int64(i+1), total)
+		}
+	}
+
+	if err := store.InsertUsers(users); err != nil {
+		return err
+	}
+	downloadLog().Infof("Refreshed %d user profiles", len(ids))
+	return nil
+}
+
+// RefreshUsers fetches the profiles of item authors not stored yet and
+// refreshes profiles older than DefaultUserRefreshAge, recording a karma
+// snapshot for each fetched profile. progress is called after each profile.
+func (h *HackerNewsDataSource) RefreshUsers(ctx context.Context, progress func(done, total int64)) error {
+	if h.storage == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	return refreshUsers(ctx, h.client, h.storage, DefaultUserRefreshAge, progress)
+}
@@ -0,0 +1,115 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetUser(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/user/missing.json" {
+			w.Write([]byte("null"))
+			return
+		}
+		assert.Equal(t, "/user/pg.json", r.URL.Path)
+		w.Write([]byte(`{"id": "pg", "created": 1160418092, "karma": 157236, "about": "Bug fixer.", "submitted": [1, 2, 3]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.httpClient = server.Client()
+	client.baseURL = server.URL
+
+	user, err := client.GetUser(context.Background(), "pg")
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.Equal(t, "pg", user.ID)
+	assert.Equal(t, int64(1160418092), user.Created)
+	assert.Equal(t, int64(157236), user.Karma)
+	assert.Equal(t, "Bug fixer.", user.About)
+	assert.Len(t, user.Submitted, 3)
+
+	user, err = client.GetUser(context.Background(), "missing")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+}
+
+func TestRefreshUsers(t *testing.T) {
+	log.InitLogger(false)
+	storage, tempDir := createTestStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer storage.Close()
+
+	var karma atomic.Int64
+	karma.Store(100)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/user/"), ".json")
+		if id == "gone" {
+			w.Write([]byte("null"))
+			return
+		}
+		fmt.Fprintf(w, `{"id": %q, "created": 1, "karma": %d}`, id, karma.Load())
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.httpClient = server.Client()
+	client.baseURL = server.URL
+
+	require.NoError(t, storage.InsertItemsBatch([]*Item{
+		{ID: 1, Type: "story", By: "alice"},
+		{ID: 2, Type: "comment", By: "bob"},
+		{ID: 3, Type: "comment", By: "alice"},
+		{ID: 4, Type: "comment", By: "gone"},
+		{ID: 5, Type: "comment"},
+	}))
+
+	missing, err := storage.MissingAuthors()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob", "gone"}, missing)
+
+	var done, total int64
+	require.NoError(t, refreshUsers(context.Background(), client, storage, time.Hour, func(d, t int64) {
+		done, total = d, t
+	}))
+	assert.Equal(t, int64(3), done)
+	assert.Equal(t, int64(3), total)
+
+	result, err := storage.Query("SELECT id, karma FROM users ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{"alice", int64(100)}, {"bob", int64(100)}}, result.Rows)
+
+	// Fresh profiles are not fetched again; outdated ones get a new snapshot
+	requests.Store(0)
+	require.NoError(t, refreshUsers(context.Background(), client, storage, time.Hour, nil))
+	assert.Equal(t, int32(1), requests.Load(), "only the missing user is retried")
+
+	_, err = storage.db.Exec("UPDATE users SET fetched_at = datetime('now', '-2 hours')")
+	require.NoError(t, err)
+	_, err = storage.db.Exec("UPDATE user_karma SET recorded_at = datetime('now', '-2 hours')")
+	require.NoError(t, err)
+	karma.Store(150)
+	require.NoError(t, refreshUsers(context.Background(), client, storage, time.Hour, nil))
+
+	result, err = storage.Query("SELECT karma FROM user_karma WHERE user_id = 'alice' ORDER BY recorded_at")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(100)}, {int64(150)}}, result.Rows)
+
+	stale, err := storage.StaleUsers(time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+}
@@ -0,0 +1,49 @@
+package datasource
+
+import "fmt"
+
+// ResultLimits caps how much of a query result is read into memory; zero
+// fields are unlimited
+type ResultLimits struct {
+	MaxRows  int
+	MaxBytes int64
+}
+
+// Allows reports whether a result of rows rows holding bytes bytes fits
+// within the limits
+func (l ResultLimits) Allows(rows int, bytes int64) bool {
+	return (l.MaxRows <= 0 || rows <= l.MaxRows) && (l.MaxBytes <= 0 || bytes <= l.MaxBytes)
+}
+
+// String describes the limits, e.g. "100000 rows or 256 MB"
+func (l ResultLimits) String() string {
+	rows := fmt.Sprintf("%d rows", l.MaxRows)
+	size := fmt.Sprintf("%d MB", l.MaxBytes>>20)
+	switch {
+	case l.MaxRows > 0 && l.MaxBytes > 0:
+		return rows + " or " + size
+	case l.MaxRows > 0:
+		return rows
+	case l.MaxBytes > 0:
+		return size
+	}
+	return "unlimited"
+}
+
+// valueOverhead approximates the memory a scanned value takes besides its
+// text
+const valueOverhead = 16
+
+// RowSize estimates the memory a scanned row holds in bytes
+func RowSize(row []interface{}) int64 {
+	size := int64(len(row)) * valueOverhead
+	for _, value := range row {
+		switch v := value.(type) {
+		case string:
+			size += int64(len(v))
+		case []byte:
+			size += int64(len(v))
+		}
+	}
+	return size
+}
@@ -0,0 +1,27 @@
+package datasource_test
+
+import (
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultLimits(t *testing.T) {
+	assert.True(t, datasource.ResultLimits{}.Allows(1000000, 1<<40))
+
+	limits := datasource.ResultLimits{MaxRows: 10, MaxBytes: 256 << 20}
+	assert.True(t, limits.Allows(10, 256<<20))
+	assert.False(t, limits.Allows(11, 0))
+	assert.False(t, limits.Allows(1, 256<<20+1))
+
+	assert.Equal(t, "10 rows or 256 MB", limits.String())
+	assert.Equal(t, "10 rows", datasource.ResultLimits{MaxRows: 10}.String())
+	assert.Equal(t, "unlimited", datasource.ResultLimits{}.String())
+}
+
+func TestRowSize(t *testing.T) {
+	assert.Equal(t, int64(0), datasource.RowSize(nil))
+	// Every value counts, text and byte values also by their length
+	assert.Equal(t, int64(3*16+5+2), datasource.RowSize([]interface{}{int64(1), "hello", []byte("hi")}))
+}
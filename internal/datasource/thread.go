@@ -0,0 +1,27 @@
+package datasource
+
+// ThreadItem is an item of a discussion thread, such as a story or a
+// comment, with its replies in display order
+type ThreadItem struct {
+	ID      int64         `json:"id"`
+	Type    string        `json:"type"`
+	By      string        `json:"by,omitempty"`
+	Time    int64         `json:"time"` // Unix seconds
+	Title   string        `json:"title,omitempty"`
+	URL     string        `json:"url,omitempty"`
+	Text    string        `json:"text,omitempty"` // HTML as published by the source
+	Score   int64         `json:"score,omitempty"`
+	Dead    bool          `json:"dead,omitempty"`
+	Deleted bool          `json:"deleted,omitempty"`
+	Missing int           `json:"missing,omitempty"` // Replies known to exist but not downloaded
+	Replies []*ThreadItem `json:"replies,omitempty"`
+}
+
+// CountReplies returns the number of replies below the item at any depth
+func (t *ThreadItem) CountReplies() int {
+	count := 0
+	for _, reply := range t.Replies {
+		count += 1 + reply.CountReplies()
+	}
+	return count
+}
@@ -0,0 +1,59 @@
+// Package editor opens text in the user's external editor, such as vim or
+// nano, and returns the saved text
+package editor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Command returns the editor command line: $VISUAL, then $EDITOR, then vi
+// (notepad on Windows). Editors may take arguments, e.g. "code --wait".
+func Command() []string {
+	for _, variable := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(variable)); len(fields) > 0 {
+			return fields
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// Edit writes text to a temporary file named with suffix, such as ".sql" for
+// syntax highlighting, opens it in the editor attached to the terminal and
+// returns the saved content
+func Edit(text, suffix string) (string, error) {
+	file, err := os.CreateTemp("", "pubdatahub-*"+suffix)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(text); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	args := Command()
+	cmd := exec.Command(args[0], append(args[1:], file.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", args[0], err)
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited file: %w", err)
+	}
+	return string(data), nil
+}
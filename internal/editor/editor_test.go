@@ -0,0 +1,40 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommand(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "code --wait")
+	assert.Equal(t, []string{"code", "--wait"}, Command())
+
+	t.Setenv("VISUAL", "nano")
+	assert.Equal(t, []string{"nano"}, Command())
+}
+
+func TestEdit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the editor")
+	}
+
+	// Stand in for the editor with a script that appends a line to the file
+	script := filepath.Join(t.TempDir(), "fake-editor")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho 'LIMIT 10' >> \"$1\"\n"), 0755))
+	t.Setenv("VISUAL", script)
+
+	text, err := Edit("SELECT * FROM items\n", ".sql")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM items\nLIMIT 10\n", text)
+
+	t.Setenv("VISUAL", "false")
+	_, err = Edit("SELECT 1", ".sql")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "editor false failed")
+}
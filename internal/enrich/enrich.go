@@ -0,0 +1,347 @@
+// Package enrich post-processes downloaded text with pluggable enrichers,
+// such as language detection, word counts and sentiment scores, and stores
+// their results in side tables named enrich_<enricher>.
+package enrich
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// TablePrefix starts the name of every enrichment table
+const TablePrefix = "enrich_"
+
+// batchSize is how many items are enriched and stored per transaction
+const batchSize = 500
+
+// Column is a result column of an enricher
+type Column struct {
+	Name string
+	Type string // SQLite type, e.g. "TEXT", "INTEGER", "REAL"
+}
+
+// Enricher computes values from the text of an item
+type Enricher interface {
+	// Name identifies the enricher and names its table enrich_<name>
+	Name() string
+	// Version changes whenever Enrich would return different values for the
+	// same text, so stored results are computed again
+	Version() int
+	// Columns are the values Enrich returns, in order
+	Columns() []Column
+	// Enrich returns the values of the columns for plain text
+	Enrich(text string) []interface{}
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Enricher)
+)
+
+// Register makes an enricher available by name, replacing any enricher
+// registered under the same name
+func Register(enricher Enricher) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[enricher.Name()] = enricher
+}
+
+// Names returns the names of the registered enrichers in order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the named enrichers, or every registered enricher when no
+// names are given
+func Lookup(names ...string) ([]Enricher, error) {
+	if len(names) == 0 {
+		names = Names()
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	enrichers := make([]Enricher, 0, len(names))
+	for _, name := range names {
+		enricher, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown enricher %q (available: %s)", name, strings.Join(namesLocked(), ", "))
+		}
+		enrichers = append(enrichers, enricher)
+	}
+	return enrichers, nil
+}
+
+// namesLocked returns the registered names; the caller holds registryMu
+func namesLocked() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Runner applies enrichers to the rows of a table with an integer id column
+// and text columns, such as Hacker News items with their title and text
+type Runner struct {
+	db          *sql.DB
+	source      string
+	table       string
+	textColumns []string
+}
+
+// NewRunner creates a runner for the text columns of table in db; source is
+// the data source reported with table writes
+func NewRunner(db *sql.DB, source, table string, textColumns ...string) *Runner {
+	return &Runner{
+		db:          db,
+		source:      source,
+		table:       table,
+		textColumns: textColumns,
+	}
+}
+
+// Run enriches the rows without a current result of each enricher and
+// returns how many results it stored. Rows enriched by an older version of
+// an enricher are enriched again; rerun discards all stored results first.
+// progress is called after each batch.
+func (r *Runner) Run(ctx context.Context, enrichers []Enricher, rerun bool, progress func(done, total int64)) (int64, error) {
+	var total int64
+	for _, enricher := range enrichers {
+		if err := r.prepareTable(enricher, rerun); err != nil {
+			return 0, err
+		}
+		pending, err := r.countPending(enricher)
+		if err != nil {
+			return 0, err
+		}
+		total += pending
+	}
+
+	var done int64
+	for _, enricher := range enrichers {
+		lastID := int64(-1) << 62
+		for {
+			if err := ctx.Err(); err != nil {
+				return done, err
+			}
+
+			ids, texts, err := r.pending(enricher, lastID)
+			if err != nil {
+				return done, err
+			}
+			if len(ids) == 0 {
+				break
+			}
+			if err := r.store(enricher, ids, texts); err != nil {
+				return done, err
+			}
+
+			lastID = ids[len(ids)-1]
+			done += int64(len(ids))
+			if progress != nil {
+				progress(done, total)
+			}
+		}
+	}
+	return done, nil
+}
+
+// prepareTable creates the table of an enricher, recreating it when its
+// columns changed or rerun is set
+func (r *Runner) prepareTable(enricher Enricher, rerun bool) error {
+	table := TablePrefix + enricher.Name()
+	existing, err := tableColumns(r.db, table)
+	if err != nil {
+		return err
+	}
+
+	expected := []string{"item_id", "version"}
+	for _, column := range enricher.Columns() {
+		expected = append(expected, column.Name)
+	}
+	expected = append(expected, "enriched_at")
+
+	if len(existing) > 0 && (rerun || strings.Join(existing, ",") != strings.Join(expected, ",")) {
+		if _, err := r.db.Exec("DROP TABLE " + table); err != nil {
+			return fmt.Errorf("failed to drop %s: %w", table, err)
+		}
+		storage.NotifyTableWrites(r.source, table)
+	}
+
+	definitions := []string{"item_id INTEGER PRIMARY KEY", "version INTEGER NOT NULL"}
+	for _, column := range enricher.Columns() {
+		definitions = append(definitions, column.Name+" "+column.Type)
+	}
+	definitions = append(definitions, "enriched_at DATETIME DEFAULT CURRENT_TIMESTAMP")
+	if _, err := r.db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(definitions, ", "))); err != nil {
+		return fmt.Errorf("failed to create %s: %w", table, err)
+	}
+	return nil
+}
+
+// pendingCondition selects rows with text and no current result
+func (r *Runner) pendingCondition(enricher Enricher) string {
+	hasText := make([]string, len(r.textColumns))
+	for i, column := range r.textColumns {
+		hasText[i] = fmt.Sprintf("COALESCE(t.%s, '') != ''", column)
+	}
+	return fmt.Sprintf("FROM %s t LEFT JOIN %s%s e ON e.item_id = t.id WHERE (e.item_id IS NULL OR e.version != %d) AND (%s)",
+		r.table, TablePrefix, enricher.Name(), enricher.Version(), strings.Join(hasText, " OR "))
+}
+
+// countPending returns how many rows an enricher has yet to process
+func (r *Runner) countPending(enricher Enricher) (int64, error) {
+	var count int64
+	if err := r.db.QueryRow("SELECT COUNT(*) " + r.pendingCondition(enricher)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count items for %s: %w", enricher.Name(), err)
+	}
+	return count, nil
+}
+
+// pending returns the next batch of rows after lastID that an enricher has
+// yet to process, with their plain text
+func (r *Runner) pending(enricher Enricher, lastID int64) ([]int64, []string, error) {
+	columns := make([]string, len(r.textColumns))
+	for i, column := range r.textColumns {
+		columns[i] = fmt.Sprintf("COALESCE(t.%s, '')", column)
+	}
+	query := fmt.Sprintf("SELECT t.id, %s %s AND t.id > ? ORDER BY t.id LIMIT %d",
+		strings.Join(columns, ", "), r.pendingCondition(enricher), batchSize)
+
+	rows, err := r.db.Query(query, lastID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load items for %s: %w", enricher.Name(), err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var texts []string
+	values := make([]string, len(r.textColumns))
+	targets := make([]interface{}, len(values)+1)
+	for rows.Next() {
+		var id int64
+		targets[0] = &id
+		for i := range values {
+			targets[i+1] = &values[i]
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		ids = append(ids, id)
+		texts = append(texts, PlainText(strings.Join(values, "\n\n")))
+	}
+	return ids, texts, rows.Err()
+}
+
+// store enriches texts and saves the results in one transaction
+func (r *Runner) store(enricher Enricher, ids []int64, texts []string) error {
+	table := TablePrefix + enricher.Name()
+	columns := []string{"item_id", "version"}
+	for _, column := range enricher.Columns() {
+		columns = append(columns, column.Name)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), placeholders))
+	if err != nil {
+		return fmt.Errorf("failed to prepare %s insert: %w", table, err)
+	}
+	defer stmt.Close()
+
+	for i, id := range ids {
+		args := append([]interface{}{id, enricher.Version()}, enricher.Enrich(texts[i])...)
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("failed to store %s of item %d: %w", enricher.Name(), id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	storage.NotifyTableWrites(r.source, table)
+	return nil
+}
+
+// TableSchemas returns the schemas of the enrichment tables that exist in db
+func TableSchemas(db *sql.DB) ([]datasource.TableSchema, error) {
+	enrichers, err := Lookup()
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []datasource.TableSchema
+	for _, enricher := range enrichers {
+		table := TablePrefix + enricher.Name()
+		existing, err := tableColumns(db, table)
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) == 0 {
+			continue
+		}
+
+		schema := datasource.TableSchema{Name: table}
+		schema.Columns = append(schema.Columns,
+			datasource.ColumnSchema{Name: "item_id", Type: "INTEGER"},
+			datasource.ColumnSchema{Name: "version", Type: "INTEGER"})
+		for _, column := range enricher.Columns() {
+			schema.Columns = append(schema.Columns, datasource.ColumnSchema{Name: column.Name, Type: column.Type})
+		}
+		schema.Columns = append(schema.Columns, datasource.ColumnSchema{Name: "enriched_at", Type: "DATETIME"})
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+// tableColumns returns the column names of a table, or none if it does not exist
+func tableColumns(db *sql.DB, table string) ([]string, error) {
+	columns, err := storage.QueryAs[string](db, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	return columns, nil
+}
+
+var (
+	paragraphTag = regexp.MustCompile(`(?i)<p>`)
+	htmlTag      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// PlainText strips HTML tags and entities from item text, keeping paragraphs
+func PlainText(text string) string {
+	text = paragraphTag.ReplaceAllString(text, "\n\n")
+	text = htmlTag.ReplaceAllString(text, " ")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+// words splits plain text into lower-cased words
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
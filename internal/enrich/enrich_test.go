@@ -0,0 +1,153 @@
+package enrich
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingEnricher records how many texts it enriched
+type countingEnricher struct {
+	version int
+	calls   int
+}
+
+func (c *countingEnricher) Name() string { return "counting" }
+
+func (c *countingEnricher) Version() int { return c.version }
+
+func (c *countingEnricher) Columns() []Column {
+	return []Column{{Name: "length", Type: "INTEGER"}}
+}
+
+func (c *countingEnricher) Enrich(text string) []interface{} {
+	c.calls++
+	return []interface{}{int64(len(text))}
+}
+
+func openItems(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(storage.DriverName, filepath.Join(t.TempDir(), "enrich.sqlite"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY, title TEXT, text TEXT);
+		INSERT INTO items VALUES (1, 'A title', NULL), (2, NULL, '<p>Some &amp; text'), (3, NULL, NULL)`)
+	require.NoError(t, err)
+	return db
+}
+
+func TestRunner_Run(t *testing.T) {
+	db := openItems(t)
+	runner := NewRunner(db, "test", "items", "title", "text")
+	enricher := &countingEnricher{version: 1}
+
+	var progressed int64
+	count, err := runner.Run(context.Background(), []Enricher{enricher}, false, func(done, total int64) {
+		progressed = done
+		assert.Equal(t, int64(2), total)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, int64(2), progressed)
+
+	var length int64
+	require.NoError(t, db.QueryRow("SELECT length FROM enrich_counting WHERE item_id = 2").Scan(&length))
+	assert.Equal(t, int64(len("Some & text")), length)
+
+	// Current results are kept
+	count, err = runner.Run(context.Background(), []Enricher{enricher}, false, nil)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	// New items and a new version are enriched again
+	_, err = db.Exec("INSERT INTO items VALUES (4, 'Another', NULL)")
+	require.NoError(t, err)
+	count, err = runner.Run(context.Background(), []Enricher{enricher}, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	enricher.version = 2
+	count, err = runner.Run(context.Background(), []Enricher{enricher}, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	count, err = runner.Run(context.Background(), []Enricher{enricher}, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	assert.Equal(t, 9, enricher.calls)
+}
+
+func TestTableSchemas(t *testing.T) {
+	db := openItems(t)
+	schemas, err := TableSchemas(db)
+	require.NoError(t, err)
+	assert.Empty(t, schemas)
+
+	enrichers, err := Lookup("words")
+	require.NoError(t, err)
+	_, err = NewRunner(db, "test", "items", "title", "text").Run(context.Background(), enrichers, false, nil)
+	require.NoError(t, err)
+
+	schemas, err = TableSchemas(db)
+	require.NoError(t, err)
+	require.Len(t, schemas, 1)
+	assert.Equal(t, "enrich_words", schemas[0].Name)
+	assert.Len(t, schemas[0].Columns, 6)
+}
+
+func TestLookup(t *testing.T) {
+	assert.Equal(t, []string{"language", "sentiment", "words"}, Names())
+
+	enrichers, err := Lookup()
+	require.NoError(t, err)
+	assert.Len(t, enrichers, 3)
+
+	_, err = Lookup("emoji")
+	assert.ErrorContains(t, err, "unknown enricher")
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text     string
+		language string
+	}{
+		{"This is the best thing that I have seen in a while and it works", "en"},
+		{"Das ist nicht die Lösung, aber es ist auch kein Problem mit der Idee", "de"},
+		{"Je pense que ce n'est pas une bonne idée pour les utilisateurs", "fr"},
+		{"Это очень интересная статья", "ru"},
+		{"これは面白い記事です", "ja"},
+		{"Rust", UndeterminedLanguage},
+		{"", UndeterminedLanguage},
+	}
+	for _, test := range tests {
+		language, _ := detectLanguage(test.text)
+		assert.Equal(t, test.language, language, test.text)
+	}
+}
+
+func TestWordCounter(t *testing.T) {
+	values := wordCounter{}.Enrich("The cat and the  hat")
+	assert.Equal(t, []interface{}{int64(5), int64(4), int64(19)}, values)
+}
+
+func TestSentimentScorer(t *testing.T) {
+	values := sentimentScorer{}.Enrich("Great library, I love it")
+	assert.Equal(t, []interface{}{1.0, int64(2), int64(0)}, values)
+
+	values = sentimentScorer{}.Enrich("This is not good and the docs are terrible")
+	assert.Equal(t, []interface{}{-1.0, int64(0), int64(2)}, values)
+
+	values = sentimentScorer{}.Enrich("Nothing to see here")
+	assert.Equal(t, []interface{}{0.0, int64(0), int64(0)}, values)
+}
+
+func TestPlainText(t *testing.T) {
+	assert.Equal(t, "First\n\nSecond <b>", PlainText("First<p>Second &lt;b&gt;"))
+	assert.Equal(t, "a  link", PlainText(`a <a href="https://example.com">link</a>`))
+}
@@ -0,0 +1,146 @@
+package enrich
+
+import (
+	"math"
+	"unicode"
+)
+
+func init() {
+	Register(languageDetector{})
+}
+
+// UndeterminedLanguage is reported when a text is too short or matches no
+// known language (ISO 639-2 "und")
+const UndeterminedLanguage = "und"
+
+// minLanguageWords is how many stopwords a text needs for a language guess
+const minLanguageWords = 2
+
+// stopwords are frequent function words of the languages the detector tells
+// apart, by ISO 639-1 code
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "of", "to", "in", "that", "it", "for", "with", "this", "was", "are", "you", "not", "but", "have", "be", "on", "what"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ich", "es", "mit", "sich", "auch", "auf", "ein", "eine", "zu", "den", "von", "wir", "aber", "wie"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "pas", "que", "pour", "dans", "qui", "sur", "ce", "avec", "sont", "je", "du", "au", "mais"},
+	"es": {"el", "la", "los", "las", "que", "es", "y", "en", "un", "una", "por", "con", "para", "no", "del", "se", "lo", "como", "pero", "muy"},
+	"it": {"il", "di", "che", "è", "e", "la", "per", "un", "non", "sono", "una", "del", "della", "con", "gli", "ma", "anche", "questo", "come", "nel"},
+	"pt": {"o", "que", "não", "de", "da", "do", "em", "um", "uma", "para", "com", "os", "as", "é", "mas", "como", "se", "mais", "dos", "ao"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "ik", "je", "op", "te", "zijn", "met", "voor", "ook", "maar", "wat", "die", "er"},
+	"sv": {"och", "att", "det", "som", "är", "en", "på", "för", "med", "inte", "jag", "har", "av", "till", "den", "om", "men", "ett", "var", "så"},
+}
+
+// stopwordLanguages maps each stopword to the languages using it
+var stopwordLanguages = func() map[string][]string {
+	index := make(map[string][]string)
+	for language, words := range stopwords {
+		for _, word := range words {
+			index[word] = append(index[word], language)
+		}
+	}
+	return index
+}()
+
+// scriptLanguages are languages recognized by their script alone
+var scriptLanguages = []struct {
+	language string
+	table    *unicode.RangeTable
+}{
+	{"ru", unicode.Cyrillic},
+	{"el", unicode.Greek},
+	{"ja", unicode.Hiragana},
+	{"ja", unicode.Katakana},
+	{"ko", unicode.Hangul},
+	{"zh", unicode.Han},
+	{"ar", unicode.Arabic},
+	{"he", unicode.Hebrew},
+}
+
+// languageDetector guesses the language of a text from its script and
+// stopwords, with the share of matching words as confidence
+type languageDetector struct{}
+
+func (languageDetector) Name() string { return "language" }
+
+func (languageDetector) Version() int { return 1 }
+
+func (languageDetector) Columns() []Column {
+	return []Column{
+		{Name: "language", Type: "TEXT"},
+		{Name: "confidence", Type: "REAL"},
+	}
+}
+
+func (languageDetector) Enrich(text string) []interface{} {
+	language, confidence := detectLanguage(text)
+	return []interface{}{language, confidence}
+}
+
+// detectLanguage returns the ISO 639-1 code of the most likely language of
+// text and a confidence between 0 and 1
+func detectLanguage(text string) (string, float64) {
+	letters := 0
+	scripts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, script := range scriptLanguages {
+			if unicode.Is(script.table, r) {
+				scripts[script.language]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return UndeterminedLanguage, 0
+	}
+
+	// Kana marks Japanese even when most characters are Han
+	if scripts["ja"] > 0 {
+		scripts["ja"] += scripts["zh"]
+		delete(scripts, "zh")
+	}
+	best, bestCount := "", 0
+	for language, count := range scripts {
+		if count > bestCount || (count == bestCount && language < best) {
+			best, bestCount = language, count
+		}
+	}
+	if bestCount*2 > letters {
+		return best, round(float64(bestCount) / float64(letters))
+	}
+
+	tokens := words(text)
+	scores := make(map[string]int)
+	matched := 0
+	for _, token := range tokens {
+		languages := stopwordLanguages[token]
+		if len(languages) > 0 {
+			matched++
+		}
+		for _, language := range languages {
+			scores[language]++
+		}
+	}
+	if matched < minLanguageWords {
+		return UndeterminedLanguage, 0
+	}
+
+	best, bestScore, second := "", 0, 0
+	for language, score := range scores {
+		switch {
+		case score > bestScore || (score == bestScore && language < best):
+			best, bestScore, second = language, score, bestScore
+		case score > second:
+			second = score
+		}
+	}
+	// Confidence grows with the lead over the runner-up language
+	return best, round(float64(bestScore-second) / float64(bestScore))
+}
+
+// round rounds a score to two decimals
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
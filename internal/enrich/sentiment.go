@@ -0,0 +1,73 @@
+package enrich
+
+func init() {
+	Register(sentimentScorer{})
+}
+
+// positiveWords and negativeWords are a small English opinion lexicon
+var (
+	positiveWords = wordSet("good", "great", "excellent", "amazing", "awesome", "love", "loved", "like", "nice",
+		"best", "better", "happy", "impressive", "interesting", "useful", "helpful", "beautiful", "brilliant",
+		"fantastic", "wonderful", "elegant", "fast", "clean", "simple", "easy", "cool", "fun", "enjoy",
+		"enjoyed", "thanks", "thank", "agree", "right", "correct", "works", "win", "wins", "success",
+		"successful", "recommend", "perfect", "solid", "clever", "smart", "neat", "glad", "excited", "favorite")
+	negativeWords = wordSet("bad", "terrible", "awful", "horrible", "hate", "hated", "worst", "worse", "poor",
+		"broken", "bug", "buggy", "slow", "ugly", "wrong", "useless", "annoying", "disappointing",
+		"disappointed", "fail", "fails", "failed", "failure", "problem", "problems", "crash", "crashes",
+		"difficult", "hard", "confusing", "stupid", "sad", "angry", "scam", "spam", "waste", "painful",
+		"mess", "bloated", "insecure", "dangerous", "risky", "sucks", "garbage", "disagree", "unfortunately", "lose")
+	negations = wordSet("not", "no", "never", "don't", "doesn't", "isn't", "wasn't", "aren't", "can't", "won't", "nothing", "hardly")
+)
+
+// wordSet returns a set of words
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// sentimentScorer scores the opinion of a text from -1 (negative) to 1
+// (positive) by counting lexicon words, flipping words after a negation
+type sentimentScorer struct{}
+
+func (sentimentScorer) Name() string { return "sentiment" }
+
+func (sentimentScorer) Version() int { return 1 }
+
+func (sentimentScorer) Columns() []Column {
+	return []Column{
+		{Name: "score", Type: "REAL"},
+		{Name: "positive", Type: "INTEGER"},
+		{Name: "negative", Type: "INTEGER"},
+	}
+}
+
+func (sentimentScorer) Enrich(text string) []interface{} {
+	positive, negative := 0, 0
+	negated := 0 // Words left in the scope of the last negation
+	for _, token := range words(text) {
+		if negations[token] {
+			negated = 3
+			continue
+		}
+		isPositive, isNegative := positiveWords[token], negativeWords[token]
+		if negated > 0 {
+			isPositive, isNegative = isNegative, isPositive
+			negated--
+		}
+		if isPositive {
+			positive++
+		}
+		if isNegative {
+			negative++
+		}
+	}
+
+	score := 0.0
+	if positive+negative > 0 {
+		score = round(float64(positive-negative) / float64(positive+negative))
+	}
+	return []interface{}{score, int64(positive), int64(negative)}
+}
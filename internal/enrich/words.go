@@ -0,0 +1,41 @@
+package enrich
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+func init() {
+	Register(wordCounter{})
+}
+
+// wordCounter counts the words, distinct words and characters of a text
+type wordCounter struct{}
+
+func (wordCounter) Name() string { return "words" }
+
+func (wordCounter) Version() int { return 1 }
+
+func (wordCounter) Columns() []Column {
+	return []Column{
+		{Name: "words", Type: "INTEGER"},
+		{Name: "unique_words", Type: "INTEGER"},
+		{Name: "characters", Type: "INTEGER"},
+	}
+}
+
+func (wordCounter) Enrich(text string) []interface{} {
+	tokens := words(text)
+	unique := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		unique[token] = true
+	}
+	characters := utf8.RuneCountInString(strings.Join(strings.Fields(text), " "))
+	return []interface{}{int64(len(tokens)), int64(len(unique)), int64(characters)}
+}
+
+// isWordRune reports whether r can be part of a word
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+}
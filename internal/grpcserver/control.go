@@ -0,0 +1,334 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/audit"
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/storage"
+	pubdatahubv1 "github.com/brainless/PubDataHub/proto/pubdatahub/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultQueryLimit caps the rows returned by Query without a limit
+const defaultQueryLimit = 1000
+
+// watchInterval is how often WatchJob checks the job for changes
+var watchInterval = 500 * time.Millisecond
+
+// hiddenConfigKeys are not returned by GetConfig because they may hold
+// API tokens
+var hiddenConfigKeys = map[string]bool{"api.tokens": true}
+
+// controlService implements the Control service
+type controlService struct {
+	pubdatahubv1.UnimplementedControlServer
+	config    Config
+	startedAt time.Time
+}
+
+func newControlService(config Config) *controlService {
+	return &controlService{config: config, startedAt: time.Now()}
+}
+
+// jobStates maps job states to their protobuf values
+var jobStates = map[jobs.JobState]pubdatahubv1.JobState{
+	jobs.JobStateQueued:    pubdatahubv1.JobState_JOB_STATE_QUEUED,
+	jobs.JobStateRunning:   pubdatahubv1.JobState_JOB_STATE_RUNNING,
+	jobs.JobStatePaused:    pubdatahubv1.JobState_JOB_STATE_PAUSED,
+	jobs.JobStateCompleted: pubdatahubv1.JobState_JOB_STATE_COMPLETED,
+	jobs.JobStateFailed:    pubdatahubv1.JobState_JOB_STATE_FAILED,
+	jobs.JobStateCancelled: pubdatahubv1.JobState_JOB_STATE_CANCELLED,
+
+	// Dead-letter jobs are failed jobs that no longer retry
+	jobs.JobStateDeadLetter: pubdatahubv1.JobState_JOB_STATE_FAILED,
+}
+
+// toJob converts a job status to its protobuf message
+func toJob(job *jobs.JobStatus) *pubdatahubv1.Job {
+	message := &pubdatahubv1.Job{
+		Id:           job.ID,
+		Type:         string(job.Type),
+		State:        jobStates[job.State],
+		Priority:     int32(job.Priority),
+		Description:  job.Description,
+		StartTime:    timestamppb.New(job.StartTime),
+		ErrorMessage: job.ErrorMessage,
+		RetryCount:   int32(job.RetryCount),
+		MaxRetries:   int32(job.MaxRetries),
+		CreatedBy:    job.CreatedBy,
+		Progress: &pubdatahubv1.JobProgress{
+			Current: job.Progress.Current,
+			Total:   job.Progress.Total,
+			Message: job.Progress.Message,
+			Percent: job.Progress.Percentage(),
+			Rate:    job.Progress.Rate,
+			Stalled: job.Progress.Stalled,
+		},
+	}
+	if source, ok := job.Metadata["source_name"].(string); ok {
+		message.Source = source
+	}
+	if job.EndTime != nil {
+		message.EndTime = timestamppb.New(*job.EndTime)
+	}
+	if job.Progress.ETA != nil {
+		message.Progress.EtaSeconds = int64(job.Progress.ETA.Seconds())
+	}
+	return message
+}
+
+// jobError converts a job manager error to a gRPC status
+func jobError(err error) error {
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.FailedPrecondition, err.Error())
+}
+
+func (s *controlService) GetStatus(ctx context.Context, req *pubdatahubv1.GetStatusRequest) (*pubdatahubv1.GetStatusResponse, error) {
+	stats := s.config.JobManager.GetStats()
+	return &pubdatahubv1.GetStatusResponse{
+		Pid:         int32(os.Getpid()),
+		StartedAt:   timestamppb.New(s.startedAt),
+		StoragePath: s.config.StoragePath,
+		Version:     s.config.Version,
+		Stats: &pubdatahubv1.JobStats{
+			Total:     int32(stats.TotalJobs),
+			Queued:    int32(stats.QueuedJobs),
+			Running:   int32(stats.RunningJobs),
+			Completed: int32(stats.CompletedJobs),
+			Failed:    int32(stats.FailedJobs),
+		},
+	}, nil
+}
+
+func (s *controlService) ListJobs(ctx context.Context, req *pubdatahubv1.ListJobsRequest) (*pubdatahubv1.ListJobsResponse, error) {
+	filter := jobs.JobFilter{Source: req.GetSource()}
+	for _, state := range req.GetStates() {
+		for name, value := range jobStates {
+			if value == state {
+				filter.States = append(filter.States, name)
+			}
+		}
+	}
+	for _, jobType := range req.GetTypes() {
+		filter.Types = append(filter.Types, jobs.JobType(jobType))
+	}
+
+	list, err := s.config.JobManager.ListJobs(filter)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list jobs: %v", err)
+	}
+	resp := &pubdatahubv1.ListJobsResponse{Jobs: make([]*pubdatahubv1.Job, 0, len(list))}
+	for _, job := range list {
+		resp.Jobs = append(resp.Jobs, toJob(job))
+	}
+	return resp, nil
+}
+
+func (s *controlService) GetJob(ctx context.Context, req *pubdatahubv1.GetJobRequest) (*pubdatahubv1.Job, error) {
+	job, err := s.config.JobManager.GetJob(req.GetId())
+	if err != nil {
+		return nil, jobError(err)
+	}
+	return toJob(job), nil
+}
+
+func (s *controlService) StartDownload(ctx context.Context, req *pubdatahubv1.StartDownloadRequest) (*pubdatahubv1.Job, error) {
+	ds, ok := s.config.DataSources[req.GetSource()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown data source: %s", req.GetSource())
+	}
+	batchSize := int(req.GetBatchSize())
+	if batchSize == 0 {
+		batchSize = 100
+	}
+	priority := jobs.JobPriority(req.GetPriority())
+	if priority == 0 {
+		priority = jobs.PriorityNormal
+	}
+	if batchSize < 1 || priority < jobs.PriorityLow || priority > jobs.PriorityHigh {
+		return nil, status.Errorf(codes.InvalidArgument, "batch size must be at least 1 and priority from %d to %d", jobs.PriorityLow, jobs.PriorityHigh)
+	}
+
+	id, err := s.config.JobManager.StartSourceDownload(req.GetSource(), ds, batchSize, priority)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s.GetJob(ctx, &pubdatahubv1.GetJobRequest{Id: id})
+}
+
+// controlJob checks the job exists, applies action and returns the job
+func (s *controlService) controlJob(ctx context.Context, id string, action func(string) error) (*pubdatahubv1.Job, error) {
+	if _, err := s.config.JobManager.GetJob(id); err != nil {
+		return nil, jobError(err)
+	}
+	if err := action(id); err != nil {
+		return nil, jobError(err)
+	}
+	return s.GetJob(ctx, &pubdatahubv1.GetJobRequest{Id: id})
+}
+
+func (s *controlService) PauseJob(ctx context.Context, req *pubdatahubv1.JobRequest) (*pubdatahubv1.Job, error) {
+	return s.controlJob(ctx, req.GetId(), s.config.JobManager.PauseJob)
+}
+
+func (s *controlService) ResumeJob(ctx context.Context, req *pubdatahubv1.JobRequest) (*pubdatahubv1.Job, error) {
+	return s.controlJob(ctx, req.GetId(), s.config.JobManager.ResumeJob)
+}
+
+func (s *controlService) CancelJob(ctx context.Context, req *pubdatahubv1.JobRequest) (*pubdatahubv1.Job, error) {
+	return s.controlJob(ctx, req.GetId(), s.config.JobManager.CancelJob)
+}
+
+func (s *controlService) WatchJob(req *pubdatahubv1.WatchJobRequest, stream pubdatahubv1.Control_WatchJobServer) error {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	var last *jobs.JobStatus
+	for {
+		job, err := s.config.JobManager.GetJob(req.GetId())
+		if err != nil {
+			return jobError(err)
+		}
+		if last == nil || job.State != last.State || job.Progress.Current != last.Progress.Current ||
+			job.Progress.Total != last.Progress.Total || job.Progress.Message != last.Progress.Message {
+			if err := stream.Send(toJob(job)); err != nil {
+				return err
+			}
+			last = job
+		}
+		if job.State.IsFinished() {
+			return nil
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *controlService) ListSources(ctx context.Context, req *pubdatahubv1.ListSourcesRequest) (*pubdatahubv1.ListSourcesResponse, error) {
+	names := make([]string, 0, len(s.config.DataSources))
+	for name := range s.config.DataSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resp := &pubdatahubv1.ListSourcesResponse{}
+	for _, name := range names {
+		ds := s.config.DataSources[name]
+		download := ds.GetDownloadStatus()
+		resp.Sources = append(resp.Sources, &pubdatahubv1.Source{
+			Name:        name,
+			Description: ds.Description(),
+			Download: &pubdatahubv1.DownloadStatus{
+				Active:       download.IsActive,
+				Status:       download.Status,
+				Progress:     download.Progress,
+				ItemsTotal:   download.ItemsTotal,
+				ItemsCached:  download.ItemsCached,
+				LastUpdate:   timestamppb.New(download.LastUpdate),
+				ErrorMessage: download.ErrorMessage,
+			},
+		})
+	}
+	return resp, nil
+}
+
+func (s *controlService) Query(ctx context.Context, req *pubdatahubv1.QueryRequest) (*pubdatahubv1.QueryResponse, error) {
+	ds, ok := s.config.DataSources[req.GetSource()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown data source: %s", req.GetSource())
+	}
+	if tables := storage.WrittenTables(req.GetSql()); len(tables) > 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "the control API only runs queries that read; this query writes %v", tables)
+	}
+
+	caller := audit.Caller{Interface: audit.InterfaceGRPC, User: tokenName(ctx)}
+	result, err := audit.Query(caller, req.GetSource(), req.GetSql(), ds.Query)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	resp := &pubdatahubv1.QueryResponse{
+		Columns:    result.Columns,
+		Count:      int32(result.Count),
+		DurationMs: result.Duration.Milliseconds(),
+	}
+	for i, row := range result.Rows {
+		if i == limit {
+			resp.Truncated = true
+			break
+		}
+		values := make([]*pubdatahubv1.Value, len(row))
+		for j, value := range row {
+			values[j] = &pubdatahubv1.Value{}
+			if value != nil {
+				text := queryText(value)
+				values[j].Text = &text
+			}
+		}
+		resp.Rows = append(resp.Rows, &pubdatahubv1.Row{Values: values})
+	}
+	return resp, nil
+}
+
+// queryText formats a query value as text
+func queryText(value interface{}) string {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (s *controlService) GetConfig(ctx context.Context, req *pubdatahubv1.GetConfigRequest) (*pubdatahubv1.GetConfigResponse, error) {
+	resp := &pubdatahubv1.GetConfigResponse{Values: make(map[string]string)}
+	if key := req.GetKey(); key != "" {
+		value, ok := config.Get(key)
+		if !ok || hiddenConfigKeys[key] {
+			return nil, status.Errorf(codes.NotFound, "unknown config key %s", key)
+		}
+		resp.Values[key] = value
+		return resp, nil
+	}
+	for _, key := range config.Keys() {
+		if !hiddenConfigKeys[key] {
+			resp.Values[key], _ = config.Get(key)
+		}
+	}
+	return resp, nil
+}
+
+func (s *controlService) SetConfig(ctx context.Context, req *pubdatahubv1.SetConfigRequest) (*pubdatahubv1.SetConfigResponse, error) {
+	if req.GetKey() == "" || hiddenConfigKeys[req.GetKey()] {
+		return nil, status.Errorf(codes.InvalidArgument, "config key %q cannot be set over the control API", req.GetKey())
+	}
+	origin := config.OriginGRPC
+	if name := tokenName(ctx); name != "" {
+		origin += ":" + name
+	}
+	if err := config.Set(req.GetKey(), req.GetValue(), origin); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pubdatahubv1.SetConfigResponse{}, nil
+}
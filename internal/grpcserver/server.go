@@ -0,0 +1,125 @@
+// Package grpcserver serves the gRPC control API defined in
+// proto/pubdatahub/v1, giving external tools typed access to jobs, data
+// sources, queries and settings of a running daemon.
+package grpcserver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/log"
+	pubdatahubv1 "github.com/brainless/PubDataHub/proto/pubdatahub/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Config configures the control service
+type Config struct {
+	JobManager  *jobs.EnhancedJobManager
+	DataSources map[string]datasource.DataSource
+	StoragePath string
+	Version     string
+	// Auth checks the bearer token of each call; nil accepts every call
+	Auth *api.Authenticator
+}
+
+// controlMethods change state and need a token with the admin scope
+var controlMethods = map[string]bool{
+	pubdatahubv1.Control_StartDownload_FullMethodName: true,
+	pubdatahubv1.Control_PauseJob_FullMethodName:      true,
+	pubdatahubv1.Control_ResumeJob_FullMethodName:     true,
+	pubdatahubv1.Control_CancelJob_FullMethodName:     true,
+	pubdatahubv1.Control_SetConfig_FullMethodName:     true,
+}
+
+// New creates a gRPC server with the control service registered
+func New(config Config) *grpc.Server {
+	guard := &authGuard{auth: config.Auth}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(guard.unary),
+		grpc.ChainStreamInterceptor(guard.stream),
+	)
+	pubdatahubv1.RegisterControlServer(server, newControlService(config))
+	return server
+}
+
+// authGuard checks tokens and logs calls like the HTTP API middleware
+type authGuard struct {
+	auth *api.Authenticator
+}
+
+// check returns the name of the token that may call method
+func (g *authGuard) check(ctx context.Context, method string) (string, error) {
+	if g.auth == nil {
+		return "", nil
+	}
+
+	value := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, header := range md.Get("authorization") {
+			if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+				value = strings.TrimSpace(token)
+			}
+		}
+	}
+	if value == "" {
+		return "", status.Error(codes.Unauthenticated, "missing API token")
+	}
+	token, ok := g.auth.Authenticate(value)
+	if !ok {
+		log.ForComponent("grpc").Warnf("Rejected call %s: invalid token", method)
+		return "", status.Error(codes.Unauthenticated, "invalid API token")
+	}
+	if controlMethods[method] && token.Scope != api.ScopeAdmin {
+		log.ForComponent("grpc").Warnf("Rejected call %s by token %s: needs scope %s", method, token.Name, api.ScopeAdmin)
+		return "", status.Errorf(codes.PermissionDenied, "token %s has scope %s; %s needs scope %s", token.Name, token.Scope, method, api.ScopeAdmin)
+	}
+	return token.Name, nil
+}
+
+// tokenContextKey stores the name of the token that made a call
+type tokenContextKey struct{}
+
+// tokenName returns the name of the token that made the call
+func tokenName(ctx context.Context) string {
+	name, _ := ctx.Value(tokenContextKey{}).(string)
+	return name
+}
+
+// logCall logs a finished call
+func logCall(token, method string, err error, start time.Time) {
+	log.ForComponent("grpc").WithFields(map[string]interface{}{
+		"token":       token,
+		"method":      method,
+		"code":        status.Code(err).String(),
+		"duration_ms": time.Since(start).Milliseconds(),
+	}).Info("gRPC call")
+}
+
+func (g *authGuard) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	token, err := g.check(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := handler(context.WithValue(ctx, tokenContextKey{}, token), req)
+	logCall(token, info.FullMethod, err, start)
+	return resp, err
+}
+
+func (g *authGuard) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	token, err := g.check(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = handler(srv, ss)
+	logCall(token, info.FullMethod, err, start)
+	return err
+}
@@ -0,0 +1,154 @@
+package grpcserver
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/log"
+	pubdatahubv1 "github.com/brainless/PubDataHub/proto/pubdatahub/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	readToken = "read-token-0123456789"
+	jobsToken = "jobs-token-0123456789"
+)
+
+// newTestClient serves the control API with a mock data source over an
+// in-memory connection
+func newTestClient(t *testing.T) pubdatahubv1.ControlClient {
+	t.Helper()
+	log.InitLogger(false)
+
+	dataSources := map[string]datasource.DataSource{
+		"mock": datasource.NewMockDataSource("mock", "Mock data source"),
+	}
+	jobManager, err := jobs.NewEnhancedJobManager(t.TempDir(), dataSources, jobs.DefaultManagerConfig())
+	require.NoError(t, err)
+	require.NoError(t, jobManager.Start())
+	t.Cleanup(func() { jobManager.Stop() })
+
+	auth, err := api.NewAuthenticator([]api.Token{
+		{Name: "dashboard", Value: readToken, Scope: api.ScopeRead},
+		{Name: "ci", Value: jobsToken, Scope: api.ScopeAdmin},
+	})
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1 << 20)
+	server := New(Config{JobManager: jobManager, DataSources: dataSources, Version: "test", Auth: auth})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pubdatahubv1.NewControlClient(conn)
+}
+
+// withToken returns a context that sends token with each call
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestControl_Authentication(t *testing.T) {
+	client := newTestClient(t)
+
+	_, err := client.GetStatus(context.Background(), &pubdatahubv1.GetStatusRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.GetStatus(withToken("wrong-token-0123456789"), &pubdatahubv1.GetStatusRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	resp, err := client.GetStatus(withToken(readToken), &pubdatahubv1.GetStatusRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "test", resp.GetVersion())
+
+	// Read tokens cannot change state
+	_, err = client.StartDownload(withToken(readToken), &pubdatahubv1.StartDownloadRequest{Source: "mock"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.SetConfig(withToken(readToken), &pubdatahubv1.SetConfigRequest{Key: "jobs.workers", Value: "2"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestControl_Jobs(t *testing.T) {
+	client := newTestClient(t)
+	ctx := withToken(jobsToken)
+
+	job, err := client.StartDownload(ctx, &pubdatahubv1.StartDownloadRequest{Source: "mock", BatchSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, "mock", job.GetSource())
+	assert.Equal(t, string(jobs.JobTypeDownload), job.GetType())
+
+	list, err := client.ListJobs(ctx, &pubdatahubv1.ListJobsRequest{Source: "mock"})
+	require.NoError(t, err)
+	require.Len(t, list.GetJobs(), 1)
+	assert.Equal(t, job.GetId(), list.GetJobs()[0].GetId())
+
+	_, err = client.GetJob(ctx, &pubdatahubv1.GetJobRequest{Id: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.StartDownload(ctx, &pubdatahubv1.StartDownloadRequest{Source: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.StartDownload(ctx, &pubdatahubv1.StartDownloadRequest{Source: "mock", Priority: 42})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestControl_WatchJob(t *testing.T) {
+	watchInterval = 10 * time.Millisecond
+	client := newTestClient(t)
+	ctx := withToken(jobsToken)
+
+	job, err := client.StartDownload(ctx, &pubdatahubv1.StartDownloadRequest{Source: "mock"})
+	require.NoError(t, err)
+
+	watchCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	stream, err := client.WatchJob(watchCtx, &pubdatahubv1.WatchJobRequest{Id: job.GetId()})
+	require.NoError(t, err)
+
+	// The stream ends once the job finishes
+	var last *pubdatahubv1.Job
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		last = update
+	}
+	require.NotNil(t, last)
+	assert.Contains(t, []pubdatahubv1.JobState{
+		pubdatahubv1.JobState_JOB_STATE_COMPLETED,
+		pubdatahubv1.JobState_JOB_STATE_FAILED,
+	}, last.GetState())
+}
+
+func TestControl_SourcesAndQuery(t *testing.T) {
+	client := newTestClient(t)
+	ctx := withToken(readToken)
+
+	sources, err := client.ListSources(ctx, &pubdatahubv1.ListSourcesRequest{})
+	require.NoError(t, err)
+	require.Len(t, sources.GetSources(), 1)
+	assert.Equal(t, "mock", sources.GetSources()[0].GetName())
+
+	_, err = client.Query(ctx, &pubdatahubv1.QueryRequest{Source: "mock", Sql: "DELETE FROM items"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.Query(ctx, &pubdatahubv1.QueryRequest{Source: "missing", Sql: "SELECT 1"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,256 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metaExt = ".json"
+	bodyExt = ".body"
+)
+
+// CacheEntry describes a cached response
+type CacheEntry struct {
+	URL          string      `json:"url"`
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	StoredAt     time.Time   `json:"stored_at"`
+	Size         int64       `json:"size"`
+}
+
+// CacheStats summarizes the contents of the cache
+type CacheStats struct {
+	Dir      string `json:"dir"`
+	Entries  int    `json:"entries"`
+	Size     int64  `json:"size_bytes"`
+	MaxSize  int64  `json:"max_size_bytes"`
+	Hits     int64  `json:"hits"`
+	Misses   int64  `json:"misses"`
+	Evicted  int64  `json:"evicted"`
+	Requests int64  `json:"requests"`
+}
+
+// Cache is an on-disk store of HTTP responses keyed by URL. Entries are
+// kept as a JSON metadata file next to the raw body, and the least recently
+// used entries are evicted once the total size exceeds maxSize.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	sized   bool
+	hits    int64
+	misses  int64
+	evicted int64
+}
+
+// NewCache creates a cache in dir holding at most maxSize bytes of bodies;
+// a maxSize of 0 means unlimited
+func NewCache(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir, maxSize: maxSize}, nil
+}
+
+// CacheDir returns the HTTP cache directory under storagePath
+func CacheDir(storagePath string) string {
+	return filepath.Join(storagePath, "cache", "http")
+}
+
+// Get returns the cached entry and body for url
+func (c *Cache) Get(url string) (*CacheEntry, []byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	metaPath, bodyPath := c.paths(url)
+	metaData, err := os.ReadFile(metaPath)
+	if err != nil {
+		c.misses++
+		return nil, nil, false
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(metaData, &entry); err != nil || entry.URL != url {
+		c.misses++
+		return nil, nil, false
+	}
+
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		c.misses++
+		return nil, nil, false
+	}
+
+	// Touch the entry so eviction is least recently used
+	now := time.Now()
+	os.Chtimes(metaPath, now, now)
+
+	c.hits++
+	return &entry, body, true
+}
+
+// Put stores a response body for url, evicting old entries if needed
+func (c *Cache) Put(entry CacheEntry, body []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.maxSize > 0 && int64(len(body)) > c.maxSize {
+		return nil
+	}
+	c.ensureSized()
+
+	metaPath, bodyPath := c.paths(entry.URL)
+	if previous, err := os.Stat(bodyPath); err == nil {
+		c.size -= previous.Size()
+	}
+
+	entry.Size = int64(len(body))
+	entry.StoredAt = time.Now()
+	metaData, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+
+	if err := writeFileAtomic(bodyPath, body); err != nil {
+		return fmt.Errorf("failed to write cached body: %w", err)
+	}
+	if err := writeFileAtomic(metaPath, metaData); err != nil {
+		os.Remove(bodyPath)
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	c.size += entry.Size
+
+	c.evict(metaPath)
+	return nil
+}
+
+// Clear removes every cached entry
+func (c *Cache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(c.dir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove cache entry: %w", err)
+		}
+	}
+
+	c.size = 0
+	c.sized = true
+	return nil
+}
+
+// Stats returns the number and size of cached entries
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ensureSized()
+	entries, _ := filepath.Glob(filepath.Join(c.dir, "*"+metaExt))
+	return CacheStats{
+		Dir:      c.dir,
+		Entries:  len(entries),
+		Size:     c.size,
+		MaxSize:  c.maxSize,
+		Hits:     c.hits,
+		Misses:   c.misses,
+		Evicted:  c.evicted,
+		Requests: c.hits + c.misses,
+	}
+}
+
+// evict removes least recently used entries other than keep until the cache
+// fits; callers hold mu
+func (c *Cache) evict(keep string) {
+	if c.maxSize <= 0 || c.size <= c.maxSize {
+		return
+	}
+
+	type cached struct {
+		key     string
+		size    int64
+		touched time.Time
+	}
+
+	metas, _ := filepath.Glob(filepath.Join(c.dir, "*"+metaExt))
+	entries := make([]cached, 0, len(metas))
+	for _, metaPath := range metas {
+		if metaPath == keep {
+			continue
+		}
+		key := strings.TrimSuffix(filepath.Base(metaPath), metaExt)
+		metaInfo, err := os.Stat(metaPath)
+		if err != nil {
+			continue
+		}
+		var size int64
+		if bodyInfo, err := os.Stat(filepath.Join(c.dir, key+bodyExt)); err == nil {
+			size = bodyInfo.Size()
+		}
+		entries = append(entries, cached{key: key, size: size, touched: metaInfo.ModTime()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].touched.Before(entries[j].touched)
+	})
+
+	for _, entry := range entries {
+		if c.size <= c.maxSize {
+			break
+		}
+		os.Remove(filepath.Join(c.dir, entry.key+metaExt))
+		os.Remove(filepath.Join(c.dir, entry.key+bodyExt))
+		c.size -= entry.size
+		c.evicted++
+	}
+}
+
+// ensureSized computes the cache size from disk on first use; callers hold mu
+func (c *Cache) ensureSized() {
+	if c.sized {
+		return
+	}
+
+	bodies, _ := filepath.Glob(filepath.Join(c.dir, "*"+bodyExt))
+	c.size = 0
+	for _, bodyPath := range bodies {
+		if info, err := os.Stat(bodyPath); err == nil {
+			c.size += info.Size()
+		}
+	}
+	c.sized = true
+}
+
+// paths returns the metadata and body paths for url
+func (c *Cache) paths(url string) (string, string) {
+	sum := sha256.Sum256([]byte(url))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, key+metaExt), filepath.Join(c.dir, key+bodyExt)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestTransport_RevalidatesWithETag(t *testing.T) {
+	var notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+
+	client, err := New(Config{CacheEnabled: true, CacheDir: t.TempDir()})
+	require.NoError(t, err)
+
+	resp, body := get(t, client, server.URL+"/item/1.json")
+	assert.False(t, IsRevalidated(resp))
+	assert.Equal(t, `{"id":1}`, body)
+
+	resp, body = get(t, client, server.URL+"/item/1.json")
+	assert.True(t, IsRevalidated(resp))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `{"id":1}`, body)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+
+	stats := CacheOf(client).Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, int64(1), stats.Hits)
+}
+
+func TestTransport_SkipsResponsesWithoutValidators(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("If-Modified-Since"))
+		w.Write([]byte("fresh"))
+	}))
+	defer server.Close()
+
+	client, err := New(Config{CacheEnabled: true, CacheDir: t.TempDir()})
+	require.NoError(t, err)
+
+	get(t, client, server.URL)
+	resp, _ := get(t, client, server.URL)
+	assert.False(t, IsRevalidated(resp))
+	assert.Equal(t, 0, CacheOf(client).Stats().Entries)
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), 10)
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Put(CacheEntry{URL: "a", StatusCode: 200}, []byte("aaaa")))
+	require.NoError(t, cache.Put(CacheEntry{URL: "b", StatusCode: 200}, []byte("bbbb")))
+
+	// Make "a" the least recently used entry
+	metaPath, _ := cache.paths("a")
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(metaPath, old, old))
+	require.NoError(t, cache.Put(CacheEntry{URL: "c", StatusCode: 200}, []byte("cccc")))
+
+	_, _, ok := cache.Get("a")
+	assert.False(t, ok)
+	_, body, ok := cache.Get("c")
+	require.True(t, ok)
+	assert.Equal(t, "cccc", string(body))
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(8), stats.Size)
+	assert.Equal(t, int64(1), stats.Evicted)
+
+	// Bodies larger than the whole cache are not stored
+	require.NoError(t, cache.Put(CacheEntry{URL: "big", StatusCode: 200}, []byte(strings.Repeat("x", 11))))
+	_, _, ok = cache.Get("big")
+	assert.False(t, ok)
+}
+
+func TestCache_Clear(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewCache(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, cache.Put(CacheEntry{URL: "a", StatusCode: 200}, []byte("data")))
+
+	require.NoError(t, cache.Clear())
+	_, _, ok := cache.Get("a")
+	assert.False(t, ok)
+
+	reopened, err := NewCache(dir, 0)
+	require.NoError(t, err)
+	assert.Equal(t, CacheStats{Dir: dir}, reopened.Stats())
+}
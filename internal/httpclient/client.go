@@ -0,0 +1,191 @@
+// Package httpclient builds the HTTP clients used by data source clients,
+// adding proxy and TLS settings and an on-disk response cache that
+// revalidates with ETag and Last-Modified headers.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the default request timeout
+	DefaultTimeout = 30 * time.Second
+	// DefaultCacheMaxBytes is the default cache size limit (256MB)
+	DefaultCacheMaxBytes = 256 * 1024 * 1024
+)
+
+// Config holds HTTP client settings
+type Config struct {
+	Timeout       time.Duration
+	Proxy         string // http, https, socks5 or socks5h URL; empty uses the environment
+	CABundle      string // PEM file of extra trusted CA certificates
+	UserAgent     string // Overrides the User-Agent header when set
+	CacheEnabled  bool
+	CacheDir      string
+	CacheMaxBytes int64
+}
+
+// Override holds settings that replace the defaults for a single source;
+// zero values keep the default
+type Override struct {
+	Timeout   time.Duration
+	Proxy     string
+	CABundle  string
+	UserAgent string
+}
+
+// Apply returns config with the override's non-zero settings applied
+func (o Override) Apply(config Config) Config {
+	if o.Timeout > 0 {
+		config.Timeout = o.Timeout
+	}
+	if o.Proxy != "" {
+		config.Proxy = o.Proxy
+	}
+	if o.CABundle != "" {
+		config.CABundle = o.CABundle
+	}
+	if o.UserAgent != "" {
+		config.UserAgent = o.UserAgent
+	}
+	return config
+}
+
+// DefaultConfig returns the default client configuration
+func DefaultConfig() Config {
+	return Config{
+		Timeout:       DefaultTimeout,
+		CacheEnabled:  true,
+		CacheMaxBytes: DefaultCacheMaxBytes,
+	}
+}
+
+var (
+	defaultsMux sync.RWMutex
+	defaults    = DefaultConfig()
+	overrides   = make(map[string]Override)
+)
+
+// SetDefaults sets the configuration used by data sources that build their
+// own clients
+func SetDefaults(config Config) {
+	defaultsMux.Lock()
+	defer defaultsMux.Unlock()
+	defaults = config
+}
+
+// SetSourceOverride sets the settings that replace the defaults for source
+func SetSourceOverride(source string, override Override) {
+	defaultsMux.Lock()
+	defer defaultsMux.Unlock()
+	overrides[source] = override
+}
+
+// Defaults returns the configuration set by SetDefaults
+func Defaults() Config {
+	defaultsMux.RLock()
+	defer defaultsMux.RUnlock()
+	return defaults
+}
+
+// ForSource returns the defaults with any override for source applied
+func ForSource(source string) Config {
+	defaultsMux.RLock()
+	defer defaultsMux.RUnlock()
+	return overrides[source].Apply(defaults)
+}
+
+// New creates an HTTP client from config. Responses are cached when the
+// cache is enabled and a cache directory is set.
+func New(config Config) (*http.Client, error) {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Proxy != "" {
+		proxyURL, err := parseProxy(config.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		base.Proxy = http.ProxyURL(proxyURL)
+	}
+	if config.CABundle != "" {
+		pool, err := loadCABundle(config.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		base.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	var transport http.RoundTripper = base
+	if config.UserAgent != "" {
+		transport = &userAgentTransport{base: transport, userAgent: config.UserAgent}
+	}
+	if config.CacheEnabled && config.CacheDir != "" {
+		cache, err := NewCache(config.CacheDir, config.CacheMaxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open HTTP cache: %w", err)
+		}
+		transport = NewTransport(transport, cache)
+	}
+
+	return &http.Client{
+		Timeout:   config.Timeout,
+		Transport: transport,
+	}, nil
+}
+
+// parseProxy validates a proxy URL
+func parseProxy(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (use http, https, socks5 or socks5h)", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxy)
+	}
+	return proxyURL, nil
+}
+
+// loadCABundle returns the system roots plus the certificates in path
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// userAgentTransport sets the User-Agent header on every request
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
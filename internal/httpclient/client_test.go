@@ -0,0 +1,78 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_UsesProxyAndUserAgent(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		assert.Equal(t, "PubDataHub-test/1.0", r.Header.Get("User-Agent"))
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	client, err := New(Config{Proxy: proxy.URL, UserAgent: "PubDataHub-test/1.0"})
+	require.NoError(t, err)
+
+	resp, body := get(t, client, "http://example.invalid/item/1.json")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "via proxy", body)
+	assert.Equal(t, "http://example.invalid/item/1.json", proxied)
+}
+
+func TestNew_RejectsInvalidProxy(t *testing.T) {
+	_, err := New(Config{Proxy: "ftp://proxy.local:21"})
+	assert.Error(t, err)
+	_, err = New(Config{Proxy: "socks5://"})
+	assert.Error(t, err)
+
+	_, err = New(Config{Proxy: "socks5://127.0.0.1:1080"})
+	assert.NoError(t, err)
+}
+
+func TestNew_TrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	defer server.Close()
+
+	untrusted, err := New(Config{})
+	require.NoError(t, err)
+	_, err = untrusted.Get(server.URL)
+	assert.Error(t, err)
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(bundle, certPEM, 0644))
+
+	trusted, err := New(Config{CABundle: bundle})
+	require.NoError(t, err)
+	_, body := get(t, trusted, server.URL)
+	assert.Equal(t, "secure", body)
+
+	require.NoError(t, os.WriteFile(bundle, []byte("not a certificate"), 0644))
+	_, err = New(Config{CABundle: bundle})
+	assert.Error(t, err)
+}
+
+func TestOverride_Apply(t *testing.T) {
+	base := Config{Timeout: 30 * time.Second, Proxy: "http://global:8080", UserAgent: "global"}
+
+	config := Override{Proxy: "socks5://source:1080", Timeout: time.Minute}.Apply(base)
+	assert.Equal(t, "socks5://source:1080", config.Proxy)
+	assert.Equal(t, time.Minute, config.Timeout)
+	assert.Equal(t, "global", config.UserAgent)
+
+	assert.Equal(t, base, Override{}.Apply(base))
+}
@@ -0,0 +1,372 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultSegments is the number of parallel range requests of a file
+	// download unless set
+	DefaultSegments = 4
+
+	// minSegmentSize keeps small files from being split into tiny requests
+	minSegmentSize = 1 << 20
+
+	// stateSaveInterval is how often the progress of a file download is saved
+	stateSaveInterval = 2 * time.Second
+)
+
+// ErrChecksumMismatch is returned when a downloaded file does not match its
+// expected SHA-256 checksum; the partial download is removed
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// FileDownload describes a large file fetched in parallel range segments
+type FileDownload struct {
+	URL      string
+	Path     string // Destination; the download is written to Path + ".part"
+	SHA256   string // Expected hex checksum, verified when set
+	Segments int    // Parallel range requests; 0 uses DefaultSegments
+}
+
+// FileProgress is called with the bytes downloaded and the file size, which
+// is 0 when the server does not report it
+type FileProgress func(done, total int64)
+
+// segment is a byte range of a file download and how much of it is written
+type segment struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"` // Inclusive
+	Done  int64 `json:"done"`
+}
+
+// downloadState is saved next to the partial file so an interrupted
+// download continues where it stopped
+type downloadState struct {
+	URL      string     `json:"url"`
+	Size     int64      `json:"size"`
+	ETag     string     `json:"etag,omitempty"`
+	Modified string     `json:"last_modified,omitempty"`
+	Segments []*segment `json:"segments"`
+}
+
+func (fd FileDownload) partPath() string  { return fd.Path + ".part" }
+func (fd FileDownload) statePath() string { return fd.Path + ".part.json" }
+
+// DownloadFile fetches fd.URL to fd.Path. When the server accepts range
+// requests and reports the size, the file is split into segments fetched in
+// parallel, and a download cancelled through ctx continues from the saved
+// segments the next time. Otherwise the file is fetched in one request from
+// the start. The checksum is verified before the file is moved into place.
+func DownloadFile(ctx context.Context, client *http.Client, fd FileDownload, progress FileProgress) error {
+	if fd.Segments <= 0 {
+		fd.Segments = DefaultSegments
+	}
+	if progress == nil {
+		progress = func(done, total int64) {}
+	}
+
+	remote, err := probe(ctx, client, fd.URL)
+	if err != nil {
+		return err
+	}
+
+	if remote.Size > 0 && remote.acceptsRanges {
+		state := loadState(fd, remote)
+		if err := downloadSegments(ctx, client, fd, state, progress); err != nil {
+			return err
+		}
+	} else if err := downloadWhole(ctx, client, fd, progress); err != nil {
+		return err
+	}
+
+	if fd.SHA256 != "" {
+		if err := verifyChecksum(fd.partPath(), fd.SHA256); err != nil {
+			os.Remove(fd.partPath())
+			os.Remove(fd.statePath())
+			return err
+		}
+	}
+	if err := os.Rename(fd.partPath(), fd.Path); err != nil {
+		return fmt.Errorf("failed to move download into place: %w", err)
+	}
+	os.Remove(fd.statePath())
+	return nil
+}
+
+// remoteFile is what a probe learned about a file
+type remoteFile struct {
+	downloadState
+	acceptsRanges bool
+}
+
+// probe requests the first byte of the file to learn its size and whether
+// the server serves ranges; HEAD is not relied on as some servers reject it
+func probe(ctx context.Context, client *http.Client, url string) (*remoteFile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid download URL: %w", err)
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	remote := &remoteFile{downloadState: downloadState{
+		URL:      url,
+		ETag:     resp.Header.Get("ETag"),
+		Modified: resp.Header.Get("Last-Modified"),
+	}}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/size
+		contentRange := resp.Header.Get("Content-Range")
+		if i := strings.LastIndex(contentRange, "/"); i >= 0 {
+			if size, err := strconv.ParseInt(contentRange[i+1:], 10, 64); err == nil {
+				remote.Size = size
+				remote.acceptsRanges = true
+			}
+		}
+	case http.StatusOK:
+		remote.Size = resp.ContentLength
+	default:
+		return nil, fmt.Errorf("failed to download %s: HTTP %d", url, resp.StatusCode)
+	}
+	return remote, nil
+}
+
+// loadState returns the saved segments of an interrupted download of the
+// same file, or new segments when there are none or the file changed
+func loadState(fd FileDownload, remote *remoteFile) *downloadState {
+	if data, err := os.ReadFile(fd.statePath()); err == nil {
+		var saved downloadState
+		if json.Unmarshal(data, &saved) == nil && saved.URL == remote.URL && saved.Size == remote.Size &&
+			saved.ETag == remote.ETag && saved.Modified == remote.Modified {
+			if _, err := os.Stat(fd.partPath()); err == nil {
+				return &saved
+			}
+		}
+	}
+
+	state := remote.downloadState
+	count := int64(fd.Segments)
+	if maxCount := (state.Size + minSegmentSize - 1) / minSegmentSize; count > maxCount {
+		count = maxCount
+	}
+	size := (state.Size + count - 1) / count
+	for start := int64(0); start < state.Size; start += size {
+		state.Segments = append(state.Segments, &segment{Start: start, End: min(start+size, state.Size) - 1})
+	}
+	os.Remove(fd.partPath())
+	return &state
+}
+
+// saveState writes the download state, replacing the previous one
+func saveState(fd FileDownload, state *downloadState, mu *sync.Mutex) error {
+	mu.Lock()
+	data, err := json.Marshal(state)
+	mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fd.statePath(), data)
+}
+
+// downloadSegments fetches the unfinished segments in parallel, saving their
+// progress regularly and when the download stops
+func downloadSegments(ctx context.Context, client *http.Client, fd FileDownload, state *downloadState, progress FileProgress) error {
+	file, err := os.OpenFile(fd.partPath(), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open partial download: %w", err)
+	}
+	defer file.Close()
+
+	var mu sync.Mutex
+	var done atomic.Int64
+	for _, seg := range state.Segments {
+		done.Add(seg.Done)
+	}
+	progress(done.Load(), state.Size)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(state.Segments))
+	for _, seg := range state.Segments {
+		if seg.Start+seg.Done > seg.End {
+			continue
+		}
+		wg.Add(1)
+		go func(seg *segment) {
+			defer wg.Done()
+			if err := fetchSegment(ctx, client, fd.URL, file, seg, &mu, &done); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(seg)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	ticker := time.NewTicker(stateSaveInterval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-finished:
+			running = false
+		case <-ticker.C:
+			progress(done.Load(), state.Size)
+			if err := saveState(fd, state, &mu); err != nil {
+				cancel()
+				wg.Wait()
+				return fmt.Errorf("failed to save download state: %w", err)
+			}
+		}
+	}
+	progress(done.Load(), state.Size)
+
+	if err := saveState(fd, state, &mu); err != nil {
+		return fmt.Errorf("failed to save download state: %w", err)
+	}
+	close(errs)
+	if err := <-errs; err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to write download: %w", err)
+	}
+	return nil
+}
+
+// fetchSegment requests the rest of a segment and writes it in place
+func fetchSegment(ctx context.Context, client *http.Client, url string, file *os.File, seg *segment, mu *sync.Mutex, done *atomic.Int64) error {
+	mu.Lock()
+	offset := seg.Start + seg.Done
+	mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, seg.End))
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download bytes %d-%d: %w", offset, seg.End, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("failed to download bytes %d-%d: HTTP %d", offset, seg.End, resp.StatusCode)
+	}
+
+	buf := make([]byte, 256*1024)
+	for offset <= seg.End {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			n = int(min(int64(n), seg.End-offset+1))
+			if _, err := file.WriteAt(buf[:n], offset); err != nil {
+				return fmt.Errorf("failed to write download: %w", err)
+			}
+			offset += int64(n)
+			done.Add(int64(n))
+			mu.Lock()
+			seg.Done = offset - seg.Start
+			mu.Unlock()
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("failed to download bytes %d-%d: %w", offset, seg.End, readErr)
+		}
+	}
+	if offset <= seg.End {
+		return fmt.Errorf("download of bytes %d-%d ended early", offset, seg.End)
+	}
+	return nil
+}
+
+// downloadWhole fetches the file in a single request
+func downloadWhole(ctx context.Context, client *http.Client, fd FileDownload, progress FileProgress) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fd.URL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid download URL: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", fd.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: HTTP %d", fd.URL, resp.StatusCode)
+	}
+
+	file, err := os.Create(fd.partPath())
+	if err != nil {
+		return fmt.Errorf("failed to create partial download: %w", err)
+	}
+	defer file.Close()
+	os.Remove(fd.statePath())
+
+	counter := &countingWriter{total: max(resp.ContentLength, 0), progress: progress}
+	if _, err := io.Copy(io.MultiWriter(file, counter), resp.Body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", fd.URL, err)
+	}
+	progress(counter.done, counter.total)
+	return file.Sync()
+}
+
+// countingWriter reports the bytes written through it at most once a second
+type countingWriter struct {
+	done, total int64
+	reported    time.Time
+	progress    FileProgress
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.done += int64(len(p))
+	if now := time.Now(); now.Sub(cw.reported) >= time.Second {
+		cw.reported = now
+		cw.progress(cw.done, cw.total)
+	}
+	return len(p), nil
+}
+
+// verifyChecksum compares the SHA-256 of the file at path with expected
+func verifyChecksum(path, expected string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to checksum download: %w", err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeServer serves content with range support and records the Range
+// headers it receives
+func rangeServer(t *testing.T, content []byte) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "dump.bin", time.Unix(1700000000, 0), bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), ranges...)
+	}
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownloadFile_Segments(t *testing.T) {
+	content := make([]byte, 3*minSegmentSize+123)
+	rand.New(rand.NewSource(1)).Read(content)
+	server, ranges := rangeServer(t, content)
+
+	path := filepath.Join(t.TempDir(), "dump.bin")
+	var last int64
+	err := DownloadFile(context.Background(), server.Client(), FileDownload{
+		URL: server.URL, Path: path, SHA256: checksum(content), Segments: 3,
+	}, func(done, total int64) {
+		last = done
+		assert.Equal(t, int64(len(content)), total)
+	})
+	require.NoError(t, err)
+
+	downloaded, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+	assert.Equal(t, int64(len(content)), last)
+	assert.Len(t, ranges(), 4) // The probe and 3 segments
+	assert.NoFileExists(t, path+".part")
+	assert.NoFileExists(t, path+".part.json")
+}
+
+func TestDownloadFile_ResumesSavedSegments(t *testing.T) {
+	content := make([]byte, 2*minSegmentSize)
+	rand.New(rand.NewSource(2)).Read(content)
+	server, ranges := rangeServer(t, content)
+
+	// An interrupted download with the first half of each segment written
+	path := filepath.Join(t.TempDir(), "dump.bin")
+	half := int64(minSegmentSize / 2)
+	part := make([]byte, len(content))
+	copy(part[:half], content[:half])
+	copy(part[minSegmentSize:minSegmentSize+half], content[minSegmentSize:minSegmentSize+half])
+	require.NoError(t, os.WriteFile(path+".part", part, 0o644))
+	state := downloadState{
+		URL:      server.URL,
+		Size:     int64(len(content)),
+		Modified: time.Unix(1700000000, 0).UTC().Format(http.TimeFormat),
+		Segments: []*segment{
+			{Start: 0, End: minSegmentSize - 1, Done: half},
+			{Start: minSegmentSize, End: 2*minSegmentSize - 1, Done: half},
+		},
+	}
+	data, err := json.Marshal(state)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path+".part.json", data, 0o644))
+
+	require.NoError(t, DownloadFile(context.Background(), server.Client(), FileDownload{URL: server.URL, Path: path, SHA256: checksum(content)}, nil))
+
+	downloaded, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+	assert.ElementsMatch(t, []string{"bytes=0-0", "bytes=524288-1048575", "bytes=1572864-2097151"}, ranges())
+}
+
+func TestDownloadFile_ChecksumMismatch(t *testing.T) {
+	server, _ := rangeServer(t, []byte("not the expected dump"))
+
+	path := filepath.Join(t.TempDir(), "dump.bin")
+	err := DownloadFile(context.Background(), server.Client(), FileDownload{URL: server.URL, Path: path, SHA256: checksum([]byte("dump"))}, nil)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.NoFileExists(t, path)
+	assert.NoFileExists(t, path+".part")
+}
+
+func TestDownloadFile_WithoutRanges(t *testing.T) {
+	content := strings.Repeat("item\n", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "dump.txt")
+	require.NoError(t, DownloadFile(context.Background(), server.Client(), FileDownload{URL: server.URL, Path: path}, nil))
+	downloaded, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(downloaded))
+}
@@ -0,0 +1,122 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// revalidatedHeader marks responses served from the cache after a 304
+const revalidatedHeader = "X-Pubdatahub-Cache"
+
+// Transport is an http.RoundTripper that caches GET responses on disk and
+// revalidates them with conditional requests
+type Transport struct {
+	base  http.RoundTripper
+	cache *Cache
+}
+
+// NewTransport wraps base with the given cache
+func NewTransport(base http.RoundTripper, cache *Cache) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base, cache: cache}
+}
+
+// Cache returns the transport's cache
+func (t *Transport) Cache() *Cache {
+	return t.cache
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	url := req.URL.String()
+	entry, body, cached := t.cache.Get(url)
+	if cached {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return cachedResponse(req, entry, body), nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if resp.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		return resp, nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	// A cache write failure should not fail the request
+	t.cache.Put(CacheEntry{
+		URL:          url,
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		ETag:         etag,
+		LastModified: lastModified,
+	}, data)
+
+	return resp, nil
+}
+
+// cachedResponse builds a response from a cache entry
+func cachedResponse(req *http.Request, entry *CacheEntry, body []byte) *http.Response {
+	header := entry.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set(revalidatedHeader, "revalidated")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// IsRevalidated reports whether resp was served from the cache because the
+// server said the resource had not changed
+func IsRevalidated(resp *http.Response) bool {
+	return resp != nil && resp.Header.Get(revalidatedHeader) == "revalidated"
+}
+
+// CacheOf returns the cache used by client, or nil if it does not cache
+func CacheOf(client *http.Client) *Cache {
+	if client == nil {
+		return nil
+	}
+	if transport, ok := client.Transport.(*Transport); ok {
+		return transport.cache
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+// Package instance coordinates pubdatahub processes that share a storage
+// path. The first process takes a lock file holding its PID and a heartbeat;
+// later processes see the live lock and run read-only.
+package instance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LockFile is the name of the lock file in the storage directory
+const LockFile = "pubdatahub.lock"
+
+// Heartbeat defaults; a lock whose heartbeat is older than StaleAfter is
+// considered abandoned
+const (
+	DefaultHeartbeatInterval = 5 * time.Second
+	StaleAfter               = 30 * time.Second
+)
+
+// Info describes the process holding the lock
+type Info struct {
+	PID         int       `json:"pid"`
+	Hostname    string    `json:"hostname"`
+	Command     string    `json:"command"`
+	StartedAt   time.Time `json:"started_at"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+}
+
+// String returns a short description of the holder
+func (i Info) String() string {
+	return fmt.Sprintf("pid %d on %s (%s, started %s)", i.PID, i.Hostname, i.Command, i.StartedAt.Format(time.RFC3339))
+}
+
+// Stale reports whether the holder has stopped heartbeating or, on this
+// host, is no longer running
+func (i Info) Stale() bool {
+	if time.Since(i.HeartbeatAt) > StaleAfter {
+		return true
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname == i.Hostname {
+		return !processAlive(i.PID)
+	}
+	return false
+}
+
+// LockedError is returned by Acquire when another live instance holds the lock
+type LockedError struct {
+	Path   string
+	Holder Info
+}
+
+// Error implements error
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("storage is in use by another instance: %s", e.Holder)
+}
+
+// Lock is a held instance lock kept fresh by a heartbeat
+type Lock struct {
+	path     string
+	info     Info
+	mu       sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+	released bool
+}
+
+// LockPath returns the lock file path for a storage directory
+func LockPath(storagePath string) string {
+	return filepath.Join(storagePath, LockFile)
+}
+
+// Acquire takes the instance lock for a storage directory, replacing a stale
+// lock left by a crashed process. It returns a *LockedError when another live
+// instance holds the lock.
+func Acquire(storagePath, command string) (*Lock, error) {
+	return acquire(storagePath, command, DefaultHeartbeatInterval)
+}
+
+// acquire takes the lock with the given heartbeat interval
+func acquire(storagePath, command string, interval time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(storagePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	lock := &Lock{
+		path: LockPath(storagePath),
+		info: Info{
+			PID:         os.Getpid(),
+			Hostname:    hostname,
+			Command:     command,
+			StartedAt:   now,
+			HeartbeatAt: now,
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	// A second attempt follows removal of a stale lock
+	for attempt := 0; attempt < 2; attempt++ {
+		err := lock.create()
+		if err == nil {
+			go lock.heartbeat(interval)
+			return lock, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		holder, err := Read(storagePath)
+		if err != nil {
+			return nil, err
+		}
+		if holder != nil && !holder.Stale() {
+			return nil, &LockedError{Path: lock.path, Holder: *holder}
+		}
+		if err := os.Remove(lock.path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale lock: %w", err)
+		}
+	}
+	return nil, fmt.Errorf("failed to acquire lock %s", lock.path)
+}
+
+// create writes the lock file, failing if it already exists
+func (l *Lock) create() error {
+	data, err := json.MarshalIndent(l.info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lock: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return err
+		}
+		return fmt.Errorf("failed to create lock: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(l.path)
+		return fmt.Errorf("failed to write lock: %w", err)
+	}
+	return file.Close()
+}
+
+// heartbeat refreshes the lock until it is released
+func (l *Lock) heartbeat(interval time.Duration) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			// Stop if the lock was force-unlocked or taken over
+			holder, err := Read(filepath.Dir(l.path))
+			if err != nil || holder == nil || !l.owns(*holder) {
+				return
+			}
+
+			l.mu.Lock()
+			l.info.HeartbeatAt = time.Now()
+			l.write()
+			l.mu.Unlock()
+		}
+	}
+}
+
+// write replaces the lock file with the current info; callers hold mu
+func (l *Lock) write() {
+	data, err := json.MarshalIndent(l.info, "", "  ")
+	if err != nil {
+		return
+	}
+	tmpPath := l.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return
+	}
+	os.Rename(tmpPath, l.path)
+}
+
+// owns reports whether holder is this lock
+func (l *Lock) owns(holder Info) bool {
+	return holder.PID == l.info.PID && holder.Hostname == l.info.Hostname && holder.StartedAt.Equal(l.info.StartedAt)
+}
+
+// Info returns the lock holder information of this process
+func (l *Lock) Info() Info {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.info
+}
+
+// Release stops the heartbeat and removes the lock file if this process
+// still owns it
+func (l *Lock) Release() error {
+	l.mu.Lock()
+	if l.released {
+		l.mu.Unlock()
+		return nil
+	}
+	l.released = true
+	l.mu.Unlock()
+
+	close(l.stop)
+	<-l.done
+
+	// Leave the file alone if it was force-unlocked and taken by another process
+	holder, err := Read(filepath.Dir(l.path))
+	if err != nil || holder == nil || !l.owns(*holder) {
+		return err
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock: %w", err)
+	}
+	return nil
+}
+
+// Read returns the current lock holder, or nil when the storage is unlocked
+func Read(storagePath string) (*Info, error) {
+	data, err := os.ReadFile(LockPath(storagePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read lock: %w", err)
+	}
+
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		// A lock being written is briefly empty; a corrupt lock goes stale
+		// once it is older than StaleAfter
+		info = Info{}
+		if stat, statErr := os.Stat(LockPath(storagePath)); statErr == nil {
+			info.HeartbeatAt = stat.ModTime()
+		}
+	}
+	return &info, nil
+}
+
+// ForceUnlock removes the lock file regardless of its holder and returns the
+// previous holder, if any
+func ForceUnlock(storagePath string) (*Info, error) {
+	holder, err := Read(storagePath)
+	if err != nil {
+		return nil, err
+	}
+	if holder == nil {
+		return nil, nil
+	}
+	if err := os.Remove(LockPath(storagePath)); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove lock: %w", err)
+	}
+	return holder, nil
+}
@@ -0,0 +1,116 @@
+package instance
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeLock writes a lock file as if held by another process
+func writeLock(t *testing.T, dir string, info Info) {
+	data, err := json.Marshal(info)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(LockPath(dir), data, 0644))
+}
+
+func TestAcquireAndRelease(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := Acquire(dir, "shell")
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), lock.Info().PID)
+
+	holder, err := Read(dir)
+	require.NoError(t, err)
+	require.NotNil(t, holder)
+	assert.Equal(t, "shell", holder.Command)
+
+	// A second instance sees the live lock
+	_, err = Acquire(dir, "serve")
+	var locked *LockedError
+	require.True(t, errors.As(err, &locked))
+	assert.Equal(t, os.Getpid(), locked.Holder.PID)
+
+	require.NoError(t, lock.Release())
+	require.NoError(t, lock.Release())
+	holder, err = Read(dir)
+	require.NoError(t, err)
+	assert.Nil(t, holder)
+}
+
+func TestAcquireReplacesStaleLock(t *testing.T) {
+	hostname, _ := os.Hostname()
+
+	tests := []struct {
+		name string
+		info Info
+	}{
+		{
+			name: "old heartbeat",
+			info: Info{PID: os.Getpid(), Hostname: "elsewhere", HeartbeatAt: time.Now().Add(-time.Hour)},
+		},
+		{
+			name: "dead process on this host",
+			info: Info{PID: 1 << 30, Hostname: hostname, HeartbeatAt: time.Now()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeLock(t, dir, tt.info)
+
+			lock, err := Acquire(dir, "shell")
+			require.NoError(t, err)
+			defer lock.Release()
+
+			holder, err := Read(dir)
+			require.NoError(t, err)
+			assert.Equal(t, os.Getpid(), holder.PID)
+		})
+	}
+}
+
+func TestAcquireKeepsLiveRemoteLock(t *testing.T) {
+	dir := t.TempDir()
+	writeLock(t, dir, Info{PID: 42, Hostname: "elsewhere", HeartbeatAt: time.Now()})
+
+	_, err := Acquire(dir, "shell")
+	var locked *LockedError
+	require.True(t, errors.As(err, &locked))
+	assert.Equal(t, "elsewhere", locked.Holder.Hostname)
+}
+
+func TestHeartbeatAndForceUnlock(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := acquire(dir, "shell", 10*time.Millisecond)
+	require.NoError(t, err)
+	started := lock.Info().HeartbeatAt
+
+	require.Eventually(t, func() bool {
+		holder, err := Read(dir)
+		return err == nil && holder != nil && holder.HeartbeatAt.After(started)
+	}, time.Second, 5*time.Millisecond)
+
+	holder, err := ForceUnlock(dir)
+	require.NoError(t, err)
+	require.NotNil(t, holder)
+	assert.Equal(t, os.Getpid(), holder.PID)
+
+	// The heartbeat must not recreate a force-unlocked lock
+	time.Sleep(50 * time.Millisecond)
+	current, err := Read(dir)
+	require.NoError(t, err)
+	assert.Nil(t, current)
+	require.NoError(t, lock.Release())
+
+	holder, err = ForceUnlock(dir)
+	require.NoError(t, err)
+	assert.Nil(t, holder)
+}
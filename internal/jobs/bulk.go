@@ -0,0 +1,117 @@
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BulkResult reports the outcome of a bulk job operation
+type BulkResult struct {
+	Matched  int              `json:"matched"`
+	Affected []string         `json:"affected"`
+	Skipped  []string         `json:"skipped,omitempty"`
+	Failed   map[string]error `json:"-"`
+	TrashID  string           `json:"trash_id,omitempty"` // Trash entry holding removed jobs
+}
+
+// Err summarizes per-job failures, or returns nil if every job succeeded
+func (r *BulkResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(r.Failed))
+	for id := range r.Failed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	messages := make([]string, 0, len(ids))
+	for _, id := range ids {
+		messages = append(messages, fmt.Sprintf("%s: %v", id, r.Failed[id]))
+	}
+	return fmt.Errorf("%d jobs failed: %s", len(ids), strings.Join(messages, "; "))
+}
+
+// CancelMatching cancels every unfinished job matching the filter
+func (m *Manager) CancelMatching(filter JobFilter) (*BulkResult, error) {
+	return m.applyMatching(filter, func(status *JobStatus) (bool, error) {
+		if status.IsFinished() {
+			return false, nil
+		}
+		return true, m.CancelJob(status.ID)
+	})
+}
+
+// RetryMatching requeues and restarts every failed job matching the filter
+// that has retries left
+func (m *Manager) RetryMatching(filter JobFilter) (*BulkResult, error) {
+	return m.applyMatching(filter, func(status *JobStatus) (bool, error) {
+		if status.State != JobStateFailed {
+			return false, nil
+		}
+		if err := m.RetryJob(status.ID); err != nil {
+			return true, err
+		}
+		return true, m.StartJob(status.ID)
+	})
+}
+
+// CleanupMatching deletes every finished job matching the filter
+func (m *Manager) CleanupMatching(filter JobFilter) (*BulkResult, error) {
+	return m.applyMatching(filter, func(status *JobStatus) (bool, error) {
+		if !status.IsFinished() {
+			return false, nil
+		}
+		return true, m.deleteJob(status.ID)
+	})
+}
+
+// deleteJob removes a job from memory and the database
+func (m *Manager) deleteJob(id string) error {
+	m.jobsMux.Lock()
+	delete(m.jobs, id)
+	delete(m.estimators, id)
+	m.jobsMux.Unlock()
+
+	if err := m.persistence.DeleteJob(id); err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+	return nil
+}
+
+// applyMatching runs action on every job matching the filter. The action
+// reports whether the job applied to it; jobs it does not apply to are skipped.
+func (m *Manager) applyMatching(filter JobFilter, action func(status *JobStatus) (bool, error)) (*BulkResult, error) {
+	matched, err := m.ListJobs(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	result := &BulkResult{
+		Matched: len(matched),
+		Failed:  make(map[string]error),
+	}
+
+	for _, listed := range matched {
+		// GetJob loads jobs from earlier runs into memory so they can be acted on
+		status, err := m.GetJob(listed.ID)
+		if err != nil {
+			result.Failed[listed.ID] = err
+			continue
+		}
+
+		applies, err := action(status)
+		switch {
+		case err != nil:
+			result.Failed[status.ID] = err
+		case applies:
+			result.Affected = append(result.Affected, status.ID)
+		default:
+			result.Skipped = append(result.Skipped, status.ID)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/trash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJobFilter(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	filter, ids, err := ParseJobFilter([]string{"--source", "hackernews", "--state=queued,paused"}, now)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+	assert.Equal(t, "hackernews", filter.Source)
+	assert.Equal(t, []JobState{JobStateQueued, JobStatePaused}, filter.States)
+
+	filter, _, err = ParseJobFilter([]string{"--failed", "--since", "1h"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []JobState{JobStateFailed}, filter.States)
+	require.NotNil(t, filter.CreatedAfter)
+	assert.Equal(t, now.Add(-time.Hour), *filter.CreatedAfter)
+
+	filter, _, err = ParseJobFilter([]string{"--older-than", "7d"}, now)
+	require.NoError(t, err)
+	require.NotNil(t, filter.CreatedBefore)
+	assert.Equal(t, now.AddDate(0, 0, -7), *filter.CreatedBefore)
+
+	filter, ids, err = ParseJobFilter([]string{"job-1", "job-2"}, now)
+	require.NoError(t, err)
+	assert.True(t, filter.IsEmpty())
+	assert.Equal(t, []string{"job-1", "job-2"}, ids)
+
+	_, _, err = ParseJobFilter([]string{"--state", "bogus"}, now)
+	assert.Error(t, err)
+	_, _, err = ParseJobFilter([]string{"--since"}, now)
+	assert.Error(t, err)
+	_, _, err = ParseJobFilter([]string{"--colour", "red"}, now)
+	assert.Error(t, err)
+}
+
+func TestManager_BulkOperations(t *testing.T) {
+	manager, _ := newBlockingTestManager(t, func(config *ManagerConfig) {})
+
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	jobs := []*JobStatus{
+		{ID: "hn-queued", Type: "blocking", State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{"source_name": "hackernews"}},
+		{ID: "other-queued", Type: "blocking", State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{"source_name": "other"}},
+		{ID: "old-done", Type: "blocking", State: JobStateCompleted, StartTime: old, Metadata: JobMetadata{}},
+		{ID: "new-done", Type: "blocking", State: JobStateCompleted, StartTime: time.Now(), Metadata: JobMetadata{}},
+	}
+	for _, status := range jobs {
+		require.NoError(t, manager.persistence.SaveJob(status))
+	}
+
+	result, err := manager.CancelMatching(JobFilter{Source: "hackernews", States: []JobState{JobStateQueued}})
+	require.NoError(t, err)
+	require.NoError(t, result.Err())
+	assert.Equal(t, []string{"hn-queued"}, result.Affected)
+
+	status, err := manager.GetJob("hn-queued")
+	require.NoError(t, err)
+	assert.Equal(t, JobStateCancelled, status.State)
+	status, err = manager.GetJob("other-queued")
+	require.NoError(t, err)
+	assert.Equal(t, JobStateQueued, status.State)
+
+	before := time.Now().Add(-7 * 24 * time.Hour)
+	result, err = manager.CleanupMatching(JobFilter{CreatedBefore: &before})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old-done"}, result.Affected)
+
+	remaining, err := manager.ListJobs(JobFilter{States: []JobState{JobStateCompleted}})
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "new-done", remaining[0].ID)
+}
+
+func TestManager_TrashMatching(t *testing.T) {
+	manager, _ := newBlockingTestManager(t, func(config *ManagerConfig) {})
+	bin, err := trash.New(trash.Dir(t.TempDir()), time.Hour)
+	require.NoError(t, err)
+	manager.SetTrash(bin)
+
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	require.NoError(t, manager.persistence.SaveJob(&JobStatus{ID: "old-done", Type: "blocking", State: JobStateCompleted, StartTime: old, Metadata: JobMetadata{}}))
+	require.NoError(t, manager.persistence.SaveJob(&JobStatus{ID: "old-queued", Type: "blocking", State: JobStateQueued, StartTime: old, Metadata: JobMetadata{}}))
+	require.NoError(t, manager.persistence.SaveEvent(JobEvent{JobID: "old-done", EventType: EventJobCompleted, Timestamp: old, Message: "done"}))
+
+	before := time.Now().Add(-7 * 24 * time.Hour)
+	result, err := manager.TrashMatching(JobFilter{CreatedBefore: &before})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old-done"}, result.Affected)
+	assert.Equal(t, []string{"old-queued"}, result.Skipped)
+	require.NotEmpty(t, result.TrashID)
+
+	removed, err := manager.persistence.LoadJob("old-done")
+	require.NoError(t, err)
+	assert.Nil(t, removed)
+
+	entry, err := bin.Restore("")
+	require.NoError(t, err)
+	assert.Equal(t, result.TrashID, entry.ID)
+
+	status, err := manager.persistence.LoadJob("old-done")
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, JobStateCompleted, status.State)
+	events, err := manager.persistence.LoadEvents("old-done")
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "done", events[0].Message)
+}
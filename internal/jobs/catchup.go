@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// CatchUpPolicy is what a scheduled job does about times it missed because
+// the application was not running, or the machine was asleep
+type CatchUpPolicy string
+
+const (
+	CatchUpSkip CatchUpPolicy = "skip" // Record the missed times and wait for the next one
+	CatchUpOnce CatchUpPolicy = "once" // Run once immediately for all missed times
+	CatchUpAll  CatchUpPolicy = "all"  // Run for each missed time, up to the job's cap
+)
+
+// DefaultMaxCatchUp caps the runs of the "all" policy
+const DefaultMaxCatchUp = 10
+
+const (
+	// missedGrace is how late a run may start before its time counts as
+	// missed; the scheduler checks every second
+	missedGrace = time.Minute
+
+	// maxMissedScan bounds the missed times counted for one job, such as a
+	// schedule with seconds after a long downtime
+	maxMissedScan = 100000
+)
+
+// ParseCatchUpPolicy validates a catch-up policy; empty is skip
+func ParseCatchUpPolicy(name string) (CatchUpPolicy, error) {
+	switch policy := CatchUpPolicy(strings.ToLower(name)); policy {
+	case "":
+		return CatchUpSkip, nil
+	case CatchUpSkip, CatchUpOnce, CatchUpAll:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid catch-up policy %q (supported: skip, once, all)", name)
+}
+
+// ScheduleOutcome is what happened at a scheduled time
+type ScheduleOutcome string
+
+const (
+	ScheduleRan      ScheduleOutcome = "run"
+	ScheduleCaughtUp ScheduleOutcome = "caught_up" // A missed time run late by the catch-up policy
+	ScheduleMissed   ScheduleOutcome = "missed"
+	ScheduleSkipped  ScheduleOutcome = "skipped" // Dependencies were not satisfied
+	ScheduleBlocked  ScheduleOutcome = "blocked" // The previous run was still active under the forbid policy
+	ScheduleFailed   ScheduleOutcome = "failed"  // The job could not be submitted
+)
+
+// ScheduleRun is an entry of a scheduled job's history: a run at a
+// scheduled time, or a range of missed times that did not run
+type ScheduleRun struct {
+	ScheduleID   string          `json:"schedule_id"`
+	ScheduledFor time.Time       `json:"scheduled_for"`
+	Until        time.Time       `json:"until,omitempty"` // Last time of a missed range
+	Outcome      ScheduleOutcome `json:"outcome"`
+	Missed       int             `json:"missed,omitempty"` // Times in a missed range
+	JobID        string          `json:"job_id,omitempty"`
+	RecordedAt   time.Time       `json:"recorded_at"`
+}
+
+// dueRun is a scheduled time to run now
+type dueRun struct {
+	job          *ScheduledJob
+	scheduledFor time.Time
+	outcome      ScheduleOutcome
+}
+
+// planDueRuns splits the times of a job due by now into runs and a range of
+// missed times, following the job's catch-up policy. Times more than
+// missedGrace ago were missed; of those, "once" runs the latest unless a
+// time is due on time anyway, and "all" runs the latest up to the cap.
+func planDueRuns(job *ScheduledJob, schedule *CronSchedule, now time.Time) ([]dueRun, *ScheduleRun) {
+	var late, onTime []time.Time
+	for t := job.NextRun; !t.IsZero() && !t.After(now) && len(late)+len(onTime) < maxMissedScan; t = schedule.Next(t) {
+		if now.Sub(t) > missedGrace {
+			late = append(late, t)
+		} else {
+			onTime = append(onTime, t)
+		}
+	}
+
+	catchUp := 0
+	switch job.CatchUp {
+	case CatchUpOnce:
+		if len(onTime) == 0 {
+			catchUp = min(len(late), 1)
+		}
+	case CatchUpAll:
+		limit := job.MaxCatchUp
+		if limit <= 0 {
+			limit = DefaultMaxCatchUp
+		}
+		catchUp = min(len(late), limit)
+	}
+
+	var runs []dueRun
+	for _, t := range late[len(late)-catchUp:] {
+		runs = append(runs, dueRun{job: job, scheduledFor: t, outcome: ScheduleCaughtUp})
+	}
+	for _, t := range onTime {
+		runs = append(runs, dueRun{job: job, scheduledFor: t, outcome: ScheduleRan})
+	}
+
+	skipped := late[:len(late)-catchUp]
+	if len(skipped) == 0 {
+		return runs, nil
+	}
+	return runs, &ScheduleRun{
+		ScheduleID:   job.ID,
+		ScheduledFor: skipped[0],
+		Until:        skipped[len(skipped)-1],
+		Outcome:      ScheduleMissed,
+		Missed:       len(skipped),
+		RecordedAt:   now,
+	}
+}
+
+// persistence returns the job database the scheduler records its history
+// in, or nil without one
+func (js *JobScheduler) persistence() *JobPersistence {
+	if js.manager == nil {
+		return nil
+	}
+	return js.manager.persistence
+}
+
+// recordRun adds an entry to a scheduled job's history
+func (js *JobScheduler) recordRun(run ScheduleRun) {
+	if persistence := js.persistence(); persistence != nil {
+		if err := persistence.SaveScheduleRun(run); err != nil {
+			log.Logger.Warnf("Failed to record run of scheduled job '%s': %v", run.ScheduleID, err)
+		}
+	}
+}
+
+// resumeMissed moves the next run of a job back to the first time after the
+// last one recorded in its history, so times missed while the application
+// was not running are handled by its catch-up policy
+func (js *JobScheduler) resumeMissed(job *ScheduledJob, schedule *CronSchedule) {
+	persistence := js.persistence()
+	if persistence == nil {
+		return
+	}
+	last, ok, err := persistence.LastScheduledTime(job.ID)
+	if err != nil {
+		log.Logger.Warnf("Failed to read history of scheduled job '%s': %v", job.ID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	if next := schedule.Next(last); !next.IsZero() && next.Before(job.NextRun) {
+		job.NextRun = next
+	}
+}
+
+// History returns the history of a scheduled job, newest first: its runs,
+// catch-up runs and missed times. A positive limit returns only that many
+// entries.
+func (js *JobScheduler) History(scheduleID string, limit int) ([]ScheduleRun, error) {
+	persistence := js.persistence()
+	if persistence == nil {
+		return nil, nil
+	}
+	return persistence.LoadScheduleRuns(scheduleID, limit)
+}
@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCatchUpPolicy(t *testing.T) {
+	for name, want := range map[string]CatchUpPolicy{"": CatchUpSkip, "skip": CatchUpSkip, "ONCE": CatchUpOnce, "all": CatchUpAll} {
+		policy, err := ParseCatchUpPolicy(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, policy, name)
+	}
+	_, err := ParseCatchUpPolicy("sometimes")
+	assert.Error(t, err)
+}
+
+func TestPlanDueRuns(t *testing.T) {
+	schedule, err := ParseCronExpression("@hourly", "UTC")
+	require.NoError(t, err)
+	// Down from before 01:00 until 05:00:30, so 01:00 to 04:00 were missed
+	// and 05:00 is on time
+	now := time.Date(2024, 5, 1, 5, 0, 30, 0, time.UTC)
+	first := time.Date(2024, 5, 1, 1, 0, 0, 0, time.UTC)
+	scheduledFor := func(runs []dueRun) []int {
+		var hours []int
+		for _, run := range runs {
+			hours = append(hours, run.scheduledFor.Hour())
+		}
+		return hours
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		runs, missed := planDueRuns(&ScheduledJob{ID: "s", NextRun: first, CatchUp: CatchUpSkip}, schedule, now)
+		assert.Equal(t, []int{5}, scheduledFor(runs))
+		require.NotNil(t, missed)
+		assert.Equal(t, 4, missed.Missed)
+		assert.Equal(t, first, missed.ScheduledFor)
+		assert.Equal(t, 4, missed.Until.Hour())
+	})
+
+	t.Run("once", func(t *testing.T) {
+		late := now.Add(10 * time.Minute)
+		runs, missed := planDueRuns(&ScheduledJob{ID: "o", NextRun: first, CatchUp: CatchUpOnce}, schedule, late)
+		assert.Equal(t, []int{5}, scheduledFor(runs))
+		assert.Equal(t, ScheduleCaughtUp, runs[0].outcome)
+		require.NotNil(t, missed)
+		assert.Equal(t, 4, missed.Missed)
+
+		// A time due on time makes up for the missed ones
+		runs, missed = planDueRuns(&ScheduledJob{ID: "o", NextRun: first, CatchUp: CatchUpOnce}, schedule, now)
+		assert.Equal(t, []int{5}, scheduledFor(runs))
+		assert.Equal(t, ScheduleRan, runs[0].outcome)
+		assert.Equal(t, 4, missed.Missed)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		runs, missed := planDueRuns(&ScheduledJob{ID: "a", NextRun: first, CatchUp: CatchUpAll, MaxCatchUp: 3}, schedule, now)
+		assert.Equal(t, []int{2, 3, 4, 5}, scheduledFor(runs))
+		require.NotNil(t, missed)
+		assert.Equal(t, 1, missed.Missed)
+
+		runs, missed = planDueRuns(&ScheduledJob{ID: "a", NextRun: first, CatchUp: CatchUpAll}, schedule, now)
+		assert.Equal(t, []int{1, 2, 3, 4, 5}, scheduledFor(runs))
+		assert.Nil(t, missed)
+	})
+
+	t.Run("on time", func(t *testing.T) {
+		runs, missed := planDueRuns(&ScheduledJob{ID: "t", NextRun: now.Add(-30 * time.Second), CatchUp: CatchUpSkip}, schedule, now)
+		assert.Len(t, runs, 1)
+		assert.Nil(t, missed)
+	})
+}
+
+func TestJobScheduler_MissedRunsAfterRestart(t *testing.T) {
+	log.InitLogger(false)
+	manager, err := NewManager(t.TempDir(), DefaultManagerConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.persistence.Close() })
+	scheduler := NewJobScheduler(manager)
+
+	// The last run was three hours ago; the schedule's minute is half an
+	// hour from now, so all three runs since were missed
+	now := time.Now()
+	last := now.Add(-3 * time.Hour)
+	expr := fmt.Sprintf("%d * * * *", (now.Minute()+30)%60)
+	require.NoError(t, manager.persistence.SaveScheduleRun(ScheduleRun{
+		ScheduleID: "hourly", ScheduledFor: last, Outcome: ScheduleRan, JobID: "hourly_1", RecordedAt: last,
+	}))
+
+	job := &ScheduledJob{ID: "hourly", Name: "hourly", JobType: "sync", Schedule: expr, Enabled: true}
+	require.NoError(t, scheduler.ScheduleJob(job))
+	assert.Equal(t, CatchUpSkip, job.CatchUp)
+	schedule, err := ParseCronExpression(expr, "")
+	require.NoError(t, err)
+	firstMissed := schedule.Next(last)
+	assert.True(t, job.NextRun.Equal(firstMissed), "the first missed time is due")
+
+	scheduler.checkAndRunJobs()
+	assert.True(t, job.NextRun.After(now))
+
+	history, err := scheduler.History("hourly", 0)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, ScheduleMissed, history[0].Outcome)
+	assert.Equal(t, 3, history[0].Missed)
+	assert.True(t, history[0].ScheduledFor.Equal(firstMissed))
+	assert.True(t, history[0].Until.Equal(firstMissed.Add(2*time.Hour)))
+	assert.Equal(t, ScheduleRan, history[1].Outcome)
+	assert.Equal(t, "hourly_1", history[1].JobID)
+
+	lastTime, ok, err := manager.persistence.LastScheduledTime("hourly")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, lastTime.Equal(firstMissed.Add(2*time.Hour)))
+}
+
+func TestJobScheduler_ConfiguredCatchUp(t *testing.T) {
+	log.InitLogger(false)
+	SetSchedulePolicies(SchedulePolicy{CatchUp: CatchUpOnce}, map[string]SchedulePolicy{"sync-x": {CatchUp: CatchUpAll, MaxCatchUp: 5}})
+	t.Cleanup(func() { SetSchedulePolicies(SchedulePolicy{CatchUp: CatchUpSkip, Concurrency: ConcurrencyForbid}, nil) })
+	scheduler := NewJobScheduler(nil)
+
+	sync := &ScheduledJob{ID: "sync-x", Schedule: "@daily"}
+	require.NoError(t, scheduler.ScheduleJob(sync))
+	assert.Equal(t, CatchUpAll, sync.CatchUp)
+	assert.Equal(t, 5, sync.MaxCatchUp)
+
+	other := &ScheduledJob{ID: "other", Schedule: "@daily"}
+	require.NoError(t, scheduler.ScheduleJob(other))
+	assert.Equal(t, CatchUpOnce, other.CatchUp)
+
+	own := &ScheduledJob{ID: "sync-x", Schedule: "@daily", CatchUp: CatchUpSkip}
+	require.NoError(t, scheduler.ScheduleJob(own))
+	assert.Equal(t, CatchUpSkip, own.CatchUp)
+
+	assert.Error(t, scheduler.ScheduleJob(&ScheduledJob{ID: "bad", Schedule: "@daily", CatchUp: "never"}))
+}
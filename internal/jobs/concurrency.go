@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// ConcurrencyPolicy is what a scheduled job does when its time comes while
+// the job of an earlier time is still queued, running or paused
+type ConcurrencyPolicy string
+
+const (
+	ConcurrencyForbid  ConcurrencyPolicy = "forbid"  // Skip the new run
+	ConcurrencyAllow   ConcurrencyPolicy = "allow"   // Run both
+	ConcurrencyReplace ConcurrencyPolicy = "replace" // Cancel the earlier run and start the new one
+)
+
+// ScheduledByKey is the metadata key naming the scheduled job that
+// submitted a job
+const ScheduledByKey = "scheduled_by"
+
+// jobPollInterval is how often the scheduler checks whether a job it waits
+// for has finished
+var jobPollInterval = time.Second
+
+// ParseConcurrencyPolicy validates a concurrency policy; empty is forbid
+func ParseConcurrencyPolicy(name string) (ConcurrencyPolicy, error) {
+	switch policy := ConcurrencyPolicy(strings.ToLower(name)); policy {
+	case "":
+		return ConcurrencyForbid, nil
+	case ConcurrencyForbid, ConcurrencyAllow, ConcurrencyReplace:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid concurrency policy %q (supported: forbid, allow, replace)", name)
+}
+
+// activeScheduledJobs returns the IDs of the unfinished jobs submitted by a
+// scheduled job
+func (m *Manager) activeScheduledJobs(scheduleID string) []string {
+	m.jobsMux.RLock()
+	defer m.jobsMux.RUnlock()
+
+	var active []string
+	for id, status := range m.jobs {
+		if status.IsFinished() {
+			continue
+		}
+		if scheduledBy, _ := status.Metadata[ScheduledByKey].(string); scheduledBy == scheduleID {
+			active = append(active, id)
+		}
+	}
+	return active
+}
+
+// admitRun applies the job's concurrency policy to its active jobs before a
+// run is submitted, reporting whether the run may start
+func (js *JobScheduler) admitRun(run dueRun) bool {
+	active := js.manager.activeScheduledJobs(run.job.ID)
+	if len(active) == 0 {
+		return true
+	}
+
+	switch run.job.Concurrency {
+	case ConcurrencyAllow:
+		return true
+	case ConcurrencyReplace:
+		for _, id := range active {
+			if err := js.manager.CancelJob(id); err != nil {
+				log.Logger.Warnf("Failed to cancel job %s replaced by scheduled job '%s': %v", id, run.job.ID, err)
+				continue
+			}
+			log.Logger.Infof("Cancelled job %s, replaced by a new run of scheduled job '%s'", id, run.job.ID)
+		}
+		return true
+	default:
+		log.Logger.Infof("Scheduled job '%s' is still running as %s, skipping the run of %s",
+			run.job.ID, strings.Join(active, ", "), run.scheduledFor.Format("2006-01-02 15:04:05"))
+		js.recordRun(ScheduleRun{
+			ScheduleID:   run.job.ID,
+			ScheduledFor: run.scheduledFor,
+			Outcome:      ScheduleBlocked,
+			JobID:        active[0],
+			RecordedAt:   time.Now(),
+		})
+		return false
+	}
+}
+
+// waitForJob waits until a job has finished or the scheduler stops,
+// reporting whether the job finished
+func (js *JobScheduler) waitForJob(id string) bool {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		status, err := js.manager.GetJob(id)
+		if err != nil || status.IsFinished() {
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-js.stopChan:
+			return false
+		}
+	}
+}
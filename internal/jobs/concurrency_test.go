@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConcurrencyPolicy(t *testing.T) {
+	for name, want := range map[string]ConcurrencyPolicy{"": ConcurrencyForbid, "forbid": ConcurrencyForbid, "Allow": ConcurrencyAllow, "replace": ConcurrencyReplace} {
+		policy, err := ParseConcurrencyPolicy(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, policy, name)
+	}
+	_, err := ParseConcurrencyPolicy("queue")
+	assert.Error(t, err)
+}
+
+func newConcurrencyScheduler(t *testing.T) (*JobScheduler, *Manager) {
+	log.InitLogger(false)
+	manager, err := NewManager(t.TempDir(), DefaultManagerConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.persistence.Close() })
+	return NewJobScheduler(manager), manager
+}
+
+func addScheduledJob(manager *Manager, id, scheduleID string, state JobState) {
+	manager.jobsMux.Lock()
+	manager.jobs[id] = &JobStatus{ID: id, Type: "blocking", State: state, StartTime: time.Now(), Metadata: JobMetadata{ScheduledByKey: scheduleID}}
+	manager.jobsMux.Unlock()
+}
+
+func TestJobScheduler_AdmitRun(t *testing.T) {
+	scheduledFor := time.Date(2024, 5, 1, 5, 0, 0, 0, time.UTC)
+
+	t.Run("no active job", func(t *testing.T) {
+		scheduler, manager := newConcurrencyScheduler(t)
+		addScheduledJob(manager, "sync_1", "sync", JobStateCompleted)
+		addScheduledJob(manager, "other_1", "other", JobStateRunning)
+
+		job := &ScheduledJob{ID: "sync", Concurrency: ConcurrencyForbid}
+		assert.True(t, scheduler.admitRun(dueRun{job: job, scheduledFor: scheduledFor}))
+	})
+
+	t.Run("forbid", func(t *testing.T) {
+		scheduler, manager := newConcurrencyScheduler(t)
+		addScheduledJob(manager, "sync_1", "sync", JobStateRunning)
+
+		job := &ScheduledJob{ID: "sync", Concurrency: ConcurrencyForbid}
+		assert.False(t, scheduler.admitRun(dueRun{job: job, scheduledFor: scheduledFor}))
+
+		history, err := scheduler.History("sync", 0)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, ScheduleBlocked, history[0].Outcome)
+		assert.Equal(t, "sync_1", history[0].JobID)
+		assert.True(t, history[0].ScheduledFor.Equal(scheduledFor))
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		scheduler, manager := newConcurrencyScheduler(t)
+		addScheduledJob(manager, "sync_1", "sync", JobStateRunning)
+
+		job := &ScheduledJob{ID: "sync", Concurrency: ConcurrencyAllow}
+		assert.True(t, scheduler.admitRun(dueRun{job: job, scheduledFor: scheduledFor}))
+
+		status, err := manager.GetJob("sync_1")
+		require.NoError(t, err)
+		assert.Equal(t, JobStateRunning, status.State)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		scheduler, manager := newConcurrencyScheduler(t)
+		addScheduledJob(manager, "sync_1", "sync", JobStatePaused)
+
+		job := &ScheduledJob{ID: "sync", Concurrency: ConcurrencyReplace}
+		assert.True(t, scheduler.admitRun(dueRun{job: job, scheduledFor: scheduledFor}))
+
+		status, err := manager.GetJob("sync_1")
+		require.NoError(t, err)
+		assert.Equal(t, JobStateCancelled, status.State)
+	})
+}
+
+func TestJobScheduler_WaitForJob(t *testing.T) {
+	jobPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { jobPollInterval = time.Second })
+	scheduler, manager := newConcurrencyScheduler(t)
+	addScheduledJob(manager, "sync_1", "sync", JobStateRunning)
+
+	done := make(chan bool)
+	go func() { done <- scheduler.waitForJob("sync_1") }()
+	require.NoError(t, manager.CancelJob("sync_1"))
+	select {
+	case finished := <-done:
+		assert.True(t, finished)
+	case <-time.After(2 * time.Second):
+		t.Fatal("waitForJob did not return")
+	}
+}
+
+func TestJobScheduler_ConfiguredConcurrency(t *testing.T) {
+	log.InitLogger(false)
+	SetSchedulePolicies(SchedulePolicy{Concurrency: ConcurrencyAllow}, map[string]SchedulePolicy{"sync-x": {Concurrency: ConcurrencyReplace}})
+	t.Cleanup(func() { SetSchedulePolicies(SchedulePolicy{CatchUp: CatchUpSkip, Concurrency: ConcurrencyForbid}, nil) })
+	scheduler := NewJobScheduler(nil)
+
+	sync := &ScheduledJob{ID: "sync-x", Schedule: "@daily"}
+	require.NoError(t, scheduler.ScheduleJob(sync))
+	assert.Equal(t, ConcurrencyReplace, sync.Concurrency)
+
+	other := &ScheduledJob{ID: "other", Schedule: "@daily"}
+	require.NoError(t, scheduler.ScheduleJob(other))
+	assert.Equal(t, ConcurrencyAllow, other.Concurrency)
+
+	own := &ScheduledJob{ID: "sync-x", Schedule: "@daily", Concurrency: ConcurrencyForbid}
+	require.NoError(t, scheduler.ScheduleJob(own))
+	assert.Equal(t, ConcurrencyForbid, own.Concurrency)
+
+	assert.Error(t, scheduler.ScheduleJob(&ScheduledJob{ID: "bad", Schedule: "@daily", Concurrency: "queue"}))
+}
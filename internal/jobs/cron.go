@@ -0,0 +1,253 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronAliases are the predefined schedules accepted in place of fields
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchYears bounds the search for the next run; a schedule such as
+// "0 0 30 2 *" never runs
+const cronSearchYears = 5
+
+// ParseCronExpression parses a cron schedule: five fields (minute, hour,
+// day of month, month, day of week), six with a leading seconds field, or
+// an alias such as @daily. The schedule is evaluated in timezone, an IANA
+// name such as "Europe/Berlin" (empty is the local time zone); a
+// "CRON_TZ=<zone> " or "TZ=<zone> " prefix of the expression overrides it.
+func ParseCronExpression(expr, timezone string) (*CronSchedule, error) {
+	schedule := &CronSchedule{Expression: expr}
+
+	spec := strings.TrimSpace(expr)
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if strings.HasPrefix(spec, prefix) {
+			zone, rest, _ := strings.Cut(spec[len(prefix):], " ")
+			timezone, spec = zone, strings.TrimSpace(rest)
+			break
+		}
+	}
+
+	schedule.Location = time.Local
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", timezone)
+		}
+		schedule.Location = location
+	}
+
+	if alias, ok := cronAliases[strings.ToLower(spec)]; ok {
+		spec = alias
+	} else if strings.HasPrefix(spec, "@") {
+		return nil, fmt.Errorf("unknown schedule alias %s (supported: @yearly, @monthly, @weekly, @daily, @hourly)", spec)
+	}
+
+	parts := strings.Fields(spec)
+	switch len(parts) {
+	case 5:
+		parts = append([]string{"0"}, parts...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron expression must have 5 or 6 fields: %s", expr)
+	}
+
+	var err error
+	if schedule.Second, err = parseCronField(parts[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid second field: %w", err)
+	}
+	if schedule.Minute, err = parseCronField(parts[1], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if schedule.Hour, err = parseCronField(parts[2], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if schedule.DayOfMonth, err = parseCronField(parts[3], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid day of month field: %w", err)
+	}
+	if schedule.Month, err = parseCronField(parts[4], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	// 7 is accepted for Sunday as well as 0
+	if schedule.DayOfWeek, err = parseCronField(parts[5], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid day of week field: %w", err)
+	}
+	for i, day := range schedule.DayOfWeek {
+		if day == 7 {
+			schedule.DayOfWeek[i] = 0
+		}
+	}
+	schedule.anyDayOfMonth = isWildcard(parts[3])
+	schedule.anyDayOfWeek = isWildcard(parts[5])
+
+	return schedule, nil
+}
+
+// isWildcard reports whether a field matches every value, as * or ?
+func isWildcard(field string) bool {
+	return field == "*" || field == "?"
+}
+
+// parseCronField parses one field: *, a value, a range a-b, and steps such
+// as */15, 0-30/5 or 5/15 (from 5 to the maximum), separated by commas
+func parseCronField(field string, min, max int) ([]int, error) {
+	seen := make(map[int]bool)
+	var values []int
+	for _, part := range strings.Split(field, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepExpr)
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step value: %s", stepExpr)
+			}
+		}
+
+		start, end := min, max
+		switch {
+		case isWildcard(rangeExpr):
+		case strings.Contains(rangeExpr, "-"):
+			from, to, _ := strings.Cut(rangeExpr, "-")
+			var err error
+			if start, err = parseCronValue(from, min, max); err != nil {
+				return nil, err
+			}
+			if end, err = parseCronValue(to, min, max); err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, fmt.Errorf("range start %d greater than end %d", start, end)
+			}
+		default:
+			value, err := parseCronValue(rangeExpr, min, max)
+			if err != nil {
+				return nil, err
+			}
+			start, end = value, value
+			if hasStep {
+				end = max
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			if !seen[value] {
+				seen[value] = true
+				values = append(values, value)
+			}
+		}
+	}
+	return values, nil
+}
+
+// parseCronValue parses a single value within [min, max]
+func parseCronValue(s string, min, max int) (int, error) {
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %s", s)
+	}
+	if value < min || value > max {
+		return 0, fmt.Errorf("value %d out of range [%d-%d]", value, min, max)
+	}
+	return value, nil
+}
+
+// Next returns the first time after from that matches the schedule, in the
+// location of from, or the zero time if the schedule never runs. Each field
+// advances to its next matching value in turn instead of testing every
+// minute. Times skipped when daylight saving time starts do not run that
+// day; hours repeated when it ends may run twice.
+func (s *CronSchedule) Next(from time.Time) time.Time {
+	location := s.Location
+	if location == nil {
+		location = time.Local
+	}
+	t := from.In(location).Truncate(time.Second).Add(time.Second)
+	limit := t.Year() + cronSearchYears
+
+wrap:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+
+	for !containsValue(s.Month, int(t.Month())) {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !s.matchesDay(t) {
+		month := t.Month()
+		t = time.Date(t.Year(), month, t.Day()+1, 0, 0, 0, 0, location)
+		if t.Month() != month {
+			goto wrap
+		}
+	}
+	for !containsValue(s.Hour, t.Hour()) {
+		day := t.Day()
+		// Step in elapsed time; wall-clock arithmetic loops on repeated hours
+		t = t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second).Add(time.Hour)
+		if t.Day() != day {
+			goto wrap
+		}
+	}
+	for !containsValue(s.Minute, t.Minute()) {
+		hour := t.Hour()
+		t = t.Add(-time.Duration(t.Second()) * time.Second).Add(time.Minute)
+		if t.Hour() != hour {
+			goto wrap
+		}
+	}
+	for !containsValue(s.Second, t.Second()) {
+		minute := t.Minute()
+		t = t.Add(time.Second)
+		if t.Minute() != minute {
+			goto wrap
+		}
+	}
+	return t.In(from.Location())
+}
+
+// Matches reports whether t, to the second, is a time the schedule runs
+func (s *CronSchedule) Matches(t time.Time) bool {
+	if s.Location != nil {
+		t = t.In(s.Location)
+	}
+	return containsValue(s.Second, t.Second()) &&
+		containsValue(s.Minute, t.Minute()) &&
+		containsValue(s.Hour, t.Hour()) &&
+		containsValue(s.Month, int(t.Month())) &&
+		s.matchesDay(t)
+}
+
+// matchesDay follows cron: when both the day of month and the day of week
+// are restricted, a day matching either runs
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := containsValue(s.DayOfMonth, t.Day())
+	dayOfWeek := containsValue(s.DayOfWeek, int(t.Weekday()))
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+// containsValue checks if a slice contains a value
+func containsValue(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
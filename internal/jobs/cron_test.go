@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronExpression_Fields(t *testing.T) {
+	schedule, err := ParseCronExpression("*/15 9-17 * * 1-5", "UTC")
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, schedule.Second)
+	assert.Equal(t, []int{0, 15, 30, 45}, schedule.Minute)
+	assert.Equal(t, []int{9, 10, 11, 12, 13, 14, 15, 16, 17}, schedule.Hour)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, schedule.DayOfWeek)
+
+	schedule, err = ParseCronExpression("30 5/20 * * * 7", "UTC")
+	require.NoError(t, err)
+	assert.Equal(t, []int{30}, schedule.Second)
+	assert.Equal(t, []int{5, 25, 45}, schedule.Minute)
+	assert.Equal(t, []int{0}, schedule.DayOfWeek, "7 is Sunday")
+
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* * * * * * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"1-70 * * * *",
+		"@fortnightly",
+	} {
+		_, err := ParseCronExpression(expr, "")
+		assert.Error(t, err, expr)
+	}
+
+	_, err = ParseCronExpression("@daily", "Mars/Olympus_Mons")
+	assert.Error(t, err)
+}
+
+func TestCronSchedule_Next(t *testing.T) {
+	from := time.Date(2024, 1, 31, 10, 20, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/10 * * * * *", time.Date(2024, 1, 31, 10, 20, 40, 0, time.UTC)},
+		{"20 10 * * *", time.Date(2024, 2, 1, 10, 20, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+		// Both days restricted: the 15th or any Friday
+		{"0 12 15 * 5", time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCronExpression(tt.expr, "UTC")
+		require.NoError(t, err, tt.expr)
+		assert.Equal(t, tt.want, schedule.Next(from), tt.expr)
+		assert.True(t, schedule.Matches(tt.want), tt.expr)
+	}
+
+	schedule, err := ParseCronExpression("0 0 30 2 *", "UTC")
+	require.NoError(t, err)
+	assert.True(t, schedule.Next(from).IsZero(), "February 30 never comes")
+}
+
+func TestCronSchedule_Timezone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	from := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	schedule, err := ParseCronExpression("0 8 * * *", "Europe/Berlin")
+	require.NoError(t, err)
+	next := schedule.Next(from)
+	assert.Equal(t, time.UTC, next.Location(), "the result is in the location of from")
+	assert.Equal(t, time.Date(2024, 6, 2, 8, 0, 0, 0, berlin), next.In(berlin))
+
+	prefixed, err := ParseCronExpression("CRON_TZ=America/New_York 0 8 * * *", "Europe/Berlin")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), prefixed.Next(from.Add(-time.Hour)))
+
+	// 02:30 does not exist on the day daylight saving time starts
+	dst, err := ParseCronExpression("30 2 * * *", "Europe/Berlin")
+	require.NoError(t, err)
+	next = dst.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, berlin))
+	assert.Equal(t, time.Date(2024, 4, 1, 2, 30, 0, 0, berlin), next.In(berlin))
+
+	// 02:30 comes twice on the day it ends
+	first := dst.Next(time.Date(2024, 10, 27, 0, 0, 0, 0, berlin))
+	second := dst.Next(first)
+	assert.Equal(t, time.Hour, second.Sub(first))
+}
+
+func TestJobScheduler_ScheduleJobTimezone(t *testing.T) {
+	log.InitLogger(false)
+	scheduler := NewJobScheduler(nil)
+
+	job := &ScheduledJob{ID: "tz", Name: "tz", JobType: "sync", Schedule: "0 6 * * *", Timezone: "Asia/Tokyo", Enabled: true}
+	require.NoError(t, scheduler.ScheduleJob(job))
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	assert.Equal(t, 6, job.NextRun.In(tokyo).Hour())
+
+	assert.Error(t, scheduler.ScheduleJob(&ScheduledJob{ID: "never", Schedule: "0 0 31 4 *"}))
+	assert.Error(t, scheduler.ScheduleJob(&ScheduledJob{ID: "bad", Schedule: "@daily", Timezone: "Nowhere/Land"}))
+}
@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// maxRetryDelay caps the exponential backoff between automatic retries
+const maxRetryDelay = time.Hour
+
+// JobError is one failed attempt of a job
+type JobError struct {
+	Time    time.Time `json:"time"`
+	Attempt int       `json:"attempt"`
+	Message string    `json:"message"`
+}
+
+// retryDelay returns the backoff before the given retry, doubling from
+// base with each one
+func retryDelay(base time.Duration, retry int) time.Duration {
+	delay := base
+	for i := 0; i < retry && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// scheduleRetry retries a failed job after its backoff; with RetryDelay 0
+// failed jobs wait for jobs retry
+func (m *Manager) scheduleRetry(id string, retry int) {
+	if m.config.RetryDelay <= 0 {
+		return
+	}
+	delay := retryDelay(m.config.RetryDelay, retry)
+	log.Logger.Infof("Job %s will be retried in %s", id, delay)
+
+	time.AfterFunc(delay, func() {
+		if m.ctx.Err() != nil {
+			return
+		}
+		if err := m.RetryJob(id); err != nil {
+			log.Logger.Debugf("Skipped automatic retry of job %s: %v", id, err)
+			return
+		}
+		if err := m.StartJob(id); err != nil {
+			log.Logger.Warnf("Failed to start retry of job %s: %v", id, err)
+		}
+	})
+}
+
+// deadLetter announces a job that failed after its last retry, so that
+// persistent failures are noticed rather than cleaned up
+func (m *Manager) deadLetter(id string, attempts int, err error) {
+	log.Logger.Warnf("Job %s moved to the dead-letter queue after %d attempts: %v", id, attempts, err)
+	m.emitEvent(JobEvent{
+		JobID:     id,
+		EventType: EventJobDeadLettered,
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf("Job %s failed %d times and was moved to the dead-letter queue: %v", id, attempts, err),
+		Data: JobMetadata{
+			"error":    err.Error(),
+			"attempts": attempts,
+		},
+	})
+}
+
+// DeadLetterJobs lists the jobs in the dead-letter queue, newest first
+func (m *Manager) DeadLetterJobs() ([]*JobStatus, error) {
+	return m.ListJobs(JobFilter{States: []JobState{JobStateDeadLetter}})
+}
+
+// JobErrors returns the errors of every failed attempt of a job, oldest
+// first
+func (m *Manager) JobErrors(id string) ([]JobError, error) {
+	events, err := m.persistence.LoadEvents(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job events: %w", err)
+	}
+
+	var errors []JobError
+	for _, event := range events {
+		if event.EventType != EventJobFailed {
+			continue
+		}
+		message, _ := event.Data["error"].(string)
+		errors = append(errors, JobError{Time: event.Timestamp, Attempt: len(errors) + 1, Message: message})
+	}
+	return errors, nil
+}
+
+// RequeueDeadLetter gives a job in the dead-letter queue a fresh set of
+// retries and starts it again
+func (m *Manager) RequeueDeadLetter(id string) error {
+	m.jobsMux.Lock()
+	status, exists := m.jobs[id]
+	if !exists {
+		m.jobsMux.Unlock()
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+	if status.State != JobStateDeadLetter {
+		m.jobsMux.Unlock()
+		return invalidJobState("job %s is not in the dead-letter queue (current state: %s)", id, status.State)
+	}
+
+	status.State = JobStateQueued
+	status.RetryCount = 0
+	status.ErrorMessage = ""
+	status.EndTime = nil
+	if err := m.persistence.SaveJob(status); err != nil {
+		log.Logger.Warnf("Failed to persist job requeue: %v", err)
+	}
+	m.jobsMux.Unlock()
+
+	m.emitEvent(JobEvent{
+		JobID:     id,
+		EventType: EventJobRetrying,
+		Timestamp: time.Now(),
+		Message:   fmt.Sprintf("Job %s requeued from the dead-letter queue", id),
+	})
+
+	return m.StartJob(id)
+}
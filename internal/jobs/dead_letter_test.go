@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingJob fails every attempt
+type failingJob struct {
+	*MaintenanceJob
+}
+
+func (fj *failingJob) Execute(ctx context.Context, progressCallback ProgressCallback) error {
+	return errors.New("schema mismatch")
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, retryDelay(time.Minute, 0))
+	assert.Equal(t, 4*time.Minute, retryDelay(time.Minute, 2))
+	assert.Equal(t, maxRetryDelay, retryDelay(time.Minute, 20))
+}
+
+func TestManager_DeadLettersJobsAfterRetries(t *testing.T) {
+	log.InitLogger(false)
+	config := DefaultManagerConfig()
+	config.MaxWorkers = 1
+	config.MaxRetries = 1
+	config.RetryDelay = 10 * time.Millisecond
+	manager, err := NewManager(t.TempDir(), config)
+	require.NoError(t, err)
+	require.NoError(t, manager.JobFactory().RegisterJobType("failing", func(status *JobStatus) (Job, error) {
+		return &failingJob{MaintenanceJob: NewMaintenanceJob(status.ID, MaintenanceOptimize, "mock", nil)}, nil
+	}))
+	require.NoError(t, manager.Start())
+	t.Cleanup(func() { manager.Stop() })
+
+	status := &JobStatus{ID: "failing-1", Type: "failing", State: JobStateQueued, StartTime: time.Now(), MaxRetries: 1, Metadata: JobMetadata{}}
+	manager.jobsMux.Lock()
+	manager.jobs[status.ID] = status
+	manager.jobsMux.Unlock()
+	require.NoError(t, manager.persistence.SaveJob(status))
+	require.NoError(t, manager.StartJob(status.ID))
+
+	deadLettered := func() bool {
+		job, err := manager.GetJob(status.ID)
+		return err == nil && job.State == JobStateDeadLetter
+	}
+	failedTimes := func(n int) func() bool {
+		return func() bool {
+			jobErrors, err := manager.JobErrors(status.ID)
+			return err == nil && len(jobErrors) == n && deadLettered()
+		}
+	}
+	require.Eventually(t, failedTimes(2), 2*time.Second, 10*time.Millisecond)
+
+	// The error of every attempt is kept
+	jobErrors, err := manager.JobErrors(status.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "schema mismatch", jobErrors[1].Message)
+	assert.Equal(t, 2, jobErrors[1].Attempt)
+
+	deadLetter, err := manager.DeadLetterJobs()
+	require.NoError(t, err)
+	require.Len(t, deadLetter, 1)
+	assert.Equal(t, status.ID, deadLetter[0].ID)
+
+	// Requeuing grants a fresh set of retries
+	require.NoError(t, manager.RequeueDeadLetter(status.ID))
+	require.Eventually(t, failedTimes(4), 2*time.Second, 10*time.Millisecond)
+
+	assert.Error(t, manager.RetryJob(status.ID))
+	assert.Error(t, manager.RequeueDeadLetter("missing"))
+}
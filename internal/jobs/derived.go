@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"fmt"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// derivedTableProvider is implemented by data sources that maintain derived tables
+type derivedTableProvider interface {
+	DerivedTables() *storage.DerivedTables
+}
+
+// derivedScheduleID returns the scheduler ID for a derived table refresh
+func derivedScheduleID(sourceName, table string) string {
+	return fmt.Sprintf("derived-%s-%s", sourceName, table)
+}
+
+// RefreshDerivedTable submits a maintenance job that rebuilds a derived
+// table, or every derived table of the source when table is empty
+func (ejm *EnhancedJobManager) RefreshDerivedTable(sourceName, table string) (string, error) {
+	return ejm.SubmitJobFromConfig(string(JobTypeMaintenance), map[string]interface{}{
+		"operation":   MaintenanceRefreshDerived,
+		"source_name": sourceName,
+		"target":      table,
+	})
+}
+
+// ScheduleDerivedRefresh registers the cron refresh of a derived table with
+// the scheduler; tables refreshed manually or after sync are unscheduled
+func (ejm *EnhancedJobManager) ScheduleDerivedRefresh(sourceName string, table storage.DerivedTable) error {
+	id := derivedScheduleID(sourceName, table.Name)
+	if !table.IsScheduled() {
+		ejm.scheduler.UnscheduleJob(id)
+		return nil
+	}
+
+	return ejm.scheduler.ScheduleJob(&ScheduledJob{
+		ID:      id,
+		Name:    fmt.Sprintf("Refresh %s.%s", sourceName, table.Name),
+		JobType: string(JobTypeMaintenance),
+		Config: map[string]interface{}{
+			"operation":   MaintenanceRefreshDerived,
+			"source_name": sourceName,
+			"target":      table.Name,
+		},
+		Schedule:    table.Refresh,
+		Enabled:     true,
+		Description: fmt.Sprintf("Refresh derived table %s.%s", sourceName, table.Name),
+	})
+}
+
+// UnscheduleDerivedRefresh removes the cron refresh of a derived table
+func (ejm *EnhancedJobManager) UnscheduleDerivedRefresh(sourceName, table string) {
+	ejm.scheduler.UnscheduleJob(derivedScheduleID(sourceName, table))
+}
+
+// scheduleDerivedRefreshes schedules every derived table with a cron refresh
+func (ejm *EnhancedJobManager) scheduleDerivedRefreshes() {
+	for sourceName, ds := range ejm.factory.dataSources {
+		provider, ok := ds.(derivedTableProvider)
+		if !ok || provider.DerivedTables() == nil {
+			continue
+		}
+
+		tables, err := provider.DerivedTables().List()
+		if err != nil {
+			log.Logger.Warnf("Failed to list derived tables for %s: %v", sourceName, err)
+			continue
+		}
+		for _, table := range tables {
+			if err := ejm.ScheduleDerivedRefresh(sourceName, table); err != nil {
+				log.Logger.Warnf("Failed to schedule refresh of %s.%s: %v", sourceName, table.Name, err)
+			}
+		}
+	}
+}
+
+// derivedRefresher refreshes after_sync derived tables when a download or
+// sync job for their source completes
+type derivedRefresher struct {
+	manager *EnhancedJobManager
+}
+
+// HandleEvent implements EventHandler
+func (dr *derivedRefresher) HandleEvent(event JobEvent) {
+	if event.EventType != EventJobCompleted {
+		return
+	}
+
+	status, err := dr.manager.GetJob(event.JobID)
+	if err != nil || (status.Type != JobTypeDownload && status.Type != JobTypeSync) {
+		return
+	}
+	sourceName, _ := status.Metadata["source_name"].(string)
+	provider, ok := dr.manager.factory.dataSources[sourceName].(derivedTableProvider)
+	if !ok || provider.DerivedTables() == nil {
+		return
+	}
+
+	tables, err := provider.DerivedTables().List()
+	if err != nil {
+		log.Logger.Warnf("Failed to list derived tables for %s: %v", sourceName, err)
+		return
+	}
+	for _, table := range tables {
+		if table.Refresh != storage.RefreshAfterSync {
+			continue
+		}
+		if _, err := dr.manager.RefreshDerivedTable(sourceName, table.Name); err != nil {
+			log.Logger.Warnf("Failed to refresh %s.%s after sync: %v", sourceName, table.Name, err)
+		}
+	}
+}
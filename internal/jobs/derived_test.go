@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// derivedDataSource is a mock data source with derived tables
+type derivedDataSource struct {
+	*datasource.MockDataSource
+	derived *storage.DerivedTables
+}
+
+func (d *derivedDataSource) DerivedTables() *storage.DerivedTables {
+	return d.derived
+}
+
+func TestMaintenanceJob_RefreshDerived(t *testing.T) {
+	log.InitLogger(false)
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "derived.sqlite"))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY); INSERT INTO items VALUES (1), (2)")
+	require.NoError(t, err)
+
+	derived, err := storage.NewDerivedTables(db)
+	require.NoError(t, err)
+	require.NoError(t, derived.Define("item_count", "SELECT COUNT(*) AS n FROM items", storage.RefreshManual))
+	require.NoError(t, derived.Define("item_ids", "SELECT id FROM items", storage.RefreshManual))
+
+	ds := &derivedDataSource{MockDataSource: datasource.NewMockDataSource("mock", "Mock data source"), derived: derived}
+
+	job := NewMaintenanceJob("refresh-1", MaintenanceRefreshDerived, "mock", ds)
+	job.SetTarget("item_ids")
+	require.NoError(t, job.Execute(context.Background(), func(JobProgress) {}))
+
+	tables, err := derived.List()
+	require.NoError(t, err)
+	assert.Nil(t, tables[0].RefreshedAt, "item_count was not targeted")
+	assert.Equal(t, int64(2), tables[1].RowCount)
+
+	all := NewMaintenanceJob("refresh-2", MaintenanceRefreshDerived, "mock", ds)
+	require.NoError(t, all.Execute(context.Background(), func(JobProgress) {}))
+	table, err := derived.Get("item_count")
+	require.NoError(t, err)
+	assert.NotNil(t, table.RefreshedAt)
+
+	plain := NewMaintenanceJob("refresh-3", MaintenanceRefreshDerived, "mock", datasource.NewMockDataSource("mock", "Mock"))
+	assert.Error(t, plain.Execute(context.Background(), func(JobProgress) {}))
+}
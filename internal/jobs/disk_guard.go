@@ -0,0 +1,316 @@
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// Disk guard event types
+const (
+	EventDiskSpaceLow       = "disk_space_low"
+	EventDiskSpaceRecovered = "disk_space_recovered"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// DiskGuardConfig configures the free disk space guard for download jobs
+type DiskGuardConfig struct {
+	MinFreeMB     int64         // Pause guarded jobs below this much free space; 0 disables the guard
+	ResumeFreeMB  int64         // Resume paused jobs once free space reaches this
+	CheckInterval time.Duration // How often to check free space while jobs run
+	JobTypes      []JobType     // Job types the guard pauses
+}
+
+// DefaultDiskGuardConfig returns the default disk guard configuration
+func DefaultDiskGuardConfig() DiskGuardConfig {
+	return DiskGuardConfig{
+		MinFreeMB:     500,
+		ResumeFreeMB:  1024,
+		CheckInterval: 30 * time.Second,
+		JobTypes:      []JobType{JobTypeDownload},
+	}
+}
+
+// DiskGuardStatus describes the current state of the disk guard
+type DiskGuardStatus struct {
+	Enabled         bool      `json:"enabled"`
+	Path            string    `json:"path"`
+	FreeBytes       uint64    `json:"free_bytes"`
+	MinFreeBytes    uint64    `json:"min_free_bytes"`
+	ResumeFreeBytes uint64    `json:"resume_free_bytes"`
+	Low             bool      `json:"low"`
+	Overridden      bool      `json:"overridden"`
+	HeldJobs        []string  `json:"held_jobs,omitempty"`
+	LastCheck       time.Time `json:"last_check"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// DiskGuard pauses download jobs when free space on the storage filesystem
+// drops below a threshold and resumes them once space is recovered or the
+// user overrides the guard
+type DiskGuard struct {
+	manager   *Manager
+	path      string
+	config    DiskGuardConfig
+	freeSpace func(path string) (uint64, error)
+
+	mu        sync.Mutex
+	low       bool
+	override  bool
+	held      map[string]bool
+	freeBytes uint64
+	lastCheck time.Time
+	checkErr  error
+
+	running  int32
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDiskGuard creates a disk guard watching the filesystem containing path
+func NewDiskGuard(manager *Manager, path string, config DiskGuardConfig) *DiskGuard {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = DefaultDiskGuardConfig().CheckInterval
+	}
+	if config.ResumeFreeMB < config.MinFreeMB {
+		config.ResumeFreeMB = config.MinFreeMB
+	}
+	if len(config.JobTypes) == 0 {
+		config.JobTypes = DefaultDiskGuardConfig().JobTypes
+	}
+
+	return &DiskGuard{
+		manager:   manager,
+		path:      path,
+		config:    config,
+		freeSpace: FreeDiskSpace,
+		held:      make(map[string]bool),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Enabled reports whether the guard has a free space threshold
+func (g *DiskGuard) Enabled() bool {
+	return g.config.MinFreeMB > 0
+}
+
+// Start begins periodic free space checks in the background
+func (g *DiskGuard) Start() {
+	if !g.Enabled() || !atomic.CompareAndSwapInt32(&g.running, 0, 1) {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(g.config.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-g.stopChan:
+				return
+			case <-ticker.C:
+				g.Check()
+			}
+		}
+	}()
+}
+
+// Stop stops periodic checks
+func (g *DiskGuard) Stop() {
+	g.stopOnce.Do(func() {
+		close(g.stopChan)
+	})
+}
+
+// Check measures free space, pausing guarded jobs when it is low and
+// resuming them once it has recovered
+func (g *DiskGuard) Check() DiskGuardStatus {
+	if !g.Enabled() {
+		return g.Status()
+	}
+
+	free, err := g.freeSpace(g.path)
+
+	g.mu.Lock()
+	g.lastCheck = time.Now()
+	g.checkErr = err
+	if err != nil {
+		g.mu.Unlock()
+		log.Logger.Debugf("Disk guard could not read free space: %v", err)
+		return g.Status()
+	}
+	g.freeBytes = free
+
+	becameLow := !g.low && free < g.minFreeBytes()
+	recovered := g.low && free >= g.resumeFreeBytes()
+	if becameLow {
+		g.low = true
+	}
+	var resume []string
+	if recovered {
+		g.low = false
+		g.override = false
+		resume = g.takeHeld()
+	}
+	enforce := g.low && !g.override
+	g.mu.Unlock()
+
+	if becameLow {
+		g.emit(EventDiskSpaceLow, free, fmt.Sprintf("Low disk space: %s free, pausing downloads below %s",
+			formatMB(free), formatMB(g.minFreeBytes())))
+	}
+	if enforce {
+		g.pauseGuardedJobs()
+	}
+	if recovered {
+		g.emit(EventDiskSpaceRecovered, free, fmt.Sprintf("Disk space recovered: %s free, resuming %d jobs",
+			formatMB(free), len(resume)))
+		g.startJobs(resume)
+	}
+
+	return g.Status()
+}
+
+// Allow returns an error if a job of the given type must not start because
+// free space is low; the job is held and started once space is recovered
+func (g *DiskGuard) Allow(id string, jobType JobType) error {
+	if !g.Enabled() || !g.guards(jobType) {
+		return nil
+	}
+
+	status := g.Check()
+	if !status.Low || status.Overridden {
+		return nil
+	}
+
+	g.mu.Lock()
+	g.held[id] = true
+	g.mu.Unlock()
+
+	return fmt.Errorf("insufficient disk space: %s free, %s required (job %s will start when space is recovered or the guard is overridden)",
+		formatMB(status.FreeBytes), formatMB(status.MinFreeBytes), id)
+}
+
+// Override lets guarded jobs run despite low disk space until space is
+// recovered, and starts any jobs the guard is holding
+func (g *DiskGuard) Override() []string {
+	g.mu.Lock()
+	g.override = true
+	resume := g.takeHeld()
+	g.mu.Unlock()
+
+	log.Logger.Warnf("Disk space guard overridden, resuming %d jobs", len(resume))
+	g.startJobs(resume)
+	return resume
+}
+
+// Status returns the guard's last observed state
+func (g *DiskGuard) Status() DiskGuardStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	status := DiskGuardStatus{
+		Enabled:         g.Enabled(),
+		Path:            g.path,
+		FreeBytes:       g.freeBytes,
+		MinFreeBytes:    g.minFreeBytes(),
+		ResumeFreeBytes: g.resumeFreeBytes(),
+		Low:             g.low,
+		Overridden:      g.override,
+		LastCheck:       g.lastCheck,
+	}
+	for id := range g.held {
+		status.HeldJobs = append(status.HeldJobs, id)
+	}
+	sort.Strings(status.HeldJobs)
+	if g.checkErr != nil {
+		status.Error = g.checkErr.Error()
+	}
+	return status
+}
+
+// pauseGuardedJobs pauses every running job of a guarded type
+func (g *DiskGuard) pauseGuardedJobs() {
+	m := g.manager
+	m.jobsMux.RLock()
+	var ids []string
+	for id := range m.runningJobs {
+		if status, exists := m.jobs[id]; exists && status.State == JobStateRunning && g.guards(status.Type) {
+			ids = append(ids, id)
+		}
+	}
+	m.jobsMux.RUnlock()
+
+	for _, id := range ids {
+		if err := m.pauseExecution(id, fmt.Sprintf("Job %s paused: low disk space", id)); err != nil {
+			log.Logger.Warnf("Failed to pause job %s for low disk space: %v", id, err)
+			continue
+		}
+		g.mu.Lock()
+		g.held[id] = true
+		g.mu.Unlock()
+	}
+}
+
+// startJobs restarts jobs previously held by the guard
+func (g *DiskGuard) startJobs(ids []string) {
+	for _, id := range ids {
+		if err := g.manager.StartJob(id); err != nil {
+			log.Logger.Warnf("Failed to resume job %s after disk space check: %v", id, err)
+		}
+	}
+}
+
+// takeHeld empties the held job set and returns its contents; callers hold mu
+func (g *DiskGuard) takeHeld() []string {
+	ids := make([]string, 0, len(g.held))
+	for id := range g.held {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	g.held = make(map[string]bool)
+	return ids
+}
+
+// guards reports whether jobs of the given type are subject to the guard
+func (g *DiskGuard) guards(jobType JobType) bool {
+	for _, t := range g.config.JobTypes {
+		if t == jobType {
+			return true
+		}
+	}
+	return false
+}
+
+// emit logs and publishes a disk space event
+func (g *DiskGuard) emit(eventType string, free uint64, message string) {
+	log.Logger.Warn(message)
+	g.manager.emitEvent(JobEvent{
+		EventType: eventType,
+		Timestamp: time.Now(),
+		Message:   message,
+		Data: JobMetadata{
+			"path":           g.path,
+			"free_bytes":     free,
+			"min_free_bytes": g.minFreeBytes(),
+		},
+	})
+}
+
+func (g *DiskGuard) minFreeBytes() uint64 {
+	return uint64(g.config.MinFreeMB) * bytesPerMB
+}
+
+func (g *DiskGuard) resumeFreeBytes() uint64 {
+	return uint64(g.config.ResumeFreeMB) * bytesPerMB
+}
+
+// formatMB formats a byte count in megabytes
+func formatMB(bytes uint64) string {
+	return fmt.Sprintf("%d MB", bytes/bytesPerMB)
+}
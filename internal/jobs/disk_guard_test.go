@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDiskGuardTestManager(t *testing.T, free *uint64) (*Manager, chan struct{}) {
+	manager, started := newBlockingTestManager(t, func(config *ManagerConfig) {
+		config.DiskGuard = DiskGuardConfig{
+			MinFreeMB:     100,
+			ResumeFreeMB:  200,
+			CheckInterval: time.Hour,
+			JobTypes:      []JobType{"blocking"},
+		}
+	})
+	manager.DiskGuard().freeSpace = func(string) (uint64, error) {
+		return atomic.LoadUint64(free), nil
+	}
+
+	return manager, started
+}
+
+func addQueuedJob(manager *Manager, id string) {
+	manager.jobsMux.Lock()
+	manager.jobs[id] = &JobStatus{ID: id, Type: "blocking", State: JobStateQueued, StartTime: time.Now()}
+	manager.jobsMux.Unlock()
+}
+
+func waitStarted(t *testing.T, started chan struct{}) {
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not start")
+	}
+}
+
+func waitForState(t *testing.T, manager *Manager, id string, state JobState) {
+	require.Eventually(t, func() bool {
+		status, err := manager.GetJob(id)
+		return err == nil && status.State == state
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestDiskGuard_PausesAndResumesJobs(t *testing.T) {
+	free := uint64(500 * bytesPerMB)
+	manager, started := newDiskGuardTestManager(t, &free)
+
+	addQueuedJob(manager, "job-1")
+	require.NoError(t, manager.StartJob("job-1"))
+	waitStarted(t, started)
+	waitForState(t, manager, "job-1", JobStateRunning)
+
+	atomic.StoreUint64(&free, 50*bytesPerMB)
+	status := manager.DiskGuard().Check()
+	assert.True(t, status.Low)
+	assert.Equal(t, []string{"job-1"}, status.HeldJobs)
+	waitForState(t, manager, "job-1", JobStatePaused)
+
+	// Still below the resume threshold
+	atomic.StoreUint64(&free, 150*bytesPerMB)
+	assert.True(t, manager.DiskGuard().Check().Low)
+
+	atomic.StoreUint64(&free, 300*bytesPerMB)
+	status = manager.DiskGuard().Check()
+	assert.False(t, status.Low)
+	assert.Empty(t, status.HeldJobs)
+	waitStarted(t, started)
+	waitForState(t, manager, "job-1", JobStateRunning)
+}
+
+func TestDiskGuard_HoldsNewJobsUntilOverride(t *testing.T) {
+	free := uint64(50 * bytesPerMB)
+	manager, started := newDiskGuardTestManager(t, &free)
+
+	addQueuedJob(manager, "job-1")
+	err := manager.StartJob("job-1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient disk space")
+	assert.Equal(t, []string{"job-1"}, manager.DiskGuard().Status().HeldJobs)
+
+	assert.Equal(t, []string{"job-1"}, manager.DiskGuard().Override())
+	waitStarted(t, started)
+	waitForState(t, manager, "job-1", JobStateRunning)
+
+	// The override stays in effect while space remains low
+	status := manager.DiskGuard().Check()
+	assert.True(t, status.Low)
+	assert.True(t, status.Overridden)
+	waitForState(t, manager, "job-1", JobStateRunning)
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package jobs
+
+import "syscall"
+
+// FreeDiskSpace returns the bytes available to unprivileged users on the
+// filesystem containing path
+func FreeDiskSpace(path string) (uint64, error) {
+	free, _, err := DiskSpace(path)
+	return free, err
+}
+
+// DiskSpace returns the bytes available to unprivileged users and the total
+// size of the filesystem containing path
+func DiskSpace(path string) (free, total uint64, err error) {
+	var statfs syscall.Statfs_t
+	if err := syscall.Statfs(path, &statfs); err != nil {
+		return 0, 0, err
+	}
+	return uint64(statfs.Bavail) * uint64(statfs.Bsize), uint64(statfs.Blocks) * uint64(statfs.Bsize), nil
+}
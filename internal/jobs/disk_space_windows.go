@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package jobs
+
+import "fmt"
+
+// FreeDiskSpace is not implemented on Windows, which disables the disk guard
+// and the setup free space check
+func FreeDiskSpace(path string) (uint64, error) {
+	return 0, fmt.Errorf("free disk space is not available on windows")
+}
+
+// DiskSpace is not implemented on Windows
+func DiskSpace(path string) (free, total uint64, err error) {
+	return 0, 0, fmt.Errorf("disk space is not available on windows")
+}
@@ -135,19 +135,7 @@ func (dj *DownloadJob) monitorProgress(ctx context.Context, progressCallback Pro
 			dj.progress.Total = status.ItemsTotal
 			dj.progress.Message = status.Status
 
-			// Calculate ETA if we have enough data
-			if status.Progress > 0 && status.Progress < 1 {
-				// Simple ETA calculation based on current progress
-				elapsed := time.Since(status.LastUpdate)
-				if elapsed > 0 {
-					remainingProgress := 1.0 - status.Progress
-					estimatedTotal := time.Duration(float64(elapsed) / status.Progress)
-					eta := time.Duration(float64(estimatedTotal) * remainingProgress)
-					dj.progress.ETA = &eta
-				}
-			}
-
-			// Report progress
+			// Report progress; the job manager derives rate and ETA from successive reports
 			progressCallback(dj.progress)
 		}
 	}
@@ -215,6 +203,26 @@ func (dj *DownloadJob) Validate() error {
 	return nil
 }
 
+// SyncJob fetches items added to a data source since its last download
+type SyncJob struct {
+	*DownloadJob
+}
+
+// NewSyncJob creates a new sync job
+func NewSyncJob(id, sourceName string, dataSource datasource.DataSource, batchSize int) *SyncJob {
+	return &SyncJob{DownloadJob: NewDownloadJob(id, sourceName, dataSource, batchSize)}
+}
+
+// Type returns the job type
+func (sj *SyncJob) Type() JobType {
+	return JobTypeSync
+}
+
+// Description returns the job description
+func (sj *SyncJob) Description() string {
+	return fmt.Sprintf("Sync new data from %s", sj.sourceName)
+}
+
 // RetryStrategy defines retry behavior for download jobs
 type RetryStrategy struct {
 	MaxRetries    int
@@ -35,15 +35,6 @@ type PoolConfig struct {
 	ResourceLimits      ResourceLimits `json:"resource_limits"`
 }
 
-// ScalingConfig defines auto-scaling behavior
-type ScalingConfig struct {
-	Enabled            bool          `json:"enabled"`
-	ScaleUpThreshold   float64       `json:"scale_up_threshold"`
-	ScaleDownThreshold float64       `json:"scale_down_threshold"`
-	EvaluationWindow   time.Duration `json:"evaluation_window"`
-	CooldownPeriod     time.Duration `json:"cooldown_period"`
-}
-
 // ResourceLimits defines resource constraints
 type ResourceLimits struct {
 	MaxCPUPercent float64 `json:"max_cpu_percent"`
@@ -63,11 +54,13 @@ func DefaultPoolConfig() *PoolConfig {
 		ShutdownTimeout:     30 * time.Second,
 		TaskTimeout:         2 * time.Hour,
 		Scaling: ScalingConfig{
-			Enabled:            true,
-			ScaleUpThreshold:   0.8,
-			ScaleDownThreshold: 0.2,
-			EvaluationWindow:   5 * time.Minute,
-			CooldownPeriod:     2 * time.Minute,
+			Enabled:        true,
+			MinWorkers:     1,
+			MaxWorkers:     runtime.NumCPU() * 4,
+			ScaleUpWait:    5 * time.Second,
+			IdleTimeout:    time.Minute,
+			CheckInterval:  time.Second,
+			CooldownPeriod: 5 * time.Second,
 		},
 		ResourceLimits: ResourceLimits{
 			MaxCPUPercent: 80.0,
@@ -96,7 +89,7 @@ func NewEnhancedWorkerPool(config *PoolConfig, manager *Manager) *EnhancedWorker
 
 	// Initialize components
 	enhanced.healthChecker = NewHealthChecker(enhanced, config.HealthCheckInterval)
-	enhanced.scaler = NewPoolScaler(enhanced, &config.Scaling)
+	enhanced.scaler = NewPoolScaler(basePool, config.Scaling)
 	enhanced.resourceMonitor = NewResourceMonitor(&config.ResourceLimits)
 
 	return enhanced
@@ -164,58 +157,15 @@ func (ewp *EnhancedWorkerPool) SetSize(newSize int) error {
 		return fmt.Errorf("size %d exceeds maximum %d", newSize, ewp.config.MaxSize)
 	}
 
-	ewp.mu.Lock()
-	defer ewp.mu.Unlock()
-
-	currentSize := len(ewp.workers)
-	if newSize == currentSize {
-		return nil
-	}
-
-	if newSize > currentSize {
-		// Scale up - add workers
-		return ewp.scaleUp(newSize - currentSize)
-	} else {
-		// Scale down - remove workers
-		return ewp.scaleDown(currentSize - newSize)
-	}
-}
-
-// scaleUp adds new workers to the pool
-func (ewp *EnhancedWorkerPool) scaleUp(count int) error {
-	log.Logger.Infof("Scaling up worker pool by %d workers", count)
-
-	for i := 0; i < count; i++ {
-		workerID := len(ewp.workers)
-		worker := NewWorker(workerID, ewp.jobQueue, ewp.WorkerPool)
-		ewp.workers = append(ewp.workers, worker)
-		ewp.wg.Add(1)
-		go worker.Start()
+	from := ewp.GetStats().TotalWorkers
+	if err := ewp.WorkerPool.SetSize(newSize); err != nil {
+		return err
 	}
-
-	ewp.stats.TotalWorkers = len(ewp.workers)
-	ewp.metrics.RecordScaling("up", count)
-	log.Logger.Infof("Scaled up to %d workers", len(ewp.workers))
-	return nil
-}
-
-// scaleDown removes workers from the pool
-func (ewp *EnhancedWorkerPool) scaleDown(count int) error {
-	log.Logger.Infof("Scaling down worker pool by %d workers", count)
-
-	currentSize := len(ewp.workers)
-	if count >= currentSize {
-		return fmt.Errorf("cannot remove %d workers from pool of %d", count, currentSize)
+	if newSize > from {
+		ewp.metrics.RecordScaling("up", newSize-from)
+	} else if newSize < from {
+		ewp.metrics.RecordScaling("down", from-newSize)
 	}
-
-	// Mark workers for removal (they'll stop when they finish current jobs)
-	// This is a simplified approach - in production you'd want more sophisticated worker management
-	newSize := currentSize - count
-	ewp.workers = ewp.workers[:newSize]
-	ewp.stats.TotalWorkers = newSize
-
-	ewp.metrics.RecordScaling("down", count)
-	log.Logger.Infof("Scaled down to %d workers", newSize)
 	return nil
 }
 
@@ -234,7 +184,6 @@ func (ewp *EnhancedWorkerPool) GetEnhancedStatus() *EnhancedPoolStatus {
 		},
 		ResourceStats: resourceStats,
 		HealthStats:   ewp.healthChecker.GetStats(),
-		ScalingStats:  ewp.scaler.GetStats(),
 		LastActivity:  time.Unix(atomic.LoadInt64(&ewp.lastActivity), 0),
 		UptimeSeconds: time.Since(ewp.metrics.StartTime).Seconds(),
 	}
@@ -245,7 +194,6 @@ type EnhancedPoolStatus struct {
 	PoolStatus    PoolStatus    `json:"pool_status"`
 	ResourceStats ResourceStats `json:"resource_stats"`
 	HealthStats   HealthStats   `json:"health_stats"`
-	ScalingStats  ScalingStats  `json:"scaling_stats"`
 	LastActivity  time.Time     `json:"last_activity"`
 	UptimeSeconds float64       `json:"uptime_seconds"`
 }
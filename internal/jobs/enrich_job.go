@@ -0,0 +1,144 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// textEnricher is implemented by data sources whose downloaded text can be
+// post-processed by enrichers, such as language detection or word counts
+type textEnricher interface {
+	Enrich(ctx context.Context, names []string, rerun bool, progress func(done, total int64)) error
+}
+
+// EnrichJob runs text enrichers over a data source's downloaded items
+type EnrichJob struct {
+	id         string
+	sourceName string
+	enrichers  []string
+	rerun      bool
+	dataSource datasource.DataSource
+	priority   JobPriority
+	metadata   JobMetadata
+	progress   JobProgress
+}
+
+// NewEnrichJob creates an enrich job for the given enrichers, or for every
+// registered enricher when enrichers is empty; rerun discards stored results
+func NewEnrichJob(id, sourceName string, dataSource datasource.DataSource, enrichers []string, rerun bool) *EnrichJob {
+	return &EnrichJob{
+		id:         id,
+		sourceName: sourceName,
+		enrichers:  enrichers,
+		rerun:      rerun,
+		dataSource: dataSource,
+		priority:   PriorityLow,
+		metadata: JobMetadata{
+			"source_name": sourceName,
+			"enrichers":   strings.Join(enrichers, ","),
+			"rerun":       rerun,
+		},
+		progress: JobProgress{
+			Current: 0,
+			Total:   1,
+			Message: "Waiting to enrich items...",
+		},
+	}
+}
+
+// ID returns the job ID
+func (ej *EnrichJob) ID() string {
+	return ej.id
+}
+
+// Type returns the job type
+func (ej *EnrichJob) Type() JobType {
+	return JobTypeEnrich
+}
+
+// Priority returns the job priority
+func (ej *EnrichJob) Priority() JobPriority {
+	return ej.priority
+}
+
+// SetPriority sets the job priority
+func (ej *EnrichJob) SetPriority(priority JobPriority) {
+	ej.priority = priority
+}
+
+// Description returns the job description
+func (ej *EnrichJob) Description() string {
+	if len(ej.enrichers) == 0 {
+		return fmt.Sprintf("Enrich %s items", ej.sourceName)
+	}
+	return fmt.Sprintf("Enrich %s items (%s)", ej.sourceName, strings.Join(ej.enrichers, ", "))
+}
+
+// Metadata returns the job metadata
+func (ej *EnrichJob) Metadata() JobMetadata {
+	return ej.metadata
+}
+
+// Execute runs the enrichers
+func (ej *EnrichJob) Execute(ctx context.Context, progressCallback ProgressCallback) error {
+	enricher, ok := ej.dataSource.(textEnricher)
+	if !ok {
+		return fmt.Errorf("data source %s does not support enrichment", ej.sourceName)
+	}
+
+	ej.progress.Message = "Enriching items..."
+	progressCallback(ej.progress)
+
+	err := enricher.Enrich(ctx, ej.enrichers, ej.rerun, func(done, total int64) {
+		ej.progress.Current = done
+		ej.progress.Total = total
+		ej.progress.Message = fmt.Sprintf("Enriched %d of %d items", done, total)
+		progressCallback(ej.progress)
+	})
+	if err != nil {
+		return fmt.Errorf("enrichment failed: %w", err)
+	}
+
+	ej.progress.Current = ej.progress.Total
+	ej.progress.Message = "Enrichment completed"
+	progressCallback(ej.progress)
+
+	log.Logger.Infof("Enriched %s items", ej.sourceName)
+	return nil
+}
+
+// CanPause returns false; a cancelled run keeps the batches it stored and the
+// next run continues with the remaining items
+func (ej *EnrichJob) CanPause() bool {
+	return false
+}
+
+// Pause pauses the job
+func (ej *EnrichJob) Pause() error {
+	return fmt.Errorf("enrich jobs cannot be paused")
+}
+
+// Resume resumes the job
+func (ej *EnrichJob) Resume(ctx context.Context) error {
+	return fmt.Errorf("enrich jobs cannot be resumed")
+}
+
+// Progress returns the current job progress
+func (ej *EnrichJob) Progress() JobProgress {
+	return ej.progress
+}
+
+// Validate validates the job configuration
+func (ej *EnrichJob) Validate() error {
+	if ej.id == "" {
+		return fmt.Errorf("job ID cannot be empty")
+	}
+	if ej.dataSource == nil {
+		return fmt.Errorf("data source cannot be nil")
+	}
+	return nil
+}
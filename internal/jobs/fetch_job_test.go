@@ -0,0 +1,80 @@
+package jobs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_PausesAndResumesFetchJobs(t *testing.T) {
+	log.InitLogger(false)
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<17) // 2MB, two segments
+
+	// The second segment stalls until released, so the job can be paused
+	// with the first one written
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rng := r.Header.Get("Range"); rng != "bytes=0-0" && !strings.HasPrefix(rng, "bytes=0-") {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		http.ServeContent(w, r, "dump.bin", time.Unix(1700000000, 0), bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	config := DefaultManagerConfig()
+	config.MaxWorkers = 1
+	manager, err := NewManager(t.TempDir(), config)
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	t.Cleanup(func() { manager.Stop() })
+
+	sum := sha256.Sum256(content)
+	path := filepath.Join(t.TempDir(), "dump.bin")
+	id, err := manager.SubmitJob(NewFetchJob("fetch-1", server.URL, path, hex.EncodeToString(sum[:]), 2))
+	require.NoError(t, err)
+	require.NoError(t, manager.StartJob(id))
+
+	// Wait for the first segment to be saved before pausing
+	require.Eventually(t, func() bool {
+		job, err := manager.GetJob(id)
+		return err == nil && job.Progress.Current >= int64(len(content)/2)
+	}, 5*time.Second, 20*time.Millisecond)
+	require.NoError(t, manager.PauseJob(id))
+	require.Eventually(t, func() bool {
+		job, err := manager.GetJob(id)
+		return err == nil && job.State == JobStatePaused
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.FileExists(t, path+".part.json")
+
+	close(release)
+	require.NoError(t, manager.ResumeJob(id))
+	require.Eventually(t, func() bool {
+		job, err := manager.GetJob(id)
+		return err == nil && job.State == JobStateCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	downloaded, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func TestFetchJob_Validate(t *testing.T) {
+	assert.NoError(t, NewFetchJob("fetch-1", "https://example.com/dump.gz", "dump.gz", "", 0).Validate())
+	assert.Error(t, NewFetchJob("fetch-1", "ftp://example.com/dump.gz", "dump.gz", "", 0).Validate())
+	assert.Error(t, NewFetchJob("fetch-1", "https://example.com/dump.gz", "", "", 0).Validate())
+}
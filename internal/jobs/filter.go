@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseJobFilter parses command line filter flags into a JobFilter and
+// returns the remaining positional arguments. Supported flags:
+//
+//	--source <name>        jobs whose source_name metadata matches
+//	--state <s>[,<s>...]   jobs in any of the given states
+//	--type <t>[,<t>...]    jobs of any of the given types
+//	--failed               shorthand for --state failed
+//	--since <age>          jobs created within the given age (e.g. 1h, 7d)
+//	--older-than <age>     jobs created before the given age
+//	--created-by <user>    jobs created by the given user
+func ParseJobFilter(args []string, now time.Time) (JobFilter, []string, error) {
+	var filter JobFilter
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if name == "failed" {
+			filter.States = append(filter.States, JobStateFailed)
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return filter, nil, fmt.Errorf("flag --%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "source":
+			filter.Source = value
+		case "state":
+			for _, state := range strings.Split(value, ",") {
+				parsed, err := parseJobState(state)
+				if err != nil {
+					return filter, nil, err
+				}
+				filter.States = append(filter.States, parsed)
+			}
+		case "type":
+			for _, jobType := range strings.Split(value, ",") {
+				filter.Types = append(filter.Types, JobType(strings.TrimSpace(jobType)))
+			}
+		case "since":
+			age, err := ParseAge(value)
+			if err != nil {
+				return filter, nil, fmt.Errorf("invalid --since: %w", err)
+			}
+			after := now.Add(-age)
+			filter.CreatedAfter = &after
+		case "older-than":
+			age, err := ParseAge(value)
+			if err != nil {
+				return filter, nil, fmt.Errorf("invalid --older-than: %w", err)
+			}
+			before := now.Add(-age)
+			filter.CreatedBefore = &before
+		case "created-by":
+			filter.CreatedBy = value
+		default:
+			return filter, nil, fmt.Errorf("unknown filter flag: --%s", name)
+		}
+	}
+
+	return filter, positional, nil
+}
+
+// IsEmpty reports whether the filter matches every job
+func (f JobFilter) IsEmpty() bool {
+	return len(f.States) == 0 && len(f.Types) == 0 && f.CreatedBy == "" &&
+		f.CreatedAfter == nil && f.CreatedBefore == nil && f.Source == ""
+}
+
+// ParseAge parses a duration that may also use day (d) and week (w) units,
+// such as "90m", "7d" or "2w"
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, found := strings.CutSuffix(value, suffix); found {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+
+	age, err := time.ParseDuration(value)
+	if err != nil || age < 0 {
+		return 0, fmt.Errorf("invalid age %q", value)
+	}
+	return age, nil
+}
+
+// parseJobState validates a job state name
+func parseJobState(value string) (JobState, error) {
+	state := JobState(strings.ToLower(strings.TrimSpace(value)))
+	switch state {
+	case JobStateQueued, JobStateRunning, JobStatePaused, JobStateCompleted, JobStateFailed, JobStateCancelled, JobStateDeadLetter:
+		return state, nil
+	}
+	return "", fmt.Errorf("unknown job state: %s", value)
+}
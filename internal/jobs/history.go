@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// progressMilestoneStep is the percentage step at which progress events are
+// kept in a job timeline; other progress events are collapsed
+const progressMilestoneStep = 25.0
+
+// TimelineEntry is a single event in a job's history
+type TimelineEntry struct {
+	Timestamp     time.Time     `json:"timestamp"`
+	EventType     string        `json:"event_type"`
+	Message       string        `json:"message"`
+	SinceStart    time.Duration `json:"since_start"`
+	SincePrevious time.Duration `json:"since_previous"`
+}
+
+// JobHistory returns the persisted event timeline of a job, keeping only
+// progress events that reach a new 25% milestone
+func (m *Manager) JobHistory(id string) ([]TimelineEntry, error) {
+	events, err := m.persistence.LoadEvents(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load job events: %w", err)
+	}
+	if len(events) == 0 {
+		if _, err := m.GetJob(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return buildTimeline(events), nil
+}
+
+// buildTimeline converts events to timeline entries with durations
+func buildTimeline(events []JobEvent) []TimelineEntry {
+	var timeline []TimelineEntry
+	var start, previous time.Time
+	nextMilestone := progressMilestoneStep
+
+	for _, event := range events {
+		if event.EventType == EventJobProgress {
+			percentage, _ := event.Data["percentage"].(float64)
+			if percentage < nextMilestone {
+				continue
+			}
+			for nextMilestone <= percentage {
+				nextMilestone += progressMilestoneStep
+			}
+		}
+
+		if start.IsZero() {
+			start = event.Timestamp
+			previous = event.Timestamp
+		}
+
+		timeline = append(timeline, TimelineEntry{
+			Timestamp:     event.Timestamp,
+			EventType:     event.EventType,
+			Message:       event.Message,
+			SinceStart:    event.Timestamp.Sub(start),
+			SincePrevious: event.Timestamp.Sub(previous),
+		})
+		previous = event.Timestamp
+	}
+
+	return timeline
+}
+
+// JobOutcomeSummary summarizes the outcomes of a set of jobs
+type JobOutcomeSummary struct {
+	Total           int                          `json:"total"`
+	ByState         map[JobState]int             `json:"by_state"`
+	ByType          map[JobType]map[JobState]int `json:"by_type"`
+	AverageDuration time.Duration                `json:"average_duration"`
+	LongestDuration time.Duration                `json:"longest_duration"`
+	Failures        []*JobStatus                 `json:"failures,omitempty"`
+}
+
+// SummarizeJobs summarizes the outcomes of jobs matching the filter, with
+// failures listed newest first
+func (m *Manager) SummarizeJobs(filter JobFilter) (*JobOutcomeSummary, error) {
+	jobs, err := m.ListJobs(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	summary := &JobOutcomeSummary{
+		Total:   len(jobs),
+		ByState: make(map[JobState]int),
+		ByType:  make(map[JobType]map[JobState]int),
+	}
+
+	var totalDuration time.Duration
+	finished := 0
+	for _, job := range jobs {
+		summary.ByState[job.State]++
+		if summary.ByType[job.Type] == nil {
+			summary.ByType[job.Type] = make(map[JobState]int)
+		}
+		summary.ByType[job.Type][job.State]++
+
+		if job.EndTime != nil {
+			duration := job.EndTime.Sub(job.StartTime)
+			totalDuration += duration
+			finished++
+			if duration > summary.LongestDuration {
+				summary.LongestDuration = duration
+			}
+		}
+
+		if job.State == JobStateFailed || job.State == JobStateDeadLetter {
+			summary.Failures = append(summary.Failures, job)
+		}
+	}
+
+	if finished > 0 {
+		summary.AverageDuration = totalDuration / time.Duration(finished)
+	}
+	sort.Slice(summary.Failures, func(i, j int) bool {
+		return summary.Failures[i].StartTime.After(summary.Failures[j].StartTime)
+	})
+
+	return summary, nil
+}
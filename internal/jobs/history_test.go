@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTimeline_CollapsesProgressToMilestones(t *testing.T) {
+	start := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	progress := func(offset time.Duration, percentage float64) JobEvent {
+		return JobEvent{EventType: EventJobProgress, Timestamp: start.Add(offset), Data: JobMetadata{"percentage": percentage}}
+	}
+
+	timeline := buildTimeline([]JobEvent{
+		{EventType: EventJobSubmitted, Timestamp: start},
+		progress(time.Second, 5),
+		progress(2*time.Second, 30),
+		progress(3*time.Second, 40),
+		progress(4*time.Second, 80),
+		{EventType: EventJobRetrying, Timestamp: start.Add(5 * time.Second)},
+		progress(6*time.Second, 100),
+		{EventType: EventJobCompleted, Timestamp: start.Add(10 * time.Second)},
+	})
+
+	var types []string
+	for _, entry := range timeline {
+		types = append(types, entry.EventType)
+	}
+	assert.Equal(t, []string{EventJobSubmitted, EventJobProgress, EventJobProgress, EventJobRetrying,
+		EventJobProgress, EventJobCompleted}, types)
+
+	last := timeline[len(timeline)-1]
+	assert.Equal(t, 10*time.Second, last.SinceStart)
+	assert.Equal(t, 4*time.Second, last.SincePrevious)
+}
+
+func TestManager_SummarizeJobs(t *testing.T) {
+	manager, _ := newBlockingTestManager(t, func(config *ManagerConfig) {})
+
+	now := time.Now()
+	end := func(d time.Duration) *time.Time {
+		t := now.Add(-time.Hour).Add(d)
+		return &t
+	}
+	for _, status := range []*JobStatus{
+		{ID: "ok-1", Type: JobTypeDownload, State: JobStateCompleted, StartTime: now.Add(-time.Hour), EndTime: end(time.Minute), Metadata: JobMetadata{}},
+		{ID: "ok-2", Type: JobTypeDownload, State: JobStateCompleted, StartTime: now.Add(-time.Hour), EndTime: end(3 * time.Minute), Metadata: JobMetadata{}},
+		{ID: "bad-1", Type: JobTypeExport, State: JobStateFailed, StartTime: now.Add(-time.Hour), EndTime: end(2 * time.Minute), ErrorMessage: "boom", Metadata: JobMetadata{}},
+		{ID: "ancient", Type: JobTypeExport, State: JobStateFailed, StartTime: now.Add(-72 * time.Hour), Metadata: JobMetadata{}},
+	} {
+		require.NoError(t, manager.persistence.SaveJob(status))
+	}
+
+	since := now.Add(-24 * time.Hour)
+	summary, err := manager.SummarizeJobs(JobFilter{CreatedAfter: &since})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, summary.Total)
+	assert.Equal(t, 2, summary.ByState[JobStateCompleted])
+	assert.Equal(t, 1, summary.ByType[JobTypeExport][JobStateFailed])
+	assert.Equal(t, 2*time.Minute, summary.AverageDuration)
+	assert.Equal(t, 3*time.Minute, summary.LongestDuration)
+	require.Len(t, summary.Failures, 1)
+	assert.Equal(t, "bad-1", summary.Failures[0].ID)
+}
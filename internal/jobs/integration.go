@@ -1,35 +1,109 @@
 package jobs
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/brainless/PubDataHub/internal/datasource"
 	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/report"
 )
 
+// JobConstructor builds a job instance from a job status, typically one
+// restored from persistence or produced by the scheduler
+type JobConstructor func(status *JobStatus) (Job, error)
+
 // JobFactory creates job instances based on job type and metadata
 type JobFactory struct {
-	dataSources map[string]datasource.DataSource
+	mu           sync.RWMutex
+	dataSources  map[string]datasource.DataSource
+	constructors map[JobType]JobConstructor
 }
 
-// NewJobFactory creates a new job factory
+// NewJobFactory creates a new job factory with the built-in job types registered
 func NewJobFactory(dataSources map[string]datasource.DataSource) *JobFactory {
-	return &JobFactory{
-		dataSources: dataSources,
+	if dataSources == nil {
+		dataSources = make(map[string]datasource.DataSource)
+	}
+
+	jf := &JobFactory{
+		dataSources:  dataSources,
+		constructors: make(map[JobType]JobConstructor),
+	}
+
+	jf.constructors[JobTypeDownload] = jf.createDownloadJob
+	jf.constructors[JobTypeExport] = jf.createExportJob
+	jf.constructors[JobTypeSync] = jf.createSyncJob
+	jf.constructors[JobTypeMaintenance] = jf.createMaintenanceJob
+	jf.constructors[JobTypeSnapshot] = jf.createSnapshotJob
+	jf.constructors[JobTypeEnrich] = jf.createEnrichJob
+	jf.constructors[JobTypeFetch] = jf.createFetchJob
+
+	return jf
+}
+
+// RegisterJobType registers a constructor for a job type
+func (jf *JobFactory) RegisterJobType(jobType JobType, constructor JobConstructor) error {
+	if jobType == "" {
+		return fmt.Errorf("job type cannot be empty")
+	}
+	if constructor == nil {
+		return fmt.Errorf("constructor for job type %s cannot be nil", jobType)
+	}
+
+	jf.mu.Lock()
+	defer jf.mu.Unlock()
+
+	if _, exists := jf.constructors[jobType]; exists {
+		return fmt.Errorf("job type %s already registered", jobType)
+	}
+
+	jf.constructors[jobType] = constructor
+	return nil
+}
+
+// IsRegistered reports whether a constructor exists for the job type
+func (jf *JobFactory) IsRegistered(jobType JobType) bool {
+	jf.mu.RLock()
+	defer jf.mu.RUnlock()
+
+	_, exists := jf.constructors[jobType]
+	return exists
+}
+
+// RegisteredTypes returns all job types the factory can create, sorted by name
+func (jf *JobFactory) RegisteredTypes() []JobType {
+	jf.mu.RLock()
+	defer jf.mu.RUnlock()
+
+	types := make([]JobType, 0, len(jf.constructors))
+	for jobType := range jf.constructors {
+		types = append(types, jobType)
 	}
+
+	sort.Slice(types, func(i, j int) bool {
+		return types[i] < types[j]
+	})
+
+	return types
 }
 
 // CreateJob creates a job instance from persisted job status
 func (jf *JobFactory) CreateJob(status *JobStatus) (Job, error) {
-	switch status.Type {
-	case JobTypeDownload:
-		return jf.createDownloadJob(status)
-	case JobTypeExport:
-		return jf.createExportJob(status)
-	default:
+	jf.mu.RLock()
+	constructor, exists := jf.constructors[status.Type]
+	jf.mu.RUnlock()
+
+	if !exists {
 		return nil, fmt.Errorf("unknown job type: %s", status.Type)
 	}
+
+	return constructor(status)
 }
 
 // createDownloadJob creates a download job from status
@@ -44,10 +118,7 @@ func (jf *JobFactory) createDownloadJob(status *JobStatus) (Job, error) {
 		return nil, fmt.Errorf("data source not found: %s", sourceName)
 	}
 
-	batchSize, ok := status.Metadata["batch_size"].(int)
-	if !ok {
-		batchSize = 100 // default batch size
-	}
+	batchSize := metadataInt(status.Metadata, "batch_size", 100)
 
 	job := NewDownloadJob(status.ID, sourceName, dataSource, batchSize)
 	job.SetPriority(status.Priority)
@@ -77,6 +148,140 @@ func (jf *JobFactory) createExportJob(status *JobStatus) (Job, error) {
 	return job, nil
 }
 
+// createSyncJob creates a sync job from status
+func (jf *JobFactory) createSyncJob(status *JobStatus) (Job, error) {
+	sourceName, ok := status.Metadata["source_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing source_name in sync job metadata")
+	}
+
+	dataSource, exists := jf.dataSources[sourceName]
+	if !exists {
+		return nil, fmt.Errorf("data source not found: %s", sourceName)
+	}
+
+	job := NewSyncJob(status.ID, sourceName, dataSource, metadataInt(status.Metadata, "batch_size", 100))
+	job.SetPriority(status.Priority)
+	return job, nil
+}
+
+// createMaintenanceJob creates a maintenance job from status
+func (jf *JobFactory) createMaintenanceJob(status *JobStatus) (Job, error) {
+	operation, ok := status.Metadata["operation"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing operation in maintenance job metadata")
+	}
+
+	sourceName, ok := status.Metadata["source_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing source_name in maintenance job metadata")
+	}
+
+	dataSource, exists := jf.dataSources[sourceName]
+	if !exists {
+		return nil, fmt.Errorf("data source not found: %s", sourceName)
+	}
+
+	job := NewMaintenanceJob(status.ID, operation, sourceName, dataSource)
+	if target, ok := status.Metadata["target"].(string); ok {
+		job.SetTarget(target)
+	}
+	if days := metadataInt(status.Metadata, "older_than_days", 0); days > 0 {
+		job.SetOlderThanDays(days)
+	}
+	job.SetPriority(status.Priority)
+	return job, nil
+}
+
+// createSnapshotJob creates a snapshot job from status
+func (jf *JobFactory) createSnapshotJob(status *JobStatus) (Job, error) {
+	sourceName, ok := status.Metadata["source_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing source_name in snapshot job metadata")
+	}
+
+	dataSource, exists := jf.dataSources[sourceName]
+	if !exists {
+		return nil, fmt.Errorf("data source not found: %s", sourceName)
+	}
+
+	lists, _ := status.Metadata["lists"].(string)
+	job := NewSnapshotJob(status.ID, sourceName, dataSource, splitLists(lists), metadataInt(status.Metadata, "depth", 0))
+	job.SetPriority(status.Priority)
+	return job, nil
+}
+
+// createEnrichJob creates an enrich job from status
+func (jf *JobFactory) createEnrichJob(status *JobStatus) (Job, error) {
+	sourceName, ok := status.Metadata["source_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing source_name in enrich job metadata")
+	}
+
+	dataSource, exists := jf.dataSources[sourceName]
+	if !exists {
+		return nil, fmt.Errorf("data source not found: %s", sourceName)
+	}
+
+	enrichers, _ := status.Metadata["enrichers"].(string)
+	rerun, _ := status.Metadata["rerun"].(bool)
+	job := NewEnrichJob(status.ID, sourceName, dataSource, splitLists(enrichers), rerun)
+	job.SetPriority(status.Priority)
+	return job, nil
+}
+
+// createFetchJob creates a fetch job from status
+func (jf *JobFactory) createFetchJob(status *JobStatus) (Job, error) {
+	url, ok := status.Metadata["url"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing url in fetch job metadata")
+	}
+
+	path, ok := status.Metadata["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing path in fetch job metadata")
+	}
+
+	sha256, _ := status.Metadata["sha256"].(string)
+	job := NewFetchJob(status.ID, url, path, sha256, metadataInt(status.Metadata, "segments", 0))
+	job.SetPriority(status.Priority)
+	return job, nil
+}
+
+// CreateJobFromConfig creates a new job instance of the given type from a
+// free-form configuration map, such as a saved job template
+func (jf *JobFactory) CreateJobFromConfig(id string, jobType JobType, config map[string]interface{}) (Job, error) {
+	metadata := make(JobMetadata, len(config))
+	for key, value := range config {
+		metadata[key] = value
+	}
+
+	return jf.CreateJob(&JobStatus{
+		ID:       id,
+		Type:     jobType,
+		Priority: JobPriority(metadataInt(metadata, "priority", int(PriorityNormal))),
+		Metadata: metadata,
+	})
+}
+
+// metadataInt reads an integer metadata value, accepting the float64 values
+// produced by JSON decoding and the strings produced by command line parsing
+func metadataInt(metadata JobMetadata, key string, defaultValue int) int {
+	switch value := metadata[key].(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	case string:
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
 // TUIEventHandler handles job events for the TUI
 type TUIEventHandler struct {
 	displayUpdates chan JobEvent
@@ -109,7 +314,9 @@ func (teh *TUIEventHandler) GetDisplayUpdates() <-chan JobEvent {
 type EnhancedJobManager struct {
 	*Manager
 	factory      *JobFactory
+	scheduler    *JobScheduler
 	eventHandler *TUIEventHandler
+	reports      *report.Store
 	idCounter    int
 }
 
@@ -126,19 +333,284 @@ func NewEnhancedJobManager(storagePath string, dataSources map[string]datasource
 	enhancedManager := &EnhancedJobManager{
 		Manager:      manager,
 		factory:      factory,
+		scheduler:    NewJobScheduler(manager),
 		eventHandler: eventHandler,
+		reports:      report.NewStore(report.Dir(storagePath)),
 		idCounter:    1,
 	}
+	if err := factory.RegisterJobType(JobTypeReport, enhancedManager.createReportJob); err != nil {
+		return nil, err
+	}
 
 	// Add the TUI event handler
 	manager.AddEventHandler(eventHandler)
+	manager.AddEventHandler(&derivedRefresher{manager: enhancedManager})
 
 	// Set the job factory
-	manager.jobFactory = factory
+	manager.SetJobFactory(factory)
 
 	return enhancedManager, nil
 }
 
+// Start starts the job manager and its scheduler
+func (ejm *EnhancedJobManager) Start() error {
+	if err := ejm.Manager.Start(); err != nil {
+		return err
+	}
+
+	if err := ejm.scheduler.Start(); err != nil {
+		return fmt.Errorf("failed to start job scheduler: %w", err)
+	}
+	ejm.scheduleDerivedRefreshes()
+	ejm.scheduleReports()
+
+	return nil
+}
+
+// Stop stops the scheduler and the job manager
+func (ejm *EnhancedJobManager) Stop() error {
+	if err := ejm.scheduler.Stop(); err != nil {
+		log.Logger.Warnf("Error stopping job scheduler: %v", err)
+	}
+
+	return ejm.Manager.Stop()
+}
+
+// Shutdown stops the scheduler, then drains the running jobs and stops the
+// job manager
+func (ejm *EnhancedJobManager) Shutdown(ctx context.Context) error {
+	if err := ejm.scheduler.Stop(); err != nil {
+		log.Logger.Warnf("Error stopping job scheduler: %v", err)
+	}
+
+	return ejm.Manager.Shutdown(ctx)
+}
+
+// Scheduler returns the job scheduler used for recurring jobs
+func (ejm *EnhancedJobManager) Scheduler() *JobScheduler {
+	return ejm.scheduler
+}
+
+// ScheduleSourceSync schedules a recurring download of a data source, such
+// as the nightly sync chosen during setup
+func (ejm *EnhancedJobManager) ScheduleSourceSync(sourceName, schedule string) (*ScheduledJob, error) {
+	job := &ScheduledJob{
+		ID:          "sync-" + sourceName,
+		Name:        sourceName + " sync",
+		JobType:     string(JobTypeDownload),
+		Config:      map[string]interface{}{"source_name": sourceName},
+		Schedule:    schedule,
+		Enabled:     true,
+		CreatedBy:   "config",
+		Description: fmt.Sprintf("Recurring download of %s", sourceName),
+	}
+	if err := ejm.scheduler.ScheduleJob(job); err != nil {
+		return nil, fmt.Errorf("failed to schedule %s sync: %w", sourceName, err)
+	}
+	return job, nil
+}
+
+// UnscheduleSource removes the scheduled jobs of a data source, such as its
+// sync and repair schedules, and returns how many were removed
+func (ejm *EnhancedJobManager) UnscheduleSource(sourceName string) int {
+	removed := 0
+	for _, job := range ejm.scheduler.ListScheduledJobs() {
+		if job.Config["source_name"] != sourceName {
+			continue
+		}
+		if err := ejm.scheduler.UnscheduleJob(job.ID); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// RefreshUsers submits a maintenance job that fetches new and outdated user
+// profiles of a data source
+func (ejm *EnhancedJobManager) RefreshUsers(sourceName string) (string, error) {
+	return ejm.SubmitJobFromConfig(string(JobTypeMaintenance), map[string]interface{}{
+		"operation":   MaintenanceRefreshUsers,
+		"source_name": sourceName,
+	})
+}
+
+// ScheduleUserRefresh schedules a recurring refresh of a data source's user
+// profiles, which records karma snapshots over time
+func (ejm *EnhancedJobManager) ScheduleUserRefresh(sourceName, schedule string) (*ScheduledJob, error) {
+	job := &ScheduledJob{
+		ID:      "users-" + sourceName,
+		Name:    sourceName + " user refresh",
+		JobType: string(JobTypeMaintenance),
+		Config: map[string]interface{}{
+			"operation":   MaintenanceRefreshUsers,
+			"source_name": sourceName,
+		},
+		Schedule:    schedule,
+		Enabled:     true,
+		CreatedBy:   "config",
+		Description: fmt.Sprintf("Recurring refresh of %s user profiles", sourceName),
+	}
+	if err := ejm.scheduler.ScheduleJob(job); err != nil {
+		return nil, fmt.Errorf("failed to schedule %s user refresh: %w", sourceName, err)
+	}
+	return job, nil
+}
+
+// RepairItems submits a maintenance job that re-fetches the failed items of a
+// data source and its dead, deleted and missing items due for a re-check
+func (ejm *EnhancedJobManager) RepairItems(sourceName string) (string, error) {
+	return ejm.SubmitJobFromConfig(string(JobTypeMaintenance), map[string]interface{}{
+		"operation":   MaintenanceRepairItems,
+		"source_name": sourceName,
+	})
+}
+
+// ScheduleItemRepair schedules a recurring repair of a data source's failed
+// and tombstoned items
+func (ejm *EnhancedJobManager) ScheduleItemRepair(sourceName, schedule string) (*ScheduledJob, error) {
+	job := &ScheduledJob{
+		ID:      "repair-" + sourceName,
+		Name:    sourceName + " item repair",
+		JobType: string(JobTypeMaintenance),
+		Config: map[string]interface{}{
+			"operation":   MaintenanceRepairItems,
+			"source_name": sourceName,
+		},
+		Schedule:    schedule,
+		Enabled:     true,
+		CreatedBy:   "config",
+		Description: fmt.Sprintf("Recurring re-check of %s dead, deleted and failed items", sourceName),
+	}
+	if err := ejm.scheduler.ScheduleJob(job); err != nil {
+		return nil, fmt.Errorf("failed to schedule %s item repair: %w", sourceName, err)
+	}
+	return job, nil
+}
+
+// ArchiveItems submits a maintenance job that moves the items of a data
+// source older than the given number of days into its archive databases
+func (ejm *EnhancedJobManager) ArchiveItems(sourceName string, olderThanDays int) (string, error) {
+	return ejm.SubmitJobFromConfig(string(JobTypeMaintenance), map[string]interface{}{
+		"operation":       MaintenanceArchive,
+		"source_name":     sourceName,
+		"older_than_days": olderThanDays,
+	})
+}
+
+// ScheduleArchive schedules a recurring archive of a data source's items
+// older than the given number of days
+func (ejm *EnhancedJobManager) ScheduleArchive(sourceName, schedule string, olderThanDays int) (*ScheduledJob, error) {
+	job := &ScheduledJob{
+		ID:      "archive-" + sourceName,
+		Name:    sourceName + " archive",
+		JobType: string(JobTypeMaintenance),
+		Config: map[string]interface{}{
+			"operation":       MaintenanceArchive,
+			"source_name":     sourceName,
+			"older_than_days": olderThanDays,
+		},
+		Schedule:    schedule,
+		Enabled:     true,
+		CreatedBy:   "config",
+		Description: fmt.Sprintf("Recurring archive of %s items older than %d days", sourceName, olderThanDays),
+	}
+	if err := ejm.scheduler.ScheduleJob(job); err != nil {
+		return nil, fmt.Errorf("failed to schedule %s archive: %w", sourceName, err)
+	}
+	return job, nil
+}
+
+// CaptureRankings submits a snapshot job recording the current rankings of
+// a data source's lists, or of every list when lists is empty
+func (ejm *EnhancedJobManager) CaptureRankings(sourceName string, lists []string, depth int) (string, error) {
+	return ejm.SubmitJobFromConfig(string(JobTypeSnapshot), map[string]interface{}{
+		"source_name": sourceName,
+		"lists":       strings.Join(lists, ","),
+		"depth":       depth,
+	})
+}
+
+// ScheduleRankingSnapshot schedules a recurring capture of every ranked list
+// of a data source, such as the Hacker News front page
+func (ejm *EnhancedJobManager) ScheduleRankingSnapshot(sourceName, schedule string) (*ScheduledJob, error) {
+	job := &ScheduledJob{
+		ID:          "snapshot-" + sourceName,
+		Name:        sourceName + " ranking snapshot",
+		JobType:     string(JobTypeSnapshot),
+		Config:      map[string]interface{}{"source_name": sourceName},
+		Schedule:    schedule,
+		Enabled:     true,
+		CreatedBy:   "config",
+		Description: fmt.Sprintf("Recurring capture of %s rankings", sourceName),
+	}
+	if err := ejm.scheduler.ScheduleJob(job); err != nil {
+		return nil, fmt.Errorf("failed to schedule %s ranking snapshot: %w", sourceName, err)
+	}
+	return job, nil
+}
+
+// EnrichItems submits an enrich job running the given enrichers, or every
+// enricher when enrichers is empty, over a data source's items
+func (ejm *EnhancedJobManager) EnrichItems(sourceName string, enrichers []string, rerun bool) (string, error) {
+	return ejm.SubmitJobFromConfig(string(JobTypeEnrich), map[string]interface{}{
+		"source_name": sourceName,
+		"enrichers":   strings.Join(enrichers, ","),
+		"rerun":       rerun,
+	})
+}
+
+// ScheduleEnrichment schedules a recurring enrichment of a data source's new
+// items and of items enriched by an outdated enricher version
+func (ejm *EnhancedJobManager) ScheduleEnrichment(sourceName, schedule string, enrichers []string) (*ScheduledJob, error) {
+	job := &ScheduledJob{
+		ID:      "enrich-" + sourceName,
+		Name:    sourceName + " enrichment",
+		JobType: string(JobTypeEnrich),
+		Config: map[string]interface{}{
+			"source_name": sourceName,
+			"enrichers":   strings.Join(enrichers, ","),
+		},
+		Schedule:    schedule,
+		Enabled:     true,
+		CreatedBy:   "config",
+		Description: fmt.Sprintf("Recurring enrichment of %s items", sourceName),
+	}
+	if err := ejm.scheduler.ScheduleJob(job); err != nil {
+		return nil, fmt.Errorf("failed to schedule %s enrichment: %w", sourceName, err)
+	}
+	return job, nil
+}
+
+// FetchFile submits a job downloading a large file from url to path in
+// parallel segments, verifying sha256 when set
+func (ejm *EnhancedJobManager) FetchFile(url, path, sha256 string, segments int) (string, error) {
+	return ejm.SubmitJobFromConfig(string(JobTypeFetch), map[string]interface{}{
+		"url":      url,
+		"path":     path,
+		"sha256":   sha256,
+		"segments": segments,
+	})
+}
+
+// SubmitJobFromConfig creates a job of the given type from a configuration
+// map and submits it for execution
+func (ejm *EnhancedJobManager) SubmitJobFromConfig(jobType string, config map[string]interface{}) (string, error) {
+	jobID := fmt.Sprintf("%s-%d-%d", jobType, ejm.idCounter, time.Now().Unix())
+	ejm.idCounter++
+
+	job, err := ejm.factory.CreateJobFromConfig(jobID, JobType(jobType), config)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s job: %w", jobType, err)
+	}
+
+	id, err := ejm.SubmitJob(job)
+	if err != nil {
+		return "", fmt.Errorf("failed to submit %s job: %w", jobType, err)
+	}
+
+	return id, nil
+}
+
 // StartDownloadJob starts a new download job (for compatibility with existing TUI)
 func (ejm *EnhancedJobManager) StartDownloadJob(sourceName string, ds datasource.DataSource) (string, error) {
 	// Generate unique job ID
@@ -157,6 +629,34 @@ func (ejm *EnhancedJobManager) StartDownloadJob(sourceName string, ds datasource
 	return id, nil
 }
 
+// StartSourceDownload submits and starts a download job for a data source
+// with the given batch size and priority
+func (ejm *EnhancedJobManager) StartSourceDownload(sourceName string, ds datasource.DataSource, batchSize int, priority JobPriority) (string, error) {
+	if batchSize < 1 {
+		return "", fmt.Errorf("batch size must be at least 1")
+	}
+	if priority < PriorityLow || priority > PriorityHigh {
+		return "", fmt.Errorf("priority must be from %d to %d", PriorityLow, PriorityHigh)
+	}
+
+	job := NewDownloadJob(fmt.Sprintf("download-%s-%d", sourceName, time.Now().Unix()), sourceName, ds, batchSize)
+	job.SetPriority(priority)
+
+	id, err := ejm.SubmitJob(job)
+	if err != nil {
+		return "", fmt.Errorf("failed to start download job: %w", err)
+	}
+	if err := ejm.StartJob(id); err != nil {
+		return "", fmt.Errorf("failed to start job: %w", err)
+	}
+	return id, nil
+}
+
+// JobFactory returns the factory used to create and rehydrate jobs
+func (ejm *EnhancedJobManager) JobFactory() *JobFactory {
+	return ejm.factory
+}
+
 // GetDisplayUpdates returns the channel for TUI display updates
 func (ejm *EnhancedJobManager) GetDisplayUpdates() <-chan JobEvent {
 	return ejm.eventHandler.GetDisplayUpdates()
@@ -180,6 +680,19 @@ func (ejm *EnhancedJobManager) GetJobSummary(id string) (map[string]interface{},
 		"active":      status.IsActive(),
 	}
 
+	if status.Progress.Rate > 0 {
+		summary["rate"] = status.Progress.Rate
+	}
+	if status.Progress.ETA != nil && status.State == JobStateRunning {
+		summary["eta"] = status.Progress.ETA.String()
+	}
+	if status.Progress.Stalled {
+		summary["stalled"] = true
+	}
+	if status.WaitReason != "" {
+		summary["wait_reason"] = status.WaitReason
+	}
+
 	if status.EndTime != nil {
 		summary["end_time"] = status.EndTime.Format("2006-01-02 15:04:05")
 	}
@@ -187,6 +700,12 @@ func (ejm *EnhancedJobManager) GetJobSummary(id string) (map[string]interface{},
 	if status.ErrorMessage != "" {
 		summary["error"] = status.ErrorMessage
 	}
+	if url, ok := status.Metadata["object_url"].(string); ok {
+		summary["object_url"] = url
+	}
+	if hookError, ok := status.Metadata["hook_error"].(string); ok {
+		summary["hook_error"] = hookError
+	}
 
 	return summary, nil
 }
@@ -220,12 +739,13 @@ func (ejm *EnhancedJobManager) GetManagerSummary() map[string]interface{} {
 	stats := ejm.GetStats()
 
 	return map[string]interface{}{
-		"total_jobs":     stats.TotalJobs,
-		"active_jobs":    stats.ActiveJobs,
-		"queued_jobs":    stats.QueuedJobs,
-		"running_jobs":   stats.RunningJobs,
-		"completed_jobs": stats.CompletedJobs,
-		"failed_jobs":    stats.FailedJobs,
+		"total_jobs":       stats.TotalJobs,
+		"active_jobs":      stats.ActiveJobs,
+		"queued_jobs":      stats.QueuedJobs,
+		"running_jobs":     stats.RunningJobs,
+		"completed_jobs":   stats.CompletedJobs,
+		"failed_jobs":      stats.FailedJobs,
+		"dead_letter_jobs": stats.DeadLetterJobs,
 		"worker_stats": map[string]interface{}{
 			"total_workers":  stats.WorkerStats.TotalWorkers,
 			"active_workers": stats.WorkerStats.ActiveWorkers,
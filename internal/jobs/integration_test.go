@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobFactory_BuiltinTypes(t *testing.T) {
+	factory := NewJobFactory(map[string]datasource.DataSource{
+		"mock": datasource.NewMockDataSource("mock", "Mock data source"),
+	})
+
+	assert.Equal(t, []JobType{JobTypeDownload, JobTypeEnrich, JobTypeExport, JobTypeFetch, JobTypeMaintenance, JobTypeSnapshot, JobTypeSync}, factory.RegisteredTypes())
+
+	job, err := factory.CreateJob(&JobStatus{
+		ID:       "sync-1",
+		Type:     JobTypeSync,
+		Priority: PriorityHigh,
+		Metadata: JobMetadata{"source_name": "mock", "batch_size": float64(50)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, JobTypeSync, job.Type())
+	assert.Equal(t, PriorityHigh, job.Priority())
+	assert.Equal(t, 50, job.Metadata()["batch_size"])
+
+	_, err = factory.CreateJob(&JobStatus{ID: "x", Type: JobType("unknown")})
+	assert.Error(t, err)
+}
+
+func TestJobFactory_RegisterJobType(t *testing.T) {
+	factory := NewJobFactory(nil)
+
+	err := factory.RegisterJobType("custom", func(status *JobStatus) (Job, error) {
+		return NewExportJob(status.ID, "SELECT 1", "csv", "out.csv"), nil
+	})
+	require.NoError(t, err)
+	assert.True(t, factory.IsRegistered("custom"))
+
+	job, err := factory.CreateJobFromConfig("custom-1", "custom", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "custom-1", job.ID())
+
+	assert.Error(t, factory.RegisterJobType("custom", func(status *JobStatus) (Job, error) { return nil, nil }))
+	assert.Error(t, factory.RegisterJobType(JobTypeDownload, func(status *JobStatus) (Job, error) { return nil, nil }))
+	assert.Error(t, factory.RegisterJobType("nil", nil))
+}
+
+func TestEnhancedJobManager_UnscheduleSource(t *testing.T) {
+	log.InitLogger(false)
+	ejm := &EnhancedJobManager{scheduler: NewJobScheduler(nil)}
+	_, err := ejm.ScheduleSourceSync("hackernews", "0 2 * * *")
+	require.NoError(t, err)
+	_, err = ejm.ScheduleItemRepair("hackernews", "0 3 * * *")
+	require.NoError(t, err)
+	_, err = ejm.ScheduleSourceSync("other", "0 4 * * *")
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, ejm.UnscheduleSource("hackernews"))
+	jobs := ejm.scheduler.ListScheduledJobs()
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "sync-other", jobs[0].ID)
+	assert.Equal(t, 0, ejm.UnscheduleSource("hackernews"))
+}
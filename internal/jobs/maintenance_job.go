@@ -0,0 +1,301 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// Maintenance operations supported by MaintenanceJob
+const (
+	MaintenanceOptimize        = "optimize"
+	MaintenanceRefreshDerived  = "refresh_derived"
+	MaintenanceRefreshUsers    = "refresh_users"
+	MaintenanceRepairItems     = "repair_items"
+	MaintenanceArchive         = "archive"
+	MaintenanceRedownloadItems = "redownload_items"
+)
+
+// DefaultArchiveAfterDays is the age in days after which items are archived
+// when no age is given
+const DefaultArchiveAfterDays = 365
+
+// userRefresher is implemented by data sources that keep user profiles, such
+// as Hacker News authors and their karma history
+type userRefresher interface {
+	RefreshUsers(ctx context.Context, progress func(done, total int64)) error
+}
+
+// itemRepairer is implemented by data sources that re-check items recorded
+// as dead, deleted or failed during download
+type itemRepairer interface {
+	RepairItems(ctx context.Context, progress func(done, total int64)) error
+}
+
+// itemRedownloader is implemented by data sources that can fetch specific
+// items again and update them in place
+type itemRedownloader interface {
+	RedownloadItems(ctx context.Context, ids []int64, progress func(done, total int64)) error
+}
+
+// itemArchiver is implemented by data sources that move old items into
+// compressed archive databases
+type itemArchiver interface {
+	ArchiveItems(ctx context.Context, olderThanDays int, progress func(done, total int64)) error
+}
+
+// MaintenanceJob runs a storage maintenance operation for a data source
+type MaintenanceJob struct {
+	id         string
+	operation  string
+	sourceName string
+	target     string
+	olderThan  int
+	dataSource datasource.DataSource
+	priority   JobPriority
+	metadata   JobMetadata
+	progress   JobProgress
+}
+
+// NewMaintenanceJob creates a new maintenance job
+func NewMaintenanceJob(id, operation, sourceName string, dataSource datasource.DataSource) *MaintenanceJob {
+	return &MaintenanceJob{
+		id:         id,
+		operation:  operation,
+		sourceName: sourceName,
+		dataSource: dataSource,
+		priority:   PriorityLow,
+		metadata: JobMetadata{
+			"operation":   operation,
+			"source_name": sourceName,
+		},
+		progress: JobProgress{
+			Current: 0,
+			Total:   1,
+			Message: "Waiting to start maintenance...",
+		},
+	}
+}
+
+// SetTarget sets the object the operation applies to, such as the derived
+// table to refresh or the item IDs to re-download
+func (mj *MaintenanceJob) SetTarget(target string) {
+	mj.target = target
+	mj.metadata["target"] = target
+}
+
+// SetOlderThanDays sets the age in days of the items an archive operation
+// moves
+func (mj *MaintenanceJob) SetOlderThanDays(days int) {
+	mj.olderThan = days
+	mj.metadata["older_than_days"] = days
+}
+
+// ID returns the job ID
+func (mj *MaintenanceJob) ID() string {
+	return mj.id
+}
+
+// Type returns the job type
+func (mj *MaintenanceJob) Type() JobType {
+	return JobTypeMaintenance
+}
+
+// Priority returns the job priority
+func (mj *MaintenanceJob) Priority() JobPriority {
+	return mj.priority
+}
+
+// SetPriority sets the job priority
+func (mj *MaintenanceJob) SetPriority(priority JobPriority) {
+	mj.priority = priority
+}
+
+// Description returns the job description
+func (mj *MaintenanceJob) Description() string {
+	if mj.operation == MaintenanceRedownloadItems {
+		return fmt.Sprintf("Re-download %s items %s", mj.sourceName, mj.target)
+	}
+	if mj.target != "" {
+		return fmt.Sprintf("Run %s maintenance on %s.%s", mj.operation, mj.sourceName, mj.target)
+	}
+	return fmt.Sprintf("Run %s maintenance on %s", mj.operation, mj.sourceName)
+}
+
+// Metadata returns the job metadata
+func (mj *MaintenanceJob) Metadata() JobMetadata {
+	return mj.metadata
+}
+
+// Execute runs the maintenance operation
+func (mj *MaintenanceJob) Execute(ctx context.Context, progressCallback ProgressCallback) error {
+	log.Logger.Infof("Starting %s maintenance for %s", mj.operation, mj.sourceName)
+
+	mj.progress.Message = fmt.Sprintf("Running %s...", mj.operation)
+	progressCallback(mj.progress)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	switch mj.operation {
+	case MaintenanceOptimize:
+		optimizer, ok := mj.dataSource.(interface{ Optimize() error })
+		if !ok {
+			return fmt.Errorf("data source %s does not support %s", mj.sourceName, mj.operation)
+		}
+		if err := optimizer.Optimize(); err != nil {
+			return fmt.Errorf("%s failed: %w", mj.operation, err)
+		}
+	case MaintenanceRefreshDerived:
+		if err := mj.refreshDerived(progressCallback); err != nil {
+			return fmt.Errorf("%s failed: %w", mj.operation, err)
+		}
+	case MaintenanceRefreshUsers:
+		refresher, ok := mj.dataSource.(userRefresher)
+		if !ok {
+			return fmt.Errorf("data source %s does not support %s", mj.sourceName, mj.operation)
+		}
+		err := refresher.RefreshUsers(ctx, func(done, total int64) {
+			mj.progress.Current = done
+			mj.progress.Total = total
+			mj.progress.Message = fmt.Sprintf("Fetched %d of %d user profiles", done, total)
+			progressCallback(mj.progress)
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", mj.operation, err)
+		}
+	case MaintenanceRepairItems:
+		repairer, ok := mj.dataSource.(itemRepairer)
+		if !ok {
+			return fmt.Errorf("data source %s does not support %s", mj.sourceName, mj.operation)
+		}
+		err := repairer.RepairItems(ctx, func(done, total int64) {
+			mj.progress.Current = done
+			mj.progress.Total = total
+			mj.progress.Message = fmt.Sprintf("Re-checked %d of %d items", done, total)
+			progressCallback(mj.progress)
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", mj.operation, err)
+		}
+	case MaintenanceRedownloadItems:
+		redownloader, ok := mj.dataSource.(itemRedownloader)
+		if !ok {
+			return fmt.Errorf("data source %s does not support %s", mj.sourceName, mj.operation)
+		}
+		ids, err := ParseItemIDs(mj.target)
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", mj.operation, err)
+		}
+		err = redownloader.RedownloadItems(ctx, ids, func(done, total int64) {
+			mj.progress.Current = done
+			mj.progress.Total = total
+			mj.progress.Message = fmt.Sprintf("Re-downloaded %d of %d items", done, total)
+			progressCallback(mj.progress)
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", mj.operation, err)
+		}
+	case MaintenanceArchive:
+		archiver, ok := mj.dataSource.(itemArchiver)
+		if !ok {
+			return fmt.Errorf("data source %s does not support %s", mj.sourceName, mj.operation)
+		}
+		days := mj.olderThan
+		if days <= 0 {
+			days = DefaultArchiveAfterDays
+		}
+		err := archiver.ArchiveItems(ctx, days, func(done, total int64) {
+			mj.progress.Current = done
+			mj.progress.Total = total
+			mj.progress.Message = fmt.Sprintf("Archived %d of %d items older than %d days", done, total, days)
+			progressCallback(mj.progress)
+		})
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", mj.operation, err)
+		}
+	default:
+		return fmt.Errorf("unknown maintenance operation: %s", mj.operation)
+	}
+
+	mj.progress.Current = mj.progress.Total
+	mj.progress.Message = fmt.Sprintf("%s completed", mj.operation)
+	progressCallback(mj.progress)
+
+	log.Logger.Infof("Completed %s maintenance for %s", mj.operation, mj.sourceName)
+	return nil
+}
+
+// refreshDerived rebuilds the target derived table, or every derived table
+// of the source when no target is set
+func (mj *MaintenanceJob) refreshDerived(progressCallback ProgressCallback) error {
+	provider, ok := mj.dataSource.(derivedTableProvider)
+	if !ok || provider.DerivedTables() == nil {
+		return fmt.Errorf("data source %s does not support derived tables", mj.sourceName)
+	}
+	derived := provider.DerivedTables()
+
+	names := []string{mj.target}
+	if mj.target == "" {
+		tables, err := derived.List()
+		if err != nil {
+			return err
+		}
+		names = names[:0]
+		for _, table := range tables {
+			names = append(names, table.Name)
+		}
+	}
+
+	mj.progress.Total = int64(len(names))
+	for i, name := range names {
+		count, err := derived.Refresh(name)
+		if err != nil {
+			return err
+		}
+		mj.progress.Current = int64(i + 1)
+		mj.progress.Message = fmt.Sprintf("Refreshed %s (%d rows)", name, count)
+		progressCallback(mj.progress)
+	}
+	return nil
+}
+
+// CanPause returns false since maintenance operations are short and atomic
+func (mj *MaintenanceJob) CanPause() bool {
+	return false
+}
+
+// Pause pauses the job
+func (mj *MaintenanceJob) Pause() error {
+	return fmt.Errorf("maintenance jobs cannot be paused")
+}
+
+// Resume resumes the job
+func (mj *MaintenanceJob) Resume(ctx context.Context) error {
+	return fmt.Errorf("maintenance jobs cannot be resumed")
+}
+
+// Progress returns the current job progress
+func (mj *MaintenanceJob) Progress() JobProgress {
+	return mj.progress
+}
+
+// Validate validates the job configuration
+func (mj *MaintenanceJob) Validate() error {
+	if mj.id == "" {
+		return fmt.Errorf("job ID cannot be empty")
+	}
+
+	if mj.operation == "" {
+		return fmt.Errorf("maintenance operation cannot be empty")
+	}
+
+	if mj.dataSource == nil {
+		return fmt.Errorf("data source cannot be nil")
+	}
+
+	return nil
+}
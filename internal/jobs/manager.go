@@ -3,16 +3,21 @@ package jobs
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/brainless/PubDataHub/internal/trash"
 )
 
 // Manager implements the JobManager interface
 type Manager struct {
 	persistence   *JobPersistence
 	workerPool    *WorkerPool
+	scaler        *PoolScaler // Resizes the worker pool with load; nil keeps its size fixed
 	jobs          map[string]*JobStatus
 	runningJobs   map[string]*JobExecution
 	pausedJobs    map[string]*JobExecution
@@ -22,6 +27,14 @@ type Manager struct {
 	config        ManagerConfig
 	eventHandlers []EventHandler
 	jobFactory    *JobFactory
+	diskGuard     *DiskGuard
+	quotaGuard    *QuotaGuard
+	estimators    map[string]*rateEstimator
+	runs          map[string]jobRun // Start of the current run of running jobs, for their metrics
+	bound         map[string]Job    // Submitted instances of bound jobs, run instead of factory-built ones
+	trash         *trash.Trash      // Keeps jobs removed by TrashMatching; nil deletes them
+	submitMux     sync.Mutex        // Serializes keyed submissions
+	sourceQueue   sourceQueue       // Jobs waiting for their data source's limits
 }
 
 // ManagerConfig holds configuration for the job manager
@@ -31,10 +44,42 @@ type ManagerConfig struct {
 	MaxRetries      int
 	RetryDelay      time.Duration
 	CleanupInterval time.Duration
-	JobTimeout      time.Duration
+	JobTimeout      time.Duration             // Running jobs are cancelled and failed after this long; 0 disables it
+	JobTimeouts     map[JobType]time.Duration // Timeouts of job types that override JobTimeout
 	PersistProgress bool
+	GracefulTimeout time.Duration
+	DrainPolicy     DrainPolicy
+	DiskGuard       DiskGuardConfig
+	QuotaGuard      QuotaGuardConfig
+	StallTimeout    time.Duration // Running jobs without progress for this long are flagged as stalled
+	Scaling         ScalingConfig // Resizes the worker pool between its bounds with load; MaxWorkers is the default upper bound
+	SourceLimits    SourceLimits  // Jobs of a type that may run at the same time per data source
 }
 
+// DrainPolicy decides what happens to jobs still running when a drain times out
+type DrainPolicy string
+
+const (
+	// DrainPolicyPause pauses remaining jobs so they resume on the next start
+	DrainPolicyPause DrainPolicy = "pause"
+	// DrainPolicyCancel cancels remaining jobs
+	DrainPolicyCancel DrainPolicy = "cancel"
+)
+
+// ParseDrainPolicy parses the name of a drain policy; empty names pause
+func ParseDrainPolicy(name string) (DrainPolicy, error) {
+	switch policy := DrainPolicy(strings.ToLower(name)); policy {
+	case "":
+		return DrainPolicyPause, nil
+	case DrainPolicyPause, DrainPolicyCancel:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid drain policy %q (supported: pause, cancel)", name)
+}
+
+// drainSettleTimeout bounds how long Drain waits for interrupted jobs to return
+const drainSettleTimeout = 5 * time.Second
+
 // DefaultManagerConfig returns default configuration
 func DefaultManagerConfig() ManagerConfig {
 	return ManagerConfig{
@@ -45,6 +90,12 @@ func DefaultManagerConfig() ManagerConfig {
 		CleanupInterval: time.Hour,
 		JobTimeout:      time.Hour * 2,
 		PersistProgress: true,
+		GracefulTimeout: 30 * time.Second,
+		DrainPolicy:     DrainPolicyPause,
+		DiskGuard:       DefaultDiskGuardConfig(),
+		QuotaGuard:      DefaultQuotaGuardConfig(),
+		StallTimeout:    DefaultStallTimeout,
+		Scaling:         DefaultScalingConfig(),
 	}
 }
 
@@ -60,6 +111,11 @@ func NewManager(storagePath string, config ManagerConfig) (*Manager, error) {
 		return nil, fmt.Errorf("failed to create job persistence: %w", err)
 	}
 
+	if err := config.SourceLimits.Validate(); err != nil {
+		persistence.Close()
+		return nil, fmt.Errorf("invalid source limits: %w", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	manager := &Manager{
@@ -71,10 +127,32 @@ func NewManager(storagePath string, config ManagerConfig) (*Manager, error) {
 		cancel:        cancel,
 		config:        config,
 		eventHandlers: make([]EventHandler, 0),
+		jobFactory:    NewJobFactory(nil),
+		estimators:    make(map[string]*rateEstimator),
+		runs:          make(map[string]jobRun),
+		bound:         make(map[string]Job),
 	}
 
-	// Create worker pool
-	manager.workerPool = NewWorkerPool(config.MaxWorkers, config.QueueSize, manager)
+	// Create worker pool, starting small when it scales with load
+	workers := config.MaxWorkers
+	if config.Scaling.Enabled {
+		if config.Scaling.MaxWorkers == 0 {
+			config.Scaling.MaxWorkers = config.MaxWorkers
+		}
+		if err := config.Scaling.Validate(); err != nil {
+			persistence.Close()
+			cancel()
+			return nil, fmt.Errorf("invalid worker scaling: %w", err)
+		}
+		workers = config.Scaling.MinWorkers
+		manager.config.Scaling = config.Scaling
+	}
+	manager.workerPool = NewWorkerPool(workers, config.QueueSize, manager)
+	if config.Scaling.Enabled {
+		manager.scaler = NewPoolScaler(manager.workerPool, config.Scaling)
+	}
+	manager.diskGuard = NewDiskGuard(manager, storagePath, config.DiskGuard)
+	manager.quotaGuard = NewQuotaGuard(manager, storagePath, config.QuotaGuard)
 
 	return manager, nil
 }
@@ -87,15 +165,31 @@ func (m *Manager) Start() error {
 	if err := m.workerPool.Start(); err != nil {
 		return fmt.Errorf("failed to start worker pool: %w", err)
 	}
+	if m.scaler != nil {
+		m.scaler.Start()
+	}
 
-	// Load existing jobs from persistence
+	// Load existing jobs from persistence and requeue the ones that were
+	// waiting for a worker
 	if err := m.loadExistingJobs(); err != nil {
 		log.Logger.Warnf("Failed to load existing jobs: %v", err)
 	}
+	m.requeueJobs()
 
 	// Start cleanup routine
 	go m.cleanupRoutine()
 
+	// Watch running jobs for stalls
+	if m.config.StallTimeout > 0 {
+		go m.stallCheckRoutine()
+	}
+
+	// Watch free disk space for download jobs
+	m.diskGuard.Start()
+
+	// Watch the storage used by data sources with a quota
+	m.quotaGuard.Start()
+
 	log.Logger.Info("Job manager started successfully")
 	return nil
 }
@@ -106,6 +200,11 @@ func (m *Manager) Stop() error {
 
 	// Cancel context
 	m.cancel()
+	m.diskGuard.Stop()
+	m.quotaGuard.Stop()
+	if m.scaler != nil {
+		m.scaler.Stop()
+	}
 
 	// Stop worker pool
 	if err := m.workerPool.Stop(); err != nil {
@@ -132,34 +231,72 @@ func (m *Manager) Stop() error {
 
 // SubmitJob submits a job for execution
 func (m *Manager) SubmitJob(job Job) (string, error) {
+	id, _, err := m.submitJob(job, "")
+	return id, err
+}
+
+// SubmitJobOnce submits a job for execution unless a job was already
+// submitted with the idempotency key, in this process or an earlier one. It
+// returns the ID of the job holding the key and whether this call submitted
+// it.
+func (m *Manager) SubmitJobOnce(job Job, key string) (string, bool, error) {
+	if key == "" {
+		return "", false, fmt.Errorf("idempotency key cannot be empty")
+	}
+	return m.submitJob(job, key)
+}
+
+// submitJob queues a job, persisting it first. A keyed job must be saved
+// before it runs so a restart neither loses nor repeats it.
+func (m *Manager) submitJob(job Job, key string) (string, bool, error) {
 	// Validate job
 	if err := job.Validate(); err != nil {
-		return "", fmt.Errorf("job validation failed: %w", err)
+		return "", false, fmt.Errorf("job validation failed: %w", err)
 	}
 
 	// Create job status
 	status := &JobStatus{
-		ID:          job.ID(),
-		Type:        job.Type(),
-		State:       JobStateQueued,
-		Priority:    job.Priority(),
-		Description: job.Description(),
-		StartTime:   time.Now(),
-		RetryCount:  0,
-		MaxRetries:  m.config.MaxRetries,
-		CreatedBy:   "system", // TODO: Get from context
-		Metadata:    job.Metadata(),
-		Progress:    job.Progress(),
+		ID:             job.ID(),
+		Type:           job.Type(),
+		State:          JobStateQueued,
+		Priority:       job.Priority(),
+		Description:    job.Description(),
+		StartTime:      time.Now(),
+		RetryCount:     0,
+		MaxRetries:     m.config.MaxRetries,
+		CreatedBy:      "system", // TODO: Get from context
+		Metadata:       job.Metadata(),
+		Progress:       job.Progress(),
+		IdempotencyKey: key,
+	}
+
+	if key != "" {
+		m.submitMux.Lock()
+		defer m.submitMux.Unlock()
+
+		id, err := m.persistence.InsertJob(status)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to persist job %s: %w", status.ID, err)
+		}
+		if id != status.ID {
+			log.Logger.Infof("Job %s not submitted: idempotency key %q is held by job %s", status.ID, key, id)
+			return id, false, nil
+		}
 	}
 
 	// Store job
 	m.jobsMux.Lock()
 	m.jobs[status.ID] = status
+	if bound, ok := job.(BoundJob); ok && bound.Bound() {
+		m.bound[status.ID] = job
+	}
 	m.jobsMux.Unlock()
 
 	// Persist job
-	if err := m.persistence.SaveJob(status); err != nil {
-		log.Logger.Warnf("Failed to persist job %s: %v", status.ID, err)
+	if key == "" {
+		if err := m.persistence.SaveJob(status); err != nil {
+			log.Logger.Warnf("Failed to persist job %s: %v", status.ID, err)
+		}
 	}
 
 	// Emit event
@@ -172,10 +309,10 @@ func (m *Manager) SubmitJob(job Job) (string, error) {
 
 	// Start job execution
 	if err := m.StartJob(status.ID); err != nil {
-		return status.ID, fmt.Errorf("failed to start job: %w", err)
+		return status.ID, true, fmt.Errorf("failed to start job: %w", err)
 	}
 
-	return status.ID, nil
+	return status.ID, true, nil
 }
 
 // StartJob starts a queued job
@@ -184,30 +321,53 @@ func (m *Manager) StartJob(id string) error {
 	status, exists := m.jobs[id]
 	if !exists {
 		m.jobsMux.RUnlock()
-		return fmt.Errorf("job not found: %s", id)
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
 	}
 
 	if status.State != JobStateQueued && status.State != JobStatePaused {
 		m.jobsMux.RUnlock()
-		return fmt.Errorf("job %s cannot be started (current state: %s)", id, status.State)
+		return invalidJobState("job %s cannot be started (current state: %s)", id, status.State)
 	}
+	jobType := status.Type
+	source := jobSource(status)
+	m.jobsMux.RUnlock()
 
+	// Hold download jobs while disk space is low or their source is over its
+	// storage quota
+	if err := m.diskGuard.Allow(id, jobType); err != nil {
+		return err
+	}
+	if err := m.quotaGuard.Allow(id, jobType, source); err != nil {
+		return err
+	}
+
+	m.jobsMux.RLock()
 	// Create job instance - this would need to be implemented based on job type
 	job, err := m.createJobInstance(status)
 	if err != nil {
 		m.jobsMux.RUnlock()
 		return fmt.Errorf("failed to create job instance: %w", err)
 	}
+	timeout := m.jobTimeout(status)
 	m.jobsMux.RUnlock()
 
 	// Create execution context
 	ctx, cancel := context.WithCancel(m.ctx)
 
-	execution := NewJobExecution(job, status, ctx, m.config.JobTimeout)
+	execution := NewJobExecution(job, status, ctx, timeout)
 	execution.cancel = cancel
 
-	// Store running job execution
+	// Store running job execution, unless its data source is at its limit
 	m.jobsMux.Lock()
+	if reason := m.sourceLimitReason(status); reason != "" {
+		status.WaitReason = reason
+		m.sourceQueue.hold(jobSource(status), status.ID)
+		m.jobsMux.Unlock()
+		cancel()
+		log.Logger.Infof("Job %s waiting: %s", status.ID, reason)
+		return nil
+	}
+	status.WaitReason = ""
 	m.runningJobs[status.ID] = execution
 	m.jobsMux.Unlock()
 
@@ -223,37 +383,23 @@ func (m *Manager) StartJob(id string) error {
 	return nil
 }
 
-// PauseJob pauses a running job
+// PauseJob pauses a running job, cancelling its execution so it can be
+// resumed later
 func (m *Manager) PauseJob(id string) error {
-	m.jobsMux.Lock()
-	defer m.jobsMux.Unlock()
-
+	m.jobsMux.RLock()
 	status, exists := m.jobs[id]
 	if !exists {
-		return fmt.Errorf("job not found: %s", id)
+		m.jobsMux.RUnlock()
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
 	}
 
 	if status.State != JobStateRunning {
-		return fmt.Errorf("job %s cannot be paused (current state: %s)", id, status.State)
-	}
-
-	// Update state
-	status.State = JobStatePaused
-
-	// Persist state
-	if err := m.persistence.SaveJob(status); err != nil {
-		log.Logger.Warnf("Failed to persist job pause: %v", err)
+		m.jobsMux.RUnlock()
+		return invalidJobState("job %s cannot be paused (current state: %s)", id, status.State)
 	}
+	m.jobsMux.RUnlock()
 
-	// Emit event
-	m.emitEvent(JobEvent{
-		JobID:     id,
-		EventType: EventJobPaused,
-		Timestamp: time.Now(),
-		Message:   fmt.Sprintf("Job %s paused", id),
-	})
-
-	return nil
+	return m.pauseExecution(id, fmt.Sprintf("Job %s paused", id))
 }
 
 // ResumeJob resumes a paused job
@@ -262,12 +408,12 @@ func (m *Manager) ResumeJob(id string) error {
 	status, exists := m.jobs[id]
 	if !exists {
 		m.jobsMux.RUnlock()
-		return fmt.Errorf("job not found: %s", id)
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
 	}
 
 	if status.State != JobStatePaused {
 		m.jobsMux.RUnlock()
-		return fmt.Errorf("job %s cannot be resumed (current state: %s)", id, status.State)
+		return invalidJobState("job %s cannot be resumed (current state: %s)", id, status.State)
 	}
 	m.jobsMux.RUnlock()
 
@@ -282,11 +428,11 @@ func (m *Manager) CancelJob(id string) error {
 
 	status, exists := m.jobs[id]
 	if !exists {
-		return fmt.Errorf("job not found: %s", id)
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
 	}
 
 	if status.IsFinished() {
-		return fmt.Errorf("job %s is already finished (state: %s)", id, status.State)
+		return invalidJobState("job %s is already finished (state: %s)", id, status.State)
 	}
 
 	// Cancel running job if it exists
@@ -301,6 +447,7 @@ func (m *Manager) CancelJob(id string) error {
 	status.State = JobStateCancelled
 	endTime := time.Now()
 	status.EndTime = &endTime
+	m.finishRun(status)
 
 	// Persist state
 	if err := m.persistence.SaveJob(status); err != nil {
@@ -322,6 +469,11 @@ func (m *Manager) CancelJob(id string) error {
 func (m *Manager) GetJob(id string) (*JobStatus, error) {
 	m.jobsMux.RLock()
 	status, exists := m.jobs[id]
+	var statusCopy JobStatus
+	if exists {
+		// Copy under the lock to avoid racing with state updates
+		statusCopy = *status
+	}
 	m.jobsMux.RUnlock()
 
 	if !exists {
@@ -331,7 +483,7 @@ func (m *Manager) GetJob(id string) (*JobStatus, error) {
 			return nil, fmt.Errorf("failed to load job from persistence: %w", err)
 		}
 		if persistedStatus == nil {
-			return nil, fmt.Errorf("job not found: %s", id)
+			return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
 		}
 
 		// Add to memory cache
@@ -342,8 +494,6 @@ func (m *Manager) GetJob(id string) (*JobStatus, error) {
 		return persistedStatus, nil
 	}
 
-	// Return a copy to avoid race conditions
-	statusCopy := *status
 	return &statusCopy, nil
 }
 
@@ -359,15 +509,15 @@ func (m *Manager) RetryJob(id string) error {
 
 	status, exists := m.jobs[id]
 	if !exists {
-		return fmt.Errorf("job not found: %s", id)
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
 	}
 
 	if status.State != JobStateFailed {
-		return fmt.Errorf("job %s cannot be retried (current state: %s)", id, status.State)
+		return invalidJobState("job %s cannot be retried (current state: %s)", id, status.State)
 	}
 
 	if status.RetryCount >= status.MaxRetries {
-		return fmt.Errorf("job %s has exceeded maximum retry count (%d)", id, status.MaxRetries)
+		return invalidJobState("job %s has exceeded maximum retry count (%d)", id, status.MaxRetries)
 	}
 
 	// Reset job state for retry
@@ -392,25 +542,15 @@ func (m *Manager) RetryJob(id string) error {
 	return nil
 }
 
-// CleanupJobs removes jobs matching the filter
+// CleanupJobs removes finished jobs matching the filter
 func (m *Manager) CleanupJobs(filter JobFilter) error {
-	jobs, err := m.ListJobs(filter)
+	result, err := m.CleanupMatching(filter)
 	if err != nil {
 		return fmt.Errorf("failed to list jobs for cleanup: %w", err)
 	}
-
-	m.jobsMux.Lock()
-	defer m.jobsMux.Unlock()
-
-	for _, job := range jobs {
-		if job.IsFinished() {
-			delete(m.jobs, job.ID)
-			if err := m.persistence.DeleteJob(job.ID); err != nil {
-				log.Logger.Warnf("Failed to delete job %s: %v", job.ID, err)
-			}
-		}
+	for id, err := range result.Failed {
+		log.Logger.Warnf("Failed to delete job %s: %v", id, err)
 	}
-
 	return nil
 }
 
@@ -422,15 +562,61 @@ func (m *Manager) GetStats() ManagerStats {
 		stats = ManagerStats{}
 	}
 
-	stats.WorkerStats = m.workerPool.GetStats()
+	stats.WorkerStats = m.WorkerStats()
 	return stats
 }
 
+// WorkerStats returns the worker pool statistics with its scaling bounds
+func (m *Manager) WorkerStats() WorkerPoolStats {
+	stats := m.workerPool.GetStats()
+	if m.scaler != nil {
+		stats.MinWorkers = m.config.Scaling.MinWorkers
+		stats.MaxWorkers = m.config.Scaling.MaxWorkers
+		stats.Pinned = m.scaler.IsPinned()
+	}
+	return stats
+}
+
+// SetWorkers sets the number of workers by hand, pausing auto-scaling until
+// AutoScaleWorkers is called
+func (m *Manager) SetWorkers(size int) error {
+	if err := m.workerPool.SetSize(size); err != nil {
+		return err
+	}
+	if m.scaler != nil {
+		m.scaler.Pin()
+	}
+	return nil
+}
+
+// AutoScaleWorkers resumes auto-scaling after SetWorkers
+func (m *Manager) AutoScaleWorkers() error {
+	if m.scaler == nil {
+		return fmt.Errorf("worker auto-scaling is not enabled")
+	}
+	m.scaler.Unpin()
+	return nil
+}
+
 // AddEventHandler adds an event handler
 func (m *Manager) AddEventHandler(handler EventHandler) {
 	m.eventHandlers = append(m.eventHandlers, handler)
 }
 
+// SetJobFactory replaces the factory used to create job instances
+func (m *Manager) SetJobFactory(factory *JobFactory) {
+	m.jobsMux.Lock()
+	defer m.jobsMux.Unlock()
+	m.jobFactory = factory
+}
+
+// JobFactory returns the factory used to create job instances
+func (m *Manager) JobFactory() *JobFactory {
+	m.jobsMux.RLock()
+	defer m.jobsMux.RUnlock()
+	return m.jobFactory
+}
+
 // Helper methods
 
 // loadExistingJobs loads jobs from persistence on startup
@@ -449,6 +635,19 @@ func (m *Manager) loadExistingJobs() error {
 	defer m.jobsMux.Unlock()
 
 	for _, job := range jobs {
+		// A job left running by a previous process was interrupted; mark it
+		// paused so it can be rehydrated and resumed
+		if job.State == JobStateRunning {
+			job.State = JobStatePaused
+			if err := m.persistence.SaveJob(job); err != nil {
+				log.Logger.Warnf("Failed to persist interrupted job %s: %v", job.ID, err)
+			}
+		}
+
+		if m.jobFactory != nil && !m.jobFactory.IsRegistered(job.Type) {
+			log.Logger.Warnf("Loaded job %s has unregistered type %s and cannot be resumed", job.ID, job.Type)
+		}
+
 		m.jobs[job.ID] = job
 		log.Logger.Infof("Loaded job %s (state: %s)", job.ID, job.State)
 	}
@@ -457,8 +656,38 @@ func (m *Manager) loadExistingJobs() error {
 	return nil
 }
 
+// requeueJobs starts the loaded jobs still queued, highest priority and
+// oldest first. They were saved before they ran, so each runs once.
+func (m *Manager) requeueJobs() {
+	m.jobsMux.RLock()
+	var queued []*JobStatus
+	for _, status := range m.jobs {
+		if status.State == JobStateQueued {
+			queued = append(queued, status)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		if queued[i].Priority != queued[j].Priority {
+			return queued[i].Priority > queued[j].Priority
+		}
+		return queued[i].StartTime.Before(queued[j].StartTime)
+	})
+	m.jobsMux.RUnlock()
+
+	for _, status := range queued {
+		if err := m.StartJob(status.ID); err != nil {
+			log.Logger.Warnf("Failed to requeue job %s: %v", status.ID, err)
+			continue
+		}
+		log.Logger.Infof("Requeued job %s", status.ID)
+	}
+}
+
 // createJobInstance creates a job instance based on job status
 func (m *Manager) createJobInstance(status *JobStatus) (Job, error) {
+	if job, ok := m.bound[status.ID]; ok {
+		return job, nil
+	}
 	if m.jobFactory == nil {
 		return nil, fmt.Errorf("job factory not configured")
 	}
@@ -475,13 +704,32 @@ func (m *Manager) updateJobState(id string, state JobState, errorMessage string)
 		return
 	}
 
+	wasRunning := status.State == JobStateRunning
 	status.State = state
 	status.ErrorMessage = errorMessage
 
+	// Rates are measured per run so pauses do not drag the average down
+	if state == JobStateRunning {
+		m.estimators[id] = newRateEstimator(time.Now())
+	} else if state.IsFinished() {
+		delete(m.estimators, id)
+	}
+
+	// Each run is recorded in the metrics history when it stops
+	if state == JobStateRunning && !wasRunning {
+		m.startRun(status)
+	} else if wasRunning && state != JobStateRunning {
+		m.finishRun(status)
+	}
+
 	if state.IsFinished() {
 		endTime := time.Now()
 		status.EndTime = &endTime
 	}
+	// Failed jobs may be retried with their bound instance
+	if state == JobStateCompleted || state == JobStateCancelled || state == JobStateDeadLetter {
+		delete(m.bound, id)
+	}
 
 	// Persist state
 	if err := m.persistence.SaveJob(status); err != nil {
@@ -499,6 +747,21 @@ func (m *Manager) updateJobProgress(id string, progress JobProgress) {
 		return
 	}
 
+	// Derive rate, ETA and stall state from the job's recent samples
+	wasStalled := status.Progress.Stalled
+	if estimator, exists := m.estimators[id]; exists {
+		now := time.Now()
+		estimator.observe(progress.Current, now)
+		progress.Rate = estimator.rate()
+		if eta := estimator.eta(progress.Current, progress.Total); eta != nil {
+			progress.ETA = eta
+		}
+		progress.Stalled = m.config.StallTimeout > 0 && estimator.stalledFor(now) >= m.config.StallTimeout
+		if progress.Stalled && !wasStalled {
+			m.emitStalled(id, estimator.stalledFor(now))
+		}
+	}
+
 	status.Progress = progress
 
 	// Persist progress if enabled
@@ -509,15 +772,72 @@ func (m *Manager) updateJobProgress(id string, progress JobProgress) {
 	}
 
 	// Emit progress event
+	data := JobMetadata{
+		"current":    progress.Current,
+		"total":      progress.Total,
+		"percentage": progress.Percentage(),
+		"rate":       progress.Rate,
+		"stalled":    progress.Stalled,
+	}
+	if progress.ETA != nil {
+		data["eta_seconds"] = int64(progress.ETA.Seconds())
+	}
 	m.emitEvent(JobEvent{
 		JobID:     id,
 		EventType: EventJobProgress,
 		Timestamp: time.Now(),
 		Message:   progress.Message,
+		Data:      data,
+	})
+}
+
+// stallCheckRoutine flags running jobs that stop reporting progress
+func (m *Manager) stallCheckRoutine() {
+	ticker := time.NewTicker(m.config.StallTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkStalledJobs()
+		}
+	}
+}
+
+// checkStalledJobs marks running jobs without recent progress as stalled
+func (m *Manager) checkStalledJobs() {
+	m.jobsMux.Lock()
+	defer m.jobsMux.Unlock()
+
+	now := time.Now()
+	for id, estimator := range m.estimators {
+		status, exists := m.jobs[id]
+		if !exists || status.State != JobStateRunning || status.Progress.Stalled {
+			continue
+		}
+		if stalled := estimator.stalledFor(now); stalled >= m.config.StallTimeout {
+			status.Progress.Stalled = true
+			status.Progress.Rate = 0
+			m.emitStalled(id, stalled)
+		}
+	}
+}
+
+// emitStalled emits a stall warning for a job; callers hold jobsMux
+func (m *Manager) emitStalled(id string, stalled time.Duration) {
+	reason := formatStall(stalled)
+	message := fmt.Sprintf("Job %s stalled: %s", id, reason)
+	log.Logger.Warn(message)
+	m.emitEvent(JobEvent{
+		JobID:     id,
+		EventType: EventJobStalled,
+		Timestamp: time.Now(),
+		Message:   message,
 		Data: JobMetadata{
-			"current":    progress.Current,
-			"total":      progress.Total,
-			"percentage": progress.Percentage(),
+			"reason":          reason,
+			"stalled_seconds": int64(stalled.Seconds()),
 		},
 	})
 }
@@ -541,11 +861,24 @@ func (m *Manager) handleJobCompletion(id string) {
 
 // handleJobFailure handles job failure
 func (m *Manager) handleJobFailure(id string, err error) {
-	m.updateJobState(id, JobStateFailed, err.Error())
+	// Jobs out of retries move to the dead-letter queue
+	m.jobsMux.RLock()
+	state, retries := JobStateFailed, 0
+	if status, exists := m.jobs[id]; exists {
+		retries = status.RetryCount
+		if retries >= status.MaxRetries {
+			state = JobStateDeadLetter
+		}
+	}
+	m.jobsMux.RUnlock()
+	m.updateJobState(id, state, err.Error())
 
 	// Remove from running jobs
 	m.jobsMux.Lock()
 	delete(m.runningJobs, id)
+	if status, exists := m.jobs[id]; exists {
+		status.ErrorCount++
+	}
 	m.jobsMux.Unlock()
 
 	m.emitEvent(JobEvent{
@@ -554,11 +887,16 @@ func (m *Manager) handleJobFailure(id string, err error) {
 		Timestamp: time.Now(),
 		Message:   fmt.Sprintf("Job %s failed: %v", id, err),
 		Data: JobMetadata{
-			"error": err.Error(),
+			"error":   err.Error(),
+			"attempt": retries + 1,
 		},
 	})
 
-	// TODO: Implement retry logic with exponential backoff
+	if state == JobStateDeadLetter {
+		m.deadLetter(id, retries+1, err)
+	} else {
+		m.scheduleRetry(id, retries)
+	}
 }
 
 // emitEvent emits an event to all handlers
@@ -584,7 +922,8 @@ func (m *Manager) cleanupRoutine() {
 		case <-m.ctx.Done():
 			return
 		case <-ticker.C:
-			// Cleanup completed jobs older than 24 hours
+			// Cleanup completed jobs older than 24 hours; dead-letter
+			// jobs stay until requeued or removed by hand
 			cutoff := time.Now().Add(-24 * time.Hour)
 			filter := JobFilter{
 				States:        []JobState{JobStateCompleted, JobStateFailed, JobStateCancelled},
@@ -662,6 +1001,160 @@ func (m *Manager) GetRunningJobs() []string {
 	return runningJobs
 }
 
+// WorkerPool returns the worker pool executing the manager's jobs
+func (m *Manager) WorkerPool() *WorkerPool {
+	return m.workerPool
+}
+
+// RecoverableDatabase returns the jobs database for the startup integrity
+// check and repair
+func (m *Manager) RecoverableDatabase() *storage.RecoverableDB {
+	return storage.NewRecoverableDB(m.persistence.db)
+}
+
+// DiskGuard returns the guard that pauses download jobs on low disk space
+func (m *Manager) DiskGuard() *DiskGuard {
+	return m.diskGuard
+}
+
+// QuotaGuard returns the guard that pauses download jobs of data sources
+// over their storage quota
+func (m *Manager) QuotaGuard() *QuotaGuard {
+	return m.quotaGuard
+}
+
+// Drain stops accepting jobs and waits up to GracefulTimeout for running jobs
+// to finish; jobs still running afterwards are paused or cancelled according
+// to DrainPolicy so that their state can be resumed on the next start
+func (m *Manager) Drain(ctx context.Context) error {
+	if err := m.workerPool.StopAcceptingTasks(); err != nil {
+		return fmt.Errorf("failed to stop accepting jobs: %w", err)
+	}
+
+	waitCtx := ctx
+	if m.config.GracefulTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, m.config.GracefulTimeout)
+		defer cancel()
+	}
+
+	log.Logger.Infof("Draining %d running jobs", len(m.GetRunningJobs()))
+	err := m.workerPool.WaitForCompletion(waitCtx)
+	if err == nil {
+		log.Logger.Info("All running jobs finished during drain")
+		return nil
+	}
+	log.Logger.Warnf("Drain timed out, applying %s policy: %v", m.config.DrainPolicy, err)
+
+	m.interruptRunningJobs(m.config.DrainPolicy)
+
+	settleCtx, cancel := context.WithTimeout(context.Background(), drainSettleTimeout)
+	defer cancel()
+	if err := m.workerPool.WaitForCompletion(settleCtx); err != nil {
+		return fmt.Errorf("jobs did not stop after drain: %w", err)
+	}
+
+	return nil
+}
+
+// Shutdown drains the running jobs and stops the manager. Unlike Stop, which
+// cancels them, jobs still running after GracefulTimeout are paused or
+// cancelled according to DrainPolicy.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	if err := m.Drain(ctx); err != nil {
+		log.Logger.Warnf("Failed to drain jobs: %v", err)
+	}
+	return m.Stop()
+}
+
+// interruptRunningJobs pauses or cancels every job that still has an execution
+func (m *Manager) interruptRunningJobs(policy DrainPolicy) {
+	m.jobsMux.RLock()
+	ids := make([]string, 0, len(m.runningJobs))
+	for id := range m.runningJobs {
+		ids = append(ids, id)
+	}
+	m.jobsMux.RUnlock()
+
+	for _, id := range ids {
+		var err error
+		if policy == DrainPolicyCancel {
+			err = m.CancelJob(id)
+		} else {
+			err = m.pauseExecution(id, fmt.Sprintf("Job %s paused for shutdown", id))
+		}
+		if err != nil {
+			log.Logger.Warnf("Failed to interrupt job %s: %v", id, err)
+		}
+	}
+}
+
+// pauseExecution marks a job as paused and cancels its execution context
+func (m *Manager) pauseExecution(id, message string) error {
+	m.jobsMux.Lock()
+	defer m.jobsMux.Unlock()
+
+	status, exists := m.jobs[id]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+
+	if execution, exists := m.runningJobs[id]; exists && execution.cancel != nil {
+		execution.cancel()
+	}
+
+	// Jobs that never started stay queued
+	if status.State != JobStateRunning {
+		return nil
+	}
+
+	status.State = JobStatePaused
+	m.finishRun(status)
+	if err := m.persistence.SaveJob(status); err != nil {
+		log.Logger.Warnf("Failed to persist job pause: %v", err)
+	}
+
+	m.emitEvent(JobEvent{
+		JobID:     id,
+		EventType: EventJobPaused,
+		Timestamp: time.Now(),
+		Message:   message,
+	})
+
+	return nil
+}
+
+// isInterrupted reports whether a job was paused or cancelled while executing
+func (m *Manager) isInterrupted(id string) bool {
+	m.jobsMux.RLock()
+	defer m.jobsMux.RUnlock()
+
+	status, exists := m.jobs[id]
+	if !exists {
+		return false
+	}
+	return status.State == JobStatePaused || status.State == JobStateCancelled
+}
+
+// releaseExecution forgets a job's execution without changing its state,
+// unless the job has already been restarted with a new execution
+func (m *Manager) releaseExecution(execution *JobExecution) {
+	id := execution.Status.ID
+	m.jobsMux.Lock()
+	if current, exists := m.runningJobs[id]; exists && current == execution {
+		delete(m.runningJobs, id)
+	}
+	m.jobsMux.Unlock()
+}
+
+// executionEnded starts the jobs waiting for the data source of an
+// execution that left the worker pool
+func (m *Manager) executionEnded(execution *JobExecution) {
+	if source := jobSource(execution.Status); source != "" {
+		m.startWaitingJobs(source)
+	}
+}
+
 // Recovery methods for application restart support
 
 // LoadJobStates loads job states from persistence
@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobPersistence_InsertJob(t *testing.T) {
+	persistence, err := NewJobPersistence(t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { persistence.Close() })
+
+	first := &JobStatus{ID: "job-1", Type: "blocking", State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{}, IdempotencyKey: "key"}
+	id, err := persistence.InsertJob(first)
+	require.NoError(t, err)
+	assert.Equal(t, "job-1", id)
+
+	second := &JobStatus{ID: "job-2", Type: "blocking", State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{}, IdempotencyKey: "key"}
+	id, err = persistence.InsertJob(second)
+	require.NoError(t, err)
+	assert.Equal(t, "job-1", id)
+
+	missing, err := persistence.LoadJob("job-2")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	loaded, err := persistence.LoadJob("job-1")
+	require.NoError(t, err)
+	assert.Equal(t, "key", loaded.IdempotencyKey)
+
+	// Jobs without a key do not collide
+	for _, id := range []string{"job-3", "job-4"} {
+		_, err := persistence.InsertJob(&JobStatus{ID: id, Type: "blocking", State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{}})
+		require.NoError(t, err)
+	}
+}
+
+func TestJobPersistence_MigratesIdempotencyKeys(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := storage.DatabasePath(dir, DatabaseFile)
+	require.NoError(t, storage.PrepareDatabase(dbPath))
+	db, err := sql.Open("sqlite3", dbPath)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE jobs (
+		id TEXT PRIMARY KEY, type TEXT NOT NULL, state TEXT NOT NULL, priority INTEGER NOT NULL,
+		description TEXT NOT NULL, created_by TEXT NOT NULL, start_time DATETIME NOT NULL, end_time DATETIME,
+		error_message TEXT, retry_count INTEGER DEFAULT 0, max_retries INTEGER DEFAULT 3,
+		metadata TEXT NOT NULL DEFAULT '{}', created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	persistence, err := NewJobPersistence(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { persistence.Close() })
+
+	_, err = persistence.InsertJob(&JobStatus{ID: "job-1", Type: "blocking", State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{}, IdempotencyKey: "key"})
+	require.NoError(t, err)
+	loaded, err := persistence.LoadJob("job-1")
+	require.NoError(t, err)
+	assert.Equal(t, "key", loaded.IdempotencyKey)
+}
+
+func TestManager_RequeuesAndDeduplicatesAfterRestart(t *testing.T) {
+	log.InitLogger(false)
+	dir := t.TempDir()
+
+	// A keyed job saved by a process that died before running it
+	persistence, err := NewJobPersistence(dir)
+	require.NoError(t, err)
+	_, err = persistence.InsertJob(&JobStatus{ID: "job-1", Type: "blocking", State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{}, IdempotencyKey: "sync@1"})
+	require.NoError(t, err)
+	require.NoError(t, persistence.Close())
+
+	config := DefaultManagerConfig()
+	config.MaxWorkers = 1
+	manager, err := NewManager(dir, config)
+	require.NoError(t, err)
+	started := make(chan struct{})
+	require.NoError(t, manager.JobFactory().RegisterJobType("blocking", func(status *JobStatus) (Job, error) {
+		return &blockingJob{MaintenanceJob: NewMaintenanceJob(status.ID, MaintenanceOptimize, "mock", nil), started: started}, nil
+	}))
+	require.NoError(t, manager.Start())
+	t.Cleanup(func() { manager.Stop() })
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("queued job was not requeued")
+	}
+
+	// Submitting the same work again returns the requeued job
+	id, submitted, err := manager.SubmitJobOnce(&ScheduledJobExecution{id: "job-2", jobType: "blocking", metadata: JobMetadata{}}, "sync@1")
+	require.NoError(t, err)
+	assert.False(t, submitted)
+	assert.Equal(t, "job-1", id)
+	_, err = manager.GetJob("job-2")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	_, _, err = manager.SubmitJobOnce(&ScheduledJobExecution{id: "job-3", jobType: "blocking", metadata: JobMetadata{}}, "")
+	assert.Error(t, err)
+}
+
+func TestManager_ControlErrors(t *testing.T) {
+	log.InitLogger(false)
+	manager, err := NewManager(t.TempDir(), DefaultManagerConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.Stop() })
+
+	assert.ErrorIs(t, manager.PauseJob("missing"), ErrJobNotFound)
+	assert.ErrorIs(t, manager.CancelJob("missing"), ErrJobNotFound)
+
+	manager.jobsMux.Lock()
+	manager.jobs["job-1"] = &JobStatus{ID: "job-1", Type: "blocking", State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{}}
+	manager.jobsMux.Unlock()
+
+	err = manager.ResumeJob("job-1")
+	assert.ErrorIs(t, err, ErrInvalidJobState)
+	assert.EqualError(t, err, "job job-1 cannot be resumed (current state: queued)")
+	assert.ErrorIs(t, manager.RetryJob("job-1"), ErrInvalidJobState)
+
+	require.NoError(t, manager.CancelJob("job-1"))
+	assert.ErrorIs(t, manager.CancelJob("job-1"), ErrInvalidJobState)
+}
+
+// boundTestJob is a job of a type the factory cannot build
+type boundTestJob struct {
+	*MaintenanceJob
+}
+
+func (bj *boundTestJob) Type() JobType   { return "session" }
+func (bj *boundTestJob) Bound() bool     { return true }
+func (bj *boundTestJob) Validate() error { return nil }
+
+func (bj *boundTestJob) Execute(ctx context.Context, progressCallback ProgressCallback) error {
+	return nil
+}
+
+func TestManager_RunsBoundJobs(t *testing.T) {
+	log.InitLogger(false)
+	manager, err := NewManager(t.TempDir(), DefaultManagerConfig())
+	require.NoError(t, err)
+	require.NoError(t, manager.Start())
+	t.Cleanup(func() { manager.Stop() })
+
+	job := &boundTestJob{MaintenanceJob: NewMaintenanceJob("bound-1", MaintenanceOptimize, "mock", nil)}
+	id, err := manager.SubmitJob(job)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		status, err := manager.GetJob(id)
+		return err == nil && status.State == JobStateCompleted
+	}, 2*time.Second, 10*time.Millisecond)
+
+	manager.jobsMux.RLock()
+	defer manager.jobsMux.RUnlock()
+	assert.NotContains(t, manager.bound, id, "finished jobs release their instance")
+}
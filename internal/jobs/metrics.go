@@ -0,0 +1,133 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// metricsHistoryLimit is the number of runs kept per data source in the
+// metrics history
+const metricsHistoryLimit = 1000
+
+// MetricsFilter selects recorded job runs
+type MetricsFilter struct {
+	DataSource string
+	JobType    string
+	Since      time.Time
+	Limit      int // Latest runs to return; 0 returns all
+}
+
+// jobRun is the start of a job's current run
+type jobRun struct {
+	start   time.Time
+	current int64 // Progress when the run started, so resumed runs count only their own items
+}
+
+// startRun notes that a job started running; callers hold jobsMux
+func (m *Manager) startRun(status *JobStatus) {
+	m.runs[status.ID] = jobRun{start: time.Now(), current: status.Progress.Current}
+}
+
+// finishRun records the metrics of a job's run when it stops running, in
+// the state already set on status; callers hold jobsMux
+func (m *Manager) finishRun(status *JobStatus) {
+	run, exists := m.runs[status.ID]
+	if !exists {
+		return
+	}
+	delete(m.runs, status.ID)
+
+	if err := m.persistence.SaveRunMetrics(runMetrics(status, run, time.Now())); err != nil {
+		log.Logger.Warnf("Failed to save metrics of job %s: %v", status.ID, err)
+	}
+}
+
+// runMetrics returns the metrics of a run that ended at end
+func runMetrics(status *JobStatus, run jobRun, end time.Time) storage.JobMetrics {
+	sourceName, _ := status.Metadata["source_name"].(string)
+	processed := status.Progress.Current - run.current
+	if processed < 0 {
+		processed = 0
+	}
+
+	metrics := storage.JobMetrics{
+		JobID:          status.ID,
+		JobType:        string(status.Type),
+		DataSource:     sourceName,
+		StartTime:      run.start,
+		EndTime:        &end,
+		Duration:       end.Sub(run.start),
+		TotalItems:     status.Progress.Total,
+		ProcessedItems: processed,
+		Status:         string(status.State),
+	}
+	if seconds := metrics.Duration.Seconds(); seconds > 0 {
+		metrics.ItemsPerSecond = float64(processed) / seconds
+	}
+	return metrics
+}
+
+// RunMetrics returns the recorded runs matching filter, oldest first
+func (m *Manager) RunMetrics(filter MetricsFilter) ([]storage.JobMetrics, error) {
+	return m.persistence.LoadRunMetrics(filter)
+}
+
+// RunFailed reports whether a recorded run ended in failure
+func RunFailed(run storage.JobMetrics) bool {
+	return run.Status == string(JobStateFailed) || run.Status == string(JobStateDeadLetter)
+}
+
+// MetricsSummary summarizes recorded job runs
+type MetricsSummary struct {
+	Runs                  int     `json:"runs"`
+	Failed                int     `json:"failed"`
+	ErrorRate             float64 `json:"error_rate"` // Share of runs that failed
+	Items                 int64   `json:"items"`
+	AverageItemsPerSecond float64 `json:"average_items_per_second"` // Items over the time spent running
+}
+
+// SummarizeRuns summarizes recorded job runs
+func SummarizeRuns(runs []storage.JobMetrics) MetricsSummary {
+	summary := MetricsSummary{Runs: len(runs)}
+	var running time.Duration
+	for _, run := range runs {
+		if RunFailed(run) {
+			summary.Failed++
+		}
+		summary.Items += run.ProcessedItems
+		running += run.Duration
+	}
+	if summary.Runs > 0 {
+		summary.ErrorRate = float64(summary.Failed) / float64(summary.Runs)
+	}
+	if running > 0 {
+		summary.AverageItemsPerSecond = float64(summary.Items) / running.Seconds()
+	}
+	return summary
+}
+
+// RollingErrorRates returns for each run the share of failed runs among it
+// and the window-1 runs before it, so a trend of run outcomes can be drawn
+func RollingErrorRates(runs []storage.JobMetrics, window int) []float64 {
+	if window < 1 {
+		window = 1
+	}
+	rates := make([]float64, len(runs))
+	failed := 0
+	for i, run := range runs {
+		if RunFailed(run) {
+			failed++
+		}
+		if i >= window && RunFailed(runs[i-window]) {
+			failed--
+		}
+		size := window
+		if i+1 < window {
+			size = i + 1
+		}
+		rates[i] = float64(failed) / float64(size)
+	}
+	return rates
+}
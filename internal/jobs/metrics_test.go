@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_RecordsRunMetrics(t *testing.T) {
+	log.InitLogger(false)
+	manager, err := NewManager(t.TempDir(), DefaultManagerConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.Stop() })
+
+	manager.jobsMux.Lock()
+	manager.jobs["job-1"] = &JobStatus{ID: "job-1", Type: JobTypeDownload, State: JobStateQueued, StartTime: time.Now(),
+		Progress: JobProgress{Current: 100, Total: 1000}, Metadata: JobMetadata{"source_name": "hackernews"}}
+	manager.jobs["job-2"] = &JobStatus{ID: "job-2", Type: JobTypeExport, State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{}}
+	manager.jobsMux.Unlock()
+
+	// A resumed run counts only the items it processed itself
+	manager.updateJobState("job-1", JobStateRunning, "")
+	manager.runs["job-1"] = jobRun{start: time.Now().Add(-10 * time.Second), current: 100}
+	manager.updateJobProgress("job-1", JobProgress{Current: 600, Total: 1000})
+	manager.updateJobState("job-1", JobStateFailed, "boom")
+
+	manager.updateJobState("job-2", JobStateRunning, "")
+	manager.updateJobState("job-2", JobStateCompleted, "")
+
+	runs, err := manager.RunMetrics(MetricsFilter{DataSource: "hackernews"})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "job-1", runs[0].JobID)
+	assert.Equal(t, string(JobTypeDownload), runs[0].JobType)
+	assert.Equal(t, string(JobStateFailed), runs[0].Status)
+	assert.Equal(t, int64(500), runs[0].ProcessedItems)
+	assert.Equal(t, int64(1000), runs[0].TotalItems)
+	assert.InDelta(t, 50, runs[0].ItemsPerSecond, 1)
+	assert.InDelta(t, 10, runs[0].Duration.Seconds(), 1)
+
+	all, err := manager.RunMetrics(MetricsFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "job-2", all[1].JobID, "runs are returned oldest first")
+
+	latest, err := manager.RunMetrics(MetricsFilter{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, latest, 1)
+	assert.Equal(t, "job-2", latest[0].JobID)
+}
+
+func TestSummarizeRuns(t *testing.T) {
+	runs := []storage.JobMetrics{
+		{Status: string(JobStateCompleted), ProcessedItems: 100, Duration: 10 * time.Second},
+		{Status: string(JobStateFailed), ProcessedItems: 20, Duration: 10 * time.Second},
+		{Status: string(JobStatePaused), ProcessedItems: 80, Duration: 20 * time.Second},
+		{Status: string(JobStateDeadLetter)},
+	}
+
+	summary := SummarizeRuns(runs)
+	assert.Equal(t, 4, summary.Runs)
+	assert.Equal(t, 2, summary.Failed)
+	assert.Equal(t, 0.5, summary.ErrorRate)
+	assert.Equal(t, int64(200), summary.Items)
+	assert.Equal(t, 5.0, summary.AverageItemsPerSecond)
+
+	assert.Equal(t, []float64{0, 0.5, 0.5, 0.5}, RollingErrorRates(runs, 2))
+	assert.Equal(t, []float64{0, 0.5, 1.0 / 3, 0.5}, RollingErrorRates(runs, 4))
+	assert.Empty(t, RollingErrorRates(nil, 5))
+}
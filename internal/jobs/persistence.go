@@ -4,10 +4,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/brainless/PubDataHub/internal/storage"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -17,11 +17,18 @@ type JobPersistence struct {
 	path string
 }
 
+// DatabaseFile is the name of the jobs database in the storage directory
+const DatabaseFile = "jobs.db"
+
 // NewJobPersistence creates a new job persistence manager
 func NewJobPersistence(storagePath string) (*JobPersistence, error) {
-	dbPath := filepath.Join(storagePath, "jobs.db")
+	dbPath := storage.DatabasePath(storagePath, DatabaseFile)
+
+	if err := storage.PrepareDatabase(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open jobs database: %w", err)
+	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", storage.DatabaseDSN(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open jobs database: %w", err)
 	}
@@ -55,6 +62,7 @@ func (jp *JobPersistence) initializeTables() error {
 			retry_count INTEGER DEFAULT 0,
 			max_retries INTEGER DEFAULT 3,
 			metadata TEXT NOT NULL DEFAULT '{}',
+			idempotency_key TEXT,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
@@ -76,12 +84,36 @@ func (jp *JobPersistence) initializeTables() error {
 			data TEXT DEFAULT '{}',
 			FOREIGN KEY (job_id) REFERENCES jobs (id) ON DELETE CASCADE
 		)`,
+		`CREATE TABLE IF NOT EXISTS schedule_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			schedule_id TEXT NOT NULL,
+			scheduled_for DATETIME NOT NULL,
+			until DATETIME,
+			outcome TEXT NOT NULL,
+			missed INTEGER NOT NULL DEFAULT 0,
+			job_id TEXT NOT NULL DEFAULT '',
+			recorded_at DATETIME NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS job_metrics (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			job_id TEXT NOT NULL,
+			job_type TEXT NOT NULL,
+			data_source TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			start_time DATETIME NOT NULL,
+			end_time DATETIME NOT NULL,
+			total_items INTEGER NOT NULL DEFAULT 0,
+			processed_items INTEGER NOT NULL DEFAULT 0,
+			items_per_second REAL NOT NULL DEFAULT 0
+		)`,
 		`CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs (state)`,
 		`CREATE INDEX IF NOT EXISTS idx_jobs_type ON jobs (type)`,
 		`CREATE INDEX IF NOT EXISTS idx_jobs_created_by ON jobs (created_by)`,
 		`CREATE INDEX IF NOT EXISTS idx_jobs_start_time ON jobs (start_time)`,
 		`CREATE INDEX IF NOT EXISTS idx_job_events_job_id ON job_events (job_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_job_events_timestamp ON job_events (timestamp)`,
+		`CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule ON schedule_runs (schedule_id, scheduled_for)`,
+		`CREATE INDEX IF NOT EXISTS idx_job_metrics_source ON job_metrics (data_source, start_time)`,
 	}
 
 	for _, query := range queries {
@@ -90,22 +122,85 @@ func (jp *JobPersistence) initializeTables() error {
 		}
 	}
 
+	return jp.migrateIdempotencyKeys()
+}
+
+// migrateIdempotencyKeys adds the idempotency_key column to databases
+// created before it existed, and the index that keeps keys unique
+func (jp *JobPersistence) migrateIdempotencyKeys() error {
+	var hasColumn int
+	if err := jp.db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('jobs') WHERE name = 'idempotency_key'").Scan(&hasColumn); err != nil {
+		return fmt.Errorf("failed to inspect jobs table: %w", err)
+	}
+	if hasColumn == 0 {
+		if _, err := jp.db.Exec("ALTER TABLE jobs ADD COLUMN idempotency_key TEXT"); err != nil {
+			return fmt.Errorf("failed to add idempotency_key column: %w", err)
+		}
+	}
+	if _, err := jp.db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_idempotency_key ON jobs (idempotency_key) WHERE idempotency_key IS NOT NULL"); err != nil {
+		return fmt.Errorf("failed to create idempotency key index: %w", err)
+	}
 	return nil
 }
 
+// execer runs statements on the database or in a transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
 // SaveJob saves a job status to the database
 func (jp *JobPersistence) SaveJob(status *JobStatus) error {
+	return saveJob(jp.db, "INSERT OR REPLACE", status)
+}
+
+// InsertJob saves a new job. A job whose idempotency key was already used
+// is not saved; the ID of the job submitted with the key is returned
+// instead.
+func (jp *JobPersistence) InsertJob(status *JobStatus) (string, error) {
+	tx, err := jp.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if status.IdempotencyKey != "" {
+		var existing string
+		err := tx.QueryRow("SELECT id FROM jobs WHERE idempotency_key = ?", status.IdempotencyKey).Scan(&existing)
+		if err == nil {
+			return existing, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", fmt.Errorf("failed to look up idempotency key: %w", err)
+		}
+	}
+
+	if err := saveJob(tx, "INSERT", status); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit job: %w", err)
+	}
+	return status.ID, nil
+}
+
+// saveJob writes a job status and its progress with the given insert verb
+func saveJob(db execer, verb string, status *JobStatus) error {
 	metadataJSON, err := json.Marshal(status.Metadata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal job metadata: %w", err)
 	}
 
-	query := `INSERT OR REPLACE INTO jobs 
+	var idempotencyKey *string
+	if status.IdempotencyKey != "" {
+		idempotencyKey = &status.IdempotencyKey
+	}
+
+	query := verb + ` INTO jobs 
 		(id, type, state, priority, description, created_by, start_time, end_time, 
-		 error_message, retry_count, max_retries, metadata, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
+		 error_message, retry_count, max_retries, metadata, idempotency_key, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
 
-	_, err = jp.db.Exec(query,
+	_, err = db.Exec(query,
 		status.ID,
 		string(status.Type),
 		string(status.State),
@@ -118,6 +213,7 @@ func (jp *JobPersistence) SaveJob(status *JobStatus) error {
 		status.RetryCount,
 		status.MaxRetries,
 		string(metadataJSON),
+		idempotencyKey,
 	)
 
 	if err != nil {
@@ -125,11 +221,16 @@ func (jp *JobPersistence) SaveJob(status *JobStatus) error {
 	}
 
 	// Save progress separately
-	return jp.SaveProgress(status.ID, status.Progress)
+	return saveProgress(db, status.ID, status.Progress)
 }
 
 // SaveProgress saves job progress information
 func (jp *JobPersistence) SaveProgress(jobID string, progress JobProgress) error {
+	return saveProgress(jp.db, jobID, progress)
+}
+
+// saveProgress writes job progress information
+func saveProgress(db execer, jobID string, progress JobProgress) error {
 	var etaSeconds *int64
 	if progress.ETA != nil {
 		seconds := int64(progress.ETA.Seconds())
@@ -140,7 +241,7 @@ func (jp *JobPersistence) SaveProgress(jobID string, progress JobProgress) error
 		(job_id, current_value, total_value, message, eta_seconds, updated_at)
 		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
 
-	_, err := jp.db.Exec(query,
+	_, err := db.Exec(query,
 		jobID,
 		progress.Current,
 		progress.Total,
@@ -159,7 +260,7 @@ func (jp *JobPersistence) SaveProgress(jobID string, progress JobProgress) error
 func (jp *JobPersistence) LoadJob(jobID string) (*JobStatus, error) {
 	query := `SELECT j.id, j.type, j.state, j.priority, j.description, j.created_by,
 		j.start_time, j.end_time, j.error_message, j.retry_count, j.max_retries, j.metadata,
-		COALESCE(p.current_value, 0), COALESCE(p.total_value, 0), 
+		COALESCE(j.idempotency_key, ''), COALESCE(p.current_value, 0), COALESCE(p.total_value, 0), 
 		COALESCE(p.message, ''), p.eta_seconds
 		FROM jobs j
 		LEFT JOIN job_progress p ON j.id = p.job_id
@@ -184,6 +285,7 @@ func (jp *JobPersistence) LoadJob(jobID string) (*JobStatus, error) {
 		&status.RetryCount,
 		&status.MaxRetries,
 		&metadataJSON,
+		&status.IdempotencyKey,
 		&status.Progress.Current,
 		&status.Progress.Total,
 		&status.Progress.Message,
@@ -215,7 +317,7 @@ func (jp *JobPersistence) LoadJob(jobID string) (*JobStatus, error) {
 func (jp *JobPersistence) ListJobs(filter JobFilter) ([]*JobStatus, error) {
 	query := `SELECT j.id, j.type, j.state, j.priority, j.description, j.created_by,
 		j.start_time, j.end_time, j.error_message, j.retry_count, j.max_retries, j.metadata,
-		COALESCE(p.current_value, 0), COALESCE(p.total_value, 0), 
+		COALESCE(j.idempotency_key, ''), COALESCE(p.current_value, 0), COALESCE(p.total_value, 0), 
 		COALESCE(p.message, ''), p.eta_seconds
 		FROM jobs j
 		LEFT JOIN job_progress p ON j.id = p.job_id`
@@ -292,6 +394,7 @@ func (jp *JobPersistence) ListJobs(filter JobFilter) ([]*JobStatus, error) {
 			&status.RetryCount,
 			&status.MaxRetries,
 			&metadataJSON,
+			&status.IdempotencyKey,
 			&status.Progress.Current,
 			&status.Progress.Total,
 			&status.Progress.Message,
@@ -307,6 +410,11 @@ func (jp *JobPersistence) ListJobs(filter JobFilter) ([]*JobStatus, error) {
 			return nil, fmt.Errorf("failed to unmarshal job metadata: %w", err)
 		}
 
+		// Source lives in metadata, so it is matched after decoding
+		if filter.Source != "" && status.Metadata["source_name"] != filter.Source {
+			continue
+		}
+
 		// Parse ETA
 		if etaSeconds != nil {
 			eta := time.Duration(*etaSeconds) * time.Second
@@ -321,12 +429,26 @@ func (jp *JobPersistence) ListJobs(filter JobFilter) ([]*JobStatus, error) {
 
 // DeleteJob removes a job and its associated data
 func (jp *JobPersistence) DeleteJob(jobID string) error {
-	// SQLite will handle cascading deletes for progress and events
-	query := "DELETE FROM jobs WHERE id = ?"
-	_, err := jp.db.Exec(query, jobID)
+	// Foreign keys are not enforced on this connection, so progress and
+	// events are deleted explicitly rather than by cascade
+	tx, err := jp.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to delete job: %w", err)
 	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM job_events WHERE job_id = ?",
+		"DELETE FROM job_progress WHERE job_id = ?",
+		"DELETE FROM jobs WHERE id = ?",
+	} {
+		if _, err := tx.Exec(query, jobID); err != nil {
+			return fmt.Errorf("failed to delete job: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
 	return nil
 }
 
@@ -429,6 +551,8 @@ func (jp *JobPersistence) GetStats() (ManagerStats, error) {
 			stats.CompletedJobs = count
 		case JobStateFailed:
 			stats.FailedJobs = count
+		case JobStateDeadLetter:
+			stats.DeadLetterJobs = count
 		}
 	}
 
@@ -454,6 +578,166 @@ func (jp *JobPersistence) GetStats() (ManagerStats, error) {
 	return stats, nil
 }
 
+// scheduleHistoryLimit is how many history entries are kept per scheduled job
+const scheduleHistoryLimit = 200
+
+// SaveScheduleRun adds an entry to a scheduled job's history, dropping the
+// oldest entries beyond the limit
+func (jp *JobPersistence) SaveScheduleRun(run ScheduleRun) error {
+	// Times are stored in UTC so they sort as text
+	var until *time.Time
+	if !run.Until.IsZero() {
+		utc := run.Until.UTC()
+		until = &utc
+	}
+	_, err := jp.db.Exec(`INSERT INTO schedule_runs
+		(schedule_id, scheduled_for, until, outcome, missed, job_id, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		run.ScheduleID, run.ScheduledFor.UTC(), until, string(run.Outcome), run.Missed, run.JobID, run.RecordedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save schedule run: %w", err)
+	}
+
+	_, err = jp.db.Exec(`DELETE FROM schedule_runs WHERE schedule_id = ? AND id NOT IN
+		(SELECT id FROM schedule_runs WHERE schedule_id = ? ORDER BY id DESC LIMIT ?)`,
+		run.ScheduleID, run.ScheduleID, scheduleHistoryLimit)
+	if err != nil {
+		return fmt.Errorf("failed to prune schedule history: %w", err)
+	}
+	return nil
+}
+
+// LoadScheduleRuns returns the history of a scheduled job, newest first; a
+// positive limit returns only that many entries
+func (jp *JobPersistence) LoadScheduleRuns(scheduleID string, limit int) ([]ScheduleRun, error) {
+	if limit <= 0 {
+		limit = scheduleHistoryLimit
+	}
+	rows, err := jp.db.Query(`SELECT schedule_id, scheduled_for, until, outcome, missed, job_id, recorded_at
+		FROM schedule_runs WHERE schedule_id = ? ORDER BY id DESC LIMIT ?`, scheduleID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule history: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []ScheduleRun
+	for rows.Next() {
+		var run ScheduleRun
+		var until sql.NullTime
+		var outcome string
+		if err := rows.Scan(&run.ScheduleID, &run.ScheduledFor, &until, &outcome, &run.Missed, &run.JobID, &run.RecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule run: %w", err)
+		}
+		run.Outcome = ScheduleOutcome(outcome)
+		if until.Valid {
+			run.Until = until.Time
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// LastScheduledTime returns the latest scheduled time recorded for a
+// scheduled job, whether it ran or was missed
+func (jp *JobPersistence) LastScheduledTime(scheduleID string) (time.Time, bool, error) {
+	var scheduledFor time.Time
+	var until sql.NullTime
+	err := jp.db.QueryRow(`SELECT scheduled_for, until FROM schedule_runs WHERE schedule_id = ?
+		ORDER BY COALESCE(until, scheduled_for) DESC LIMIT 1`, scheduleID).Scan(&scheduledFor, &until)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to query schedule history: %w", err)
+	}
+	if until.Valid {
+		return until.Time, true, nil
+	}
+	return scheduledFor, true, nil
+}
+
+// SaveRunMetrics records the metrics of a job run, dropping the oldest runs
+// of its data source beyond the limit
+func (jp *JobPersistence) SaveRunMetrics(metrics storage.JobMetrics) error {
+	endTime := time.Now()
+	if metrics.EndTime != nil {
+		endTime = *metrics.EndTime
+	}
+	_, err := jp.db.Exec(`INSERT INTO job_metrics
+		(job_id, job_type, data_source, status, start_time, end_time, total_items, processed_items, items_per_second)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		metrics.JobID, metrics.JobType, metrics.DataSource, metrics.Status, metrics.StartTime.UTC(), endTime.UTC(),
+		metrics.TotalItems, metrics.ProcessedItems, metrics.ItemsPerSecond)
+	if err != nil {
+		return fmt.Errorf("failed to save job metrics: %w", err)
+	}
+
+	_, err = jp.db.Exec(`DELETE FROM job_metrics WHERE data_source = ? AND id NOT IN
+		(SELECT id FROM job_metrics WHERE data_source = ? ORDER BY id DESC LIMIT ?)`,
+		metrics.DataSource, metrics.DataSource, metricsHistoryLimit)
+	if err != nil {
+		return fmt.Errorf("failed to prune job metrics: %w", err)
+	}
+	return nil
+}
+
+// LoadRunMetrics returns the recorded job runs matching filter, oldest
+// first; a positive limit returns only the latest runs
+func (jp *JobPersistence) LoadRunMetrics(filter MetricsFilter) ([]storage.JobMetrics, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.DataSource != "" {
+		conditions = append(conditions, "data_source = ?")
+		args = append(args, filter.DataSource)
+	}
+	if filter.JobType != "" {
+		conditions = append(conditions, "job_type = ?")
+		args = append(args, filter.JobType)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "start_time >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	query := `SELECT job_id, job_type, data_source, status, start_time, end_time, total_items, processed_items, items_per_second
+		FROM job_metrics`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := jp.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []storage.JobMetrics
+	for rows.Next() {
+		var run storage.JobMetrics
+		var endTime time.Time
+		if err := rows.Scan(&run.JobID, &run.JobType, &run.DataSource, &run.Status, &run.StartTime, &endTime,
+			&run.TotalItems, &run.ProcessedItems, &run.ItemsPerSecond); err != nil {
+			return nil, fmt.Errorf("failed to scan job metrics: %w", err)
+		}
+		run.StartTime, endTime = run.StartTime.Local(), endTime.Local()
+		run.EndTime = &endTime
+		run.Duration = endTime.Sub(run.StartTime)
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs, nil
+}
+
 // Close closes the database connection
 func (jp *JobPersistence) Close() error {
 	return jp.db.Close()
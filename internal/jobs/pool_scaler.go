@@ -2,6 +2,7 @@ package jobs
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -9,41 +10,66 @@ import (
 	"github.com/brainless/PubDataHub/internal/log"
 )
 
+// ScalingConfig defines auto-scaling behavior. The pool grows while jobs
+// wait for a worker and shrinks while workers sit idle, so few workers
+// compete for the SQLite databases when there is little to do.
+type ScalingConfig struct {
+	Enabled        bool          `json:"enabled"`
+	MinWorkers     int           `json:"min_workers"`
+	MaxWorkers     int           `json:"max_workers"`
+	ScaleUpWait    time.Duration `json:"scale_up_wait"`   // Queued jobs waiting this long add workers
+	IdleTimeout    time.Duration `json:"idle_timeout"`    // Workers idle this long are removed
+	CheckInterval  time.Duration `json:"check_interval"`  // How often the load is evaluated
+	CooldownPeriod time.Duration `json:"cooldown_period"` // Least time between two size changes
+}
+
+// DefaultScalingConfig returns the default auto-scaling settings, disabled
+func DefaultScalingConfig() ScalingConfig {
+	return ScalingConfig{
+		MinWorkers:     1,
+		ScaleUpWait:    5 * time.Second,
+		IdleTimeout:    time.Minute,
+		CheckInterval:  time.Second,
+		CooldownPeriod: 5 * time.Second,
+	}
+}
+
+// Validate checks the scaling bounds
+func (c ScalingConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MinWorkers < 1 {
+		return fmt.Errorf("min workers must be at least 1, got %d", c.MinWorkers)
+	}
+	if c.MaxWorkers < c.MinWorkers {
+		return fmt.Errorf("max workers %d is below min workers %d", c.MaxWorkers, c.MinWorkers)
+	}
+	return nil
+}
+
 // PoolScaler handles automatic scaling of the worker pool based on load
 type PoolScaler struct {
-	pool      *EnhancedWorkerPool
-	config    *ScalingConfig
+	pool      *WorkerPool
+	config    ScalingConfig
 	ctx       context.Context
 	cancel    context.CancelFunc
 	running   int32
-	stats     ScalingStats
-	mu        sync.RWMutex
+	pinned    int32 // The size was set by hand; scaling waits for Unpin
+	mu        sync.Mutex
 	lastScale time.Time
-}
-
-// ScalingStats tracks scaling activities and decisions
-type ScalingStats struct {
-	TotalScaleUps      int64     `json:"total_scale_ups"`
-	TotalScaleDowns    int64     `json:"total_scale_downs"`
-	LastScaleAction    string    `json:"last_scale_action"`
-	LastScaleTime      time.Time `json:"last_scale_time"`
-	CurrentUtilization float64   `json:"current_utilization"`
-	AverageUtilization float64   `json:"average_utilization"`
-	TargetSize         int       `json:"target_size"`
-	utilizationHistory []float64
+	idleSince time.Time // Start of the current stretch with idle workers
 }
 
 // NewPoolScaler creates a new pool scaler
-func NewPoolScaler(pool *EnhancedWorkerPool, config *ScalingConfig) *PoolScaler {
+func NewPoolScaler(pool *WorkerPool, config ScalingConfig) *PoolScaler {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &PoolScaler{
-		pool:      pool,
-		config:    config,
-		ctx:       ctx,
-		cancel:    cancel,
-		stats:     ScalingStats{utilizationHistory: make([]float64, 0, 20)}, // Keep last 20 measurements
-		lastScale: time.Now(),
+		pool:   pool,
+		config: config,
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
@@ -59,7 +85,7 @@ func (ps *PoolScaler) Start() {
 	}
 
 	go ps.scalingLoop()
-	log.Logger.Infof("Pool scaler started with evaluation window %v", ps.config.EvaluationWindow)
+	log.Logger.Infof("Pool scaler started with %d to %d workers", ps.config.MinWorkers, ps.config.MaxWorkers)
 }
 
 // Stop stops automatic scaling
@@ -74,160 +100,77 @@ func (ps *PoolScaler) Stop() {
 
 // scalingLoop runs the main scaling evaluation loop
 func (ps *PoolScaler) scalingLoop() {
-	ticker := time.NewTicker(ps.config.EvaluationWindow / 4) // Check 4 times per evaluation window
+	ticker := time.NewTicker(ps.config.CheckInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ps.ctx.Done():
 			return
-		case <-ticker.C:
-			ps.evaluateScaling()
+		case now := <-ticker.C:
+			ps.evaluateScaling(now)
 		}
 	}
 }
 
-// evaluateScaling determines if scaling action is needed
-func (ps *PoolScaler) evaluateScaling() {
-	// Check if we're still in cooldown period
-	if time.Since(ps.lastScale) < ps.config.CooldownPeriod {
+// evaluateScaling resizes the pool if the load calls for it: up by the
+// queued jobs once the oldest has waited ScaleUpWait, down to the busy
+// workers once some have been idle for IdleTimeout
+func (ps *PoolScaler) evaluateScaling(now time.Time) {
+	if ps.IsPinned() {
 		return
 	}
 
-	// Get current pool stats
-	poolStats := ps.pool.GetStats()
-	utilization := ps.calculateUtilization(poolStats)
-
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
-	// Update utilization history
-	ps.stats.CurrentUtilization = utilization
-	ps.stats.utilizationHistory = append(ps.stats.utilizationHistory, utilization)
-	if len(ps.stats.utilizationHistory) > 20 {
-		ps.stats.utilizationHistory = ps.stats.utilizationHistory[1:]
-	}
-
-	// Calculate average utilization over evaluation window
-	ps.stats.AverageUtilization = ps.calculateAverageUtilization()
-
-	// Determine scaling action
-	currentSize := poolStats.TotalWorkers
-	targetSize := ps.determineTargetSize(currentSize, ps.stats.AverageUtilization)
-
-	if targetSize != currentSize {
-		ps.performScaling(currentSize, targetSize)
-	}
-}
-
-// calculateUtilization calculates current pool utilization as a percentage
-func (ps *PoolScaler) calculateUtilization(stats WorkerPoolStats) float64 {
-	if stats.TotalWorkers == 0 {
-		return 0.0
-	}
-
-	// Utilization is based on active workers + queue pressure
-	activeUtilization := float64(stats.ActiveWorkers) / float64(stats.TotalWorkers)
-
-	// Add queue pressure factor
-	queuePressure := 0.0
-	if stats.QueueSize > 0 {
-		// If queue has items, consider utilization higher
-		queuePressure = float64(stats.QueueSize) / float64(stats.TotalWorkers*2) // Normalize by 2x workers
-		if queuePressure > 0.5 {
-			queuePressure = 0.5 // Cap at 50% additional pressure
+	stats := ps.pool.GetStats()
+	size := stats.TotalWorkers
+	target, reason := size, ""
+
+	switch {
+	case size < ps.config.MinWorkers || size > ps.config.MaxWorkers:
+		target = min(max(size, ps.config.MinWorkers), ps.config.MaxWorkers)
+		reason = "back within scaling bounds"
+	case stats.QueueSize > 0 && stats.OldestWait >= ps.config.ScaleUpWait && size < ps.config.MaxWorkers:
+		target = min(size+stats.QueueSize, ps.config.MaxWorkers)
+		reason = fmt.Sprintf("%d queued jobs, oldest waiting %s", stats.QueueSize, stats.OldestWait.Round(time.Second))
+	case stats.QueueSize == 0 && stats.IdleWorkers > 0 && size > ps.config.MinWorkers:
+		if ps.idleSince.IsZero() {
+			ps.idleSince = now
 		}
-	}
-
-	return activeUtilization + queuePressure
-}
-
-// calculateAverageUtilization calculates average utilization over recent history
-func (ps *PoolScaler) calculateAverageUtilization() float64 {
-	if len(ps.stats.utilizationHistory) == 0 {
-		return 0.0
-	}
-
-	var sum float64
-	for _, util := range ps.stats.utilizationHistory {
-		sum += util
-	}
-
-	return sum / float64(len(ps.stats.utilizationHistory))
-}
-
-// determineTargetSize determines the target pool size based on utilization
-func (ps *PoolScaler) determineTargetSize(currentSize int, avgUtilization float64) int {
-	minSize := ps.pool.config.MinSize
-	maxSize := ps.pool.config.MaxSize
-
-	// Scale up if average utilization is above threshold
-	if avgUtilization > ps.config.ScaleUpThreshold {
-		// Increase by 25% or at least 1 worker
-		increase := currentSize / 4
-		if increase < 1 {
-			increase = 1
+		if idle := now.Sub(ps.idleSince); idle >= ps.config.IdleTimeout {
+			target = max(size-stats.IdleWorkers, ps.config.MinWorkers)
+			reason = fmt.Sprintf("%d workers idle for %s", size-target, idle.Round(time.Second))
 		}
-		targetSize := currentSize + increase
-		if targetSize > maxSize {
-			targetSize = maxSize
-		}
-		return targetSize
+	default:
+		ps.idleSince = time.Time{}
 	}
 
-	// Scale down if average utilization is below threshold
-	if avgUtilization < ps.config.ScaleDownThreshold {
-		// Decrease by 25% or at least 1 worker
-		decrease := currentSize / 4
-		if decrease < 1 {
-			decrease = 1
-		}
-		targetSize := currentSize - decrease
-		if targetSize < minSize {
-			targetSize = minSize
-		}
-		return targetSize
-	}
-
-	return currentSize // No scaling needed
-}
-
-// performScaling executes the scaling action
-func (ps *PoolScaler) performScaling(currentSize, targetSize int) {
-	action := "down"
-	if targetSize > currentSize {
-		action = "up"
+	if target == size || now.Sub(ps.lastScale) < ps.config.CooldownPeriod {
+		return
 	}
-
-	log.Logger.Infof("Scaling %s from %d to %d workers (utilization: %.2f%%)",
-		action, currentSize, targetSize, ps.stats.AverageUtilization*100)
-
-	err := ps.pool.SetSize(targetSize)
-	if err != nil {
-		log.Logger.Errorf("Failed to scale pool to %d workers: %v", targetSize, err)
+	if err := ps.pool.resize(target, reason); err != nil {
+		log.Logger.Errorf("Failed to scale pool to %d workers: %v", target, err)
 		return
 	}
+	ps.lastScale = now
+	ps.idleSince = time.Time{}
+}
 
-	// Update stats
-	ps.stats.LastScaleAction = action
-	ps.stats.LastScaleTime = time.Now()
-	ps.stats.TargetSize = targetSize
-	ps.lastScale = time.Now()
-
-	if action == "up" {
-		ps.stats.TotalScaleUps++
-	} else {
-		ps.stats.TotalScaleDowns++
-	}
+// Pin stops automatic scaling after the size was set by hand
+func (ps *PoolScaler) Pin() {
+	atomic.StoreInt32(&ps.pinned, 1)
+}
 
-	log.Logger.Infof("Successfully scaled %s to %d workers", action, targetSize)
+// Unpin resumes automatic scaling
+func (ps *PoolScaler) Unpin() {
+	atomic.StoreInt32(&ps.pinned, 0)
 }
 
-// GetStats returns current scaling statistics
-func (ps *PoolScaler) GetStats() ScalingStats {
-	ps.mu.RLock()
-	defer ps.mu.RUnlock()
-	return ps.stats
+// IsPinned returns true while the size set by hand holds
+func (ps *PoolScaler) IsPinned() bool {
+	return atomic.LoadInt32(&ps.pinned) == 1
 }
 
 // IsRunning returns true if the scaler is active
@@ -242,5 +185,5 @@ func (ps *PoolScaler) ForceScale() {
 	}
 
 	log.Logger.Info("Forcing scaling evaluation")
-	ps.evaluateScaling()
+	ps.evaluateScaling(time.Now())
 }
@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// rateWindowSize bounds the number of samples in the moving average
+	rateWindowSize = 30
+	// rateWindowSpan bounds the age of samples in the moving average so the
+	// rate follows recent throughput rather than the whole run
+	rateWindowSpan = 2 * time.Minute
+	// DefaultStallTimeout is how long a running job may go without progress
+	// before it is reported as stalled
+	DefaultStallTimeout = 2 * time.Minute
+)
+
+// ratePoint is a progress sample used for rate calculation
+type ratePoint struct {
+	count int64
+	time  time.Time
+}
+
+// rateEstimator computes a moving-average rate and tracks when a job last
+// made progress
+type rateEstimator struct {
+	window      []ratePoint
+	lastCurrent int64
+	lastAdvance time.Time
+}
+
+// newRateEstimator creates an estimator for a job that started running at now
+func newRateEstimator(now time.Time) *rateEstimator {
+	return &rateEstimator{
+		window:      make([]ratePoint, 0, rateWindowSize),
+		lastCurrent: -1,
+		lastAdvance: now,
+	}
+}
+
+// observe records a progress sample
+func (re *rateEstimator) observe(current int64, now time.Time) {
+	if current != re.lastCurrent {
+		if re.lastCurrent >= 0 {
+			re.lastAdvance = now
+		}
+		re.lastCurrent = current
+	}
+
+	re.window = append(re.window, ratePoint{count: current, time: now})
+
+	cutoff := now.Add(-rateWindowSpan)
+	start := 0
+	for start < len(re.window)-2 && re.window[start].time.Before(cutoff) {
+		start++
+	}
+	if len(re.window)-start > rateWindowSize {
+		start = len(re.window) - rateWindowSize
+	}
+	re.window = re.window[start:]
+}
+
+// rate returns items per second over the sample window
+func (re *rateEstimator) rate() float64 {
+	if len(re.window) < 2 {
+		return 0
+	}
+
+	start := re.window[0]
+	end := re.window[len(re.window)-1]
+	elapsed := end.time.Sub(start.time).Seconds()
+	if elapsed <= 0 || end.count <= start.count {
+		return 0
+	}
+	return float64(end.count-start.count) / elapsed
+}
+
+// eta estimates the time remaining to reach total at the current rate
+func (re *rateEstimator) eta(current, total int64) *time.Duration {
+	rate := re.rate()
+	if rate <= 0 || total <= 0 || current >= total {
+		return nil
+	}
+
+	eta := time.Duration(float64(total-current) / rate * float64(time.Second)).Round(time.Second)
+	return &eta
+}
+
+// stalledFor returns how long the job has gone without progress
+func (re *rateEstimator) stalledFor(now time.Time) time.Duration {
+	return now.Sub(re.lastAdvance)
+}
+
+// formatStall describes a stall duration for display, e.g. "no progress for 2m"
+func formatStall(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("no progress for %ds", int(d.Seconds()))
+	}
+	return fmt.Sprintf("no progress for %dm", int(d.Minutes()))
+}
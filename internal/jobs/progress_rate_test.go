@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateEstimator_MovingAverageAndETA(t *testing.T) {
+	start := time.Now()
+	re := newRateEstimator(start)
+
+	re.observe(0, start)
+	assert.Zero(t, re.rate())
+	assert.Nil(t, re.eta(0, 1000))
+
+	re.observe(100, start.Add(10*time.Second))
+	re.observe(200, start.Add(20*time.Second))
+	assert.InDelta(t, 10.0, re.rate(), 0.001)
+
+	eta := re.eta(200, 1000)
+	require.NotNil(t, eta)
+	assert.Equal(t, 80*time.Second, *eta)
+	assert.Nil(t, re.eta(1000, 1000))
+
+	// Old samples fall out of the window so the rate follows recent speed
+	now := start.Add(30*time.Second + rateWindowSpan)
+	re.observe(300, now.Add(-10*time.Second))
+	re.observe(1300, now)
+	assert.InDelta(t, 100.0, re.rate(), 0.001)
+}
+
+func TestRateEstimator_StallDetection(t *testing.T) {
+	start := time.Now()
+	re := newRateEstimator(start)
+
+	re.observe(50, start)
+	re.observe(50, start.Add(time.Minute))
+	assert.Equal(t, time.Minute, re.stalledFor(start.Add(time.Minute)))
+
+	re.observe(60, start.Add(3*time.Minute))
+	assert.Zero(t, re.stalledFor(start.Add(3*time.Minute)))
+	assert.Equal(t, "no progress for 2m", formatStall(2*time.Minute+10*time.Second))
+}
+
+func TestManager_FlagsStalledJobs(t *testing.T) {
+	manager, started := newBlockingTestManager(t, func(config *ManagerConfig) {
+		config.StallTimeout = 50 * time.Millisecond
+	})
+	id := submitBlockingJob(t, manager, started)
+
+	manager.updateJobProgress(id, JobProgress{Current: 10, Total: 100})
+	require.Eventually(t, func() bool {
+		manager.checkStalledJobs()
+		status, err := manager.GetJob(id)
+		return err == nil && status.Progress.Stalled
+	}, 2*time.Second, 10*time.Millisecond)
+
+	manager.updateJobProgress(id, JobProgress{Current: 20, Total: 100})
+	status, err := manager.GetJob(id)
+	require.NoError(t, err)
+	assert.False(t, status.Progress.Stalled)
+	assert.Greater(t, status.Progress.Rate, 0.0)
+	assert.NotNil(t, status.Progress.ETA)
+}
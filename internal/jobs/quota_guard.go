@@ -0,0 +1,446 @@
+package jobs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// Storage quota event types
+const (
+	EventQuotaWarning   = "storage_quota_warning"
+	EventQuotaExceeded  = "storage_quota_exceeded"
+	EventQuotaRecovered = "storage_quota_recovered"
+)
+
+// QuotaGuardConfig configures the storage quotas of data sources
+type QuotaGuardConfig struct {
+	QuotasMB      map[string]int64 // Storage allowed per data source directory; sources without a quota are unlimited
+	WarnPercent   int              // Warn once a source uses this much of its quota
+	CheckInterval time.Duration    // How often to measure usage while jobs run
+	JobTypes      []JobType        // Job types paused when their source is over its quota
+}
+
+// DefaultQuotaGuardConfig returns the default quota guard configuration,
+// without quotas
+func DefaultQuotaGuardConfig() QuotaGuardConfig {
+	return QuotaGuardConfig{
+		WarnPercent:   90,
+		CheckInterval: 30 * time.Second,
+		JobTypes:      []JobType{JobTypeDownload},
+	}
+}
+
+// SourceUsage is the storage used by a data source and its quota
+type SourceUsage struct {
+	Source     string    `json:"source"`
+	Path       string    `json:"path"`
+	UsedBytes  uint64    `json:"used_bytes"`
+	QuotaBytes uint64    `json:"quota_bytes,omitempty"` // 0 without a quota
+	Warning    bool      `json:"warning"`               // At or over the warning threshold
+	Exceeded   bool      `json:"exceeded"`
+	HeldJobs   []string  `json:"held_jobs,omitempty"`
+	LastCheck  time.Time `json:"last_check"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Percent returns the share of the quota in use, or 0 without a quota
+func (u SourceUsage) Percent() float64 {
+	if u.QuotaBytes == 0 {
+		return 0
+	}
+	return float64(u.UsedBytes) / float64(u.QuotaBytes) * 100
+}
+
+// SourceDir returns the directory holding a data source's files
+func SourceDir(storagePath, source string) string {
+	return filepath.Join(storagePath, source)
+}
+
+// DirectorySize returns the bytes used by the regular files under path; a
+// missing directory uses none
+func DirectorySize(path string) (uint64, error) {
+	stats, err := MeasureDirectory(path)
+	return stats.Bytes, err
+}
+
+// DirectoryStats describes the regular files under a directory
+type DirectoryStats struct {
+	Bytes  uint64    `json:"bytes"`
+	Files  int64     `json:"files"`
+	Oldest time.Time `json:"oldest,omitempty"` // Modification time of the oldest file
+	Newest time.Time `json:"newest,omitempty"` // Modification time of the newest file
+}
+
+// MeasureDirectory walks the regular files under path; a missing directory
+// has none
+func MeasureDirectory(path string) (DirectoryStats, error) {
+	var stats DirectoryStats
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		stats.Bytes += uint64(info.Size())
+		stats.Files++
+		modified := info.ModTime()
+		if stats.Oldest.IsZero() || modified.Before(stats.Oldest) {
+			stats.Oldest = modified
+		}
+		if modified.After(stats.Newest) {
+			stats.Newest = modified
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// quotaState is the last observed usage of a source with a quota
+type quotaState struct {
+	used      uint64
+	warned    bool
+	exceeded  bool
+	lastCheck time.Time
+	err       error
+}
+
+// QuotaGuard measures the storage of data sources with a quota, warning as
+// usage approaches it and pausing the source's download jobs once it is
+// reached. Held jobs resume when usage drops below the quota again.
+type QuotaGuard struct {
+	manager     *Manager
+	storagePath string
+	config      QuotaGuardConfig
+	usage       func(path string) (uint64, error)
+
+	mu     sync.Mutex
+	states map[string]*quotaState
+	held   map[string]string // Job ID to the source it waits for
+
+	running  int32
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewQuotaGuard creates a quota guard for the sources under storagePath
+func NewQuotaGuard(manager *Manager, storagePath string, config QuotaGuardConfig) *QuotaGuard {
+	defaults := DefaultQuotaGuardConfig()
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaults.CheckInterval
+	}
+	if config.WarnPercent <= 0 || config.WarnPercent > 100 {
+		config.WarnPercent = defaults.WarnPercent
+	}
+	if len(config.JobTypes) == 0 {
+		config.JobTypes = defaults.JobTypes
+	}
+
+	return &QuotaGuard{
+		manager:     manager,
+		storagePath: storagePath,
+		config:      config,
+		usage:       DirectorySize,
+		states:      make(map[string]*quotaState),
+		held:        make(map[string]string),
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Enabled reports whether any data source has a quota
+func (g *QuotaGuard) Enabled() bool {
+	for _, quota := range g.config.QuotasMB {
+		if quota > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Start begins periodic usage checks in the background
+func (g *QuotaGuard) Start() {
+	if !g.Enabled() || !atomic.CompareAndSwapInt32(&g.running, 0, 1) {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(g.config.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-g.stopChan:
+				return
+			case <-ticker.C:
+				g.Check()
+			}
+		}
+	}()
+}
+
+// Stop stops periodic checks
+func (g *QuotaGuard) Stop() {
+	g.stopOnce.Do(func() {
+		close(g.stopChan)
+	})
+}
+
+// Check measures the usage of every source with a quota, warning, pausing
+// and resuming its jobs as usage crosses the thresholds
+func (g *QuotaGuard) Check() []SourceUsage {
+	for _, source := range g.quotaSources() {
+		g.checkSource(source)
+	}
+	return g.Usage()
+}
+
+// checkSource measures one source and acts on threshold crossings
+func (g *QuotaGuard) checkSource(source string) {
+	quota := g.quotaBytes(source)
+	used, err := g.usage(SourceDir(g.storagePath, source))
+
+	g.mu.Lock()
+	state := g.states[source]
+	if state == nil {
+		state = &quotaState{}
+		g.states[source] = state
+	}
+	state.lastCheck = time.Now()
+	state.err = err
+	if err != nil {
+		g.mu.Unlock()
+		log.Logger.Debugf("Quota guard could not measure %s: %v", source, err)
+		return
+	}
+	state.used = used
+
+	warn := g.warnBytes(source)
+	wasWarned, wasExceeded := state.warned, state.exceeded
+	recovered := (wasExceeded && used < quota) || (wasWarned && used < warn)
+	warned := used >= warn && used < quota && (!wasWarned || wasExceeded)
+	exceeded := !wasExceeded && used >= quota
+	var resume []string
+	if wasExceeded && used < quota {
+		resume = g.takeHeld(source)
+	}
+	state.warned = used >= warn
+	state.exceeded = used >= quota
+	enforce := state.exceeded
+	g.mu.Unlock()
+
+	if recovered {
+		g.emit(EventQuotaRecovered, source, used, fmt.Sprintf("Storage of %s down to %s of its %s quota, resuming %d jobs",
+			source, formatMB(used), formatMB(quota), len(resume)))
+		g.startJobs(resume)
+	}
+	if warned {
+		g.emit(EventQuotaWarning, source, used, fmt.Sprintf("Storage of %s at %s of its %s quota",
+			source, formatMB(used), formatMB(quota)))
+	}
+	if exceeded {
+		g.emit(EventQuotaExceeded, source, used, fmt.Sprintf("Storage quota of %s reached: %s of %s, pausing its downloads",
+			source, formatMB(used), formatMB(quota)))
+	}
+	if enforce {
+		g.pauseSourceJobs(source)
+	}
+}
+
+// Allow returns an error if a job must not start because its data source is
+// over its quota; the job is held and started once usage drops below it
+func (g *QuotaGuard) Allow(id string, jobType JobType, source string) error {
+	if source == "" || g.quotaBytes(source) == 0 || !g.guards(jobType) {
+		return nil
+	}
+
+	g.checkSource(source)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	state := g.states[source]
+	if state == nil || !state.exceeded {
+		return nil
+	}
+	g.held[id] = source
+
+	return fmt.Errorf("storage quota of %s reached: %s of %s used (job %s will start when usage drops below the quota)",
+		source, formatMB(state.used), formatMB(g.quotaBytes(source)), id)
+}
+
+// Usage returns the last observed usage of the sources with a quota, by name
+func (g *QuotaGuard) Usage() []SourceUsage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var usage []SourceUsage
+	for _, source := range g.quotaSources() {
+		entry := SourceUsage{
+			Source:     source,
+			Path:       SourceDir(g.storagePath, source),
+			QuotaBytes: g.quotaBytes(source),
+		}
+		if state := g.states[source]; state != nil {
+			entry.UsedBytes = state.used
+			entry.Warning = state.warned
+			entry.Exceeded = state.exceeded
+			entry.LastCheck = state.lastCheck
+			if state.err != nil {
+				entry.Error = state.err.Error()
+			}
+		}
+		for id, heldSource := range g.held {
+			if heldSource == source {
+				entry.HeldJobs = append(entry.HeldJobs, id)
+			}
+		}
+		sort.Strings(entry.HeldJobs)
+		usage = append(usage, entry)
+	}
+	return usage
+}
+
+// MeasureUsage reports the storage used by each source under storagePath
+// against its quota in QuotasMB, sorted by source
+func MeasureUsage(storagePath string, sources []string, quotasMB map[string]int64, warnPercent int) []SourceUsage {
+	if warnPercent <= 0 || warnPercent > 100 {
+		warnPercent = DefaultQuotaGuardConfig().WarnPercent
+	}
+	names := make(map[string]bool, len(sources)+len(quotasMB))
+	for _, source := range sources {
+		names[source] = true
+	}
+	for source := range quotasMB {
+		names[source] = true
+	}
+
+	usage := make([]SourceUsage, 0, len(names))
+	for source := range names {
+		entry := SourceUsage{Source: source, Path: SourceDir(storagePath, source), LastCheck: time.Now()}
+		if quota := quotasMB[source]; quota > 0 {
+			entry.QuotaBytes = uint64(quota) * bytesPerMB
+		}
+		used, err := DirectorySize(entry.Path)
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		entry.UsedBytes = used
+		if entry.QuotaBytes > 0 {
+			entry.Warning = used >= entry.QuotaBytes/100*uint64(warnPercent)
+			entry.Exceeded = used >= entry.QuotaBytes
+		}
+		usage = append(usage, entry)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Source < usage[j].Source })
+	return usage
+}
+
+// pauseSourceJobs pauses the running guarded jobs of a source
+func (g *QuotaGuard) pauseSourceJobs(source string) {
+	m := g.manager
+	m.jobsMux.RLock()
+	var ids []string
+	for id := range m.runningJobs {
+		if status, exists := m.jobs[id]; exists && status.State == JobStateRunning && g.guards(status.Type) && jobSource(status) == source {
+			ids = append(ids, id)
+		}
+	}
+	m.jobsMux.RUnlock()
+
+	for _, id := range ids {
+		if err := m.pauseExecution(id, fmt.Sprintf("Job %s paused: storage quota of %s reached", id, source)); err != nil {
+			log.Logger.Warnf("Failed to pause job %s for the storage quota: %v", id, err)
+			continue
+		}
+		g.mu.Lock()
+		g.held[id] = source
+		g.mu.Unlock()
+	}
+}
+
+// startJobs restarts jobs previously held by the guard
+func (g *QuotaGuard) startJobs(ids []string) {
+	for _, id := range ids {
+		if err := g.manager.StartJob(id); err != nil {
+			log.Logger.Warnf("Failed to resume job %s after storage quota check: %v", id, err)
+		}
+	}
+}
+
+// takeHeld removes the jobs held for a source and returns them; callers
+// hold mu
+func (g *QuotaGuard) takeHeld(source string) []string {
+	var ids []string
+	for id, heldSource := range g.held {
+		if heldSource == source {
+			ids = append(ids, id)
+			delete(g.held, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// quotaSources returns the sources with a quota, by name
+func (g *QuotaGuard) quotaSources() []string {
+	var sources []string
+	for source, quota := range g.config.QuotasMB {
+		if quota > 0 {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// guards reports whether jobs of the given type are subject to quotas
+func (g *QuotaGuard) guards(jobType JobType) bool {
+	for _, t := range g.config.JobTypes {
+		if t == jobType {
+			return true
+		}
+	}
+	return false
+}
+
+// emit logs and publishes a storage quota event
+func (g *QuotaGuard) emit(eventType, source string, used uint64, message string) {
+	log.Logger.Warn(message)
+	g.manager.emitEvent(JobEvent{
+		EventType: eventType,
+		Timestamp: time.Now(),
+		Message:   message,
+		Data: JobMetadata{
+			"source_name": source,
+			"used_bytes":  used,
+			"quota_bytes": g.quotaBytes(source),
+		},
+	})
+}
+
+func (g *QuotaGuard) quotaBytes(source string) uint64 {
+	if quota := g.config.QuotasMB[source]; quota > 0 {
+		return uint64(quota) * bytesPerMB
+	}
+	return 0
+}
+
+func (g *QuotaGuard) warnBytes(source string) uint64 {
+	return g.quotaBytes(source) / 100 * uint64(g.config.WarnPercent)
+}
@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// quotaEventRecorder records the types of quota events
+type quotaEventRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *quotaEventRecorder) HandleEvent(event JobEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event.EventType)
+}
+
+func (r *quotaEventRecorder) has(eventType string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, recorded := range r.events {
+		if recorded == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func newQuotaGuardTestManager(t *testing.T, used *uint64) (*Manager, chan struct{}, *quotaEventRecorder) {
+	manager, started := newBlockingTestManager(t, func(config *ManagerConfig) {
+		config.QuotaGuard = QuotaGuardConfig{
+			QuotasMB:      map[string]int64{"hackernews": 100},
+			WarnPercent:   80,
+			CheckInterval: time.Hour,
+			JobTypes:      []JobType{"blocking"},
+		}
+	})
+	manager.QuotaGuard().usage = func(string) (uint64, error) {
+		return atomic.LoadUint64(used), nil
+	}
+	recorder := &quotaEventRecorder{}
+	manager.AddEventHandler(recorder)
+
+	return manager, started, recorder
+}
+
+func addQueuedSourceJob(manager *Manager, id, source string) {
+	manager.jobsMux.Lock()
+	manager.jobs[id] = &JobStatus{ID: id, Type: "blocking", State: JobStateQueued, StartTime: time.Now(),
+		Metadata: JobMetadata{"source_name": source}}
+	manager.jobsMux.Unlock()
+}
+
+func TestQuotaGuard_WarnsPausesAndResumes(t *testing.T) {
+	used := uint64(10 * bytesPerMB)
+	manager, started, recorder := newQuotaGuardTestManager(t, &used)
+
+	addQueuedSourceJob(manager, "job-1", "hackernews")
+	require.NoError(t, manager.StartJob("job-1"))
+	waitStarted(t, started)
+	waitForState(t, manager, "job-1", JobStateRunning)
+
+	atomic.StoreUint64(&used, 85*bytesPerMB)
+	usage := manager.QuotaGuard().Check()
+	require.Len(t, usage, 1)
+	assert.True(t, usage[0].Warning)
+	assert.False(t, usage[0].Exceeded)
+	assert.Eventually(t, func() bool { return recorder.has(EventQuotaWarning) }, 2*time.Second, 10*time.Millisecond)
+	waitForState(t, manager, "job-1", JobStateRunning)
+
+	atomic.StoreUint64(&used, 120*bytesPerMB)
+	usage = manager.QuotaGuard().Check()
+	assert.True(t, usage[0].Exceeded)
+	assert.Equal(t, []string{"job-1"}, usage[0].HeldJobs)
+	assert.Eventually(t, func() bool { return recorder.has(EventQuotaExceeded) }, 2*time.Second, 10*time.Millisecond)
+	waitForState(t, manager, "job-1", JobStatePaused)
+
+	// New jobs of the source are held while it is over its quota
+	addQueuedSourceJob(manager, "job-2", "hackernews")
+	err := manager.StartJob("job-2")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "storage quota of hackernews reached")
+
+	atomic.StoreUint64(&used, 50*bytesPerMB)
+	usage = manager.QuotaGuard().Check()
+	assert.False(t, usage[0].Exceeded)
+	assert.Empty(t, usage[0].HeldJobs)
+	assert.Eventually(t, func() bool { return recorder.has(EventQuotaRecovered) }, 2*time.Second, 10*time.Millisecond)
+	waitStarted(t, started)
+	waitForState(t, manager, "job-1", JobStateRunning)
+}
+
+func TestQuotaGuard_IgnoresSourcesWithoutQuota(t *testing.T) {
+	used := uint64(500 * bytesPerMB)
+	manager, started, _ := newQuotaGuardTestManager(t, &used)
+
+	addQueuedSourceJob(manager, "job-1", "other")
+	require.NoError(t, manager.StartJob("job-1"))
+	waitStarted(t, started)
+	waitForState(t, manager, "job-1", JobStateRunning)
+}
+
+func TestMeasureUsage(t *testing.T) {
+	storagePath := t.TempDir()
+	dir := SourceDir(storagePath, "hackernews")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cache"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.sqlite"), make([]byte, 3000), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cache", "page"), make([]byte, 1000), 0644))
+
+	usage := MeasureUsage(storagePath, []string{"hackernews", "missing"}, map[string]int64{"hackernews": 1}, 0)
+	require.Len(t, usage, 2)
+	assert.Equal(t, "hackernews", usage[0].Source)
+	assert.Equal(t, uint64(4000), usage[0].UsedBytes)
+	assert.Equal(t, uint64(bytesPerMB), usage[0].QuotaBytes)
+	assert.False(t, usage[0].Warning)
+	assert.Equal(t, "missing", usage[1].Source)
+	assert.Zero(t, usage[1].UsedBytes)
+	assert.Empty(t, usage[1].Error)
+}
+
+func TestMeasureDirectory(t *testing.T) {
+	dir := t.TempDir()
+	oldest := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cache"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.sqlite"), make([]byte, 3000), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cache", "page"), make([]byte, 1000), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "cache", "page"), oldest, oldest))
+
+	stats, err := MeasureDirectory(dir)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4000), stats.Bytes)
+	assert.Equal(t, int64(2), stats.Files)
+	assert.True(t, stats.Oldest.Equal(oldest))
+	assert.True(t, stats.Newest.After(oldest))
+
+	stats, err = MeasureDirectory(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Zero(t, stats.Files)
+	assert.True(t, stats.Oldest.IsZero())
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxRedownloadItems caps how many items one re-download fetches, so a
+// mistyped range does not start a full download
+const MaxRedownloadItems = 100000
+
+// ParseItemIDs parses a comma-separated list of item IDs and inclusive
+// ranges, such as "123,456,1000-1010", into distinct IDs in the given order
+func ParseItemIDs(spec string) ([]int64, error) {
+	var ids []int64
+	seen := make(map[int64]bool)
+	add := func(id int64) error {
+		if seen[id] {
+			return nil
+		}
+		if len(ids) == MaxRedownloadItems {
+			return fmt.Errorf("too many item IDs: at most %d can be re-downloaded at once", MaxRedownloadItems)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		return nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.ParseInt(strings.TrimSpace(from), 10, 64)
+		if err != nil || start < 1 {
+			return nil, fmt.Errorf("invalid item ID %q", part)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.ParseInt(strings.TrimSpace(to), 10, 64)
+			if err != nil || end < start {
+				return nil, fmt.Errorf("invalid item range %q", part)
+			}
+			if end-start >= MaxRedownloadItems {
+				return nil, fmt.Errorf("item range %q is longer than %d items", part, MaxRedownloadItems)
+			}
+		}
+		for id := start; id <= end; id++ {
+			if err := add(id); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no item IDs given")
+	}
+	return ids, nil
+}
+
+// RedownloadItems submits a maintenance job that fetches the items of a
+// data source given by spec again, see ParseItemIDs, and updates them in
+// place
+func (ejm *EnhancedJobManager) RedownloadItems(sourceName, spec string) (string, error) {
+	if _, err := ParseItemIDs(spec); err != nil {
+		return "", err
+	}
+	return ejm.SubmitJobFromConfig(string(JobTypeMaintenance), map[string]interface{}{
+		"operation":   MaintenanceRedownloadItems,
+		"source_name": sourceName,
+		"target":      spec,
+	})
+}
@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redownloadDataSource is a mock data source that records re-downloaded IDs
+type redownloadDataSource struct {
+	*datasource.MockDataSource
+	ids []int64
+}
+
+func (r *redownloadDataSource) RedownloadItems(ctx context.Context, ids []int64, progress func(done, total int64)) error {
+	r.ids = ids
+	progress(int64(len(ids)), int64(len(ids)))
+	return nil
+}
+
+func TestParseItemIDs(t *testing.T) {
+	ids, err := ParseItemIDs("123, 456,10-12,11")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{123, 456, 10, 11, 12}, ids)
+
+	for _, spec := range []string{"", " , ", "abc", "0", "-5", "12-10", "1-x", "1-200000"} {
+		_, err := ParseItemIDs(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestMaintenanceJob_RedownloadItems(t *testing.T) {
+	log.InitLogger(false)
+
+	ds := &redownloadDataSource{MockDataSource: datasource.NewMockDataSource("mock", "Mock data source")}
+	job := NewMaintenanceJob("redownload-1", MaintenanceRedownloadItems, "mock", ds)
+	job.SetTarget("7,1-3")
+	assert.Equal(t, "Re-download mock items 7,1-3", job.Description())
+
+	var last JobProgress
+	require.NoError(t, job.Execute(context.Background(), func(progress JobProgress) { last = progress }))
+	assert.Equal(t, []int64{7, 1, 2, 3}, ds.ids)
+	assert.Equal(t, int64(4), last.Current)
+
+	plain := NewMaintenanceJob("redownload-2", MaintenanceRedownloadItems, "mock", datasource.NewMockDataSource("mock", "Mock"))
+	plain.SetTarget("1")
+	assert.Error(t, plain.Execute(context.Background(), func(JobProgress) {}))
+}
@@ -0,0 +1,205 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/httpclient"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/report"
+)
+
+// reportScheduleID returns the scheduler ID for a report
+func reportScheduleID(name string) string {
+	return "report-" + name
+}
+
+// ReportJob renders a stored report and delivers it to its file or webhook
+type ReportJob struct {
+	id          string
+	name        string
+	store       *report.Store
+	dataSources map[string]datasource.DataSource
+	priority    JobPriority
+	metadata    JobMetadata
+	progress    JobProgress
+}
+
+// NewReportJob creates a job generating the named report
+func NewReportJob(id, name string, store *report.Store, dataSources map[string]datasource.DataSource) *ReportJob {
+	return &ReportJob{
+		id:          id,
+		name:        name,
+		store:       store,
+		dataSources: dataSources,
+		priority:    PriorityNormal,
+		metadata: JobMetadata{
+			"report": name,
+		},
+		progress: JobProgress{
+			Current: 0,
+			Total:   1,
+			Message: "Waiting to generate report...",
+		},
+	}
+}
+
+// ID returns the job ID
+func (rj *ReportJob) ID() string {
+	return rj.id
+}
+
+// Type returns the job type
+func (rj *ReportJob) Type() JobType {
+	return JobTypeReport
+}
+
+// Priority returns the job priority
+func (rj *ReportJob) Priority() JobPriority {
+	return rj.priority
+}
+
+// SetPriority sets the job priority
+func (rj *ReportJob) SetPriority(priority JobPriority) {
+	rj.priority = priority
+}
+
+// Description returns the job description
+func (rj *ReportJob) Description() string {
+	return fmt.Sprintf("Generate report %s", rj.name)
+}
+
+// Metadata returns the job metadata
+func (rj *ReportJob) Metadata() JobMetadata {
+	return rj.metadata
+}
+
+// Execute renders and delivers the report
+func (rj *ReportJob) Execute(ctx context.Context, progressCallback ProgressCallback) error {
+	rj.progress.Message = fmt.Sprintf("Generating %s...", rj.name)
+	progressCallback(rj.progress)
+
+	clientConfig := httpclient.Defaults()
+	clientConfig.CacheEnabled = false
+	client, err := httpclient.New(clientConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	r, err := report.Run(ctx, rj.store, rj.name, rj.dataSources, client)
+	if err != nil {
+		return fmt.Errorf("report %s failed: %w", rj.name, err)
+	}
+
+	rj.progress.Current = 1
+	rj.progress.Message = "Report generated"
+	if r.LastOutput != "" {
+		rj.progress.Message = fmt.Sprintf("Report written to %s", r.LastOutput)
+	}
+	progressCallback(rj.progress)
+
+	log.Logger.Infof("Generated report %s", rj.name)
+	return nil
+}
+
+// CanPause returns false since a report is generated as a whole
+func (rj *ReportJob) CanPause() bool {
+	return false
+}
+
+// Pause pauses the job
+func (rj *ReportJob) Pause() error {
+	return fmt.Errorf("report jobs cannot be paused")
+}
+
+// Resume resumes the job
+func (rj *ReportJob) Resume(ctx context.Context) error {
+	return fmt.Errorf("report jobs cannot be resumed")
+}
+
+// Progress returns the current job progress
+func (rj *ReportJob) Progress() JobProgress {
+	return rj.progress
+}
+
+// Validate validates the job configuration
+func (rj *ReportJob) Validate() error {
+	if rj.id == "" {
+		return fmt.Errorf("job ID cannot be empty")
+	}
+	if rj.name == "" {
+		return fmt.Errorf("report name cannot be empty")
+	}
+	if rj.store == nil {
+		return fmt.Errorf("report store cannot be nil")
+	}
+	return nil
+}
+
+// createReportJob creates a report job from status
+func (ejm *EnhancedJobManager) createReportJob(status *JobStatus) (Job, error) {
+	name, ok := status.Metadata["report"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing report in report job metadata")
+	}
+
+	job := NewReportJob(status.ID, name, ejm.reports, ejm.factory.dataSources)
+	job.SetPriority(status.Priority)
+	return job, nil
+}
+
+// Reports returns the store of report definitions
+func (ejm *EnhancedJobManager) Reports() *report.Store {
+	return ejm.reports
+}
+
+// RunReport submits a job generating a report
+func (ejm *EnhancedJobManager) RunReport(name string) (string, error) {
+	if _, err := ejm.reports.Get(name); err != nil {
+		return "", err
+	}
+	return ejm.SubmitJobFromConfig(string(JobTypeReport), map[string]interface{}{
+		"report": name,
+	})
+}
+
+// ScheduleReport registers the schedule of a report with the scheduler;
+// reports without a schedule are unscheduled
+func (ejm *EnhancedJobManager) ScheduleReport(r *report.Report) error {
+	id := reportScheduleID(r.Name)
+	if r.Schedule == "" {
+		ejm.scheduler.UnscheduleJob(id)
+		return nil
+	}
+
+	return ejm.scheduler.ScheduleJob(&ScheduledJob{
+		ID:          id,
+		Name:        "Report " + r.Name,
+		JobType:     string(JobTypeReport),
+		Config:      map[string]interface{}{"report": r.Name},
+		Schedule:    r.Schedule,
+		Enabled:     true,
+		CreatedBy:   "report",
+		Description: fmt.Sprintf("Generate report %s", r.Name),
+	})
+}
+
+// UnscheduleReport removes the schedule of a report
+func (ejm *EnhancedJobManager) UnscheduleReport(name string) {
+	ejm.scheduler.UnscheduleJob(reportScheduleID(name))
+}
+
+// scheduleReports schedules every report with a schedule
+func (ejm *EnhancedJobManager) scheduleReports() {
+	reports, err := ejm.reports.List()
+	if err != nil {
+		log.Logger.Warnf("Failed to list reports: %v", err)
+		return
+	}
+	for _, r := range reports {
+		if err := ejm.ScheduleReport(r); err != nil {
+			log.Logger.Warnf("Failed to schedule report %s: %v", r.Name, err)
+		}
+	}
+}
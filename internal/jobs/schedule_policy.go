@@ -0,0 +1,40 @@
+package jobs
+
+import "sync"
+
+// SchedulePolicy is how a scheduled job handles missed times and runs that
+// would overlap
+type SchedulePolicy struct {
+	CatchUp     CatchUpPolicy
+	MaxCatchUp  int // Runs made up by the "all" policy; 0 uses DefaultMaxCatchUp
+	Concurrency ConcurrencyPolicy
+}
+
+var (
+	policyMu         sync.RWMutex
+	defaultPolicy    = SchedulePolicy{CatchUp: CatchUpSkip, Concurrency: ConcurrencyForbid}
+	schedulePolicies = map[string]SchedulePolicy{}
+)
+
+// SetSchedulePolicies sets the policies of scheduled jobs that do not set
+// their own, and overrides keyed by scheduled job ID such as
+// "sync-hackernews"
+func SetSchedulePolicies(defaults SchedulePolicy, schedules map[string]SchedulePolicy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	defaultPolicy = defaults
+	schedulePolicies = make(map[string]SchedulePolicy, len(schedules))
+	for id, policy := range schedules {
+		schedulePolicies[id] = policy
+	}
+}
+
+// policyFor returns the configured policies of a scheduled job
+func policyFor(scheduleID string) SchedulePolicy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	if policy, ok := schedulePolicies[scheduleID]; ok {
+		return policy
+	}
+	return defaultPolicy
+}
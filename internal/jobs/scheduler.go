@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -22,6 +21,7 @@ type JobScheduler struct {
 	ticker        *time.Ticker
 	stopChan      chan struct{}
 	running       bool
+	submitMu      sync.Mutex // Orders concurrency checks with the submissions they allow
 }
 
 // ScheduledJob represents a job that runs on a schedule
@@ -30,7 +30,11 @@ type ScheduledJob struct {
 	Name        string                 `json:"name"`
 	JobType     string                 `json:"job_type"`
 	Config      map[string]interface{} `json:"config"`
-	Schedule    string                 `json:"schedule"` // Cron expression
+	Schedule    string                 `json:"schedule"`               // Cron expression or alias such as @daily
+	Timezone    string                 `json:"timezone,omitempty"`     // IANA zone the schedule is evaluated in; empty is local time
+	CatchUp     CatchUpPolicy          `json:"catch_up,omitempty"`     // Handling of missed times; empty uses the configured policy
+	MaxCatchUp  int                    `json:"max_catch_up,omitempty"` // Runs made up by the "all" policy; 0 uses the configured cap
+	Concurrency ConcurrencyPolicy      `json:"concurrency,omitempty"`  // Handling of a run while the previous one is active; empty uses the configured policy
 	Enabled     bool                   `json:"enabled"`
 	NextRun     time.Time              `json:"next_run"`
 	LastRun     time.Time              `json:"last_run"`
@@ -47,11 +51,17 @@ type ScheduledJob struct {
 // CronSchedule represents a parsed cron schedule
 type CronSchedule struct {
 	Expression string
+	Second     []int // 0-59; 0 for five-field expressions
 	Minute     []int // 0-59
 	Hour       []int // 0-23
 	DayOfMonth []int // 1-31
 	Month      []int // 1-12
 	DayOfWeek  []int // 0-6 (Sunday = 0)
+	Location   *time.Location
+
+	// Unrestricted day fields, which decide whether both days must match
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
 }
 
 // JobDependency represents a dependency between jobs
@@ -92,7 +102,7 @@ func (js *JobScheduler) Start() error {
 		return fmt.Errorf("scheduler already running")
 	}
 
-	js.ticker = time.NewTicker(time.Minute) // Check every minute
+	js.ticker = time.NewTicker(time.Second) // Schedules may have seconds
 	js.running = true
 
 	go js.schedulingLoop()
@@ -126,13 +136,34 @@ func (js *JobScheduler) ScheduleJob(job *ScheduledJob) error {
 	defer js.mu.Unlock()
 
 	// Parse the cron schedule
-	cronSchedule, err := js.parseCronExpression(job.Schedule)
+	cronSchedule, err := ParseCronExpression(job.Schedule, job.Timezone)
 	if err != nil {
 		return fmt.Errorf("invalid cron expression: %w", err)
 	}
 
+	policy := policyFor(job.ID)
+	if job.CatchUp == "" {
+		job.CatchUp = policy.CatchUp
+		if job.MaxCatchUp == 0 {
+			job.MaxCatchUp = policy.MaxCatchUp
+		}
+	}
+	if job.CatchUp, err = ParseCatchUpPolicy(string(job.CatchUp)); err != nil {
+		return err
+	}
+	if job.Concurrency == "" {
+		job.Concurrency = policy.Concurrency
+	}
+	if job.Concurrency, err = ParseConcurrencyPolicy(string(job.Concurrency)); err != nil {
+		return err
+	}
+
 	// Calculate next run time
-	job.NextRun = js.calculateNextRun(cronSchedule, time.Now())
+	job.NextRun = cronSchedule.Next(time.Now())
+	if job.NextRun.IsZero() {
+		return fmt.Errorf("schedule %q never runs", job.Schedule)
+	}
+	js.resumeMissed(job, cronSchedule)
 	job.Created = time.Now()
 
 	js.scheduledJobs[job.ID] = job
@@ -216,6 +247,10 @@ func (js *JobScheduler) EnableJob(jobID string) error {
 	}
 
 	job.Enabled = true
+	// Times passed while disabled were not missed
+	if now := time.Now(); job.NextRun.Before(now) {
+		js.advanceNextRun(job, now)
+	}
 	log.Logger.Infof("Enabled scheduled job '%s'", jobID)
 	return nil
 }
@@ -269,60 +304,146 @@ func (js *JobScheduler) schedulingLoop() {
 	}
 }
 
-// checkAndRunJobs checks for jobs that need to run and executes them
+// checkAndRunJobs checks for jobs that need to run and executes them.
+// Times missed while the application was down are handled by each job's
+// catch-up policy.
 func (js *JobScheduler) checkAndRunJobs() {
 	js.mu.Lock()
 	now := time.Now()
-	var jobsToRun []*ScheduledJob
+	var runs []dueRun
+	var missed []ScheduleRun
 
 	for _, job := range js.scheduledJobs {
-		if job.Enabled && now.After(job.NextRun) {
-			jobsToRun = append(jobsToRun, job)
+		if !job.Enabled || now.Before(job.NextRun) {
+			continue
+		}
+		if cronSchedule, exists := js.cronSchedules[job.ID]; exists {
+			jobRuns, jobMissed := planDueRuns(job, cronSchedule, now)
+			runs = append(runs, jobRuns...)
+			if jobMissed != nil {
+				missed = append(missed, *jobMissed)
+			}
 		}
+		// Advance now so the next tick does not run the job again
+		js.advanceNextRun(job, now)
 	}
 	js.mu.Unlock()
 
-	// Run jobs (outside the lock to avoid blocking)
-	for _, job := range jobsToRun {
-		if js.areDependenciesSatisfied(job.ID) {
-			go js.executeScheduledJob(job)
-		} else {
-			log.Logger.Infof("Job '%s' dependencies not satisfied, skipping", job.ID)
+	for _, run := range missed {
+		log.Logger.Warnf("Scheduled job '%s' missed %d run(s) from %s to %s", run.ScheduleID, run.Missed,
+			run.ScheduledFor.Format("2006-01-02 15:04:05"), run.Until.Format("2006-01-02 15:04:05"))
+		js.recordRun(run)
+	}
+
+	// Run jobs (outside the lock to avoid blocking), the runs of each job in
+	// order
+	byJob := make(map[string][]dueRun)
+	for _, run := range runs {
+		byJob[run.job.ID] = append(byJob[run.job.ID], run)
+	}
+	for _, jobRuns := range byJob {
+		go js.runScheduled(jobRuns)
+	}
+}
+
+// runScheduled submits the due runs of one job in order. Unless the job
+// allows concurrent runs, each waits for the previous one to finish, so
+// catching up runs the missed times one after another.
+func (js *JobScheduler) runScheduled(runs []dueRun) {
+	previous := ""
+	for _, run := range runs {
+		if previous != "" && run.job.Concurrency != ConcurrencyAllow {
+			if !js.waitForJob(previous) {
+				return
+			}
+		}
+		if !js.areDependenciesSatisfied(run.job.ID) {
+			log.Logger.Infof("Job '%s' dependencies not satisfied, skipping", run.job.ID)
+			js.recordRun(ScheduleRun{ScheduleID: run.job.ID, ScheduledFor: run.scheduledFor, Outcome: ScheduleSkipped, RecordedAt: time.Now()})
+			continue
 		}
+		previous = js.executeScheduledJob(run)
+	}
+}
+
+// advanceNextRun sets the next run of a job after now, disabling a job whose
+// schedule has no further runs; the caller holds the lock
+func (js *JobScheduler) advanceNextRun(job *ScheduledJob, now time.Time) {
+	cronSchedule, exists := js.cronSchedules[job.ID]
+	if !exists {
+		return
+	}
+	job.NextRun = cronSchedule.Next(now)
+	if job.NextRun.IsZero() {
+		job.Enabled = false
+		log.Logger.Warnf("Scheduled job '%s' has no further runs, disabling it", job.ID)
 	}
 }
 
-// executeScheduledJob executes a scheduled job
-func (js *JobScheduler) executeScheduledJob(scheduledJob *ScheduledJob) {
+// executeScheduledJob submits a job for a scheduled time, as its
+// concurrency policy allows, and records it in the scheduled job's history.
+// It returns the ID of the submitted job, or "" if none was.
+func (js *JobScheduler) executeScheduledJob(run dueRun) string {
+	js.submitMu.Lock()
+	defer js.submitMu.Unlock()
+	if !js.admitRun(run) {
+		return ""
+	}
+
+	scheduledJob := run.job
 	js.mu.Lock()
 	scheduledJob.LastRun = time.Now()
 	scheduledJob.RunCount++
-
-	// Calculate next run time
-	if cronSchedule, exists := js.cronSchedules[scheduledJob.ID]; exists {
-		scheduledJob.NextRun = js.calculateNextRun(cronSchedule, time.Now())
-	}
 	js.mu.Unlock()
 
-	log.Logger.Infof("Executing scheduled job '%s' (%s)", scheduledJob.Name, scheduledJob.ID)
+	if run.outcome == ScheduleCaughtUp {
+		log.Logger.Infof("Catching up scheduled job '%s' (%s) missed at %s", scheduledJob.Name, scheduledJob.ID, run.scheduledFor.Format("2006-01-02 15:04:05"))
+	} else {
+		log.Logger.Infof("Executing scheduled job '%s' (%s)", scheduledJob.Name, scheduledJob.ID)
+	}
 
 	// Create a scheduled job implementation
 	job := &ScheduledJobExecution{
-		id:          scheduledJob.ID + "_" + strconv.FormatInt(time.Now().Unix(), 10),
+		id:          scheduledJob.ID + "_" + strconv.FormatInt(run.scheduledFor.Unix(), 10),
 		jobType:     JobType(scheduledJob.JobType),
 		priority:    PriorityNormal,
 		config:      scheduledJob.Config,
 		description: scheduledJob.Description,
-		metadata:    make(JobMetadata),
+		metadata:    make(JobMetadata, len(scheduledJob.Config)),
 	}
 
-	_, err := js.manager.SubmitJob(job)
+	// Carry the configuration over so the job factory can build the real job
+	for key, value := range scheduledJob.Config {
+		job.metadata[key] = value
+	}
+	job.metadata[ScheduledByKey] = scheduledJob.ID
+
+	// The key makes a time that is planned again after a restart, before its
+	// history was recorded, reuse the job submitted for it
+	history := ScheduleRun{ScheduleID: scheduledJob.ID, ScheduledFor: run.scheduledFor, Outcome: run.outcome, JobID: job.id}
+	id, _, err := js.manager.SubmitJobOnce(job, scheduleRunKey(scheduledJob.ID, run.scheduledFor))
+	if id != "" {
+		history.JobID = id
+	}
 	if err != nil {
 		js.mu.Lock()
 		scheduledJob.FailCount++
 		js.mu.Unlock()
 		log.Logger.Errorf("Failed to submit scheduled job '%s': %v", scheduledJob.ID, err)
+		history.Outcome = ScheduleFailed
+	}
+	history.RecordedAt = time.Now()
+	js.recordRun(history)
+	if err != nil {
+		return ""
 	}
+	return history.JobID
+}
+
+// scheduleRunKey is the idempotency key of the job submitted for a
+// scheduled time
+func scheduleRunKey(scheduleID string, scheduledFor time.Time) string {
+	return "schedule:" + scheduleID + "@" + scheduledFor.UTC().Format(time.RFC3339)
 }
 
 // areDependenciesSatisfied checks if all dependencies for a job are satisfied
@@ -374,186 +495,6 @@ func (js *JobScheduler) hasCircularDependency(jobID string, newDeps []string) bo
 	return checkCycle(jobID)
 }
 
-// parseCronExpression parses a cron expression (simplified version)
-func (js *JobScheduler) parseCronExpression(expr string) (*CronSchedule, error) {
-	parts := strings.Fields(expr)
-	if len(parts) != 5 {
-		return nil, fmt.Errorf("cron expression must have 5 fields: %s", expr)
-	}
-
-	schedule := &CronSchedule{Expression: expr}
-
-	var err error
-	schedule.Minute, err = js.parseField(parts[0], 0, 59)
-	if err != nil {
-		return nil, fmt.Errorf("invalid minute field: %w", err)
-	}
-
-	schedule.Hour, err = js.parseField(parts[1], 0, 23)
-	if err != nil {
-		return nil, fmt.Errorf("invalid hour field: %w", err)
-	}
-
-	schedule.DayOfMonth, err = js.parseField(parts[2], 1, 31)
-	if err != nil {
-		return nil, fmt.Errorf("invalid day of month field: %w", err)
-	}
-
-	schedule.Month, err = js.parseField(parts[3], 1, 12)
-	if err != nil {
-		return nil, fmt.Errorf("invalid month field: %w", err)
-	}
-
-	schedule.DayOfWeek, err = js.parseField(parts[4], 0, 6)
-	if err != nil {
-		return nil, fmt.Errorf("invalid day of week field: %w", err)
-	}
-
-	return schedule, nil
-}
-
-// parseField parses a single cron field
-func (js *JobScheduler) parseField(field string, min, max int) ([]int, error) {
-	if field == "*" {
-		// Return all values in range
-		values := make([]int, max-min+1)
-		for i := min; i <= max; i++ {
-			values[i-min] = i
-		}
-		return values, nil
-	}
-
-	var values []int
-
-	// Handle comma-separated values
-	parts := strings.Split(field, ",")
-	for _, part := range parts {
-		if strings.Contains(part, "/") {
-			// Handle step values (e.g., "*/5", "0-23/2")
-			stepParts := strings.Split(part, "/")
-			if len(stepParts) != 2 {
-				return nil, fmt.Errorf("invalid step format: %s", part)
-			}
-
-			step, err := strconv.Atoi(stepParts[1])
-			if err != nil {
-				return nil, fmt.Errorf("invalid step value: %s", stepParts[1])
-			}
-
-			rangeValues, err := js.parseRange(stepParts[0], min, max)
-			if err != nil {
-				return nil, err
-			}
-
-			for i := 0; i < len(rangeValues); i += step {
-				values = append(values, rangeValues[i])
-			}
-		} else if strings.Contains(part, "-") {
-			// Handle ranges (e.g., "1-5")
-			rangeValues, err := js.parseRange(part, min, max)
-			if err != nil {
-				return nil, err
-			}
-			values = append(values, rangeValues...)
-		} else {
-			// Handle single values
-			value, err := strconv.Atoi(part)
-			if err != nil {
-				return nil, fmt.Errorf("invalid value: %s", part)
-			}
-			if value < min || value > max {
-				return nil, fmt.Errorf("value %d out of range [%d-%d]", value, min, max)
-			}
-			values = append(values, value)
-		}
-	}
-
-	return values, nil
-}
-
-// parseRange parses a range expression
-func (js *JobScheduler) parseRange(rangeExpr string, min, max int) ([]int, error) {
-	if rangeExpr == "*" {
-		values := make([]int, max-min+1)
-		for i := min; i <= max; i++ {
-			values[i-min] = i
-		}
-		return values, nil
-	}
-
-	if !strings.Contains(rangeExpr, "-") {
-		// Single value
-		value, err := strconv.Atoi(rangeExpr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid value: %s", rangeExpr)
-		}
-		return []int{value}, nil
-	}
-
-	// Range (e.g., "1-5")
-	parts := strings.Split(rangeExpr, "-")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid range format: %s", rangeExpr)
-	}
-
-	start, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return nil, fmt.Errorf("invalid range start: %s", parts[0])
-	}
-
-	end, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return nil, fmt.Errorf("invalid range end: %s", parts[1])
-	}
-
-	if start > end {
-		return nil, fmt.Errorf("range start %d greater than end %d", start, end)
-	}
-
-	values := make([]int, end-start+1)
-	for i := start; i <= end; i++ {
-		values[i-start] = i
-	}
-
-	return values, nil
-}
-
-// calculateNextRun calculates the next run time for a cron schedule
-func (js *JobScheduler) calculateNextRun(schedule *CronSchedule, from time.Time) time.Time {
-	// Start from the next minute
-	next := from.Truncate(time.Minute).Add(time.Minute)
-
-	// Find the next valid time (simplified implementation)
-	for i := 0; i < 366*24*60; i++ { // Search up to a year
-		if js.matchesSchedule(schedule, next) {
-			return next
-		}
-		next = next.Add(time.Minute)
-	}
-
-	// Fallback to a year from now if no match found
-	return from.AddDate(1, 0, 0)
-}
-
-// matchesSchedule checks if a time matches the cron schedule
-func (js *JobScheduler) matchesSchedule(schedule *CronSchedule, t time.Time) bool {
-	return js.contains(schedule.Minute, t.Minute()) &&
-		js.contains(schedule.Hour, t.Hour()) &&
-		js.contains(schedule.DayOfMonth, t.Day()) &&
-		js.contains(schedule.Month, int(t.Month())) &&
-		js.contains(schedule.DayOfWeek, int(t.Weekday()))
-}
-
-// contains checks if a slice contains a value
-func (js *JobScheduler) contains(slice []int, value int) bool {
-	for _, v := range slice {
-		if v == value {
-			return true
-		}
-	}
-	return false
-}
-
 // GetSchedulerStats returns statistics about the scheduler
 func (js *JobScheduler) GetSchedulerStats() SchedulerStats {
 	js.mu.RLock()
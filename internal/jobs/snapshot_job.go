@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// rankingCapturer is implemented by data sources with ranked lists whose
+// positions can be recorded over time, such as the Hacker News front page
+type rankingCapturer interface {
+	RankingLists() []string
+	CaptureRanking(ctx context.Context, list string, depth int) (int, error)
+}
+
+// SnapshotJob records the current rankings of a data source's lists
+type SnapshotJob struct {
+	id         string
+	sourceName string
+	lists      []string
+	depth      int
+	dataSource datasource.DataSource
+	priority   JobPriority
+	metadata   JobMetadata
+	progress   JobProgress
+}
+
+// NewSnapshotJob creates a snapshot job for the given lists, or for every
+// list of the source when lists is empty; depth 0 uses the source's default
+func NewSnapshotJob(id, sourceName string, dataSource datasource.DataSource, lists []string, depth int) *SnapshotJob {
+	return &SnapshotJob{
+		id:         id,
+		sourceName: sourceName,
+		lists:      lists,
+		depth:      depth,
+		dataSource: dataSource,
+		priority:   PriorityNormal,
+		metadata: JobMetadata{
+			"source_name": sourceName,
+			"lists":       strings.Join(lists, ","),
+			"depth":       depth,
+		},
+		progress: JobProgress{
+			Current: 0,
+			Total:   int64(len(lists)),
+			Message: "Waiting to capture rankings...",
+		},
+	}
+}
+
+// ID returns the job ID
+func (sj *SnapshotJob) ID() string {
+	return sj.id
+}
+
+// Type returns the job type
+func (sj *SnapshotJob) Type() JobType {
+	return JobTypeSnapshot
+}
+
+// Priority returns the job priority
+func (sj *SnapshotJob) Priority() JobPriority {
+	return sj.priority
+}
+
+// SetPriority sets the job priority
+func (sj *SnapshotJob) SetPriority(priority JobPriority) {
+	sj.priority = priority
+}
+
+// Description returns the job description
+func (sj *SnapshotJob) Description() string {
+	if len(sj.lists) == 0 {
+		return fmt.Sprintf("Capture %s rankings", sj.sourceName)
+	}
+	return fmt.Sprintf("Capture %s rankings (%s)", sj.sourceName, strings.Join(sj.lists, ", "))
+}
+
+// Metadata returns the job metadata
+func (sj *SnapshotJob) Metadata() JobMetadata {
+	return sj.metadata
+}
+
+// Execute captures the ranking of each list
+func (sj *SnapshotJob) Execute(ctx context.Context, progressCallback ProgressCallback) error {
+	capturer, ok := sj.dataSource.(rankingCapturer)
+	if !ok {
+		return fmt.Errorf("data source %s has no rankings to capture", sj.sourceName)
+	}
+
+	lists := sj.lists
+	if len(lists) == 0 {
+		lists = capturer.RankingLists()
+	}
+	sj.progress.Total = int64(len(lists))
+
+	for i, list := range lists {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		sj.progress.Message = fmt.Sprintf("Capturing %s...", list)
+		progressCallback(sj.progress)
+
+		count, err := capturer.CaptureRanking(ctx, list, sj.depth)
+		if err != nil {
+			return fmt.Errorf("failed to capture %s ranking: %w", list, err)
+		}
+		sj.progress.Current = int64(i + 1)
+		sj.progress.Message = fmt.Sprintf("Captured %d %s entries", count, list)
+		progressCallback(sj.progress)
+	}
+
+	log.Logger.Infof("Captured %s rankings: %s", sj.sourceName, strings.Join(lists, ", "))
+	return nil
+}
+
+// CanPause returns false since a snapshot is only meaningful as a whole
+func (sj *SnapshotJob) CanPause() bool {
+	return false
+}
+
+// Pause pauses the job
+func (sj *SnapshotJob) Pause() error {
+	return fmt.Errorf("snapshot jobs cannot be paused")
+}
+
+// Resume resumes the job
+func (sj *SnapshotJob) Resume(ctx context.Context) error {
+	return fmt.Errorf("snapshot jobs cannot be resumed")
+}
+
+// Progress returns the current job progress
+func (sj *SnapshotJob) Progress() JobProgress {
+	return sj.progress
+}
+
+// Validate validates the job configuration
+func (sj *SnapshotJob) Validate() error {
+	if sj.id == "" {
+		return fmt.Errorf("job ID cannot be empty")
+	}
+	if sj.dataSource == nil {
+		return fmt.Errorf("data source cannot be nil")
+	}
+	if sj.depth < 0 {
+		return fmt.Errorf("depth cannot be negative")
+	}
+	return nil
+}
+
+// splitLists parses a comma-separated list of names, ignoring blanks
+func splitLists(value string) []string {
+	var lists []string
+	for _, list := range strings.Split(value, ",") {
+		if list = strings.TrimSpace(list); list != "" {
+			lists = append(lists, list)
+		}
+	}
+	return lists
+}
@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// SourceLimits caps the jobs of each type that run at the same time for a
+// data source, keyed by source name and job type, such as
+// {"hackernews": {"download": 1, "export": 2}}. Jobs over a limit wait in
+// the source's queue until one of its jobs ends.
+type SourceLimits map[string]map[JobType]int
+
+// Validate checks that every limit allows at least one job
+func (l SourceLimits) Validate() error {
+	for source, limits := range l {
+		for jobType, limit := range limits {
+			if limit < 1 {
+				return fmt.Errorf("limit of %s jobs for %s must be at least 1, got %d", jobType, source, limit)
+			}
+		}
+	}
+	return nil
+}
+
+// jobSource returns the data source a job works on, if any. Query exports
+// name it data_source, other jobs source_name.
+func jobSource(status *JobStatus) string {
+	if source, ok := status.Metadata["source_name"].(string); ok && source != "" {
+		return source
+	}
+	source, _ := status.Metadata["data_source"].(string)
+	return source
+}
+
+// sourceQueue holds jobs whose data source is at its limit for their type
+type sourceQueue struct {
+	mu      sync.Mutex
+	waiting map[string][]string // Job IDs per source, oldest first
+}
+
+// hold adds a job to the wait queue of its source
+func (q *sourceQueue) hold(source, id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.waiting == nil {
+		q.waiting = make(map[string][]string)
+	}
+	for _, waiting := range q.waiting[source] {
+		if waiting == id {
+			return
+		}
+	}
+	q.waiting[source] = append(q.waiting[source], id)
+}
+
+// take empties the wait queue of a source and returns its jobs, oldest
+// first
+func (q *sourceQueue) take(source string) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ids := q.waiting[source]
+	delete(q.waiting, source)
+	return ids
+}
+
+// sourceLimitReason returns why a job must wait for its data source, or
+// empty when it may start; the caller holds jobsMux
+func (m *Manager) sourceLimitReason(status *JobStatus) string {
+	source := jobSource(status)
+	limit, limited := m.config.SourceLimits[source][status.Type]
+	if source == "" || !limited {
+		return ""
+	}
+
+	running := 0
+	for id := range m.runningJobs {
+		if other, exists := m.jobs[id]; exists && id != status.ID && other.Type == status.Type && jobSource(other) == source {
+			running++
+		}
+	}
+	if running < limit {
+		return ""
+	}
+	return fmt.Sprintf("%s has %d %s jobs running (limit %d)", source, running, status.Type, limit)
+}
+
+// startWaitingJobs starts the jobs waiting for a data source, oldest first,
+// after one of its jobs ended; jobs still over the limit wait again in order
+func (m *Manager) startWaitingJobs(source string) {
+	for _, id := range m.sourceQueue.take(source) {
+		if err := m.StartJob(id); err != nil {
+			log.Logger.Debugf("Dropped waiting job %s: %v", id, err)
+		}
+	}
+}
+
+// PauseSourceJobs pauses the running jobs of a data source and waits for
+// them to return, so that its storage can be closed. Jobs waiting for its
+// job limits or queued in the worker pool are not started; they stay queued.
+func (m *Manager) PauseSourceJobs(ctx context.Context, source string) error {
+	// Taken first, so that pausing a job does not start the next one
+	m.sourceQueue.take(source)
+
+	m.jobsMux.RLock()
+	var ids, running []string
+	for id := range m.runningJobs {
+		if status, exists := m.jobs[id]; exists && jobSource(status) == source {
+			ids = append(ids, id)
+			if status.State == JobStateRunning {
+				running = append(running, id)
+			}
+		}
+	}
+	m.jobsMux.RUnlock()
+
+	for _, id := range ids {
+		if err := m.pauseExecution(id, fmt.Sprintf("Job %s paused: data source %s closed", id, source)); err != nil {
+			log.Logger.Warnf("Failed to pause job %s of %s: %v", id, source, err)
+		}
+	}
+	if len(running) > 0 {
+		log.Logger.Infof("Paused %d running jobs of %s", len(running), source)
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		remaining := 0
+		m.jobsMux.RLock()
+		for _, id := range running {
+			if _, exists := m.runningJobs[id]; exists {
+				remaining++
+			}
+		}
+		m.jobsMux.RUnlock()
+		if remaining == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d jobs of %s still running: %w", remaining, source, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceLimits_Validate(t *testing.T) {
+	assert.NoError(t, SourceLimits{"hackernews": {JobTypeDownload: 1, JobTypeExport: 2}}.Validate())
+	assert.Error(t, SourceLimits{"hackernews": {JobTypeDownload: 0}}.Validate())
+}
+
+func TestJobSource(t *testing.T) {
+	assert.Equal(t, "hackernews", jobSource(&JobStatus{Metadata: JobMetadata{"source_name": "hackernews"}}))
+	assert.Equal(t, "hackernews", jobSource(&JobStatus{Metadata: JobMetadata{"data_source": "hackernews"}}))
+	assert.Empty(t, jobSource(&JobStatus{Metadata: JobMetadata{}}))
+}
+
+func TestManager_SourceLimitsHoldJobs(t *testing.T) {
+	log.InitLogger(false)
+	config := DefaultManagerConfig()
+	config.MaxWorkers = 4
+	config.SourceLimits = SourceLimits{"hn": {"blocking": 1}}
+	manager, err := NewManager(t.TempDir(), config)
+	require.NoError(t, err)
+
+	started := make(map[string]chan struct{})
+	for _, id := range []string{"hn-1", "hn-2", "other-1"} {
+		started[id] = make(chan struct{})
+	}
+	require.NoError(t, manager.JobFactory().RegisterJobType("blocking", func(status *JobStatus) (Job, error) {
+		return &blockingJob{MaintenanceJob: NewMaintenanceJob(status.ID, MaintenanceOptimize, "mock", nil), started: started[status.ID]}, nil
+	}))
+	require.NoError(t, manager.Start())
+	t.Cleanup(func() { manager.Stop() })
+
+	add := func(id, source string) {
+		manager.jobsMux.Lock()
+		manager.jobs[id] = &JobStatus{ID: id, Type: "blocking", State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{"source_name": source}}
+		manager.jobsMux.Unlock()
+		require.NoError(t, manager.StartJob(id))
+	}
+	wait := func(id string) {
+		select {
+		case <-started[id]:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("job %s did not start", id)
+		}
+	}
+
+	add("hn-1", "hn")
+	wait("hn-1")
+	add("hn-2", "hn")
+	add("other-1", "other")
+	wait("other-1")
+
+	status, err := manager.GetJob("hn-2")
+	require.NoError(t, err)
+	assert.Equal(t, JobStateQueued, status.State)
+	assert.Equal(t, "hn has 1 blocking jobs running (limit 1)", status.WaitReason)
+
+	// The waiting job starts once the running one ends
+	require.NoError(t, manager.CancelJob("hn-1"))
+	wait("hn-2")
+	status, err = manager.GetJob("hn-2")
+	require.NoError(t, err)
+	assert.Empty(t, status.WaitReason)
+}
+
+func TestManager_PauseSourceJobs(t *testing.T) {
+	manager, started := newBlockingTestManager(t, func(config *ManagerConfig) {
+		config.MaxWorkers = 2
+		config.SourceLimits = SourceLimits{"hn": {"blocking": 1}}
+	})
+	add := func(id string) {
+		manager.jobsMux.Lock()
+		manager.jobs[id] = &JobStatus{ID: id, Type: "blocking", State: JobStateQueued, StartTime: time.Now(), Metadata: JobMetadata{"source_name": "hn"}}
+		manager.jobsMux.Unlock()
+		require.NoError(t, manager.StartJob(id))
+	}
+	add("hn-1")
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not start")
+	}
+	add("hn-2")
+
+	// Disabling the source pauses its running job before the storage closes
+	require.NoError(t, manager.PauseSourceJobs(context.Background(), "hn"))
+	assert.Empty(t, manager.GetRunningJobs())
+	status, err := manager.GetJob("hn-1")
+	require.NoError(t, err)
+	assert.Equal(t, JobStatePaused, status.State)
+
+	// The job waiting for the source's limit is not started in its place
+	time.Sleep(100 * time.Millisecond)
+	status, err = manager.GetJob("hn-2")
+	require.NoError(t, err)
+	assert.Equal(t, JobStateQueued, status.State)
+	assert.Empty(t, manager.GetRunningJobs())
+}
@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ValidateStoragePath expands ~ and makes path absolute, checks that it is
+// (or can be created as) a writable directory and returns the free space of
+// its filesystem, or 0 when that is unknown
+func ValidateStoragePath(path string) (string, uint64, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid path: %w", err)
+	}
+
+	// The nearest existing directory must be writable for path to be created
+	existing := path
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				return "", 0, fmt.Errorf("%s is not a directory", existing)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return "", 0, fmt.Errorf("cannot access %s: %w", existing, err)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return "", 0, fmt.Errorf("no existing parent directory for %s", path)
+		}
+		existing = parent
+	}
+
+	probe, err := os.CreateTemp(existing, ".pubdatahub-write-test-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("%s is not writable: %w", existing, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	free, err := FreeDiskSpace(existing)
+	if err != nil {
+		return path, 0, nil
+	}
+	return path, free, nil
+}
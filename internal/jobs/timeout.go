@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// TimeoutKey is the job metadata key of a job's own timeout, a duration
+// such as "30m0s" that overrides the configured ones; "0s" disables it
+const TimeoutKey = "timeout"
+
+// ErrJobTimeout marks jobs failed for running past their timeout
+var ErrJobTimeout = errors.New("job timed out")
+
+// timeoutGrace is how long a job past its timeout may take to return after
+// its context is cancelled, before the worker fails it and moves on
+var timeoutGrace = 10 * time.Second
+
+// SetJobTimeout sets a job's own timeout in its metadata
+func SetJobTimeout(metadata JobMetadata, timeout time.Duration) {
+	metadata[TimeoutKey] = timeout.String()
+}
+
+// jobTimeout returns the timeout of a job: its own, else the one of its
+// type, else the default; the caller holds jobsMux
+func (m *Manager) jobTimeout(status *JobStatus) time.Duration {
+	if value, ok := status.Metadata[TimeoutKey].(string); ok {
+		if timeout, err := time.ParseDuration(value); err == nil && timeout >= 0 {
+			return timeout
+		}
+	}
+	if timeout, ok := m.config.JobTimeouts[status.Type]; ok {
+		return timeout
+	}
+	return m.config.JobTimeout
+}
+
+// runJob executes a job within its timeout. A job still running when the
+// timeout passes has its context cancelled; if it then does not return
+// within timeoutGrace it is abandoned, and either way it fails with
+// ErrJobTimeout.
+func runJob(ctx context.Context, execution *JobExecution, progress ProgressCallback) error {
+	if execution.Timeout <= 0 {
+		return execution.Job.Execute(ctx, progress)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, execution.Timeout)
+	defer cancel()
+
+	// An abandoned job must not report progress on the failed job
+	var abandoned int32
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		done <- execution.Job.Execute(ctx, func(p JobProgress) {
+			if atomic.LoadInt32(&abandoned) == 0 {
+				progress(p)
+			}
+		})
+	}()
+
+	timedOut := func(err error) error {
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%w after %s", ErrJobTimeout, execution.Timeout)
+		}
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return timedOut(err)
+	case <-ctx.Done():
+	}
+
+	// Cancelled or paused jobs are waited for as before; only jobs past
+	// their timeout are given up on
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return <-done
+	}
+	select {
+	case err := <-done:
+		return timedOut(err)
+	case <-time.After(timeoutGrace):
+		atomic.StoreInt32(&abandoned, 1)
+		return fmt.Errorf("%w after %s and did not stop", ErrJobTimeout, execution.Timeout)
+	}
+}
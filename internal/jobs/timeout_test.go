@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubbornJob ignores the cancellation of its context
+type stubbornJob struct {
+	*MaintenanceJob
+	release chan struct{}
+}
+
+func (sj *stubbornJob) Execute(ctx context.Context, progressCallback ProgressCallback) error {
+	<-sj.release
+	return nil
+}
+
+func TestManager_JobTimeout(t *testing.T) {
+	manager := &Manager{config: ManagerConfig{
+		JobTimeout:  time.Hour,
+		JobTimeouts: map[JobType]time.Duration{JobTypeExport: 30 * time.Minute},
+	}}
+
+	assert.Equal(t, time.Hour, manager.jobTimeout(&JobStatus{Type: JobTypeDownload, Metadata: JobMetadata{}}))
+	assert.Equal(t, 30*time.Minute, manager.jobTimeout(&JobStatus{Type: JobTypeExport, Metadata: JobMetadata{}}))
+
+	metadata := JobMetadata{}
+	SetJobTimeout(metadata, 5*time.Minute)
+	assert.Equal(t, 5*time.Minute, manager.jobTimeout(&JobStatus{Type: JobTypeExport, Metadata: metadata}))
+	SetJobTimeout(metadata, 0)
+	assert.Zero(t, manager.jobTimeout(&JobStatus{Type: JobTypeExport, Metadata: metadata}))
+}
+
+func TestRunJob_AbandonsJobsIgnoringTimeout(t *testing.T) {
+	grace := timeoutGrace
+	timeoutGrace = 50 * time.Millisecond
+	t.Cleanup(func() { timeoutGrace = grace })
+
+	release := make(chan struct{})
+	defer close(release)
+	job := &stubbornJob{MaintenanceJob: NewMaintenanceJob("stubborn-1", MaintenanceOptimize, "mock", nil), release: release}
+	execution := NewJobExecution(job, &JobStatus{ID: job.ID()}, context.Background(), 50*time.Millisecond)
+
+	err := runJob(execution.Context, execution, func(JobProgress) {})
+	assert.ErrorIs(t, err, ErrJobTimeout)
+}
+
+func TestManager_FailsJobsPastTimeout(t *testing.T) {
+	manager, started := newBlockingTestManager(t, func(config *ManagerConfig) {
+		config.JobTimeouts = map[JobType]time.Duration{"blocking": 50 * time.Millisecond}
+	})
+	id := submitBlockingJob(t, manager, started)
+
+	require.Eventually(t, func() bool {
+		status, err := manager.GetJob(id)
+		return err == nil && status.State.IsFinished()
+	}, 2*time.Second, 10*time.Millisecond)
+
+	status, err := manager.GetJob(id)
+	require.NoError(t, err)
+	assert.Equal(t, "job timed out after 50ms", status.ErrorMessage)
+}
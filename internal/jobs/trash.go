@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/brainless/PubDataHub/internal/trash"
+)
+
+// TrashKind is the trash entry kind of jobs removed by TrashMatching
+const TrashKind = "jobs"
+
+const trashJobsFile = "jobs.json"
+
+// trashedJob is a removed job together with its event history
+type trashedJob struct {
+	Status *JobStatus `json:"status"`
+	Events []JobEvent `json:"events"`
+}
+
+// SetTrash makes TrashMatching keep removed jobs in bin so they can be
+// restored with undo
+func (m *Manager) SetTrash(bin *trash.Trash) {
+	m.trash = bin
+	bin.RegisterRestorer(TrashKind, m.restoreTrashedJobs)
+}
+
+// TrashMatching removes every finished job matching the filter like
+// CleanupMatching, first moving the jobs and their history to the trash when
+// one is set. Jobs that could not be stored in the trash are not removed.
+func (m *Manager) TrashMatching(filter JobFilter) (*BulkResult, error) {
+	if m.trash == nil {
+		return m.CleanupMatching(filter)
+	}
+
+	matched, err := m.ListJobs(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	var removed []trashedJob
+	trashed := make(map[string]bool)
+	for _, listed := range matched {
+		status, err := m.GetJob(listed.ID)
+		if err != nil || !status.IsFinished() {
+			continue
+		}
+		events, err := m.persistence.LoadEvents(status.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load history of job %s: %w", status.ID, err)
+		}
+		removed = append(removed, trashedJob{Status: status, Events: events})
+		trashed[status.ID] = true
+	}
+
+	var entry *trash.Entry
+	if len(removed) > 0 {
+		entry, err = m.trash.Put(TrashKind, fmt.Sprintf("cleanup of %d jobs", len(removed)), func(dir string) error {
+			data, err := json.MarshalIndent(removed, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to marshal jobs: %w", err)
+			}
+			return os.WriteFile(filepath.Join(dir, trashJobsFile), data, 0644)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to move jobs to the trash: %w", err)
+		}
+	}
+
+	result, err := m.applyMatching(filter, func(status *JobStatus) (bool, error) {
+		if !trashed[status.ID] {
+			return false, nil
+		}
+		return true, m.deleteJob(status.ID)
+	})
+	if result != nil && entry != nil {
+		result.TrashID = entry.ID
+	}
+	return result, err
+}
+
+// restoreTrashedJobs puts jobs removed by TrashMatching back in the database;
+// jobs that exist again are left alone
+func (m *Manager) restoreTrashedJobs(entry *trash.Entry, dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, trashJobsFile))
+	if err != nil {
+		return fmt.Errorf("failed to read trashed jobs: %w", err)
+	}
+	var removed []trashedJob
+	if err := json.Unmarshal(data, &removed); err != nil {
+		return fmt.Errorf("failed to parse trashed jobs: %w", err)
+	}
+
+	for _, job := range removed {
+		existing, err := m.persistence.LoadJob(job.Status.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check job %s: %w", job.Status.ID, err)
+		}
+		if existing != nil {
+			continue
+		}
+		if err := m.persistence.SaveJob(job.Status); err != nil {
+			return fmt.Errorf("failed to restore job %s: %w", job.Status.ID, err)
+		}
+		for _, event := range job.Events {
+			if err := m.persistence.SaveEvent(event); err != nil {
+				return fmt.Errorf("failed to restore history of job %s: %w", job.Status.ID, err)
+			}
+		}
+	}
+	return nil
+}
@@ -3,14 +3,36 @@ package jobs
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 )
 
 // Common errors
 var (
 	ErrJobNotFound = errors.New("job not found")
+	// ErrInvalidJobState is matched by errors for actions a job's current
+	// state does not allow, such as pausing a job that is not running
+	ErrInvalidJobState = errors.New("invalid job state")
 )
 
+// jobStateError is an error matching ErrInvalidJobState
+type jobStateError struct {
+	message string
+}
+
+func (e *jobStateError) Error() string {
+	return e.message
+}
+
+func (e *jobStateError) Is(target error) bool {
+	return target == ErrInvalidJobState
+}
+
+// invalidJobState returns an error matching ErrInvalidJobState
+func invalidJobState(format string, args ...interface{}) error {
+	return &jobStateError{message: fmt.Sprintf(format, args...)}
+}
+
 // JobState represents the current state of a job
 type JobState string
 
@@ -21,6 +43,10 @@ const (
 	JobStateCompleted JobState = "completed"
 	JobStateFailed    JobState = "failed"
 	JobStateCancelled JobState = "cancelled"
+
+	// JobStateDeadLetter holds jobs that failed after their last retry until
+	// they are requeued by hand
+	JobStateDeadLetter JobState = "dead_letter"
 )
 
 // JobType represents different types of jobs
@@ -29,7 +55,12 @@ type JobType string
 const (
 	JobTypeDownload    JobType = "download"
 	JobTypeExport      JobType = "export"
+	JobTypeSync        JobType = "sync"
 	JobTypeMaintenance JobType = "maintenance"
+	JobTypeSnapshot    JobType = "snapshot"
+	JobTypeEnrich      JobType = "enrich"
+	JobTypeReport      JobType = "report"
+	JobTypeFetch       JobType = "fetch"
 )
 
 // JobPriority represents job execution priority
@@ -47,6 +78,8 @@ type JobProgress struct {
 	Total   int64          `json:"total"`
 	Message string         `json:"message"`
 	ETA     *time.Duration `json:"eta,omitempty"`
+	Rate    float64        `json:"rate,omitempty"`    // Items per second, moving average
+	Stalled bool           `json:"stalled,omitempty"` // No progress within the stall timeout
 }
 
 // Percentage returns the completion percentage (0-100)
@@ -69,9 +102,18 @@ type JobStatus struct {
 	ErrorMessage string      `json:"error_message,omitempty"`
 	RetryCount   int         `json:"retry_count"`
 	MaxRetries   int         `json:"max_retries"`
+	ErrorCount   int         `json:"error_count"` // Failures seen by this process, including retried ones
 	CreatedBy    string      `json:"created_by"`
 	Description  string      `json:"description"`
 	Metadata     JobMetadata `json:"metadata"`
+
+	// IdempotencyKey identifies a submission; a job is submitted at most
+	// once per key
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// WaitReason says why a queued job has not started, such as its data
+	// source being at its job limit
+	WaitReason string `json:"wait_reason,omitempty"`
 }
 
 // JobMetadata holds job-specific metadata
@@ -95,12 +137,12 @@ func (js *JobStatus) IsActive() bool {
 
 // IsFinished returns true if the job has completed execution
 func (js *JobStatus) IsFinished() bool {
-	return js.State == JobStateCompleted || js.State == JobStateFailed || js.State == JobStateCancelled
+	return js.State.IsFinished()
 }
 
 // IsFinished returns true if the job state has completed execution
 func (js JobState) IsFinished() bool {
-	return js == JobStateCompleted || js == JobStateFailed || js == JobStateCancelled
+	return js == JobStateCompleted || js == JobStateFailed || js == JobStateCancelled || js == JobStateDeadLetter
 }
 
 // Job interface defines the contract for all job implementations
@@ -128,6 +170,15 @@ type Job interface {
 	Validate() error
 }
 
+// BoundJob is implemented by jobs holding what they run in memory, such as
+// a query runner of the interactive session, that their metadata cannot
+// restore. While the process runs, the manager runs the submitted instance,
+// also on retries, instead of one built by the job factory.
+type BoundJob interface {
+	Job
+	Bound() bool
+}
+
 // ProgressCallback is called to report job progress
 type ProgressCallback func(progress JobProgress)
 
@@ -161,19 +212,21 @@ type JobFilter struct {
 	CreatedBy     string
 	CreatedAfter  *time.Time
 	CreatedBefore *time.Time
+	Source        string // Matches the source_name metadata of data source jobs
 }
 
 // ManagerStats provides statistics about the job manager
 type ManagerStats struct {
-	TotalJobs     int              `json:"total_jobs"`
-	ActiveJobs    int              `json:"active_jobs"`
-	QueuedJobs    int              `json:"queued_jobs"`
-	RunningJobs   int              `json:"running_jobs"`
-	CompletedJobs int              `json:"completed_jobs"`
-	FailedJobs    int              `json:"failed_jobs"`
-	JobsByType    map[JobType]int  `json:"jobs_by_type"`
-	JobsByState   map[JobState]int `json:"jobs_by_state"`
-	WorkerStats   WorkerPoolStats  `json:"worker_stats"`
+	TotalJobs      int              `json:"total_jobs"`
+	ActiveJobs     int              `json:"active_jobs"`
+	QueuedJobs     int              `json:"queued_jobs"`
+	RunningJobs    int              `json:"running_jobs"`
+	CompletedJobs  int              `json:"completed_jobs"`
+	FailedJobs     int              `json:"failed_jobs"`
+	DeadLetterJobs int              `json:"dead_letter_jobs"`
+	JobsByType     map[JobType]int  `json:"jobs_by_type"`
+	JobsByState    map[JobState]int `json:"jobs_by_state"`
+	WorkerStats    WorkerPoolStats  `json:"worker_stats"`
 }
 
 // WorkerPoolStats provides statistics about the worker pool
@@ -182,6 +235,22 @@ type WorkerPoolStats struct {
 	ActiveWorkers int `json:"active_workers"`
 	IdleWorkers   int `json:"idle_workers"`
 	QueueSize     int `json:"queue_size"`
+
+	OldestWait    time.Duration  `json:"oldest_wait,omitempty"` // How long the oldest queued job has waited for a worker
+	MinWorkers    int            `json:"min_workers,omitempty"` // Bounds of auto-scaling; zero when the size is fixed
+	MaxWorkers    int            `json:"max_workers,omitempty"`
+	Pinned        bool           `json:"pinned,omitempty"` // The size was set by hand, pausing auto-scaling
+	ScaleUps      int            `json:"scale_ups,omitempty"`
+	ScaleDowns    int            `json:"scale_downs,omitempty"`
+	ScalingEvents []ScalingEvent `json:"scaling_events,omitempty"` // Latest size changes, newest first
+}
+
+// ScalingEvent is a change of the worker pool size
+type ScalingEvent struct {
+	Time   time.Time `json:"time"`
+	From   int       `json:"from"`
+	To     int       `json:"to"`
+	Reason string    `json:"reason"`
 }
 
 // JobEvent represents events in the job lifecycle
@@ -195,13 +264,15 @@ type JobEvent struct {
 
 // EventType constants for job events
 const (
-	EventJobSubmitted = "job_submitted"
-	EventJobStarted   = "job_started"
-	EventJobProgress  = "job_progress"
-	EventJobPaused    = "job_paused"
-	EventJobResumed   = "job_resumed"
-	EventJobCompleted = "job_completed"
-	EventJobFailed    = "job_failed"
-	EventJobCancelled = "job_cancelled"
-	EventJobRetrying  = "job_retrying"
+	EventJobSubmitted    = "job_submitted"
+	EventJobStarted      = "job_started"
+	EventJobProgress     = "job_progress"
+	EventJobPaused       = "job_paused"
+	EventJobResumed      = "job_resumed"
+	EventJobCompleted    = "job_completed"
+	EventJobFailed       = "job_failed"
+	EventJobCancelled    = "job_cancelled"
+	EventJobRetrying     = "job_retrying"
+	EventJobStalled      = "job_stalled"
+	EventJobDeadLettered = "job_dead_lettered"
 )
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/sirupsen/logrus"
 )
 
 // WorkerPool manages a pool of workers for job execution
@@ -20,12 +21,19 @@ type WorkerPool struct {
 	jobQueue   chan *JobExecution
 	queueSize  int
 	running    int32
+	draining   int32
 	wg         sync.WaitGroup
 	mu         sync.RWMutex
 	stats      WorkerPoolStats
-	jobManager *Manager // Reference back to manager for status updates
+	jobManager *Manager    // Reference back to manager for status updates
+	nextID     int         // ID of the next worker started
+	queuedAt   []time.Time // Submission times of the queued executions, oldest first
 }
 
+// maxScalingEvents is how many recent size changes the pool keeps in its
+// stats
+const maxScalingEvents = 10
+
 // NewWorkerPool creates a new worker pool
 func NewWorkerPool(maxWorkers, queueSize int, manager *Manager) *WorkerPool {
 	if maxWorkers <= 0 {
@@ -61,13 +69,8 @@ func (wp *WorkerPool) Start() error {
 	log.Logger.Infof("Starting worker pool with %d workers", wp.maxWorkers)
 
 	wp.mu.Lock()
-	wp.workers = make([]*Worker, wp.maxWorkers)
-	for i := 0; i < wp.maxWorkers; i++ {
-		worker := NewWorker(i, wp.jobQueue, wp)
-		wp.workers[i] = worker
-		wp.wg.Add(1)
-		go worker.Start()
-	}
+	wp.workers = make([]*Worker, 0, wp.maxWorkers)
+	wp.addWorkers(wp.maxWorkers)
 	wp.mu.Unlock()
 
 	log.Logger.Info("Worker pool started successfully")
@@ -100,18 +103,156 @@ func (wp *WorkerPool) SubmitJob(execution *JobExecution) error {
 	if atomic.LoadInt32(&wp.running) == 0 {
 		return fmt.Errorf("worker pool is not running")
 	}
+	if wp.IsDraining() {
+		return fmt.Errorf("worker pool is draining")
+	}
 
+	// Queue under the lock so the submission time is recorded before a
+	// worker can take the execution
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
 	select {
 	case wp.jobQueue <- execution:
-		wp.updateStats(func(s *WorkerPoolStats) {
-			s.QueueSize++
-		})
+		wp.stats.QueueSize++
+		wp.queuedAt = append(wp.queuedAt, time.Now())
 		return nil
 	default:
 		return fmt.Errorf("job queue is full")
 	}
 }
 
+// takeQueued accounts for an execution taken from the queue by a worker,
+// which runs it when active is true
+func (wp *WorkerPool) takeQueued(active bool) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.stats.QueueSize--
+	if len(wp.queuedAt) > 0 {
+		wp.queuedAt = wp.queuedAt[1:]
+	}
+	if active {
+		wp.stats.ActiveWorkers++
+	}
+}
+
+// SetSize changes the number of workers by hand
+func (wp *WorkerPool) SetSize(size int) error {
+	return wp.resize(size, "set by hand")
+}
+
+// resize changes the number of workers, recording the reason in the stats.
+// Added workers start at once; removed workers finish their current job
+// first, idle ones being removed before busy ones.
+func (wp *WorkerPool) resize(size int, reason string) error {
+	if size < 1 {
+		return fmt.Errorf("worker pool needs at least 1 worker, got %d", size)
+	}
+
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	from := wp.maxWorkers
+	if size == from {
+		return nil
+	}
+	wp.maxWorkers = size
+	wp.stats.TotalWorkers = size
+
+	if atomic.LoadInt32(&wp.running) == 1 {
+		if size > len(wp.workers) {
+			wp.addWorkers(size - len(wp.workers))
+		} else {
+			wp.removeWorkers(len(wp.workers) - size)
+		}
+	}
+
+	if size > from {
+		wp.stats.ScaleUps++
+	} else {
+		wp.stats.ScaleDowns++
+	}
+	event := ScalingEvent{Time: time.Now(), From: from, To: size, Reason: reason}
+	wp.stats.ScalingEvents = append([]ScalingEvent{event}, wp.stats.ScalingEvents...)
+	if len(wp.stats.ScalingEvents) > maxScalingEvents {
+		wp.stats.ScalingEvents = wp.stats.ScalingEvents[:maxScalingEvents]
+	}
+
+	log.Logger.Infof("Worker pool resized from %d to %d workers (%s)", from, size, reason)
+	return nil
+}
+
+// addWorkers starts count workers; the caller holds wp.mu
+func (wp *WorkerPool) addWorkers(count int) {
+	for i := 0; i < count; i++ {
+		worker := NewWorker(wp.nextID, wp.jobQueue, wp)
+		wp.nextID++
+		wp.workers = append(wp.workers, worker)
+		wp.wg.Add(1)
+		go worker.Start()
+	}
+}
+
+// removeWorkers stops count workers, idle ones first; the caller holds wp.mu
+func (wp *WorkerPool) removeWorkers(count int) {
+	var idle, busy []*Worker
+	for _, worker := range wp.workers {
+		if worker.IsActive() {
+			busy = append(busy, worker)
+		} else {
+			idle = append(idle, worker)
+		}
+	}
+
+	ordered := append(idle, busy...)
+	for _, worker := range ordered[:count] {
+		worker.Stop()
+	}
+	wp.workers = ordered[count:]
+}
+
+// StopAcceptingTasks puts the pool into drain mode so no new jobs are accepted
+// and queued jobs that have not started yet are left queued
+func (wp *WorkerPool) StopAcceptingTasks() error {
+	if atomic.CompareAndSwapInt32(&wp.draining, 0, 1) {
+		log.Logger.Info("Worker pool draining, no longer accepting jobs")
+	}
+	return nil
+}
+
+// IsDraining returns true once the pool has stopped accepting jobs
+func (wp *WorkerPool) IsDraining() bool {
+	return atomic.LoadInt32(&wp.draining) == 1
+}
+
+// WaitForCompletion blocks until no job is executing or the context is done
+func (wp *WorkerPool) WaitForCompletion(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		stats := wp.GetStats()
+		if stats.ActiveWorkers == 0 && stats.QueueSize == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d jobs still running: %w", stats.ActiveWorkers, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// ForceStop interrupts the jobs that are still running according to the
+// manager's drain policy and stops the pool
+func (wp *WorkerPool) ForceStop() error {
+	wp.StopAcceptingTasks()
+	if wp.jobManager != nil {
+		wp.jobManager.interruptRunningJobs(wp.jobManager.config.DrainPolicy)
+	}
+	return wp.Stop()
+}
+
 // GetStats returns current worker pool statistics
 func (wp *WorkerPool) GetStats() WorkerPoolStats {
 	wp.mu.RLock()
@@ -119,7 +260,11 @@ func (wp *WorkerPool) GetStats() WorkerPoolStats {
 
 	stats := wp.stats
 	stats.QueueSize = len(wp.jobQueue)
-	stats.IdleWorkers = stats.TotalWorkers - stats.ActiveWorkers
+	stats.IdleWorkers = max(stats.TotalWorkers-stats.ActiveWorkers, 0)
+	stats.ScalingEvents = append([]ScalingEvent(nil), wp.stats.ScalingEvents...)
+	if len(wp.queuedAt) > 0 {
+		stats.OldestWait = time.Since(wp.queuedAt[0])
+	}
 
 	return stats
 }
@@ -137,6 +282,8 @@ type Worker struct {
 	jobQueue <-chan *JobExecution
 	pool     *WorkerPool
 	active   int32
+	quit     chan struct{}
+	stopOnce sync.Once
 }
 
 // NewWorker creates a new worker
@@ -145,9 +292,15 @@ func NewWorker(id int, jobQueue <-chan *JobExecution, pool *WorkerPool) *Worker
 		id:       id,
 		jobQueue: jobQueue,
 		pool:     pool,
+		quit:     make(chan struct{}),
 	}
 }
 
+// Stop makes the worker exit once its current job, if any, returns
+func (w *Worker) Stop() {
+	w.stopOnce.Do(func() { close(w.quit) })
+}
+
 // Start begins the worker's job processing loop
 func (w *Worker) Start() {
 	defer w.pool.wg.Done()
@@ -155,16 +308,32 @@ func (w *Worker) Start() {
 	log.Logger.Debugf("Worker %d started", w.id)
 
 	for {
+		// A removed worker takes no further job, even with one waiting
+		select {
+		case <-w.quit:
+			log.Logger.Debugf("Worker %d removed from the pool", w.id)
+			return
+		default:
+		}
+
 		select {
 		case <-w.pool.ctx.Done():
 			log.Logger.Debugf("Worker %d stopping due to context cancellation", w.id)
 			return
+		case <-w.quit:
+			log.Logger.Debugf("Worker %d removed from the pool", w.id)
+			return
 		case execution, ok := <-w.jobQueue:
 			if !ok {
 				log.Logger.Debugf("Worker %d stopping due to closed job queue", w.id)
 				return
 			}
 
+			if w.pool.IsDraining() || execution.Context.Err() != nil {
+				w.skipJob(execution)
+				continue
+			}
+
 			w.executeJob(execution)
 		}
 	}
@@ -174,10 +343,7 @@ func (w *Worker) Start() {
 func (w *Worker) executeJob(execution *JobExecution) {
 	// Mark worker as active
 	atomic.StoreInt32(&w.active, 1)
-	w.pool.updateStats(func(s *WorkerPoolStats) {
-		s.ActiveWorkers++
-		s.QueueSize--
-	})
+	w.pool.takeQueued(true)
 
 	defer func() {
 		// Mark worker as idle
@@ -193,12 +359,14 @@ func (w *Worker) executeJob(execution *JobExecution) {
 
 		// Recover from panics
 		if r := recover(); r != nil {
-			log.Logger.Errorf("Worker %d panic while executing job %s: %v", w.id, execution.Status.ID, r)
+			jobLog(execution).Errorf("Worker %d panic while executing job: %v", w.id, r)
 			w.pool.jobManager.handleJobFailure(execution.Status.ID, fmt.Errorf("job panicked: %v", r))
 		}
+
+		w.pool.jobManager.executionEnded(execution)
 	}()
 
-	log.Logger.Infof("Worker %d executing job %s", w.id, execution.Status.ID)
+	jobLog(execution).Infof("Worker %d executing job", w.id)
 
 	// Update job state to running
 	w.pool.jobManager.updateJobState(execution.Status.ID, JobStateRunning, "")
@@ -208,32 +376,43 @@ func (w *Worker) executeJob(execution *JobExecution) {
 		w.pool.jobManager.updateJobProgress(execution.Status.ID, progress)
 	}
 
-	// Execute the job with timeout if specified
-	ctx := execution.Context
-	if execution.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, execution.Timeout)
-		defer cancel()
-	}
-
 	// Record start time
 	startTime := time.Now()
 
-	// Execute the job
-	err := execution.Job.Execute(ctx, progressCallback)
+	// Execute the job with timeout if specified
+	err := runJob(execution.Context, execution, progressCallback)
 
 	// Calculate execution time
 	duration := time.Since(startTime)
 
-	if err != nil {
-		log.Logger.Errorf("Worker %d job %s failed after %v: %v", w.id, execution.Status.ID, duration, err)
+	if err != nil && w.pool.jobManager.isInterrupted(execution.Status.ID) {
+		jobLog(execution).Infof("Worker %d job interrupted after %v", w.id, duration)
+		w.pool.jobManager.releaseExecution(execution)
+	} else if err != nil {
+		jobLog(execution).Errorf("Worker %d job failed after %v: %v", w.id, duration, err)
 		w.pool.jobManager.handleJobFailure(execution.Status.ID, err)
 	} else {
-		log.Logger.Infof("Worker %d job %s completed successfully in %v", w.id, execution.Status.ID, duration)
+		jobLog(execution).Infof("Worker %d job completed successfully in %v", w.id, duration)
 		w.pool.jobManager.handleJobCompletion(execution.Status.ID)
 	}
 }
 
+// skipJob drops a queued execution without running it, leaving the job queued
+func (w *Worker) skipJob(execution *JobExecution) {
+	w.pool.takeQueued(false)
+	if execution.cancel != nil {
+		execution.cancel()
+	}
+	w.pool.jobManager.releaseExecution(execution)
+	w.pool.jobManager.executionEnded(execution)
+	jobLog(execution).Debugf("Worker %d skipped job while draining", w.id)
+}
+
+// jobLog returns a logger entry tagged with the execution's job ID
+func jobLog(execution *JobExecution) *logrus.Entry {
+	return log.ForComponent("jobs").WithField(log.FieldJobID, execution.Status.ID)
+}
+
 // IsActive returns true if the worker is currently executing a job
 func (w *Worker) IsActive() bool {
 	return atomic.LoadInt32(&w.active) == 1
@@ -0,0 +1,222 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingJob runs until its context is cancelled
+type blockingJob struct {
+	*MaintenanceJob
+	started chan struct{}
+}
+
+func (bj *blockingJob) Execute(ctx context.Context, progressCallback ProgressCallback) error {
+	close(bj.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newDrainTestManager(t *testing.T, policy DrainPolicy) (*Manager, chan struct{}) {
+	return newBlockingTestManager(t, func(config *ManagerConfig) {
+		config.GracefulTimeout = 100 * time.Millisecond
+		config.DrainPolicy = policy
+	})
+}
+
+func newBlockingTestManager(t *testing.T, configure func(*ManagerConfig)) (*Manager, chan struct{}) {
+	log.InitLogger(false)
+
+	config := DefaultManagerConfig()
+	config.MaxWorkers = 1
+	configure(&config)
+
+	manager, err := NewManager(t.TempDir(), config)
+	require.NoError(t, err)
+
+	// Each job that starts sends once on started
+	started := make(chan struct{}, 4)
+	require.NoError(t, manager.JobFactory().RegisterJobType("blocking", func(status *JobStatus) (Job, error) {
+		ready := make(chan struct{})
+		go func() {
+			<-ready
+			started <- struct{}{}
+		}()
+		return &blockingJob{MaintenanceJob: NewMaintenanceJob(status.ID, MaintenanceOptimize, "mock", nil), started: ready}, nil
+	}))
+	require.NoError(t, manager.Start())
+	t.Cleanup(func() { manager.Stop() })
+
+	return manager, started
+}
+
+func submitBlockingJob(t *testing.T, manager *Manager, started chan struct{}) string {
+	status := &JobStatus{ID: "blocking-1", Type: "blocking", State: JobStateQueued, StartTime: time.Now()}
+	manager.jobsMux.Lock()
+	manager.jobs[status.ID] = status
+	manager.jobsMux.Unlock()
+
+	require.NoError(t, manager.StartJob(status.ID))
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not start")
+	}
+	return status.ID
+}
+
+func TestManager_DrainPausesRemainingJobs(t *testing.T) {
+	manager, started := newDrainTestManager(t, DrainPolicyPause)
+	id := submitBlockingJob(t, manager, started)
+
+	require.NoError(t, manager.Drain(context.Background()))
+
+	status, err := manager.GetJob(id)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatePaused, status.State)
+	assert.Empty(t, manager.GetRunningJobs())
+
+	assert.Error(t, manager.WorkerPool().SubmitJob(NewJobExecution(nil, status, context.Background(), 0)))
+}
+
+func TestManager_DrainCancelsRemainingJobs(t *testing.T) {
+	manager, started := newDrainTestManager(t, DrainPolicyCancel)
+	id := submitBlockingJob(t, manager, started)
+
+	require.NoError(t, manager.Drain(context.Background()))
+
+	status, err := manager.GetJob(id)
+	require.NoError(t, err)
+	assert.Equal(t, JobStateCancelled, status.State)
+}
+
+func TestManager_ShutdownOnSignalPausesRunningJobs(t *testing.T) {
+	manager, started := newDrainTestManager(t, DrainPolicyPause)
+	id := submitBlockingJob(t, manager, started)
+
+	// The daemon, serve and the shell shut the manager down on SIGTERM
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGTERM))
+	select {
+	case <-stop:
+	case <-time.After(2 * time.Second):
+		t.Fatal("signal not received")
+	}
+	require.NoError(t, manager.Shutdown(context.Background()))
+
+	// The job is kept paused to resume on the next start, not cancelled
+	persistence, err := NewJobPersistence(filepath.Dir(manager.persistence.path))
+	require.NoError(t, err)
+	defer persistence.Close()
+	status, err := persistence.LoadJob(id)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatePaused, status.State)
+}
+
+func TestWorkerPool_Resize(t *testing.T) {
+	log.InitLogger(false)
+	pool := NewWorkerPool(2, 10, nil)
+	require.NoError(t, pool.Start())
+	t.Cleanup(func() { pool.Stop() })
+
+	require.NoError(t, pool.SetSize(4))
+	assert.Len(t, pool.workers, 4)
+	require.NoError(t, pool.resize(1, "idle"))
+	assert.Len(t, pool.workers, 1)
+	assert.Error(t, pool.SetSize(0))
+
+	stats := pool.GetStats()
+	assert.Equal(t, 1, stats.TotalWorkers)
+	assert.Equal(t, 1, stats.ScaleUps)
+	assert.Equal(t, 1, stats.ScaleDowns)
+	require.Len(t, stats.ScalingEvents, 2)
+	assert.Equal(t, ScalingEvent{Time: stats.ScalingEvents[0].Time, From: 4, To: 1, Reason: "idle"}, stats.ScalingEvents[0])
+}
+
+func TestPoolScaler_ScalesWithQueueAndIdleWorkers(t *testing.T) {
+	log.InitLogger(false)
+	// The pool is not started, so queued executions stay queued
+	pool := NewWorkerPool(1, 10, nil)
+	now := time.Now()
+	queue := func(count int, age time.Duration) {
+		for i := 0; i < count; i++ {
+			pool.jobQueue <- &JobExecution{}
+			pool.queuedAt = append(pool.queuedAt, now.Add(-age))
+		}
+	}
+	config := ScalingConfig{Enabled: true, MinWorkers: 1, MaxWorkers: 3, ScaleUpWait: 5 * time.Second, IdleTimeout: time.Minute}
+	scaler := NewPoolScaler(pool, config)
+
+	queue(2, time.Second)
+	scaler.evaluateScaling(now)
+	assert.Equal(t, 1, pool.GetStats().TotalWorkers, "jobs have not waited long enough")
+
+	// Time passes and more jobs queue up
+	for i := range pool.queuedAt {
+		pool.queuedAt[i] = now.Add(-10 * time.Second)
+	}
+	queue(3, 10*time.Second)
+	scaler.evaluateScaling(now)
+	assert.Equal(t, 3, pool.GetStats().TotalWorkers, "capped at max workers")
+
+	for len(pool.jobQueue) > 0 {
+		<-pool.jobQueue
+	}
+	pool.queuedAt = nil
+	scaler.evaluateScaling(now.Add(time.Second))
+	assert.Equal(t, 3, pool.GetStats().TotalWorkers)
+	scaler.evaluateScaling(now.Add(time.Minute + time.Second))
+	assert.Equal(t, 1, pool.GetStats().TotalWorkers)
+
+	scaler.Pin()
+	queue(2, 10*time.Second)
+	scaler.evaluateScaling(now.Add(2 * time.Minute))
+	assert.Equal(t, 1, pool.GetStats().TotalWorkers, "a size set by hand holds")
+	scaler.Unpin()
+	scaler.evaluateScaling(now.Add(2 * time.Minute))
+	assert.Equal(t, 3, pool.GetStats().TotalWorkers)
+}
+
+func TestManager_SetWorkers(t *testing.T) {
+	log.InitLogger(false)
+	config := DefaultManagerConfig()
+	config.MaxWorkers = 4
+	config.Scaling.Enabled = true
+	config.Scaling.MinWorkers = 2
+	manager, err := NewManager(t.TempDir(), config)
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.persistence.Close() })
+
+	stats := manager.WorkerStats()
+	assert.Equal(t, 2, stats.TotalWorkers, "a scaling pool starts at min workers")
+	assert.Equal(t, 4, stats.MaxWorkers)
+
+	require.NoError(t, manager.SetWorkers(6))
+	stats = manager.WorkerStats()
+	assert.Equal(t, 6, stats.TotalWorkers)
+	assert.True(t, stats.Pinned)
+	require.NoError(t, manager.AutoScaleWorkers())
+	assert.False(t, manager.WorkerStats().Pinned)
+
+	fixed, err := NewManager(t.TempDir(), DefaultManagerConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { fixed.persistence.Close() })
+	assert.Error(t, fixed.AutoScaleWorkers())
+
+	config.Scaling.MinWorkers = 5
+	_, err = NewManager(t.TempDir(), config)
+	assert.Error(t, err)
+}
@@ -1,19 +1,33 @@
 package log
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
+// Structured field names shared across components
+const (
+	FieldComponent = "component"
+	FieldJobID     = "job_id"
+	FieldSource    = "source"
+)
+
 var Logger *logrus.Logger
 
+var (
+	mu               sync.RWMutex
+	componentLevels  = make(map[string]logrus.Level)
+	componentLoggers = make(map[string]*logrus.Logger)
+	fileWriter       *RotatingWriter
+	consoleWriter    io.Writer = os.Stdout
+)
+
 func InitLogger(verbose bool) {
-	Logger = logrus.New()
-	Logger.SetOutput(os.Stdout)
-	Logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
+	Logger = newLogger()
 
 	if verbose {
 		Logger.SetLevel(logrus.DebugLevel)
@@ -25,11 +39,7 @@ func InitLogger(verbose bool) {
 // InitLoggerForTUI initializes logger with appropriate level for TUI mode
 // In TUI mode, we want to reduce log noise while keeping important messages
 func InitLoggerForTUI(verbose bool) {
-	Logger = logrus.New()
-	Logger.SetOutput(os.Stdout)
-	Logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
-	})
+	Logger = newLogger()
 
 	if verbose {
 		Logger.SetLevel(logrus.DebugLevel)
@@ -39,3 +49,134 @@ func InitLoggerForTUI(verbose bool) {
 		Logger.SetLevel(logrus.WarnLevel)
 	}
 }
+
+// newLogger creates a logger writing to stdout and, if enabled, the log file
+func newLogger() *logrus.Logger {
+	mu.Lock()
+	defer mu.Unlock()
+
+	logger := logrus.New()
+	logger.SetOutput(outputLocked())
+	logger.SetFormatter(&redactingFormatter{&logrus.TextFormatter{
+		FullTimestamp: true,
+	}})
+
+	componentLoggers = make(map[string]*logrus.Logger)
+	return logger
+}
+
+// outputLocked returns the writer for log output; callers hold mu
+func outputLocked() io.Writer {
+	if fileWriter != nil {
+		return io.MultiWriter(consoleWriter, fileWriter)
+	}
+	return consoleWriter
+}
+
+// UseStderr sends console log output to stderr so stdout only carries
+// command output, e.g. when results are piped to another program
+func UseStderr() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	consoleWriter = os.Stderr
+	if Logger != nil {
+		Logger.SetOutput(outputLocked())
+	}
+	componentLoggers = make(map[string]*logrus.Logger)
+}
+
+// ForComponent returns a log entry tagged with the component name that
+// honours any per-component level override
+func ForComponent(component string) *logrus.Entry {
+	mu.RLock()
+	logger, cached := componentLoggers[component]
+	level, overridden := componentLevels[component]
+	mu.RUnlock()
+
+	if !overridden {
+		return Logger.WithField(FieldComponent, component)
+	}
+
+	if !cached {
+		mu.Lock()
+		logger = &logrus.Logger{
+			Out:          Logger.Out,
+			Formatter:    Logger.Formatter,
+			Hooks:        Logger.Hooks,
+			Level:        level,
+			ExitFunc:     os.Exit,
+			ReportCaller: Logger.ReportCaller,
+		}
+		componentLoggers[component] = logger
+		mu.Unlock()
+	}
+
+	return logger.WithField(FieldComponent, component)
+}
+
+// SetComponentLevel overrides the log level for a single component
+func SetComponentLevel(component, level string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("invalid log level for %s: %w", component, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	componentLevels[component] = parsed
+	delete(componentLoggers, component)
+	return nil
+}
+
+// SetComponentLevels replaces all per-component level overrides
+func SetComponentLevels(levels map[string]string) error {
+	parsed := make(map[string]logrus.Level, len(levels))
+	for component, level := range levels {
+		l, err := logrus.ParseLevel(level)
+		if err != nil {
+			return fmt.Errorf("invalid log level for %s: %w", component, err)
+		}
+		parsed[component] = l
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	componentLevels = parsed
+	componentLoggers = make(map[string]*logrus.Logger)
+	return nil
+}
+
+// ComponentLevels returns the current per-component level overrides
+func ComponentLevels() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	levels := make(map[string]string, len(componentLevels))
+	for component, level := range componentLevels {
+		levels[component] = level.String()
+	}
+	return levels
+}
+
+// EnableFileLogging additionally writes logs to rotating files in dir
+func EnableFileLogging(dir string, config RotationConfig) error {
+	writer, err := NewRotatingWriter(dir, "pubdatahub", config)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	mu.Lock()
+	previous := fileWriter
+	fileWriter = writer
+	if Logger != nil {
+		Logger.SetOutput(outputLocked())
+	}
+	componentLoggers = make(map[string]*logrus.Logger)
+	mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	return nil
+}
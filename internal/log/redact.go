@@ -0,0 +1,47 @@
+package log
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// redactedValue replaces secrets in log output
+const redactedValue = "[REDACTED]"
+
+var (
+	secretsMu sync.RWMutex
+	secrets   = make(map[string]bool)
+)
+
+// RedactSecret keeps value out of log output from now on. Values shorter than
+// four characters are ignored, since replacing them would mangle messages.
+func RedactSecret(value string) {
+	if len(value) < 4 {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets[value] = true
+}
+
+// redactingFormatter replaces registered secrets in formatted entries
+type redactingFormatter struct {
+	logrus.Formatter
+}
+
+// Format formats the entry and masks any secret it contains
+func (f *redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data, err := f.Formatter.Format(entry)
+	if err != nil {
+		return data, err
+	}
+
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for secret := range secrets {
+		data = bytes.ReplaceAll(data, []byte(secret), []byte(redactedValue))
+	}
+	return data, nil
+}
@@ -0,0 +1,192 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotationConfig controls log file rotation and retention
+type RotationConfig struct {
+	MaxSizeMB  int // Rotate once the current file exceeds this size
+	MaxAgeDays int // Remove rotated files older than this
+	MaxBackups int // Keep at most this many rotated files
+
+	FileMode os.FileMode // Mode of new log files; 0 uses 0644
+	DirMode  os.FileMode // Mode of a new log directory; 0 uses 0755
+}
+
+// DefaultRotationConfig returns the default rotation settings
+func DefaultRotationConfig() RotationConfig {
+	return RotationConfig{
+		MaxSizeMB:  10,
+		MaxAgeDays: 14,
+		MaxBackups: 5,
+	}
+}
+
+// RotatingWriter is an io.Writer that writes to <dir>/<name>.log and rotates
+// it by size, pruning old files by age and count
+type RotatingWriter struct {
+	mu     sync.Mutex
+	dir    string
+	name   string
+	config RotationConfig
+	file   *os.File
+	size   int64
+}
+
+// NewRotatingWriter opens (or creates) the log file in dir
+func NewRotatingWriter(dir, name string, config RotationConfig) (*RotatingWriter, error) {
+	dirMode := config.DirMode
+	if dirMode == 0 {
+		dirMode = 0755
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, dirMode); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+		// The umask would otherwise narrow the mode
+		if err := os.Chmod(dir, dirMode); err != nil {
+			return nil, fmt.Errorf("failed to set log directory mode: %w", err)
+		}
+	}
+
+	w := &RotatingWriter{
+		dir:    dir,
+		name:   name,
+		config: config,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	w.prune()
+	return w, nil
+}
+
+// Write writes p to the current file, rotating first if it would exceed MaxSizeMB
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, fmt.Errorf("log file is closed")
+	}
+
+	maxSize := int64(w.config.MaxSizeMB) * 1024 * 1024
+	if maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the current log file
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Path returns the path of the active log file
+func (w *RotatingWriter) Path() string {
+	return filepath.Join(w.dir, w.name+".log")
+}
+
+// open opens the active log file for appending
+func (w *RotatingWriter) open() error {
+	mode := w.config.FileMode
+	if mode == 0 {
+		mode = 0644
+	}
+	_, statErr := os.Stat(w.Path())
+	file, err := os.OpenFile(w.Path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, mode)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	if os.IsNotExist(statErr) {
+		if err := file.Chmod(mode); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to set log file mode: %w", err)
+		}
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	w.file = file
+	w.size = stat.Size()
+	return nil
+}
+
+// rotate renames the active file with a timestamp suffix and starts a new one
+func (w *RotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+
+	rotated := filepath.Join(w.dir, fmt.Sprintf("%s-%s.log", w.name, time.Now().Format("20060102-150405.000")))
+	if err := os.Rename(w.Path(), rotated); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.prune()
+	return nil
+}
+
+// prune removes rotated files beyond MaxBackups or older than MaxAgeDays
+func (w *RotatingWriter) prune() {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return
+	}
+
+	prefix := w.name + "-"
+	var rotated []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) && strings.HasSuffix(entry.Name(), ".log") {
+			rotated = append(rotated, entry.Name())
+		}
+	}
+
+	// Timestamp suffixes sort chronologically, newest first after reversing
+	sort.Sort(sort.Reverse(sort.StringSlice(rotated)))
+
+	cutoff := time.Now().AddDate(0, 0, -w.config.MaxAgeDays)
+	for i, name := range rotated {
+		path := filepath.Join(w.dir, name)
+
+		expired := false
+		if w.config.MaxAgeDays > 0 {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+				expired = true
+			}
+		}
+
+		if expired || (w.config.MaxBackups > 0 && i >= w.config.MaxBackups) {
+			os.Remove(path)
+		}
+	}
+}
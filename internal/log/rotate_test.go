@@ -0,0 +1,87 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingWriter_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := NewRotatingWriter(dir, "app", RotationConfig{MaxSizeMB: 1, MaxBackups: 2})
+	require.NoError(t, err)
+	defer writer.Close()
+
+	line := []byte(strings.Repeat("x", 400*1024))
+	for i := 0; i < 10; i++ {
+		_, err := writer.Write(line)
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond) // distinct rotation timestamps
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	var rotated int
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "app-") {
+			rotated++
+		}
+	}
+	assert.Equal(t, 2, rotated)
+
+	info, err := os.Stat(writer.Path())
+	require.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1024*1024))
+}
+
+func TestRotatingWriter_PrunesByAge(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "app-20200101-000000.000.log")
+	require.NoError(t, os.WriteFile(old, []byte("old"), 0644))
+	oldTime := time.Now().AddDate(0, 0, -30)
+	require.NoError(t, os.Chtimes(old, oldTime, oldTime))
+
+	writer, err := NewRotatingWriter(dir, "app", RotationConfig{MaxSizeMB: 1, MaxAgeDays: 7})
+	require.NoError(t, err)
+	defer writer.Close()
+
+	_, err = os.Stat(old)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestForComponent_LevelOverride(t *testing.T) {
+	InitLogger(false)
+	defer SetComponentLevels(nil)
+
+	assert.False(t, ForComponent("jobs").Logger.IsLevelEnabled(logrus.DebugLevel))
+
+	require.NoError(t, SetComponentLevel("jobs", "debug"))
+	entry := ForComponent("jobs")
+	assert.Equal(t, "jobs", entry.Data[FieldComponent])
+	assert.True(t, entry.Logger.IsLevelEnabled(logrus.DebugLevel))
+	assert.False(t, ForComponent("api").Logger.IsLevelEnabled(logrus.DebugLevel))
+
+	assert.Error(t, SetComponentLevel("jobs", "loud"))
+	assert.Equal(t, map[string]string{"jobs": "debug"}, ComponentLevels())
+}
+
+func TestRedactSecret(t *testing.T) {
+	InitLogger(false)
+	var out strings.Builder
+	Logger.SetOutput(&out)
+
+	RedactSecret("s3cr3t-token")
+	RedactSecret("abc")
+	Logger.Infof("request with token s3cr3t-token and id abc")
+
+	assert.NotContains(t, out.String(), "s3cr3t-token")
+	assert.Contains(t, out.String(), "token [REDACTED]")
+	assert.Contains(t, out.String(), "id abc")
+}
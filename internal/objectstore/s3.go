@@ -0,0 +1,363 @@
+// Package objectstore uploads exports to S3-compatible object storage such as
+// AWS S3, MinIO or Cloudflare R2. Requests are signed with AWS Signature
+// Version 4; large objects are sent as multipart uploads while they are
+// written, and transient failures are retried.
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/httpclient"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/secrets"
+)
+
+const (
+	// Scheme starts object URLs, s3://bucket/key
+	Scheme = "s3://"
+	// CredentialSource is the secrets source of the access keys, e.g. the
+	// secret s3.access_key_id
+	CredentialSource = "s3"
+	// DefaultRegion is the signing region when none is configured
+	DefaultRegion = "us-east-1"
+	// DefaultPartSize is the size of multipart upload parts (8MB)
+	DefaultPartSize = 8 * 1024 * 1024
+	// MinPartSize is the smallest part S3 accepts, except for the last (5MB)
+	MinPartSize = 5 * 1024 * 1024
+	// uploadTimeout bounds each request, which may carry a whole part
+	uploadTimeout = 5 * time.Minute
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// maxAttempts is how often a request is tried before a transient failure is
+// returned; retryDelay is the first wait, doubled after each attempt
+var (
+	maxAttempts = 4
+	retryDelay  = time.Second
+)
+
+// Settings describe the object storage service; credentials come from the
+// secrets store
+type Settings struct {
+	Endpoint  string // Service URL; empty uses AWS S3 in Region
+	Region    string // Signing region; empty uses DefaultRegion
+	PathStyle bool   // Address buckets as endpoint/bucket instead of bucket.endpoint, as MinIO needs
+	PartSize  int64  // Multipart upload part size; 0 uses DefaultPartSize
+}
+
+// Config is the full configuration of a client
+type Config struct {
+	Settings
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string       // Temporary credentials only
+	HTTPClient      *http.Client // Nil uses the configured HTTP client settings
+}
+
+var (
+	defaultsMu sync.RWMutex
+	defaults   Settings
+)
+
+// SetDefaults sets the settings used by Open
+func SetDefaults(settings Settings) {
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+	defaults = settings
+}
+
+// Defaults returns the settings set by SetDefaults
+func Defaults() Settings {
+	defaultsMu.RLock()
+	defer defaultsMu.RUnlock()
+	return defaults
+}
+
+// IsURL reports whether path is an object URL rather than a local file
+func IsURL(path string) bool {
+	return strings.HasPrefix(strings.ToLower(path), Scheme)
+}
+
+// ParseURL splits an object URL into its bucket and key
+func ParseURL(rawURL string) (bucket, key string, err error) {
+	if !IsURL(rawURL) {
+		return "", "", fmt.Errorf("invalid object URL %q (expected s3://bucket/key)", rawURL)
+	}
+	bucket, key, _ = strings.Cut(rawURL[len(Scheme):], "/")
+	if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		return "", "", fmt.Errorf("invalid object URL %q (expected s3://bucket/key)", rawURL)
+	}
+	return bucket, key, nil
+}
+
+// Client talks to an S3-compatible service
+type Client struct {
+	config   Config
+	endpoint *url.URL
+	http     *http.Client
+	now      func() time.Time
+}
+
+// New creates a client from config
+func New(config Config) (*Client, error) {
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, fmt.Errorf("object storage needs an access key ID and secret access key")
+	}
+	if config.Region == "" {
+		config.Region = DefaultRegion
+	}
+	if config.PartSize == 0 {
+		config.PartSize = DefaultPartSize
+	}
+	if config.PartSize < MinPartSize {
+		return nil, fmt.Errorf("part size must be at least %dMB", MinPartSize/(1024*1024))
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	parsed, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid object storage endpoint %q", endpoint)
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		httpConfig := httpclient.ForSource(CredentialSource)
+		httpConfig.CacheEnabled = false
+		httpConfig.Timeout = max(httpConfig.Timeout, uploadTimeout)
+		if client, err = httpclient.New(httpConfig); err != nil {
+			return nil, err
+		}
+	}
+	return &Client{config: config, endpoint: parsed, http: client, now: time.Now}, nil
+}
+
+// Open creates a client from the default settings with the access keys
+// s3.access_key_id and s3.secret_access_key, and s3.session_token when set,
+// from the secrets store
+func Open() (*Client, error) {
+	credentials := secrets.ForSource(CredentialSource)
+	config := Config{Settings: Defaults()}
+	var err error
+	if config.AccessKeyID, err = credentials.Credential("access_key_id"); err != nil {
+		return nil, fmt.Errorf("object storage credentials missing, run 'config secret set s3.access_key_id': %w", err)
+	}
+	if config.SecretAccessKey, err = credentials.Credential("secret_access_key"); err != nil {
+		return nil, fmt.Errorf("object storage credentials missing, run 'config secret set s3.secret_access_key': %w", err)
+	}
+	if config.SessionToken, err = credentials.Credential("session_token"); err != nil && !errors.Is(err, secrets.ErrNotFound) {
+		return nil, err
+	}
+	return New(config)
+}
+
+// ObjectURL returns the HTTP URL of an object
+func (c *Client) ObjectURL(bucket, key string) string {
+	return c.objectURL(bucket, key).String()
+}
+
+// objectURL addresses an object by virtual host or, with PathStyle, by path
+func (c *Client) objectURL(bucket, key string) *url.URL {
+	u := *c.endpoint
+	path, rawPath := "/"+key, "/"+uriEncode(key, false)
+	if c.config.PathStyle {
+		path, rawPath = "/"+bucket+path, "/"+uriEncode(bucket, false)+rawPath
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+	u.Path = c.endpoint.Path + path
+	u.RawPath = c.endpoint.EscapedPath() + rawPath
+	return &u
+}
+
+// do sends a signed request for an object, retrying transient failures, and
+// returns the response body of a successful request
+func (c *Client) do(ctx context.Context, method, bucket, key string, query url.Values, header http.Header, body []byte) (http.Header, []byte, error) {
+	target := c.objectURL(bucket, key)
+	target.RawQuery = query.Encode()
+
+	delay := retryDelay
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			log.Logger.Warnf("Object storage %s %s failed, retrying in %s: %v", method, key, delay, lastErr)
+			select {
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		req.ContentLength = int64(len(body))
+		c.sign(req, payloadHash(body), c.now())
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return resp.Header, data, nil
+		}
+
+		lastErr = responseError(resp.StatusCode, data)
+		if !transientStatus(resp.StatusCode) {
+			return nil, nil, lastErr
+		}
+	}
+	return nil, nil, fmt.Errorf("giving up after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// transientStatus reports whether a failed request may succeed when retried
+func transientStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// responseError describes an error response from its S3 error document
+func responseError(status int, body []byte) error {
+	var doc struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &doc) == nil && doc.Code != "" {
+		return fmt.Errorf("object storage error %d %s: %s", status, doc.Code, doc.Message)
+	}
+	return fmt.Errorf("object storage error %d %s", status, http.StatusText(status))
+}
+
+// sign adds AWS Signature Version 4 headers to req. The host, range,
+// content type and x-amz-* headers are signed.
+func (c *Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.config.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.config.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-type" || lower == "content-md5" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.config.SecretAccessKey), date)
+	key = hmacSHA256(key, c.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns the query sorted by name, encoded for signing
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte except unreserved characters, and
+// slashes unless encodeSlash is set, as Signature Version 4 requires
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// payloadHash returns the hex SHA-256 of a request body
+func payloadHash(body []byte) string {
+	if len(body) == 0 {
+		return emptyPayloadHash
+	}
+	return hashHex(body)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,229 @@
+package objectstore
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// Upload writes an object as it is produced. Data is buffered into parts;
+// an object smaller than one part is sent with a single request, larger
+// ones as a multipart upload so at most one part is held in memory.
+type Upload struct {
+	client      *Client
+	ctx         context.Context
+	bucket      string
+	key         string
+	contentType string
+
+	buffer   []byte
+	uploadID string // Set once the multipart upload has started
+	parts    []completedPart
+	size     int64
+	err      error
+	closed   bool
+}
+
+// completedPart is a part of a multipart upload, as listed when completing it
+type completedPart struct {
+	XMLName    xml.Name `xml:"Part"`
+	PartNumber int      `xml:"PartNumber"`
+	ETag       string   `xml:"ETag"`
+}
+
+// Create starts an upload to bucket and key; nothing is stored until the
+// upload is closed
+func (c *Client) Create(ctx context.Context, bucket, key, contentType string) *Upload {
+	return &Upload{
+		client:      c,
+		ctx:         ctx,
+		bucket:      bucket,
+		key:         key,
+		contentType: contentType,
+		buffer:      make([]byte, 0, c.config.PartSize),
+	}
+}
+
+// Write buffers p, uploading each part once it is full
+func (u *Upload) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	if u.closed {
+		return 0, fmt.Errorf("upload of %s is closed", u.key)
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), cap(u.buffer)-len(u.buffer))
+		u.buffer = append(u.buffer, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(u.buffer) == cap(u.buffer) {
+			if err := u.flushPart(); err != nil {
+				u.fail(err)
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close uploads the remaining data and completes the object
+func (u *Upload) Close() error {
+	if u.closed {
+		return u.err
+	}
+	u.closed = true
+	if u.err != nil {
+		return u.err
+	}
+
+	if u.uploadID == "" {
+		header := http.Header{}
+		if u.contentType != "" {
+			header.Set("Content-Type", u.contentType)
+		}
+		if _, _, err := u.client.do(u.ctx, http.MethodPut, u.bucket, u.key, nil, header, u.buffer); err != nil {
+			u.err = fmt.Errorf("failed to upload %s: %w", u.key, err)
+			return u.err
+		}
+		u.size += int64(len(u.buffer))
+		u.buffer = nil
+		return nil
+	}
+
+	if len(u.buffer) > 0 {
+		if err := u.flushPart(); err != nil {
+			u.fail(err)
+			return u.err
+		}
+	}
+	if err := u.complete(); err != nil {
+		u.fail(err)
+		return u.err
+	}
+	return nil
+}
+
+// Abort discards the upload, removing any parts already stored
+func (u *Upload) Abort() {
+	if u.closed && u.err == nil {
+		return
+	}
+	u.closed = true
+	if u.err == nil {
+		u.fail(fmt.Errorf("upload of %s aborted", u.key))
+	}
+}
+
+// Size returns the bytes uploaded so far
+func (u *Upload) Size() int64 {
+	return u.size
+}
+
+// Location returns the HTTP URL of the object
+func (u *Upload) Location() string {
+	return u.client.ObjectURL(u.bucket, u.key)
+}
+
+// flushPart uploads the buffer as the next part, starting the multipart
+// upload with the first one
+func (u *Upload) flushPart() error {
+	if u.uploadID == "" {
+		if err := u.start(); err != nil {
+			return err
+		}
+	}
+
+	number := len(u.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {u.uploadID}}
+	header, _, err := u.client.do(u.ctx, http.MethodPut, u.bucket, u.key, query, nil, u.buffer)
+	if err != nil {
+		return fmt.Errorf("failed to upload part %d of %s: %w", number, u.key, err)
+	}
+	u.parts = append(u.parts, completedPart{PartNumber: number, ETag: header.Get("ETag")})
+	u.size += int64(len(u.buffer))
+	u.buffer = u.buffer[:0]
+	return nil
+}
+
+// start creates the multipart upload
+func (u *Upload) start() error {
+	header := http.Header{}
+	if u.contentType != "" {
+		header.Set("Content-Type", u.contentType)
+	}
+	_, body, err := u.client.do(u.ctx, http.MethodPost, u.bucket, u.key, url.Values{"uploads": {""}}, header, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start upload of %s: %w", u.key, err)
+	}
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return fmt.Errorf("failed to start upload of %s: no upload ID in the response", u.key)
+	}
+	u.uploadID = result.UploadID
+	return nil
+}
+
+// complete assembles the uploaded parts into the object
+func (u *Upload) complete() error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: u.parts})
+	if err != nil {
+		return fmt.Errorf("failed to encode parts: %w", err)
+	}
+	query := url.Values{"uploadId": {u.uploadID}}
+	_, response, err := u.client.do(u.ctx, http.MethodPost, u.bucket, u.key, query, nil, body)
+	if err != nil {
+		return fmt.Errorf("failed to complete upload of %s: %w", u.key, err)
+	}
+	// S3 may report a failure in the body of a successful response
+	var failure struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+		Message string   `xml:"Message"`
+	}
+	if xml.Unmarshal(response, &failure) == nil && failure.Code != "" {
+		return fmt.Errorf("failed to complete upload of %s: %s: %s", u.key, failure.Code, failure.Message)
+	}
+	return nil
+}
+
+// fail records err and aborts a started multipart upload so its parts are
+// not kept, and billed, by the service
+func (u *Upload) fail(err error) {
+	u.err = err
+	u.buffer = nil
+	if u.uploadID == "" {
+		return
+	}
+	query := url.Values{"uploadId": {u.uploadID}}
+	if _, _, abortErr := u.client.do(context.Background(), http.MethodDelete, u.bucket, u.key, query, nil, nil); abortErr != nil {
+		log.Logger.Warnf("Failed to abort upload of %s, its parts remain stored: %v", u.key, abortErr)
+	}
+	u.uploadID = ""
+}
+
+// CreateURL starts an upload to an s3://bucket/key URL with the client of
+// the default settings and stored credentials
+func CreateURL(ctx context.Context, rawURL, contentType string) (*Upload, error) {
+	bucket, key, err := ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client, err := Open()
+	if err != nil {
+		return nil, err
+	}
+	return client.Create(ctx, bucket, key, contentType), nil
+}
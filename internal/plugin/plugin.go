@@ -0,0 +1,169 @@
+// Package plugin runs external commands that extend the shell and the CLI
+// without forking PubDataHub. An executable named pubdatahub-<command> on
+// PATH, or any executable in the plugins directory, becomes the command
+// <command>. It gets its arguments on the command line and a JSON Context
+// on stdin, and writes its output to stdout.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const (
+	// Prefix starts the names of plugin executables on PATH
+	Prefix = "pubdatahub-"
+	// DirName is the plugins directory in the config directory
+	DirName = "plugins"
+	// ContextVersion is the version of the Context plugins get on stdin
+	ContextVersion = 1
+)
+
+// Interfaces plugins are run from
+const (
+	InterfaceCLI   = "cli"
+	InterfaceShell = "tui"
+)
+
+// namePattern matches the command names plugins can have
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Plugin is an external command
+type Plugin struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Source is a data source as plugins see it
+type Source struct {
+	Name        string `json:"name"`
+	StoragePath string `json:"storage_path"` // Where the source keeps its database
+}
+
+// Context is written as JSON to the stdin of a plugin, so it can find the
+// data it analyses without parsing the config itself
+type Context struct {
+	Version      int      `json:"version"`
+	Command      string   `json:"command"`
+	Args         []string `json:"args"`
+	Interface    string   `json:"interface"`
+	ConfigDir    string   `json:"config_dir"`
+	StoragePath  string   `json:"storage_path"`
+	DataSources  []Source `json:"data_sources"`
+	ActiveSource string   `json:"active_source,omitempty"` // Chosen with use in the shell
+	ReadOnly     bool     `json:"read_only"`               // The storage must not be written
+}
+
+// Dir returns the plugins directory in configDir
+func Dir(configDir string) string {
+	return filepath.Join(configDir, DirName)
+}
+
+// Discover returns the plugins in dir and on PATH, sorted by name. A plugin
+// in dir hides one with the same name on PATH, and earlier PATH entries
+// hide later ones, as in the shell.
+func Discover(dir string) []Plugin {
+	found := make(map[string]Plugin)
+	add := func(dir string, prefixed bool) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			name, ok := commandName(entry.Name(), prefixed)
+			if !ok {
+				continue
+			}
+			if _, seen := found[name]; seen {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if info, err := os.Stat(path); err == nil && isExecutable(info) {
+				found[name] = Plugin{Name: name, Path: path}
+			}
+		}
+	}
+
+	if dir != "" {
+		add(dir, false)
+	}
+	for _, pathDir := range filepath.SplitList(os.Getenv("PATH")) {
+		if pathDir != "" {
+			add(pathDir, true)
+		}
+	}
+
+	plugins := make([]Plugin, 0, len(found))
+	for _, plugin := range found {
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// commandName returns the command of an executable file name. Executables
+// on PATH need Prefix; in the plugins directory it is optional.
+func commandName(file string, prefixed bool) (string, bool) {
+	if runtime.GOOS == "windows" {
+		file = strings.TrimSuffix(file, filepath.Ext(file))
+	}
+	name, hasPrefix := strings.CutPrefix(file, Prefix)
+	if prefixed && !hasPrefix {
+		return "", false
+	}
+	return name, namePattern.MatchString(name)
+}
+
+// isExecutable reports whether a file can be run as a plugin
+func isExecutable(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(info.Name())) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		}
+		return false
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+// Run runs the plugin with the arguments of pctx, writing pctx as JSON to
+// its stdin. The plugin is stopped when ctx is cancelled.
+func (p Plugin) Run(ctx context.Context, pctx Context, stdout, stderr io.Writer) error {
+	pctx.Version = ContextVersion
+	pctx.Command = p.Name
+	if pctx.Args == nil {
+		pctx.Args = []string{}
+	}
+	if pctx.DataSources == nil {
+		pctx.DataSources = []Source{}
+	}
+	input, err := json.Marshal(pctx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plugin context: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, p.Path, pctx.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("plugin %s cancelled", p.Name)
+		}
+		return fmt.Errorf("plugin %s failed: %w", p.Name, err)
+	}
+	return nil
+}
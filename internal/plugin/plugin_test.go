@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeScript writes an executable shell script
+func writeScript(t *testing.T, path, body string) {
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755))
+}
+
+func TestDiscover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts in this test")
+	}
+	pluginDir, pathDir, laterDir := t.TempDir(), t.TempDir(), t.TempDir()
+	writeScript(t, filepath.Join(pluginDir, "trends"), "echo dir")
+	writeScript(t, filepath.Join(pathDir, Prefix+"trends"), "echo path")
+	writeScript(t, filepath.Join(pathDir, Prefix+"top-authors"), "echo path")
+	writeScript(t, filepath.Join(laterDir, Prefix+"top-authors"), "echo later")
+	writeScript(t, filepath.Join(pathDir, "unrelated"), "echo no")
+	require.NoError(t, os.WriteFile(filepath.Join(pathDir, Prefix+"notes"), []byte("not executable"), 0644))
+	t.Setenv("PATH", pathDir+string(os.PathListSeparator)+laterDir)
+
+	plugins := Discover(pluginDir)
+	assert.Equal(t, []Plugin{
+		{Name: "top-authors", Path: filepath.Join(pathDir, Prefix+"top-authors")},
+		{Name: "trends", Path: filepath.Join(pluginDir, "trends")},
+	}, plugins)
+}
+
+func TestPlugin_Run(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts in this test")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "echo-context")
+	writeScript(t, path, `echo "args: $*" >&2; cat`)
+	plugin := Plugin{Name: "echo-context", Path: path}
+
+	var stdout, stderr bytes.Buffer
+	err := plugin.Run(context.Background(), Context{
+		Args:        []string{"--since", "7d"},
+		Interface:   InterfaceShell,
+		StoragePath: "/data",
+		DataSources: []Source{{Name: "hackernews", StoragePath: "/data/hackernews"}},
+	}, &stdout, &stderr)
+	require.NoError(t, err)
+	assert.Equal(t, "args: --since 7d\n", stderr.String())
+
+	var received Context
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &received))
+	assert.Equal(t, ContextVersion, received.Version)
+	assert.Equal(t, "echo-context", received.Command)
+	assert.Equal(t, []string{"--since", "7d"}, received.Args)
+	assert.Equal(t, "hackernews", received.DataSources[0].Name)
+
+	failing := filepath.Join(dir, "failing")
+	writeScript(t, failing, "exit 3")
+	err = Plugin{Name: "failing", Path: failing}.Run(context.Background(), Context{}, &stdout, &stderr)
+	assert.ErrorContains(t, err, "plugin failing failed")
+}
@@ -0,0 +1,130 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// Refresh intervals of the download bar on a terminal and of its log lines
+// otherwise
+var (
+	downloadBarInterval = 500 * time.Millisecond
+	downloadLogInterval = 10 * time.Second
+)
+
+// downloadBarWidth is the number of cells in the drawn bar
+const downloadBarWidth = 30
+
+// DownloadBar reports a data source download run in the foreground: a bar
+// with items/sec, ETA and batch counter redrawn in place on a terminal, or
+// a log line every 10 seconds when the output is not a terminal
+type DownloadBar struct {
+	source datasource.DataSource
+	out    io.Writer
+	tty    bool
+
+	// Progress when reporting began; downloads resume, so only items and
+	// batches fetched from here on count towards the rate
+	start        time.Time
+	startItems   int64
+	startBatches int
+	counted      bool // The source reported its batches
+	stop         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewDownloadBar creates a bar for a download of source; tty draws the bar
+// on out, otherwise progress goes to the log
+func NewDownloadBar(source datasource.DataSource, out io.Writer, tty bool) *DownloadBar {
+	return &DownloadBar{source: source, out: out, tty: tty, startItems: -1}
+}
+
+// Start reports progress until Stop
+func (b *DownloadBar) Start() {
+	b.stop = make(chan struct{})
+	interval := downloadLogInterval
+	if b.tty {
+		interval = downloadBarInterval
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.stop:
+				return
+			case now := <-ticker.C:
+				b.report(now)
+			}
+		}
+	}()
+}
+
+// Stop ends reporting, leaving the last state of the bar on its line
+func (b *DownloadBar) Stop() {
+	close(b.stop)
+	b.wg.Wait()
+	if b.tty {
+		b.report(time.Now())
+		fmt.Fprintln(b.out)
+	}
+}
+
+// report draws the bar or logs a progress line
+func (b *DownloadBar) report(now time.Time) {
+	status := b.source.GetDownloadStatus()
+	// Sources count the items already stored before planning batches
+	if b.startItems < 0 || !b.counted && status.BatchesTotal > 0 {
+		b.start = now
+		b.startItems = status.ItemsCached
+		b.startBatches = status.BatchesDone
+		b.counted = status.BatchesTotal > 0
+	}
+	line := b.describe(status, now)
+	if b.tty {
+		percentage := status.Progress * 100
+		filled := min(int(status.Progress*downloadBarWidth), downloadBarWidth)
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", downloadBarWidth-filled)
+		fmt.Fprintf(b.out, "\r%s %5.1f%%  %s\033[K", bar, percentage, line)
+		return
+	}
+	log.Logger.Infof("Download progress: %.1f%%, %s", status.Progress*100, line)
+}
+
+// describe formats the batch counter, items fetched, rate and ETA
+func (b *DownloadBar) describe(status datasource.DownloadStatus, now time.Time) string {
+	var parts []string
+	if status.BatchesTotal > 0 {
+		parts = append(parts, fmt.Sprintf("batch %d/%d", status.BatchesDone, status.BatchesTotal))
+	}
+
+	fetched := status.ItemsCached - b.startItems
+	parts = append(parts, fmt.Sprintf("%d items", fetched))
+
+	elapsed := now.Sub(b.start)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(fetched) / elapsed.Seconds()
+	}
+	parts = append(parts, fmt.Sprintf("%.1f items/s", rate))
+
+	// Batches give the closer estimate, as items already stored are skipped
+	eta := "ETA --"
+	if batches := status.BatchesDone - b.startBatches; batches > 0 && status.BatchesTotal > status.BatchesDone {
+		perBatch := elapsed / time.Duration(batches)
+		eta = "ETA " + (perBatch * time.Duration(status.BatchesTotal-status.BatchesDone)).Round(time.Second).String()
+	} else if status.BatchesTotal == 0 && rate > 0 && status.ItemsTotal > status.ItemsCached {
+		remaining := float64(status.ItemsTotal-status.ItemsCached) / rate
+		eta = "ETA " + (time.Duration(remaining) * time.Second).Round(time.Second).String()
+	}
+	return strings.Join(append(parts, eta), "  ")
+}
@@ -4,9 +4,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"path/filepath"
 	"time"
 
+	"github.com/brainless/PubDataHub/internal/storage"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -16,11 +16,18 @@ type ProgressPersistence struct {
 	path string
 }
 
+// DatabaseFile is the name of the progress database in the storage directory
+const DatabaseFile = "progress.db"
+
 // NewProgressPersistence creates a new progress persistence manager
 func NewProgressPersistence(storagePath string) (*ProgressPersistence, error) {
-	dbPath := filepath.Join(storagePath, "progress.db")
+	dbPath := storage.DatabasePath(storagePath, DatabaseFile)
+
+	if err := storage.PrepareDatabase(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open progress database: %w", err)
+	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", storage.DatabaseDSN(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open progress database: %w", err)
 	}
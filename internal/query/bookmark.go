@@ -0,0 +1,55 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BookmarkDir returns the directory bookmarked results are kept in. Bookmarks
+// are result snapshots saved by name to be shown again later.
+func BookmarkDir(storagePath string) string {
+	return filepath.Join(storagePath, "bookmarks")
+}
+
+// ListResultSnapshots reads the snapshots saved in dir, oldest first; a
+// missing directory has none
+func ListResultSnapshots(dir string) ([]*ResultSnapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var snapshots []*ResultSnapshot
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() || !validSnapshotName.MatchString(name) {
+			continue
+		}
+		snapshot, err := LoadResultSnapshot(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Taken.Before(snapshots[j].Taken) })
+	return snapshots, nil
+}
+
+// DeleteResultSnapshot removes a snapshot from dir; the error wraps
+// os.ErrNotExist when there is none
+func DeleteResultSnapshot(dir, name string) error {
+	if !validSnapshotName.MatchString(name) {
+		return fmt.Errorf("invalid snapshot name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	if err := os.Remove(filepath.Join(dir, name+".json")); err != nil {
+		return fmt.Errorf("failed to delete snapshot %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package query
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListAndDeleteResultSnapshots(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bookmarks")
+	if snapshots, err := ListResultSnapshots(dir); err != nil || len(snapshots) != 0 {
+		t.Fatalf("ListResultSnapshots of a missing directory = %v, %v", snapshots, err)
+	}
+
+	now := time.Now()
+	for i, name := range []string{"newer", "older"} {
+		snapshot := &ResultSnapshot{Name: name, Taken: now.Add(-time.Duration(i) * time.Hour), Columns: []string{"id"}}
+		if err := SaveResultSnapshot(dir, snapshot); err != nil {
+			t.Fatalf("SaveResultSnapshot failed: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := ListResultSnapshots(dir)
+	if err != nil {
+		t.Fatalf("ListResultSnapshots failed: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Name != "older" || snapshots[1].Name != "newer" {
+		t.Errorf("ListResultSnapshots = %v, want older then newer", snapshots)
+	}
+
+	if err := DeleteResultSnapshot(dir, "older"); err != nil {
+		t.Fatalf("DeleteResultSnapshot failed: %v", err)
+	}
+	if err := DeleteResultSnapshot(dir, "older"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not exist error, got %v", err)
+	}
+	if err := DeleteResultSnapshot(dir, "../escape"); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+}
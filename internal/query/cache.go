@@ -3,6 +3,8 @@ package query
 import (
 	"sync"
 	"time"
+
+	"github.com/brainless/PubDataHub/internal/storage"
 )
 
 // InMemoryQueryCache implements a simple in-memory cache for query results
@@ -14,10 +16,13 @@ type InMemoryQueryCache struct {
 	missCount int64
 }
 
-// cacheItem represents a cached query result with TTL
+// cacheItem represents a cached query result with TTL and the tables the
+// query reads
 type cacheItem struct {
 	result QueryResult
 	expiry time.Time
+	source string
+	tables []string
 }
 
 // NewInMemoryQueryCache creates a new in-memory query cache
@@ -68,6 +73,8 @@ func (c *InMemoryQueryCache) Set(key string, result QueryResult, ttl time.Durati
 	c.items[key] = &cacheItem{
 		result: result,
 		expiry: time.Now().Add(ttl),
+		source: result.DataSource,
+		tables: storage.ReferencedTables(result.Query),
 	}
 
 	return nil
@@ -82,6 +89,42 @@ func (c *InMemoryQueryCache) Delete(key string) error {
 	return nil
 }
 
+// InvalidateTables removes results of source that read any of the given
+// tables and returns how many were removed. An empty source matches every
+// source; no tables, or a query whose tables are unknown, always matches.
+func (c *InMemoryQueryCache) InvalidateTables(source string, tables ...string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	written := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		written[table] = true
+	}
+
+	removed := 0
+	for key, item := range c.items {
+		if source != "" && item.source != source {
+			continue
+		}
+		if len(written) > 0 && len(item.tables) > 0 && !readsAny(item.tables, written) {
+			continue
+		}
+		delete(c.items, key)
+		removed++
+	}
+	return removed
+}
+
+// readsAny reports whether any of tables is in written
+func readsAny(tables []string, written map[string]bool) bool {
+	for _, table := range tables {
+		if written[table] {
+			return true
+		}
+	}
+	return false
+}
+
 // Clear removes all items from the cache
 func (c *InMemoryQueryCache) Clear() error {
 	c.mu.Lock()
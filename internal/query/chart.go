@@ -0,0 +1,355 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Chart kinds supported by RenderChart
+const (
+	ChartBar       = "bar"
+	ChartSparkline = "spark"
+	ChartHistogram = "hist"
+)
+
+// Chart rendering defaults
+const (
+	DefaultChartWidth = 40
+	DefaultChartBins  = 10
+	maxChartLabel     = 20
+)
+
+// ChartSpec describes how to chart a query result, parsed from specs such as
+// "bar:x=day,y=stories", "spark:y=score" or "hist:x=score,bins=20"
+type ChartSpec struct {
+	Kind  string
+	X     string
+	Y     string
+	Bins  int
+	Width int
+}
+
+// sparkLevels are the block characters used by sparklines, lowest first
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// barEighths are the partial blocks used for the fractional end of a bar
+var barEighths = []rune(" ▏▎▍▌▋▊▉")
+
+// ParseChartSpec parses a chart spec of the form kind:key=value,...
+func ParseChartSpec(spec string) (ChartSpec, error) {
+	kind, options, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	chart := ChartSpec{
+		Kind:  strings.ToLower(kind),
+		Bins:  DefaultChartBins,
+		Width: DefaultChartWidth,
+	}
+
+	switch chart.Kind {
+	case ChartBar, ChartSparkline, ChartHistogram:
+	case "sparkline":
+		chart.Kind = ChartSparkline
+	case "histogram":
+		chart.Kind = ChartHistogram
+	case "":
+		return ChartSpec{}, fmt.Errorf("chart spec is empty, expected e.g. bar:x=col1,y=col2")
+	default:
+		return ChartSpec{}, fmt.Errorf("unknown chart type %q (supported: bar, spark, hist)", kind)
+	}
+
+	for _, option := range strings.Split(options, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(option, "=")
+		if !ok || value == "" {
+			return ChartSpec{}, fmt.Errorf("invalid chart option %q, expected key=value", option)
+		}
+
+		switch strings.ToLower(key) {
+		case "x":
+			chart.X = value
+		case "y":
+			chart.Y = value
+		case "bins":
+			bins, err := strconv.Atoi(value)
+			if err != nil || bins < 1 {
+				return ChartSpec{}, fmt.Errorf("bins must be a positive integer, got %q", value)
+			}
+			chart.Bins = bins
+		case "width":
+			width, err := strconv.Atoi(value)
+			if err != nil || width < 1 {
+				return ChartSpec{}, fmt.Errorf("width must be a positive integer, got %q", value)
+			}
+			chart.Width = width
+		default:
+			return ChartSpec{}, fmt.Errorf("unknown chart option %q", key)
+		}
+	}
+
+	switch chart.Kind {
+	case ChartBar, ChartSparkline:
+		if chart.Y == "" {
+			return ChartSpec{}, fmt.Errorf("%s chart requires a y column, e.g. %s:y=col", chart.Kind, chart.Kind)
+		}
+	case ChartHistogram:
+		if chart.X == "" {
+			chart.X = chart.Y
+		}
+		if chart.X == "" {
+			return ChartSpec{}, fmt.Errorf("hist chart requires an x column, e.g. hist:x=col")
+		}
+	}
+	return chart, nil
+}
+
+// RenderChart renders columns and rows of a query result as a text chart
+func RenderChart(columns []string, rows [][]interface{}, spec ChartSpec) (string, error) {
+	if spec.Width < 1 {
+		spec.Width = DefaultChartWidth
+	}
+	if spec.Bins < 1 {
+		spec.Bins = DefaultChartBins
+	}
+
+	switch spec.Kind {
+	case ChartBar:
+		return renderBarChart(columns, rows, spec)
+	case ChartSparkline:
+		return renderSparkline(columns, rows, spec)
+	case ChartHistogram:
+		return renderHistogram(columns, rows, spec)
+	default:
+		return "", fmt.Errorf("unknown chart type %q", spec.Kind)
+	}
+}
+
+// renderBarChart draws one horizontal bar per row, labelled by the x column
+// or the row number when no x column is given
+func renderBarChart(columns []string, rows [][]interface{}, spec ChartSpec) (string, error) {
+	yIndex, err := columnIndex(columns, spec.Y)
+	if err != nil {
+		return "", err
+	}
+	xIndex := -1
+	if spec.X != "" {
+		if xIndex, err = columnIndex(columns, spec.X); err != nil {
+			return "", err
+		}
+	}
+
+	var labels []string
+	var values []float64
+	for i, row := range rows {
+		value, ok := numericCell(row, yIndex)
+		if !ok {
+			continue
+		}
+		label := strconv.Itoa(i + 1)
+		if xIndex >= 0 {
+			label = cellString(row, xIndex)
+		}
+		labels = append(labels, label)
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return "", fmt.Errorf("column %s has no numeric values to chart", spec.Y)
+	}
+
+	return drawBars(labels, values, spec.Width), nil
+}
+
+// renderSparkline draws the y column as a single line of block characters
+func renderSparkline(columns []string, rows [][]interface{}, spec ChartSpec) (string, error) {
+	yIndex, err := columnIndex(columns, spec.Y)
+	if err != nil {
+		return "", err
+	}
+
+	var values []float64
+	for _, row := range rows {
+		if value, ok := numericCell(row, yIndex); ok {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return "", fmt.Errorf("column %s has no numeric values to chart", spec.Y)
+	}
+
+	// Long results are averaged into buckets so the line fits the width
+	if len(values) > spec.Width {
+		values = downsample(values, spec.Width)
+	}
+
+	low, high := valueRange(values)
+	var line strings.Builder
+	for _, value := range values {
+		level := len(sparkLevels) - 1
+		if high > low {
+			level = int((value - low) / (high - low) * float64(len(sparkLevels)-1))
+		}
+		line.WriteRune(sparkLevels[level])
+	}
+
+	return fmt.Sprintf("%s %s  min %s  max %s\n", spec.Y, line.String(), formatChartValue(low), formatChartValue(high)), nil
+}
+
+// renderHistogram buckets the x column into equal-width bins and draws the
+// number of rows in each bin
+func renderHistogram(columns []string, rows [][]interface{}, spec ChartSpec) (string, error) {
+	xIndex, err := columnIndex(columns, spec.X)
+	if err != nil {
+		return "", err
+	}
+
+	var values []float64
+	for _, row := range rows {
+		if value, ok := numericCell(row, xIndex); ok {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return "", fmt.Errorf("column %s has no numeric values to chart", spec.X)
+	}
+
+	low, high := valueRange(values)
+	bins := spec.Bins
+	if high == low {
+		bins = 1
+	}
+	step := (high - low) / float64(bins)
+
+	counts := make([]float64, bins)
+	for _, value := range values {
+		bin := bins - 1
+		if step > 0 {
+			bin = int((value - low) / step)
+			if bin >= bins {
+				bin = bins - 1
+			}
+		}
+		counts[bin]++
+	}
+
+	labels := make([]string, bins)
+	for i := range labels {
+		start := low + float64(i)*step
+		end := start + step
+		closing := ")"
+		if i == bins-1 {
+			end = high
+			closing = "]"
+		}
+		labels[i] = fmt.Sprintf("[%s, %s%s", formatChartValue(start), formatChartValue(end), closing)
+	}
+
+	return drawBars(labels, counts, spec.Width), nil
+}
+
+// drawBars draws labelled horizontal bars scaled to the largest value
+func drawBars(labels []string, values []float64, width int) string {
+	labelWidth := 0
+	for i, label := range labels {
+		if utf8.RuneCountInString(label) > maxChartLabel {
+			labels[i] = string([]rune(label)[:maxChartLabel-3]) + "..."
+		}
+		if n := utf8.RuneCountInString(labels[i]); n > labelWidth {
+			labelWidth = n
+		}
+	}
+
+	_, high := valueRange(values)
+	var chart strings.Builder
+	for i, value := range values {
+		eighths := 0
+		if high > 0 && value > 0 {
+			eighths = int(math.Round(value / high * float64(width*8)))
+		}
+
+		bar := strings.Repeat("█", eighths/8)
+		if eighths%8 > 0 {
+			bar += string(barEighths[eighths%8])
+		}
+		padding := labelWidth - utf8.RuneCountInString(labels[i])
+		fmt.Fprintf(&chart, "%s%s │%s %s\n", labels[i], strings.Repeat(" ", padding), bar, formatChartValue(value))
+	}
+	return chart.String()
+}
+
+// downsample averages values into the given number of buckets
+func downsample(values []float64, buckets int) []float64 {
+	result := make([]float64, buckets)
+	for i := range result {
+		start := i * len(values) / buckets
+		end := (i + 1) * len(values) / buckets
+		sum := 0.0
+		for _, value := range values[start:end] {
+			sum += value
+		}
+		result[i] = sum / float64(end-start)
+	}
+	return result
+}
+
+// valueRange returns the smallest and largest values
+func valueRange(values []float64) (float64, float64) {
+	low, high := values[0], values[0]
+	for _, value := range values[1:] {
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+	return low, high
+}
+
+// columnIndex finds a result column by name, ignoring case
+func columnIndex(columns []string, name string) (int, error) {
+	for i, column := range columns {
+		if strings.EqualFold(column, name) {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("column %s not found in result (columns: %s)", name, strings.Join(columns, ", "))
+}
+
+// numericCell returns a cell as a number; NULL and non-numeric cells are skipped
+func numericCell(row []interface{}, index int) (float64, bool) {
+	if index >= len(row) {
+		return 0, false
+	}
+	switch v := row[index].(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case []byte:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+		return parsed, err == nil
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return parsed, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// cellString returns a cell as a label
+func cellString(row []interface{}, index int) string {
+	if index >= len(row) || row[index] == nil {
+		return "NULL"
+	}
+	if b, ok := row[index].([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprintf("%v", row[index])
+}
+
+// formatChartValue formats a value to at most two decimals without trailing zeros
+func formatChartValue(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,118 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseChartSpec(t *testing.T) {
+	spec, err := ParseChartSpec("bar:x=day,y=stories,width=20")
+	if err != nil {
+		t.Fatalf("ParseChartSpec failed: %v", err)
+	}
+	if spec.Kind != ChartBar || spec.X != "day" || spec.Y != "stories" || spec.Width != 20 {
+		t.Errorf("Unexpected spec: %+v", spec)
+	}
+
+	spec, err = ParseChartSpec("histogram:x=score")
+	if err != nil {
+		t.Fatalf("ParseChartSpec failed: %v", err)
+	}
+	if spec.Kind != ChartHistogram || spec.Bins != DefaultChartBins {
+		t.Errorf("Unexpected spec: %+v", spec)
+	}
+
+	invalid := []string{"", "pie:y=a", "bar:x=day", "spark", "hist:x=a,bins=0", "bar:y=a,color=red", "bar:y"}
+	for _, input := range invalid {
+		if _, err := ParseChartSpec(input); err == nil {
+			t.Errorf("Expected error for spec %q", input)
+		}
+	}
+}
+
+func TestRenderBarChart(t *testing.T) {
+	columns := []string{"day", "stories"}
+	rows := [][]interface{}{
+		{"2024-01-01", int64(10)},
+		{[]byte("2024-01-02"), int64(5)},
+		{"2024-01-03", nil},
+	}
+
+	chart, err := RenderChart(columns, rows, ChartSpec{Kind: ChartBar, X: "day", Y: "STORIES", Width: 10})
+	if err != nil {
+		t.Fatalf("RenderChart failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(chart, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 bars (NULL skipped), got %d:\n%s", len(lines), chart)
+	}
+	if lines[0] != "2024-01-01 │"+strings.Repeat("█", 10)+" 10" {
+		t.Errorf("Unexpected first bar: %q", lines[0])
+	}
+	if lines[1] != "2024-01-02 │"+strings.Repeat("█", 5)+" 5" {
+		t.Errorf("Unexpected second bar: %q", lines[1])
+	}
+
+	if _, err := RenderChart(columns, rows, ChartSpec{Kind: ChartBar, Y: "missing"}); err == nil {
+		t.Error("Expected error for unknown column")
+	}
+}
+
+func TestRenderSparkline(t *testing.T) {
+	rows := [][]interface{}{{int64(0)}, {int64(7)}, {3.5}, {"7"}}
+
+	chart, err := RenderChart([]string{"score"}, rows, ChartSpec{Kind: ChartSparkline, Y: "score"})
+	if err != nil {
+		t.Fatalf("RenderChart failed: %v", err)
+	}
+	if chart != "score ▁█▄█  min 0  max 7\n" {
+		t.Errorf("Unexpected sparkline: %q", chart)
+	}
+
+	// Values beyond the width are averaged down
+	var long [][]interface{}
+	for i := 0; i < 100; i++ {
+		long = append(long, []interface{}{int64(i)})
+	}
+	chart, err = RenderChart([]string{"score"}, long, ChartSpec{Kind: ChartSparkline, Y: "score", Width: 10})
+	if err != nil {
+		t.Fatalf("RenderChart failed: %v", err)
+	}
+	line := strings.Fields(chart)[1]
+	if n := len([]rune(line)); n != 10 {
+		t.Errorf("Expected 10 sparkline points, got %d", n)
+	}
+}
+
+func TestRenderHistogram(t *testing.T) {
+	var rows [][]interface{}
+	for _, score := range []int64{1, 2, 3, 4, 10} {
+		rows = append(rows, []interface{}{score})
+	}
+
+	chart, err := RenderChart([]string{"score"}, rows, ChartSpec{Kind: ChartHistogram, X: "score", Bins: 3, Width: 4})
+	if err != nil {
+		t.Fatalf("RenderChart failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(chart, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 bins, got %d:\n%s", len(lines), chart)
+	}
+	if !strings.HasPrefix(lines[0], "[1, 4)") || !strings.HasSuffix(lines[0], " 3") {
+		t.Errorf("Unexpected first bin: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[2], "[7, 10]") || !strings.HasSuffix(lines[2], " 1") {
+		t.Errorf("Unexpected last bin: %q", lines[2])
+	}
+
+	// A single distinct value collapses into one bin
+	chart, err = RenderChart([]string{"score"}, [][]interface{}{{int64(5)}, {int64(5)}}, ChartSpec{Kind: ChartHistogram, X: "score"})
+	if err != nil {
+		t.Fatalf("RenderChart failed: %v", err)
+	}
+	if strings.Count(chart, "\n") != 1 {
+		t.Errorf("Expected a single bin, got:\n%s", chart)
+	}
+}
@@ -0,0 +1,333 @@
+package query
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/objectstore"
+)
+
+// Compression is how an exported file is compressed while it is written
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// ParseCompression validates a --compress value; empty and none mean no
+// compression
+func ParseCompression(name string) (Compression, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return CompressionNone, nil
+	case "gzip", "gz":
+		return CompressionGzip, nil
+	case "zstd", "zst":
+		return CompressionZstd, nil
+	}
+	return "", fmt.Errorf("invalid compression %q (supported: gzip, zstd)", name)
+}
+
+// Extension returns the file extension of the compression, such as .gz
+func (c Compression) Extension() string {
+	switch c {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// CompressionForPath returns the compression of a file by its extension,
+// .gz or .zst
+func CompressionForPath(path string) Compression {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		return CompressionGzip
+	case ".zst":
+		return CompressionZstd
+	}
+	return CompressionNone
+}
+
+// CompressedPath returns path with the compression's extension appended,
+// unless it already ends with it
+func CompressedPath(path string, c Compression) string {
+	ext := c.Extension()
+	if ext == "" || strings.HasSuffix(strings.ToLower(path), ext) {
+		return path
+	}
+	return path + ext
+}
+
+// CheckCompression reports whether files of format can be compressed while
+// they are written: xlsx workbooks are compressed already and SQLite
+// databases are not written as a stream
+func CheckCompression(format OutputFormat, c Compression) error {
+	if c == CompressionNone {
+		return nil
+	}
+	switch format {
+	case OutputFormatXLSX:
+		return fmt.Errorf("xlsx workbooks are already compressed, leave out --compress")
+	case OutputFormatSQLite:
+		return fmt.Errorf("sqlite databases cannot be compressed while written, compress the file afterwards")
+	}
+	return nil
+}
+
+// CompressedFile is an output file whose writes are compressed on the way
+// to disk or object storage, so no uncompressed copy is ever stored. It
+// counts the bytes written before and after compression to report the
+// savings.
+type CompressedFile struct {
+	path       string
+	dest       io.WriteCloser // The local file or the object upload
+	upload     *objectstore.Upload
+	sink       *countingWriter // Counts the bytes reaching dest
+	compressor io.WriteCloser  // nil without compression
+	zstd       *exec.Cmd
+	written    int64
+	closed     bool
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// CreateCompressed creates the file at path, compressing what is written to
+// it. An s3://bucket/key path uploads the file to object storage as it is
+// written. zstd compression runs the zstd command, which must be installed.
+func CreateCompressed(path string, c Compression) (*CompressedFile, error) {
+	out := &CompressedFile{path: path}
+	if objectstore.IsURL(path) {
+		upload, err := objectstore.CreateURL(context.Background(), path, contentType(path))
+		if err != nil {
+			return nil, err
+		}
+		out.dest, out.upload = upload, upload
+	} else {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output file: %w", err)
+		}
+		out.dest = file
+	}
+	out.sink = &countingWriter{w: out.dest}
+
+	switch c {
+	case CompressionGzip:
+		out.compressor = gzip.NewWriter(out.sink)
+	case CompressionZstd:
+		cmd := exec.Command("zstd", "-q", "-c", "-T0")
+		cmd.Stdout = out.sink
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err != nil {
+			out.Abort()
+			return nil, fmt.Errorf("zstd compression needs the zstd command: %w", err)
+		}
+		out.compressor, out.zstd = stdin, cmd
+	}
+	return out, nil
+}
+
+// OpenCompressed opens the file at path, decompressing what is read from
+// it. zstd decompression runs the zstd command, which must be installed.
+func OpenCompressed(path string, c Compression) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	switch c {
+	case CompressionGzip:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read gzip stream: %w", err)
+		}
+		return &decompressedFile{Reader: gz, file: file}, nil
+	case CompressionZstd:
+		cmd := exec.Command("zstd", "-q", "-d", "-c")
+		cmd.Stdin = file
+		cmd.Stderr = os.Stderr
+		stdout, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("zstd decompression needs the zstd command: %w", err)
+		}
+		return &decompressedFile{Reader: stdout, file: file, zstd: cmd}, nil
+	}
+	return file, nil
+}
+
+// decompressedFile reads a file through its decompressor
+type decompressedFile struct {
+	io.Reader
+	file *os.File
+	zstd *exec.Cmd
+}
+
+// Close stops the decompressor and closes the file
+func (f *decompressedFile) Close() error {
+	if f.zstd != nil {
+		// Reading may stop before the end of the stream
+		f.zstd.Process.Kill()
+		f.zstd.Wait()
+	}
+	return f.file.Close()
+}
+
+// Write compresses p into the file
+func (f *CompressedFile) Write(p []byte) (int, error) {
+	var n int
+	var err error
+	if f.compressor != nil {
+		n, err = f.compressor.Write(p)
+	} else {
+		n, err = f.sink.Write(p)
+	}
+	f.written += int64(n)
+	return n, err
+}
+
+// Close flushes the compressor and closes the file, completing an upload;
+// closing it again does nothing
+func (f *CompressedFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	err := f.closeCompressor()
+	if err != nil {
+		f.discard()
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if err := f.dest.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	return nil
+}
+
+// Abort stops writing and removes the partial file or upload; it does
+// nothing once the file is closed
+func (f *CompressedFile) Abort() {
+	if f.closed {
+		return
+	}
+	f.closed = true
+	f.closeCompressor()
+	f.discard()
+}
+
+// closeCompressor flushes the compressed stream into the file
+func (f *CompressedFile) closeCompressor() error {
+	if f.compressor == nil {
+		return nil
+	}
+	err := f.compressor.Close()
+	if f.zstd != nil {
+		if waitErr := f.zstd.Wait(); err == nil && waitErr != nil {
+			err = fmt.Errorf("zstd failed: %w", waitErr)
+		}
+	}
+	return err
+}
+
+// discard removes the partial file or aborts the upload
+func (f *CompressedFile) discard() {
+	if f.upload != nil {
+		f.upload.Abort()
+		return
+	}
+	f.dest.Close()
+	os.Remove(f.path)
+}
+
+// Location returns where the file was written: its path, or the HTTP URL of
+// an uploaded object
+func (f *CompressedFile) Location() string {
+	if f.upload != nil {
+		return f.upload.Location()
+	}
+	return f.path
+}
+
+// Written returns the bytes written before compression
+func (f *CompressedFile) Written() int64 {
+	return f.written
+}
+
+// Size returns the size of the written file
+func (f *CompressedFile) Size() int64 {
+	return f.sink.n
+}
+
+// Savings describes how much smaller the compressed file is than its
+// contents, e.g. "12.1 MB, 81% smaller than 63.7 MB"; empty without
+// compression
+func (f *CompressedFile) Savings() string {
+	if f.compressor == nil || f.written == 0 {
+		return ""
+	}
+	saved := 100 - float64(f.Size())*100/float64(f.written)
+	return fmt.Sprintf("%s, %.0f%% smaller than %s", formatBytes(f.Size()), saved, formatBytes(f.written))
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// contentType returns the media type of an exported file by its extension
+func contentType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		return "application/gzip"
+	case ".zst":
+		return "application/zstd"
+	case ".csv":
+		return "text/csv"
+	case ".tsv", ".txt":
+		return "text/tab-separated-values"
+	case ".json":
+		return "application/json"
+	case ".xlsx":
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,167 @@
+package query
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/objectstore"
+)
+
+func TestParseCompression(t *testing.T) {
+	for name, want := range map[string]Compression{
+		"":     CompressionNone,
+		"none": CompressionNone,
+		"GZIP": CompressionGzip,
+		"zst":  CompressionZstd,
+	} {
+		got, err := ParseCompression(name)
+		if err != nil || got != want {
+			t.Errorf("ParseCompression(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseCompression("bzip2"); err == nil {
+		t.Error("bzip2 should be rejected")
+	}
+}
+
+func TestCompressedPath(t *testing.T) {
+	for _, tt := range []struct {
+		path        string
+		compression Compression
+		want        string
+	}{
+		{"items.csv", CompressionGzip, "items.csv.gz"},
+		{"items.csv.GZ", CompressionGzip, "items.csv.GZ"},
+		{"items.json", CompressionZstd, "items.json.zst"},
+		{"items.csv", CompressionNone, "items.csv"},
+	} {
+		if got := CompressedPath(tt.path, tt.compression); got != tt.want {
+			t.Errorf("CompressedPath(%q, %q) = %q, want %q", tt.path, tt.compression, got, tt.want)
+		}
+	}
+
+	if got := CompressionForPath("items.tsv.zst"); got != CompressionZstd {
+		t.Errorf("CompressionForPath = %q, want zstd", got)
+	}
+	if got := FormatForPath("items.JSON.GZ"); got != OutputFormatJSON {
+		t.Errorf("FormatForPath should skip the compression extension, got %q", got)
+	}
+}
+
+func TestSaveResultGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "items.csv.gz")
+	rows := make([][]interface{}, 1000)
+	for i := range rows {
+		rows[i] = []interface{}{int64(i), "the same title over and over"}
+	}
+
+	savings, err := SaveResult(path, SaveOptions{Format: OutputFormatCSV, Compression: CompressionGzip}, []string{"id", "title"}, rows)
+	if err != nil {
+		t.Fatalf("SaveResult failed: %v", err)
+	}
+	if !strings.Contains(savings, "% smaller than") {
+		t.Errorf("unexpected savings %q", savings)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open export: %v", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("export is not gzip: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to decompress: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "id,title\n0,the same title over and over\n") {
+		t.Errorf("unexpected contents %q", string(data)[:40])
+	}
+}
+
+func TestCreateCompressedZstd(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd is not installed")
+	}
+	path := filepath.Join(t.TempDir(), "items.tsv.zst")
+	file, err := CreateCompressed(path, CompressionZstd)
+	if err != nil {
+		t.Fatalf("CreateCompressed failed: %v", err)
+	}
+	if err := WriteDelimited(file, []string{"id"}, [][]interface{}{{int64(1)}, {int64(2)}}, '\t'); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	out, err := exec.Command("zstd", "-d", "-c", path).Output()
+	if err != nil {
+		t.Fatalf("zstd could not decompress the export: %v", err)
+	}
+	if string(out) != "id\n1\n2\n" {
+		t.Errorf("unexpected contents %q", out)
+	}
+	if file.Written() != int64(len(out)) {
+		t.Errorf("Written() = %d, want %d", file.Written(), len(out))
+	}
+}
+
+func TestSaveResultCompressionUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	for _, format := range []OutputFormat{OutputFormatXLSX, OutputFormatSQLite} {
+		opts := SaveOptions{Format: format, Compression: CompressionGzip}
+		if _, err := SaveResult(filepath.Join(dir, "results"), opts, []string{"n"}, nil); err == nil {
+			t.Errorf("%s should not be compressed", format)
+		}
+	}
+}
+
+func TestSaveResultUpload(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPut {
+			objects[r.URL.Path] = body
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("PUBDATAHUB_SECRET_S3_ACCESS_KEY_ID", "key")
+	t.Setenv("PUBDATAHUB_SECRET_S3_SECRET_ACCESS_KEY", "secret")
+	previous := objectstore.Defaults()
+	objectstore.SetDefaults(objectstore.Settings{Endpoint: server.URL, PathStyle: true})
+	defer objectstore.SetDefaults(previous)
+
+	opts := SaveOptions{Format: FormatForPath("items.csv.gz"), Compression: CompressionGzip}
+	if _, err := SaveResult("s3://exports/items.csv.gz", opts, []string{"id"}, [][]interface{}{{int64(1)}}); err != nil {
+		t.Fatalf("SaveResult failed: %v", err)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(objects["/exports/items.csv.gz"]))
+	if err != nil {
+		t.Fatalf("uploaded object is not gzip: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	if string(data) != "id\n1\n" {
+		t.Errorf("unexpected object contents %q", data)
+	}
+
+	if _, err := SaveResult("s3://exports/items.db", SaveOptions{Format: OutputFormatSQLite}, []string{"id"}, nil); err == nil {
+		t.Error("sqlite exports should not be uploaded")
+	}
+}
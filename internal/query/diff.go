@@ -0,0 +1,366 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+// Placeholders replaced with the bounds of a time window, as Unix seconds
+const (
+	WindowStartPlaceholder = "{start}"
+	WindowEndPlaceholder   = "{end}"
+)
+
+// ResultDiff is the difference between two results of a query, matching rows
+// by the values of key columns
+type ResultDiff struct {
+	Columns   []string
+	Key       []string
+	Added     [][]interface{} // Rows only in the later result
+	Removed   [][]interface{} // Rows only in the earlier result, in Columns order
+	Changed   []RowChange
+	Unchanged int
+}
+
+// RowChange is a row present in both results with different values
+type RowChange struct {
+	Before  []interface{}
+	After   []interface{}
+	Columns []int // Indexes of the columns that changed
+}
+
+// DiffResults compares two results of the same query. Rows are matched by the
+// key columns, which must identify rows uniquely; the earlier result may have
+// its columns in a different order.
+func DiffResults(before, after datasource.QueryResult, key []string) (*ResultDiff, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("a key column is required to match rows")
+	}
+	afterKey, err := columnIndexes(after.Columns, key)
+	if err != nil {
+		return nil, err
+	}
+	beforeKey, err := columnIndexes(before.Columns, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Arrange earlier rows in the column order of the later result
+	positions := make([]int, len(after.Columns))
+	for i, column := range after.Columns {
+		positions[i] = indexOf(before.Columns, column)
+	}
+	earlier := make(map[string][]interface{}, len(before.Rows))
+	var order []string
+	for _, row := range before.Rows {
+		k := rowKey(row, beforeKey)
+		if _, exists := earlier[k]; exists {
+			return nil, fmt.Errorf("key %s is not unique in the earlier result", strings.Join(key, ", "))
+		}
+		aligned := make([]interface{}, len(positions))
+		for i, position := range positions {
+			if position >= 0 && position < len(row) {
+				aligned[i] = row[position]
+			}
+		}
+		earlier[k] = aligned
+		order = append(order, k)
+	}
+
+	diff := &ResultDiff{Columns: after.Columns, Key: key}
+	seen := make(map[string]bool, len(after.Rows))
+	for _, row := range after.Rows {
+		k := rowKey(row, afterKey)
+		if seen[k] {
+			return nil, fmt.Errorf("key %s is not unique in the later result", strings.Join(key, ", "))
+		}
+		seen[k] = true
+
+		previous, exists := earlier[k]
+		if !exists {
+			diff.Added = append(diff.Added, row)
+			continue
+		}
+		var changed []int
+		for i := range after.Columns {
+			var value interface{}
+			if i < len(row) {
+				value = row[i]
+			}
+			if diffValue(value) != diffValue(previous[i]) {
+				changed = append(changed, i)
+			}
+		}
+		if len(changed) == 0 {
+			diff.Unchanged++
+			continue
+		}
+		diff.Changed = append(diff.Changed, RowChange{Before: previous, After: row, Columns: changed})
+	}
+	for _, k := range order {
+		if !seen[k] {
+			diff.Removed = append(diff.Removed, earlier[k])
+		}
+	}
+	return diff, nil
+}
+
+// IsEmpty reports whether both results had the same rows
+func (d *ResultDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// FormatDiff describes a diff as text: added rows start with +, removed rows
+// with - and changed rows with ~ followed by the old and new values
+func FormatDiff(diff *ResultDiff) string {
+	var out strings.Builder
+	keyIndexes, _ := columnIndexes(diff.Columns, diff.Key)
+
+	if len(diff.Added) > 0 {
+		fmt.Fprintf(&out, "Added (%d):\n", len(diff.Added))
+		for _, row := range diff.Added {
+			out.WriteString("+ " + formatDiffRow(diff.Columns, row) + "\n")
+		}
+	}
+	if len(diff.Removed) > 0 {
+		fmt.Fprintf(&out, "Removed (%d):\n", len(diff.Removed))
+		for _, row := range diff.Removed {
+			out.WriteString("- " + formatDiffRow(diff.Columns, row) + "\n")
+		}
+	}
+	if len(diff.Changed) > 0 {
+		fmt.Fprintf(&out, "Changed (%d):\n", len(diff.Changed))
+		for _, change := range diff.Changed {
+			parts := make([]string, 0, len(keyIndexes)+len(change.Columns))
+			for _, i := range keyIndexes {
+				parts = append(parts, diff.Columns[i]+"="+diffValue(change.After[i]))
+			}
+			for _, i := range change.Columns {
+				parts = append(parts, fmt.Sprintf("%s: %s -> %s", diff.Columns[i], diffValue(change.Before[i]), diffValue(change.After[i])))
+			}
+			out.WriteString("~ " + strings.Join(parts, "  ") + "\n")
+		}
+	}
+	fmt.Fprintf(&out, "%d added, %d removed, %d changed, %d unchanged\n",
+		len(diff.Added), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+	return out.String()
+}
+
+// formatDiffRow formats a row as column=value pairs
+func formatDiffRow(columns []string, row []interface{}) string {
+	parts := make([]string, 0, len(columns))
+	for i, column := range columns {
+		var value interface{}
+		if i < len(row) {
+			value = row[i]
+		}
+		parts = append(parts, column+"="+diffValue(value))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// diffValue formats a value for comparison and display; numbers compare
+// equal whether they come from the database or a JSON snapshot
+func diffValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// rowKey joins the key values of a row
+func rowKey(row []interface{}, indexes []int) string {
+	values := make([]string, len(indexes))
+	for i, index := range indexes {
+		if index < len(row) {
+			values[i] = diffValue(row[index])
+		}
+	}
+	return strings.Join(values, "\x00")
+}
+
+// columnIndexes returns the positions of the named columns
+func columnIndexes(columns, names []string) ([]int, error) {
+	indexes := make([]int, len(names))
+	for i, name := range names {
+		indexes[i] = indexOf(columns, name)
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("key column %q is not in the result (columns: %s)", name, strings.Join(columns, ", "))
+		}
+	}
+	return indexes, nil
+}
+
+// indexOf returns the position of a column, or -1
+func indexOf(columns []string, name string) int {
+	for i, column := range columns {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// TimeWindow is a time range a query is run for, from Start up to End
+type TimeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ParseTimeWindow parses a range such as "2024-01-01..2024-01-08"; bounds
+// are dates or RFC 3339 times
+func ParseTimeWindow(value string) (TimeWindow, error) {
+	start, end, found := strings.Cut(value, "..")
+	if !found {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q (expected start..end)", value)
+	}
+	var window TimeWindow
+	var err error
+	if window.Start, err = parseWindowBound(start); err != nil {
+		return TimeWindow{}, err
+	}
+	if window.End, err = parseWindowBound(end); err != nil {
+		return TimeWindow{}, err
+	}
+	if !window.End.After(window.Start) {
+		return TimeWindow{}, fmt.Errorf("time window %q ends before it starts", value)
+	}
+	return window, nil
+}
+
+// parseWindowBound parses a date or RFC 3339 time
+func parseWindowBound(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (use YYYY-MM-DD or RFC 3339)", value)
+	}
+	return t, nil
+}
+
+// ConsecutiveWindows returns the period before now and the period before
+// that, e.g. this week and last week
+func ConsecutiveWindows(period time.Duration, now time.Time) (previous, current TimeWindow) {
+	current = TimeWindow{Start: now.Add(-period), End: now}
+	previous = TimeWindow{Start: current.Start.Add(-period), End: current.Start}
+	return previous, current
+}
+
+// Apply replaces the window placeholders of a query with the bounds of the
+// window
+func (w TimeWindow) Apply(query string) string {
+	return strings.NewReplacer(
+		WindowStartPlaceholder, strconv.FormatInt(w.Start.Unix(), 10),
+		WindowEndPlaceholder, strconv.FormatInt(w.End.Unix(), 10),
+	).Replace(query)
+}
+
+// String formats the window for display
+func (w TimeWindow) String() string {
+	return w.Start.Format("2006-01-02 15:04") + " .. " + w.End.Format("2006-01-02 15:04")
+}
+
+// HasWindowPlaceholders reports whether a query uses a time window
+func HasWindowPlaceholders(query string) bool {
+	return strings.Contains(query, WindowStartPlaceholder) || strings.Contains(query, WindowEndPlaceholder)
+}
+
+// ResultSnapshot is a saved query result that later results are compared with
+type ResultSnapshot struct {
+	Name    string          `json:"name"`
+	Source  string          `json:"source"`
+	Query   string          `json:"query"`
+	Taken   time.Time       `json:"taken"`
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// validSnapshotName matches snapshot names, which are also file names
+var validSnapshotName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ResultSnapshotDir returns the directory result snapshots are kept in
+func ResultSnapshotDir(storagePath string) string {
+	return filepath.Join(storagePath, "result_snapshots")
+}
+
+// NewResultSnapshot captures a query result
+func NewResultSnapshot(name, source, query string, result datasource.QueryResult) *ResultSnapshot {
+	rows := make([][]interface{}, len(result.Rows))
+	for i, row := range result.Rows {
+		rows[i] = make([]interface{}, len(row))
+		for j, value := range row {
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			rows[i][j] = value
+		}
+	}
+	return &ResultSnapshot{
+		Name:    name,
+		Source:  source,
+		Query:   query,
+		Taken:   time.Now(),
+		Columns: result.Columns,
+		Rows:    rows,
+	}
+}
+
+// Result returns the saved result
+func (s *ResultSnapshot) Result() datasource.QueryResult {
+	return datasource.QueryResult{Columns: s.Columns, Rows: s.Rows, Count: len(s.Rows)}
+}
+
+// SaveResultSnapshot writes a snapshot to dir, replacing one with the same
+// name
+func SaveResultSnapshot(dir string, snapshot *ResultSnapshot) error {
+	if !validSnapshotName.MatchString(snapshot.Name) {
+		return fmt.Errorf("invalid snapshot name %q (use letters, digits, '.', '_' and '-')", snapshot.Name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, snapshot.Name+".json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+	return nil
+}
+
+// LoadResultSnapshot reads a snapshot from dir; the error wraps
+// os.ErrNotExist when there is none
+func LoadResultSnapshot(dir, name string) (*ResultSnapshot, error) {
+	if !validSnapshotName.MatchString(name) {
+		return nil, fmt.Errorf("invalid snapshot name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", name, err)
+	}
+	var snapshot ResultSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", name, err)
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,139 @@
+package query
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+func TestDiffResults(t *testing.T) {
+	before := datasource.QueryResult{
+		Columns: []string{"score", "id", "title"},
+		Rows: [][]interface{}{
+			{float64(100), float64(1), "Kept"},
+			{float64(50), float64(2), "Gone"},
+			{float64(10), float64(3), "Rising"},
+		},
+	}
+	after := datasource.QueryResult{
+		Columns: []string{"id", "title", "score"},
+		Rows: [][]interface{}{
+			{int64(1), []byte("Kept"), int64(100)},
+			{int64(3), "Rising", int64(80)},
+			{int64(4), "New", nil},
+		},
+	}
+
+	diff, err := DiffResults(before, after, []string{"id"})
+	if err != nil {
+		t.Fatalf("DiffResults failed: %v", err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0][1] != "New" {
+		t.Errorf("Added = %v, want the New row", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0][1] != "Gone" {
+		t.Errorf("Removed = %v, want the Gone row in result column order", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || len(diff.Changed[0].Columns) != 1 || diff.Changed[0].Columns[0] != 2 {
+		t.Fatalf("Changed = %+v, want the score of id 3", diff.Changed)
+	}
+	if diff.Unchanged != 1 {
+		t.Errorf("Unchanged = %d, want 1", diff.Unchanged)
+	}
+
+	text := FormatDiff(diff)
+	for _, want := range []string{
+		"+ id=4  title=New  score=NULL\n",
+		"- id=2  title=Gone  score=50\n",
+		"~ id=3  score: 10 -> 80\n",
+		"1 added, 1 removed, 1 changed, 1 unchanged\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("FormatDiff missing %q in:\n%s", want, text)
+		}
+	}
+}
+
+func TestDiffResultsErrors(t *testing.T) {
+	result := datasource.QueryResult{Columns: []string{"id"}, Rows: [][]interface{}{{1}, {1}}}
+	if _, err := DiffResults(result, result, nil); err == nil {
+		t.Error("expected an error without a key")
+	}
+	if _, err := DiffResults(result, result, []string{"title"}); err == nil {
+		t.Error("expected an error for a missing key column")
+	}
+	if _, err := DiffResults(result, result, []string{"id"}); err == nil {
+		t.Error("expected an error for duplicate keys")
+	}
+}
+
+func TestDiffResultsCompositeKey(t *testing.T) {
+	before := datasource.QueryResult{Columns: []string{"by", "day", "n"}, Rows: [][]interface{}{{"pg", "mon", 1}, {"pg", "tue", 2}}}
+	after := datasource.QueryResult{Columns: []string{"by", "day", "n"}, Rows: [][]interface{}{{"pg", "mon", 1}, {"pg", "tue", 3}}}
+
+	diff, err := DiffResults(before, after, []string{"by", "day"})
+	if err != nil {
+		t.Fatalf("DiffResults failed: %v", err)
+	}
+	if len(diff.Changed) != 1 || diff.Unchanged != 1 || diff.IsEmpty() {
+		t.Errorf("diff = %+v, want one changed and one unchanged row", diff)
+	}
+}
+
+func TestTimeWindows(t *testing.T) {
+	window, err := ParseTimeWindow("2024-01-01..2024-01-08")
+	if err != nil {
+		t.Fatalf("ParseTimeWindow failed: %v", err)
+	}
+	got := window.Apply("SELECT COUNT(*) FROM items WHERE time >= {start} AND time < {end}")
+	if got != "SELECT COUNT(*) FROM items WHERE time >= 1704067200 AND time < 1704672000" {
+		t.Errorf("Apply = %q", got)
+	}
+
+	for _, invalid := range []string{"2024-01-01", "2024-01-08..2024-01-01", "yesterday..today"} {
+		if _, err := ParseTimeWindow(invalid); err == nil {
+			t.Errorf("ParseTimeWindow(%q) should fail", invalid)
+		}
+	}
+
+	now := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	previous, current := ConsecutiveWindows(7*24*time.Hour, now)
+	if !current.End.Equal(now) || !previous.End.Equal(current.Start) || previous.Start.Day() != 1 {
+		t.Errorf("ConsecutiveWindows = %v, %v", previous, current)
+	}
+	if HasWindowPlaceholders("SELECT 1") || !HasWindowPlaceholders("WHERE time < {end}") {
+		t.Error("HasWindowPlaceholders reported the wrong result")
+	}
+}
+
+func TestResultSnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadResultSnapshot(dir, "top"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a not exist error, got %v", err)
+	}
+
+	result := datasource.QueryResult{Columns: []string{"id", "title"}, Rows: [][]interface{}{{int64(1), []byte("Hello")}}}
+	if err := SaveResultSnapshot(dir, NewResultSnapshot("top", "hackernews", "SELECT id, title FROM items", result)); err != nil {
+		t.Fatalf("SaveResultSnapshot failed: %v", err)
+	}
+	snapshot, err := LoadResultSnapshot(dir, "top")
+	if err != nil {
+		t.Fatalf("LoadResultSnapshot failed: %v", err)
+	}
+
+	diff, err := DiffResults(snapshot.Result(), result, []string{"id"})
+	if err != nil {
+		t.Fatalf("DiffResults failed: %v", err)
+	}
+	if !diff.IsEmpty() {
+		t.Errorf("a snapshot should equal its result, got %s", FormatDiff(diff))
+	}
+
+	if err := SaveResultSnapshot(dir, &ResultSnapshot{Name: "../escape"}); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+}
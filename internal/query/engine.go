@@ -29,10 +29,11 @@ type TUIQueryEngine struct {
 	enableCache          bool
 
 	// State
-	isRunning    bool
-	ctx          context.Context
-	cancel       context.CancelFunc
-	queryCounter int64
+	isRunning        bool
+	stopInvalidation func()
+	ctx              context.Context
+	cancel           context.CancelFunc
+	queryCounter     int64
 }
 
 // NewTUIQueryEngine creates a new query engine instance
@@ -68,6 +69,9 @@ func (e *TUIQueryEngine) Start() error {
 	log.Logger.Info("Starting query engine")
 	e.isRunning = true
 
+	// Drop cached results once downloads or other writers change their tables
+	e.stopInvalidation = storage.OnTableWrites(e.invalidateCache)
+
 	// Initialize metrics tracking
 	go e.metricsCollector()
 
@@ -86,6 +90,11 @@ func (e *TUIQueryEngine) Stop() error {
 	log.Logger.Info("Stopping query engine")
 	e.cancel()
 
+	if e.stopInvalidation != nil {
+		e.stopInvalidation()
+		e.stopInvalidation = nil
+	}
+
 	// Close active session
 	if e.activeSession != nil {
 		e.activeSession.Close()
@@ -143,7 +152,7 @@ func (e *TUIQueryEngine) ExecuteConcurrent(dataSource string, query string) (Que
 	result, err := e.executeQueryWithContext(ctx, ds, query, dataSource)
 	if err != nil {
 		e.updateMetrics(false, time.Since(start))
-		return QueryResult{}, err
+		return QueryResult{}, DiagnoseQueryError(query, err, ds.GetSchema())
 	}
 
 	duration := time.Since(start)
@@ -182,6 +191,16 @@ func (e *TUIQueryEngine) ExecuteConcurrent(dataSource string, query string) (Que
 	return tuiResult, nil
 }
 
+// invalidateCache removes cached results that read tables which were written
+func (e *TUIQueryEngine) invalidateCache(source string, tables []string) {
+	if e.cache == nil {
+		return
+	}
+	if removed := e.cache.InvalidateTables(source, tables...); removed > 0 {
+		log.Logger.Debugf("Invalidated %d cached query results after writes to %s %v", removed, source, tables)
+	}
+}
+
 // ExecuteInteractive starts an interactive query session
 func (e *TUIQueryEngine) ExecuteInteractive(dataSource string) error {
 	if !e.isRunning {
@@ -203,8 +222,11 @@ func (e *TUIQueryEngine) ExecuteInteractive(dataSource string) error {
 	return e.runInteractiveLoop(session)
 }
 
-// StartExportJob creates a background export job
-func (e *TUIQueryEngine) StartExportJob(dataSource, query string, format OutputFormat, file string) (string, error) {
+// StartExportJob creates a background export job. A compressed export is
+// written to file with the compression's extension appended, with the
+// redaction rules applied when redaction is not nil. Once written, the
+// configured export hooks run, then hooks.
+func (e *TUIQueryEngine) StartExportJob(dataSource, query string, format OutputFormat, compression Compression, redaction *Redaction, file string, hooks ...ExportHook) (string, error) {
 	if !e.isRunning {
 		return "", fmt.Errorf("query engine not running")
 	}
@@ -213,26 +235,8 @@ func (e *TUIQueryEngine) StartExportJob(dataSource, query string, format OutputF
 		return "", fmt.Errorf("job manager not available")
 	}
 
-	// Create export job
-	exportJob := &ExportJobImpl{
-		BaseJob: BaseJob{
-			JobID:          fmt.Sprintf("export_%d", time.Now().UnixNano()),
-			JobType:        jobs.JobTypeExport,
-			JobPriority:    jobs.PriorityNormal,
-			JobDescription: fmt.Sprintf("Export query results from %s to %s", dataSource, file),
-			JobMetadata: jobs.JobMetadata{
-				"data_source":   dataSource,
-				"query":         query,
-				"output_file":   file,
-				"output_format": string(format),
-			},
-		},
-		dataSource: dataSource,
-		query:      query,
-		format:     format,
-		outputFile: file,
-		engine:     e,
-	}
+	exportJob := NewExportJob(dataSource, query, format, compression, redaction, file, hooks...)
+	exportJob.engine = e
 
 	// Submit the job
 	jobID, err := e.jobManager.SubmitJob(exportJob)
@@ -8,6 +8,7 @@ import (
 	"github.com/brainless/PubDataHub/internal/datasource"
 	"github.com/brainless/PubDataHub/internal/jobs"
 	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/storage"
 )
 
 // MockDataSource implements the DataSource interface for testing
@@ -241,7 +242,7 @@ func TestStartExportJob(t *testing.T) {
 	engine.Start()
 	defer engine.Stop()
 
-	jobID, err := engine.StartExportJob("test", "SELECT * FROM items", OutputFormatCSV, "/tmp/export.csv")
+	jobID, err := engine.StartExportJob("test", "SELECT * FROM items", OutputFormatCSV, CompressionNone, nil, "/tmp/export.csv")
 	if err != nil {
 		t.Fatalf("Failed to start export job: %v", err)
 	}
@@ -322,3 +323,39 @@ func TestSessionManagement(t *testing.T) {
 		t.Error("Expected no active session after closing")
 	}
 }
+
+func TestExecuteConcurrentCacheInvalidation(t *testing.T) {
+	source := &MockDataSource{
+		name:        "test",
+		queryResult: datasource.QueryResult{Columns: []string{"count"}, Rows: [][]interface{}{{1}}, Count: 1},
+	}
+	engine := NewTUIQueryEngine(map[string]datasource.DataSource{"test": source}, nil, NewMockJobManager())
+	engine.Start()
+	defer engine.Stop()
+
+	query := "SELECT COUNT(*) AS count FROM items WHERE type = 'story'"
+	if _, err := engine.ExecuteConcurrent("test", query); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	source.queryResult = datasource.QueryResult{Columns: []string{"count"}, Rows: [][]interface{}{{2}}, Count: 1}
+
+	// Writes to other tables or sources leave the cached result in place
+	storage.NotifyTableWrites("test", "batch_status")
+	storage.NotifyTableWrites("other", "items")
+	result, err := engine.ExecuteConcurrent("test", query)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.Rows[0][0] != 1 {
+		t.Errorf("Expected cached result, got %v", result.Rows[0][0])
+	}
+
+	storage.NotifyTableWrites("test", "items")
+	result, err = engine.ExecuteConcurrent("test", query)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.Rows[0][0] != 2 {
+		t.Errorf("Expected fresh result after write, got %v", result.Rows[0][0])
+	}
+}
@@ -1,6 +1,7 @@
 package query
 
 import (
+	"archive/zip"
 	"context"
 	"encoding/csv"
 	"encoding/json"
@@ -9,8 +10,10 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/brainless/PubDataHub/internal/datasource"
 	"github.com/brainless/PubDataHub/internal/jobs"
 	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/objectstore"
 )
 
 // ExportJobImpl implements the Job interface for query export operations
@@ -18,22 +21,71 @@ type ExportJobImpl struct {
 	BaseJob
 
 	// Export-specific fields
-	dataSource string
-	query      string
-	format     OutputFormat
-	outputFile string
-	engine     *TUIQueryEngine
+	dataSource  string
+	query       string
+	format      OutputFormat
+	compression Compression
+	outputFile  string
+	redaction   *Redaction   // Applied to the results before they are written
+	hooks       []ExportHook // Run once the output is written
+	engine      *TUIQueryEngine
+	run         func(query string) (datasource.QueryResult, error) // Runs the query instead of the engine
 
 	// Progress tracking
-	rowsExported     int64
-	totalRows        int64
-	bytesWritten     int64
-	compressionRatio float64
+	rowsExported int64
+	totalRows    int64
+	bytesWritten int64
+	savings      string // Size saved by compression, empty without
 
 	// State
 	isPaused bool
 }
 
+// NewExportJob creates a job exporting the result of a query to file,
+// compressed with compression and redacted when redaction is not nil. The
+// configured export hooks run once it is written, then hooks.
+func NewExportJob(dataSource, query string, format OutputFormat, compression Compression, redaction *Redaction, file string, hooks ...ExportHook) *ExportJobImpl {
+	file = CompressedPath(file, compression)
+	job := &ExportJobImpl{
+		BaseJob: BaseJob{
+			JobID:          fmt.Sprintf("export_%d", time.Now().UnixNano()),
+			JobType:        jobs.JobTypeExport,
+			JobPriority:    jobs.PriorityNormal,
+			JobDescription: fmt.Sprintf("Export query results from %s to %s", dataSource, file),
+			JobMetadata: jobs.JobMetadata{
+				"data_source":   dataSource,
+				"query":         query,
+				"output_file":   file,
+				"output_format": string(format),
+				"compression":   string(compression),
+			},
+		},
+		dataSource:  dataSource,
+		query:       query,
+		format:      format,
+		compression: compression,
+		outputFile:  file,
+		redaction:   redaction,
+		hooks:       append(ExportHooks(), hooks...),
+	}
+	if redaction != nil {
+		job.JobMetadata["redaction_rules"] = len(redaction.Rules)
+	}
+	return job
+}
+
+// SetQueryRunner makes the job run its query with run, such as on a data
+// source of the interactive session, instead of through a query engine
+func (e *ExportJobImpl) SetQueryRunner(run func(query string) (datasource.QueryResult, error)) {
+	e.run = run
+}
+
+// Bound reports that the job runs its submitted instance: its query runner
+// and engine cannot be restored from its metadata
+func (e *ExportJobImpl) Bound() bool {
+	return e.run != nil || e.engine != nil
+}
+
 // BaseJob provides common job functionality
 type BaseJob struct {
 	JobID          string
@@ -94,11 +146,18 @@ func (e *ExportJobImpl) Execute(ctx context.Context, progressCallback jobs.Progr
 	}
 
 	// Execute the query to get data
-	result, err := e.engine.ExecuteConcurrent(e.dataSource, e.query)
+	result, err := e.execute()
 	if err != nil {
 		return fmt.Errorf("failed to execute query: %w", err)
 	}
 
+	if e.redaction != nil {
+		result.Columns, result.Rows, err = e.redaction.Apply(result.Columns, result.Rows)
+		if err != nil {
+			return fmt.Errorf("failed to redact results: %w", err)
+		}
+	}
+
 	e.totalRows = int64(result.Count)
 
 	// Report initial progress
@@ -112,6 +171,10 @@ func (e *ExportJobImpl) Execute(ctx context.Context, progressCallback jobs.Progr
 		err = e.exportToJSON(ctx, result, progressCallback)
 	case OutputFormatTSV:
 		err = e.exportToTSV(ctx, result, progressCallback)
+	case OutputFormatSQLite:
+		err = e.exportToSQLite(result)
+	case OutputFormatXLSX:
+		err = e.exportToXLSX(result)
 	default:
 		return fmt.Errorf("unsupported export format: %s", e.format)
 	}
@@ -123,12 +186,67 @@ func (e *ExportJobImpl) Execute(ctx context.Context, progressCallback jobs.Progr
 	// Report completion
 	e.updateProgress(e.totalRows, "Export completed", progressCallback)
 
-	log.Logger.Infof("Export job completed: %s (%d rows, %.2f MB)",
-		e.ID(), e.rowsExported, float64(e.bytesWritten)/(1024*1024))
+	summary := fmt.Sprintf("%.2f MB", float64(e.bytesWritten)/(1024*1024))
+	if e.savings != "" {
+		summary = e.savings
+	}
+	if url, ok := e.JobMetadata["object_url"].(string); ok {
+		summary += ", uploaded to " + url
+	}
+	log.Logger.Infof("Export job completed: %s (%d rows, %s)", e.ID(), e.rowsExported, summary)
 
+	e.runHooks(ctx)
 	return nil
 }
 
+// execute runs the job's query with its runner or the engine
+func (e *ExportJobImpl) execute() (QueryResult, error) {
+	if e.run == nil {
+		return e.engine.ExecuteConcurrent(e.dataSource, e.query)
+	}
+	result, err := e.run(e.query)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	return QueryResult{
+		Columns:    result.Columns,
+		Rows:       result.Rows,
+		Count:      result.Count,
+		Duration:   result.Duration,
+		Query:      e.query,
+		Timestamp:  time.Now(),
+		DataSource: e.dataSource,
+	}, nil
+}
+
+// runHooks runs the job's hooks on its output. The export itself has
+// succeeded, so a failing hook is logged and recorded in the job metadata
+// rather than failing the job.
+func (e *ExportJobImpl) runHooks(ctx context.Context) {
+	if len(e.hooks) == 0 {
+		return
+	}
+	objectURL, _ := e.JobMetadata["object_url"].(string)
+	event := ExportEvent{
+		JobID:       e.ID(),
+		DataSource:  e.dataSource,
+		Query:       e.query,
+		Format:      string(e.format),
+		Compression: string(e.compression),
+		Output:      e.outputFile,
+		ObjectURL:   objectURL,
+		Rows:        e.rowsExported,
+		Bytes:       e.bytesWritten,
+		CompletedAt: time.Now(),
+	}
+	if err := RunExportHooks(ctx, e.hooks, event); err != nil {
+		log.Logger.Warnf("Export job %s hooks failed: %v", e.ID(), err)
+		e.JobMetadata["hook_error"] = err.Error()
+		return
+	}
+	e.JobMetadata["hooks_run"] = len(e.hooks)
+}
+
 // CanPause returns whether this job can be paused
 func (e *ExportJobImpl) CanPause() bool {
 	return true
@@ -164,25 +282,58 @@ func (e *ExportJobImpl) Validate() error {
 
 	// Validate format
 	validFormats := map[OutputFormat]bool{
-		OutputFormatCSV:  true,
-		OutputFormatJSON: true,
-		OutputFormatTSV:  true,
+		OutputFormatCSV:    true,
+		OutputFormatJSON:   true,
+		OutputFormatTSV:    true,
+		OutputFormatSQLite: true,
+		OutputFormatXLSX:   true,
 	}
 
 	if !validFormats[e.format] {
 		return fmt.Errorf("unsupported format: %s", e.format)
 	}
+	if err := CheckCompression(e.format, e.compression); err != nil {
+		return err
+	}
+	if objectstore.IsURL(e.outputFile) {
+		if e.format == OutputFormatSQLite {
+			return fmt.Errorf("sqlite exports cannot be uploaded, export to a local .db file")
+		}
+		if _, _, err := objectstore.ParseURL(e.outputFile); err != nil {
+			return err
+		}
+	}
+
+	for _, hook := range e.hooks {
+		if err := hook.Validate(); err != nil {
+			return err
+		}
+	}
+	if e.redaction != nil {
+		if err := e.redaction.Validate(); err != nil {
+			return err
+		}
+	}
 
-	// Check if data source exists
-	if _, exists := e.engine.dataSources[e.dataSource]; !exists {
-		return fmt.Errorf("unknown data source: %s", e.dataSource)
+	// Check if data source exists; a query runner brings its own
+	if e.run == nil && e.engine == nil {
+		return fmt.Errorf("export job has no query engine")
+	}
+	if e.run == nil {
+		if _, exists := e.engine.dataSources[e.dataSource]; !exists {
+			return fmt.Errorf("unknown data source: %s", e.dataSource)
+		}
 	}
 
 	return nil
 }
 
-// ensureOutputDirectory creates the output directory if it doesn't exist
+// ensureOutputDirectory creates the output directory if it doesn't exist;
+// uploads need none
 func (e *ExportJobImpl) ensureOutputDirectory() error {
+	if objectstore.IsURL(e.outputFile) {
+		return nil
+	}
 	dir := filepath.Dir(e.outputFile)
 	if dir != "." {
 		return os.MkdirAll(dir, 0755)
@@ -192,14 +343,13 @@ func (e *ExportJobImpl) ensureOutputDirectory() error {
 
 // exportToCSV exports query results to CSV format
 func (e *ExportJobImpl) exportToCSV(ctx context.Context, result QueryResult, progressCallback jobs.ProgressCallback) error {
-	file, err := os.Create(e.outputFile)
+	file, err := CreateCompressed(e.outputFile, e.compression)
 	if err != nil {
 		return fmt.Errorf("failed to create CSV file: %w", err)
 	}
-	defer file.Close()
+	defer file.Abort()
 
 	writer := csv.NewWriter(file)
-	defer writer.Flush()
 
 	// Write headers
 	if err := writer.Write(result.Columns); err != nil {
@@ -222,9 +372,7 @@ func (e *ExportJobImpl) exportToCSV(ctx context.Context, result QueryResult, pro
 		// Convert row to strings
 		stringRow := make([]string, len(row))
 		for j, cell := range row {
-			if cell != nil {
-				stringRow[j] = fmt.Sprintf("%v", cell)
-			}
+			stringRow[j] = FieldString(cell)
 		}
 
 		if err := writer.Write(stringRow); err != nil {
@@ -239,21 +387,20 @@ func (e *ExportJobImpl) exportToCSV(ctx context.Context, result QueryResult, pro
 		}
 	}
 
-	// Get file size
-	if stat, err := file.Stat(); err == nil {
-		e.bytesWritten = stat.Size()
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
 	}
-
-	return nil
+	return e.closeOutput(file)
 }
 
 // exportToJSON exports query results to JSON format
 func (e *ExportJobImpl) exportToJSON(ctx context.Context, result QueryResult, progressCallback jobs.ProgressCallback) error {
-	file, err := os.Create(e.outputFile)
+	file, err := CreateCompressed(e.outputFile, e.compression)
 	if err != nil {
 		return fmt.Errorf("failed to create JSON file: %w", err)
 	}
-	defer file.Close()
+	defer file.Abort()
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
@@ -307,25 +454,19 @@ func (e *ExportJobImpl) exportToJSON(ctx context.Context, result QueryResult, pr
 		return fmt.Errorf("failed to encode JSON: %w", err)
 	}
 
-	// Get file size
-	if stat, err := file.Stat(); err == nil {
-		e.bytesWritten = stat.Size()
-	}
-
-	return nil
+	return e.closeOutput(file)
 }
 
 // exportToTSV exports query results to TSV format
 func (e *ExportJobImpl) exportToTSV(ctx context.Context, result QueryResult, progressCallback jobs.ProgressCallback) error {
-	file, err := os.Create(e.outputFile)
+	file, err := CreateCompressed(e.outputFile, e.compression)
 	if err != nil {
 		return fmt.Errorf("failed to create TSV file: %w", err)
 	}
-	defer file.Close()
+	defer file.Abort()
 
 	writer := csv.NewWriter(file)
 	writer.Comma = '\t' // Use tab as separator
-	defer writer.Flush()
 
 	// Write headers
 	if err := writer.Write(result.Columns); err != nil {
@@ -348,9 +489,7 @@ func (e *ExportJobImpl) exportToTSV(ctx context.Context, result QueryResult, pro
 		// Convert row to strings
 		stringRow := make([]string, len(row))
 		for j, cell := range row {
-			if cell != nil {
-				stringRow[j] = fmt.Sprintf("%v", cell)
-			}
+			stringRow[j] = FieldString(cell)
 		}
 
 		if err := writer.Write(stringRow); err != nil {
@@ -365,11 +504,24 @@ func (e *ExportJobImpl) exportToTSV(ctx context.Context, result QueryResult, pro
 		}
 	}
 
-	// Get file size
-	if stat, err := file.Stat(); err == nil {
-		e.bytesWritten = stat.Size()
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write TSV: %w", err)
 	}
+	return e.closeOutput(file)
+}
 
+// closeOutput closes a written export file, recording its size, the
+// savings of compression and the URL of an uploaded object
+func (e *ExportJobImpl) closeOutput(file *CompressedFile) error {
+	if err := file.Close(); err != nil {
+		return err
+	}
+	e.bytesWritten = file.Size()
+	e.savings = file.Savings()
+	if objectstore.IsURL(e.outputFile) {
+		e.JobMetadata["object_url"] = file.Location()
+	}
 	return nil
 }
 
@@ -385,3 +537,34 @@ func (e *ExportJobImpl) updateProgress(current int64, message string, callback j
 		callback(e.BaseJob.JobProgress)
 	}
 }
+
+// exportToSQLite exports query results to a new SQLite database, written in
+// one transaction
+func (e *ExportJobImpl) exportToSQLite(result QueryResult) error {
+	if err := WriteSQLite(e.outputFile, result.Columns, result.Rows); err != nil {
+		return err
+	}
+	e.rowsExported = int64(len(result.Rows))
+
+	// Get file size
+	if stat, err := os.Stat(e.outputFile); err == nil {
+		e.bytesWritten = stat.Size()
+	}
+	return nil
+}
+
+// exportToXLSX exports query results to an Excel workbook with one sheet
+func (e *ExportJobImpl) exportToXLSX(result QueryResult) error {
+	file, err := CreateCompressed(e.outputFile, CompressionNone)
+	if err != nil {
+		return fmt.Errorf("failed to create workbook: %w", err)
+	}
+	defer file.Abort()
+
+	sheet := Sheet{Name: DefaultSheetName, Columns: result.Columns, Rows: result.Rows}
+	if err := writeWorkbook(zip.NewWriter(file), []Sheet{sheet}); err != nil {
+		return err
+	}
+	e.rowsExported = int64(len(result.Rows))
+	return e.closeOutput(file)
+}
@@ -0,0 +1,329 @@
+package query
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ColumnKind is the kind of values a result column holds, inferred from
+// its values because SQLite columns are not typed
+type ColumnKind int
+
+const (
+	ColumnText ColumnKind = iota
+	ColumnNumeric
+	ColumnBool
+)
+
+// DefaultCellWidth is the width text cells are cut to in tables
+const DefaultCellWidth = 60
+
+// cellEscaper makes control characters in text visible so they cannot
+// break table rows
+var cellEscaper = strings.NewReplacer("\r\n", `\n`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// InferColumnKinds returns the kind of each column: numeric when every
+// non-NULL value is a number, boolean when every one is a boolean, and text
+// otherwise or when the column has only NULLs
+func InferColumnKinds(columns []string, rows [][]interface{}) []ColumnKind {
+	kinds := make([]ColumnKind, len(columns))
+	for j := range columns {
+		kind, seen := ColumnText, false
+		for _, row := range rows {
+			if j >= len(row) || row[j] == nil {
+				continue
+			}
+			var valueKind ColumnKind
+			switch row[j].(type) {
+			case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+				valueKind = ColumnNumeric
+			case bool:
+				valueKind = ColumnBool
+			default:
+				valueKind = ColumnText
+			}
+			if seen && valueKind != kind {
+				kind = ColumnText
+				break
+			}
+			kind, seen = valueKind, true
+		}
+		kinds[j] = kind
+	}
+	return kinds
+}
+
+// FieldString returns a value as a field of delimited output: empty for
+// NULL, true or false for booleans, and decimals without exponents
+func FieldString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	}
+	return fmt.Sprint(value)
+}
+
+// DisplayString returns a value as a table cell: NULL for NULL, text with
+// newlines and tabs escaped and cut to maxWidth characters with an
+// ellipsis. A maxWidth of 0 keeps the whole text.
+func DisplayString(value interface{}, maxWidth int) string {
+	if value == nil {
+		return "NULL"
+	}
+	text := cellEscaper.Replace(FieldString(value))
+	if maxWidth > 0 && utf8.RuneCountInString(text) > maxWidth {
+		runes := []rune(text)
+		text = string(runes[:max(maxWidth-1, 0)]) + "…"
+	}
+	return text
+}
+
+// WriteTable writes columns and rows as an aligned table with numeric
+// columns right-aligned. kinds are inferred from rows when nil, which lets
+// callers infer them before formatting numbers as text; text cells are cut
+// to maxWidth characters.
+func WriteTable(w io.Writer, columns []string, rows [][]interface{}, kinds []ColumnKind, maxWidth int) error {
+	return WriteTableWidth(w, columns, rows, kinds, maxWidth, 0)
+}
+
+// minTableColumnWidth is the narrowest a column is cut to when a table is
+// fitted to the terminal
+const minTableColumnWidth = 4
+
+// WriteTableWidth writes a table like WriteTable, narrowing the widest
+// columns so lines fit lineWidth characters, such as the terminal width;
+// 0 leaves lines as wide as their cells
+func WriteTableWidth(w io.Writer, columns []string, rows [][]interface{}, kinds []ColumnKind, maxWidth, lineWidth int) error {
+	if kinds == nil {
+		kinds = InferColumnKinds(columns, rows)
+	}
+
+	cells := make([][]string, len(rows))
+	widths := make([]int, len(columns))
+	for j, column := range columns {
+		widths[j] = utf8.RuneCountInString(column)
+	}
+	for i, row := range rows {
+		cells[i] = make([]string, len(columns))
+		for j := range columns {
+			var value interface{}
+			if j < len(row) {
+				value = row[j]
+			}
+			cells[i][j] = DisplayString(value, maxWidth)
+			widths[j] = max(widths[j], utf8.RuneCountInString(cells[i][j]))
+		}
+	}
+
+	headers := columns
+	if lineWidth > 0 && fitWidths(widths, lineWidth) {
+		headers = make([]string, len(columns))
+		for j, column := range columns {
+			headers[j] = cutString(column, widths[j])
+		}
+		for _, row := range cells {
+			for j := range row {
+				row[j] = cutString(row[j], widths[j])
+			}
+		}
+	}
+
+	separators := make([]string, len(columns))
+	for j := range columns {
+		separators[j] = strings.Repeat("-", widths[j])
+	}
+
+	writeLine := func(values []string) error {
+		var b strings.Builder
+		for j, value := range values {
+			if j > 0 {
+				b.WriteString("  ")
+			}
+			padding := strings.Repeat(" ", widths[j]-utf8.RuneCountInString(value))
+			if j < len(kinds) && kinds[j] == ColumnNumeric {
+				b.WriteString(padding + value)
+			} else {
+				b.WriteString(value + padding)
+			}
+		}
+		_, err := fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+		return err
+	}
+
+	if err := writeLine(headers); err != nil {
+		return err
+	}
+	if err := writeLine(separators); err != nil {
+		return err
+	}
+	for _, row := range cells {
+		if err := writeLine(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fitWidths narrows the widest columns until a line with two spaces between
+// columns fits lineWidth, or every column is at the minimum width. It
+// reports whether any column was narrowed.
+func fitWidths(widths []int, lineWidth int) bool {
+	total := 2 * (len(widths) - 1)
+	for _, width := range widths {
+		total += width
+	}
+
+	narrowed := false
+	for total > lineWidth {
+		widest := 0
+		for j, width := range widths {
+			if width > widths[widest] {
+				widest = j
+			}
+		}
+		if widths[widest] <= minTableColumnWidth {
+			break
+		}
+		widths[widest]--
+		total--
+		narrowed = true
+	}
+	return narrowed
+}
+
+// cutString cuts text to width characters with an ellipsis
+func cutString(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:max(width-1, 0)]) + "…"
+}
+
+// WriteDelimited writes columns and rows as delimited text with a header
+// line, suitable for unix pipelines and spreadsheets. NULL becomes an empty
+// field and booleans true or false.
+func WriteDelimited(w io.Writer, columns []string, rows [][]interface{}, delimiter rune) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = delimiter
+
+	if err := writer.Write(columns); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	record := make([]string, len(columns))
+	for i, row := range rows {
+		for j := range record {
+			record[j] = ""
+			if j < len(row) {
+				record[j] = FieldString(row[j])
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", i, err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON writes rows as an indented JSON array of objects keyed by
+// column name
+func WriteJSON(w io.Writer, columns []string, rows [][]interface{}) error {
+	records := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		record := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if i < len(row) {
+				record[col] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode results: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// SaveOptions control how SaveResult writes a file
+type SaveOptions struct {
+	Format      OutputFormat
+	Sheet       string      // Worksheet name of an xlsx workbook; DefaultSheetName when empty
+	Compression Compression // Compresses CSV, TSV and JSON files while they are written
+}
+
+// SaveResult writes columns and rows to a file as CSV, TSV, JSON, an Excel
+// workbook or a new SQLite database; the table format is saved as
+// tab-separated text. It returns the savings of compression, empty for an
+// uncompressed file. path is used as given, see CompressedPath.
+func SaveResult(path string, opts SaveOptions, columns []string, rows [][]interface{}) (string, error) {
+	if err := CheckCompression(opts.Format, opts.Compression); err != nil {
+		return "", err
+	}
+	switch opts.Format {
+	case OutputFormatSQLite:
+		return "", WriteSQLite(path, columns, rows)
+	case OutputFormatXLSX:
+		sheet := opts.Sheet
+		if sheet == "" {
+			sheet = DefaultSheetName
+		}
+		return "", WriteXLSX(path, []Sheet{{Name: sheet, Columns: columns, Rows: rows}})
+	}
+
+	file, err := CreateCompressed(path, opts.Compression)
+	if err != nil {
+		return "", err
+	}
+	switch opts.Format {
+	case OutputFormatJSON:
+		err = WriteJSON(file, columns, rows)
+	case OutputFormatCSV:
+		err = WriteDelimited(file, columns, rows, ',')
+	default:
+		err = WriteDelimited(file, columns, rows, '\t')
+	}
+	if err != nil {
+		file.Abort()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	return file.Savings(), nil
+}
+
+// FormatDelimited returns columns and rows as delimited text
+func FormatDelimited(columns []string, rows [][]interface{}, delimiter rune) (string, error) {
+	var builder strings.Builder
+	if err := WriteDelimited(&builder, columns, rows, delimiter); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// Delimiter returns the field delimiter for a delimited output format,
+// defaulting to tabs
+func Delimiter(format string) rune {
+	if strings.EqualFold(format, string(OutputFormatCSV)) {
+		return ','
+	}
+	return '\t'
+}
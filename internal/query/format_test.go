@@ -0,0 +1,89 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatDelimited(t *testing.T) {
+	columns := []string{"id", "title", "url"}
+	rows := [][]interface{}{
+		{int64(1), "Show HN: a, b", nil},
+		{int64(2), []byte("plain"), "https://example.com"},
+	}
+
+	text, err := FormatDelimited(columns, rows, Delimiter("tsv"))
+	if err != nil {
+		t.Fatalf("FormatDelimited failed: %v", err)
+	}
+	expected := "id\ttitle\turl\n1\tShow HN: a, b\t\n2\tplain\thttps://example.com\n"
+	if text != expected {
+		t.Errorf("Unexpected TSV output: %q", text)
+	}
+
+	text, err = FormatDelimited(columns, rows, Delimiter("CSV"))
+	if err != nil {
+		t.Fatalf("FormatDelimited failed: %v", err)
+	}
+	expected = "id,title,url\n1,\"Show HN: a, b\",\n2,plain,https://example.com\n"
+	if text != expected {
+		t.Errorf("Unexpected CSV output: %q", text)
+	}
+}
+
+func TestInferColumnKinds(t *testing.T) {
+	columns := []string{"score", "ratio", "dead", "title", "mixed", "empty"}
+	rows := [][]interface{}{
+		{int64(10), 1.5, true, "a", int64(1), nil},
+		{nil, int64(2), false, "b", "x", nil},
+	}
+
+	got := InferColumnKinds(columns, rows)
+	want := []ColumnKind{ColumnNumeric, ColumnNumeric, ColumnBool, ColumnText, ColumnText, ColumnText}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InferColumnKinds() = %v, want %v", got, want)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	columns := []string{"id", "title", "dead", "score"}
+	rows := [][]interface{}{
+		{int64(1), "Line one\nline two", false, 1.5},
+		{int64(1234), nil, true, 1e6},
+	}
+
+	var b strings.Builder
+	if err := WriteTable(&b, columns, rows, nil, 0); err != nil {
+		t.Fatalf("WriteTable failed: %v", err)
+	}
+	want := "" +
+		"  id  title               dead     score\n" +
+		"----  ------------------  -----  -------\n" +
+		"   1  Line one\\nline two  false      1.5\n" +
+		"1234  NULL                true   1000000\n"
+	if b.String() != want {
+		t.Errorf("WriteTable() =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	// Fitted to 30 characters, the widest column is cut first
+	b.Reset()
+	if err := WriteTableWidth(&b, columns, rows, nil, 0, 30); err != nil {
+		t.Fatalf("WriteTableWidth failed: %v", err)
+	}
+	want = "" +
+		"  id  title     dead     score\n" +
+		"----  --------  -----  -------\n" +
+		"   1  Line on…  false      1.5\n" +
+		"1234  NULL      true   1000000\n"
+	if b.String() != want {
+		t.Errorf("WriteTableWidth() =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	if got := DisplayString("a long title", 6); got != "a lon…" {
+		t.Errorf("DisplayString() = %q, want the text cut with an ellipsis", got)
+	}
+	if got := FieldString(true); got != "true" {
+		t.Errorf("FieldString(true) = %q", got)
+	}
+}
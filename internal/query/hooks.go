@@ -0,0 +1,157 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/httpclient"
+)
+
+// hookTimeout bounds how long one hook of an export job may run
+const hookTimeout = 5 * time.Minute
+
+// ExportHook runs when an export job has written its output, handing the
+// file on to a downstream pipeline. A hook has a command, a webhook or both.
+type ExportHook struct {
+	Command string // Run with the output path as its last argument, e.g. "scripts/load.sh --table items"
+	Webhook string // http(s) URL the ExportEvent is posted to as JSON
+}
+
+var (
+	hooksMu      sync.RWMutex
+	defaultHooks []ExportHook
+)
+
+// SetExportHooks sets the hooks every export job runs, before its own
+func SetExportHooks(hooks []ExportHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	defaultHooks = append([]ExportHook(nil), hooks...)
+}
+
+// ExportHooks returns the hooks every export job runs
+func ExportHooks() []ExportHook {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	return append([]ExportHook(nil), defaultHooks...)
+}
+
+// Validate checks that the hook does something and its webhook is a URL
+func (h ExportHook) Validate() error {
+	if strings.TrimSpace(h.Command) == "" && h.Webhook == "" {
+		return fmt.Errorf("export hook needs a command or a webhook")
+	}
+	if h.Webhook != "" && !strings.HasPrefix(h.Webhook, "http://") && !strings.HasPrefix(h.Webhook, "https://") {
+		return fmt.Errorf("export hook webhook must be an http or https URL: %s", h.Webhook)
+	}
+	return nil
+}
+
+// ExportEvent describes a completed export job to its hooks
+type ExportEvent struct {
+	JobID       string    `json:"job_id"`
+	DataSource  string    `json:"data_source"`
+	Query       string    `json:"query"`
+	Format      string    `json:"format"`
+	Compression string    `json:"compression,omitempty"`
+	Output      string    `json:"output"`               // The file path or s3:// URL written
+	ObjectURL   string    `json:"object_url,omitempty"` // HTTP URL of an uploaded object
+	Rows        int64     `json:"rows"`
+	Bytes       int64     `json:"bytes"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// RunExportHooks runs each hook for a completed export in order. A failing
+// hook does not stop the others; their errors are returned together.
+func RunExportHooks(ctx context.Context, hooks []ExportHook, event ExportEvent) error {
+	var client *http.Client
+	var errs []error
+	for i, hook := range hooks {
+		if err := hook.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("hook %d: %w", i+1, err))
+			continue
+		}
+		if hook.Command != "" {
+			if err := runHookCommand(ctx, hook.Command, event); err != nil {
+				errs = append(errs, fmt.Errorf("hook %d: %w", i+1, err))
+			}
+		}
+		if hook.Webhook != "" {
+			if client == nil {
+				clientConfig := httpclient.Defaults()
+				clientConfig.CacheEnabled = false
+				var err error
+				if client, err = httpclient.New(clientConfig); err != nil {
+					return errors.Join(append(errs, fmt.Errorf("failed to create HTTP client: %w", err))...)
+				}
+			}
+			if err := postHookWebhook(ctx, client, hook.Webhook, event); err != nil {
+				errs = append(errs, fmt.Errorf("hook %d: %w", i+1, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runHookCommand runs command with the output path appended. The rest of
+// the event is passed in PUBDATAHUB_EXPORT_* environment variables.
+func runHookCommand(ctx context.Context, command string, event ExportEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+
+	args := strings.Fields(command)
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], event.Output)...)
+	cmd.Env = append(os.Environ(),
+		"PUBDATAHUB_EXPORT_JOB_ID="+event.JobID,
+		"PUBDATAHUB_EXPORT_DATA_SOURCE="+event.DataSource,
+		"PUBDATAHUB_EXPORT_FORMAT="+event.Format,
+		"PUBDATAHUB_EXPORT_OUTPUT="+event.Output,
+		"PUBDATAHUB_EXPORT_OBJECT_URL="+event.ObjectURL,
+		"PUBDATAHUB_EXPORT_ROWS="+strconv.FormatInt(event.Rows, 10),
+		"PUBDATAHUB_EXPORT_BYTES="+strconv.FormatInt(event.Bytes, 10),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if text := strings.TrimSpace(string(output)); text != "" {
+			return fmt.Errorf("command %s failed: %w: %s", args[0], err, truncateRunes(text, 500))
+		}
+		return fmt.Errorf("command %s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// postHookWebhook posts the event to a webhook as JSON
+func postHookWebhook(ctx context.Context, client *http.Client, webhook string, event ExportEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post export to webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
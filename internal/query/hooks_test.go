@@ -0,0 +1,102 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExportHookValidate(t *testing.T) {
+	tests := []struct {
+		hook  ExportHook
+		valid bool
+	}{
+		{ExportHook{Command: "load.sh"}, true},
+		{ExportHook{Webhook: "https://example.com/hook"}, true},
+		{ExportHook{Command: "load.sh", Webhook: "http://localhost/hook"}, true},
+		{ExportHook{}, false},
+		{ExportHook{Command: "  "}, false},
+		{ExportHook{Webhook: "ftp://example.com"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.hook.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%+v) error = %v, want valid %v", tt.hook, err, tt.valid)
+		}
+	}
+}
+
+func TestRunExportHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook script needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	record := filepath.Join(dir, "record.txt")
+	script := filepath.Join(dir, "hook.sh")
+	body := "#!/bin/sh\necho \"$1 $2 $3 $PUBDATAHUB_EXPORT_JOB_ID $PUBDATAHUB_EXPORT_ROWS\" > " + record + "\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var posted ExportEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+			t.Errorf("failed to decode webhook body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	event := ExportEvent{JobID: "export_1", DataSource: "hackernews", Format: "csv", Output: "/tmp/out.csv", Rows: 42}
+	hooks := []ExportHook{{Command: script + " --table items", Webhook: server.URL}}
+	if err := RunExportHooks(context.Background(), hooks, event); err != nil {
+		t.Fatalf("RunExportHooks failed: %v", err)
+	}
+
+	data, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatalf("hook command did not run: %v", err)
+	}
+	if got, want := strings.TrimSpace(string(data)), "--table items /tmp/out.csv export_1 42"; got != want {
+		t.Errorf("hook command saw %q, want %q", got, want)
+	}
+	if posted.JobID != "export_1" || posted.Output != "/tmp/out.csv" || posted.Rows != 42 {
+		t.Errorf("webhook received %+v", posted)
+	}
+}
+
+func TestRunExportHooksFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	calls := 0
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer ok.Close()
+
+	hooks := []ExportHook{
+		{Command: "pubdatahub-no-such-command"},
+		{Webhook: server.URL},
+		{Webhook: ok.URL},
+	}
+	err := RunExportHooks(context.Background(), hooks, ExportEvent{Output: "/tmp/out.csv"})
+	if err == nil {
+		t.Fatal("expected hook errors")
+	}
+	if !strings.Contains(err.Error(), "hook 1:") || !strings.Contains(err.Error(), "hook 2: webhook returned 500") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("later hooks should still run, webhook called %d times", calls)
+	}
+}
@@ -0,0 +1,378 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+// maxSuggestions is how many close names a diagnostic suggests at most
+const maxSuggestions = 3
+
+// sqlFunctions are the functions suggested for misspelled function names:
+// SQLite's common built-ins, FTS helpers and PubDataHub's own functions
+var sqlFunctions = []string{
+	"abs", "avg", "bm25", "char", "coalesce", "count", "date", "datetime",
+	"epoch_to_date", "group_concat", "hex", "highlight", "ifnull", "iif",
+	"instr", "json_extract", "julianday", "length", "lower", "ltrim", "max",
+	"min", "nullif", "percentile", "printf", "quote", "random", "replace",
+	"round", "rtrim", "snippet", "strftime", "substr", "sum", "text_tokens",
+	"time", "total", "trim", "typeof", "unicode", "unixepoch", "upper",
+	"url_canonical", "url_domain",
+}
+
+// sqlKeywords are the keywords suggested for misspelled tokens near a
+// syntax error
+var sqlKeywords = []string{
+	"AND", "AS", "ASC", "BETWEEN", "BY", "CASE", "DESC", "DISTINCT", "ELSE",
+	"END", "EXISTS", "FROM", "GROUP", "HAVING", "IN", "INNER", "IS", "JOIN",
+	"LEFT", "LIKE", "LIMIT", "NOT", "NULL", "OFFSET", "ON", "OR", "ORDER",
+	"SELECT", "THEN", "UNION", "WHEN", "WHERE", "WITH",
+}
+
+var (
+	noSuchColumnPattern   = regexp.MustCompile(`no such column: (\S+)`)
+	noSuchTablePattern    = regexp.MustCompile(`no such table: (\S+)`)
+	noSuchFunctionPattern = regexp.MustCompile(`no such function: (\S+)`)
+	ambiguousPattern      = regexp.MustCompile(`ambiguous column name: (\S+)`)
+	nearPattern           = regexp.MustCompile(`near "(.+?)": syntax error`)
+	unrecognizedPattern   = regexp.MustCompile(`unrecognized token: "(.+?)"`)
+)
+
+// QueryDiagnostic is a failed query with diagnostics: the offending token,
+// where it is in the statement and hints such as close column or table names
+type QueryDiagnostic struct {
+	Query  string
+	Err    error
+	Token  string   // Offending token, empty when SQLite names none
+	Offset int      // Byte offset of Token in Query, -1 when not found
+	Hints  []string // Suggestions shown below the statement
+}
+
+// Error returns the SQLite error, the statement line with the offending
+// token marked and the hints
+func (e *QueryDiagnostic) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Err.Error())
+	if e.Offset >= 0 {
+		line, column := statementLine(e.Query, e.Offset)
+		fmt.Fprintf(&b, "\n  %s\n  %s%s", line, column, strings.Repeat("^", max(1, len([]rune(e.Token)))))
+	}
+	for _, hint := range e.Hints {
+		b.WriteString("\n  hint: " + hint)
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying SQLite error
+func (e *QueryDiagnostic) Unwrap() error {
+	return e.Err
+}
+
+// statementLine returns the line of query containing offset and the
+// indentation that puts a marker under offset
+func statementLine(query string, offset int) (string, string) {
+	start := strings.LastIndex(query[:offset], "\n") + 1
+	end := len(query)
+	if i := strings.Index(query[offset:], "\n"); i >= 0 {
+		end = offset + i
+	}
+	indent := []rune(query[start:offset])
+	for i, r := range indent {
+		if r != '\t' {
+			indent[i] = ' '
+		}
+	}
+	return query[start:end], string(indent)
+}
+
+// DiagnoseQueryError explains why query failed with err. It points at the
+// token SQLite rejected, suggests close column, table, function or keyword
+// names from schema and hints at missing quotes. Errors it cannot explain
+// are returned unchanged.
+func DiagnoseQueryError(query string, err error, schema datasource.Schema) error {
+	var diagnosed *QueryDiagnostic
+	if err == nil || errors.As(err, &diagnosed) {
+		return err
+	}
+
+	diagnostic := &QueryDiagnostic{Query: query, Err: err, Offset: -1}
+	message := err.Error()
+	switch {
+	case noSuchColumnPattern.MatchString(message):
+		diagnostic.Token = noSuchColumnPattern.FindStringSubmatch(message)[1]
+		name := diagnostic.Token
+		if dot := strings.LastIndex(name, "."); dot >= 0 {
+			name = name[dot+1:]
+		}
+		diagnostic.suggest(name, schemaColumns(schema))
+		if len(diagnostic.Hints) == 0 && comparedValue(query, diagnostic.Token) {
+			diagnostic.Hints = append(diagnostic.Hints, fmt.Sprintf("if %s is a text value, quote it: '%s'", diagnostic.Token, diagnostic.Token))
+		}
+	case noSuchTablePattern.MatchString(message):
+		diagnostic.Token = noSuchTablePattern.FindStringSubmatch(message)[1]
+		name := strings.TrimPrefix(diagnostic.Token, "main.")
+		tables := make([]string, len(schema.Tables))
+		for i, table := range schema.Tables {
+			tables[i] = table.Name
+		}
+		diagnostic.suggest(name, tables)
+	case noSuchFunctionPattern.MatchString(message):
+		diagnostic.Token = noSuchFunctionPattern.FindStringSubmatch(message)[1]
+		diagnostic.suggest(diagnostic.Token, sqlFunctions)
+	case ambiguousPattern.MatchString(message):
+		diagnostic.Token = ambiguousPattern.FindStringSubmatch(message)[1]
+		var qualified []string
+		for _, table := range schema.Tables {
+			for _, column := range table.Columns {
+				if strings.EqualFold(column.Name, diagnostic.Token) {
+					qualified = append(qualified, table.Name+"."+column.Name)
+				}
+			}
+		}
+		hint := "qualify the column with its table name or alias"
+		if len(qualified) > 0 {
+			hint += ", e.g. " + strings.Join(qualified, " or ")
+		}
+		diagnostic.Hints = append(diagnostic.Hints, hint)
+	case nearPattern.MatchString(message):
+		diagnostic.Token = nearPattern.FindStringSubmatch(message)[1]
+		if len(diagnostic.Token) >= 3 && !isKeyword(diagnostic.Token) {
+			diagnostic.suggest(strings.ToUpper(diagnostic.Token), sqlKeywords)
+		}
+	case unrecognizedPattern.MatchString(message):
+		diagnostic.Token = unrecognizedPattern.FindStringSubmatch(message)[1]
+	}
+
+	if diagnostic.Token != "" {
+		diagnostic.Offset = tokenOffset(query, diagnostic.Token)
+		if diagnostic.Offset >= 0 && nearPattern.MatchString(message) {
+			diagnostic.hintPrecedingWord(query, schemaColumns(schema))
+		}
+	}
+	if _, quote := stringLiterals(query); quote >= 0 {
+		diagnostic.Token = query[quote : quote+1]
+		diagnostic.Offset = quote
+		diagnostic.Hints = append(diagnostic.Hints, "the string starting here is missing its closing quote")
+	}
+	if depth := parenthesisDepth(query); depth > 0 {
+		diagnostic.Hints = append(diagnostic.Hints, fmt.Sprintf("%d opening parenthesis not closed", depth))
+	}
+
+	if diagnostic.Offset < 0 && len(diagnostic.Hints) == 0 {
+		return err
+	}
+	return diagnostic
+}
+
+// hintPrecedingWord explains a syntax error by the word before the token
+// SQLite rejected: a trailing comma before a keyword, or a misspelled keyword
+// that SQLite took for an alias, as in "SELECT id FORM items". Short words
+// and column names are not taken for keywords.
+func (e *QueryDiagnostic) hintPrecedingWord(query string, columns []string) {
+	before := strings.TrimRightFunc(query[:e.Offset], unicode.IsSpace)
+	if strings.HasSuffix(before, ",") {
+		if isKeyword(e.Token) {
+			e.Hints = append(e.Hints, "remove the comma before "+strings.ToUpper(e.Token))
+		}
+		return
+	}
+
+	start := len(before)
+	for start > 0 && isWordByte(before[start-1]) {
+		start--
+	}
+	word := before[start:]
+	if len(word) < 3 || isKeyword(word) {
+		return
+	}
+	for _, column := range columns {
+		if strings.EqualFold(column, word) {
+			return
+		}
+	}
+	if matches := closeMatches(strings.ToUpper(word), sqlKeywords); len(matches) > 0 {
+		e.Hints = append(e.Hints, fmt.Sprintf("did you mean %s instead of %s?", strings.Join(matches, ", "), word))
+	}
+}
+
+// suggest adds a hint naming the candidates closest to name
+func (e *QueryDiagnostic) suggest(name string, candidates []string) {
+	matches := closeMatches(name, candidates)
+	if len(matches) > 0 {
+		e.Hints = append(e.Hints, "did you mean "+strings.Join(matches, ", ")+"?")
+	}
+}
+
+// schemaColumns returns the distinct column names of all tables in schema
+func schemaColumns(schema datasource.Schema) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, table := range schema.Tables {
+		for _, column := range table.Columns {
+			if !seen[column.Name] {
+				seen[column.Name] = true
+				columns = append(columns, column.Name)
+			}
+		}
+	}
+	return columns
+}
+
+// closeMatches returns up to maxSuggestions candidates within a few edits of
+// name, closest first. The allowed distance grows with the length of name.
+func closeMatches(name string, candidates []string) []string {
+	limit := max(1, len([]rune(name))/3)
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	for _, candidate := range candidates {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if distance > 0 && distance <= limit {
+			matches = append(matches, match{candidate, distance})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	names := make([]string, 0, maxSuggestions)
+	for i := 0; i < len(matches) && i < maxSuggestions; i++ {
+		names = append(names, matches[i].name)
+	}
+	return names
+}
+
+// editDistance is the Levenshtein distance between a and b, counting a swap
+// of adjacent characters as one edit
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(min(d[i-1][j]+1, d[i][j-1]+1), d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+// tokenOffset returns the offset of the first occurrence of token in query
+// outside string literals, matching whole words case-insensitively, or -1
+func tokenOffset(query, token string) int {
+	lowerQuery, lowerToken := strings.ToLower(query), strings.ToLower(token)
+	literals, _ := stringLiterals(query)
+	for from := 0; from < len(query); {
+		i := strings.Index(lowerQuery[from:], lowerToken)
+		if i < 0 {
+			return -1
+		}
+		start, end := from+i, from+i+len(token)
+		if !insideRange(start, literals) && (!isWordByte(token[0]) || start == 0 || !isWordByte(query[start-1])) &&
+			(!isWordByte(token[len(token)-1]) || end == len(query) || !isWordByte(query[end])) {
+			return start
+		}
+		from = start + 1
+	}
+	return -1
+}
+
+// stringLiterals returns the start and end offsets of the single-quoted
+// strings in query and the offset of the opening quote of an unterminated
+// string, or -1. An unterminated string runs to the end.
+func stringLiterals(query string) ([][2]int, int) {
+	var literals [][2]int
+	start := -1
+	for i := 0; i < len(query); i++ {
+		if query[i] != '\'' {
+			continue
+		}
+		switch {
+		case start < 0:
+			start = i
+		case i+1 < len(query) && query[i+1] == '\'':
+			i++ // Escaped quote
+		default:
+			literals = append(literals, [2]int{start, i + 1})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		literals = append(literals, [2]int{start, len(query)})
+	}
+	return literals, start
+}
+
+// insideRange reports whether offset falls in one of ranges
+func insideRange(offset int, ranges [][2]int) bool {
+	for _, r := range ranges {
+		if offset >= r[0] && offset < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// comparedValue reports whether name is compared with an operator, as in
+// "by = pg", which suggests a text value without quotes
+func comparedValue(query, name string) bool {
+	pattern := `(?i)(=|<>|<|>|\blike|\bin\s*\()\s*` + regexp.QuoteMeta(name) + `\b`
+	return regexp.MustCompile(pattern).MatchString(query)
+}
+
+// parenthesisDepth returns how many opening parentheses outside string
+// literals are not closed
+func parenthesisDepth(query string) int {
+	literals, _ := stringLiterals(query)
+	depth := 0
+	for i := 0; i < len(query); i++ {
+		if insideRange(i, literals) {
+			continue
+		}
+		switch query[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+	return depth
+}
+
+// isKeyword reports whether token is one of sqlKeywords
+func isKeyword(token string) bool {
+	for _, keyword := range sqlKeywords {
+		if strings.EqualFold(keyword, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// isWordByte reports whether c can be part of an identifier
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,127 @@
+package query
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+func TestDiagnoseQueryError(t *testing.T) {
+	db, err := sql.Open(storage.DriverName, ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER, title TEXT, score INTEGER, by TEXT); CREATE TABLE users (id TEXT, karma INTEGER)"); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+	schema := datasource.Schema{Tables: []datasource.TableSchema{
+		{Name: "items", Columns: []datasource.ColumnSchema{{Name: "id"}, {Name: "title"}, {Name: "score"}, {Name: "by"}}},
+		{Name: "users", Columns: []datasource.ColumnSchema{{Name: "id"}, {Name: "karma"}}},
+	}}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "misspelled column",
+			query: "SELECT tilte, score FROM items",
+			want:  []string{"SELECT tilte, score FROM items\n         ^^^^^", "hint: did you mean title?"},
+		},
+		{
+			name:  "misspelled table",
+			query: "SELECT id FROM itmes",
+			want:  []string{"               ^^^^^", "hint: did you mean items?"},
+		},
+		{
+			name:  "misspelled keyword",
+			query: "SELECT id FORM items",
+			want:  []string{"hint: did you mean FROM instead of FORM?"},
+		},
+		{
+			name:  "misspelled function",
+			query: "SELECT url_domian(title) FROM items",
+			want:  []string{"hint: did you mean url_domain?"},
+		},
+		{
+			name:  "unquoted value",
+			query: "SELECT id FROM items WHERE by = pg",
+			want:  []string{"hint: if pg is a text value, quote it: 'pg'"},
+		},
+		{
+			name:  "unterminated string",
+			query: "SELECT id FROM items WHERE by = 'pg",
+			want:  []string{"                                ^", "missing its closing quote"},
+		},
+		{
+			name:  "trailing comma",
+			query: "SELECT id, title, FROM items",
+			want:  []string{"remove the comma before FROM"},
+		},
+		{
+			name:  "ambiguous column",
+			query: "SELECT id FROM items, users",
+			want:  []string{"items.id or users.id"},
+		},
+		{
+			name:  "unclosed parenthesis",
+			query: "SELECT COUNT(id) FROM items WHERE (score > 1",
+			want:  []string{"hint: 1 opening parenthesis not closed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, queryErr := db.Query(tt.query)
+			if queryErr == nil {
+				t.Fatalf("query %q should fail", tt.query)
+			}
+			err := DiagnoseQueryError(tt.query, queryErr, schema)
+			if !errors.Is(err, queryErr) {
+				t.Errorf("diagnostic should wrap the SQLite error, got %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("diagnostic missing %q in:\n%s", want, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestDiagnoseQueryErrorUnexplained(t *testing.T) {
+	err := errors.New("database is locked")
+	if got := DiagnoseQueryError("SELECT 1", err, datasource.Schema{}); got != err {
+		t.Errorf("unexplained errors should be returned unchanged, got %v", got)
+	}
+	if DiagnoseQueryError("SELECT 1", nil, datasource.Schema{}) != nil {
+		t.Error("a nil error should stay nil")
+	}
+
+	diagnosed := DiagnoseQueryError("SELECT scor FROM items", errors.New("no such column: scor"), datasource.Schema{})
+	if again := DiagnoseQueryError("SELECT scor FROM items", diagnosed, datasource.Schema{}); again != diagnosed {
+		t.Error("a diagnosed error should not be diagnosed again")
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"score", "score", 0},
+		{"scor", "score", 1},
+		{"form", "from", 1},
+		{"kitten", "sitting", 3},
+	} {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+// Notebook export formats supported by RenderNotebook
+const (
+	NotebookFormatMarkdown = "markdown"
+	NotebookFormatHTML     = "html"
+)
+
+// MaxNotebookRows is how many rows of each result a notebook keeps, so saved
+// workspaces stay small; the full row count is still recorded
+const MaxNotebookRows = 50
+
+// Notebook is an ordered list of annotated queries whose results are captured
+// when it runs, saved as part of a workspace
+type Notebook struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Created     time.Time      `json:"created"`
+	Updated     time.Time      `json:"updated"`
+	LastRun     *time.Time     `json:"last_run,omitempty"`
+	Cells       []NotebookCell `json:"cells"`
+}
+
+// NotebookCell is a Markdown annotation, a query, or a query with the
+// annotation explaining it
+type NotebookCell struct {
+	Note   string      `json:"note,omitempty"`   // Markdown shown before the query
+	Source string      `json:"source,omitempty"` // Data source the query runs against
+	Query  string      `json:"query,omitempty"`  // Empty for annotation-only cells
+	Output *CellOutput `json:"output,omitempty"` // Result of the last run
+}
+
+// CellOutput is the captured result of a cell's query
+type CellOutput struct {
+	Columns  []string        `json:"columns,omitempty"`
+	Rows     [][]interface{} `json:"rows,omitempty"` // At most MaxNotebookRows rows
+	Count    int             `json:"count"`          // Rows the query returned
+	Duration time.Duration   `json:"duration"`
+	RanAt    time.Time       `json:"ran_at"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// NewNotebook creates an empty notebook
+func NewNotebook(name, description string) *Notebook {
+	now := time.Now()
+	return &Notebook{
+		Name:        name,
+		Description: description,
+		Created:     now,
+		Updated:     now,
+		Cells:       []NotebookCell{},
+	}
+}
+
+// AddCell appends a cell; a cell needs a note, a query or both
+func (nb *Notebook) AddCell(cell NotebookCell) error {
+	cell.Note = strings.TrimSpace(cell.Note)
+	cell.Query = strings.TrimSpace(cell.Query)
+	if cell.Note == "" && cell.Query == "" {
+		return fmt.Errorf("a cell needs a query or a note")
+	}
+	if cell.Query != "" && cell.Source == "" {
+		return fmt.Errorf("a query cell needs a data source")
+	}
+	nb.Cells = append(nb.Cells, cell)
+	nb.Updated = time.Now()
+	return nil
+}
+
+// RemoveCell removes the cell at a 1-based position
+func (nb *Notebook) RemoveCell(position int) error {
+	if position < 1 || position > len(nb.Cells) {
+		return fmt.Errorf("cell %d does not exist (notebook has %d cells)", position, len(nb.Cells))
+	}
+	nb.Cells = append(nb.Cells[:position-1], nb.Cells[position:]...)
+	nb.Updated = time.Now()
+	return nil
+}
+
+// QueryFunc runs a query against a data source
+type QueryFunc func(source, query string) (datasource.QueryResult, error)
+
+// RunNotebook runs the query cells in order, replacing their outputs, and
+// returns how many failed. A failing cell records its error and the
+// remaining cells still run; cancelling ctx stops before the next cell.
+func RunNotebook(ctx context.Context, nb *Notebook, run QueryFunc, progress func(position int, cell NotebookCell)) (int, error) {
+	failed := 0
+	for i := range nb.Cells {
+		cell := &nb.Cells[i]
+		if cell.Query == "" {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return failed, err
+		}
+
+		started := time.Now()
+		result, err := run(cell.Source, cell.Query)
+		output := &CellOutput{RanAt: started, Duration: time.Since(started)}
+		if err != nil {
+			output.Error = err.Error()
+			failed++
+		} else {
+			rows := result.Rows
+			if len(rows) > MaxNotebookRows {
+				rows = rows[:MaxNotebookRows]
+			}
+			output.Columns = result.Columns
+			output.Rows = rows
+			output.Count = len(result.Rows)
+			if result.Duration > 0 {
+				output.Duration = result.Duration
+			}
+		}
+		cell.Output = output
+
+		if progress != nil {
+			progress(i+1, *cell)
+		}
+	}
+
+	now := time.Now()
+	nb.LastRun = &now
+	return failed, nil
+}
+
+// NotebookFormatForPath returns the notebook format matching a file
+// extension, defaulting to Markdown
+func NotebookFormatForPath(path string) string {
+	lower := strings.ToLower(path)
+	if strings.HasSuffix(lower, ".html") || strings.HasSuffix(lower, ".htm") {
+		return NotebookFormatHTML
+	}
+	return NotebookFormatMarkdown
+}
+
+// RenderNotebook renders a notebook with its annotations, queries and
+// captured result tables as Markdown or as a standalone HTML page
+func RenderNotebook(nb *Notebook, format string) (string, error) {
+	var out strings.Builder
+	switch format {
+	case "", NotebookFormatMarkdown:
+		renderNotebookMarkdown(&out, nb)
+	case NotebookFormatHTML:
+		renderNotebookHTML(&out, nb)
+	default:
+		return "", fmt.Errorf("invalid notebook format %q (supported: markdown, html)", format)
+	}
+	return out.String(), nil
+}
+
+// renderNotebookMarkdown writes the notebook as a Markdown document
+func renderNotebookMarkdown(out *strings.Builder, nb *Notebook) {
+	fmt.Fprintf(out, "# %s\n\n", nb.Name)
+	if nb.Description != "" {
+		fmt.Fprintf(out, "%s\n\n", nb.Description)
+	}
+	if nb.LastRun != nil {
+		fmt.Fprintf(out, "*Last run %s*\n\n", nb.LastRun.Format("2006-01-02 15:04"))
+	}
+
+	for _, cell := range nb.Cells {
+		if cell.Note != "" {
+			fmt.Fprintf(out, "%s\n\n", cell.Note)
+		}
+		if cell.Query == "" {
+			continue
+		}
+		fmt.Fprintf(out, "```sql\n-- %s\n%s\n```\n\n", cell.Source, cell.Query)
+
+		switch output := cell.Output; {
+		case output == nil:
+			out.WriteString("*Not run yet*\n\n")
+		case output.Error != "":
+			fmt.Fprintf(out, "> **Error:** %s\n\n", output.Error)
+		case len(output.Rows) == 0:
+			fmt.Fprintf(out, "*No results (%s)*\n\n", output.Duration.Round(time.Millisecond))
+		default:
+			out.WriteString("| " + strings.Join(markdownCells(output.Columns), " | ") + " |\n")
+			out.WriteString("|" + strings.Repeat(" --- |", len(output.Columns)) + "\n")
+			for _, row := range output.Rows {
+				out.WriteString("| " + strings.Join(markdownCells(formatRow(row)), " | ") + " |\n")
+			}
+			fmt.Fprintf(out, "\n*%s*\n\n", outputSummary(output))
+		}
+	}
+}
+
+// renderNotebookHTML writes the notebook as a standalone HTML page
+func renderNotebookHTML(out *strings.Builder, nb *Notebook) {
+	title := html.EscapeString(nb.Name)
+	fmt.Fprintf(out, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", title)
+	out.WriteString(`<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; line-height: 1.5; }
+pre { background: #f5f5f5; padding: 0.75em; overflow-x: auto; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.5em; text-align: left; }
+th { background: #f0f0f0; }
+.meta { color: #666; font-style: italic; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+`)
+	fmt.Fprintf(out, "<h1>%s</h1>\n", title)
+	if nb.Description != "" {
+		fmt.Fprintf(out, "<p>%s</p>\n", html.EscapeString(nb.Description))
+	}
+	if nb.LastRun != nil {
+		fmt.Fprintf(out, "<p class=\"meta\">Last run %s</p>\n", nb.LastRun.Format("2006-01-02 15:04"))
+	}
+
+	for _, cell := range nb.Cells {
+		out.WriteString("<section>\n")
+		if cell.Note != "" {
+			out.WriteString(markdownToHTML(cell.Note))
+		}
+		if cell.Query != "" {
+			fmt.Fprintf(out, "<pre><code>-- %s\n%s</code></pre>\n", html.EscapeString(cell.Source), html.EscapeString(cell.Query))
+
+			switch output := cell.Output; {
+			case output == nil:
+				out.WriteString("<p class=\"meta\">Not run yet</p>\n")
+			case output.Error != "":
+				fmt.Fprintf(out, "<p class=\"error\"><strong>Error:</strong> %s</p>\n", html.EscapeString(output.Error))
+			case len(output.Rows) == 0:
+				fmt.Fprintf(out, "<p class=\"meta\">No results (%s)</p>\n", output.Duration.Round(time.Millisecond))
+			default:
+				out.WriteString("<table>\n<tr>")
+				for _, column := range output.Columns {
+					fmt.Fprintf(out, "<th>%s</th>", html.EscapeString(column))
+				}
+				out.WriteString("</tr>\n")
+				for _, row := range output.Rows {
+					out.WriteString("<tr>")
+					for _, value := range formatRow(row) {
+						fmt.Fprintf(out, "<td>%s</td>", html.EscapeString(value))
+					}
+					out.WriteString("</tr>\n")
+				}
+				fmt.Fprintf(out, "</table>\n<p class=\"meta\">%s</p>\n", outputSummary(output))
+			}
+		}
+		out.WriteString("</section>\n")
+	}
+	out.WriteString("</body>\n</html>\n")
+}
+
+// outputSummary describes how many rows a cell returned and how fast
+func outputSummary(output *CellOutput) string {
+	summary := fmt.Sprintf("%d %s in %s", output.Count, plural(output.Count, "row", "rows"), output.Duration.Round(time.Millisecond))
+	if output.Count > len(output.Rows) {
+		summary += fmt.Sprintf(", first %d shown", len(output.Rows))
+	}
+	return summary
+}
+
+// formatRow formats the values of a result row for display. Rows restored
+// from a saved workspace hold JSON numbers, so whole floats print as integers.
+func formatRow(row []interface{}) []string {
+	values := make([]string, len(row))
+	for i, value := range row {
+		switch v := value.(type) {
+		case nil:
+			values[i] = ""
+		case float64:
+			values[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case []byte:
+			values[i] = string(v)
+		default:
+			values[i] = fmt.Sprint(v)
+		}
+	}
+	return values
+}
+
+// markdownCells escapes values for use in a Markdown table row
+func markdownCells(values []string) []string {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		value = strings.ReplaceAll(value, "|", `\|`)
+		cells[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return cells
+}
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	markdownItem    = regexp.MustCompile(`^\s*[-*]\s+(.*)$`)
+	markdownCode    = regexp.MustCompile("`([^`]+)`")
+	markdownBold    = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	markdownItalic  = regexp.MustCompile(`\*([^*]+)\*`)
+	markdownLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+)
+
+// markdownToHTML converts the Markdown of a note to HTML, supporting
+// headings, paragraphs, bullet lists, code spans, emphasis and links
+func markdownToHTML(markdown string) string {
+	var out strings.Builder
+	var paragraph []string
+	inList := false
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			fmt.Fprintf(&out, "<p>%s</p>\n", markdownInline(strings.Join(paragraph, " ")))
+			paragraph = nil
+		}
+	}
+	closeList := func() {
+		if inList {
+			out.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+
+	for _, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+			closeList()
+		case markdownHeading.MatchString(trimmed):
+			flush()
+			closeList()
+			match := markdownHeading.FindStringSubmatch(trimmed)
+			level := len(match[1])
+			fmt.Fprintf(&out, "<h%d>%s</h%d>\n", level, markdownInline(match[2]), level)
+		case markdownItem.MatchString(line):
+			flush()
+			if !inList {
+				out.WriteString("<ul>\n")
+				inList = true
+			}
+			fmt.Fprintf(&out, "<li>%s</li>\n", markdownInline(markdownItem.FindStringSubmatch(line)[1]))
+		default:
+			closeList()
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flush()
+	closeList()
+	return out.String()
+}
+
+// markdownInline converts inline Markdown of escaped text to HTML
+func markdownInline(text string) string {
+	text = html.EscapeString(text)
+	text = markdownCode.ReplaceAllString(text, "<code>$1</code>")
+	text = markdownLink.ReplaceAllString(text, `<a href="$2">$1</a>`)
+	text = markdownBold.ReplaceAllString(text, "<strong>$1</strong>")
+	return markdownItalic.ReplaceAllString(text, "<em>$1</em>")
+}
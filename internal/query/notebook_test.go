@@ -0,0 +1,151 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+func testNotebook(t *testing.T) *Notebook {
+	t.Helper()
+	nb := NewNotebook("analysis1", "Top posters")
+	cells := []NotebookCell{
+		{Note: "## Posters\n\nWho posts **most**? See [docs](https://example.com)."},
+		{Source: "hackernews", Query: "SELECT by, COUNT(*) AS posts FROM items GROUP BY by", Note: "- counts per author"},
+		{Source: "hackernews", Query: "SELECT broken"},
+	}
+	for _, cell := range cells {
+		if err := nb.AddCell(cell); err != nil {
+			t.Fatalf("AddCell failed: %v", err)
+		}
+	}
+	return nb
+}
+
+func testRun(source, query string) (datasource.QueryResult, error) {
+	if strings.Contains(query, "broken") {
+		return datasource.QueryResult{}, fmt.Errorf("no such column: broken")
+	}
+	rows := make([][]interface{}, MaxNotebookRows+5)
+	for i := range rows {
+		rows[i] = []interface{}{fmt.Sprintf("user|%d", i), int64(i)}
+	}
+	return datasource.QueryResult{Columns: []string{"by", "posts"}, Rows: rows, Count: len(rows)}, nil
+}
+
+func TestNotebookCells(t *testing.T) {
+	nb := testNotebook(t)
+	if err := nb.AddCell(NotebookCell{Note: "  "}); err == nil {
+		t.Error("expected an error for an empty cell")
+	}
+	if err := nb.AddCell(NotebookCell{Query: "SELECT 1"}); err == nil {
+		t.Error("expected an error for a query without a source")
+	}
+
+	if err := nb.RemoveCell(4); err == nil {
+		t.Error("expected an error for a missing cell")
+	}
+	if err := nb.RemoveCell(3); err != nil {
+		t.Fatalf("RemoveCell failed: %v", err)
+	}
+	if len(nb.Cells) != 2 {
+		t.Errorf("expected 2 cells, got %d", len(nb.Cells))
+	}
+}
+
+func TestRunNotebook(t *testing.T) {
+	nb := testNotebook(t)
+	var ran []int
+	failed, err := RunNotebook(context.Background(), nb, testRun, func(position int, cell NotebookCell) {
+		ran = append(ran, position)
+	})
+	if err != nil {
+		t.Fatalf("RunNotebook failed: %v", err)
+	}
+	if failed != 1 {
+		t.Errorf("expected 1 failed cell, got %d", failed)
+	}
+	if fmt.Sprint(ran) != "[2 3]" {
+		t.Errorf("expected query cells 2 and 3 to run, got %v", ran)
+	}
+	if nb.LastRun == nil {
+		t.Error("expected LastRun to be set")
+	}
+
+	output := nb.Cells[1].Output
+	if len(output.Rows) != MaxNotebookRows || output.Count != MaxNotebookRows+5 {
+		t.Errorf("expected %d of %d rows, got %d of %d", MaxNotebookRows, MaxNotebookRows+5, len(output.Rows), output.Count)
+	}
+	if nb.Cells[2].Output.Error != "no such column: broken" {
+		t.Errorf("expected the error to be captured, got %q", nb.Cells[2].Output.Error)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunNotebook(ctx, nb, testRun, nil); err == nil {
+		t.Error("expected a cancelled run to fail")
+	}
+}
+
+func TestRenderNotebookMarkdown(t *testing.T) {
+	nb := testNotebook(t)
+	if _, err := RunNotebook(context.Background(), nb, testRun, nil); err != nil {
+		t.Fatalf("RunNotebook failed: %v", err)
+	}
+
+	// Results survive saving the workspace, where numbers become floats
+	data, err := json.Marshal(nb)
+	if err != nil {
+		t.Fatalf("failed to encode notebook: %v", err)
+	}
+	var saved Notebook
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("failed to decode notebook: %v", err)
+	}
+
+	out, err := RenderNotebook(&saved, NotebookFormatMarkdown)
+	if err != nil {
+		t.Fatalf("RenderNotebook failed: %v", err)
+	}
+	for _, want := range []string{
+		"# analysis1\n\nTop posters",
+		"## Posters",
+		"```sql\n-- hackernews\nSELECT by, COUNT(*) AS posts FROM items GROUP BY by\n```",
+		"| by | posts |\n| --- | --- |\n| user\\|0 | 0 |",
+		"55 rows in",
+		"first 50 shown",
+		"> **Error:** no such column: broken",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderNotebookHTML(t *testing.T) {
+	nb := testNotebook(t)
+	out, err := RenderNotebook(nb, NotebookFormatForPath("analysis.HTML"))
+	if err != nil {
+		t.Fatalf("RenderNotebook failed: %v", err)
+	}
+	for _, want := range []string{
+		"<title>analysis1</title>",
+		"<h2>Posters</h2>",
+		`<p>Who posts <strong>most</strong>? See <a href="https://example.com">docs</a>.</p>`,
+		"<ul>\n<li>counts per author</li>\n</ul>",
+		"SELECT by, COUNT(*) AS posts FROM items GROUP BY by</code></pre>",
+		"Not run yet",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected HTML to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	if _, err := RenderNotebook(nb, "pdf"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,57 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parameterPlaceholder matches a named parameter in a saved query, such as
+// {min_score}
+var parameterPlaceholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Parameters returns the names of the parameters a query takes, sorted
+func Parameters(query string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, match := range parameterPlaceholder.FindAllStringSubmatch(query, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BindParameters replaces the parameters of a query with SQL literals:
+// numbers as they are and anything else as a quoted string. Every parameter
+// needs a value.
+func BindParameters(query string, values map[string]string) (string, error) {
+	var missing []string
+	for _, name := range Parameters(query) {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing query parameters: %s", strings.Join(missing, ", "))
+	}
+
+	return parameterPlaceholder.ReplaceAllStringFunc(query, func(placeholder string) string {
+		return sqlLiteral(values[placeholder[1:len(placeholder)-1]])
+	}), nil
+}
+
+// sqlLiteral formats a parameter value as a SQL literal
+func sqlLiteral(value string) string {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil && !strings.ContainsAny(value, "xXnN") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,31 @@
+package query
+
+import "testing"
+
+func TestBindParameters(t *testing.T) {
+	query := "SELECT id FROM items WHERE score >= {min_score} AND by = {author} AND time > {min_score}"
+
+	names := Parameters(query)
+	if len(names) != 2 || names[0] != "author" || names[1] != "min_score" {
+		t.Fatalf("Parameters() = %v, want [author min_score]", names)
+	}
+
+	bound, err := BindParameters(query, map[string]string{"min_score": "100", "author": "o'brien"})
+	if err != nil {
+		t.Fatalf("BindParameters() error = %v", err)
+	}
+	want := "SELECT id FROM items WHERE score >= 100 AND by = 'o''brien' AND time > 100"
+	if bound != want {
+		t.Errorf("BindParameters() = %q, want %q", bound, want)
+	}
+
+	for value, literal := range map[string]string{"1.5": "1.5", "-3": "-3", "NaN": "'NaN'", "Inf": "'Inf'", "0x10": "'0x10'", "1 OR 1=1": "'1 OR 1=1'"} {
+		if got := sqlLiteral(value); got != literal {
+			t.Errorf("sqlLiteral(%q) = %q, want %q", value, got, literal)
+		}
+	}
+
+	if _, err := BindParameters(query, map[string]string{"author": "pg"}); err == nil {
+		t.Error("BindParameters() with a missing parameter succeeded")
+	}
+}
@@ -0,0 +1,180 @@
+package query
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Redaction actions
+const (
+	RedactHash     = "hash"     // Replace values with a keyed hash, so equal values still match
+	RedactDrop     = "drop"     // Remove the column
+	RedactTruncate = "truncate" // Keep the first Length characters of text
+)
+
+// RedactionRule redacts one column of exported results
+type RedactionRule struct {
+	Column string `json:"column"`
+	Action string `json:"action"`
+	Length int    `json:"length,omitempty"` // Characters truncate keeps
+}
+
+// ParseRedactionRule parses a rule for column from an action such as
+// "hash", "drop" or "truncate:80"
+func ParseRedactionRule(column, action string) (RedactionRule, error) {
+	rule := RedactionRule{Column: column, Action: strings.ToLower(action)}
+	if name, length, ok := strings.Cut(rule.Action, ":"); ok {
+		n, err := strconv.Atoi(length)
+		if err != nil {
+			return RedactionRule{}, fmt.Errorf("invalid truncate length %q", length)
+		}
+		rule.Action, rule.Length = name, n
+	}
+	return rule, rule.Validate()
+}
+
+// Validate checks that the rule names a column and a known action
+func (r RedactionRule) Validate() error {
+	if strings.TrimSpace(r.Column) == "" {
+		return fmt.Errorf("redaction rule needs a column")
+	}
+	switch r.Action {
+	case RedactHash, RedactDrop:
+		if r.Length != 0 {
+			return fmt.Errorf("only truncate takes a length, got %s:%d", r.Action, r.Length)
+		}
+	case RedactTruncate:
+		if r.Length <= 0 {
+			return fmt.Errorf("truncate needs the characters to keep, e.g. truncate:80")
+		}
+	default:
+		return fmt.Errorf("unknown redaction action %q (supported: hash, drop, truncate:N)", r.Action)
+	}
+	return nil
+}
+
+// String formats the rule as "column: action"
+func (r RedactionRule) String() string {
+	if r.Action == RedactTruncate {
+		return fmt.Sprintf("%s: %s:%d", r.Column, r.Action, r.Length)
+	}
+	return fmt.Sprintf("%s: %s", r.Column, r.Action)
+}
+
+// Redaction is a named set of rules applied to results before they are
+// written for sharing, such as hashing usernames and dropping emails
+type Redaction struct {
+	Rules []RedactionRule `json:"rules"`
+	Salt  string          `json:"salt"` // Key of the hashes, so hashed values cannot be looked up
+}
+
+// NewRedaction returns an empty rule set with a random salt
+func NewRedaction() Redaction {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	return Redaction{Salt: hex.EncodeToString(salt)}
+}
+
+// SetRule adds rule, replacing the set's rule for the same column
+func (r *Redaction) SetRule(rule RedactionRule) {
+	for i := range r.Rules {
+		if strings.EqualFold(r.Rules[i].Column, rule.Column) {
+			r.Rules[i] = rule
+			return
+		}
+	}
+	r.Rules = append(r.Rules, rule)
+}
+
+// RemoveRule removes the rule for column and reports whether there was one
+func (r *Redaction) RemoveRule(column string) bool {
+	for i := range r.Rules {
+		if strings.EqualFold(r.Rules[i].Column, column) {
+			r.Rules = append(r.Rules[:i], r.Rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks every rule of the set
+func (r Redaction) Validate() error {
+	for _, rule := range r.Rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply returns columns and rows with the rules applied, leaving the given
+// rows unchanged. Rules match column names case-insensitively; rules for
+// columns the results do not have are ignored, so one set serves many
+// queries. NULL values stay NULL.
+func (r Redaction) Apply(columns []string, rows [][]interface{}) ([]string, [][]interface{}, error) {
+	if err := r.Validate(); err != nil {
+		return nil, nil, err
+	}
+	rules := make([]*RedactionRule, len(columns))
+	for i := range r.Rules {
+		for j, column := range columns {
+			if strings.EqualFold(column, r.Rules[i].Column) {
+				rules[j] = &r.Rules[i]
+			}
+		}
+	}
+
+	var kept []int
+	var redactedColumns []string
+	for i, column := range columns {
+		if rules[i] == nil || rules[i].Action != RedactDrop {
+			kept = append(kept, i)
+			redactedColumns = append(redactedColumns, column)
+		}
+	}
+
+	redactedRows := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		redacted := make([]interface{}, len(kept))
+		for j, index := range kept {
+			if index < len(row) {
+				redacted[j] = r.value(rules[index], row[index])
+			}
+		}
+		redactedRows[i] = redacted
+	}
+	return redactedColumns, redactedRows, nil
+}
+
+// value returns a value redacted by rule, which may be nil
+func (r Redaction) value(rule *RedactionRule, value interface{}) interface{} {
+	if rule == nil || value == nil {
+		return value
+	}
+	switch rule.Action {
+	case RedactHash:
+		mac := hmac.New(sha256.New, []byte(r.Salt))
+		mac.Write([]byte(FieldString(value)))
+		return hex.EncodeToString(mac.Sum(nil)[:16])
+	case RedactTruncate:
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = v
+		case []byte:
+			text = string(v)
+		default:
+			return value
+		}
+		if runes := []rune(text); len(runes) > rule.Length {
+			return string(runes[:rule.Length])
+		}
+		return text
+	}
+	return value
+}
@@ -0,0 +1,129 @@
+package query
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+func TestParseRedactionRule(t *testing.T) {
+	rule, err := ParseRedactionRule("text", "TRUNCATE:80")
+	if err != nil || rule != (RedactionRule{Column: "text", Action: RedactTruncate, Length: 80}) {
+		t.Errorf("ParseRedactionRule = %+v, %v", rule, err)
+	}
+	for _, action := range []string{"truncate", "truncate:0", "truncate:x", "hash:3", "mask"} {
+		if _, err := ParseRedactionRule("by", action); err == nil {
+			t.Errorf("%s should be rejected", action)
+		}
+	}
+	if _, err := ParseRedactionRule("", "drop"); err == nil {
+		t.Error("a rule without a column should be rejected")
+	}
+}
+
+func TestRedaction_Apply(t *testing.T) {
+	redaction := NewRedaction()
+	redaction.SetRule(RedactionRule{Column: "by", Action: RedactHash})
+	redaction.SetRule(RedactionRule{Column: "email", Action: RedactDrop})
+	redaction.SetRule(RedactionRule{Column: "text", Action: RedactTruncate, Length: 4})
+	redaction.SetRule(RedactionRule{Column: "missing", Action: RedactDrop})
+
+	columns := []string{"id", "BY", "email", "text"}
+	rows := [][]interface{}{
+		{int64(1), "pg", "pg@example.com", "héllo world"},
+		{int64(2), "pg", "pg@example.com", nil},
+		{int64(3), nil, nil, "hi"},
+	}
+	gotColumns, gotRows, err := redaction.Apply(columns, rows)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotColumns, []string{"id", "BY", "text"}) {
+		t.Errorf("columns = %v, want email dropped", gotColumns)
+	}
+
+	hash, ok := gotRows[0][1].(string)
+	if !ok || hash == "pg" || len(hash) != 32 {
+		t.Errorf("by = %v, want a hash", gotRows[0][1])
+	}
+	if gotRows[1][1] != hash {
+		t.Error("equal values should hash the same")
+	}
+	if gotRows[2][1] != nil {
+		t.Errorf("NULL should stay NULL, got %v", gotRows[2][1])
+	}
+	if gotRows[0][2] != "héll" || gotRows[1][2] != nil || gotRows[2][2] != "hi" {
+		t.Errorf("text = %v, %v, %v", gotRows[0][2], gotRows[1][2], gotRows[2][2])
+	}
+	if rows[0][1] != "pg" || len(rows[0]) != 4 {
+		t.Error("the given rows should not change")
+	}
+
+	// Another set has another salt, so its hashes differ
+	other := NewRedaction()
+	other.SetRule(RedactionRule{Column: "by", Action: RedactHash})
+	_, otherRows, err := other.Apply(columns, rows)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if otherRows[0][1] == hash {
+		t.Error("hashes of sets with different salts should differ")
+	}
+
+	if !redaction.RemoveRule("EMAIL") || redaction.RemoveRule("email") {
+		t.Error("RemoveRule should remove the rule once")
+	}
+}
+
+func TestExportJob_Redaction(t *testing.T) {
+	log.InitLogger(false)
+	dataSources := map[string]datasource.DataSource{
+		"test": &MockDataSource{
+			name: "test",
+			queryResult: datasource.QueryResult{
+				Columns: []string{"id", "by", "email"},
+				Rows:    [][]interface{}{{int64(1), "pg", "pg@example.com"}},
+				Count:   1,
+			},
+		},
+	}
+	engine := NewTUIQueryEngine(dataSources, nil, NewMockJobManager())
+	engine.Start()
+	defer engine.Stop()
+
+	redaction := NewRedaction()
+	redaction.SetRule(RedactionRule{Column: "email", Action: RedactDrop})
+	redaction.SetRule(RedactionRule{Column: "by", Action: RedactHash})
+	output := filepath.Join(t.TempDir(), "share.csv")
+	job := &ExportJobImpl{
+		BaseJob:    BaseJob{JobID: "export_test", JobType: jobs.JobTypeExport, JobMetadata: jobs.JobMetadata{}},
+		dataSource: "test",
+		query:      "SELECT id, by, email FROM users",
+		format:     OutputFormatCSV,
+		outputFile: output,
+		redaction:  &redaction,
+		engine:     engine,
+	}
+	if err := job.Execute(context.Background(), nil); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || lines[0] != "id,by" {
+		t.Fatalf("export = %q, want id and a hashed by", data)
+	}
+	if strings.Contains(string(data), "pg") {
+		t.Errorf("export should not contain the username: %q", data)
+	}
+}
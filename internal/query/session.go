@@ -18,6 +18,7 @@ type TUIQuerySession struct {
 	history      []QueryHistory
 	savedQueries map[string]string
 	settings     SessionSettings
+	lastResult   *QueryResult
 	isActive     bool
 }
 
@@ -55,6 +56,7 @@ func (s *TUIQuerySession) Execute(query string) (QueryResult, error) {
 
 	// Add successful query to history
 	s.addToHistoryUnsafe(query, result, nil)
+	s.lastResult = &result
 
 	return result, nil
 }
@@ -70,6 +72,13 @@ func (s *TUIQuerySession) GetHistory() []QueryHistory {
 	return history
 }
 
+// LastResult returns the result of the last successful query, or nil
+func (s *TUIQuerySession) LastResult() *QueryResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastResult
+}
+
 // AddToHistory adds a query to the session history
 func (s *TUIQuerySession) AddToHistory(query string, result QueryResult) error {
 	s.mu.Lock()
@@ -318,6 +327,7 @@ func (s *TUIInteractiveSession) initializeCommands() {
 	s.commands["load"] = &LoadQueryCommand{}
 	s.commands["exit"] = &ExitCommand{}
 	s.commands["settings"] = &SettingsCommand{}
+	s.commands["chart"] = &ChartCommand{}
 }
 
 // InteractiveCommand interface for interactive session commands
@@ -416,6 +426,36 @@ func (c *HistoryCommand) Description() string { return "Show query history" }
 func (c *HistoryCommand) Usage() string       { return ".history" }
 func (c *HistoryCommand) Category() string    { return "session" }
 
+type ChartCommand struct{}
+
+func (c *ChartCommand) Execute(session *TUIInteractiveSession, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("chart command requires a chart spec, e.g. bar:x=day,y=stories")
+	}
+
+	result := session.LastResult()
+	if result == nil {
+		return fmt.Errorf("no query result to chart, run a query first")
+	}
+
+	spec, err := ParseChartSpec(args[0])
+	if err != nil {
+		return err
+	}
+	chart, err := RenderChart(result.Columns, result.Rows, spec)
+	if err != nil {
+		return err
+	}
+	fmt.Print(chart)
+	return nil
+}
+
+func (c *ChartCommand) Description() string { return "Chart the last query result" }
+func (c *ChartCommand) Usage() string {
+	return ".chart <bar:x=col,y=col|spark:y=col|hist:x=col[,bins=N]>"
+}
+func (c *ChartCommand) Category() string { return "output" }
+
 type SaveQueryCommand struct{}
 
 func (c *SaveQueryCommand) Execute(session *TUIInteractiveSession, args []string) error {
@@ -0,0 +1,173 @@
+package query
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/objectstore"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// SQLiteExportTable is the table results are written to in an exported
+// SQLite database
+const SQLiteExportTable = "results"
+
+// FormatForPath returns the output format for a file by its extension: JSON,
+// TSV, Excel for .xlsx, SQLite for .db, .sqlite and .sqlite3, and CSV
+// otherwise. A compression extension is skipped, so data.json.gz is JSON.
+func FormatForPath(path string) OutputFormat {
+	if ext := CompressionForPath(path).Extension(); ext != "" {
+		path = path[:len(path)-len(ext)]
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return OutputFormatJSON
+	case ".tsv", ".txt":
+		return OutputFormatTSV
+	case ".xlsx":
+		return OutputFormatXLSX
+	case ".db", ".sqlite", ".sqlite3":
+		return OutputFormatSQLite
+	default:
+		return OutputFormatCSV
+	}
+}
+
+// WriteSQLite writes columns and rows to a new standalone SQLite database at
+// path, in a table whose column types are inferred from the values. An
+// existing file at path is replaced once the database is complete.
+func WriteSQLite(path string, columns []string, rows [][]interface{}) error {
+	if objectstore.IsURL(path) {
+		return fmt.Errorf("sqlite databases cannot be uploaded while written, export to a local .db file")
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("failed to create database file: %w", err)
+	}
+	temp.Close()
+	defer os.Remove(temp.Name())
+
+	if err := writeSQLiteTable(temp.Name(), columns, rows); err != nil {
+		return err
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write database file: %w", err)
+	}
+	return nil
+}
+
+// writeSQLiteTable creates the results table in the database at path and
+// inserts the rows in one transaction
+func writeSQLiteTable(path string, columns []string, rows [][]interface{}) error {
+	db, err := sql.Open(storage.DriverName, path)
+	if err != nil {
+		return fmt.Errorf("failed to open database file: %w", err)
+	}
+	defer db.Close()
+
+	names := sqliteColumnNames(columns)
+	types := sqliteColumnTypes(columns, rows)
+	definitions := make([]string, len(names))
+	placeholders := make([]string, len(names))
+	for i, name := range names {
+		definitions[i] = quoteIdentifier(name) + " " + types[i]
+		placeholders[i] = "?"
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", SQLiteExportTable, strings.Join(definitions, ", "))); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (%s)", SQLiteExportTable, strings.Join(placeholders, ", ")))
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	values := make([]interface{}, len(columns))
+	for i, row := range rows {
+		for j := range values {
+			values[j] = nil
+			if j < len(row) {
+				values[j] = row[j]
+			}
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			return fmt.Errorf("failed to insert row %d: %w", i, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rows: %w", err)
+	}
+	return nil
+}
+
+// sqliteColumnNames returns the result columns as distinct, non-empty table
+// column names; repeated names get a numeric suffix
+func sqliteColumnNames(columns []string) []string {
+	names := make([]string, len(columns))
+	seen := make(map[string]bool)
+	for i, column := range columns {
+		name := column
+		if name == "" {
+			name = "column" + strconv.Itoa(i+1)
+		}
+		for n := 2; seen[strings.ToLower(name)]; n++ {
+			name = column + "_" + strconv.Itoa(n)
+		}
+		seen[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// sqliteColumnTypes returns the declared type of each column: INTEGER for
+// whole numbers and booleans, REAL for other numbers, BLOB for binary
+// values and TEXT otherwise
+func sqliteColumnTypes(columns []string, rows [][]interface{}) []string {
+	kinds := InferColumnKinds(columns, rows)
+	types := make([]string, len(columns))
+	for j, kind := range kinds {
+		switch kind {
+		case ColumnBool:
+			types[j] = "INTEGER"
+		case ColumnNumeric:
+			types[j] = "INTEGER"
+			for _, row := range rows {
+				if j < len(row) {
+					switch row[j].(type) {
+					case float32, float64:
+						types[j] = "REAL"
+					}
+				}
+			}
+		default:
+			types[j] = "TEXT"
+			for _, row := range rows {
+				if j < len(row) {
+					if _, ok := row[j].([]byte); ok {
+						types[j] = "BLOB"
+						break
+					}
+				}
+			}
+		}
+	}
+	return types
+}
+
+// quoteIdentifier quotes a table or column name for SQL
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
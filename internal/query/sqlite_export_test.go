@@ -0,0 +1,81 @@
+package query
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+func TestWriteSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.db")
+	if err := os.WriteFile(path, []byte("an older export"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	columns := []string{"id", "title", "score", "ratio", "dead", "id"}
+	rows := [][]interface{}{
+		{int64(1), "Show HN", int64(10), 0.5, true, int64(7)},
+		{int64(2), nil, nil, 1.0, false, int64(8)},
+	}
+	if err := WriteSQLite(path, columns, rows); err != nil {
+		t.Fatalf("WriteSQLite failed: %v", err)
+	}
+
+	db, err := sql.Open(storage.DriverName, path)
+	if err != nil {
+		t.Fatalf("failed to open export: %v", err)
+	}
+	defer db.Close()
+
+	var schema string
+	if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE name = ?", SQLiteExportTable).Scan(&schema); err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	want := `CREATE TABLE results ("id" INTEGER, "title" TEXT, "score" INTEGER, "ratio" REAL, "dead" INTEGER, "id_2" INTEGER)`
+	if schema != want {
+		t.Errorf("schema = %q, want %q", schema, want)
+	}
+
+	var got [][]interface{}
+	result, err := db.Query("SELECT title, score, dead, id_2 FROM results ORDER BY id")
+	if err != nil {
+		t.Fatalf("failed to query export: %v", err)
+	}
+	defer result.Close()
+	for result.Next() {
+		var title sql.NullString
+		var score sql.NullInt64
+		var dead, id2 int64
+		if err := result.Scan(&title, &score, &dead, &id2); err != nil {
+			t.Fatalf("failed to scan row: %v", err)
+		}
+		got = append(got, []interface{}{title.String, score.Int64, dead, id2})
+	}
+	if !reflect.DeepEqual(got, [][]interface{}{{"Show HN", int64(10), int64(1), int64(7)}, {"", int64(0), int64(0), int64(8)}}) {
+		t.Errorf("rows = %v", got)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".results.db-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestFormatForPath(t *testing.T) {
+	for path, want := range map[string]OutputFormat{
+		"top.json":    OutputFormatJSON,
+		"top.TSV":     OutputFormatTSV,
+		"top.db":      OutputFormatSQLite,
+		"top.sqlite3": OutputFormatSQLite,
+		"top.XLSX":    OutputFormatXLSX,
+		"top":         OutputFormatCSV,
+	} {
+		if got := FormatForPath(path); got != want {
+			t.Errorf("FormatForPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
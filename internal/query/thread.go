@@ -0,0 +1,245 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+// Thread export formats supported by RenderThread
+const (
+	ThreadFormatText     = "text"
+	ThreadFormatMarkdown = "markdown"
+	ThreadFormatJSON     = "json"
+)
+
+// DefaultThreadWidth is the line width text threads are wrapped to
+const DefaultThreadWidth = 100
+
+// ThreadOptions control how a thread is rendered
+type ThreadOptions struct {
+	Format string // text, markdown or json; defaults to text
+	Depth  int    // Reply levels shown before collapsing; 0 shows every level
+	Width  int    // Line width of text output; defaults to DefaultThreadWidth
+}
+
+// ThreadFormatForPath returns the thread format matching a file extension,
+// defaulting to text
+func ThreadFormatForPath(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".md"), strings.HasSuffix(path, ".markdown"):
+		return ThreadFormatMarkdown
+	case strings.HasSuffix(path, ".json"):
+		return ThreadFormatJSON
+	}
+	return ThreadFormatText
+}
+
+// RenderThread renders a thread as an indented tree of text, as nested
+// Markdown lists or as JSON. Replies below opts.Depth are collapsed into a
+// count.
+func RenderThread(root *datasource.ThreadItem, opts ThreadOptions) (string, error) {
+	if opts.Width <= 0 {
+		opts.Width = DefaultThreadWidth
+	}
+
+	var out strings.Builder
+	switch opts.Format {
+	case "", ThreadFormatText:
+		renderThreadText(&out, root, 0, opts)
+	case ThreadFormatMarkdown:
+		renderThreadMarkdown(&out, root, 0, opts)
+	case ThreadFormatJSON:
+		data, err := json.MarshalIndent(pruneThread(root, 0, opts.Depth), "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to encode thread: %w", err)
+		}
+		out.Write(data)
+		out.WriteString("\n")
+	default:
+		return "", fmt.Errorf("invalid thread format %q (supported: text, markdown, json)", opts.Format)
+	}
+	return out.String(), nil
+}
+
+// renderThreadText writes an item and its replies indented two spaces per level
+func renderThreadText(out *strings.Builder, item *datasource.ThreadItem, depth int, opts ThreadOptions) {
+	indent := strings.Repeat("  ", depth)
+	width := opts.Width - len(indent)
+	if width < 20 {
+		width = 20
+	}
+
+	fmt.Fprintf(out, "%s%s\n", indent, threadHeader(item))
+	if item.Title != "" {
+		fmt.Fprintf(out, "%s%s\n", indent, item.Title)
+	}
+	if item.URL != "" {
+		fmt.Fprintf(out, "%s%s\n", indent, item.URL)
+	}
+	for _, paragraph := range threadParagraphs(item.Text, false) {
+		for _, line := range wrapText(paragraph, width) {
+			fmt.Fprintf(out, "%s%s\n", indent, line)
+		}
+	}
+	if item.Missing > 0 {
+		fmt.Fprintf(out, "%s[%d %s not downloaded]\n", indent, item.Missing, plural(item.Missing, "reply", "replies"))
+	}
+	out.WriteString("\n")
+
+	if opts.Depth > 0 && depth >= opts.Depth && len(item.Replies) > 0 {
+		count := item.CountReplies()
+		fmt.Fprintf(out, "%s  [+%d %s collapsed]\n\n", indent, count, plural(count, "reply", "replies"))
+		return
+	}
+	for _, reply := range item.Replies {
+		renderThreadText(out, reply, depth+1, opts)
+	}
+}
+
+// renderThreadMarkdown writes the root as a heading and replies as nested lists
+func renderThreadMarkdown(out *strings.Builder, item *datasource.ThreadItem, depth int, opts ThreadOptions) {
+	if depth == 0 {
+		title := item.Title
+		if title == "" {
+			title = fmt.Sprintf("Item %d", item.ID)
+		}
+		fmt.Fprintf(out, "# %s\n\n", title)
+		if item.URL != "" {
+			fmt.Fprintf(out, "<%s>\n\n", item.URL)
+		}
+		fmt.Fprintf(out, "*%s*\n\n", threadHeader(item))
+		for _, paragraph := range threadParagraphs(item.Text, true) {
+			fmt.Fprintf(out, "%s\n\n", paragraph)
+		}
+	} else {
+		indent := strings.Repeat("  ", depth-1)
+		fmt.Fprintf(out, "%s- **%s**\n", indent, threadHeader(item))
+		for _, paragraph := range threadParagraphs(item.Text, true) {
+			fmt.Fprintf(out, "\n%s  %s\n", indent, strings.ReplaceAll(paragraph, "\n", "\n"+indent+"  "))
+		}
+		out.WriteString("\n")
+	}
+	if item.Missing > 0 {
+		fmt.Fprintf(out, "%s- *%d %s not downloaded*\n\n", strings.Repeat("  ", depth), item.Missing, plural(item.Missing, "reply", "replies"))
+	}
+
+	if opts.Depth > 0 && depth >= opts.Depth && len(item.Replies) > 0 {
+		count := item.CountReplies()
+		fmt.Fprintf(out, "%s- *%d %s collapsed*\n\n", strings.Repeat("  ", depth), count, plural(count, "reply", "replies"))
+		return
+	}
+	for _, reply := range item.Replies {
+		renderThreadMarkdown(out, reply, depth+1, opts)
+	}
+}
+
+// pruneThread returns a copy of the thread without replies below maxDepth
+func pruneThread(item *datasource.ThreadItem, depth, maxDepth int) *datasource.ThreadItem {
+	pruned := *item
+	if maxDepth > 0 && depth >= maxDepth {
+		pruned.Replies = nil
+		return &pruned
+	}
+	pruned.Replies = make([]*datasource.ThreadItem, len(item.Replies))
+	for i, reply := range item.Replies {
+		pruned.Replies[i] = pruneThread(reply, depth+1, maxDepth)
+	}
+	return &pruned
+}
+
+// threadHeader describes who posted an item and when
+func threadHeader(item *datasource.ThreadItem) string {
+	parts := []string{}
+	switch {
+	case item.Deleted:
+		parts = append(parts, "[deleted]")
+	case item.By != "":
+		parts = append(parts, item.By)
+	}
+	if item.Dead {
+		parts = append(parts, "[dead]")
+	}
+	if item.Score > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", item.Score, plural(int(item.Score), "point", "points")))
+	}
+	if item.Time > 0 {
+		parts = append(parts, time.Unix(item.Time, 0).UTC().Format("2006-01-02 15:04"))
+	}
+	parts = append(parts, fmt.Sprintf("#%d", item.ID))
+	return strings.Join(parts, " · ")
+}
+
+var (
+	paragraphTag = regexp.MustCompile(`(?i)<p>`)
+	linkTag      = regexp.MustCompile(`(?is)<a\s[^>]*href="([^"]*)"[^>]*>.*?</a>`)
+	italicTag    = regexp.MustCompile(`(?i)</?i>`)
+	anyTag       = regexp.MustCompile(`<[^>]*>`)
+)
+
+// threadParagraphs converts the HTML of an item's text to plain paragraphs;
+// markdown keeps italics and links in Markdown syntax
+func threadParagraphs(text string, markdown bool) []string {
+	if text == "" {
+		return nil
+	}
+
+	text = paragraphTag.ReplaceAllString(text, "\n\n")
+	if markdown {
+		text = linkTag.ReplaceAllString(text, "<$1>")
+		text = italicTag.ReplaceAllString(text, "*")
+		text = anyTag.ReplaceAllStringFunc(text, func(tag string) string {
+			if strings.HasPrefix(tag, "<http") {
+				return tag // autolink from a replaced anchor
+			}
+			return ""
+		})
+	} else {
+		text = linkTag.ReplaceAllString(text, "$1")
+		text = anyTag.ReplaceAllString(text, "")
+	}
+	text = html.UnescapeString(text)
+
+	var paragraphs []string
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return paragraphs
+}
+
+// wrapText breaks text into lines of at most width characters at spaces;
+// longer words get a line of their own
+func wrapText(text string, width int) []string {
+	var lines []string
+	for _, source := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(source) {
+			if line != "" && utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// plural returns singular when count is one and plural otherwise
+func plural(count int, singular, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,99 @@
+package query
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+func testThread() *datasource.ThreadItem {
+	return &datasource.ThreadItem{
+		ID: 1, Type: "story", By: "alice", Time: 1175714200, Title: "Show HN: A thing", Score: 42,
+		URL: "https://example.com",
+		Replies: []*datasource.ThreadItem{
+			{ID: 2, Type: "comment", By: "bob", Text: "Nice &amp; useful.<p>See <a href=\"https://example.org/docs\" rel=\"nofollow\">https://example.org/d...</a>",
+				Replies: []*datasource.ThreadItem{
+					{ID: 4, Type: "comment", By: "alice", Text: "<i>Thanks</i>",
+						Replies: []*datasource.ThreadItem{{ID: 5, Type: "comment", By: "bob", Text: "np"}}},
+				}},
+			{ID: 3, Type: "comment", Deleted: true, Missing: 1},
+		},
+	}
+}
+
+func TestRenderThreadText(t *testing.T) {
+	out, err := RenderThread(testThread(), ThreadOptions{})
+	if err != nil {
+		t.Fatalf("RenderThread failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		"alice · 42 points · 2007-04-04 19:16 · #1\nShow HN: A thing\nhttps://example.com\n",
+		"  bob · #2\n  Nice & useful.\n  See https://example.org/docs\n",
+		"    alice · #4\n    Thanks\n",
+		"      bob · #5\n      np\n",
+		"  [deleted] · #3\n  [1 reply not downloaded]\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in thread:\n%s", expected, out)
+		}
+	}
+
+	out, err = RenderThread(testThread(), ThreadOptions{Depth: 1})
+	if err != nil {
+		t.Fatalf("RenderThread failed: %v", err)
+	}
+	if !strings.Contains(out, "    [+2 replies collapsed]") || strings.Contains(out, "#4") {
+		t.Errorf("Expected replies below depth 1 collapsed:\n%s", out)
+	}
+}
+
+func TestRenderThreadMarkdown(t *testing.T) {
+	out, err := RenderThread(testThread(), ThreadOptions{Format: ThreadFormatMarkdown})
+	if err != nil {
+		t.Fatalf("RenderThread failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		"# Show HN: A thing\n\n<https://example.com>\n\n",
+		"- **bob · #2**\n\n  Nice & useful.\n\n  See <https://example.org/docs>\n",
+		"  - **alice · #4**\n\n    *Thanks*\n",
+		"    - **bob · #5**\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in thread:\n%s", expected, out)
+		}
+	}
+}
+
+func TestRenderThreadJSON(t *testing.T) {
+	root := testThread()
+	out, err := RenderThread(root, ThreadOptions{Format: ThreadFormatJSON, Depth: 1})
+	if err != nil {
+		t.Fatalf("RenderThread failed: %v", err)
+	}
+
+	var decoded datasource.ThreadItem
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(decoded.Replies) != 2 || decoded.Replies[0].Replies != nil {
+		t.Errorf("Expected replies pruned below depth 1: %+v", decoded)
+	}
+	if len(root.Replies[0].Replies) != 1 {
+		t.Errorf("Pruning changed the original thread")
+	}
+
+	if _, err := RenderThread(root, ThreadOptions{Format: "html"}); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	lines := wrapText("one two three four", 9)
+	if strings.Join(lines, "|") != "one two|three|four" {
+		t.Errorf("Unexpected wrap: %q", lines)
+	}
+}
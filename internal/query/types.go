@@ -13,7 +13,7 @@ type QueryEngine interface {
 	ExecuteInteractive(dataSource string) error
 
 	// Background export jobs
-	StartExportJob(dataSource, query string, format OutputFormat, file string) (string, error)
+	StartExportJob(dataSource, query string, format OutputFormat, compression Compression, redaction *Redaction, file string, hooks ...ExportHook) (string, error)
 
 	// Real-time integration
 	GetQueryMetrics() QueryMetrics
@@ -137,6 +137,8 @@ const (
 	OutputFormatCSV     OutputFormat = "csv"
 	OutputFormatTSV     OutputFormat = "tsv"
 	OutputFormatParquet OutputFormat = "parquet"
+	OutputFormatSQLite  OutputFormat = "sqlite"
+	OutputFormatXLSX    OutputFormat = "xlsx"
 )
 
 // QueryMetrics tracks query engine performance
@@ -270,6 +272,7 @@ type QueryCache interface {
 	Get(key string) (QueryResult, bool)
 	Set(key string, result QueryResult, ttl time.Duration) error
 	Delete(key string) error
+	InvalidateTables(source string, tables ...string) int
 	Clear() error
 	Stats() CacheStats
 }
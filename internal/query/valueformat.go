@@ -0,0 +1,221 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numberLocales are the digit group and decimal separators of the supported
+// number locales
+var numberLocales = map[string]struct{ group, decimal string }{
+	"en": {",", "."},
+	"de": {".", ","},
+	"fr": {" ", ","},
+	"ch": {"'", "."},
+}
+
+// dateFormats are the named layouts accepted for date_format in addition to
+// Go layouts
+var dateFormats = map[string]string{
+	"iso":     "2006-01-02 15:04:05",
+	"date":    "2006-01-02",
+	"rfc3339": time.RFC3339,
+	"us":      "01/02/2006 15:04",
+	"eu":      "02.01.2006 15:04",
+}
+
+// ValueFormat controls how numbers and timestamps are shown in result
+// tables. The zero value shows values as they are stored; delimited and
+// JSON output always keep the stored values.
+type ValueFormat struct {
+	NumberLocale string // en, de, fr or ch groups digits the locale's way; empty leaves numbers
+	DateFormat   string // Named or Go layout for time columns; empty leaves timestamps
+	RelativeTime bool   // Shows time columns as "3h ago", overriding DateFormat
+}
+
+// ValidateNumberLocale checks a number locale setting; empty is allowed
+func ValidateNumberLocale(locale string) error {
+	if _, ok := numberLocales[locale]; !ok && locale != "" {
+		return fmt.Errorf("unknown number locale %q (supported: en, de, fr, ch, or empty for none)", locale)
+	}
+	return nil
+}
+
+// DateLayout returns the Go layout of a date format setting, which is one of
+// iso, date, rfc3339, us and eu, or a Go layout such as "Jan 2 15:04"
+func DateLayout(format string) (string, error) {
+	if layout, ok := dateFormats[format]; ok {
+		return layout, nil
+	}
+	if !strings.Contains(format, "2006") && !strings.Contains(format, "Jan") && !strings.Contains(format, "01") && !strings.Contains(format, "15") {
+		return "", fmt.Errorf("invalid date format %q (use iso, date, rfc3339, us, eu or a Go layout such as 2006-01-02)", format)
+	}
+	return format, nil
+}
+
+// IsZero reports whether the format leaves every value as stored
+func (f ValueFormat) IsZero() bool {
+	return f.NumberLocale == "" && f.DateFormat == "" && !f.RelativeTime
+}
+
+// FormatRows returns the rows with numbers and timestamps formatted as
+// strings. Timestamps are shown in now's time zone, relative to now. Rows
+// are returned unchanged when the format is the zero value.
+func (f ValueFormat) FormatRows(columns []string, rows [][]interface{}, now time.Time) [][]interface{} {
+	if f.IsZero() {
+		return rows
+	}
+
+	formatted := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		formatted[i] = make([]interface{}, len(row))
+		for j, value := range row {
+			column := ""
+			if j < len(columns) {
+				column = columns[j]
+			}
+			formatted[i][j] = f.FormatValue(column, value, now)
+		}
+	}
+	return formatted
+}
+
+// FormatValue formats a value of the named column
+func (f ValueFormat) FormatValue(column string, value interface{}, now time.Time) interface{} {
+	if isTimeColumn(column) {
+		if t, ok := timestampValue(value); ok && (f.RelativeTime || f.DateFormat != "") {
+			return f.formatTime(t, now)
+		}
+		return value
+	}
+	if isIDColumn(column) {
+		return value
+	}
+
+	separators, ok := numberLocales[f.NumberLocale]
+	if !ok {
+		return value
+	}
+	switch v := value.(type) {
+	case int64:
+		return groupDigits(strconv.FormatInt(v, 10), separators.group)
+	case int:
+		return groupDigits(strconv.Itoa(v), separators.group)
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return value
+		}
+		text := strconv.FormatFloat(v, 'f', -1, 64)
+		whole, fraction, hasFraction := strings.Cut(text, ".")
+		text = groupDigits(whole, separators.group)
+		if hasFraction {
+			text += separators.decimal + fraction
+		}
+		return text
+	}
+	return value
+}
+
+// formatTime formats a timestamp relative to now or with the date format
+func (f ValueFormat) formatTime(t time.Time, now time.Time) interface{} {
+	if f.RelativeTime {
+		return RelativeTime(t, now)
+	}
+	layout, err := DateLayout(f.DateFormat)
+	if err != nil {
+		return t
+	}
+	return t.In(now.Location()).Format(layout)
+}
+
+// RelativeTime describes t relative to now, e.g. "3h ago" or "in 2d"
+func RelativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	suffix := " ago"
+	prefix := ""
+	if d < 0 {
+		d = -d
+		suffix, prefix = "", "in "
+	}
+
+	var amount string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		amount = fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		amount = fmt.Sprintf("%dh", int(d/time.Hour))
+	case d < 30*24*time.Hour:
+		amount = fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	case d < 365*24*time.Hour:
+		amount = fmt.Sprintf("%dmo", int(d/(30*24*time.Hour)))
+	default:
+		amount = fmt.Sprintf("%dy", int(d/(365*24*time.Hour)))
+	}
+	return prefix + amount + suffix
+}
+
+// groupDigits inserts separator between groups of three digits of an
+// integer in decimal notation
+func groupDigits(digits, separator string) string {
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= 3 {
+		return sign + digits
+	}
+
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(separator)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return sign + b.String()
+}
+
+// isTimeColumn reports whether a column holds timestamps by its name: time,
+// timestamp, or a name ending in _at or _time such as created_at
+func isTimeColumn(column string) bool {
+	name := strings.ToLower(column)
+	return name == "time" || name == "timestamp" || strings.HasSuffix(name, "_at") || strings.HasSuffix(name, "_time")
+}
+
+// isIDColumn reports whether a column holds identifiers, which are not
+// grouped into thousands
+func isIDColumn(column string) bool {
+	name := strings.ToLower(column)
+	return name == "id" || name == "parent" || strings.HasSuffix(name, "_id")
+}
+
+// timestampValue returns the time of a Unix timestamp in seconds, a time
+// value or SQLite datetime text
+func timestampValue(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case int64:
+		return time.Unix(v, 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,97 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValueFormatRows(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "score", "ratio", "time", "created_at", "title"}
+	rows := [][]interface{}{
+		{int64(39012345), int64(1234567), 1234.5, int64(now.Add(-3 * time.Hour).Unix()), now.Add(-2 * 24 * time.Hour), "Show HN"},
+		{int64(2), int64(-1500), nil, nil, "2024-01-10 11:59:30", "Ask HN"},
+	}
+
+	tests := []struct {
+		name   string
+		format ValueFormat
+		want   [][]interface{}
+	}{
+		{
+			name:   "english numbers and iso dates",
+			format: ValueFormat{NumberLocale: "en", DateFormat: "iso"},
+			want: [][]interface{}{
+				{int64(39012345), "1,234,567", "1,234.5", "2024-01-10 09:00:00", "2024-01-08 12:00:00", "Show HN"},
+				{int64(2), "-1,500", nil, nil, "2024-01-10 11:59:30", "Ask HN"},
+			},
+		},
+		{
+			name:   "german numbers and relative time",
+			format: ValueFormat{NumberLocale: "de", DateFormat: "iso", RelativeTime: true},
+			want: [][]interface{}{
+				{int64(39012345), "1.234.567", "1.234,5", "3h ago", "2d ago", "Show HN"},
+				{int64(2), "-1.500", nil, nil, "just now", "Ask HN"},
+			},
+		},
+		{
+			name:   "dates only",
+			format: ValueFormat{DateFormat: "2006-01-02"},
+			want: [][]interface{}{
+				{int64(39012345), int64(1234567), 1234.5, "2024-01-10", "2024-01-08", "Show HN"},
+				{int64(2), int64(-1500), nil, nil, "2024-01-10", "Ask HN"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.format.FormatRows(columns, rows, now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FormatRows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := (ValueFormat{}).FormatRows(columns, rows, now); !reflect.DeepEqual(got, rows) {
+		t.Errorf("the zero format should keep rows, got %v", got)
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		offset time.Duration
+		want   string
+	}{
+		{-20 * time.Second, "just now"},
+		{-45 * time.Minute, "45m ago"},
+		{-10 * 24 * time.Hour, "10d ago"},
+		{-90 * 24 * time.Hour, "3mo ago"},
+		{-800 * 24 * time.Hour, "2y ago"},
+		{2 * time.Hour, "in 2h"},
+	} {
+		if got := RelativeTime(now.Add(tt.offset), now); got != tt.want {
+			t.Errorf("RelativeTime(%v) = %q, want %q", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestValueFormatSettings(t *testing.T) {
+	if err := ValidateNumberLocale("xx"); err == nil {
+		t.Error("expected an error for an unknown locale")
+	}
+	if err := ValidateNumberLocale(""); err != nil {
+		t.Errorf("an empty locale should be valid, got %v", err)
+	}
+	if layout, err := DateLayout("eu"); err != nil || layout != "02.01.2006 15:04" {
+		t.Errorf("DateLayout(eu) = %q, %v", layout, err)
+	}
+	if _, err := DateLayout("Jan 2 15:04"); err != nil {
+		t.Errorf("a Go layout should be valid, got %v", err)
+	}
+	if _, err := DateLayout("yyyy-mm-dd"); err == nil {
+		t.Error("expected an error for a layout without reference values")
+	}
+}
@@ -0,0 +1,201 @@
+package query
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSheetName is the worksheet name of a single exported result
+const DefaultSheetName = "Results"
+
+// maxSheetNameLength is the longest sheet name Excel accepts
+const maxSheetNameLength = 31
+
+// Sheet is a worksheet of an xlsx workbook: a header row of columns followed
+// by the rows
+type Sheet struct {
+	Name    string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// WriteXLSX writes sheets to a new Excel workbook at path, or uploads it to
+// an s3:// URL, readable by Excel, LibreOffice and Google Sheets. Numbers and
+// booleans keep their cell types, other values become text. Sheet names are
+// made valid and unique.
+func WriteXLSX(path string, sheets []Sheet) error {
+	if len(sheets) == 0 {
+		return fmt.Errorf("a workbook needs at least one sheet")
+	}
+
+	file, err := CreateCompressed(path, CompressionNone)
+	if err != nil {
+		return fmt.Errorf("failed to create workbook: %w", err)
+	}
+	if err := writeWorkbook(zip.NewWriter(file), sheets); err != nil {
+		file.Abort()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write workbook: %w", err)
+	}
+	return nil
+}
+
+// writeWorkbook writes the parts of an xlsx package
+func writeWorkbook(archive *zip.Writer, sheets []Sheet) error {
+	names := SheetNames(sheets)
+
+	var contentTypes, workbook, workbookRels strings.Builder
+	contentTypes.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	for i, name := range names {
+		n := i + 1
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(name), n, n)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`, len(names)+1)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.String()},
+		// Style 1 is the bold header row
+		{"xl/styles.xml", xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font/><font><b/></font></fonts>` +
+			`<fills count="1"><fill><patternFill patternType="none"/></fill></fills>` +
+			`<borders count="1"><border/></borders>` +
+			`<cellStyleXfs count="1"><xf/></cellStyleXfs>` +
+			`<cellXfs count="2"><xf/><xf fontId="1" applyFont="1"/></cellXfs></styleSheet>`},
+	}
+	for i, sheet := range sheets {
+		parts = append(parts, struct{ name, content string }{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheetXML(sheet)})
+	}
+
+	for _, part := range parts {
+		w, err := archive.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("failed to write workbook: %w", err)
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return fmt.Errorf("failed to write workbook: %w", err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write workbook: %w", err)
+	}
+	return nil
+}
+
+// worksheetXML returns a sheet with a frozen, bold header row
+func worksheetXML(sheet Sheet) string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData><row r="1">`)
+	for j, column := range sheet.Columns {
+		fmt.Fprintf(&b, `<c r="%s1" s="1" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, columnName(j), xmlEscape(column))
+	}
+	b.WriteString(`</row>`)
+
+	for i, row := range sheet.Rows {
+		r := i + 2
+		fmt.Fprintf(&b, `<row r="%d">`, r)
+		for j, value := range row {
+			if value == nil {
+				continue
+			}
+			ref := columnName(j) + strconv.Itoa(r)
+			switch v := value.(type) {
+			case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, FieldString(v))
+			case bool:
+				bit := 0
+				if v {
+					bit = 1
+				}
+				fmt.Fprintf(&b, `<c r="%s" t="b"><v>%d</v></c>`, ref, bit)
+			case time.Time:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, v.Format("2006-01-02 15:04:05"))
+			default:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, xmlEscape(FieldString(v)))
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+// SheetNames returns valid, unique names for sheets: without the characters
+// Excel forbids, at most 31 characters long, and numbered when repeated or
+// empty
+func SheetNames(sheets []Sheet) []string {
+	names := make([]string, len(sheets))
+	used := make(map[string]bool)
+	for i, sheet := range sheets {
+		base := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`[]:*?/\`, r) {
+				return '_'
+			}
+			return r
+		}, strings.TrimSpace(sheet.Name))
+		base = strings.Trim(base, "'")
+		if base == "" {
+			base = "Sheet" + strconv.Itoa(i+1)
+		}
+
+		name := truncateRunes(base, maxSheetNameLength)
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			suffix := " (" + strconv.Itoa(n) + ")"
+			name = truncateRunes(base, maxSheetNameLength-len(suffix)) + suffix
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// truncateRunes cuts s to at most n characters
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
+
+// columnName returns the spreadsheet column letters of a zero-based index:
+// A, B, ..., Z, AA, AB, ...
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// xmlEscape escapes text for XML content and attributes, replacing
+// characters XML cannot hold
+func xmlEscape(text string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
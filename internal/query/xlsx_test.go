@@ -0,0 +1,143 @@
+package query
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readXLSXParts returns the parts of a workbook by name, checking that each
+// XML part is well-formed
+func readXLSXParts(t *testing.T, path string) map[string]string {
+	t.Helper()
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("failed to open workbook: %v", err)
+	}
+	defer archive.Close()
+
+	parts := make(map[string]string)
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+		decoder := xml.NewDecoder(strings.NewReader(string(data)))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed XML: %v", file.Name, err)
+			}
+		}
+		parts[file.Name] = string(data)
+	}
+	return parts
+}
+
+func TestWriteXLSX(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xlsx")
+	sheets := []Sheet{
+		{
+			Name:    "Top stories",
+			Columns: []string{"id", "title", "score", "dead"},
+			Rows: [][]interface{}{
+				{int64(1), "Show HN: <tags> & more", 42.5, false},
+				{int64(2), []byte("bytes"), nil, true},
+			},
+		},
+		{Name: "Authors", Columns: []string{"by"}, Rows: [][]interface{}{{"pg"}}},
+	}
+	if err := WriteXLSX(path, sheets); err != nil {
+		t.Fatalf("WriteXLSX failed: %v", err)
+	}
+
+	parts := readXLSXParts(t, path)
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook is missing %s", name)
+		}
+	}
+
+	workbook := parts["xl/workbook.xml"]
+	for _, want := range []string{`<sheet name="Top stories" sheetId="1" r:id="rId1"/>`, `<sheet name="Authors" sheetId="2" r:id="rId2"/>`} {
+		if !strings.Contains(workbook, want) {
+			t.Errorf("workbook.xml missing %s", want)
+		}
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="B1" s="1" t="inlineStr"><is><t xml:space="preserve">title</t></is></c>`,
+		`<c r="A2"><v>1</v></c>`,
+		`<t xml:space="preserve">Show HN: &lt;tags&gt; &amp; more</t>`,
+		`<c r="C2"><v>42.5</v></c>`,
+		`<c r="D2" t="b"><v>0</v></c>`,
+		`<c r="D3" t="b"><v>1</v></c>`,
+		`<t xml:space="preserve">bytes</t>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet1.xml missing %s", want)
+		}
+	}
+	if strings.Contains(sheet, `r="C3"`) {
+		t.Error("NULL values should leave the cell empty")
+	}
+}
+
+func TestWriteXLSXNoSheets(t *testing.T) {
+	if err := WriteXLSX(filepath.Join(t.TempDir(), "empty.xlsx"), nil); err == nil {
+		t.Error("a workbook without sheets should fail")
+	}
+}
+
+func TestSaveResultXLSX(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.xlsx")
+	if _, err := SaveResult(path, SaveOptions{Format: OutputFormatXLSX}, []string{"n"}, [][]interface{}{{int64(7)}}); err != nil {
+		t.Fatalf("SaveResult failed: %v", err)
+	}
+	if workbook := readXLSXParts(t, path)["xl/workbook.xml"]; !strings.Contains(workbook, `name="Results"`) {
+		t.Errorf("the sheet should default to Results:\n%s", workbook)
+	}
+}
+
+func TestSheetNames(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	got := SheetNames([]Sheet{
+		{Name: "Top/Stories [2024]"},
+		{Name: "top_stories _2024_"},
+		{Name: ""},
+		{Name: long},
+		{Name: long},
+		{Name: "'quoted'"},
+	})
+	want := []string{
+		"Top_Stories _2024_",
+		"top_stories _2024_ (2)",
+		"Sheet3",
+		strings.Repeat("x", 31),
+		strings.Repeat("x", 27) + " (2)",
+		"quoted",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sheet %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for index, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(index); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", index, got, want)
+		}
+	}
+}
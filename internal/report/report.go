@@ -0,0 +1,407 @@
+// Package report generates periodic reports, such as a weekly Hacker News
+// digest, from templates with query placeholders. Reports are written to
+// files, posted to a webhook, or both.
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+// Report is a template rendered against a data source and delivered to a
+// file or webhook, on a schedule or on demand
+type Report struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Source      string     `json:"source"`             // Data source the template queries
+	Template    string     `json:"template"`           // Go template, usually Markdown with query placeholders
+	Output      string     `json:"output,omitempty"`   // File path; {name}, {date} and {time} are replaced
+	Webhook     string     `json:"webhook,omitempty"`  // URL the report is posted to as JSON
+	Schedule    string     `json:"schedule,omitempty"` // Cron expression; empty runs only on demand
+	Created     time.Time  `json:"created"`
+	LastRun     *time.Time `json:"last_run,omitempty"`
+	LastOutput  string     `json:"last_output,omitempty"` // File written by the last run
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// validName matches report names, which are also file names
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Validate checks that a report can be rendered and delivered
+func (r *Report) Validate() error {
+	if !validName.MatchString(r.Name) {
+		return fmt.Errorf("invalid report name %q (use letters, digits, '.', '_' and '-')", r.Name)
+	}
+	if r.Source == "" {
+		return fmt.Errorf("report %s needs a data source", r.Name)
+	}
+	if strings.TrimSpace(r.Template) == "" {
+		return fmt.Errorf("report %s needs a template", r.Name)
+	}
+	if r.Output == "" && r.Webhook == "" {
+		return fmt.Errorf("report %s needs an output file or a webhook", r.Name)
+	}
+	if r.Webhook != "" && !strings.HasPrefix(r.Webhook, "http://") && !strings.HasPrefix(r.Webhook, "https://") {
+		return fmt.Errorf("webhook must be an http or https URL")
+	}
+	if _, err := parseTemplate(r.Name, r.Template, Funcs(nil, time.Now())); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Dir returns the directory report definitions are kept in
+func Dir(storagePath string) string {
+	return filepath.Join(storagePath, "reports")
+}
+
+// Store keeps report definitions as one JSON file per report
+type Store struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewStore creates a store in dir, which is created on the first save
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Save validates and stores a report, replacing one with the same name
+func (s *Store) Save(r *Report) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if r.Created.IsZero() {
+		r.Created = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := os.WriteFile(s.path(r.Name), data, 0644); err != nil {
+		return fmt.Errorf("failed to save report: %w", err)
+	}
+	return nil
+}
+
+// Get returns a report by name
+func (s *Store) Get(name string) (*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("report '%s' not found", name)
+	}
+	data, err := os.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("report '%s' not found", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", name, err)
+	}
+	return &r, nil
+}
+
+// List returns every report sorted by name
+func (s *Store) List() ([]*Report, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+
+	reports := make([]*Report, 0, len(files))
+	for _, file := range files {
+		r, err := s.Get(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Name < reports[j].Name
+	})
+	return reports, nil
+}
+
+// Delete removes a report
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !validName.MatchString(name) {
+		return fmt.Errorf("report '%s' not found", name)
+	}
+	err := os.Remove(s.path(name))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("report '%s' not found", name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete report: %w", err)
+	}
+	return nil
+}
+
+// path returns the file of a report
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// QueryFunc runs a query against the data source of a report
+type QueryFunc func(query string) (datasource.QueryResult, error)
+
+// Data is the value templates are executed with
+type Data struct {
+	Name        string
+	Description string
+	Source      string
+	Now         time.Time
+}
+
+// Funcs returns the template functions of a report run at now; run is nil
+// when a template is only checked:
+//
+//	query "SQL"    rows as maps from column name to value, for range
+//	value "SQL"    the first column of the first row
+//	table "SQL"    the result as a Markdown table
+//	daysAgo N      the Unix time N days before now, for time filters
+//	date "layout"  now formatted with a Go time layout
+func Funcs(run QueryFunc, now time.Time) template.FuncMap {
+	execute := func(query string) (datasource.QueryResult, error) {
+		if run == nil {
+			return datasource.QueryResult{}, nil
+		}
+		result, err := run(query)
+		if err != nil {
+			return result, fmt.Errorf("query %q failed: %w", query, err)
+		}
+		return result, nil
+	}
+
+	return template.FuncMap{
+		"query": func(query string) ([]map[string]interface{}, error) {
+			result, err := execute(query)
+			if err != nil {
+				return nil, err
+			}
+			rows := make([]map[string]interface{}, len(result.Rows))
+			for i, row := range result.Rows {
+				rows[i] = make(map[string]interface{}, len(result.Columns))
+				for j, column := range result.Columns {
+					if j < len(row) {
+						rows[i][column] = displayValue(row[j])
+					}
+				}
+			}
+			return rows, nil
+		},
+		"value": func(query string) (interface{}, error) {
+			result, err := execute(query)
+			if err != nil || len(result.Rows) == 0 || len(result.Rows[0]) == 0 {
+				return "", err
+			}
+			return displayValue(result.Rows[0][0]), nil
+		},
+		"table": func(query string) (string, error) {
+			result, err := execute(query)
+			if err != nil {
+				return "", err
+			}
+			return markdownTable(result), nil
+		},
+		"daysAgo": func(days int) int64 {
+			return now.AddDate(0, 0, -days).Unix()
+		},
+		"date": func(layout string) string {
+			return now.Format(layout)
+		},
+	}
+}
+
+// Render executes the template of a report against its data source
+func Render(r *Report, run QueryFunc, now time.Time) (string, error) {
+	tmpl, err := parseTemplate(r.Name, r.Template, Funcs(run, now))
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	data := Data{Name: r.Name, Description: r.Description, Source: r.Source, Now: now}
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render report %s: %w", r.Name, err)
+	}
+	return out.String(), nil
+}
+
+// parseTemplate parses the template of a report
+func parseTemplate(name, text string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// OutputPath returns the file a report run at now is written to
+func OutputPath(r *Report, now time.Time) string {
+	return strings.NewReplacer(
+		"{name}", r.Name,
+		"{date}", now.Format("2006-01-02"),
+		"{time}", now.Format("150405"),
+	).Replace(r.Output)
+}
+
+// webhookPayload is posted to report webhooks; text makes it readable by
+// Slack and Mattermost incoming webhooks
+type webhookPayload struct {
+	Report      string    `json:"report"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Text        string    `json:"text"`
+}
+
+// Deliver writes a rendered report to its output file and posts it to its
+// webhook, returning the file written
+func Deliver(ctx context.Context, r *Report, content string, now time.Time, client *http.Client) (string, error) {
+	path := ""
+	if r.Output != "" {
+		path = OutputPath(r, now)
+		if dir := filepath.Dir(path); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return "", fmt.Errorf("failed to create report directory: %w", err)
+			}
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return "", fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	if r.Webhook != "" {
+		body, err := json.Marshal(webhookPayload{Report: r.Name, GeneratedAt: now, Text: content})
+		if err != nil {
+			return path, fmt.Errorf("failed to encode webhook payload: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Webhook, bytes.NewReader(body))
+		if err != nil {
+			return path, fmt.Errorf("invalid webhook: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return path, fmt.Errorf("failed to post report to webhook: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return path, fmt.Errorf("webhook returned %s", resp.Status)
+		}
+	}
+	return path, nil
+}
+
+// Run renders and delivers a stored report with the named data sources and
+// records the outcome in the store
+func Run(ctx context.Context, store *Store, name string, sources map[string]datasource.DataSource, client *http.Client) (*Report, error) {
+	r, err := store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	path, runErr := generate(ctx, r, sources, client, now)
+	r.LastRun = &now
+	r.LastOutput = path
+	r.LastError = ""
+	if runErr != nil {
+		r.LastError = runErr.Error()
+	}
+	if err := store.Save(r); err != nil {
+		return r, err
+	}
+	return r, runErr
+}
+
+// generate renders a report and delivers it
+func generate(ctx context.Context, r *Report, sources map[string]datasource.DataSource, client *http.Client, now time.Time) (string, error) {
+	ds, ok := sources[r.Source]
+	if !ok || ds == nil {
+		return "", fmt.Errorf("data source not found: %s", r.Source)
+	}
+	content, err := Render(r, func(query string) (datasource.QueryResult, error) {
+		if err := ctx.Err(); err != nil {
+			return datasource.QueryResult{}, err
+		}
+		return ds.Query(query)
+	}, now)
+	if err != nil {
+		return "", err
+	}
+	return Deliver(ctx, r, content, now, client)
+}
+
+// displayValue converts database values for display in templates
+func displayValue(value interface{}) interface{} {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
+
+// markdownTable formats a query result as a Markdown table
+func markdownTable(result datasource.QueryResult) string {
+	if len(result.Rows) == 0 {
+		return "*No results*\n"
+	}
+
+	var out strings.Builder
+	out.WriteString("| " + strings.Join(markdownCells(result.Columns), " | ") + " |\n")
+	out.WriteString("|" + strings.Repeat(" --- |", len(result.Columns)) + "\n")
+	for _, row := range result.Rows {
+		values := make([]string, len(row))
+		for i, value := range row {
+			switch v := displayValue(value).(type) {
+			case nil:
+			case float64:
+				values[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				values[i] = fmt.Sprint(v)
+			}
+		}
+		out.WriteString("| " + strings.Join(markdownCells(values), " | ") + " |\n")
+	}
+	return out.String()
+}
+
+// markdownCells escapes values for use in a Markdown table row
+func markdownCells(values []string) []string {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		value = strings.ReplaceAll(value, "|", `\|`)
+		cells[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return cells
+}
@@ -0,0 +1,144 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	store := NewStore(Dir(t.TempDir()))
+
+	reports, err := store.List()
+	require.NoError(t, err)
+	assert.Empty(t, reports)
+
+	digest := &Report{Name: "weekly-digest", Source: "hackernews", Template: "# Digest", Output: "digest.md", Schedule: "0 8 * * 1"}
+	require.NoError(t, store.Save(digest))
+	require.NoError(t, store.Save(&Report{Name: "alerts", Source: "hackernews", Template: "x", Webhook: "https://example.com/hook"}))
+
+	loaded, err := store.Get("weekly-digest")
+	require.NoError(t, err)
+	assert.Equal(t, "0 8 * * 1", loaded.Schedule)
+	assert.False(t, loaded.Created.IsZero())
+
+	reports, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, "alerts", reports[0].Name)
+
+	require.NoError(t, store.Delete("alerts"))
+	_, err = store.Get("alerts")
+	assert.Error(t, err)
+	assert.Error(t, store.Delete("alerts"))
+}
+
+func TestValidate(t *testing.T) {
+	valid := Report{Name: "digest", Source: "hackernews", Template: "{{table \"SELECT 1\"}}", Output: "out.md"}
+	assert.NoError(t, valid.Validate())
+
+	tests := map[string]func(r *Report){
+		"name":     func(r *Report) { r.Name = "../digest" },
+		"source":   func(r *Report) { r.Source = "" },
+		"template": func(r *Report) { r.Template = " " },
+		"syntax":   func(r *Report) { r.Template = "{{table" },
+		"function": func(r *Report) { r.Template = "{{unknown}}" },
+		"delivery": func(r *Report) { r.Output = "" },
+		"webhook":  func(r *Report) { r.Webhook = "ftp://example.com" },
+	}
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := valid
+			change(&r)
+			assert.Error(t, r.Validate())
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	now := time.Date(2024, 3, 15, 8, 0, 0, 0, time.UTC)
+	var queries []string
+	run := func(query string) (datasource.QueryResult, error) {
+		queries = append(queries, query)
+		return datasource.QueryResult{
+			Columns: []string{"title", "score"},
+			Rows:    [][]interface{}{{[]byte("Show HN: a | b"), int64(120)}, {"Ask HN", nil}},
+		}, nil
+	}
+
+	r := &Report{
+		Name:   "digest",
+		Source: "hackernews",
+		Template: `# {{.Name}} for {{date "2006-01-02"}}
+Top: {{value "SELECT title"}}
+{{range query "SELECT title, score"}}- {{.title}}
+{{end}}{{table "SELECT title, score"}}since {{daysAgo 7}}`,
+	}
+	rendered, err := Render(r, run, now)
+	require.NoError(t, err)
+
+	assert.Contains(t, rendered, "# digest for 2024-03-15")
+	assert.Contains(t, rendered, "Top: Show HN: a | b")
+	assert.Contains(t, rendered, "- Show HN: a | b\n- Ask HN\n")
+	assert.Contains(t, rendered, "| title | score |\n| --- | --- |\n| Show HN: a \\| b | 120 |\n| Ask HN |  |\n")
+	assert.Contains(t, rendered, "since 1709884800")
+	assert.Len(t, queries, 3)
+}
+
+func TestRenderQueryError(t *testing.T) {
+	r := &Report{Name: "digest", Template: `{{table "SELECT nope"}}`}
+	_, err := Render(r, func(string) (datasource.QueryResult, error) {
+		return datasource.QueryResult{}, assert.AnError
+	}, time.Now())
+	assert.ErrorContains(t, err, "SELECT nope")
+}
+
+func TestOutputPath(t *testing.T) {
+	now := time.Date(2024, 3, 15, 8, 30, 5, 0, time.UTC)
+	r := &Report{Name: "digest", Output: "reports/{name}-{date}-{time}.md"}
+	assert.Equal(t, "reports/digest-2024-03-15-083005.md", OutputPath(r, now))
+}
+
+func TestDeliver(t *testing.T) {
+	var payload webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 3, 15, 8, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	r := &Report{Name: "digest", Output: filepath.Join(dir, "out", "{name}-{date}.md"), Webhook: server.URL}
+
+	path, err := Deliver(context.Background(), r, "# Digest\n", now, server.Client())
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "out", "digest-2024-03-15.md"), path)
+
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# Digest\n", string(written))
+	assert.Equal(t, "digest", payload.Report)
+	assert.Equal(t, "# Digest\n", payload.Text)
+}
+
+func TestDeliverWebhookFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	r := &Report{Name: "digest", Webhook: server.URL}
+	_, err := Deliver(context.Background(), r, "text", time.Now(), server.Client())
+	assert.ErrorContains(t, err, "502")
+}
@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"sync"
+)
+
+// Credentials gives a data source client access to the secrets of its source
+type Credentials interface {
+	// Credential returns the secret <source>.<key>, or an error wrapping
+	// ErrNotFound when it is not set
+	Credential(key string) (string, error)
+}
+
+// sourceCredentials reads the secrets of one source from a store
+type sourceCredentials struct {
+	store  *Store
+	source string
+}
+
+// Credential returns the secret source.key
+func (c *sourceCredentials) Credential(key string) (string, error) {
+	return c.store.Get(c.source + "." + key)
+}
+
+// ForSource returns the credentials of source kept in the store
+func (s *Store) ForSource(source string) Credentials {
+	return &sourceCredentials{store: s, source: source}
+}
+
+var (
+	defaultMu    sync.RWMutex
+	defaultStore = New("")
+)
+
+// SetDefault sets the store used by ForSource
+func SetDefault(store *Store) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultStore = store
+}
+
+// Default returns the store used by ForSource; until SetDefault is called it
+// only sees secrets set in the environment
+func Default() *Store {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultStore
+}
+
+// ForSource returns the credentials of source in the default store, for use
+// by data source clients
+func ForSource(source string) Credentials {
+	return Default().ForSource(source)
+}
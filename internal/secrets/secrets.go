@@ -0,0 +1,404 @@
+// Package secrets stores API keys and other credentials of data sources in
+// an encrypted file, outside the config file and the logs.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+const (
+	// FileName is the encrypted secrets file in the config directory
+	FileName = "secrets.enc"
+	// KeyFileName holds the random key of the secrets file unless a
+	// passphrase is used
+	KeyFileName = "secrets.key"
+	// PassphraseEnv names the environment variable with the passphrase that
+	// protects the secrets file instead of the key file
+	PassphraseEnv = "PUBDATAHUB_SECRETS_PASSPHRASE"
+	// EnvPrefix starts environment variables that override stored secrets,
+	// e.g. PUBDATAHUB_SECRET_REDDIT_CLIENT_ID for reddit.client_id
+	EnvPrefix = "PUBDATAHUB_SECRET_"
+)
+
+const (
+	kdfKeyFile    = "keyfile"
+	kdfPassphrase = "passphrase"
+	keySize       = 32
+)
+
+// passphraseIterations is the PBKDF2 work factor for passphrase keys
+var passphraseIterations = 600000
+
+// additionalData binds the ciphertext to this file format
+var additionalData = []byte("pubdatahub-secrets-v1")
+
+// sealedData binds the ciphertext of data sealed for other files to Seal
+var sealedData = []byte("pubdatahub-sealed-v1")
+
+var (
+	// ErrNotFound is returned for secrets that are not set
+	ErrNotFound = errors.New("secret not found")
+	// ErrPassphraseRequired is wrapped by errors for data protected by a
+	// passphrase when none is set
+	ErrPassphraseRequired = errors.New("protected by a passphrase")
+)
+
+var (
+	passphraseMu      sync.RWMutex
+	sessionPassphrase string
+)
+
+// SetPassphrase sets the passphrase for the rest of the process, as if
+// PassphraseEnv were set, such as one the user entered at a prompt; ""
+// goes back to PassphraseEnv
+func SetPassphrase(passphrase string) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	sessionPassphrase = passphrase
+}
+
+// passphrase returns the passphrase set with SetPassphrase or in PassphraseEnv
+func passphrase() string {
+	passphraseMu.RLock()
+	defer passphraseMu.RUnlock()
+	if sessionPassphrase != "" {
+		return sessionPassphrase
+	}
+	return os.Getenv(PassphraseEnv)
+}
+
+// namePattern matches <source>.<key> names such as reddit.client_id
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*\.[a-z0-9][a-z0-9_.-]*$`)
+
+// envelope is the on-disk format of the secrets file
+type envelope struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt,omitempty"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Store reads and writes the secrets file in a directory. A store without a
+// directory only sees secrets set in the environment.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// New returns the store of the secrets file in dir
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// ValidateName checks that name has the form <source>.<key>
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q (expected <source>.<key>, e.g. reddit.client_id)", name)
+	}
+	return nil
+}
+
+// EnvName returns the environment variable that overrides the secret name
+func EnvName(name string) string {
+	replacer := strings.NewReplacer(".", "_", "-", "_")
+	return EnvPrefix + strings.ToUpper(replacer.Replace(name))
+}
+
+// Get returns the secret name, preferring the environment over the file
+func (s *Store) Get(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	if value := os.Getenv(EnvName(name)); value != "" {
+		log.RedactSecret(value)
+		return value, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := values[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	log.RedactSecret(value)
+	return value, nil
+}
+
+// Set stores the secret name
+func (s *Store) Set(name, value string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if value == "" {
+		return fmt.Errorf("secret %s cannot be empty", name)
+	}
+	log.RedactSecret(value)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.load()
+	if err != nil {
+		return err
+	}
+	values[name] = value
+	return s.save(values)
+}
+
+// Delete removes the secret name from the file
+func (s *Store) Delete(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := values[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(values, name)
+	return s.save(values)
+}
+
+// Names returns the names of the stored secrets in order
+func (s *Store) Names() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Path returns the path of the secrets file
+func (s *Store) Path() string {
+	return filepath.Join(s.dir, FileName)
+}
+
+// load decrypts the secrets file; callers hold mu
+func (s *Store) load() (map[string]string, error) {
+	values := make(map[string]string)
+	if s.dir == "" {
+		return values, nil
+	}
+
+	data, err := os.ReadFile(s.Path())
+	if os.IsNotExist(err) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.Path(), err)
+	}
+	plain, err := s.decrypt(&env, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets: %w", err)
+	}
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted secrets: %w", err)
+	}
+	return values, nil
+}
+
+// save encrypts values into the secrets file, readable by the owner only;
+// callers hold mu
+func (s *Store) save(values map[string]string) error {
+	if s.dir == "" {
+		return fmt.Errorf("no secrets file configured; set secrets with %s<NAME> environment variables", EnvPrefix)
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create secrets directory: %w", err)
+	}
+
+	plain, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+	env, err := s.encrypt(plain, additionalData)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets file: %w", err)
+	}
+	tmpPath := s.Path() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.Path()); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+	return nil
+}
+
+// Seal encrypts plain with the key of the secrets file, so other files, such
+// as encrypted workspaces, can be kept encrypted at rest the same way
+func (s *Store) Seal(plain []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir != "" {
+		if err := os.MkdirAll(s.dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create secrets directory: %w", err)
+		}
+	}
+	env, err := s.encrypt(plain, sealedData)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal sealed data: %w", err)
+	}
+	return data, nil
+}
+
+// Open decrypts data encrypted by Seal
+func (s *Store) Open(data []byte) ([]byte, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse sealed data: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.decrypt(&env, sealedData)
+}
+
+// encrypt encrypts plain with a key from the passphrase, when one is set,
+// or else from the key file, creating it if needed; callers hold mu
+func (s *Store) encrypt(plain, aad []byte) (*envelope, error) {
+	env := &envelope{Version: 1, KDF: kdfKeyFile}
+	if passphrase() != "" {
+		env.KDF = kdfPassphrase
+		env.Salt = make([]byte, 16)
+		if _, err := rand.Read(env.Salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %w", err)
+		}
+	}
+	key, err := s.key(env.KDF, env.Salt, true)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	env.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	env.Data = gcm.Seal(nil, env.Nonce, plain, aad)
+	return env, nil
+}
+
+// decrypt decrypts env with the key it was encrypted with; callers hold mu
+func (s *Store) decrypt(env *envelope, aad []byte) ([]byte, error) {
+	key, err := s.key(env.KDF, env.Salt, false)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, env.Nonce, env.Data, aad)
+	if err != nil {
+		if env.KDF == kdfPassphrase {
+			return nil, fmt.Errorf("wrong %s", PassphraseEnv)
+		}
+		return nil, fmt.Errorf("%s does not match the encrypted data", KeyFileName)
+	}
+	return plain, nil
+}
+
+// key returns the encryption key for kdf, creating the key file when create
+// is set and it does not exist yet
+func (s *Store) key(kdf string, salt []byte, create bool) ([]byte, error) {
+	switch kdf {
+	case kdfPassphrase:
+		passphrase := passphrase()
+		if passphrase == "" {
+			return nil, fmt.Errorf("%w; set %s", ErrPassphraseRequired, PassphraseEnv)
+		}
+		key, err := pbkdf2.Key(sha256.New, passphrase, salt, passphraseIterations, keySize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		return key, nil
+	case kdfKeyFile:
+		if s.dir == "" {
+			return nil, fmt.Errorf("no key file configured; set %s", PassphraseEnv)
+		}
+		path := filepath.Join(s.dir, KeyFileName)
+		key, err := os.ReadFile(path)
+		if os.IsNotExist(err) && create {
+			key = make([]byte, keySize)
+			if _, err := rand.Read(key); err != nil {
+				return nil, fmt.Errorf("failed to generate key: %w", err)
+			}
+			if err := os.WriteFile(path, key, 0600); err != nil {
+				return nil, fmt.Errorf("failed to write key file: %w", err)
+			}
+			return key, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key file %s is corrupt", path)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets key derivation %q", kdf)
+	}
+}
+
+// newGCM returns an AES-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return gcm, nil
+}
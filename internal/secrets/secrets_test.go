@@ -0,0 +1,147 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_SetGetDelete(t *testing.T) {
+	dir := t.TempDir()
+	store := New(dir)
+
+	_, err := store.Get("reddit.client_id")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	require.NoError(t, store.Set("reddit.client_id", "abc123"))
+	require.NoError(t, store.Set("reddit.client_secret", "very-secret"))
+
+	// A new store reads what the first one wrote
+	value, err := New(dir).Get("reddit.client_id")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", value)
+
+	names, err := store.Names()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reddit.client_id", "reddit.client_secret"}, names)
+
+	// Values are not stored in plain text, and only the owner can read them
+	data, err := os.ReadFile(store.Path())
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "very-secret")
+	for _, file := range []string{FileName, KeyFileName} {
+		info, err := os.Stat(filepath.Join(dir, file))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), file)
+	}
+
+	require.NoError(t, store.Delete("reddit.client_id"))
+	_, err = store.Get("reddit.client_id")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.True(t, errors.Is(store.Delete("reddit.client_id"), ErrNotFound))
+}
+
+func TestStore_Validation(t *testing.T) {
+	store := New(t.TempDir())
+
+	for _, name := range []string{"", "reddit", "Reddit.key", ".key", "reddit."} {
+		assert.Error(t, store.Set(name, "value"), name)
+	}
+	assert.Error(t, store.Set("reddit.client_id", ""))
+	assert.NoError(t, ValidateName("twitter.bearer-token"))
+}
+
+func TestStore_EnvironmentOverride(t *testing.T) {
+	store := New(t.TempDir())
+	require.NoError(t, store.Set("reddit.client_id", "from-file"))
+
+	assert.Equal(t, "PUBDATAHUB_SECRET_REDDIT_CLIENT_ID", EnvName("reddit.client_id"))
+	t.Setenv(EnvName("reddit.client_id"), "from-env")
+
+	value, err := store.ForSource("reddit").Credential("client_id")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", value)
+
+	// Without a directory only the environment is used
+	value, err = New("").Get("reddit.client_id")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", value)
+	assert.Error(t, New("").Set("reddit.client_id", "value"))
+}
+
+func TestStore_Passphrase(t *testing.T) {
+	defer func(iterations int) { passphraseIterations = iterations }(passphraseIterations)
+	passphraseIterations = 1000
+
+	dir := t.TempDir()
+	t.Setenv(PassphraseEnv, "correct horse")
+	require.NoError(t, New(dir).Set("twitter.token", "tok-1234"))
+
+	// No key file is needed with a passphrase
+	_, err := os.Stat(filepath.Join(dir, KeyFileName))
+	assert.True(t, os.IsNotExist(err))
+
+	value, err := New(dir).Get("twitter.token")
+	require.NoError(t, err)
+	assert.Equal(t, "tok-1234", value)
+
+	t.Setenv(PassphraseEnv, "wrong")
+	_, err = New(dir).Get("twitter.token")
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), PassphraseEnv))
+
+	t.Setenv(PassphraseEnv, "")
+	_, err = New(dir).Get("twitter.token")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "passphrase")
+}
+
+func TestDefaultStore(t *testing.T) {
+	defer SetDefault(Default())
+
+	store := New(t.TempDir())
+	require.NoError(t, store.Set("reddit.client_id", "abc123"))
+	SetDefault(store)
+
+	value, err := ForSource("reddit").Credential("client_id")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", value)
+
+	_, err = ForSource("twitter").Credential("client_id")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
+
+func TestStore_SealAndOpen(t *testing.T) {
+	defer func(iterations int) { passphraseIterations = iterations }(passphraseIterations)
+	passphraseIterations = 1000
+	store := New(t.TempDir())
+
+	sealed, err := store.Seal([]byte(`{"query":"SELECT 1"}`))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "SELECT")
+	plain, err := store.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"query":"SELECT 1"}`, string(plain))
+
+	// Sealed data cannot pass for the secrets file
+	require.NoError(t, os.WriteFile(store.Path(), sealed, 0600))
+	_, err = store.Names()
+	assert.Error(t, err)
+
+	// A passphrase entered in the session seals like PassphraseEnv
+	SetPassphrase("correct horse")
+	sealed, err = store.Seal([]byte("notes"))
+	SetPassphrase("")
+	require.NoError(t, err)
+	_, err = store.Open(sealed)
+	assert.True(t, errors.Is(err, ErrPassphraseRequired))
+	t.Setenv(PassphraseEnv, "correct horse")
+	plain, err = store.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "notes", string(plain))
+}
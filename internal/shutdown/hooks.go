@@ -22,6 +22,12 @@ type JobManagerInterface interface {
 	GetRunningJobs() []string
 }
 
+// JobDrainer is implemented by job managers that can let running jobs finish
+// or checkpoint before they are paused
+type JobDrainer interface {
+	Drain(ctx context.Context) error
+}
+
 // NewJobManagerShutdownHook creates a new job manager shutdown hook
 func NewJobManagerShutdownHook(jobManager JobManagerInterface, timeout time.Duration) *JobManagerShutdownHook {
 	if timeout == 0 {
@@ -53,6 +59,14 @@ func (h *JobManagerShutdownHook) Timeout() time.Duration {
 func (h *JobManagerShutdownHook) Shutdown(ctx context.Context) error {
 	log.Logger.Info("Shutting down job manager...")
 
+	// Step 0: Give running jobs a chance to finish or checkpoint
+	if drainer, ok := h.jobManager.(JobDrainer); ok {
+		log.Logger.Info("Draining running jobs...")
+		if err := drainer.Drain(ctx); err != nil {
+			log.Logger.Warnf("Failed to drain jobs: %v", err)
+		}
+	}
+
 	// Step 1: Pause all running jobs
 	log.Logger.Info("Pausing all running jobs...")
 	if err := h.jobManager.PauseAllJobs(); err != nil {
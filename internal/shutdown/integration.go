@@ -384,8 +384,9 @@ func ExampleIntegration() {
 	// database := db.NewDatabase(...)
 	// etc.
 
-	// Register shutdown hooks
-	// shutdown.RegisterShutdownHooks(jobManager, database, workerPool, configManager)
+	// Register shutdown hooks; the job manager drains its worker pool before
+	// pausing, so SIGTERM leaves interrupted jobs paused and resumable
+	// shutdown.RegisterShutdownHooks(jobManager, database, jobManager.WorkerPool(), configManager)
 
 	// Register recovery handlers
 	// shutdown.RegisterRecoveryHandlers(jobManager, database, configManager, sessionManager)
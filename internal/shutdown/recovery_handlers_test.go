@@ -0,0 +1,143 @@
+package shutdown
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeBackup writes raw component files into a named backup directory
+func writeBackup(t *testing.T, storagePath, backupName string, files map[string]string) {
+	t.Helper()
+
+	backupDir := filepath.Join(storagePath, "state", "backups", backupName)
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		t.Fatalf("Failed to create backup dir: %v", err)
+	}
+	for component, content := range files {
+		if err := os.WriteFile(filepath.Join(backupDir, component+".json"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write backup file: %v", err)
+		}
+	}
+}
+
+// corruptState overwrites a component's state file with invalid JSON
+func corruptState(t *testing.T, storagePath, component string) {
+	t.Helper()
+
+	path := filepath.Join(storagePath, "state", component+".json")
+	if err := os.WriteFile(path, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt state file: %v", err)
+	}
+}
+
+func TestStateManager_ListBackups(t *testing.T) {
+	tempDir := t.TempDir()
+	manager, err := NewStateManager(tempDir, 5)
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+
+	writeBackup(t, tempDir, "20240101_100000", map[string]string{"jobs": "{}"})
+	writeBackup(t, tempDir, "20240103_100000", map[string]string{"jobs": "{}"})
+	writeBackup(t, tempDir, "20240102_100000", map[string]string{"jobs": "{}"})
+
+	backups, err := manager.ListBackups()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+
+	expected := []string{"20240103_100000", "20240102_100000", "20240101_100000"}
+	if len(backups) != len(expected) {
+		t.Fatalf("Expected %d backups, got %d", len(expected), len(backups))
+	}
+	for i := range expected {
+		if backups[i] != expected[i] {
+			t.Errorf("Expected backup %d to be %s, got %s", i, expected[i], backups[i])
+		}
+	}
+}
+
+func TestStateManagerRecoveryHandler_RestoresNewestValidBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	manager, err := NewStateManager(tempDir, 5)
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+
+	if err := manager.SaveState("jobs", map[string]interface{}{"version": "current"}); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+	if err := manager.SaveState("session", map[string]interface{}{"user": "current"}); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	// Oldest backup is valid, the middle one is missing the component and the
+	// newest one holds a corrupted copy
+	writeBackup(t, tempDir, "20240101_100000", map[string]string{"jobs": `{"version": "oldest"}`})
+	writeBackup(t, tempDir, "20240102_100000", map[string]string{"jobs": `{"version": "middle"}`})
+	writeBackup(t, tempDir, "20240103_100000", map[string]string{"session": `{"user": "backup"}`})
+	writeBackup(t, tempDir, "20240104_100000", map[string]string{"jobs": `{"version": `})
+
+	corruptState(t, tempDir, "jobs")
+
+	handler := NewStateManagerRecoveryHandler(manager, 0)
+	if err := handler.Recover(context.Background(), manager); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	var jobs map[string]interface{}
+	if err := manager.LoadState("jobs", &jobs); err != nil {
+		t.Fatalf("Jobs state should be restored: %v", err)
+	}
+	if jobs["version"] != "middle" {
+		t.Errorf("Expected jobs restored from newest valid backup, got %v", jobs["version"])
+	}
+
+	// Only the corrupted component is restored
+	var session map[string]interface{}
+	if err := manager.LoadState("session", &session); err != nil {
+		t.Fatalf("Failed to load session state: %v", err)
+	}
+	if session["user"] != "current" {
+		t.Errorf("Session state should be untouched, got %v", session["user"])
+	}
+}
+
+func TestStateManagerRecoveryHandler_ClearsStateWithoutValidBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	manager, err := NewStateManager(tempDir, 5)
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+
+	if err := manager.SaveState("jobs", map[string]interface{}{"version": "current"}); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+	writeBackup(t, tempDir, "20240101_100000", map[string]string{"jobs": "corrupted"})
+	corruptState(t, tempDir, "jobs")
+
+	handler := NewStateManagerRecoveryHandler(manager, 0)
+	if err := handler.tryRestoreFromBackup(manager, "jobs"); err == nil {
+		t.Error("Expected restore to fail without a valid backup")
+	}
+
+	// A corrupted backup must not overwrite the current file
+	data, err := os.ReadFile(filepath.Join(tempDir, "state", "jobs.json"))
+	if err != nil {
+		t.Fatalf("Failed to read state file: %v", err)
+	}
+	if string(data) != "{not json" {
+		t.Errorf("State file should be unchanged, got %q", string(data))
+	}
+
+	if err := handler.Recover(context.Background(), manager); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	for _, component := range manager.ListStates() {
+		if component == "jobs" {
+			t.Error("Corrupted jobs state should be cleared")
+		}
+	}
+}
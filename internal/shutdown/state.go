@@ -6,6 +6,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/brainless/PubDataHub/internal/log"
@@ -19,6 +20,8 @@ type StatePersistence interface {
 	ListStates() []string
 	BackupState() error
 	RestoreFromBackup(backupName string) error
+	ListBackups() ([]string, error)
+	RestoreComponentFromBackup(backupName, component string) error
 }
 
 // StateManager implements StatePersistence
@@ -243,6 +246,57 @@ func (sm *StateManager) RestoreFromBackup(backupName string) error {
 	return nil
 }
 
+// ListBackups returns the names of available backups, newest first
+func (sm *StateManager) ListBackups() ([]string, error) {
+	entries, err := os.ReadDir(sm.backupPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			backups = append(backups, entry.Name())
+		}
+	}
+
+	// Backup names are YYYYMMDD_HHMMSS timestamps, so lexicographic order is chronological
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// RestoreComponentFromBackup restores a single component's state from a backup,
+// leaving the current state untouched if the backed up copy is not valid JSON
+func (sm *StateManager) RestoreComponentFromBackup(backupName, component string) error {
+	srcPath := filepath.Join(sm.backupPath, backupName, component+".json")
+
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("backup %s does not contain component %s", backupName, component)
+		}
+		return fmt.Errorf("failed to read backup file: %w", err)
+	}
+
+	var temp interface{}
+	if err := json.Unmarshal(data, &temp); err != nil {
+		return fmt.Errorf("invalid JSON in backup %s for component %s: %w", backupName, component, err)
+	}
+
+	dstPath := filepath.Join(sm.statePath, component+".json")
+	tempPath := dstPath + ".tmp"
+	if err := os.WriteFile(tempPath, data, sm.permissions); err != nil {
+		return fmt.Errorf("failed to write temporary state file: %w", err)
+	}
+	if err := os.Rename(tempPath, dstPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to commit restored state file: %w", err)
+	}
+
+	log.Logger.Infof("Restored %s from backup: %s", component, backupName)
+	return nil
+}
+
 // SaveApplicationState saves the complete application state
 func (sm *StateManager) SaveApplicationState(appState ApplicationState) error {
 	return sm.SaveState("application", appState)
@@ -0,0 +1,112 @@
+//go:build linux
+// +build linux
+
+package sshserver
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// terminalSession is a command running on a pseudo-terminal
+type terminalSession struct {
+	pty  *os.File
+	cmd  *exec.Cmd
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+// startTerminalSession starts cmd as a session leader with a new
+// pseudo-terminal of the requested size as its controlling terminal
+func startTerminalSession(cmd *exec.Cmd, terminal *ptyRequest) (*terminalSession, error) {
+	ptmx, pts, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer pts.Close()
+
+	if err := setWindowSize(ptmx, terminal.Columns, terminal.Rows); err != nil {
+		ptmx.Close()
+		return nil, err
+	}
+
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = pts, pts, pts
+	if terminal.Term != "" {
+		cmd.Env = append(cmd.Environ(), "TERM="+terminal.Term)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := cmd.Start(); err != nil {
+		ptmx.Close()
+		return nil, fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+
+	session := &terminalSession{pty: ptmx, cmd: cmd, done: make(chan struct{})}
+	go func() {
+		session.err = cmd.Wait()
+		close(session.done)
+	}()
+	return session, nil
+}
+
+// openPTY opens a new pseudo-terminal pair
+func openPTY() (ptmx, pts *os.File, err error) {
+	ptmx, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+	if err := unix.IoctlSetPointerInt(int(ptmx.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		ptmx.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pseudo-terminal: %w", err)
+	}
+	number, err := unix.IoctlGetInt(int(ptmx.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, fmt.Errorf("failed to get pseudo-terminal number: %w", err)
+	}
+	pts, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+	return ptmx, pts, nil
+}
+
+// setWindowSize sets the size of the terminal in characters
+func setWindowSize(pty *os.File, columns, rows uint32) error {
+	size := &unix.Winsize{Col: uint16(columns), Row: uint16(rows)}
+	if err := unix.IoctlSetWinsize(int(pty.Fd()), unix.TIOCSWINSZ, size); err != nil {
+		return fmt.Errorf("failed to set terminal size: %w", err)
+	}
+	return nil
+}
+
+// resize applies a window change of the client; the kernel notifies the
+// command with SIGWINCH
+func (t *terminalSession) resize(columns, rows uint32) {
+	setWindowSize(t.pty, columns, rows)
+}
+
+// wait waits for the command to exit
+func (t *terminalSession) wait() error {
+	<-t.done
+	return t.err
+}
+
+// close hangs up the terminal and stops the command if it still runs
+func (t *terminalSession) close() {
+	t.once.Do(func() {
+		select {
+		case <-t.done:
+		default:
+			// The command shuts down on SIGTERM like on Ctrl+C
+			t.cmd.Process.Signal(syscall.SIGTERM)
+		}
+		t.pty.Close()
+	})
+}
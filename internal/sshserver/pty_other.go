@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+package sshserver
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// terminalSession is a command running on a pseudo-terminal
+type terminalSession struct {
+	pty *os.File
+}
+
+// startTerminalSession is only implemented on Linux
+func startTerminalSession(cmd *exec.Cmd, terminal *ptyRequest) (*terminalSession, error) {
+	return nil, fmt.Errorf("SSH sessions are not supported on %s", runtime.GOOS)
+}
+
+func (t *terminalSession) resize(columns, rows uint32) {}
+
+func (t *terminalSession) wait() error { return nil }
+
+func (t *terminalSession) close() {}
@@ -0,0 +1,292 @@
+// Package sshserver serves the interactive shell over SSH, so a PubDataHub
+// instance on another machine can be used with the same commands,
+// completions and status bar as a local terminal.
+package sshserver
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/brainless/PubDataHub/internal/audit"
+	"github.com/brainless/PubDataHub/internal/log"
+	"golang.org/x/crypto/ssh"
+)
+
+// HostKeyFile is the generated host key in the config directory
+const HostKeyFile = "ssh_host_ed25519_key"
+
+// Config configures the SSH server
+type Config struct {
+	// HostKeyPath is the server's private key; it is generated if missing
+	HostKeyPath string
+	// AuthorizedKeysPath lists the public keys allowed to log in
+	AuthorizedKeysPath string
+	// Command returns the process that runs for each session, attached to
+	// the session's terminal
+	Command func() *exec.Cmd
+}
+
+// Server accepts SSH connections and runs a shell for each session
+type Server struct {
+	config    Config
+	sshConfig *ssh.ServerConfig
+
+	mu       sync.Mutex
+	listener net.Listener
+	closed   bool
+}
+
+// New creates a server that accepts the keys in config.AuthorizedKeysPath
+func New(config Config) (*Server, error) {
+	if config.Command == nil {
+		return nil, fmt.Errorf("no session command configured")
+	}
+
+	authorized, err := LoadAuthorizedKeys(config.AuthorizedKeysPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(authorized) == 0 {
+		return nil, fmt.Errorf("no public keys in %s; add the keys allowed to log in", config.AuthorizedKeysPath)
+	}
+
+	hostKey, err := LoadOrCreateHostKey(config.HostKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	sshConfig := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			fingerprint := ssh.FingerprintSHA256(key)
+			if !authorized[fingerprint] {
+				return nil, fmt.Errorf("unknown public key %s", fingerprint)
+			}
+			return &ssh.Permissions{Extensions: map[string]string{"fingerprint": fingerprint}}, nil
+		},
+	}
+	sshConfig.AddHostKey(hostKey)
+
+	return &Server{config: config, sshConfig: sshConfig}, nil
+}
+
+// LoadAuthorizedKeys reads an authorized_keys file and returns the SHA256
+// fingerprints of its keys
+func LoadAuthorizedKeys(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authorized keys: %w", err)
+	}
+
+	keys := make(map[string]bool)
+	for len(bytes.TrimSpace(data)) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		keys[ssh.FingerprintSHA256(key)] = true
+		data = rest
+	}
+	return keys, nil
+}
+
+// LoadOrCreateHostKey reads the host key at path, generating an ed25519 key
+// readable by the owner only if it does not exist
+func LoadOrCreateHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host key %s: %w", path, err)
+		}
+		return signer, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read host key: %w", err)
+	}
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate host key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "pubdatahub")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode host key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create host key directory: %w", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write host key: %w", err)
+	}
+	log.Logger.Infof("Generated SSH host key %s", path)
+	return ssh.NewSignerFromKey(private)
+}
+
+// Serve accepts connections on listener until Close is called
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return fmt.Errorf("failed to accept SSH connection: %w", err)
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// Close stops accepting connections; running sessions continue
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// handleConn runs the SSH handshake and the sessions of one connection
+func (s *Server) handleConn(conn net.Conn) {
+	logger := log.ForComponent("ssh")
+
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.sshConfig)
+	if err != nil {
+		logger.Warnf("SSH handshake from %s failed: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	defer serverConn.Close()
+	logger.Infof("SSH login by %s from %s with key %s", serverConn.User(), serverConn.RemoteAddr(),
+		serverConn.Permissions.Extensions["fingerprint"])
+
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			logger.Warnf("Failed to accept SSH session: %v", err)
+			continue
+		}
+		go s.handleSession(channel, channelRequests, serverConn.User())
+	}
+	logger.Infof("SSH connection from %s closed", serverConn.RemoteAddr())
+}
+
+// ptyRequest is the payload of a "pty-req" request (RFC 4254 section 6.2)
+type ptyRequest struct {
+	Term    string
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+	Modes   string
+}
+
+// windowChange is the payload of a "window-change" request
+type windowChange struct {
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+}
+
+// handleSession waits for a terminal and a shell request, then runs the
+// session command on the terminal until either side ends. Queries in the
+// session are audited as run by the SSH user.
+func (s *Server) handleSession(channel ssh.Channel, requests <-chan *ssh.Request, user string) {
+	defer channel.Close()
+	logger := log.ForComponent("ssh")
+
+	var terminal *ptyRequest
+	var session *terminalSession
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			var request ptyRequest
+			if err := ssh.Unmarshal(req.Payload, &request); err != nil || session != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			// Clients without a local terminal report no size
+			if request.Columns == 0 || request.Rows == 0 {
+				request.Columns, request.Rows = 80, 24
+			}
+			terminal = &request
+			req.Reply(true, nil)
+		case "window-change":
+			var change windowChange
+			if err := ssh.Unmarshal(req.Payload, &change); err == nil && session != nil {
+				session.resize(change.Columns, change.Rows)
+			}
+		case "shell":
+			if terminal == nil || session != nil {
+				if terminal == nil {
+					fmt.Fprint(channel.Stderr(), "PubDataHub needs a terminal; connect with ssh -t\r\n")
+				}
+				req.Reply(false, nil)
+				continue
+			}
+			cmd := s.config.Command()
+			cmd.Env = append(cmd.Environ(), audit.UserEnv+"=ssh:"+user)
+			var err error
+			session, err = startTerminalSession(cmd, terminal)
+			if err != nil {
+				logger.Errorf("Failed to start SSH shell: %v", err)
+				fmt.Fprintf(channel.Stderr(), "Failed to start shell: %v\r\n", err)
+				req.Reply(false, nil)
+				return
+			}
+			req.Reply(true, nil)
+			go s.runSession(channel, session)
+		default:
+			// exec, subsystem, env and x11 requests are not supported
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+	if session != nil {
+		session.close()
+	}
+}
+
+// runSession copies data between the channel and the terminal and reports
+// the exit status when the command ends
+func (s *Server) runSession(channel ssh.Channel, session *terminalSession) {
+	go io.Copy(session.pty, channel)
+	io.Copy(channel, session.pty)
+
+	status := uint32(0)
+	if err := session.wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			status = uint32(exitErr.ExitCode())
+		} else {
+			status = 1
+		}
+	}
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+	channel.Close()
+}
@@ -0,0 +1,144 @@
+package sshserver
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// newClientKey creates a client key and returns its signer and
+// authorized_keys line
+func newClientKey(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(private)
+	require.NoError(t, err)
+	return signer, ssh.MarshalAuthorizedKey(signer.PublicKey())
+}
+
+// startTestServer serves command on a local port for the authorized key
+func startTestServer(t *testing.T, authorized []byte, command func() *exec.Cmd) string {
+	t.Helper()
+	log.InitLogger(false)
+	dir := t.TempDir()
+	keysPath := filepath.Join(dir, "authorized_keys")
+	require.NoError(t, os.WriteFile(keysPath, authorized, 0600))
+
+	server, err := New(Config{
+		HostKeyPath:        filepath.Join(dir, HostKeyFile),
+		AuthorizedKeysPath: keysPath,
+		Command:            command,
+	})
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return listener.Addr().String()
+}
+
+func dial(addr string, signer ssh.Signer) (*ssh.Client, error) {
+	return ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            "tester",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+}
+
+func TestServer_RunsCommandOnTerminal(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SSH sessions need Linux pseudo-terminals")
+	}
+	signer, authorized := newClientKey(t)
+	addr := startTestServer(t, authorized, func() *exec.Cmd {
+		return exec.Command("/bin/sh", "-c", "stty size; test -t 0 && echo interactive; exit 3")
+	})
+
+	client, err := dial(addr, signer)
+	require.NoError(t, err)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	var output strings.Builder
+	session.Stdout = &output
+	require.NoError(t, session.RequestPty("xterm", 40, 100, ssh.TerminalModes{}))
+	require.NoError(t, session.Shell())
+
+	err = session.Wait()
+	var exitErr *ssh.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 3, exitErr.ExitStatus())
+	assert.Contains(t, output.String(), "40 100")
+	assert.Contains(t, output.String(), "interactive")
+}
+
+func TestServer_RequiresTerminal(t *testing.T) {
+	signer, authorized := newClientKey(t)
+	addr := startTestServer(t, authorized, func() *exec.Cmd {
+		return exec.Command("/bin/true")
+	})
+
+	client, err := dial(addr, signer)
+	require.NoError(t, err)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+	assert.Error(t, session.Shell())
+}
+
+func TestServer_RejectsUnknownKeys(t *testing.T) {
+	_, authorized := newClientKey(t)
+	other, _ := newClientKey(t)
+	addr := startTestServer(t, authorized, func() *exec.Cmd {
+		return exec.Command("/bin/true")
+	})
+
+	_, err := dial(addr, other)
+	assert.Error(t, err)
+}
+
+func TestLoadOrCreateHostKey(t *testing.T) {
+	log.InitLogger(false)
+	path := filepath.Join(t.TempDir(), "keys", HostKeyFile)
+
+	first, err := LoadOrCreateHostKey(path)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The generated key is reused so clients keep trusting the server
+	second, err := LoadOrCreateHostKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, first.PublicKey().Marshal(), second.PublicKey().Marshal())
+}
+
+func TestNew_NeedsAuthorizedKeys(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "authorized_keys")
+	require.NoError(t, os.WriteFile(empty, []byte("\n"), 0600))
+
+	command := func() *exec.Cmd { return exec.Command("/bin/true") }
+	_, err := New(Config{HostKeyPath: filepath.Join(dir, HostKeyFile), AuthorizedKeysPath: empty, Command: command})
+	assert.Error(t, err)
+	_, err = New(Config{HostKeyPath: filepath.Join(dir, HostKeyFile), AuthorizedKeysPath: filepath.Join(dir, "missing"), Command: command})
+	assert.Error(t, err)
+}
@@ -0,0 +1,341 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BenchmarkConfig sizes a storage benchmark
+type BenchmarkConfig struct {
+	Items     int // Synthetic items inserted
+	BatchSize int // Items per insert transaction
+	Queries   int // Runs of each indexed query; the full scan runs a tenth as often
+	Commits   int // Single-item commits synced to disk
+}
+
+// DefaultBenchmarkConfig returns a benchmark that takes a few seconds on a
+// local SSD
+func DefaultBenchmarkConfig() BenchmarkConfig {
+	return BenchmarkConfig{
+		Items:     50000,
+		BatchSize: 500,
+		Queries:   200,
+		Commits:   50,
+	}
+}
+
+// Validate checks that every part of the benchmark runs at least once
+func (c BenchmarkConfig) Validate() error {
+	if c.Items < 1 || c.BatchSize < 1 || c.Queries < 1 || c.Commits < 1 {
+		return fmt.Errorf("items, batch size, queries and commits must be at least 1")
+	}
+	return nil
+}
+
+// Latency summarizes the timings of repeated operations
+type Latency struct {
+	Runs int           `json:"runs"`
+	P50  time.Duration `json:"p50"`
+	P95  time.Duration `json:"p95"`
+	Max  time.Duration `json:"max"`
+}
+
+// QueryLatency is the latency of one kind of benchmark query
+type QueryLatency struct {
+	Name string `json:"name"`
+	Latency
+}
+
+// BenchmarkResult reports a storage benchmark
+type BenchmarkResult struct {
+	Path               string         `json:"path"`
+	Items              int            `json:"items"`
+	InsertDuration     time.Duration  `json:"insert_duration"`
+	ItemsPerSecond     float64        `json:"items_per_second"`
+	Commits            Latency        `json:"commits"` // Single-item transactions with synchronous=FULL
+	Queries            []QueryLatency `json:"queries"`
+	WALPeakBytes       int64          `json:"wal_peak_bytes"`
+	CheckpointDuration time.Duration  `json:"checkpoint_duration"`
+	CheckpointPages    int            `json:"checkpoint_pages"`
+	DatabaseBytes      int64          `json:"database_bytes"`
+}
+
+// Thresholds above which a benchmark result is reported as slow
+const (
+	slowItemsPerSecond = 5000
+	slowCommit         = 20 * time.Millisecond
+	slowQuery          = 5 * time.Millisecond
+	slowCheckpoint     = time.Second
+)
+
+// Warnings explains results that suggest a slow storage location, such as a
+// network drive
+func (r *BenchmarkResult) Warnings() []string {
+	var warnings []string
+	if r.ItemsPerSecond < slowItemsPerSecond {
+		warnings = append(warnings, fmt.Sprintf("Inserts run at %.0f items/s; downloads will be limited by storage rather than the API", r.ItemsPerSecond))
+	}
+	if r.Commits.P50 > slowCommit {
+		warnings = append(warnings, fmt.Sprintf("Synced commits take %s; syncing is slow, as on network drives and some USB disks", r.Commits.P50.Round(time.Millisecond)))
+	}
+	for _, query := range r.Queries {
+		if query.Name != benchFullScan && query.P95 > slowQuery {
+			warnings = append(warnings, fmt.Sprintf("Indexed %s queries take %s at p95; reads are slow on this location", query.Name, query.P95.Round(time.Microsecond)))
+		}
+	}
+	if r.CheckpointDuration > slowCheckpoint {
+		warnings = append(warnings, fmt.Sprintf("A WAL checkpoint takes %s; writers may wait on it", r.CheckpointDuration.Round(time.Millisecond)))
+	}
+	return warnings
+}
+
+// Names of the benchmark queries
+const (
+	benchPointLookup = "point lookup"
+	benchAuthor      = "author lookup"
+	benchTimeRange   = "time range"
+	benchFullScan    = "full scan"
+)
+
+// benchAuthors is the number of distinct synthetic authors
+const benchAuthors = 1000
+
+// RunBenchmark measures insert throughput, synced commit latency, query
+// latency and WAL checkpointing in a temporary database under dir, the way
+// data sources use SQLite, and removes the database afterwards. progress,
+// when set, is called as each stage starts.
+func RunBenchmark(ctx context.Context, dir string, config BenchmarkConfig, progress func(stage string)) (*BenchmarkResult, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		progress = func(string) {}
+	}
+
+	if err := MkdirAll(dir); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	benchDir, err := os.MkdirTemp(dir, ".bench-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create benchmark directory: %w", err)
+	}
+	defer os.RemoveAll(benchDir)
+
+	dbPath := filepath.Join(benchDir, "bench.sqlite")
+	db, err := sql.Open(DriverName, dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open benchmark database: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE items (
+		id INTEGER PRIMARY KEY,
+		type TEXT NOT NULL,
+		by TEXT,
+		time INTEGER,
+		text TEXT,
+		score INTEGER,
+		title TEXT
+	);
+	CREATE INDEX idx_items_by ON items (by);
+	CREATE INDEX idx_items_time ON items (time)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create benchmark table: %w", err)
+	}
+
+	result := &BenchmarkResult{Path: dir, Items: config.Items}
+	random := rand.New(rand.NewSource(1))
+	start := time.Now().Unix() - int64(config.Items)*60
+
+	progress(fmt.Sprintf("Inserting %d items", config.Items))
+	if err := benchInserts(ctx, db, dbPath, config, random, start, result); err != nil {
+		return nil, err
+	}
+
+	progress(fmt.Sprintf("Timing %d synced commits", config.Commits))
+	if err := benchCommits(ctx, db, config, result); err != nil {
+		return nil, err
+	}
+
+	progress("Checkpointing the WAL")
+	if walSize(dbPath) > result.WALPeakBytes {
+		result.WALPeakBytes = walSize(dbPath)
+	}
+	// A truncating checkpoint reports no pages, so the timed one copies them
+	// and the WAL is truncated afterwards
+	began := time.Now()
+	var busy, logPages int
+	if err := db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(FULL)").Scan(&busy, &logPages, &result.CheckpointPages); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+	result.CheckpointDuration = time.Since(began)
+	if _, err := db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+
+	progress(fmt.Sprintf("Running %d queries of each kind", config.Queries))
+	if err := benchQueries(ctx, db, config, random, start, result); err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(dbPath); err == nil {
+		result.DatabaseBytes = info.Size()
+	}
+	return result, nil
+}
+
+// benchInserts inserts the synthetic items in batches with synchronous=NORMAL,
+// as downloads store items, tracking the largest WAL
+func benchInserts(ctx context.Context, db *sql.DB, dbPath string, config BenchmarkConfig, random *rand.Rand, start int64, result *BenchmarkResult) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA synchronous = NORMAL"); err != nil {
+		return fmt.Errorf("failed to set synchronous setting: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA synchronous = FULL")
+
+	text := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ", 6)
+	began := time.Now()
+	for first := 1; first <= config.Items; first += config.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		stmt, err := tx.PrepareContext(ctx, "INSERT INTO items (id, type, by, time, text, score, title) VALUES (?, ?, ?, ?, ?, ?, ?)")
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to prepare insert: %w", err)
+		}
+		for id := first; id < first+config.BatchSize && id <= config.Items; id++ {
+			_, err := stmt.ExecContext(ctx, id, "comment", fmt.Sprintf("user%d", random.Intn(benchAuthors)),
+				start+int64(id)*60, text[:random.Intn(len(text))], random.Intn(500), fmt.Sprintf("Item %d", id))
+			if err != nil {
+				stmt.Close()
+				tx.Rollback()
+				return fmt.Errorf("failed to insert item %d: %w", id, err)
+			}
+		}
+		stmt.Close()
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit items: %w", err)
+		}
+		if size := walSize(dbPath); size > result.WALPeakBytes {
+			result.WALPeakBytes = size
+		}
+	}
+	result.InsertDuration = time.Since(began)
+	if seconds := result.InsertDuration.Seconds(); seconds > 0 {
+		result.ItemsPerSecond = float64(config.Items) / seconds
+	}
+	return nil
+}
+
+// benchCommits times single-item transactions that are synced to disk,
+// which is what slow storage such as a network drive makes expensive
+func benchCommits(ctx context.Context, db *sql.DB, config BenchmarkConfig, result *BenchmarkResult) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "PRAGMA synchronous = FULL"); err != nil {
+		return fmt.Errorf("failed to set synchronous setting: %w", err)
+	}
+
+	timings := make([]time.Duration, 0, config.Commits)
+	for i := 1; i <= config.Commits; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		began := time.Now()
+		_, err := conn.ExecContext(ctx, "INSERT INTO items (id, type, by, time) VALUES (?, 'story', 'bench', ?)", config.Items+i, time.Now().Unix())
+		if err != nil {
+			return fmt.Errorf("failed to commit item: %w", err)
+		}
+		timings = append(timings, time.Since(began))
+	}
+	result.Commits = summarizeLatency(timings)
+	return nil
+}
+
+// benchQueries times indexed lookups and a full table scan
+func benchQueries(ctx context.Context, db *sql.DB, config BenchmarkConfig, random *rand.Rand, start int64, result *BenchmarkResult) error {
+	fullScans := config.Queries / 10
+	if fullScans < 1 {
+		fullScans = 1
+	}
+	queries := []struct {
+		name string
+		runs int
+		sql  string
+		args func() []interface{}
+	}{
+		{benchPointLookup, config.Queries, "SELECT id, by, title, score FROM items WHERE id = ?", func() []interface{} {
+			return []interface{}{random.Intn(config.Items) + 1}
+		}},
+		{benchAuthor, config.Queries, "SELECT id, time FROM items WHERE by = ?", func() []interface{} {
+			return []interface{}{fmt.Sprintf("user%d", random.Intn(benchAuthors))}
+		}},
+		{benchTimeRange, config.Queries, "SELECT COUNT(*), AVG(score) FROM items WHERE time BETWEEN ? AND ?", func() []interface{} {
+			from := start + int64(random.Intn(config.Items))*60
+			return []interface{}{from, from + 24*60*60}
+		}},
+		{benchFullScan, fullScans, "SELECT COUNT(*) FROM items WHERE text LIKE ?", func() []interface{} {
+			return []interface{}{"%elit%"}
+		}},
+	}
+
+	for _, query := range queries {
+		timings := make([]time.Duration, 0, query.runs)
+		for i := 0; i < query.runs; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			began := time.Now()
+			rows, err := db.QueryContext(ctx, query.sql, query.args()...)
+			if err != nil {
+				return fmt.Errorf("%s query failed: %w", query.name, err)
+			}
+			for rows.Next() {
+			}
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				return fmt.Errorf("%s query failed: %w", query.name, err)
+			}
+			timings = append(timings, time.Since(began))
+		}
+		result.Queries = append(result.Queries, QueryLatency{Name: query.name, Latency: summarizeLatency(timings)})
+	}
+	return nil
+}
+
+// summarizeLatency returns the nearest-rank percentiles of timings
+func summarizeLatency(timings []time.Duration) Latency {
+	if len(timings) == 0 {
+		return Latency{}
+	}
+	sorted := append([]time.Duration(nil), timings...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(percentile int) time.Duration {
+		index := (percentile*len(sorted)+99)/100 - 1
+		if index < 0 {
+			index = 0
+		}
+		return sorted[index]
+	}
+	return Latency{Runs: len(sorted), P50: rank(50), P95: rank(95), Max: sorted[len(sorted)-1]}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunBenchmark(t *testing.T) {
+	dir := t.TempDir()
+	config := BenchmarkConfig{Items: 2000, BatchSize: 300, Queries: 20, Commits: 5}
+
+	var stages []string
+	result, err := RunBenchmark(context.Background(), dir, config, func(stage string) {
+		stages = append(stages, stage)
+	})
+	require.NoError(t, err)
+
+	assert.Len(t, stages, 4)
+	assert.Equal(t, 2000, result.Items)
+	assert.Greater(t, result.ItemsPerSecond, 0.0)
+	assert.Equal(t, 5, result.Commits.Runs)
+	assert.Greater(t, result.WALPeakBytes, int64(0))
+	assert.Greater(t, result.DatabaseBytes, int64(0))
+	assert.Greater(t, result.CheckpointPages, 0)
+
+	require.Len(t, result.Queries, 4)
+	assert.Equal(t, 20, result.Queries[0].Runs)
+	assert.Equal(t, 2, result.Queries[3].Runs, "the full scan runs a tenth as often")
+	for _, query := range result.Queries {
+		assert.LessOrEqual(t, query.P50, query.P95, query.Name)
+		assert.LessOrEqual(t, query.P95, query.Max, query.Name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the benchmark database is removed")
+
+	_, err = RunBenchmark(context.Background(), dir, BenchmarkConfig{Items: 10}, nil)
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = RunBenchmark(ctx, dir, config, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSummarizeLatency(t *testing.T) {
+	var timings []time.Duration
+	for i := 20; i >= 1; i-- {
+		timings = append(timings, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, Latency{Runs: 20, P50: 10 * time.Millisecond, P95: 19 * time.Millisecond, Max: 20 * time.Millisecond}, summarizeLatency(timings))
+	assert.Equal(t, Latency{Runs: 1, P50: time.Second, P95: time.Second, Max: time.Second}, summarizeLatency([]time.Duration{time.Second}))
+	assert.Equal(t, Latency{}, summarizeLatency(nil))
+}
+
+func TestBenchmarkResult_Warnings(t *testing.T) {
+	fast := &BenchmarkResult{
+		ItemsPerSecond: 50000,
+		Commits:        Latency{P50: time.Millisecond},
+		Queries:        []QueryLatency{{Name: benchPointLookup, Latency: Latency{P95: 100 * time.Microsecond}}, {Name: benchFullScan, Latency: Latency{P95: time.Second}}},
+	}
+	assert.Empty(t, fast.Warnings())
+
+	slow := &BenchmarkResult{
+		ItemsPerSecond:     800,
+		Commits:            Latency{P50: 40 * time.Millisecond},
+		Queries:            []QueryLatency{{Name: benchAuthor, Latency: Latency{P95: 30 * time.Millisecond}}},
+		CheckpointDuration: 3 * time.Second,
+	}
+	assert.Len(t, slow.Warnings(), 4)
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// checkpointsTable is the table checkpoints are kept in
+const checkpointsTable = "checkpoints"
+
+// Checkpoint is a value a pipeline keeps between runs, such as the last ID
+// or timestamp it processed. Namespaces keep pipelines apart.
+type Checkpoint struct {
+	Namespace string    `json:"namespace" db:"namespace"`
+	Key       string    `json:"key" db:"key"`
+	Value     string    `json:"value" db:"value"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Checkpoints stores the incremental cursors of custom data sources,
+// enrichers and other pipelines in a database, so they need no tables of
+// their own
+type Checkpoints struct {
+	db *sql.DB
+}
+
+// NewCheckpoints creates the checkpoint table in db if needed
+func NewCheckpoints(db *sql.DB) (*Checkpoints, error) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS ` + checkpointsTable + ` (
+		namespace TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (namespace, key)
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint table: %w", err)
+	}
+	return &Checkpoints{db: db}, nil
+}
+
+// Get returns a checkpoint, or nil if it was never set
+func (c *Checkpoints) Get(namespace, key string) (*Checkpoint, error) {
+	checkpoints, err := c.list("WHERE namespace = ? AND key = ?", namespace, key)
+	if err != nil || len(checkpoints) == 0 {
+		return nil, err
+	}
+	return &checkpoints[0], nil
+}
+
+// Value returns the value of a checkpoint, or fallback if it was never set
+func (c *Checkpoints) Value(namespace, key, fallback string) (string, error) {
+	checkpoint, err := c.Get(namespace, key)
+	if err != nil || checkpoint == nil {
+		return fallback, err
+	}
+	return checkpoint.Value, nil
+}
+
+// Set stores the value of a checkpoint, replacing the previous one
+func (c *Checkpoints) Set(namespace, key, value string) error {
+	if err := setCheckpoint(c.db, namespace, key, value); err != nil {
+		return err
+	}
+	NotifyTableWrites("", checkpointsTable)
+	return nil
+}
+
+// SetTx stores the value of a checkpoint in a transaction, so a pipeline
+// can save its cursor in the same commit as the rows it wrote
+func (c *Checkpoints) SetTx(tx *sql.Tx, namespace, key, value string) error {
+	return setCheckpoint(tx, namespace, key, value)
+}
+
+// setCheckpoint upserts a checkpoint with a database or transaction
+func setCheckpoint(db interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, namespace, key, value string) error {
+	if err := validateCheckpoint(namespace, key); err != nil {
+		return err
+	}
+	_, err := db.Exec(`
+	INSERT INTO `+checkpointsTable+` (namespace, key, value, updated_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT(namespace, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		namespace, key, value, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// List returns the checkpoints of a namespace, or of every namespace when
+// namespace is empty, ordered by namespace and key
+func (c *Checkpoints) List(namespace string) ([]Checkpoint, error) {
+	if namespace == "" {
+		return c.list("")
+	}
+	return c.list("WHERE namespace = ?", namespace)
+}
+
+// Delete removes a checkpoint, or every checkpoint of the namespace when key
+// is empty, and returns how many were removed
+func (c *Checkpoints) Delete(namespace, key string) (int64, error) {
+	if namespace == "" {
+		return 0, errors.New("checkpoint namespace cannot be empty")
+	}
+	query, args := "DELETE FROM "+checkpointsTable+" WHERE namespace = ?", []interface{}{namespace}
+	if key != "" {
+		query, args = query+" AND key = ?", append(args, key)
+	}
+	return c.delete(query, args...)
+}
+
+// Prune removes the checkpoints not updated since before, left behind by
+// pipelines that no longer run, and returns how many were removed
+func (c *Checkpoints) Prune(before time.Time) (int64, error) {
+	return c.delete("DELETE FROM "+checkpointsTable+" WHERE updated_at < ?", before)
+}
+
+// delete runs a DELETE and returns the rows removed
+func (c *Checkpoints) delete(query string, args ...interface{}) (int64, error) {
+	result, err := c.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete checkpoints: %w", err)
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete checkpoints: %w", err)
+	}
+	if removed > 0 {
+		NotifyTableWrites("", checkpointsTable)
+	}
+	return removed, nil
+}
+
+// list queries checkpoints with an optional WHERE clause
+func (c *Checkpoints) list(where string, args ...interface{}) ([]Checkpoint, error) {
+	checkpoints, err := QueryAs[Checkpoint](c.db, "SELECT namespace, key, value, updated_at FROM "+checkpointsTable+" "+
+		where+" ORDER BY namespace, key", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// validateCheckpoint checks that a checkpoint has a namespace and a key
+func validateCheckpoint(namespace, key string) error {
+	if strings.TrimSpace(namespace) == "" {
+		return errors.New("checkpoint namespace cannot be empty")
+	}
+	if strings.TrimSpace(key) == "" {
+		return errors.New("checkpoint key cannot be empty")
+	}
+	return nil
+}
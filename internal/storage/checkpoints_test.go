@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoints(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "checkpoints.sqlite"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	checkpoints, err := NewCheckpoints(db)
+	require.NoError(t, err)
+	// Opening again keeps the stored checkpoints
+	_, err = NewCheckpoints(db)
+	require.NoError(t, err)
+
+	value, err := checkpoints.Value("github", "cursor", "0")
+	require.NoError(t, err)
+	assert.Equal(t, "0", value, "unset checkpoints return the fallback")
+
+	require.NoError(t, checkpoints.Set("github", "cursor", "100"))
+	require.NoError(t, checkpoints.Set("github", "cursor", "250"))
+	require.NoError(t, checkpoints.Set("github", "etag", `W/"abc"`))
+	require.NoError(t, checkpoints.Set("enrich", "last_id", "42"))
+	assert.Error(t, checkpoints.Set("", "cursor", "1"))
+	assert.Error(t, checkpoints.Set("github", " ", "1"))
+
+	checkpoint, err := checkpoints.Get("github", "cursor")
+	require.NoError(t, err)
+	require.NotNil(t, checkpoint)
+	assert.Equal(t, "250", checkpoint.Value)
+	assert.WithinDuration(t, time.Now(), checkpoint.UpdatedAt, time.Minute)
+
+	all, err := checkpoints.List("")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "enrich", all[0].Namespace)
+	github, err := checkpoints.List("github")
+	require.NoError(t, err)
+	assert.Len(t, github, 2)
+
+	// A transaction saves the cursor with the rows it covers
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, checkpoints.SetTx(tx, "enrich", "last_id", "43"))
+	require.NoError(t, tx.Rollback())
+	value, err = checkpoints.Value("enrich", "last_id", "")
+	require.NoError(t, err)
+	assert.Equal(t, "42", value, "a rolled back checkpoint is not saved")
+
+	removed, err := checkpoints.Delete("github", "etag")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	removed, err = checkpoints.Delete("github", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	removed, err = checkpoints.Prune(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+	removed, err = checkpoints.Prune(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+}
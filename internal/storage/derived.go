@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Refresh modes for derived tables; any other value is a cron expression
+const (
+	RefreshManual    = "manual"
+	RefreshAfterSync = "after_sync"
+)
+
+// derivedTableNamePattern restricts derived table names to plain identifiers
+var derivedTableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// DerivedTable is a table built from a SQL query over a source's data
+type DerivedTable struct {
+	Name        string     `json:"name"`
+	Query       string     `json:"query"`
+	Refresh     string     `json:"refresh"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RefreshedAt *time.Time `json:"refreshed_at,omitempty"`
+	RowCount    int64      `json:"row_count"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// IsScheduled reports whether the table is refreshed on a cron schedule
+func (dt DerivedTable) IsScheduled() bool {
+	return dt.Refresh != RefreshManual && dt.Refresh != RefreshAfterSync
+}
+
+// DerivedColumn describes a column of a derived table
+type DerivedColumn struct {
+	Name string
+	Type string
+}
+
+// DerivedTables stores derived table definitions in a database and rebuilds
+// the tables from their queries
+type DerivedTables struct {
+	db *sql.DB
+	mu sync.Mutex // Serializes refreshes so two rebuilds never race on a table
+}
+
+// NewDerivedTables creates the derived table catalog in db if needed
+func NewDerivedTables(db *sql.DB) (*DerivedTables, error) {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS derived_tables (
+		name TEXT PRIMARY KEY,
+		query TEXT NOT NULL,
+		refresh TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		refreshed_at DATETIME,
+		row_count INTEGER DEFAULT 0,
+		last_error TEXT DEFAULT ''
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create derived table catalog: %w", err)
+	}
+	return &DerivedTables{db: db}, nil
+}
+
+// Define validates and stores a derived table definition, replacing any
+// existing definition with the same name. The table is built on the next refresh.
+func (d *DerivedTables) Define(name, query, refresh string) error {
+	if !derivedTableNamePattern.MatchString(name) || strings.HasPrefix(strings.ToLower(name), "sqlite_") {
+		return fmt.Errorf("invalid derived table name %q", name)
+	}
+	if name == "derived_tables" {
+		return fmt.Errorf("%s is reserved", name)
+	}
+	if refresh == "" {
+		refresh = RefreshManual
+	}
+
+	query = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	fields := strings.Fields(query)
+	if len(fields) == 0 || (!strings.EqualFold(fields[0], "SELECT") && !strings.EqualFold(fields[0], "WITH")) {
+		return fmt.Errorf("derived table query must be a SELECT statement")
+	}
+
+	existing, err := d.Get(name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		var count int
+		if err := d.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = ?", name).Scan(&count); err != nil {
+			return fmt.Errorf("failed to check table name: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("table %s already exists", name)
+		}
+	}
+
+	// EXPLAIN compiles the query without running it
+	rows, err := d.db.Query("EXPLAIN " + query)
+	if err != nil {
+		return fmt.Errorf("invalid derived table query: %w", err)
+	}
+	rows.Close()
+
+	_, err = d.db.Exec(`
+	INSERT INTO derived_tables (name, query, refresh, created_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT(name) DO UPDATE SET query = excluded.query, refresh = excluded.refresh`,
+		name, query, refresh, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save derived table: %w", err)
+	}
+	return nil
+}
+
+// Drop removes a derived table and its definition
+func (d *DerivedTables) Drop(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	existing, err := d.Get(name)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("derived table not found: %s", name)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to drop table: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM derived_tables WHERE name = ?", name); err != nil {
+		return fmt.Errorf("failed to delete definition: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	NotifyTableWrites("", name)
+	return nil
+}
+
+// Get returns a derived table definition, or nil if it does not exist
+func (d *DerivedTables) Get(name string) (*DerivedTable, error) {
+	tables, err := d.list("WHERE name = ?", name)
+	if err != nil || len(tables) == 0 {
+		return nil, err
+	}
+	return &tables[0], nil
+}
+
+// List returns all derived table definitions ordered by name
+func (d *DerivedTables) List() ([]DerivedTable, error) {
+	return d.list("")
+}
+
+// Refresh rebuilds a derived table from its query, replacing the previous
+// contents atomically, and returns the new row count
+func (d *DerivedTables) Refresh(name string) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	table, err := d.Get(name)
+	if err != nil {
+		return 0, err
+	}
+	if table == nil {
+		return 0, fmt.Errorf("derived table not found: %s", name)
+	}
+
+	count, err := d.rebuild(table)
+	if err != nil {
+		d.db.Exec("UPDATE derived_tables SET last_error = ? WHERE name = ?", err.Error(), name)
+		return 0, err
+	}
+	NotifyTableWrites("", name)
+	return count, nil
+}
+
+// Columns returns the columns of a built derived table
+func (d *DerivedTables) Columns(name string) ([]DerivedColumn, error) {
+	rows, err := d.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", quoteIdentifier(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []DerivedColumn
+	for rows.Next() {
+		var cid, notNull, pk int
+		var column DerivedColumn
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &column.Name, &column.Type, &notNull, &defaultValue, &pk); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// rebuild creates the table under a temporary name and swaps it in
+func (d *DerivedTables) rebuild(table *DerivedTable) (int64, error) {
+	staging := quoteIdentifier(table.Name + "__refresh")
+	target := quoteIdentifier(table.Name)
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", staging),
+		fmt.Sprintf("CREATE TABLE %s AS %s", staging, table.Query),
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", target),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", staging, target),
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return 0, fmt.Errorf("failed to rebuild %s: %w", table.Name, err)
+		}
+	}
+
+	var count int64
+	if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", target)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count rows: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE derived_tables SET refreshed_at = ?, row_count = ?, last_error = '' WHERE name = ?",
+		time.Now(), count, table.Name); err != nil {
+		return 0, fmt.Errorf("failed to update definition: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit refresh: %w", err)
+	}
+	return count, nil
+}
+
+// list queries derived table definitions with an optional WHERE clause
+func (d *DerivedTables) list(where string, args ...interface{}) ([]DerivedTable, error) {
+	rows, err := d.db.Query("SELECT name, query, refresh, created_at, refreshed_at, row_count, last_error FROM derived_tables "+
+		where+" ORDER BY name", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list derived tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []DerivedTable
+	for rows.Next() {
+		var table DerivedTable
+		var refreshedAt sql.NullTime
+		if err := rows.Scan(&table.Name, &table.Query, &table.Refresh, &table.CreatedAt, &refreshedAt,
+			&table.RowCount, &table.LastError); err != nil {
+			return nil, fmt.Errorf("failed to scan derived table: %w", err)
+		}
+		if refreshedAt.Valid {
+			table.RefreshedAt = &refreshedAt.Time
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// quoteIdentifier quotes a SQLite identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
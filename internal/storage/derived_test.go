@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDerivedTestDB(t *testing.T) (*sql.DB, *DerivedTables) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "derived.sqlite"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE items (id INTEGER PRIMARY KEY, type TEXT, time INTEGER);
+		INSERT INTO items (type, time) VALUES ('story', 0), ('story', 86400), ('comment', 86400)`)
+	require.NoError(t, err)
+
+	derived, err := NewDerivedTables(db)
+	require.NoError(t, err)
+	return db, derived
+}
+
+func TestDerivedTables_DefineRefreshDrop(t *testing.T) {
+	db, derived := newDerivedTestDB(t)
+
+	query := "SELECT time / 86400 AS day, COUNT(*) AS stories FROM items WHERE type = 'story' GROUP BY day"
+	require.NoError(t, derived.Define("daily_story_counts", query, RefreshAfterSync))
+
+	count, err := derived.Refresh("daily_story_counts")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	columns, err := derived.Columns("daily_story_counts")
+	require.NoError(t, err)
+	require.Len(t, columns, 2)
+	assert.Equal(t, "day", columns[0].Name)
+
+	// A refresh picks up new rows
+	_, err = db.Exec("INSERT INTO items (type, time) VALUES ('story', 172800)")
+	require.NoError(t, err)
+	count, err = derived.Refresh("daily_story_counts")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	tables, err := derived.List()
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+	assert.Equal(t, RefreshAfterSync, tables[0].Refresh)
+	assert.Equal(t, int64(3), tables[0].RowCount)
+	assert.NotNil(t, tables[0].RefreshedAt)
+	assert.False(t, tables[0].IsScheduled())
+
+	require.NoError(t, derived.Drop("daily_story_counts"))
+	columns, err = derived.Columns("daily_story_counts")
+	require.NoError(t, err)
+	assert.Empty(t, columns)
+}
+
+func TestDerivedTables_DefineValidation(t *testing.T) {
+	_, derived := newDerivedTestDB(t)
+
+	assert.Error(t, derived.Define("bad name", "SELECT 1", RefreshManual))
+	assert.Error(t, derived.Define("items", "SELECT 1", RefreshManual), "must not shadow a source table")
+	assert.Error(t, derived.Define("derived_tables", "SELECT 1", RefreshManual))
+	assert.Error(t, derived.Define("wipe", "DELETE FROM items", RefreshManual))
+	assert.Error(t, derived.Define("broken", "SELECT nope FROM missing", RefreshManual))
+
+	require.NoError(t, derived.Define("hourly", "SELECT 1 AS one", "0 * * * *"))
+	table, err := derived.Get("hourly")
+	require.NoError(t, err)
+	assert.True(t, table.IsScheduled())
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DriverName is the database/sql driver that registers PubDataHub's SQL
+// functions on every connection. Open databases with it instead of "sqlite3"
+// so queries can use them:
+//
+//	url_domain(url)        host of a URL without "www.", e.g. github.com
+//	url_canonical(url)     URL without tracking parameters, see CanonicalURL
+//	epoch_to_date(time)    UTC date of a Unix timestamp, e.g. 2024-01-10
+//	text_tokens(text)      number of words in text after stripping HTML
+//	percentile(col, p)     p-th percentile (0-100) of col, interpolated
+const DriverName = "sqlite3_pubdatahub"
+
+func init() {
+	sql.Register(DriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: registerFunctions,
+	})
+}
+
+// registerFunctions adds the custom SQL functions to a connection
+func registerFunctions(conn *sqlite3.SQLiteConn) error {
+	functions := map[string]interface{}{
+		"url_domain":    urlDomain,
+		"url_canonical": urlCanonical,
+		"epoch_to_date": epochToDate,
+		"text_tokens":   textTokens,
+	}
+	for name, fn := range functions {
+		if err := conn.RegisterFunc(name, fn, true); err != nil {
+			return fmt.Errorf("failed to register %s: %w", name, err)
+		}
+	}
+
+	if err := conn.RegisterAggregator("percentile", newPercentileAggregator, true); err != nil {
+		return fmt.Errorf("failed to register percentile: %w", err)
+	}
+	return nil
+}
+
+// urlDomain returns the lower-cased host of a URL without a leading "www.",
+// or NULL for NULL, empty or unparsable URLs
+func urlDomain(value interface{}) interface{} {
+	raw, ok := textValue(value)
+	if !ok || raw == "" {
+		return nil
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Hostname() == "" {
+		return nil
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
+
+// urlCanonical returns the canonical form of a URL, or NULL for NULL and
+// empty values
+func urlCanonical(value interface{}) interface{} {
+	raw, ok := textValue(value)
+	if !ok || strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	return CanonicalURL(raw)
+}
+
+// epochToDate formats a Unix timestamp as a UTC YYYY-MM-DD date
+func epochToDate(value interface{}) interface{} {
+	var seconds int64
+	switch v := value.(type) {
+	case int64:
+		seconds = v
+	case float64:
+		seconds = int64(v)
+	default:
+		text, ok := textValue(value)
+		if !ok {
+			return nil
+		}
+		parsed, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+		if err != nil {
+			return nil
+		}
+		seconds = parsed
+	}
+	return time.Unix(seconds, 0).UTC().Format("2006-01-02")
+}
+
+// htmlTagPattern matches HTML tags in item text
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// textTokens counts the words in text, ignoring HTML tags and entities
+func textTokens(value interface{}) interface{} {
+	text, ok := textValue(value)
+	if !ok {
+		return nil
+	}
+
+	text = html.UnescapeString(htmlTagPattern.ReplaceAllString(text, " "))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '-'
+	})
+	return int64(len(words))
+}
+
+// textValue returns the string form of a TEXT or BLOB argument
+func textValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		if v == nil {
+			return "", false
+		}
+		return string(v), true
+	default:
+		return "", false
+	}
+}
+
+// numericValue returns the value of an INTEGER or REAL argument
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// percentileAggregator computes a percentile with linear interpolation
+type percentileAggregator struct {
+	values     []float64
+	percentile float64
+}
+
+// newPercentileAggregator creates a percentile aggregator for one group
+func newPercentileAggregator() *percentileAggregator {
+	return &percentileAggregator{percentile: math.NaN()}
+}
+
+// Step adds a value; NULL and non-numeric values are ignored
+func (pa *percentileAggregator) Step(value, percentile interface{}) {
+	if math.IsNaN(pa.percentile) {
+		if p, ok := numericValue(percentile); ok {
+			pa.percentile = p
+		}
+	}
+	if v, ok := numericValue(value); ok {
+		pa.values = append(pa.values, v)
+	}
+}
+
+// Done returns the percentile, or NULL when there were no values
+func (pa *percentileAggregator) Done() (interface{}, error) {
+	if len(pa.values) == 0 {
+		return nil, nil
+	}
+	if math.IsNaN(pa.percentile) || pa.percentile < 0 || pa.percentile > 100 {
+		return nil, fmt.Errorf("percentile must be between 0 and 100, got %g", pa.percentile)
+	}
+
+	sort.Float64s(pa.values)
+	rank := pa.percentile / 100 * float64(len(pa.values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	fraction := rank - float64(lower)
+	return pa.values[lower] + (pa.values[upper]-pa.values[lower])*fraction, nil
+}
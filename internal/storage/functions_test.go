@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomFunctions(t *testing.T) {
+	db, err := sql.Open(DriverName, filepath.Join(t.TempDir(), "functions.sqlite"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	var domain, date string
+	var tokens int64
+	err = db.QueryRow(`SELECT url_domain('https://WWW.GitHub.com/brainless/PubDataHub?tab=readme'),
+		epoch_to_date(1704931200),
+		text_tokens('Show HN: <i>my</i> side-project&#x27;s launch')`).Scan(&domain, &date, &tokens)
+	require.NoError(t, err)
+	assert.Equal(t, "github.com", domain)
+	assert.Equal(t, "2024-01-11", date)
+	assert.Equal(t, int64(5), tokens)
+
+	var nullDomain sql.NullString
+	require.NoError(t, db.QueryRow("SELECT url_domain(NULL)").Scan(&nullDomain))
+	assert.False(t, nullDomain.Valid)
+
+	var canonical string
+	require.NoError(t, db.QueryRow("SELECT url_canonical('http://www.example.com/post/?utm_source=hn')").Scan(&canonical))
+	assert.Equal(t, "https://example.com/post", canonical)
+
+	var nullCanonical sql.NullString
+	require.NoError(t, db.QueryRow("SELECT url_canonical('')").Scan(&nullCanonical))
+	assert.False(t, nullCanonical.Valid)
+
+	_, err = db.Exec(`CREATE TABLE items (score INTEGER);
+		INSERT INTO items VALUES (1), (2), (3), (4), (NULL)`)
+	require.NoError(t, err)
+
+	var median, p90 float64
+	require.NoError(t, db.QueryRow("SELECT percentile(score, 50), percentile(score, 90) FROM items").Scan(&median, &p90))
+	assert.Equal(t, 2.5, median)
+	assert.InDelta(t, 3.7, p90, 1e-9)
+
+	var invalid float64
+	assert.Error(t, db.QueryRow("SELECT percentile(score, 150) FROM items").Scan(&invalid))
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DBHealthConfig configures the database health monitor
+type DBHealthConfig struct {
+	CheckpointInterval time.Duration // How often to run wal_checkpoint(TRUNCATE)
+	WALSizeLimit       int64         // WAL size in bytes above which health is degraded
+	BusyStormThreshold int           // Busy/locked errors within BusyStormWindow that count as a storm
+	BusyStormWindow    time.Duration
+}
+
+// DefaultDBHealthConfig returns the default health monitor configuration
+func DefaultDBHealthConfig() DBHealthConfig {
+	return DBHealthConfig{
+		CheckpointInterval: 5 * time.Minute,
+		WALSizeLimit:       64 * 1024 * 1024,
+		BusyStormThreshold: 20,
+		BusyStormWindow:    time.Minute,
+	}
+}
+
+// DBHealth describes the health of a single SQLite database
+type DBHealth struct {
+	Status            string    `json:"status"` // "healthy", "degraded", "unhealthy"
+	WALSizeBytes      int64     `json:"wal_size_bytes"`
+	LastCheckpoint    time.Time `json:"last_checkpoint,omitempty"`
+	CheckpointedPages int       `json:"checkpointed_pages"`
+	CheckpointError   string    `json:"checkpoint_error,omitempty"`
+	RecentBusyErrors  int       `json:"recent_busy_errors"`
+	TotalBusyErrors   int64     `json:"total_busy_errors"`
+	BusyStorm         bool      `json:"busy_storm"`
+	LastCheck         time.Time `json:"last_check"`
+	Issues            []string  `json:"issues,omitempty"`
+}
+
+// DBHealthMonitor periodically checkpoints the WAL of a SQLite database and
+// tracks SQLITE_BUSY/SQLITE_LOCKED errors reported by its users
+type DBHealthMonitor struct {
+	db     *sql.DB
+	dbPath string
+	config DBHealthConfig
+
+	mu                sync.Mutex
+	lastCheckpoint    time.Time
+	checkpointedPages int
+	checkpointErr     error
+	busyTimes         []time.Time
+	totalBusy         int64
+
+	running  int32
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDBHealthMonitor creates a health monitor for the database at dbPath
+func NewDBHealthMonitor(db *sql.DB, dbPath string, config DBHealthConfig) *DBHealthMonitor {
+	if config.CheckpointInterval <= 0 {
+		config.CheckpointInterval = DefaultDBHealthConfig().CheckpointInterval
+	}
+	if config.BusyStormWindow <= 0 {
+		config.BusyStormWindow = DefaultDBHealthConfig().BusyStormWindow
+	}
+
+	return &DBHealthMonitor{
+		db:       db,
+		dbPath:   dbPath,
+		config:   config,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start begins periodic WAL checkpointing in the background
+func (m *DBHealthMonitor) Start() {
+	if !atomic.CompareAndSwapInt32(&m.running, 0, 1) {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.config.CheckpointInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-m.stopChan:
+				return
+			case <-ticker.C:
+				m.Checkpoint()
+			}
+		}
+	}()
+}
+
+// Stop stops periodic checkpointing
+func (m *DBHealthMonitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopChan)
+	})
+}
+
+// Checkpoint runs wal_checkpoint(TRUNCATE) and records the outcome
+func (m *DBHealthMonitor) Checkpoint() error {
+	var busy, logPages, checkpointed int
+	err := m.db.QueryRow("PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &logPages, &checkpointed)
+	if err == nil && busy != 0 {
+		err = fmt.Errorf("checkpoint blocked by active readers or writers")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if busy != 0 || IsBusyError(err) {
+		m.recordBusy()
+	}
+	m.checkpointErr = err
+	if err == nil {
+		m.lastCheckpoint = time.Now()
+		m.checkpointedPages = checkpointed
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to checkpoint WAL: %w", err)
+	}
+	return nil
+}
+
+// RecordError counts err towards busy storm detection if it is a busy or locked error
+func (m *DBHealthMonitor) RecordError(err error) {
+	if !IsBusyError(err) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recordBusy()
+}
+
+// recordBusy notes a busy event; callers hold mu
+func (m *DBHealthMonitor) recordBusy() {
+	now := time.Now()
+	m.totalBusy++
+	m.busyTimes = append(m.pruneBusyTimes(now), now)
+}
+
+// pruneBusyTimes drops busy timestamps outside the storm window; callers hold mu
+func (m *DBHealthMonitor) pruneBusyTimes(now time.Time) []time.Time {
+	cutoff := now.Add(-m.config.BusyStormWindow)
+	kept := m.busyTimes[:0]
+	for _, t := range m.busyTimes {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// Health returns the current health of the database
+func (m *DBHealthMonitor) Health() DBHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.busyTimes = m.pruneBusyTimes(now)
+
+	health := DBHealth{
+		WALSizeBytes:      walSize(m.dbPath),
+		LastCheckpoint:    m.lastCheckpoint,
+		CheckpointedPages: m.checkpointedPages,
+		RecentBusyErrors:  len(m.busyTimes),
+		TotalBusyErrors:   m.totalBusy,
+		LastCheck:         now,
+	}
+
+	degraded := false
+	if m.checkpointErr != nil {
+		health.CheckpointError = m.checkpointErr.Error()
+		health.Issues = append(health.Issues, fmt.Sprintf("WAL checkpoint failed: %v", m.checkpointErr))
+		degraded = true
+	}
+	if m.config.WALSizeLimit > 0 && health.WALSizeBytes > m.config.WALSizeLimit {
+		health.Issues = append(health.Issues, fmt.Sprintf("WAL file is %d bytes (limit %d)", health.WALSizeBytes, m.config.WALSizeLimit))
+		degraded = true
+	}
+	if m.config.BusyStormThreshold > 0 && health.RecentBusyErrors >= m.config.BusyStormThreshold {
+		health.BusyStorm = true
+		health.Issues = append(health.Issues, fmt.Sprintf("%d busy/locked errors in the last %v", health.RecentBusyErrors, m.config.BusyStormWindow))
+	}
+
+	switch {
+	case health.BusyStorm:
+		health.Status = "unhealthy"
+	case degraded:
+		health.Status = "degraded"
+	default:
+		health.Status = "healthy"
+	}
+
+	return health
+}
+
+// IsBusyError reports whether err is a SQLITE_BUSY or SQLITE_LOCKED error
+func IsBusyError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
+
+// walSize returns the size of the write-ahead log next to dbPath
+func walSize(dbPath string) int64 {
+	stat, err := os.Stat(dbPath + "-wal")
+	if err != nil {
+		return 0
+	}
+	return stat.Size()
+}
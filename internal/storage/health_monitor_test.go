@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBHealthMonitor_CheckpointTruncatesWAL(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "health.sqlite")
+	db, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE t (v TEXT)")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = db.Exec("INSERT INTO t (v) VALUES (?)", fmt.Sprintf("row-%d", i))
+		require.NoError(t, err)
+	}
+
+	monitor := NewDBHealthMonitor(db, dbPath, DefaultDBHealthConfig())
+	assert.Greater(t, monitor.Health().WALSizeBytes, int64(0))
+
+	require.NoError(t, monitor.Checkpoint())
+
+	health := monitor.Health()
+	assert.Equal(t, "healthy", health.Status)
+	assert.Equal(t, int64(0), health.WALSizeBytes)
+	assert.False(t, health.LastCheckpoint.IsZero())
+}
+
+func TestDBHealthMonitor_BusyStorm(t *testing.T) {
+	config := DefaultDBHealthConfig()
+	config.BusyStormThreshold = 3
+	config.BusyStormWindow = time.Minute
+	monitor := NewDBHealthMonitor(nil, filepath.Join(t.TempDir(), "missing.sqlite"), config)
+
+	monitor.RecordError(fmt.Errorf("unrelated"))
+	monitor.RecordError(nil)
+	assert.Equal(t, 0, monitor.Health().RecentBusyErrors)
+
+	for i := 0; i < 3; i++ {
+		monitor.RecordError(fmt.Errorf("failed to execute query: %w", sqlite3.Error{Code: sqlite3.ErrBusy}))
+	}
+
+	health := monitor.Health()
+	assert.True(t, health.BusyStorm)
+	assert.Equal(t, "unhealthy", health.Status)
+	assert.Equal(t, int64(3), health.TotalBusyErrors)
+}
+
+func TestSQLiteStorage_Recovery(t *testing.T) {
+	storage := NewSQLiteStorage(2)
+	require.NoError(t, storage.Initialize(t.TempDir()))
+	defer storage.Close()
+
+	assert.NoError(t, storage.VerifyIntegrity())
+	assert.NoError(t, storage.RepairIfNeeded())
+	assert.NoError(t, storage.ValidateConnection())
+	assert.NoError(t, storage.CheckpointWAL())
+	assert.Equal(t, "healthy", storage.DatabaseHealth().Status)
+}
@@ -93,6 +93,7 @@ type HealthStatus struct {
 	ConnectionPool   PoolHealth        `json:"connection_pool"`
 	DiskSpace        DiskHealth        `json:"disk_space"`
 	QueryPerformance PerformanceHealth `json:"query_performance"`
+	Database         DBHealth          `json:"database"`
 	LastCheck        time.Time         `json:"last_check"`
 	Issues           []string          `json:"issues,omitempty"`
 }
@@ -46,6 +46,7 @@ type JobMetricsCollector struct {
 // JobMetrics holds performance metrics for a job
 type JobMetrics struct {
 	JobID              string           `json:"job_id"`
+	JobType            string           `json:"job_type,omitempty"`
 	DataSource         string           `json:"data_source"`
 	StartTime          time.Time        `json:"start_time"`
 	EndTime            *time.Time       `json:"end_time,omitempty"`
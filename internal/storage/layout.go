@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Storage layouts: each component in its own database file, or every
+// component in one WAL-mode database at the root of the storage path
+const (
+	LayoutSeparate = "separate"
+	LayoutShared   = "shared"
+)
+
+// SharedDatabaseFile is the name of the shared database in the storage directory
+const SharedDatabaseFile = "pubdatahub.db"
+
+// MigratedSuffix is appended to component databases moved into the shared
+// database; the renamed files are kept as a backup
+const MigratedSuffix = ".pre-shared"
+
+var (
+	layoutMu       sync.RWMutex
+	sharedDatabase string
+)
+
+// UseSharedDatabase makes DatabasePath return path for every component; an
+// empty path restores the separate layout
+func UseSharedDatabase(path string) {
+	layoutMu.Lock()
+	defer layoutMu.Unlock()
+	sharedDatabase = path
+}
+
+// SharedDatabase returns the shared database path, or "" in the separate layout
+func SharedDatabase() string {
+	layoutMu.RLock()
+	defer layoutMu.RUnlock()
+	return sharedDatabase
+}
+
+// DatabasePath returns the database a component should open: its own file
+// in dir, or the shared database when the shared layout is in use
+func DatabasePath(dir, file string) string {
+	if shared := SharedDatabase(); shared != "" {
+		return shared
+	}
+	return filepath.Join(dir, file)
+}
+
+// DatabaseDSN returns the connection string for a component database. The
+// shared database is written by several components at once, so it always uses
+// WAL mode and waits for locks instead of failing with SQLITE_BUSY.
+func DatabaseDSN(path string) string {
+	if ReadOnly() {
+		return ReadOnlyDSN(path)
+	}
+	if shared := SharedDatabase(); shared != "" && path == shared {
+		return path + "?_journal_mode=WAL&_busy_timeout=5000"
+	}
+	return path
+}
+
+// MigratedDatabase describes one component database moved into the shared database
+type MigratedDatabase struct {
+	Path   string           `json:"path"`
+	Backup string           `json:"backup"`
+	Tables map[string]int64 `json:"tables"`
+}
+
+// schemaObject is an entry of sqlite_master
+type schemaObject struct {
+	kind string
+	name string
+	sql  string
+}
+
+// MigrateToShared copies the tables, indexes, views and triggers of each
+// component database into a new shared database, verifies the row counts and
+// renames the originals with MigratedSuffix. Missing databases are skipped.
+// The shared database is built under a temporary name, so a failed copy
+// leaves the component databases in use; once it returns migrated databases
+// the shared database is complete, even alongside an error. Nothing else may have the databases
+// open while this runs.
+func MigrateToShared(sharedPath string, databases []string) ([]MigratedDatabase, error) {
+	if _, err := os.Stat(sharedPath); err == nil {
+		return nil, fmt.Errorf("shared database %s already exists", sharedPath)
+	}
+
+	var existing []string
+	for _, path := range databases {
+		if _, err := os.Stat(path); err == nil {
+			existing = append(existing, path)
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+	}
+	if len(existing) == 0 {
+		return nil, nil
+	}
+
+	tmpPath := sharedPath + ".migrating"
+	removeDatabaseFiles(tmpPath)
+	migrated, err := buildSharedDatabase(tmpPath, existing)
+	if err != nil {
+		removeDatabaseFiles(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, sharedPath); err != nil {
+		removeDatabaseFiles(tmpPath)
+		return nil, fmt.Errorf("failed to create shared database: %w", err)
+	}
+
+	for i := range migrated {
+		path := migrated[i].Path
+		migrated[i].Backup = path + MigratedSuffix
+		if err := os.Rename(path, migrated[i].Backup); err != nil {
+			// The shared database is complete; only the backup is missing
+			migrated[i].Backup = ""
+			return migrated, fmt.Errorf("failed to rename %s: %w", path, err)
+		}
+		// The WAL was checkpointed into the database during the copy
+		os.Remove(path + "-wal")
+		os.Remove(path + "-shm")
+	}
+	return migrated, nil
+}
+
+// buildSharedDatabase copies every database into a new database at path
+func buildSharedDatabase(path string, databases []string) ([]MigratedDatabase, error) {
+	if err := PrepareDatabase(path); err != nil {
+		return nil, fmt.Errorf("failed to create shared database: %w", err)
+	}
+	db, err := sql.Open(DriverName, path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shared database: %w", err)
+	}
+	defer db.Close()
+	// ATTACH applies to one connection only
+	db.SetMaxOpenConns(1)
+
+	// Refuse before copying anything if two databases define the same table
+	owners := make(map[string]string)
+	for _, database := range databases {
+		tables, err := attachedTables(db, database)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			if owner, ok := owners[table]; ok {
+				return nil, fmt.Errorf("table %s exists in both %s and %s", table, owner, database)
+			}
+			owners[table] = database
+		}
+	}
+
+	var migrated []MigratedDatabase
+	for _, database := range databases {
+		tables, err := copyDatabase(db, database)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate %s: %w", database, err)
+		}
+		migrated = append(migrated, MigratedDatabase{Path: database, Tables: tables})
+	}
+
+	// Fold the WAL into the file so it can be renamed on its own
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint shared database: %w", err)
+	}
+	return migrated, nil
+}
+
+// removeDatabaseFiles removes a database and its WAL files
+func removeDatabaseFiles(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(path + suffix)
+	}
+}
+
+// attachedTables returns the table names of a database file
+func attachedTables(db *sql.DB, path string) ([]string, error) {
+	if _, err := db.Exec("ATTACH DATABASE ? AS src", path); err != nil {
+		return nil, fmt.Errorf("failed to attach %s: %w", path, err)
+	}
+	defer db.Exec("DETACH DATABASE src")
+
+	objects, err := schemaObjects(db, "src")
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for _, object := range objects {
+		if object.kind == "table" {
+			tables = append(tables, object.name)
+		}
+	}
+	return tables, nil
+}
+
+// copyDatabase copies one attached database into main in a transaction and
+// returns the row count of each copied table
+func copyDatabase(db *sql.DB, path string) (map[string]int64, error) {
+	if _, err := db.Exec("ATTACH DATABASE ? AS src", path); err != nil {
+		return nil, fmt.Errorf("failed to attach: %w", err)
+	}
+	defer db.Exec("DETACH DATABASE src")
+
+	if _, err := db.Exec("PRAGMA src.wal_checkpoint(TRUNCATE)"); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint: %w", err)
+	}
+
+	objects, err := schemaObjects(db, "src")
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	counts := make(map[string]int64)
+	for _, object := range objects {
+		// Unqualified CREATE statements run against main
+		if _, err := tx.Exec(object.sql); err != nil {
+			return nil, fmt.Errorf("failed to create %s %s: %w", object.kind, object.name, err)
+		}
+		if object.kind != "table" {
+			continue
+		}
+
+		table := quoteIdentifier(object.name)
+		if _, err := tx.Exec(fmt.Sprintf("INSERT INTO main.%s SELECT * FROM src.%s", table, table)); err != nil {
+			return nil, fmt.Errorf("failed to copy table %s: %w", object.name, err)
+		}
+
+		var source, copied int64
+		if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM src.%s", table)).Scan(&source); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", object.name, err)
+		}
+		if err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM main.%s", table)).Scan(&copied); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", object.name, err)
+		}
+		if source != copied {
+			return nil, fmt.Errorf("table %s has %d rows after copy, expected %d", object.name, copied, source)
+		}
+		counts[object.name] = copied
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+	return counts, nil
+}
+
+// schemaObjects lists the user-defined objects of a schema, tables first so
+// indexes, views and triggers can refer to them
+func schemaObjects(db *sql.DB, schema string) ([]schemaObject, error) {
+	rows, err := db.Query(fmt.Sprintf(
+		"SELECT type, name, sql FROM %s.sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%%'", schema))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	defer rows.Close()
+
+	var objects []schemaObject
+	for rows.Next() {
+		var object schemaObject
+		if err := rows.Scan(&object.kind, &object.name, &object.sql); err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		objects = append(objects, object)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	order := map[string]int{"table": 0, "index": 1, "view": 2, "trigger": 3}
+	sort.SliceStable(objects, func(i, j int) bool {
+		return order[strings.ToLower(objects[i].kind)] < order[strings.ToLower(objects[j].kind)]
+	})
+	return objects, nil
+}
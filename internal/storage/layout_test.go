@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestDatabase creates a WAL-mode database from a schema script
+func createTestDatabase(t *testing.T, path, script string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(script)
+	require.NoError(t, err)
+}
+
+func TestDatabasePath(t *testing.T) {
+	defer UseSharedDatabase("")
+
+	assert.Equal(t, filepath.Join("data", "jobs.db"), DatabasePath("data", "jobs.db"))
+	assert.Equal(t, filepath.Join("data", "jobs.db"), DatabaseDSN(filepath.Join("data", "jobs.db")))
+
+	UseSharedDatabase(filepath.Join("data", SharedDatabaseFile))
+	shared := filepath.Join("data", SharedDatabaseFile)
+	assert.Equal(t, shared, DatabasePath(filepath.Join("data", "hackernews"), "hackernews.sqlite"))
+	assert.Equal(t, shared+"?_journal_mode=WAL&_busy_timeout=5000", DatabaseDSN(shared))
+}
+
+func TestMigrateToShared(t *testing.T) {
+	dir := t.TempDir()
+	jobsPath := filepath.Join(dir, "jobs.db")
+	itemsPath := filepath.Join(dir, "hackernews", "hackernews.sqlite")
+
+	createTestDatabase(t, jobsPath, `
+		CREATE TABLE jobs (id TEXT PRIMARY KEY, state TEXT);
+		CREATE TABLE job_events (id INTEGER PRIMARY KEY AUTOINCREMENT, job_id TEXT);
+		CREATE INDEX idx_jobs_state ON jobs (state);
+		INSERT INTO jobs VALUES ('a', 'completed'), ('b', 'failed');
+		INSERT INTO job_events (job_id) VALUES ('a'), ('a'), ('b');`)
+	createTestDatabase(t, itemsPath, `
+		CREATE TABLE items (id INTEGER PRIMARY KEY, title TEXT);
+		CREATE VIEW titles AS SELECT title FROM items;
+		INSERT INTO items VALUES (1, 'one'), (2, 'two');`)
+
+	sharedPath := filepath.Join(dir, SharedDatabaseFile)
+	migrated, err := MigrateToShared(sharedPath, []string{jobsPath, filepath.Join(dir, "missing.db"), itemsPath})
+	require.NoError(t, err)
+	require.Len(t, migrated, 2)
+	assert.Equal(t, map[string]int64{"jobs": 2, "job_events": 3}, migrated[0].Tables)
+	assert.Equal(t, map[string]int64{"items": 2}, migrated[1].Tables)
+
+	// Originals are kept as backups
+	assert.NoFileExists(t, jobsPath)
+	assert.FileExists(t, jobsPath+MigratedSuffix)
+	assert.FileExists(t, itemsPath+MigratedSuffix)
+
+	db, err := sql.Open("sqlite3", sharedPath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM titles").Scan(&count))
+	assert.Equal(t, 2, count)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'idx_jobs_state'").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	// AUTOINCREMENT continues after the copied rows
+	result, err := db.Exec("INSERT INTO job_events (job_id) VALUES ('c')")
+	require.NoError(t, err)
+	id, err := result.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), id)
+
+	// A second migration refuses to overwrite the shared database
+	_, err = MigrateToShared(sharedPath, []string{jobsPath + MigratedSuffix})
+	assert.Error(t, err)
+}
+
+func TestMigrateToShared_TableConflict(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.db")
+	second := filepath.Join(dir, "second.db")
+	createTestDatabase(t, first, "CREATE TABLE items (id INTEGER PRIMARY KEY)")
+	createTestDatabase(t, second, "CREATE TABLE items (id INTEGER PRIMARY KEY)")
+
+	sharedPath := filepath.Join(dir, SharedDatabaseFile)
+	_, err := MigrateToShared(sharedPath, []string{first, second})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "table items exists in both")
+
+	// Nothing was moved
+	assert.FileExists(t, first)
+	assert.FileExists(t, second)
+	assert.NoFileExists(t, sharedPath)
+	assert.NoFileExists(t, sharedPath+".migrating")
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// FileModes are the permissions of files and directories created in the
+// storage path. They are applied exactly, whatever the umask, so a storage
+// path shared by a group of OS users can be made group-writable.
+type FileModes struct {
+	File os.FileMode
+	Dir  os.FileMode
+}
+
+// DefaultFileModes returns modes for storage owned by a single user
+func DefaultFileModes() FileModes {
+	return FileModes{File: 0644, Dir: 0755}
+}
+
+var (
+	permissionsMu sync.RWMutex
+	fileModes     = DefaultFileModes()
+	readOnly      bool
+)
+
+// SetFileModes sets the modes of files and directories created afterwards
+func SetFileModes(modes FileModes) {
+	permissionsMu.Lock()
+	defer permissionsMu.Unlock()
+	fileModes = modes
+}
+
+// CurrentFileModes returns the modes used for new files and directories
+func CurrentFileModes() FileModes {
+	permissionsMu.RLock()
+	defer permissionsMu.RUnlock()
+	return fileModes
+}
+
+// SetReadOnly makes databases open read-only and refuses to create them
+func SetReadOnly(enabled bool) {
+	permissionsMu.Lock()
+	defer permissionsMu.Unlock()
+	readOnly = enabled
+}
+
+// ReadOnly reports whether storage is opened read-only
+func ReadOnly() bool {
+	permissionsMu.RLock()
+	defer permissionsMu.RUnlock()
+	return readOnly
+}
+
+// ParseFileMode parses an octal mode such as "0664"
+func ParseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid file mode %q (expected octal, e.g. 0664)", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// PermissionError reports storage the current user cannot write to
+type PermissionError struct {
+	Path string
+	Err  error
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("no write permission for %s: ask its owner for write access (see storage.file_mode and storage.dir_mode for shared storage) or start with --read-only", e.Path)
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+// permissionError wraps permission failures on path in a PermissionError
+func permissionError(path string, err error) error {
+	if errors.Is(err, fs.ErrPermission) {
+		return &PermissionError{Path: path, Err: err}
+	}
+	return err
+}
+
+// MkdirAll creates dir and any missing parents with the directory mode
+func MkdirAll(dir string) error {
+	// Find the directories that do not exist yet so only those are chmodded
+	var created []string
+	for path := filepath.Clean(dir); ; path = filepath.Dir(path) {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		created = append(created, path)
+		if parent := filepath.Dir(path); parent == path {
+			break
+		}
+	}
+
+	mode := CurrentFileModes().Dir
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return permissionError(dir, err)
+	}
+	for _, path := range created {
+		if err := os.Chmod(path, mode); err != nil {
+			return permissionError(path, err)
+		}
+	}
+	return nil
+}
+
+// WriteFile writes data to path, creating it with the file mode
+func WriteFile(path string, data []byte) error {
+	file, err := OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// OpenFile opens path with flag, which must include os.O_CREATE; a file it
+// creates gets the file mode
+func OpenFile(path string, flag int) (*os.File, error) {
+	_, statErr := os.Stat(path)
+	mode := CurrentFileModes().File
+
+	file, err := os.OpenFile(path, flag, mode)
+	if err != nil {
+		return nil, permissionError(path, err)
+	}
+	if os.IsNotExist(statErr) {
+		if err := file.Chmod(mode); err != nil {
+			file.Close()
+			return nil, permissionError(path, err)
+		}
+	}
+	return file, nil
+}
+
+// CheckWritable returns a PermissionError when the current user cannot
+// create files in dir, creating dir first if it is missing
+func CheckWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return MkdirAll(dir)
+		}
+		return permissionError(dir, err)
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
+
+// PrepareDatabase makes sure a database file can be opened by the current
+// user before SQLite opens it. A new database is created empty with the file
+// mode; SQLite gives its journal and WAL files the same mode. In read-only
+// mode the database must already exist.
+func PrepareDatabase(path string) error {
+	if ReadOnly() {
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("database %s does not exist and cannot be created in read-only mode", path)
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := MkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	file, err := OpenFile(path, os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// ReadOnlyDSN returns a connection string that opens path without writing,
+// for use in read-only mode
+func ReadOnlyDSN(path string) string {
+	return "file:" + path + "?mode=ro&_busy_timeout=5000"
+}
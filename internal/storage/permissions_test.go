@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFileMode(t *testing.T) {
+	mode, err := ParseFileMode("0664")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0664), mode)
+
+	mode, err = ParseFileMode("775")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0775), mode)
+
+	for _, invalid := range []string{"", "rw-r--r--", "0999", "1777"} {
+		_, err := ParseFileMode(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestFileModesIgnoreUmask(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no Unix file modes")
+	}
+	defer SetFileModes(DefaultFileModes())
+	SetFileModes(FileModes{File: 0660, Dir: 0770})
+
+	// A restrictive umask would otherwise remove the group permissions
+	previous := SetUmask(0077)
+	defer SetUmask(previous)
+
+	dir := filepath.Join(t.TempDir(), "shared", "logs")
+	require.NoError(t, MkdirAll(dir))
+	for _, path := range []string{dir, filepath.Dir(dir)} {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0770), info.Mode().Perm(), path)
+	}
+
+	path := filepath.Join(dir, "state.json")
+	require.NoError(t, WriteFile(path, []byte("{}")))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+
+	// Existing files keep the mode they have
+	require.NoError(t, os.Chmod(path, 0600))
+	require.NoError(t, WriteFile(path, []byte("{}")))
+	info, err = os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestPrepareDatabase(t *testing.T) {
+	defer SetReadOnly(false)
+	path := filepath.Join(t.TempDir(), "data", "jobs.db")
+
+	SetReadOnly(true)
+	err := PrepareDatabase(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read-only")
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	SetReadOnly(false)
+	require.NoError(t, PrepareDatabase(path))
+	createTestDatabase(t, path, `CREATE TABLE jobs (id TEXT PRIMARY KEY); INSERT INTO jobs VALUES ('a');`)
+
+	// Read-only connections read the database but refuse writes
+	SetReadOnly(true)
+	require.NoError(t, PrepareDatabase(path))
+	db, err := sql.Open(DriverName, DatabaseDSN(path))
+	require.NoError(t, err)
+	defer db.Close()
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM jobs").Scan(&count))
+	assert.Equal(t, 1, count)
+	_, err = db.Exec("INSERT INTO jobs VALUES ('b')")
+	assert.Error(t, err)
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, CheckWritable(dir))
+
+	missing := filepath.Join(dir, "new")
+	assert.NoError(t, CheckWritable(missing))
+	assert.DirExists(t, missing)
+
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for this user")
+	}
+	locked := filepath.Join(dir, "locked")
+	require.NoError(t, os.Mkdir(locked, 0555))
+	defer os.Chmod(locked, 0755)
+
+	err := CheckWritable(locked)
+	var denied *PermissionError
+	require.True(t, errors.As(err, &denied), "error = %v", err)
+	assert.Equal(t, locked, denied.Path)
+	assert.Contains(t, err.Error(), "--read-only")
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// anyTable marks a cached query whose tables could not be determined; it is
+// purged by a write to any table
+const anyTable = "*"
+
+// queryHash returns the query_cache key of a query
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(query)))
+	return hex.EncodeToString(sum[:])
+}
+
+// StoreCachedQuery saves a query result in query_cache together with the
+// tables it reads, so writes to those tables purge it
+func (s *SQLiteStorage) StoreCachedQuery(query string, result QueryResult, ttl time.Duration) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode cached result: %w", err)
+	}
+
+	tables := ReferencedTables(query)
+	if len(tables) == 0 {
+		tables = []string{anyTable}
+	}
+
+	hash := queryHash(query)
+	now := time.Now()
+	return s.Write(func(conn *sql.DB) error {
+		tx, err := conn.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO query_cache
+			(query_hash, query_text, result_data, created_at, expires_at, hit_count, last_accessed)
+			VALUES (?, ?, ?, ?, ?, 0, ?)`, hash, query, string(data), now, now.Add(ttl), now); err != nil {
+			return fmt.Errorf("failed to store cached result: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM query_cache_tables WHERE query_hash = ?", hash); err != nil {
+			return fmt.Errorf("failed to store cached tables: %w", err)
+		}
+		for _, table := range tables {
+			if _, err := tx.Exec("INSERT INTO query_cache_tables (query_hash, table_name) VALUES (?, ?)", hash, table); err != nil {
+				return fmt.Errorf("failed to store cached tables: %w", err)
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+// CachedQuery returns an unexpired result saved by StoreCachedQuery
+func (s *SQLiteStorage) CachedQuery(query string) (QueryResult, bool) {
+	conn, err := s.GetConnection()
+	if err != nil {
+		return QueryResult{}, false
+	}
+	defer s.ReleaseConnection(conn)
+
+	var data string
+	err = conn.QueryRow("SELECT result_data FROM query_cache WHERE query_hash = ? AND expires_at > ?",
+		queryHash(query), time.Now()).Scan(&data)
+	if err != nil {
+		atomic.AddInt64(&s.metrics.cacheMisses, 1)
+		return QueryResult{}, false
+	}
+
+	var result QueryResult
+	decoder := json.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&result); err != nil {
+		atomic.AddInt64(&s.metrics.cacheMisses, 1)
+		return QueryResult{}, false
+	}
+	for _, row := range result.Rows {
+		for i, value := range row {
+			row[i] = decodeCachedValue(value)
+		}
+	}
+
+	atomic.AddInt64(&s.metrics.cacheHits, 1)
+	result.FromCache = true
+	return result, true
+}
+
+// InvalidateCachedQueries purges query_cache entries that read any of the
+// given tables, or every entry when no tables are given, and returns the
+// number of entries removed
+func (s *SQLiteStorage) InvalidateCachedQueries(tables ...string) (int64, error) {
+	tables = normalizeTables(tables)
+
+	var removed int64
+	err := s.Write(func(conn *sql.DB) error {
+		tx, err := conn.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		var result sql.Result
+		if len(tables) == 0 {
+			result, err = tx.Exec("DELETE FROM query_cache")
+		} else {
+			args := []interface{}{anyTable}
+			for _, table := range tables {
+				args = append(args, table)
+			}
+			result, err = tx.Exec(`DELETE FROM query_cache WHERE query_hash IN
+				(SELECT query_hash FROM query_cache_tables WHERE table_name IN (?`+strings.Repeat(", ?", len(tables))+`))`, args...)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to purge cached results: %w", err)
+		}
+		removed, _ = result.RowsAffected()
+
+		if _, err := tx.Exec("DELETE FROM query_cache_tables WHERE query_hash NOT IN (SELECT query_hash FROM query_cache)"); err != nil {
+			return fmt.Errorf("failed to purge cached tables: %w", err)
+		}
+		return tx.Commit()
+	})
+	return removed, err
+}
+
+// decodeCachedValue turns a JSON number back into int64 or float64
+func decodeCachedValue(value interface{}) interface{} {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if i, err := number.Int64(); err == nil {
+		return i
+	}
+	f, _ := number.Float64()
+	return f
+}
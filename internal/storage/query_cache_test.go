@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_CachedQueryInvalidation(t *testing.T) {
+	storage := NewSQLiteStorage(2)
+	require.NoError(t, storage.Initialize(t.TempDir()))
+	defer storage.Close()
+
+	itemsQuery := "SELECT id, score FROM items WHERE type = 'story'"
+	metadataQuery := "SELECT value FROM download_metadata"
+	require.NoError(t, storage.StoreCachedQuery(itemsQuery, QueryResult{
+		Columns: []string{"id", "score"},
+		Rows:    [][]interface{}{{int64(1), 2.5}},
+		Count:   1,
+	}, time.Minute))
+	require.NoError(t, storage.StoreCachedQuery(metadataQuery, QueryResult{Columns: []string{"value"}}, time.Minute))
+
+	cached, ok := storage.CachedQuery(itemsQuery)
+	require.True(t, ok)
+	assert.True(t, cached.FromCache)
+	assert.Equal(t, [][]interface{}{{int64(1), 2.5}}, cached.Rows)
+
+	// A write to items purges only the query reading items
+	_, err := storage.Exec("INSERT INTO items (id, type) VALUES (1, 'story')")
+	require.NoError(t, err)
+
+	_, ok = storage.CachedQuery(itemsQuery)
+	assert.False(t, ok)
+	_, ok = storage.CachedQuery(metadataQuery)
+	assert.True(t, ok)
+
+	// Writes reported by other components purge too
+	NotifyTableWrites("hackernews", "download_metadata")
+	_, ok = storage.CachedQuery(metadataQuery)
+	assert.False(t, ok)
+
+	result, err := storage.Query("SELECT COUNT(*) FROM query_cache_tables")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Rows[0][0])
+}
+
+func TestSQLiteStorage_CachedQueryExpires(t *testing.T) {
+	storage := NewSQLiteStorage(1)
+	require.NoError(t, storage.Initialize(t.TempDir()))
+	defer storage.Close()
+
+	require.NoError(t, storage.StoreCachedQuery("SELECT 1", QueryResult{}, -time.Second))
+	_, ok := storage.CachedQuery("SELECT 1")
+	assert.False(t, ok)
+
+	// Queries without tables are purged by any write
+	require.NoError(t, storage.StoreCachedQuery("SELECT 1", QueryResult{}, time.Minute))
+	removed, err := storage.InvalidateCachedQueries("items")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+}
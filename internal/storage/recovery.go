@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// VerifyIntegrity runs SQLite's integrity check and returns an error listing any problems
+func (s *SQLiteStorage) VerifyIntegrity() error {
+	conn, err := s.GetConnection()
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer s.ReleaseConnection(conn)
+
+	return checkIntegrity(conn)
+}
+
+// RepairIfNeeded rebuilds indexes and the database file when the integrity
+// check fails, then verifies the result
+func (s *SQLiteStorage) RepairIfNeeded() error {
+	if err := s.VerifyIntegrity(); err == nil {
+		return nil
+	}
+
+	if err := s.Write(repair); err != nil {
+		return err
+	}
+
+	if err := s.VerifyIntegrity(); err != nil {
+		return fmt.Errorf("database still corrupt after repair: %w", err)
+	}
+	return nil
+}
+
+// checkIntegrity runs SQLite's integrity check on db
+func checkIntegrity(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return fmt.Errorf("failed to read integrity check result: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read integrity check result: %w", err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// repair rebuilds the indexes and the database file of db
+func repair(db *sql.DB) error {
+	for _, stmt := range []string{"REINDEX", "VACUUM", "PRAGMA wal_checkpoint(TRUNCATE)"} {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to run %s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// ValidateConnection checks that a pooled connection can reach the database
+func (s *SQLiteStorage) ValidateConnection() error {
+	conn, err := s.GetConnection()
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer s.ReleaseConnection(conn)
+
+	if err := conn.Ping(); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
+
+// RecoverableDB adapts an open database, such as a data source's or the
+// jobs database, to the startup recovery handlers
+type RecoverableDB struct {
+	db *sql.DB
+}
+
+// NewRecoverableDB wraps db for the startup recovery handlers
+func NewRecoverableDB(db *sql.DB) *RecoverableDB {
+	return &RecoverableDB{db: db}
+}
+
+// Initialize checks that the database, which is already open, can be reached
+func (r *RecoverableDB) Initialize() error {
+	return r.ValidateConnection()
+}
+
+// VerifyIntegrity runs SQLite's integrity check and returns an error listing any problems
+func (r *RecoverableDB) VerifyIntegrity() error {
+	return checkIntegrity(r.db)
+}
+
+// RepairIfNeeded rebuilds indexes and the database file when the integrity
+// check fails, then verifies the result
+func (r *RecoverableDB) RepairIfNeeded() error {
+	if err := checkIntegrity(r.db); err == nil {
+		return nil
+	}
+
+	if err := repair(r.db); err != nil {
+		return err
+	}
+
+	if err := checkIntegrity(r.db); err != nil {
+		return fmt.Errorf("database still corrupt after repair: %w", err)
+	}
+	return nil
+}
+
+// ValidateConnection checks that the database can be reached
+func (r *RecoverableDB) ValidateConnection() error {
+	if err := r.db.Ping(); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	return nil
+}
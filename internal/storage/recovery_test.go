@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/shutdown"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// corruptIndexDB returns a database whose index no longer matches its table
+func corruptIndexDB(t *testing.T) *sql.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "corrupt.sqlite")
+	db, err := sql.Open(DriverName, path)
+	require.NoError(t, err)
+	for _, stmt := range []string{
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, a INTEGER, b INTEGER)",
+		"CREATE INDEX items_a ON items (a)",
+		"INSERT INTO items (a, b) VALUES (1, 30), (2, 20), (3, 10)",
+		// The index keeps its entries for a but claims to cover b
+		"PRAGMA writable_schema = ON",
+		"UPDATE sqlite_master SET sql = 'CREATE INDEX items_a ON items (b)' WHERE name = 'items_a'",
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	require.NoError(t, db.Close())
+
+	db, err = sql.Open(DriverName, path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRecoverableDB_RepairsAfterCrash(t *testing.T) {
+	log.InitLogger(false)
+	db := NewRecoverableDB(corruptIndexDB(t))
+	require.Error(t, db.VerifyIntegrity())
+
+	state, err := shutdown.NewStateManager(t.TempDir(), 1)
+	require.NoError(t, err)
+	// The previous run did not record a clean shutdown
+	require.NoError(t, state.SaveApplicationState(shutdown.ApplicationState{Timestamp: time.Now()}))
+
+	recovery := shutdown.NewRecovery(state, shutdown.DefaultRecoveryConfig())
+	require.NoError(t, recovery.RegisterRecoveryHandler("database", shutdown.NewDatabaseRecoveryHandler(db, 0)))
+	require.NoError(t, recovery.PerformRecovery())
+
+	assert.NoError(t, db.VerifyIntegrity())
+	assert.Empty(t, recovery.GetRecoveryStatus().Errors)
+}
+
+func TestRecoverableDB_SkipsCheckAfterCleanShutdown(t *testing.T) {
+	log.InitLogger(false)
+	db := NewRecoverableDB(corruptIndexDB(t))
+
+	state, err := shutdown.NewStateManager(t.TempDir(), 1)
+	require.NoError(t, err)
+	require.NoError(t, state.SaveApplicationState(shutdown.ApplicationState{
+		Application: shutdown.ApplicationInfo{CleanShutdown: true},
+		Timestamp:   time.Now(),
+	}))
+
+	recovery := shutdown.NewRecovery(state, shutdown.DefaultRecoveryConfig())
+	require.NoError(t, recovery.RegisterRecoveryHandler("database", shutdown.NewDatabaseRecoveryHandler(db, 0)))
+	require.NoError(t, recovery.PerformRecovery())
+
+	assert.Error(t, db.VerifyIntegrity(), "a clean shutdown is not checked")
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Querier runs queries, as *sql.DB and *sql.Tx do
+type Querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// QueryAs runs a query and scans each row into a T, a struct whose fields
+// are matched to the columns by their `db` tags, such as `db:"item_id"`.
+// Fields of embedded structs are matched too; fields without a tag or
+// tagged "-" are left alone. A column without a field is an error, so a
+// query and its struct cannot drift apart unnoticed. Other types, such as
+// int64, time.Time or sql.NullString, take the only column of a query.
+func QueryAs[T any](db Querier, query string, args ...interface{}) ([]T, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return ScanAll[T](rows)
+}
+
+// ScanAll scans the remaining rows into values of T as QueryAs does
+func ScanAll[T any](rows *sql.Rows) ([]T, error) {
+	var zero T
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	if !scansFields(reflect.TypeOf(zero)) {
+		return scanValues[T](rows, len(columns))
+	}
+
+	fields, err := structFields(reflect.TypeOf(zero))
+	if err != nil {
+		return nil, err
+	}
+	indexes := make([][]int, len(columns))
+	for i, column := range columns {
+		index, ok := fields[strings.ToLower(column)]
+		if !ok {
+			return nil, fmt.Errorf("column %q has no db field in %T", column, zero)
+		}
+		indexes[i] = index
+	}
+
+	var results []T
+	targets := make([]interface{}, len(columns))
+	for rows.Next() {
+		var value T
+		row := reflect.ValueOf(&value).Elem()
+		for i, index := range indexes {
+			targets[i] = row.FieldByIndex(index).Addr().Interface()
+		}
+		if err := rows.Scan(targets...); err != nil {
+			return nil, fmt.Errorf("failed to scan %T: %w", value, err)
+		}
+		results = append(results, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return results, nil
+}
+
+// scanValues scans rows of a single column into values of T
+func scanValues[T any](rows *sql.Rows, columns int) ([]T, error) {
+	var results []T
+	if columns != 1 {
+		var zero T
+		return nil, fmt.Errorf("cannot scan %d columns into %T", columns, zero)
+	}
+	for rows.Next() {
+		var value T
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("failed to scan %T: %w", value, err)
+		}
+		results = append(results, value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return results, nil
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// scansFields reports whether rows are scanned into the fields of t rather
+// than into a t as a whole
+func scansFields(t reflect.Type) bool {
+	return t != nil && t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType)
+}
+
+// scanFields caches the field indexes of the struct types scanned, by
+// lowercase column name
+var scanFields sync.Map
+
+// structFields returns the indexes of the db-tagged fields of a struct
+// type, by lowercase column name
+func structFields(t reflect.Type) (map[string][]int, error) {
+	if cached, ok := scanFields.Load(t); ok {
+		return cached.(map[string][]int), nil
+	}
+	fields := make(map[string][]int)
+	var collect func(t reflect.Type, parent []int) error
+	collect = func(t reflect.Type, parent []int) error {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			index := append(append([]int(nil), parent...), i)
+			tag := field.Tag.Get("db")
+			if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+				if err := collect(field.Type, index); err != nil {
+					return err
+				}
+				continue
+			}
+			if tag == "" || tag == "-" || !field.IsExported() {
+				continue
+			}
+			name := strings.ToLower(tag)
+			if _, ok := fields[name]; ok {
+				return fmt.Errorf("cannot scan rows into %v: db tag %q is used twice", t, tag)
+			}
+			fields[name] = index
+		}
+		return nil
+	}
+	if err := collect(t, nil); err != nil {
+		return nil, err
+	}
+	scanFields.Store(t, fields)
+	return fields, nil
+}
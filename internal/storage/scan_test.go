@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type scanAudit struct {
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type scanItem struct {
+	scanAudit
+	ID       int64          `db:"id"`
+	Title    string         `db:"title"`
+	Score    *int64         `db:"score"`
+	Author   sql.NullString `db:"by"`
+	Rendered string         `db:"-"`
+}
+
+func TestQueryAs(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "scan.sqlite"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE items (id INTEGER, title TEXT, score INTEGER, by TEXT, created_at DATETIME);
+	INSERT INTO items VALUES (1, 'First', 10, 'pg', '2024-01-02 03:04:05'), (2, 'Second', NULL, NULL, '2024-01-03 00:00:00')`)
+	require.NoError(t, err)
+
+	items, err := QueryAs[scanItem](db, "SELECT id, title, score, BY, created_at FROM items ORDER BY id")
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "First", items[0].Title)
+	require.NotNil(t, items[0].Score)
+	assert.Equal(t, int64(10), *items[0].Score)
+	assert.Equal(t, "pg", items[0].Author.String)
+	assert.Equal(t, 2024, items[0].CreatedAt.Year(), "fields of embedded structs are scanned")
+	assert.Nil(t, items[1].Score, "NULL leaves pointer fields nil")
+	assert.False(t, items[1].Author.Valid)
+
+	// Selecting a subset of the fields leaves the others zero
+	items, err = QueryAs[scanItem](db, "SELECT id FROM items WHERE id = ?", 2)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, int64(2), items[0].ID)
+	assert.Empty(t, items[0].Title)
+
+	_, err = QueryAs[scanItem](db, "SELECT id, title AS name FROM items")
+	assert.ErrorContains(t, err, `column "name" has no db field`)
+
+	// Other types take the only column
+	ids, err := QueryAs[int64](db, "SELECT id FROM items ORDER BY id DESC")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2, 1}, ids)
+	authors, err := QueryAs[sql.NullString](db, "SELECT by FROM items ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, []sql.NullString{{String: "pg", Valid: true}, {}}, authors)
+	_, err = QueryAs[int64](db, "SELECT id, title FROM items")
+	assert.Error(t, err)
+
+	// A transaction queries as the database does
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+	titles, err := QueryAs[string](tx, "SELECT title FROM items WHERE id > ?", 5)
+	require.NoError(t, err)
+	assert.Empty(t, titles)
+}
+
+func TestQueryAs_DuplicateTag(t *testing.T) {
+	type duplicate struct {
+		A int `db:"id"`
+		B int `db:"ID"`
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "scan.sqlite"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = QueryAs[duplicate](db, "SELECT 1 AS id")
+	assert.ErrorContains(t, err, "used twice")
+}
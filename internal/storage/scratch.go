@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+)
+
+// Scratch is a database connection kept for one interactive session. TEMP
+// tables created through it, by CREATE TEMP TABLE or Materialize, last until
+// the session ends and are never written to the database file.
+type Scratch struct {
+	conn *sql.Conn
+}
+
+// ScratchTable is a temporary table of a scratch connection
+type ScratchTable struct {
+	Name string
+	Rows int64
+}
+
+// NewScratch takes a connection from db for a session
+func NewScratch(db *sql.DB) (*Scratch, error) {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scratch connection: %w", err)
+	}
+	return &Scratch{conn: conn}, nil
+}
+
+// Query runs a statement on the session's connection and returns its rows;
+// byte slices are returned as strings
+func (s *Scratch) Query(query string) ([]string, [][]interface{}, error) {
+	columns, rows, _, err := s.QueryLimited(query, datasource.ResultLimits{})
+	return columns, rows, err
+}
+
+// QueryLimited runs a statement like Query, reading rows only until the
+// result would exceed limits; truncated reports that more rows exist
+func (s *Scratch) QueryLimited(query string, limits datasource.ResultLimits) (columns []string, results [][]interface{}, truncated bool, err error) {
+	rows, err := s.conn.QueryContext(context.Background(), query)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err = rows.Columns()
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	var size int64
+	for rows.Next() {
+		if !limits.Allows(len(results)+1, size) {
+			return columns, results, true, nil
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, false, fmt.Errorf("failed to scan row: %w", err)
+		}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		size += datasource.RowSize(values)
+		if !limits.Allows(len(results)+1, size) {
+			return columns, results, true, nil
+		}
+		results = append(results, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, false, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return columns, results, false, nil
+}
+
+// Materialize stores rows in a TEMP table of the session, typing columns by
+// their first non-null value. An existing table is only replaced when
+// replace is set.
+func (s *Scratch) Materialize(name string, columns []string, rows [][]interface{}, replace bool) error {
+	if !derivedTableNamePattern.MatchString(name) || strings.HasPrefix(strings.ToLower(name), "sqlite_") {
+		return fmt.Errorf("invalid table name %q", name)
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("the result has no columns")
+	}
+
+	ctx := context.Background()
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if _, err := tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS temp.%s", quoteIdentifier(name))); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", name, err)
+		}
+	}
+
+	definitions := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	seen := make(map[string]int)
+	for i, column := range columns {
+		// Joins often return the same column name twice
+		key := strings.ToLower(column)
+		seen[key]++
+		if seen[key] > 1 {
+			column = fmt.Sprintf("%s_%d", column, seen[key])
+		}
+		definitions[i] = strings.TrimSpace(quoteIdentifier(column) + " " + scratchColumnType(rows, i))
+		placeholders[i] = "?"
+	}
+	create := fmt.Sprintf("CREATE TEMP TABLE %s (%s)", quoteIdentifier(name), strings.Join(definitions, ", "))
+	if _, err := tx.Exec(create); err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+
+	insert, err := tx.Prepare(fmt.Sprintf("INSERT INTO temp.%s VALUES (%s)", quoteIdentifier(name), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer insert.Close()
+	for _, row := range rows {
+		if _, err := insert.Exec(row...); err != nil {
+			return fmt.Errorf("failed to insert row: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Tables lists the session's TEMP tables with their row counts
+func (s *Scratch) Tables() ([]ScratchTable, error) {
+	_, rows, err := s.Query("SELECT name FROM sqlite_temp_master WHERE type = 'table' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make([]ScratchTable, 0, len(rows))
+	for _, row := range rows {
+		name := fmt.Sprint(row[0])
+		var count int64
+		query := fmt.Sprintf("SELECT COUNT(*) FROM temp.%s", quoteIdentifier(name))
+		if err := s.conn.QueryRowContext(context.Background(), query).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", name, err)
+		}
+		tables = append(tables, ScratchTable{Name: name, Rows: count})
+	}
+	return tables, nil
+}
+
+// Drop removes a TEMP table of the session
+func (s *Scratch) Drop(name string) error {
+	var exists int
+	err := s.conn.QueryRowContext(context.Background(),
+		"SELECT COUNT(*) FROM sqlite_temp_master WHERE type = 'table' AND name = ?", name).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to look up %s: %w", name, err)
+	}
+	if exists == 0 {
+		return fmt.Errorf("no scratch table named %s", name)
+	}
+	if _, err := s.conn.ExecContext(context.Background(), fmt.Sprintf("DROP TABLE temp.%s", quoteIdentifier(name))); err != nil {
+		return fmt.Errorf("failed to drop %s: %w", name, err)
+	}
+	return nil
+}
+
+// Close ends the session, dropping its TEMP tables
+func (s *Scratch) Close() error {
+	return s.conn.Close()
+}
+
+// scratchColumnType returns the SQLite type of a column from its first
+// non-null value, or "" to leave the column untyped
+func scratchColumnType(rows [][]interface{}, column int) string {
+	for _, row := range rows {
+		if column >= len(row) || row[column] == nil {
+			continue
+		}
+		switch row[column].(type) {
+		case int, int8, int16, int32, int64, uint8, uint16, uint32, uint64, bool:
+			return "INTEGER"
+		case float32, float64:
+			return "REAL"
+		case string, time.Time:
+			return "TEXT"
+		case []byte:
+			return "BLOB"
+		}
+		return ""
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScratch_TempTablesStayInTheSession(t *testing.T) {
+	db, _ := newDerivedTestDB(t)
+	db.SetMaxOpenConns(4)
+
+	scratch, err := NewScratch(db)
+	require.NoError(t, err)
+
+	_, _, err = scratch.Query("CREATE TEMP TABLE stories AS SELECT id, time FROM items WHERE type = 'story'")
+	require.NoError(t, err)
+	columns, rows, err := scratch.Query("SELECT COUNT(*) AS n FROM stories")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"n"}, columns)
+	assert.Equal(t, int64(2), rows[0][0])
+
+	require.NoError(t, scratch.Materialize("top", []string{"id", "title", "id"},
+		[][]interface{}{{int64(1), "first", int64(7)}, {int64(2), nil, int64(8)}}, false))
+	_, rows, err = scratch.Query("SELECT id_2, title FROM top ORDER BY id")
+	require.NoError(t, err)
+	assert.Equal(t, [][]interface{}{{int64(7), "first"}, {int64(8), nil}}, rows)
+
+	assert.Error(t, scratch.Materialize("top", []string{"id"}, nil, false), "existing tables are kept")
+	require.NoError(t, scratch.Materialize("top", []string{"id"}, [][]interface{}{{int64(3)}}, true))
+	assert.Error(t, scratch.Materialize("bad name", []string{"id"}, nil, false))
+
+	tables, err := scratch.Tables()
+	require.NoError(t, err)
+	assert.Equal(t, []ScratchTable{{Name: "stories", Rows: 2}, {Name: "top", Rows: 1}}, tables)
+
+	// Other connections, and the database file, never see the tables
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name IN ('stories', 'top')").Scan(&count))
+	assert.Equal(t, 0, count)
+
+	require.NoError(t, scratch.Drop("stories"))
+	assert.Error(t, scratch.Drop("stories"))
+	require.NoError(t, scratch.Close())
+}
+
+func TestScratch_ReadOnlyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ro.sqlite")
+	writer, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = writer.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY); INSERT INTO items VALUES (1), (2)")
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	db, err := sql.Open("sqlite3", ReadOnlyDSN(path))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	scratch, err := NewScratch(db)
+	require.NoError(t, err)
+	defer scratch.Close()
+
+	_, _, err = scratch.Query("CREATE TEMP TABLE ids AS SELECT id FROM items")
+	require.NoError(t, err, "temp tables work on a read-only database")
+	_, rows, err := scratch.Query("SELECT COUNT(*) FROM ids")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows[0][0])
+}
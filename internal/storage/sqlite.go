@@ -12,17 +12,27 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// SQLiteStorage implements ConcurrentStorage with SQLite backend
+// SQLiteStorage implements ConcurrentStorage with SQLite backend. Queries
+// use a pool of read-only connections; every write goes through a single
+// writer connection and its queue.
+//
+// It backs TUIStorageImpl and the TUIQueryEngine, which the application does
+// not create yet: data sources open and write their own databases, such as
+// hackernews.Storage.
 type SQLiteStorage struct {
 	dbPath            string
 	pool              *connectionPool
+	writer            *writeQueue
 	metrics           *queryMetrics
 	progressCallbacks map[string]ProgressCallback
 	callbackMutex     sync.RWMutex
 	closed            int32
+	monitorConn       *sql.DB
+	monitor           *DBHealthMonitor
+	stopInvalidation  func()
 }
 
-// connectionPool manages database connections for concurrent access
+// connectionPool manages the reader connections
 type connectionPool struct {
 	connections chan *sql.DB
 	maxSize     int
@@ -52,12 +62,17 @@ type queryMetrics struct {
 	mutex             sync.RWMutex
 }
 
-// sqliteTransaction implements the Transaction interface
+// sqliteTransaction implements the Transaction interface. It holds the
+// writer connection until it is committed or rolled back.
 type sqliteTransaction struct {
-	tx *sql.Tx
+	tx       *sql.Tx
+	finished chan struct{}
+	once     sync.Once
+	written  []string
 }
 
-// NewSQLiteStorage creates a new SQLite storage instance with connection pooling
+// NewSQLiteStorage creates a new SQLite storage instance with maxConnections
+// reader connections and one writer connection
 func NewSQLiteStorage(maxConnections int) *SQLiteStorage {
 	return &SQLiteStorage{
 		pool: &connectionPool{
@@ -76,29 +91,53 @@ func (s *SQLiteStorage) Initialize(storagePath string) error {
 	}
 
 	// Ensure directory exists
-	if err := os.MkdirAll(storagePath, 0755); err != nil {
+	if err := MkdirAll(storagePath); err != nil {
 		return fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
 	s.dbPath = filepath.Join(storagePath, "pubdatahub.sqlite")
+	if err := PrepareDatabase(s.dbPath); err != nil {
+		return fmt.Errorf("failed to create database: %w", err)
+	}
 
-	// Initialize connection pool
-	if err := s.initializePool(); err != nil {
-		return fmt.Errorf("failed to initialize connection pool: %w", err)
+	// The writer creates the database and schema before the readers open it
+	writerConn, err := s.createConnection(false)
+	if err != nil {
+		return fmt.Errorf("failed to create writer connection: %w", err)
 	}
+	s.writer = newWriteQueue(writerConn, DefaultWriteQueueSize)
 
 	// Run migrations
 	if err := s.migrate(); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// Initialize connection pool
+	if err := s.initializePool(); err != nil {
+		return fmt.Errorf("failed to initialize connection pool: %w", err)
+	}
+
+	// Checkpoint the WAL on a dedicated connection so it never waits on the pool
+	monitorConn, err := s.createConnection(false)
+	if err != nil {
+		return fmt.Errorf("failed to create monitor connection: %w", err)
+	}
+	s.monitorConn = monitorConn
+	s.monitor = NewDBHealthMonitor(monitorConn, s.dbPath, DefaultDBHealthConfig())
+	s.monitor.Start()
+
+	// Purge cached results when any component reports table writes
+	s.stopInvalidation = OnTableWrites(func(source string, tables []string) {
+		s.InvalidateCachedQueries(tables...)
+	})
+
 	return nil
 }
 
-// initializePool creates the initial database connections
+// initializePool creates the reader connections
 func (s *SQLiteStorage) initializePool() error {
 	for i := 0; i < s.pool.maxSize; i++ {
-		conn, err := s.createConnection()
+		conn, err := s.createConnection(true)
 		if err != nil {
 			return fmt.Errorf("failed to create connection %d: %w", i, err)
 		}
@@ -110,12 +149,20 @@ func (s *SQLiteStorage) initializePool() error {
 	return nil
 }
 
-// createConnection creates a new SQLite database connection with optimal settings
-func (s *SQLiteStorage) createConnection() (*sql.DB, error) {
+// createConnection creates a new SQLite database connection with optimal
+// settings. Read-only connections refuse writes so they can only reach the
+// database through the write queue.
+func (s *SQLiteStorage) createConnection(readOnly bool) (*sql.DB, error) {
 	// SQLite connection string with performance optimizations
 	connStr := fmt.Sprintf("%s?_journal_mode=WAL&_synchronous=NORMAL&_cache_size=10000&_foreign_keys=ON&_busy_timeout=30000", s.dbPath)
+	if readOnly {
+		connStr += "&_query_only=1"
+	} else {
+		// Take the write lock up front rather than upgrading a read lock
+		connStr += "&_txlock=immediate"
+	}
 
-	db, err := sql.Open("sqlite3", connStr)
+	db, err := sql.Open(DriverName, connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -136,12 +183,6 @@ func (s *SQLiteStorage) createConnection() (*sql.DB, error) {
 
 // migrate creates or updates the database schema
 func (s *SQLiteStorage) migrate() error {
-	conn, err := s.GetConnection()
-	if err != nil {
-		return fmt.Errorf("failed to get connection for migration: %w", err)
-	}
-	defer s.ReleaseConnection(conn)
-
 	schema := `
 	-- Core items table (from existing hackernews storage)
 	CREATE TABLE IF NOT EXISTS items (
@@ -186,6 +227,13 @@ func (s *SQLiteStorage) migrate() error {
 		last_accessed DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 
+	-- Tables read by each cached query, purged when those tables change
+	CREATE TABLE IF NOT EXISTS query_cache_tables (
+		query_hash TEXT NOT NULL,
+		table_name TEXT NOT NULL,
+		PRIMARY KEY (query_hash, table_name)
+	);
+
 	-- Download metadata table (from existing hackernews storage)
 	CREATE TABLE IF NOT EXISTS download_metadata (
 		key TEXT PRIMARY KEY,
@@ -215,14 +263,18 @@ func (s *SQLiteStorage) migrate() error {
 	CREATE INDEX IF NOT EXISTS idx_job_progress_status ON job_progress(status);
 	CREATE INDEX IF NOT EXISTS idx_job_progress_data_source ON job_progress(data_source);
 	CREATE INDEX IF NOT EXISTS idx_query_cache_expires ON query_cache(expires_at);
+	CREATE INDEX IF NOT EXISTS idx_query_cache_tables_table ON query_cache_tables(table_name);
 	CREATE INDEX IF NOT EXISTS idx_batch_status_completed ON batch_status(completed, data_source);
 	`
 
-	_, err = conn.Exec(schema)
-	return err
+	return s.Write(func(conn *sql.DB) error {
+		_, err := conn.Exec(schema)
+		return err
+	})
 }
 
-// GetConnection retrieves a connection from the pool
+// GetConnection retrieves a read-only connection from the pool; use Write or
+// Exec to change the database
 func (s *SQLiteStorage) GetConnection() (*sql.DB, error) {
 	if atomic.LoadInt32(&s.closed) == 1 {
 		return nil, fmt.Errorf("storage is closed")
@@ -242,7 +294,7 @@ func (s *SQLiteStorage) GetConnection() (*sql.DB, error) {
 	}
 }
 
-// ReleaseConnection returns a connection to the pool
+// ReleaseConnection returns a reader connection to the pool
 func (s *SQLiteStorage) ReleaseConnection(conn *sql.DB) error {
 	if conn == nil {
 		return nil
@@ -293,6 +345,7 @@ func (s *SQLiteStorage) QueryConcurrent(query string, args ...interface{}) (Quer
 
 	rows, err := conn.Query(query, args...)
 	if err != nil {
+		s.recordError(err)
 		return QueryResult{}, fmt.Errorf("failed to execute query: %w", err)
 	}
 	defer rows.Close()
@@ -360,42 +413,58 @@ func (s *SQLiteStorage) InsertConcurrent(table string, data interface{}) error {
 
 // InsertBatch performs a batch insert operation
 func (s *SQLiteStorage) InsertBatch(table string, data []interface{}) error {
-	conn, err := s.GetConnection()
-	if err != nil {
-		return fmt.Errorf("failed to get connection: %w", err)
-	}
-	defer s.ReleaseConnection(conn)
-
-	tx, err := conn.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer tx.Rollback()
+	return s.Write(func(conn *sql.DB) error {
+		tx, err := conn.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
 
-	// This is a simplified implementation - in practice, you'd need
-	// to handle different data types and generate appropriate SQL
-	for _, item := range data {
-		_ = item // Process each item
-		// Insert logic would go here
-	}
+		// This is a simplified implementation - in practice, you'd need
+		// to handle different data types and generate appropriate SQL
+		for _, item := range data {
+			_ = item // Process each item
+			// Insert logic would go here
+		}
 
-	return tx.Commit()
+		return tx.Commit()
+	})
 }
 
-// BeginTransaction starts a new database transaction
+// BeginTransaction starts a transaction on the writer connection. Other
+// writes wait in the queue until it is committed or rolled back.
 func (s *SQLiteStorage) BeginTransaction() (Transaction, error) {
-	conn, err := s.GetConnection()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get connection: %w", err)
+	if atomic.LoadInt32(&s.closed) == 1 || s.writer == nil {
+		return nil, errStorageClosed
 	}
 
-	tx, err := conn.Begin()
+	begun := make(chan *sql.Tx, 1)
+	finished := make(chan struct{})
+	done, err := s.writer.enqueue(func(conn *sql.DB) error {
+		tx, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		begun <- tx
+
+		select {
+		case <-finished:
+		case <-s.writer.stop:
+			tx.Rollback()
+		}
+		return nil
+	})
 	if err != nil {
-		s.ReleaseConnection(conn)
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	return &sqliteTransaction{tx: tx}, nil
+	select {
+	case tx := <-begun:
+		return &sqliteTransaction{tx: tx, finished: finished}, nil
+	case err := <-done:
+		s.recordError(err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
 }
 
 // RegisterJobProgress registers a progress callback for a job
@@ -414,7 +483,7 @@ func (s *SQLiteStorage) GetStorageStats() StorageStats {
 		TotalRecords:    s.getTotalRecords(),
 		DatabaseSize:    s.getDatabaseSize(),
 		ActiveQueries:   activeQueries,
-		QueuedWrites:    0, // Would track pending writes
+		QueuedWrites:    s.WriteQueueDepth(),
 		ConnectionsUsed: s.getUsedConnections(),
 		ConnectionsMax:  s.pool.maxSize,
 		LastUpdate:      time.Now(),
@@ -462,6 +531,23 @@ func (s *SQLiteStorage) Close() error {
 		return nil // Already closed
 	}
 
+	if s.stopInvalidation != nil {
+		s.stopInvalidation()
+	}
+	// Stop the writer first so an open transaction cannot block the checkpoint
+	if s.writer != nil {
+		s.writer.Close()
+	}
+
+	if s.monitor != nil {
+		s.monitor.Stop()
+		// Leave an empty WAL behind for the next start
+		s.monitor.Checkpoint()
+	}
+	if s.monitorConn != nil {
+		s.monitorConn.Close()
+	}
+
 	// Close all connections in the pool
 	close(s.pool.connections)
 	for conn := range s.pool.connections {
@@ -474,8 +560,30 @@ func (s *SQLiteStorage) Close() error {
 	return nil
 }
 
+// DatabaseHealth returns WAL and lock contention health for the database
+func (s *SQLiteStorage) DatabaseHealth() DBHealth {
+	if s.monitor == nil {
+		return DBHealth{Status: "unknown", LastCheck: time.Now()}
+	}
+	return s.monitor.Health()
+}
+
+// CheckpointWAL forces a wal_checkpoint(TRUNCATE)
+func (s *SQLiteStorage) CheckpointWAL() error {
+	if s.monitor == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	return s.monitor.Checkpoint()
+}
+
 // Helper methods
 
+func (s *SQLiteStorage) recordError(err error) {
+	if s.monitor != nil {
+		s.monitor.RecordError(err)
+	}
+}
+
 func (s *SQLiteStorage) getTotalRecords() int64 {
 	conn, err := s.GetConnection()
 	if err != nil {
@@ -520,7 +628,11 @@ func (s *SQLiteStorage) recordQueryMetrics(query string, duration time.Duration)
 // Transaction implementation
 
 func (tx *sqliteTransaction) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return tx.tx.Exec(query, args...)
+	result, err := tx.tx.Exec(query, args...)
+	if err == nil {
+		tx.written = append(tx.written, WrittenTables(query)...)
+	}
+	return result, err
 }
 
 func (tx *sqliteTransaction) Query(query string, args ...interface{}) (*sql.Rows, error) {
@@ -536,9 +648,25 @@ func (tx *sqliteTransaction) Prepare(query string) (*sql.Stmt, error) {
 }
 
 func (tx *sqliteTransaction) Commit() error {
-	return tx.tx.Commit()
+	err := tx.tx.Commit()
+	// The writer must be free before listeners purge cached results
+	tx.release()
+	if err == nil && len(tx.written) > 0 {
+		NotifyTableWrites("", tx.written...)
+	}
+	return err
 }
 
 func (tx *sqliteTransaction) Rollback() error {
+	defer tx.release()
 	return tx.tx.Rollback()
 }
+
+// release hands the writer connection back to the write queue
+func (tx *sqliteTransaction) release() {
+	tx.once.Do(func() {
+		if tx.finished != nil {
+			close(tx.finished)
+		}
+	})
+}
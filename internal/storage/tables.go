@@ -0,0 +1,288 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// TableWriteListener is called after tables of a data source receive writes.
+// An empty source means the tables may belong to any source, and no tables
+// means every table of the source may have changed.
+type TableWriteListener func(source string, tables []string)
+
+var (
+	listenersMu  sync.RWMutex
+	listeners    = make(map[int]TableWriteListener)
+	nextListener int
+)
+
+// OnTableWrites registers a listener for table writes and returns a function
+// that removes it
+func OnTableWrites(listener TableWriteListener) func() {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+
+	id := nextListener
+	nextListener++
+	listeners[id] = listener
+
+	return func() {
+		listenersMu.Lock()
+		defer listenersMu.Unlock()
+		delete(listeners, id)
+	}
+}
+
+// NotifyTableWrites tells listeners that tables of source have changed. Call
+// it after the write has committed, never while holding a write transaction,
+// since listeners may write to purge cached results.
+func NotifyTableWrites(source string, tables ...string) {
+	listenersMu.RLock()
+	current := make([]TableWriteListener, 0, len(listeners))
+	for _, listener := range listeners {
+		current = append(current, listener)
+	}
+	listenersMu.RUnlock()
+
+	normalized := normalizeTables(tables)
+	for _, listener := range current {
+		listener(source, normalized)
+	}
+}
+
+// ReferencedTables returns the lower-cased names of the tables a statement
+// reads from or joins, without schema qualifiers. Common table expression
+// names are included; they never match a real write.
+func ReferencedTables(statement string) []string {
+	tokens := sqlTokens(statement)
+
+	var tables []string
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i].keyword() {
+		case "from":
+			// FROM a, b AS x, c y
+			for {
+				name, next := sourceTable(tokens, i+1)
+				if name == "" {
+					break
+				}
+				tables = append(tables, name)
+				next = skipAlias(tokens, next)
+				if next >= len(tokens) || tokens[next].text != "," {
+					break
+				}
+				i = next
+			}
+		case "join":
+			if name, _ := sourceTable(tokens, i+1); name != "" {
+				tables = append(tables, name)
+			}
+		case "into", "update":
+			if name, _ := tableName(tokens, skipConflictClause(tokens, i+1)); name != "" {
+				tables = append(tables, name)
+			}
+		}
+	}
+	return normalizeTables(tables)
+}
+
+// WrittenTables returns the lower-cased names of the tables an INSERT,
+// REPLACE, UPDATE, DELETE, CREATE, DROP or ALTER statement changes
+func WrittenTables(statement string) []string {
+	tokens := sqlTokens(statement)
+
+	var tables []string
+	for i := 0; i < len(tokens); i++ {
+		var name string
+		switch tokens[i].keyword() {
+		case "into", "update":
+			name, _ = tableName(tokens, skipConflictClause(tokens, i+1))
+		case "delete":
+			if i+1 < len(tokens) && tokens[i+1].keyword() == "from" {
+				name, _ = tableName(tokens, i+2)
+			}
+		case "to":
+			if i > 0 && tokens[i-1].keyword() == "rename" {
+				name, _ = tableName(tokens, i+1)
+			}
+		case "table":
+			if i > 0 {
+				switch tokens[i-1].keyword() {
+				case "create", "temp", "temporary", "drop", "alter":
+					name, _ = tableName(tokens, skipIfExists(tokens, i+1))
+				}
+			}
+		}
+		if name != "" {
+			tables = append(tables, name)
+		}
+	}
+	return normalizeTables(tables)
+}
+
+// sqlToken is an identifier, keyword or punctuation character of a statement
+type sqlToken struct {
+	text   string
+	quoted bool
+}
+
+// keyword returns the token lower-cased, or "" for quoted identifiers
+func (t sqlToken) keyword() string {
+	if t.quoted {
+		return ""
+	}
+	return strings.ToLower(t.text)
+}
+
+// isName reports whether the token can be a table name
+func (t sqlToken) isName() bool {
+	if t.quoted {
+		return true
+	}
+	r := []rune(t.text)
+	return len(r) > 0 && (unicode.IsLetter(r[0]) || r[0] == '_')
+}
+
+// sqlTokens splits a statement into tokens, skipping string literals,
+// numbers and comments
+func sqlTokens(statement string) []sqlToken {
+	var tokens []sqlToken
+	runes := []rune(statement)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i++
+		case r == '\'':
+			// String literal; '' is an escaped quote
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case r == '"' || r == '`' || r == '[':
+			closing := r
+			if r == '[' {
+				closing = ']'
+			}
+			start := i + 1
+			for i++; i < len(runes) && runes[i] != closing; i++ {
+			}
+			tokens = append(tokens, sqlToken{text: string(runes[start:min(i, len(runes))]), quoted: true})
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$':
+			start := i
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_' || runes[i+1] == '$') {
+				i++
+			}
+			tokens = append(tokens, sqlToken{text: string(runes[start : i+1])})
+		default:
+			tokens = append(tokens, sqlToken{text: string(r)})
+		}
+	}
+	return tokens
+}
+
+// tableName reads a possibly schema-qualified table name at index i and
+// returns it without the schema, with the index after it. Subqueries and
+// keywords return "".
+func tableName(tokens []sqlToken, i int) (string, int) {
+	if i >= len(tokens) || !tokens[i].isName() || sqlKeywords[tokens[i].keyword()] {
+		return "", i
+	}
+	name := tokens[i].text
+	i++
+	if i+1 < len(tokens) && tokens[i].text == "." && tokens[i+1].isName() {
+		name = tokens[i+1].text
+		i += 2
+	}
+	return name, i
+}
+
+// sourceTable reads a table name in FROM or JOIN; a table-valued function
+// such as json_each(...) is not a table
+func sourceTable(tokens []sqlToken, i int) (string, int) {
+	name, next := tableName(tokens, i)
+	if next < len(tokens) && tokens[next].text == "(" {
+		return "", next
+	}
+	return name, next
+}
+
+// skipAlias skips an optional [AS] alias after a table name
+func skipAlias(tokens []sqlToken, i int) int {
+	if i < len(tokens) && tokens[i].keyword() == "as" {
+		i++
+	}
+	if i < len(tokens) && tokens[i].isName() && !sqlKeywords[tokens[i].keyword()] {
+		i++
+	}
+	return i
+}
+
+// skipConflictClause skips OR REPLACE/IGNORE/... after INSERT or UPDATE and
+// INTO after INSERT OR ...
+func skipConflictClause(tokens []sqlToken, i int) int {
+	if i+1 < len(tokens) && tokens[i].keyword() == "or" {
+		i += 2
+	}
+	if i < len(tokens) && tokens[i].keyword() == "into" {
+		i++
+	}
+	return i
+}
+
+// skipIfExists skips IF [NOT] EXISTS after TABLE
+func skipIfExists(tokens []sqlToken, i int) int {
+	if i < len(tokens) && tokens[i].keyword() == "if" {
+		i++
+		if i < len(tokens) && tokens[i].keyword() == "not" {
+			i++
+		}
+		if i < len(tokens) && tokens[i].keyword() == "exists" {
+			i++
+		}
+	}
+	return i
+}
+
+// normalizeTables lower-cases, sorts and de-duplicates table names
+func normalizeTables(tables []string) []string {
+	seen := make(map[string]bool, len(tables))
+	result := make([]string, 0, len(tables))
+	for _, table := range tables {
+		table = strings.ToLower(table)
+		if table != "" && !seen[table] {
+			seen[table] = true
+			result = append(result, table)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// sqlKeywords are words that end a table reference and are never read as
+// table names or aliases
+var sqlKeywords = map[string]bool{
+	"select": true, "where": true, "group": true, "order": true, "limit": true,
+	"having": true, "join": true, "inner": true, "left": true, "right": true,
+	"full": true, "outer": true, "cross": true, "natural": true, "on": true,
+	"using": true, "union": true, "intersect": true, "except": true, "set": true,
+	"values": true, "as": true, "window": true, "returning": true, "default": true,
+	"offset": true, "with": true, "from": true, "into": true, "indexed": true,
+	"not": true,
+}
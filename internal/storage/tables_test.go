@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferencedTables(t *testing.T) {
+	cases := map[string][]string{
+		"SELECT * FROM items": {"items"},
+		"select i.id from Items i join main.\"Users\" u on u.id = i.by":          {"items", "users"},
+		"SELECT * FROM a, b AS x, c y WHERE a.id = x.id":                         {"a", "b", "c"},
+		"SELECT * FROM (SELECT id FROM items) t LEFT JOIN kids k ON k.id = t.id": {"items", "kids"},
+		"SELECT 'FROM fake' FROM items -- FROM comment":                          {"items"},
+		"SELECT value FROM json_each('[1,2]')":                                   {},
+		"WITH top AS (SELECT * FROM items) SELECT * FROM top":                    {"items", "top"},
+		"SELECT 1": {},
+	}
+	for query, expected := range cases {
+		assert.Equal(t, expected, ReferencedTables(query), query)
+	}
+}
+
+func TestWrittenTables(t *testing.T) {
+	cases := map[string][]string{
+		"INSERT OR REPLACE INTO items (id) VALUES (1)":                      {"items"},
+		"REPLACE INTO download_metadata VALUES ('a', 'b')":                  {"download_metadata"},
+		"UPDATE OR IGNORE items SET score = 1":                              {"items"},
+		"DELETE FROM main.batch_status WHERE completed":                     {"batch_status"},
+		"CREATE TABLE IF NOT EXISTS top AS SELECT * FROM items":             {"top"},
+		"DROP TABLE IF EXISTS \"top\"":                                      {"top"},
+		"ALTER TABLE top__refresh RENAME TO top":                            {"top", "top__refresh"},
+		"INSERT INTO a SELECT * FROM b ON CONFLICT(id) DO UPDATE SET v = 1": {"a"},
+		"SELECT * FROM items":                                               {},
+		"PRAGMA optimize":                                                   {},
+	}
+	for statement, expected := range cases {
+		assert.Equal(t, expected, WrittenTables(statement), statement)
+	}
+}
+
+func TestNotifyTableWrites(t *testing.T) {
+	var sources []string
+	var written [][]string
+	stop := OnTableWrites(func(source string, tables []string) {
+		sources = append(sources, source)
+		written = append(written, tables)
+	})
+
+	NotifyTableWrites("hackernews", "Items", "items", "batch_status")
+	stop()
+	NotifyTableWrites("hackernews", "items")
+
+	assert.Equal(t, []string{"hackernews"}, sources)
+	assert.Equal(t, [][]string{{"batch_status", "items"}}, written)
+}
@@ -4,6 +4,7 @@
 package storage
 
 import (
+	"database/sql"
 	"fmt"
 	"os"
 	"sync/atomic"
@@ -67,6 +68,7 @@ func (t *TUIStorageImpl) GetStorageHealth() HealthStatus {
 		ConnectionPool:   t.getPoolHealth(),
 		DiskSpace:        t.getDiskHealth(),
 		QueryPerformance: t.getPerformanceHealth(),
+		Database:         t.DatabaseHealth(),
 		LastCheck:        t.healthChecker.lastCheck,
 		Issues:           append([]string(nil), t.healthChecker.issues...), // Copy slice
 	}
@@ -74,25 +76,26 @@ func (t *TUIStorageImpl) GetStorageHealth() HealthStatus {
 
 // VacuumDatabase performs database maintenance operations
 func (t *TUIStorageImpl) VacuumDatabase() error {
-	conn, err := t.GetConnection()
-	if err != nil {
-		return fmt.Errorf("failed to get connection for vacuum: %w", err)
-	}
-	defer t.ReleaseConnection(conn)
-
-	// Run VACUUM to optimize database
-	if _, err := conn.Exec("VACUUM"); err != nil {
-		return fmt.Errorf("failed to vacuum database: %w", err)
-	}
+	// Maintenance writes to the database, so it runs on the writer connection
+	err := t.Write(func(conn *sql.DB) error {
+		// Run VACUUM to optimize database
+		if _, err := conn.Exec("VACUUM"); err != nil {
+			return fmt.Errorf("failed to vacuum database: %w", err)
+		}
 
-	// Update WAL checkpoint
-	if _, err := conn.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
-		return fmt.Errorf("failed to checkpoint WAL: %w", err)
-	}
+		// Update WAL checkpoint
+		if _, err := conn.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+			return fmt.Errorf("failed to checkpoint WAL: %w", err)
+		}
 
-	// Analyze query plans for optimization
-	if _, err := conn.Exec("ANALYZE"); err != nil {
-		return fmt.Errorf("failed to analyze database: %w", err)
+		// Analyze query plans for optimization
+		if _, err := conn.Exec("ANALYZE"); err != nil {
+			return fmt.Errorf("failed to analyze database: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	t.vacuumScheduler.lastVacuum = time.Now()
@@ -182,6 +185,11 @@ func (h *healthChecker) checkHealth() {
 	if perfHealth := h.storage.getPerformanceHealth(); perfHealth.Status != "healthy" {
 		h.issues = append(h.issues, fmt.Sprintf("Query performance: %s", perfHealth.Status))
 	}
+
+	// Check WAL size and lock contention
+	if dbHealth := h.storage.DatabaseHealth(); dbHealth.Status != "healthy" {
+		h.issues = append(h.issues, fmt.Sprintf("Database: %s", dbHealth.Status))
+	}
 }
 
 func (h *healthChecker) getOverallStatus() string {
@@ -209,7 +217,7 @@ func (t *TUIStorageImpl) getPoolHealth() PoolHealth {
 	return PoolHealth{
 		Status:       status,
 		Utilization:  utilization,
-		WaitingCount: 0, // Would track from actual queue
+		WaitingCount: t.WriteQueueDepth(),
 		TimeoutCount: atomic.LoadInt64(&t.pool.stats.connectionTimeouts),
 	}
 }
@@ -324,7 +332,6 @@ func (t *TUIStorageImpl) OptimizeForInteractiveQueries() error {
 		"PRAGMA cache_size = 20000",    // Increase cache size
 		"PRAGMA temp_store = memory",   // Use memory for temp storage
 		"PRAGMA mmap_size = 268435456", // Enable memory mapping (256MB)
-		"PRAGMA optimize",              // Run SQLite optimizer
 	}
 
 	for _, pragma := range optimizations {
@@ -333,5 +340,10 @@ func (t *TUIStorageImpl) OptimizeForInteractiveQueries() error {
 		}
 	}
 
+	// The optimizer may run ANALYZE, which writes to the database
+	if _, err := t.Exec("PRAGMA optimize"); err != nil {
+		return fmt.Errorf("failed to execute optimization PRAGMA optimize: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package storage
+
+import "syscall"
+
+// SetUmask sets the process umask, which applies to files created by SQLite
+// and other libraries, and returns the previous one
+func SetUmask(mask int) int {
+	return syscall.Umask(mask)
+}
@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package storage
+
+// SetUmask has no effect on Windows, which has no umask
+func SetUmask(mask int) int {
+	return 0
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// trackingParams are query parameters that identify where a link was shared
+// rather than what it points to
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"gclsrc":  true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+	"mkt_tok": true,
+	"ref":     true,
+	"ref_src": true,
+	"ref_url": true,
+	"trk":     true,
+}
+
+// CanonicalURL normalizes a URL so that links to the same page compare
+// equal: the scheme becomes https, the host is lower-cased without "www."
+// or a default port, tracking parameters such as utm_source are removed,
+// the remaining parameters are sorted and fragments and trailing slashes are
+// dropped. Fragments that route single-page apps ("#!" or "#/") are kept.
+// Text that is not an absolute http(s) URL is returned trimmed.
+func CanonicalURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return raw
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return raw
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	if port := parsed.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	query := parsed.Query()
+	for name := range query {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(name)
+		}
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	canonical.WriteString("https://")
+	canonical.WriteString(host)
+	canonical.WriteString(strings.TrimRight(parsed.EscapedPath(), "/"))
+	for i, name := range names {
+		if i == 0 {
+			canonical.WriteString("?")
+		} else {
+			canonical.WriteString("&")
+		}
+		values := query[name]
+		sort.Strings(values)
+		for j, value := range values {
+			if j > 0 {
+				canonical.WriteString("&")
+			}
+			canonical.WriteString(url.QueryEscape(name))
+			if value != "" {
+				canonical.WriteString("=" + url.QueryEscape(value))
+			}
+		}
+	}
+	if strings.HasPrefix(parsed.Fragment, "!") || strings.HasPrefix(parsed.Fragment, "/") {
+		canonical.WriteString("#" + parsed.EscapedFragment())
+	}
+	return canonical.String()
+}
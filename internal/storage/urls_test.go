@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	cases := map[string]string{
+		"https://example.com/post":                                        "https://example.com/post",
+		"http://WWW.Example.COM/post/":                                    "https://example.com/post",
+		"https://example.com:443/":                                        "https://example.com",
+		"http://example.com:8080/a":                                       "https://example.com:8080/a",
+		"https://example.com/post?utm_source=hn&utm_medium=social":        "https://example.com/post",
+		"https://example.com/post?b=2&fbclid=x&a=1&ref=hackernews":        "https://example.com/post?a=1&b=2",
+		"https://example.com/post#comments":                               "https://example.com/post",
+		"https://app.example.com/#/dashboard":                             "https://app.example.com#/dashboard",
+		"https://example.com/Case/Sensitive?Q=Search%20Term&UTM_SOURCE=x": "https://example.com/Case/Sensitive?Q=Search+Term",
+		"  https://example.com/spaces  ":                                  "https://example.com/spaces",
+		"ftp://example.com/file":                                          "ftp://example.com/file",
+		"not a url":                                                       "not a url",
+	}
+	for input, expected := range cases {
+		assert.Equal(t, expected, CanonicalURL(input), input)
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultWriteQueueSize is the number of writes that can wait for the writer
+// connection before callers block
+const DefaultWriteQueueSize = 256
+
+// errStorageClosed is returned for writes queued after or during Close
+var errStorageClosed = fmt.Errorf("storage is closed")
+
+// writeRequest is one unit of work for the writer connection
+type writeRequest struct {
+	fn   func(conn *sql.DB) error
+	done chan error
+}
+
+// writeQueue runs every write on a single connection, one at a time,
+// following SQLite's single-writer model. Readers use their own connections
+// and never wait behind the queue.
+type writeQueue struct {
+	conn     *sql.DB
+	requests chan writeRequest
+	depth    int32
+	stop     chan struct{}
+	stopped  chan struct{}
+
+	// mu is held to queue a write and to close the queue, so no write is
+	// queued after the writer has failed the waiting ones and returned
+	mu     sync.RWMutex
+	closed bool
+}
+
+// newWriteQueue starts the writer goroutine for conn
+func newWriteQueue(conn *sql.DB, size int) *writeQueue {
+	q := &writeQueue{
+		conn:     conn,
+		requests: make(chan writeRequest, size),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// run executes queued writes until the queue is stopped
+func (q *writeQueue) run() {
+	defer close(q.stopped)
+
+	for {
+		select {
+		case <-q.stop:
+			// Fail writes still waiting so their callers return
+			for {
+				select {
+				case req := <-q.requests:
+					atomic.AddInt32(&q.depth, -1)
+					req.done <- errStorageClosed
+				default:
+					return
+				}
+			}
+		case req := <-q.requests:
+			atomic.AddInt32(&q.depth, -1)
+			// select picks at random when both are ready; stopping wins
+			select {
+			case <-q.stop:
+				req.done <- errStorageClosed
+				continue
+			default:
+			}
+			req.done <- req.fn(q.conn)
+		}
+	}
+}
+
+// enqueue adds fn to the queue and returns the channel its result is sent on
+func (q *writeQueue) enqueue(fn func(conn *sql.DB) error) (<-chan error, error) {
+	req := writeRequest{fn: fn, done: make(chan error, 1)}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return nil, errStorageClosed
+	}
+	// The writer keeps taking requests until closed, so a full queue only
+	// delays Close until this request is in
+	atomic.AddInt32(&q.depth, 1)
+	q.requests <- req
+	return req.done, nil
+}
+
+// Depth returns the number of writes waiting for the writer connection
+func (q *writeQueue) Depth() int {
+	return int(atomic.LoadInt32(&q.depth))
+}
+
+// Close stops the writer after the running write finishes, fails queued
+// writes and closes the connection. An open transaction is rolled back.
+func (q *writeQueue) Close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.stop)
+	}
+	q.mu.Unlock()
+	<-q.stopped
+	return q.conn.Close()
+}
+
+// Write runs fn on the writer connection once every earlier write has
+// finished and returns its error
+func (s *SQLiteStorage) Write(fn func(conn *sql.DB) error) error {
+	if atomic.LoadInt32(&s.closed) == 1 || s.writer == nil {
+		return errStorageClosed
+	}
+
+	done, err := s.writer.enqueue(fn)
+	if err != nil {
+		return err
+	}
+	return <-done
+}
+
+// Exec runs a statement that changes the database through the write queue
+// and reports the tables it wrote to table write listeners
+func (s *SQLiteStorage) Exec(query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := s.Write(func(conn *sql.DB) error {
+		var err error
+		result, err = conn.Exec(query, args...)
+		return err
+	})
+	if err != nil {
+		s.recordError(err)
+		return nil, fmt.Errorf("failed to execute statement: %w", err)
+	}
+
+	if tables := WrittenTables(query); len(tables) > 0 {
+		NotifyTableWrites("", tables...)
+	}
+	return result, nil
+}
+
+// WriteQueueDepth returns the number of writes waiting for the writer connection
+func (s *SQLiteStorage) WriteQueueDepth() int {
+	if s.writer == nil {
+		return 0
+	}
+	return s.writer.Depth()
+}
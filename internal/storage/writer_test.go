@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorage_ReadersAreReadOnly(t *testing.T) {
+	storage := NewSQLiteStorage(2)
+	require.NoError(t, storage.Initialize(t.TempDir()))
+	defer storage.Close()
+
+	conn, err := storage.GetConnection()
+	require.NoError(t, err)
+	_, err = conn.Exec("INSERT INTO download_metadata (key, value) VALUES ('a', '1')")
+	assert.Error(t, err)
+	require.NoError(t, storage.ReleaseConnection(conn))
+
+	_, err = storage.Exec("INSERT INTO download_metadata (key, value) VALUES ('a', '1')")
+	require.NoError(t, err)
+
+	result, err := storage.Query("SELECT value FROM download_metadata WHERE key = 'a'")
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Count)
+	assert.Equal(t, "1", result.Rows[0][0])
+}
+
+func TestSQLiteStorage_WriteQueue(t *testing.T) {
+	storage := NewSQLiteStorage(2)
+	require.NoError(t, storage.Initialize(t.TempDir()))
+	defer storage.Close()
+
+	// An open transaction holds the writer, so later writes queue up
+	tx, err := storage.BeginTransaction()
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO download_metadata (key, value) VALUES ('tx', '1')")
+	require.NoError(t, err)
+
+	const queued = 3
+	errs := make(chan error, queued)
+	for i := 0; i < queued; i++ {
+		go func(i int) {
+			_, err := storage.Exec("INSERT INTO download_metadata (key, value) VALUES (?, 'queued')", string(rune('a'+i)))
+			errs <- err
+		}(i)
+	}
+	require.Eventually(t, func() bool {
+		return storage.GetStorageStats().QueuedWrites == queued
+	}, time.Second, 5*time.Millisecond)
+
+	// Reads are not blocked by the writer
+	result, err := storage.Query("SELECT COUNT(*) FROM download_metadata")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Rows[0][0])
+
+	require.NoError(t, tx.Commit())
+	for i := 0; i < queued; i++ {
+		assert.NoError(t, <-errs)
+	}
+	assert.Equal(t, 0, storage.WriteQueueDepth())
+
+	result, err = storage.Query("SELECT COUNT(*) FROM download_metadata")
+	require.NoError(t, err)
+	assert.Equal(t, int64(queued+1), result.Rows[0][0])
+}
+
+func TestSQLiteStorage_CloseFailsQueuedWrites(t *testing.T) {
+	storage := NewSQLiteStorage(1)
+	require.NoError(t, storage.Initialize(t.TempDir()))
+
+	tx, err := storage.BeginTransaction()
+	require.NoError(t, err)
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- storage.Write(func(conn *sql.DB) error { return nil })
+	}()
+	require.Eventually(t, func() bool { return storage.WriteQueueDepth() == 1 }, time.Second, 5*time.Millisecond)
+
+	// Close rolls back the open transaction and fails the waiting write
+	require.NoError(t, storage.Close())
+	assert.ErrorIs(t, <-errs, errStorageClosed)
+	assert.Error(t, tx.Commit())
+	assert.ErrorIs(t, storage.Write(func(conn *sql.DB) error { return nil }), errStorageClosed)
+}
+
+func TestSQLiteStorage_WritesRacingCloseReturn(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		storage := NewSQLiteStorage(1)
+		require.NoError(t, storage.Initialize(t.TempDir()))
+
+		// Writes queued while Close runs are either run or failed, never
+		// left waiting for a writer that has stopped
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := 0; k < 20; k++ {
+					// Past the closed check of Write, straight to the queue
+					done, err := storage.writer.enqueue(func(conn *sql.DB) error { return nil })
+					if err == nil {
+						err = <-done
+					}
+					if err != nil {
+						assert.ErrorIs(t, err, errStorageClosed)
+					}
+				}
+			}()
+		}
+		require.NoError(t, storage.Close())
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("writes racing Close did not return")
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Package transform runs user Starlark scripts on downloaded items before
+// they are stored, to redact fields or compute derived ones. Scripts run
+// sandboxed: they cannot read files, reach the network or load other
+// modules, and each call is stopped after a timeout.
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	starlarkjson "go.starlark.net/lib/json"
+	starlarkmath "go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// FunctionName is the function a script defines; it gets the item as a
+// dict and returns the dict to store, or None to skip the item
+const FunctionName = "transform"
+
+// DefaultTimeout is how long a script may take per item unless configured
+const DefaultTimeout = 100 * time.Millisecond
+
+// maxSteps bounds the computation of one call independently of the timeout
+const maxSteps = 10_000_000
+
+// predeclared are the modules scripts can use besides the built-ins
+var predeclared = starlark.StringDict{
+	"json": starlarkjson.Module,
+	"math": starlarkmath.Module,
+}
+
+// Hook is a loaded transformation script
+type Hook struct {
+	path    string
+	fn      *starlark.Function
+	timeout time.Duration
+}
+
+// Load reads and runs the script at path, which must define transform.
+// timeout bounds loading the script and every call; 0 uses DefaultTimeout.
+func Load(path string, timeout time.Duration) (*Hook, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transform script: %w", err)
+	}
+
+	thread := newThread(path)
+	stop := time.AfterFunc(timeout, func() { thread.Cancel("timeout") })
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, path, src, predeclared)
+	stop.Stop()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transform script %s: %w", path, scriptError(err))
+	}
+
+	fn, ok := globals[FunctionName].(*starlark.Function)
+	if !ok {
+		return nil, fmt.Errorf("transform script %s does not define %s(item)", path, FunctionName)
+	}
+	if fn.NumParams() != 1 {
+		return nil, fmt.Errorf("transform script %s: %s must take one argument, the item", path, FunctionName)
+	}
+	// Frozen globals can be shared by calls from several downloads at once
+	globals.Freeze()
+	return &Hook{path: path, fn: fn, timeout: timeout}, nil
+}
+
+// Path returns the file the hook was loaded from
+func (h *Hook) Path() string {
+	return h.path
+}
+
+// Apply calls transform with item and returns the fields it returned, or
+// nil when the item should be skipped
+func (h *Hook) Apply(item map[string]interface{}) (map[string]interface{}, error) {
+	arg, err := toStarlark(item)
+	if err != nil {
+		return nil, err
+	}
+
+	thread := newThread(h.path)
+	thread.SetMaxExecutionSteps(maxSteps)
+	stop := time.AfterFunc(h.timeout, func() { thread.Cancel(fmt.Sprintf("took longer than %s", h.timeout)) })
+	result, err := starlark.Call(thread, h.fn, starlark.Tuple{arg}, nil)
+	stop.Stop()
+	if err != nil {
+		return nil, fmt.Errorf("transform script %s failed: %w", h.path, scriptError(err))
+	}
+
+	if result == starlark.None {
+		return nil, nil
+	}
+	if _, ok := result.(*starlark.Dict); !ok {
+		return nil, fmt.Errorf("transform script %s returned %s; it must return the item dict or None", h.path, result.Type())
+	}
+	value, err := fromStarlark(result)
+	if err != nil {
+		return nil, fmt.Errorf("transform script %s: %w", h.path, err)
+	}
+	return value.(map[string]interface{}), nil
+}
+
+// Chain is the hooks of a data source, run in order on every item
+type Chain struct {
+	hooks []*Hook
+	err   error
+}
+
+// LoadChain loads the scripts at paths. When one fails to load the error
+// is returned, and the chain fails every Apply with it, so items are not
+// stored without the transformation they were meant to get.
+func LoadChain(paths []string, timeout time.Duration) (*Chain, error) {
+	chain := &Chain{}
+	for _, path := range paths {
+		hook, err := Load(path, timeout)
+		if err != nil {
+			chain.err = err
+			return chain, err
+		}
+		chain.hooks = append(chain.hooks, hook)
+	}
+	return chain, nil
+}
+
+// Empty reports whether the chain has nothing to run
+func (c *Chain) Empty() bool {
+	return c == nil || (len(c.hooks) == 0 && c.err == nil)
+}
+
+// Apply runs every hook on item, each on the result of the one before. It
+// returns nil when a hook skipped the item.
+func (c *Chain) Apply(item map[string]interface{}) (map[string]interface{}, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	for _, hook := range c.hooks {
+		var err error
+		if item, err = hook.Apply(item); err != nil || item == nil {
+			return nil, err
+		}
+	}
+	return item, nil
+}
+
+// newThread returns a thread for running a script; load is not available
+// and print goes to the debug log
+func newThread(path string) *starlark.Thread {
+	return &starlark.Thread{
+		Name: path,
+		Print: func(thread *starlark.Thread, msg string) {
+			log.ForComponent("transform").Debugf("%s: %s", path, msg)
+		},
+	}
+}
+
+// scriptError adds the Starlark backtrace to script failures
+func scriptError(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
+
+// toStarlark converts an item value to Starlark
+func toStarlark(value interface{}) (starlark.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case int:
+		return starlark.MakeInt(v), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case float64:
+		return starlark.Float(v), nil
+	case string:
+		return starlark.String(v), nil
+	case []int64:
+		list := make([]starlark.Value, len(v))
+		for i, n := range v {
+			list[i] = starlark.MakeInt64(n)
+		}
+		return starlark.NewList(list), nil
+	case []interface{}:
+		list := make([]starlark.Value, len(v))
+		for i, element := range v {
+			converted, err := toStarlark(element)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = converted
+		}
+		return starlark.NewList(list), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			converted, err := toStarlark(v[key])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(key), converted); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("cannot pass %T to a transform script", value)
+	}
+}
+
+// fromStarlark converts a value returned by a script: ints become int64,
+// lists []interface{} and dicts with string keys map[string]interface{}
+func fromStarlark(value starlark.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		n, ok := v.Int64()
+		if !ok {
+			return nil, fmt.Errorf("integer %s is too large", v)
+		}
+		return n, nil
+	case starlark.Float:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil, fmt.Errorf("%s cannot be stored", v)
+		}
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case *starlark.List:
+		return fromIterable(v)
+	case starlark.Tuple:
+		return fromIterable(v)
+	case *starlark.Dict:
+		result := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("dict keys must be strings, got %s", item[0].Type())
+			}
+			converted, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			result[string(key)] = converted
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("%s values cannot be stored", value.Type())
+	}
+}
+
+// fromIterable converts a list or tuple
+func fromIterable(iterable starlark.Indexable) ([]interface{}, error) {
+	result := make([]interface{}, iterable.Len())
+	for i := range result {
+		converted, err := fromStarlark(iterable.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		result[i] = converted
+	}
+	return result, nil
+}
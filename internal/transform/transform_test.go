@@ -0,0 +1,105 @@
+package transform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeScript writes a Starlark script and returns its path
+func writeScript(t *testing.T, source string) string {
+	path := filepath.Join(t.TempDir(), "hook.star")
+	require.NoError(t, os.WriteFile(path, []byte(source), 0644))
+	return path
+}
+
+func TestHook_Apply(t *testing.T) {
+	log.InitLogger(false)
+	hook, err := Load(writeScript(t, `
+def transform(item):
+    if item["type"] == "job":
+        return None
+    item["text"] = ""
+    item["title_words"] = len(item["title"].split())
+    item["first_kid"] = item["kids"][0] if item["kids"] else None
+    return item
+`), 0)
+	require.NoError(t, err)
+
+	result, err := hook.Apply(map[string]interface{}{
+		"id": int64(8863), "type": "story", "title": "My YC app: Dropbox", "text": "secret", "kids": []int64{9224, 8917},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "", result["text"])
+	assert.Equal(t, int64(4), result["title_words"])
+	assert.Equal(t, int64(9224), result["first_kid"])
+	assert.Equal(t, []interface{}{int64(9224), int64(8917)}, result["kids"])
+
+	result, err = hook.Apply(map[string]interface{}{"type": "job", "title": "Hiring", "kids": []int64{}})
+	require.NoError(t, err)
+	assert.Nil(t, result, "None skips the item")
+}
+
+func TestHook_Sandbox(t *testing.T) {
+	log.InitLogger(false)
+	_, err := Load(writeScript(t, `load("other.star", "x")
+def transform(item):
+    return item
+`), 0)
+	assert.Error(t, err, "load is not available")
+
+	_, err = Load(writeScript(t, `x = 1`), 0)
+	assert.ErrorContains(t, err, "does not define transform(item)")
+
+	hook, err := Load(writeScript(t, `
+def transform(item):
+    n = 0
+    for i in range(100000000):
+        n += i
+    return item
+`), 20*time.Millisecond)
+	require.NoError(t, err)
+	start := time.Now()
+	_, err = hook.Apply(map[string]interface{}{})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	hook, err = Load(writeScript(t, `
+def transform(item):
+    return "not a dict"
+`), 0)
+	require.NoError(t, err)
+	_, err = hook.Apply(map[string]interface{}{})
+	assert.ErrorContains(t, err, "must return the item dict or None")
+}
+
+func TestChain(t *testing.T) {
+	log.InitLogger(false)
+	first := writeScript(t, `
+def transform(item):
+    item["score"] = item["score"] * 2
+    return item
+`)
+	second := writeScript(t, `
+def transform(item):
+    item["popular"] = item["score"] > 100
+    return item
+`)
+	chain, err := LoadChain([]string{first, second}, 0)
+	require.NoError(t, err)
+	assert.False(t, chain.Empty())
+	result, err := chain.Apply(map[string]interface{}{"score": int64(60)})
+	require.NoError(t, err)
+	assert.Equal(t, true, result["popular"])
+
+	// A chain that failed to load fails every item
+	chain, err = LoadChain([]string{first, filepath.Join(t.TempDir(), "missing.star")}, 0)
+	require.Error(t, err)
+	_, applyErr := chain.Apply(map[string]interface{}{"score": int64(1)})
+	assert.Equal(t, err, applyErr)
+}
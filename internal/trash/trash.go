@@ -0,0 +1,256 @@
+package trash
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const entryFile = "entry.json"
+
+// Entry describes a deletion kept in the trash until it is undone or expires
+type Entry struct {
+	ID          string    `json:"id"`
+	Kind        string    `json:"kind"`
+	Description string    `json:"description"`
+	DeletedAt   time.Time `json:"deleted_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// RestoreFunc restores an entry from the files stored in dir
+type RestoreFunc func(entry *Entry, dir string) error
+
+// Trash keeps deleted data in a directory for a retention window so that
+// deletions can be undone. Each entry is a subdirectory holding entry.json
+// and whatever files the deleting component stored; the component registers
+// a RestoreFunc for its kind to put them back.
+type Trash struct {
+	dir       string
+	retention time.Duration
+
+	mu        sync.Mutex
+	restorers map[string]RestoreFunc
+}
+
+// Dir returns the trash directory under storagePath
+func Dir(storagePath string) string {
+	return filepath.Join(storagePath, "trash")
+}
+
+// New opens the trash in dir, keeping entries for retention
+func New(dir string, retention time.Duration) (*Trash, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	return &Trash{
+		dir:       dir,
+		retention: retention,
+		restorers: make(map[string]RestoreFunc),
+	}, nil
+}
+
+// RegisterRestorer sets how entries of a kind are restored
+func (t *Trash) RegisterRestorer(kind string, restore RestoreFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.restorers[kind] = restore
+}
+
+// Put adds an entry; write stores the deleted data in the entry directory.
+// If write fails the entry is discarded, so write must leave the original
+// data in place unless it succeeds.
+func (t *Trash) Put(kind, description string, write func(dir string) error) (*Entry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	entry := &Entry{
+		ID:          t.newID(kind, now),
+		Kind:        kind,
+		Description: description,
+		DeletedAt:   now,
+		ExpiresAt:   now.Add(t.retention),
+	}
+
+	tmpDir := filepath.Join(t.dir, "."+entry.ID+".tmp")
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create trash entry: %w", err)
+	}
+	if err := writeEntry(tmpDir, entry); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	if err := write(tmpDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return nil, err
+	}
+	if err := os.Rename(tmpDir, filepath.Join(t.dir, entry.ID)); err != nil {
+		return nil, fmt.Errorf("failed to store trash entry: %w", err)
+	}
+	return entry, nil
+}
+
+// List returns the entries in the trash, newest first
+func (t *Trash) List() ([]*Entry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.list()
+}
+
+// Restore puts back the entry with the given ID, or the newest entry when id
+// is empty, and removes it from the trash
+func (t *Trash) Restore(id string) (*Entry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if id == "" {
+		entries, err := t.list()
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("nothing to undo")
+		}
+		id = entries[0].ID
+	}
+
+	dir := filepath.Join(t.dir, id)
+	entry, err := readEntry(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("trash entry %s not found", id)
+		}
+		return nil, err
+	}
+	if time.Now().After(entry.ExpiresAt) {
+		return nil, fmt.Errorf("trash entry %s expired at %s", id, entry.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+
+	restore, ok := t.restorers[entry.Kind]
+	if !ok {
+		return nil, fmt.Errorf("%s deletions cannot be undone in this session", entry.Kind)
+	}
+	if err := restore(entry, dir); err != nil {
+		return nil, fmt.Errorf("failed to undo %s: %w", entry.Description, err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return entry, fmt.Errorf("restored, but failed to remove trash entry: %w", err)
+	}
+	return entry, nil
+}
+
+// Purge permanently removes entries that expired before now and returns how
+// many were removed
+func (t *Trash) Purge(now time.Time) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries, err := t.list()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if now.Before(entry.ExpiresAt) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(t.dir, entry.ID)); err != nil {
+			return removed, fmt.Errorf("failed to purge trash entry %s: %w", entry.ID, err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// list reads every entry, newest first; callers hold mu
+func (t *Trash) list() ([]*Entry, error) {
+	dirEntries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash directory: %w", err)
+	}
+
+	var entries []*Entry
+	for _, dirEntry := range dirEntries {
+		// Entries still being written are hidden
+		if !dirEntry.IsDir() || strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		entry, err := readEntry(filepath.Join(t.dir, dirEntry.Name()))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+// newID returns a readable entry ID that is not yet in use; callers hold mu
+func (t *Trash) newID(kind string, now time.Time) string {
+	base := fmt.Sprintf("%s-%s", now.Format("20060102-150405"), kind)
+	id := base
+	for n := 2; ; n++ {
+		if _, err := os.Stat(filepath.Join(t.dir, id)); os.IsNotExist(err) {
+			return id
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// writeEntry stores entry.json in dir
+func writeEntry(dir string, entry *Entry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal trash entry: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, entryFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write trash entry: %w", err)
+	}
+	return nil
+}
+
+// readEntry loads entry.json from dir
+func readEntry(dir string) (*Entry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, entryFile))
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse trash entry %s: %w", filepath.Base(dir), err)
+	}
+	return &entry, nil
+}
+
+// MoveFile moves src to dst, copying when they are on different file systems
+func MoveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", src, err)
+	}
+	if err := os.WriteFile(dst, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	if err := os.Remove(src); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("failed to remove %s: %w", src, err)
+	}
+	return nil
+}
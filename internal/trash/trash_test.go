@@ -0,0 +1,109 @@
+package trash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrash_PutRestore(t *testing.T) {
+	storage := t.TempDir()
+	bin, err := New(Dir(storage), time.Hour)
+	require.NoError(t, err)
+
+	original := filepath.Join(storage, "notes.json")
+	require.NoError(t, os.WriteFile(original, []byte(`{"name":"notes"}`), 0644))
+
+	entry, err := bin.Put("file", "file notes.json", func(dir string) error {
+		return MoveFile(original, filepath.Join(dir, "notes.json"))
+	})
+	require.NoError(t, err)
+	assert.NoFileExists(t, original)
+	assert.Equal(t, "file", entry.Kind)
+	assert.WithinDuration(t, entry.DeletedAt.Add(time.Hour), entry.ExpiresAt, time.Second)
+
+	entries, err := bin.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, entry.ID, entries[0].ID)
+
+	_, err = bin.Restore("")
+	assert.ErrorContains(t, err, "cannot be undone")
+
+	bin.RegisterRestorer("file", func(entry *Entry, dir string) error {
+		return MoveFile(filepath.Join(dir, "notes.json"), original)
+	})
+	restored, err := bin.Restore("")
+	require.NoError(t, err)
+	assert.Equal(t, entry.ID, restored.ID)
+	assert.FileExists(t, original)
+
+	entries, err = bin.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = bin.Restore("")
+	assert.ErrorContains(t, err, "nothing to undo")
+	_, err = bin.Restore(entry.ID)
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestTrash_FailedWriteDiscardsEntry(t *testing.T) {
+	bin, err := New(Dir(t.TempDir()), time.Hour)
+	require.NoError(t, err)
+
+	_, err = bin.Put("file", "broken", func(dir string) error {
+		return errors.New("disk full")
+	})
+	assert.ErrorContains(t, err, "disk full")
+
+	entries, err := bin.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	dirEntries, err := os.ReadDir(bin.dir)
+	require.NoError(t, err)
+	assert.Empty(t, dirEntries)
+}
+
+func TestTrash_NewestFirstAndUniqueIDs(t *testing.T) {
+	bin, err := New(Dir(t.TempDir()), time.Hour)
+	require.NoError(t, err)
+
+	noop := func(dir string) error { return nil }
+	first, err := bin.Put("jobs", "first", noop)
+	require.NoError(t, err)
+	second, err := bin.Put("jobs", "second", noop)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	entries, err := bin.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "second", entries[0].Description)
+}
+
+func TestTrash_ExpiryAndPurge(t *testing.T) {
+	bin, err := New(Dir(t.TempDir()), time.Minute)
+	require.NoError(t, err)
+	bin.RegisterRestorer("jobs", func(entry *Entry, dir string) error { return nil })
+
+	entry, err := bin.Put("jobs", "old jobs", func(dir string) error { return nil })
+	require.NoError(t, err)
+
+	removed, err := bin.Purge(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	removed, err = bin.Purge(time.Now().Add(2 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, err = bin.Restore(entry.ID)
+	assert.ErrorContains(t, err, "not found")
+}
@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/httpclient"
+)
+
+// httpCacheProvider is implemented by data sources that cache API responses
+type httpCacheProvider interface {
+	HTTPCache() *httpclient.Cache
+}
+
+// CacheCommand manages the on-disk HTTP response cache
+type CacheCommand struct {
+	BaseCommand
+}
+
+// NewCacheCommand creates a new cache command
+func NewCacheCommand() *CacheCommand {
+	return &CacheCommand{
+		BaseCommand: BaseCommand{
+			Name:        "cache",
+			Description: "Manage the HTTP response cache",
+			Usage:       "cache http <stats|clear>",
+		},
+	}
+}
+
+// Execute shows or clears the HTTP cache
+func (cc *CacheCommand) Execute(ctx *ShellContext) error {
+	if len(ctx.Args) < 3 || ctx.Args[1] != "http" {
+		return fmt.Errorf("usage: %s", cc.Usage)
+	}
+
+	cache, err := sharedHTTPCache(ctx.DataSources)
+	if err != nil {
+		return err
+	}
+
+	switch ctx.Args[2] {
+	case "stats":
+		stats := cache.Stats()
+		fmt.Printf("HTTP cache: %s\n", stats.Dir)
+		fmt.Printf("  Entries: %d  Size: %.1f MB", stats.Entries, float64(stats.Size)/(1024*1024))
+		if stats.MaxSize > 0 {
+			fmt.Printf(" / %.1f MB", float64(stats.MaxSize)/(1024*1024))
+		}
+		fmt.Println()
+		fmt.Printf("  Hits: %d  Misses: %d  Evicted: %d\n", stats.Hits, stats.Misses, stats.Evicted)
+		return nil
+	case "clear":
+		if err := cache.Clear(); err != nil {
+			return fmt.Errorf("failed to clear HTTP cache: %w", err)
+		}
+		fmt.Println("HTTP cache cleared")
+		return nil
+	default:
+		return fmt.Errorf("unknown cache http subcommand: %s", ctx.Args[2])
+	}
+}
+
+// GetCompletions completes the cache subcommands
+func (cc *CacheCommand) GetCompletions(partial string, args []string) []string {
+	var options []string
+	switch len(args) {
+	case 0:
+		options = []string{"http"}
+	case 1:
+		options = []string{"stats", "clear"}
+	}
+
+	var completions []string
+	for _, option := range options {
+		if strings.HasPrefix(option, partial) {
+			completions = append(completions, option)
+		}
+	}
+	return completions
+}
+
+// sharedHTTPCache returns the cache used by data sources, or opens the
+// cache directory directly when no data source caches responses
+func sharedHTTPCache(dataSources map[string]interface{}) (*httpclient.Cache, error) {
+	for _, ds := range dataSources {
+		if provider, ok := ds.(httpCacheProvider); ok {
+			if cache := provider.HTTPCache(); cache != nil {
+				return cache, nil
+			}
+		}
+	}
+
+	maxBytes := config.AppConfig.HTTP.Cache.MaxSizeMB * 1024 * 1024
+	cache, err := httpclient.NewCache(httpclient.CacheDir(config.AppConfig.StoragePath), maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open HTTP cache: %w", err)
+	}
+	return cache, nil
+}
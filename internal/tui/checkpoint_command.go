@@ -0,0 +1,213 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// checkpointProvider is implemented by data sources that keep pipeline
+// checkpoints
+type checkpointProvider interface {
+	Checkpoints() *storage.Checkpoints
+}
+
+// CheckpointCommand lists and cleans up the checkpoints custom pipelines
+// keep with a data source
+type CheckpointCommand struct {
+	BaseCommand
+}
+
+// NewCheckpointCommand creates a new checkpoint command
+func NewCheckpointCommand() *CheckpointCommand {
+	return &CheckpointCommand{
+		BaseCommand: BaseCommand{
+			Name:        "checkpoint",
+			Description: "Manage the incremental cursors pipelines keep with a data source",
+			Usage:       "checkpoint <list|get|set|delete|prune> <source> [namespace] [key] [value] [--older-than <age>]",
+		},
+	}
+}
+
+// Execute runs a checkpoint subcommand
+func (cc *CheckpointCommand) Execute(ctx *ShellContext) error {
+	if len(ctx.Args) < 2 {
+		return fmt.Errorf("usage: %s", cc.Usage)
+	}
+	args := ctx.Args[2:]
+
+	switch ctx.Args[1] {
+	case "list", "ls":
+		return cc.list(ctx, args)
+	case "get":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: checkpoint get <source> <namespace> <key>")
+		}
+		checkpoints, err := sourceCheckpoints(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		checkpoint, err := checkpoints.Get(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		if checkpoint == nil {
+			return fmt.Errorf("checkpoint not found: %s/%s", args[1], args[2])
+		}
+		fmt.Println(checkpoint.Value)
+		return nil
+	case "set":
+		if len(args) < 4 {
+			return fmt.Errorf("usage: checkpoint set <source> <namespace> <key> <value>")
+		}
+		checkpoints, err := sourceCheckpoints(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		value := strings.Join(args[3:], " ")
+		if err := checkpoints.Set(args[1], args[2], value); err != nil {
+			return err
+		}
+		fmt.Printf("Set checkpoint %s/%s to %s\n", args[1], args[2], value)
+		return nil
+	case "delete", "rm":
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("usage: checkpoint delete <source> <namespace> [key]")
+		}
+		checkpoints, err := sourceCheckpoints(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		key := ""
+		if len(args) == 3 {
+			key = args[2]
+		}
+		removed, err := checkpoints.Delete(args[1], key)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %d checkpoints\n", removed)
+		return nil
+	case "prune":
+		return cc.prune(ctx, args)
+	default:
+		return fmt.Errorf("unknown checkpoint subcommand: %s", ctx.Args[1])
+	}
+}
+
+// list prints the checkpoints of one or all sources, optionally of one
+// namespace
+func (cc *CheckpointCommand) list(ctx *ShellContext, args []string) error {
+	var sources []string
+	namespace := ""
+	if len(args) > 0 {
+		sources = args[:1]
+	}
+	if len(args) > 1 {
+		namespace = args[1]
+	}
+	if len(sources) == 0 {
+		for name, ds := range ctx.DataSources {
+			if _, ok := ds.(checkpointProvider); ok {
+				sources = append(sources, name)
+			}
+		}
+		sort.Strings(sources)
+	}
+
+	found := false
+	for _, source := range sources {
+		checkpoints, err := sourceCheckpoints(ctx, source)
+		if err != nil {
+			return err
+		}
+		list, err := checkpoints.List(namespace)
+		if err != nil {
+			return err
+		}
+		for _, checkpoint := range list {
+			found = true
+			fmt.Printf("  %s  %-32s %-24s updated %s\n", source, checkpoint.Namespace+"/"+checkpoint.Key,
+				checkpoint.Value, checkpoint.UpdatedAt.Format(time.RFC3339))
+		}
+	}
+	if !found {
+		fmt.Println("No checkpoints stored")
+	}
+	return nil
+}
+
+// prune removes the checkpoints of a source not updated within an age
+func (cc *CheckpointCommand) prune(ctx *ShellContext, args []string) error {
+	var positional []string
+	olderThan := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--older-than" {
+			if i+1 >= len(args) {
+				return fmt.Errorf("--older-than requires a value")
+			}
+			olderThan = args[i+1]
+			i++
+			continue
+		}
+		positional = append(positional, args[i])
+	}
+	if len(positional) != 1 || olderThan == "" {
+		return fmt.Errorf("usage: checkpoint prune <source> --older-than <age, e.g. 30d>")
+	}
+	age, err := jobs.ParseAge(olderThan)
+	if err != nil {
+		return err
+	}
+
+	checkpoints, err := sourceCheckpoints(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	removed, err := checkpoints.Prune(time.Now().Add(-age))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Pruned %d checkpoints not updated in %s\n", removed, olderThan)
+	return nil
+}
+
+// GetCompletions completes checkpoint subcommands and source names
+func (cc *CheckpointCommand) GetCompletions(partial string, args []string) []string {
+	var options []string
+	switch len(args) {
+	case 0:
+		options = []string{"list", "get", "set", "delete", "prune"}
+	case 1:
+		options = []string{"hackernews"}
+	default:
+		if args[0] == "prune" {
+			options = []string{"--older-than"}
+		}
+	}
+
+	var completions []string
+	for _, option := range options {
+		if strings.HasPrefix(option, partial) {
+			completions = append(completions, option)
+		}
+	}
+	return completions
+}
+
+// sourceCheckpoints returns the checkpoints of a data source
+func sourceCheckpoints(ctx *ShellContext, source string) (*storage.Checkpoints, error) {
+	ds, exists := ctx.DataSources[source]
+	if !exists {
+		return nil, fmt.Errorf("unknown data source: %s", source)
+	}
+	provider, ok := ds.(checkpointProvider)
+	if !ok || provider.Checkpoints() == nil {
+		return nil, fmt.Errorf("data source %s does not keep checkpoints", source)
+	}
+	return provider.Checkpoints(), nil
+}
@@ -13,6 +13,8 @@ type ShellContext struct {
 	RawInput    string
 	Context     context.Context
 	DataSources map[string]interface{}
+	// ReadSecret reads a line without echo, such as a passphrase
+	ReadSecret func(prompt string) (string, error)
 }
 
 // CommandHandler defines the interface for shell commands
@@ -166,193 +168,3 @@ func NewExitCommand() *ExitCommand {
 func (ec *ExitCommand) Execute(ctx *ShellContext) error {
 	return fmt.Errorf("exit")
 }
-
-// ConfigCommand implements configuration management
-type ConfigCommand struct {
-	BaseCommand
-}
-
-// NewConfigCommand creates a new config command
-func NewConfigCommand() *ConfigCommand {
-	return &ConfigCommand{
-		BaseCommand: BaseCommand{
-			Name:        "config",
-			Description: "Manage configuration settings",
-			Usage:       "config <show|set-storage> [args...]",
-		},
-	}
-}
-
-// Execute handles config operations
-func (cc *ConfigCommand) Execute(ctx *ShellContext) error {
-	return ctx.Shell.handleConfigCommand(ctx.Args[1:])
-}
-
-// GetCompletions provides config subcommand completions
-func (cc *ConfigCommand) GetCompletions(partial string, args []string) []string {
-	if len(args) <= 2 {
-		subcommands := []string{"show", "set-storage"}
-		var completions []string
-		for _, cmd := range subcommands {
-			if strings.HasPrefix(cmd, partial) {
-				completions = append(completions, cmd)
-			}
-		}
-		return completions
-	}
-	return []string{}
-}
-
-// DownloadCommand implements download operations
-type DownloadCommand struct {
-	BaseCommand
-}
-
-// NewDownloadCommand creates a new download command
-func NewDownloadCommand() *DownloadCommand {
-	return &DownloadCommand{
-		BaseCommand: BaseCommand{
-			Name:        "download",
-			Description: "Start background download for a data source",
-			Usage:       "download <source>",
-		},
-	}
-}
-
-// Execute handles download operations
-func (dc *DownloadCommand) Execute(ctx *ShellContext) error {
-	return ctx.Shell.handleDownloadCommand(ctx.Args[1:])
-}
-
-// GetCompletions provides data source name completions
-func (dc *DownloadCommand) GetCompletions(partial string, args []string) []string {
-	if len(args) <= 2 {
-		sources := []string{"hackernews"}
-		var completions []string
-		for _, source := range sources {
-			if strings.HasPrefix(source, partial) {
-				completions = append(completions, source)
-			}
-		}
-		return completions
-	}
-	return []string{}
-}
-
-// QueryCommand implements query operations
-type QueryCommand struct {
-	BaseCommand
-}
-
-// NewQueryCommand creates a new query command
-func NewQueryCommand() *QueryCommand {
-	return &QueryCommand{
-		BaseCommand: BaseCommand{
-			Name:        "query",
-			Description: "Execute SQL query against a data source",
-			Usage:       "query <source> <sql>",
-		},
-	}
-}
-
-// Execute handles query operations
-func (qc *QueryCommand) Execute(ctx *ShellContext) error {
-	return ctx.Shell.handleQueryCommand(ctx.Args[1:])
-}
-
-// GetCompletions provides data source name completions
-func (qc *QueryCommand) GetCompletions(partial string, args []string) []string {
-	if len(args) <= 2 {
-		sources := []string{"hackernews"}
-		var completions []string
-		for _, source := range sources {
-			if strings.HasPrefix(source, partial) {
-				completions = append(completions, source)
-			}
-		}
-		return completions
-	}
-	return []string{}
-}
-
-// JobsCommand implements job management
-type JobsCommand struct {
-	BaseCommand
-}
-
-// NewJobsCommand creates a new jobs command
-func NewJobsCommand() *JobsCommand {
-	return &JobsCommand{
-		BaseCommand: BaseCommand{
-			Name:        "jobs",
-			Description: "Manage background jobs",
-			Usage:       "jobs <list|status|stop> [args...]",
-		},
-	}
-}
-
-// Execute handles job operations
-func (jc *JobsCommand) Execute(ctx *ShellContext) error {
-	return ctx.Shell.handleJobsCommand(ctx.Args[1:])
-}
-
-// GetCompletions provides jobs subcommand completions
-func (jc *JobsCommand) GetCompletions(partial string, args []string) []string {
-	if len(args) <= 2 {
-		subcommands := []string{"list", "status", "stop"}
-		var completions []string
-		for _, cmd := range subcommands {
-			if strings.HasPrefix(cmd, partial) {
-				completions = append(completions, cmd)
-			}
-		}
-		return completions
-	}
-	return []string{}
-}
-
-// SourcesCommand implements data source management
-type SourcesCommand struct {
-	BaseCommand
-}
-
-// NewSourcesCommand creates a new sources command
-func NewSourcesCommand() *SourcesCommand {
-	return &SourcesCommand{
-		BaseCommand: BaseCommand{
-			Name:        "sources",
-			Description: "Manage data sources",
-			Usage:       "sources <list|status> [args...]",
-		},
-	}
-}
-
-// Execute handles sources operations
-func (sc *SourcesCommand) Execute(ctx *ShellContext) error {
-	return ctx.Shell.handleSourcesCommand(ctx.Args[1:])
-}
-
-// GetCompletions provides sources subcommand completions
-func (sc *SourcesCommand) GetCompletions(partial string, args []string) []string {
-	if len(args) <= 2 {
-		subcommands := []string{"list", "status"}
-		var completions []string
-		for _, cmd := range subcommands {
-			if strings.HasPrefix(cmd, partial) {
-				completions = append(completions, cmd)
-			}
-		}
-		return completions
-	}
-	if len(args) == 3 && args[1] == "status" {
-		sources := []string{"hackernews"}
-		var completions []string
-		for _, source := range sources {
-			if strings.HasPrefix(source, partial) {
-				completions = append(completions, source)
-			}
-		}
-		return completions
-	}
-	return []string{}
-}
@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/command"
+)
+
+// destructiveCommands lists the legacy subcommands that cannot be undone and
+// ask for confirmation; commands of the command framework declare theirs in
+// their spec
+var destructiveCommands = map[string][]string{
+	"derived":    {"drop"},
+	"checkpoint": {"delete", "rm", "prune"},
+	"cache":      {"clear"},
+	"notebook":   {"delete"},
+}
+
+// trashedCommands lists the legacy subcommands that move data to the trash;
+// they only ask for confirmation when the trash is disabled
+var trashedCommands = map[string][]string{
+	"workspace": {"delete", "remove", "rm"},
+}
+
+// guardLegacyCommand applies --dry-run and --yes to a command of the legacy
+// registry, which does not run the command middleware. It returns the
+// arguments without those flags and whether the command should run.
+func (s *EnhancedShell) guardLegacyCommand(parts []string) ([]string, bool, error) {
+	dryRun, yes := false, false
+	args := make([]string, 0, len(parts))
+	for _, part := range parts {
+		switch part {
+		case "--" + command.DryRunFlag:
+			dryRun = true
+		case "--" + command.YesFlag, "-y":
+			yes = true
+		default:
+			args = append(args, part)
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("Dry run: would run %s\n", strings.Join(args, " "))
+		return nil, false, nil
+	}
+
+	destructive := matchesCommand(destructiveCommands, args) ||
+		(s.Shell.trash == nil && matchesCommand(trashedCommands, args))
+	if !destructive || yes {
+		return args, true, nil
+	}
+
+	confirmed, err := s.Confirm(fmt.Sprintf("%s cannot be undone. Continue?", strings.Join(args, " ")))
+	if err != nil {
+		return nil, false, err
+	}
+	if !confirmed {
+		fmt.Println("Cancelled")
+		return nil, false, nil
+	}
+	return args, true, nil
+}
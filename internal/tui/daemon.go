@@ -0,0 +1,171 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/api"
+	"github.com/brainless/PubDataHub/internal/daemon"
+	"github.com/brainless/PubDataHub/internal/jobs"
+)
+
+// attachedDaemon is set when a daemon owns the storage; shells created
+// afterwards send download and jobs commands to it
+var attachedDaemon *daemon.Client
+
+// attachedRole is the role the daemon gave the attached shell's token
+var attachedRole api.Scope
+
+// AttachDaemon makes shells created afterwards read-only, with download and
+// jobs commands sent to the daemon with the given PID. With the read role
+// they can only list jobs and show their status.
+func AttachDaemon(client *daemon.Client, pid int, role api.Scope) {
+	attachedDaemon = client
+	attachedRole = role
+	readOnlyReason = fmt.Sprintf("attached to the daemon (pid %d)", pid)
+	if role == api.ScopeRead {
+		readOnlyReason += " with a read token"
+	}
+}
+
+// requireAdmin returns an error when the attached shell's token cannot run
+// command in the daemon
+func requireAdmin(command string) error {
+	if attachedRole == api.ScopeRead {
+		return fmt.Errorf("%s needs the %s role; this shell attached to the daemon with a %s token", command, api.ScopeAdmin, api.ScopeRead)
+	}
+	return nil
+}
+
+// runDaemonCommand runs download and jobs commands in the attached daemon;
+// handled is false for other commands and when no daemon is attached
+func (s *Shell) runDaemonCommand(parts []string) (handled bool, err error) {
+	if s.daemon == nil || len(parts) == 0 {
+		return false, nil
+	}
+
+	switch parts[0] {
+	case "download":
+		return true, s.daemonDownload(parts[1:])
+	case "jobs":
+		return true, s.daemonJobs(parts[1:])
+	}
+	return false, nil
+}
+
+// daemonDownload starts a download in the daemon
+func (s *Shell) daemonDownload(args []string) error {
+	if err := requireAdmin("download"); err != nil {
+		return err
+	}
+	var source string
+	var batchSize, priority int
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--batch-size", "--priority", "-p":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s needs a value", arg)
+			}
+			value, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return fmt.Errorf("invalid %s value %q", arg, args[i+1])
+			}
+			if arg == "--batch-size" {
+				batchSize = value
+			} else {
+				priority = value
+			}
+			i++
+		case "--resume", "-r":
+			// Downloads always resume
+		default:
+			if strings.HasPrefix(arg, "-") || source != "" {
+				return fmt.Errorf("usage: download <source> [--batch-size N] [--priority N]")
+			}
+			source = arg
+		}
+	}
+	if source == "" {
+		return fmt.Errorf("usage: download <source> [--batch-size N] [--priority N]")
+	}
+
+	job, err := s.daemon.Download(source, batchSize, priority)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started download job %s for %s in the daemon\n", job.ID, source)
+	fmt.Println("It keeps running after you exit; check it with 'jobs'")
+	return nil
+}
+
+// daemonJobs lists and controls the daemon's jobs
+func (s *Shell) daemonJobs(args []string) error {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		active, err := s.daemon.Jobs(jobs.JobFilter{
+			States: []jobs.JobState{jobs.JobStateQueued, jobs.JobStateRunning, jobs.JobStatePaused},
+		})
+		if err != nil {
+			return err
+		}
+		if len(active) == 0 {
+			fmt.Println("No active jobs in the daemon")
+			return nil
+		}
+		fmt.Println("Active jobs in the daemon:")
+		for _, job := range active {
+			fmt.Printf("  %s: %s (%s) - %.1f%% - %s\n", job.ID, job.Description, job.State,
+				job.Progress.Percentage(), job.Progress.Message)
+		}
+		return nil
+	case "status", "pause", "resume", "stop", "cancel":
+		if len(args) < 2 {
+			return fmt.Errorf("%s command requires job ID", args[0])
+		}
+	default:
+		return fmt.Errorf("jobs %s is not available while attached to the daemon", args[0])
+	}
+
+	id := args[1]
+	if args[0] != "status" {
+		if err := requireAdmin("jobs " + args[0]); err != nil {
+			return err
+		}
+	}
+	switch args[0] {
+	case "status":
+		job, err := s.daemon.Job(id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Job %s: %s\n", job.ID, job.Description)
+		fmt.Printf("  State: %s\n", job.State)
+		fmt.Printf("  Progress: %.1f%% (%d/%d) %s\n", job.Progress.Percentage(),
+			job.Progress.Current, job.Progress.Total, job.Progress.Message)
+		if job.ErrorMessage != "" {
+			fmt.Printf("  Error: %s\n", job.ErrorMessage)
+		}
+		return nil
+	case "pause":
+		if err := s.daemon.Pause(id); err != nil {
+			return err
+		}
+		fmt.Printf("Job %s paused\n", id)
+	case "resume":
+		if err := s.daemon.Resume(id); err != nil {
+			return err
+		}
+		fmt.Printf("Job %s resumed\n", id)
+	default:
+		if err := s.daemon.Cancel(id); err != nil {
+			return err
+		}
+		fmt.Printf("Job %s cancelled\n", id)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/storage"
+)
+
+// derivedTableProvider is implemented by data sources that maintain derived tables
+type derivedTableProvider interface {
+	DerivedTables() *storage.DerivedTables
+}
+
+// DerivedCommand manages derived tables built from SQL over a data source
+type DerivedCommand struct {
+	BaseCommand
+}
+
+// NewDerivedCommand creates a new derived command
+func NewDerivedCommand() *DerivedCommand {
+	return &DerivedCommand{
+		BaseCommand: BaseCommand{
+			Name:        "derived",
+			Description: "Manage derived tables maintained by jobs",
+			Usage:       "derived <list|create|refresh|drop> <source> [name] [\"<sql>\"] [--refresh manual|after_sync|\"<cron>\"]",
+		},
+	}
+}
+
+// Execute runs a derived table subcommand
+func (dc *DerivedCommand) Execute(ctx *ShellContext) error {
+	if len(ctx.Args) < 2 {
+		return fmt.Errorf("usage: %s", dc.Usage)
+	}
+	args := ctx.Args[2:]
+
+	switch ctx.Args[1] {
+	case "list":
+		return dc.list(ctx, args)
+	case "create":
+		return dc.create(ctx, args)
+	case "refresh":
+		if len(args) < 1 {
+			return fmt.Errorf("usage: derived refresh <source> [name]")
+		}
+		if _, err := derivedTables(ctx, args[0]); err != nil {
+			return err
+		}
+		name := ""
+		if len(args) > 1 {
+			name = args[1]
+		}
+		return dc.submitRefresh(ctx, args[0], name)
+	case "drop":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: derived drop <source> <name>")
+		}
+		derived, err := derivedTables(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		if err := derived.Drop(args[1]); err != nil {
+			return err
+		}
+		if jm := ctx.Shell.jobManager; jm != nil {
+			jm.UnscheduleDerivedRefresh(args[0], args[1])
+		}
+		fmt.Printf("Dropped derived table %s.%s\n", args[0], args[1])
+		return nil
+	default:
+		return fmt.Errorf("unknown derived subcommand: %s", ctx.Args[1])
+	}
+}
+
+// list prints the derived tables of one or all sources
+func (dc *DerivedCommand) list(ctx *ShellContext, args []string) error {
+	sources := args
+	if len(sources) == 0 {
+		for name, ds := range ctx.DataSources {
+			if _, ok := ds.(derivedTableProvider); ok {
+				sources = append(sources, name)
+			}
+		}
+		sort.Strings(sources)
+	}
+
+	found := false
+	for _, source := range sources {
+		derived, err := derivedTables(ctx, source)
+		if err != nil {
+			return err
+		}
+		tables, err := derived.List()
+		if err != nil {
+			return err
+		}
+		for _, table := range tables {
+			found = true
+			refreshed := "never"
+			if table.RefreshedAt != nil {
+				refreshed = table.RefreshedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("  %s.%-24s %-12s %8d rows  refreshed %s\n",
+				source, table.Name, table.Refresh, table.RowCount, refreshed)
+			if table.LastError != "" {
+				fmt.Printf("      last error: %s\n", table.LastError)
+			}
+		}
+	}
+	if !found {
+		fmt.Println("No derived tables defined")
+	}
+	return nil
+}
+
+// create defines a derived table and builds it
+func (dc *DerivedCommand) create(ctx *ShellContext, args []string) error {
+	refresh := storage.RefreshManual
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--refresh" {
+			if i+1 >= len(args) {
+				return fmt.Errorf("--refresh requires a value")
+			}
+			refresh = args[i+1]
+			i++
+			continue
+		}
+		positional = append(positional, args[i])
+	}
+	if len(positional) < 3 {
+		return fmt.Errorf("usage: derived create <source> <name> \"<sql>\" [--refresh manual|after_sync|\"<cron>\"]")
+	}
+	source, name, query := positional[0], positional[1], strings.Join(positional[2:], " ")
+
+	derived, err := derivedTables(ctx, source)
+	if err != nil {
+		return err
+	}
+	existing, err := derived.Get(name)
+	if err != nil {
+		return err
+	}
+	if err := derived.Define(name, query, refresh); err != nil {
+		return err
+	}
+
+	table, err := derived.Get(name)
+	if err != nil {
+		return err
+	}
+	if jm := ctx.Shell.jobManager; jm != nil {
+		if err := jm.ScheduleDerivedRefresh(source, *table); err != nil {
+			// Do not leave a new definition behind with an unusable schedule
+			if existing == nil {
+				derived.Drop(name)
+			}
+			return err
+		}
+	}
+
+	fmt.Printf("Defined derived table %s.%s (refresh: %s)\n", source, name, refresh)
+	return dc.submitRefresh(ctx, source, name)
+}
+
+// submitRefresh rebuilds derived tables in a maintenance job, or directly
+// when no job manager is available
+func (dc *DerivedCommand) submitRefresh(ctx *ShellContext, source, name string) error {
+	if jm := ctx.Shell.jobManager; jm != nil {
+		jobID, err := jm.RefreshDerivedTable(source, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Refresh job %s started\n", jobID)
+		return nil
+	}
+
+	derived, err := derivedTables(ctx, source)
+	if err != nil {
+		return err
+	}
+	names := []string{name}
+	if name == "" {
+		tables, err := derived.List()
+		if err != nil {
+			return err
+		}
+		names = names[:0]
+		for _, table := range tables {
+			names = append(names, table.Name)
+		}
+	}
+	for _, table := range names {
+		count, err := derived.Refresh(table)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Refreshed %s.%s (%d rows)\n", source, table, count)
+	}
+	return nil
+}
+
+// GetCompletions completes derived subcommands and source names
+func (dc *DerivedCommand) GetCompletions(partial string, args []string) []string {
+	var options []string
+	switch len(args) {
+	case 0:
+		options = []string{"list", "create", "refresh", "drop"}
+	case 1:
+		options = []string{"hackernews"}
+	default:
+		if args[0] == "create" {
+			options = []string{"--refresh"}
+		}
+	}
+
+	var completions []string
+	for _, option := range options {
+		if strings.HasPrefix(option, partial) {
+			completions = append(completions, option)
+		}
+	}
+	return completions
+}
+
+// derivedTables returns the derived tables of a data source
+func derivedTables(ctx *ShellContext, source string) (*storage.DerivedTables, error) {
+	ds, exists := ctx.DataSources[source]
+	if !exists {
+		return nil, fmt.Errorf("unknown data source: %s", source)
+	}
+	provider, ok := ds.(derivedTableProvider)
+	if !ok || provider.DerivedTables() == nil {
+		return nil, fmt.Errorf("data source %s does not support derived tables", source)
+	}
+	return provider.DerivedTables(), nil
+}
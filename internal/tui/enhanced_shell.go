@@ -1,33 +1,52 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/brainless/PubDataHub/internal/command"
+	"github.com/brainless/PubDataHub/internal/config"
 	"github.com/brainless/PubDataHub/internal/jobs"
 	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/plugin"
+	"github.com/brainless/PubDataHub/internal/shutdown"
+	"github.com/brainless/PubDataHub/internal/storage"
+	"github.com/brainless/PubDataHub/internal/workspace"
 	"github.com/chzyer/readline"
 )
 
 // EnhancedShell represents the enhanced interactive shell with readline support
 type EnhancedShell struct {
-	*Shell             // Embed the original Shell
-	registry           *CommandRegistry
-	commandIntegration *command.ShellIntegration
-	readline           *readline.Instance
-	historyFile        string
-	prompt             string
-	aliasManager       *AliasManager
-	workspaceManager   *WorkspaceManager
-	terminalManager    *TerminalManager
-	statusBar          *StatusBar
+	*Shell           // Embed the original Shell
+	registry         *CommandRegistry
+	readline         *readline.Instance
+	historyFile      string
+	prompt           string
+	aliasManager     *AliasManager
+	workspaceManager *WorkspaceManager
+	terminalManager  *TerminalManager
+	statusBar        *StatusBar
+	sessionManager   *ShellSessionManager
+	stateManager     *shutdown.StateManager // Records whether the shell shut down cleanly
+	pendingInput     string                 // Text the next prompt starts with, such as input recovered from the previous session
+	keys             *Keymap
+	keyInput         *keyReader
+
+	promptMu  sync.Mutex
+	reading   bool        // Whether the prompt waits for a command, so live updates redraw it
+	mouseOff  func()      // Turns off mouse reporting, which is on while the prompt waits
+	lastQuery promptState // Source and duration of the last query
+
+	sourceWorkspace string // Workspace whose data source the session uses
 }
 
 // NewEnhancedShell creates a new enhanced shell instance
@@ -35,12 +54,6 @@ func NewEnhancedShell() (*EnhancedShell, error) {
 	// Create the base shell first
 	baseShell := NewShell()
 
-	// Create command integration
-	commandIntegration := command.NewShellIntegration()
-	if err := commandIntegration.RegisterApplicationCommands(); err != nil {
-		log.Logger.Warnf("Failed to register application commands: %v", err)
-	}
-
 	// Create alias manager
 	aliasManager, err := NewAliasManager()
 	if err != nil {
@@ -53,10 +66,11 @@ func NewEnhancedShell() (*EnhancedShell, error) {
 		log.Logger.Warnf("Could not get home directory: %v", err)
 		homeDir = "."
 	}
-	workspaceDir := filepath.Join(homeDir, ".pubdatahub_workspaces")
-	workspaceManager, err := NewWorkspaceManager(workspaceDir)
+	workspaceManager, err := NewWorkspaceManager(workspace.DefaultDir())
 	if err != nil {
 		log.Logger.Warnf("Failed to create workspace manager: %v", err)
+	} else if baseShell.trash != nil {
+		workspaceManager.SetTrash(baseShell.trash)
 	}
 
 	// Initialize terminal manager and status bar
@@ -64,15 +78,23 @@ func NewEnhancedShell() (*EnhancedShell, error) {
 	statusBar := NewStatusBar(terminalManager)
 
 	shell := &EnhancedShell{
-		Shell:              baseShell,
-		registry:           NewCommandRegistry(),
-		commandIntegration: commandIntegration,
-		prompt:             "> ",
-		aliasManager:       aliasManager,
-		workspaceManager:   workspaceManager,
-		terminalManager:    terminalManager,
-		statusBar:          statusBar,
+		Shell:            baseShell,
+		registry:         NewCommandRegistry(),
+		prompt:           defaultPrompt,
+		aliasManager:     aliasManager,
+		workspaceManager: workspaceManager,
+		terminalManager:  terminalManager,
+		statusBar:        statusBar,
+	}
+	baseShell.statusBar = statusBar
+
+	keys, keyErr := shellKeymap()
+	if keyErr != nil {
+		log.Logger.Warnf("%v; using the default key bindings", keyErr)
 	}
+	shell.keys = keys
+	shell.keyInput = newKeyReader(readline.Stdin, keys)
+	shell.keyInput.mouse = shell.statusBarClick
 
 	// Set up history file
 	if err == nil {
@@ -89,9 +111,86 @@ func NewEnhancedShell() (*EnhancedShell, error) {
 	// Register commands (data sources already initialized by base shell)
 	shell.registerCommands()
 
+	// Recover the previous session (history, workspace, unfinished input);
+	// session state belongs to the instance holding the storage lock
+	if baseShell.readOnly == "" {
+		shell.recoverSession()
+	}
+
 	return shell, nil
 }
 
+// recoverableDatabase is implemented by data sources whose database can be
+// checked and repaired at startup
+type recoverableDatabase interface {
+	RecoverableDatabase() *storage.RecoverableDB
+}
+
+// recoverSession wires the session manager and the databases to the recovery
+// system, restoring the previous session and, after a crash, checking the
+// databases
+func (s *EnhancedShell) recoverSession() {
+	stateManager, err := shutdown.NewStateManager(config.AppConfig.StoragePath, 3)
+	if err != nil {
+		log.Logger.Warnf("Session recovery disabled: %v", err)
+		return
+	}
+
+	s.sessionManager = NewShellSessionManager(stateManager, s)
+	s.stateManager = stateManager
+
+	recovery := shutdown.NewRecovery(stateManager, shutdown.DefaultRecoveryConfig())
+	handler := shutdown.NewSessionRecoveryHandler(s.sessionManager, 0)
+	if err := recovery.RegisterRecoveryHandler("session", handler); err != nil {
+		log.Logger.Warnf("Failed to register session recovery: %v", err)
+		return
+	}
+
+	databases := make(map[string]*storage.RecoverableDB)
+	for name, ds := range s.Shell.dataSources {
+		if recoverable, ok := ds.(recoverableDatabase); ok {
+			if db := recoverable.RecoverableDatabase(); db != nil {
+				databases["database-"+name] = db
+			}
+		}
+	}
+	if s.Shell.jobManager != nil {
+		databases["database-jobs"] = s.Shell.jobManager.RecoverableDatabase()
+	}
+	for name, db := range databases {
+		if err := recovery.RegisterRecoveryHandler(name, shutdown.NewDatabaseRecoveryHandler(db, 0)); err != nil {
+			log.Logger.Warnf("Failed to register %s recovery: %v", name, err)
+		}
+	}
+
+	if err := recovery.PerformRecovery(); err != nil {
+		log.Logger.Warnf("Session recovery failed: %v", err)
+	}
+
+	// Until shutdown records otherwise, the next start treats this run as
+	// a crash
+	s.saveApplicationState(false)
+}
+
+// saveApplicationState records whether the shell shut down cleanly, which
+// decides whether the next start checks the databases
+func (s *EnhancedShell) saveApplicationState(clean bool) {
+	if s.stateManager == nil {
+		return
+	}
+	now := time.Now()
+	appState := shutdown.ApplicationState{
+		Application: shutdown.ApplicationInfo{PID: os.Getpid(), CleanShutdown: clean},
+		Timestamp:   now,
+	}
+	if clean {
+		appState.Application.ShutdownTime = now
+	}
+	if err := s.stateManager.SaveApplicationState(appState); err != nil {
+		log.Logger.Warnf("Failed to save application state: %v", err)
+	}
+}
+
 // initReadline sets up the readline instance with completions and history
 func (s *EnhancedShell) initReadline() error {
 	config := &readline.Config{
@@ -102,6 +201,10 @@ func (s *EnhancedShell) initReadline() error {
 		EOFPrompt:           "exit",
 		HistorySearchFold:   true,
 		FuncFilterInputRune: s.filterInput,
+		Stdin:               readline.NewCancelableStdin(s.keyInput),
+		// Lines ended by a bound key are not commands; history is saved
+		// by the input loop instead
+		DisableAutoSaveHistory: true,
 	}
 
 	rl, err := readline.NewEx(config)
@@ -129,12 +232,12 @@ func (cc *CustomCompleter) Do(line []rune, pos int) (newLine [][]rune, length in
 	lineStr := string(line[:pos])
 
 	// Get completions from new command system
-	completions := cc.shell.commandIntegration.GetCompletions(
+	completions := cc.shell.Shell.commands.GetCompletions(
 		cc.shell.Shell.ctx,
 		lineStr,
 		cc.shell.Shell.jobManager,
 		cc.shell.Shell.dataSources,
-		nil, // config
+		config.AppConfig,
 	)
 
 	// If no completions from new system, try legacy
@@ -187,50 +290,262 @@ func (cc *CustomCompleter) getLegacyCompletions(input string) []string {
 // buildCompletionTree builds the completion tree for all commands
 func (s *EnhancedShell) buildCompletionTree() []readline.PrefixCompleterInterface {
 	var items []readline.PrefixCompleterInterface
+	for _, cmdName := range s.commandNames() {
+		items = append(items, s.buildCommandCompletion(cmdName))
+	}
+	return items
+}
 
-	for _, cmdName := range s.registry.List() {
-		if handler, exists := s.registry.Get(cmdName); exists {
-			item := s.buildCommandCompletion(cmdName, handler)
-			items = append(items, item)
+// commandNames returns the commands of the command system and the legacy
+// registry, sorted
+func (s *EnhancedShell) commandNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, commands := range s.Shell.commands.ListCommands() {
+		for _, name := range commands {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
 		}
 	}
-
-	return items
+	for _, name := range s.registry.List() {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 // buildCommandCompletion builds completion tree for a specific command
-func (s *EnhancedShell) buildCommandCompletion(cmdName string, handler CommandHandler) readline.PrefixCompleterInterface {
+func (s *EnhancedShell) buildCommandCompletion(cmdName string) readline.PrefixCompleterInterface {
 	switch cmdName {
 	case "config":
 		return readline.PcItem("config",
 			readline.PcItem("show"),
+			readline.PcItem("set"),
 			readline.PcItem("set-storage"),
+			readline.PcItem("secret",
+				readline.PcItem("list"),
+				readline.PcItem("set"),
+				readline.PcItem("delete"),
+			),
 		)
 	case "download":
 		return readline.PcItem("download",
 			readline.PcItem("hackernews"),
+			readline.PcItem("--batch-size"),
+			readline.PcItem("--priority"),
 		)
 	case "query":
 		return readline.PcItem("query",
 			readline.PcItem("hackernews"),
+			readline.PcItem("diff",
+				readline.PcItem("hackernews"),
+				readline.PcItem("--key"),
+				readline.PcItem("--snapshot"),
+				readline.PcItem("--keep"),
+				readline.PcItem("--period"),
+				readline.PcItem("--before"),
+				readline.PcItem("--after"),
+			),
+			readline.PcItem("--chart"),
+			readline.PcItem("--copy"),
+			readline.PcItem("--limit"),
+			readline.PcItem("--output"),
+			readline.PcItem("--format",
+				readline.PcItem("table"),
+				readline.PcItem("csv"),
+				readline.PcItem("tsv"),
+				readline.PcItem("json"),
+			),
+		)
+	case ".chart":
+		return readline.PcItem(".chart",
+			readline.PcItem("bar:"),
+			readline.PcItem("spark:"),
+			readline.PcItem("hist:"),
+		)
+	case ".export":
+		formats := make([]readline.PrefixCompleterInterface, 0, 5)
+		for _, format := range []string{"csv", "tsv", "json", "sqlite", "xlsx"} {
+			formats = append(formats, readline.PcItem(format))
+		}
+		return readline.PcItem(".export", append(formats, readline.PcItem("--compress"), readline.PcItem("--redact"),
+			readline.PcItem("--on-complete"), readline.PcItem("--webhook"))...)
+	case ".browse":
+		return readline.PcItem(".browse",
+			readline.PcItem("hackernews"),
+		)
+	case ".edit":
+		return readline.PcItem(".edit",
+			readline.PcItem("hackernews"),
+		)
+	case "thread":
+		return readline.PcItem("thread",
+			readline.PcItem("hackernews"),
+			readline.PcItem("--depth"),
+			readline.PcItem("--width"),
+			readline.PcItem("--output"),
+			readline.PcItem("--format",
+				readline.PcItem("text"),
+				readline.PcItem("markdown"),
+				readline.PcItem("json"),
+			),
+		)
+	case "report":
+		return readline.PcItem("report",
+			readline.PcItem("list"),
+			readline.PcItem("create",
+				readline.PcItem("--source"),
+				readline.PcItem("--template"),
+				readline.PcItem("--template-file"),
+				readline.PcItem("--output"),
+				readline.PcItem("--webhook"),
+				readline.PcItem("--schedule"),
+				readline.PcItem("--description"),
+			),
+			readline.PcItem("show"),
+			readline.PcItem("run"),
+			readline.PcItem("delete"),
 		)
 	case "jobs":
 		return readline.PcItem("jobs",
 			readline.PcItem("list"),
 			readline.PcItem("status"),
 			readline.PcItem("stop"),
+			readline.PcItem("cancel",
+				readline.PcItem("--source"),
+				readline.PcItem("--state"),
+				readline.PcItem("--dry-run"),
+				readline.PcItem("--yes"),
+			),
+			readline.PcItem("retry",
+				readline.PcItem("--failed"),
+				readline.PcItem("--since"),
+			),
+			readline.PcItem("cleanup",
+				readline.PcItem("--older-than"),
+				readline.PcItem("--dry-run"),
+				readline.PcItem("--yes"),
+			),
+			readline.PcItem("history",
+				readline.PcItem("--all"),
+			),
+			readline.PcItem("top",
+				readline.PcItem("--once"),
+			),
+			readline.PcItem("disk",
+				readline.PcItem("override"),
+			),
 		)
 	case "sources":
 		return readline.PcItem("sources",
 			readline.PcItem("list"),
+			readline.PcItem("enable",
+				readline.PcItem("hackernews"),
+			),
+			readline.PcItem("disable",
+				readline.PcItem("hackernews"),
+			),
 			readline.PcItem("status",
 				readline.PcItem("hackernews"),
 			),
+			readline.PcItem("redownload",
+				readline.PcItem("hackernews",
+					readline.PcItem("--ids"),
+					readline.PcItem("--range"),
+				),
+			),
+			readline.PcItem("export-dataset",
+				readline.PcItem("hackernews"),
+			),
+			readline.PcItem("import-dataset",
+				readline.PcItem("--yes"),
+			),
+		)
+	case "cache":
+		return readline.PcItem("cache",
+			readline.PcItem("http",
+				readline.PcItem("stats"),
+				readline.PcItem("clear",
+					readline.PcItem("--yes"),
+				),
+			),
+		)
+	case "derived":
+		return readline.PcItem("derived",
+			readline.PcItem("list"),
+			readline.PcItem("create",
+				readline.PcItem("hackernews"),
+			),
+			readline.PcItem("refresh",
+				readline.PcItem("hackernews"),
+			),
+			readline.PcItem("drop",
+				readline.PcItem("hackernews"),
+				readline.PcItem("--yes"),
+			),
+		)
+	case "metrics":
+		return readline.PcItem("metrics",
+			readline.PcItem("history",
+				readline.PcItem("--source",
+					readline.PcItem("hackernews"),
+				),
+				readline.PcItem("--type"),
+				readline.PcItem("--limit"),
+			),
+		)
+	case "checkpoint":
+		return readline.PcItem("checkpoint",
+			readline.PcItem("list",
+				readline.PcItem("hackernews"),
+			),
+			readline.PcItem("get",
+				readline.PcItem("hackernews"),
+			),
+			readline.PcItem("set",
+				readline.PcItem("hackernews"),
+			),
+			readline.PcItem("delete",
+				readline.PcItem("hackernews"),
+				readline.PcItem("--yes"),
+			),
+			readline.PcItem("prune",
+				readline.PcItem("hackernews",
+					readline.PcItem("--older-than"),
+				),
+			),
+		)
+	case "notebook":
+		return readline.PcItem("notebook",
+			readline.PcItem("create"),
+			readline.PcItem("list"),
+			readline.PcItem("add"),
+			readline.PcItem("note"),
+			readline.PcItem("remove"),
+			readline.PcItem("show"),
+			readline.PcItem("run"),
+			readline.PcItem("export"),
+			readline.PcItem("delete"),
+		)
+	case "status":
+		statusItems := []readline.PrefixCompleterInterface{readline.PcItem("--verbose"), readline.PcItem("--json")}
+		for _, component := range command.StatusComponents {
+			statusItems = append(statusItems, readline.PcItem(component))
+		}
+		return readline.PcItem("status", statusItems...)
+	case "undo":
+		return readline.PcItem("undo",
+			readline.PcItem("list"),
 		)
 	case "help":
 		// Build help completions for all commands
 		helpItems := make([]readline.PrefixCompleterInterface, 0)
-		for _, name := range s.registry.List() {
+		for _, name := range s.commandNames() {
 			helpItems = append(helpItems, readline.PcItem(name))
 		}
 		return readline.PcItem("help", helpItems...)
@@ -251,11 +566,10 @@ func (s *EnhancedShell) registerCommands() {
 	s.registry.Register("help", NewHelpCommand(s.registry))
 	s.registry.Register("exit", NewExitCommand())
 	s.registry.Register("quit", NewExitCommand()) // Alias for exit
-	s.registry.Register("config", NewConfigCommand())
-	s.registry.Register("download", NewDownloadCommand())
-	s.registry.Register("query", NewQueryCommand())
-	s.registry.Register("jobs", NewJobsCommand())
-	s.registry.Register("sources", NewSourcesCommand())
+	s.registry.Register("cache", NewCacheCommand())
+	s.registry.Register("derived", NewDerivedCommand())
+	s.registry.Register("checkpoint", NewCheckpointCommand())
+	s.registry.Register("keys", NewKeysCommand(s.keys))
 
 	// Register enhanced features
 	if s.aliasManager != nil {
@@ -263,10 +577,27 @@ func (s *EnhancedShell) registerCommands() {
 	}
 	if s.workspaceManager != nil {
 		s.registry.Register("workspace", NewWorkspaceCommand(s.workspaceManager))
+		s.registry.Register("notebook", NewNotebookCommand(s.workspaceManager))
 	}
 
 	// Register demo command for testing
 	s.registry.Register("demo-status", NewDemoCommand(s))
+
+	s.registerPlugins()
+}
+
+// registerPlugins makes the plugins in the plugins directory and on PATH
+// shell commands; a plugin named like a built-in command is skipped
+func (s *EnhancedShell) registerPlugins() {
+	for _, p := range plugin.Discover(plugin.Dir(config.Dir())) {
+		if _, exists := s.registry.Get(p.Name); exists {
+			log.Logger.Warnf("Plugin %s is hidden by the built-in %s command", p.Path, p.Name)
+			continue
+		}
+		if err := s.Shell.commands.RegisterPlugin(p); err != nil {
+			log.Logger.Warnf("Plugin %s is hidden by the built-in %s command", p.Path, p.Name)
+		}
+	}
 }
 
 // Run starts the enhanced interactive shell
@@ -292,6 +623,7 @@ func (s *EnhancedShell) Run() error {
 	fmt.Println("Type 'help' for available commands or 'exit' to quit")
 	fmt.Println("Features: Command history, tab completion, multi-line support")
 	fmt.Println()
+	s.showReadOnlyBanner()
 
 	// Always reserve bottom line for status - permanently
 	s.terminalManager.SetStatusBarHeight(1)
@@ -300,6 +632,10 @@ func (s *EnhancedShell) Run() error {
 	s.statusBar.Start()
 	s.statusBar.ShowPersistentStatusLine()
 
+	// Reflow the fixed layout when the terminal is resized
+	s.terminalManager.OnResize(s.handleResize)
+	s.terminalManager.WatchResize()
+
 	// Disable old progress display to avoid conflicts
 	if s.Shell.progressDisplay != nil {
 		s.Shell.progressDisplay.Disable()
@@ -308,6 +644,8 @@ func (s *EnhancedShell) Run() error {
 	// Start job event consumer to populate status bar
 	s.startJobEventConsumer()
 
+	s.restoreActiveSource()
+
 	// Main input loop
 	for {
 		select {
@@ -317,7 +655,18 @@ func (s *EnhancedShell) Run() error {
 			// Ensure prompt stays above status line before reading input
 			s.ensurePromptAboveStatusLine()
 
-			line, err := s.readline.Readline()
+			var line string
+			var err error
+			s.keyInput.takeCommand()
+			s.refreshPrompt()
+			s.setReading(true)
+			if s.pendingInput != "" {
+				line, err = s.readline.ReadlineWithDefault(s.pendingInput)
+				s.pendingInput = ""
+			} else {
+				line, err = s.readline.Readline()
+			}
+			s.setReading(false)
 			if err != nil {
 				if err == readline.ErrInterrupt {
 					if len(line) == 0 {
@@ -336,6 +685,15 @@ func (s *EnhancedShell) Run() error {
 				return s.shutdown()
 			}
 
+			if command := s.keyInput.takeCommand(); command != "" {
+				// A bound key ended the prompt; what was typed is kept
+				// for the next one
+				s.pendingInput = line
+				line = command
+			} else if strings.TrimSpace(line) != "" {
+				s.readline.SaveHistory(line)
+			}
+
 			input := strings.TrimSpace(line)
 			if input == "" {
 				continue
@@ -351,6 +709,10 @@ func (s *EnhancedShell) Run() error {
 				input = fullInput
 			}
 
+			if s.sessionManager != nil {
+				s.sessionManager.RecordCommand(input)
+			}
+
 			// Try to expand aliases first
 			if s.aliasManager != nil {
 				if expandedInput, wasExpanded := s.aliasManager.ExpandAlias(input); wasExpanded {
@@ -365,6 +727,7 @@ func (s *EnhancedShell) Run() error {
 				}
 				fmt.Printf("Error: %v\n", err)
 			}
+			s.syncActiveSource()
 		}
 	}
 }
@@ -378,16 +741,18 @@ func (s *EnhancedShell) isMultiLineCommand(input string) bool {
 // handleMultiLineInput handles multi-line input for complex queries
 func (s *EnhancedShell) handleMultiLineInput(initialInput string) (string, error) {
 	lines := []string{strings.TrimSuffix(initialInput, "\\")}
+	s.savePendingInput(lines)
 
 	// Change prompt to indicate continuation
 	s.readline.SetPrompt("... ")
-	defer s.readline.SetPrompt(s.prompt)
+	defer s.readline.SetPrompt(s.currentPrompt())
 
 	for {
 		line, err := s.readline.Readline()
 		if err != nil {
 			return "", err
 		}
+		s.readline.SaveHistory(line)
 
 		line = strings.TrimSpace(line)
 		if line == "" {
@@ -398,6 +763,7 @@ func (s *EnhancedShell) handleMultiLineInput(initialInput string) (string, error
 		if strings.HasSuffix(line, "\\") {
 			// Continue on next line
 			lines = append(lines, strings.TrimSuffix(line, "\\"))
+			s.savePendingInput(lines)
 		} else {
 			// Final line
 			lines = append(lines, line)
@@ -408,22 +774,33 @@ func (s *EnhancedShell) handleMultiLineInput(initialInput string) (string, error
 	return strings.Join(lines, " "), nil
 }
 
+// savePendingInput persists unfinished multi-line input so a crash doesn't lose it
+func (s *EnhancedShell) savePendingInput(lines []string) {
+	if s.sessionManager != nil {
+		s.sessionManager.SetPendingInput(lines)
+	}
+}
+
 // processCommand handles individual commands using the enhanced command system
 func (s *EnhancedShell) processCommand(input string) error {
-	// Try the new command system first
-	err := s.commandIntegration.ProcessCommand(
-		s.Shell.ctx,
-		input,
-		s.Shell.jobManager,
-		s.Shell.dataSources,
-		nil, // config - could be added later
-	)
+	if handled, err := s.runDaemonCommand(parseCommandArgs(input)); handled {
+		return err
+	}
+	if err := s.checkReadOnly(parseCommandArgs(input)); err != nil {
+		return err
+	}
 
-	// If command not found in new system, fall back to old registry
-	if err != nil && strings.Contains(err.Error(), "not fully implemented yet") {
-		return s.processLegacyCommand(input)
+	// Commands only known to the legacy registry (alias, workspace) go straight there
+	if parts := parseCommandArgs(input); len(parts) > 0 {
+		if _, exists := s.Shell.commands.GetRegistry().GetHandler(parts[0]); !exists {
+			if _, legacy := s.registry.Get(parts[0]); legacy {
+				return s.processLegacyCommand(input)
+			}
+		}
 	}
 
+	err := s.runCommand(input, s)
+
 	// Handle demo command directly for testing
 	if err != nil && strings.HasPrefix(input, "demo-status") {
 		s.DemoStatusBar()
@@ -435,9 +812,9 @@ func (s *EnhancedShell) processCommand(input string) error {
 
 // processLegacyCommand handles commands using the legacy registry
 func (s *EnhancedShell) processLegacyCommand(input string) error {
-	parts := parseCommandArgs(input)
-	if len(parts) == 0 {
-		return nil
+	parts, run, err := s.guardLegacyCommand(parseCommandArgs(input))
+	if err != nil || !run || len(parts) == 0 {
+		return err
 	}
 
 	commandName := parts[0]
@@ -453,6 +830,7 @@ func (s *EnhancedShell) processLegacyCommand(input string) error {
 		RawInput:    input,
 		Context:     s.Shell.ctx,
 		DataSources: make(map[string]interface{}),
+		ReadSecret:  s.ReadSecret,
 	}
 
 	// Populate data sources in context
@@ -463,14 +841,196 @@ func (s *EnhancedShell) processLegacyCommand(input string) error {
 	return handler.Execute(ctx)
 }
 
+// OutputSettings returns the query output settings of the active workspace
+func (s *EnhancedShell) OutputSettings() command.OutputSettings {
+	if s.workspaceManager == nil {
+		return s.Shell.OutputSettings()
+	}
+	return outputSettings(s.workspaceManager.CurrentSettings())
+}
+
+// BrowseSchema runs the schema browser of a data source; the query it built
+// becomes the input of the next prompt, ready to edit and run
+func (s *EnhancedShell) BrowseSchema(sourceName string) error {
+	buffer, err := s.browseSchema(sourceName)
+	if err != nil || buffer == "" {
+		return err
+	}
+	s.pendingInput = fmt.Sprintf("query %s %s", sourceName, buffer)
+	return nil
+}
+
+// Confirm asks a yes/no question with readline
+func (s *EnhancedShell) Confirm(prompt string) (bool, error) {
+	s.readline.SetPrompt(prompt + " [y/N] ")
+	defer s.readline.SetPrompt(s.currentPrompt())
+
+	answer, err := s.readline.Readline()
+	if err == readline.ErrInterrupt || err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read answer: %w", err)
+	}
+	return isYes(answer), nil
+}
+
+// ReadSecret reads a line without echo through readline, which keeps it out
+// of the history
+func (s *EnhancedShell) ReadSecret(prompt string) (string, error) {
+	value, err := s.readline.ReadPassword(prompt)
+	if err == readline.ErrInterrupt || err == io.EOF {
+		return "", fmt.Errorf("cancelled")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return string(value), nil
+}
+
 // SetPrompt updates the shell prompt
 func (s *EnhancedShell) SetPrompt(prompt string) {
+	s.promptMu.Lock()
+	defer s.promptMu.Unlock()
 	s.prompt = prompt
 	if s.readline != nil {
 		s.readline.SetPrompt(prompt)
 	}
 }
 
+// currentPrompt returns the shell prompt
+func (s *EnhancedShell) currentPrompt() string {
+	s.promptMu.Lock()
+	defer s.promptMu.Unlock()
+	return s.prompt
+}
+
+// setReading records whether the prompt waits for a command, showing the
+// current prompt when it starts to
+func (s *EnhancedShell) setReading(reading bool) {
+	s.promptMu.Lock()
+	defer s.promptMu.Unlock()
+	s.reading = reading
+	if reading && s.readline != nil {
+		s.readline.SetPrompt(s.prompt)
+	}
+
+	// Clicks are only reported at the prompt; full-screen views turn the
+	// mouse on themselves and command output stays selectable
+	switch {
+	case reading && s.mouseOff == nil:
+		s.mouseOff = enableMouse()
+	case !reading && s.mouseOff != nil:
+		s.mouseOff()
+		s.mouseOff = nil
+	}
+}
+
+// promptTemplate returns the prompt template of the active workspace, or
+// the configured one
+func (s *EnhancedShell) promptTemplate() string {
+	if s.workspaceManager != nil {
+		if template := s.workspaceManager.CurrentSettings().Prompt; template != "" {
+			return template
+		}
+	}
+	if template := config.AppConfig.UI.Prompt; template != "" {
+		if err := workspace.ValidatePrompt(template); err == nil {
+			return template
+		}
+	}
+	return defaultPrompt
+}
+
+// restoreActiveSource uses the data source last chosen with use in the
+// active workspace
+func (s *EnhancedShell) restoreActiveSource() {
+	if s.workspaceManager == nil || s.Shell.commands == nil {
+		return
+	}
+	s.sourceWorkspace = ""
+	if workspace := s.workspaceManager.GetCurrentWorkspace(); workspace != nil {
+		s.sourceWorkspace = workspace.Name
+	}
+	command.SetActiveSource(s.Shell.commands.GetSession(), s.workspaceManager.SessionDataSource())
+}
+
+// syncActiveSource saves the data source chosen with use in the active
+// workspace, or restores the source of a workspace switched to
+func (s *EnhancedShell) syncActiveSource() {
+	if s.workspaceManager == nil || s.Shell.commands == nil {
+		return
+	}
+	workspace := s.workspaceManager.GetCurrentWorkspace()
+	if workspace == nil {
+		return
+	}
+	if workspace.Name != s.sourceWorkspace {
+		s.restoreActiveSource()
+		return
+	}
+
+	source := command.ActiveSource(s.Shell.commands.GetSession())
+	if source != s.workspaceManager.SessionDataSource() {
+		if err := s.workspaceManager.SetSessionDataSource(source); err != nil {
+			log.Logger.Warnf("Failed to save the data source in use: %v", err)
+		}
+	}
+}
+
+// refreshPrompt records the data source and duration of the last query
+// for the prompt and redraws it. The session is only used by the input
+// loop, which calls this before each prompt.
+func (s *EnhancedShell) refreshPrompt() {
+	if s.Shell.commands != nil {
+		session := s.Shell.commands.GetSession()
+		source, duration := command.LastQuery(session)
+		if active := command.ActiveSource(session); active != "" {
+			source = active
+		}
+		s.promptMu.Lock()
+		s.lastQuery = promptState{source: source, lastQuery: duration}
+		s.promptMu.Unlock()
+	}
+	s.redrawPrompt()
+}
+
+// redrawPrompt fills the prompt template with the current workspace, data
+// source, running jobs and last query time, redrawing a waiting prompt when
+// it changed
+func (s *EnhancedShell) redrawPrompt() {
+	s.promptMu.Lock()
+	state := s.lastQuery
+	s.promptMu.Unlock()
+
+	settings := workspace.DefaultSettings()
+	if s.workspaceManager != nil {
+		if workspace := s.workspaceManager.GetCurrentWorkspace(); workspace != nil {
+			state.workspace = workspace.Name
+		}
+		settings = s.workspaceManager.CurrentSettings()
+	}
+	if state.source == "" {
+		state.source = settings.DefaultDataSource
+	}
+	if s.Shell.jobManager != nil {
+		state.jobs = len(s.Shell.jobManager.GetRunningJobs())
+	}
+	prompt := formatPrompt(s.promptTemplate(), state)
+
+	s.promptMu.Lock()
+	defer s.promptMu.Unlock()
+	if prompt == s.prompt {
+		return
+	}
+	s.prompt = prompt
+	// Other prompts, such as confirmations, are left alone
+	if s.readline != nil && s.reading {
+		s.readline.SetPrompt(prompt)
+		s.readline.Refresh()
+	}
+}
+
 // setupFixedLayout initializes the terminal for fixed layout with reserved status line
 func (s *EnhancedShell) setupFixedLayout() {
 	// Clear screen and move cursor to top
@@ -482,6 +1042,18 @@ func (s *EnhancedShell) setupFixedLayout() {
 	}
 }
 
+// handleResize moves the scrolling region and status line to the new last
+// row and redraws the prompt, which the terminal may have wrapped
+func (s *EnhancedShell) handleResize(size TerminalSize) {
+	if s.terminalManager.IsANSISupported() {
+		s.terminalManager.SetupScrollingRegion()
+	}
+	s.statusBar.Resize(size)
+	if s.readline != nil {
+		s.readline.Refresh()
+	}
+}
+
 // ensurePromptAboveStatusLine ensures the prompt never overlaps with the status line
 func (s *EnhancedShell) ensurePromptAboveStatusLine() {
 	if !s.terminalManager.IsANSISupported() {
@@ -517,6 +1089,7 @@ func (s *EnhancedShell) shutdown() error {
 
 	// Reset scrolling region
 	if s.terminalManager != nil {
+		s.terminalManager.StopWatchingResize()
 		s.terminalManager.ResetScrollingRegion()
 	}
 
@@ -530,8 +1103,23 @@ func (s *EnhancedShell) shutdown() error {
 		s.readline.Close()
 	}
 
-	// Stop job manager
-	s.Shell.jobManager.Stop()
+	// Persist the session for the next start
+	if s.sessionManager != nil {
+		if err := s.sessionManager.SaveSession(); err != nil {
+			log.Logger.Warnf("Failed to save shell session: %v", err)
+		}
+	}
+
+	// Stop job manager, letting running jobs finish or pause; read-only
+	// shells have none
+	if s.Shell.jobManager != nil {
+		s.Shell.jobManager.Shutdown(context.Background())
+	}
+
+	s.Shell.closeHistory()
+
+	// Drop the session's scratch tables before their databases close
+	command.CloseScratch(s.Shell.commands.GetSession())
 
 	// Close data sources
 	for name, ds := range s.Shell.dataSources {
@@ -542,6 +1130,8 @@ func (s *EnhancedShell) shutdown() error {
 		}
 	}
 
+	s.saveApplicationState(true)
+
 	fmt.Println("Goodbye!")
 	return nil
 }
@@ -558,12 +1148,67 @@ func (s *EnhancedShell) startJobEventConsumer() {
 	}
 }
 
+// diskSpaceItemID is the status bar item used for low disk space warnings
+const diskSpaceItemID = "disk_space"
+
+// quotaItemID returns the status bar item of a data source's storage quota
+// warning
+func quotaItemID(event jobs.JobEvent) string {
+	source, _ := event.Data["source_name"].(string)
+	return "quota_" + source
+}
+
+// deadLetterItemID returns the status bar item of a dead-letter job, kept
+// apart from the job's own item which is removed shortly after it fails
+func deadLetterItemID(jobID string) string {
+	return "dead_letter:" + jobID
+}
+
+// statusBarClick returns the command a click at the prompt runs: clicking
+// a job on the status line shows its details
+func (s *EnhancedShell) statusBarClick(event mouseEvent) string {
+	if event.button != mouseLeft || event.release || s.Shell.jobManager == nil {
+		return ""
+	}
+	id := strings.TrimPrefix(s.statusBar.ItemAt(event.y), deadLetterItemID(""))
+	if id == "" {
+		return ""
+	}
+	// Items such as source quotas are not jobs
+	if _, err := s.Shell.jobManager.GetJob(id); err != nil {
+		return ""
+	}
+	return "jobs status " + id
+}
+
+// exportOutput returns where an export job wrote its result, the uploaded
+// object's URL for uploads, or "" for other jobs
+func (s *EnhancedShell) exportOutput(jobID string) string {
+	if s.Shell.jobManager == nil {
+		return ""
+	}
+	status, err := s.Shell.jobManager.GetJob(jobID)
+	if err != nil || status.Type != jobs.JobTypeExport {
+		return ""
+	}
+	if url, ok := status.Metadata["object_url"].(string); ok && url != "" {
+		return url
+	}
+	output, _ := status.Metadata["output_file"].(string)
+	return output
+}
+
 // handleJobEvent processes job events for status bar display
 func (s *EnhancedShell) handleJobEvent(event jobs.JobEvent) {
 	// Debug: log all job events to see what's happening (remove in production)
 	// log.Logger.Infof("Status Bar: Received job event - Type: %s, JobID: %s, Message: %s",
 	//	event.EventType, event.JobID, event.Message)
 
+	// Jobs starting and stopping change the running job count of the prompt
+	if event.EventType != jobs.EventJobProgress {
+		s.redrawPrompt()
+	}
+
 	switch event.EventType {
 	case jobs.EventJobSubmitted, jobs.EventJobStarted:
 		// Create new status bar item for submitted/started job
@@ -577,19 +1222,34 @@ func (s *EnhancedShell) handleJobEvent(event jobs.JobEvent) {
 			current, _ := event.Data["current"].(int64)
 			total, _ := event.Data["total"].(int64)
 			s.statusBar.UpdateProgress(event.JobID, current, total, event.Message)
+
+			rate, _ := event.Data["rate"].(float64)
+			etaSeconds, _ := event.Data["eta_seconds"].(int64)
+			stalled, _ := event.Data["stalled"].(bool)
+			s.statusBar.UpdateRate(event.JobID, rate, time.Duration(etaSeconds)*time.Second, stalled)
 		}
 
+	case jobs.EventJobStalled:
+		reason, _ := event.Data["reason"].(string)
+		s.statusBar.SetWarning(event.JobID, reason)
+
 	case jobs.EventJobCompleted:
 		// Remove completed job after a brief display
 		go func() {
-			// Show completion status briefly
+			// Show completion status briefly; finished exports a little
+			// longer, naming the file written
 			item := CreateItemFromJobEvent(event)
 			item.Progress = 100
 			item.Status = "Completed"
+			shown := 3 * time.Second
+			if output := s.exportOutput(event.JobID); output != "" {
+				item.Type = string(jobs.JobTypeExport)
+				item.Description = "Exported to " + output
+				shown = 10 * time.Second
+			}
 			s.statusBar.AddItem(item)
 
-			// Remove after 3 seconds
-			time.Sleep(3 * time.Second)
+			time.Sleep(shown)
 			s.statusBar.RemoveItem(event.JobID)
 		}()
 
@@ -600,5 +1260,35 @@ func (s *EnhancedShell) handleJobEvent(event jobs.JobEvent) {
 			time.Sleep(5 * time.Second)
 			s.statusBar.RemoveItem(event.JobID)
 		}()
+
+	case jobs.EventJobDeadLettered:
+		// Keep persistent failures visible until the job is requeued
+		item := CreateItemFromJobEvent(event)
+		item.ID = deadLetterItemID(event.JobID)
+		s.statusBar.AddItem(item)
+		s.statusBar.SetError(item.ID, event.Message)
+
+	case jobs.EventJobRetrying:
+		s.statusBar.RemoveItem(deadLetterItemID(event.JobID))
+
+	case jobs.EventDiskSpaceLow:
+		// Keep a warning visible until space is recovered
+		item := CreateItemFromJobEvent(event)
+		item.ID = diskSpaceItemID
+		s.statusBar.AddItem(item)
+		s.statusBar.SetError(diskSpaceItemID, event.Message)
+
+	case jobs.EventDiskSpaceRecovered:
+		s.statusBar.RemoveItem(diskSpaceItemID)
+
+	case jobs.EventQuotaWarning, jobs.EventQuotaExceeded:
+		// Keep the warning visible until the source is back under its quota
+		item := CreateItemFromJobEvent(event)
+		item.ID = quotaItemID(event)
+		s.statusBar.AddItem(item)
+		s.statusBar.SetError(item.ID, event.Message)
+
+	case jobs.EventQuotaRecovered:
+		s.statusBar.RemoveItem(quotaItemID(event))
 	}
 }
@@ -0,0 +1,540 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"golang.org/x/term"
+)
+
+// topRefreshInterval is how often the jobs top view redraws
+const topRefreshInterval = time.Second
+
+// topKey is a decoded keypress in the jobs top view
+type topKey string
+
+const (
+	topKeyUp   topKey = "up"
+	topKeyDown topKey = "down"
+	topKeyQuit topKey = "quit"
+)
+
+// topSortColumn orders rows in the jobs top view
+type topSortColumn struct {
+	name string
+	less func(a, b *jobs.JobStatus) bool
+}
+
+// topSortColumns lists the sort orders cycled through with the 's' key
+var topSortColumns = []topSortColumn{
+	{"state", func(a, b *jobs.JobStatus) bool { return topStateRank(a.State) < topStateRank(b.State) }},
+	{"rate", func(a, b *jobs.JobStatus) bool { return a.Progress.Rate > b.Progress.Rate }},
+	{"eta", func(a, b *jobs.JobStatus) bool { return topETA(a) < topETA(b) }},
+	{"retries", func(a, b *jobs.JobStatus) bool { return a.RetryCount > b.RetryCount }},
+	{"errors", func(a, b *jobs.JobStatus) bool { return a.ErrorCount > b.ErrorCount }},
+	{"id", func(a, b *jobs.JobStatus) bool { return a.ID < b.ID }},
+}
+
+// JobsTop is a live, top-style view of running and queued jobs
+type JobsTop struct {
+	manager    *jobs.EnhancedJobManager
+	terminal   *TerminalManager
+	sortIndex  int
+	reverse    bool
+	selectedID string
+	detail     bool // Whether the selected job's detail view is shown
+	message    string
+	rows       []*jobs.JobStatus
+	keys       *Keymap
+}
+
+// NewJobsTop creates a jobs top view for the given manager
+func NewJobsTop(manager *jobs.EnhancedJobManager) *JobsTop {
+	jt := &JobsTop{
+		manager:  manager,
+		terminal: NewTerminalManager(),
+	}
+	keys, err := topKeymap()
+	if err != nil {
+		jt.message = fmt.Sprintf("%v; using the default keys", err)
+	}
+	jt.keys = keys
+	return jt
+}
+
+// Run shows the live view until the user quits. When stdin is not a
+// terminal, or once is set, a single snapshot is printed instead.
+func (jt *JobsTop) Run(once bool) error {
+	fd := int(os.Stdin.Fd())
+	if once || !term.IsTerminal(fd) {
+		jt.refresh()
+		size := jt.terminal.GetSize()
+		fmt.Print(strings.ReplaceAll(jt.render(size.Width, len(jt.rows)+4), "\r\n", "\n"))
+		return nil
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to enter raw mode: %w", err)
+	}
+	defer term.Restore(fd, oldState)
+
+	// Use the alternate screen so the shell's scrollback is left untouched
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+	defer enableMouse()()
+
+	keys := make(chan topKey)
+	go readTopKeys(keys)
+	resized := watchResize(jt.terminal)
+	defer jt.terminal.StopWatchingResize()
+
+	ticker := time.NewTicker(topRefreshInterval)
+	defer ticker.Stop()
+
+	jt.refresh()
+	jt.draw()
+	for {
+		select {
+		case <-ticker.C:
+		case <-resized:
+			// Redraw at the new size without waiting for the next tick
+			jt.draw()
+			continue
+		case key := <-keys:
+			if key == topKeyQuit {
+				return nil
+			}
+			jt.handleKey(key)
+		}
+		jt.refresh()
+		jt.draw()
+	}
+}
+
+// watchResize starts watching terminal resizes and returns a channel that
+// signals a full-screen view to redraw
+func watchResize(terminal *TerminalManager) <-chan struct{} {
+	resized := make(chan struct{}, 1)
+	terminal.OnResize(func(TerminalSize) {
+		select {
+		case resized <- struct{}{}:
+		default:
+		}
+	})
+	terminal.WatchResize()
+	return resized
+}
+
+// readTopKeys decodes keypresses and mouse reports from stdin until the
+// quit key is read, so no input is consumed after the view closes
+func readTopKeys(keys chan<- topKey) {
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			keys <- topKeyQuit
+			return
+		}
+
+		for _, input := range splitMouseReports(string(buf[:n])) {
+			var key topKey
+			switch input {
+			case "\033[A", "k":
+				key = topKeyUp
+			case "\033[B", "j":
+				key = topKeyDown
+			case "q", "Q", "\033", "\x03":
+				key = topKeyQuit
+			default:
+				key = topKey(input)
+			}
+
+			keys <- key
+			if key == topKeyQuit {
+				return
+			}
+		}
+	}
+}
+
+// handleKey applies a keypress or mouse report to the view
+func (jt *JobsTop) handleKey(key topKey) {
+	if event, ok := parseMouse(key); ok {
+		jt.handleMouse(event)
+		return
+	}
+
+	switch key {
+	case "\x7f", "\b":
+		jt.detail = false
+		return
+	case topKeyUp:
+		jt.moveSelection(-1)
+		return
+	case topKeyDown:
+		jt.moveSelection(1)
+		return
+	}
+
+	action, _ := jt.keys.Action(string(key))
+	switch action {
+	case "details":
+		jt.detail = !jt.detail && jt.selectedID != ""
+	case "sort":
+		jt.sortIndex = (jt.sortIndex + 1) % len(topSortColumns)
+		jt.message = fmt.Sprintf("Sorted by %s", topSortColumns[jt.sortIndex].name)
+	case "reverse":
+		jt.reverse = !jt.reverse
+		jt.message = "Reversed sort order"
+	case "pause":
+		jt.applyToSelected("paused", jt.manager.PauseJob)
+	case "resume":
+		jt.applyToSelected("resumed", jt.manager.ResumeJob)
+	case "cancel":
+		jt.applyToSelected("cancelled", jt.manager.CancelJob)
+	case "refresh":
+		// Every keypress reloads the jobs; this one only says so
+		jt.message = "Refreshed"
+	}
+}
+
+// keyHints lists the bound keys of the given actions for the footer
+func (jt *JobsTop) keyHints(actions ...string) string {
+	var hints []string
+	for _, action := range actions {
+		for _, binding := range jt.keys.Bindings() {
+			if binding.Action == action {
+				hints = append(hints, binding.Key+" "+action)
+			}
+		}
+	}
+	return strings.Join(hints, "  ")
+}
+
+// topHeaderColumns maps the column titles of the view to the sort orders
+// they select when clicked; end is the last screen column of the title
+var topHeaderColumns = []struct {
+	end  int
+	sort string
+}{
+	{24, "id"}, {36, ""}, {45, "state"}, {52, ""}, {62, "rate"},
+	{72, "eta"}, {80, "retries"}, {87, "errors"},
+}
+
+// handleMouse applies a click or wheel turn: clicking a job opens its
+// detail view, clicking a column title sorts by it and the wheel moves the
+// selection
+func (jt *JobsTop) handleMouse(event mouseEvent) {
+	switch {
+	case event.button == mouseWheelUp:
+		jt.moveSelection(-1)
+		return
+	case event.button == mouseWheelDown:
+		jt.moveSelection(1)
+		return
+	case event.button != mouseLeft || event.release:
+		return
+	}
+
+	if jt.detail {
+		jt.detail = false
+		return
+	}
+
+	// Line 1 is the header, line 2 the column titles, jobs start on line 3
+	switch {
+	case event.y == 2:
+		jt.sortByHeader(event.x)
+	case event.y >= 3 && event.y-3 < len(jt.rows):
+		jt.selectedID = jt.rows[event.y-3].ID
+		jt.detail = true
+	}
+}
+
+// sortByHeader sorts by the column whose title is at screen column x;
+// clicking the current sort column reverses the order
+func (jt *JobsTop) sortByHeader(x int) {
+	for _, column := range topHeaderColumns {
+		if x > column.end {
+			continue
+		}
+		if column.sort == "" {
+			return
+		}
+		for i, sortColumn := range topSortColumns {
+			if sortColumn.name != column.sort {
+				continue
+			}
+			if i == jt.sortIndex {
+				jt.reverse = !jt.reverse
+			} else {
+				jt.sortIndex, jt.reverse = i, false
+			}
+			jt.message = fmt.Sprintf("Sorted by %s", sortColumn.name)
+			return
+		}
+	}
+}
+
+// applyToSelected runs a job control action on the selected job
+func (jt *JobsTop) applyToSelected(verb string, action func(id string) error) {
+	if jt.selectedID == "" {
+		jt.message = "No job selected"
+		return
+	}
+	if err := action(jt.selectedID); err != nil {
+		jt.message = err.Error()
+		return
+	}
+	jt.message = fmt.Sprintf("Job %s %s", jt.selectedID, verb)
+}
+
+// moveSelection moves the selected row by delta
+func (jt *JobsTop) moveSelection(delta int) {
+	if len(jt.rows) == 0 {
+		return
+	}
+	index := jt.selectedIndex() + delta
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(jt.rows) {
+		index = len(jt.rows) - 1
+	}
+	jt.selectedID = jt.rows[index].ID
+}
+
+// selectedIndex returns the row of the selected job, or 0 if it is gone
+func (jt *JobsTop) selectedIndex() int {
+	for i, row := range jt.rows {
+		if row.ID == jt.selectedID {
+			return i
+		}
+	}
+	return 0
+}
+
+// refresh reloads active jobs, preferring the manager's in-memory status
+// which carries live rate and stall information
+func (jt *JobsTop) refresh() {
+	listed, err := jt.manager.ListJobs(jobs.JobFilter{
+		States: []jobs.JobState{jobs.JobStateRunning, jobs.JobStateQueued, jobs.JobStatePaused},
+	})
+	if err != nil {
+		jt.message = fmt.Sprintf("Failed to list jobs: %v", err)
+		return
+	}
+
+	rows := make([]*jobs.JobStatus, 0, len(listed))
+	for _, status := range listed {
+		if live, err := jt.manager.GetJob(status.ID); err == nil {
+			status = live
+		}
+		rows = append(rows, status)
+	}
+
+	column := topSortColumns[jt.sortIndex]
+	sort.SliceStable(rows, func(i, j int) bool {
+		if jt.reverse {
+			return column.less(rows[j], rows[i])
+		}
+		return column.less(rows[i], rows[j])
+	})
+	jt.rows = rows
+
+	if len(rows) > 0 {
+		jt.selectedID = rows[jt.selectedIndex()].ID
+	} else {
+		jt.selectedID = ""
+	}
+}
+
+// draw renders the view to the terminal
+func (jt *JobsTop) draw() {
+	size := jt.terminal.GetSize()
+	fmt.Print("\033[H\033[2J" + jt.render(size.Width, size.Height))
+}
+
+// render formats the view for a terminal of the given size
+func (jt *JobsTop) render(width, height int) string {
+	if jt.detail {
+		if status := jt.selectedStatus(); status != nil {
+			return jt.renderDetail(status, width)
+		}
+		jt.detail = false
+	}
+
+	var b strings.Builder
+
+	counts := make(map[jobs.JobState]int)
+	for _, row := range jt.rows {
+		counts[row.State]++
+	}
+	order := ""
+	if jt.reverse {
+		order = " (reversed)"
+	}
+	header := fmt.Sprintf("jobs top - %s - %d running, %d queued, %d paused - sort: %s%s",
+		time.Now().Format("15:04:05"), counts[jobs.JobStateRunning], counts[jobs.JobStateQueued],
+		counts[jobs.JobStatePaused], topSortColumns[jt.sortIndex].name, order)
+	b.WriteString(Bold + fitWidth(header, width) + Reset + "\r\n")
+
+	columns := fmt.Sprintf("%-24s %-11s %-8s %6s %9s %9s %7s %6s  %s",
+		"ID", "TYPE", "STATE", "PROG%", "RATE/s", "ETA", "RETRIES", "ERRORS", "MESSAGE")
+	b.WriteString(Bold + fitWidth(columns, width) + Reset + "\r\n")
+
+	// Header, column titles and footer take three lines
+	visible := height - 3
+	if visible < 1 {
+		visible = 1
+	}
+	for i, row := range jt.rows {
+		if i >= visible {
+			break
+		}
+		line := fitWidth(formatTopRow(row), width)
+		switch {
+		case row.ID == jt.selectedID:
+			line = "\033[7m" + line + Reset
+		case row.Progress.Stalled:
+			line = FgYellow + line + Reset
+		}
+		b.WriteString(line + "\r\n")
+	}
+	if len(jt.rows) == 0 {
+		b.WriteString("No running or queued jobs\r\n")
+	}
+
+	footer := "↑/↓ select  " + jt.keyHints(topActions...) + "  q quit"
+	if jt.message != "" {
+		footer = jt.message + "  |  " + footer
+	}
+	b.WriteString(fitWidth(footer, width) + "\r\n")
+	return b.String()
+}
+
+// selectedStatus returns the selected job, or nil if there is none
+func (jt *JobsTop) selectedStatus() *jobs.JobStatus {
+	for _, row := range jt.rows {
+		if row.ID == jt.selectedID {
+			return row
+		}
+	}
+	return nil
+}
+
+// renderDetail formats the detail view of a job
+func (jt *JobsTop) renderDetail(status *jobs.JobStatus, width int) string {
+	var b strings.Builder
+	b.WriteString(Bold + fitWidth("Job "+status.ID, width) + Reset + "\r\n\r\n")
+
+	field := func(name string, value interface{}) {
+		b.WriteString(fitWidth(fmt.Sprintf("%-14s %v", name+":", value), width) + "\r\n")
+	}
+	field("Type", status.Type)
+	field("State", status.State)
+	field("Priority", status.Priority)
+	field("Description", status.Description)
+	if !status.StartTime.IsZero() {
+		field("Started", status.StartTime.Format("2006-01-02 15:04:05"))
+	}
+	field("Progress", fmt.Sprintf("%.1f%% (%d/%d)", status.Progress.Percentage(), status.Progress.Current, status.Progress.Total))
+	if status.Progress.Rate > 0 {
+		field("Rate", formatRate(status.Progress.Rate)+"/s")
+	}
+	if status.Progress.Stalled {
+		field("ETA", "stalled")
+	} else if status.Progress.ETA != nil {
+		field("ETA", status.Progress.ETA.Round(time.Second))
+	}
+	field("Retries", fmt.Sprintf("%d/%d", status.RetryCount, status.MaxRetries))
+	field("Errors", status.ErrorCount)
+	if status.ErrorMessage != "" {
+		field("Last error", status.ErrorMessage)
+	}
+	if status.WaitReason != "" {
+		field("Waiting", status.WaitReason)
+	}
+	field("Message", status.Progress.Message)
+
+	if len(status.Metadata) > 0 {
+		keys := make([]string, 0, len(status.Metadata))
+		for key := range status.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteString("\r\n" + Bold + "Metadata" + Reset + "\r\n")
+		for _, key := range keys {
+			field("  "+key, status.Metadata[key])
+		}
+	}
+
+	footer := "⌫/click back  " + jt.keyHints("details", "pause", "resume", "cancel") + "  q quit"
+	if jt.message != "" {
+		footer = jt.message + "  |  " + footer
+	}
+	b.WriteString("\r\n" + fitWidth(footer, width) + "\r\n")
+	return b.String()
+}
+
+// formatTopRow formats a job as a row of the jobs top view
+func formatTopRow(status *jobs.JobStatus) string {
+	rate := "-"
+	if status.Progress.Rate > 0 {
+		rate = formatRate(status.Progress.Rate)
+	}
+	eta := "-"
+	if status.Progress.Stalled {
+		eta = "stalled"
+	} else if status.Progress.ETA != nil && status.State == jobs.JobStateRunning {
+		eta = status.Progress.ETA.Round(time.Second).String()
+	}
+
+	return fmt.Sprintf("%-24s %-11s %-8s %6.1f %9s %9s %7d %6d  %s",
+		fitWidth(status.ID, 24),
+		fitWidth(string(status.Type), 11),
+		status.State,
+		status.Progress.Percentage(),
+		rate,
+		eta,
+		status.RetryCount,
+		status.ErrorCount,
+		status.Progress.Message)
+}
+
+// topStateRank orders running jobs before paused and queued ones
+func topStateRank(state jobs.JobState) int {
+	switch state {
+	case jobs.JobStateRunning:
+		return 0
+	case jobs.JobStatePaused:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// topETA returns a job's ETA for sorting, placing unknown ETAs last
+func topETA(status *jobs.JobStatus) time.Duration {
+	if status.Progress.ETA == nil || status.Progress.Stalled {
+		return time.Duration(1<<63 - 1)
+	}
+	return *status.Progress.ETA
+}
+
+// fitWidth truncates s to at most width runes
+func fitWidth(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return s
+	}
+	if width <= 3 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-3]) + "..."
+}
@@ -0,0 +1,260 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/brainless/PubDataHub/internal/config"
+)
+
+// defaultShellKeys binds keys at the shell prompt to the command they run
+var defaultShellKeys = map[string]string{
+	"ctrl+j": "jobs top",
+	"ctrl+e": ".edit",
+	"f5":     "status",
+}
+
+// defaultTopKeys binds keys in jobs top to the actions in topActions
+var defaultTopKeys = map[string]string{
+	"enter": "details",
+	"s":     "sort",
+	"r":     "reverse",
+	"p":     "pause",
+	"u":     "resume",
+	"c":     "cancel",
+	"f5":    "refresh",
+}
+
+// topActions are the actions keys can be bound to in jobs top
+var topActions = []string{"details", "sort", "reverse", "pause", "resume", "cancel", "refresh"}
+
+// Keys that keep their meaning and cannot be bound
+var (
+	reservedShellKeys = []string{"enter", "tab", "backspace", "ctrl+c", "ctrl+d", "ctrl+h", "ctrl+i", "ctrl+m"}
+	reservedTopKeys   = []string{"q", "esc", "ctrl+c", "j", "k", "backspace", "ctrl+h"}
+)
+
+// functionKeys are the sequences terminals send for F1 to F12; F1 to F4
+// differ between xterm and rxvt style terminals
+var functionKeys = map[string][]string{
+	"f1": {"\033OP", "\033[11~"}, "f2": {"\033OQ", "\033[12~"},
+	"f3": {"\033OR", "\033[13~"}, "f4": {"\033OS", "\033[14~"},
+	"f5": {"\033[15~"}, "f6": {"\033[17~"}, "f7": {"\033[18~"}, "f8": {"\033[19~"},
+	"f9": {"\033[20~"}, "f10": {"\033[21~"}, "f11": {"\033[23~"}, "f12": {"\033[24~"},
+}
+
+// KeyBinding is a key and the action or command bound to it
+type KeyBinding struct {
+	Key    string
+	Action string
+}
+
+// Keymap maps keys to actions. Keys are named like "ctrl+j", "alt+x",
+// "f5", "enter" or a single character.
+type Keymap struct {
+	bindings map[string]string // Key name to action
+	inputs   map[string]string // Input sequence to action
+}
+
+// NewKeymap binds the default keys with overrides applied; an override
+// bound to "" removes the key. Reserved keys cannot be bound and, when
+// actions is set, only those actions are accepted.
+func NewKeymap(defaults, overrides map[string]string, reserved, actions []string) (*Keymap, error) {
+	km := &Keymap{
+		bindings: make(map[string]string),
+		inputs:   make(map[string]string),
+	}
+
+	merged := make(map[string]string, len(defaults)+len(overrides))
+	for key, action := range defaults {
+		merged[key] = action
+	}
+	for key, action := range overrides {
+		merged[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(action)
+	}
+
+	for key, action := range merged {
+		if action == "" {
+			continue
+		}
+		sequences, err := keySequences(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range reserved {
+			if key == r {
+				return nil, fmt.Errorf("key %s cannot be rebound", key)
+			}
+		}
+		if actions != nil && !containsString(actions, action) {
+			return nil, fmt.Errorf("unknown action %q for key %s (valid: %s)", action, key, strings.Join(actions, ", "))
+		}
+
+		km.bindings[key] = action
+		for _, sequence := range sequences {
+			km.inputs[sequence] = action
+		}
+	}
+	return km, nil
+}
+
+// Action returns the action bound to the input read for one keypress
+func (km *Keymap) Action(input string) (string, bool) {
+	action, ok := km.inputs[input]
+	return action, ok
+}
+
+// Bindings returns the bound keys sorted by name
+func (km *Keymap) Bindings() []KeyBinding {
+	bindings := make([]KeyBinding, 0, len(km.bindings))
+	for key, action := range km.bindings {
+		bindings = append(bindings, KeyBinding{Key: key, Action: action})
+	}
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i].Key < bindings[j].Key })
+	return bindings
+}
+
+// keySequences returns the input sequences terminals send for a key name
+func keySequences(name string) ([]string, error) {
+	switch name {
+	case "enter":
+		return []string{"\r", "\n"}, nil
+	case "tab":
+		return []string{"\t"}, nil
+	case "backspace":
+		return []string{"\x7f", "\b"}, nil
+	case "esc":
+		return []string{"\033"}, nil
+	case "space":
+		return []string{" "}, nil
+	}
+	if sequences, ok := functionKeys[name]; ok {
+		return sequences, nil
+	}
+
+	switch {
+	case strings.HasPrefix(name, "ctrl+") && len(name) == len("ctrl+")+1:
+		c := name[len(name)-1]
+		if c < 'a' || c > 'z' {
+			break
+		}
+		return []string{string(rune(c - 'a' + 1))}, nil
+	case strings.HasPrefix(name, "alt+") && len([]rune(name)) == len("alt+")+1:
+		return []string{"\033" + name[len("alt+"):]}, nil
+	case len([]rune(name)) == 1 && name > " ":
+		return []string{name}, nil
+	}
+	return nil, fmt.Errorf("unknown key %q (use ctrl+<letter>, alt+<key>, f1-f12, enter, tab, esc, space or a character)", name)
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// shellKeymap returns the key bindings of the shell prompt from the
+// configuration, falling back to the defaults when they are invalid
+func shellKeymap() (*Keymap, error) {
+	km, err := NewKeymap(defaultShellKeys, config.AppConfig.UI.Keys.Shell, reservedShellKeys, nil)
+	if err != nil {
+		km, _ = NewKeymap(defaultShellKeys, nil, reservedShellKeys, nil)
+		return km, fmt.Errorf("invalid ui.keys.shell: %w", err)
+	}
+	return km, nil
+}
+
+// topKeymap returns the key bindings of jobs top from the configuration,
+// falling back to the defaults when they are invalid
+func topKeymap() (*Keymap, error) {
+	km, err := NewKeymap(defaultTopKeys, config.AppConfig.UI.Keys.Top, reservedTopKeys, topActions)
+	if err != nil {
+		km, _ = NewKeymap(defaultTopKeys, nil, reservedTopKeys, topActions)
+		return km, fmt.Errorf("invalid ui.keys.top: %w", err)
+	}
+	return km, nil
+}
+
+// keyReader reads the shell's input, turning a keypress bound in keymap
+// into Enter so the prompt returns, with the bound command left for the
+// shell to run. Only whole reads match, so pasted text is passed through.
+// Mouse reports never reach the prompt: clicks that mouse turns into a
+// command end it the same way, the others are dropped.
+type keyReader struct {
+	in      io.Reader
+	keymap  *Keymap
+	mouse   func(event mouseEvent) string
+	buf     []byte
+	pending []byte
+
+	mu      sync.Mutex
+	command string
+}
+
+// newKeyReader creates a reader applying keymap to input read from in
+func newKeyReader(in io.Reader, keymap *Keymap) *keyReader {
+	return &keyReader{in: in, keymap: keymap}
+}
+
+// Read implements io.Reader
+func (kr *keyReader) Read(p []byte) (int, error) {
+	for len(kr.pending) == 0 {
+		if len(kr.buf) < len(p) {
+			kr.buf = make([]byte, len(p))
+		}
+		n, err := kr.in.Read(kr.buf[:len(p)])
+		if n == 0 {
+			return 0, err
+		}
+		kr.pending = kr.buf[:n]
+		command, ok := kr.keymap.Action(string(kr.pending))
+		if strings.HasPrefix(string(kr.pending), "\033[<") {
+			command, ok = kr.mouseCommand(string(kr.pending))
+			kr.pending = nil
+		}
+		if ok {
+			kr.mu.Lock()
+			kr.command = command
+			kr.mu.Unlock()
+			kr.pending = []byte("\r")
+		}
+	}
+
+	n := copy(p, kr.pending)
+	kr.pending = kr.pending[n:]
+	return n, nil
+}
+
+// mouseCommand returns the command of the last click in input holding
+// mouse reports, if any
+func (kr *keyReader) mouseCommand(input string) (string, bool) {
+	if kr.mouse == nil {
+		return "", false
+	}
+	var command string
+	for _, report := range splitMouseReports(input) {
+		if event, ok := parseMouse(topKey(report)); ok {
+			if clicked := kr.mouse(event); clicked != "" {
+				command = clicked
+			}
+		}
+	}
+	return command, command != ""
+}
+
+// takeCommand returns the command of the last bound keypress, if any, and
+// clears it
+func (kr *keyReader) takeCommand() string {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	command := kr.command
+	kr.command = ""
+	return command
+}
@@ -0,0 +1,52 @@
+package tui
+
+import (
+	"fmt"
+)
+
+// KeysCommand lists the key bindings of the shell and jobs top
+type KeysCommand struct {
+	BaseCommand
+	shell *Keymap
+}
+
+// NewKeysCommand creates a new keys command for the shell's bindings
+func NewKeysCommand(shell *Keymap) *KeysCommand {
+	return &KeysCommand{
+		BaseCommand: BaseCommand{
+			Name:        "keys",
+			Description: "List the key bindings",
+			Usage:       "keys",
+		},
+		shell: shell,
+	}
+}
+
+// Execute prints the bindings with the commands and actions they run
+func (kc *KeysCommand) Execute(ctx *ShellContext) error {
+	fmt.Println("Shell prompt:")
+	printBindings(kc.shell)
+
+	top, err := topKeymap()
+	fmt.Println()
+	fmt.Println("jobs top:")
+	printBindings(top)
+	if err != nil {
+		fmt.Printf("Warning: %v; using the defaults\n", err)
+	}
+
+	fmt.Println()
+	fmt.Println("Change them with ui.keys.shell and ui.keys.top in the config file; bind a key to \"\" to remove it")
+	return nil
+}
+
+// printBindings prints the keys of a keymap and what they are bound to
+func printBindings(km *Keymap) {
+	bindings := km.Bindings()
+	if len(bindings) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, binding := range bindings {
+		fmt.Printf("  %-10s %s\n", binding.Key, binding.Action)
+	}
+}
@@ -0,0 +1,93 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"golang.org/x/term"
+)
+
+// Mouse buttons reported by the terminal, after modifier bits are masked
+const (
+	mouseLeft      = 0
+	mouseWheelUp   = 64
+	mouseWheelDown = 65
+)
+
+// mouseEvent is a decoded SGR mouse report; x and y are 1-based screen cells
+type mouseEvent struct {
+	button  int
+	x, y    int
+	release bool
+}
+
+// enableMouse turns on click and wheel reporting in SGR encoding when the
+// ui.mouse setting allows it and the shell runs in a terminal, and returns
+// a function turning it off again. Terminals without mouse support ignore
+// the sequences, leaving the views keyboard-only.
+func enableMouse() func() {
+	if !config.AppConfig.UI.Mouse || !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return func() {}
+	}
+	fmt.Print("\033[?1000h\033[?1006h")
+	return func() { fmt.Print("\033[?1006l\033[?1000l") }
+}
+
+// parseMouse decodes a key read by readTopKeys as a mouse report of the
+// form ESC [ < button ; x ; y followed by M (press) or m (release)
+func parseMouse(key topKey) (mouseEvent, bool) {
+	s := string(key)
+	if !strings.HasPrefix(s, "\033[<") || len(s) < 4 {
+		return mouseEvent{}, false
+	}
+	final := s[len(s)-1]
+	if final != 'M' && final != 'm' {
+		return mouseEvent{}, false
+	}
+
+	fields := strings.Split(s[3:len(s)-1], ";")
+	if len(fields) != 3 {
+		return mouseEvent{}, false
+	}
+	values := make([]int, 3)
+	for i, field := range fields {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return mouseEvent{}, false
+		}
+		values[i] = value
+	}
+
+	// Motion reports are not requested; drop them if a terminal sends them
+	if values[0]&32 != 0 {
+		return mouseEvent{}, false
+	}
+	return mouseEvent{
+		button:  values[0] &^ (4 | 8 | 16), // shift, meta and control
+		x:       values[1],
+		y:       values[2],
+		release: final == 'm',
+	}, true
+}
+
+// splitMouseReports splits input holding several mouse reports, as read
+// when the wheel is turned quickly, into one key per report
+func splitMouseReports(input string) []string {
+	if !strings.HasPrefix(input, "\033[<") {
+		return []string{input}
+	}
+	var reports []string
+	for input != "" {
+		next := strings.Index(input[1:], "\033[<")
+		if next < 0 {
+			reports = append(reports, input)
+			break
+		}
+		reports = append(reports, input[:next+1])
+		input = input[next+1:]
+	}
+	return reports
+}
@@ -0,0 +1,324 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/audit"
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/query"
+)
+
+// NotebookCommand manages notebooks of annotated queries in the current workspace
+type NotebookCommand struct {
+	BaseCommand
+	workspaceManager *WorkspaceManager
+}
+
+// NewNotebookCommand creates a new notebook command
+func NewNotebookCommand(workspaceManager *WorkspaceManager) *NotebookCommand {
+	return &NotebookCommand{
+		BaseCommand: BaseCommand{
+			Name:        "notebook",
+			Description: "Save annotated multi-query analyses in the current workspace",
+			Usage:       "notebook <create|list|add|note|remove|show|run|export|delete> <name> [args...]",
+		},
+		workspaceManager: workspaceManager,
+	}
+}
+
+// Execute runs a notebook subcommand
+func (nc *NotebookCommand) Execute(ctx *ShellContext) error {
+	if len(ctx.Args) < 2 {
+		return nc.showUsage()
+	}
+	args := ctx.Args[2:]
+
+	switch ctx.Args[1] {
+	case "create":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: notebook create <name> [description]")
+		}
+		if err := nc.workspaceManager.CreateNotebook(args[0], strings.Join(args[1:], " ")); err != nil {
+			return err
+		}
+		fmt.Printf("Created notebook '%s'\n", args[0])
+		return nil
+	case "list", "ls":
+		return nc.list()
+	case "add":
+		return nc.add(ctx, args)
+	case "note":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: notebook note <name> \"<markdown>\"")
+		}
+		return nc.addCell(args[0], query.NotebookCell{Note: strings.Join(args[1:], " ")})
+	case "remove", "rm":
+		return nc.remove(args)
+	case "show":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: notebook show <name>")
+		}
+		notebook, err := nc.workspaceManager.GetNotebook(args[0])
+		if err != nil {
+			return err
+		}
+		return nc.print(notebook)
+	case "run":
+		return nc.run(ctx, args)
+	case "export":
+		return nc.export(args)
+	case "delete":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: notebook delete <name>")
+		}
+		if err := nc.workspaceManager.DeleteNotebook(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted notebook '%s'\n", args[0])
+		return nil
+	default:
+		return fmt.Errorf("unknown notebook subcommand: %s", ctx.Args[1])
+	}
+}
+
+// list prints the notebooks of the current workspace
+func (nc *NotebookCommand) list() error {
+	notebooks, err := nc.workspaceManager.ListNotebooks()
+	if err != nil {
+		return err
+	}
+	if len(notebooks) == 0 {
+		fmt.Println("No notebooks in current workspace")
+		return nil
+	}
+
+	fmt.Printf("%-20s %-6s %-17s %s\n", "NAME", "CELLS", "LAST RUN", "DESCRIPTION")
+	fmt.Println(strings.Repeat("-", 70))
+	for _, notebook := range notebooks {
+		lastRun := "never"
+		if notebook.LastRun != nil {
+			lastRun = notebook.LastRun.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-20s %-6d %-17s %s\n", notebook.Name, len(notebook.Cells), lastRun, notebook.Description)
+	}
+	return nil
+}
+
+// add appends a query cell, annotated when --note is given
+func (nc *NotebookCommand) add(ctx *ShellContext, args []string) error {
+	note := ""
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--note" {
+			if i+1 >= len(args) {
+				return fmt.Errorf("--note requires a value")
+			}
+			note = args[i+1]
+			i++
+			continue
+		}
+		positional = append(positional, args[i])
+	}
+	if len(positional) < 3 {
+		return fmt.Errorf("usage: notebook add <name> <source> \"<sql>\" [--note \"<markdown>\"]")
+	}
+
+	source := positional[1]
+	if _, exists := ctx.DataSources[source]; !exists {
+		return fmt.Errorf("unknown data source: %s", source)
+	}
+	return nc.addCell(positional[0], query.NotebookCell{
+		Note:   note,
+		Source: source,
+		Query:  strings.Join(positional[2:], " "),
+	})
+}
+
+// addCell appends a cell to a notebook and saves it
+func (nc *NotebookCommand) addCell(name string, cell query.NotebookCell) error {
+	notebook, err := nc.workspaceManager.GetNotebook(name)
+	if err != nil {
+		return err
+	}
+	if err := notebook.AddCell(cell); err != nil {
+		return err
+	}
+	if err := nc.workspaceManager.SaveNotebook(notebook); err != nil {
+		return err
+	}
+	fmt.Printf("Added cell %d to notebook '%s'\n", len(notebook.Cells), name)
+	return nil
+}
+
+// remove deletes a cell by its position
+func (nc *NotebookCommand) remove(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: notebook remove <name> <cell>")
+	}
+	position, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("cell must be a number, got %q", args[1])
+	}
+
+	notebook, err := nc.workspaceManager.GetNotebook(args[0])
+	if err != nil {
+		return err
+	}
+	if err := notebook.RemoveCell(position); err != nil {
+		return err
+	}
+	if err := nc.workspaceManager.SaveNotebook(notebook); err != nil {
+		return err
+	}
+	fmt.Printf("Removed cell %d from notebook '%s'\n", position, args[0])
+	return nil
+}
+
+// run runs every query cell in order and saves the captured results
+func (nc *NotebookCommand) run(ctx *ShellContext, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: notebook run <name>")
+	}
+	notebook, err := nc.workspaceManager.GetNotebook(args[0])
+	if err != nil {
+		return err
+	}
+
+	runQuery := func(source, sql string) (datasource.QueryResult, error) {
+		ds, ok := ctx.DataSources[source].(datasource.DataSource)
+		if !ok {
+			return datasource.QueryResult{}, fmt.Errorf("unknown data source: %s", source)
+		}
+		return audit.Query(audit.Local(audit.InterfaceShell), source, sql, ds.Query)
+	}
+	failed, err := query.RunNotebook(ctx.Context, notebook, runQuery, func(position int, cell query.NotebookCell) {
+		if cell.Output.Error != "" {
+			fmt.Printf("[%d/%d] %s: error: %s\n", position, len(notebook.Cells), cell.Source, cell.Output.Error)
+			return
+		}
+		fmt.Printf("[%d/%d] %s: %d rows in %s\n", position, len(notebook.Cells), cell.Source,
+			cell.Output.Count, cell.Output.Duration.Round(time.Millisecond))
+	})
+	if err != nil {
+		return err
+	}
+	if err := nc.workspaceManager.SaveNotebook(notebook); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d cells of notebook '%s' failed", failed, notebook.Name)
+	}
+	fmt.Printf("Ran notebook '%s'; view it with: notebook show %s\n", notebook.Name, notebook.Name)
+	return nil
+}
+
+// export writes a notebook with its results as Markdown or HTML
+func (nc *NotebookCommand) export(args []string) error {
+	format := ""
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--format" {
+			if i+1 >= len(args) {
+				return fmt.Errorf("--format requires a value")
+			}
+			format = args[i+1]
+			i++
+			continue
+		}
+		positional = append(positional, args[i])
+	}
+	if len(positional) < 2 {
+		return fmt.Errorf("usage: notebook export <name> <file.md|file.html> [--format markdown|html]")
+	}
+
+	notebook, err := nc.workspaceManager.GetNotebook(positional[0])
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		format = query.NotebookFormatForPath(positional[1])
+	}
+	rendered, err := query.RenderNotebook(notebook, format)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(positional[1], []byte(rendered), 0644); err != nil {
+		return fmt.Errorf("failed to write notebook: %w", err)
+	}
+
+	fmt.Printf("Exported notebook '%s' to %s\n", notebook.Name, positional[1])
+	return nil
+}
+
+// print shows a notebook with its results as Markdown
+func (nc *NotebookCommand) print(notebook *query.Notebook) error {
+	rendered, err := query.RenderNotebook(notebook, query.NotebookFormatMarkdown)
+	if err != nil {
+		return err
+	}
+	fmt.Print(rendered)
+	return nil
+}
+
+// GetCompletions completes notebook subcommands and notebook names
+func (nc *NotebookCommand) GetCompletions(partial string, args []string) []string {
+	var options []string
+	switch len(args) {
+	case 0:
+		options = []string{"create", "list", "add", "note", "remove", "show", "run", "export", "delete"}
+	case 1:
+		if args[0] != "create" {
+			notebooks, _ := nc.workspaceManager.ListNotebooks()
+			for _, notebook := range notebooks {
+				options = append(options, notebook.Name)
+			}
+		}
+	case 2:
+		if args[0] == "add" {
+			options = []string{"hackernews"}
+		}
+	default:
+		switch args[0] {
+		case "add":
+			options = []string{"--note"}
+		case "export":
+			options = []string{"--format"}
+		}
+	}
+
+	var completions []string
+	for _, option := range options {
+		if strings.HasPrefix(option, partial) {
+			completions = append(completions, option)
+		}
+	}
+	return completions
+}
+
+// showUsage displays command usage information
+func (nc *NotebookCommand) showUsage() error {
+	fmt.Println("Notebook Command Usage:")
+	fmt.Println("  notebook create <name> [description]           - Create a notebook in the current workspace")
+	fmt.Println("  notebook list                                  - List notebooks")
+	fmt.Println("  notebook add <name> <source> \"<sql>\"           - Append a query cell")
+	fmt.Println("      [--note \"<markdown>\"]")
+	fmt.Println("  notebook note <name> \"<markdown>\"              - Append an annotation")
+	fmt.Println("  notebook remove <name> <cell>                  - Remove a cell by number")
+	fmt.Println("  notebook show <name>                           - Show cells and their last results")
+	fmt.Println("  notebook run <name>                            - Run all cells in order and keep the results")
+	fmt.Println("  notebook export <name> <file.md|file.html>     - Export with result tables")
+	fmt.Println("  notebook delete <name>                         - Delete a notebook")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  notebook create analysis1 'Who posts on weekends?'")
+	fmt.Println("  notebook add analysis1 hackernews \"SELECT by, COUNT(*) FROM items GROUP BY by ORDER BY 2 DESC LIMIT 10\" --note '## Top posters'")
+	fmt.Println("  notebook run analysis1")
+	fmt.Println("  notebook export analysis1 analysis1.html")
+
+	return nil
+}
@@ -3,7 +3,6 @@ package tui
 import (
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -33,87 +32,9 @@ func (spd *SimpleProgressDisplay) SetTerminalHeight(height int) {
 	spd.termHeight = height
 }
 
-// StartDownloadWithProgress starts a download and shows progress
-func (spd *SimpleProgressDisplay) StartDownloadWithProgress(sourceName string, args []string) error {
-	// Parse download configuration
-	config := spd.parseDownloadConfig(args)
-
-	// Check if data source exists
-	ds, exists := spd.dataSources[sourceName]
-	if !exists {
-		return fmt.Errorf("unknown data source: %s", sourceName)
-	}
-
-	// Create and start a download job using existing job manager
-	jobID, err := spd.jobManager.SubmitJob(jobs.NewDownloadJob(
-		fmt.Sprintf("download-%s-%d", sourceName, time.Now().Unix()),
-		sourceName,
-		ds,
-		config.BatchSize,
-	))
-	if err != nil {
-		return fmt.Errorf("failed to start download job: %w", err)
-	}
-
-	// Start the job
-	if err := spd.jobManager.StartJob(jobID); err != nil {
-		return fmt.Errorf("failed to start job: %w", err)
-	}
-
-	fmt.Printf("Started download job %s for %s\n", jobID, sourceName)
-
-	// Show progress monitoring
+// Follow shows the progress of a started download job until it finishes
+func (spd *SimpleProgressDisplay) Follow(jobID, sourceName string) {
 	go spd.monitorProgress(jobID, sourceName)
-
-	return nil
-}
-
-// DownloadConfig holds configuration for a download operation
-type DownloadConfig struct {
-	BatchSize  int
-	Priority   int
-	Resume     bool
-	MaxRetries int
-	Timeout    int
-	RateLimit  int
-}
-
-// parseDownloadConfig parses download configuration from command arguments
-func (spd *SimpleProgressDisplay) parseDownloadConfig(args []string) DownloadConfig {
-	config := DownloadConfig{
-		BatchSize:  100,
-		Priority:   5,
-		Resume:     true,
-		MaxRetries: 3,
-		Timeout:    300,
-		RateLimit:  0,
-	}
-
-	for _, arg := range args {
-		switch {
-		case strings.HasPrefix(arg, "--batch-size="):
-			if size, err := strconv.Atoi(strings.TrimPrefix(arg, "--batch-size=")); err == nil {
-				config.BatchSize = size
-			}
-		case strings.HasPrefix(arg, "--priority="):
-			if priority, err := strconv.Atoi(strings.TrimPrefix(arg, "--priority=")); err == nil {
-				config.Priority = priority
-			}
-		case arg == "--resume":
-			config.Resume = true
-		case strings.HasPrefix(arg, "--max-retries="):
-			if retries, err := strconv.Atoi(strings.TrimPrefix(arg, "--max-retries=")); err == nil {
-				config.MaxRetries = retries
-			}
-		default:
-			// Try to parse as batch size if it's a number
-			if size, err := strconv.Atoi(arg); err == nil {
-				config.BatchSize = size
-			}
-		}
-	}
-
-	return config
 }
 
 // monitorProgress monitors and displays progress for a download job
@@ -146,14 +67,14 @@ func (spd *SimpleProgressDisplay) monitorProgress(jobID, sourceName string) {
 			// Get data source status for progress information
 			if ds, exists := spd.dataSources[sourceName]; exists {
 				status := ds.GetDownloadStatus()
-				spd.displayProgress(jobID, status)
+				spd.displayProgress(jobID, status, jobStatus.Progress)
 			}
 		}
 	}
 }
 
 // displayProgress displays the current progress
-func (spd *SimpleProgressDisplay) displayProgress(jobID string, status datasource.DownloadStatus) {
+func (spd *SimpleProgressDisplay) displayProgress(jobID string, status datasource.DownloadStatus, jobProgress jobs.JobProgress) {
 	if !status.IsActive || spd.disabled {
 		return
 	}
@@ -169,9 +90,20 @@ func (spd *SimpleProgressDisplay) displayProgress(jobID string, status datasourc
 
 	bar := strings.Repeat("█", filledWidth) + strings.Repeat("░", barWidth-filledWidth)
 
+	// Rate and ETA come from the job manager's moving average
+	suffix := ""
+	if jobProgress.Stalled {
+		suffix = " (stalled)"
+	} else if jobProgress.Rate > 0 {
+		suffix = fmt.Sprintf(" %s/s", formatRate(jobProgress.Rate))
+		if jobProgress.ETA != nil {
+			suffix += fmt.Sprintf(" ETA %s", jobProgress.ETA.String())
+		}
+	}
+
 	// Save cursor position, move to bottom, print, then restore cursor
-	fmt.Printf("\033[s\033[%d;0H\r%s: [%s] %.1f%% (%d/%d)\033[u",
-		spd.termHeight, jobID, bar, progress, status.ItemsCached, status.ItemsTotal)
+	fmt.Printf("\033[s\033[%d;0H\r%s: [%s] %.1f%% (%d/%d)%s\033[u",
+		spd.termHeight, jobID, bar, progress, status.ItemsCached, status.ItemsTotal, suffix)
 
 	// Flush the output
 	os.Stdout.Sync()
@@ -0,0 +1,37 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPrompt is the shell prompt when no template is configured
+const defaultPrompt = "> "
+
+// promptState is what the segments of a prompt show
+type promptState struct {
+	workspace string        // Active workspace, empty without one
+	source    string        // Data source of the last query, or the workspace default
+	jobs      int           // Running jobs
+	lastQuery time.Duration // Duration of the last query, 0 before the first
+}
+
+// formatPrompt fills the segments of a prompt template
+func formatPrompt(template string, state promptState) string {
+	last := "-"
+	switch {
+	case state.lastQuery >= time.Second:
+		last = fmt.Sprintf("%.1fs", state.lastQuery.Seconds())
+	case state.lastQuery > 0:
+		last = fmt.Sprintf("%dms", state.lastQuery.Milliseconds())
+	}
+
+	return strings.NewReplacer(
+		"{ws}", state.workspace,
+		"{source}", state.source,
+		"{jobs}", strconv.Itoa(state.jobs),
+		"{last}", last,
+	).Replace(template)
+}
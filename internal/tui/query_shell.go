@@ -3,6 +3,7 @@ package tui
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/brainless/PubDataHub/internal/log"
 	"github.com/brainless/PubDataHub/internal/query"
@@ -144,7 +145,7 @@ func (s *QueryShell) handleExportQuery(args []string) error {
 	}
 
 	// Start export job
-	jobID, err := s.queryEngine.StartExportJob(dataSource, queryStr, query.OutputFormat(format), file)
+	jobID, err := s.queryEngine.StartExportJob(dataSource, queryStr, query.OutputFormat(format), query.CompressionNone, nil, file)
 	if err != nil {
 		return fmt.Errorf("failed to start export job: %w", err)
 	}
@@ -289,13 +290,13 @@ func (s *QueryShell) displayTableWithBorders(result query.QueryResult) {
 		limit = 20
 	}
 
+	kinds := query.InferColumnKinds(result.Columns, result.Rows[:limit])
 	for i := 0; i < limit; i++ {
 		row := result.Rows[i]
 		for j, cell := range row {
 			if j < len(colWidths) {
-				cellStr := fmt.Sprintf("%v", cell)
-				if len(cellStr) > colWidths[j] {
-					colWidths[j] = len(cellStr)
+				if width := utf8.RuneCountInString(query.DisplayString(cell, 50)); width > colWidths[j] {
+					colWidths[j] = width
 				}
 			}
 		}
@@ -340,9 +341,14 @@ func (s *QueryShell) displayTableWithBorders(result query.QueryResult) {
 		row := result.Rows[i]
 		fmt.Print("│")
 		for j, cell := range row {
-			if j < len(colWidths) {
-				cellStr := fmt.Sprintf("%v", cell)
-				fmt.Printf(" %-*s │", colWidths[j], truncateString(cellStr, colWidths[j]))
+			if j >= len(colWidths) {
+				continue
+			}
+			// Numbers are right-aligned
+			if kinds[j] == query.ColumnNumeric {
+				fmt.Printf(" %*s │", colWidths[j], query.DisplayString(cell, colWidths[j]))
+			} else {
+				fmt.Printf(" %-*s │", colWidths[j], query.DisplayString(cell, colWidths[j]))
 			}
 		}
 		fmt.Println()
@@ -375,7 +381,7 @@ func (s *QueryShell) showQueryHelp() error {
 	fmt.Println("  query cache stats                      Show cache statistics")
 	fmt.Println("  query cache clear                      Clear query cache")
 	fmt.Println()
-	fmt.Println("Export formats: csv, json, tsv")
+	fmt.Println("Export formats: csv, json, tsv, sqlite, xlsx; --file s3://bucket/key uploads to object storage")
 	fmt.Println()
 	fmt.Println("Backward compatibility:")
 	fmt.Println("  query <source> <sql>                   Execute query (legacy format)")
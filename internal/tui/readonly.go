@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"fmt"
+)
+
+// readOnlyReason is set when another instance holds the storage lock or the
+// storage is opened read-only; shells created afterwards run read-only
+var readOnlyReason string
+
+// SetReadOnly makes shells created afterwards read-only: no job manager or
+// session recovery is started and commands that modify storage are refused
+func SetReadOnly(reason string) {
+	readOnlyReason = reason
+}
+
+// readOnlyCommands lists the subcommands refused in read-only mode; a nil
+// list refuses the whole command
+var readOnlyCommands = map[string][]string{
+	"download":   nil,
+	"jobs":       nil,
+	"sources":    {"import-dataset", "enable", "disable", "add", "remove", "redownload"},
+	"derived":    {"create", "refresh", "drop"},
+	"checkpoint": {"set", "delete", "rm", "prune"},
+	"workspace":  {"create", "delete", "import", "set"},
+	"notebook":   {"create", "add", "note", "remove", "rm", "run", "delete"},
+	"report":     {"create", "run", "delete"},
+	"config":     {"set", "set-storage"},
+	"cache":      {"clear"},
+	".export":    nil,
+}
+
+// checkReadOnly returns an error when the shell is read-only and the command
+// would modify shared storage
+func (s *Shell) checkReadOnly(parts []string) error {
+	if s.readOnly == "" || len(parts) == 0 {
+		return nil
+	}
+
+	if !matchesCommand(readOnlyCommands, parts) {
+		return nil
+	}
+	return fmt.Errorf("%s is not available in read-only mode (%s)", parts[0], s.readOnly)
+}
+
+// matchesCommand reports whether parts run a command listed in commands,
+// which maps command names to subcommands; a nil list matches the whole
+// command
+func matchesCommand(commands map[string][]string, parts []string) bool {
+	if len(parts) == 0 {
+		return false
+	}
+	subcommands, listed := commands[parts[0]]
+	if !listed {
+		return false
+	}
+	if subcommands == nil {
+		return true
+	}
+
+	// Subcommands come first, after an optional group such as "cache http"
+	args := parts[1:]
+	if len(args) > 2 {
+		args = args[:2]
+	}
+	for _, arg := range args {
+		for _, sub := range subcommands {
+			if arg == sub {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// showReadOnlyBanner tells the user the shell is read-only
+func (s *Shell) showReadOnlyBanner() {
+	if s.readOnly == "" {
+		return
+	}
+	fmt.Printf("Read-only mode: %s\n", s.readOnly)
+	if s.daemon != nil {
+		fmt.Println("Queries work; download and jobs run in the daemon and continue after you exit.")
+		fmt.Println()
+		return
+	}
+	fmt.Println("Queries work; downloads, jobs and changes to storage are disabled.")
+	fmt.Println()
+}
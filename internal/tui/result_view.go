@@ -0,0 +1,338 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"github.com/brainless/PubDataHub/internal/query"
+	"golang.org/x/term"
+)
+
+// resultWheelRows is how many rows one turn of the wheel scrolls
+const resultWheelRows = 3
+
+// ResultView is a full-screen view paging through every row of a query
+// result. Clicking a column title, or pressing its number, sorts by it.
+type ResultView struct {
+	title    string
+	result   datasource.QueryResult
+	values   query.ValueFormat
+	terminal *TerminalManager
+	order    []int // Rows in display order
+	sortBy   int   // Sorted column, -1 for the order of the query
+	reverse  bool
+	top      int // First row shown
+	message  string
+
+	// Rendered table for the current order and width: column titles,
+	// separator, then one line per row
+	lines []string
+	spans [][2]int // Screen columns of each column, 0-based and end-exclusive
+	width int
+}
+
+// NewResultView creates a view of result, formatting values like tables
+// in the shell
+func NewResultView(title string, result datasource.QueryResult, values query.ValueFormat) *ResultView {
+	order := make([]int, len(result.Rows))
+	for i := range order {
+		order[i] = i
+	}
+	return &ResultView{
+		title:    title,
+		result:   result,
+		values:   values,
+		terminal: NewTerminalManager(),
+		order:    order,
+		sortBy:   -1,
+	}
+}
+
+// Run shows the view until the user quits. When stdin is not a terminal
+// the whole table is printed once instead.
+func (rv *ResultView) Run() error {
+	if len(rv.result.Columns) == 0 {
+		return fmt.Errorf("the result has no columns")
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		rv.renderTable(0)
+		fmt.Println(strings.Join(rv.lines, "\n"))
+		return nil
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("failed to enter raw mode: %w", err)
+	}
+	defer term.Restore(fd, oldState)
+
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+	defer enableMouse()()
+
+	keys := make(chan topKey)
+	go readTopKeys(keys)
+	resized := watchResize(rv.terminal)
+	defer rv.terminal.StopWatchingResize()
+
+	rv.draw()
+	for {
+		select {
+		case <-resized:
+		case key := <-keys:
+			if key == topKeyQuit {
+				return nil
+			}
+			rv.handleKey(key)
+		}
+		rv.draw()
+	}
+}
+
+// handleKey applies a keypress or mouse report to the view
+func (rv *ResultView) handleKey(key topKey) {
+	rv.message = ""
+	if event, ok := parseMouse(key); ok {
+		rv.handleMouse(event)
+		return
+	}
+
+	page := rv.pageRows()
+	switch key {
+	case topKeyUp:
+		rv.scroll(-1)
+	case topKeyDown:
+		rv.scroll(1)
+	case "\033[6~", " ", "f":
+		rv.scroll(page)
+	case "\033[5~", "b":
+		rv.scroll(-page)
+	case "\033[H", "g":
+		rv.top = 0
+	case "\033[F", "G":
+		rv.scroll(len(rv.order))
+	case "r":
+		if rv.sortBy >= 0 {
+			rv.sort(rv.sortBy)
+		}
+	default:
+		if len(key) == 1 && key[0] >= '1' && key[0] <= '9' {
+			if column := int(key[0] - '1'); column < len(rv.result.Columns) {
+				rv.sort(column)
+			}
+		}
+	}
+}
+
+// handleMouse applies a click or wheel turn: the wheel scrolls the rows and
+// clicking a column title sorts by it
+func (rv *ResultView) handleMouse(event mouseEvent) {
+	switch {
+	case event.button == mouseWheelUp:
+		rv.scroll(-resultWheelRows)
+	case event.button == mouseWheelDown:
+		rv.scroll(resultWheelRows)
+	case event.button != mouseLeft || event.release:
+	case event.y == 2:
+		// Line 1 is the title, line 2 the column titles
+		if column := rv.columnAt(event.x - 1); column >= 0 {
+			rv.sort(column)
+		}
+	}
+}
+
+// columnAt returns the column shown at 0-based screen column x, or -1
+func (rv *ResultView) columnAt(x int) int {
+	for i, span := range rv.spans {
+		// The gap after a column belongs to it, so near misses still sort
+		if x >= span[0] && x < span[1]+2 {
+			return i
+		}
+	}
+	return -1
+}
+
+// sort orders the rows by a column; sorting by the same column again
+// reverses the order
+func (rv *ResultView) sort(column int) {
+	if column == rv.sortBy {
+		rv.reverse = !rv.reverse
+	} else {
+		rv.sortBy, rv.reverse = column, false
+	}
+	rows := rv.result.Rows
+	sort.SliceStable(rv.order, func(i, j int) bool {
+		a, b := rows[rv.order[i]], rows[rv.order[j]]
+		if rv.reverse {
+			a, b = b, a
+		}
+		return compareCells(cell(a, column), cell(b, column)) < 0
+	})
+	rv.top = 0
+	rv.lines = nil
+
+	direction := "ascending"
+	if rv.reverse {
+		direction = "descending"
+	}
+	rv.message = fmt.Sprintf("Sorted by %s, %s", rv.result.Columns[column], direction)
+}
+
+// cell returns a value of a row, or nil for a short row
+func cell(row []interface{}, column int) interface{} {
+	if column < len(row) {
+		return row[column]
+	}
+	return nil
+}
+
+// compareCells orders two result values: NULLs first, then numbers by
+// value, times by instant and anything else by its text
+func compareCells(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	if x, ok := cellNumber(a); ok {
+		if y, ok := cellNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := a.(time.Time); ok {
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(cellText(a), cellText(b))
+}
+
+// cellNumber returns a numeric value as a float64
+func cellNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// cellText returns a value as text, with blobs read as strings
+func cellText(value interface{}) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+// scroll moves the first row shown by delta rows, keeping a full page
+// shown where there are enough rows
+func (rv *ResultView) scroll(delta int) {
+	rv.top = clampIndex(rv.top+delta, len(rv.order)-rv.pageRows()+1)
+}
+
+// pageRows returns how many rows fit on the screen below the title, the
+// column titles and the separator, above the footer
+func (rv *ResultView) pageRows() int {
+	rows := rv.terminal.GetSize().Height - 4
+	if rows < 1 {
+		rows = 1
+	}
+	return rows
+}
+
+// renderTable renders the rows in display order for a terminal width,
+// unless they already are
+func (rv *ResultView) renderTable(width int) {
+	if rv.lines != nil && rv.width == width {
+		return
+	}
+
+	rows := make([][]interface{}, len(rv.order))
+	for i, index := range rv.order {
+		rows[i] = rv.result.Rows[index]
+	}
+	// Column kinds come from the stored values, before numbers and
+	// timestamps are formatted as text
+	kinds := query.InferColumnKinds(rv.result.Columns, rows)
+	rows = rv.values.FormatRows(rv.result.Columns, rows, time.Now())
+
+	var table bytes.Buffer
+	if err := query.WriteTableWidth(&table, rv.result.Columns, rows, kinds, query.DefaultCellWidth, width); err != nil {
+		rv.lines = []string{err.Error(), ""}
+	} else {
+		rv.lines = strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")
+	}
+	rv.width = width
+
+	// The separator line has a run of dashes per column, two spaces apart
+	rv.spans = rv.spans[:0]
+	start := 0
+	for _, dashes := range strings.Split(rv.lines[1], "  ") {
+		rv.spans = append(rv.spans, [2]int{start, start + len(dashes)})
+		start += len(dashes) + 2
+	}
+}
+
+// draw renders the view to the terminal
+func (rv *ResultView) draw() {
+	size := rv.terminal.GetSize()
+	fmt.Print("\033[H\033[2J" + rv.render(size.Width, size.Height))
+}
+
+// render formats the view for a terminal of the given size: a title, the
+// column titles, a page of rows and a footer with the keys
+func (rv *ResultView) render(width, height int) string {
+	rv.renderTable(width)
+	page := height - 4
+	if page < 1 {
+		page = 1
+	}
+
+	var b strings.Builder
+	last := min(rv.top+page, len(rv.order))
+	title := fmt.Sprintf("%s - rows %d-%d of %d", rv.title, rv.top+1, last, len(rv.order))
+	if len(rv.order) == 0 {
+		title = rv.title + " - no rows"
+	}
+	b.WriteString(Bold + fitWidth(title, width) + Reset + "\r\n")
+	b.WriteString(Bold + fitWidth(rv.lines[0], width) + Reset + "\r\n")
+	b.WriteString(fitWidth(rv.lines[1], width) + "\r\n")
+	for i := 0; i < page; i++ {
+		if line := 2 + rv.top + i; line < len(rv.lines) {
+			b.WriteString(fitWidth(rv.lines[line], width))
+		}
+		b.WriteString("\r\n")
+	}
+
+	footer := "↑/↓ scroll  pgup/pgdn page  g/G top/bottom  1-9 or click a title sort  r reverse  q close"
+	if rv.message != "" {
+		footer = rv.message + "  |  " + footer
+	}
+	b.WriteString(Dim + fitWidth(footer, width) + Reset)
+	return b.String()
+}
@@ -0,0 +1,360 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brainless/PubDataHub/internal/datasource"
+	"golang.org/x/term"
+)
+
+// browseSampleRows is how many rows of the selected table are shown
+const browseSampleRows = 5
+
+// browsePane is the pane of the schema browser that has the focus
+type browsePane int
+
+const (
+	browseTables browsePane = iota
+	browseColumns
+)
+
+// tableDetails are the indexes and sample rows of a table, loaded when the
+// table is first selected
+type tableDetails struct {
+	indexes []string
+	sample  datasource.QueryResult
+	err     error
+}
+
+// SchemaBrowser is a full-screen view of a data source's tables with their
+// columns, indexes and sample rows. Selected table and column names are
+// collected in a query buffer that the shell's next prompt starts with.
+type SchemaBrowser struct {
+	source   string
+	ds       datasource.DataSource
+	terminal *TerminalManager
+	tables   []datasource.TableSchema
+	details  map[string]*tableDetails
+	focus    browsePane
+	table    int
+	column   int
+	buffer   string
+	lastWord browsePane // Pane of the last inserted name, to join columns with commas
+	message  string
+}
+
+// NewSchemaBrowser creates a schema browser for a data source
+func NewSchemaBrowser(source string, ds datasource.DataSource) *SchemaBrowser {
+	return &SchemaBrowser{
+		source:   source,
+		ds:       ds,
+		terminal: NewTerminalManager(),
+		tables:   ds.GetSchema().Tables,
+		details:  make(map[string]*tableDetails),
+	}
+}
+
+// Run shows the browser until the user quits and returns the query buffer.
+// When stdin is not a terminal the schema is printed once instead.
+func (sb *SchemaBrowser) Run() (string, error) {
+	if len(sb.tables) == 0 {
+		return "", fmt.Errorf("data source %s has no tables", sb.source)
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		size := sb.terminal.GetSize()
+		fmt.Print(strings.ReplaceAll(sb.render(size.Width, len(sb.tables)+browseSampleRows+16), "\r\n", "\n"))
+		return "", nil
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to enter raw mode: %w", err)
+	}
+	defer term.Restore(fd, oldState)
+
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+	defer enableMouse()()
+
+	keys := make(chan topKey)
+	go readTopKeys(keys)
+	resized := watchResize(sb.terminal)
+	defer sb.terminal.StopWatchingResize()
+
+	sb.draw()
+	for {
+		select {
+		case <-resized:
+		case key := <-keys:
+			if key == topKeyQuit {
+				return sb.buffer, nil
+			}
+			sb.handleKey(key)
+		}
+		sb.draw()
+	}
+}
+
+// handleKey applies a keypress or mouse report to the browser
+func (sb *SchemaBrowser) handleKey(key topKey) {
+	sb.message = ""
+	if event, ok := parseMouse(key); ok {
+		sb.handleMouse(event)
+		return
+	}
+
+	switch key {
+	case topKeyUp:
+		sb.move(-1)
+	case topKeyDown:
+		sb.move(1)
+	case "\033[C", "l", "\t":
+		if len(sb.tables[sb.table].Columns) > 0 {
+			sb.focus = browseColumns
+		}
+	case "\033[D", "h", "\033[Z":
+		sb.focus = browseTables
+	case "\r", "\n", "i":
+		sb.insertSelected()
+	case "s":
+		sb.buffer = fmt.Sprintf("SELECT * FROM %s LIMIT 10", sb.tables[sb.table].Name)
+		sb.message = "Replaced the buffer with a query of " + sb.tables[sb.table].Name
+	case "\x7f", "\b":
+		sb.removeLastWord()
+	case "x":
+		sb.buffer = ""
+	}
+}
+
+// handleMouse applies a click or wheel turn: clicking a table or column
+// selects it and focuses its pane, and the wheel moves the selection
+func (sb *SchemaBrowser) handleMouse(event mouseEvent) {
+	switch {
+	case event.button == mouseWheelUp:
+		sb.move(-1)
+		return
+	case event.button == mouseWheelDown:
+		sb.move(1)
+		return
+	case event.button != mouseLeft || event.release:
+		return
+	}
+
+	// Line 1 is the title, line 2 the pane titles, entries start on line 3
+	index := event.y - 3
+	if index < 0 {
+		return
+	}
+	leftWidth := browseLeftWidth(sb.terminal.GetSize().Width)
+	switch {
+	case event.x <= leftWidth && index < len(sb.tables):
+		sb.focus = browseTables
+		if index != sb.table {
+			sb.table, sb.column = index, 0
+		}
+	case event.x > leftWidth+3 && index < len(sb.tables[sb.table].Columns):
+		sb.focus = browseColumns
+		sb.column = index
+	}
+}
+
+// move moves the selection of the focused pane by delta
+func (sb *SchemaBrowser) move(delta int) {
+	if sb.focus == browseColumns {
+		sb.column = clampIndex(sb.column+delta, len(sb.tables[sb.table].Columns))
+		return
+	}
+	sb.table = clampIndex(sb.table+delta, len(sb.tables))
+	sb.column = 0
+}
+
+// clampIndex limits index to the positions of a list of length n
+func clampIndex(index, n int) int {
+	if index >= n {
+		index = n - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	return index
+}
+
+// insertSelected appends the selected table or column name to the buffer;
+// consecutive columns are separated by commas
+func (sb *SchemaBrowser) insertSelected() {
+	table := sb.tables[sb.table]
+	name := table.Name
+	if sb.focus == browseColumns {
+		name = table.Columns[sb.column].Name
+	}
+
+	switch {
+	case sb.buffer == "":
+		sb.buffer = name
+	case sb.focus == browseColumns && sb.lastWord == browseColumns:
+		sb.buffer += ", " + name
+	default:
+		sb.buffer += " " + name
+	}
+	sb.lastWord = sb.focus
+	sb.message = "Inserted " + name
+}
+
+// removeLastWord removes the last name or keyword from the buffer
+func (sb *SchemaBrowser) removeLastWord() {
+	trimmed := strings.TrimRight(sb.buffer, " ,")
+	cut := strings.LastIndexAny(trimmed, " ,")
+	sb.buffer = strings.TrimRight(trimmed[:cut+1], " ,")
+}
+
+// selectedDetails loads the indexes and sample rows of the selected table
+func (sb *SchemaBrowser) selectedDetails() *tableDetails {
+	name := sb.tables[sb.table].Name
+	if details, ok := sb.details[name]; ok {
+		return details
+	}
+
+	details := &tableDetails{}
+	// Index definitions come from SQLite's catalog; other stores have none
+	if indexes, err := sb.ds.Query(fmt.Sprintf(
+		"SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = '%s' AND sql IS NOT NULL ORDER BY name",
+		strings.ReplaceAll(name, "'", "''"))); err == nil {
+		for _, row := range indexes.Rows {
+			definition := fmt.Sprint(row[1])
+			if open := strings.Index(definition, "("); open >= 0 {
+				definition = definition[open:]
+			}
+			details.indexes = append(details.indexes, fmt.Sprintf("%v %s", row[0], definition))
+		}
+	}
+	details.sample, details.err = sb.ds.Query(fmt.Sprintf("SELECT * FROM %s LIMIT %d", name, browseSampleRows))
+	sb.details[name] = details
+	return details
+}
+
+// draw renders the browser to the terminal
+func (sb *SchemaBrowser) draw() {
+	size := sb.terminal.GetSize()
+	fmt.Print("\033[H\033[2J" + sb.render(size.Width, size.Height))
+}
+
+// render formats the browser for a terminal of the given size: tables on
+// the left, the selected table's columns, indexes and sample rows on the
+// right, and the query buffer at the bottom
+func (sb *SchemaBrowser) render(width, height int) string {
+	var b strings.Builder
+	b.WriteString(Bold + fitWidth(fmt.Sprintf("Schema of %s - %d tables", sb.source, len(sb.tables)), width) + Reset + "\r\n")
+
+	leftWidth := browseLeftWidth(width)
+	rightWidth := width - leftWidth - 3
+
+	left := make([]string, 0, len(sb.tables)+1)
+	left = append(left, Bold+padRight("TABLES", leftWidth)+Reset)
+	for i, table := range sb.tables {
+		line := padRight(fmt.Sprintf("%s (%d)", table.Name, len(table.Columns)), leftWidth)
+		if i == sb.table {
+			line = highlight(line, sb.focus == browseTables)
+		}
+		left = append(left, line)
+	}
+
+	right := sb.renderDetails(rightWidth)
+
+	// Title, buffer and footer take three lines
+	rows := height - 3
+	if rows < 1 {
+		rows = 1
+	}
+	for i := 0; i < rows; i++ {
+		l := strings.Repeat(" ", leftWidth)
+		if i < len(left) {
+			l = left[i]
+		}
+		r := ""
+		if i < len(right) {
+			r = right[i]
+		}
+		b.WriteString(l + " │ " + r + "\r\n")
+	}
+
+	b.WriteString(fitWidth("Buffer: "+sb.buffer, width) + "\r\n")
+	footer := "↑/↓ select  ←/→ tables/columns  enter insert name  s select table  ⌫ remove  x clear  q done"
+	if sb.message != "" {
+		footer = sb.message + "  |  " + footer
+	}
+	b.WriteString(Dim + fitWidth(footer, width) + Reset)
+	return b.String()
+}
+
+// browseLeftWidth returns the width of the tables pane for a terminal of
+// the given width
+func browseLeftWidth(width int) int {
+	leftWidth := 28
+	if width > 0 && leftWidth > width/3 {
+		leftWidth = width / 3
+	}
+	return leftWidth
+}
+
+// renderDetails formats the columns, indexes and sample rows of the
+// selected table
+func (sb *SchemaBrowser) renderDetails(width int) []string {
+	table := sb.tables[sb.table]
+	lines := []string{Bold + fitWidth("COLUMNS", width) + Reset}
+	for i, column := range table.Columns {
+		line := padRight(fitWidth(fmt.Sprintf("%-24s %s", column.Name, column.Type), width), width)
+		if i == sb.column {
+			line = highlight(line, sb.focus == browseColumns)
+		}
+		lines = append(lines, line)
+	}
+
+	details := sb.selectedDetails()
+	lines = append(lines, "", Bold+fitWidth("INDEXES", width)+Reset)
+	if len(details.indexes) == 0 {
+		lines = append(lines, Dim+"none"+Reset)
+	}
+	for _, index := range details.indexes {
+		lines = append(lines, fitWidth(index, width))
+	}
+
+	lines = append(lines, "", Bold+fitWidth("SAMPLE ROWS", width)+Reset)
+	switch {
+	case details.err != nil:
+		lines = append(lines, fitWidth(details.err.Error(), width))
+	case len(details.sample.Rows) == 0:
+		lines = append(lines, Dim+"empty"+Reset)
+	}
+	for _, row := range details.sample.Rows {
+		values := make([]string, len(row))
+		for i, value := range row {
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			values[i] = strings.Join(strings.Fields(fmt.Sprint(value)), " ")
+		}
+		lines = append(lines, fitWidth(strings.Join(values, " | "), width))
+	}
+	return lines
+}
+
+// highlight marks the selected line, underlined when its pane is not focused
+func highlight(line string, focused bool) string {
+	if focused {
+		return "\033[7m" + line + Reset
+	}
+	return Underline + line + Reset
+}
+
+// padRight pads s with spaces to width runes
+func padRight(s string, width int) string {
+	s = fitWidth(s, width)
+	if n := len([]rune(s)); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+	return s
+}
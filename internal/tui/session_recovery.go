@@ -0,0 +1,154 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/shutdown"
+)
+
+const (
+	// shellSessionComponent is the state component holding the shell session
+	shellSessionComponent = "shell_session"
+	// maxSessionHistory bounds the number of commands kept in the session state
+	maxSessionHistory = 200
+)
+
+// ShellSessionState is the persisted state of an interactive shell session
+type ShellSessionState struct {
+	CommandHistory []string  `json:"command_history"`
+	Workspace      string    `json:"workspace,omitempty"`
+	PendingInput   []string  `json:"pending_input,omitempty"`
+	SavedAt        time.Time `json:"saved_at"`
+}
+
+// ShellSessionManager persists the enhanced shell's session after every
+// command so it can be recovered after an unexpected exit. It implements
+// shutdown.SessionRecoveryInterface.
+type ShellSessionManager struct {
+	mu           sync.Mutex
+	state        shutdown.StatePersistence
+	shell        *EnhancedShell
+	history      []string
+	pendingInput []string
+	recovered    *ShellSessionState
+}
+
+// NewShellSessionManager creates a session manager for the given shell
+func NewShellSessionManager(state shutdown.StatePersistence, shell *EnhancedShell) *ShellSessionManager {
+	return &ShellSessionManager{
+		state: state,
+		shell: shell,
+	}
+}
+
+// RecordCommand adds a command to the session history and clears pending input
+func (sm *ShellSessionManager) RecordCommand(input string) {
+	sm.mu.Lock()
+	sm.history = append(sm.history, input)
+	if len(sm.history) > maxSessionHistory {
+		sm.history = sm.history[len(sm.history)-maxSessionHistory:]
+	}
+	sm.pendingInput = nil
+	sm.mu.Unlock()
+
+	if err := sm.SaveSession(); err != nil {
+		log.Logger.Debugf("Failed to save shell session: %v", err)
+	}
+}
+
+// SetPendingInput records the lines of an unfinished multi-line command
+func (sm *ShellSessionManager) SetPendingInput(lines []string) {
+	sm.mu.Lock()
+	sm.pendingInput = append([]string(nil), lines...)
+	sm.mu.Unlock()
+
+	if err := sm.SaveSession(); err != nil {
+		log.Logger.Debugf("Failed to save shell session: %v", err)
+	}
+}
+
+// SaveSession writes the current session to the state store
+func (sm *ShellSessionManager) SaveSession() error {
+	sm.mu.Lock()
+	session := ShellSessionState{
+		CommandHistory: append([]string(nil), sm.history...),
+		PendingInput:   append([]string(nil), sm.pendingInput...),
+		SavedAt:        time.Now(),
+	}
+	sm.mu.Unlock()
+
+	if wm := sm.shell.workspaceManager; wm != nil {
+		if ws := wm.GetCurrentWorkspace(); ws != nil {
+			session.Workspace = ws.Name
+		}
+	}
+
+	if err := sm.state.SaveState(shellSessionComponent, session); err != nil {
+		return fmt.Errorf("failed to save shell session: %w", err)
+	}
+	return nil
+}
+
+// LoadSession loads the previous session, switches back to its workspace and
+// queues any unfinished multi-line input for editing
+func (sm *ShellSessionManager) LoadSession() error {
+	var session ShellSessionState
+	if err := sm.state.LoadState(shellSessionComponent, &session); err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	sm.recovered = &session
+	sm.history = append([]string(nil), session.CommandHistory...)
+	sm.mu.Unlock()
+
+	if session.Workspace != "" && sm.shell.workspaceManager != nil {
+		if err := sm.shell.workspaceManager.SwitchWorkspace(session.Workspace); err != nil {
+			log.Logger.Warnf("Could not restore workspace '%s': %v", session.Workspace, err)
+		}
+	}
+
+	if len(session.PendingInput) > 0 {
+		sm.shell.pendingInput = strings.Join(session.PendingInput, " ")
+		fmt.Printf("Recovered unfinished input from %s\n", session.SavedAt.Format("2006-01-02 15:04:05"))
+	}
+
+	return nil
+}
+
+// RestoreCommandHistory seeds readline history from the session when the
+// history file was lost
+func (sm *ShellSessionManager) RestoreCommandHistory() error {
+	sm.mu.Lock()
+	recovered := sm.recovered
+	sm.mu.Unlock()
+
+	if recovered == nil || len(recovered.CommandHistory) == 0 || sm.shell.readline == nil {
+		return nil
+	}
+
+	// Readline appends to the history file on every line, so only restore
+	// when the file is missing or empty
+	if stat, err := os.Stat(sm.shell.historyFile); err == nil && stat.Size() > 0 {
+		return nil
+	}
+
+	for _, cmd := range recovered.CommandHistory {
+		if err := sm.shell.readline.SaveHistory(cmd); err != nil {
+			return fmt.Errorf("failed to restore history entry: %w", err)
+		}
+	}
+
+	log.Logger.Infof("Restored %d commands to shell history", len(recovered.CommandHistory))
+	return nil
+}
+
+// ValidateSession checks that the session can be persisted
+func (sm *ShellSessionManager) ValidateSession() error {
+	return sm.SaveSession()
+}
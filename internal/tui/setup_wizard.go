@@ -0,0 +1,320 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/datasource/hackernews"
+	"github.com/brainless/PubDataHub/internal/jobs"
+)
+
+// Limits for values entered in the setup wizard
+const (
+	maxSetupWorkers   = 32
+	maxSetupRateLimit = 100
+)
+
+// ErrSetupCancelled is returned when the user declines to save the setup
+var ErrSetupCancelled = fmt.Errorf("setup cancelled")
+
+// SetupWizard walks through first-run configuration: storage path, enabled
+// data sources, worker count, rate limits and an optional nightly sync
+type SetupWizard struct {
+	in      *bufio.Reader
+	out     io.Writer
+	sources []setupSource
+}
+
+// setupSource is a data source offered by the setup wizard
+type setupSource struct {
+	name        string
+	description string
+	rateLimit   int // Default API requests per second
+}
+
+// setupChoices holds the answers collected by the wizard
+type setupChoices struct {
+	storagePath string
+	enabled     map[string]bool
+	workers     int
+	rateLimits  map[string]int
+	syncTime    string // HH:MM, empty for no nightly sync
+}
+
+// NewSetupWizard creates a wizard reading answers from in and writing
+// prompts to out
+func NewSetupWizard(in io.Reader, out io.Writer) *SetupWizard {
+	return &SetupWizard{
+		in:  bufio.NewReader(in),
+		out: out,
+		sources: []setupSource{
+			{name: "hackernews", description: "Hacker News stories, comments and users", rateLimit: hackernews.DefaultRequestsPerSecond},
+		},
+	}
+}
+
+// Run asks the setup questions, writes the resulting config and creates the
+// storage directory layout
+func (w *SetupWizard) Run() error {
+	fmt.Fprintln(w.out, "Welcome to PubDataHub! Let's set up your configuration.")
+	fmt.Fprintln(w.out, "Press Enter to accept the default shown in brackets.")
+	fmt.Fprintln(w.out)
+
+	choices := setupChoices{
+		enabled:    make(map[string]bool),
+		rateLimits: make(map[string]int),
+	}
+
+	var err error
+	if choices.storagePath, err = w.askStoragePath(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w.out)
+	for _, ds := range w.sources {
+		enable, err := w.askYesNo(fmt.Sprintf("Enable %s (%s)?", ds.name, ds.description), config.AppConfig.SourceEnabled(ds.name))
+		if err != nil {
+			return err
+		}
+		choices.enabled[ds.name] = enable
+	}
+
+	fmt.Fprintln(w.out)
+	if choices.workers, err = w.askInt("Background workers", defaultInt(config.AppConfig.Jobs.Workers, jobs.DefaultManagerConfig().MaxWorkers), 1, maxSetupWorkers); err != nil {
+		return err
+	}
+	for _, ds := range w.sources {
+		if !choices.enabled[ds.name] {
+			continue
+		}
+		limit := defaultInt(config.AppConfig.DataSources[ds.name].RateLimit, ds.rateLimit)
+		if choices.rateLimits[ds.name], err = w.askInt(fmt.Sprintf("%s API requests per second", ds.name), limit, 1, maxSetupRateLimit); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w.out)
+	if len(choices.rateLimits) > 0 {
+		nightly, err := w.askYesNo("Schedule a nightly sync of enabled sources?", false)
+		if err != nil {
+			return err
+		}
+		if nightly {
+			if choices.syncTime, err = w.askSyncTime(); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.showSummary(choices)
+	save, err := w.askYesNo("Save this configuration?", true)
+	if err != nil {
+		return err
+	}
+	if !save {
+		return ErrSetupCancelled
+	}
+
+	if err := config.Save(choices.configValues(w.sources), config.OriginSetup); err != nil {
+		return err
+	}
+	if err := createStorageLayout(choices); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w.out, "Setup complete. Data will be stored in %s\n\n", choices.storagePath)
+	return nil
+}
+
+// askStoragePath asks for a storage directory until a usable one is given
+func (w *SetupWizard) askStoragePath() (string, error) {
+	for {
+		answer, err := w.ask("Storage path for downloaded data", config.AppConfig.StoragePath)
+		if err != nil {
+			return "", err
+		}
+
+		path, free, err := jobs.ValidateStoragePath(answer)
+		if err != nil {
+			fmt.Fprintf(w.out, "  %v\n", err)
+			continue
+		}
+		if free == 0 {
+			return path, nil
+		}
+
+		fmt.Fprintf(w.out, "  %d MB free\n", free/(1024*1024))
+		minFreeMB := config.AppConfig.Download.MinFreeMB
+		if free >= uint64(minFreeMB)*1024*1024 {
+			return path, nil
+		}
+		useAnyway, err := w.askYesNo(fmt.Sprintf("  Downloads pause below %d MB free. Use this path anyway?", minFreeMB), false)
+		if err != nil {
+			return "", err
+		}
+		if useAnyway {
+			return path, nil
+		}
+	}
+}
+
+// askSyncTime asks for the time of day of the nightly sync
+func (w *SetupWizard) askSyncTime() (string, error) {
+	for {
+		answer, err := w.ask("Sync time (HH:MM, local time)", "02:00")
+		if err != nil {
+			return "", err
+		}
+		if _, err := time.Parse("15:04", answer); err != nil {
+			fmt.Fprintln(w.out, "  Enter a time such as 02:00 or 23:30")
+			continue
+		}
+		return answer, nil
+	}
+}
+
+// askInt asks for a number between min and max
+func (w *SetupWizard) askInt(question string, defaultValue, min, max int) (int, error) {
+	for {
+		answer, err := w.ask(question, strconv.Itoa(defaultValue))
+		if err != nil {
+			return 0, err
+		}
+		value, err := strconv.Atoi(answer)
+		if err != nil || value < min || value > max {
+			fmt.Fprintf(w.out, "  Enter a number from %d to %d\n", min, max)
+			continue
+		}
+		return value, nil
+	}
+}
+
+// askYesNo asks a yes/no question
+func (w *SetupWizard) askYesNo(question string, defaultValue bool) (bool, error) {
+	hint := "y/N"
+	if defaultValue {
+		hint = "Y/n"
+	}
+	for {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, hint)
+		answer, err := w.readLine()
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return defaultValue, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(w.out, "  Answer y or n")
+	}
+}
+
+// ask prints a question with its default and returns the answer
+func (w *SetupWizard) ask(question, defaultValue string) (string, error) {
+	fmt.Fprintf(w.out, "%s [%s]: ", question, defaultValue)
+	answer, err := w.readLine()
+	if err != nil {
+		return "", err
+	}
+	if answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// readLine reads one trimmed line of input
+func (w *SetupWizard) readLine() (string, error) {
+	line, err := w.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return "", ErrSetupCancelled
+		}
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// showSummary prints the collected answers before they are saved
+func (w *SetupWizard) showSummary(choices setupChoices) {
+	fmt.Fprintln(w.out, "Configuration summary:")
+	fmt.Fprintf(w.out, "  Storage path:  %s\n", choices.storagePath)
+	fmt.Fprintf(w.out, "  Workers:       %d\n", choices.workers)
+	for _, ds := range w.sources {
+		if !choices.enabled[ds.name] {
+			fmt.Fprintf(w.out, "  %-14s disabled\n", ds.name+":")
+			continue
+		}
+		fmt.Fprintf(w.out, "  %-14s enabled, %d requests/s\n", ds.name+":", choices.rateLimits[ds.name])
+	}
+	if choices.syncTime != "" {
+		fmt.Fprintf(w.out, "  Nightly sync:  %s\n", choices.syncTime)
+	} else {
+		fmt.Fprintln(w.out, "  Nightly sync:  off")
+	}
+	fmt.Fprintln(w.out)
+}
+
+// configValues returns the config keys to save for the choices
+func (c setupChoices) configValues(sources []setupSource) map[string]interface{} {
+	values := map[string]interface{}{
+		"storage_path": c.storagePath,
+		"jobs.workers": c.workers,
+	}
+	for _, ds := range sources {
+		prefix := "data_sources." + ds.name + "."
+		values[prefix+"enabled"] = c.enabled[ds.name]
+		schedule := ""
+		if c.enabled[ds.name] {
+			values[prefix+"rate_limit"] = c.rateLimits[ds.name]
+			schedule = dailyCronSchedule(c.syncTime)
+		}
+		values[prefix+"sync_schedule"] = schedule
+	}
+	return values
+}
+
+// createStorageLayout creates the storage directory, the log directory and a
+// directory for each enabled data source
+func createStorageLayout(choices setupChoices) error {
+	dirs := []string{choices.storagePath, filepath.Join(choices.storagePath, "logs")}
+	for name, enabled := range choices.enabled {
+		if enabled {
+			dirs = append(dirs, filepath.Join(choices.storagePath, name))
+		}
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// dailyCronSchedule converts HH:MM to a daily cron expression, or "" for an
+// empty time
+func dailyCronSchedule(syncTime string) string {
+	t, err := time.Parse("15:04", syncTime)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d %d * * *", t.Minute(), t.Hour())
+}
+
+// defaultInt returns value, or fallback when value is not positive
+func defaultInt(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
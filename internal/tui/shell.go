@@ -8,16 +8,26 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/brainless/PubDataHub/internal/command"
 	"github.com/brainless/PubDataHub/internal/config"
+	"github.com/brainless/PubDataHub/internal/daemon"
 	"github.com/brainless/PubDataHub/internal/datasource"
 	"github.com/brainless/PubDataHub/internal/datasource/hackernews"
+	"github.com/brainless/PubDataHub/internal/editor"
 	"github.com/brainless/PubDataHub/internal/jobs"
 	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/query"
+	"github.com/brainless/PubDataHub/internal/trash"
+	"github.com/brainless/PubDataHub/internal/workspace"
 
 	"golang.org/x/term"
 )
 
+// slowCommandThreshold is how long a command runs before its time is printed
+const slowCommandThreshold = 2 * time.Second
+
 // Shell represents the interactive TUI shell
 type Shell struct {
 	ctx             context.Context
@@ -27,6 +37,12 @@ type Shell struct {
 	reader          *bufio.Scanner
 	progressDisplay *SimpleProgressDisplay
 	termHeight      int
+	statusBar       *StatusBar // Set by the enhanced shell; suspended during full-screen views
+	commands        *command.ShellIntegration
+	history         *command.CommandHistory // nil when the shell is read-only
+	trash           *trash.Trash            // nil when read-only or deletions are permanent
+	readOnly        string                  // Why the shell is read-only; empty when it owns the storage
+	daemon          *daemon.Client          // Runs downloads and jobs when a daemon owns the storage
 }
 
 // NewShell creates a new interactive shell instance
@@ -46,13 +62,43 @@ func NewShell() *Shell {
 		dataSources: make(map[string]datasource.DataSource),
 		reader:      bufio.NewScanner(os.Stdin),
 		termHeight:  height,
+		commands:    command.NewShellIntegration(),
+		readOnly:    readOnlyReason,
+		daemon:      attachedDaemon,
+	}
+	if err := shell.commands.RegisterApplicationCommands(); err != nil {
+		log.Logger.Warnf("Failed to register application commands: %v", err)
 	}
 
 	// Initialize available data sources
 	shell.initializeDataSources()
 
+	// Jobs, the command history and the trash are owned by the instance
+	// holding the storage lock
+	if shell.readOnly != "" {
+		shell.commands.Use(command.TimingMiddleware(slowCommandThreshold))
+		return shell
+	}
+
+	history, err := command.NewCommandHistory(config.AppConfig.StoragePath)
+	if err != nil {
+		log.Logger.Warnf("Failed to open command history, commands will not be audited: %v", err)
+		shell.commands.Use(command.TimingMiddleware(slowCommandThreshold))
+	} else {
+		shell.history = history
+		shell.commands.Use(command.TimingMiddleware(slowCommandThreshold), command.AuditMiddleware(history))
+	}
+	shell.openTrash()
+
 	// Initialize enhanced job manager
 	jobConfig := jobs.DefaultManagerConfig()
+	if config.AppConfig.Jobs.Workers > 0 {
+		jobConfig.MaxWorkers = config.AppConfig.Jobs.Workers
+	}
+	jobConfig.DiskGuard.MinFreeMB = config.AppConfig.Download.MinFreeMB
+	jobConfig.DiskGuard.ResumeFreeMB = config.AppConfig.Download.ResumeFreeMB
+	jobConfig.QuotaGuard.QuotasMB = config.AppConfig.SourceQuotas()
+	jobConfig.QuotaGuard.WarnPercent = config.AppConfig.Storage.QuotaWarnPercent
 	enhancedJobManager, err := jobs.NewEnhancedJobManager(config.AppConfig.StoragePath, shell.dataSources, jobConfig)
 	if err != nil {
 		log.Logger.Errorf("Failed to create enhanced job manager: %v", err)
@@ -60,10 +106,14 @@ func NewShell() *Shell {
 		shell.jobManager = nil
 	} else {
 		shell.jobManager = enhancedJobManager
+		if shell.trash != nil {
+			enhancedJobManager.SetTrash(shell.trash)
+		}
 		// Start the job manager
 		if err := shell.jobManager.Start(); err != nil {
 			log.Logger.Errorf("Failed to start job manager: %v", err)
 		}
+		shell.scheduleSourceSyncs()
 
 		// Initialize simple progress display
 		shell.progressDisplay = NewSimpleProgressDisplay(enhancedJobManager, shell.dataSources)
@@ -73,10 +123,40 @@ func NewShell() *Shell {
 	return shell
 }
 
+// openTrash opens the trash that keeps deletions for undo, purges expired
+// entries and registers the undo command; a retention of 0 days disables it
+func (s *Shell) openTrash() {
+	days := config.AppConfig.Trash.RetentionDays
+	if days <= 0 {
+		return
+	}
+
+	bin, err := trash.New(trash.Dir(config.AppConfig.StoragePath), time.Duration(days)*24*time.Hour)
+	if err != nil {
+		log.Logger.Warnf("Failed to open trash, deletions cannot be undone: %v", err)
+		return
+	}
+	if purged, err := bin.Purge(time.Now()); err != nil {
+		log.Logger.Warnf("Failed to purge expired trash entries: %v", err)
+	} else if purged > 0 {
+		log.Logger.Infof("Purged %d expired trash entries", purged)
+	}
+
+	if err := s.commands.GetRegistry().Register(command.NewUndoHandler(bin)); err != nil {
+		log.Logger.Warnf("Failed to register undo command: %v", err)
+		return
+	}
+	s.trash = bin
+}
+
 // initializeDataSources sets up available data sources
 func (s *Shell) initializeDataSources() {
+	if !config.AppConfig.SourceEnabled("hackernews") {
+		return
+	}
+
 	// Initialize Hacker News data source
-	hnDS := hackernews.NewHackerNewsDataSource(100)
+	hnDS := hackernews.NewHackerNewsDataSource(0)
 	if err := hnDS.InitializeStorage(config.AppConfig.StoragePath); err != nil {
 		log.Logger.Warnf("Failed to initialize Hacker News storage: %v", err)
 	} else {
@@ -84,6 +164,26 @@ func (s *Shell) initializeDataSources() {
 	}
 }
 
+// scheduleSourceSyncs schedules the recurring downloads configured for
+// enabled data sources
+func (s *Shell) scheduleSourceSyncs() {
+	for name := range s.dataSources {
+		s.scheduleSourceSync(name)
+	}
+}
+
+// scheduleSourceSync schedules the recurring download configured for an
+// open data source
+func (s *Shell) scheduleSourceSync(name string) {
+	schedule := config.AppConfig.DataSources[name].SyncSchedule
+	if schedule == "" || s.dataSources[name] == nil {
+		return
+	}
+	if _, err := s.jobManager.ScheduleSourceSync(name, schedule); err != nil {
+		log.Logger.Warnf("Failed to schedule sync: %v", err)
+	}
+}
+
 // Run starts the interactive shell
 func (s *Shell) Run() error {
 	// Set up signal handling for graceful shutdown
@@ -99,6 +199,7 @@ func (s *Shell) Run() error {
 	fmt.Println("PubDataHub Interactive Shell")
 	fmt.Println("Type 'help' for available commands or 'exit' to quit")
 	fmt.Println()
+	s.showReadOnlyBanner()
 
 	// Main input loop
 	for {
@@ -139,335 +240,190 @@ func (s *Shell) processCommand(input string) error {
 		return nil
 	}
 
-	command := parts[0]
-	args := parts[1:]
-
-	switch command {
-	case "help":
-		return s.showHelp()
-	case "exit", "quit":
-		return fmt.Errorf("exit")
-	case "config":
-		return s.handleConfigCommand(args)
-	case "download":
-		return s.handleDownloadCommand(args)
-	case "query":
-		return s.handleQueryCommand(args)
-	case "jobs":
-		return s.handleJobsCommand(args)
-	case "sources":
-		return s.handleSourcesCommand(args)
-	default:
-		return fmt.Errorf("unknown command: %s. Type 'help' for available commands", command)
+	if handled, err := s.runDaemonCommand(parts); handled {
+		return err
+	}
+	if err := s.checkReadOnly(parts); err != nil {
+		return err
 	}
-}
 
-// showHelp displays available commands
-func (s *Shell) showHelp() error {
-	fmt.Println("Available commands:")
-	fmt.Println("  help                           Show this help message")
-	fmt.Println("  config show                    Show current configuration")
-	fmt.Println("  config set-storage <path>      Set storage path")
-	fmt.Println("  sources list                   List available data sources")
-	fmt.Println("  sources status <source>        Show source status")
-	fmt.Println("  download <source>              Start download (background)")
-	fmt.Println("  query <source> <sql>           Execute SQL query")
-	fmt.Println("  jobs list                      List running jobs")
-	fmt.Println("  jobs status <id>               Show job status")
-	fmt.Println("  jobs stop <id>                 Stop a job")
-	fmt.Println("  exit                           Exit the shell")
-	fmt.Println()
-	return nil
+	return s.runCommand(input, s)
 }
 
-// handleConfigCommand processes config-related commands
-func (s *Shell) handleConfigCommand(args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("config command requires subcommand (show, set-storage)")
-	}
+// runCommand executes input with the command framework; services provides
+// the shell features commands use
+func (s *Shell) runCommand(input string, services command.ShellServices) error {
+	return s.commands.ProcessCommand(s.ctx, input, s.jobManager, s.dataSources, config.AppConfig, services)
+}
 
-	switch args[0] {
-	case "show":
-		fmt.Printf("Storage path: %s\n", config.AppConfig.StoragePath)
-		return nil
-	case "set-storage":
-		if len(args) < 2 {
-			return fmt.Errorf("set-storage requires a path argument")
-		}
-		if err := config.SetStoragePath(args[1]); err != nil {
-			return fmt.Errorf("failed to set storage path: %w", err)
-		}
-		fmt.Printf("Storage path set to: %s\n", args[1])
-		// Reinitialize data sources with new path
-		s.initializeDataSources()
-		return nil
-	default:
-		return fmt.Errorf("unknown config subcommand: %s", args[0])
+// FollowDownload reports the progress of a download job until it finishes
+func (s *Shell) FollowDownload(jobID, sourceName string) {
+	if s.progressDisplay != nil {
+		s.progressDisplay.Follow(jobID, sourceName)
 	}
 }
 
-// handleDownloadCommand processes download commands
-func (s *Shell) handleDownloadCommand(args []string) error {
+// RunJobsTop runs the live jobs view with the status bar suspended
+func (s *Shell) RunJobsTop(once bool) error {
 	if s.jobManager == nil {
 		return fmt.Errorf("job manager not available")
 	}
-
-	if s.progressDisplay == nil {
-		return fmt.Errorf("progress display not available")
+	if s.statusBar != nil {
+		s.statusBar.Suspend()
+		defer s.statusBar.Resume()
 	}
-
-	if len(args) == 0 {
-		return fmt.Errorf("download command requires a data source name")
-	}
-
-	sourceName := args[0]
-
-	// Use the simple progress display for enhanced progress tracking
-	return s.progressDisplay.StartDownloadWithProgress(sourceName, args[1:])
+	return NewJobsTop(s.jobManager).Run(once)
 }
 
-// handleQueryCommand processes query commands
-func (s *Shell) handleQueryCommand(args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("query command requires source name and SQL query")
-	}
-
-	sourceName := args[0]
-	query := strings.Join(args[1:], " ")
-
-	ds, exists := s.dataSources[sourceName]
-	if !exists {
-		return fmt.Errorf("unknown data source: %s", sourceName)
+// EditQuery opens a query in $VISUAL or $EDITOR with the status bar
+// suspended and returns the saved text
+func (s *Shell) EditQuery(sql string) (string, error) {
+	if s.statusBar != nil {
+		s.statusBar.Suspend()
+		defer s.statusBar.Resume()
 	}
+	return editor.Edit(sql, ".sql")
+}
 
-	result, err := ds.Query(query)
-	if err != nil {
-		return fmt.Errorf("query failed: %w", err)
+// BrowseSchema runs the schema browser of a data source with the status bar
+// suspended and prints the query it built
+func (s *Shell) BrowseSchema(sourceName string) error {
+	buffer, err := s.browseSchema(sourceName)
+	if err != nil || buffer == "" {
+		return err
 	}
-
-	// Display results
-	s.displayQueryResult(result)
+	fmt.Printf("query %s %s\n", sourceName, buffer)
 	return nil
 }
 
-// handleJobsCommand processes job management commands
-func (s *Shell) handleJobsCommand(args []string) error {
-	if s.jobManager == nil {
-		return fmt.Errorf("job manager not available")
+// browseSchema runs the schema browser and returns its query buffer
+func (s *Shell) browseSchema(sourceName string) (string, error) {
+	ds, ok := s.dataSources[sourceName]
+	if !ok {
+		return "", fmt.Errorf("unknown data source: %s", sourceName)
 	}
-
-	if len(args) == 0 {
-		return fmt.Errorf("jobs command requires subcommand (list, status, pause, resume, stop, stats)")
-	}
-
-	switch args[0] {
-	case "list":
-		summaries, err := s.jobManager.ListActiveSummaries()
-		if err != nil {
-			return fmt.Errorf("failed to list jobs: %w", err)
-		}
-		if len(summaries) == 0 {
-			fmt.Println("No active jobs")
-			return nil
-		}
-		fmt.Println("Active jobs:")
-		for _, summary := range summaries {
-			fmt.Printf("  %s: %s (%s) - %.1f%% - %s\n",
-				summary["id"],
-				summary["description"],
-				summary["state"],
-				summary["progress"],
-				summary["message"])
-		}
-		return nil
-	case "status":
-		if len(args) < 2 {
-			return fmt.Errorf("status command requires job ID")
-		}
-		summary, err := s.jobManager.GetJobSummary(args[1])
-		if err != nil {
-			return fmt.Errorf("failed to get job status: %w", err)
-		}
-		s.displayJobSummary(summary)
-		return nil
-	case "pause":
-		if len(args) < 2 {
-			return fmt.Errorf("pause command requires job ID")
-		}
-		if err := s.jobManager.PauseJob(args[1]); err != nil {
-			return fmt.Errorf("failed to pause job: %w", err)
-		}
-		fmt.Printf("Job %s paused\n", args[1])
-		return nil
-	case "resume":
-		if len(args) < 2 {
-			return fmt.Errorf("resume command requires job ID")
-		}
-		if err := s.jobManager.ResumeJob(args[1]); err != nil {
-			return fmt.Errorf("failed to resume job: %w", err)
-		}
-		fmt.Printf("Job %s resumed\n", args[1])
-		return nil
-	case "stop":
-		if len(args) < 2 {
-			return fmt.Errorf("stop command requires job ID")
-		}
-		if err := s.jobManager.CancelJob(args[1]); err != nil {
-			return fmt.Errorf("failed to stop job: %w", err)
-		}
-		fmt.Printf("Job %s stopped\n", args[1])
-		return nil
-	case "stats":
-		summary := s.jobManager.GetManagerSummary()
-		s.displayManagerStats(summary)
-		return nil
-	default:
-		return fmt.Errorf("unknown jobs subcommand: %s", args[0])
+	if s.statusBar != nil {
+		s.statusBar.Suspend()
+		defer s.statusBar.Resume()
 	}
+	return NewSchemaBrowser(sourceName, ds).Run()
 }
 
-// handleSourcesCommand processes data source commands
-func (s *Shell) handleSourcesCommand(args []string) error {
-	if len(args) == 0 {
-		return fmt.Errorf("sources command requires subcommand (list, status)")
+// BrowseResult runs the result view of a query result with the status bar
+// suspended
+func (s *Shell) BrowseResult(title string, result datasource.QueryResult, values query.ValueFormat) error {
+	if s.statusBar != nil {
+		s.statusBar.Suspend()
+		defer s.statusBar.Resume()
 	}
+	return NewResultView(title, result, values).Run()
+}
 
-	switch args[0] {
-	case "list":
-		fmt.Println("Available data sources:")
-		for name := range s.dataSources {
-			fmt.Printf("  %s\n", name)
-		}
-		return nil
-	case "status":
-		if len(args) < 2 {
-			return fmt.Errorf("status command requires source name")
-		}
-		sourceName := args[1]
-		ds, exists := s.dataSources[sourceName]
-		if !exists {
-			return fmt.Errorf("unknown data source: %s", sourceName)
-		}
-		status := ds.GetDownloadStatus()
-		s.displayDownloadStatus(sourceName, status)
-		return nil
-	default:
-		return fmt.Errorf("unknown sources subcommand: %s", args[0])
-	}
+// OutputSettings returns the default query output settings; the enhanced
+// shell uses those of the active workspace
+func (s *Shell) OutputSettings() command.OutputSettings {
+	return outputSettings(workspace.DefaultSettings())
 }
 
-// displayQueryResult formats and displays query results
-func (s *Shell) displayQueryResult(result datasource.QueryResult) {
-	if len(result.Rows) == 0 {
-		fmt.Println("No results found")
-		return
-	}
+// sourceJobsPauseTimeout bounds how long disabling a data source waits for
+// its running jobs to pause
+const sourceJobsPauseTimeout = 30 * time.Second
 
-	// Print headers
-	for i, col := range result.Columns {
-		if i > 0 {
-			fmt.Print("\t")
+// ReloadDataSources reinitializes the enabled data sources with the
+// configured storage path. Sources disabled since have their scheduled jobs
+// removed and their running jobs paused before they are closed; newly
+// enabled ones get their sync schedule.
+func (s *Shell) ReloadDataSources() {
+	for name, ds := range s.dataSources {
+		if config.AppConfig.SourceEnabled(name) {
+			continue
 		}
-		fmt.Print(col)
-	}
-	fmt.Println()
-
-	// Print separator
-	for i := range result.Columns {
-		if i > 0 {
-			fmt.Print("\t")
+		if s.jobManager != nil {
+			s.jobManager.UnscheduleSource(name)
+			// Running jobs write to the storage closed below
+			ctx, cancel := context.WithTimeout(s.ctx, sourceJobsPauseTimeout)
+			err := s.jobManager.PauseSourceJobs(ctx, name)
+			cancel()
+			if err != nil {
+				log.Logger.Warnf("Leaving data source %s open: %v", name, err)
+				delete(s.dataSources, name)
+				continue
+			}
 		}
-		fmt.Print("---")
-	}
-	fmt.Println()
-
-	// Print rows (limit to 20 for readability)
-	limit := len(result.Rows)
-	if limit > 20 {
-		limit = 20
-	}
-
-	for i := 0; i < limit; i++ {
-		row := result.Rows[i]
-		for j, val := range row {
-			if j > 0 {
-				fmt.Print("\t")
+		if closer, ok := ds.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				log.Logger.Warnf("Error closing data source %s: %v", name, err)
 			}
-			fmt.Print(val)
 		}
-		fmt.Println()
+		delete(s.dataSources, name)
 	}
 
-	if len(result.Rows) > 20 {
-		fmt.Printf("... and %d more rows\n", len(result.Rows)-20)
+	open := make(map[string]bool, len(s.dataSources))
+	for name := range s.dataSources {
+		open[name] = true
 	}
-
-	fmt.Printf("\nQuery completed in %v (%d rows)\n", result.Duration, result.Count)
-}
-
-// displayJobStatus shows detailed job status
-func (s *Shell) displayJobStatus(job *Job) {
-	fmt.Printf("Job %s:\n", job.ID)
-	fmt.Printf("  Description: %s\n", job.Description)
-	fmt.Printf("  Status: %s\n", job.Status)
-	fmt.Printf("  Started: %s\n", job.StartTime.Format("2006-01-02 15:04:05"))
-	if job.Status == "completed" && !job.EndTime.IsZero() {
-		fmt.Printf("  Completed: %s\n", job.EndTime.Format("2006-01-02 15:04:05"))
+	s.initializeDataSources()
+	if s.jobManager == nil {
+		return
 	}
-	if job.ErrorMessage != "" {
-		fmt.Printf("  Error: %s\n", job.ErrorMessage)
+	for name := range s.dataSources {
+		if !open[name] {
+			s.scheduleSourceSync(name)
+		}
 	}
 }
 
-// displayDownloadStatus shows data source download status
-func (s *Shell) displayDownloadStatus(sourceName string, status datasource.DownloadStatus) {
-	fmt.Printf("Status for %s:\n", sourceName)
-	fmt.Printf("  Active: %t\n", status.IsActive)
-	fmt.Printf("  Status: %s\n", status.Status)
-	fmt.Printf("  Progress: %.1f%%\n", status.Progress*100)
-	fmt.Printf("  Items: %d/%d\n", status.ItemsCached, status.ItemsTotal)
-	fmt.Printf("  Last Update: %s\n", status.LastUpdate.Format("2006-01-02 15:04:05"))
-	if status.ErrorMessage != "" {
-		fmt.Printf("  Error: %s\n", status.ErrorMessage)
+// Confirm asks a yes/no question on standard input; anything but y or yes
+// is a no
+func (s *Shell) Confirm(prompt string) (bool, error) {
+	fmt.Printf("%s [y/N] ", prompt)
+	if !s.reader.Scan() {
+		if err := s.reader.Err(); err != nil {
+			return false, fmt.Errorf("failed to read answer: %w", err)
+		}
+		return false, nil
 	}
+	return isYes(s.reader.Text()), nil
 }
 
-// displayJobSummary shows detailed job summary
-func (s *Shell) displayJobSummary(summary map[string]interface{}) {
-	fmt.Printf("Job %s:\n", summary["id"])
-	fmt.Printf("  Type: %s\n", summary["type"])
-	fmt.Printf("  Description: %s\n", summary["description"])
-	fmt.Printf("  State: %s\n", summary["state"])
-	fmt.Printf("  Progress: %.1f%%\n", summary["progress"])
-	fmt.Printf("  Message: %s\n", summary["message"])
-	fmt.Printf("  Duration: %s\n", summary["duration"])
-	fmt.Printf("  Active: %t\n", summary["active"])
+// ReadSecret reads a line from the terminal without echo, or a plain line
+// when input is piped
+func (s *Shell) ReadSecret(prompt string) (string, error) {
+	fmt.Print(prompt)
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		value, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		return string(value), nil
+	}
 
-	if endTime, exists := summary["end_time"]; exists {
-		fmt.Printf("  End Time: %s\n", endTime)
+	if !s.reader.Scan() {
+		if err := s.reader.Err(); err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		return "", fmt.Errorf("cancelled")
 	}
+	fmt.Println()
+	return s.reader.Text(), nil
+}
 
-	if errorMsg, exists := summary["error"]; exists {
-		fmt.Printf("  Error: %s\n", errorMsg)
+// isYes reports whether a confirmation answer is yes
+func isYes(answer string) bool {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
 	}
+	return false
 }
 
-// displayManagerStats shows job manager statistics
-func (s *Shell) displayManagerStats(summary map[string]interface{}) {
-	fmt.Println("Job Manager Statistics:")
-	fmt.Printf("  Total Jobs: %v\n", summary["total_jobs"])
-	fmt.Printf("  Active Jobs: %v\n", summary["active_jobs"])
-	fmt.Printf("  Queued Jobs: %v\n", summary["queued_jobs"])
-	fmt.Printf("  Running Jobs: %v\n", summary["running_jobs"])
-	fmt.Printf("  Completed Jobs: %v\n", summary["completed_jobs"])
-	fmt.Printf("  Failed Jobs: %v\n", summary["failed_jobs"])
-
-	if workerStats, exists := summary["worker_stats"].(map[string]interface{}); exists {
-		fmt.Println("  Worker Pool:")
-		fmt.Printf("    Total Workers: %v\n", workerStats["total_workers"])
-		fmt.Printf("    Active Workers: %v\n", workerStats["active_workers"])
-		fmt.Printf("    Idle Workers: %v\n", workerStats["idle_workers"])
-		fmt.Printf("    Queue Size: %v\n", workerStats["queue_size"])
+// closeHistory closes the command history database
+func (s *Shell) closeHistory() {
+	if s.history != nil {
+		if err := s.history.Close(); err != nil {
+			log.Logger.Warnf("Error closing command history: %v", err)
+		}
 	}
 }
 
@@ -475,11 +431,13 @@ func (s *Shell) displayManagerStats(summary map[string]interface{}) {
 func (s *Shell) shutdown() error {
 	fmt.Println("\nShutting down...")
 
-	// Stop job manager
+	// Stop job manager, letting running jobs finish or pause
 	if s.jobManager != nil {
-		s.jobManager.Stop()
+		s.jobManager.Shutdown(context.Background())
 	}
 
+	s.closeHistory()
+
 	// Close data sources
 	for name, ds := range s.dataSources {
 		if closer, ok := ds.(interface{ Close() error }); ok {
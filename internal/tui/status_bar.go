@@ -20,6 +20,8 @@ type StatusBarItem struct {
 	Current     int64
 	Status      string
 	ETA         time.Duration
+	Rate        float64 // Items per second
+	Warning     string  // Non-fatal problem such as a stall
 	Error       string
 	LastUpdate  time.Time
 }
@@ -35,6 +37,7 @@ type StatusBar struct {
 	updateChan chan struct{}
 	stopChan   chan struct{}
 	started    bool
+	suspended  bool
 }
 
 // NewStatusBar creates a new status bar
@@ -51,7 +54,11 @@ func NewStatusBar(terminal *TerminalManager) *StatusBar {
 
 // getMaxStatusItems determines max items based on terminal size
 func getMaxStatusItems(terminal *TerminalManager) int {
-	size := terminal.GetSize()
+	return maxStatusItemsFor(terminal.GetSize())
+}
+
+// maxStatusItemsFor determines max items for a terminal of the given size
+func maxStatusItemsFor(size TerminalSize) int {
 	// Reserve space for separator (1 line) + minimum content area (10 lines)
 	// Each status item takes 1 line
 	maxItems := (size.Height - 11) / 1
@@ -128,20 +135,7 @@ func (sb *StatusBar) UpdateProgress(id string, current, total int64, message str
 		}
 		item.Status = message
 		item.LastUpdate = time.Now()
-
-		// Improved ETA calculation
-		if item.Progress > 0 && item.Progress < 100 {
-			// Calculate based on time since job started (more accurate)
-			elapsed := time.Since(item.LastUpdate)
-			if elapsed > time.Second { // Only calculate ETA after reasonable time
-				progressRate := item.Progress / elapsed.Seconds()
-				if progressRate > 0 {
-					remainingProgress := 100 - item.Progress
-					etaSeconds := remainingProgress / progressRate
-					item.ETA = time.Duration(etaSeconds * float64(time.Second))
-				}
-			}
-		} else if item.Progress >= 100 {
+		if item.Progress >= 100 {
 			item.ETA = 0 // Completed
 		}
 
@@ -149,6 +143,34 @@ func (sb *StatusBar) UpdateProgress(id string, current, total int64, message str
 	}
 }
 
+// UpdateRate sets the throughput and ETA reported by the job manager and
+// clears any stall warning when the job is moving again
+func (sb *StatusBar) UpdateRate(id string, rate float64, eta time.Duration, stalled bool) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	if item, exists := sb.items[id]; exists {
+		item.Rate = rate
+		item.ETA = eta
+		if !stalled {
+			item.Warning = ""
+		}
+		sb.triggerUpdate()
+	}
+}
+
+// SetWarning shows a warning state for an item
+func (sb *StatusBar) SetWarning(id string, warning string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	if item, exists := sb.items[id]; exists {
+		item.Warning = warning
+		item.LastUpdate = time.Now()
+		sb.triggerUpdate()
+	}
+}
+
 // SetError sets an error status for an item
 func (sb *StatusBar) SetError(id string, err string) {
 	sb.mu.Lock()
@@ -193,6 +215,34 @@ func (sb *StatusBar) Hide() {
 	sb.hide()
 }
 
+// Resize recomputes the layout for a new terminal size and redraws the
+// status line on the new last row
+func (sb *StatusBar) Resize(size TerminalSize) {
+	sb.mu.Lock()
+	sb.maxItems = maxStatusItemsFor(size)
+	// The persistent status line keeps its single row without items
+	if sb.isVisible && len(sb.items) > 0 {
+		sb.show()
+	}
+	sb.mu.Unlock()
+	sb.triggerUpdate()
+}
+
+// Suspend stops drawing the status bar while a full-screen view is active
+func (sb *StatusBar) Suspend() {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.suspended = true
+}
+
+// Resume redraws the status bar after Suspend
+func (sb *StatusBar) Resume() {
+	sb.mu.Lock()
+	sb.suspended = false
+	sb.mu.Unlock()
+	sb.triggerUpdate()
+}
+
 // ShowPersistentStatusLine shows a persistent status line even when no jobs are active
 func (sb *StatusBar) ShowPersistentStatusLine() {
 	sb.mu.Lock()
@@ -280,8 +330,8 @@ func (sb *StatusBar) render() {
 	sb.mu.RLock()
 	defer sb.mu.RUnlock()
 
-	// Don't update if terminal doesn't support ANSI
-	if !sb.terminal.IsANSISupported() {
+	// Don't update if terminal doesn't support ANSI or a full-screen view is active
+	if !sb.terminal.IsANSISupported() || sb.suspended {
 		return
 	}
 
@@ -302,6 +352,21 @@ func (sb *StatusBar) render() {
 	fmt.Print(sb.terminal.ClearCurrentLine())
 
 	// Draw the most important/recent status item on the single status line
+	if mostRecentItem := sb.shownItem(); mostRecentItem != nil {
+		statusLine := sb.formatStatusLine(mostRecentItem, size.Width)
+		fmt.Print(statusLine)
+	}
+
+	// Restore cursor position
+	fmt.Print(sb.terminal.RestoreCursor())
+
+	// Ensure output is flushed
+	os.Stdout.Sync()
+}
+
+// shownItem returns the item the status line shows, the most recently
+// updated one, or nil when there are none; sb.mu must be held
+func (sb *StatusBar) shownItem() *StatusBarItem {
 	var mostRecentItem *StatusBarItem
 	var latestTime time.Time
 	for _, item := range sb.items {
@@ -310,17 +375,22 @@ func (sb *StatusBar) render() {
 			mostRecentItem = item
 		}
 	}
+	return mostRecentItem
+}
 
-	if mostRecentItem != nil {
-		statusLine := sb.formatStatusLine(mostRecentItem, size.Width)
-		fmt.Print(statusLine)
-	}
-
-	// Restore cursor position
-	fmt.Print(sb.terminal.RestoreCursor())
+// ItemAt returns the ID of the item shown on a screen row, 1-based, or ""
+// when the row is not the status line or it shows no item
+func (sb *StatusBar) ItemAt(row int) string {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
 
-	// Ensure output is flushed
-	os.Stdout.Sync()
+	if !sb.isVisible || sb.suspended || row != sb.terminal.GetSize().Height {
+		return ""
+	}
+	if item := sb.shownItem(); item != nil {
+		return item.ID
+	}
+	return ""
 }
 
 // formatStatusLine formats a single status line
@@ -333,10 +403,13 @@ func (sb *StatusBar) formatStatusLine(item *StatusBarItem, width int) string {
 	// Create progress bar
 	progressBar := sb.createProgressBar(item.Progress, 20)
 
-	// Format ETA
+	// Format rate and ETA
 	etaStr := ""
+	if item.Rate > 0 && item.Progress < 100 {
+		etaStr = fmt.Sprintf(" %s/s", formatRate(item.Rate))
+	}
 	if item.ETA > 0 && item.Progress < 100 {
-		etaStr = fmt.Sprintf(" ETA: %s", sb.formatDuration(item.ETA))
+		etaStr += fmt.Sprintf(" ETA: %s", sb.formatDuration(item.ETA))
 	}
 
 	// Choose appropriate icon based on job type
@@ -353,9 +426,18 @@ func (sb *StatusBar) formatStatusLine(item *StatusBarItem, width int) string {
 		displayID = displayID[:17] + "..."
 	}
 
-	// Create status line
+	// Stalled jobs keep their progress but are shown as a warning
+	color := FgGreen
+	if item.Warning != "" {
+		color = FgYellow
+		icon = "⚠️"
+		etaStr = " " + item.Warning
+	}
+
+	// Create status line; completed jobs with a description show it
+	// instead of their progress
 	statusLine := fmt.Sprintf("%s%s %s: %s%s %.1f%% (%d/%d)%s%s",
-		FgGreen,
+		color,
 		icon,
 		displayID,
 		FgWhite,
@@ -365,6 +447,9 @@ func (sb *StatusBar) formatStatusLine(item *StatusBarItem, width int) string {
 		item.Total,
 		etaStr,
 		Reset)
+	if item.Status == "Completed" && item.Description != "" {
+		statusLine = fmt.Sprintf("%s%s %s: %s%s%s", color, icon, displayID, FgWhite, item.Description, Reset)
+	}
 
 	// Truncate if too long
 	if len(statusLine) > width {
@@ -391,6 +476,17 @@ func (sb *StatusBar) createProgressBar(progress float64, width int) string {
 	return fmt.Sprintf("[%s]", bar)
 }
 
+// formatRate formats an items/sec rate compactly
+func formatRate(rate float64) string {
+	if rate >= 1000 {
+		return fmt.Sprintf("%.1fk", rate/1000)
+	}
+	if rate >= 10 {
+		return fmt.Sprintf("%.0f", rate)
+	}
+	return fmt.Sprintf("%.1f", rate)
+}
+
 // formatDuration formats a duration for display
 func (sb *StatusBar) formatDuration(d time.Duration) string {
 	if d < time.Minute {
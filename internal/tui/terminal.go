@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 
 	"golang.org/x/term"
 )
@@ -61,10 +62,13 @@ const (
 
 // TerminalManager handles terminal operations and state
 type TerminalManager struct {
+	mu               sync.RWMutex
 	size             TerminalSize
 	statusBarHeight  int
 	isANSISupported  bool
 	originalTermMode uint32
+	resizeCallbacks  []ResizeCallback
+	stopResize       func() // Stops the resize watcher; nil when not watching
 }
 
 // NewTerminalManager creates a new terminal manager
@@ -79,19 +83,20 @@ func NewTerminalManager() *TerminalManager {
 
 // GetSize returns the current terminal size
 func (tm *TerminalManager) GetSize() TerminalSize {
-	tm.updateSize()
-	return tm.size
+	return tm.updateSize()
 }
 
-// updateSize updates the cached terminal size
-func (tm *TerminalManager) updateSize() {
-	width, height, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		// Fallback to environment variables or defaults
-		tm.size = tm.getSizeFromEnv()
-		return
+// updateSize updates the cached terminal size and returns it
+func (tm *TerminalManager) updateSize() TerminalSize {
+	size := tm.getSizeFromEnv()
+	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		size = TerminalSize{Width: width, Height: height}
 	}
-	tm.size = TerminalSize{Width: width, Height: height}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.size = size
+	return size
 }
 
 // getSizeFromEnv gets terminal size from environment variables
@@ -208,26 +213,36 @@ func (tm *TerminalManager) RestoreCursor() string {
 
 // GetAvailableHeight returns height available for content (excluding status bar)
 func (tm *TerminalManager) GetAvailableHeight() int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
 	return tm.size.Height - tm.statusBarHeight
 }
 
 // SetStatusBarHeight sets the number of lines reserved for status bar
 func (tm *TerminalManager) SetStatusBarHeight(height int) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
 	tm.statusBarHeight = height
 }
 
 // GetStatusBarHeight returns the current status bar height
 func (tm *TerminalManager) GetStatusBarHeight() int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
 	return tm.statusBarHeight
 }
 
 // GetStatusBarStartRow returns the first row of the status bar (1-based)
 func (tm *TerminalManager) GetStatusBarStartRow() int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
 	return tm.size.Height - tm.statusBarHeight + 1
 }
 
 // GetStatusBarRow returns the exact row for the status bar (always last line)
 func (tm *TerminalManager) GetStatusBarRow() int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
 	return tm.size.Height
 }
 
@@ -238,8 +253,8 @@ func (tm *TerminalManager) SetupScrollingRegion() {
 	}
 
 	// Set scrolling region from line 1 to (height-1), preserving last line
-	tm.updateSize()
-	fmt.Printf("\033[1;%dr", tm.size.Height-1)
+	size := tm.updateSize()
+	fmt.Printf("\033[1;%dr", size.Height-1)
 }
 
 // ResetScrollingRegion resets the scrolling region to full screen
@@ -272,5 +287,69 @@ func (tm *TerminalManager) DisableRawMode() error {
 // ResizeCallback represents a function to call when terminal is resized
 type ResizeCallback func(newSize TerminalSize)
 
-// TODO: Add terminal resize signal handling in future iterations
-// This would require signal handling for SIGWINCH on Unix systems
+// OnResize registers a callback run with the new size whenever the terminal
+// is resized while WatchResize is active
+func (tm *TerminalManager) OnResize(callback ResizeCallback) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.resizeCallbacks = append(tm.resizeCallbacks, callback)
+}
+
+// WatchResize starts watching for terminal resizes, SIGWINCH on Unix
+// systems, and runs the resize callbacks when the size changes
+func (tm *TerminalManager) WatchResize() {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.stopResize != nil {
+		return
+	}
+
+	events, stop := resizeEvents()
+	done := make(chan struct{})
+	tm.stopResize = func() {
+		stop()
+		close(done)
+	}
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-events:
+				tm.handleResize()
+			}
+		}
+	}()
+}
+
+// StopWatchingResize stops the watcher started by WatchResize
+func (tm *TerminalManager) StopWatchingResize() {
+	tm.mu.Lock()
+	stop := tm.stopResize
+	tm.stopResize = nil
+	tm.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// handleResize refreshes the cached size and runs the callbacks if it
+// changed
+func (tm *TerminalManager) handleResize() {
+	tm.mu.RLock()
+	previous := tm.size
+	tm.mu.RUnlock()
+
+	size := tm.updateSize()
+	if size == previous {
+		return
+	}
+
+	tm.mu.RLock()
+	callbacks := append([]ResizeCallback(nil), tm.resizeCallbacks...)
+	tm.mu.RUnlock()
+	for _, callback := range callbacks {
+		callback(size)
+	}
+}
@@ -4,6 +4,8 @@
 package tui
 
 import (
+	"os"
+	"os/signal"
 	"syscall"
 	"unsafe"
 )
@@ -29,3 +31,30 @@ func getTerminalSizeUnix() (int, int, error) {
 	}
 	return int(ws.Col), int(ws.Row), nil
 }
+
+// resizeEvents delivers an event for each SIGWINCH, sent when the terminal
+// is resized, until stop is called
+func resizeEvents() (<-chan struct{}, func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+
+	events := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-signals:
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return events, func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -2,3 +2,32 @@
 // +build windows
 
 package tui
+
+import "time"
+
+// resizePollInterval is how often the console size is checked, as Windows
+// has no resize signal
+const resizePollInterval = 500 * time.Millisecond
+
+// resizeEvents delivers an event every poll interval until stop is called;
+// the watcher only runs callbacks when the size changed
+func resizeEvents() (<-chan struct{}, func()) {
+	events := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(resizePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return events, func() { close(done) }
+}
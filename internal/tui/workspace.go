@@ -4,303 +4,370 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/brainless/PubDataHub/internal/command"
 	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/query"
+	"github.com/brainless/PubDataHub/internal/trash"
+	"github.com/brainless/PubDataHub/internal/workspace"
 )
 
-// WorkspaceManager manages multiple workspaces and sessions
+// WorkspaceManager tracks the active workspace of the shell; workspaces are
+// stored by a workspace.Service shared with the API server
 type WorkspaceManager struct {
-	mu           sync.RWMutex
-	workspaces   map[string]*Workspace
-	currentWS    string
-	storagePath  string
-	autosave     bool
-	autosaveFreq time.Duration
-	stopChan     chan struct{}
-}
-
-// Workspace represents a saved workspace containing queries, settings, and state
-type Workspace struct {
-	Name         string                 `json:"name"`
-	Description  string                 `json:"description"`
-	Created      time.Time              `json:"created"`
-	LastUsed     time.Time              `json:"last_used"`
-	SavedQueries map[string]SavedQuery  `json:"saved_queries"`
-	JobTemplates map[string]JobTemplate `json:"job_templates"`
-	Settings     WorkspaceSettings      `json:"settings"`
-	Sessions     map[string]SessionData `json:"sessions"`
-	Tags         []string               `json:"tags"`
-	UsageCount   int                    `json:"usage_count"`
-}
-
-// SavedQuery represents a saved query in a workspace
-type SavedQuery struct {
-	Name        string    `json:"name"`
-	Query       string    `json:"query"`
-	DataSource  string    `json:"data_source"`
-	Description string    `json:"description"`
-	Tags        []string  `json:"tags"`
-	Created     time.Time `json:"created"`
-	LastUsed    time.Time `json:"last_used"`
-	UsageCount  int       `json:"usage_count"`
-	IsFavorite  bool      `json:"is_favorite"`
-}
-
-// JobTemplate represents a saved job configuration
-type JobTemplate struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description"`
-	JobType     string                 `json:"job_type"`
-	Config      map[string]interface{} `json:"config"`
-	Schedule    string                 `json:"schedule,omitempty"`
-	Tags        []string               `json:"tags"`
-	Created     time.Time              `json:"created"`
-	UsageCount  int                    `json:"usage_count"`
-}
-
-// SessionData represents saved session state
-type SessionData struct {
-	DataSource    string            `json:"data_source"`
-	QueryHistory  []string          `json:"query_history"`
-	Settings      map[string]string `json:"settings"`
-	LastQuery     string            `json:"last_query"`
-	LastTimestamp time.Time         `json:"last_timestamp"`
-}
-
-// WorkspaceSettings contains workspace-specific configuration
-type WorkspaceSettings struct {
-	DefaultDataSource string            `json:"default_data_source"`
-	AutoComplete      bool              `json:"auto_complete"`
-	ShowTiming        bool              `json:"show_timing"`
-	PaginationSize    int               `json:"pagination_size"`
-	OutputFormat      string            `json:"output_format"`
-	CustomVariables   map[string]string `json:"custom_variables"`
-	Theme             string            `json:"theme"`
+	mu        sync.RWMutex
+	service   *workspace.Service
+	currentWS string
 }
 
-// NewWorkspaceManager creates a new workspace manager
-func NewWorkspaceManager(storagePath string) (*WorkspaceManager, error) {
-	wm := &WorkspaceManager{
-		workspaces:   make(map[string]*Workspace),
-		storagePath:  storagePath,
-		autosave:     true,
-		autosaveFreq: 5 * time.Minute,
-		stopChan:     make(chan struct{}),
-	}
-
-	// Create storage directory if it doesn't exist
-	if err := os.MkdirAll(storagePath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create workspace directory: %w", err)
-	}
-
-	// Load existing workspaces
-	if err := wm.loadWorkspaces(); err != nil {
-		log.Logger.Warnf("Failed to load workspaces: %v", err)
+// outputSettings returns the settings that control how query results are displayed
+func outputSettings(ws workspace.Settings) command.OutputSettings {
+	return command.OutputSettings{
+		Format:     query.OutputFormat(ws.OutputFormat),
+		PageSize:   ws.PaginationSize,
+		ShowTiming: ws.ShowTiming,
+		Redactions: ws.Redactions,
+		Values: query.ValueFormat{
+			NumberLocale: ws.NumberLocale,
+			DateFormat:   ws.DateFormat,
+			RelativeTime: ws.RelativeTime,
+		},
 	}
+}
 
-	// Start autosave routine if enabled
-	if wm.autosave {
-		go wm.autosaveRoutine()
+// NewWorkspaceManager creates a new workspace manager
+func NewWorkspaceManager(storagePath string) (*WorkspaceManager, error) {
+	service, err := workspace.NewService(storagePath)
+	if err != nil {
+		return nil, err
 	}
+	return &WorkspaceManager{service: service}, nil
+}
 
-	return wm, nil
+// Service returns the service storing the workspaces
+func (wm *WorkspaceManager) Service() *workspace.Service {
+	return wm.service
 }
 
 // CreateWorkspace creates a new workspace
 func (wm *WorkspaceManager) CreateWorkspace(name, description string) error {
-	wm.mu.Lock()
-	defer wm.mu.Unlock()
-
-	if name == "" {
-		return fmt.Errorf("workspace name cannot be empty")
-	}
-
-	if _, exists := wm.workspaces[name]; exists {
-		return fmt.Errorf("workspace '%s' already exists", name)
-	}
-
-	workspace := &Workspace{
-		Name:         name,
-		Description:  description,
-		Created:      time.Now(),
-		LastUsed:     time.Now(),
-		SavedQueries: make(map[string]SavedQuery),
-		JobTemplates: make(map[string]JobTemplate),
-		Sessions:     make(map[string]SessionData),
-		Tags:         make([]string, 0),
-		Settings: WorkspaceSettings{
-			DefaultDataSource: "hackernews",
-			AutoComplete:      true,
-			ShowTiming:        true,
-			PaginationSize:    20,
-			OutputFormat:      "table",
-			CustomVariables:   make(map[string]string),
-			Theme:             "default",
-		},
-		UsageCount: 0,
+	if _, err := wm.service.Create(name, description); err != nil {
+		return err
 	}
-
-	wm.workspaces[name] = workspace
-
-	// Save immediately
-	if err := wm.saveWorkspace(workspace); err != nil {
-		return fmt.Errorf("failed to save workspace: %w", err)
-	}
-
 	log.Logger.Infof("Created workspace '%s'", name)
 	return nil
 }
 
 // SwitchWorkspace changes the current active workspace
 func (wm *WorkspaceManager) SwitchWorkspace(name string) error {
-	wm.mu.Lock()
-	defer wm.mu.Unlock()
-
-	workspace, exists := wm.workspaces[name]
-	if !exists {
-		return fmt.Errorf("workspace '%s' not found", name)
+	_, err := wm.service.Update(name, func(ws *workspace.Workspace) error {
+		ws.LastUsed = time.Now()
+		ws.UsageCount++
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	wm.mu.Lock()
 	wm.currentWS = name
-	workspace.LastUsed = time.Now()
-	workspace.UsageCount++
+	wm.mu.Unlock()
 
 	log.Logger.Infof("Switched to workspace '%s'", name)
 	return nil
 }
 
-// GetCurrentWorkspace returns the currently active workspace
-func (wm *WorkspaceManager) GetCurrentWorkspace() *Workspace {
-	wm.mu.RLock()
-	defer wm.mu.RUnlock()
+// SetEncrypted encrypts or decrypts the file of a workspace
+func (wm *WorkspaceManager) SetEncrypted(name string, encrypted bool) error {
+	if _, err := wm.service.SetEncrypted(name, encrypted); err != nil {
+		return err
+	}
+	if encrypted {
+		log.Logger.Infof("Encrypted workspace '%s'", name)
+	} else {
+		log.Logger.Infof("Decrypted workspace '%s'", name)
+	}
+	return nil
+}
 
-	if wm.currentWS == "" {
+// GetCurrentWorkspace returns the currently active workspace, as last saved
+func (wm *WorkspaceManager) GetCurrentWorkspace() *workspace.Workspace {
+	name := wm.currentName()
+	if name == "" {
 		return nil
 	}
 
-	return wm.workspaces[wm.currentWS]
+	ws, err := wm.service.Get(name)
+	if err != nil {
+		log.Logger.Warnf("Failed to load workspace %s: %v", name, err)
+		return nil
+	}
+	return ws
 }
 
-// ListWorkspaces returns all available workspaces
-func (wm *WorkspaceManager) ListWorkspaces() []*Workspace {
-	wm.mu.RLock()
-	defer wm.mu.RUnlock()
-
-	workspaces := make([]*Workspace, 0, len(wm.workspaces))
-	for _, ws := range wm.workspaces {
-		workspaces = append(workspaces, ws)
+// CurrentSettings returns the settings of the active workspace, or the
+// defaults when no workspace is active
+func (wm *WorkspaceManager) CurrentSettings() workspace.Settings {
+	ws := wm.GetCurrentWorkspace()
+	if ws == nil {
+		return workspace.DefaultSettings()
 	}
+	return ws.Settings
+}
+
+// UpdateSetting changes a setting of the active workspace and saves it
+func (wm *WorkspaceManager) UpdateSetting(key, value string) error {
+	return wm.updateCurrent(func(ws *workspace.Workspace) error {
+		return ws.Settings.Set(key, value)
+	})
+}
 
-	// Sort by last used (most recent first)
-	sort.Slice(workspaces, func(i, j int) bool {
-		return workspaces[i].LastUsed.After(workspaces[j].LastUsed)
+// SetRedactionRule adds a rule to a redaction rule set of the active
+// workspace and saves it
+func (wm *WorkspaceManager) SetRedactionRule(name string, rule query.RedactionRule) error {
+	return wm.updateCurrent(func(ws *workspace.Workspace) error {
+		return ws.Settings.SetRedactionRule(name, rule)
 	})
+}
 
-	return workspaces
+// RemoveRedaction removes a rule, or a whole rule set when column is empty,
+// from the active workspace and saves it
+func (wm *WorkspaceManager) RemoveRedaction(name, column string) error {
+	return wm.updateCurrent(func(ws *workspace.Workspace) error {
+		return ws.Settings.RemoveRedaction(name, column)
+	})
 }
 
-// DeleteWorkspace removes a workspace
-func (wm *WorkspaceManager) DeleteWorkspace(name string) error {
-	wm.mu.Lock()
-	defer wm.mu.Unlock()
+// shellSessionKey is the session of the interactive shell in a workspace
+const shellSessionKey = "shell"
 
-	if _, exists := wm.workspaces[name]; !exists {
-		return fmt.Errorf("workspace '%s' not found", name)
+// SessionDataSource returns the data source chosen with use in the active
+// workspace, or "" when none is
+func (wm *WorkspaceManager) SessionDataSource() string {
+	ws := wm.GetCurrentWorkspace()
+	if ws == nil {
+		return ""
 	}
+	return ws.Sessions[shellSessionKey].DataSource
+}
 
-	// Remove from memory
-	delete(wm.workspaces, name)
+// SetSessionDataSource records the data source chosen with use in the
+// active workspace and saves it
+func (wm *WorkspaceManager) SetSessionDataSource(source string) error {
+	if wm.currentName() == "" {
+		return nil
+	}
+	return wm.updateCurrent(func(ws *workspace.Workspace) error {
+		if ws.Sessions == nil {
+			ws.Sessions = make(map[string]workspace.SessionData)
+		}
+		session := ws.Sessions[shellSessionKey]
+		session.DataSource = source
+		session.LastTimestamp = time.Now()
+		ws.Sessions[shellSessionKey] = session
+		return nil
+	})
+}
+
+// ListWorkspaces returns all available workspaces, most recently used first
+func (wm *WorkspaceManager) ListWorkspaces() []*workspace.Workspace {
+	workspaces, err := wm.service.List()
+	if err != nil {
+		log.Logger.Warnf("Failed to list workspaces: %v", err)
+	}
+	return workspaces
+}
 
-	// Remove file
-	filename := filepath.Join(wm.storagePath, name+".json")
-	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete workspace file: %w", err)
+// SetTrash makes DeleteWorkspace keep deleted workspaces in bin so they can
+// be restored with undo
+func (wm *WorkspaceManager) SetTrash(bin *trash.Trash) {
+	wm.service.SetTrash(bin)
+}
+
+// DeleteWorkspace removes a workspace, moving it to the trash when one is
+// set. It returns the ID of the trash entry, or "" when the workspace is gone
+// for good.
+func (wm *WorkspaceManager) DeleteWorkspace(name string) (string, error) {
+	undoID, err := wm.service.Delete(name)
+	if err != nil {
+		return "", err
 	}
 
 	// Switch away if this was the current workspace
+	wm.mu.Lock()
 	if wm.currentWS == name {
 		wm.currentWS = ""
 	}
+	wm.mu.Unlock()
 
 	log.Logger.Infof("Deleted workspace '%s'", name)
-	return nil
+	return undoID, nil
 }
 
 // SaveQuery saves a query to the current workspace
 func (wm *WorkspaceManager) SaveQuery(name, query, dataSource, description string, tags []string) error {
-	wm.mu.Lock()
-	defer wm.mu.Unlock()
-
-	workspace := wm.getCurrentWorkspaceUnsafe()
-	if workspace == nil {
+	current := wm.currentName()
+	if current == "" {
 		return fmt.Errorf("no active workspace")
 	}
-
-	savedQuery := SavedQuery{
+	_, err := wm.service.SaveQuery(current, workspace.SavedQuery{
 		Name:        name,
 		Query:       query,
 		DataSource:  dataSource,
 		Description: description,
 		Tags:        tags,
-		Created:     time.Now(),
-		LastUsed:    time.Now(),
-		UsageCount:  0,
-		IsFavorite:  false,
+	})
+	return err
+}
+
+// GetSavedQuery retrieves a saved query from the current workspace and
+// records that it has been used
+func (wm *WorkspaceManager) GetSavedQuery(name string) (workspace.SavedQuery, error) {
+	current := wm.currentName()
+	if current == "" {
+		return workspace.SavedQuery{}, fmt.Errorf("no active workspace")
 	}
+	return wm.service.UseQuery(current, name)
+}
 
-	workspace.SavedQueries[name] = savedQuery
-	return nil
+// DeleteQuery removes a saved query from the current workspace
+func (wm *WorkspaceManager) DeleteQuery(name string) error {
+	current := wm.currentName()
+	if current == "" {
+		return fmt.Errorf("no active workspace")
+	}
+	return wm.service.DeleteQuery(current, name)
 }
 
-// GetSavedQuery retrieves a saved query from the current workspace
-func (wm *WorkspaceManager) GetSavedQuery(name string) (SavedQuery, error) {
-	wm.mu.RLock()
-	defer wm.mu.RUnlock()
+// SaveJobTemplate saves a job template to the current workspace
+func (wm *WorkspaceManager) SaveJobTemplate(name, jobType, schedule, description string, config map[string]interface{}) error {
+	current := wm.currentName()
+	if current == "" {
+		return fmt.Errorf("no active workspace")
+	}
+	_, err := wm.service.SaveJobTemplate(current, workspace.JobTemplate{
+		Name:        name,
+		Description: description,
+		JobType:     jobType,
+		Config:      config,
+		Schedule:    schedule,
+	})
+	return err
+}
 
-	workspace := wm.getCurrentWorkspaceUnsafe()
-	if workspace == nil {
-		return SavedQuery{}, fmt.Errorf("no active workspace")
+// RecordJobTemplateUse records that a job template of the current
+// workspace has been used
+func (wm *WorkspaceManager) RecordJobTemplateUse(name string) error {
+	current := wm.currentName()
+	if current == "" {
+		return fmt.Errorf("no active workspace")
+	}
+	return wm.service.RecordJobTemplateUse(current, name)
+}
+
+// DeleteJobTemplate removes a job template from the current workspace
+func (wm *WorkspaceManager) DeleteJobTemplate(name string) error {
+	current := wm.currentName()
+	if current == "" {
+		return fmt.Errorf("no active workspace")
+	}
+	return wm.service.DeleteJobTemplate(current, name)
+}
+
+// CreateNotebook adds an empty notebook to the current workspace
+func (wm *WorkspaceManager) CreateNotebook(name, description string) error {
+	if name == "" {
+		return fmt.Errorf("notebook name cannot be empty")
+	}
+	return wm.updateCurrent(func(ws *workspace.Workspace) error {
+		if _, exists := ws.Notebooks[name]; exists {
+			return fmt.Errorf("notebook '%s' already exists", name)
+		}
+		if ws.Notebooks == nil {
+			ws.Notebooks = make(map[string]*query.Notebook)
+		}
+		ws.Notebooks[name] = query.NewNotebook(name, description)
+		return nil
+	})
+}
+
+// ListNotebooks returns the notebooks of the current workspace sorted by name
+func (wm *WorkspaceManager) ListNotebooks() ([]*query.Notebook, error) {
+	ws := wm.GetCurrentWorkspace()
+	if ws == nil {
+		return nil, fmt.Errorf("no active workspace")
+	}
+
+	notebooks := make([]*query.Notebook, 0, len(ws.Notebooks))
+	for _, notebook := range ws.Notebooks {
+		notebooks = append(notebooks, notebook)
+	}
+	sort.Slice(notebooks, func(i, j int) bool {
+		return notebooks[i].Name < notebooks[j].Name
+	})
+	return notebooks, nil
+}
+
+// GetNotebook returns a notebook of the current workspace; changes are kept
+// with SaveNotebook, so running cells does not hold the workspace lock
+func (wm *WorkspaceManager) GetNotebook(name string) (*query.Notebook, error) {
+	ws := wm.GetCurrentWorkspace()
+	if ws == nil {
+		return nil, fmt.Errorf("no active workspace")
 	}
 
-	query, exists := workspace.SavedQueries[name]
+	notebook, exists := ws.Notebooks[name]
 	if !exists {
-		return SavedQuery{}, fmt.Errorf("query '%s' not found", name)
+		return nil, fmt.Errorf("notebook '%s' not found", name)
 	}
+	return notebook, nil
+}
 
-	// Update usage stats
-	go func() {
-		wm.mu.Lock()
-		defer wm.mu.Unlock()
-		q := workspace.SavedQueries[name]
-		q.LastUsed = time.Now()
-		q.UsageCount++
-		workspace.SavedQueries[name] = q
-	}()
+// SaveNotebook stores a notebook in the current workspace and saves it
+func (wm *WorkspaceManager) SaveNotebook(notebook *query.Notebook) error {
+	return wm.updateCurrent(func(ws *workspace.Workspace) error {
+		if ws.Notebooks == nil {
+			ws.Notebooks = make(map[string]*query.Notebook)
+		}
+		ws.Notebooks[notebook.Name] = notebook
+		return nil
+	})
+}
 
-	return query, nil
+// DeleteNotebook removes a notebook from the current workspace
+func (wm *WorkspaceManager) DeleteNotebook(name string) error {
+	return wm.updateCurrent(func(ws *workspace.Workspace) error {
+		if _, exists := ws.Notebooks[name]; !exists {
+			return fmt.Errorf("notebook '%s' not found", name)
+		}
+		delete(ws.Notebooks, name)
+		return nil
+	})
 }
 
 // ExportWorkspace exports a workspace to a file
 func (wm *WorkspaceManager) ExportWorkspace(name, filename string) error {
-	wm.mu.RLock()
-	workspace, exists := wm.workspaces[name]
-	wm.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("workspace '%s' not found", name)
+	ws, err := wm.service.Get(name)
+	if err != nil {
+		return err
 	}
 
-	data, err := json.MarshalIndent(workspace, "", "  ")
+	data, err := json.MarshalIndent(ws, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal workspace: %w", err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	perm := os.FileMode(0644)
+	if ws.Encrypted {
+		// The export is plain JSON, so at least keep it from other users
+		perm = 0600
+		fmt.Printf("Note: '%s' is encrypted, but the export is not\n", name)
+	}
+	if err := os.WriteFile(filename, data, perm); err != nil {
 		return fmt.Errorf("failed to write workspace file: %w", err)
 	}
 
@@ -315,43 +382,31 @@ func (wm *WorkspaceManager) ImportWorkspace(filename string) error {
 		return fmt.Errorf("failed to read workspace file: %w", err)
 	}
 
-	var workspace Workspace
-	if err := json.Unmarshal(data, &workspace); err != nil {
+	var ws workspace.Workspace
+	if err := json.Unmarshal(data, &ws); err != nil {
 		return fmt.Errorf("failed to parse workspace file: %w", err)
 	}
 
-	wm.mu.Lock()
-	defer wm.mu.Unlock()
-
-	// Check if workspace already exists
-	if _, exists := wm.workspaces[workspace.Name]; exists {
-		return fmt.Errorf("workspace '%s' already exists", workspace.Name)
-	}
-
-	wm.workspaces[workspace.Name] = &workspace
-
-	// Save the imported workspace
-	if err := wm.saveWorkspace(&workspace); err != nil {
-		return fmt.Errorf("failed to save imported workspace: %w", err)
+	if err := wm.service.Import(&ws); err != nil {
+		return err
 	}
 
-	log.Logger.Infof("Imported workspace '%s' from %s", workspace.Name, filename)
+	log.Logger.Infof("Imported workspace '%s' from %s", ws.Name, filename)
 	return nil
 }
 
 // GetWorkspaceStats returns statistics for all workspaces
 func (wm *WorkspaceManager) GetWorkspaceStats() WorkspaceStats {
-	wm.mu.RLock()
-	defer wm.mu.RUnlock()
+	workspaces := wm.ListWorkspaces()
 
 	stats := WorkspaceStats{
-		TotalWorkspaces: len(wm.workspaces),
+		TotalWorkspaces: len(workspaces),
 		TotalQueries:    0,
 		TotalTemplates:  0,
 		TotalUsage:      0,
 	}
 
-	for _, ws := range wm.workspaces {
+	for _, ws := range workspaces {
 		stats.TotalQueries += len(ws.SavedQueries)
 		stats.TotalTemplates += len(ws.JobTemplates)
 		stats.TotalUsage += ws.UsageCount
@@ -362,9 +417,6 @@ func (wm *WorkspaceManager) GetWorkspaceStats() WorkspaceStats {
 
 // Search searches for workspaces, queries, or templates by name or tags
 func (wm *WorkspaceManager) Search(query string) WorkspaceSearchResults {
-	wm.mu.RLock()
-	defer wm.mu.RUnlock()
-
 	results := WorkspaceSearchResults{
 		Workspaces: make([]string, 0),
 		Queries:    make([]QuerySearchResult, 0),
@@ -373,7 +425,7 @@ func (wm *WorkspaceManager) Search(query string) WorkspaceSearchResults {
 
 	queryLower := strings.ToLower(query)
 
-	for _, ws := range wm.workspaces {
+	for _, ws := range wm.ListWorkspaces() {
 		// Search workspace names and descriptions
 		if strings.Contains(strings.ToLower(ws.Name), queryLower) ||
 			strings.Contains(strings.ToLower(ws.Description), queryLower) {
@@ -413,11 +465,21 @@ func (wm *WorkspaceManager) Search(query string) WorkspaceSearchResults {
 
 // Internal helper methods
 
-func (wm *WorkspaceManager) getCurrentWorkspaceUnsafe() *Workspace {
-	if wm.currentWS == "" {
-		return nil
+// currentName returns the name of the active workspace, or ""
+func (wm *WorkspaceManager) currentName() string {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	return wm.currentWS
+}
+
+// updateCurrent applies change to the active workspace and saves it
+func (wm *WorkspaceManager) updateCurrent(change func(*workspace.Workspace) error) error {
+	current := wm.currentName()
+	if current == "" {
+		return fmt.Errorf("no active workspace")
 	}
-	return wm.workspaces[wm.currentWS]
+	_, err := wm.service.Update(current, change)
+	return err
 }
 
 func (wm *WorkspaceManager) containsTag(tags []string, search string) bool {
@@ -429,79 +491,6 @@ func (wm *WorkspaceManager) containsTag(tags []string, search string) bool {
 	return false
 }
 
-func (wm *WorkspaceManager) loadWorkspaces() error {
-	files, err := filepath.Glob(filepath.Join(wm.storagePath, "*.json"))
-	if err != nil {
-		return fmt.Errorf("failed to list workspace files: %w", err)
-	}
-
-	for _, file := range files {
-		data, err := os.ReadFile(file)
-		if err != nil {
-			log.Logger.Warnf("Failed to read workspace file %s: %v", file, err)
-			continue
-		}
-
-		var workspace Workspace
-		if err := json.Unmarshal(data, &workspace); err != nil {
-			log.Logger.Warnf("Failed to parse workspace file %s: %v", file, err)
-			continue
-		}
-
-		wm.workspaces[workspace.Name] = &workspace
-	}
-
-	log.Logger.Infof("Loaded %d workspaces", len(wm.workspaces))
-	return nil
-}
-
-func (wm *WorkspaceManager) saveWorkspace(workspace *Workspace) error {
-	filename := filepath.Join(wm.storagePath, workspace.Name+".json")
-	data, err := json.MarshalIndent(workspace, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal workspace: %w", err)
-	}
-
-	return os.WriteFile(filename, data, 0644)
-}
-
-func (wm *WorkspaceManager) autosaveRoutine() {
-	ticker := time.NewTicker(wm.autosaveFreq)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			wm.mu.RLock()
-			for _, workspace := range wm.workspaces {
-				if err := wm.saveWorkspace(workspace); err != nil {
-					log.Logger.Warnf("Failed to autosave workspace %s: %v", workspace.Name, err)
-				}
-			}
-			wm.mu.RUnlock()
-		case <-wm.stopChan:
-			return
-		}
-	}
-}
-
-// Stop stops the workspace manager and saves all workspaces
-func (wm *WorkspaceManager) Stop() error {
-	close(wm.stopChan)
-
-	wm.mu.Lock()
-	defer wm.mu.Unlock()
-
-	// Save all workspaces before stopping
-	for _, workspace := range wm.workspaces {
-		if err := wm.saveWorkspace(workspace); err != nil {
-			log.Logger.Warnf("Failed to save workspace %s during shutdown: %v", workspace.Name, err)
-		}
-	}
-
-	return nil
-}
-
 // Supporting types
 
 type WorkspaceStats struct {
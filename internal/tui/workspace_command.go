@@ -1,9 +1,19 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/brainless/PubDataHub/internal/jobs"
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/query"
+	"github.com/brainless/PubDataHub/internal/secrets"
+	"github.com/brainless/PubDataHub/internal/workspace"
 )
 
 // WorkspaceCommand handles workspace-related operations
@@ -42,7 +52,7 @@ func (wc *WorkspaceCommand) Execute(ctx *ShellContext) error {
 	case "list", "ls":
 		return wc.handleList(ctx.Args[2:])
 	case "switch", "use":
-		return wc.handleSwitch(ctx.Args[2:])
+		return wc.handleSwitch(ctx, ctx.Args[2:])
 	case "delete", "remove", "rm":
 		return wc.handleDelete(ctx.Args[2:])
 	case "current":
@@ -53,12 +63,24 @@ func (wc *WorkspaceCommand) Execute(ctx *ShellContext) error {
 		return wc.handleExport(ctx.Args[2:])
 	case "import":
 		return wc.handleImport(ctx.Args[2:])
+	case "set":
+		return wc.handleSet(ctx.Args[2:])
 	case "stats":
 		return wc.handleStats()
 	case "search":
 		return wc.handleSearch(ctx.Args[2:])
 	case "query":
 		return wc.handleQuery(ctx.Args[2:])
+	case "template":
+		return wc.handleTemplate(ctx, ctx.Args[2:])
+	case "sync":
+		return wc.handleSync(ctx.Args[2:])
+	case "redact":
+		return wc.handleRedact(ctx.Args[2:])
+	case "encrypt":
+		return wc.handleEncrypt(ctx.Args[2:], true)
+	case "decrypt":
+		return wc.handleEncrypt(ctx.Args[2:], false)
 	default:
 		return fmt.Errorf("unknown workspace subcommand: %s", subcommand)
 	}
@@ -68,7 +90,7 @@ func (wc *WorkspaceCommand) Execute(ctx *ShellContext) error {
 func (wc *WorkspaceCommand) GetCompletions(partial string, args []string) []string {
 	if len(args) == 0 {
 		// Complete subcommands
-		subcommands := []string{"create", "list", "switch", "delete", "current", "info", "export", "import", "stats", "search", "query"}
+		subcommands := []string{"create", "list", "switch", "delete", "current", "info", "export", "import", "set", "stats", "search", "query", "template", "sync", "redact", "encrypt", "decrypt"}
 		var completions []string
 		for _, cmd := range subcommands {
 			if partial == "" || strings.HasPrefix(cmd, partial) {
@@ -81,9 +103,33 @@ func (wc *WorkspaceCommand) GetCompletions(partial string, args []string) []stri
 	if len(args) == 1 {
 		subcommand := args[0]
 		switch subcommand {
-		case "switch", "use", "delete", "remove", "rm", "info", "show", "export":
+		case "switch", "use", "delete", "remove", "rm", "info", "show", "export", "encrypt", "decrypt":
 			// Complete with workspace names
 			return wc.getWorkspaceCompletions(partial)
+		case "redact":
+			var completions []string
+			for _, action := range []string{"list", "set", "remove"} {
+				if strings.HasPrefix(action, partial) {
+					completions = append(completions, action)
+				}
+			}
+			return completions
+		case "sync":
+			var completions []string
+			for _, action := range []string{"init", "push", "pull", "status", "autosave"} {
+				if strings.HasPrefix(action, partial) {
+					completions = append(completions, action)
+				}
+			}
+			return completions
+		case "set":
+			var completions []string
+			for _, key := range workspace.SettingKeys {
+				if strings.HasPrefix(key, partial) {
+					completions = append(completions, key)
+				}
+			}
+			return completions
 		}
 	}
 
@@ -139,6 +185,10 @@ func (wc *WorkspaceCommand) handleList(args []string) error {
 		if time.Since(ws.LastUsed) < 24*time.Hour {
 			lastUsed = ws.LastUsed.Format("15:04")
 		}
+		if ws.Encrypted && ws.Created.IsZero() {
+			// Listed without its passphrase, so only the name is known
+			description, lastUsed = "(encrypted)", "-"
+		}
 
 		fmt.Printf("%-20s %-30s %-12s %-8d %s\n",
 			ws.Name, description, lastUsed, ws.UsageCount, marker)
@@ -147,14 +197,125 @@ func (wc *WorkspaceCommand) handleList(args []string) error {
 	return nil
 }
 
-// handleSwitch switches to a different workspace
-func (wc *WorkspaceCommand) handleSwitch(args []string) error {
+// handleSwitch switches to a different workspace. An encrypted workspace
+// whose key is protected by a passphrase that is not set asks for it; the
+// passphrase is then kept for the rest of the session.
+func (wc *WorkspaceCommand) handleSwitch(ctx *ShellContext, args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("usage: workspace switch <name>")
 	}
 
 	name := args[0]
-	return wc.workspaceManager.SwitchWorkspace(name)
+	err := wc.workspaceManager.SwitchWorkspace(name)
+	if !errors.Is(err, secrets.ErrPassphraseRequired) || ctx.ReadSecret == nil {
+		return err
+	}
+
+	passphrase, err := ctx.ReadSecret(fmt.Sprintf("Passphrase for workspace '%s': ", name))
+	if err != nil {
+		return err
+	}
+	secrets.SetPassphrase(strings.TrimSpace(passphrase))
+	if err := wc.workspaceManager.SwitchWorkspace(name); err != nil {
+		secrets.SetPassphrase("")
+		return err
+	}
+	return nil
+}
+
+// handleEncrypt encrypts or decrypts the file of a workspace, the current
+// one by default
+func (wc *WorkspaceCommand) handleEncrypt(args []string, encrypted bool) error {
+	name := wc.workspaceManager.currentName()
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name == "" {
+		return fmt.Errorf("no workspace specified and no current workspace")
+	}
+
+	if err := wc.workspaceManager.SetEncrypted(name, encrypted); err != nil {
+		return err
+	}
+	if encrypted {
+		fmt.Printf("Workspace '%s' is now encrypted at rest\n", name)
+	} else {
+		fmt.Printf("Workspace '%s' is now stored as plain JSON\n", name)
+	}
+	return nil
+}
+
+// handleSet changes an output setting of the current workspace
+func (wc *WorkspaceCommand) handleSet(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: workspace set <%s> <value>", strings.Join(workspace.SettingKeys, "|"))
+	}
+
+	if err := wc.workspaceManager.UpdateSetting(args[0], args[1]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Set %s to %s\n", args[0], args[1])
+	return nil
+}
+
+// handleRedact lists and changes the redaction rule sets of the current
+// workspace
+func (wc *WorkspaceCommand) handleRedact(args []string) error {
+	usage := "usage: workspace redact list | set <set> <column> <hash|drop|truncate:N> | remove <set> [column]"
+	if len(args) == 0 || args[0] == "list" {
+		redactions := wc.workspaceManager.CurrentSettings().Redactions
+		if len(redactions) == 0 {
+			fmt.Println("No redaction rule sets. Add one with: workspace redact set <set> <column> <action>")
+			return nil
+		}
+		names := make([]string, 0, len(redactions))
+		for name := range redactions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s:\n", name)
+			for _, rule := range redactions[name].Rules {
+				fmt.Printf("  %s\n", rule)
+			}
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) != 4 {
+			return fmt.Errorf("%s", usage)
+		}
+		rule, err := query.ParseRedactionRule(args[2], args[3])
+		if err != nil {
+			return err
+		}
+		if err := wc.workspaceManager.SetRedactionRule(args[1], rule); err != nil {
+			return err
+		}
+		fmt.Printf("Redaction rule set %s: %s\n", args[1], rule)
+	case "remove", "rm":
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("%s", usage)
+		}
+		var column string
+		if len(args) == 3 {
+			column = args[2]
+		}
+		if err := wc.workspaceManager.RemoveRedaction(args[1], column); err != nil {
+			return err
+		}
+		if column == "" {
+			fmt.Printf("Removed redaction rule set %s\n", args[1])
+		} else {
+			fmt.Printf("Removed the %s rule from redaction rule set %s\n", column, args[1])
+		}
+	default:
+		return fmt.Errorf("%s", usage)
+	}
+	return nil
 }
 
 // handleDelete removes a workspace
@@ -165,13 +326,21 @@ func (wc *WorkspaceCommand) handleDelete(args []string) error {
 
 	name := args[0]
 
-	// Confirm deletion
 	current := wc.workspaceManager.GetCurrentWorkspace()
 	if current != nil && current.Name == name {
 		fmt.Printf("Warning: You are about to delete the current workspace '%s'\n", name)
 	}
 
-	return wc.workspaceManager.DeleteWorkspace(name)
+	undoID, err := wc.workspaceManager.DeleteWorkspace(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted workspace '%s'\n", name)
+	if undoID != "" {
+		fmt.Printf("Undo with: undo %s\n", undoID)
+	}
+	return nil
 }
 
 // handleCurrent shows the current workspace
@@ -191,6 +360,7 @@ func (wc *WorkspaceCommand) handleCurrent() error {
 	fmt.Printf("Usage count: %d\n", current.UsageCount)
 	fmt.Printf("Saved queries: %d\n", len(current.SavedQueries))
 	fmt.Printf("Job templates: %d\n", len(current.JobTemplates))
+	fmt.Printf("Notebooks: %d\n", len(current.Notebooks))
 
 	return nil
 }
@@ -209,44 +379,45 @@ func (wc *WorkspaceCommand) handleInfo(args []string) error {
 		name = args[0]
 	}
 
-	workspaces := wc.workspaceManager.ListWorkspaces()
-	var workspace *Workspace
-	for _, ws := range workspaces {
-		if ws.Name == name {
-			workspace = ws
-			break
-		}
-	}
-
-	if workspace == nil {
-		return fmt.Errorf("workspace '%s' not found", name)
+	ws, err := wc.workspaceManager.Service().Get(name)
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Workspace: %s\n", workspace.Name)
-	fmt.Printf("Description: %s\n", workspace.Description)
-	fmt.Printf("Created: %s\n", workspace.Created.Format("2006-01-02 15:04:05"))
-	fmt.Printf("Last used: %s\n", workspace.LastUsed.Format("2006-01-02 15:04:05"))
-	fmt.Printf("Usage count: %d\n", workspace.UsageCount)
-	fmt.Printf("Tags: %s\n", strings.Join(workspace.Tags, ", "))
+	fmt.Printf("Workspace: %s\n", ws.Name)
+	fmt.Printf("Description: %s\n", ws.Description)
+	fmt.Printf("Created: %s\n", ws.Created.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Last used: %s\n", ws.LastUsed.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Usage count: %d\n", ws.UsageCount)
+	fmt.Printf("Tags: %s\n", strings.Join(ws.Tags, ", "))
+	fmt.Printf("Encrypted: %t\n", ws.Encrypted)
 
 	fmt.Printf("\nSettings:\n")
-	fmt.Printf("  Default data source: %s\n", workspace.Settings.DefaultDataSource)
-	fmt.Printf("  Auto complete: %t\n", workspace.Settings.AutoComplete)
-	fmt.Printf("  Show timing: %t\n", workspace.Settings.ShowTiming)
-	fmt.Printf("  Pagination size: %d\n", workspace.Settings.PaginationSize)
-	fmt.Printf("  Output format: %s\n", workspace.Settings.OutputFormat)
-	fmt.Printf("  Theme: %s\n", workspace.Settings.Theme)
-
-	fmt.Printf("\nSaved queries (%d):\n", len(workspace.SavedQueries))
-	for name, query := range workspace.SavedQueries {
+	fmt.Printf("  Default data source: %s\n", ws.Settings.DefaultDataSource)
+	fmt.Printf("  Auto complete: %t\n", ws.Settings.AutoComplete)
+	fmt.Printf("  Show timing: %t\n", ws.Settings.ShowTiming)
+	fmt.Printf("  Pagination size: %d\n", ws.Settings.PaginationSize)
+	fmt.Printf("  Output format: %s\n", ws.Settings.OutputFormat)
+	fmt.Printf("  Number locale: %s\n", valueOrNone(ws.Settings.NumberLocale))
+	fmt.Printf("  Date format: %s\n", valueOrNone(ws.Settings.DateFormat))
+	fmt.Printf("  Relative time: %t\n", ws.Settings.RelativeTime)
+	fmt.Printf("  Theme: %s\n", ws.Settings.Theme)
+
+	fmt.Printf("\nSaved queries (%d):\n", len(ws.SavedQueries))
+	for name, query := range ws.SavedQueries {
 		fmt.Printf("  - %s: %s\n", name, query.Description)
 	}
 
-	fmt.Printf("\nJob templates (%d):\n", len(workspace.JobTemplates))
-	for name, template := range workspace.JobTemplates {
+	fmt.Printf("\nJob templates (%d):\n", len(ws.JobTemplates))
+	for name, template := range ws.JobTemplates {
 		fmt.Printf("  - %s: %s\n", name, template.Description)
 	}
 
+	fmt.Printf("\nNotebooks (%d):\n", len(ws.Notebooks))
+	for name, notebook := range ws.Notebooks {
+		fmt.Printf("  - %s: %d cells\n", name, len(notebook.Cells))
+	}
+
 	return nil
 }
 
@@ -443,15 +614,204 @@ func (wc *WorkspaceCommand) handleDeleteQuery(args []string) error {
 		return fmt.Errorf("no active workspace")
 	}
 
-	if _, exists := current.SavedQueries[name]; !exists {
-		return fmt.Errorf("query '%s' not found", name)
+	if err := wc.workspaceManager.DeleteQuery(name); err != nil {
+		return err
 	}
-
-	delete(current.SavedQueries, name)
 	fmt.Printf("Deleted query '%s' from workspace '%s'\n", name, current.Name)
 	return nil
 }
 
+// handleTemplate manages job templates in the current workspace
+func (wc *WorkspaceCommand) handleTemplate(ctx *ShellContext, args []string) error {
+	if len(args) == 0 {
+		return wc.showTemplateUsage()
+	}
+
+	subcommand := args[0]
+
+	switch subcommand {
+	case "save":
+		return wc.handleSaveTemplate(args[1:])
+	case "list", "ls":
+		return wc.handleListTemplates()
+	case "run":
+		return wc.handleRunTemplate(ctx, args[1:])
+	case "delete", "remove", "rm":
+		return wc.handleDeleteTemplate(args[1:])
+	default:
+		return fmt.Errorf("unknown template subcommand: %s", subcommand)
+	}
+}
+
+// handleSaveTemplate saves a job template to the current workspace
+func (wc *WorkspaceCommand) handleSaveTemplate(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: workspace template save <name> <job_type> [key=value...] [--schedule <cron>] [--description <text>]")
+	}
+
+	name := args[0]
+	jobType := args[1]
+	schedule := ""
+	description := ""
+	config := make(map[string]interface{})
+
+	for i := 2; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--schedule" && i+1 < len(args):
+			schedule = args[i+1]
+			i++
+		case arg == "--description" && i+1 < len(args):
+			description = args[i+1]
+			i++
+		case strings.Contains(arg, "="):
+			parts := strings.SplitN(arg, "=", 2)
+			if n, err := strconv.Atoi(parts[1]); err == nil {
+				config[parts[0]] = n
+			} else {
+				config[parts[0]] = parts[1]
+			}
+		default:
+			return fmt.Errorf("invalid template argument: %s (expected key=value)", arg)
+		}
+	}
+
+	if err := wc.workspaceManager.SaveJobTemplate(name, jobType, schedule, description, config); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved job template '%s'\n", name)
+	return nil
+}
+
+// handleListTemplates lists all job templates in the current workspace
+func (wc *WorkspaceCommand) handleListTemplates() error {
+	current := wc.workspaceManager.GetCurrentWorkspace()
+	if current == nil {
+		return fmt.Errorf("no active workspace")
+	}
+
+	if len(current.JobTemplates) == 0 {
+		fmt.Println("No job templates in current workspace")
+		return nil
+	}
+
+	fmt.Printf("Job templates in workspace '%s':\n", current.Name)
+	fmt.Printf("%-20s %-12s %-15s %-8s %s\n", "NAME", "JOB TYPE", "SCHEDULE", "USAGE", "DESCRIPTION")
+	fmt.Println(strings.Repeat("-", 75))
+
+	for _, template := range current.JobTemplates {
+		schedule := template.Schedule
+		if schedule == "" {
+			schedule = "-"
+		}
+
+		description := template.Description
+		if len(description) > 25 {
+			description = description[:22] + "..."
+		}
+
+		fmt.Printf("%-20s %-12s %-15s %-8d %s\n",
+			template.Name, template.JobType, schedule, template.UsageCount, description)
+	}
+
+	return nil
+}
+
+// handleRunTemplate instantiates a job from a template, scheduling it when
+// the template has a cron schedule and submitting it immediately otherwise
+func (wc *WorkspaceCommand) handleRunTemplate(ctx *ShellContext, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: workspace template run <name>")
+	}
+
+	if ctx.Shell == nil || ctx.Shell.jobManager == nil {
+		return fmt.Errorf("job manager not available")
+	}
+
+	current := wc.workspaceManager.GetCurrentWorkspace()
+	if current == nil {
+		return fmt.Errorf("no active workspace")
+	}
+
+	template, ok := current.JobTemplates[args[0]]
+	if !ok {
+		return fmt.Errorf("job template '%s' %w", args[0], workspace.ErrNotFound)
+	}
+
+	if template.Schedule != "" {
+		scheduler := ctx.Shell.jobManager.Scheduler()
+		scheduledJob := &jobs.ScheduledJob{
+			ID:          fmt.Sprintf("template-%s-%s", current.Name, template.Name),
+			Name:        template.Name,
+			JobType:     template.JobType,
+			Config:      template.Config,
+			Schedule:    template.Schedule,
+			Enabled:     true,
+			Tags:        template.Tags,
+			CreatedBy:   "workspace:" + current.Name,
+			Description: template.Description,
+		}
+
+		// A template has one schedule entry: running it again reports the
+		// entry, or updates it when the template has changed since
+		action := "Scheduled"
+		if existing, err := scheduler.GetScheduledJob(scheduledJob.ID); err == nil {
+			if existing.JobType == template.JobType && existing.Schedule == template.Schedule &&
+				reflect.DeepEqual(existing.Config, template.Config) {
+				fmt.Printf("Template '%s' is already scheduled as %s (%s), next run: %s\n",
+					template.Name, existing.ID, existing.Schedule, existing.NextRun.Format("2006-01-02 15:04"))
+				return nil
+			}
+			scheduledJob.Enabled = existing.Enabled
+			scheduledJob.LastRun = existing.LastRun
+			scheduledJob.RunCount = existing.RunCount
+			scheduledJob.FailCount = existing.FailCount
+			action = "Updated the schedule of"
+		}
+
+		if err := scheduler.ScheduleJob(scheduledJob); err != nil {
+			return fmt.Errorf("failed to schedule template '%s': %w", template.Name, err)
+		}
+		wc.recordTemplateUse(template.Name)
+
+		fmt.Printf("%s template '%s' (%s), next run: %s\n",
+			action, template.Name, template.Schedule, scheduledJob.NextRun.Format("2006-01-02 15:04"))
+		return nil
+	}
+
+	jobID, err := ctx.Shell.jobManager.SubmitJobFromConfig(template.JobType, template.Config)
+	if err != nil {
+		return fmt.Errorf("failed to run template '%s': %w", template.Name, err)
+	}
+	wc.recordTemplateUse(template.Name)
+
+	fmt.Printf("Started job %s from template '%s'\n", jobID, template.Name)
+	return nil
+}
+
+// recordTemplateUse counts a use of a template whose job has been submitted
+// or scheduled; failing to save the count does not fail the command
+func (wc *WorkspaceCommand) recordTemplateUse(name string) {
+	if err := wc.workspaceManager.RecordJobTemplateUse(name); err != nil {
+		log.Logger.Warnf("Failed to record use of job template %s: %v", name, err)
+	}
+}
+
+// handleDeleteTemplate removes a job template
+func (wc *WorkspaceCommand) handleDeleteTemplate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: workspace template delete <name>")
+	}
+
+	if err := wc.workspaceManager.DeleteJobTemplate(args[0]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted job template '%s'\n", args[0])
+	return nil
+}
+
 // getWorkspaceCompletions returns workspace names for completion
 func (wc *WorkspaceCommand) getWorkspaceCompletions(partial string) []string {
 	workspaces := wc.workspaceManager.ListWorkspaces()
@@ -466,6 +826,60 @@ func (wc *WorkspaceCommand) getWorkspaceCompletions(partial string) []string {
 	return completions
 }
 
+// handleSync shares the workspaces through a git repository
+func (wc *WorkspaceCommand) handleSync(args []string) error {
+	service := wc.workspaceManager.Service()
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+
+	switch args[0] {
+	case "init":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: workspace sync init <repo>")
+		}
+		if err := service.InitSync(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Workspaces committed and pushed to %s\n", args[1])
+		fmt.Println("Use 'workspace sync push' and 'workspace sync pull' to share changes, or 'workspace sync autosave on'")
+	case "push":
+		if err := service.Push(); err != nil {
+			return err
+		}
+		fmt.Println("Workspaces pushed")
+	case "pull":
+		if err := service.Pull(); err != nil {
+			return err
+		}
+		fmt.Println("Workspaces pulled")
+	case "status":
+		status, err := service.SyncStatus()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Repository: %s\n", status.Remote)
+		fmt.Printf("Autosave: %t\n", status.Autosave)
+		fmt.Printf("Last commit: %s\n", status.LastCommit)
+		if len(status.Changed) == 0 {
+			fmt.Println("No uncommitted changes")
+		} else {
+			fmt.Printf("Uncommitted changes: %s\n", strings.Join(status.Changed, ", "))
+		}
+	case "autosave":
+		if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+			return fmt.Errorf("usage: workspace sync autosave <on|off>")
+		}
+		if err := service.SetAutosave(args[1] == "on"); err != nil {
+			return err
+		}
+		fmt.Printf("Workspace autosave turned %s\n", args[1])
+	default:
+		return fmt.Errorf("unknown workspace sync subcommand: %s (init, push, pull, status, autosave)", args[0])
+	}
+	return nil
+}
+
 // showUsage displays command usage information
 func (wc *WorkspaceCommand) showUsage() error {
 	fmt.Println("Workspace Command Usage:")
@@ -477,13 +891,19 @@ func (wc *WorkspaceCommand) showUsage() error {
 	fmt.Println("  workspace info [name]                     - Show workspace details")
 	fmt.Println("  workspace export <name> <file>            - Export workspace to file")
 	fmt.Println("  workspace import <file>                   - Import workspace from file")
+	fmt.Println("  workspace set <key> <value>               - Change a setting of the current workspace")
 	fmt.Println("  workspace stats                           - Show workspace statistics")
 	fmt.Println("  workspace search <query>                  - Search across workspaces")
 	fmt.Println("  workspace query <subcommand>              - Manage saved queries")
+	fmt.Println("  workspace template <subcommand>           - Manage and run job templates")
+	fmt.Println("  workspace sync <init|push|pull|status>    - Share workspaces through a git repository")
+	fmt.Println("  workspace redact <list|set|remove>        - Manage redaction rules applied to exports with --redact")
+	fmt.Println("  workspace encrypt|decrypt [name]          - Encrypt or decrypt a workspace file at rest")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  workspace create analytics 'Data analysis workspace'")
 	fmt.Println("  workspace switch analytics")
+	fmt.Println("  workspace set output_format csv")
 	fmt.Println("  workspace query save top_stories 'SELECT title FROM items ORDER BY score DESC LIMIT 10'")
 
 	return nil
@@ -503,3 +923,28 @@ func (wc *WorkspaceCommand) showQueryUsage() error {
 
 	return nil
 }
+
+// showTemplateUsage displays template subcommand usage
+func (wc *WorkspaceCommand) showTemplateUsage() error {
+	fmt.Println("Workspace Template Command Usage:")
+	fmt.Println("  workspace template save <name> <type> [key=value...] - Save a job template")
+	fmt.Println("      [--schedule <cron>] [--description <text>]")
+	fmt.Println("  workspace template list                        - List job templates")
+	fmt.Println("  workspace template run <name>                  - Run or schedule a template")
+	fmt.Println("  workspace template delete <name>               - Delete a job template")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  workspace template save hn-sync download source_name=hackernews batch_size=200")
+	fmt.Println("  workspace template save nightly download source_name=hackernews --schedule \"0 2 * * *\"")
+	fmt.Println("  workspace template run hn-sync")
+
+	return nil
+}
+
+// valueOrNone returns value, or "none" for an unset setting
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
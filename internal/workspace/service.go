@@ -0,0 +1,432 @@
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/secrets"
+	"github.com/brainless/PubDataHub/internal/trash"
+)
+
+// trashKind is the trash entry kind of deleted workspaces
+const trashKind = "workspace"
+
+// trashFile is the file a deleted workspace is kept in within its trash entry
+const trashFile = "workspace.json"
+
+var (
+	// ErrNotFound is wrapped by errors for missing workspaces, saved
+	// queries and job templates
+	ErrNotFound = errors.New("not found")
+	// ErrExists is wrapped by errors for names already taken
+	ErrExists = errors.New("already exists")
+)
+
+// DefaultDir returns the directory workspaces are kept in, in the user's
+// home directory
+func DefaultDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		log.Logger.Warnf("Could not get home directory: %v", err)
+		homeDir = "."
+	}
+	return filepath.Join(homeDir, ".pubdatahub_workspaces")
+}
+
+// Service stores workspaces as one JSON file each in a directory. The shell
+// and the API server both work through it; every call reads the files and
+// every change is written at once, so each sees the other's changes.
+type Service struct {
+	mu    sync.Mutex
+	dir   string
+	trash *trash.Trash // Keeps deleted workspaces for undo; nil deletes them
+	sync  syncState
+}
+
+// NewService creates a service for the workspaces in dir, creating it if needed
+func NewService(dir string) (*Service, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+	s := &Service{dir: dir}
+	s.loadAutosave()
+	return s, nil
+}
+
+// SetTrash makes Delete keep deleted workspaces in bin so they can be
+// restored with undo
+func (s *Service) SetTrash(bin *trash.Trash) {
+	s.mu.Lock()
+	s.trash = bin
+	s.mu.Unlock()
+	bin.RegisterRestorer(trashKind, s.restore)
+}
+
+// List returns all workspaces, most recently used first. Encrypted
+// workspaces that need a passphrase are listed with only their name and
+// Encrypted set; other files that cannot be read are skipped with a warning.
+func (s *Service) List() ([]*Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workspace files: %w", err)
+	}
+
+	workspaces := make([]*Workspace, 0, len(files))
+	for _, file := range files {
+		workspace, err := readWorkspace(file)
+		if errors.Is(err, secrets.ErrPassphraseRequired) {
+			workspace = &Workspace{Name: strings.TrimSuffix(filepath.Base(file), ".json"), Encrypted: true}
+		} else if err != nil {
+			log.Logger.Warnf("Failed to load workspace file %s: %v", file, err)
+			continue
+		}
+		workspaces = append(workspaces, workspace)
+	}
+
+	sort.Slice(workspaces, func(i, j int) bool {
+		return workspaces[i].LastUsed.After(workspaces[j].LastUsed)
+	})
+	return workspaces, nil
+}
+
+// Get returns a workspace by name
+func (s *Service) Get(name string) (*Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(name)
+}
+
+// Create adds an empty workspace with the default settings
+func (s *Service) Create(name, description string) (*Workspace, error) {
+	workspace := New(name, description)
+	if err := s.Import(workspace); err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
+
+// Import adds a complete workspace, such as one read from an export file
+func (s *Service) Import(workspace *Workspace) error {
+	if err := ValidateName(workspace.Name); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.path(workspace.Name)); err == nil {
+		return fmt.Errorf("workspace '%s' %w", workspace.Name, ErrExists)
+	}
+	return s.save(workspace)
+}
+
+// Update applies change to a workspace and saves it, unless change fails.
+// The name cannot be changed.
+func (s *Service) Update(name string, change func(*Workspace) error) (*Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	workspace, err := s.load(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := change(workspace); err != nil {
+		return nil, err
+	}
+	workspace.Name = name
+	if err := s.save(workspace); err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
+
+// SetEncrypted encrypts or decrypts the file of a workspace. Encrypted
+// workspaces are sealed with the key of the secrets store, the passphrase in
+// secrets.PassphraseEnv or else the secrets key file, and are decrypted
+// whenever they are read.
+func (s *Service) SetEncrypted(name string, encrypted bool) (*Workspace, error) {
+	return s.Update(name, func(workspace *Workspace) error {
+		workspace.Encrypted = encrypted
+		return nil
+	})
+}
+
+// Delete removes a workspace, moving it to the trash when one is set. It
+// returns the ID of the trash entry, or "" when the workspace is gone for good.
+func (s *Service) Delete(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	workspace, err := s.load(name)
+	if err != nil {
+		return "", err
+	}
+
+	removeFile := func() error {
+		if err := os.Remove(s.path(name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete workspace file: %w", err)
+		}
+		s.changed(name)
+		return nil
+	}
+
+	if s.trash == nil {
+		return "", removeFile()
+	}
+	entry, err := s.trash.Put(trashKind, fmt.Sprintf("workspace '%s'", name), func(dir string) error {
+		data, err := encodeWorkspace(workspace)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, trashFile), data, 0644); err != nil {
+			return fmt.Errorf("failed to write workspace to the trash: %w", err)
+		}
+		return removeFile()
+	})
+	if err != nil {
+		return "", err
+	}
+	return entry.ID, nil
+}
+
+// SaveQuery adds or replaces a saved query of a workspace. A replaced query
+// keeps when it was created and its usage.
+func (s *Service) SaveQuery(workspaceName string, saved SavedQuery) (SavedQuery, error) {
+	if saved.Name == "" || strings.TrimSpace(saved.Query) == "" {
+		return SavedQuery{}, fmt.Errorf("a saved query needs a name and a query")
+	}
+
+	_, err := s.Update(workspaceName, func(workspace *Workspace) error {
+		now := time.Now()
+		saved.Created, saved.LastUsed = now, now
+		if existing, ok := workspace.SavedQueries[saved.Name]; ok {
+			saved.Created = existing.Created
+			saved.LastUsed = existing.LastUsed
+			saved.UsageCount = existing.UsageCount
+		}
+		if saved.Tags == nil {
+			saved.Tags = []string{}
+		}
+		if workspace.SavedQueries == nil {
+			workspace.SavedQueries = make(map[string]SavedQuery)
+		}
+		workspace.SavedQueries[saved.Name] = saved
+		return nil
+	})
+	return saved, err
+}
+
+// UseQuery returns a saved query and records that it has been used
+func (s *Service) UseQuery(workspaceName, name string) (SavedQuery, error) {
+	var saved SavedQuery
+	_, err := s.Update(workspaceName, func(workspace *Workspace) error {
+		var ok bool
+		if saved, ok = workspace.SavedQueries[name]; !ok {
+			return fmt.Errorf("query '%s' %w", name, ErrNotFound)
+		}
+		saved.LastUsed = time.Now()
+		saved.UsageCount++
+		workspace.SavedQueries[name] = saved
+		return nil
+	})
+	return saved, err
+}
+
+// DeleteQuery removes a saved query from a workspace
+func (s *Service) DeleteQuery(workspaceName, name string) error {
+	_, err := s.Update(workspaceName, func(workspace *Workspace) error {
+		if _, ok := workspace.SavedQueries[name]; !ok {
+			return fmt.Errorf("query '%s' %w", name, ErrNotFound)
+		}
+		delete(workspace.SavedQueries, name)
+		return nil
+	})
+	return err
+}
+
+// SaveJobTemplate adds or replaces a job template of a workspace. A
+// replaced template keeps when it was created and its usage.
+func (s *Service) SaveJobTemplate(workspaceName string, template JobTemplate) (JobTemplate, error) {
+	if template.Name == "" {
+		return JobTemplate{}, fmt.Errorf("template name cannot be empty")
+	}
+	if template.JobType == "" {
+		return JobTemplate{}, fmt.Errorf("template '%s' needs a job type", template.Name)
+	}
+
+	_, err := s.Update(workspaceName, func(workspace *Workspace) error {
+		template.Created = time.Now()
+		if existing, ok := workspace.JobTemplates[template.Name]; ok {
+			template.Created = existing.Created
+			template.UsageCount = existing.UsageCount
+		}
+		if template.Config == nil {
+			template.Config = make(map[string]interface{})
+		}
+		if template.Tags == nil {
+			template.Tags = []string{}
+		}
+		if workspace.JobTemplates == nil {
+			workspace.JobTemplates = make(map[string]JobTemplate)
+		}
+		workspace.JobTemplates[template.Name] = template
+		return nil
+	})
+	return template, err
+}
+
+// RecordJobTemplateUse records that a job template has been used, once
+// its job is submitted or scheduled
+func (s *Service) RecordJobTemplateUse(workspaceName, name string) error {
+	_, err := s.Update(workspaceName, func(workspace *Workspace) error {
+		template, ok := workspace.JobTemplates[name]
+		if !ok {
+			return fmt.Errorf("job template '%s' %w", name, ErrNotFound)
+		}
+		template.UsageCount++
+		workspace.JobTemplates[name] = template
+		return nil
+	})
+	return err
+}
+
+// DeleteJobTemplate removes a job template from a workspace
+func (s *Service) DeleteJobTemplate(workspaceName, name string) error {
+	_, err := s.Update(workspaceName, func(workspace *Workspace) error {
+		if _, ok := workspace.JobTemplates[name]; !ok {
+			return fmt.Errorf("job template '%s' %w", name, ErrNotFound)
+		}
+		delete(workspace.JobTemplates, name)
+		return nil
+	})
+	return err
+}
+
+// restore puts back a workspace deleted to the trash
+func (s *Service) restore(entry *trash.Entry, dir string) error {
+	workspace, err := readWorkspace(filepath.Join(dir, trashFile))
+	if err != nil {
+		return fmt.Errorf("failed to read trashed workspace: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.path(workspace.Name)); err == nil {
+		return fmt.Errorf("a workspace named '%s' exists; delete or rename it first", workspace.Name)
+	}
+	if err := s.save(workspace); err != nil {
+		return err
+	}
+
+	log.Logger.Infof("Restored workspace '%s'", workspace.Name)
+	return nil
+}
+
+// load reads a workspace; the caller holds s.mu
+func (s *Service) load(name string) (*Workspace, error) {
+	if ValidateName(name) != nil {
+		return nil, fmt.Errorf("workspace '%s' %w", name, ErrNotFound)
+	}
+	workspace, err := readWorkspace(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("workspace '%s' %w", name, ErrNotFound)
+	}
+	return workspace, err
+}
+
+// save writes a workspace, replacing its file atomically; the caller holds s.mu
+func (s *Service) save(workspace *Workspace) error {
+	data, err := encodeWorkspace(workspace)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(workspace.Name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save workspace: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save workspace: %w", err)
+	}
+	s.changed(workspace.Name)
+	return nil
+}
+
+// path returns the file of a workspace
+func (s *Service) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// sealedWorkspace is the file of an encrypted workspace; only its name can
+// be read without the key
+type sealedWorkspace struct {
+	Name   string `json:"name"`
+	Sealed []byte `json:"sealed,omitempty"`
+}
+
+// encodeWorkspace returns the file contents of a workspace, sealed with the
+// default secrets store when it is encrypted
+func encodeWorkspace(workspace *Workspace) ([]byte, error) {
+	data, err := json.MarshalIndent(workspace, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal workspace: %w", err)
+	}
+	if !workspace.Encrypted {
+		return data, nil
+	}
+
+	sealed, err := secrets.Default().Seal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt workspace '%s': %w", workspace.Name, err)
+	}
+	return json.MarshalIndent(sealedWorkspace{Name: workspace.Name, Sealed: sealed}, "", "  ")
+}
+
+// readWorkspace reads and parses a workspace file, decrypting it if needed
+func readWorkspace(path string) (*Workspace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var sealed sealedWorkspace
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace file: %w", err)
+	}
+	if sealed.Sealed != nil {
+		if data, err = secrets.Default().Open(sealed.Sealed); err != nil {
+			return nil, fmt.Errorf("failed to decrypt workspace '%s': %w", sealed.Name, err)
+		}
+	}
+
+	var workspace Workspace
+	if err := json.Unmarshal(data, &workspace); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace file: %w", err)
+	}
+	return &workspace, nil
+}
+
+// ValidateName checks that a workspace name can be used as its file name
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("workspace name cannot be empty")
+	}
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid workspace name %q (it cannot contain slashes or start with '.')", name)
+	}
+	return nil
+}
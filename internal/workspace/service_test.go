@@ -0,0 +1,213 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/brainless/PubDataHub/internal/query"
+	"github.com/brainless/PubDataHub/internal/secrets"
+	"github.com/brainless/PubDataHub/internal/trash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_SharesChangesBetweenInstances(t *testing.T) {
+	log.InitLogger(false)
+	dir := t.TempDir()
+
+	shell, err := NewService(dir)
+	require.NoError(t, err)
+	server, err := NewService(dir)
+	require.NoError(t, err)
+
+	_, err = shell.Create("research", "")
+	require.NoError(t, err)
+	_, err = server.Create("research", "")
+	assert.ErrorIs(t, err, ErrExists)
+
+	_, err = server.SaveQuery("research", SavedQuery{Name: "top", Query: "SELECT 1"})
+	require.NoError(t, err)
+
+	saved, err := shell.UseQuery("research", "top")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT 1", saved.Query)
+	assert.Equal(t, 1, saved.UsageCount)
+
+	// Replacing a query keeps its usage
+	_, err = server.SaveQuery("research", SavedQuery{Name: "top", Query: "SELECT 2"})
+	require.NoError(t, err)
+	ws, err := shell.Get("research")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT 2", ws.SavedQueries["top"].Query)
+	assert.Equal(t, 1, ws.SavedQueries["top"].UsageCount)
+
+	assert.ErrorIs(t, shell.DeleteQuery("research", "missing"), ErrNotFound)
+	_, err = shell.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestService_RecordJobTemplateUse(t *testing.T) {
+	log.InitLogger(false)
+	service, err := NewService(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = service.Create("research", "")
+	require.NoError(t, err)
+	_, err = service.SaveJobTemplate("research", JobTemplate{Name: "nightly", JobType: "download"})
+	require.NoError(t, err)
+
+	require.NoError(t, service.RecordJobTemplateUse("research", "nightly"))
+	require.NoError(t, service.RecordJobTemplateUse("research", "nightly"))
+	assert.ErrorIs(t, service.RecordJobTemplateUse("research", "missing"), ErrNotFound)
+
+	ws, err := service.Get("research")
+	require.NoError(t, err)
+	assert.Equal(t, 2, ws.JobTemplates["nightly"].UsageCount)
+}
+
+func TestService_Update(t *testing.T) {
+	log.InitLogger(false)
+	service, err := NewService(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = service.Create("research", "before")
+	require.NoError(t, err)
+
+	_, err = service.Update("research", func(ws *Workspace) error {
+		ws.Description = "after"
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+
+	ws, err := service.Get("research")
+	require.NoError(t, err)
+	assert.Equal(t, "before", ws.Description, "a failed change is not saved")
+
+	ws, err = service.Update("research", func(ws *Workspace) error {
+		ws.Name = "renamed"
+		ws.Description = "after"
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "research", ws.Name)
+	assert.Equal(t, "after", ws.Description)
+}
+
+func TestService_DeleteToTrash(t *testing.T) {
+	log.InitLogger(false)
+	dir := t.TempDir()
+	service, err := NewService(filepath.Join(dir, "workspaces"))
+	require.NoError(t, err)
+	bin, err := trash.New(filepath.Join(dir, "trash"), time.Hour)
+	require.NoError(t, err)
+	service.SetTrash(bin)
+
+	_, err = service.Create("research", "")
+	require.NoError(t, err)
+
+	undoID, err := service.Delete("research")
+	require.NoError(t, err)
+	assert.NotEmpty(t, undoID)
+	_, err = service.Get("research")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = bin.Restore(undoID)
+	require.NoError(t, err)
+	_, err = service.Get("research")
+	assert.NoError(t, err)
+}
+
+func TestService_Encrypted(t *testing.T) {
+	log.InitLogger(false)
+	defer secrets.SetDefault(secrets.Default())
+	secrets.SetDefault(secrets.New(t.TempDir()))
+	service, err := NewService(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = service.Create("research", "")
+	require.NoError(t, err)
+	_, err = service.SaveQuery("research", SavedQuery{Name: "vip", Query: "SELECT * FROM users WHERE email = 'ceo@example.com'"})
+	require.NoError(t, err)
+	_, err = service.SetEncrypted("research", true)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(service.path("research"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "ceo@example.com")
+	ws, err := service.Get("research")
+	require.NoError(t, err)
+	assert.True(t, ws.Encrypted)
+	assert.Contains(t, ws.SavedQueries["vip"].Query, "ceo@example.com")
+
+	_, err = service.SetEncrypted("research", false)
+	require.NoError(t, err)
+	data, err = os.ReadFile(service.path("research"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "ceo@example.com")
+
+	// Without the passphrase a workspace is listed but cannot be read
+	secrets.SetPassphrase("correct horse")
+	_, err = service.SetEncrypted("research", true)
+	secrets.SetPassphrase("")
+	require.NoError(t, err)
+	_, err = service.Get("research")
+	assert.ErrorIs(t, err, secrets.ErrPassphraseRequired)
+	workspaces, err := service.List()
+	require.NoError(t, err)
+	require.Len(t, workspaces, 1)
+	assert.Equal(t, "research", workspaces[0].Name)
+	assert.True(t, workspaces[0].Encrypted)
+
+	secrets.SetPassphrase("correct horse")
+	defer secrets.SetPassphrase("")
+	ws, err = service.Get("research")
+	require.NoError(t, err)
+	assert.Len(t, ws.SavedQueries, 1)
+}
+
+func TestValidateName(t *testing.T) {
+	assert.NoError(t, ValidateName("research"))
+	for _, name := range []string{"", "../escape", "a/b", `a\b`, ".hidden"} {
+		assert.Error(t, ValidateName(name), name)
+	}
+
+	service, err := NewService(t.TempDir())
+	require.NoError(t, err)
+	_, err = service.Create("../escape", "")
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(filepath.Dir(service.dir), "escape.json"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestService_Redactions(t *testing.T) {
+	log.InitLogger(false)
+	service, err := NewService(t.TempDir())
+	require.NoError(t, err)
+	_, err = service.Create("research", "")
+	require.NoError(t, err)
+
+	_, err = service.Update("research", func(ws *Workspace) error {
+		if err := ws.Settings.SetRedactionRule("public", query.RedactionRule{Column: "by", Action: query.RedactHash}); err != nil {
+			return err
+		}
+		return ws.Settings.SetRedactionRule("public", query.RedactionRule{Column: "text", Action: query.RedactTruncate, Length: 100})
+	})
+	require.NoError(t, err)
+
+	ws, err := service.Get("research")
+	require.NoError(t, err)
+	public, err := ws.Settings.Redaction("public")
+	require.NoError(t, err)
+	assert.Len(t, public.Rules, 2)
+	assert.NotEmpty(t, public.Salt, "the salt is kept, so hashes match between exports")
+	assert.NoError(t, ws.Settings.Validate())
+
+	assert.Error(t, ws.Settings.SetRedactionRule("public", query.RedactionRule{Column: "by", Action: "mask"}))
+	assert.Error(t, ws.Settings.RemoveRedaction("public", "title"))
+	require.NoError(t, ws.Settings.RemoveRedaction("public", ""))
+	_, err = ws.Settings.Redaction("public")
+	assert.Error(t, err)
+}
@@ -0,0 +1,277 @@
+package workspace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+)
+
+// syncBranch is the branch workspaces are committed to
+const syncBranch = "main"
+
+// autosaveConfigKey is the git config key of the workspace repository that
+// turns autosave on
+const autosaveConfigKey = "pubdatahub.autosave"
+
+// autosaveDelay is how long autosave waits after a change, so a burst of
+// changes becomes one commit
+var autosaveDelay = 2 * time.Second
+
+// ErrSyncNotInitialized is returned by sync operations before sync init
+var ErrSyncNotInitialized = errors.New("workspace sync is not set up; run 'workspace sync init <repo>'")
+
+// SyncStatus describes the git repository workspaces are synced with
+type SyncStatus struct {
+	Remote     string
+	Autosave   bool
+	LastCommit string   // Hash, date and subject of the last commit
+	Changed    []string // Files changed since the last commit
+}
+
+// syncState is the autosave state of a service
+type syncState struct {
+	mu       sync.Mutex // Serializes git commands
+	autosave bool
+	pending  map[string]bool // Workspaces changed since the last autosave
+	timer    *time.Timer
+}
+
+// git runs a git command in the workspace directory and returns its
+// trimmed output
+func (s *Service) git(args ...string) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("workspace sync needs git installed: %w", err)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = s.dir
+	// Never wait for credentials on a terminal the shell owns
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if text := strings.TrimSpace(stderr.String()); text != "" {
+			return "", fmt.Errorf("git %s failed: %s", args[0], text)
+		}
+		return "", fmt.Errorf("git %s failed: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// syncInitialized reports whether the workspace directory is a repository
+// with a remote
+func (s *Service) syncInitialized() bool {
+	if _, err := os.Stat(filepath.Join(s.dir, ".git")); err != nil {
+		return false
+	}
+	_, err := s.git("remote", "get-url", "origin")
+	return err == nil
+}
+
+// InitSync makes the workspace directory a git repository pushing to repo.
+// Workspaces already in repo are merged with the local ones, then every
+// workspace is committed and pushed.
+func (s *Service) InitSync(repo string) error {
+	s.sync.mu.Lock()
+	defer s.sync.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(s.dir, ".git")); os.IsNotExist(err) {
+		if _, err := s.git("init", "--quiet"); err != nil {
+			return err
+		}
+		if _, err := s.git("symbolic-ref", "HEAD", "refs/heads/"+syncBranch); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, ".gitignore"), []byte("*.tmp\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write .gitignore: %w", err)
+	}
+
+	if _, err := s.git("remote", "get-url", "origin"); err == nil {
+		if _, err := s.git("remote", "set-url", "origin", repo); err != nil {
+			return err
+		}
+	} else if _, err := s.git("remote", "add", "origin", repo); err != nil {
+		return err
+	}
+
+	if err := s.commit("Add workspaces"); err != nil {
+		return err
+	}
+	if _, err := s.git("fetch", "--quiet", "origin"); err != nil {
+		return err
+	}
+	if _, err := s.git("rev-parse", "--verify", "--quiet", "origin/"+syncBranch); err == nil {
+		if err := s.merge("--allow-unrelated-histories"); err != nil {
+			return err
+		}
+	}
+	_, err := s.git("push", "--quiet", "--set-upstream", "origin", syncBranch)
+	return err
+}
+
+// Push commits the changed workspaces and pushes them
+func (s *Service) Push() error {
+	s.sync.mu.Lock()
+	defer s.sync.mu.Unlock()
+
+	if !s.syncInitialized() {
+		return ErrSyncNotInitialized
+	}
+	if err := s.commit("Update workspaces"); err != nil {
+		return err
+	}
+	_, err := s.git("push", "--quiet", "origin", syncBranch)
+	return err
+}
+
+// Pull commits the changed workspaces and merges the ones pushed from
+// other machines
+func (s *Service) Pull() error {
+	s.sync.mu.Lock()
+	defer s.sync.mu.Unlock()
+
+	if !s.syncInitialized() {
+		return ErrSyncNotInitialized
+	}
+	if err := s.commit("Update workspaces"); err != nil {
+		return err
+	}
+	if _, err := s.git("fetch", "--quiet", "origin"); err != nil {
+		return err
+	}
+	return s.merge()
+}
+
+// merge merges the remote branch. On a conflict the merge is aborted, so
+// the local workspaces stay as they were.
+func (s *Service) merge(flags ...string) error {
+	args := append(s.identity(), "merge", "--quiet", "--no-edit")
+	args = append(args, flags...)
+	if _, err := s.git(append(args, "origin/"+syncBranch)...); err != nil {
+		s.git("merge", "--abort")
+		return fmt.Errorf("workspaces changed here and in the repository conflict; resolve them with git in %s: %w", s.dir, err)
+	}
+	return nil
+}
+
+// commit commits every change in the workspace directory, if there is one;
+// the caller holds s.sync.mu
+func (s *Service) commit(message string) error {
+	if _, err := s.git("add", "--all"); err != nil {
+		return err
+	}
+	if _, err := s.git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	_, err := s.git(append(s.identity(), "commit", "--quiet", "-m", message)...)
+	return err
+}
+
+// identity returns the git options naming the author of commits and merges
+// when git has no user configured
+func (s *Service) identity() []string {
+	if email, _ := s.git("config", "user.email"); email != "" {
+		return nil
+	}
+	return []string{"-c", "user.name=PubDataHub", "-c", "user.email=pubdatahub@localhost"}
+}
+
+// SyncStatus returns the repository workspaces are synced with
+func (s *Service) SyncStatus() (*SyncStatus, error) {
+	s.sync.mu.Lock()
+	defer s.sync.mu.Unlock()
+
+	if !s.syncInitialized() {
+		return nil, ErrSyncNotInitialized
+	}
+	status := &SyncStatus{}
+	status.Remote, _ = s.git("remote", "get-url", "origin")
+	autosave, _ := s.git("config", "--get", autosaveConfigKey)
+	status.Autosave = autosave == "true"
+	status.LastCommit, _ = s.git("log", "-1", "--format=%h %ad %s", "--date=short")
+	changed, err := s.git("ls-files", "--modified", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	if changed != "" {
+		status.Changed = strings.Split(changed, "\n")
+	}
+	return status, nil
+}
+
+// SetAutosave turns autosave on or off. With autosave, changed workspaces
+// are committed and pushed shortly after each change. The setting is kept
+// in the repository's git config, so processes opening the directory later,
+// such as the API server, follow it.
+func (s *Service) SetAutosave(enabled bool) error {
+	s.sync.mu.Lock()
+	defer s.sync.mu.Unlock()
+
+	if !s.syncInitialized() {
+		return ErrSyncNotInitialized
+	}
+	if _, err := s.git("config", autosaveConfigKey, fmt.Sprint(enabled)); err != nil {
+		return err
+	}
+	s.sync.autosave = enabled
+	return nil
+}
+
+// loadAutosave reads whether autosave is on for the workspace directory
+func (s *Service) loadAutosave() {
+	if _, err := os.Stat(filepath.Join(s.dir, ".git")); err != nil {
+		return
+	}
+	value, _ := s.git("config", "--get", autosaveConfigKey)
+	s.sync.autosave = value == "true"
+}
+
+// changed schedules an autosave of a changed workspace when autosave is on
+func (s *Service) changed(name string) {
+	s.sync.mu.Lock()
+	defer s.sync.mu.Unlock()
+
+	if !s.sync.autosave {
+		return
+	}
+	if s.sync.pending == nil {
+		s.sync.pending = make(map[string]bool)
+	}
+	s.sync.pending[name] = true
+	if s.sync.timer == nil {
+		s.sync.timer = time.AfterFunc(autosaveDelay, s.autosave)
+	}
+}
+
+// autosave commits and pushes the workspaces changed since the last one.
+// Failures are logged; the changes are pushed with the next autosave or push.
+func (s *Service) autosave() {
+	s.sync.mu.Lock()
+	defer s.sync.mu.Unlock()
+
+	names := make([]string, 0, len(s.sync.pending))
+	for name := range s.sync.pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	s.sync.pending = nil
+	s.sync.timer = nil
+
+	if err := s.commit("Autosave " + strings.Join(names, ", ")); err != nil {
+		log.Logger.Warnf("Workspace autosave failed: %v", err)
+		return
+	}
+	if _, err := s.git("push", "--quiet", "origin", syncBranch); err != nil {
+		log.Logger.Warnf("Workspace autosave could not push: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package workspace
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRemote creates an empty bare repository to sync with
+func newRemote(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	remote := filepath.Join(t.TempDir(), "workspaces.git")
+	require.NoError(t, exec.Command("git", "init", "--quiet", "--bare", remote).Run())
+	return remote
+}
+
+func TestService_SyncPushAndPull(t *testing.T) {
+	log.InitLogger(false)
+	remote := newRemote(t)
+
+	laptop, err := NewService(t.TempDir())
+	require.NoError(t, err)
+	assert.ErrorIs(t, laptop.Push(), ErrSyncNotInitialized)
+
+	_, err = laptop.Create("research", "")
+	require.NoError(t, err)
+	require.NoError(t, laptop.InitSync(remote))
+
+	// A second machine merges its own workspaces with the repository's
+	desktop, err := NewService(t.TempDir())
+	require.NoError(t, err)
+	_, err = desktop.Create("reports", "")
+	require.NoError(t, err)
+	require.NoError(t, desktop.InitSync(remote))
+	_, err = desktop.Get("research")
+	require.NoError(t, err)
+
+	_, err = desktop.SaveQuery("research", SavedQuery{Name: "top", Query: "SELECT 1"})
+	require.NoError(t, err)
+	status, err := desktop.SyncStatus()
+	require.NoError(t, err)
+	assert.Equal(t, remote, status.Remote)
+	assert.NotEmpty(t, status.Changed)
+	require.NoError(t, desktop.Push())
+
+	require.NoError(t, laptop.Pull())
+	ws, err := laptop.Get("research")
+	require.NoError(t, err)
+	require.Len(t, ws.SavedQueries, 1)
+	assert.Equal(t, "SELECT 1", ws.SavedQueries["top"].Query)
+	_, err = laptop.Get("reports")
+	assert.NoError(t, err)
+
+	status, err = laptop.SyncStatus()
+	require.NoError(t, err)
+	assert.Empty(t, status.Changed)
+}
+
+func TestService_SyncAutosave(t *testing.T) {
+	log.InitLogger(false)
+	remote := newRemote(t)
+	defer func(delay time.Duration) { autosaveDelay = delay }(autosaveDelay)
+	autosaveDelay = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	service, err := NewService(dir)
+	require.NoError(t, err)
+	require.NoError(t, service.InitSync(remote))
+	require.NoError(t, service.SetAutosave(true))
+
+	// The setting is kept with the repository
+	reopened, err := NewService(dir)
+	require.NoError(t, err)
+	status, err := reopened.SyncStatus()
+	require.NoError(t, err)
+	assert.True(t, status.Autosave)
+
+	_, err = service.Create("research", "")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		output, err := exec.Command("git", "--git-dir", remote, "log", "-1", "--format=%s", syncBranch).Output()
+		return err == nil && string(output) == "Autosave research\n"
+	}, 5*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,274 @@
+// Package workspace stores workspaces: named collections of saved queries,
+// job templates, notebooks and settings shared by the shell and the API.
+package workspace
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brainless/PubDataHub/internal/query"
+)
+
+// Workspace represents a saved workspace containing queries, settings, and state
+type Workspace struct {
+	Name         string                     `json:"name"`
+	Description  string                     `json:"description"`
+	Created      time.Time                  `json:"created"`
+	LastUsed     time.Time                  `json:"last_used"`
+	SavedQueries map[string]SavedQuery      `json:"saved_queries"`
+	JobTemplates map[string]JobTemplate     `json:"job_templates"`
+	Notebooks    map[string]*query.Notebook `json:"notebooks,omitempty"`
+	Settings     Settings                   `json:"settings"`
+	Sessions     map[string]SessionData     `json:"sessions"`
+	Tags         []string                   `json:"tags"`
+	UsageCount   int                        `json:"usage_count"`
+	Encrypted    bool                       `json:"encrypted,omitempty"`
+}
+
+// SavedQuery represents a saved query in a workspace
+type SavedQuery struct {
+	Name        string    `json:"name"`
+	Query       string    `json:"query"`
+	DataSource  string    `json:"data_source"`
+	Description string    `json:"description"`
+	Tags        []string  `json:"tags"`
+	Created     time.Time `json:"created"`
+	LastUsed    time.Time `json:"last_used"`
+	UsageCount  int       `json:"usage_count"`
+	IsFavorite  bool      `json:"is_favorite"`
+}
+
+// JobTemplate represents a saved job configuration
+type JobTemplate struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	JobType     string                 `json:"job_type"`
+	Config      map[string]interface{} `json:"config"`
+	Schedule    string                 `json:"schedule,omitempty"`
+	Tags        []string               `json:"tags"`
+	Created     time.Time              `json:"created"`
+	UsageCount  int                    `json:"usage_count"`
+}
+
+// SessionData represents saved session state
+type SessionData struct {
+	DataSource    string            `json:"data_source"`
+	QueryHistory  []string          `json:"query_history"`
+	Settings      map[string]string `json:"settings"`
+	LastQuery     string            `json:"last_query"`
+	LastTimestamp time.Time         `json:"last_timestamp"`
+}
+
+// Settings contains workspace-specific configuration
+type Settings struct {
+	DefaultDataSource string            `json:"default_data_source"`
+	AutoComplete      bool              `json:"auto_complete"`
+	ShowTiming        bool              `json:"show_timing"`
+	PaginationSize    int               `json:"pagination_size"`
+	OutputFormat      string            `json:"output_format"`
+	NumberLocale      string            `json:"number_locale,omitempty"`
+	DateFormat        string            `json:"date_format,omitempty"`
+	RelativeTime      bool              `json:"relative_time,omitempty"`
+	CustomVariables   map[string]string `json:"custom_variables"`
+	Theme             string            `json:"theme"`
+	Prompt            string            `json:"prompt,omitempty"`
+
+	// Redactions are rule sets applied to exported results by name, so
+	// they can be shared without usernames or emails
+	Redactions map[string]query.Redaction `json:"redactions,omitempty"`
+}
+
+// Output formats for query results in the shell
+const (
+	OutputFormatTable = "table"
+	OutputFormatCSV   = "csv"
+	OutputFormatTSV   = "tsv"
+	OutputFormatJSON  = "json"
+)
+
+// PromptSegments are the placeholders a prompt template can contain
+var PromptSegments = []string{"{ws}", "{source}", "{jobs}", "{last}"}
+
+// promptPlaceholder matches a placeholder in a prompt template
+var promptPlaceholder = regexp.MustCompile(`\{[a-z]+\}`)
+
+// ValidatePrompt checks that a prompt template only uses known segments
+func ValidatePrompt(template string) error {
+	for _, placeholder := range promptPlaceholder.FindAllString(template, -1) {
+		if !slices.Contains(PromptSegments, placeholder) {
+			return fmt.Errorf("unknown prompt segment %s (supported: %s)", placeholder, strings.Join(PromptSegments, ", "))
+		}
+	}
+	return nil
+}
+
+// DefaultSettings returns the settings of a new workspace, also used for
+// queries when no workspace is active
+func DefaultSettings() Settings {
+	return Settings{
+		DefaultDataSource: "hackernews",
+		AutoComplete:      true,
+		ShowTiming:        true,
+		PaginationSize:    20,
+		OutputFormat:      OutputFormatTable,
+		CustomVariables:   make(map[string]string),
+		Theme:             "default",
+	}
+}
+
+// SettingKeys are the settings that Set changes
+var SettingKeys = []string{"output_format", "pagination_size", "show_timing", "number_locale", "date_format", "relative_time", "default_data_source", "prompt"}
+
+// Set changes a setting by the key used in workspace settings files
+func (s *Settings) Set(key, value string) error {
+	switch key {
+	case "output_format":
+		switch value {
+		case OutputFormatTable, OutputFormatCSV, OutputFormatTSV, OutputFormatJSON:
+			s.OutputFormat = value
+		default:
+			return fmt.Errorf("invalid output format %q (supported: table, csv, tsv, json)", value)
+		}
+	case "pagination_size":
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return fmt.Errorf("pagination_size must be a number of rows, or 0 for all rows")
+		}
+		s.PaginationSize = size
+	case "show_timing":
+		show, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("show_timing must be true or false")
+		}
+		s.ShowTiming = show
+	case "number_locale":
+		if value == "none" {
+			value = ""
+		}
+		if err := query.ValidateNumberLocale(value); err != nil {
+			return err
+		}
+		s.NumberLocale = value
+	case "date_format":
+		if value == "none" {
+			value = ""
+		} else if _, err := query.DateLayout(value); err != nil {
+			return err
+		}
+		s.DateFormat = value
+	case "relative_time":
+		relative, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("relative_time must be true or false")
+		}
+		s.RelativeTime = relative
+	case "default_data_source":
+		s.DefaultDataSource = value
+	case "prompt":
+		if value == "none" {
+			value = ""
+		}
+		if err := ValidatePrompt(value); err != nil {
+			return err
+		}
+		s.Prompt = value
+	default:
+		return fmt.Errorf("unknown setting %q (supported: %s)", key, strings.Join(SettingKeys, ", "))
+	}
+	return nil
+}
+
+// Validate checks settings given as a whole, such as through the API, the
+// way Set checks them one at a time
+func (s Settings) Validate() error {
+	switch s.OutputFormat {
+	case OutputFormatTable, OutputFormatCSV, OutputFormatTSV, OutputFormatJSON:
+	default:
+		return fmt.Errorf("invalid output format %q (supported: table, csv, tsv, json)", s.OutputFormat)
+	}
+	if s.PaginationSize < 0 {
+		return fmt.Errorf("pagination_size must be a number of rows, or 0 for all rows")
+	}
+	if err := query.ValidateNumberLocale(s.NumberLocale); err != nil {
+		return err
+	}
+	if s.DateFormat != "" {
+		if _, err := query.DateLayout(s.DateFormat); err != nil {
+			return err
+		}
+	}
+	for name, redaction := range s.Redactions {
+		if err := redaction.Validate(); err != nil {
+			return fmt.Errorf("redaction rule set %s: %w", name, err)
+		}
+	}
+	return ValidatePrompt(s.Prompt)
+}
+
+// Redaction returns the redaction rule set called name
+func (s Settings) Redaction(name string) (query.Redaction, error) {
+	redaction, ok := s.Redactions[name]
+	if !ok {
+		return query.Redaction{}, fmt.Errorf("unknown redaction rule set %q; add rules with 'workspace redact set %s <column> <action>'", name, name)
+	}
+	return redaction, nil
+}
+
+// SetRedactionRule adds a rule to the redaction rule set called name,
+// creating the set if needed
+func (s *Settings) SetRedactionRule(name string, rule query.RedactionRule) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("redaction rule set name cannot be empty")
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if s.Redactions == nil {
+		s.Redactions = make(map[string]query.Redaction)
+	}
+	redaction, ok := s.Redactions[name]
+	if !ok {
+		redaction = query.NewRedaction()
+	}
+	redaction.SetRule(rule)
+	s.Redactions[name] = redaction
+	return nil
+}
+
+// RemoveRedaction removes the rule for column from the redaction rule set
+// called name, or the whole set when column is empty
+func (s *Settings) RemoveRedaction(name, column string) error {
+	redaction, err := s.Redaction(name)
+	if err != nil {
+		return err
+	}
+	if column == "" {
+		delete(s.Redactions, name)
+		return nil
+	}
+	if !redaction.RemoveRule(column) {
+		return fmt.Errorf("redaction rule set %s has no rule for %s", name, column)
+	}
+	s.Redactions[name] = redaction
+	return nil
+}
+
+// New returns an empty workspace with the default settings
+func New(name, description string) *Workspace {
+	now := time.Now()
+	return &Workspace{
+		Name:         name,
+		Description:  description,
+		Created:      now,
+		LastUsed:     now,
+		SavedQueries: make(map[string]SavedQuery),
+		JobTemplates: make(map[string]JobTemplate),
+		Sessions:     make(map[string]SessionData),
+		Tags:         make([]string, 0),
+		Settings:     DefaultSettings(),
+	}
+}